@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/aqua777/go-llamaindex/callbacks"
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/memory"
 	"github.com/aqua777/go-llamaindex/tools"
@@ -426,6 +427,22 @@ func (s *AgentTestSuite) TestReActAgentDirectAnswer() {
 	s.Empty(response.ToolCalls)
 }
 
+func (s *AgentTestSuite) TestReActAgentWithLimitedLLM() {
+	limiter := llm.NewConcurrencyLimiter(llm.WithModelConcurrency("mock", 1))
+	limited := llm.NewLimitedLLM(NewMockLLM("Thought: I can answer this directly.\nAnswer: 42."), limiter,
+		llm.WithLimitedLLMModel("mock"))
+
+	agent := NewReActAgent(WithAgentLLM(limited))
+	response, err := agent.Chat(context.Background(), "What is the answer?")
+
+	s.NoError(err)
+	s.Equal("42.", response.Response)
+	stats := limiter.Stats()
+	s.Require().Len(stats, 1)
+	s.Equal(uint64(1), stats[0].Acquired)
+	s.Zero(stats[0].InFlight)
+}
+
 func (s *AgentTestSuite) TestReActAgentWithToolCall() {
 	mockLLM := NewMockLLM(
 		`Thought: I need to search for this.
@@ -825,6 +842,33 @@ Action Input: {}`,
 	assert.NotEmpty(t, response.Response)
 }
 
+func TestReActAgentCallbacks(t *testing.T) {
+	debug := callbacks.NewDebugHandler(callbacks.WithPrintTraceOnEnd(false))
+	manager := callbacks.NewCallbackManager(callbacks.WithHandlers([]callbacks.CallbackHandler{debug}))
+	ctx := callbacks.ContextWithManager(context.Background(), manager)
+
+	mockLLM := NewMockLLM(
+		`Thought: I need to use a tool.
+Action: lookup
+Action Input: {"input": "x"}`,
+		"Thought: I can answer.\nAnswer: Done.",
+	)
+	agent := NewReActAgentFromDefaults(mockLLM, []tools.Tool{NewMockTool("lookup", "Looks things up", nil)})
+
+	_, err := agent.Chat(ctx, "Do something")
+	require.NoError(t, err)
+
+	steps := debug.EventPairs(callbacks.CBEventTypeAgentStep)
+	require.Len(t, steps, 2)
+	assert.Equal(t, 1, steps[0].StartPayload["iteration"])
+
+	calls := debug.EventPairs(callbacks.CBEventTypeFunctionCall)
+	require.Len(t, calls, 1)
+	assert.Equal(t, steps[0].EventID, calls[0].ParentID)
+	assert.Equal(t, "lookup", calls[0].StartPayload[string(callbacks.EventPayloadTool)])
+	assert.Equal(t, "Mock output", calls[0].EndPayload[string(callbacks.EventPayloadFunctionOutput)])
+}
+
 func TestReActAgentMaxIterations(t *testing.T) {
 	// LLM that always wants to use tools
 	mockLLM := NewMockLLM()
@@ -1010,3 +1054,106 @@ func TestExtractResponseFromReasoning(t *testing.T) {
 	response = ExtractResponseFromReasoning([]BaseReasoningStep{})
 	assert.Empty(t, response)
 }
+
+func TestIsNumericQuestion(t *testing.T) {
+	assert.True(t, IsNumericQuestion("What is 25 * 4?"))
+	assert.True(t, IsNumericQuestion("How much do 3 books at $12.50 cost?"))
+	assert.True(t, IsNumericQuestion("Calculate the average of 4, 8 and 15"))
+	assert.False(t, IsNumericQuestion("What is the capital of France?"))
+	assert.False(t, IsNumericQuestion("Summarize chapter 3"))
+}
+
+func TestProgramOfThought(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Solve substitutes computed values", func(t *testing.T) {
+		mockLLM := NewMockLLM("```\nbooks = 3\nprice = 12.5\nanswer = books * price\n```\nANSWER: The books cost ${answer} ({books} at ${price} each).")
+		pot := NewProgramOfThought(mockLLM)
+
+		result, err := pot.Solve(ctx, "How much do 3 books at $12.50 cost?")
+		require.NoError(t, err)
+		assert.Equal(t, "The books cost $37.5 (3 at $12.5 each).", result.Answer)
+		assert.Equal(t, "books = 3\nprice = 12.5\nanswer = books * price", result.Program)
+		assert.Equal(t, 1, result.Attempts)
+		assert.Equal(t, tools.DefaultCodeInterpreterToolName, result.Output.ToolName)
+	})
+
+	t.Run("Failing program is retried with its error", func(t *testing.T) {
+		mockLLM := NewMockLLM(
+			"```\nanswer = total / 0\n```\nANSWER: {answer}",
+			"```python\nanswer = 10 / 4\n```",
+		)
+		pot := NewProgramOfThought(mockLLM)
+
+		result, err := pot.Solve(ctx, "What is 10 divided by 4?")
+		require.NoError(t, err)
+		assert.Equal(t, "2.5", result.Answer)
+		assert.Equal(t, 2, result.Attempts)
+	})
+
+	t.Run("Gives up after retries", func(t *testing.T) {
+		mockLLM := NewMockLLM("answer = nope", "answer = still_nope")
+		pot := NewProgramOfThought(mockLLM, WithProgramOfThoughtRetries(1))
+
+		_, err := pot.Solve(ctx, "What is 1 + 1?")
+		assert.Error(t, err)
+	})
+
+	t.Run("Undefined placeholder is an error", func(t *testing.T) {
+		mockLLM := NewMockLLM("answer = 2\nANSWER: {total}")
+		pot := NewProgramOfThought(mockLLM, WithProgramOfThoughtRetries(0))
+
+		_, err := pot.Solve(ctx, "What is 1 + 1?")
+		assert.Error(t, err)
+	})
+}
+
+func TestAgentProgramOfThought(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ReActAgent answers numeric questions with a program", func(t *testing.T) {
+		potLLM := NewMockLLM("```\nanswer = 25 * 4\n```\nANSWER: 25 times 4 is {answer}.")
+		mem := memory.NewSimpleMemory()
+		agent := NewReActAgent(
+			WithAgentLLM(NewMockLLM()),
+			WithAgentMemory(mem),
+			WithAgentProgramOfThought(NewProgramOfThought(potLLM)),
+		)
+
+		response, err := agent.Chat(ctx, "What is 25 * 4?")
+		require.NoError(t, err)
+		assert.Equal(t, "25 times 4 is 100.", response.Response)
+		require.Len(t, response.ToolCalls, 1)
+		assert.Equal(t, tools.DefaultCodeInterpreterToolName, response.ToolCalls[0].ToolName)
+		assert.Equal(t, true, response.Metadata["program_of_thought"])
+
+		history, err := mem.GetAll(ctx)
+		require.NoError(t, err)
+		assert.Len(t, history, 2)
+	})
+
+	t.Run("Non-numeric questions use the regular loop", func(t *testing.T) {
+		potLLM := NewMockLLM()
+		agent := NewReActAgent(
+			WithAgentLLM(NewMockLLM("Thought: I know this.\nAnswer: Paris.")),
+			WithAgentProgramOfThought(NewProgramOfThought(potLLM)),
+		)
+
+		response, err := agent.Chat(ctx, "What is the capital of France?")
+		require.NoError(t, err)
+		assert.Equal(t, "Paris.", response.Response)
+		assert.Equal(t, 0, potLLM.callCount)
+	})
+
+	t.Run("Failed programs fall back to the regular loop", func(t *testing.T) {
+		potLLM := NewMockLLM("answer = ???")
+		agent := NewFunctionCallingReActAgent(
+			WithAgentLLM(NewMockToolCallingLLM(llm.CompletionResponse{Text: "About 100."})),
+			WithAgentProgramOfThought(NewProgramOfThought(potLLM, WithProgramOfThoughtRetries(0))),
+		)
+
+		response, err := agent.Chat(ctx, "What is 25 * 4?")
+		require.NoError(t, err)
+		assert.Equal(t, "About 100.", response.Response)
+	})
+}
@@ -0,0 +1,252 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/tools"
+)
+
+// DefaultProgramOfThoughtPrompt asks the LLM to answer a numeric question
+// with a program for the code interpreter tool. It uses the {question}
+// placeholder.
+const DefaultProgramOfThoughtPrompt = `Answer the question below by writing a short program instead of doing the arithmetic yourself.
+
+Program rules:
+- One statement per line, in the form: name = expression
+- Expressions may only use numbers, earlier variables, + - * / % ^, parentheses, the constants pi and e, and the functions abs, sqrt, round, floor, ceil, min, max, pow, log, log10, exp, sum and avg.
+- Store the final value in a variable named answer.
+
+Reply with the program in a fenced block, followed by a single line that starts with ANSWER: and states the answer in words. Write {answer}, or any other {variable}, where a computed value goes; do not compute values yourself.
+
+Example:
+` + "```" + `
+price = 12.5
+quantity = 8
+answer = price * quantity
+` + "```" + `
+ANSWER: The order costs ${answer}.
+
+Question: {question}`
+
+// ProgramOfThoughtAnswerVariable is the program variable holding the answer.
+const ProgramOfThoughtAnswerVariable = "answer"
+
+// ProgramOfThoughtResult is the outcome of solving a question with a program.
+type ProgramOfThoughtResult struct {
+	// Program is the program the LLM wrote.
+	Program string
+	// Result holds the program's variables.
+	Result *tools.ProgramResult
+	// Output is the code interpreter tool's output.
+	Output *tools.ToolOutput
+	// Answer is the final answer with computed values substituted.
+	Answer string
+	// Attempts is the number of programs the LLM wrote.
+	Attempts int
+}
+
+// ProgramOfThought answers numeric questions by having the LLM write a small
+// program in the code interpreter's restricted expression language, running
+// it, and substituting the computed values into the LLM's answer. The LLM
+// never does arithmetic itself.
+type ProgramOfThought struct {
+	llm            llm.LLM
+	interpreter    *tools.CodeInterpreterTool
+	promptTemplate string
+	maxRetries     int
+	detector       func(question string) bool
+}
+
+// ProgramOfThoughtOption configures a ProgramOfThought.
+type ProgramOfThoughtOption func(*ProgramOfThought)
+
+// WithProgramOfThoughtPrompt sets the prompt template.
+func WithProgramOfThoughtPrompt(template string) ProgramOfThoughtOption {
+	return func(p *ProgramOfThought) {
+		p.promptTemplate = template
+	}
+}
+
+// WithProgramOfThoughtInterpreter sets the code interpreter tool.
+func WithProgramOfThoughtInterpreter(interpreter *tools.CodeInterpreterTool) ProgramOfThoughtOption {
+	return func(p *ProgramOfThought) {
+		p.interpreter = interpreter
+	}
+}
+
+// WithProgramOfThoughtRetries sets how many times a failing program is sent
+// back to the LLM with its error. Defaults to 1.
+func WithProgramOfThoughtRetries(n int) ProgramOfThoughtOption {
+	return func(p *ProgramOfThought) {
+		p.maxRetries = n
+	}
+}
+
+// WithProgramOfThoughtDetector sets the function that decides which
+// questions are numeric. Defaults to IsNumericQuestion.
+func WithProgramOfThoughtDetector(detector func(question string) bool) ProgramOfThoughtOption {
+	return func(p *ProgramOfThought) {
+		p.detector = detector
+	}
+}
+
+// NewProgramOfThought creates a new ProgramOfThought.
+func NewProgramOfThought(l llm.LLM, opts ...ProgramOfThoughtOption) *ProgramOfThought {
+	p := &ProgramOfThought{
+		llm:            l,
+		interpreter:    tools.NewCodeInterpreterTool(),
+		promptTemplate: DefaultProgramOfThoughtPrompt,
+		maxRetries:     1,
+		detector:       IsNumericQuestion,
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// ShouldUse reports whether question should be answered with a program.
+func (p *ProgramOfThought) ShouldUse(question string) bool {
+	return p.detector != nil && p.detector(question)
+}
+
+// Solve asks the LLM for a program answering question, runs it and returns
+// the answer with the computed values substituted.
+func (p *ProgramOfThought) Solve(ctx context.Context, question string) (*ProgramOfThoughtResult, error) {
+	prompt := strings.ReplaceAll(p.promptTemplate, "{question}", question)
+
+	var lastErr error
+	for attempt := 0; attempt <= p.maxRetries; attempt++ {
+		response, err := p.llm.Complete(ctx, prompt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate program: %w", err)
+		}
+
+		program, template := parseProgramResponse(response)
+		output, err := p.interpreter.Call(ctx, map[string]interface{}{"code": program})
+		if err != nil {
+			lastErr = err
+			prompt += fmt.Sprintf("\n\n%s\n\nThe program above failed: %v\nWrite a corrected program in the same format.", strings.TrimSpace(response), err)
+			continue
+		}
+
+		result, _ := output.RawOutput.(*tools.ProgramResult)
+		answer, err := substituteProgramValues(template, result)
+		if err != nil {
+			return nil, err
+		}
+		return &ProgramOfThoughtResult{
+			Program:  program,
+			Result:   result,
+			Output:   output,
+			Answer:   answer,
+			Attempts: attempt + 1,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("program failed after %d attempts: %w", p.maxRetries+1, lastErr)
+}
+
+// programFencePattern matches a fenced code block with an optional language.
+var programFencePattern = regexp.MustCompile("(?s)```[a-zA-Z]*\\n(.*?)```")
+
+// parseProgramResponse splits the LLM response into the program and the
+// ANSWER: template. Without a fenced block, every line before ANSWER: is
+// taken as the program.
+func parseProgramResponse(response string) (string, string) {
+	var template string
+	body := response
+	if i := strings.LastIndex(strings.ToUpper(response), "ANSWER:"); i >= 0 {
+		template = strings.TrimSpace(strings.SplitN(response[i+len("ANSWER:"):], "\n", 2)[0])
+		body = response[:i]
+	}
+
+	if m := programFencePattern.FindStringSubmatch(response); m != nil {
+		return strings.TrimSpace(m[1]), template
+	}
+	return strings.TrimSpace(body), template
+}
+
+// programPlaceholderPattern matches {variable} placeholders.
+var programPlaceholderPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// substituteProgramValues fills the template's placeholders with program
+// values. An empty template yields the answer variable, or the last
+// assigned variable.
+func substituteProgramValues(template string, result *tools.ProgramResult) (string, error) {
+	if result == nil || len(result.Order) == 0 {
+		return "", fmt.Errorf("program produced no values")
+	}
+
+	if template == "" {
+		if v, ok := result.Value(ProgramOfThoughtAnswerVariable); ok {
+			return tools.FormatNumber(v), nil
+		}
+		return tools.FormatNumber(result.Variables[result.Order[len(result.Order)-1]]), nil
+	}
+
+	var missing []string
+	answer := programPlaceholderPattern.ReplaceAllStringFunc(template, func(m string) string {
+		name := m[1 : len(m)-1]
+		v, ok := result.Value(name)
+		if !ok {
+			missing = append(missing, name)
+			return m
+		}
+		return tools.FormatNumber(v)
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("answer refers to undefined variables: %s", strings.Join(missing, ", "))
+	}
+	return answer, nil
+}
+
+// numericQuestionPattern matches wording that asks for a computation.
+var numericQuestionPattern = regexp.MustCompile(`(?i)\b(how (many|much)|calculate|compute|sum|total|average|mean|percent(age)?|difference|product|times|divided|multipl(y|ied)|plus|minus|ratio|increase|decrease|interest|per)\b|[-+*/^%]\s*\d`)
+
+// IsNumericQuestion reports whether question contains numbers and asks for
+// a computation.
+func IsNumericQuestion(question string) bool {
+	return strings.ContainsAny(question, "0123456789") && numericQuestionPattern.MatchString(question)
+}
+
+// answerWithProgram answers numeric questions with program of thought when
+// it is enabled. It reports false when the question is not numeric or the
+// program failed, so the agent can fall back to its regular loop.
+func (a *BaseAgent) answerWithProgram(ctx context.Context, message string) (*AgentChatResponse, bool) {
+	if a.programOfThought == nil || !a.programOfThought.ShouldUse(message) {
+		return nil, false
+	}
+
+	result, err := a.programOfThought.Solve(ctx, message)
+	if err != nil {
+		if a.verbose {
+			fmt.Printf("[%s] Program of thought failed, falling back: %v\n", a.name, err)
+		}
+		return nil, false
+	}
+
+	if a.memory != nil {
+		if err := a.memory.Put(ctx, llm.NewAssistantMessage(result.Answer)); err != nil {
+			return nil, false
+		}
+	}
+
+	toolCall := NewToolCallResult(result.Output.ToolName, GenerateToolID(), result.Output.RawInput, result.Output, false)
+	a.SetState(AgentStateCompleted)
+	return &AgentChatResponse{
+		Response:  result.Answer,
+		ToolCalls: []*ToolCallResult{toolCall},
+		Sources:   []*tools.ToolOutput{result.Output},
+		Metadata: map[string]interface{}{
+			"program_of_thought": true,
+			"program":            result.Program,
+		},
+	}, true
+}
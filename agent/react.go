@@ -118,11 +118,17 @@ func (a *ReActAgent) ChatWithHistory(ctx context.Context, message string, chatHi
 		}
 	}
 
+	if response, ok := a.answerWithProgram(ctx, message); ok {
+		return response, nil
+	}
+
 	// Run the reasoning loop
 	var finalResponse string
 	var allToolCalls []*ToolCallResult
 
 	for iteration := 0; iteration < a.maxIterations; iteration++ {
+		stepCtx, step := startAgentStep(ctx, iteration)
+
 		// Format messages for LLM
 		messages := a.formatter.Format(a.tools, chatHistory, a.currentReasoning)
 
@@ -131,8 +137,9 @@ func (a *ReActAgent) ChatWithHistory(ctx context.Context, message string, chatHi
 		}
 
 		// Get LLM response
-		response, err := a.llm.Chat(ctx, messages)
+		response, err := a.llm.Chat(stepCtx, messages)
 		if err != nil {
+			step.End(nil, err)
 			return nil, fmt.Errorf("LLM chat failed: %w", err)
 		}
 
@@ -172,6 +179,7 @@ func (a *ReActAgent) ChatWithHistory(ctx context.Context, message string, chatHi
 				Response: "",
 			})
 			chatHistory = append(chatHistory, llm.NewUserMessage(errorMsg))
+			step.End(agentStepPayload(response), nil)
 			continue
 		}
 
@@ -183,6 +191,7 @@ func (a *ReActAgent) ChatWithHistory(ctx context.Context, message string, chatHi
 			if respStep, ok := reasoningStep.(*ResponseReasoningStep); ok {
 				finalResponse = respStep.Response
 			}
+			step.End(agentStepPayload(response), nil)
 			break
 		}
 
@@ -191,7 +200,7 @@ func (a *ReActAgent) ChatWithHistory(ctx context.Context, message string, chatHi
 			a.SetState(AgentStateWaitingForTool)
 
 			// Execute the tool
-			toolResult, err := a.executeTool(ctx, actionStep)
+			toolResult, err := a.executeTool(stepCtx, actionStep)
 			if err != nil {
 				if a.verbose {
 					fmt.Printf("[ReActAgent] Tool execution error: %v\n", err)
@@ -206,6 +215,7 @@ func (a *ReActAgent) ChatWithHistory(ctx context.Context, message string, chatHi
 				ReturnDirect: toolResult.ReturnDirect,
 			}
 			a.currentReasoning = append(a.currentReasoning, observation)
+			step.End(agentStepPayload(response), nil)
 
 			// If return_direct, use the tool output as the final response
 			if toolResult.ReturnDirect && !toolResult.ToolOutput.IsError {
@@ -214,6 +224,8 @@ func (a *ReActAgent) ChatWithHistory(ctx context.Context, message string, chatHi
 			}
 
 			a.SetState(AgentStateRunning)
+		} else {
+			step.End(agentStepPayload(response), nil)
 		}
 	}
 
@@ -283,7 +295,7 @@ func (a *ReActAgent) executeTool(ctx context.Context, action *ActionReasoningSte
 	}
 
 	// Execute the tool
-	output, err := tool.Call(ctx, action.ActionInput)
+	output, err := callTool(ctx, tool, action.ActionInput)
 	if err != nil {
 		errOutput := tools.NewErrorToolOutput(action.Action, err)
 		return NewToolCallResult(action.Action, toolID, action.ActionInput, errOutput, tool.Metadata().ReturnDirect), err
@@ -374,6 +386,10 @@ func (a *FunctionCallingReActAgent) ChatWithHistory(ctx context.Context, message
 		}
 	}
 
+	if response, ok := a.answerWithProgram(ctx, message); ok {
+		return response, nil
+	}
+
 	// Get tool metadata
 	toolMetadata := make([]*llm.ToolMetadata, len(a.tools))
 	for i, t := range a.tools {
@@ -389,9 +405,12 @@ func (a *FunctionCallingReActAgent) ChatWithHistory(ctx context.Context, message
 
 	// Run the tool calling loop
 	for iteration := 0; iteration < a.maxIterations; iteration++ {
+		stepCtx, step := startAgentStep(ctx, iteration)
+
 		// Call LLM with tools
-		response, err := toolLLM.ChatWithTools(ctx, messages, toolMetadata, nil)
+		response, err := toolLLM.ChatWithTools(stepCtx, messages, toolMetadata, nil)
 		if err != nil {
+			step.End(nil, err)
 			return nil, fmt.Errorf("LLM chat with tools failed: %w", err)
 		}
 
@@ -415,7 +434,7 @@ func (a *FunctionCallingReActAgent) ChatWithHistory(ctx context.Context, message
 				if tool == nil {
 					output = tools.NewErrorToolOutput(tc.Name, fmt.Errorf("tool not found: %s", tc.Name))
 				} else {
-					output, _ = tool.Call(ctx, args)
+					output, _ = callTool(stepCtx, tool, args)
 					returnDirect = tool.Metadata().ReturnDirect
 				}
 
@@ -428,6 +447,7 @@ func (a *FunctionCallingReActAgent) ChatWithHistory(ctx context.Context, message
 
 				// If return_direct, return immediately
 				if returnDirect && !output.IsError {
+					step.End(agentStepPayload(response.Text), nil)
 					return &AgentChatResponse{
 						Response:  output.Content,
 						ToolCalls: allToolCalls,
@@ -436,9 +456,11 @@ func (a *FunctionCallingReActAgent) ChatWithHistory(ctx context.Context, message
 				}
 			}
 
+			step.End(agentStepPayload(response.Text), nil)
 			a.SetState(AgentStateRunning)
 			continue
 		}
+		step.End(agentStepPayload(response.Text), nil)
 
 		// No tool calls, we have a final response
 		finalResponse := response.Text
@@ -544,6 +566,10 @@ func (a *SimpleAgent) ChatWithHistory(ctx context.Context, message string, chatH
 		}
 	}
 
+	if response, ok := a.answerWithProgram(ctx, message); ok {
+		return response, nil
+	}
+
 	// Get LLM response
 	response, err := a.llm.Chat(ctx, messages)
 	if err != nil {
@@ -267,6 +267,8 @@ type BaseAgent struct {
 	maxIterations  int
 	verbose        bool
 	state          AgentState
+
+	programOfThought *ProgramOfThought
 }
 
 // BaseAgentOption configures a BaseAgent.
@@ -286,7 +288,9 @@ func WithAgentDescription(description string) BaseAgentOption {
 	}
 }
 
-// WithAgentLLM sets the LLM.
+// WithAgentLLM sets the LLM. To keep the agent within provider limits
+// shared with other agents, pipelines and evaluators, pass an LLM wrapped
+// with llm.NewLimitedLLM around a shared llm.ConcurrencyLimiter.
 func WithAgentLLM(l llm.LLM) BaseAgentOption {
 	return func(a *BaseAgent) {
 		a.llm = l
@@ -328,6 +332,16 @@ func WithAgentVerbose(verbose bool) BaseAgentOption {
 	}
 }
 
+// WithAgentProgramOfThought enables program of thought for numeric
+// questions: they are answered by running an LLM-written program with the
+// code interpreter tool instead of the agent's regular loop, which remains
+// the fallback when the program fails.
+func WithAgentProgramOfThought(pot *ProgramOfThought) BaseAgentOption {
+	return func(a *BaseAgent) {
+		a.programOfThought = pot
+	}
+}
+
 // NewBaseAgent creates a new BaseAgent.
 func NewBaseAgent(opts ...BaseAgentOption) *BaseAgent {
 	a := &BaseAgent{
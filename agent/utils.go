@@ -5,9 +5,38 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/aqua777/go-llamaindex/callbacks"
 	"github.com/aqua777/go-llamaindex/tools"
 )
 
+// startAgentStep starts an agent step event on the callback manager in ctx.
+func startAgentStep(ctx context.Context, iteration int) (context.Context, *callbacks.Span) {
+	return callbacks.StartSpan(ctx, callbacks.CBEventTypeAgentStep, map[string]interface{}{
+		"iteration": iteration + 1,
+	})
+}
+
+// agentStepPayload returns the end payload of an agent step event.
+func agentStepPayload(response string) map[string]interface{} {
+	return map[string]interface{}{string(callbacks.EventPayloadResponse): response}
+}
+
+// callTool calls tool within a function call event on the callback manager
+// in ctx.
+func callTool(ctx context.Context, tool tools.Tool, input map[string]interface{}) (*tools.ToolOutput, error) {
+	ctx, span := callbacks.StartSpan(ctx, callbacks.CBEventTypeFunctionCall, map[string]interface{}{
+		string(callbacks.EventPayloadTool):         tool.Metadata().Name,
+		string(callbacks.EventPayloadFunctionCall): input,
+	})
+	output, err := tool.Call(ctx, input)
+	payload := map[string]interface{}{}
+	if output != nil {
+		payload[string(callbacks.EventPayloadFunctionOutput)] = output.Content
+	}
+	span.End(payload, err)
+	return output, err
+}
+
 // CallTool executes a tool with the given input.
 func CallTool(ctx context.Context, tool tools.Tool, input map[string]interface{}) (*tools.ToolOutput, error) {
 	if tool == nil {
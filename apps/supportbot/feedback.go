@@ -0,0 +1,162 @@
+package supportbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxTrackedAnswers is how many recent answers are kept to attach their
+// question, answer and sources to feedback.
+const maxTrackedAnswers = 1000
+
+// Rating is a user's verdict on an answer.
+type Rating string
+
+const (
+	// RatingPositive marks a helpful answer.
+	RatingPositive Rating = "positive"
+	// RatingNegative marks an unhelpful or wrong answer.
+	RatingNegative Rating = "negative"
+)
+
+// ErrUnknownMessage is returned when feedback refers to an answer the bot
+// does not remember.
+var ErrUnknownMessage = errors.New("supportbot: unknown message ID")
+
+// Feedback is a rating of an answer, with what is needed to review it.
+type Feedback struct {
+	// SessionID is the conversation of the answer.
+	SessionID string `json:"session_id"`
+	// MessageID is the Reply.MessageID of the answer.
+	MessageID string `json:"message_id"`
+	// Rating is the user's verdict.
+	Rating Rating `json:"rating"`
+	// Comment is an optional free-text comment.
+	Comment string `json:"comment,omitempty"`
+	// UserID identifies the user, if known.
+	UserID string `json:"user_id,omitempty"`
+	// Question is the message that was answered. Filled in by the bot.
+	Question string `json:"question,omitempty"`
+	// Answer is the rated answer. Filled in by the bot.
+	Answer string `json:"answer,omitempty"`
+	// Sources are the chunks the answer was based on. Filled in by the bot.
+	Sources []Source `json:"sources,omitempty"`
+	// CreatedAt is when the feedback was recorded. Filled in by the bot.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FeedbackStore records feedback, e.g. to build evaluation datasets from
+// negatively rated answers.
+type FeedbackStore interface {
+	// RecordFeedback stores one feedback entry.
+	RecordFeedback(ctx context.Context, fb Feedback) error
+	// ListFeedback returns the feedback of a session, or of all sessions if
+	// sessionID is empty, oldest first.
+	ListFeedback(ctx context.Context, sessionID string) ([]Feedback, error)
+}
+
+// MemoryFeedbackStore keeps feedback in memory.
+type MemoryFeedbackStore struct {
+	mu      sync.RWMutex
+	entries []Feedback
+}
+
+// NewMemoryFeedbackStore creates an empty in-memory feedback store.
+func NewMemoryFeedbackStore() *MemoryFeedbackStore {
+	return &MemoryFeedbackStore{}
+}
+
+// RecordFeedback stores fb.
+func (s *MemoryFeedbackStore) RecordFeedback(ctx context.Context, fb Feedback) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, fb)
+	return nil
+}
+
+// ListFeedback returns the feedback of a session, or all feedback.
+func (s *MemoryFeedbackStore) ListFeedback(ctx context.Context, sessionID string) ([]Feedback, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var result []Feedback
+	for _, fb := range s.entries {
+		if sessionID == "" || fb.SessionID == sessionID {
+			result = append(result, fb)
+		}
+	}
+	return result, nil
+}
+
+// RecordFeedback records a rating of an answer given by the bot. The
+// question, answer and sources are attached from the bot's record of recent
+// answers; feedback on answers it no longer remembers fails with
+// ErrUnknownMessage.
+func (b *Bot) RecordFeedback(ctx context.Context, fb Feedback) error {
+	switch fb.Rating {
+	case RatingPositive, RatingNegative:
+	default:
+		return fmt.Errorf("supportbot: invalid rating %q", fb.Rating)
+	}
+	answer, ok := b.answers.get(fb.MessageID)
+	if !ok {
+		return ErrUnknownMessage
+	}
+	if fb.SessionID != "" && fb.SessionID != answer.sessionID {
+		return ErrUnknownMessage
+	}
+	fb.SessionID = answer.sessionID
+	fb.Question = answer.question
+	fb.Answer = answer.answer
+	fb.Sources = answer.sources
+	fb.CreatedAt = b.now()
+	return b.feedback.RecordFeedback(ctx, fb)
+}
+
+// Feedback returns the feedback store.
+func (b *Bot) Feedback() FeedbackStore {
+	return b.feedback
+}
+
+// trackedAnswer is an answer remembered for feedback.
+type trackedAnswer struct {
+	sessionID string
+	question  string
+	answer    string
+	sources   []Source
+}
+
+// answerLog remembers the most recent answers, evicting the oldest.
+type answerLog struct {
+	mu      sync.Mutex
+	limit   int
+	order   []string
+	answers map[string]trackedAnswer
+}
+
+func newAnswerLog(limit int) *answerLog {
+	return &answerLog{limit: limit, answers: make(map[string]trackedAnswer)}
+}
+
+func (l *answerLog) add(id string, a trackedAnswer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.order) >= l.limit {
+		delete(l.answers, l.order[0])
+		l.order = l.order[1:]
+	}
+	l.order = append(l.order, id)
+	l.answers[id] = a
+}
+
+func (l *answerLog) get(id string) (trackedAnswer, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a, ok := l.answers[id]
+	return a, ok
+}
+
+// Ensure MemoryFeedbackStore implements FeedbackStore.
+var _ FeedbackStore = (*MemoryFeedbackStore)(nil)
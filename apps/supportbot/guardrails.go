@@ -0,0 +1,199 @@
+package supportbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aqua777/go-llamaindex/postprocessor"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// Guardrail checks a user message or an answer. It returns the text to use
+// in its place, which may be rewritten (e.g. redacted), or a *BlockedError
+// to reject it. Other errors fail the turn.
+type Guardrail interface {
+	// Name identifies the guardrail in replies and errors.
+	Name() string
+	// Check checks text and returns the text to use.
+	Check(ctx context.Context, text string) (string, error)
+}
+
+// BlockedError reports that a guardrail rejected a message or answer.
+type BlockedError struct {
+	// Guardrail is the name of the guardrail.
+	Guardrail string
+	// Reason is shown to the user in place of the answer.
+	Reason string
+}
+
+// Error implements error.
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("blocked by %s: %s", e.Guardrail, e.Reason)
+}
+
+// runGuardrails runs guards in order, each on the text returned by the
+// previous one.
+func runGuardrails(ctx context.Context, guards []Guardrail, text string) (string, error) {
+	for _, g := range guards {
+		var err error
+		if text, err = g.Check(ctx, text); err != nil {
+			var be *BlockedError
+			if errors.As(err, &be) {
+				return "", err
+			}
+			return "", fmt.Errorf("guardrail %s failed: %w", g.Name(), err)
+		}
+	}
+	return text, nil
+}
+
+func blockedMessage(err error) string {
+	var be *BlockedError
+	if errors.As(err, &be) {
+		return be.Reason
+	}
+	return err.Error()
+}
+
+func blockedBy(err error) string {
+	var be *BlockedError
+	if errors.As(err, &be) {
+		return be.Guardrail
+	}
+	return ""
+}
+
+// GuardrailFunc adapts a function to a Guardrail.
+type GuardrailFunc struct {
+	name string
+	fn   func(ctx context.Context, text string) (string, error)
+}
+
+// NewGuardrailFunc creates a guardrail named name that runs fn.
+func NewGuardrailFunc(name string, fn func(ctx context.Context, text string) (string, error)) *GuardrailFunc {
+	return &GuardrailFunc{name: name, fn: fn}
+}
+
+// Name returns the guardrail name.
+func (g *GuardrailFunc) Name() string {
+	return g.name
+}
+
+// Check runs the function.
+func (g *GuardrailFunc) Check(ctx context.Context, text string) (string, error) {
+	return g.fn(ctx, text)
+}
+
+// MaxLengthGuardrail rejects texts longer than a number of characters.
+type MaxLengthGuardrail struct {
+	maxChars int
+}
+
+// NewMaxLengthGuardrail creates a guardrail rejecting texts longer than
+// maxChars characters.
+func NewMaxLengthGuardrail(maxChars int) *MaxLengthGuardrail {
+	return &MaxLengthGuardrail{maxChars: maxChars}
+}
+
+// Name returns the guardrail name.
+func (g *MaxLengthGuardrail) Name() string {
+	return "max_length"
+}
+
+// Check rejects text longer than the limit.
+func (g *MaxLengthGuardrail) Check(ctx context.Context, text string) (string, error) {
+	if utf8.RuneCountInString(text) > g.maxChars {
+		return "", &BlockedError{
+			Guardrail: g.Name(),
+			Reason:    fmt.Sprintf("Your message is too long. Please keep it under %d characters.", g.maxChars),
+		}
+	}
+	return text, nil
+}
+
+// BlockedTermsGuardrail rejects texts containing any of a list of terms,
+// ignoring case.
+type BlockedTermsGuardrail struct {
+	terms  []string
+	reason string
+}
+
+// DefaultBlockedTermsReason is the refusal of a BlockedTermsGuardrail.
+const DefaultBlockedTermsReason = "Sorry, I can't help with that request."
+
+// NewBlockedTermsGuardrail creates a guardrail rejecting texts that contain
+// any of terms.
+func NewBlockedTermsGuardrail(terms ...string) *BlockedTermsGuardrail {
+	lower := make([]string, 0, len(terms))
+	for _, t := range terms {
+		if t = strings.TrimSpace(t); t != "" {
+			lower = append(lower, strings.ToLower(t))
+		}
+	}
+	return &BlockedTermsGuardrail{terms: lower, reason: DefaultBlockedTermsReason}
+}
+
+// WithReason sets the refusal shown to the user.
+func (g *BlockedTermsGuardrail) WithReason(reason string) *BlockedTermsGuardrail {
+	g.reason = reason
+	return g
+}
+
+// Name returns the guardrail name.
+func (g *BlockedTermsGuardrail) Name() string {
+	return "blocked_terms"
+}
+
+// Check rejects text containing a blocked term.
+func (g *BlockedTermsGuardrail) Check(ctx context.Context, text string) (string, error) {
+	lower := strings.ToLower(text)
+	for _, t := range g.terms {
+		if strings.Contains(lower, t) {
+			return "", &BlockedError{Guardrail: g.Name(), Reason: g.reason}
+		}
+	}
+	return text, nil
+}
+
+// PIIRedactionGuardrail masks personal data such as emails, phone numbers
+// and card numbers, using the patterns of a postprocessor.PIIPostprocessor.
+// On input it keeps personal data out of prompts, history and logs; on
+// output it keeps answers from echoing personal data found in documents.
+type PIIRedactionGuardrail struct {
+	pii *postprocessor.PIIPostprocessor
+}
+
+// NewPIIRedactionGuardrail creates a redaction guardrail. The options
+// configure the underlying PIIPostprocessor, e.g. to restrict PII types.
+func NewPIIRedactionGuardrail(opts ...postprocessor.PIIPostprocessorOption) *PIIRedactionGuardrail {
+	opts = append(opts, postprocessor.WithPIIMask(true), postprocessor.WithPIIStoreOriginal(false))
+	return &PIIRedactionGuardrail{pii: postprocessor.NewPIIPostprocessor(opts...)}
+}
+
+// Name returns the guardrail name.
+func (g *PIIRedactionGuardrail) Name() string {
+	return "pii_redaction"
+}
+
+// Check returns text with personal data masked.
+func (g *PIIRedactionGuardrail) Check(ctx context.Context, text string) (string, error) {
+	if len(g.pii.DetectPII(text)) == 0 {
+		return text, nil
+	}
+	masked, err := g.pii.PostprocessNodes(ctx, []schema.NodeWithScore{{Node: *schema.NewTextNode(text)}}, nil)
+	if err != nil {
+		return "", err
+	}
+	return masked[0].Node.Text, nil
+}
+
+// Ensure the guardrails implement Guardrail.
+var (
+	_ Guardrail = (*GuardrailFunc)(nil)
+	_ Guardrail = (*MaxLengthGuardrail)(nil)
+	_ Guardrail = (*BlockedTermsGuardrail)(nil)
+	_ Guardrail = (*PIIRedactionGuardrail)(nil)
+)
@@ -0,0 +1,96 @@
+package supportbot
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/aqua777/go-llamaindex/requestctx"
+)
+
+// maxRequestBytes limits the size of request bodies.
+const maxRequestBytes = 1 << 20
+
+// ChatRequest is the body of POST /chat.
+type ChatRequest struct {
+	SessionID string `json:"session_id"`
+	Message   string `json:"message"`
+	UserID    string `json:"user_id,omitempty"`
+}
+
+// Handler returns an HTTP handler serving the bot:
+//
+//	POST /chat      ChatRequest -> Reply
+//	POST /feedback  Feedback -> 204 No Content
+//	GET  /healthz   200 OK
+//
+// The user ID of a chat request is put in the request context with
+// requestctx.WithUserID, where LLMs, tools and callbacks can read it.
+// Errors are returned as {"error": "..."}. Authentication, rate limiting
+// and CORS are left to middleware wrapping the handler.
+func (b *Bot) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /chat", b.handleChat)
+	mux.HandleFunc("POST /feedback", b.handleFeedback)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux
+}
+
+func (b *Bot) handleChat(w http.ResponseWriter, r *http.Request) {
+	var req ChatRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.SessionID == "" || req.Message == "" {
+		writeError(w, http.StatusBadRequest, "session_id and message are required")
+		return
+	}
+	ctx := requestctx.WithChannel(r.Context(), "http")
+	if req.UserID != "" {
+		ctx = requestctx.WithUserID(ctx, req.UserID)
+	}
+	reply, err := b.Chat(ctx, req.SessionID, req.Message)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, reply)
+}
+
+func (b *Bot) handleFeedback(w http.ResponseWriter, r *http.Request) {
+	var fb Feedback
+	if !decodeJSON(w, r, &fb) {
+		return
+	}
+	if err := b.RecordFeedback(r.Context(), fb); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrUnknownMessage) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
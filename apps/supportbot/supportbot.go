@@ -0,0 +1,541 @@
+// Package supportbot is a reference customer-support bot built from the
+// library's building blocks. It is meant to be embedded as is or copied and
+// extended, and doubles as an end-to-end test bed for the pieces it wires
+// together:
+//
+//   - readers and ingestion: documents are chunked into nodes and upserted
+//     by document ID, so re-ingesting a changed document replaces its chunks
+//     and unchanged documents are skipped;
+//   - hybrid retrieval: a vector index and a BM25 index over the same chunks
+//     are fused with reciprocal rank fusion;
+//   - reranking: optional node postprocessors, such as an LLM reranker, run
+//     on the fused results;
+//   - chat: a condense-plus-context chat engine answers with per-session
+//     memory kept in a chat store;
+//   - guardrails: input guardrails can reject a message before retrieval and
+//     output guardrails can redact or withhold an answer;
+//   - feedback: ratings of answers are captured with the question, answer
+//     and sources they refer to;
+//   - HTTP: Handler serves chat and feedback as JSON endpoints.
+//
+// A minimal setup:
+//
+//	l := llm.NewOpenAILLM("", "gpt-4o-mini", "")
+//	bot, err := supportbot.New(ctx, l, embedding.NewOpenAIEmbedding("", "text-embedding-3-small"),
+//		supportbot.WithRerankers(postprocessor.NewLLMRerank(postprocessor.WithLLMRerankLLM(l))),
+//		supportbot.WithInputGuardrails(supportbot.NewMaxLengthGuardrail(2000)),
+//		supportbot.WithOutputGuardrails(supportbot.NewPIIRedactionGuardrail()),
+//	)
+//	if _, err := bot.IngestReader(ctx, reader.NewSimpleDirectoryReader("./kb")); err != nil { ... }
+//	http.ListenAndServe(":8080", bot.Handler())
+package supportbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/chatengine"
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/index"
+	"github.com/aqua777/go-llamaindex/ingestion"
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/memory"
+	"github.com/aqua777/go-llamaindex/nodeparser"
+	"github.com/aqua777/go-llamaindex/postprocessor"
+	"github.com/aqua777/go-llamaindex/rag/reader"
+	"github.com/aqua777/go-llamaindex/rag/retriever"
+	"github.com/aqua777/go-llamaindex/rag/store"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/storage"
+	"github.com/aqua777/go-llamaindex/storage/chatstore"
+	"github.com/aqua777/go-llamaindex/storage/docstore"
+)
+
+// DefaultSystemPrompt is the system prompt of the chat engine.
+const DefaultSystemPrompt = `You are a helpful customer support assistant.
+Answer only from the provided documents and say so when they do not cover the question.
+Keep answers short and give concrete steps when the user needs to do something.`
+
+// Default chunking and retrieval settings.
+const (
+	DefaultChunkSize    = 512
+	DefaultChunkOverlap = 64
+	DefaultTopK         = 5
+)
+
+// sessionLockStripes is the number of locks serializing turns of the same
+// session.
+const sessionLockStripes = 64
+
+// Source is a document chunk an answer was based on.
+type Source struct {
+	// NodeID is the ID of the chunk.
+	NodeID string `json:"node_id"`
+	// DocumentID is the ID of the document the chunk came from.
+	DocumentID string `json:"document_id,omitempty"`
+	// Text is the chunk text.
+	Text string `json:"text"`
+	// Score is the retrieval or rerank score.
+	Score float64 `json:"score"`
+	// Metadata is the chunk metadata, e.g. its file name or URL.
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Reply is the bot's answer to one message.
+type Reply struct {
+	// MessageID identifies the answer, for feedback.
+	MessageID string `json:"message_id"`
+	// SessionID is the conversation the answer belongs to.
+	SessionID string `json:"session_id"`
+	// Answer is the answer text, after output guardrails.
+	Answer string `json:"answer"`
+	// Sources are the chunks the answer was based on.
+	Sources []Source `json:"sources,omitempty"`
+	// Blocked is set when a guardrail rejected the message or answer;
+	// Answer then holds the guardrail's refusal.
+	Blocked bool `json:"blocked,omitempty"`
+	// BlockedBy names the guardrail that rejected the message or answer.
+	BlockedBy string `json:"blocked_by,omitempty"`
+}
+
+// Bot is a support bot over an ingested knowledge base. It is safe for
+// concurrent use; turns of the same session are serialized.
+type Bot struct {
+	llm             llm.LLM
+	embedModel      embedding.EmbeddingModel
+	storageContext  *storage.StorageContext
+	transformations []ingestion.TransformComponent
+	topK            int
+	alpha           float64
+	rerankers       []postprocessor.NodePostprocessor
+	systemPrompt    string
+	inputGuards     []Guardrail
+	outputGuards    []Guardrail
+	feedback        FeedbackStore
+	chatStore       chatstore.ChatStore
+	tokenLimit      int
+	now             func() time.Time
+
+	// ingestMu serializes ingestion, which updates both indexes.
+	ingestMu sync.Mutex
+	index    *index.VectorStoreIndex
+	bm25     *retriever.BM25Retriever
+	hybrid   *retriever.HybridRetriever
+
+	sessionLocks [sessionLockStripes]sync.Mutex
+	messageSeq   atomic.Uint64
+	answers      *answerLog
+}
+
+// Option configures a Bot.
+type Option func(*Bot)
+
+// WithStorageContext sets the storage context holding the docstore, index
+// store and vector store. Defaults to in-memory stores.
+func WithStorageContext(sc *storage.StorageContext) Option {
+	return func(b *Bot) {
+		b.storageContext = sc
+	}
+}
+
+// WithTransformations sets the transformations that turn documents into
+// chunks. Defaults to a sentence splitter with DefaultChunkSize and
+// DefaultChunkOverlap.
+func WithTransformations(transformations ...ingestion.TransformComponent) Option {
+	return func(b *Bot) {
+		b.transformations = transformations
+	}
+}
+
+// WithTopK sets the number of chunks retrieved by each retriever and kept
+// after fusion. Defaults to DefaultTopK.
+func WithTopK(k int) Option {
+	return func(b *Bot) {
+		if k > 0 {
+			b.topK = k
+		}
+	}
+}
+
+// WithHybridAlpha sets the weight of vector retrieval against BM25, in
+// [0, 1]. Defaults to 0.5.
+func WithHybridAlpha(alpha float64) Option {
+	return func(b *Bot) {
+		b.alpha = alpha
+	}
+}
+
+// WithRerankers sets postprocessors run in order on the fused results, such
+// as a postprocessor.LLMRerank or a SimilarityPostprocessor cutoff.
+func WithRerankers(rerankers ...postprocessor.NodePostprocessor) Option {
+	return func(b *Bot) {
+		b.rerankers = rerankers
+	}
+}
+
+// WithSystemPrompt sets the system prompt. Defaults to DefaultSystemPrompt.
+func WithSystemPrompt(prompt string) Option {
+	return func(b *Bot) {
+		b.systemPrompt = prompt
+	}
+}
+
+// WithInputGuardrails sets the guardrails checking user messages, in order.
+func WithInputGuardrails(guards ...Guardrail) Option {
+	return func(b *Bot) {
+		b.inputGuards = guards
+	}
+}
+
+// WithOutputGuardrails sets the guardrails checking answers, in order.
+func WithOutputGuardrails(guards ...Guardrail) Option {
+	return func(b *Bot) {
+		b.outputGuards = guards
+	}
+}
+
+// WithFeedbackStore sets where feedback is recorded. Defaults to a
+// MemoryFeedbackStore.
+func WithFeedbackStore(fs FeedbackStore) Option {
+	return func(b *Bot) {
+		b.feedback = fs
+	}
+}
+
+// WithChatStore sets the chat store holding the history of every session,
+// keyed by session ID. Defaults to an in-memory store.
+func WithChatStore(cs chatstore.ChatStore) Option {
+	return func(b *Bot) {
+		b.chatStore = cs
+	}
+}
+
+// WithMemoryTokenLimit sets how many tokens of history are kept in context
+// per session. Defaults to memory.DefaultTokenLimit.
+func WithMemoryTokenLimit(limit int) Option {
+	return func(b *Bot) {
+		if limit > 0 {
+			b.tokenLimit = limit
+		}
+	}
+}
+
+// WithClock sets the function used to timestamp feedback.
+func WithClock(now func() time.Time) Option {
+	return func(b *Bot) {
+		b.now = now
+	}
+}
+
+// New creates a bot answering with l and embedding with embedModel. Chunks
+// already in the storage context's docstore are indexed for BM25, so a bot
+// over persisted stores can answer without re-ingesting.
+func New(ctx context.Context, l llm.LLM, embedModel embedding.EmbeddingModel, opts ...Option) (*Bot, error) {
+	if l == nil {
+		return nil, errors.New("supportbot: LLM is required")
+	}
+	if embedModel == nil {
+		return nil, errors.New("supportbot: embedding model is required")
+	}
+	b := &Bot{
+		llm:          l,
+		embedModel:   embedModel,
+		topK:         DefaultTopK,
+		alpha:        0.5,
+		systemPrompt: DefaultSystemPrompt,
+		tokenLimit:   memory.DefaultTokenLimit,
+		now:          time.Now,
+		answers:      newAnswerLog(maxTrackedAnswers),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.storageContext == nil {
+		b.storageContext = storage.NewStorageContext()
+	}
+	if b.storageContext.VectorStore() == nil {
+		b.storageContext.SetVectorStore(store.NewSimpleVectorStore())
+	}
+	if b.transformations == nil {
+		b.transformations = []ingestion.TransformComponent{
+			ingestion.NewNodeParserTransform(nodeparser.NewSentenceNodeParserWithConfig(DefaultChunkSize, DefaultChunkOverlap)),
+		}
+	}
+	if b.feedback == nil {
+		b.feedback = NewMemoryFeedbackStore()
+	}
+	if b.chatStore == nil {
+		b.chatStore = chatstore.NewSimpleChatStore()
+	}
+
+	var err error
+	b.index, err = index.NewVectorStoreIndex(ctx, nil,
+		index.WithVectorIndexStorageContext(b.storageContext),
+		index.WithVectorIndexEmbedModel(embedModel),
+		index.WithVectorIndexTransformations(b.transformations...),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("supportbot: failed to create vector index: %w", err)
+	}
+	b.bm25, err = retriever.NewBM25RetrieverFromDocStore(ctx, b.storageContext.DocStore, retriever.WithBM25TopK(b.topK))
+	if err != nil {
+		return nil, fmt.Errorf("supportbot: failed to build BM25 index: %w", err)
+	}
+	b.hybrid = retriever.NewHybridRetriever(
+		b.index.AsRetriever(index.WithSimilarityTopK(b.topK)),
+		b.bm25,
+		retriever.WithHybridAlpha(b.alpha),
+		retriever.WithHybridTopK(b.topK),
+	)
+	return b, nil
+}
+
+// Ingest adds documents to the knowledge base, keyed by document ID.
+// Documents seen before are re-chunked only if their content changed, in
+// which case their old chunks are replaced. It returns how many documents
+// were added or replaced.
+func (b *Bot) Ingest(ctx context.Context, documents []schema.Document) (int, error) {
+	b.ingestMu.Lock()
+	defer b.ingestMu.Unlock()
+
+	changed := 0
+	for _, doc := range documents {
+		if doc.ID == "" {
+			return changed, errors.New("supportbot: documents need an ID to be updated later")
+		}
+		oldIDs, err := b.chunkIDs(ctx, doc.ID)
+		if err != nil {
+			return changed, err
+		}
+		refreshed, err := b.index.RefreshDocuments(ctx, []schema.Document{doc})
+		if err != nil {
+			return changed, fmt.Errorf("supportbot: failed to index document %s: %w", doc.ID, err)
+		}
+		if !refreshed[0] {
+			continue
+		}
+		b.bm25.DeleteNodes(oldIDs...)
+		newIDs, err := b.chunkIDs(ctx, doc.ID)
+		if err != nil {
+			return changed, err
+		}
+		chunks, err := docstore.GetNodes(ctx, b.storageContext.DocStore, newIDs, false)
+		if err != nil {
+			return changed, err
+		}
+		nodes := make([]schema.Node, 0, len(chunks))
+		for _, c := range chunks {
+			if n, ok := c.(*schema.Node); ok {
+				nodes = append(nodes, *n)
+			}
+		}
+		b.bm25.AddNodes(nodes)
+		changed++
+	}
+	return changed, nil
+}
+
+// IngestReader loads the documents of r and ingests them. Node IDs from the
+// reader become document IDs, so readers with stable IDs (file paths, page
+// IDs) can be re-run to pick up changes.
+func (b *Bot) IngestReader(ctx context.Context, r reader.Reader) (int, error) {
+	var nodes []schema.Node
+	var err error
+	if rc, ok := r.(reader.ReaderWithContext); ok {
+		nodes, err = rc.LoadDataWithContext(ctx)
+	} else {
+		nodes, err = r.LoadData()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("supportbot: failed to load documents: %w", err)
+	}
+	docs := make([]schema.Document, len(nodes))
+	for i, n := range nodes {
+		docs[i] = schema.Document{ID: n.ID, Text: n.Text, Metadata: n.Metadata, MimeType: n.MimeType}
+	}
+	return b.Ingest(ctx, docs)
+}
+
+// Remove deletes documents and their chunks from the knowledge base.
+func (b *Bot) Remove(ctx context.Context, documentIDs ...string) error {
+	b.ingestMu.Lock()
+	defer b.ingestMu.Unlock()
+
+	for _, id := range documentIDs {
+		chunkIDs, err := b.chunkIDs(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := b.index.DeleteRefDoc(ctx, id); err != nil {
+			return fmt.Errorf("supportbot: failed to remove document %s: %w", id, err)
+		}
+		b.bm25.DeleteNodes(chunkIDs...)
+	}
+	return nil
+}
+
+// chunkIDs returns the IDs of a document's chunks.
+func (b *Bot) chunkIDs(ctx context.Context, docID string) ([]string, error) {
+	info, err := b.storageContext.DocStore.GetRefDocInfo(ctx, docID)
+	if err != nil {
+		return nil, fmt.Errorf("supportbot: failed to look up document %s: %w", docID, err)
+	}
+	if info == nil {
+		return nil, nil
+	}
+	return info.NodeIDs, nil
+}
+
+// Chat answers a message in a session. Sessions are created on first use.
+func (b *Bot) Chat(ctx context.Context, sessionID, message string) (*Reply, error) {
+	if sessionID == "" {
+		return nil, errors.New("supportbot: session ID is required")
+	}
+	lock := b.sessionLock(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	reply := &Reply{
+		MessageID: b.nextMessageID(),
+		SessionID: sessionID,
+	}
+
+	checked, err := runGuardrails(ctx, b.inputGuards, message)
+	if err != nil {
+		return b.blocked(reply, message, err)
+	}
+
+	mem := b.sessionMemory(sessionID)
+	engine := chatengine.NewCondensePlusContextChatEngine(
+		chatengine.WithCondensePlusContextLLM(b.llm),
+		chatengine.WithCondensePlusContextMemory(mem),
+		chatengine.WithCondensePlusContextRetriever(&rerankingRetriever{base: b.hybrid, rerankers: b.rerankers}),
+		chatengine.WithCondensePlusContextSystemPrompt(b.systemPrompt),
+	)
+	resp, err := engine.Chat(ctx, checked)
+	if err != nil {
+		return nil, fmt.Errorf("supportbot: chat failed: %w", err)
+	}
+
+	answer, err := runGuardrails(ctx, b.outputGuards, resp.Response)
+	if err != nil {
+		answer = blockedMessage(err)
+		reply.Blocked, reply.BlockedBy = true, blockedBy(err)
+	}
+	if answer != resp.Response {
+		// Keep the redacted or withheld answer in the history, so later
+		// turns do not repeat what the user was not shown.
+		if err := replaceLastAnswer(ctx, mem, answer); err != nil {
+			return nil, err
+		}
+	}
+	reply.Answer = answer
+	if reply.Blocked {
+		b.answers.add(reply.MessageID, trackedAnswer{sessionID: sessionID, question: checked, answer: answer})
+		return reply, nil
+	}
+
+	reply.Sources = make([]Source, len(resp.SourceNodes))
+	for i, n := range resp.SourceNodes {
+		reply.Sources[i] = Source{
+			NodeID:   n.Node.ID,
+			Text:     n.Node.Text,
+			Score:    n.Score,
+			Metadata: n.Node.Metadata,
+		}
+		if src := n.Node.Relationships.GetSource(); src != nil {
+			reply.Sources[i].DocumentID = src.NodeID
+		}
+	}
+	b.answers.add(reply.MessageID, trackedAnswer{sessionID: sessionID, question: checked, answer: answer, sources: reply.Sources})
+	return reply, nil
+}
+
+// Reset clears the history of a session.
+func (b *Bot) Reset(ctx context.Context, sessionID string) error {
+	lock := b.sessionLock(sessionID)
+	lock.Lock()
+	defer lock.Unlock()
+	return b.sessionMemory(sessionID).Reset(ctx)
+}
+
+// History returns the messages of a session.
+func (b *Bot) History(ctx context.Context, sessionID string) ([]llm.ChatMessage, error) {
+	return b.chatStore.GetMessages(ctx, sessionID)
+}
+
+// blocked builds the reply to a message rejected by an input guardrail.
+// Rejected messages are not added to the session history.
+func (b *Bot) blocked(reply *Reply, message string, err error) (*Reply, error) {
+	var be *BlockedError
+	if !errors.As(err, &be) {
+		return nil, err
+	}
+	reply.Answer = blockedMessage(err)
+	reply.Blocked, reply.BlockedBy = true, be.Guardrail
+	b.answers.add(reply.MessageID, trackedAnswer{sessionID: reply.SessionID, question: message, answer: reply.Answer})
+	return reply, nil
+}
+
+// sessionMemory returns the memory of a session.
+func (b *Bot) sessionMemory(sessionID string) memory.Memory {
+	return memory.NewChatMemoryBuffer(
+		memory.WithBufferChatStore(b.chatStore),
+		memory.WithBufferChatStoreKey(sessionID),
+		memory.WithTokenLimit(b.tokenLimit),
+	)
+}
+
+func (b *Bot) sessionLock(sessionID string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(sessionID))
+	return &b.sessionLocks[h.Sum32()%sessionLockStripes]
+}
+
+func (b *Bot) nextMessageID() string {
+	return "msg-" + strconv.FormatInt(b.now().UnixNano(), 36) + "-" + strconv.FormatUint(b.messageSeq.Add(1), 36)
+}
+
+// replaceLastAnswer replaces the last assistant message of the history.
+func replaceLastAnswer(ctx context.Context, mem memory.Memory, answer string) error {
+	history, err := mem.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == llm.MessageRoleAssistant {
+			history[i].Content = answer
+			return mem.Set(ctx, history)
+		}
+	}
+	return nil
+}
+
+// rerankingRetriever runs postprocessors on the results of a retriever.
+type rerankingRetriever struct {
+	base      retriever.Retriever
+	rerankers []postprocessor.NodePostprocessor
+}
+
+// Retrieve retrieves and reranks nodes.
+func (r *rerankingRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	nodes, err := r.base.Retrieve(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	for _, pp := range r.rerankers {
+		if nodes, err = pp.PostprocessNodes(ctx, nodes, &query); err != nil {
+			return nil, fmt.Errorf("%s failed: %w", pp.Name(), err)
+		}
+	}
+	return nodes, nil
+}
+
+// Ensure rerankingRetriever implements Retriever.
+var _ retriever.Retriever = (*rerankingRetriever)(nil)
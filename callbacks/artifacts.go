@@ -0,0 +1,547 @@
+package callbacks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// ArtifactSchemaVersion is the version of the Artifact JSON layout. It is
+// bumped whenever fields are renamed or removed.
+const ArtifactSchemaVersion = 1
+
+// RedactedValue replaces redacted text in artifacts.
+const RedactedValue = "[REDACTED]"
+
+// ObjectStore stores immutable objects by key. The S3Store and GCSStore of
+// the rag/reader/objectstore package implement it; adapt other clients,
+// e.g. for Azure Blob Storage, with ObjectStoreFunc.
+type ObjectStore interface {
+	PutObject(ctx context.Context, key string, data []byte, contentType string) error
+}
+
+// ObjectStoreFunc adapts a function to ObjectStore.
+type ObjectStoreFunc func(ctx context.Context, key string, data []byte, contentType string) error
+
+// PutObject calls f.
+func (f ObjectStoreFunc) PutObject(ctx context.Context, key string, data []byte, contentType string) error {
+	return f(ctx, key, data, contentType)
+}
+
+// MemoryObjectStore keeps objects in memory. It is useful in tests and for
+// local debugging.
+type MemoryObjectStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+// NewMemoryObjectStore creates an empty MemoryObjectStore.
+func NewMemoryObjectStore() *MemoryObjectStore {
+	return &MemoryObjectStore{objects: make(map[string][]byte)}
+}
+
+// PutObject stores a copy of data under key.
+func (s *MemoryObjectStore) PutObject(ctx context.Context, key string, data []byte, contentType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = append([]byte(nil), data...)
+	return nil
+}
+
+// Get returns the object stored under key.
+func (s *MemoryObjectStore) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[key]
+	return data, ok
+}
+
+// Keys returns the stored keys in sorted order.
+func (s *MemoryObjectStore) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.objects))
+	for k := range s.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// DirObjectStore writes objects as files below a local directory, using
+// the key as the relative path.
+type DirObjectStore struct {
+	root string
+}
+
+// NewDirObjectStore creates a DirObjectStore rooted at dir.
+func NewDirObjectStore(dir string) *DirObjectStore {
+	return &DirObjectStore{root: dir}
+}
+
+// PutObject writes data to root/key.
+func (s *DirObjectStore) PutObject(ctx context.Context, key string, data []byte, contentType string) error {
+	path := filepath.Join(s.root, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, filepath.Clean(s.root)+string(filepath.Separator)) {
+		return fmt.Errorf("invalid object key %q", key)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Redactor rewrites text before it is written to an artifact.
+type Redactor func(text string) string
+
+// RegexRedactor returns a Redactor that replaces every match of the
+// patterns with RedactedValue.
+func RegexRedactor(patterns ...*regexp.Regexp) Redactor {
+	return func(text string) string {
+		for _, p := range patterns {
+			text = p.ReplaceAllString(text, RedactedValue)
+		}
+		return text
+	}
+}
+
+// DefaultArtifactRedactor masks email addresses, bearer tokens and common
+// API key formats.
+var DefaultArtifactRedactor = RegexRedactor(
+	regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._\-]+`),
+	regexp.MustCompile(`\b(sk|pk|rk)-[A-Za-z0-9_\-]{16,}\b`),
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),
+)
+
+// Artifact is the record written for one trace.
+type Artifact struct {
+	SchemaVersion int               `json:"schema_version"`
+	TraceID       string            `json:"trace_id"`
+	StartedAt     time.Time         `json:"started_at"`
+	EndedAt       time.Time         `json:"ended_at"`
+	Query         string            `json:"query,omitempty"`
+	Response      string            `json:"response,omitempty"`
+	Contexts      []ArtifactContext `json:"contexts,omitempty"`
+	LLMCalls      []ArtifactLLMCall `json:"llm_calls,omitempty"`
+	Errors        []string          `json:"errors,omitempty"`
+}
+
+// ArtifactContext is a retrieved node recorded in an artifact.
+type ArtifactContext struct {
+	NodeID   string                 `json:"node_id"`
+	Score    float64                `json:"score"`
+	Text     string                 `json:"text"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ArtifactLLMCall is a single LLM call recorded in an artifact.
+type ArtifactLLMCall struct {
+	EventID    string            `json:"event_id"`
+	Model      string            `json:"model,omitempty"`
+	Prompt     string            `json:"prompt,omitempty"`
+	Messages   []ArtifactMessage `json:"messages,omitempty"`
+	Completion string            `json:"completion,omitempty"`
+}
+
+// ArtifactMessage is a chat message recorded in an artifact.
+type ArtifactMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ArtifactSink is a callback handler that writes the full prompts,
+// retrieved contexts and responses of each trace to an ObjectStore, for
+// offline debugging and compliance review.
+//
+// Each trace is written once, when it ends, as a new JSON object under
+// "<prefix>/<trace id>/<timestamp>.json", so re-used trace IDs never
+// overwrite earlier records. Text is passed through the redactors before
+// it is written, and metadata values under redacted keys are masked.
+//
+// Traces are sampled deterministically by trace ID; traces that recorded
+// an exception are always written unless WithArtifactSampleErrors(false)
+// is set.
+type ArtifactSink struct {
+	*BaseCallbackHandler
+	store        ObjectStore
+	prefix       string
+	sampleRate   float64
+	sampleErrors bool
+	redactors    []Redactor
+	redactedKeys map[string]bool
+	timeout      time.Duration
+	onError      func(traceID string, err error)
+	now          func() time.Time
+
+	mu       sync.Mutex
+	current  *Artifact
+	llmCalls map[string]int
+}
+
+// ArtifactSinkOption configures an ArtifactSink.
+type ArtifactSinkOption func(*ArtifactSink)
+
+// WithArtifactPrefix sets the key prefix. Defaults to "artifacts".
+func WithArtifactPrefix(prefix string) ArtifactSinkOption {
+	return func(s *ArtifactSink) {
+		s.prefix = strings.Trim(prefix, "/")
+	}
+}
+
+// WithArtifactSampleRate sets the fraction of traces written, in [0, 1].
+// Defaults to 1.
+func WithArtifactSampleRate(rate float64) ArtifactSinkOption {
+	return func(s *ArtifactSink) {
+		if rate < 0 {
+			rate = 0
+		}
+		if rate > 1 {
+			rate = 1
+		}
+		s.sampleRate = rate
+	}
+}
+
+// WithArtifactSampleErrors sets whether traces with exceptions bypass
+// sampling. Defaults to true.
+func WithArtifactSampleErrors(always bool) ArtifactSinkOption {
+	return func(s *ArtifactSink) {
+		s.sampleErrors = always
+	}
+}
+
+// WithArtifactRedactors replaces the redactors. Defaults to
+// DefaultArtifactRedactor; pass none to disable text redaction.
+func WithArtifactRedactors(redactors ...Redactor) ArtifactSinkOption {
+	return func(s *ArtifactSink) {
+		s.redactors = redactors
+	}
+}
+
+// WithArtifactRedactedKeys masks node metadata values under these keys.
+// Matching is case-insensitive.
+func WithArtifactRedactedKeys(keys ...string) ArtifactSinkOption {
+	return func(s *ArtifactSink) {
+		for _, k := range keys {
+			s.redactedKeys[strings.ToLower(k)] = true
+		}
+	}
+}
+
+// WithArtifactTimeout bounds each object write. Defaults to 30 seconds.
+func WithArtifactTimeout(timeout time.Duration) ArtifactSinkOption {
+	return func(s *ArtifactSink) {
+		s.timeout = timeout
+	}
+}
+
+// WithArtifactErrorHandler sets a function called when an artifact cannot
+// be encoded or written. Errors are dropped by default so that logging
+// never fails a request.
+func WithArtifactErrorHandler(fn func(traceID string, err error)) ArtifactSinkOption {
+	return func(s *ArtifactSink) {
+		s.onError = fn
+	}
+}
+
+// NewArtifactSink creates an ArtifactSink writing to store.
+func NewArtifactSink(store ObjectStore, opts ...ArtifactSinkOption) *ArtifactSink {
+	s := &ArtifactSink{
+		BaseCallbackHandler: NewBaseCallbackHandler(),
+		store:               store,
+		prefix:              "artifacts",
+		sampleRate:          1,
+		sampleErrors:        true,
+		redactors:           []Redactor{DefaultArtifactRedactor},
+		redactedKeys:        make(map[string]bool),
+		timeout:             30 * time.Second,
+		now:                 time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// StartTrace begins recording a trace.
+func (s *ArtifactSink) StartTrace(traceID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.current = &Artifact{
+		SchemaVersion: ArtifactSchemaVersion,
+		TraceID:       traceID,
+		StartedAt:     s.now().UTC(),
+	}
+	s.llmCalls = make(map[string]int)
+}
+
+// OnEventStart records prompts, queries and exceptions.
+func (s *ArtifactSink) OnEventStart(
+	eventType CBEventType,
+	payload map[string]interface{},
+	eventID string,
+	parentID string,
+) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.current
+	if a == nil {
+		return eventID
+	}
+
+	switch eventType {
+	case CBEventTypeLLM:
+		call := ArtifactLLMCall{EventID: eventID}
+		if v, ok := payload[string(EventPayloadModelName)]; ok {
+			call.Model = payloadText(v)
+		}
+		if v, ok := payload[string(EventPayloadPrompt)]; ok {
+			call.Prompt = s.redact(payloadText(v))
+		}
+		if v, ok := payload[string(EventPayloadMessages)]; ok {
+			call.Messages = s.messages(v)
+		}
+		s.llmCalls[eventID] = len(a.LLMCalls)
+		a.LLMCalls = append(a.LLMCalls, call)
+	case CBEventTypeQuery:
+		if v, ok := payload[string(EventPayloadQueryStr)]; ok && a.Query == "" {
+			a.Query = s.redact(payloadText(v))
+		}
+	}
+
+	if v, ok := payload[string(EventPayloadException)]; ok {
+		a.Errors = append(a.Errors, s.redact(payloadText(v)))
+	}
+
+	return eventID
+}
+
+// OnEventEnd records completions, retrieved nodes, responses and
+// exceptions.
+func (s *ArtifactSink) OnEventEnd(
+	eventType CBEventType,
+	payload map[string]interface{},
+	eventID string,
+) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.current
+	if a == nil {
+		return
+	}
+
+	switch eventType {
+	case CBEventTypeLLM:
+		i, ok := s.llmCalls[eventID]
+		if !ok {
+			s.llmCalls[eventID] = len(a.LLMCalls)
+			i = len(a.LLMCalls)
+			a.LLMCalls = append(a.LLMCalls, ArtifactLLMCall{EventID: eventID})
+		}
+		if v, ok := payload[string(EventPayloadCompletion)]; ok {
+			a.LLMCalls[i].Completion = s.redact(payloadText(v))
+		} else if v, ok := payload[string(EventPayloadResponse)]; ok {
+			a.LLMCalls[i].Completion = s.redact(payloadText(v))
+		}
+	case CBEventTypeRetrieve:
+		if v, ok := payload[string(EventPayloadNodes)]; ok {
+			a.Contexts = append(a.Contexts, s.contexts(v)...)
+		}
+	case CBEventTypeQuery, CBEventTypeSynthesize:
+		if v, ok := payload[string(EventPayloadResponse)]; ok {
+			a.Response = s.redact(payloadText(v))
+		}
+	}
+
+	if v, ok := payload[string(EventPayloadException)]; ok {
+		a.Errors = append(a.Errors, s.redact(payloadText(v)))
+	}
+}
+
+// EndTrace writes the recorded trace if it is sampled.
+func (s *ArtifactSink) EndTrace(traceID string, traceMap map[string][]string) {
+	s.mu.Lock()
+	a := s.current
+	s.current = nil
+	s.llmCalls = nil
+	s.mu.Unlock()
+
+	if a == nil {
+		return
+	}
+	a.EndedAt = s.now().UTC()
+	if !s.sampled(a) {
+		return
+	}
+
+	if err := s.write(a); err != nil && s.onError != nil {
+		s.onError(a.TraceID, err)
+	}
+}
+
+// Key returns the object key an artifact is written under.
+func (s *ArtifactSink) Key(a *Artifact) string {
+	traceID := a.TraceID
+	if traceID == "" {
+		traceID = "untraced"
+	}
+	name := a.EndedAt.UTC().Format("20060102T150405.000000000Z") + ".json"
+	if s.prefix == "" {
+		return traceID + "/" + name
+	}
+	return s.prefix + "/" + traceID + "/" + name
+}
+
+func (s *ArtifactSink) write(a *Artifact) error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode artifact: %w", err)
+	}
+
+	ctx := context.Background()
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	key := s.Key(a)
+	if err := s.store.PutObject(ctx, key, data, "application/json"); err != nil {
+		return fmt.Errorf("failed to write artifact %s: %w", key, err)
+	}
+	return nil
+}
+
+// sampled reports whether an artifact should be written. The decision
+// hashes the trace ID so that every handler sharing a rate keeps the same
+// traces.
+func (s *ArtifactSink) sampled(a *Artifact) bool {
+	if s.sampleErrors && len(a.Errors) > 0 {
+		return true
+	}
+	if s.sampleRate >= 1 {
+		return true
+	}
+	if s.sampleRate <= 0 {
+		return false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(a.TraceID))
+	return float64(h.Sum64()%10000)/10000 < s.sampleRate
+}
+
+func (s *ArtifactSink) redact(text string) string {
+	for _, r := range s.redactors {
+		text = r(text)
+	}
+	return text
+}
+
+func (s *ArtifactSink) messages(v interface{}) []ArtifactMessage {
+	var out []ArtifactMessage
+	add := func(m *llm.ChatMessage) {
+		out = append(out, ArtifactMessage{
+			Role:    string(m.Role),
+			Content: s.redact(m.GetTextContent()),
+		})
+	}
+	switch msgs := v.(type) {
+	case []llm.ChatMessage:
+		for i := range msgs {
+			add(&msgs[i])
+		}
+	case []*llm.ChatMessage:
+		for _, m := range msgs {
+			if m != nil {
+				add(m)
+			}
+		}
+	default:
+		out = append(out, ArtifactMessage{Content: s.redact(payloadText(v))})
+	}
+	return out
+}
+
+func (s *ArtifactSink) contexts(v interface{}) []ArtifactContext {
+	var out []ArtifactContext
+	add := func(node *schema.Node, score float64) {
+		out = append(out, ArtifactContext{
+			NodeID:   node.ID,
+			Score:    score,
+			Text:     s.redact(node.GetText()),
+			Metadata: s.redactMetadata(node.Metadata),
+		})
+	}
+	switch nodes := v.(type) {
+	case []schema.NodeWithScore:
+		for i := range nodes {
+			add(&nodes[i].Node, nodes[i].Score)
+		}
+	case []schema.Node:
+		for i := range nodes {
+			add(&nodes[i], 0)
+		}
+	}
+	return out
+}
+
+func (s *ArtifactSink) redactMetadata(metadata map[string]interface{}) map[string]interface{} {
+	if len(metadata) == 0 {
+		return nil
+	}
+	out := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		if s.redactedKeys[strings.ToLower(k)] {
+			out[k] = RedactedValue
+		} else if text, ok := v.(string); ok {
+			out[k] = s.redact(text)
+		} else {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// payloadText renders a payload value as text.
+func payloadText(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case error:
+		return t.Error()
+	case llm.CompletionResponse:
+		return t.Text
+	case *llm.CompletionResponse:
+		return t.Text
+	case llm.ChatMessage:
+		return t.GetTextContent()
+	case *llm.ChatMessage:
+		return t.GetTextContent()
+	case fmt.Stringer:
+		return t.String()
+	default:
+		return fmt.Sprintf("%v", t)
+	}
+}
+
+// Ensure ArtifactSink implements CallbackHandler.
+var _ CallbackHandler = (*ArtifactSink)(nil)
@@ -2,10 +2,20 @@ package callbacks
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/schema"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -410,6 +420,157 @@ func TestTokenCountingHandler(t *testing.T) {
 		assert.Equal(t, 0, handler.TotalLLMTokens())
 		assert.Equal(t, 0, handler.LLMEventCount())
 	})
+
+	t.Run("Model tokenizers", func(t *testing.T) {
+		handler := NewTokenCountingHandler(
+			WithModelTokenizer("gpt-4o", wordTokenizer{}),
+		)
+		manager := NewCallbackManager(WithHandlers([]CallbackHandler{handler}))
+
+		// Counted with the model's tokenizer.
+		eventID := manager.OnEventStart(CBEventTypeLLM, map[string]interface{}{
+			string(EventPayloadPrompt):    "one two three",
+			string(EventPayloadModelName): "gpt-4o-mini",
+		}, "", "")
+		manager.OnEventEnd(CBEventTypeLLM, map[string]interface{}{
+			string(EventPayloadCompletion):   "four five",
+			string(EventPayloadPromptTokens): 100,
+		}, eventID)
+
+		// Reported counts are used without a tokenizer.
+		eventID = manager.OnEventStart(CBEventTypeLLM, map[string]interface{}{
+			string(EventPayloadMessages):  []llm.ChatMessage{llm.NewUserMessage("hi")},
+			string(EventPayloadModelName): "claude",
+		}, "", "")
+		manager.OnEventEnd(CBEventTypeLLM, map[string]interface{}{
+			string(EventPayloadResponse):         "hello there",
+			string(EventPayloadPromptTokens):     7,
+			string(EventPayloadCompletionTokens): 5,
+		}, eventID)
+
+		// Otherwise tokens are estimated.
+		eventID = manager.OnEventStart(CBEventTypeEmbedding, map[string]interface{}{
+			string(EventPayloadChunks): []string{"abcdefgh"},
+		}, "", "")
+		manager.OnEventEnd(CBEventTypeEmbedding, nil, eventID)
+
+		counts := handler.TokenCounts()
+		require.Len(t, counts, 3)
+		assert.Equal(t, "gpt-4o-mini", counts[0].Model)
+		assert.Equal(t, 3, counts[0].PromptTokens)
+		assert.Equal(t, 2, counts[0].CompletionTokens)
+		assert.Equal(t, 7, counts[1].PromptTokens)
+		assert.Equal(t, 5, counts[1].CompletionTokens)
+		assert.Equal(t, 2, counts[2].EmbeddingTokens)
+
+		assert.Len(t, handler.LLMTokenCounts(), 2)
+		assert.Len(t, handler.EmbeddingTokenCounts(), 1)
+		assert.Equal(t, TokenUsage{
+			PromptTokens: 10, CompletionTokens: 7, EmbeddingTokens: 2, LLMCalls: 2, EmbeddingCalls: 1,
+		}, handler.Usage())
+		assert.Equal(t, 17, handler.TotalLLMTokens())
+	})
+
+	t.Run("Trace usage", func(t *testing.T) {
+		handler := NewTokenCountingHandler()
+		manager := NewCallbackManager(WithHandlers([]CallbackHandler{handler}))
+		llmCall := func(tokens int) {
+			eventID := manager.OnEventStart(CBEventTypeLLM, nil, "", "")
+			manager.OnEventEnd(CBEventTypeLLM, map[string]interface{}{
+				string(EventPayloadPromptTokens): tokens,
+			}, eventID)
+		}
+
+		require.NoError(t, manager.WithTrace("query", func() error {
+			llmCall(10)
+			return nil
+		}))
+		require.NoError(t, manager.WithTrace("index", func() error {
+			llmCall(20)
+			return nil
+		}))
+		require.NoError(t, manager.WithTrace("query", func() error {
+			llmCall(30)
+			return nil
+		}))
+		llmCall(1)
+
+		assert.Equal(t, 40, handler.TraceUsage("query").PromptTokens)
+		assert.Equal(t, 2, handler.TraceUsage("query").LLMCalls)
+		assert.Equal(t, 20, handler.TraceUsage("index").PromptTokens)
+		assert.Equal(t, 1, handler.TraceUsage("").PromptTokens)
+	})
+
+	t.Run("Cost report", func(t *testing.T) {
+		handler := NewTokenCountingHandler()
+		manager := NewCallbackManager(WithHandlers([]CallbackHandler{handler}))
+
+		eventID := manager.OnEventStart(CBEventTypeLLM, map[string]interface{}{
+			string(EventPayloadModelName): "gpt-4o-2024-08-06",
+		}, "", "")
+		manager.OnEventEnd(CBEventTypeLLM, map[string]interface{}{
+			string(EventPayloadPromptTokens):     1000000,
+			string(EventPayloadCompletionTokens): 500000,
+		}, eventID)
+		eventID = manager.OnEventStart(CBEventTypeEmbedding, map[string]interface{}{
+			string(EventPayloadModelName): "text-embedding-3-small",
+		}, "", "")
+		manager.OnEventEnd(CBEventTypeEmbedding, map[string]interface{}{
+			string(EventPayloadTotalTokens): 2000000,
+		}, eventID)
+		eventID = manager.OnEventStart(CBEventTypeLLM, map[string]interface{}{
+			string(EventPayloadModelName): "local-llama",
+		}, "", "")
+		manager.OnEventEnd(CBEventTypeLLM, map[string]interface{}{
+			string(EventPayloadPromptTokens): 10,
+		}, eventID)
+
+		report := handler.CostReport(PriceTable{
+			"gpt-4o":                 {Input: 2.5, Output: 10},
+			"gpt-4o-mini":            {Input: 0.15, Output: 0.6},
+			"text-embedding-3-small": {Input: 0.02},
+		})
+		require.Len(t, report.Models, 3)
+		assert.Equal(t, "gpt-4o-2024-08-06", report.Models[0].Model)
+		assert.InDelta(t, 7.5, report.Models[0].Cost, 1e-9)
+		assert.Equal(t, "text-embedding-3-small", report.Models[1].Model)
+		assert.InDelta(t, 0.04, report.Models[1].Cost, 1e-9)
+		assert.False(t, report.Models[2].Priced)
+		assert.InDelta(t, 7.54, report.TotalCost, 1e-9)
+		assert.Equal(t, []string{"local-llama"}, report.Unpriced)
+		assert.Equal(t, 1000010, report.Usage.PromptTokens)
+		assert.Equal(t, 3, report.Usage.LLMCalls+report.Usage.EmbeddingCalls)
+
+		output := report.String()
+		assert.Contains(t, output, "gpt-4o-2024-08-06")
+		assert.Contains(t, output, "n/a")
+		assert.Contains(t, output, "7.540000")
+	})
+}
+
+// TestPriceTable tests price lookup by longest prefix.
+func TestPriceTable(t *testing.T) {
+	prices := PriceTable{
+		"gpt-4o":      {Input: 2.5},
+		"gpt-4o-mini": {Input: 0.15},
+	}
+
+	price, ok := prices.Lookup("gpt-4o-mini-2024-07-18")
+	assert.True(t, ok)
+	assert.Equal(t, 0.15, price.Input)
+
+	price, ok = prices.Lookup("gpt-4o")
+	assert.True(t, ok)
+	assert.Equal(t, 2.5, price.Input)
+
+	_, ok = prices.Lookup("claude-3-haiku")
+	assert.False(t, ok)
+	_, ok = prices.Lookup("")
+	assert.False(t, ok)
+
+	assert.InDelta(t, 0.5, ModelPrice{Input: 0.1, Output: 0.4}.Cost(TokenUsage{
+		PromptTokens: 1000000, CompletionTokens: 1000000,
+	}), 1e-9)
 }
 
 // TestEventCollectorHandler tests the EventCollectorHandler.
@@ -601,3 +762,303 @@ func TestLoggingHandlerOutput(t *testing.T) {
 		assert.True(t, strings.Contains(output, "/"))
 	})
 }
+
+// TestArtifactSink tests writing trace artifacts to an object store.
+func TestArtifactSink(t *testing.T) {
+	runTrace := func(manager *CallbackManager, traceID string, fail bool) {
+		_ = manager.WithTrace(traceID, func() error {
+			query := manager.Event(CBEventTypeQuery, "")
+			query.OnStart(map[string]interface{}{
+				string(EventPayloadQueryStr): "What is jane@example.com's plan?",
+			})
+
+			retrieve := manager.Event(CBEventTypeRetrieve, "")
+			retrieve.OnStart(nil)
+			retrieve.OnEnd(map[string]interface{}{
+				string(EventPayloadNodes): []schema.NodeWithScore{{
+					Node: schema.Node{
+						ID:       "n1",
+						Text:     "Jane is on the pro plan.",
+						Metadata: map[string]interface{}{"customer_id": "c-42", "source": "crm"},
+					},
+					Score: 0.9,
+				}},
+			})
+
+			call := manager.Event(CBEventTypeLLM, "llm-1")
+			call.OnStart(map[string]interface{}{
+				string(EventPayloadMessages): []llm.ChatMessage{
+					llm.NewUserMessage("Context: Jane is on the pro plan. Key sk-abcdefghijklmnopqrstuv"),
+				},
+				string(EventPayloadModelName): "gpt-test",
+			})
+			call.OnEnd(map[string]interface{}{
+				string(EventPayloadCompletion): "Jane is on the pro plan.",
+			})
+
+			query.OnEnd(map[string]interface{}{
+				string(EventPayloadResponse): "Jane is on the pro plan.",
+			})
+			if fail {
+				return errors.New("synthesis failed")
+			}
+			return nil
+		})
+	}
+
+	t.Run("Writes redacted artifact keyed by trace", func(t *testing.T) {
+		objects := NewMemoryObjectStore()
+		sink := NewArtifactSink(objects, WithArtifactRedactedKeys("Customer_ID"))
+		manager := NewCallbackManager(WithHandlers([]CallbackHandler{sink}))
+
+		runTrace(manager, "trace-1", false)
+
+		keys := objects.Keys()
+		require.Len(t, keys, 1)
+		assert.True(t, strings.HasPrefix(keys[0], "artifacts/trace-1/"))
+		assert.True(t, strings.HasSuffix(keys[0], ".json"))
+
+		data, _ := objects.Get(keys[0])
+		var artifact Artifact
+		require.NoError(t, json.Unmarshal(data, &artifact))
+
+		assert.Equal(t, ArtifactSchemaVersion, artifact.SchemaVersion)
+		assert.Equal(t, "trace-1", artifact.TraceID)
+		assert.Equal(t, "What is [REDACTED]'s plan?", artifact.Query)
+		assert.Equal(t, "Jane is on the pro plan.", artifact.Response)
+		require.Len(t, artifact.Contexts, 1)
+		assert.Equal(t, "n1", artifact.Contexts[0].NodeID)
+		assert.Equal(t, RedactedValue, artifact.Contexts[0].Metadata["customer_id"])
+		assert.Equal(t, "crm", artifact.Contexts[0].Metadata["source"])
+		require.Len(t, artifact.LLMCalls, 1)
+		assert.Equal(t, "gpt-test", artifact.LLMCalls[0].Model)
+		assert.Equal(t, "Jane is on the pro plan.", artifact.LLMCalls[0].Completion)
+		require.Len(t, artifact.LLMCalls[0].Messages, 1)
+		assert.Equal(t, "user", artifact.LLMCalls[0].Messages[0].Role)
+		assert.NotContains(t, artifact.LLMCalls[0].Messages[0].Content, "sk-")
+		assert.Empty(t, artifact.Errors)
+	})
+
+	t.Run("Repeated trace IDs create new versions", func(t *testing.T) {
+		objects := NewMemoryObjectStore()
+		sink := NewArtifactSink(objects, WithArtifactPrefix("/prod/"))
+		tick := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		sink.now = func() time.Time {
+			tick = tick.Add(time.Millisecond)
+			return tick
+		}
+		manager := NewCallbackManager(WithHandlers([]CallbackHandler{sink}))
+
+		runTrace(manager, "trace-1", false)
+		runTrace(manager, "trace-1", false)
+
+		keys := objects.Keys()
+		require.Len(t, keys, 2)
+		for _, key := range keys {
+			assert.True(t, strings.HasPrefix(key, "prod/trace-1/"))
+		}
+	})
+
+	t.Run("Sampling", func(t *testing.T) {
+		objects := NewMemoryObjectStore()
+		sink := NewArtifactSink(objects, WithArtifactSampleRate(0))
+		manager := NewCallbackManager(WithHandlers([]CallbackHandler{sink}))
+
+		runTrace(manager, "ok", false)
+		assert.Empty(t, objects.Keys())
+
+		runTrace(manager, "failed", true)
+		keys := objects.Keys()
+		require.Len(t, keys, 1)
+		data, _ := objects.Get(keys[0])
+		var artifact Artifact
+		require.NoError(t, json.Unmarshal(data, &artifact))
+		assert.Equal(t, []string{"synthesis failed"}, artifact.Errors)
+
+		half := NewArtifactSink(NewMemoryObjectStore(), WithArtifactSampleRate(0.5))
+		kept := 0
+		for i := 0; i < 1000; i++ {
+			a := &Artifact{TraceID: fmt.Sprintf("trace-%d", i)}
+			if half.sampled(a) {
+				kept++
+			}
+			assert.Equal(t, half.sampled(a), half.sampled(a))
+		}
+		assert.InDelta(t, 500, kept, 100)
+	})
+
+	t.Run("Write errors are reported", func(t *testing.T) {
+		var failedTrace string
+		sink := NewArtifactSink(
+			ObjectStoreFunc(func(ctx context.Context, key string, data []byte, contentType string) error {
+				return errors.New("access denied")
+			}),
+			WithArtifactErrorHandler(func(traceID string, err error) {
+				failedTrace = traceID
+			}),
+		)
+		manager := NewCallbackManager(WithHandlers([]CallbackHandler{sink}))
+
+		runTrace(manager, "trace-1", false)
+		assert.Equal(t, "trace-1", failedTrace)
+	})
+
+	t.Run("DirObjectStore", func(t *testing.T) {
+		dir := t.TempDir()
+		objects := NewDirObjectStore(dir)
+
+		require.NoError(t, objects.PutObject(context.Background(), "a/b.json", []byte("{}"), "application/json"))
+		data, err := os.ReadFile(filepath.Join(dir, "a", "b.json"))
+		require.NoError(t, err)
+		assert.Equal(t, "{}", string(data))
+
+		assert.Error(t, objects.PutObject(context.Background(), "../escape.json", []byte("{}"), "application/json"))
+	})
+}
+
+func TestStartSpan(t *testing.T) {
+	t.Run("no manager", func(t *testing.T) {
+		ctx := context.Background()
+		spanCtx, span := StartSpan(ctx, CBEventTypeQuery, nil)
+		assert.Nil(t, span)
+		assert.Equal(t, ctx, spanCtx)
+		span.End(nil, errors.New("ignored"))
+		assert.Equal(t, "", span.ID())
+	})
+
+	t.Run("nesting", func(t *testing.T) {
+		collector := NewEventCollectorHandler()
+		manager := NewCallbackManager(WithHandlers([]CallbackHandler{collector}))
+		ctx := ContextWithManager(context.Background(), manager)
+		assert.Same(t, manager, ManagerFromContext(ctx))
+
+		queryCtx, query := StartSpan(ctx, CBEventTypeQuery, map[string]interface{}{
+			string(EventPayloadQueryStr): "q",
+		})
+		_, retrieve := StartSpan(queryCtx, CBEventTypeRetrieve, nil)
+		retrieve.End(nil, errors.New("boom"))
+		query.End(nil, nil)
+
+		starts := collector.StartEvents()
+		require.Len(t, starts, 2)
+		assert.Equal(t, BaseTraceEvent, starts[0].ParentID)
+		assert.Equal(t, query.ID(), starts[1].ParentID)
+
+		ends := collector.EndEvents()
+		require.Len(t, ends, 2)
+		assert.Equal(t, retrieve.ID(), ends[0].EventID)
+		assert.EqualError(t, ends[0].Payload[string(EventPayloadException)].(error), "boom")
+		assert.IsType(t, time.Duration(0), ends[1].Payload[string(EventPayloadDuration)])
+		assert.NotContains(t, ends[1].Payload, string(EventPayloadException))
+	})
+}
+
+// wordTokenizer splits on whitespace.
+type wordTokenizer struct{}
+
+func (wordTokenizer) Encode(text string) []string { return strings.Fields(text) }
+
+func TestInstrumentedLLM(t *testing.T) {
+	tokens := NewTokenCountingHandler()
+	collector := NewEventCollectorHandler()
+	manager := NewCallbackManager(WithHandlers([]CallbackHandler{tokens, collector}))
+	ctx := ContextWithManager(context.Background(), manager)
+
+	l := NewInstrumentedLLM(llm.NewMockLLM("the answer"), WithInstrumentTokenizer(wordTokenizer{}))
+	assert.Equal(t, "mock-model", l.Metadata().ModelName)
+
+	completion, err := l.Complete(ctx, "what is the question")
+	require.NoError(t, err)
+	assert.Equal(t, "the answer", completion)
+
+	_, err = l.Chat(ctx, []llm.ChatMessage{llm.NewUserMessage("hi there")})
+	require.NoError(t, err)
+
+	stream, err := l.Stream(ctx, "stream this")
+	require.NoError(t, err)
+	for range stream {
+	}
+
+	assert.Equal(t, 3, tokens.LLMEventCount())
+	assert.Equal(t, 4+2+2, tokens.PromptTokens())
+	assert.Equal(t, 3*2, tokens.CompletionTokens())
+
+	starts := collector.GetEventsByType(CBEventTypeLLM)
+	require.Len(t, starts, 3)
+	assert.Equal(t, "what is the question", starts[0].Payload[string(EventPayloadPrompt)])
+	assert.Equal(t, "mock-model", starts[0].Payload[string(EventPayloadModelName)])
+	ends := collector.EndEvents()
+	assert.Equal(t, "the answer", ends[0].Payload[string(EventPayloadCompletion)])
+	assert.Equal(t, "the answer", ends[1].Payload[string(EventPayloadResponse)])
+	assert.Equal(t, "the answer", ends[2].Payload[string(EventPayloadCompletion)])
+
+	t.Run("error", func(t *testing.T) {
+		collector.Clear()
+		failing := NewInstrumentedLLM(llm.NewMockLLMWithError(errors.New("down")))
+		_, err := failing.Complete(ctx, "q")
+		assert.Error(t, err)
+		ends := collector.EndEvents()
+		require.Len(t, ends, 1)
+		assert.Contains(t, ends[0].Payload, string(EventPayloadException))
+	})
+
+	t.Run("no manager", func(t *testing.T) {
+		completion, err := l.Complete(context.Background(), "q")
+		require.NoError(t, err)
+		assert.Equal(t, "the answer", completion)
+	})
+}
+
+func TestInstrumentedEmbedding(t *testing.T) {
+	tokens := NewTokenCountingHandler()
+	manager := NewCallbackManager(WithHandlers([]CallbackHandler{tokens}))
+	ctx := ContextWithManager(context.Background(), manager)
+
+	e := NewInstrumentedEmbedding(embedding.NewMockEmbeddingModel([]float64{1, 0}))
+	emb, err := e.GetQueryEmbedding(ctx, "abcd")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1, 0}, emb)
+
+	embs, err := e.GetTextEmbeddingsBatch(ctx, []string{"abcdefgh", "ab"}, nil)
+	require.NoError(t, err)
+	assert.Len(t, embs, 2)
+
+	assert.Equal(t, 2, tokens.EmbedEventCount())
+	assert.Equal(t, 1+2+1, tokens.TotalEmbedTokens())
+
+	failing := NewInstrumentedEmbedding(embedding.NewMockEmbeddingModelWithError(errors.New("down")))
+	_, err = failing.GetTextEmbedding(ctx, "x")
+	assert.Error(t, err)
+}
+
+func TestDebugHandler(t *testing.T) {
+	var buf bytes.Buffer
+	debug := NewDebugHandler(WithDebugWriter(&buf))
+	manager := NewCallbackManager(WithHandlers([]CallbackHandler{debug}))
+	ctx := ContextWithManager(context.Background(), manager)
+
+	err := manager.WithTrace("query", func() error {
+		queryCtx, query := StartSpan(ctx, CBEventTypeQuery, nil)
+		l := NewInstrumentedLLM(llm.NewMockLLM("answer"))
+		_, err := l.Complete(queryCtx, "prompt")
+		query.End(nil, err)
+		return err
+	})
+	require.NoError(t, err)
+
+	pairs := debug.LLMInputsOutputs()
+	require.Len(t, pairs, 1)
+	assert.Equal(t, "prompt", pairs[0].StartPayload[string(EventPayloadPrompt)])
+	assert.Equal(t, "answer", pairs[0].EndPayload[string(EventPayloadCompletion)])
+	assert.GreaterOrEqual(t, pairs[0].Duration(), time.Duration(0))
+	assert.Len(t, debug.EventPairs(""), 2)
+	assert.Equal(t, 1, debug.EventTimeInfo(CBEventTypeQuery).TotalCount)
+
+	out := buf.String()
+	assert.Contains(t, out, "Trace: query")
+	assert.Contains(t, out, "  |_query -> ")
+	assert.Contains(t, out, "    |_llm -> ")
+
+	debug.Clear()
+	assert.Empty(t, debug.EventPairs(""))
+}
@@ -0,0 +1,127 @@
+package callbacks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ModelPrice is the price of a model in USD per million tokens.
+type ModelPrice struct {
+	// Input is the price of prompt and embedding tokens.
+	Input float64 `json:"input"`
+	// Output is the price of completion tokens.
+	Output float64 `json:"output"`
+}
+
+// Cost returns the cost of usage.
+func (p ModelPrice) Cost(usage TokenUsage) float64 {
+	input := float64(usage.PromptTokens + usage.EmbeddingTokens)
+	output := float64(usage.CompletionTokens)
+	return (input*p.Input + output*p.Output) / 1e6
+}
+
+// PriceTable maps model names to prices. A model without an entry of its
+// own is priced by the entry of its longest prefix, so "gpt-4o" prices
+// "gpt-4o-2024-08-06".
+type PriceTable map[string]ModelPrice
+
+// Lookup returns the price of model.
+func (t PriceTable) Lookup(model string) (ModelPrice, bool) {
+	return longestPrefixMatch(t, model)
+}
+
+// longestPrefixMatch returns the value of the longest key of m that is a
+// prefix of name. The empty key only matches the empty name.
+func longestPrefixMatch[V any](m map[string]V, name string) (V, bool) {
+	if v, ok := m[name]; ok {
+		return v, true
+	}
+	var best V
+	bestLen := 0
+	for key, v := range m {
+		if len(key) > bestLen && strings.HasPrefix(name, key) {
+			best, bestLen = v, len(key)
+		}
+	}
+	return best, bestLen > 0
+}
+
+// ModelCost is the usage and cost of a model.
+type ModelCost struct {
+	// Model is the model name, "" for calls without one.
+	Model string `json:"model"`
+	// Usage is the model's token usage.
+	Usage TokenUsage `json:"usage"`
+	// Cost is the estimated cost in USD, 0 if the model is not priced.
+	Cost float64 `json:"cost"`
+	// Priced reports whether the price table has a price for the model.
+	Priced bool `json:"priced"`
+}
+
+// CostReport is the estimated cost of token usage.
+type CostReport struct {
+	// Models are the per-model costs, most expensive first.
+	Models []ModelCost `json:"models"`
+	// Usage is the total usage.
+	Usage TokenUsage `json:"usage"`
+	// TotalCost is the total estimated cost in USD.
+	TotalCost float64 `json:"total_cost"`
+	// Unpriced lists the models without a price, whose cost is missing
+	// from TotalCost.
+	Unpriced []string `json:"unpriced,omitempty"`
+}
+
+// NewCostReport prices the usage of each model with prices.
+func NewCostReport(usageByModel map[string]TokenUsage, prices PriceTable) *CostReport {
+	report := &CostReport{}
+	for model, usage := range usageByModel {
+		cost := ModelCost{Model: model, Usage: usage}
+		if price, ok := prices.Lookup(model); ok {
+			cost.Cost = price.Cost(usage)
+			cost.Priced = true
+		} else {
+			report.Unpriced = append(report.Unpriced, model)
+		}
+		report.Models = append(report.Models, cost)
+		report.TotalCost += cost.Cost
+
+		report.Usage.PromptTokens += usage.PromptTokens
+		report.Usage.CompletionTokens += usage.CompletionTokens
+		report.Usage.EmbeddingTokens += usage.EmbeddingTokens
+		report.Usage.LLMCalls += usage.LLMCalls
+		report.Usage.EmbeddingCalls += usage.EmbeddingCalls
+	}
+
+	sort.Slice(report.Models, func(i, j int) bool {
+		if report.Models[i].Cost != report.Models[j].Cost {
+			return report.Models[i].Cost > report.Models[j].Cost
+		}
+		return report.Models[i].Model < report.Models[j].Model
+	})
+	sort.Strings(report.Unpriced)
+	return report
+}
+
+// String formats the report as a table.
+func (r *CostReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-30s %8s %12s %12s %12s %12s\n", "model", "calls", "prompt", "completion", "embedding", "cost (USD)")
+	for _, m := range r.Models {
+		name := m.Model
+		if name == "" {
+			name = "(unknown)"
+		}
+		cost := "n/a"
+		if m.Priced {
+			cost = fmt.Sprintf("%.6f", m.Cost)
+		}
+		fmt.Fprintf(&sb, "%-30s %8d %12d %12d %12d %12s\n", name,
+			m.Usage.LLMCalls+m.Usage.EmbeddingCalls,
+			m.Usage.PromptTokens, m.Usage.CompletionTokens, m.Usage.EmbeddingTokens, cost)
+	}
+	fmt.Fprintf(&sb, "%-30s %8d %12d %12d %12d %12.6f\n", "total",
+		r.Usage.LLMCalls+r.Usage.EmbeddingCalls,
+		r.Usage.PromptTokens, r.Usage.CompletionTokens, r.Usage.EmbeddingTokens, r.TotalCost)
+	return sb.String()
+}
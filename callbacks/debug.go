@@ -0,0 +1,195 @@
+package callbacks
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventPair is an event with both its start and end recorded.
+type EventPair struct {
+	// EventType is the type of the event.
+	EventType CBEventType
+	// EventID is the unique identifier of the event.
+	EventID string
+	// ParentID is the ID of the parent event.
+	ParentID string
+	// StartPayload and EndPayload are the payloads of the start and end.
+	StartPayload map[string]interface{}
+	EndPayload   map[string]interface{}
+	// Start and End are when the event started and ended.
+	Start time.Time
+	End   time.Time
+}
+
+// Duration returns how long the event took.
+func (p EventPair) Duration() time.Duration {
+	return p.End.Sub(p.Start)
+}
+
+// DebugHandler records events as start/end pairs for inspection, e.g. of
+// the LLM inputs and outputs of a query, and prints the tree of events of
+// each trace with their durations when it ends.
+type DebugHandler struct {
+	*BaseCallbackHandler
+	writer          io.Writer
+	printTraceOnEnd bool
+
+	mu      sync.Mutex
+	started map[string]*EventPair
+	pairs   []EventPair
+}
+
+// DebugHandlerOption configures a DebugHandler.
+type DebugHandlerOption func(*DebugHandler)
+
+// WithDebugWriter sets the writer traces are printed to. Defaults to
+// os.Stdout.
+func WithDebugWriter(w io.Writer) DebugHandlerOption {
+	return func(h *DebugHandler) {
+		h.writer = w
+	}
+}
+
+// WithPrintTraceOnEnd sets whether the trace is printed when it ends.
+// Defaults to true.
+func WithPrintTraceOnEnd(print bool) DebugHandlerOption {
+	return func(h *DebugHandler) {
+		h.printTraceOnEnd = print
+	}
+}
+
+// NewDebugHandler creates a new DebugHandler.
+func NewDebugHandler(opts ...DebugHandlerOption) *DebugHandler {
+	h := &DebugHandler{
+		BaseCallbackHandler: NewBaseCallbackHandler(),
+		writer:              os.Stdout,
+		printTraceOnEnd:     true,
+		started:             make(map[string]*EventPair),
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// OnEventStart records the event start.
+func (h *DebugHandler) OnEventStart(
+	eventType CBEventType,
+	payload map[string]interface{},
+	eventID string,
+	parentID string,
+) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.started[eventID] = &EventPair{
+		EventType:    eventType,
+		EventID:      eventID,
+		ParentID:     parentID,
+		StartPayload: payload,
+		Start:        time.Now(),
+	}
+	return eventID
+}
+
+// OnEventEnd completes the event's pair.
+func (h *DebugHandler) OnEventEnd(
+	eventType CBEventType,
+	payload map[string]interface{},
+	eventID string,
+) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	pair, ok := h.started[eventID]
+	if !ok {
+		return
+	}
+	delete(h.started, eventID)
+	pair.EndPayload = payload
+	pair.End = time.Now()
+	h.pairs = append(h.pairs, *pair)
+}
+
+// EndTrace prints the trace if enabled.
+func (h *DebugHandler) EndTrace(traceID string, traceMap map[string][]string) {
+	if !h.printTraceOnEnd {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	byID := make(map[string]EventPair, len(h.pairs))
+	for _, p := range h.pairs {
+		byID[p.EventID] = p
+	}
+
+	var sb strings.Builder
+	sb.WriteString(strings.Repeat("*", 10) + "\n")
+	fmt.Fprintf(&sb, "Trace: %s\n", traceID)
+	h.writeTree(&sb, traceMap, byID, BaseTraceEvent, 1)
+	sb.WriteString(strings.Repeat("*", 10) + "\n")
+	fmt.Fprint(h.writer, sb.String())
+}
+
+// writeTree writes the children of parentID, indented by depth.
+func (h *DebugHandler) writeTree(sb *strings.Builder, traceMap map[string][]string, byID map[string]EventPair, parentID string, depth int) {
+	for _, id := range traceMap[parentID] {
+		indent := strings.Repeat("  ", depth)
+		if p, ok := byID[id]; ok {
+			fmt.Fprintf(sb, "%s|_%s -> %v\n", indent, p.EventType, p.Duration())
+		} else {
+			fmt.Fprintf(sb, "%s|_%s (not ended)\n", indent, id)
+		}
+		h.writeTree(sb, traceMap, byID, id, depth+1)
+	}
+}
+
+// EventPairs returns the ended events of eventType in the order they
+// ended. An empty eventType returns all of them.
+func (h *DebugHandler) EventPairs(eventType CBEventType) []EventPair {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var pairs []EventPair
+	for _, p := range h.pairs {
+		if eventType == "" || p.EventType == eventType {
+			pairs = append(pairs, p)
+		}
+	}
+	return pairs
+}
+
+// LLMInputsOutputs returns the ended LLM events, whose payloads hold the
+// prompts or messages and the completions.
+func (h *DebugHandler) LLMInputsOutputs() []EventPair {
+	return h.EventPairs(CBEventTypeLLM)
+}
+
+// EventTimeInfo returns the time statistics of the ended events of
+// eventType.
+func (h *DebugHandler) EventTimeInfo(eventType CBEventType) *EventStats {
+	pairs := h.EventPairs(eventType)
+	total := 0.0
+	for _, p := range pairs {
+		total += p.Duration().Seconds()
+	}
+	return NewEventStats(total, len(pairs))
+}
+
+// Clear removes all recorded events.
+func (h *DebugHandler) Clear() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.started = make(map[string]*EventPair)
+	h.pairs = nil
+}
+
+// Ensure DebugHandler implements CallbackHandler.
+var _ CallbackHandler = (*DebugHandler)(nil)
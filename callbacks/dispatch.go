@@ -0,0 +1,90 @@
+package callbacks
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// managerContextKey is the context key for the callback manager.
+type managerContextKey struct{}
+
+// spanContextKey is the context key for the current span.
+type spanContextKey struct{}
+
+// ContextWithManager returns a context carrying m. Retriever query engines,
+// agents, ingestion pipelines and instrumented models report their events
+// to the manager of the context they run with.
+func ContextWithManager(ctx context.Context, m *CallbackManager) context.Context {
+	return context.WithValue(ctx, managerContextKey{}, m)
+}
+
+// ManagerFromContext returns the manager set with ContextWithManager, or
+// nil.
+func ManagerFromContext(ctx context.Context) *CallbackManager {
+	m, _ := ctx.Value(managerContextKey{}).(*CallbackManager)
+	return m
+}
+
+// Span is a started event. Its end is reported by End.
+type Span struct {
+	manager   *CallbackManager
+	eventType CBEventType
+	id        string
+	start     time.Time
+}
+
+// StartSpan starts an event of eventType on the manager in ctx and returns
+// a context whose events are its children. Without a manager it returns
+// ctx and a nil span, whose methods are no-ops, so callers can always
+// instrument:
+//
+//	ctx, span := callbacks.StartSpan(ctx, callbacks.CBEventTypeRetrieve, payload)
+//	nodes, err := r.Retrieve(ctx, query)
+//	span.End(map[string]interface{}{"nodes": nodes}, err)
+func StartSpan(ctx context.Context, eventType CBEventType, payload map[string]interface{}) (context.Context, *Span) {
+	m := ManagerFromContext(ctx)
+	if m == nil {
+		return ctx, nil
+	}
+
+	parentID := ""
+	if parent, ok := ctx.Value(spanContextKey{}).(*Span); ok {
+		parentID = parent.id
+	}
+
+	s := &Span{
+		manager:   m,
+		eventType: eventType,
+		id:        uuid.New().String(),
+		start:     time.Now(),
+	}
+	m.OnEventStart(eventType, payload, s.id, parentID)
+	return context.WithValue(ctx, spanContextKey{}, s), s
+}
+
+// ID returns the span's event ID, or "" for a nil span.
+func (s *Span) ID() string {
+	if s == nil {
+		return ""
+	}
+	return s.id
+}
+
+// End ends the span. The payload is extended with the span's duration and,
+// if err is not nil, the error as exception.
+func (s *Span) End(payload map[string]interface{}, err error) {
+	if s == nil {
+		return
+	}
+
+	if payload == nil {
+		payload = make(map[string]interface{})
+	}
+	payload[string(EventPayloadDuration)] = time.Since(s.start)
+	if err != nil {
+		payload[string(EventPayloadException)] = err
+	}
+	s.manager.OnEventEnd(s.eventType, payload, s.id)
+}
@@ -6,6 +6,10 @@ import (
 	"os"
 	"sync"
 	"time"
+
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 // LoggingHandler is a callback handler that logs events.
@@ -131,9 +135,66 @@ func (h *LoggingHandler) EndTrace(traceID string, traceMap map[string][]string)
 // Ensure LoggingHandler implements CallbackHandler.
 var _ CallbackHandler = (*LoggingHandler)(nil)
 
-// TokenCountingHandler tracks token usage.
+// TokenUsage is the token usage of a set of LLM and embedding calls.
+type TokenUsage struct {
+	// PromptTokens and CompletionTokens are the tokens of LLM calls.
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	// EmbeddingTokens are the tokens of embedding calls.
+	EmbeddingTokens int `json:"embedding_tokens"`
+	// LLMCalls and EmbeddingCalls count the calls.
+	LLMCalls       int `json:"llm_calls"`
+	EmbeddingCalls int `json:"embedding_calls"`
+}
+
+// TotalTokens returns the sum of prompt, completion and embedding tokens.
+func (u TokenUsage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens + u.EmbeddingTokens
+}
+
+// add adds the tokens of a call.
+func (u *TokenUsage) add(c TokenCount) {
+	u.PromptTokens += c.PromptTokens
+	u.CompletionTokens += c.CompletionTokens
+	u.EmbeddingTokens += c.EmbeddingTokens
+	if c.EventType == CBEventTypeEmbedding {
+		u.EmbeddingCalls++
+	} else {
+		u.LLMCalls++
+	}
+}
+
+// TokenCount is the token count of one LLM or embedding call.
+type TokenCount struct {
+	// EventID is the ID of the call's event.
+	EventID string `json:"event_id"`
+	// EventType is CBEventTypeLLM or CBEventTypeEmbedding.
+	EventType CBEventType `json:"event_type"`
+	// TraceID is the ID of the trace the call started in, or "".
+	TraceID string `json:"trace_id,omitempty"`
+	// Model is the name of the model, if reported.
+	Model string `json:"model,omitempty"`
+	// PromptTokens and CompletionTokens are set for LLM calls.
+	PromptTokens     int `json:"prompt_tokens,omitempty"`
+	CompletionTokens int `json:"completion_tokens,omitempty"`
+	// EmbeddingTokens is set for embedding calls.
+	EmbeddingTokens int `json:"embedding_tokens,omitempty"`
+	// Time is when the call ended.
+	Time time.Time `json:"time"`
+}
+
+// TokenCountingHandler tracks the token usage of LLM and embedding events,
+// per call, per trace and per model. Tokens are counted from the prompts,
+// messages, completions and chunks of the events with the tokenizer of the
+// event's model if one is configured; otherwise the counts reported in the
+// payload are used, or estimated at four characters per token.
+//
+// Every call is recorded until Reset; CostReport prices the usage.
 type TokenCountingHandler struct {
 	*BaseCallbackHandler
+	tokenizer       textutil.Tokenizer
+	modelTokenizers map[string]textutil.Tokenizer
+
 	mu               sync.Mutex
 	totalLLMTokens   int
 	promptTokens     int
@@ -141,66 +202,192 @@ type TokenCountingHandler struct {
 	totalEmbedTokens int
 	llmEventCount    int
 	embedEventCount  int
+	started          map[string]tokenCountStart
+	traceID          string
+	counts           []TokenCount
+}
+
+// tokenCountStart is what is known of a call when it starts.
+type tokenCountStart struct {
+	traceID string
+	model   string
+	inputs  []string
+}
+
+// TokenCountingOption configures a TokenCountingHandler.
+type TokenCountingOption func(*TokenCountingHandler)
+
+// WithTokenCountingTokenizer sets the tokenizer for models without a model
+// tokenizer.
+func WithTokenCountingTokenizer(tokenizer textutil.Tokenizer) TokenCountingOption {
+	return func(h *TokenCountingHandler) {
+		h.tokenizer = tokenizer
+	}
+}
+
+// WithModelTokenizer sets the tokenizer for models whose name starts with
+// model, e.g. "gpt-4o". The longest matching prefix wins.
+func WithModelTokenizer(model string, tokenizer textutil.Tokenizer) TokenCountingOption {
+	return func(h *TokenCountingHandler) {
+		h.modelTokenizers[model] = tokenizer
+	}
 }
 
 // NewTokenCountingHandler creates a new TokenCountingHandler.
-func NewTokenCountingHandler() *TokenCountingHandler {
-	return &TokenCountingHandler{
+func NewTokenCountingHandler(opts ...TokenCountingOption) *TokenCountingHandler {
+	h := &TokenCountingHandler{
 		BaseCallbackHandler: NewBaseCallbackHandler(),
+		modelTokenizers:     make(map[string]textutil.Tokenizer),
+		started:             make(map[string]tokenCountStart),
+	}
+
+	for _, opt := range opts {
+		opt(h)
 	}
+
+	return h
 }
 
-// OnEventStart handles event start for token counting.
+// OnEventStart records the inputs and model of LLM and embedding events.
 func (h *TokenCountingHandler) OnEventStart(
 	eventType CBEventType,
 	payload map[string]interface{},
 	eventID string,
 	parentID string,
 ) string {
+	if eventType != CBEventTypeLLM && eventType != CBEventTypeEmbedding {
+		return eventID
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	model, _ := payload[string(EventPayloadModelName)].(string)
+	h.started[eventID] = tokenCountStart{
+		traceID: h.traceID,
+		model:   model,
+		inputs:  payloadTexts(payload, EventPayloadPrompt, EventPayloadMessages, EventPayloadChunks),
+	}
 	return eventID
 }
 
-// OnEventEnd handles event end for token counting.
+// OnEventEnd counts the tokens of LLM and embedding events.
 func (h *TokenCountingHandler) OnEventEnd(
 	eventType CBEventType,
 	payload map[string]interface{},
 	eventID string,
 ) {
+	if eventType != CBEventTypeLLM && eventType != CBEventTypeEmbedding {
+		return
+	}
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if payload == nil {
-		return
+	start, ok := h.started[eventID]
+	if ok {
+		delete(h.started, eventID)
+	} else {
+		start.traceID = h.traceID
+	}
+	if model, ok := payload[string(EventPayloadModelName)].(string); ok && model != "" {
+		start.model = model
+	}
+
+	count := TokenCount{
+		EventID:   eventID,
+		EventType: eventType,
+		TraceID:   start.traceID,
+		Model:     start.model,
+		Time:      time.Now(),
 	}
+	tokenizer := h.tokenizerFor(start.model)
 
 	switch eventType {
 	case CBEventTypeLLM:
-		h.llmEventCount++
-		if tokens, ok := payload["prompt_tokens"].(int); ok {
-			h.promptTokens += tokens
-			h.totalLLMTokens += tokens
-		}
-		if tokens, ok := payload["completion_tokens"].(int); ok {
-			h.completionTokens += tokens
-			h.totalLLMTokens += tokens
-		}
-		if tokens, ok := payload["total_tokens"].(int); ok {
-			h.totalLLMTokens = tokens
+		count.PromptTokens = countTokens(tokenizer, payload[string(EventPayloadPromptTokens)], start.inputs)
+		outputs := payloadTexts(payload, EventPayloadCompletion, EventPayloadResponse)
+		count.CompletionTokens = countTokens(tokenizer, payload[string(EventPayloadCompletionTokens)], outputs)
+
+		total := count.PromptTokens + count.CompletionTokens
+		if tokens, ok := payload[string(EventPayloadTotalTokens)].(int); ok && tokens > total {
+			total = tokens
 		}
+		h.llmEventCount++
+		h.promptTokens += count.PromptTokens
+		h.completionTokens += count.CompletionTokens
+		h.totalLLMTokens += total
 
 	case CBEventTypeEmbedding:
-		h.embedEventCount++
-		if tokens, ok := payload["total_tokens"].(int); ok {
-			h.totalEmbedTokens += tokens
+		inputs := start.inputs
+		if len(inputs) == 0 {
+			inputs = payloadTexts(payload, EventPayloadChunks)
 		}
+		count.EmbeddingTokens = countTokens(tokenizer, payload[string(EventPayloadTotalTokens)], inputs)
+
+		h.embedEventCount++
+		h.totalEmbedTokens += count.EmbeddingTokens
+	}
+
+	h.counts = append(h.counts, count)
+}
+
+// StartTrace sets the trace that calls are attributed to.
+func (h *TokenCountingHandler) StartTrace(traceID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.traceID = traceID
+}
+
+// EndTrace ends the attribution of calls to the trace.
+func (h *TokenCountingHandler) EndTrace(traceID string, traceMap map[string][]string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.traceID == traceID {
+		h.traceID = ""
+	}
+}
+
+// tokenizerFor returns the tokenizer for model, or nil.
+func (h *TokenCountingHandler) tokenizerFor(model string) textutil.Tokenizer {
+	if tokenizer, ok := longestPrefixMatch(h.modelTokenizers, model); ok {
+		return tokenizer
 	}
+	return h.tokenizer
 }
 
-// StartTrace is a no-op for token counting.
-func (h *TokenCountingHandler) StartTrace(traceID string) {}
+// countTokens returns the number of tokens in texts, counted with tokenizer
+// if there is one and the texts are known. Otherwise it returns the count
+// reported in the payload, if any, or an estimate.
+func countTokens(tokenizer textutil.Tokenizer, reported interface{}, texts []string) int {
+	if tokenizer != nil && len(texts) > 0 {
+		total := 0
+		for _, text := range texts {
+			total += textutil.TokenLen(text, tokenizer)
+		}
+		return total
+	}
+	if tokens, ok := reported.(int); ok {
+		return tokens
+	}
+	return embedding.EstimateTokens(texts...)
+}
 
-// EndTrace is a no-op for token counting.
-func (h *TokenCountingHandler) EndTrace(traceID string, traceMap map[string][]string) {}
+// payloadTexts returns the texts under the first of keys present in
+// payload: a string, a list of strings or a list of chat messages.
+func payloadTexts(payload map[string]interface{}, keys ...EventPayload) []string {
+	for _, key := range keys {
+		switch v := payload[string(key)].(type) {
+		case string:
+			return []string{v}
+		case []string:
+			return v
+		case []llm.ChatMessage:
+			return messagesText(v)
+		}
+	}
+	return nil
+}
 
 // TotalLLMTokens returns the total LLM tokens.
 func (h *TokenCountingHandler) TotalLLMTokens() int {
@@ -244,7 +431,74 @@ func (h *TokenCountingHandler) EmbedEventCount() int {
 	return h.embedEventCount
 }
 
-// Reset resets all counters.
+// TokenCounts returns the token counts of the calls in the order they
+// ended.
+func (h *TokenCountingHandler) TokenCounts() []TokenCount {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]TokenCount(nil), h.counts...)
+}
+
+// LLMTokenCounts returns the token counts of the LLM calls.
+func (h *TokenCountingHandler) LLMTokenCounts() []TokenCount {
+	return h.countsOf(CBEventTypeLLM)
+}
+
+// EmbeddingTokenCounts returns the token counts of the embedding calls.
+func (h *TokenCountingHandler) EmbeddingTokenCounts() []TokenCount {
+	return h.countsOf(CBEventTypeEmbedding)
+}
+
+// countsOf returns the token counts of the calls of eventType.
+func (h *TokenCountingHandler) countsOf(eventType CBEventType) []TokenCount {
+	var counts []TokenCount
+	for _, c := range h.TokenCounts() {
+		if c.EventType == eventType {
+			counts = append(counts, c)
+		}
+	}
+	return counts
+}
+
+// Usage returns the usage of all calls.
+func (h *TokenCountingHandler) Usage() TokenUsage {
+	var usage TokenUsage
+	for _, c := range h.TokenCounts() {
+		usage.add(c)
+	}
+	return usage
+}
+
+// TraceUsage returns the usage of the calls made in traces with traceID.
+func (h *TokenCountingHandler) TraceUsage(traceID string) TokenUsage {
+	var usage TokenUsage
+	for _, c := range h.TokenCounts() {
+		if c.TraceID == traceID {
+			usage.add(c)
+		}
+	}
+	return usage
+}
+
+// UsageByModel returns the usage per model. Calls without a model name are
+// under "".
+func (h *TokenCountingHandler) UsageByModel() map[string]TokenUsage {
+	byModel := make(map[string]TokenUsage)
+	for _, c := range h.TokenCounts() {
+		usage := byModel[c.Model]
+		usage.add(c)
+		byModel[c.Model] = usage
+	}
+	return byModel
+}
+
+// CostReport returns the estimated cost of the usage per model, priced
+// with prices.
+func (h *TokenCountingHandler) CostReport(prices PriceTable) *CostReport {
+	return NewCostReport(h.UsageByModel(), prices)
+}
+
+// Reset resets all counters and recorded calls.
 func (h *TokenCountingHandler) Reset() {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -254,6 +508,8 @@ func (h *TokenCountingHandler) Reset() {
 	h.totalEmbedTokens = 0
 	h.llmEventCount = 0
 	h.embedEventCount = 0
+	h.started = make(map[string]tokenCountStart)
+	h.counts = nil
 }
 
 // Ensure TokenCountingHandler implements CallbackHandler.
@@ -0,0 +1,317 @@
+package callbacks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/textutil"
+)
+
+// InstrumentOption configures an instrumented model.
+type InstrumentOption func(*instrumentConfig)
+
+type instrumentConfig struct {
+	tokenizer textutil.Tokenizer
+}
+
+// WithInstrumentTokenizer sets the tokenizer used to count tokens. By
+// default tokens are estimated at four characters per token.
+func WithInstrumentTokenizer(tokenizer textutil.Tokenizer) InstrumentOption {
+	return func(c *instrumentConfig) {
+		c.tokenizer = tokenizer
+	}
+}
+
+func newInstrumentConfig(opts []InstrumentOption) instrumentConfig {
+	var c instrumentConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// countTokens returns the number of tokens in texts.
+func (c instrumentConfig) countTokens(texts ...string) int {
+	if c.tokenizer == nil {
+		return embedding.EstimateTokens(texts...)
+	}
+	total := 0
+	for _, text := range texts {
+		total += textutil.TokenLen(text, c.tokenizer)
+	}
+	return total
+}
+
+// messagesText returns the text of messages, for token counting.
+func messagesText(messages []llm.ChatMessage) []string {
+	texts := make([]string, len(messages))
+	for i := range messages {
+		texts[i] = messages[i].GetTextContent()
+	}
+	return texts
+}
+
+// InstrumentedLLM wraps an LLM and reports every call as an LLM event to
+// the callback manager in the call's context, with the prompt or messages,
+// the completion and token counts. Tool calling, structured output and
+// chat streaming are passed through when the wrapped LLM supports them.
+type InstrumentedLLM struct {
+	llm    llm.LLM
+	model  string
+	config instrumentConfig
+}
+
+// NewInstrumentedLLM wraps l.
+func NewInstrumentedLLM(l llm.LLM, opts ...InstrumentOption) *InstrumentedLLM {
+	instrumented := &InstrumentedLLM{llm: l, config: newInstrumentConfig(opts)}
+	if withMetadata, ok := l.(llm.LLMWithMetadata); ok {
+		instrumented.model = withMetadata.Metadata().ModelName
+	}
+	return instrumented
+}
+
+// Unwrap returns the wrapped LLM.
+func (l *InstrumentedLLM) Unwrap() llm.LLM {
+	return l.llm
+}
+
+// startPrompt starts an LLM event for a prompt.
+func (l *InstrumentedLLM) startPrompt(ctx context.Context, prompt string) (context.Context, *Span) {
+	return StartSpan(ctx, CBEventTypeLLM, map[string]interface{}{
+		string(EventPayloadPrompt):    prompt,
+		string(EventPayloadModelName): l.model,
+	})
+}
+
+// startChat starts an LLM event for chat messages.
+func (l *InstrumentedLLM) startChat(ctx context.Context, messages []llm.ChatMessage) (context.Context, *Span) {
+	return StartSpan(ctx, CBEventTypeLLM, map[string]interface{}{
+		string(EventPayloadMessages):  messages,
+		string(EventPayloadModelName): l.model,
+	})
+}
+
+// end ends an LLM event with the output under key and token counts.
+func (l *InstrumentedLLM) end(span *Span, key EventPayload, output string, inputs []string, err error) {
+	if span == nil {
+		return
+	}
+	payload := map[string]interface{}{
+		string(EventPayloadPromptTokens): l.config.countTokens(inputs...),
+	}
+	if err == nil {
+		payload[string(key)] = output
+		payload[string(EventPayloadCompletionTokens)] = l.config.countTokens(output)
+	}
+	span.End(payload, err)
+}
+
+// Complete generates a completion.
+func (l *InstrumentedLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	ctx, span := l.startPrompt(ctx, prompt)
+	completion, err := l.llm.Complete(ctx, prompt)
+	l.end(span, EventPayloadCompletion, completion, []string{prompt}, err)
+	return completion, err
+}
+
+// Chat generates a chat response.
+func (l *InstrumentedLLM) Chat(ctx context.Context, messages []llm.ChatMessage) (string, error) {
+	ctx, span := l.startChat(ctx, messages)
+	response, err := l.llm.Chat(ctx, messages)
+	l.end(span, EventPayloadResponse, response, messagesText(messages), err)
+	return response, err
+}
+
+// Stream streams a completion. The event ends when the stream is drained.
+func (l *InstrumentedLLM) Stream(ctx context.Context, prompt string) (<-chan string, error) {
+	ctx, span := l.startPrompt(ctx, prompt)
+	stream, err := l.llm.Stream(ctx, prompt)
+	if err != nil || span == nil {
+		l.end(span, EventPayloadCompletion, "", []string{prompt}, err)
+		return stream, err
+	}
+
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		var sb strings.Builder
+		for token := range stream {
+			sb.WriteString(token)
+			out <- token
+		}
+		l.end(span, EventPayloadCompletion, sb.String(), []string{prompt}, nil)
+	}()
+	return out, nil
+}
+
+// StreamChat streams a chat response. The event ends when the stream is
+// drained.
+func (l *InstrumentedLLM) StreamChat(ctx context.Context, messages []llm.ChatMessage) (<-chan llm.StreamToken, error) {
+	full, ok := l.llm.(llm.FullLLM)
+	if !ok {
+		return nil, fmt.Errorf("wrapped LLM %T does not support chat streaming", l.llm)
+	}
+	ctx, span := l.startChat(ctx, messages)
+	stream, err := full.StreamChat(ctx, messages)
+	if err != nil || span == nil {
+		l.end(span, EventPayloadResponse, "", messagesText(messages), err)
+		return stream, err
+	}
+
+	out := make(chan llm.StreamToken)
+	go func() {
+		defer close(out)
+		var sb strings.Builder
+		for token := range stream {
+			sb.WriteString(token.Delta)
+			out <- token
+		}
+		l.end(span, EventPayloadResponse, sb.String(), messagesText(messages), nil)
+	}()
+	return out, nil
+}
+
+// Metadata returns the wrapped LLM's metadata.
+func (l *InstrumentedLLM) Metadata() llm.LLMMetadata {
+	if withMetadata, ok := l.llm.(llm.LLMWithMetadata); ok {
+		return withMetadata.Metadata()
+	}
+	return llm.DefaultLLMMetadata(l.model)
+}
+
+// ChatWithTools generates a response that may include tool calls.
+func (l *InstrumentedLLM) ChatWithTools(ctx context.Context, messages []llm.ChatMessage, tools []*llm.ToolMetadata, opts *llm.ChatCompletionOptions) (llm.CompletionResponse, error) {
+	withTools, ok := l.llm.(llm.LLMWithToolCalling)
+	if !ok {
+		return llm.CompletionResponse{}, fmt.Errorf("wrapped LLM %T does not support tool calling", l.llm)
+	}
+	ctx, span := l.startChat(ctx, messages)
+	response, err := withTools.ChatWithTools(ctx, messages, tools, opts)
+	l.end(span, EventPayloadResponse, response.Text, messagesText(messages), err)
+	return response, err
+}
+
+// SupportsToolCalling reports whether the wrapped LLM supports tool calling.
+func (l *InstrumentedLLM) SupportsToolCalling() bool {
+	withTools, ok := l.llm.(llm.LLMWithToolCalling)
+	return ok && withTools.SupportsToolCalling()
+}
+
+// ChatWithFormat generates a formatted response.
+func (l *InstrumentedLLM) ChatWithFormat(ctx context.Context, messages []llm.ChatMessage, format *llm.ResponseFormat) (string, error) {
+	structured, ok := l.llm.(llm.LLMWithStructuredOutput)
+	if !ok {
+		return "", fmt.Errorf("wrapped LLM %T does not support structured output", l.llm)
+	}
+	ctx, span := l.startChat(ctx, messages)
+	response, err := structured.ChatWithFormat(ctx, messages, format)
+	l.end(span, EventPayloadResponse, response, messagesText(messages), err)
+	return response, err
+}
+
+// SupportsStructuredOutput reports whether the wrapped LLM supports
+// structured output.
+func (l *InstrumentedLLM) SupportsStructuredOutput() bool {
+	structured, ok := l.llm.(llm.LLMWithStructuredOutput)
+	return ok && structured.SupportsStructuredOutput()
+}
+
+// InstrumentedEmbedding wraps an embedding model and reports every call as
+// an embedding event to the callback manager in the call's context, with
+// the model name, the embedded texts and their token count.
+type InstrumentedEmbedding struct {
+	model  embedding.EmbeddingModel
+	config instrumentConfig
+}
+
+// NewInstrumentedEmbedding wraps model.
+func NewInstrumentedEmbedding(model embedding.EmbeddingModel, opts ...InstrumentOption) *InstrumentedEmbedding {
+	return &InstrumentedEmbedding{model: model, config: newInstrumentConfig(opts)}
+}
+
+// Unwrap returns the wrapped embedding model.
+func (e *InstrumentedEmbedding) Unwrap() embedding.EmbeddingModel {
+	return e.model
+}
+
+// embed runs fn within an embedding event for texts.
+func (e *InstrumentedEmbedding) embed(ctx context.Context, texts []string, fn func(context.Context) ([][]float64, error)) ([][]float64, error) {
+	ctx, span := StartSpan(ctx, CBEventTypeEmbedding, map[string]interface{}{
+		string(EventPayloadChunks):    texts,
+		string(EventPayloadModelName): e.Info().ModelName,
+	})
+	embeddings, err := fn(ctx)
+	if span != nil {
+		span.End(map[string]interface{}{
+			string(EventPayloadChunks):      texts,
+			string(EventPayloadEmbeddings):  embeddings,
+			string(EventPayloadTotalTokens): e.config.countTokens(texts...),
+		}, err)
+	}
+	return embeddings, err
+}
+
+// embedOne runs fn within an embedding event for text.
+func (e *InstrumentedEmbedding) embedOne(ctx context.Context, text string, fn func(context.Context, string) ([]float64, error)) ([]float64, error) {
+	embeddings, err := e.embed(ctx, []string{text}, func(ctx context.Context) ([][]float64, error) {
+		emb, err := fn(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		return [][]float64{emb}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// GetTextEmbedding embeds text.
+func (e *InstrumentedEmbedding) GetTextEmbedding(ctx context.Context, text string) ([]float64, error) {
+	return e.embedOne(ctx, text, e.model.GetTextEmbedding)
+}
+
+// GetQueryEmbedding embeds query.
+func (e *InstrumentedEmbedding) GetQueryEmbedding(ctx context.Context, query string) ([]float64, error) {
+	return e.embedOne(ctx, query, e.model.GetQueryEmbedding)
+}
+
+// GetTextEmbeddingsBatch embeds texts in one event, using the wrapped
+// model's batch support if available.
+func (e *InstrumentedEmbedding) GetTextEmbeddingsBatch(ctx context.Context, texts []string, callback embedding.ProgressCallback) ([][]float64, error) {
+	return e.embed(ctx, texts, func(ctx context.Context) ([][]float64, error) {
+		if batch, ok := e.model.(embedding.EmbeddingModelWithBatch); ok {
+			return batch.GetTextEmbeddingsBatch(ctx, texts, callback)
+		}
+		embeddings := make([][]float64, len(texts))
+		for i, text := range texts {
+			emb, err := e.model.GetTextEmbedding(ctx, text)
+			if err != nil {
+				return nil, err
+			}
+			embeddings[i] = emb
+			if callback != nil {
+				callback(i+1, len(texts))
+			}
+		}
+		return embeddings, nil
+	})
+}
+
+// Info returns the wrapped model's info, or a zero EmbeddingInfo.
+func (e *InstrumentedEmbedding) Info() embedding.EmbeddingInfo {
+	if withInfo, ok := e.model.(embedding.EmbeddingModelWithInfo); ok {
+		return withInfo.Info()
+	}
+	return embedding.EmbeddingInfo{}
+}
+
+// Ensure the instrumented models implement their interfaces.
+var (
+	_ llm.FullLLM                       = (*InstrumentedLLM)(nil)
+	_ embedding.EmbeddingModelWithBatch = (*InstrumentedEmbedding)(nil)
+	_ embedding.EmbeddingModelWithInfo  = (*InstrumentedEmbedding)(nil)
+)
@@ -0,0 +1,282 @@
+// Package otel exports callback events as OpenTelemetry traces, so RAG
+// pipelines and agents show up in tracing backends such as Jaeger, Tempo
+// or Datadog. This is a separate sub-module to avoid pulling OpenTelemetry
+// dependencies unless tracing is explicitly used.
+package otel
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/callbacks"
+	otelapi "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation name of the handler's tracer.
+const TracerName = "github.com/aqua777/go-llamaindex"
+
+// Attribute keys set on spans. Model and token usage follow the
+// OpenTelemetry semantic conventions for generative AI.
+const (
+	AttributeEventType     = attribute.Key("llamaindex.event_type")
+	AttributeModel         = attribute.Key("gen_ai.request.model")
+	AttributeInputTokens   = attribute.Key("gen_ai.usage.input_tokens")
+	AttributeOutputTokens  = attribute.Key("gen_ai.usage.output_tokens")
+	AttributeToolName      = attribute.Key("gen_ai.tool.name")
+	AttributeNodeCount     = attribute.Key("llamaindex.node_count")
+	AttributeChunkCount    = attribute.Key("llamaindex.chunk_count")
+	AttributeTopK          = attribute.Key("llamaindex.top_k")
+	AttributeIteration     = attribute.Key("llamaindex.iteration")
+	AttributeTransform     = attribute.Key("llamaindex.transform")
+	AttributePostprocessor = attribute.Key("llamaindex.postprocessor")
+	AttributeQuery         = attribute.Key("llamaindex.query")
+	AttributePrompt        = attribute.Key("llamaindex.prompt")
+	AttributeCompletion    = attribute.Key("llamaindex.completion")
+	AttributeResponse      = attribute.Key("llamaindex.response")
+	AttributeToolInput     = attribute.Key("llamaindex.tool.input")
+	AttributeToolOutput    = attribute.Key("llamaindex.tool.output")
+)
+
+// Handler is a callback handler that turns events into OpenTelemetry
+// spans. Each event becomes a span named after its event type, nested
+// under the span of its parent event, e.g. query → retrieve → synthesize →
+// llm, or agent_step → function_call. Spans of a trace started with
+// StartTrace are nested under a span for the trace.
+//
+// Model names, token usage, tool names and node counts are recorded as
+// attributes. Queries, prompts, completions and tool inputs and outputs may
+// contain sensitive data and are only recorded with WithCaptureContent.
+type Handler struct {
+	*callbacks.BaseCallbackHandler
+	tracer         trace.Tracer
+	parent         context.Context
+	captureContent bool
+
+	mu    sync.Mutex
+	spans map[string]trace.Span
+	// traces are the spans of the active traces, innermost last.
+	traces []traceSpan
+}
+
+// traceSpan is the span of an active trace.
+type traceSpan struct {
+	id   string
+	span trace.Span
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithTracerProvider sets the tracer provider. Defaults to the global
+// tracer provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(h *Handler) {
+		h.tracer = tp.Tracer(TracerName)
+	}
+}
+
+// WithTracer sets the tracer.
+func WithTracer(tracer trace.Tracer) Option {
+	return func(h *Handler) {
+		h.tracer = tracer
+	}
+}
+
+// WithParentContext sets the context whose span, e.g. that of an incoming
+// request, is the parent of spans without a parent event or trace.
+func WithParentContext(ctx context.Context) Option {
+	return func(h *Handler) {
+		h.parent = ctx
+	}
+}
+
+// WithCaptureContent sets whether queries, prompts, completions and tool
+// inputs and outputs are recorded as span attributes. Defaults to false.
+func WithCaptureContent(capture bool) Option {
+	return func(h *Handler) {
+		h.captureContent = capture
+	}
+}
+
+// WithBaseHandlerOptions sets the options of the embedded base handler,
+// e.g. event types to ignore.
+func WithBaseHandlerOptions(opts ...callbacks.BaseCallbackHandlerOption) Option {
+	return func(h *Handler) {
+		h.BaseCallbackHandler = callbacks.NewBaseCallbackHandler(opts...)
+	}
+}
+
+// NewHandler creates a new Handler.
+func NewHandler(opts ...Option) *Handler {
+	h := &Handler{
+		BaseCallbackHandler: callbacks.NewBaseCallbackHandler(),
+		parent:              context.Background(),
+		spans:               make(map[string]trace.Span),
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	if h.tracer == nil {
+		h.tracer = otelapi.GetTracerProvider().Tracer(TracerName)
+	}
+
+	return h
+}
+
+// OnEventStart starts a span for the event.
+func (h *Handler) OnEventStart(
+	eventType callbacks.CBEventType,
+	payload map[string]interface{},
+	eventID string,
+	parentID string,
+) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ctx := h.parent
+	if parent, ok := h.spans[parentID]; ok {
+		ctx = trace.ContextWithSpan(ctx, parent)
+	} else if n := len(h.traces); n > 0 {
+		ctx = trace.ContextWithSpan(ctx, h.traces[n-1].span)
+	}
+
+	kind := trace.SpanKindInternal
+	if eventType == callbacks.CBEventTypeLLM || eventType == callbacks.CBEventTypeEmbedding {
+		kind = trace.SpanKindClient
+	}
+
+	_, span := h.tracer.Start(ctx, string(eventType),
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(AttributeEventType.String(string(eventType))),
+	)
+	span.SetAttributes(h.attributes(eventType, payload)...)
+	h.spans[eventID] = span
+	return eventID
+}
+
+// OnEventEnd records the event's outputs and errors and ends its span.
+func (h *Handler) OnEventEnd(
+	eventType callbacks.CBEventType,
+	payload map[string]interface{},
+	eventID string,
+) {
+	h.mu.Lock()
+	span, ok := h.spans[eventID]
+	delete(h.spans, eventID)
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(h.attributes(eventType, payload)...)
+	if exception, ok := payload[string(callbacks.EventPayloadException)]; ok && exception != nil {
+		err, ok := exception.(error)
+		if !ok {
+			err = fmt.Errorf("%v", exception)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// StartTrace starts a span for the trace, under which the trace's events
+// are nested.
+func (h *Handler) StartTrace(traceID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ctx := h.parent
+	if n := len(h.traces); n > 0 {
+		ctx = trace.ContextWithSpan(ctx, h.traces[n-1].span)
+	}
+	_, span := h.tracer.Start(ctx, traceID)
+	h.traces = append(h.traces, traceSpan{id: traceID, span: span})
+}
+
+// EndTrace ends the trace's span.
+func (h *Handler) EndTrace(traceID string, traceMap map[string][]string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := len(h.traces) - 1; i >= 0; i-- {
+		if h.traces[i].id == traceID {
+			h.traces[i].span.End()
+			h.traces = append(h.traces[:i], h.traces[i+1:]...)
+			return
+		}
+	}
+}
+
+// attributes returns the span attributes for the payload of an event.
+func (h *Handler) attributes(eventType callbacks.CBEventType, payload map[string]interface{}) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	str := func(key callbacks.EventPayload, attr attribute.Key) {
+		if v, ok := payload[string(key)].(string); ok && v != "" {
+			attrs = append(attrs, attr.String(v))
+		}
+	}
+	count := func(key callbacks.EventPayload, attr attribute.Key) {
+		if v, ok := payload[string(key)]; ok {
+			if n, ok := sliceLen(v); ok {
+				attrs = append(attrs, attr.Int(n))
+			}
+		}
+	}
+	integer := func(key string, attr attribute.Key) {
+		if n, ok := payload[key].(int); ok {
+			attrs = append(attrs, attr.Int(n))
+		}
+	}
+
+	str(callbacks.EventPayloadModelName, AttributeModel)
+	str(callbacks.EventPayloadTool, AttributeToolName)
+	str(callbacks.EventPayloadTransform, AttributeTransform)
+	str("postprocessor", AttributePostprocessor)
+	count(callbacks.EventPayloadNodes, AttributeNodeCount)
+	count(callbacks.EventPayloadChunks, AttributeChunkCount)
+	integer(string(callbacks.EventPayloadTopK), AttributeTopK)
+	integer("iteration", AttributeIteration)
+	integer(string(callbacks.EventPayloadPromptTokens), AttributeInputTokens)
+	// Embeddings only have input tokens, so their total is the input; an
+	// LLM's total also counts the completion.
+	if eventType == callbacks.CBEventTypeEmbedding {
+		integer(string(callbacks.EventPayloadTotalTokens), AttributeInputTokens)
+	}
+	integer(string(callbacks.EventPayloadCompletionTokens), AttributeOutputTokens)
+
+	if h.captureContent {
+		str(callbacks.EventPayloadQueryStr, AttributeQuery)
+		str(callbacks.EventPayloadPrompt, AttributePrompt)
+		str(callbacks.EventPayloadCompletion, AttributeCompletion)
+		str(callbacks.EventPayloadResponse, AttributeResponse)
+		for key, attr := range map[callbacks.EventPayload]attribute.Key{
+			callbacks.EventPayloadFunctionCall:   AttributeToolInput,
+			callbacks.EventPayloadFunctionOutput: AttributeToolOutput,
+		} {
+			if v, ok := payload[string(key)]; ok && v != nil {
+				attrs = append(attrs, attr.String(fmt.Sprint(v)))
+			}
+		}
+	}
+	return attrs
+}
+
+// sliceLen returns the length of v if it is a slice.
+func sliceLen(v interface{}) (int, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return 0, false
+	}
+	return rv.Len(), true
+}
+
+// Ensure Handler implements CallbackHandler.
+var _ callbacks.CallbackHandler = (*Handler)(nil)
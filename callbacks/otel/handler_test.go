@@ -0,0 +1,188 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aqua777/go-llamaindex/callbacks"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestHandler returns a handler recording to a span recorder and a
+// context with a callback manager using it.
+func newTestHandler(opts ...Option) (*Handler, *tracetest.SpanRecorder, context.Context) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	h := NewHandler(append([]Option{WithTracerProvider(tp)}, opts...)...)
+	manager := callbacks.NewCallbackManager(callbacks.WithHandlers([]callbacks.CallbackHandler{h}))
+	return h, recorder, callbacks.ContextWithManager(context.Background(), manager)
+}
+
+// spansByName returns the ended spans by name.
+func spansByName(recorder *tracetest.SpanRecorder) map[string]sdktrace.ReadOnlySpan {
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	return spans
+}
+
+// attrs returns the attributes of span as a map.
+func attrs(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+// TestHandler tests the OpenTelemetry callback handler.
+func TestHandler(t *testing.T) {
+	t.Run("query pipeline", func(t *testing.T) {
+		_, recorder, ctx := newTestHandler()
+
+		queryCtx, query := callbacks.StartSpan(ctx, callbacks.CBEventTypeQuery, map[string]interface{}{
+			string(callbacks.EventPayloadQueryStr): "what is go?",
+		})
+		_, retrieve := callbacks.StartSpan(queryCtx, callbacks.CBEventTypeRetrieve, nil)
+		retrieve.End(map[string]interface{}{
+			string(callbacks.EventPayloadNodes): []schema.NodeWithScore{{}, {}},
+		}, nil)
+		synthCtx, synth := callbacks.StartSpan(queryCtx, callbacks.CBEventTypeSynthesize, nil)
+		_, llmSpan := callbacks.StartSpan(synthCtx, callbacks.CBEventTypeLLM, map[string]interface{}{
+			string(callbacks.EventPayloadPrompt):    "prompt",
+			string(callbacks.EventPayloadModelName): "gpt-4o",
+		})
+		llmSpan.End(map[string]interface{}{
+			string(callbacks.EventPayloadCompletion):       "answer",
+			string(callbacks.EventPayloadPromptTokens):     12,
+			string(callbacks.EventPayloadCompletionTokens): 3,
+			string(callbacks.EventPayloadTotalTokens):      15,
+		}, nil)
+		synth.End(nil, nil)
+		query.End(nil, nil)
+
+		spans := spansByName(recorder)
+		require.Len(t, spans, 4)
+
+		root := spans["query"]
+		assert.False(t, root.Parent().IsValid())
+		assert.Equal(t, root.SpanContext().SpanID(), spans["retrieve"].Parent().SpanID())
+		assert.Equal(t, root.SpanContext().SpanID(), spans["synthesize"].Parent().SpanID())
+		assert.Equal(t, spans["synthesize"].SpanContext().SpanID(), spans["llm"].Parent().SpanID())
+		assert.Equal(t, root.SpanContext().TraceID(), spans["llm"].SpanContext().TraceID())
+
+		assert.Equal(t, int64(2), attrs(spans["retrieve"])[AttributeNodeCount].AsInt64())
+
+		llmAttrs := attrs(spans["llm"])
+		assert.Equal(t, trace.SpanKindClient, spans["llm"].SpanKind())
+		assert.Equal(t, "llm", llmAttrs[AttributeEventType].AsString())
+		assert.Equal(t, "gpt-4o", llmAttrs[AttributeModel].AsString())
+		assert.Equal(t, int64(12), llmAttrs[AttributeInputTokens].AsInt64())
+		assert.Equal(t, int64(3), llmAttrs[AttributeOutputTokens].AsInt64())
+
+		// Content is not captured by default.
+		_, ok := attrs(root)[AttributeQuery]
+		assert.False(t, ok)
+		_, ok = llmAttrs[AttributePrompt]
+		assert.False(t, ok)
+	})
+
+	t.Run("capture content", func(t *testing.T) {
+		_, recorder, ctx := newTestHandler(WithCaptureContent(true))
+
+		stepCtx, step := callbacks.StartSpan(ctx, callbacks.CBEventTypeAgentStep, map[string]interface{}{
+			"iteration": 1,
+		})
+		_, call := callbacks.StartSpan(stepCtx, callbacks.CBEventTypeFunctionCall, map[string]interface{}{
+			string(callbacks.EventPayloadTool):         "search",
+			string(callbacks.EventPayloadFunctionCall): map[string]interface{}{"q": "go"},
+		})
+		call.End(map[string]interface{}{
+			string(callbacks.EventPayloadFunctionOutput): "results",
+		}, nil)
+		step.End(map[string]interface{}{string(callbacks.EventPayloadResponse): "done"}, nil)
+
+		spans := spansByName(recorder)
+		require.Len(t, spans, 2)
+		assert.Equal(t, spans["agent_step"].SpanContext().SpanID(), spans["function_call"].Parent().SpanID())
+
+		stepAttrs := attrs(spans["agent_step"])
+		assert.Equal(t, int64(1), stepAttrs[AttributeIteration].AsInt64())
+		assert.Equal(t, "done", stepAttrs[AttributeResponse].AsString())
+
+		callAttrs := attrs(spans["function_call"])
+		assert.Equal(t, "search", callAttrs[AttributeToolName].AsString())
+		assert.Equal(t, "map[q:go]", callAttrs[AttributeToolInput].AsString())
+		assert.Equal(t, "results", callAttrs[AttributeToolOutput].AsString())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, recorder, ctx := newTestHandler()
+
+		_, span := callbacks.StartSpan(ctx, callbacks.CBEventTypeRetrieve, nil)
+		span.End(nil, errors.New("boom"))
+
+		ended := recorder.Ended()
+		require.Len(t, ended, 1)
+		assert.Equal(t, codes.Error, ended[0].Status().Code)
+		assert.Equal(t, "boom", ended[0].Status().Description)
+		require.Len(t, ended[0].Events(), 1)
+		assert.Equal(t, "exception", ended[0].Events()[0].Name)
+	})
+
+	t.Run("traces", func(t *testing.T) {
+		h, recorder, ctx := newTestHandler()
+		manager := callbacks.ManagerFromContext(ctx)
+
+		err := manager.WithTrace("index_construction", func() error {
+			_, span := callbacks.StartSpan(ctx, callbacks.CBEventTypeEmbedding, map[string]interface{}{
+				string(callbacks.EventPayloadChunks): []string{"a", "b", "c"},
+			})
+			span.End(map[string]interface{}{string(callbacks.EventPayloadTotalTokens): 3}, nil)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Empty(t, h.traces)
+
+		spans := spansByName(recorder)
+		require.Len(t, spans, 2)
+		assert.Equal(t, spans["index_construction"].SpanContext().SpanID(), spans["embedding"].Parent().SpanID())
+
+		embeddingAttrs := attrs(spans["embedding"])
+		assert.Equal(t, int64(3), embeddingAttrs[AttributeChunkCount].AsInt64())
+		assert.Equal(t, int64(3), embeddingAttrs[AttributeInputTokens].AsInt64())
+	})
+
+	t.Run("parent context", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		parentCtx, parent := tp.Tracer("test").Start(context.Background(), "request")
+
+		h := NewHandler(WithTracerProvider(tp), WithParentContext(parentCtx))
+		h.OnEventStart(callbacks.CBEventTypeQuery, nil, "q", callbacks.BaseTraceEvent)
+		h.OnEventEnd(callbacks.CBEventTypeQuery, nil, "q")
+		parent.End()
+
+		spans := spansByName(recorder)
+		assert.Equal(t, parent.SpanContext().SpanID(), spans["query"].Parent().SpanID())
+	})
+
+	t.Run("ignored events", func(t *testing.T) {
+		_, recorder, ctx := newTestHandler(WithBaseHandlerOptions(
+			callbacks.WithEventStartsToIgnore([]callbacks.CBEventType{callbacks.CBEventTypeEmbedding}),
+		))
+
+		_, span := callbacks.StartSpan(ctx, callbacks.CBEventTypeEmbedding, nil)
+		span.End(nil, nil)
+		assert.Empty(t, recorder.Ended())
+	})
+}
@@ -45,6 +45,8 @@ const (
 	CBEventTypeException CBEventType = "exception"
 	// CBEventTypeAgentStep logs for agent steps.
 	CBEventTypeAgentStep CBEventType = "agent_step"
+	// CBEventTypeTransform logs for ingestion transformations.
+	CBEventTypeTransform CBEventType = "transform"
 )
 
 // LeafEvents are events that will never have children events.
@@ -112,6 +114,17 @@ const (
 	EventPayloadQueryWrapperPrompt EventPayload = "query_wrapper_prompt"
 	// EventPayloadException is the exception raised in an event.
 	EventPayloadException EventPayload = "exception"
+	// EventPayloadDuration is the duration of an event, set by Span.End.
+	EventPayloadDuration EventPayload = "duration"
+	// EventPayloadPromptTokens is the number of prompt tokens of an LLM call.
+	EventPayloadPromptTokens EventPayload = "prompt_tokens"
+	// EventPayloadCompletionTokens is the number of completion tokens of an
+	// LLM call.
+	EventPayloadCompletionTokens EventPayload = "completion_tokens"
+	// EventPayloadTotalTokens is the number of tokens of an embedding call.
+	EventPayloadTotalTokens EventPayload = "total_tokens"
+	// EventPayloadTransform is the name of an ingestion transformation.
+	EventPayloadTransform EventPayload = "transform"
 )
 
 // CBEvent is a generic class to store event information.
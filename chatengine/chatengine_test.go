@@ -6,6 +6,7 @@ import (
 
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/memory"
+	"github.com/aqua777/go-llamaindex/rag/retriever"
 	"github.com/aqua777/go-llamaindex/schema"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -532,3 +533,113 @@ func TestCustomMemory(t *testing.T) {
 		assert.NotEmpty(t, resp.Response)
 	})
 }
+
+func TestEntityMemory(t *testing.T) {
+	t.Run("extracts entities", func(t *testing.T) {
+		assert.Equal(t, []string{"Acme Cloud", "Kubernetes"},
+			ExtractCapitalizedEntities("Tell me about Acme Cloud. It runs on Kubernetes."))
+		assert.Equal(t, []string{"gpt-4o"}, ExtractCapitalizedEntities("how does gpt-4o compare?"))
+		assert.Empty(t, ExtractCapitalizedEntities("What about its pricing?"))
+	})
+
+	t.Run("detects follow-ups", func(t *testing.T) {
+		assert.True(t, IsFollowUp("what about its pricing?"))
+		assert.True(t, IsFollowUp("How much does it cost?"))
+		assert.True(t, IsFollowUp("And the SLA?"))
+		assert.False(t, IsFollowUp("How much does Acme Cloud cost?"))
+	})
+
+	t.Run("resolves follow-ups", func(t *testing.T) {
+		m := NewEntityMemory()
+		m.ObserveTurn("Tell me about Acme Cloud", "Acme Cloud is a hosting platform that runs on Kubernetes.")
+		assert.Equal(t, []string{"Acme Cloud", "Kubernetes"}, m.Entities())
+
+		assert.Equal(t, "what about its pricing? Acme Cloud", m.ResolveQuery("what about its pricing?"))
+		assert.Equal(t, "what about Globex?", m.ResolveQuery("what about Globex?"))
+		assert.Equal(t, "How is storage billed?", m.ResolveQuery("How is storage billed?"))
+
+		m.Reset()
+		assert.Equal(t, "what about its pricing?", m.ResolveQuery("what about its pricing?"))
+	})
+
+	t.Run("limits entities", func(t *testing.T) {
+		m := NewEntityMemory(WithMaxEntities(2), WithEntityCarryOver(2))
+		m.Observe("Alpha and Beta")
+		m.Observe("Gamma")
+		assert.Equal(t, []string{"Gamma", "Alpha"}, m.Entities())
+		assert.Equal(t, "and their prices? Gamma Alpha", m.ResolveQuery("and their prices?"))
+	})
+}
+
+func TestFollowUpRetrieval(t *testing.T) {
+	ctx := context.Background()
+	newNode := func(id, text string) schema.Node {
+		n := schema.NewTextNode(text)
+		n.ID = id
+		return *n
+	}
+	corpus := []schema.Node{
+		newNode("acme-overview", "Acme Cloud is a managed hosting platform."),
+		newNode("acme-pricing", "Acme Cloud pricing starts at $10 per month for the basic tier."),
+		newNode("globex-pricing", "Globex pricing: $5."),
+	}
+	topID := func(resp *ChatResponse) string {
+		require.NotEmpty(t, resp.SourceNodes)
+		return resp.SourceNodes[0].Node.ID
+	}
+
+	chat := func(engine ChatEngine) *ChatResponse {
+		_, err := engine.Chat(ctx, "Tell me about Acme Cloud")
+		require.NoError(t, err)
+		resp, err := engine.Chat(ctx, "what about its pricing?")
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("context engine without entity memory", func(t *testing.T) {
+		engine := NewContextChatEngine(
+			WithContextChatEngineLLM(NewMockLLM("Acme Cloud hosts apps.")),
+			WithContextChatEngineRetriever(retriever.NewBM25Retriever(corpus)),
+		)
+		assert.Equal(t, "globex-pricing", topID(chat(engine)))
+	})
+
+	t.Run("context engine with entity memory", func(t *testing.T) {
+		engine := NewContextChatEngine(
+			WithContextChatEngineLLM(NewMockLLM("Acme Cloud hosts apps.")),
+			WithContextChatEngineRetriever(retriever.NewBM25Retriever(corpus)),
+			WithContextChatEngineEntityMemory(NewEntityMemory()),
+		)
+		resp := chat(engine)
+		assert.Equal(t, "acme-pricing", topID(resp))
+		assert.Equal(t, "what about its pricing? Acme Cloud", resp.Metadata[RetrievalQueryMetadataKey])
+	})
+
+	t.Run("condense engine keeps the pronoun", func(t *testing.T) {
+		// The condense step returns a question that still says "its".
+		newEngine := func(opts ...CondensePlusContextChatEngineOption) ChatEngine {
+			return NewCondensePlusContextChatEngine(append([]CondensePlusContextChatEngineOption{
+				WithCondensePlusContextLLM(NewMockLLM("What is its pricing?")),
+				WithCondensePlusContextRetriever(retriever.NewBM25Retriever(corpus)),
+			}, opts...)...)
+		}
+		assert.Equal(t, "globex-pricing", topID(chat(newEngine())))
+
+		resp := chat(newEngine(WithCondensePlusContextEntityMemory(NewEntityMemory())))
+		assert.Equal(t, "acme-pricing", topID(resp))
+		assert.Equal(t, "What is its pricing? Acme Cloud", resp.Metadata[RetrievalQueryMetadataKey])
+	})
+
+	t.Run("explicit history seeds entities", func(t *testing.T) {
+		engine := NewContextChatEngine(
+			WithContextChatEngineLLM(NewMockLLM("ok")),
+			WithContextChatEngineRetriever(retriever.NewBM25Retriever(corpus)),
+			WithContextChatEngineEntityMemory(NewEntityMemory()),
+		)
+		resp, err := engine.ChatWithHistory(ctx, "what about its pricing?", []llm.ChatMessage{
+			{Role: llm.MessageRoleUser, Content: "Tell me about Acme Cloud"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "acme-pricing", topID(resp))
+	})
+}
@@ -43,6 +43,7 @@ type CondensePlusContextChatEngine struct {
 	contextPromptTemplate  string
 	skipCondense           bool
 	verbose                bool
+	entityMemory           *EntityMemory
 }
 
 // CondensePlusContextChatEngineOption configures a CondensePlusContextChatEngine.
@@ -106,6 +107,15 @@ func WithCondensePlusContextVerbose(verbose bool) CondensePlusContextChatEngineO
 	}
 }
 
+// WithCondensePlusContextEntityMemory carries entities from earlier turns
+// into the retrieval query when the condensed question is still a
+// follow-up, e.g. when condensing is skipped or leaves a pronoun.
+func WithCondensePlusContextEntityMemory(m *EntityMemory) CondensePlusContextChatEngineOption {
+	return func(e *CondensePlusContextChatEngine) {
+		e.entityMemory = m
+	}
+}
+
 // NewCondensePlusContextChatEngine creates a new CondensePlusContextChatEngine.
 func NewCondensePlusContextChatEngine(opts ...CondensePlusContextChatEngineOption) *CondensePlusContextChatEngine {
 	e := &CondensePlusContextChatEngine{
@@ -176,6 +186,9 @@ func (e *CondensePlusContextChatEngine) ChatWithHistory(ctx context.Context, mes
 		if err := e.memory.Set(ctx, chatHistory); err != nil {
 			return nil, err
 		}
+		if e.entityMemory != nil {
+			e.entityMemory.ObserveHistory(chatHistory)
+		}
 	}
 
 	// Get current chat history
@@ -189,6 +202,9 @@ func (e *CondensePlusContextChatEngine) ChatWithHistory(ctx context.Context, mes
 	if err != nil {
 		return nil, err
 	}
+	if e.entityMemory != nil {
+		condensedQuestion = e.entityMemory.ResolveQuery(condensedQuestion)
+	}
 
 	if e.verbose {
 		fmt.Printf("Condensed question: %s\n", condensedQuestion)
@@ -221,6 +237,9 @@ func (e *CondensePlusContextChatEngine) ChatWithHistory(ctx context.Context, mes
 	if err := e.memory.Put(ctx, assistantMessage); err != nil {
 		return nil, err
 	}
+	if e.entityMemory != nil {
+		e.entityMemory.ObserveTurn(message, response)
+	}
 
 	// Build response
 	chatResponse := NewChatResponse(response)
@@ -233,6 +252,7 @@ func (e *CondensePlusContextChatEngine) ChatWithHistory(ctx context.Context, mes
 			RawOutput: nodes,
 		},
 	}
+	chatResponse.Metadata[RetrievalQueryMetadataKey] = condensedQuestion
 
 	return chatResponse, nil
 }
@@ -257,6 +277,9 @@ func (e *CondensePlusContextChatEngine) StreamChat(ctx context.Context, message
 	if err != nil {
 		return nil, err
 	}
+	if e.entityMemory != nil {
+		condensedQuestion = e.entityMemory.ResolveQuery(condensedQuestion)
+	}
 
 	if e.verbose {
 		fmt.Printf("Condensed question: %s\n", condensedQuestion)
@@ -294,6 +317,9 @@ func (e *CondensePlusContextChatEngine) StreamChat(ctx context.Context, message
 		assistantMessage := llm.ChatMessage{Role: llm.MessageRoleAssistant, Content: fullResponse}
 		_ = e.memory.Put(ctx, userMessage)
 		_ = e.memory.Put(ctx, assistantMessage)
+		if e.entityMemory != nil {
+			e.entityMemory.ObserveTurn(message, fullResponse)
+		}
 	}()
 
 	streamResponse := NewStreamingChatResponse(outputChan)
@@ -312,6 +338,9 @@ func (e *CondensePlusContextChatEngine) StreamChat(ctx context.Context, message
 
 // Reset clears the conversation state.
 func (e *CondensePlusContextChatEngine) Reset(ctx context.Context) error {
+	if e.entityMemory != nil {
+		e.entityMemory.Reset()
+	}
 	return e.memory.Reset(ctx)
 }
 
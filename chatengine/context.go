@@ -26,6 +26,7 @@ type ContextChatEngine struct {
 	memory          memory.Memory
 	retriever       retriever.Retriever
 	contextTemplate string
+	entityMemory    *EntityMemory
 }
 
 // ContextChatEngineOption configures a ContextChatEngine.
@@ -75,6 +76,14 @@ func WithContextTemplate(template string) ContextChatEngineOption {
 	}
 }
 
+// WithContextChatEngineEntityMemory carries entities from earlier turns
+// into the retrieval query of follow-up questions.
+func WithContextChatEngineEntityMemory(m *EntityMemory) ContextChatEngineOption {
+	return func(e *ContextChatEngine) {
+		e.entityMemory = m
+	}
+}
+
 // NewContextChatEngine creates a new ContextChatEngine.
 func NewContextChatEngine(opts ...ContextChatEngineOption) *ContextChatEngine {
 	e := &ContextChatEngine{
@@ -142,10 +151,14 @@ func (e *ContextChatEngine) ChatWithHistory(ctx context.Context, message string,
 		if err := e.memory.Set(ctx, chatHistory); err != nil {
 			return nil, err
 		}
+		if e.entityMemory != nil {
+			e.entityMemory.ObserveHistory(chatHistory)
+		}
 	}
 
 	// Retrieve context nodes
-	nodes, err := e.retriever.Retrieve(ctx, schema.QueryBundle{QueryString: message})
+	query := e.retrievalQuery(message)
+	nodes, err := e.retriever.Retrieve(ctx, schema.QueryBundle{QueryString: query})
 	if err != nil {
 		return nil, err
 	}
@@ -171,6 +184,9 @@ func (e *ContextChatEngine) ChatWithHistory(ctx context.Context, message string,
 	if err := e.memory.Put(ctx, assistantMessage); err != nil {
 		return nil, err
 	}
+	if e.entityMemory != nil {
+		e.entityMemory.ObserveTurn(message, response)
+	}
 
 	// Build response
 	chatResponse := NewChatResponse(response)
@@ -179,10 +195,11 @@ func (e *ContextChatEngine) ChatWithHistory(ctx context.Context, message string,
 		{
 			ToolName:  "retriever",
 			Content:   contextStr,
-			RawInput:  map[string]interface{}{"message": message},
+			RawInput:  map[string]interface{}{"message": query},
 			RawOutput: nodes,
 		},
 	}
+	chatResponse.Metadata[RetrievalQueryMetadataKey] = query
 
 	return chatResponse, nil
 }
@@ -197,7 +214,8 @@ func (e *ContextChatEngine) StreamChat(ctx context.Context, message string) (*St
 	}
 
 	// Retrieve context nodes
-	nodes, err := e.retriever.Retrieve(ctx, schema.QueryBundle{QueryString: message})
+	query := e.retrievalQuery(message)
+	nodes, err := e.retriever.Retrieve(ctx, schema.QueryBundle{QueryString: query})
 	if err != nil {
 		return nil, err
 	}
@@ -228,6 +246,9 @@ func (e *ContextChatEngine) StreamChat(ctx context.Context, message string) (*St
 		assistantMessage := llm.ChatMessage{Role: llm.MessageRoleAssistant, Content: fullResponse}
 		_ = e.memory.Put(ctx, userMessage)
 		_ = e.memory.Put(ctx, assistantMessage)
+		if e.entityMemory != nil {
+			e.entityMemory.ObserveTurn(message, fullResponse)
+		}
 	}()
 
 	streamResponse := NewStreamingChatResponse(outputChan)
@@ -236,7 +257,7 @@ func (e *ContextChatEngine) StreamChat(ctx context.Context, message string) (*St
 		{
 			ToolName:  "retriever",
 			Content:   contextStr,
-			RawInput:  map[string]interface{}{"message": message},
+			RawInput:  map[string]interface{}{"message": query},
 			RawOutput: nodes,
 		},
 	}
@@ -246,6 +267,9 @@ func (e *ContextChatEngine) StreamChat(ctx context.Context, message string) (*St
 
 // Reset clears the conversation state.
 func (e *ContextChatEngine) Reset(ctx context.Context) error {
+	if e.entityMemory != nil {
+		e.entityMemory.Reset()
+	}
 	return e.memory.Reset(ctx)
 }
 
@@ -254,6 +278,15 @@ func (e *ContextChatEngine) ChatHistory(ctx context.Context) ([]llm.ChatMessage,
 	return e.memory.GetAll(ctx)
 }
 
+// retrievalQuery returns the query to retrieve with, expanding follow-ups
+// with remembered entities.
+func (e *ContextChatEngine) retrievalQuery(message string) string {
+	if e.entityMemory == nil {
+		return message
+	}
+	return e.entityMemory.ResolveQuery(message)
+}
+
 // buildContextString builds a context string from nodes.
 func (e *ContextChatEngine) buildContextString(nodes []schema.NodeWithScore) string {
 	var parts []string
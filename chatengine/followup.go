@@ -0,0 +1,272 @@
+package chatengine
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/aqua777/go-llamaindex/llm"
+)
+
+// RetrievalQueryMetadataKey holds the query sent to the retriever on chat
+// responses, after condensing and entity carry-over.
+const RetrievalQueryMetadataKey = "retrieval_query"
+
+// EntityExtractor returns the entities mentioned in text, in order of
+// appearance.
+type EntityExtractor func(text string) []string
+
+// entityTokenPattern matches word-like tokens, keeping internal dots,
+// hyphens and apostrophes (e.g. "Node.js", "GPT-4", "O'Reilly").
+var entityTokenPattern = regexp.MustCompile(`[\p{L}\p{N}][\p{L}\p{N}.'\-]*[\p{L}\p{N}]|[\p{L}\p{N}]`)
+
+// nonEntityWords are capitalized words that start sentences or questions
+// rather than name things.
+var nonEntityWords = map[string]bool{
+	"i": true, "a": true, "an": true, "the": true, "what": true, "which": true,
+	"who": true, "whom": true, "whose": true, "when": true, "where": true,
+	"why": true, "how": true, "is": true, "are": true, "was": true, "were": true,
+	"do": true, "does": true, "did": true, "can": true, "could": true,
+	"should": true, "would": true, "will": true, "tell": true, "show": true,
+	"give": true, "list": true, "explain": true, "describe": true, "please": true,
+	"and": true, "but": true, "or": true, "also": true, "so": true, "it": true,
+	"its": true, "they": true, "their": true, "this": true, "that": true,
+	"these": true, "those": true, "he": true, "she": true, "his": true,
+	"her": true, "we": true, "you": true, "yes": true, "no": true, "ok": true,
+	"sure": true, "thanks": true, "hi": true, "hello": true, "in": true,
+	"on": true, "for": true, "of": true, "to": true, "with": true, "about": true,
+	"if": true, "here": true, "there": true, "let": true, "me": true,
+}
+
+// ExtractCapitalizedEntities is the default EntityExtractor. It treats runs
+// of capitalized or alphanumeric words ("Acme Cloud", "GPT-4") as entities,
+// skipping question words and pronouns.
+func ExtractCapitalizedEntities(text string) []string {
+	var entities []string
+	var current []string
+	flush := func() {
+		if len(current) > 0 {
+			entities = append(entities, strings.Join(current, " "))
+			current = nil
+		}
+	}
+
+	for _, sentence := range splitSentences(text) {
+		for _, token := range entityTokenPattern.FindAllString(sentence, -1) {
+			if isEntityToken(token) {
+				current = append(current, token)
+				continue
+			}
+			flush()
+		}
+		flush()
+	}
+	return entities
+}
+
+func splitSentences(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return r == '.' || r == '?' || r == '!' || r == '\n' || r == ';' || r == ','
+	})
+}
+
+func isEntityToken(token string) bool {
+	if nonEntityWords[strings.ToLower(token)] {
+		return false
+	}
+	first := []rune(token)[0]
+	if unicode.IsUpper(first) {
+		return true
+	}
+	// Model names and versions such as "gpt-4o" or "v2".
+	hasLetter, hasDigit := false, false
+	for _, r := range token {
+		hasLetter = hasLetter || unicode.IsLetter(r)
+		hasDigit = hasDigit || unicode.IsDigit(r)
+	}
+	return hasLetter && hasDigit
+}
+
+// anaphora are words that refer back to something said earlier.
+var anaphora = map[string]bool{
+	"it": true, "its": true, "it's": true, "they": true, "them": true,
+	"their": true, "theirs": true, "this": true, "that": true, "these": true,
+	"those": true, "he": true, "him": true, "his": true, "she": true,
+	"her": true, "hers": true, "there": true, "one": true, "ones": true,
+	"same": true, "former": true, "latter": true,
+}
+
+// ellipsisPrefixes start follow-ups that omit their subject.
+var ellipsisPrefixes = []string{"what about", "how about", "and ", "also ", "what else", "anything else", "same for", "and what", "compared to"}
+
+// IsFollowUp reports whether message likely depends on earlier turns: it
+// uses a pronoun such as "it" or "their", or is elliptical like "what
+// about pricing?".
+func IsFollowUp(message string) bool {
+	lower := strings.ToLower(strings.TrimSpace(message))
+	for _, prefix := range ellipsisPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	for _, word := range strings.FieldsFunc(lower, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		if anaphora[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// EntityMemory remembers the entities mentioned in a conversation so that
+// follow-up questions can be expanded before retrieval. For "Tell me about
+// Acme Cloud" followed by "what about its pricing?", the retrieval query
+// becomes "what about its pricing? Acme Cloud".
+//
+// It complements the condense step: it needs no LLM call, and it still
+// applies when the condensed question keeps the pronoun.
+type EntityMemory struct {
+	// Extractor finds entities in a message.
+	Extractor EntityExtractor
+	// MaxEntities is the number of recent entities remembered.
+	MaxEntities int
+	// CarryOver is the number of entities added to a follow-up query.
+	CarryOver int
+
+	mu       sync.Mutex
+	entities []string
+}
+
+// EntityMemoryOption is a functional option for EntityMemory.
+type EntityMemoryOption func(*EntityMemory)
+
+// WithEntityExtractor sets the entity extractor, e.g. one backed by an NER
+// model. Defaults to ExtractCapitalizedEntities.
+func WithEntityExtractor(extractor EntityExtractor) EntityMemoryOption {
+	return func(m *EntityMemory) {
+		m.Extractor = extractor
+	}
+}
+
+// WithMaxEntities sets how many recent entities are remembered. Defaults
+// to 10.
+func WithMaxEntities(n int) EntityMemoryOption {
+	return func(m *EntityMemory) {
+		m.MaxEntities = n
+	}
+}
+
+// WithEntityCarryOver sets how many of the most recent entities are added
+// to a follow-up query. Defaults to 1.
+func WithEntityCarryOver(n int) EntityMemoryOption {
+	return func(m *EntityMemory) {
+		m.CarryOver = n
+	}
+}
+
+// NewEntityMemory creates a new EntityMemory.
+func NewEntityMemory(opts ...EntityMemoryOption) *EntityMemory {
+	m := &EntityMemory{
+		Extractor:   ExtractCapitalizedEntities,
+		MaxEntities: 10,
+		CarryOver:   1,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Observe records the entities mentioned in text as the most recent ones.
+func (m *EntityMemory) Observe(text string) {
+	found := m.Extractor(text)
+	if len(found) == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Entities are kept most recent first; within one text, earlier
+	// mentions rank higher as they are usually the topic.
+	for i := len(found) - 1; i >= 0; i-- {
+		m.entities = prependUnique(m.entities, found[i])
+	}
+	if m.MaxEntities > 0 && len(m.entities) > m.MaxEntities {
+		m.entities = m.entities[:m.MaxEntities]
+	}
+}
+
+// Entities returns the remembered entities, most recent first.
+func (m *EntityMemory) Entities() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.entities...)
+}
+
+// Reset forgets all entities.
+func (m *EntityMemory) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entities = nil
+}
+
+// ResolveQuery expands a follow-up message with the most recent entities
+// it does not already mention. Messages that are not follow-ups, or that
+// name an entity of their own ("what about Globex?"), are returned
+// unchanged.
+func (m *EntityMemory) ResolveQuery(message string) string {
+	if !IsFollowUp(message) || len(m.Extractor(message)) > 0 {
+		return message
+	}
+
+	lower := strings.ToLower(message)
+	var carried []string
+	for _, entity := range m.Entities() {
+		if len(carried) >= m.CarryOver {
+			break
+		}
+		if strings.Contains(lower, strings.ToLower(entity)) {
+			continue
+		}
+		carried = append(carried, entity)
+	}
+	if len(carried) == 0 {
+		return message
+	}
+	return strings.TrimSpace(message) + " " + strings.Join(carried, " ")
+}
+
+// prependUnique moves entity to the front of entities, matching
+// case-insensitively.
+func prependUnique(entities []string, entity string) []string {
+	out := make([]string, 0, len(entities)+1)
+	out = append(out, entity)
+	for _, e := range entities {
+		if !strings.EqualFold(e, entity) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// ObserveHistory replaces the remembered entities with those in history,
+// observed in order.
+func (m *EntityMemory) ObserveHistory(history []llm.ChatMessage) {
+	m.Reset()
+	for _, msg := range history {
+		m.Observe(msg.Content)
+	}
+}
+
+// ObserveTurn records a completed turn. The user's entities are recorded
+// last so the subject the user asked about ranks above entities the
+// assistant introduced.
+func (m *EntityMemory) ObserveTurn(userMessage, response string) {
+	m.Observe(response)
+	m.Observe(userMessage)
+}
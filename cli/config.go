@@ -33,6 +33,11 @@ const (
 	KeyTopK             = "rag.top-k"
 	KeyVerbose          = "verbose"
 	KeyStream           = "stream"
+	KeyNewModule        = "new.module"
+	KeyNewProvider      = "new.provider"
+	KeyNewModel         = "new.model"
+	KeyNewDir           = "new.dir"
+	KeyNewForce         = "new.force"
 )
 
 // DefaultCacheDir returns the default cache directory.
@@ -24,6 +24,15 @@ func main() {
 		WithBoolP(KeyStream, "Enable streaming output", "stream", "s", "RAG_STREAM", false).
 		WithRun(runRAG)
 
+	// Create new subcommand for project scaffolding
+	newCmd := krait.New("new", "Create a new project", "Scaffold an agent, workflow or RAG project: llamaindex new <agent|workflow|rag> <name>").
+		WithString(KeyNewModule, "Go module path (defaults to the name)", "module", "NEW_MODULE", "").
+		WithStringP(KeyNewProvider, "LLM provider: openai, ollama or mock", "provider", "p", "NEW_PROVIDER", "openai").
+		WithString(KeyNewModel, "LLM model (defaults to the provider's default)", "llm", "NEW_MODEL", "").
+		WithStringP(KeyNewDir, "Parent directory of the project", "dir", "d", "NEW_DIR", ".").
+		WithBool(KeyNewForce, "Overwrite existing files", "force", "NEW_FORCE", false).
+		WithRun(runNew)
+
 	// Create root application with global options
 	app := krait.App("llamaindex", "LlamaIndex CLI tool", "A command-line interface for LlamaIndex operations").
 		WithConfig("", "config", "", "LLAMAINDEX_CONFIG").
@@ -34,9 +43,10 @@ func main() {
 		WithStringP(KeyOllamaEmbedModel, "Ollama model for embeddings", "embed-model", "e", "OLLAMA_EMBED_MODEL", DefaultOllamaEmbedModel).
 		WithBoolP(KeyVerbose, "Enable verbose output", "verbose", "v", "LLAMAINDEX_VERBOSE", false).
 		WithCommand(ragCmd).
+		WithCommand(newCmd).
 		WithRun(func(args []string) error {
 			// Default action: show help
-			fmt.Println("LlamaIndex CLI - Use 'llamaindex rag --help' for RAG commands")
+			fmt.Println("LlamaIndex CLI - Use 'llamaindex rag --help' for RAG commands or 'llamaindex new --help' to create a project")
 			return nil
 		})
 
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/aqua777/go-llamaindex/scaffold"
+	"github.com/aqua777/krait"
+)
+
+func runNew(args []string) error {
+	if len(args) != 2 {
+		fmt.Println("Usage: llamaindex new <agent|workflow|rag> <name> [--provider openai|ollama|mock] [--module <path>] [--dir <dir>] [--force]")
+		fmt.Println("\nExamples:")
+		fmt.Println("  llamaindex new agent my-agent")
+		fmt.Println("  llamaindex new rag docs-bot --provider ollama")
+		fmt.Println("  llamaindex new workflow pipeline --module github.com/me/pipeline")
+		return nil
+	}
+
+	kind, name := scaffold.Template(args[0]), args[1]
+
+	opts := []scaffold.Option{
+		scaffold.WithProvider(krait.GetString(KeyNewProvider)),
+	}
+	if module := krait.GetString(KeyNewModule); module != "" {
+		opts = append(opts, scaffold.WithModule(module))
+	}
+	if model := krait.GetString(KeyNewModel); model != "" {
+		opts = append(opts, scaffold.WithModel(model))
+	}
+
+	dir := krait.GetString(KeyNewDir)
+	written, err := scaffold.Generate(kind, name, dir, krait.GetBool(KeyNewForce), opts...)
+	if err != nil {
+		return err
+	}
+
+	projectDir := filepath.Join(dir, name)
+	fmt.Printf("Created %s project in %s:\n", kind, projectDir)
+	for _, file := range written {
+		fmt.Printf("  %s\n", file)
+	}
+	fmt.Println("\nNext steps:")
+	fmt.Printf("  cd %s\n", projectDir)
+	fmt.Println("  go mod tidy")
+	fmt.Println("  go test ./...")
+	fmt.Println("  go run . -q \"Hello\"")
+	return nil
+}
@@ -0,0 +1,209 @@
+// Package credentials resolves provider secrets such as API keys at request
+// time, so keys can come from a secret manager and rotate without
+// restarting the process.
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned when a provider has no secret with the given name.
+var ErrNotFound = errors.New("credential not found")
+
+// Provider resolves secrets by name. Names are provider-neutral identifiers;
+// clients use the conventional environment variable names, e.g.
+// "OPENAI_API_KEY", so EnvProvider works without configuration.
+type Provider interface {
+	// Get returns the current value of the named secret.
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// Invalidator is implemented by providers that cache secrets. Invalidate
+// drops the cached value so the next Get fetches a fresh one, e.g. after
+// the backend rejected a rotated key.
+type Invalidator interface {
+	Invalidate(name string)
+}
+
+// ProviderFunc adapts a function to the Provider interface.
+type ProviderFunc func(ctx context.Context, name string) (string, error)
+
+// Get calls f(ctx, name).
+func (f ProviderFunc) Get(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// Resolve returns the named secret from p, or fallback when p is nil.
+// Clients use it to prefer a configured provider over a static key.
+func Resolve(ctx context.Context, p Provider, name, fallback string) (string, error) {
+	if p == nil {
+		return fallback, nil
+	}
+	value, err := p.Get(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve credential %s: %w", name, err)
+	}
+	return value, nil
+}
+
+// EnvProvider reads secrets from environment variables on every call.
+type EnvProvider struct {
+	// Prefix is prepended to names before lookup.
+	Prefix string
+}
+
+// NewEnvProvider creates an EnvProvider.
+func NewEnvProvider() *EnvProvider {
+	return &EnvProvider{}
+}
+
+// Get implements Provider.
+func (p *EnvProvider) Get(ctx context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(p.Prefix + name)
+	if !ok || value == "" {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrNotFound, p.Prefix+name)
+	}
+	return value, nil
+}
+
+// StaticProvider serves secrets from a map. Set replaces a value, which
+// makes it useful for tests and for rotation driven by application code.
+type StaticProvider struct {
+	mu      sync.RWMutex
+	secrets map[string]string
+}
+
+// NewStaticProvider creates a StaticProvider with the given secrets.
+func NewStaticProvider(secrets map[string]string) *StaticProvider {
+	p := &StaticProvider{secrets: make(map[string]string, len(secrets))}
+	for k, v := range secrets {
+		p.secrets[k] = v
+	}
+	return p
+}
+
+// Get implements Provider.
+func (p *StaticProvider) Get(ctx context.Context, name string) (string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	value, ok := p.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return value, nil
+}
+
+// Set sets or rotates a secret.
+func (p *StaticProvider) Set(name, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.secrets[name] = value
+}
+
+// ChainProvider tries providers in order and returns the first secret
+// found. Errors other than ErrNotFound stop the chain.
+type ChainProvider struct {
+	providers []Provider
+}
+
+// NewChainProvider creates a ChainProvider.
+func NewChainProvider(providers ...Provider) *ChainProvider {
+	return &ChainProvider{providers: providers}
+}
+
+// Get implements Provider.
+func (p *ChainProvider) Get(ctx context.Context, name string) (string, error) {
+	for _, provider := range p.providers {
+		value, err := provider.Get(ctx, name)
+		if err == nil {
+			return value, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// Invalidate implements Invalidator by invalidating every provider that
+// supports it.
+func (p *ChainProvider) Invalidate(name string) {
+	for _, provider := range p.providers {
+		if inv, ok := provider.(Invalidator); ok {
+			inv.Invalidate(name)
+		}
+	}
+}
+
+type cachedSecret struct {
+	value     string
+	fetchedAt time.Time
+}
+
+// CachingProvider caches secrets from a slower provider, such as a secret
+// manager, and refreshes them after a TTL so rotated keys are picked up.
+type CachingProvider struct {
+	provider Provider
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedSecret
+}
+
+// NewCachingProvider wraps provider with a cache. A zero TTL caches until
+// Invalidate is called.
+func NewCachingProvider(provider Provider, ttl time.Duration) *CachingProvider {
+	return &CachingProvider{
+		provider: provider,
+		ttl:      ttl,
+		cache:    make(map[string]cachedSecret),
+	}
+}
+
+// Get implements Provider. If a refresh fails, the previous value is
+// served so a secret manager outage does not break requests.
+func (p *CachingProvider) Get(ctx context.Context, name string) (string, error) {
+	p.mu.Lock()
+	cached, ok := p.cache[name]
+	p.mu.Unlock()
+
+	if ok && (p.ttl == 0 || time.Since(cached.fetchedAt) < p.ttl) {
+		return cached.value, nil
+	}
+
+	value, err := p.provider.Get(ctx, name)
+	if err != nil {
+		if ok && !errors.Is(err, ErrNotFound) {
+			return cached.value, nil
+		}
+		return "", err
+	}
+
+	p.mu.Lock()
+	p.cache[name] = cachedSecret{value: value, fetchedAt: time.Now()}
+	p.mu.Unlock()
+	return value, nil
+}
+
+// Invalidate implements Invalidator.
+func (p *CachingProvider) Invalidate(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.cache, name)
+}
+
+// Ensure providers implement the interfaces.
+var (
+	_ Provider    = ProviderFunc(nil)
+	_ Provider    = (*EnvProvider)(nil)
+	_ Provider    = (*StaticProvider)(nil)
+	_ Provider    = (*ChainProvider)(nil)
+	_ Invalidator = (*ChainProvider)(nil)
+	_ Provider    = (*CachingProvider)(nil)
+	_ Invalidator = (*CachingProvider)(nil)
+)
@@ -0,0 +1,240 @@
+package credentials
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvProvider(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("TEST_CRED_KEY", "from-env")
+
+	p := NewEnvProvider()
+	value, err := p.Get(ctx, "TEST_CRED_KEY")
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", value)
+
+	// Values are read on every call, not at construction.
+	t.Setenv("TEST_CRED_KEY", "rotated")
+	value, err = p.Get(ctx, "TEST_CRED_KEY")
+	require.NoError(t, err)
+	assert.Equal(t, "rotated", value)
+
+	_, err = p.Get(ctx, "TEST_CRED_MISSING")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+
+	value, err := Resolve(ctx, nil, "KEY", "fallback")
+	require.NoError(t, err)
+	assert.Equal(t, "fallback", value)
+
+	value, err = Resolve(ctx, NewStaticProvider(map[string]string{"KEY": "secret"}), "KEY", "fallback")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", value)
+
+	_, err = Resolve(ctx, NewStaticProvider(nil), "KEY", "fallback")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestChainProvider(t *testing.T) {
+	ctx := context.Background()
+	first := NewStaticProvider(map[string]string{"A": "a1"})
+	second := NewStaticProvider(map[string]string{"A": "a2", "B": "b2"})
+
+	chain := NewChainProvider(first, second)
+	value, err := chain.Get(ctx, "A")
+	require.NoError(t, err)
+	assert.Equal(t, "a1", value)
+
+	value, err = chain.Get(ctx, "B")
+	require.NoError(t, err)
+	assert.Equal(t, "b2", value)
+
+	_, err = chain.Get(ctx, "C")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	failing := ProviderFunc(func(ctx context.Context, name string) (string, error) {
+		return "", errors.New("backend down")
+	})
+	_, err = NewChainProvider(failing, second).Get(ctx, "B")
+	assert.ErrorContains(t, err, "backend down")
+}
+
+func TestCachingProvider(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	var backendErr error
+	backend := ProviderFunc(func(ctx context.Context, name string) (string, error) {
+		calls++
+		if backendErr != nil {
+			return "", backendErr
+		}
+		return "v" + string(rune('0'+calls)), nil
+	})
+
+	p := NewCachingProvider(backend, 0)
+	v1, _ := p.Get(ctx, "KEY")
+	v2, _ := p.Get(ctx, "KEY")
+	assert.Equal(t, "v1", v1)
+	assert.Equal(t, "v1", v2)
+	assert.Equal(t, 1, calls)
+
+	p.Invalidate("KEY")
+	v3, _ := p.Get(ctx, "KEY")
+	assert.Equal(t, "v2", v3)
+
+	// After the TTL, values are refreshed; a failing refresh serves the
+	// previous value.
+	ttl := NewCachingProvider(backend, time.Millisecond)
+	_, err := ttl.Get(ctx, "KEY")
+	require.NoError(t, err)
+	time.Sleep(2 * time.Millisecond)
+	backendErr = errors.New("secret manager unavailable")
+	stale, err := ttl.Get(ctx, "KEY")
+	require.NoError(t, err)
+	assert.Equal(t, "v3", stale)
+}
+
+func TestTransportRefreshesRejectedKey(t *testing.T) {
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer new-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body := make([]byte, 5)
+		n, _ := r.Body.Read(body)
+		w.Write(body[:n])
+	}))
+	defer server.Close()
+
+	backend := NewStaticProvider(map[string]string{"API_KEY": "old-key"})
+	cached := NewCachingProvider(backend, 0)
+	client := NewHTTPClient(nil, cached, "API_KEY", BearerAuth)
+
+	// Warm the cache with the old key, then rotate it in the backend.
+	_, err := cached.Get(context.Background(), "API_KEY")
+	require.NoError(t, err)
+	backend.Set("API_KEY", "new-key")
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("hello"))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"Bearer old-key", "Bearer new-key"}, seen)
+	assert.Empty(t, req.Header.Get("Authorization"), "caller's request must not be modified")
+}
+
+func TestTransportHeaderAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("api-key"))
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(nil, NewStaticProvider(map[string]string{"K": "secret"}), "K", HeaderAuth("api-key"))
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+}
+
+type fakeSecretsManager map[string]string
+
+func (f fakeSecretsManager) GetSecretValue(ctx context.Context, secretID string) (string, error) {
+	value, ok := f[secretID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func TestAWSSecretsManagerProvider(t *testing.T) {
+	ctx := context.Background()
+	p := NewAWSSecretsManagerProvider(fakeSecretsManager{
+		"prod/openai": "sk-plain",
+		"prod/llm":    `{"anthropic": "sk-ant", "cohere": "co-key"}`,
+	})
+	p.Mapping["OPENAI_API_KEY"] = "prod/openai"
+	p.Mapping["ANTHROPIC_API_KEY"] = "prod/llm#anthropic"
+
+	value, err := p.Get(ctx, "OPENAI_API_KEY")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-plain", value)
+
+	value, err = p.Get(ctx, "ANTHROPIC_API_KEY")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-ant", value)
+
+	value, err = p.Get(ctx, "prod/llm#cohere")
+	require.NoError(t, err)
+	assert.Equal(t, "co-key", value)
+
+	_, err = p.Get(ctx, "prod/llm#missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "vault-token", r.Header.Get("X-Vault-Token"))
+		if r.URL.Path != "/v1/secret/data/llm/keys" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data": map[string]interface{}{"OPENAI_API_KEY": "sk-vault"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	token := NewStaticProvider(map[string]string{"VAULT_TOKEN": "vault-token"})
+	p := NewVaultProvider(server.URL, "llm/keys", token)
+
+	value, err := p.Get(context.Background(), "OPENAI_API_KEY")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-vault", value)
+
+	_, err = p.Get(context.Background(), "OTHER")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = NewVaultProvider(server.URL, "missing", token).Get(context.Background(), "OPENAI_API_KEY")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestGCPSecretManagerProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer gcp-token", r.Header.Get("Authorization"))
+		assert.Equal(t, "/v1/projects/my-project/secrets/openai-key/versions/latest:access", r.URL.Path)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"payload": map[string]interface{}{
+				"data": base64.StdEncoding.EncodeToString([]byte("sk-gcp")),
+			},
+		})
+	}))
+	defer server.Close()
+
+	token := NewStaticProvider(map[string]string{"GCP_ACCESS_TOKEN": "gcp-token"})
+	p := NewGCPSecretManagerProvider("my-project", token, WithGCPSecretManagerBaseURL(server.URL))
+	p.Mapping["OPENAI_API_KEY"] = "openai-key"
+
+	value, err := p.Get(context.Background(), "OPENAI_API_KEY")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-gcp", value)
+}
@@ -0,0 +1,310 @@
+package credentials
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// splitField splits "secret#field" into the secret ID and an optional
+// JSON field name.
+func splitField(name string) (string, string) {
+	if i := strings.LastIndex(name, "#"); i != -1 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
+
+// jsonField extracts a string field from a JSON object secret.
+func jsonField(secret, field string) (string, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &obj); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %w", err)
+	}
+	value, ok := obj[field]
+	if !ok {
+		return "", fmt.Errorf("%w: field %s", ErrNotFound, field)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprintf("%v", value), nil
+}
+
+// AWSSecretsManagerAPI is the subset of the AWS Secrets Manager client used
+// by AWSSecretsManagerProvider. Adapt the AWS SDK client with a small
+// wrapper returning SecretString.
+type AWSSecretsManagerAPI interface {
+	GetSecretValue(ctx context.Context, secretID string) (string, error)
+}
+
+// AWSSecretsManagerProvider reads secrets from AWS Secrets Manager.
+// Names may be "secret-id" for plain-text secrets or "secret-id#field" for
+// JSON secrets. Names can be mapped to secret IDs with Mapping.
+type AWSSecretsManagerProvider struct {
+	client AWSSecretsManagerAPI
+	// Mapping maps secret names, e.g. "OPENAI_API_KEY", to secret IDs.
+	Mapping map[string]string
+}
+
+// NewAWSSecretsManagerProvider creates an AWSSecretsManagerProvider.
+func NewAWSSecretsManagerProvider(client AWSSecretsManagerAPI) *AWSSecretsManagerProvider {
+	return &AWSSecretsManagerProvider{
+		client:  client,
+		Mapping: make(map[string]string),
+	}
+}
+
+// Get implements Provider.
+func (p *AWSSecretsManagerProvider) Get(ctx context.Context, name string) (string, error) {
+	if mapped, ok := p.Mapping[name]; ok {
+		name = mapped
+	}
+	secretID, field := splitField(name)
+
+	secret, err := p.client.GetSecretValue(ctx, secretID)
+	if err != nil {
+		return "", fmt.Errorf("aws secrets manager: %w", err)
+	}
+	if field == "" {
+		return secret, nil
+	}
+	return jsonField(secret, field)
+}
+
+// VaultProvider reads secrets from a HashiCorp Vault KV secrets engine.
+// Each name is a key in the secret at Path.
+type VaultProvider struct {
+	address    string
+	token      Provider
+	mount      string
+	path       string
+	kvVersion  int
+	namespace  string
+	httpClient *http.Client
+}
+
+// VaultOption configures a VaultProvider.
+type VaultOption func(*VaultProvider)
+
+// WithVaultMount sets the KV mount. Defaults to "secret".
+func WithVaultMount(mount string) VaultOption {
+	return func(p *VaultProvider) {
+		p.mount = mount
+	}
+}
+
+// WithVaultKVVersion sets the KV engine version (1 or 2). Defaults to 2.
+func WithVaultKVVersion(version int) VaultOption {
+	return func(p *VaultProvider) {
+		p.kvVersion = version
+	}
+}
+
+// WithVaultNamespace sets the Vault Enterprise namespace.
+func WithVaultNamespace(namespace string) VaultOption {
+	return func(p *VaultProvider) {
+		p.namespace = namespace
+	}
+}
+
+// WithVaultHTTPClient sets the HTTP client.
+func WithVaultHTTPClient(client *http.Client) VaultOption {
+	return func(p *VaultProvider) {
+		p.httpClient = client
+	}
+}
+
+// NewVaultProvider creates a VaultProvider. The Vault token is itself
+// resolved through a Provider under the name "VAULT_TOKEN", so it can
+// rotate too; use NewEnvProvider() for the usual environment variable.
+func NewVaultProvider(address, path string, token Provider, opts ...VaultOption) *VaultProvider {
+	p := &VaultProvider{
+		address:    strings.TrimRight(address, "/"),
+		token:      token,
+		mount:      "secret",
+		path:       strings.Trim(path, "/"),
+		kvVersion:  2,
+		httpClient: http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// Get implements Provider.
+func (p *VaultProvider) Get(ctx context.Context, name string) (string, error) {
+	token, err := p.token.Get(ctx, "VAULT_TOKEN")
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/%s/%s", p.address, p.mount, p.path)
+	if p.kvVersion == 2 {
+		endpoint = fmt.Sprintf("%s/v1/%s/data/%s", p.address, p.mount, p.path)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	body, err := doSecretRequest(p.httpClient, req)
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("vault: failed to decode response: %w", err)
+	}
+
+	data := resp.Data
+	if p.kvVersion == 2 {
+		nested, _ := data["data"].(map[string]interface{})
+		data = nested
+	}
+	value, ok := data[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s in vault path %s", ErrNotFound, name, p.path)
+	}
+	return fmt.Sprintf("%v", value), nil
+}
+
+// GCPSecretManagerBaseURL is the Secret Manager REST endpoint.
+const GCPSecretManagerBaseURL = "https://secretmanager.googleapis.com"
+
+// GCPSecretManagerProvider reads the latest version of secrets from Google
+// Cloud Secret Manager. Names are secret IDs within the project, optionally
+// with a "#field" suffix for JSON secrets.
+type GCPSecretManagerProvider struct {
+	project    string
+	token      Provider
+	baseURL    string
+	httpClient *http.Client
+	// Mapping maps secret names, e.g. "OPENAI_API_KEY", to secret IDs.
+	Mapping map[string]string
+}
+
+// GCPSecretManagerOption configures a GCPSecretManagerProvider.
+type GCPSecretManagerOption func(*GCPSecretManagerProvider)
+
+// WithGCPSecretManagerBaseURL overrides the API endpoint.
+func WithGCPSecretManagerBaseURL(baseURL string) GCPSecretManagerOption {
+	return func(p *GCPSecretManagerProvider) {
+		p.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithGCPSecretManagerHTTPClient sets the HTTP client.
+func WithGCPSecretManagerHTTPClient(client *http.Client) GCPSecretManagerOption {
+	return func(p *GCPSecretManagerProvider) {
+		p.httpClient = client
+	}
+}
+
+// NewGCPSecretManagerProvider creates a GCPSecretManagerProvider. The OAuth
+// access token is resolved through a Provider under the name
+// "GCP_ACCESS_TOKEN", e.g. a ProviderFunc backed by an oauth2.TokenSource.
+func NewGCPSecretManagerProvider(project string, token Provider, opts ...GCPSecretManagerOption) *GCPSecretManagerProvider {
+	p := &GCPSecretManagerProvider{
+		project:    project,
+		token:      token,
+		baseURL:    GCPSecretManagerBaseURL,
+		httpClient: http.DefaultClient,
+		Mapping:    make(map[string]string),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// Get implements Provider.
+func (p *GCPSecretManagerProvider) Get(ctx context.Context, name string) (string, error) {
+	if mapped, ok := p.Mapping[name]; ok {
+		name = mapped
+	}
+	secretID, field := splitField(name)
+
+	token, err := p.token.Get(ctx, "GCP_ACCESS_TOKEN")
+	if err != nil {
+		return "", fmt.Errorf("gcp secret manager: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/projects/%s/secrets/%s/versions/latest:access",
+		p.baseURL, url.PathEscape(p.project), url.PathEscape(secretID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("gcp secret manager: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	body, err := doSecretRequest(p.httpClient, req)
+	if err != nil {
+		return "", fmt.Errorf("gcp secret manager: %w", err)
+	}
+
+	var resp struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("gcp secret manager: failed to decode response: %w", err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("gcp secret manager: failed to decode payload: %w", err)
+	}
+
+	if field == "" {
+		return string(decoded), nil
+	}
+	return jsonField(string(decoded), field)
+}
+
+// doSecretRequest performs a request and returns the body, mapping 404 to
+// ErrNotFound.
+func doSecretRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, req.URL.Path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request failed (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// Ensure secret manager providers implement Provider.
+var (
+	_ Provider = (*AWSSecretsManagerProvider)(nil)
+	_ Provider = (*VaultProvider)(nil)
+	_ Provider = (*GCPSecretManagerProvider)(nil)
+)
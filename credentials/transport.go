@@ -0,0 +1,98 @@
+package credentials
+
+import (
+	"net/http"
+)
+
+// AuthScheme writes a secret into a request.
+type AuthScheme func(req *http.Request, secret string)
+
+// BearerAuth sets "Authorization: Bearer <secret>".
+func BearerAuth(req *http.Request, secret string) {
+	req.Header.Set("Authorization", "Bearer "+secret)
+}
+
+// HeaderAuth sets the named header to the secret, e.g. "x-api-key" or
+// "api-key".
+func HeaderAuth(header string) AuthScheme {
+	return func(req *http.Request, secret string) {
+		req.Header.Set(header, secret)
+	}
+}
+
+// Transport is an http.RoundTripper that authenticates each request with a
+// secret resolved from a Provider. When the server responds 401 and the
+// provider supports Invalidator, the secret is refreshed and the request
+// retried once, so a rotated key is picked up immediately.
+type Transport struct {
+	// Base is the underlying transport. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+	// Provider resolves the secret.
+	Provider Provider
+	// Name is the secret name, e.g. "OPENAI_API_KEY".
+	Name string
+	// Auth writes the secret into the request. Defaults to BearerAuth.
+	Auth AuthScheme
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.roundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	inv, ok := t.Provider.(Invalidator)
+	if !ok || (req.Body != nil && req.GetBody == nil) {
+		return resp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	inv.Invalidate(t.Name)
+	resp.Body.Close()
+	return t.roundTrip(retry)
+}
+
+func (t *Transport) roundTrip(req *http.Request) (*http.Response, error) {
+	secret, err := t.Provider.Get(req.Context(), t.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// RoundTrippers must not modify the caller's request.
+	authed := req.Clone(req.Context())
+	auth := t.Auth
+	if auth == nil {
+		auth = BearerAuth
+	}
+	auth(authed, secret)
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(authed)
+}
+
+// NewHTTPClient returns an HTTP client that authenticates every request
+// with the named secret. A nil base uses http.DefaultClient's settings.
+func NewHTTPClient(base *http.Client, provider Provider, name string, auth AuthScheme) *http.Client {
+	client := &http.Client{}
+	if base != nil {
+		*client = *base
+	}
+	client.Transport = &Transport{
+		Base:     client.Transport,
+		Provider: provider,
+		Name:     name,
+		Auth:     auth,
+	}
+	return client
+}
@@ -0,0 +1,338 @@
+// Package diagnostics produces health and diagnostics reports for deployed
+// applications: provider reachability and latency, store sizes, cache hit
+// rates, last ingestion runs and a redacted configuration summary.
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/ingestion"
+	"github.com/aqua777/go-llamaindex/rag/store"
+	"github.com/aqua777/go-llamaindex/storage/docstore"
+)
+
+// Status is the overall health of a report or check.
+type Status string
+
+const (
+	// StatusOK means every check passed.
+	StatusOK Status = "ok"
+	// StatusDegraded means at least one check failed.
+	StatusDegraded Status = "degraded"
+)
+
+// RedactedValue replaces secret configuration values in reports.
+const RedactedValue = "[REDACTED]"
+
+// CheckFunc checks that a dependency is reachable. Methods such as
+// store.HealthChecker.HealthCheck and queryengine.Readiness.HealthCheck
+// can be used directly.
+type CheckFunc func(ctx context.Context) error
+
+// SizeFunc returns the number of items held by a store.
+type SizeFunc func(ctx context.Context) (int, error)
+
+// ProviderCheck is the result of a provider check.
+type ProviderCheck struct {
+	Name      string  `json:"name"`
+	Status    Status  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// StoreStats reports the size of a store.
+type StoreStats struct {
+	Name  string `json:"name"`
+	Size  int    `json:"size"`
+	Error string `json:"error,omitempty"`
+}
+
+// CacheReport reports cache usage.
+type CacheReport struct {
+	Name    string  `json:"name"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	Entries int     `json:"entries"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// IngestionReport reports the most recent run of an ingestion pipeline.
+type IngestionReport struct {
+	Name    string                      `json:"name"`
+	LastRun *ingestion.PipelineRunStats `json:"last_run,omitempty"`
+}
+
+// Report is a full diagnostics report.
+type Report struct {
+	Status      Status                 `json:"status"`
+	GeneratedAt time.Time              `json:"generated_at"`
+	Providers   []ProviderCheck        `json:"providers"`
+	Stores      []StoreStats           `json:"stores"`
+	Caches      []CacheReport          `json:"caches"`
+	Ingestion   []IngestionReport      `json:"ingestion"`
+	Config      map[string]interface{} `json:"config,omitempty"`
+}
+
+type namedCheck struct {
+	name  string
+	check CheckFunc
+}
+
+type namedSize struct {
+	name string
+	size SizeFunc
+}
+
+type namedCache struct {
+	name  string
+	stats func() ingestion.CacheStats
+}
+
+type namedPipeline struct {
+	name     string
+	pipeline *ingestion.IngestionPipeline
+}
+
+// Diagnostics collects the components to report on.
+type Diagnostics struct {
+	mu        sync.RWMutex
+	providers []namedCheck
+	stores    []namedSize
+	caches    []namedCache
+	pipelines []namedPipeline
+	config    map[string]interface{}
+
+	checkTimeout time.Duration
+	secretKeys   []string
+}
+
+// DiagnosticsOption configures Diagnostics.
+type DiagnosticsOption func(*Diagnostics)
+
+// WithCheckTimeout bounds each provider check. Defaults to 5 seconds.
+func WithCheckTimeout(timeout time.Duration) DiagnosticsOption {
+	return func(d *Diagnostics) {
+		d.checkTimeout = timeout
+	}
+}
+
+// WithSecretKeys sets the substrings that mark a configuration key as
+// secret. Matching is case-insensitive.
+func WithSecretKeys(keys ...string) DiagnosticsOption {
+	return func(d *Diagnostics) {
+		d.secretKeys = keys
+	}
+}
+
+// NewDiagnostics creates an empty Diagnostics.
+func NewDiagnostics(opts ...DiagnosticsOption) *Diagnostics {
+	d := &Diagnostics{
+		checkTimeout: 5 * time.Second,
+		secretKeys:   []string{"key", "secret", "token", "password", "credential"},
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// AddProvider registers a provider check, e.g. an LLM, embedding model or
+// vector store backend. Failing providers make /healthz report unhealthy.
+func (d *Diagnostics) AddProvider(name string, check CheckFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.providers = append(d.providers, namedCheck{name: name, check: check})
+}
+
+// AddStore registers a store whose size is reported.
+func (d *Diagnostics) AddStore(name string, size SizeFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stores = append(d.stores, namedSize{name: name, size: size})
+}
+
+// AddCache registers a cache whose hit rate is reported.
+func (d *Diagnostics) AddCache(name string, stats func() ingestion.CacheStats) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.caches = append(d.caches, namedCache{name: name, stats: stats})
+}
+
+// AddPipeline registers an ingestion pipeline whose last run is reported.
+func (d *Diagnostics) AddPipeline(name string, pipeline *ingestion.IngestionPipeline) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pipelines = append(d.pipelines, namedPipeline{name: name, pipeline: pipeline})
+}
+
+// SetConfig sets the configuration summary. Values under secret-looking
+// keys are redacted when reported.
+func (d *Diagnostics) SetConfig(config map[string]interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.config = config
+}
+
+// Health runs the provider checks concurrently.
+func (d *Diagnostics) Health(ctx context.Context) (Status, []ProviderCheck) {
+	d.mu.RLock()
+	providers := append([]namedCheck(nil), d.providers...)
+	d.mu.RUnlock()
+
+	results := make([]ProviderCheck, len(providers))
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func(i int, p namedCheck) {
+			defer wg.Done()
+			results[i] = d.runCheck(ctx, p)
+		}(i, p)
+	}
+	wg.Wait()
+
+	status := StatusOK
+	for _, r := range results {
+		if r.Status != StatusOK {
+			status = StatusDegraded
+		}
+	}
+	return status, results
+}
+
+func (d *Diagnostics) runCheck(ctx context.Context, p namedCheck) ProviderCheck {
+	if d.checkTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.checkTimeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := p.check(ctx)
+	result := ProviderCheck{
+		Name:      p.name,
+		Status:    StatusOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDegraded
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Report builds a full diagnostics report.
+func (d *Diagnostics) Report(ctx context.Context) *Report {
+	status, providers := d.Health(ctx)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	report := &Report{
+		Status:      status,
+		GeneratedAt: time.Now().UTC(),
+		Providers:   providers,
+		Stores:      make([]StoreStats, 0, len(d.stores)),
+		Caches:      make([]CacheReport, 0, len(d.caches)),
+		Ingestion:   make([]IngestionReport, 0, len(d.pipelines)),
+		Config:      d.redact(d.config),
+	}
+
+	for _, s := range d.stores {
+		stats := StoreStats{Name: s.name}
+		size, err := s.size(ctx)
+		if err != nil {
+			stats.Error = err.Error()
+			report.Status = StatusDegraded
+		}
+		stats.Size = size
+		report.Stores = append(report.Stores, stats)
+	}
+
+	for _, c := range d.caches {
+		stats := c.stats()
+		report.Caches = append(report.Caches, CacheReport{
+			Name:    c.name,
+			Hits:    stats.Hits,
+			Misses:  stats.Misses,
+			Entries: stats.Entries,
+			HitRate: stats.HitRate(),
+		})
+	}
+
+	for _, p := range d.pipelines {
+		entry := IngestionReport{Name: p.name}
+		if run, ok := p.pipeline.LastRun(); ok {
+			entry.LastRun = &run
+		}
+		report.Ingestion = append(report.Ingestion, entry)
+	}
+
+	return report
+}
+
+// redact returns a copy of config with secret values replaced.
+func (d *Diagnostics) redact(config map[string]interface{}) map[string]interface{} {
+	if config == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(config))
+	for k, v := range config {
+		switch {
+		case d.isSecret(k):
+			out[k] = RedactedValue
+		case isMap(v):
+			out[k] = d.redact(v.(map[string]interface{}))
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func (d *Diagnostics) isSecret(key string) bool {
+	lower := strings.ToLower(key)
+	for _, s := range d.secretKeys {
+		if strings.Contains(lower, strings.ToLower(s)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isMap(v interface{}) bool {
+	_, ok := v.(map[string]interface{})
+	return ok
+}
+
+// VectorStoreSize reports the node count of a vector store that implements
+// store.NodeLister.
+func VectorStoreSize(vs store.VectorStore) SizeFunc {
+	return func(ctx context.Context) (int, error) {
+		lister, ok := vs.(store.NodeLister)
+		if !ok {
+			return 0, fmt.Errorf("vector store %T does not support listing nodes", vs)
+		}
+		nodes, err := lister.ListNodes(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return len(nodes), nil
+	}
+}
+
+// DocStoreSize reports the document count of a document store.
+func DocStoreSize(ds docstore.DocStore) SizeFunc {
+	return func(ctx context.Context) (int, error) {
+		docs, err := ds.Docs(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return len(docs), nil
+	}
+}
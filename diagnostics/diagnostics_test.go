@@ -0,0 +1,139 @@
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/ingestion"
+	"github.com/aqua777/go-llamaindex/rag/store"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/storage/docstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDiagnostics(t *testing.T, llmErr error) *Diagnostics {
+	t.Helper()
+	ctx := context.Background()
+
+	vs := store.NewSimpleVectorStore()
+	_, err := vs.Add(ctx, []schema.Node{{ID: "n1"}, {ID: "n2"}})
+	require.NoError(t, err)
+
+	ds := docstore.NewSimpleDocumentStore()
+	require.NoError(t, ds.AddDocuments(ctx, []schema.BaseNode{schema.NewTextNode("doc")}, true))
+
+	cache := ingestion.NewIngestionCache()
+	cache.Put("k", []schema.Node{{ID: "n1"}}, "")
+	cache.Get("k", "")
+	cache.Get("missing", "")
+
+	pipeline := ingestion.NewIngestionPipeline(ingestion.WithDisableCache(true))
+	_, err = pipeline.Run(ctx, []schema.Document{{ID: "d1", Text: "hello"}}, nil)
+	require.NoError(t, err)
+
+	d := NewDiagnostics()
+	d.AddProvider("embedding", func(ctx context.Context) error { return nil })
+	d.AddProvider("llm", func(ctx context.Context) error { return llmErr })
+	d.AddStore("vectors", VectorStoreSize(vs))
+	d.AddStore("docs", DocStoreSize(ds))
+	d.AddCache("ingestion", cache.Stats)
+	d.AddPipeline("main", pipeline)
+	d.SetConfig(map[string]interface{}{
+		"model":   "gpt-4o",
+		"api_key": "sk-secret",
+		"store": map[string]interface{}{
+			"url":      "http://localhost:8000",
+			"password": "hunter2",
+		},
+	})
+	return d
+}
+
+func TestReport(t *testing.T) {
+	d := newTestDiagnostics(t, nil)
+
+	report := d.Report(context.Background())
+	assert.Equal(t, StatusOK, report.Status)
+
+	require.Len(t, report.Providers, 2)
+	assert.Equal(t, "embedding", report.Providers[0].Name)
+	assert.Equal(t, StatusOK, report.Providers[1].Status)
+
+	require.Len(t, report.Stores, 2)
+	assert.Equal(t, 2, report.Stores[0].Size)
+	assert.Equal(t, 1, report.Stores[1].Size)
+
+	require.Len(t, report.Caches, 1)
+	assert.Equal(t, 0.5, report.Caches[0].HitRate)
+
+	require.Len(t, report.Ingestion, 1)
+	require.NotNil(t, report.Ingestion[0].LastRun)
+	assert.Equal(t, 1, report.Ingestion[0].LastRun.OutputCount)
+
+	assert.Equal(t, "gpt-4o", report.Config["model"])
+	assert.Equal(t, RedactedValue, report.Config["api_key"])
+	nested := report.Config["store"].(map[string]interface{})
+	assert.Equal(t, "http://localhost:8000", nested["url"])
+	assert.Equal(t, RedactedValue, nested["password"])
+}
+
+func TestHealthDegraded(t *testing.T) {
+	d := newTestDiagnostics(t, errors.New("401 unauthorized"))
+
+	status, providers := d.Health(context.Background())
+	assert.Equal(t, StatusDegraded, status)
+	assert.Equal(t, "401 unauthorized", providers[1].Error)
+}
+
+func TestCheckTimeout(t *testing.T) {
+	d := NewDiagnostics(WithCheckTimeout(10 * time.Millisecond))
+	d.AddProvider("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	status, providers := d.Health(context.Background())
+	assert.Equal(t, StatusDegraded, status)
+	assert.Contains(t, providers[0].Error, "deadline exceeded")
+}
+
+func TestStoreSizeUnsupported(t *testing.T) {
+	d := NewDiagnostics()
+	d.AddStore("opaque", VectorStoreSize(opaqueStore{}))
+
+	report := d.Report(context.Background())
+	assert.Equal(t, StatusDegraded, report.Status)
+	assert.Contains(t, report.Stores[0].Error, "does not support listing nodes")
+}
+
+type opaqueStore struct{ store.VectorStore }
+
+func TestHandlers(t *testing.T) {
+	healthy := newTestDiagnostics(t, nil).Handler()
+	degraded := newTestDiagnostics(t, errors.New("down")).Handler()
+
+	rec := httptest.NewRecorder()
+	healthy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	rec = httptest.NewRecorder()
+	degraded.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	rec = httptest.NewRecorder()
+	degraded.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/diagz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var report Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, StatusDegraded, report.Status)
+	assert.Len(t, report.Stores, 2)
+	assert.NotContains(t, rec.Body.String(), "sk-secret")
+}
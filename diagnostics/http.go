@@ -0,0 +1,44 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Handler returns an http.Handler serving /healthz and /diagz.
+func (d *Diagnostics) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", d.HealthzHandler())
+	mux.Handle("/diagz", d.DiagzHandler())
+	return mux
+}
+
+// HealthzHandler serves provider health. It responds 200 when every
+// provider check passes and 503 otherwise.
+func (d *Diagnostics) HealthzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, providers := d.Health(r.Context())
+		code := http.StatusOK
+		if status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, map[string]interface{}{
+			"status":    status,
+			"providers": providers,
+		})
+	})
+}
+
+// DiagzHandler serves the full diagnostics report. It always responds 200
+// so the report is readable while the service is degraded.
+func (d *Diagnostics) DiagzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, d.Report(r.Context()))
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -6,14 +6,16 @@ import (
 	"log/slog"
 	"os"
 
+	"github.com/aqua777/go-llamaindex/credentials"
 	openai "github.com/sashabaranov/go-openai"
 )
 
 // AzureOpenAIEmbedding implements the EmbeddingModel interface for Azure OpenAI.
 type AzureOpenAIEmbedding struct {
-	client     *openai.Client
-	deployment string // Azure deployment name
-	logger     *slog.Logger
+	client      *openai.Client
+	deployment  string // Azure deployment name
+	logger      *slog.Logger
+	credentials credentials.Provider
 }
 
 // AzureOpenAIEmbeddingOption configures an AzureOpenAIEmbedding.
@@ -26,12 +28,21 @@ func WithAzureEmbeddingDeployment(deployment string) AzureOpenAIEmbeddingOption
 	}
 }
 
+// WithAzureOpenAIEmbeddingCredentials resolves the API key from a
+// credentials provider on every request, under the name
+// "AZURE_OPENAI_API_KEY", instead of reading it from the environment.
+func WithAzureOpenAIEmbeddingCredentials(provider credentials.Provider) AzureOpenAIEmbeddingOption {
+	return func(a *AzureOpenAIEmbedding) {
+		a.credentials = provider
+	}
+}
+
 // NewAzureOpenAIEmbedding creates a new Azure OpenAI embedding client.
 // It requires the Azure endpoint and API key, which can be provided via
-// environment variables AZURE_OPENAI_ENDPOINT and AZURE_OPENAI_API_KEY.
+// environment variables AZURE_OPENAI_ENDPOINT and AZURE_OPENAI_API_KEY, or
+// the key via WithAzureOpenAIEmbeddingCredentials.
 func NewAzureOpenAIEmbedding(opts ...AzureOpenAIEmbeddingOption) *AzureOpenAIEmbedding {
 	endpoint := os.Getenv("AZURE_OPENAI_ENDPOINT")
-	apiKey := os.Getenv("AZURE_OPENAI_API_KEY")
 	deployment := os.Getenv("AZURE_OPENAI_EMBEDDING_DEPLOYMENT")
 
 	a := &AzureOpenAIEmbedding{
@@ -44,7 +55,13 @@ func NewAzureOpenAIEmbedding(opts ...AzureOpenAIEmbeddingOption) *AzureOpenAIEmb
 	}
 
 	// Create Azure OpenAI config
-	config := openai.DefaultAzureConfig(apiKey, endpoint)
+	var config openai.ClientConfig
+	if a.credentials != nil {
+		config = openai.DefaultAzureConfig("", endpoint)
+		config.HTTPClient = credentials.NewHTTPClient(nil, a.credentials, "AZURE_OPENAI_API_KEY", credentials.HeaderAuth("api-key"))
+	} else {
+		config = openai.DefaultAzureConfig(os.Getenv("AZURE_OPENAI_API_KEY"), endpoint)
+	}
 	a.client = openai.NewClientWithConfig(config)
 
 	return a
@@ -310,27 +310,38 @@ func defaultTokenizer(text string) []string {
 	return tokens
 }
 
+// BM25Tokenize is the default BM25 tokenizer: it lowercases text, replaces
+// punctuation with spaces and splits on whitespace.
+func BM25Tokenize(text string) []string {
+	return defaultTokenizer(text)
+}
+
+// DefaultBM25Stopwords returns the default English stopwords.
+func DefaultBM25Stopwords() []string {
+	return append([]string(nil), bm25StopwordList...)
+}
+
 // defaultBM25Stopwords returns default English stopwords.
 func defaultBM25Stopwords() map[string]bool {
-	words := []string{
-		"a", "an", "the", "and", "or", "but", "in", "on", "at", "to", "for",
-		"of", "with", "by", "from", "as", "is", "was", "are", "were", "been",
-		"be", "have", "has", "had", "do", "does", "did", "will", "would",
-		"could", "should", "may", "might", "must", "shall", "can", "need",
-		"this", "that", "these", "those", "i", "you", "he", "she", "it",
-		"we", "they", "what", "which", "who", "whom", "when", "where", "why",
-		"how", "all", "each", "every", "both", "few", "more", "most", "other",
-		"some", "such", "no", "nor", "not", "only", "own", "same", "so",
-		"than", "too", "very", "just", "also", "now",
-	}
-
 	stopwords := make(map[string]bool)
-	for _, w := range words {
+	for _, w := range bm25StopwordList {
 		stopwords[w] = true
 	}
 	return stopwords
 }
 
+var bm25StopwordList = []string{
+	"a", "an", "the", "and", "or", "but", "in", "on", "at", "to", "for",
+	"of", "with", "by", "from", "as", "is", "was", "are", "were", "been",
+	"be", "have", "has", "had", "do", "does", "did", "will", "would",
+	"could", "should", "may", "might", "must", "shall", "can", "need",
+	"this", "that", "these", "those", "i", "you", "he", "she", "it",
+	"we", "they", "what", "which", "who", "whom", "when", "where", "why",
+	"how", "all", "each", "every", "both", "few", "more", "most", "other",
+	"some", "such", "no", "nor", "not", "only", "own", "same", "so",
+	"than", "too", "very", "just", "also", "now",
+}
+
 // BM25Plus implements BM25+ which addresses the issue of negative IDF.
 type BM25Plus struct {
 	*BM25
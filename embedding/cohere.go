@@ -9,6 +9,8 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+
+	"github.com/aqua777/go-llamaindex/credentials"
 )
 
 const (
@@ -40,13 +42,14 @@ const (
 
 // CohereEmbedding implements the EmbeddingModel interface for Cohere.
 type CohereEmbedding struct {
-	apiKey     string
-	baseURL    string
-	model      string
-	inputType  CohereInputType
-	truncate   string // "NONE", "START", "END"
-	httpClient *http.Client
-	logger     *slog.Logger
+	apiKey      string
+	baseURL     string
+	model       string
+	inputType   CohereInputType
+	truncate    string // "NONE", "START", "END"
+	httpClient  *http.Client
+	credentials credentials.Provider
+	logger      *slog.Logger
 }
 
 // CohereEmbeddingOption configures a CohereEmbedding.
@@ -94,6 +97,16 @@ func WithCohereEmbeddingHTTPClient(client *http.Client) CohereEmbeddingOption {
 	}
 }
 
+// WithCohereEmbeddingCredentials resolves the API key from a credentials provider on
+// every request, under the name "COHERE_API_KEY". It takes precedence over the
+// static API key. When the API responds 401 the key is invalidated and the
+// request retried once, so rotated keys are picked up without restarting.
+func WithCohereEmbeddingCredentials(provider credentials.Provider) CohereEmbeddingOption {
+	return func(c *CohereEmbedding) {
+		c.credentials = provider
+	}
+}
+
 // NewCohereEmbedding creates a new Cohere embedding client.
 func NewCohereEmbedding(opts ...CohereEmbeddingOption) *CohereEmbedding {
 	c := &CohereEmbedding{
@@ -109,6 +122,9 @@ func NewCohereEmbedding(opts ...CohereEmbeddingOption) *CohereEmbedding {
 	for _, opt := range opts {
 		opt(c)
 	}
+	if c.credentials != nil {
+		c.httpClient = credentials.NewHTTPClient(c.httpClient, c.credentials, "COHERE_API_KEY", credentials.BearerAuth)
+	}
 
 	return c
 }
@@ -179,7 +195,9 @@ func (c *CohereEmbedding) getEmbeddings(ctx context.Context, texts []string, inp
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	if c.credentials == nil {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
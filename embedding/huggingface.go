@@ -9,6 +9,8 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+
+	"github.com/aqua777/go-llamaindex/credentials"
 )
 
 const (
@@ -42,6 +44,7 @@ type HuggingFaceEmbedding struct {
 	model       string
 	useTEI      bool // Use Text Embeddings Inference format
 	httpClient  *http.Client
+	credentials credentials.Provider
 	logger      *slog.Logger
 	queryPrefix string // Prefix for query embeddings (e.g., "query: " for E5)
 	docPrefix   string // Prefix for document embeddings (e.g., "passage: " for E5)
@@ -85,6 +88,15 @@ func WithHuggingFaceHTTPClient(client *http.Client) HuggingFaceEmbeddingOption {
 	}
 }
 
+// WithHuggingFaceCredentials resolves the API key from a credentials provider on
+// every request, under the name "HUGGINGFACE_API_KEY". It takes precedence over the
+// static API key and allows keys to rotate without restarting.
+func WithHuggingFaceCredentials(provider credentials.Provider) HuggingFaceEmbeddingOption {
+	return func(h *HuggingFaceEmbedding) {
+		h.credentials = provider
+	}
+}
+
 // WithHuggingFaceQueryPrefix sets the query prefix.
 func WithHuggingFaceQueryPrefix(prefix string) HuggingFaceEmbeddingOption {
 	return func(h *HuggingFaceEmbedding) {
@@ -201,8 +213,12 @@ func (h *HuggingFaceEmbedding) getInferenceAPIEmbedding(ctx context.Context, tex
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	if h.apiKey != "" {
-		req.Header.Set("Authorization", "Bearer "+h.apiKey)
+	apiKey, err := credentials.Resolve(ctx, h.credentials, "HUGGINGFACE_API_KEY", h.apiKey)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
 	}
 
 	resp, err := h.httpClient.Do(req)
@@ -0,0 +1,35 @@
+package embedding
+
+import "context"
+
+// MultiVectorEmbeddingModel produces one embedding per token instead of a
+// single pooled vector, as used by ColBERT-style late-interaction models.
+type MultiVectorEmbeddingModel interface {
+	// GetTextMultiVector generates per-token embeddings for a document.
+	GetTextMultiVector(ctx context.Context, text string) ([][]float64, error)
+	// GetQueryMultiVector generates per-token embeddings for a query.
+	GetQueryMultiVector(ctx context.Context, query string) ([][]float64, error)
+}
+
+// MaxSim computes the late-interaction score between a query and a document:
+// for each query vector it takes the highest dot product with any document
+// vector, and sums the results. Vectors are expected to be normalized, so
+// each term is a cosine similarity. Document vectors whose length differs
+// from the query vector are ignored.
+func MaxSim(query, document [][]float64) float64 {
+	var score float64
+	for _, q := range query {
+		best, found := 0.0, false
+		for _, d := range document {
+			sim, err := DotProduct(q, d)
+			if err != nil {
+				continue
+			}
+			if !found || sim > best {
+				best, found = sim, true
+			}
+		}
+		score += best
+	}
+	return score
+}
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"os"
 
+	"github.com/aqua777/go-llamaindex/credentials"
 	openai "github.com/sashabaranov/go-openai"
 )
 
@@ -37,6 +38,14 @@ func NewOpenAIEmbedding(apiKey string, modelName string) *OpenAIEmbedding {
 	}
 }
 
+// NewOpenAIEmbeddingWithCredentials creates an OpenAI embedding model whose
+// API key is resolved from provider under "OPENAI_API_KEY" on every request.
+func NewOpenAIEmbeddingWithCredentials(modelName string, provider credentials.Provider) *OpenAIEmbedding {
+	config := openai.DefaultConfig("")
+	config.HTTPClient = credentials.NewHTTPClient(nil, provider, "OPENAI_API_KEY", credentials.BearerAuth)
+	return NewOpenAIEmbeddingWithClient(openai.NewClientWithConfig(config), modelName)
+}
+
 func NewOpenAIEmbeddingWithClient(client *openai.Client, modelName string) *OpenAIEmbedding {
 	var model openai.EmbeddingModel
 	if modelName == "" {
@@ -0,0 +1,126 @@
+package embedding
+
+import (
+	"context"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// RateLimiter limits requests and tokens per minute with token buckets.
+// Share one RateLimiter between everything that calls the same provider so
+// the provider's quota is respected as a whole.
+type RateLimiter struct {
+	requestsPerMinute int
+	tokensPerMinute   int
+	now               func() time.Time
+
+	mu       sync.Mutex
+	requests float64
+	tokens   float64
+	last     time.Time
+}
+
+// NewRateLimiter creates a new RateLimiter. A limit of zero or less
+// disables that limit.
+func NewRateLimiter(requestsPerMinute, tokensPerMinute int) *RateLimiter {
+	return &RateLimiter{
+		requestsPerMinute: requestsPerMinute,
+		tokensPerMinute:   tokensPerMinute,
+		now:               time.Now,
+		requests:          float64(requestsPerMinute),
+		tokens:            float64(tokensPerMinute),
+	}
+}
+
+// Wait blocks until a request of the given number of tokens is allowed, or
+// returns the context's error. Requests larger than the per-minute token
+// limit wait for a full bucket.
+func (r *RateLimiter) Wait(ctx context.Context, tokens int) error {
+	for {
+		delay := r.reserve(tokens)
+		if delay == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes capacity for a request if available and otherwise returns
+// how long to wait before trying again.
+func (r *RateLimiter) reserve(tokens int) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if !r.last.IsZero() {
+		elapsed := now.Sub(r.last).Minutes()
+		r.requests = minFloat(float64(r.requestsPerMinute), r.requests+elapsed*float64(r.requestsPerMinute))
+		r.tokens = minFloat(float64(r.tokensPerMinute), r.tokens+elapsed*float64(r.tokensPerMinute))
+	}
+	r.last = now
+
+	need := float64(tokens)
+	if r.tokensPerMinute > 0 && need > float64(r.tokensPerMinute) {
+		need = float64(r.tokensPerMinute)
+	}
+
+	var wait time.Duration
+	if r.requestsPerMinute > 0 && r.requests < 1 {
+		wait = maxDuration(wait, perMinute(1-r.requests, r.requestsPerMinute))
+	}
+	if r.tokensPerMinute > 0 && r.tokens < need {
+		wait = maxDuration(wait, perMinute(need-r.tokens, r.tokensPerMinute))
+	}
+	if wait > 0 {
+		return wait
+	}
+
+	if r.requestsPerMinute > 0 {
+		r.requests--
+	}
+	if r.tokensPerMinute > 0 {
+		r.tokens -= need
+	}
+	return 0
+}
+
+// perMinute returns how long it takes to refill amount at limit per minute.
+func perMinute(amount float64, limit int) time.Duration {
+	d := time.Duration(amount / float64(limit) * float64(time.Minute))
+	if d < time.Millisecond {
+		d = time.Millisecond
+	}
+	return d
+}
+
+// EstimateTokens roughly estimates the tokens of text for rate limiting, at
+// four characters per token.
+func EstimateTokens(texts ...string) int {
+	total := 0
+	for _, text := range texts {
+		total += (utf8.RuneCountInString(text) + 3) / 4
+	}
+	return total
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -133,3 +133,13 @@ func NewImageFromPath(path string) ImageType {
 func (i ImageType) IsEmpty() bool {
 	return i.URL == "" && i.Base64 == "" && i.Path == ""
 }
+
+// ModelName returns the name of the model as reported by Info, or "" if the
+// model does not expose it. It is used to stamp nodes with the model that
+// embedded them.
+func ModelName(model EmbeddingModel) string {
+	if withInfo, ok := model.(EmbeddingModelWithInfo); ok {
+		return withInfo.Info().ModelName
+	}
+	return ""
+}
@@ -1,8 +1,10 @@
 package embedding
 
 import (
+	"context"
 	"math"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -106,6 +108,18 @@ func TestDotProduct(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestMaxSim(t *testing.T) {
+	query := [][]float64{{1, 0}, {0, 1}}
+	document := [][]float64{{1, 0}, {0.6, 0.8}}
+
+	// Best matches: {1,0}·{1,0} = 1 and {0,1}·{0.6,0.8} = 0.8.
+	assert.InDelta(t, 1.8, MaxSim(query, document), 0.0001)
+	assert.Equal(t, 0.0, MaxSim(query, nil))
+
+	// Mismatched dimensions are ignored.
+	assert.InDelta(t, 1.0, MaxSim([][]float64{{1, 0}}, [][]float64{{1, 0, 0}, {1, 0}}), 0.0001)
+}
+
 func TestEuclideanDistance(t *testing.T) {
 	// Same point
 	a := []float64{1, 2, 3}
@@ -380,3 +394,17 @@ func TestSpecialCases(t *testing.T) {
 	assert.False(t, math.IsInf(mag, 0))
 	assert.False(t, math.IsNaN(mag))
 }
+
+func TestEmbedRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(60, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	for i := 0; i < 60; i++ {
+		require.NoError(t, limiter.Wait(ctx, 0))
+	}
+	// The bucket is empty; the next request would wait about a second.
+	assert.ErrorIs(t, limiter.Wait(ctx, 0), context.DeadlineExceeded)
+
+	assert.Equal(t, 3, EstimateTokens("hello world"))
+}
@@ -2,34 +2,65 @@ package evaluation
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
 	"sync"
+
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
 )
 
+// QueryEngine answers queries with a synthesized response. It is
+// satisfied by the query engines in the queryengine package.
+type QueryEngine interface {
+	Query(ctx context.Context, query string) (*synthesizer.Response, error)
+}
+
+// ProgressFunc is called after each evaluation job with the number of
+// completed jobs and the total number of jobs.
+type ProgressFunc func(completed, total int)
+
 // BatchEvalRunner runs evaluations in batch with concurrency control.
 type BatchEvalRunner struct {
 	evaluators   map[string]Evaluator
 	workers      int
 	showProgress bool
+	progress     ProgressFunc
 }
 
 // BatchEvalRunnerOption configures a BatchEvalRunner.
 type BatchEvalRunnerOption func(*BatchEvalRunner)
 
-// WithBatchWorkers sets the number of concurrent workers.
+// WithBatchWorkers sets the number of concurrent workers. Workers only
+// bound this runner; to bound LLM requests across runners and the rest of
+// the application, build the evaluators with LLMs wrapped by
+// llm.NewLimitedLLM around one shared llm.ConcurrencyLimiter.
 func WithBatchWorkers(workers int) BatchEvalRunnerOption {
 	return func(r *BatchEvalRunner) {
 		r.workers = workers
 	}
 }
 
-// WithBatchShowProgress enables progress reporting.
+// WithBatchShowProgress enables printing progress to stdout.
 func WithBatchShowProgress(show bool) BatchEvalRunnerOption {
 	return func(r *BatchEvalRunner) {
 		r.showProgress = show
 	}
 }
 
+// WithBatchProgress sets a callback invoked as evaluation jobs complete.
+// Calls are serialized.
+func WithBatchProgress(fn ProgressFunc) BatchEvalRunnerOption {
+	return func(r *BatchEvalRunner) {
+		r.progress = fn
+	}
+}
+
 // NewBatchEvalRunner creates a new BatchEvalRunner.
 func NewBatchEvalRunner(evaluators map[string]Evaluator, opts ...BatchEvalRunnerOption) *BatchEvalRunner {
 	r := &BatchEvalRunner{
@@ -104,6 +135,154 @@ func (r *BatchEvalResult) GetPassingRate(evaluatorName string) float64 {
 	return float64(passing) / float64(len(results))
 }
 
+// EvaluatorStats are aggregate statistics of one evaluator's results.
+type EvaluatorStats struct {
+	// Count is the number of results.
+	Count int `json:"count"`
+	// Invalid is the number of invalid results, e.g. failed evaluations.
+	Invalid int `json:"invalid"`
+	// Passing is the number of passing results.
+	Passing int `json:"passing"`
+	// PassingRate is Passing divided by Count.
+	PassingRate float64 `json:"passing_rate"`
+	// Scored is the number of results with a score.
+	Scored int `json:"scored"`
+	// MeanScore, MinScore and MaxScore are computed over scored results.
+	MeanScore float64 `json:"mean_score"`
+	MinScore  float64 `json:"min_score"`
+	MaxScore  float64 `json:"max_score"`
+}
+
+// Stats returns the statistics of an evaluator's results.
+func (r *BatchEvalResult) Stats(evaluatorName string) EvaluatorStats {
+	results := r.Results[evaluatorName]
+	stats := EvaluatorStats{Count: len(results)}
+
+	var total float64
+	for _, result := range results {
+		if result.InvalidResult {
+			stats.Invalid++
+		}
+		if result.IsPassing() {
+			stats.Passing++
+		}
+		if result.Score == nil {
+			continue
+		}
+		score := *result.Score
+		if stats.Scored == 0 {
+			stats.MinScore, stats.MaxScore = score, score
+		} else {
+			stats.MinScore = math.Min(stats.MinScore, score)
+			stats.MaxScore = math.Max(stats.MaxScore, score)
+		}
+		total += score
+		stats.Scored++
+	}
+
+	if stats.Count > 0 {
+		stats.PassingRate = float64(stats.Passing) / float64(stats.Count)
+	}
+	if stats.Scored > 0 {
+		stats.MeanScore = total / float64(stats.Scored)
+	}
+	return stats
+}
+
+// EvaluatorNames returns the evaluator names in sorted order.
+func (r *BatchEvalResult) EvaluatorNames() []string {
+	names := make([]string, 0, len(r.Results))
+	for name := range r.Results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteJSON writes the per-evaluator statistics and results as JSON.
+func (r *BatchEvalResult) WriteJSON(w io.Writer) error {
+	stats := make(map[string]EvaluatorStats, len(r.Results))
+	for name := range r.Results {
+		stats[name] = r.Stats(name)
+	}
+	errs := make([]string, len(r.Errors))
+	for i, err := range r.Errors {
+		errs[i] = err.Error()
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Stats   map[string]EvaluatorStats      `json:"stats"`
+		Results map[string][]*EvaluationResult `json:"results"`
+		Errors  []string                       `json:"errors,omitempty"`
+	}{stats, r.Results, errs})
+}
+
+// WriteCSV writes one row per evaluation result, grouped by evaluator in
+// sorted order. Rows of an evaluator are in input order.
+func (r *BatchEvalResult) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"evaluator", "index", "query", "response", "passing", "score", "invalid", "feedback"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, name := range r.EvaluatorNames() {
+		for i, result := range r.Results[name] {
+			passing, score := "", ""
+			if result.Passing != nil {
+				passing = strconv.FormatBool(*result.Passing)
+			}
+			if result.Score != nil {
+				score = strconv.FormatFloat(*result.Score, 'f', -1, 64)
+			}
+			feedback := result.Feedback
+			if result.InvalidResult {
+				feedback = result.InvalidReason
+			}
+			row := []string{
+				name,
+				strconv.Itoa(i),
+				result.Query,
+				result.Response,
+				passing,
+				score,
+				strconv.FormatBool(result.InvalidResult),
+				feedback,
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// SaveJSON writes the results to a JSON file.
+func (r *BatchEvalResult) SaveJSON(path string) error {
+	return saveResults(path, r.WriteJSON)
+}
+
+// SaveCSV writes the results to a CSV file.
+func (r *BatchEvalResult) SaveCSV(path string) error {
+	return saveResults(path, r.WriteCSV)
+}
+
+func saveResults(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 // Summary returns a summary of the batch evaluation results.
 func (r *BatchEvalResult) Summary() map[string]map[string]float64 {
 	summary := make(map[string]map[string]float64)
@@ -122,12 +301,17 @@ type evalJob struct {
 	evaluatorName string
 	evaluator     Evaluator
 	input         *EvaluateInput
-	index         int
+	// response, if set, is evaluated with its source nodes instead of input.
+	response *synthesizer.Response
+	// err, if set, is a failure that happened before evaluation.
+	err   error
+	index int
 }
 
 // evalResult represents the result of a single evaluation job.
 type evalResult struct {
 	evaluatorName string
+	input         *EvaluateInput
 	result        *EvaluationResult
 	err           error
 	index         int
@@ -192,6 +376,78 @@ func (r *BatchEvalRunner) EvaluateResponseStrs(
 	return r.formatResults(results), nil
 }
 
+// EvaluateQueries runs each query through engine and evaluates the
+// responses, using their source nodes as contexts. Queries run
+// concurrently, bounded by the number of workers. A failed query is
+// recorded as an error and an invalid result for every evaluator.
+func (r *BatchEvalRunner) EvaluateQueries(ctx context.Context, engine QueryEngine, queries []string) (*BatchEvalResult, error) {
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("at least one query must be provided")
+	}
+
+	responses := make([]*synthesizer.Response, len(queries))
+	errs := make([]error, len(queries))
+	sem := make(chan struct{}, r.workerCount())
+	var wg sync.WaitGroup
+	for i, query := range queries {
+		wg.Add(1)
+		go func(i int, query string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			responses[i], errs[i] = engine.Query(ctx, query)
+		}(i, query)
+	}
+	wg.Wait()
+
+	return r.evaluateResponses(ctx, queries, responses, errs), nil
+}
+
+// EvaluateResponses evaluates query engine responses, using their source
+// nodes as contexts.
+func (r *BatchEvalRunner) EvaluateResponses(ctx context.Context, queries []string, responses []*synthesizer.Response) (*BatchEvalResult, error) {
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("at least one query must be provided")
+	}
+	if len(responses) != len(queries) {
+		return nil, fmt.Errorf("queries and responses must have the same length")
+	}
+
+	return r.evaluateResponses(ctx, queries, responses, make([]error, len(queries))), nil
+}
+
+func (r *BatchEvalRunner) evaluateResponses(ctx context.Context, queries []string, responses []*synthesizer.Response, errs []error) *BatchEvalResult {
+	jobs := make([]evalJob, 0, len(queries)*len(r.evaluators))
+	for i, query := range queries {
+		err := errs[i]
+		if err == nil && responses[i] == nil {
+			err = fmt.Errorf("no response")
+		}
+		if err != nil {
+			err = fmt.Errorf("query %q failed: %w", query, err)
+		}
+
+		input := NewEvaluateInput().WithQuery(query)
+		if err == nil {
+			input.WithResponse(responses[i].Response).
+				WithContexts(ContextsFromNodes(responses[i].SourceNodes))
+		}
+
+		for name, evaluator := range r.evaluators {
+			jobs = append(jobs, evalJob{
+				evaluatorName: name,
+				evaluator:     evaluator,
+				input:         input,
+				response:      responses[i],
+				err:           err,
+				index:         i,
+			})
+		}
+	}
+
+	return r.formatResults(r.runJobs(ctx, jobs))
+}
+
 // EvaluateWithReferences evaluates with reference answers.
 func (r *BatchEvalRunner) EvaluateWithReferences(
 	ctx context.Context,
@@ -245,22 +501,30 @@ func (r *BatchEvalRunner) runJobs(ctx context.Context, jobs []evalJob) []evalRes
 	results := make([]evalResult, len(jobs))
 	jobChan := make(chan int, len(jobs))
 	var wg sync.WaitGroup
+	var mu sync.Mutex
+	completed := 0
 
 	// Start workers
-	for i := 0; i < r.workers; i++ {
+	for i := 0; i < r.workerCount(); i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			for jobIdx := range jobChan {
 				job := jobs[jobIdx]
 
-				result, err := job.evaluator.Evaluate(ctx, job.input)
+				result, err := runJob(ctx, job)
 				results[jobIdx] = evalResult{
 					evaluatorName: job.evaluatorName,
+					input:         job.input,
 					result:        result,
 					err:           err,
 					index:         job.index,
 				}
+
+				mu.Lock()
+				completed++
+				r.reportProgress(completed, len(jobs))
+				mu.Unlock()
 			}
 		}()
 	}
@@ -277,6 +541,35 @@ func (r *BatchEvalRunner) runJobs(ctx context.Context, jobs []evalJob) []evalRes
 	return results
 }
 
+// runJob runs a single evaluation job.
+func runJob(ctx context.Context, job evalJob) (*EvaluationResult, error) {
+	if job.err != nil {
+		return nil, job.err
+	}
+	if job.response != nil {
+		return EvaluateQueryResponse(ctx, job.evaluator, job.input.Query, job.response)
+	}
+	return job.evaluator.Evaluate(ctx, job.input)
+}
+
+// reportProgress reports that completed of total jobs are done.
+func (r *BatchEvalRunner) reportProgress(completed, total int) {
+	if r.progress != nil {
+		r.progress(completed, total)
+	}
+	if r.showProgress {
+		fmt.Printf("Evaluated %d/%d\n", completed, total)
+	}
+}
+
+// workerCount returns the number of workers, at least one.
+func (r *BatchEvalRunner) workerCount() int {
+	if r.workers < 1 {
+		return 1
+	}
+	return r.workers
+}
+
 // formatResults formats evaluation results.
 func (r *BatchEvalRunner) formatResults(results []evalResult) *BatchEvalResult {
 	evaluatorNames := make([]string, 0, len(r.evaluators))
@@ -290,7 +583,10 @@ func (r *BatchEvalRunner) formatResults(results []evalResult) *BatchEvalResult {
 		if result.err != nil {
 			batchResult.Errors = append(batchResult.Errors, result.err)
 			// Add an invalid result
-			invalidResult := NewEvaluationResult().WithInvalid(result.err.Error())
+			invalidResult := NewEvaluationResult().
+				WithQuery(result.input.Query).
+				WithResponse(result.input.Response).
+				WithInvalid(result.err.Error())
 			batchResult.Results[result.evaluatorName] = append(
 				batchResult.Results[result.evaluatorName],
 				invalidResult,
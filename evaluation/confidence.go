@@ -0,0 +1,450 @@
+package evaluation
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// Metadata keys written to Response.Metadata by ConfidenceEstimator.
+const (
+	// ConfidenceMetadataKey holds the calibrated confidence (float64 in [0, 1]).
+	ConfidenceMetadataKey = "confidence"
+	// ConfidenceSignalsMetadataKey holds the individual ConfidenceSignals.
+	ConfidenceSignalsMetadataKey = "confidence_signals"
+)
+
+// DefaultSelfAssessmentTemplate is the default prompt for LLM self-assessment.
+const DefaultSelfAssessmentTemplate = `You are assessing how confident one can be that an answer to a question is correct,
+given the context it was generated from.
+Consider whether the context actually contains the information needed and whether the answer follows from it.
+
+Question: {query}
+Context:
+{context}
+
+Answer: {response}
+
+Reply with a single line in the format "Confidence: <number between 0 and 1>".
+`
+
+var confidenceNumberRegex = regexp.MustCompile(`[-+]?\d*\.?\d+`)
+
+// ConfidenceWeights controls how much each signal contributes to the raw confidence.
+// Signals that are unavailable are dropped and the remaining weights renormalized.
+type ConfidenceWeights struct {
+	Retrieval      float64
+	Consistency    float64
+	SelfAssessment float64
+}
+
+// DefaultConfidenceWeights returns the default signal weights.
+func DefaultConfidenceWeights() ConfidenceWeights {
+	return ConfidenceWeights{
+		Retrieval:      0.3,
+		Consistency:    0.4,
+		SelfAssessment: 0.3,
+	}
+}
+
+// ConfidenceSignals holds the individual signals combined into a confidence score.
+// A nil field means the signal was not computed.
+type ConfidenceSignals struct {
+	// Retrieval is derived from the similarity scores of the source nodes.
+	Retrieval *float64 `json:"retrieval,omitempty"`
+	// Consistency is the mean agreement between the answer and resampled answers.
+	Consistency *float64 `json:"consistency,omitempty"`
+	// SelfAssessment is the LLM's own rating of the answer.
+	SelfAssessment *float64 `json:"self_assessment,omitempty"`
+	// Raw is the weighted combination before calibration.
+	Raw float64 `json:"raw"`
+}
+
+// Calibrator maps a raw confidence to a calibrated probability.
+type Calibrator interface {
+	Calibrate(raw float64) float64
+}
+
+// PlattCalibrator calibrates scores with a logistic function fitted offline:
+// p = 1 / (1 + exp(-(A*raw + B))).
+type PlattCalibrator struct {
+	A float64
+	B float64
+}
+
+// Calibrate applies the logistic mapping.
+func (c PlattCalibrator) Calibrate(raw float64) float64 {
+	return 1 / (1 + math.Exp(-(c.A*raw + c.B)))
+}
+
+// ConfidenceEstimator estimates how likely a final answer is to be correct.
+// It combines retrieval score statistics, self-consistency across several
+// regenerated answers, and an LLM self-assessment prompt.
+type ConfidenceEstimator struct {
+	*BaseEvaluator
+	llm                llm.LLM
+	synth              synthesizer.Synthesizer
+	embedModel         embedding.EmbeddingModel
+	numSamples         int
+	weights            ConfidenceWeights
+	selfAssessTemplate string
+	calibrator         Calibrator
+	threshold          float64
+}
+
+// ConfidenceEstimatorOption configures a ConfidenceEstimator.
+type ConfidenceEstimatorOption func(*ConfidenceEstimator)
+
+// WithConfidenceLLM sets the LLM used for resampling and self-assessment.
+func WithConfidenceLLM(l llm.LLM) ConfidenceEstimatorOption {
+	return func(e *ConfidenceEstimator) {
+		e.llm = l
+	}
+}
+
+// WithConfidenceSynthesizer sets the synthesizer used to regenerate answers
+// for self-consistency. Without one, answers are regenerated with the LLM and
+// the default QA prompt.
+func WithConfidenceSynthesizer(s synthesizer.Synthesizer) ConfidenceEstimatorOption {
+	return func(e *ConfidenceEstimator) {
+		e.synth = s
+	}
+}
+
+// WithConfidenceEmbedModel sets the embedding model used to compare answers.
+// Without one, answers are compared by token overlap.
+func WithConfidenceEmbedModel(model embedding.EmbeddingModel) ConfidenceEstimatorOption {
+	return func(e *ConfidenceEstimator) {
+		e.embedModel = model
+	}
+}
+
+// WithConfidenceSamples sets the number of regenerated answers (0 disables self-consistency).
+func WithConfidenceSamples(n int) ConfidenceEstimatorOption {
+	return func(e *ConfidenceEstimator) {
+		e.numSamples = n
+	}
+}
+
+// WithConfidenceWeights sets the signal weights.
+func WithConfidenceWeights(weights ConfidenceWeights) ConfidenceEstimatorOption {
+	return func(e *ConfidenceEstimator) {
+		e.weights = weights
+	}
+}
+
+// WithSelfAssessmentTemplate sets the self-assessment prompt template.
+func WithSelfAssessmentTemplate(template string) ConfidenceEstimatorOption {
+	return func(e *ConfidenceEstimator) {
+		e.selfAssessTemplate = template
+	}
+}
+
+// WithConfidenceCalibrator sets the calibrator applied to the raw score.
+func WithConfidenceCalibrator(c Calibrator) ConfidenceEstimatorOption {
+	return func(e *ConfidenceEstimator) {
+		e.calibrator = c
+	}
+}
+
+// WithConfidenceThreshold sets the confidence needed for a passing result.
+func WithConfidenceThreshold(threshold float64) ConfidenceEstimatorOption {
+	return func(e *ConfidenceEstimator) {
+		e.threshold = threshold
+	}
+}
+
+// NewConfidenceEstimator creates a new ConfidenceEstimator.
+func NewConfidenceEstimator(opts ...ConfidenceEstimatorOption) *ConfidenceEstimator {
+	e := &ConfidenceEstimator{
+		BaseEvaluator:      NewBaseEvaluator(WithEvaluatorName("confidence")),
+		numSamples:         3,
+		weights:            DefaultConfidenceWeights(),
+		selfAssessTemplate: DefaultSelfAssessmentTemplate,
+		threshold:          0.5,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Estimate computes the confidence of a response and stores it in
+// resp.Metadata under ConfidenceMetadataKey and ConfidenceSignalsMetadataKey.
+func (e *ConfidenceEstimator) Estimate(ctx context.Context, query string, resp *synthesizer.Response) (float64, error) {
+	if resp == nil {
+		return 0, fmt.Errorf("response must be provided")
+	}
+
+	confidence, signals, err := e.estimateSignals(ctx, query, resp.Response, resp.SourceNodes, true)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.Metadata == nil {
+		resp.Metadata = make(map[string]interface{})
+	}
+	resp.Metadata[ConfidenceMetadataKey] = confidence
+	resp.Metadata[ConfidenceSignalsMetadataKey] = signals
+	return confidence, nil
+}
+
+// Evaluate implements Evaluator. Contexts are treated as unscored source
+// nodes, so the retrieval signal is not available.
+func (e *ConfidenceEstimator) Evaluate(ctx context.Context, input *EvaluateInput) (*EvaluationResult, error) {
+	if input.Response == "" {
+		return NewEvaluationResult().WithInvalid("response must be provided"), nil
+	}
+
+	nodes := make([]schema.NodeWithScore, len(input.Contexts))
+	for i, c := range input.Contexts {
+		nodes[i] = schema.NodeWithScore{Node: *schema.NewTextNode(c)}
+	}
+
+	confidence, signals, err := e.estimateSignals(ctx, input.Query, input.Response, nodes, false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := NewEvaluationResult().
+		WithQuery(input.Query).
+		WithResponse(input.Response).
+		WithContexts(input.Contexts).
+		WithScore(confidence).
+		WithPassing(confidence >= e.threshold)
+	result.Metadata[ConfidenceSignalsMetadataKey] = signals
+	return result, nil
+}
+
+// EvaluateResponse evaluates a response with scored source nodes.
+func (e *ConfidenceEstimator) EvaluateResponse(ctx context.Context, query string, response string, sourceNodes []schema.NodeWithScore) (*EvaluationResult, error) {
+	if response == "" {
+		return NewEvaluationResult().WithInvalid("response must be provided"), nil
+	}
+
+	confidence, signals, err := e.estimateSignals(ctx, query, response, sourceNodes, true)
+	if err != nil {
+		return nil, err
+	}
+
+	result := NewEvaluationResult().
+		WithQuery(query).
+		WithResponse(response).
+		WithContexts(ContextsFromNodes(sourceNodes)).
+		WithScore(confidence).
+		WithPassing(confidence >= e.threshold)
+	result.Metadata[ConfidenceSignalsMetadataKey] = signals
+	return result, nil
+}
+
+// estimateSignals computes the available signals and combines them.
+// useScores is false when node scores are placeholders rather than retrieval scores.
+func (e *ConfidenceEstimator) estimateSignals(ctx context.Context, query, response string, nodes []schema.NodeWithScore, useScores bool) (float64, *ConfidenceSignals, error) {
+	signals := &ConfidenceSignals{}
+
+	if useScores && e.weights.Retrieval > 0 && len(nodes) > 0 {
+		score := RetrievalConfidence(nodes)
+		signals.Retrieval = &score
+	}
+
+	if e.weights.Consistency > 0 && e.numSamples > 0 && (e.synth != nil || e.llm != nil) {
+		score, err := e.consistency(ctx, query, response, nodes)
+		if err != nil {
+			return 0, nil, fmt.Errorf("self-consistency sampling failed: %w", err)
+		}
+		signals.Consistency = &score
+	}
+
+	if e.weights.SelfAssessment > 0 && e.llm != nil {
+		score, err := e.selfAssess(ctx, query, response, nodes)
+		if err != nil {
+			return 0, nil, fmt.Errorf("self-assessment failed: %w", err)
+		}
+		signals.SelfAssessment = &score
+	}
+
+	var total, weightSum float64
+	add := func(v *float64, w float64) {
+		if v != nil && w > 0 {
+			total += *v * w
+			weightSum += w
+		}
+	}
+	add(signals.Retrieval, e.weights.Retrieval)
+	add(signals.Consistency, e.weights.Consistency)
+	add(signals.SelfAssessment, e.weights.SelfAssessment)
+
+	if weightSum == 0 {
+		return 0, nil, fmt.Errorf("no confidence signals available: provide source nodes, an LLM or a synthesizer")
+	}
+
+	signals.Raw = total / weightSum
+	confidence := signals.Raw
+	if e.calibrator != nil {
+		confidence = e.calibrator.Calibrate(confidence)
+	}
+	return clamp01(confidence), signals, nil
+}
+
+// consistency regenerates the answer numSamples times and returns the mean
+// similarity between the original answer and each sample.
+func (e *ConfidenceEstimator) consistency(ctx context.Context, query, response string, nodes []schema.NodeWithScore) (float64, error) {
+	var total float64
+	for i := 0; i < e.numSamples; i++ {
+		sample, err := e.sample(ctx, query, nodes)
+		if err != nil {
+			return 0, err
+		}
+		sim, err := e.answerSimilarity(ctx, response, sample)
+		if err != nil {
+			return 0, err
+		}
+		total += sim
+	}
+	return total / float64(e.numSamples), nil
+}
+
+func (e *ConfidenceEstimator) sample(ctx context.Context, query string, nodes []schema.NodeWithScore) (string, error) {
+	if e.synth != nil {
+		resp, err := e.synth.Synthesize(ctx, query, nodes)
+		if err != nil {
+			return "", err
+		}
+		return resp.Response, nil
+	}
+	prompt := prompts.DefaultTextQAPrompt.Format(map[string]string{
+		"query_str":   query,
+		"context_str": joinNodeContents(nodes),
+	})
+	return e.llm.Complete(ctx, prompt)
+}
+
+func (e *ConfidenceEstimator) answerSimilarity(ctx context.Context, a, b string) (float64, error) {
+	if e.embedModel == nil {
+		return TokenJaccard(a, b), nil
+	}
+	embA, err := e.embedModel.GetTextEmbedding(ctx, a)
+	if err != nil {
+		return 0, err
+	}
+	embB, err := e.embedModel.GetTextEmbedding(ctx, b)
+	if err != nil {
+		return 0, err
+	}
+	sim, err := embedding.CosineSimilarity(embA, embB)
+	if err != nil {
+		return 0, err
+	}
+	return clamp01(sim), nil
+}
+
+func (e *ConfidenceEstimator) selfAssess(ctx context.Context, query, response string, nodes []schema.NodeWithScore) (float64, error) {
+	prompt := strings.ReplaceAll(e.selfAssessTemplate, "{query}", query)
+	prompt = strings.ReplaceAll(prompt, "{context}", joinNodeContents(nodes))
+	prompt = strings.ReplaceAll(prompt, "{response}", response)
+
+	llmResponse, err := e.llm.Complete(ctx, prompt)
+	if err != nil {
+		return 0, err
+	}
+	return ParseConfidence(llmResponse)
+}
+
+// RetrievalConfidence summarizes source node scores as a value in [0, 1].
+// It averages the top three scores, so a single lucky match does not
+// dominate, and clamps the result.
+func RetrievalConfidence(nodes []schema.NodeWithScore) float64 {
+	if len(nodes) == 0 {
+		return 0
+	}
+	scores := make([]float64, len(nodes))
+	for i, n := range nodes {
+		scores[i] = n.Score
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(scores)))
+	if len(scores) > 3 {
+		scores = scores[:3]
+	}
+	var sum float64
+	for _, s := range scores {
+		sum += s
+	}
+	return clamp01(sum / float64(len(scores)))
+}
+
+// ParseConfidence extracts a confidence value from an LLM reply.
+// Values between 1 and 100 are treated as percentages.
+func ParseConfidence(text string) (float64, error) {
+	if idx := strings.Index(strings.ToLower(text), "confidence"); idx >= 0 {
+		text = text[idx:]
+	}
+	match := confidenceNumberRegex.FindString(text)
+	if match == "" {
+		return 0, fmt.Errorf("no confidence value found in %q", text)
+	}
+	value, err := strconv.ParseFloat(match, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid confidence value %q: %w", match, err)
+	}
+	if value > 1 && value <= 100 {
+		value /= 100
+	}
+	return clamp01(value), nil
+}
+
+// TokenJaccard returns the Jaccard similarity of the lowercase word sets of a and b.
+func TokenJaccard(a, b string) float64 {
+	setA := tokenSet(a)
+	setB := tokenSet(b)
+	if len(setA) == 0 && len(setB) == 0 {
+		return 1
+	}
+	intersection := 0
+	for t := range setA {
+		if setB[t] {
+			intersection++
+		}
+	}
+	union := len(setA) + len(setB) - intersection
+	return float64(intersection) / float64(union)
+}
+
+func tokenSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, f := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !(r == '\'' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r > 127)
+	}) {
+		set[f] = true
+	}
+	return set
+}
+
+func joinNodeContents(nodes []schema.NodeWithScore) string {
+	contents := make([]string, len(nodes))
+	for i, n := range nodes {
+		contents[i] = n.Node.GetContent(schema.MetadataModeLLM)
+	}
+	return strings.Join(contents, "\n\n")
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
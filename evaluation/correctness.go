@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/schema"
 )
 
 // DefaultCorrectnessSystemTemplate is the default system template for correctness evaluation.
@@ -169,6 +170,11 @@ func (e *CorrectnessEvaluator) Evaluate(ctx context.Context, input *EvaluateInpu
 		WithFeedback(reasoning), nil
 }
 
+// EvaluateResponse evaluates a response using its source nodes as contexts.
+func (e *CorrectnessEvaluator) EvaluateResponse(ctx context.Context, query string, response string, sourceNodes []schema.NodeWithScore) (*EvaluationResult, error) {
+	return evaluateResponse(ctx, e, query, response, sourceNodes)
+}
+
 // defaultCorrectnessParser parses the LLM response to extract score and reasoning.
 func defaultCorrectnessParser(response string) (float64, string, error) {
 	lines := strings.Split(strings.TrimSpace(response), "\n")
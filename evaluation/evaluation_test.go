@@ -1,11 +1,19 @@
 package evaluation
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/aqua777/go-llamaindex/callbacks"
 	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -350,6 +358,39 @@ func (s *EvaluationTestSuite) TestCorrectnessEvaluatorCustomThreshold() {
 
 // Test SemanticSimilarityEvaluator
 
+func (s *EvaluationTestSuite) TestGuidelineEvaluatorCreation() {
+	evaluator := NewGuidelineEvaluator(WithGuidelines("Be concise."))
+	s.Equal("guideline", evaluator.Name())
+	s.Equal("Be concise.", evaluator.Guidelines())
+}
+
+func (s *EvaluationTestSuite) TestGuidelineEvaluatorFailing() {
+	mockLLM := NewMockLLM(`Critique: {"passing": false, "feedback": "The response does not give a number."}`)
+	evaluator := NewGuidelineEvaluator(WithGuidelineLLM(mockLLM))
+
+	ctx := context.Background()
+	input := NewEvaluateInput().
+		WithQuery("How many moons does Mars have?").
+		WithResponse("A few.")
+
+	result, err := evaluator.Evaluate(ctx, input)
+	s.NoError(err)
+	s.False(result.IsPassing())
+	s.Equal("The response does not give a number.", result.Feedback)
+}
+
+func (s *EvaluationTestSuite) TestGuidelineEvaluatorInvalidResponse() {
+	evaluator := NewGuidelineEvaluator(WithGuidelineLLM(NewMockLLM("looks fine")))
+
+	ctx := context.Background()
+	input := NewEvaluateInput().WithQuery("q").WithResponse("r")
+
+	result, err := evaluator.Evaluate(ctx, input)
+	s.NoError(err)
+	s.True(result.InvalidResult)
+	s.False(result.IsPassing())
+}
+
 func (s *EvaluationTestSuite) TestSemanticSimilarityEvaluatorCreation() {
 	evaluator := NewSemanticSimilarityEvaluator()
 	s.Equal("semantic_similarity", evaluator.Name())
@@ -494,6 +535,27 @@ func (s *EvaluationTestSuite) TestBatchEvalResultSummary() {
 	s.Equal(0.5, summary["eval2"]["passing_rate"])
 }
 
+func (s *EvaluationTestSuite) TestBatchEvalResultStats() {
+	result := NewBatchEvalResult([]string{"eval1"})
+	result.Results["eval1"] = []*EvaluationResult{
+		NewEvaluationResult().WithScore(0.8).WithPassing(true),
+		NewEvaluationResult().WithScore(0.2).WithPassing(false),
+		NewEvaluationResult().WithInvalid("failed"),
+	}
+
+	stats := result.Stats("eval1")
+	s.Equal(3, stats.Count)
+	s.Equal(1, stats.Invalid)
+	s.Equal(1, stats.Passing)
+	s.InDelta(1.0/3, stats.PassingRate, 1e-9)
+	s.Equal(2, stats.Scored)
+	s.InDelta(0.5, stats.MeanScore, 1e-9)
+	s.Equal(0.2, stats.MinScore)
+	s.Equal(0.8, stats.MaxScore)
+
+	s.Equal(EvaluatorStats{}, result.Stats("missing"))
+}
+
 // Test Similarity Functions
 
 func (s *EvaluationTestSuite) TestCosineSimilarity() {
@@ -611,6 +673,41 @@ func (s *EvaluationTestSuite) TestEvaluatorInterfaceCompliance() {
 	var _ Evaluator = (*AnswerRelevancyEvaluator)(nil)
 	var _ Evaluator = (*CorrectnessEvaluator)(nil)
 	var _ Evaluator = (*SemanticSimilarityEvaluator)(nil)
+	var _ Evaluator = (*PairwiseComparisonEvaluator)(nil)
+	var _ ResponseEvaluator = (*FaithfulnessEvaluator)(nil)
+	var _ ResponseEvaluator = (*RelevancyEvaluator)(nil)
+	var _ ResponseEvaluator = (*ContextRelevancyEvaluator)(nil)
+	var _ ResponseEvaluator = (*AnswerRelevancyEvaluator)(nil)
+	var _ ResponseEvaluator = (*CorrectnessEvaluator)(nil)
+	var _ ResponseEvaluator = (*GuidelineEvaluator)(nil)
+}
+
+// Test response evaluation
+
+func (s *EvaluationTestSuite) TestEvaluateQueryResponse() {
+	ctx := context.Background()
+	node := schema.NewTextNode("The sky appears blue due to Rayleigh scattering")
+	resp := synthesizer.NewResponse("The sky is blue", []schema.NodeWithScore{{Node: *node, Score: 0.9}})
+
+	faithfulness := NewFaithfulnessEvaluator(WithFaithfulnessLLM(NewMockLLM("YES")))
+	result, err := EvaluateQueryResponse(ctx, faithfulness, "Why is the sky blue?", resp)
+	s.Require().NoError(err)
+	s.True(result.IsPassing())
+	s.Equal("Why is the sky blue?", result.Query)
+	s.Equal([]string{node.Text}, result.Contexts)
+
+	relevancy := NewRelevancyEvaluator(WithRelevancyLLM(NewMockLLM("NO")))
+	result, err = relevancy.EvaluateResponse(ctx, "Why is the sky blue?", resp.Response, resp.SourceNodes)
+	s.Require().NoError(err)
+	s.False(result.IsPassing())
+
+	guideline := NewGuidelineEvaluator(WithGuidelineLLM(NewMockLLM(`{"passing": true, "feedback": "ok"}`)))
+	result, err = EvaluateQueryResponse(ctx, guideline, "Why is the sky blue?", resp)
+	s.Require().NoError(err)
+	s.True(result.IsPassing())
+
+	_, err = EvaluateQueryResponse(ctx, NewSemanticSimilarityEvaluator(), "q", resp)
+	s.Error(err, "evaluators needing a reference cannot evaluate responses alone")
 }
 
 // Test AggregateScore
@@ -950,3 +1047,514 @@ func (s *EvaluationTestSuite) TestSemanticSimilarityThreshold() {
 func main() {
 	fmt.Println("Run tests with: go test ./evaluation/...")
 }
+
+func TestConfidenceEstimator(t *testing.T) {
+	ctx := context.Background()
+	sources := []schema.NodeWithScore{
+		{Node: *schema.NewTextNode("Paris is the capital of France."), Score: 0.9},
+		{Node: *schema.NewTextNode("France is in Europe."), Score: 0.7},
+	}
+
+	t.Run("combines all signals", func(t *testing.T) {
+		// Two resampled answers, then the self-assessment reply.
+		mockLLM := NewMockLLM("Paris is the capital", "Lyon", "Confidence: 0.8")
+		estimator := NewConfidenceEstimator(
+			WithConfidenceLLM(mockLLM),
+			WithConfidenceSamples(2),
+		)
+
+		resp := synthesizer.NewResponse("Paris is the capital", sources)
+		confidence, err := estimator.Estimate(ctx, "What is the capital of France?", resp)
+		require.NoError(t, err)
+
+		signals, ok := resp.Metadata[ConfidenceSignalsMetadataKey].(*ConfidenceSignals)
+		require.True(t, ok)
+		require.NotNil(t, signals.Retrieval)
+		require.NotNil(t, signals.Consistency)
+		require.NotNil(t, signals.SelfAssessment)
+
+		assert.InDelta(t, 0.8, *signals.Retrieval, 1e-9)
+		assert.InDelta(t, 0.5, *signals.Consistency, 1e-9)
+		assert.InDelta(t, 0.8, *signals.SelfAssessment, 1e-9)
+		assert.InDelta(t, 0.3*0.8+0.4*0.5+0.3*0.8, confidence, 1e-9)
+		assert.Equal(t, confidence, resp.Metadata[ConfidenceMetadataKey])
+	})
+
+	t.Run("retrieval only with calibration", func(t *testing.T) {
+		estimator := NewConfidenceEstimator(
+			WithConfidenceCalibrator(PlattCalibrator{A: 10, B: -5}),
+		)
+
+		resp := synthesizer.NewResponse("Paris", sources)
+		confidence, err := estimator.Estimate(ctx, "q", resp)
+		require.NoError(t, err)
+		assert.InDelta(t, PlattCalibrator{A: 10, B: -5}.Calibrate(0.8), confidence, 1e-9)
+	})
+
+	t.Run("no signals", func(t *testing.T) {
+		estimator := NewConfidenceEstimator()
+		_, err := estimator.Estimate(ctx, "q", synthesizer.NewResponse("answer", nil))
+		assert.Error(t, err)
+	})
+
+	t.Run("evaluate", func(t *testing.T) {
+		estimator := NewConfidenceEstimator(
+			WithConfidenceLLM(NewMockLLM("Confidence: 90%")),
+			WithConfidenceSamples(0),
+			WithConfidenceThreshold(0.7),
+		)
+		input := NewEvaluateInput().
+			WithQuery("q").
+			WithResponse("answer").
+			WithContexts([]string{"context"})
+
+		result, err := estimator.Evaluate(ctx, input)
+		require.NoError(t, err)
+		assert.InDelta(t, 0.9, result.GetScore(), 1e-9)
+		assert.True(t, result.IsPassing())
+	})
+}
+
+func TestParseConfidence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+		wantErr  bool
+	}{
+		{"Confidence: 0.75", 0.75, false},
+		{"confidence: 85", 0.85, false},
+		{"I'd say 0.4", 0.4, false},
+		{"no number here", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseConfidence(tt.input)
+		if tt.wantErr {
+			assert.Error(t, err, tt.input)
+			continue
+		}
+		require.NoError(t, err, tt.input)
+		assert.InDelta(t, tt.expected, got, 1e-9, tt.input)
+	}
+}
+
+func TestTokenJaccard(t *testing.T) {
+	assert.Equal(t, 1.0, TokenJaccard("Paris, France", "paris france"))
+	assert.Equal(t, 0.0, TokenJaccard("Paris", "Lyon"))
+	assert.InDelta(t, 1.0/3.0, TokenJaccard("a b", "b c"), 1e-9)
+}
+
+// scoreByQuery is an evaluator returning a fixed score per query.
+type scoreByQuery struct {
+	scores map[string]float64
+	calls  int
+}
+
+func (e *scoreByQuery) Name() string { return "score_by_query" }
+
+func (e *scoreByQuery) Evaluate(ctx context.Context, input *EvaluateInput) (*EvaluationResult, error) {
+	e.calls++
+	score, ok := e.scores[input.Query]
+	if !ok {
+		return nil, fmt.Errorf("no score for %q", input.Query)
+	}
+	return NewEvaluationResult().WithScore(score).WithPassing(score >= 0.5), nil
+}
+
+func TestFinetuneDatasetGenerator(t *testing.T) {
+	ctx := context.Background()
+	traces := []TraceRecord{
+		{ID: "t1", Query: "good", Contexts: []string{"ctx a", "ctx b"}, Response: "answer one"},
+		{ID: "t2", Query: "bad", Contexts: []string{"ctx c"}, Response: "answer two"},
+		{ID: "t3", Query: "prescored", Response: "answer three", Scores: map[string]float64{"faithfulness": 0.95}},
+		{ID: "t4", Query: "no answer"},
+		{ID: "t5", Query: "unknown", Response: "answer five"},
+	}
+
+	t.Run("Chat format with evaluator filter", func(t *testing.T) {
+		evaluator := &scoreByQuery{scores: map[string]float64{"good": 0.9, "bad": 0.2}}
+		gen := NewFinetuneDatasetGenerator(WithFinetuneFilter("faithfulness", evaluator, 0.8))
+
+		dataset, err := gen.Generate(ctx, traces)
+		require.NoError(t, err)
+
+		assert.Equal(t, 5, dataset.Total)
+		assert.Equal(t, 1, dataset.Skipped)
+		assert.Equal(t, map[string]int{"faithfulness": 1}, dataset.Rejected)
+		assert.Len(t, dataset.Errors, 1)
+		assert.Equal(t, 3, evaluator.calls, "pre-scored traces are not re-evaluated")
+
+		require.Len(t, dataset.Examples, 2)
+		first := dataset.Examples[0]
+		assert.Equal(t, "t1", first.TraceID)
+		assert.Equal(t, 0.9, first.Scores["faithfulness"])
+		require.Len(t, first.Messages, 3)
+		assert.Equal(t, "system", first.Messages[0].Role)
+		assert.Equal(t, "user", first.Messages[1].Role)
+		assert.Contains(t, first.Messages[1].Content, "ctx a\n\nctx b")
+		assert.Contains(t, first.Messages[1].Content, "Query: good")
+		assert.Equal(t, FinetuneMessage{Role: "assistant", Content: "answer one"}, first.Messages[2])
+		assert.Equal(t, "t3", dataset.Examples[1].TraceID)
+
+		var buf bytes.Buffer
+		require.NoError(t, dataset.WriteJSONL(&buf))
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 2)
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &line))
+		assert.Contains(t, line, "messages")
+		assert.NotContains(t, line, "prompt")
+		assert.NotContains(t, line, "TraceID")
+	})
+
+	t.Run("Completion format", func(t *testing.T) {
+		gen := NewFinetuneDatasetGenerator(
+			WithFinetuneFormat(FinetuneFormatCompletion),
+			WithFinetuneUserTemplate("Q: {query_str}\nC: {context_str}"),
+			WithFinetuneMaxContexts(1),
+		)
+
+		dataset, err := gen.Generate(ctx, traces[:1])
+		require.NoError(t, err)
+		require.Len(t, dataset.Examples, 1)
+		assert.Equal(t, "Q: good\nC: ctx a", dataset.Examples[0].Prompt)
+		assert.Equal(t, "answer one", dataset.Examples[0].Completion)
+		assert.Empty(t, dataset.Examples[0].Messages)
+	})
+
+	t.Run("Require passing", func(t *testing.T) {
+		evaluator := &scoreByQuery{scores: map[string]float64{"good": 0.4}}
+		gen := NewFinetuneDatasetGenerator(
+			WithFinetuneFilter("quality", evaluator, 0),
+			WithFinetuneRequirePassing(true),
+		)
+
+		dataset, err := gen.Generate(ctx, traces[:1])
+		require.NoError(t, err)
+		assert.Empty(t, dataset.Examples)
+		assert.Equal(t, 1, dataset.Rejected["quality"])
+	})
+
+	t.Run("From artifacts", func(t *testing.T) {
+		artifact := &callbacks.Artifact{
+			TraceID:  "trace-1",
+			Query:    "What plan?",
+			Response: "Pro.",
+			Contexts: []callbacks.ArtifactContext{{NodeID: "n1", Text: "Jane is on pro."}},
+		}
+
+		dataset, err := NewFinetuneDatasetGenerator(WithFinetuneSystemPrompt("")).
+			GenerateFromArtifacts(ctx, []*callbacks.Artifact{artifact})
+		require.NoError(t, err)
+		require.Len(t, dataset.Examples, 1)
+		require.Len(t, dataset.Examples[0].Messages, 2)
+		assert.Contains(t, dataset.Examples[0].Messages[0].Content, "Jane is on pro.")
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		_, err := NewFinetuneDatasetGenerator(WithFinetuneFormat("xml")).Generate(ctx, traces)
+		assert.Error(t, err)
+	})
+}
+
+// mapQueryEngine answers queries from a map; unknown queries fail.
+type mapQueryEngine struct {
+	answers map[string]string
+}
+
+func (e *mapQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	answer, ok := e.answers[query]
+	if !ok {
+		return nil, fmt.Errorf("unknown query")
+	}
+	node := schema.NewTextNode("context for " + query)
+	return synthesizer.NewResponse(answer, []schema.NodeWithScore{{Node: *node, Score: 1}}), nil
+}
+
+func TestBatchEvalRunnerEvaluateQueries(t *testing.T) {
+	ctx := context.Background()
+	engine := &mapQueryEngine{answers: map[string]string{"q1": "a1", "q2": "a2"}}
+
+	var progress [][2]int
+	runner := NewBatchEvalRunner(
+		map[string]Evaluator{
+			"faithfulness": NewFaithfulnessEvaluator(WithFaithfulnessLLM(NewMockLLM("YES", "NO"))),
+		},
+		WithBatchWorkers(1),
+		WithBatchProgress(func(completed, total int) {
+			progress = append(progress, [2]int{completed, total})
+		}),
+	)
+
+	result, err := runner.EvaluateQueries(ctx, engine, []string{"q1", "q2", "q3"})
+	require.NoError(t, err)
+
+	results := result.Results["faithfulness"]
+	require.Len(t, results, 3)
+	assert.Equal(t, "a1", results[0].Response)
+	assert.Equal(t, []string{"context for q1"}, results[0].Contexts)
+	assert.True(t, results[0].IsPassing())
+	assert.False(t, results[1].IsPassing())
+	assert.True(t, results[2].InvalidResult)
+	assert.Equal(t, "q3", results[2].Query)
+	require.Len(t, result.Errors, 1)
+	assert.Contains(t, result.Errors[0].Error(), `query "q3" failed`)
+	assert.Equal(t, [][2]int{{1, 3}, {2, 3}, {3, 3}}, progress)
+
+	_, err = runner.EvaluateQueries(ctx, engine, nil)
+	assert.Error(t, err)
+
+	t.Run("responses", func(t *testing.T) {
+		runner := NewBatchEvalRunner(map[string]Evaluator{
+			"faithfulness": NewFaithfulnessEvaluator(WithFaithfulnessLLM(NewMockLLM("YES"))),
+		})
+		resp, err := engine.Query(ctx, "q1")
+		require.NoError(t, err)
+		result, err := runner.EvaluateResponses(ctx, []string{"q1"}, []*synthesizer.Response{resp})
+		require.NoError(t, err)
+		assert.Equal(t, "a1", result.Results["faithfulness"][0].Response)
+
+		_, err = runner.EvaluateResponses(ctx, []string{"q1", "q2"}, []*synthesizer.Response{resp})
+		assert.Error(t, err)
+	})
+}
+
+func TestBatchEvalResultExport(t *testing.T) {
+	result := NewBatchEvalResult([]string{"b", "a"})
+	result.Results["a"] = []*EvaluationResult{
+		NewEvaluationResult().WithQuery("q1").WithResponse("r1").WithScore(4.5).WithPassing(true).WithFeedback("good, detailed"),
+	}
+	result.Results["b"] = []*EvaluationResult{
+		NewEvaluationResult().WithQuery("q1").WithInvalid("timeout"),
+	}
+	result.Errors = append(result.Errors, fmt.Errorf("timeout"))
+
+	var csvBuf bytes.Buffer
+	require.NoError(t, result.WriteCSV(&csvBuf))
+	assert.Equal(t,
+		"evaluator,index,query,response,passing,score,invalid,feedback\n"+
+			"a,0,q1,r1,true,4.5,false,\"good, detailed\"\n"+
+			"b,0,q1,,,,true,timeout\n",
+		csvBuf.String())
+
+	var jsonBuf bytes.Buffer
+	require.NoError(t, result.WriteJSON(&jsonBuf))
+	var decoded struct {
+		Stats   map[string]EvaluatorStats      `json:"stats"`
+		Results map[string][]*EvaluationResult `json:"results"`
+		Errors  []string                       `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(jsonBuf.Bytes(), &decoded))
+	assert.Equal(t, 1.0, decoded.Stats["a"].PassingRate)
+	assert.Equal(t, 1, decoded.Stats["b"].Invalid)
+	assert.Equal(t, "r1", decoded.Results["a"][0].Response)
+	assert.Equal(t, []string{"timeout"}, decoded.Errors)
+
+	path := filepath.Join(t.TempDir(), "results.csv")
+	require.NoError(t, result.SaveCSV(path))
+	jsonPath := filepath.Join(t.TempDir(), "results.json")
+	require.NoError(t, result.SaveJSON(jsonPath))
+}
+
+func TestPairwiseComparisonEvaluator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("consistent verdict", func(t *testing.T) {
+		judge := NewMockLLM("A is more detailed.\n[[A]]", "B is more detailed.\n[[B]]")
+		e := NewPairwiseComparisonEvaluator(WithPairwiseLLM(judge))
+		result, err := e.Compare(ctx, "q", "detailed", "short")
+		require.NoError(t, err)
+		assert.True(t, result.IsPassing())
+		assert.Equal(t, 1.0, result.GetScore())
+		assert.Equal(t, "win", result.Metadata["verdict"])
+		assert.Equal(t, false, result.Metadata["position_bias"])
+		assert.Equal(t, "short", result.Metadata[SecondResponseKey])
+		assert.Equal(t, "A is more detailed.", result.Feedback)
+	})
+
+	t.Run("position bias is a tie", func(t *testing.T) {
+		judge := NewMockLLM("[[A]]", "[[A]]")
+		e := NewPairwiseComparisonEvaluator(WithPairwiseLLM(judge))
+		result, err := e.Compare(ctx, "q", "first", "second")
+		require.NoError(t, err)
+		assert.Nil(t, result.Passing)
+		assert.Equal(t, 0.5, result.GetScore())
+		assert.Equal(t, "tie", result.Metadata["verdict"])
+		assert.Equal(t, true, result.Metadata["position_bias"])
+	})
+
+	t.Run("single judgment", func(t *testing.T) {
+		e := NewPairwiseComparisonEvaluator(WithPairwiseLLM(NewMockLLM("B")), WithEnforceConsensus(false))
+		input := NewEvaluateInput().WithQuery("q").WithResponse("first")
+		input.Extra[SecondResponseKey] = "second"
+		result, err := e.Evaluate(ctx, input)
+		require.NoError(t, err)
+		assert.False(t, result.IsPassing())
+		assert.Equal(t, 0.0, result.GetScore())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		e := NewPairwiseComparisonEvaluator(WithPairwiseLLM(NewMockLLM("no idea")))
+		result, err := e.Compare(ctx, "q", "first", "second")
+		require.NoError(t, err)
+		assert.True(t, result.InvalidResult)
+
+		result, err = e.Compare(ctx, "q", "first", "")
+		require.NoError(t, err)
+		assert.True(t, result.InvalidResult)
+
+		_, err = NewPairwiseComparisonEvaluator().Compare(ctx, "q", "first", "second")
+		assert.Error(t, err)
+	})
+
+	t.Run("query engines", func(t *testing.T) {
+		judge := NewMockLLM("[[A]]", "[[B]]", "[[C]]", "[[C]]", "[[B]]", "[[A]]")
+		e := NewPairwiseComparisonEvaluator(WithPairwiseLLM(judge))
+		first := &mapQueryEngine{answers: map[string]string{"q1": "a", "q2": "b", "q3": "c"}}
+		second := &mapQueryEngine{answers: map[string]string{"q1": "x", "q2": "y", "q3": "z"}}
+
+		comparison, err := e.CompareQueryEngines(ctx, first, second, []string{"q1", "q2", "q3"})
+		require.NoError(t, err)
+		assert.Len(t, comparison.Results, 3)
+		assert.Equal(t, 1, comparison.Wins)
+		assert.Equal(t, 1, comparison.Ties)
+		assert.Equal(t, 1, comparison.Losses)
+		assert.Equal(t, 0.5, comparison.WinRate())
+
+		_, err = e.CompareQueryEngines(ctx, first, second, []string{"unknown"})
+		assert.Error(t, err)
+	})
+}
+
+func TestParsePairwiseVerdict(t *testing.T) {
+	assert.Equal(t, PairwiseWin, parsePairwiseVerdict("Unlike [[B]] suggests, [[A]]"))
+	assert.Equal(t, PairwiseTie, parsePairwiseVerdict("Both fine.\n[[C]]"))
+	assert.Equal(t, PairwiseLoss, parsePairwiseVerdict("Explanation\nB."))
+	assert.Equal(t, PairwiseTie, parsePairwiseVerdict("tie"))
+	assert.Equal(t, PairwiseVerdict(""), parsePairwiseVerdict("unclear"))
+}
+
+// staticRetriever returns nodes with the given IDs for each query.
+type staticRetriever struct {
+	results map[string][]string
+}
+
+func (r *staticRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	ids, ok := r.results[query.QueryString]
+	if !ok {
+		return nil, fmt.Errorf("unknown query %q", query.QueryString)
+	}
+	nodes := make([]schema.NodeWithScore, len(ids))
+	for i, id := range ids {
+		node := schema.NewTextNode(id)
+		node.ID = id
+		nodes[i] = schema.NodeWithScore{Node: *node, Score: 1 / float64(i+1)}
+	}
+	return nodes, nil
+}
+
+func TestRetrievalMetrics(t *testing.T) {
+	expected := []string{"a", "b"}
+	retrieved := []string{"x", "a", "y", "b"}
+
+	assert.Equal(t, 1.0, HitRate{}.Compute(expected, retrieved))
+	assert.Equal(t, 0.0, HitRate{}.Compute(expected, []string{"x"}))
+	assert.Equal(t, 0.5, MRR{}.Compute(expected, retrieved))
+	assert.Equal(t, 0.0, MRR{}.Compute(expected, nil))
+	assert.Equal(t, 0.5, Precision{}.Compute(expected, retrieved))
+	assert.Equal(t, 0.0, Precision{}.Compute(expected, nil))
+
+	dcg := 1/math.Log2(3) + 1/math.Log2(5)
+	idcg := 1 + 1/math.Log2(3)
+	assert.InDelta(t, dcg/idcg, NDCG{}.Compute(expected, retrieved), 1e-9)
+	assert.InDelta(t, 1.0, NDCG{}.Compute(expected, []string{"b", "a"}), 1e-9)
+	assert.Equal(t, 0.0, NDCG{}.Compute(nil, retrieved))
+}
+
+func TestRetrieverEvaluator(t *testing.T) {
+	ctx := context.Background()
+	ret := &staticRetriever{results: map[string][]string{
+		"q1": {"a", "b", "c"},
+		"q2": {"x", "y", "d"},
+		"q3": {"x", "x", "e"},
+	}}
+
+	t.Run("single query", func(t *testing.T) {
+		result, err := NewRetrieverEvaluator(ret).Evaluate(ctx, "q2", []string{"d"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"x", "y", "d"}, result.RetrievedIDs)
+		assert.Equal(t, 1.0, result.Metrics["hit_rate"])
+		assert.InDelta(t, 1.0/3, result.Metrics["mrr"], 1e-9)
+		assert.InDelta(t, 1.0/3, result.Metrics["precision"], 1e-9)
+		assert.Contains(t, result.Metrics, "ndcg")
+	})
+
+	t.Run("top-k and deduplication", func(t *testing.T) {
+		e := NewRetrieverEvaluator(ret, WithRetrievalTopK(2), WithRetrievalMetrics(HitRate{}, MRR{}))
+		result, err := e.Evaluate(ctx, "q3", []string{"e"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"x", "e"}, result.RetrievedIDs)
+		assert.Equal(t, map[string]float64{"hit_rate": 1, "mrr": 0.5}, result.Metrics)
+
+		result, err = e.Evaluate(ctx, "q2", []string{"d"})
+		require.NoError(t, err)
+		assert.Equal(t, 0.0, result.Metrics["hit_rate"], "d is ranked third")
+	})
+
+	t.Run("dataset", func(t *testing.T) {
+		dataset := NewRetrievalDataset()
+		dataset.AddQuery("1", "q1", "a")
+		dataset.AddQuery("2", "q2", "d")
+		dataset.AddQuery("3", "q3", "missing")
+
+		results, err := NewRetrieverEvaluator(ret).EvaluateDataset(ctx, dataset)
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		assert.Equal(t, "q1", results[0].Query)
+
+		mean := MeanRetrievalMetrics(results)
+		assert.InDelta(t, 2.0/3, mean["hit_rate"], 1e-9)
+		assert.InDelta(t, (1+1.0/3)/3, mean["mrr"], 1e-9)
+
+		dataset.AddQuery("4", "unknown", "a")
+		results, err = NewRetrieverEvaluator(ret).EvaluateDataset(ctx, dataset)
+		assert.ErrorContains(t, err, "1 of 4 queries failed")
+		assert.Nil(t, results[3])
+		assert.Len(t, MeanRetrievalMetrics(results), 4)
+	})
+}
+
+func TestRetrievalDatasetGenerator(t *testing.T) {
+	ctx := context.Background()
+	a := schema.NewTextNode("Paris is the capital of France.")
+	a.ID = "a"
+	b := schema.NewTextNode("The Seine flows through Paris.")
+	b.ID = "b"
+	empty := schema.NewTextNode("  ")
+
+	gen := NewRetrievalDatasetGenerator(WithRetrievalDatasetLLM(NewMockLLM(
+		"1. What is the capital of France?\n2) Which country is Paris in?\n3. Extra question?",
+		"Here are the questions:\n\n- Which river flows through Paris?",
+	)))
+	dataset, err := gen.Generate(ctx, []schema.Node{*a, *empty, *b})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"a-q0": "What is the capital of France?",
+		"a-q1": "Which country is Paris in?",
+		"b-q0": "Which river flows through Paris?",
+	}, dataset.Queries)
+	assert.Equal(t, []string{"a"}, dataset.RelevantDocs["a-q1"])
+	assert.Equal(t, []string{"b"}, dataset.RelevantDocs["b-q0"])
+	assert.Len(t, dataset.Corpus, 2)
+	assert.Equal(t, []string{"a-q0", "a-q1", "b-q0"}, dataset.QueryIDs())
+
+	path := filepath.Join(t.TempDir(), "dataset.json")
+	require.NoError(t, dataset.SaveJSON(path))
+	loaded, err := LoadRetrievalDataset(path)
+	require.NoError(t, err)
+	assert.Equal(t, dataset, loaded)
+
+	_, err = NewRetrievalDatasetGenerator().Generate(ctx, []schema.Node{*a})
+	assert.Error(t, err)
+}
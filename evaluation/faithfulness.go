@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/schema"
 )
 
 // DefaultFaithfulnessTemplate is the default prompt template for faithfulness evaluation.
@@ -124,6 +125,11 @@ func (e *FaithfulnessEvaluator) Evaluate(ctx context.Context, input *EvaluateInp
 		WithFeedback(llmResponse), nil
 }
 
+// EvaluateResponse evaluates a response using its source nodes as contexts.
+func (e *FaithfulnessEvaluator) EvaluateResponse(ctx context.Context, query string, response string, sourceNodes []schema.NodeWithScore) (*EvaluationResult, error) {
+	return evaluateResponse(ctx, e, query, response, sourceNodes)
+}
+
 // EvaluateStatements evaluates individual statements for faithfulness.
 // This is useful for more granular evaluation.
 func (e *FaithfulnessEvaluator) EvaluateStatements(ctx context.Context, statements []string, contexts []string) ([]*EvaluationResult, error) {
@@ -0,0 +1,331 @@
+package evaluation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/callbacks"
+)
+
+// DefaultFinetuneSystemPrompt is the system message of generated chat
+// examples.
+const DefaultFinetuneSystemPrompt = "You are a helpful assistant. Answer the question using only the provided context."
+
+// DefaultFinetuneUserTemplate formats the user turn of generated examples.
+// It uses the {context_str} and {query_str} placeholders.
+const DefaultFinetuneUserTemplate = `Context information is below.
+---------------------
+{context_str}
+---------------------
+Given the context information and not prior knowledge, answer the query.
+Query: {query_str}`
+
+// FinetuneFormat selects the layout of generated examples.
+type FinetuneFormat string
+
+const (
+	// FinetuneFormatChat writes OpenAI chat examples:
+	// {"messages": [{"role": "system", ...}, {"role": "user", ...}, {"role": "assistant", ...}]}.
+	FinetuneFormatChat FinetuneFormat = "chat"
+	// FinetuneFormatCompletion writes prompt/completion pairs:
+	// {"prompt": ..., "completion": ...}.
+	FinetuneFormatCompletion FinetuneFormat = "completion"
+)
+
+// TraceRecord is a recorded query, its retrieved contexts and the answer.
+type TraceRecord struct {
+	// ID identifies the trace.
+	ID string `json:"id,omitempty"`
+	// Query is the user query.
+	Query string `json:"query"`
+	// Contexts are the retrieved context strings.
+	Contexts []string `json:"contexts,omitempty"`
+	// Response is the answer that was returned.
+	Response string `json:"response"`
+	// Scores holds scores already computed for this trace, keyed by
+	// evaluator name. They are used instead of re-running the evaluator.
+	Scores map[string]float64 `json:"scores,omitempty"`
+}
+
+// TraceRecordFromArtifact converts an artifact written by
+// callbacks.ArtifactSink into a TraceRecord.
+func TraceRecordFromArtifact(a *callbacks.Artifact) TraceRecord {
+	contexts := make([]string, 0, len(a.Contexts))
+	for _, c := range a.Contexts {
+		contexts = append(contexts, c.Text)
+	}
+	return TraceRecord{
+		ID:       a.TraceID,
+		Query:    a.Query,
+		Contexts: contexts,
+		Response: a.Response,
+	}
+}
+
+// FinetuneMessage is a chat message in a fine-tuning example.
+type FinetuneMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// FinetuneExample is one fine-tuning example. Messages is set for the chat
+// format; Prompt and Completion for the completion format.
+type FinetuneExample struct {
+	Messages   []FinetuneMessage `json:"messages,omitempty"`
+	Prompt     string            `json:"prompt,omitempty"`
+	Completion string            `json:"completion,omitempty"`
+	// TraceID and Scores describe where the example came from. They are
+	// not written to JSONL.
+	TraceID string             `json:"-"`
+	Scores  map[string]float64 `json:"-"`
+}
+
+// FinetuneDataset is the output of a FinetuneDatasetGenerator.
+type FinetuneDataset struct {
+	// Examples are the accepted examples, in input order.
+	Examples []FinetuneExample
+	// Total is the number of traces considered.
+	Total int
+	// Skipped counts traces dropped for a missing query or response.
+	Skipped int
+	// Rejected counts traces filtered out, per evaluator.
+	Rejected map[string]int
+	// Errors holds evaluator failures; the affected traces are dropped.
+	Errors []error
+}
+
+// WriteJSONL writes one example per line.
+func (d *FinetuneDataset) WriteJSONL(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, ex := range d.Examples {
+		if err := enc.Encode(ex); err != nil {
+			return fmt.Errorf("failed to encode example: %w", err)
+		}
+	}
+	return bw.Flush()
+}
+
+// SaveJSONL writes the dataset to a JSONL file.
+func (d *FinetuneDataset) SaveJSONL(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := d.WriteJSONL(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// finetuneFilter keeps traces an evaluator scores at least minScore.
+type finetuneFilter struct {
+	name      string
+	evaluator Evaluator
+	minScore  float64
+}
+
+// FinetuneDatasetGenerator turns recorded RAG traces into an
+// instruction-tuning dataset. Each trace becomes one example whose user
+// turn holds the retrieved context and query and whose assistant turn
+// holds the recorded answer.
+//
+// Evaluator filters keep only traces that score well, so that the dataset
+// teaches the model the answers worth repeating. Scores already attached
+// to a trace are reused; otherwise the evaluator is run.
+type FinetuneDatasetGenerator struct {
+	filters        []finetuneFilter
+	requirePassing bool
+	format         FinetuneFormat
+	systemPrompt   string
+	userTemplate   string
+	maxContexts    int
+}
+
+// FinetuneDatasetOption configures a FinetuneDatasetGenerator.
+type FinetuneDatasetOption func(*FinetuneDatasetGenerator)
+
+// WithFinetuneFilter keeps only traces that evaluator scores at least
+// minScore. The name keys TraceRecord.Scores and the rejection counts.
+func WithFinetuneFilter(name string, evaluator Evaluator, minScore float64) FinetuneDatasetOption {
+	return func(g *FinetuneDatasetGenerator) {
+		g.filters = append(g.filters, finetuneFilter{name: name, evaluator: evaluator, minScore: minScore})
+	}
+}
+
+// WithFinetuneRequirePassing also requires evaluators that report a
+// pass/fail result to pass.
+func WithFinetuneRequirePassing(require bool) FinetuneDatasetOption {
+	return func(g *FinetuneDatasetGenerator) {
+		g.requirePassing = require
+	}
+}
+
+// WithFinetuneFormat sets the example format. Defaults to
+// FinetuneFormatChat.
+func WithFinetuneFormat(format FinetuneFormat) FinetuneDatasetOption {
+	return func(g *FinetuneDatasetGenerator) {
+		g.format = format
+	}
+}
+
+// WithFinetuneSystemPrompt sets the system message of chat examples. An
+// empty prompt omits the system message.
+func WithFinetuneSystemPrompt(prompt string) FinetuneDatasetOption {
+	return func(g *FinetuneDatasetGenerator) {
+		g.systemPrompt = prompt
+	}
+}
+
+// WithFinetuneUserTemplate sets the template for the user turn.
+func WithFinetuneUserTemplate(template string) FinetuneDatasetOption {
+	return func(g *FinetuneDatasetGenerator) {
+		g.userTemplate = template
+	}
+}
+
+// WithFinetuneMaxContexts caps the contexts included per example. Zero
+// includes all.
+func WithFinetuneMaxContexts(n int) FinetuneDatasetOption {
+	return func(g *FinetuneDatasetGenerator) {
+		g.maxContexts = n
+	}
+}
+
+// NewFinetuneDatasetGenerator creates a new FinetuneDatasetGenerator.
+func NewFinetuneDatasetGenerator(opts ...FinetuneDatasetOption) *FinetuneDatasetGenerator {
+	g := &FinetuneDatasetGenerator{
+		format:       FinetuneFormatChat,
+		systemPrompt: DefaultFinetuneSystemPrompt,
+		userTemplate: DefaultFinetuneUserTemplate,
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	return g
+}
+
+// Generate builds a dataset from traces.
+func (g *FinetuneDatasetGenerator) Generate(ctx context.Context, traces []TraceRecord) (*FinetuneDataset, error) {
+	if g.format != FinetuneFormatChat && g.format != FinetuneFormatCompletion {
+		return nil, fmt.Errorf("unknown fine-tune format %q", g.format)
+	}
+
+	dataset := &FinetuneDataset{
+		Examples: []FinetuneExample{},
+		Total:    len(traces),
+		Rejected: make(map[string]int),
+	}
+
+	for _, trace := range traces {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(trace.Query) == "" || strings.TrimSpace(trace.Response) == "" {
+			dataset.Skipped++
+			continue
+		}
+
+		scores, rejectedBy, err := g.score(ctx, trace)
+		if err != nil {
+			dataset.Errors = append(dataset.Errors, fmt.Errorf("trace %s: %w", trace.ID, err))
+			continue
+		}
+		if rejectedBy != "" {
+			dataset.Rejected[rejectedBy]++
+			continue
+		}
+
+		example := g.example(trace)
+		example.TraceID = trace.ID
+		example.Scores = scores
+		dataset.Examples = append(dataset.Examples, example)
+	}
+
+	return dataset, nil
+}
+
+// GenerateFromArtifacts builds a dataset from artifacts written by
+// callbacks.ArtifactSink.
+func (g *FinetuneDatasetGenerator) GenerateFromArtifacts(ctx context.Context, artifacts []*callbacks.Artifact) (*FinetuneDataset, error) {
+	traces := make([]TraceRecord, len(artifacts))
+	for i, a := range artifacts {
+		traces[i] = TraceRecordFromArtifact(a)
+	}
+	return g.Generate(ctx, traces)
+}
+
+// score runs the filters in order and returns the scores and the name of
+// the first filter that rejected the trace, if any.
+func (g *FinetuneDatasetGenerator) score(ctx context.Context, trace TraceRecord) (map[string]float64, string, error) {
+	scores := make(map[string]float64, len(g.filters))
+	for _, f := range g.filters {
+		if score, ok := trace.Scores[f.name]; ok {
+			scores[f.name] = score
+			if score < f.minScore {
+				return scores, f.name, nil
+			}
+			continue
+		}
+
+		input := NewEvaluateInput().
+			WithQuery(trace.Query).
+			WithResponse(trace.Response).
+			WithContexts(trace.Contexts)
+		result, err := f.evaluator.Evaluate(ctx, input)
+		if err != nil {
+			return nil, "", fmt.Errorf("evaluator %s failed: %w", f.name, err)
+		}
+		if result.InvalidResult {
+			return scores, f.name, nil
+		}
+
+		score := result.GetScore()
+		if result.Score == nil && result.Passing != nil {
+			score = 0
+			if result.IsPassing() {
+				score = 1
+			}
+		}
+		scores[f.name] = score
+
+		if score < f.minScore || (g.requirePassing && result.Passing != nil && !result.IsPassing()) {
+			return scores, f.name, nil
+		}
+	}
+	return scores, "", nil
+}
+
+// example formats a trace as a fine-tuning example.
+func (g *FinetuneDatasetGenerator) example(trace TraceRecord) FinetuneExample {
+	contexts := trace.Contexts
+	if g.maxContexts > 0 && len(contexts) > g.maxContexts {
+		contexts = contexts[:g.maxContexts]
+	}
+	user := strings.NewReplacer(
+		"{context_str}", strings.Join(contexts, "\n\n"),
+		"{query_str}", trace.Query,
+	).Replace(g.userTemplate)
+
+	if g.format == FinetuneFormatCompletion {
+		return FinetuneExample{Prompt: user, Completion: trace.Response}
+	}
+
+	var messages []FinetuneMessage
+	if g.systemPrompt != "" {
+		messages = append(messages, FinetuneMessage{Role: "system", Content: g.systemPrompt})
+	}
+	messages = append(messages,
+		FinetuneMessage{Role: "user", Content: user},
+		FinetuneMessage{Role: "assistant", Content: trace.Response},
+	)
+	return FinetuneExample{Messages: messages}
+}
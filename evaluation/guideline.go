@@ -0,0 +1,150 @@
+package evaluation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// DefaultGuidelines are the guidelines used when none are configured.
+const DefaultGuidelines = `The response should fully answer the query.
+The response should avoid being vague or ambiguous.
+The response should be specific and use statistics or numbers when possible.`
+
+// DefaultGuidelineTemplate is the default template for guideline evaluation.
+const DefaultGuidelineTemplate = `Here is the original query:
+Query: {query}
+Critique the following response based on the guidelines below:
+Response: {response}
+Guidelines: {guidelines}
+Now please provide constructive criticism.
+Respond with a JSON object of the form {"passing": true or false, "feedback": "<your criticism>"}.`
+
+// GuidelineEvaluator evaluates whether a response follows a set of
+// user-defined guidelines, and returns constructive feedback that can be
+// used to improve the query or the response.
+type GuidelineEvaluator struct {
+	*BaseEvaluator
+	llm          llm.LLM
+	guidelines   string
+	evalTemplate string
+}
+
+// GuidelineEvaluatorOption configures a GuidelineEvaluator.
+type GuidelineEvaluatorOption func(*GuidelineEvaluator)
+
+// WithGuidelineLLM sets the LLM for evaluation.
+func WithGuidelineLLM(l llm.LLM) GuidelineEvaluatorOption {
+	return func(e *GuidelineEvaluator) {
+		e.llm = l
+	}
+}
+
+// WithGuidelines sets the guidelines the response is judged against.
+func WithGuidelines(guidelines string) GuidelineEvaluatorOption {
+	return func(e *GuidelineEvaluator) {
+		e.guidelines = guidelines
+	}
+}
+
+// WithGuidelineTemplate sets the evaluation template.
+func WithGuidelineTemplate(template string) GuidelineEvaluatorOption {
+	return func(e *GuidelineEvaluator) {
+		e.evalTemplate = template
+	}
+}
+
+// NewGuidelineEvaluator creates a new GuidelineEvaluator.
+func NewGuidelineEvaluator(opts ...GuidelineEvaluatorOption) *GuidelineEvaluator {
+	e := &GuidelineEvaluator{
+		BaseEvaluator: NewBaseEvaluator(WithEvaluatorName("guideline")),
+		guidelines:    DefaultGuidelines,
+		evalTemplate:  DefaultGuidelineTemplate,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Guidelines returns the guidelines the response is judged against.
+func (e *GuidelineEvaluator) Guidelines() string {
+	return e.guidelines
+}
+
+// Evaluate evaluates whether the response follows the guidelines.
+func (e *GuidelineEvaluator) Evaluate(ctx context.Context, input *EvaluateInput) (*EvaluationResult, error) {
+	if input.Query == "" {
+		return NewEvaluationResult().WithInvalid("query must be provided"), nil
+	}
+	if input.Response == "" {
+		return NewEvaluationResult().WithInvalid("response must be provided"), nil
+	}
+	if e.llm == nil {
+		return nil, fmt.Errorf("LLM must be provided for guideline evaluation")
+	}
+
+	prompt := strings.ReplaceAll(e.evalTemplate, "{query}", input.Query)
+	prompt = strings.ReplaceAll(prompt, "{response}", input.Response)
+	prompt = strings.ReplaceAll(prompt, "{guidelines}", e.guidelines)
+
+	llmResponse, err := e.llm.Complete(ctx, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("LLM evaluation failed: %w", err)
+	}
+
+	passing, feedback, err := parseGuidelineResponse(llmResponse)
+	if err != nil {
+		return NewEvaluationResult().
+			WithQuery(input.Query).
+			WithResponse(input.Response).
+			WithInvalid(fmt.Sprintf("failed to parse LLM response: %v", err)).
+			WithFeedback(llmResponse), nil
+	}
+
+	score := 0.0
+	if passing {
+		score = 1.0
+	}
+
+	return NewEvaluationResult().
+		WithQuery(input.Query).
+		WithResponse(input.Response).
+		WithContexts(input.Contexts).
+		WithPassing(passing).
+		WithScore(score).
+		WithFeedback(feedback), nil
+}
+
+// EvaluateResponse evaluates a response using its source nodes as contexts.
+func (e *GuidelineEvaluator) EvaluateResponse(ctx context.Context, query string, response string, sourceNodes []schema.NodeWithScore) (*EvaluationResult, error) {
+	return evaluateResponse(ctx, e, query, response, sourceNodes)
+}
+
+// parseGuidelineResponse extracts the passing flag and feedback from the
+// JSON object in the LLM response.
+func parseGuidelineResponse(response string) (bool, string, error) {
+	start := strings.Index(response, "{")
+	end := strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return false, "", fmt.Errorf("no JSON object in response")
+	}
+
+	var parsed struct {
+		Passing  *bool  `json:"passing"`
+		Feedback string `json:"feedback"`
+	}
+	if err := json.Unmarshal([]byte(response[start:end+1]), &parsed); err != nil {
+		return false, "", err
+	}
+	if parsed.Passing == nil {
+		return false, "", fmt.Errorf("missing passing field")
+	}
+	return *parsed.Passing, strings.TrimSpace(parsed.Feedback), nil
+}
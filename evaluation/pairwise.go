@@ -0,0 +1,307 @@
+package evaluation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/llm"
+)
+
+// SecondResponseKey is the EvaluateInput.Extra key holding the answer that
+// the pairwise evaluator compares input.Response against.
+const SecondResponseKey = "second_response"
+
+// DefaultPairwiseTemplate is the default template for pairwise comparison.
+// It uses the {query}, {reference}, {answer_1} and {answer_2} placeholders.
+const DefaultPairwiseTemplate = `Please act as an impartial judge and evaluate the quality of the responses provided by two AI assistants to the user question displayed below. You should choose the assistant that follows the user's instructions and answers the user's question better. Your evaluation should consider factors such as the helpfulness, relevance, accuracy, depth, creativity, and level of detail of their responses. Begin your evaluation by comparing the two responses and provide a short explanation. Avoid any position biases and ensure that the order in which the responses were presented does not influence your decision. Do not allow the length of the responses to influence your evaluation. Be as objective as possible.
+After providing your explanation, output your final verdict by strictly following this format: "[[A]]" if assistant A is better, "[[B]]" if assistant B is better, and "[[C]]" for a tie.
+
+[User Question]
+{query}
+{reference}
+[The Start of Assistant A's Answer]
+{answer_1}
+[The End of Assistant A's Answer]
+
+[The Start of Assistant B's Answer]
+{answer_2}
+[The End of Assistant B's Answer]`
+
+// PairwiseVerdict is the outcome of a pairwise comparison for the first
+// answer.
+type PairwiseVerdict string
+
+const (
+	// PairwiseWin means the first answer is better.
+	PairwiseWin PairwiseVerdict = "win"
+	// PairwiseLoss means the second answer is better.
+	PairwiseLoss PairwiseVerdict = "loss"
+	// PairwiseTie means neither answer is better, or the judge's verdict
+	// depended on the order the answers were presented in.
+	PairwiseTie PairwiseVerdict = "tie"
+)
+
+// Score returns 1 for a win, 0 for a loss and 0.5 for a tie.
+func (v PairwiseVerdict) Score() float64 {
+	switch v {
+	case PairwiseWin:
+		return 1
+	case PairwiseLoss:
+		return 0
+	default:
+		return 0.5
+	}
+}
+
+// invert returns the verdict from the other answer's point of view.
+func (v PairwiseVerdict) invert() PairwiseVerdict {
+	switch v {
+	case PairwiseWin:
+		return PairwiseLoss
+	case PairwiseLoss:
+		return PairwiseWin
+	default:
+		return v
+	}
+}
+
+// PairwiseComparisonEvaluator asks a judge LLM which of two answers to a
+// query is better. To mitigate position bias, the answers are by default
+// judged in both orders; if the two judgments disagree the result is a tie.
+//
+// The first answer is input.Response and the second is
+// input.Extra[SecondResponseKey]. An optional input.Reference is shown to
+// the judge as the reference answer. The result's score is that of the
+// first answer's verdict, which is stored in Metadata["verdict"].
+type PairwiseComparisonEvaluator struct {
+	*BaseEvaluator
+	llm              llm.LLM
+	evalTemplate     string
+	enforceConsensus bool
+}
+
+// PairwiseEvaluatorOption configures a PairwiseComparisonEvaluator.
+type PairwiseEvaluatorOption func(*PairwiseComparisonEvaluator)
+
+// WithPairwiseLLM sets the judge LLM.
+func WithPairwiseLLM(l llm.LLM) PairwiseEvaluatorOption {
+	return func(e *PairwiseComparisonEvaluator) {
+		e.llm = l
+	}
+}
+
+// WithPairwiseTemplate sets the comparison template.
+func WithPairwiseTemplate(template string) PairwiseEvaluatorOption {
+	return func(e *PairwiseComparisonEvaluator) {
+		e.evalTemplate = template
+	}
+}
+
+// WithEnforceConsensus sets whether the answers are also judged in swapped
+// order. Defaults to true.
+func WithEnforceConsensus(enforce bool) PairwiseEvaluatorOption {
+	return func(e *PairwiseComparisonEvaluator) {
+		e.enforceConsensus = enforce
+	}
+}
+
+// NewPairwiseComparisonEvaluator creates a new PairwiseComparisonEvaluator.
+func NewPairwiseComparisonEvaluator(opts ...PairwiseEvaluatorOption) *PairwiseComparisonEvaluator {
+	e := &PairwiseComparisonEvaluator{
+		BaseEvaluator:    NewBaseEvaluator(WithEvaluatorName("pairwise")),
+		evalTemplate:     DefaultPairwiseTemplate,
+		enforceConsensus: true,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Evaluate compares input.Response against input.Extra[SecondResponseKey].
+func (e *PairwiseComparisonEvaluator) Evaluate(ctx context.Context, input *EvaluateInput) (*EvaluationResult, error) {
+	second, _ := input.Extra[SecondResponseKey].(string)
+	if input.Query == "" {
+		return NewEvaluationResult().WithInvalid("query must be provided"), nil
+	}
+	if input.Response == "" || second == "" {
+		return NewEvaluationResult().WithInvalid("both responses must be provided"), nil
+	}
+	if e.llm == nil {
+		return nil, fmt.Errorf("LLM must be provided for pairwise evaluation")
+	}
+
+	verdict, feedback, err := e.judge(ctx, input.Query, input.Response, second, input.Reference)
+	if err != nil {
+		return nil, err
+	}
+	if verdict == "" {
+		return e.newResult(input, second).
+			WithInvalid("failed to parse verdict from LLM response").
+			WithFeedback(feedback), nil
+	}
+
+	positionBias := false
+	if e.enforceConsensus {
+		swapped, swappedFeedback, err := e.judge(ctx, input.Query, second, input.Response, input.Reference)
+		if err != nil {
+			return nil, err
+		}
+		if swapped == "" {
+			return e.newResult(input, second).
+				WithInvalid("failed to parse verdict from LLM response").
+				WithFeedback(swappedFeedback), nil
+		}
+		if swapped.invert() != verdict {
+			positionBias = true
+			verdict = PairwiseTie
+			feedback = fmt.Sprintf("Verdicts disagreed when the answers were swapped.\nOriginal order: %s\nSwapped order: %s", feedback, swappedFeedback)
+		}
+	}
+
+	result := e.newResult(input, second).
+		WithScore(verdict.Score()).
+		WithFeedback(feedback)
+	if verdict != PairwiseTie {
+		result.WithPassing(verdict == PairwiseWin)
+	}
+	result.Metadata["verdict"] = string(verdict)
+	result.Metadata["position_bias"] = positionBias
+	return result, nil
+}
+
+// Compare compares two answers to query.
+func (e *PairwiseComparisonEvaluator) Compare(ctx context.Context, query, first, second string) (*EvaluationResult, error) {
+	input := NewEvaluateInput().WithQuery(query).WithResponse(first)
+	input.Extra[SecondResponseKey] = second
+	return e.Evaluate(ctx, input)
+}
+
+// newResult returns a result recording the compared answers.
+func (e *PairwiseComparisonEvaluator) newResult(input *EvaluateInput, second string) *EvaluationResult {
+	result := NewEvaluationResult().
+		WithQuery(input.Query).
+		WithResponse(input.Response).
+		WithContexts(input.Contexts).
+		WithReference(input.Reference)
+	result.Metadata[SecondResponseKey] = second
+	return result
+}
+
+// judge asks the LLM to compare answer1 (A) with answer2 (B) and returns
+// the verdict for answer1, or "" if none could be parsed, with the LLM's
+// explanation.
+func (e *PairwiseComparisonEvaluator) judge(ctx context.Context, query, answer1, answer2, reference string) (PairwiseVerdict, string, error) {
+	referenceSection := ""
+	if reference != "" {
+		referenceSection = "\n[The Start of Reference Answer]\n" + reference + "\n[The End of Reference Answer]\n"
+	}
+
+	prompt := strings.ReplaceAll(e.evalTemplate, "{query}", query)
+	prompt = strings.ReplaceAll(prompt, "{reference}", referenceSection)
+	prompt = strings.ReplaceAll(prompt, "{answer_1}", answer1)
+	prompt = strings.ReplaceAll(prompt, "{answer_2}", answer2)
+
+	llmResponse, err := e.llm.Complete(ctx, prompt)
+	if err != nil {
+		return "", "", fmt.Errorf("LLM evaluation failed: %w", err)
+	}
+
+	verdict := parsePairwiseVerdict(llmResponse)
+	feedback := strings.TrimSpace(llmResponse)
+	for _, marker := range []string{"[[A]]", "[[B]]", "[[C]]"} {
+		feedback = strings.TrimSpace(strings.ReplaceAll(feedback, marker, ""))
+	}
+	return verdict, feedback, nil
+}
+
+// parsePairwiseVerdict extracts the verdict for answer A. It looks for the
+// last "[[A]]", "[[B]]" or "[[C]]" marker, and otherwise for a final line
+// consisting only of "A", "B", "C" or "tie".
+func parsePairwiseVerdict(response string) PairwiseVerdict {
+	last, verdict := -1, PairwiseVerdict("")
+	for marker, v := range map[string]PairwiseVerdict{"[[A]]": PairwiseWin, "[[B]]": PairwiseLoss, "[[C]]": PairwiseTie} {
+		if i := strings.LastIndex(response, marker); i > last {
+			last, verdict = i, v
+		}
+	}
+	if verdict != "" {
+		return verdict
+	}
+
+	lines := strings.Split(strings.TrimSpace(response), "\n")
+	switch strings.ToUpper(strings.Trim(strings.TrimSpace(lines[len(lines)-1]), `"'.[]`)) {
+	case "A":
+		return PairwiseWin
+	case "B":
+		return PairwiseLoss
+	case "C", "TIE":
+		return PairwiseTie
+	}
+	return ""
+}
+
+// PairwiseComparison summarizes pairwise verdicts of a first system, e.g.
+// a query engine or prompt configuration, against a second one.
+type PairwiseComparison struct {
+	// Results are the per-query results, in query order.
+	Results []*EvaluationResult
+	// Wins, Losses and Ties count the first system's verdicts.
+	Wins   int
+	Losses int
+	Ties   int
+	// Invalid counts results without a verdict.
+	Invalid int
+}
+
+// WinRate returns the first system's average score over valid results,
+// counting ties as half a win.
+func (c *PairwiseComparison) WinRate() float64 {
+	n := c.Wins + c.Losses + c.Ties
+	if n == 0 {
+		return 0
+	}
+	return (float64(c.Wins) + 0.5*float64(c.Ties)) / float64(n)
+}
+
+// add records a result.
+func (c *PairwiseComparison) add(result *EvaluationResult) {
+	c.Results = append(c.Results, result)
+	verdict, _ := result.Metadata["verdict"].(string)
+	switch {
+	case result.InvalidResult:
+		c.Invalid++
+	case verdict == string(PairwiseWin):
+		c.Wins++
+	case verdict == string(PairwiseLoss):
+		c.Losses++
+	default:
+		c.Ties++
+	}
+}
+
+// CompareQueryEngines runs each query through both engines and compares
+// the answers, first against second.
+func (e *PairwiseComparisonEvaluator) CompareQueryEngines(ctx context.Context, first, second QueryEngine, queries []string) (*PairwiseComparison, error) {
+	comparison := &PairwiseComparison{}
+	for _, query := range queries {
+		firstResponse, err := first.Query(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("first query engine failed on %q: %w", query, err)
+		}
+		secondResponse, err := second.Query(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("second query engine failed on %q: %w", query, err)
+		}
+
+		result, err := e.Compare(ctx, query, firstResponse.Response, secondResponse.Response)
+		if err != nil {
+			return nil, err
+		}
+		comparison.add(result)
+	}
+	return comparison, nil
+}
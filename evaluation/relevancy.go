@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/schema"
 )
 
 // DefaultRelevancyTemplate is the default prompt template for relevancy evaluation.
@@ -121,6 +122,11 @@ func (e *RelevancyEvaluator) Evaluate(ctx context.Context, input *EvaluateInput)
 		WithFeedback(llmResponse), nil
 }
 
+// EvaluateResponse evaluates a response using its source nodes as contexts.
+func (e *RelevancyEvaluator) EvaluateResponse(ctx context.Context, query string, response string, sourceNodes []schema.NodeWithScore) (*EvaluationResult, error) {
+	return evaluateResponse(ctx, e, query, response, sourceNodes)
+}
+
 // ContextRelevancyEvaluator evaluates the relevancy of contexts to a query.
 // Unlike RelevancyEvaluator, this focuses only on context-query relevance.
 type ContextRelevancyEvaluator struct {
@@ -215,6 +221,11 @@ func (e *ContextRelevancyEvaluator) Evaluate(ctx context.Context, input *Evaluat
 		WithFeedback(strings.Join(feedbacks, "\n")), nil
 }
 
+// EvaluateResponse evaluates a response using its source nodes as contexts.
+func (e *ContextRelevancyEvaluator) EvaluateResponse(ctx context.Context, query string, response string, sourceNodes []schema.NodeWithScore) (*EvaluationResult, error) {
+	return evaluateResponse(ctx, e, query, response, sourceNodes)
+}
+
 // AnswerRelevancyEvaluator evaluates if the response answers the query.
 type AnswerRelevancyEvaluator struct {
 	*BaseEvaluator
@@ -300,3 +311,8 @@ func (e *AnswerRelevancyEvaluator) Evaluate(ctx context.Context, input *Evaluate
 		WithScore(score).
 		WithFeedback(llmResponse), nil
 }
+
+// EvaluateResponse evaluates a response using its source nodes as contexts.
+func (e *AnswerRelevancyEvaluator) EvaluateResponse(ctx context.Context, query string, response string, sourceNodes []schema.NodeWithScore) (*EvaluationResult, error) {
+	return evaluateResponse(ctx, e, query, response, sourceNodes)
+}
@@ -0,0 +1,294 @@
+package evaluation
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/rag/retriever"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// RetrievalMetric scores retrieved node IDs against the expected ones.
+type RetrievalMetric interface {
+	// Name returns the metric name used as key in results.
+	Name() string
+	// Compute returns the score of retrievedIDs, in rank order.
+	Compute(expectedIDs, retrievedIDs []string) float64
+}
+
+// HitRate is 1 if any expected ID was retrieved, else 0.
+type HitRate struct{}
+
+// Name returns "hit_rate".
+func (HitRate) Name() string { return "hit_rate" }
+
+// Compute returns the hit rate.
+func (HitRate) Compute(expectedIDs, retrievedIDs []string) float64 {
+	expected := idSet(expectedIDs)
+	for _, id := range retrievedIDs {
+		if expected[id] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// MRR is the reciprocal rank of the first expected ID retrieved, or 0.
+type MRR struct{}
+
+// Name returns "mrr".
+func (MRR) Name() string { return "mrr" }
+
+// Compute returns the reciprocal rank.
+func (MRR) Compute(expectedIDs, retrievedIDs []string) float64 {
+	expected := idSet(expectedIDs)
+	for i, id := range retrievedIDs {
+		if expected[id] {
+			return 1 / float64(i+1)
+		}
+	}
+	return 0
+}
+
+// Precision is the fraction of retrieved IDs that are expected, i.e.
+// precision at the number of results retrieved.
+type Precision struct{}
+
+// Name returns "precision".
+func (Precision) Name() string { return "precision" }
+
+// Compute returns the precision.
+func (Precision) Compute(expectedIDs, retrievedIDs []string) float64 {
+	if len(retrievedIDs) == 0 {
+		return 0
+	}
+	expected := idSet(expectedIDs)
+	hits := 0
+	for _, id := range retrievedIDs {
+		if expected[id] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(retrievedIDs))
+}
+
+// NDCG is the normalized discounted cumulative gain with binary relevance:
+// the DCG of the ranking divided by that of an ideal ranking of the same
+// length.
+type NDCG struct{}
+
+// Name returns "ndcg".
+func (NDCG) Name() string { return "ndcg" }
+
+// Compute returns the NDCG.
+func (NDCG) Compute(expectedIDs, retrievedIDs []string) float64 {
+	expected := idSet(expectedIDs)
+	dcg := 0.0
+	for i, id := range retrievedIDs {
+		if expected[id] {
+			dcg += 1 / math.Log2(float64(i+2))
+		}
+	}
+
+	ideal := len(expected)
+	if len(retrievedIDs) < ideal {
+		ideal = len(retrievedIDs)
+	}
+	idcg := 0.0
+	for i := 0; i < ideal; i++ {
+		idcg += 1 / math.Log2(float64(i+2))
+	}
+	if idcg == 0 {
+		return 0
+	}
+	return dcg / idcg
+}
+
+func idSet(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// DefaultRetrievalMetrics returns hit rate, MRR, precision and NDCG.
+func DefaultRetrievalMetrics() []RetrievalMetric {
+	return []RetrievalMetric{HitRate{}, MRR{}, Precision{}, NDCG{}}
+}
+
+// RetrievalEvalResult is the evaluation of one query.
+type RetrievalEvalResult struct {
+	// Query is the evaluated query.
+	Query string
+	// ExpectedIDs are the IDs that should be retrieved.
+	ExpectedIDs []string
+	// RetrievedIDs are the retrieved IDs, in rank order.
+	RetrievedIDs []string
+	// Metrics maps metric names to scores.
+	Metrics map[string]float64
+}
+
+// RetrieverEvaluator measures how well a retriever finds the expected
+// nodes for labelled queries.
+type RetrieverEvaluator struct {
+	retriever retriever.Retriever
+	metrics   []RetrievalMetric
+	topK      int
+	workers   int
+	idFunc    func(schema.NodeWithScore) string
+}
+
+// RetrieverEvaluatorOption configures a RetrieverEvaluator.
+type RetrieverEvaluatorOption func(*RetrieverEvaluator)
+
+// WithRetrievalMetrics sets the metrics to compute. Defaults to
+// DefaultRetrievalMetrics.
+func WithRetrievalMetrics(metrics ...RetrievalMetric) RetrieverEvaluatorOption {
+	return func(e *RetrieverEvaluator) {
+		e.metrics = metrics
+	}
+}
+
+// WithRetrievalTopK only scores the first k retrieved nodes, so that the
+// metrics are computed at k. Zero scores all of them.
+func WithRetrievalTopK(k int) RetrieverEvaluatorOption {
+	return func(e *RetrieverEvaluator) {
+		e.topK = k
+	}
+}
+
+// WithRetrievalWorkers sets the number of queries evaluated concurrently
+// by EvaluateDataset. Defaults to 2.
+func WithRetrievalWorkers(workers int) RetrieverEvaluatorOption {
+	return func(e *RetrieverEvaluator) {
+		e.workers = workers
+	}
+}
+
+// WithRetrievalIDFunc sets how a retrieved node is identified, e.g. by its
+// source document ID. Defaults to the node ID. Repeated IDs are counted
+// once, at their best rank.
+func WithRetrievalIDFunc(fn func(schema.NodeWithScore) string) RetrieverEvaluatorOption {
+	return func(e *RetrieverEvaluator) {
+		e.idFunc = fn
+	}
+}
+
+// NewRetrieverEvaluator creates a new RetrieverEvaluator.
+func NewRetrieverEvaluator(ret retriever.Retriever, opts ...RetrieverEvaluatorOption) *RetrieverEvaluator {
+	e := &RetrieverEvaluator{
+		retriever: ret,
+		metrics:   DefaultRetrievalMetrics(),
+		workers:   2,
+		idFunc:    func(n schema.NodeWithScore) string { return n.Node.ID },
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Evaluate retrieves for query and scores the results against expectedIDs.
+func (e *RetrieverEvaluator) Evaluate(ctx context.Context, query string, expectedIDs []string) (*RetrievalEvalResult, error) {
+	nodes, err := e.retriever.Retrieve(ctx, schema.QueryBundle{QueryString: query})
+	if err != nil {
+		return nil, fmt.Errorf("retrieval failed for query %q: %w", query, err)
+	}
+
+	var retrievedIDs []string
+	seen := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		id := e.idFunc(n)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		retrievedIDs = append(retrievedIDs, id)
+	}
+	if e.topK > 0 && len(retrievedIDs) > e.topK {
+		retrievedIDs = retrievedIDs[:e.topK]
+	}
+
+	result := &RetrievalEvalResult{
+		Query:        query,
+		ExpectedIDs:  expectedIDs,
+		RetrievedIDs: retrievedIDs,
+		Metrics:      make(map[string]float64, len(e.metrics)),
+	}
+	for _, m := range e.metrics {
+		result.Metrics[m.Name()] = m.Compute(expectedIDs, retrievedIDs)
+	}
+	return result, nil
+}
+
+// EvaluateDataset evaluates every query of dataset concurrently. Results
+// are in the order of dataset.QueryIDs; queries that fail are left nil
+// and reported in the returned error.
+func (e *RetrieverEvaluator) EvaluateDataset(ctx context.Context, dataset *RetrievalDataset) ([]*RetrievalEvalResult, error) {
+	queryIDs := dataset.QueryIDs()
+	results := make([]*RetrievalEvalResult, len(queryIDs))
+	errs := make([]error, len(queryIDs))
+
+	workers := e.workers
+	if workers < 1 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, qid := range queryIDs {
+		wg.Add(1)
+		go func(i int, qid string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i], errs[i] = e.Evaluate(ctx, dataset.Queries[qid], dataset.RelevantDocs[qid])
+		}(i, qid)
+	}
+	wg.Wait()
+
+	var failed []error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Errorf("query %s: %w", queryIDs[i], err))
+		}
+	}
+	if len(failed) > 0 {
+		return results, fmt.Errorf("%d of %d queries failed: %w", len(failed), len(queryIDs), failed[0])
+	}
+	return results, nil
+}
+
+// MeanRetrievalMetrics averages each metric over the non-nil results.
+func MeanRetrievalMetrics(results []*RetrievalEvalResult) map[string]float64 {
+	sums := make(map[string]float64)
+	count := 0
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		count++
+		for name, v := range r.Metrics {
+			sums[name] += v
+		}
+	}
+	if count == 0 {
+		return sums
+	}
+	for name := range sums {
+		sums[name] /= float64(count)
+	}
+	return sums
+}
+
+// Ensure the metrics implement RetrievalMetric.
+var (
+	_ RetrievalMetric = HitRate{}
+	_ RetrievalMetric = MRR{}
+	_ RetrievalMetric = Precision{}
+	_ RetrievalMetric = NDCG{}
+)
@@ -0,0 +1,188 @@
+package evaluation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// DefaultQAGenerateTemplate is the default template for generating
+// questions from a chunk. It uses the {context_str} and
+// {num_questions_per_chunk} placeholders.
+const DefaultQAGenerateTemplate = `Context information is below.
+
+---------------------
+{context_str}
+---------------------
+
+Given the context information and not prior knowledge, generate only questions based on the below query.
+
+You are a Teacher/Professor. Your task is to setup {num_questions_per_chunk} questions for an upcoming quiz/examination. The questions should be diverse in nature across the document. Restrict the questions to the context information provided.
+Write one question per line.`
+
+// questionPrefixPattern matches the numbering or bullet an LLM puts in
+// front of each generated question.
+var questionPrefixPattern = regexp.MustCompile(`^\s*(?:(?:question\s*)?\d+\s*[.):]|[-*•])\s*`)
+
+// RetrievalDataset is a set of labelled queries for retrieval evaluation.
+// Its JSON layout matches LlamaIndex's EmbeddingQAFinetuneDataset.
+type RetrievalDataset struct {
+	// Queries maps query IDs to query text.
+	Queries map[string]string `json:"queries"`
+	// Corpus maps node IDs to node text.
+	Corpus map[string]string `json:"corpus"`
+	// RelevantDocs maps query IDs to the IDs of the nodes that answer them.
+	RelevantDocs map[string][]string `json:"relevant_docs"`
+}
+
+// NewRetrievalDataset creates an empty RetrievalDataset.
+func NewRetrievalDataset() *RetrievalDataset {
+	return &RetrievalDataset{
+		Queries:      make(map[string]string),
+		Corpus:       make(map[string]string),
+		RelevantDocs: make(map[string][]string),
+	}
+}
+
+// AddQuery adds a labelled query.
+func (d *RetrievalDataset) AddQuery(id, query string, relevantIDs ...string) {
+	d.Queries[id] = query
+	d.RelevantDocs[id] = relevantIDs
+}
+
+// QueryIDs returns the query IDs in sorted order.
+func (d *RetrievalDataset) QueryIDs() []string {
+	ids := make([]string, 0, len(d.Queries))
+	for id := range d.Queries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// SaveJSON writes the dataset to a JSON file.
+func (d *RetrievalDataset) SaveJSON(path string) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode retrieval dataset: %w", err)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LoadRetrievalDataset reads a dataset written by SaveJSON.
+func LoadRetrievalDataset(path string) (*RetrievalDataset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	d := NewRetrievalDataset()
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, fmt.Errorf("failed to decode retrieval dataset: %w", err)
+	}
+	return d, nil
+}
+
+// RetrievalDatasetGenerator uses an LLM to write questions answered by
+// each node, producing a RetrievalDataset for benchmarking retrievers over
+// the same nodes, e.g. those in an index's docstore.
+type RetrievalDatasetGenerator struct {
+	llm                  llm.LLM
+	numQuestionsPerChunk int
+	template             string
+}
+
+// RetrievalDatasetGeneratorOption configures a RetrievalDatasetGenerator.
+type RetrievalDatasetGeneratorOption func(*RetrievalDatasetGenerator)
+
+// WithRetrievalDatasetLLM sets the LLM that writes the questions.
+func WithRetrievalDatasetLLM(l llm.LLM) RetrievalDatasetGeneratorOption {
+	return func(g *RetrievalDatasetGenerator) {
+		g.llm = l
+	}
+}
+
+// WithQuestionsPerChunk sets the number of questions per node. Defaults
+// to 2.
+func WithQuestionsPerChunk(n int) RetrievalDatasetGeneratorOption {
+	return func(g *RetrievalDatasetGenerator) {
+		g.numQuestionsPerChunk = n
+	}
+}
+
+// WithQAGenerateTemplate sets the question generation template.
+func WithQAGenerateTemplate(template string) RetrievalDatasetGeneratorOption {
+	return func(g *RetrievalDatasetGenerator) {
+		g.template = template
+	}
+}
+
+// NewRetrievalDatasetGenerator creates a new RetrievalDatasetGenerator.
+func NewRetrievalDatasetGenerator(opts ...RetrievalDatasetGeneratorOption) *RetrievalDatasetGenerator {
+	g := &RetrievalDatasetGenerator{
+		numQuestionsPerChunk: 2,
+		template:             DefaultQAGenerateTemplate,
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	return g
+}
+
+// Generate writes questions for each node. Each question is labelled with
+// the node it was generated from; query IDs are "<node ID>-q<n>". Nodes
+// without text are skipped.
+func (g *RetrievalDatasetGenerator) Generate(ctx context.Context, nodes []schema.Node) (*RetrievalDataset, error) {
+	if g.llm == nil {
+		return nil, fmt.Errorf("LLM must be provided for question generation")
+	}
+
+	dataset := NewRetrievalDataset()
+	for _, node := range nodes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		text := node.GetContent(schema.MetadataModeNone)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		prompt := strings.ReplaceAll(g.template, "{context_str}", text)
+		prompt = strings.ReplaceAll(prompt, "{num_questions_per_chunk}", fmt.Sprintf("%d", g.numQuestionsPerChunk))
+		response, err := g.llm.Complete(ctx, prompt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate questions for node %s: %w", node.ID, err)
+		}
+
+		dataset.Corpus[node.ID] = text
+		for i, question := range parseQuestions(response, g.numQuestionsPerChunk) {
+			dataset.AddQuery(fmt.Sprintf("%s-q%d", node.ID, i), question, node.ID)
+		}
+	}
+
+	return dataset, nil
+}
+
+// parseQuestions returns up to n non-empty lines of response, without
+// numbering. Header lines ending with a colon are skipped.
+func parseQuestions(response string, n int) []string {
+	var questions []string
+	for _, line := range strings.Split(response, "\n") {
+		if len(questions) >= n {
+			break
+		}
+		q := strings.TrimSpace(questionPrefixPattern.ReplaceAllString(line, ""))
+		if q != "" && !strings.HasSuffix(q, ":") {
+			questions = append(questions, q)
+		}
+	}
+	return questions
+}
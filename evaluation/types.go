@@ -3,7 +3,9 @@ package evaluation
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
 	"github.com/aqua777/go-llamaindex/schema"
 )
 
@@ -196,23 +198,45 @@ func (e *BaseEvaluator) Name() string {
 	return e.name
 }
 
-// EvaluateResponse evaluates a response with source nodes.
-// This is a helper that extracts contexts from source nodes.
-// Concrete implementations should override the Evaluate method.
+// EvaluateResponse reports that the evaluator cannot evaluate responses
+// from source nodes alone. Evaluators that can override it.
 func (e *BaseEvaluator) EvaluateResponse(ctx context.Context, query string, response string, sourceNodes []schema.NodeWithScore) (*EvaluationResult, error) {
-	// Extract contexts from source nodes
-	contexts := make([]string, len(sourceNodes))
-	for i, node := range sourceNodes {
-		contexts[i] = node.Node.GetContent(schema.MetadataModeNone)
+	return nil, fmt.Errorf("evaluator %s does not support response evaluation", e.name)
+}
+
+// ContextsFromNodes returns the text of source nodes as evaluation contexts.
+func ContextsFromNodes(nodes []schema.NodeWithScore) []string {
+	contexts := make([]string, len(nodes))
+	for i, n := range nodes {
+		contexts[i] = n.Node.GetContent(schema.MetadataModeNone)
 	}
+	return contexts
+}
 
-	_ = NewEvaluateInput().
+// evaluateResponse runs evaluator on a response, using the source nodes as
+// contexts.
+func evaluateResponse(ctx context.Context, evaluator Evaluator, query, response string, sourceNodes []schema.NodeWithScore) (*EvaluationResult, error) {
+	input := NewEvaluateInput().
 		WithQuery(query).
 		WithResponse(response).
-		WithContexts(contexts)
+		WithContexts(ContextsFromNodes(sourceNodes))
+	return evaluator.Evaluate(ctx, input)
+}
+
+// EvaluateResponse evaluates a response with evaluator against the given
+// source nodes. It uses the evaluator's own EvaluateResponse when it is a
+// ResponseEvaluator, and otherwise passes the nodes' text as contexts.
+func EvaluateResponse(ctx context.Context, evaluator Evaluator, query, response string, sourceNodes []schema.NodeWithScore) (*EvaluationResult, error) {
+	if re, ok := evaluator.(ResponseEvaluator); ok {
+		return re.EvaluateResponse(ctx, query, response, sourceNodes)
+	}
+	return evaluateResponse(ctx, evaluator, query, response, sourceNodes)
+}
 
-	// This should be overridden by concrete implementations
-	return nil, nil
+// EvaluateQueryResponse evaluates a query engine response with evaluator,
+// using its source nodes as contexts.
+func EvaluateQueryResponse(ctx context.Context, evaluator Evaluator, query string, response *synthesizer.Response) (*EvaluationResult, error) {
+	return EvaluateResponse(ctx, evaluator, query, response.Response, response.SourceNodes)
 }
 
 // EvaluatorRegistry holds registered evaluators.
@@ -14,6 +14,7 @@ import (
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/rag/retriever"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
 	"github.com/aqua777/go-llamaindex/tools"
 )
 
@@ -408,10 +409,7 @@ func getFinanceDocuments() []schema.Document {
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
 
 // Unused but kept for potential future use
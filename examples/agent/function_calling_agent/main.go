@@ -16,6 +16,7 @@ import (
 	"github.com/aqua777/go-llamaindex/agent"
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/memory"
+	"github.com/aqua777/go-llamaindex/textutil"
 	"github.com/aqua777/go-llamaindex/tools"
 )
 
@@ -352,8 +353,5 @@ func makeHTTPRequest(url string) (string, error) {
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/aqua777/go-llamaindex/agent"
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/memory"
+	"github.com/aqua777/go-llamaindex/textutil"
 	"github.com/aqua777/go-llamaindex/tools"
 )
 
@@ -297,8 +298,5 @@ func parseNumber(s string) (float64, error) {
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/rag/synthesizer"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
 	"github.com/aqua777/go-llamaindex/tools"
 )
 
@@ -329,8 +330,5 @@ func parseFloat(s string) float64 {
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
@@ -11,7 +11,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aqua777/go-llamaindex/agent"
 	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/textutil"
 	"github.com/aqua777/go-llamaindex/tools"
 	"github.com/aqua777/go-llamaindex/workflow"
 )
@@ -372,23 +374,16 @@ func (bw *BranchingWorkflow) Run(ctx context.Context, query string) (*AgentWorkf
 }
 
 func (bw *BranchingWorkflow) handleMathBranch(ctx context.Context, query string) (*AgentWorkflowResult, error) {
-	// Use calculator tool
-	for _, t := range bw.tools {
-		if t.Metadata().Name == "calculator" {
-			// Extract numbers and operation from query
-			output, err := t.Call(ctx, query)
-			if err != nil {
-				return &AgentWorkflowResult{
-					Response: fmt.Sprintf("Math error: %v", err),
-					Branch:   "math",
-				}, nil
-			}
-			return &AgentWorkflowResult{
-				Response:  output.Content,
-				ToolCalls: []string{"calculator"},
-				Branch:    "math",
-			}, nil
-		}
+	// Program of thought: the LLM writes a small program that the code
+	// interpreter runs, so the arithmetic is never done by the LLM itself
+	pot := agent.NewProgramOfThought(bw.llm)
+	result, err := pot.Solve(ctx, query)
+	if err == nil {
+		return &AgentWorkflowResult{
+			Response:  result.Answer,
+			ToolCalls: []string{result.Output.ToolName},
+			Branch:    "math",
+		}, nil
 	}
 
 	// Fallback to LLM
@@ -490,15 +485,8 @@ func parseToolCall(response string) (string, string) {
 
 // createAgentTools creates tools for the workflow agent.
 func createAgentTools() []tools.Tool {
-	// Calculator tool
-	calcTool, _ := tools.NewFunctionToolFromDefaults(
-		func(input string) (string, error) {
-			// Simple expression evaluation
-			return evaluateExpression(input), nil
-		},
-		"calculator",
-		"Perform mathematical calculations. Input should be a math expression.",
-	)
+	// Calculator tool: runs programs in a restricted expression language
+	calcTool := tools.NewCodeInterpreterTool(tools.WithCodeInterpreterToolName("calculator"))
 
 	// Weather tool
 	weatherTool, _ := tools.NewFunctionToolFromDefaults(
@@ -546,47 +534,7 @@ func createAgentTools() []tools.Tool {
 	return []tools.Tool{calcTool, weatherTool, timeTool, sqrtTool}
 }
 
-// evaluateExpression evaluates a simple math expression.
-func evaluateExpression(expr string) string {
-	expr = strings.TrimSpace(expr)
-
-	// Handle simple operations
-	ops := []struct {
-		op   string
-		fn   func(a, b float64) float64
-	}{
-		{"*", func(a, b float64) float64 { return a * b }},
-		{"/", func(a, b float64) float64 { if b != 0 { return a / b }; return 0 }},
-		{"+", func(a, b float64) float64 { return a + b }},
-		{"-", func(a, b float64) float64 { return a - b }},
-	}
-
-	for _, op := range ops {
-		if strings.Contains(expr, op.op) {
-			parts := strings.SplitN(expr, op.op, 2)
-			if len(parts) == 2 {
-				a := parseFloat(parts[0])
-				b := parseFloat(parts[1])
-				result := op.fn(a, b)
-				return fmt.Sprintf("%.2f", result)
-			}
-		}
-	}
-
-	return "Could not evaluate: " + expr
-}
-
-func parseFloat(s string) float64 {
-	s = strings.TrimSpace(s)
-	var f float64
-	fmt.Sscanf(s, "%f", &f)
-	return f
-}
-
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
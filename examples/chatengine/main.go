@@ -13,6 +13,7 @@ import (
 	"github.com/aqua777/go-llamaindex/memory"
 	"github.com/aqua777/go-llamaindex/rag/retriever"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -301,8 +302,5 @@ func getKnowledgeBase() []schema.NodeWithScore {
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
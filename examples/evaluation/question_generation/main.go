@@ -11,6 +11,7 @@ import (
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/questiongen"
 	"github.com/aqua777/go-llamaindex/selector"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -263,8 +264,5 @@ Generate sub-questions in JSON format:
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
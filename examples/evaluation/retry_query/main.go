@@ -15,6 +15,7 @@ import (
 	"github.com/aqua777/go-llamaindex/rag/queryengine"
 	"github.com/aqua777/go-llamaindex/rag/synthesizer"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -364,8 +365,5 @@ var _ queryengine.QueryEngine = (*RateLimitedQueryEngine)(nil)
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
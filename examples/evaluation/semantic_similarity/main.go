@@ -10,6 +10,7 @@ import (
 
 	"github.com/aqua777/go-llamaindex/embedding"
 	"github.com/aqua777/go-llamaindex/evaluation"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -237,8 +238,5 @@ func main() {
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
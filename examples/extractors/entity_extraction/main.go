@@ -10,6 +10,7 @@ import (
 	"github.com/aqua777/go-llamaindex/extractors"
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 // EntityType represents different types of entities to extract.
@@ -338,8 +339,5 @@ Environmental Summary:`
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
 	s = strings.ReplaceAll(s, "\n", " ")
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
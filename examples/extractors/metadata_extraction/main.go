@@ -11,6 +11,7 @@ import (
 	"github.com/aqua777/go-llamaindex/extractors"
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -309,10 +310,7 @@ Entities (comma-separated):`
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
 	s = strings.ReplaceAll(s, "\n", " ")
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
 
 // getKeys returns the keys of a map.
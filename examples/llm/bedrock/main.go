@@ -11,6 +11,7 @@ import (
 
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/llm/bedrock"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -470,10 +471,7 @@ func main() {
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
 	s = strings.ReplaceAll(s, "\n", " ")
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
 
 func min(a, b int) int {
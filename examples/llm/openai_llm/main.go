@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -277,8 +278,5 @@ func main() {
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
 	s = strings.ReplaceAll(s, "\n", " ")
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/memory"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -268,8 +269,5 @@ func main() {
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/memory"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -235,10 +236,7 @@ Keep the summary concise but informative.`
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
 
 // indent adds indentation to each line of a string.
@@ -11,6 +11,7 @@ import (
 
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/memory"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -631,10 +632,7 @@ func (m *ConversationTurnMemory) Reset(ctx context.Context) error {
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
 
 // Verify interface implementations
@@ -9,6 +9,7 @@ import (
 
 	"github.com/aqua777/go-llamaindex/postprocessor"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -199,10 +200,7 @@ func printScoreBar(nodes []schema.NodeWithScore) {
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
 
 // TopKPostprocessor is a simple top-k filter.
@@ -10,6 +10,7 @@ import (
 
 	"github.com/aqua777/go-llamaindex/postprocessor"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -253,8 +254,5 @@ func mustParseDate(dateStr string) time.Time {
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
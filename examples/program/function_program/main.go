@@ -11,6 +11,7 @@ import (
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/program"
 	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 // Movie represents a movie with structured fields.
@@ -324,8 +325,5 @@ However, the price increase is disappointing for many consumers.`
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
 	s = strings.ReplaceAll(s, "\n", " ")
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
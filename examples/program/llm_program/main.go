@@ -11,6 +11,7 @@ import (
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/program"
 	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 // Recipe represents a cooking recipe.
@@ -335,8 +336,5 @@ Each idea should have a title, description, and difficulty level.`,
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
 	s = strings.ReplaceAll(s, "\n", " ")
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
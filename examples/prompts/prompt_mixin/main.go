@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 // QueryEngine is a sample component that uses prompts.
@@ -292,8 +293,5 @@ Answer:`,
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
 	s = strings.ReplaceAll(s, "\n", " ")
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
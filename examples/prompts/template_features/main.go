@@ -8,6 +8,7 @@ import (
 
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -311,8 +312,5 @@ Please provide a {response_style} answer.`,
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
 	s = strings.ReplaceAll(s, "\n", " ")
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
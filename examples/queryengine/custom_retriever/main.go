@@ -15,6 +15,7 @@ import (
 	"github.com/aqua777/go-llamaindex/rag/retriever"
 	"github.com/aqua777/go-llamaindex/rag/synthesizer"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -147,7 +148,8 @@ func testRetriever(ctx context.Context, ret retriever.Retriever, query string, l
 // Custom Retriever 1: BM25-Style Retriever
 // ============================================================================
 
-// BM25Retriever implements BM25-style term frequency scoring.
+// BM25Retriever implements BM25-style term frequency scoring. It shows how
+// to write a retriever; for real workloads use retriever.BM25Retriever.
 type BM25Retriever struct {
 	*retriever.BaseRetriever
 	documents []schema.Document
@@ -620,8 +622,5 @@ func getSampleDocuments() []schema.Document {
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
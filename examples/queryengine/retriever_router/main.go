@@ -13,7 +13,7 @@ import (
 	"github.com/aqua777/go-llamaindex/rag/retriever"
 	"github.com/aqua777/go-llamaindex/rag/synthesizer"
 	"github.com/aqua777/go-llamaindex/schema"
-	"github.com/aqua777/go-llamaindex/selector"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -61,7 +61,7 @@ func main() {
 	fmt.Println("=== Router Retriever with LLM Selector ===")
 	fmt.Println(separator)
 
-	llmSelector := NewLLMRetrieverSelector(llmInstance)
+	llmSelector := retriever.NewLLMSingleSelector(llmInstance)
 
 	routerRetriever := retriever.NewRouterRetriever(
 		retrieverTools,
@@ -218,51 +218,6 @@ func (m *MockRetriever) Retrieve(ctx context.Context, query schema.QueryBundle)
 	return results, nil
 }
 
-// LLMRetrieverSelector uses an LLM to select the best retriever.
-type LLMRetrieverSelector struct {
-	llm      llm.LLM
-	selector selector.Selector
-}
-
-// NewLLMRetrieverSelector creates a new LLMRetrieverSelector.
-func NewLLMRetrieverSelector(llmModel llm.LLM) *LLMRetrieverSelector {
-	return &LLMRetrieverSelector{
-		llm:      llmModel,
-		selector: selector.NewLLMSingleSelector(llmModel),
-	}
-}
-
-// Select chooses the best retriever for the query.
-func (s *LLMRetrieverSelector) Select(ctx context.Context, tools []*retriever.RetrieverTool, query schema.QueryBundle) (*retriever.SelectorResult, error) {
-	// Convert retriever tools to selector metadata
-	choices := make([]selector.ToolMetadata, len(tools))
-	for i, tool := range tools {
-		choices[i] = selector.ToolMetadata{
-			Name:        tool.Name,
-			Description: tool.Description,
-		}
-	}
-
-	// Use the selector
-	result, err := s.selector.Select(ctx, choices, query.QueryString)
-	if err != nil {
-		return nil, err
-	}
-
-	// Convert to retriever selector result
-	indices := make([]int, len(result.Selections))
-	reasons := make([]string, len(result.Selections))
-	for i, sel := range result.Selections {
-		indices[i] = sel.Index
-		reasons[i] = sel.Reason
-	}
-
-	return &retriever.SelectorResult{
-		Indices: indices,
-		Reasons: reasons,
-	}, nil
-}
-
 // Document collections
 
 func getScienceDocuments() []schema.Document {
@@ -297,8 +252,5 @@ func getTechnologyDocuments() []schema.Document {
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
@@ -16,6 +16,7 @@ import (
 	"github.com/aqua777/go-llamaindex/schema"
 	"github.com/aqua777/go-llamaindex/settings"
 	"github.com/aqua777/go-llamaindex/textsplitter"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -205,8 +206,5 @@ Retrieval-Augmented Generation (RAG) combines the power of large language models
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
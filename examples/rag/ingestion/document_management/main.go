@@ -12,6 +12,7 @@ import (
 	"github.com/aqua777/go-llamaindex/ingestion"
 	"github.com/aqua777/go-llamaindex/schema"
 	"github.com/aqua777/go-llamaindex/textsplitter"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 // SimpleDocStore is a simple in-memory document store for demonstration.
@@ -505,8 +506,5 @@ func copyMetadata(m map[string]interface{}) map[string]interface{} {
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
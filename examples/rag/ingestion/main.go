@@ -12,6 +12,7 @@ import (
 	"github.com/aqua777/go-llamaindex/rag/reader"
 	"github.com/aqua777/go-llamaindex/schema"
 	"github.com/aqua777/go-llamaindex/textsplitter"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -267,10 +268,7 @@ Vector stores typically support multiple similarity metrics:
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
 
 // countNodesWithEmbeddings counts nodes that have embeddings.
@@ -12,6 +12,7 @@ import (
 	"github.com/aqua777/go-llamaindex/program"
 	"github.com/aqua777/go-llamaindex/prompts"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 // Summary represents a structured summary output (Go equivalent of Pydantic model).
@@ -425,8 +426,5 @@ func printJSON(v interface{}) {
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
 	s = strings.ReplaceAll(s, "\n", " ")
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
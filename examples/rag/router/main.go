@@ -13,6 +13,7 @@ import (
 	"github.com/aqua777/go-llamaindex/rag/synthesizer"
 	"github.com/aqua777/go-llamaindex/schema"
 	"github.com/aqua777/go-llamaindex/selector"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -257,8 +258,5 @@ func getHealthKnowledge() map[string]string {
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/aqua777/go-llamaindex/embedding"
 	"github.com/aqua777/go-llamaindex/rag/store/chromem"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -184,8 +185,5 @@ func main() {
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
@@ -7,10 +7,12 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/aqua777/go-llamaindex/nodeparser"
 	"github.com/aqua777/go-llamaindex/rag/retriever"
 	"github.com/aqua777/go-llamaindex/schema"
 	"github.com/aqua777/go-llamaindex/storage"
 	"github.com/aqua777/go-llamaindex/storage/docstore"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -133,7 +135,15 @@ func main() {
 		printResults("Threshold 0.8", results08)
 	}
 
-	// 5. Explain the merging behavior
+	// 5. Build the hierarchy from raw documents
+	fmt.Println(separator)
+	fmt.Println("=== Building the Hierarchy from Raw Documents ===")
+	fmt.Println(separator)
+	fmt.Println()
+
+	buildFromDocuments(ctx, query)
+
+	// 6. Explain the merging behavior
 	fmt.Println(separator)
 	fmt.Println("=== How Auto-Merging Works ===")
 	fmt.Println(separator)
@@ -157,7 +167,7 @@ func main() {
 	fmt.Println("   - Allows multi-level merging in deep hierarchies")
 	fmt.Println()
 
-	// 6. Use cases
+	// 7. Use cases
 	fmt.Println(separator)
 	fmt.Println("=== Use Cases ===")
 	fmt.Println(separator)
@@ -301,6 +311,51 @@ func createHierarchicalDocuments() ([]schema.Node, []schema.Node) {
 	return parents, children
 }
 
+// buildFromDocuments parses a raw document with HierarchicalNodeParser,
+// stores every level in the docstore and retrieves over the leaves.
+func buildFromDocuments(ctx context.Context, query schema.QueryBundle) {
+	doc := schema.Document{
+		ID: "ml-handbook",
+		Text: "Supervised learning uses labeled data to train models. Common algorithms include linear regression and decision trees. " +
+			"Unsupervised learning finds patterns in unlabeled data. Clustering is a key technique. " +
+			"Reinforcement learning trains agents through rewards. Applications include robotics. " +
+			"Convolutional neural networks excel at image processing. Recurrent neural networks process sequential data. " +
+			"Transformers rely on attention. They power modern language models.",
+	}
+
+	// Small chunk sizes (in tokens) keep the demo readable; real documents
+	// typically use the defaults of 2048/512/128.
+	parser := nodeparser.NewHierarchicalNodeParser(48, 16)
+	nodes := parser.GetNodesFromDocuments([]schema.Document{doc})
+	leaves := nodeparser.GetLeafNodes(nodes)
+	fmt.Printf("Parsed %d nodes: %d roots and %d leaves\n", len(nodes), len(nodeparser.GetRootNodes(nodes)), len(leaves))
+
+	// All levels go in the docstore so leaves can be merged into parents.
+	docStore := docstore.NewSimpleDocumentStore()
+	all := make([]schema.BaseNode, len(nodes))
+	for i, node := range nodes {
+		all[i] = node
+	}
+	_ = docStore.AddDocuments(ctx, all, true)
+
+	// Only the leaves are searched.
+	leafNodes := make([]schema.Node, len(leaves))
+	for i, leaf := range leaves {
+		leafNodes[i] = *leaf
+	}
+
+	amr := retriever.NewAutoMergingRetriever(
+		NewMockVectorRetriever(leafNodes),
+		&storage.StorageContext{DocStore: docStore},
+	)
+	results, err := amr.Retrieve(ctx, query)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	printResults("parsed hierarchy", results)
+}
+
 // createStorageContext creates a storage context with parent nodes.
 func createStorageContext(parentNodes []schema.Node) *storage.StorageContext {
 	docStore := docstore.NewSimpleDocumentStore()
@@ -374,8 +429,5 @@ func printResults(label string, results []schema.NodeWithScore) {
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
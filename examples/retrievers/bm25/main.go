@@ -9,7 +9,9 @@ import (
 	"strings"
 
 	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/rag/retriever"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -67,9 +69,23 @@ func main() {
 		"database optimization",
 	}
 
+	// retriever.BM25Retriever keeps an inverted index over nodes and
+	// returns the top-k matches directly.
+	nodes := make([]schema.Node, len(documents))
+	for i, doc := range documents {
+		node := schema.NewTextNode(doc)
+		node.ID = fmt.Sprintf("doc-%d", i+1)
+		nodes[i] = *node
+	}
+	bm25Retriever := retriever.NewBM25Retriever(nodes, retriever.WithBM25TopK(3))
+
 	for _, query := range queries {
 		fmt.Printf("Query: %s\n", query)
-		results := retrieveWithBM25(ctx, bm25, documents, query, 3)
+		results, err := bm25Retriever.Retrieve(ctx, schema.QueryBundle{QueryString: query})
+		if err != nil {
+			fmt.Printf("Retrieval failed: %v\n\n", err)
+			continue
+		}
 		printResults(results)
 	}
 
@@ -309,10 +325,7 @@ func printResults(results []schema.NodeWithScore) {
 }
 
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
 
 func min(a, b int) int {
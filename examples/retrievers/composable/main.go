@@ -10,6 +10,7 @@ import (
 
 	"github.com/aqua777/go-llamaindex/rag/retriever"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 func main() {
@@ -91,6 +92,17 @@ func main() {
 	merged := mergeResults(results1, results2, results3)
 	printResults("Merged", merged)
 
+	// For the common dense + sparse pair, retriever.HybridRetriever runs both
+	// and fuses the rankings with Reciprocal Rank Fusion.
+	fmt.Println("Hybrid (semantic + keyword, reciprocal rank fusion)...")
+	hybridRetriever := retriever.NewHybridRetriever(semanticRetriever, keywordRetriever, retriever.WithHybridTopK(5))
+	hybridResults, err := hybridRetriever.Retrieve(ctx, query)
+	if err != nil {
+		fmt.Printf("Hybrid retrieval failed: %v\n", err)
+	} else {
+		printResults("Hybrid", hybridResults)
+	}
+
 	// 4. Conditional Composition (Router)
 	fmt.Println(separator)
 	fmt.Println("=== Conditional Composition (Router) ===")
@@ -521,10 +533,7 @@ func printResults(label string, results []schema.NodeWithScore) {
 }
 
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
 
 func min(a, b int) int {
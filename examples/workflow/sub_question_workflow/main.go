@@ -11,6 +11,7 @@ import (
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/questiongen"
 	"github.com/aqua777/go-llamaindex/selector"
+	"github.com/aqua777/go-llamaindex/textutil"
 	"github.com/aqua777/go-llamaindex/workflow"
 )
 
@@ -340,8 +341,5 @@ Comprehensive Answer:`, data.OriginalQuery, subAnswerText.String())
 
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
+	return textutil.Truncate(s, maxLen)
 }
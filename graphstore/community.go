@@ -0,0 +1,248 @@
+package graphstore
+
+import (
+	"sort"
+)
+
+// CommunityOption configures community detection.
+type CommunityOption func(*communityConfig)
+
+type communityConfig struct {
+	resolution float64
+	maxPasses  int
+}
+
+// WithCommunityResolution sets the modularity resolution. Values above 1
+// produce more, smaller communities; values below 1 produce fewer, larger
+// ones. Defaults to 1.
+func WithCommunityResolution(resolution float64) CommunityOption {
+	return func(c *communityConfig) {
+		if resolution > 0 {
+			c.resolution = resolution
+		}
+	}
+}
+
+// WithCommunityMaxPasses caps the local-moving passes per level.
+func WithCommunityMaxPasses(n int) CommunityOption {
+	return func(c *communityConfig) {
+		if n > 0 {
+			c.maxPasses = n
+		}
+	}
+}
+
+// DetectCommunities groups the entities of a knowledge graph into
+// communities with the Louvain method. The graph is treated as undirected,
+// and repeated triplets between the same entities add weight to their
+// edge. Entities are processed in sorted order, so the result is
+// deterministic.
+//
+// Communities are returned largest first; entities within a community are
+// sorted by name.
+func DetectCommunities(triplets []Triplet, opts ...CommunityOption) [][]string {
+	config := communityConfig{resolution: 1, maxPasses: 100}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	names, graph := buildLouvainGraph(triplets)
+	if len(names) == 0 {
+		return nil
+	}
+
+	// membership maps each entity to its node in the current graph.
+	membership := make([]int, len(names))
+	for i := range membership {
+		membership[i] = i
+	}
+
+	for {
+		community, moved := graph.localMoving(config)
+		if !moved {
+			break
+		}
+		var numCommunities int
+		community, numCommunities = renumber(community)
+		for i, node := range membership {
+			membership[i] = community[node]
+		}
+		if numCommunities == len(graph.adj) {
+			break
+		}
+		graph = graph.aggregate(community, numCommunities)
+	}
+
+	groups := make(map[int][]string)
+	for i, c := range membership {
+		groups[c] = append(groups[c], names[i])
+	}
+	communities := make([][]string, 0, len(groups))
+	for _, members := range groups {
+		sort.Strings(members)
+		communities = append(communities, members)
+	}
+	sort.Slice(communities, func(i, j int) bool {
+		if len(communities[i]) != len(communities[j]) {
+			return len(communities[i]) > len(communities[j])
+		}
+		return communities[i][0] < communities[j][0]
+	})
+	return communities
+}
+
+// louvainGraph is a weighted undirected graph. adj holds edge weights
+// between distinct nodes; self holds the weight of edges inside a node
+// after aggregation.
+type louvainGraph struct {
+	adj    []map[int]float64
+	self   []float64
+	degree []float64
+	total  float64
+}
+
+func buildLouvainGraph(triplets []Triplet) ([]string, *louvainGraph) {
+	seen := make(map[string]bool)
+	for _, t := range triplets {
+		seen[t.Subject] = true
+		seen[t.Object] = true
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		index[name] = i
+	}
+
+	g := &louvainGraph{
+		adj:  make([]map[int]float64, len(names)),
+		self: make([]float64, len(names)),
+	}
+	for i := range g.adj {
+		g.adj[i] = make(map[int]float64)
+	}
+	for _, t := range triplets {
+		s, o := index[t.Subject], index[t.Object]
+		if s == o {
+			g.self[s]++
+			continue
+		}
+		g.adj[s][o]++
+		g.adj[o][s]++
+	}
+	g.computeDegrees()
+	return names, g
+}
+
+func (g *louvainGraph) computeDegrees() {
+	g.degree = make([]float64, len(g.adj))
+	g.total = 0
+	for i, neighbors := range g.adj {
+		d := 2 * g.self[i]
+		for _, w := range neighbors {
+			d += w
+		}
+		g.degree[i] = d
+		g.total += d
+	}
+}
+
+// localMoving moves each node to the neighbouring community with the
+// largest modularity gain until no move improves modularity. It reports
+// whether any node changed community.
+func (g *louvainGraph) localMoving(config communityConfig) ([]int, bool) {
+	n := len(g.adj)
+	community := make([]int, n)
+	tot := make([]float64, n)
+	for i := range community {
+		community[i] = i
+		tot[i] = g.degree[i]
+	}
+	if g.total == 0 {
+		return community, false
+	}
+
+	moved := false
+	for pass := 0; pass < config.maxPasses; pass++ {
+		changed := false
+		for i := 0; i < n; i++ {
+			current := community[i]
+			tot[current] -= g.degree[i]
+
+			links := make(map[int]float64)
+			for j, w := range g.adj[i] {
+				links[community[j]] += w
+			}
+
+			best := current
+			bestGain := links[current] - config.resolution*tot[current]*g.degree[i]/g.total
+			candidates := make([]int, 0, len(links))
+			for c := range links {
+				candidates = append(candidates, c)
+			}
+			sort.Ints(candidates)
+			for _, c := range candidates {
+				gain := links[c] - config.resolution*tot[c]*g.degree[i]/g.total
+				if gain > bestGain+1e-12 {
+					best, bestGain = c, gain
+				}
+			}
+
+			tot[best] += g.degree[i]
+			if best != current {
+				community[i] = best
+				changed = true
+				moved = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	return community, moved
+}
+
+// aggregate collapses each community into a single node.
+func (g *louvainGraph) aggregate(community []int, numCommunities int) *louvainGraph {
+	next := &louvainGraph{
+		adj:  make([]map[int]float64, numCommunities),
+		self: make([]float64, numCommunities),
+	}
+	for i := range next.adj {
+		next.adj[i] = make(map[int]float64)
+	}
+	for i, neighbors := range g.adj {
+		ci := community[i]
+		next.self[ci] += g.self[i]
+		for j, w := range neighbors {
+			cj := community[j]
+			if ci == cj {
+				// Each internal edge is visited from both ends.
+				next.self[ci] += w / 2
+				continue
+			}
+			next.adj[ci][cj] += w
+		}
+	}
+	next.computeDegrees()
+	return next
+}
+
+// renumber maps community labels to 0..n-1 in order of first appearance.
+func renumber(community []int) ([]int, int) {
+	labels := make(map[int]int)
+	out := make([]int, len(community))
+	for i, c := range community {
+		label, ok := labels[c]
+		if !ok {
+			label = len(labels)
+			labels[c] = label
+		}
+		out[i] = label
+	}
+	return out, len(labels)
+}
@@ -337,3 +337,73 @@ func TestSimpleGraphStore_WithOptions(t *testing.T) {
 		assert.Equal(t, 1, store.Size())
 	})
 }
+
+func TestDetectCommunities(t *testing.T) {
+	triplets := []Triplet{
+		// Two tightly connected groups joined by a single edge.
+		{Subject: "Alice", Relation: "knows", Object: "Bob"},
+		{Subject: "Bob", Relation: "knows", Object: "Carol"},
+		{Subject: "Carol", Relation: "knows", Object: "Alice"},
+		{Subject: "Dave", Relation: "works_with", Object: "Erin"},
+		{Subject: "Erin", Relation: "works_with", Object: "Frank"},
+		{Subject: "Frank", Relation: "works_with", Object: "Dave"},
+		{Subject: "Frank", Relation: "manages", Object: "Grace"},
+		{Subject: "Carol", Relation: "met", Object: "Dave"},
+	}
+
+	communities := DetectCommunities(triplets)
+	require.Len(t, communities, 2)
+	assert.Equal(t, []string{"Dave", "Erin", "Frank", "Grace"}, communities[0])
+	assert.Equal(t, []string{"Alice", "Bob", "Carol"}, communities[1])
+
+	// Detection is deterministic.
+	assert.Equal(t, communities, DetectCommunities(triplets))
+
+	// A very low resolution merges everything.
+	merged := DetectCommunities(triplets, WithCommunityResolution(0.01))
+	require.Len(t, merged, 1)
+	assert.Len(t, merged[0], 7)
+
+	assert.Nil(t, DetectCommunities(nil))
+}
+
+func TestParseTriplets(t *testing.T) {
+	response := `Triplets:
+(alice, "knows", 'bob')
+(Bob, works at, ACME)
+(, empty, subject)
+(Carol, likes, a very long object name)`
+
+	triplets := ParseTriplets(response, 0, 20)
+	assert.Equal(t, []Triplet{
+		{Subject: "Alice", Relation: "knows", Object: "Bob"},
+		{Subject: "Bob", Relation: "works at", Object: "ACME"},
+	}, triplets)
+
+	assert.Len(t, ParseTriplets(response, 1, 0), 1)
+	assert.Len(t, ParseTriplets(response, 0, 0), 3)
+	assert.Empty(t, ParseTriplets("no triplets here", 10, 0))
+}
+
+func TestTripletsFromMetadata(t *testing.T) {
+	_, ok := TripletsFromMetadata(map[string]interface{}{})
+	assert.False(t, ok)
+
+	triplets := []Triplet{{Subject: "Alice", Relation: "knows", Object: "Bob"}}
+	got, ok := TripletsFromMetadata(map[string]interface{}{TripletsMetadataKey: triplets})
+	assert.True(t, ok)
+	assert.Equal(t, triplets, got)
+
+	// Metadata persisted as JSON comes back as nested interface slices.
+	data, err := json.Marshal(map[string]interface{}{TripletsMetadataKey: triplets})
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	got, ok = TripletsFromMetadata(decoded)
+	assert.True(t, ok)
+	assert.Equal(t, triplets, got)
+
+	got, ok = TripletsFromMetadata(map[string]interface{}{TripletsMetadataKey: []interface{}{}})
+	assert.True(t, ok)
+	assert.Empty(t, got)
+}
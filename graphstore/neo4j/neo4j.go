@@ -0,0 +1,445 @@
+// Package neo4j provides a graph store backed by Neo4j.
+//
+// The store talks to Neo4j's HTTP transactional Cypher endpoint, so it
+// needs no driver dependency and works with Neo4j 4.x and 5.x servers and
+// Aura:
+//
+//	gs := neo4j.NewGraphStore("http://localhost:7474",
+//		neo4j.WithBasicAuth("neo4j", "secret"),
+//	)
+//	if err := gs.EnsureConstraint(ctx); err != nil { ... }
+//	kg, _ := index.NewKnowledgeGraphIndexFromDocuments(ctx, docs,
+//		index.WithKGIndexGraphStore(gs),
+//		index.WithKGIndexLLM(l),
+//	)
+//
+// Entities are nodes with a label (Entity by default) and an id property.
+// Each triplet is a relationship whose type is the predicate in upper snake
+// case, with the original predicate kept in its name property.
+package neo4j
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/graphstore"
+)
+
+const (
+	// DefaultDatabase is the database used when none is configured.
+	DefaultDatabase = "neo4j"
+	// DefaultNodeLabel is the label of entity nodes.
+	DefaultNodeLabel = "Entity"
+)
+
+// Error is an error returned by Neo4j for a statement.
+type Error struct {
+	// Code is the Neo4j status code, e.g.
+	// "Neo.ClientError.Statement.SyntaxError".
+	Code string `json:"code"`
+	// Message describes the error.
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("neo4j error %s: %s", e.Code, e.Message)
+}
+
+// GraphStore is a graph store backed by a Neo4j database.
+type GraphStore struct {
+	baseURL   string
+	database  string
+	username  string
+	password  string
+	nodeLabel string
+	client    *http.Client
+
+	mu     sync.Mutex
+	schema string
+}
+
+// Option configures a GraphStore.
+type Option func(*GraphStore)
+
+// WithDatabase sets the database name. Defaults to DefaultDatabase.
+func WithDatabase(name string) Option {
+	return func(s *GraphStore) {
+		s.database = name
+	}
+}
+
+// WithBasicAuth sets the credentials. Defaults to the NEO4J_USERNAME and
+// NEO4J_PASSWORD environment variables.
+func WithBasicAuth(username, password string) Option {
+	return func(s *GraphStore) {
+		s.username = username
+		s.password = password
+	}
+}
+
+// WithNodeLabel sets the label of entity nodes. Defaults to
+// DefaultNodeLabel.
+func WithNodeLabel(label string) Option {
+	return func(s *GraphStore) {
+		s.nodeLabel = label
+	}
+}
+
+// WithHTTPClient sets the HTTP client.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *GraphStore) {
+		s.client = client
+	}
+}
+
+// NewGraphStore creates a store for the Neo4j server at baseURL, e.g.
+// "http://localhost:7474".
+func NewGraphStore(baseURL string, opts ...Option) *GraphStore {
+	s := &GraphStore{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		database:  DefaultDatabase,
+		username:  os.Getenv("NEO4J_USERNAME"),
+		password:  os.Getenv("NEO4J_PASSWORD"),
+		nodeLabel: DefaultNodeLabel,
+		client:    http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// EnsureConstraint creates a uniqueness constraint on the id of entity
+// nodes, which also indexes them for lookups, if it does not exist yet.
+func (s *GraphStore) EnsureConstraint(ctx context.Context) error {
+	_, err := s.run(ctx, statement{
+		Statement: fmt.Sprintf("CREATE CONSTRAINT IF NOT EXISTS FOR (n:%s) REQUIRE n.id IS UNIQUE", quote(s.nodeLabel)),
+	})
+	return err
+}
+
+// Get returns the [relation, object] pairs of a subject.
+func (s *GraphStore) Get(ctx context.Context, subj string) ([][]string, error) {
+	results, err := s.run(ctx, statement{
+		Statement: fmt.Sprintf(
+			"MATCH (n1:%[1]s {id: $subj})-[r]->(n2:%[1]s) RETURN coalesce(r.name, type(r)) AS rel, n2.id AS obj",
+			quote(s.nodeLabel),
+		),
+		Parameters: map[string]interface{}{"subj": subj},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var pairs [][]string
+	for _, row := range results[0].rows() {
+		pairs = append(pairs, []string{asString(row[0]), asString(row[1])})
+	}
+	return pairs, nil
+}
+
+// GetRelMap returns the [subject, relation, object] triplets on the paths
+// of up to depth hops starting at each subject, at most limit in total. If
+// subjs is nil, paths start at every entity.
+func (s *GraphStore) GetRelMap(ctx context.Context, subjs []string, depth int, limit int) (map[string][][]string, error) {
+	relMap := make(map[string][][]string)
+	if depth <= 0 || limit <= 0 || (subjs != nil && len(subjs) == 0) {
+		return relMap, nil
+	}
+
+	query := fmt.Sprintf("MATCH p=(n1:%[1]s)-[*1..%[2]d]->(:%[1]s) ", quote(s.nodeLabel), depth)
+	params := map[string]interface{}{}
+	if subjs != nil {
+		query += "WHERE n1.id IN $subjs "
+		params["subjs"] = subjs
+	}
+	query += "RETURN n1.id AS subj, [r IN relationships(p) | [startNode(r).id, coalesce(r.name, type(r)), endNode(r).id]] AS rels " +
+		"ORDER BY length(p) LIMIT $paths"
+	// Each path yields up to depth triplets, most of them shared with
+	// shorter paths, so fetch more paths than the triplet limit.
+	params["paths"] = limit * depth
+
+	results, err := s.run(ctx, statement{Statement: query, Parameters: params})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	count := 0
+	for _, row := range results[0].rows() {
+		subj := asString(row[0])
+		rels, _ := row[1].([]interface{})
+		for _, r := range rels {
+			parts, _ := r.([]interface{})
+			if len(parts) != 3 {
+				continue
+			}
+			triplet := []string{asString(parts[0]), asString(parts[1]), asString(parts[2])}
+			key := subj + "\x00" + strings.Join(triplet, "\x00")
+			if seen[key] {
+				continue
+			}
+			if count >= limit {
+				return relMap, nil
+			}
+			seen[key] = true
+			relMap[subj] = append(relMap[subj], triplet)
+			count++
+		}
+	}
+	return relMap, nil
+}
+
+// UpsertTriplet merges the subject and object entities and the
+// relationship between them.
+func (s *GraphStore) UpsertTriplet(ctx context.Context, subj, rel, obj string) error {
+	_, err := s.run(ctx, statement{
+		Statement: fmt.Sprintf(
+			"MERGE (n1:%[1]s {id: $subj}) MERGE (n2:%[1]s {id: $obj}) MERGE (n1)-[r:%[2]s]->(n2) SET r.name = $rel",
+			quote(s.nodeLabel), quote(RelationType(rel)),
+		),
+		Parameters: map[string]interface{}{"subj": subj, "rel": rel, "obj": obj},
+	})
+	return err
+}
+
+// Delete removes the relationship of a triplet, and its entities if they
+// have no other relationships left.
+func (s *GraphStore) Delete(ctx context.Context, subj, rel, obj string) error {
+	params := map[string]interface{}{"subj": subj, "obj": obj}
+	_, err := s.run(ctx,
+		statement{
+			Statement: fmt.Sprintf(
+				"MATCH (n1:%[1]s {id: $subj})-[r:%[2]s]->(n2:%[1]s {id: $obj}) DELETE r",
+				quote(s.nodeLabel), quote(RelationType(rel)),
+			),
+			Parameters: params,
+		},
+		statement{
+			Statement: fmt.Sprintf(
+				"MATCH (n:%s) WHERE n.id IN [$subj, $obj] AND NOT (n)--() DELETE n",
+				quote(s.nodeLabel),
+			),
+			Parameters: params,
+		},
+	)
+	return err
+}
+
+// GetSchema describes the node labels, relationship types and the
+// relationship patterns between labels, e.g. for text-to-Cypher prompts.
+// The schema is cached until refresh is set.
+func (s *GraphStore) GetSchema(ctx context.Context, refresh bool) (string, error) {
+	s.mu.Lock()
+	cached := s.schema
+	s.mu.Unlock()
+	if cached != "" && !refresh {
+		return cached, nil
+	}
+
+	results, err := s.run(ctx,
+		statement{Statement: "CALL db.labels() YIELD label RETURN label ORDER BY label"},
+		statement{Statement: "CALL db.relationshipTypes() YIELD relationshipType RETURN relationshipType ORDER BY relationshipType"},
+		statement{Statement: "MATCH (a)-[r]->(b) RETURN DISTINCT labels(a)[0] AS source, type(r) AS rel, labels(b)[0] AS target LIMIT 100"},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	var labels, types, patterns []string
+	for _, row := range results[0].rows() {
+		labels = append(labels, asString(row[0]))
+	}
+	for _, row := range results[1].rows() {
+		types = append(types, asString(row[0]))
+	}
+	for _, row := range results[2].rows() {
+		patterns = append(patterns, fmt.Sprintf("(:%s)-[:%s]->(:%s)", asString(row[0]), asString(row[1]), asString(row[2])))
+	}
+	sort.Strings(patterns)
+
+	schema := fmt.Sprintf(
+		"Node labels: %s\nRelationship types: %s\nThe relationships are:\n%s",
+		strings.Join(labels, ", "), strings.Join(types, ", "), strings.Join(patterns, "\n"),
+	)
+	s.mu.Lock()
+	s.schema = schema
+	s.mu.Unlock()
+	return schema, nil
+}
+
+// Query runs a Cypher statement and returns its rows as maps from column
+// name to value.
+func (s *GraphStore) Query(ctx context.Context, query string, params map[string]interface{}) (interface{}, error) {
+	return s.query(ctx, accessModeWrite, query, params)
+}
+
+// QueryRead is like Query but runs the statement in a read transaction, so
+// Neo4j rejects it if it tries to write.
+func (s *GraphStore) QueryRead(ctx context.Context, query string, params map[string]interface{}) (interface{}, error) {
+	return s.query(ctx, accessModeRead, query, params)
+}
+
+func (s *GraphStore) query(ctx context.Context, mode string, query string, params map[string]interface{}) (interface{}, error) {
+	results, err := s.runWithAccessMode(ctx, mode, statement{Statement: query, Parameters: params})
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]map[string]interface{}, 0, len(results[0].Data))
+	for _, values := range results[0].rows() {
+		row := make(map[string]interface{}, len(results[0].Columns))
+		for i, column := range results[0].Columns {
+			if i < len(values) {
+				row[column] = values[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Persist is a no-op: Neo4j persists data itself.
+func (s *GraphStore) Persist(ctx context.Context, path string) error {
+	return nil
+}
+
+// GetAllSubjects returns the entities with outgoing relationships.
+func (s *GraphStore) GetAllSubjects(ctx context.Context) ([]string, error) {
+	results, err := s.run(ctx, statement{
+		Statement: fmt.Sprintf("MATCH (n:%[1]s)-->(:%[1]s) RETURN DISTINCT n.id ORDER BY n.id", quote(s.nodeLabel)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var subjects []string
+	for _, row := range results[0].rows() {
+		subjects = append(subjects, asString(row[0]))
+	}
+	return subjects, nil
+}
+
+// statement is a Cypher statement of a transaction request.
+type statement struct {
+	Statement  string                 `json:"statement"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// result is the result of one statement.
+type result struct {
+	Columns []string `json:"columns"`
+	Data    []struct {
+		Row []interface{} `json:"row"`
+	} `json:"data"`
+}
+
+func (r result) rows() [][]interface{} {
+	rows := make([][]interface{}, len(r.Data))
+	for i, d := range r.Data {
+		rows[i] = d.Row
+	}
+	return rows
+}
+
+// Transaction access modes, sent in the access-mode header.
+const (
+	accessModeRead  = "READ"
+	accessModeWrite = "WRITE"
+)
+
+// run executes the statements in one write transaction and returns one
+// result per statement.
+func (s *GraphStore) run(ctx context.Context, statements ...statement) ([]result, error) {
+	return s.runWithAccessMode(ctx, accessModeWrite, statements...)
+}
+
+// runWithAccessMode executes the statements in one transaction with the
+// given access mode.
+func (s *GraphStore) runWithAccessMode(ctx context.Context, mode string, statements ...statement) ([]result, error) {
+	body, err := json.Marshal(map[string]interface{}{"statements": statements})
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/db/%s/tx/commit", s.baseURL, s.database)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json;charset=UTF-8")
+	req.Header.Set("access-mode", mode)
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("neo4j request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read neo4j response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("neo4j request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var out struct {
+		Results []result `json:"results"`
+		Errors  []Error  `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to decode neo4j response: %w", err)
+	}
+	if len(out.Errors) > 0 {
+		return nil, &out.Errors[0]
+	}
+	if len(out.Results) != len(statements) {
+		return nil, fmt.Errorf("neo4j returned %d results for %d statements", len(out.Results), len(statements))
+	}
+	return out.Results, nil
+}
+
+var nonIdentifierChars = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// RelationType converts a predicate to the relationship type it is stored
+// under, e.g. "works at" to WORKS_AT.
+func RelationType(rel string) string {
+	t := strings.Trim(nonIdentifierChars.ReplaceAllString(strings.TrimSpace(rel), "_"), "_")
+	if t == "" {
+		return "RELATED_TO"
+	}
+	return strings.ToUpper(t)
+}
+
+// quote quotes a label or relationship type as a Cypher identifier.
+func quote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func asString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// Ensure GraphStore implements graphstore.GraphStore and
+// graphstore.ReadQuerier.
+var (
+	_ graphstore.GraphStore  = (*GraphStore)(nil)
+	_ graphstore.ReadQuerier = (*GraphStore)(nil)
+)
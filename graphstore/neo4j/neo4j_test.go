@@ -0,0 +1,225 @@
+package neo4j
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer records transaction requests and answers them with canned
+// results.
+type fakeServer struct {
+	*httptest.Server
+	requests [][]statement
+	auth     [2]string
+	path     string
+	modes    []string
+	respond  func(statements []statement) interface{}
+}
+
+func newFakeServer(t *testing.T, respond func(statements []statement) interface{}) *fakeServer {
+	t.Helper()
+	fs := &fakeServer{respond: respond}
+	fs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Statements []statement `json:"statements"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		fs.requests = append(fs.requests, body.Statements)
+		fs.path = r.URL.Path
+		fs.modes = append(fs.modes, r.Header.Get("access-mode"))
+		fs.auth[0], fs.auth[1], _ = r.BasicAuth()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(fs.respond(body.Statements))
+	}))
+	t.Cleanup(fs.Close)
+	return fs
+}
+
+// rows builds a response with one result per row set.
+func rows(columns []string, sets ...[][]interface{}) interface{} {
+	var results []interface{}
+	for _, set := range sets {
+		var data []interface{}
+		for _, row := range set {
+			data = append(data, map[string]interface{}{"row": row})
+		}
+		results = append(results, map[string]interface{}{"columns": columns, "data": data})
+	}
+	return map[string]interface{}{"results": results, "errors": []interface{}{}}
+}
+
+func empty(statements []statement) interface{} {
+	sets := make([][][]interface{}, len(statements))
+	return rows(nil, sets...)
+}
+
+func TestGraphStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("UpsertTriplet", func(t *testing.T) {
+		fs := newFakeServer(t, empty)
+		gs := NewGraphStore(fs.URL+"/", WithBasicAuth("neo4j", "secret"), WithDatabase("kg"))
+
+		require.NoError(t, gs.UpsertTriplet(ctx, "Alice", "works at", "ACME"))
+		require.Len(t, fs.requests, 1)
+		st := fs.requests[0][0]
+		assert.Contains(t, st.Statement, "MERGE (n1:`Entity` {id: $subj})")
+		assert.Contains(t, st.Statement, "MERGE (n1)-[r:`WORKS_AT`]->(n2) SET r.name = $rel")
+		assert.Equal(t, map[string]interface{}{"subj": "Alice", "rel": "works at", "obj": "ACME"}, st.Parameters)
+		assert.Equal(t, "/db/kg/tx/commit", fs.path)
+		assert.Equal(t, [2]string{"neo4j", "secret"}, fs.auth)
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		fs := newFakeServer(t, func([]statement) interface{} {
+			return rows([]string{"rel", "obj"}, [][]interface{}{{"works at", "ACME"}, {"knows", "Bob"}})
+		})
+		gs := NewGraphStore(fs.URL, WithNodeLabel("Person"))
+
+		pairs, err := gs.Get(ctx, "Alice")
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"works at", "ACME"}, {"knows", "Bob"}}, pairs)
+		assert.Contains(t, fs.requests[0][0].Statement, "(n1:`Person` {id: $subj})")
+	})
+
+	t.Run("GetRelMap", func(t *testing.T) {
+		fs := newFakeServer(t, func([]statement) interface{} {
+			return rows([]string{"subj", "rels"}, [][]interface{}{
+				{"Alice", []interface{}{[]interface{}{"Alice", "works at", "ACME"}}},
+				{"Alice", []interface{}{
+					[]interface{}{"Alice", "works at", "ACME"},
+					[]interface{}{"ACME", "based in", "Berlin"},
+				}},
+				{"Bob", []interface{}{[]interface{}{"Bob", "knows", "Alice"}}},
+			})
+		})
+		gs := NewGraphStore(fs.URL)
+
+		relMap, err := gs.GetRelMap(ctx, []string{"Alice", "Bob"}, 2, 10)
+		require.NoError(t, err)
+		assert.Equal(t, map[string][][]string{
+			"Alice": {{"Alice", "works at", "ACME"}, {"ACME", "based in", "Berlin"}},
+			"Bob":   {{"Bob", "knows", "Alice"}},
+		}, relMap)
+		st := fs.requests[0][0]
+		assert.Contains(t, st.Statement, "-[*1..2]->")
+		assert.Contains(t, st.Statement, "WHERE n1.id IN $subjs")
+		assert.Equal(t, float64(20), st.Parameters["paths"])
+
+		relMap, err = gs.GetRelMap(ctx, []string{"Alice", "Bob"}, 2, 2)
+		require.NoError(t, err)
+		assert.Len(t, relMap["Alice"], 2)
+		assert.NotContains(t, relMap, "Bob", "the triplet limit applies across subjects")
+
+		relMap, err = gs.GetRelMap(ctx, []string{}, 2, 10)
+		require.NoError(t, err)
+		assert.Empty(t, relMap)
+		assert.Len(t, fs.requests, 2, "empty subject lists need no request")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		fs := newFakeServer(t, empty)
+		gs := NewGraphStore(fs.URL)
+
+		require.NoError(t, gs.Delete(ctx, "Alice", "works at", "ACME"))
+		require.Len(t, fs.requests[0], 2, "relationship and orphan deletion run in one transaction")
+		assert.Contains(t, fs.requests[0][0].Statement, "-[r:`WORKS_AT`]->")
+		assert.Contains(t, fs.requests[0][1].Statement, "NOT (n)--() DELETE n")
+	})
+
+	t.Run("GetSchema", func(t *testing.T) {
+		fs := newFakeServer(t, func(statements []statement) interface{} {
+			return rows(nil,
+				[][]interface{}{{"Entity"}},
+				[][]interface{}{{"KNOWS"}, {"WORKS_AT"}},
+				[][]interface{}{{"Entity", "WORKS_AT", "Entity"}, {"Entity", "KNOWS", "Entity"}},
+			)
+		})
+		gs := NewGraphStore(fs.URL)
+
+		schema, err := gs.GetSchema(ctx, false)
+		require.NoError(t, err)
+		assert.Equal(t, "Node labels: Entity\nRelationship types: KNOWS, WORKS_AT\nThe relationships are:\n"+
+			"(:Entity)-[:KNOWS]->(:Entity)\n(:Entity)-[:WORKS_AT]->(:Entity)", schema)
+
+		_, err = gs.GetSchema(ctx, false)
+		require.NoError(t, err)
+		assert.Len(t, fs.requests, 1, "schema is cached")
+		_, err = gs.GetSchema(ctx, true)
+		require.NoError(t, err)
+		assert.Len(t, fs.requests, 2)
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		fs := newFakeServer(t, func([]statement) interface{} {
+			return rows([]string{"name", "age"}, [][]interface{}{{"Alice", 30}})
+		})
+		gs := NewGraphStore(fs.URL)
+
+		result, err := gs.Query(ctx, "MATCH (p) WHERE p.age > $age RETURN p.name AS name, p.age AS age", map[string]interface{}{"age": 20})
+		require.NoError(t, err)
+		assert.Equal(t, []map[string]interface{}{{"name": "Alice", "age": float64(30)}}, result)
+		assert.Equal(t, float64(20), fs.requests[0][0].Parameters["age"])
+		assert.Equal(t, []string{"WRITE"}, fs.modes)
+	})
+
+	t.Run("QueryRead", func(t *testing.T) {
+		fs := newFakeServer(t, func([]statement) interface{} {
+			return rows([]string{"n"}, [][]interface{}{{1}})
+		})
+		gs := NewGraphStore(fs.URL)
+
+		result, err := gs.QueryRead(ctx, "MATCH (n) RETURN count(n) AS n", nil)
+		require.NoError(t, err)
+		assert.Equal(t, []map[string]interface{}{{"n": float64(1)}}, result)
+		assert.Equal(t, []string{"READ"}, fs.modes)
+	})
+
+	t.Run("GetAllSubjects", func(t *testing.T) {
+		fs := newFakeServer(t, func([]statement) interface{} {
+			return rows([]string{"n.id"}, [][]interface{}{{"Alice"}, {"Bob"}})
+		})
+		subjects, err := NewGraphStore(fs.URL).GetAllSubjects(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Alice", "Bob"}, subjects)
+	})
+
+	t.Run("EnsureConstraint", func(t *testing.T) {
+		fs := newFakeServer(t, empty)
+		require.NoError(t, NewGraphStore(fs.URL).EnsureConstraint(ctx))
+		assert.Equal(t, "CREATE CONSTRAINT IF NOT EXISTS FOR (n:`Entity`) REQUIRE n.id IS UNIQUE", fs.requests[0][0].Statement)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		fs := newFakeServer(t, func([]statement) interface{} {
+			return map[string]interface{}{"results": []interface{}{}, "errors": []interface{}{
+				map[string]interface{}{"code": "Neo.ClientError.Statement.SyntaxError", "message": "Invalid input"},
+			}}
+		})
+		_, err := NewGraphStore(fs.URL).Query(ctx, "MATC (n) RETURN n", nil)
+		var neoErr *Error
+		require.ErrorAs(t, err, &neoErr)
+		assert.Equal(t, "Neo.ClientError.Statement.SyntaxError", neoErr.Code)
+
+		unauthorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		}))
+		defer unauthorized.Close()
+		err = NewGraphStore(unauthorized.URL).UpsertTriplet(ctx, "a", "b", "c")
+		require.Error(t, err)
+		assert.True(t, strings.Contains(err.Error(), "401"))
+	})
+}
+
+func TestRelationType(t *testing.T) {
+	assert.Equal(t, "WORKS_AT", RelationType("works at"))
+	assert.Equal(t, "IS_PART_OF", RelationType(" is-part-of "))
+	assert.Equal(t, "RELATED_TO", RelationType("--"))
+}
@@ -0,0 +1,103 @@
+package graphstore
+
+import (
+	"regexp"
+	"strings"
+)
+
+// tripletPattern matches "(subject, predicate, object)".
+var tripletPattern = regexp.MustCompile(`\(([^,]+),\s*([^,]+),\s*([^)]+)\)`)
+
+// ParseTriplets parses "(subject, predicate, object)" triplets from an LLM
+// response. Triplets with a part longer than maxLength are skipped, quotes
+// are trimmed and subjects and objects are capitalized so the same entity
+// maps to one graph node. At most maxTriplets are returned; zero or less
+// means no limit.
+func ParseTriplets(response string, maxTriplets, maxLength int) []Triplet {
+	var triplets []Triplet
+	for _, match := range tripletPattern.FindAllStringSubmatch(response, -1) {
+		subj := strings.TrimSpace(match[1])
+		pred := strings.TrimSpace(match[2])
+		obj := strings.TrimSpace(match[3])
+
+		// Skip if any part is empty
+		if subj == "" || pred == "" || obj == "" {
+			continue
+		}
+
+		// Skip if any part is too long
+		if maxLength > 0 && (len(subj) > maxLength || len(pred) > maxLength || len(obj) > maxLength) {
+			continue
+		}
+
+		// Clean up quotes and capitalize
+		subj = strings.Trim(subj, `"'`)
+		pred = strings.Trim(pred, `"'`)
+		obj = strings.Trim(obj, `"'`)
+
+		if len(subj) > 0 {
+			subj = strings.ToUpper(subj[:1]) + subj[1:]
+		}
+		if len(obj) > 0 {
+			obj = strings.ToUpper(obj[:1]) + obj[1:]
+		}
+
+		triplets = append(triplets, Triplet{
+			Subject:  subj,
+			Relation: pred,
+			Object:   obj,
+		})
+
+		if maxTriplets > 0 && len(triplets) >= maxTriplets {
+			break
+		}
+	}
+	return triplets
+}
+
+// TripletsMetadataKey is the node metadata key holding the triplets
+// extracted from a node, as set by ingestion.TripletExtractor.
+const TripletsMetadataKey = "kg_triplets"
+
+// TripletsFromMetadata returns the triplets stored under
+// TripletsMetadataKey. It accepts both []Triplet and the
+// [][subject, relation, object] form triplets take after a JSON round trip
+// through a docstore. The boolean reports whether the key was present, so
+// nodes that were processed but yielded no triplets can be told apart from
+// unprocessed ones.
+func TripletsFromMetadata(metadata map[string]interface{}) ([]Triplet, bool) {
+	value, ok := metadata[TripletsMetadataKey]
+	if !ok {
+		return nil, false
+	}
+	switch v := value.(type) {
+	case []Triplet:
+		return v, true
+	case [][]string:
+		triplets := make([]Triplet, 0, len(v))
+		for _, parts := range v {
+			if len(parts) == 3 {
+				triplets = append(triplets, Triplet{Subject: parts[0], Relation: parts[1], Object: parts[2]})
+			}
+		}
+		return triplets, true
+	case []interface{}:
+		triplets := make([]Triplet, 0, len(v))
+		for _, item := range v {
+			parts, ok := item.([]interface{})
+			if !ok || len(parts) != 3 {
+				continue
+			}
+			var t Triplet
+			t.Subject, _ = parts[0].(string)
+			t.Relation, _ = parts[1].(string)
+			t.Object, _ = parts[2].(string)
+			if t.Subject != "" && t.Relation != "" && t.Object != "" {
+				triplets = append(triplets, t)
+			}
+		}
+		return triplets, true
+	default:
+		return nil, true
+	}
+}
@@ -131,6 +131,13 @@ type GraphStore interface {
 	GetAllSubjects(ctx context.Context) ([]string, error)
 }
 
+// ReadQuerier is implemented by graph stores that can run a query in a
+// read-only transaction, in which the database rejects any write.
+type ReadQuerier interface {
+	// QueryRead executes a query in a read-only transaction.
+	QueryRead(ctx context.Context, query string, params map[string]interface{}) (interface{}, error)
+}
+
 // PropertyGraphStore is an extended interface for property graph stores.
 // It supports labeled nodes and relations with properties.
 type PropertyGraphStore interface {
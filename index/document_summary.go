@@ -0,0 +1,656 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/ingestion"
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/postprocessor"
+	"github.com/aqua777/go-llamaindex/rag/queryengine"
+	"github.com/aqua777/go-llamaindex/rag/retriever"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/storage"
+	"github.com/aqua777/go-llamaindex/storage/docstore"
+	"github.com/aqua777/go-llamaindex/storage/indexstore"
+)
+
+// DocumentSummaryRetrieverMode specifies how a document summary index
+// selects documents.
+type DocumentSummaryRetrieverMode string
+
+const (
+	// DocumentSummaryRetrieverModeEmbedding selects the documents whose
+	// summary embeddings are most similar to the query.
+	DocumentSummaryRetrieverModeEmbedding DocumentSummaryRetrieverMode = "embedding"
+	// DocumentSummaryRetrieverModeLLM shows the summaries to an LLM and
+	// selects the documents it rates most relevant.
+	DocumentSummaryRetrieverModeLLM DocumentSummaryRetrieverMode = "llm"
+)
+
+// DocumentSummaryIndex stores an LLM-generated summary of each document
+// next to the document's chunks. Queries are matched against the summaries
+// and return the chunks of the selected documents, which finds the right
+// documents in large, heterogeneous corpora where individual chunks lack
+// the context to be matched on their own.
+//
+// The index struct maps each document ID to its summary node (NodesDict)
+// and each summary node to the document's chunk IDs (Table). Summaries and
+// keywords are also stored as reference document metadata, see
+// docstore.GetDocumentSummary.
+type DocumentSummaryIndex struct {
+	*BaseIndex
+	// llm generates summaries, selects documents in LLM mode and
+	// synthesizes answers.
+	llm llm.LLM
+	// transformations turn inserted documents into chunks.
+	transformations []ingestion.TransformComponent
+	// summarizerOpts configure the document summarizer.
+	summarizerOpts []ingestion.DocumentSummarizerOption
+	// retrieverMode is the mode of AsRetriever and AsQueryEngine.
+	retrieverMode DocumentSummaryRetrieverMode
+	// mu guards summary embeddings computed at query time.
+	mu sync.Mutex
+}
+
+// DocumentSummaryIndexOption configures DocumentSummaryIndex creation.
+type DocumentSummaryIndexOption func(*DocumentSummaryIndex)
+
+// WithDocumentSummaryIndexLLM sets the LLM. It is required.
+func WithDocumentSummaryIndexLLM(l llm.LLM) DocumentSummaryIndexOption {
+	return func(dsi *DocumentSummaryIndex) {
+		dsi.llm = l
+	}
+}
+
+// WithDocumentSummaryIndexStorageContext sets the storage context.
+func WithDocumentSummaryIndexStorageContext(sc *storage.StorageContext) DocumentSummaryIndexOption {
+	return func(dsi *DocumentSummaryIndex) {
+		dsi.storageContext = sc
+	}
+}
+
+// WithDocumentSummaryIndexEmbedModel sets the embedding model. Summaries
+// are embedded when they are generated, and the retriever mode defaults to
+// embedding mode.
+func WithDocumentSummaryIndexEmbedModel(model EmbeddingModel) DocumentSummaryIndexOption {
+	return func(dsi *DocumentSummaryIndex) {
+		dsi.embedModel = model
+	}
+}
+
+// WithDocumentSummaryIndexTransformations sets the transformations that
+// turn inserted documents into chunks, e.g. an ingestion.NodeParserTransform.
+// Without transformations each document is stored as a single chunk.
+func WithDocumentSummaryIndexTransformations(transformations ...ingestion.TransformComponent) DocumentSummaryIndexOption {
+	return func(dsi *DocumentSummaryIndex) {
+		dsi.transformations = transformations
+	}
+}
+
+// WithDocumentSummaryIndexSummarizerOptions configures how summaries are
+// generated, e.g. with ingestion.WithDocumentSummaryPrompt.
+func WithDocumentSummaryIndexSummarizerOptions(opts ...ingestion.DocumentSummarizerOption) DocumentSummaryIndexOption {
+	return func(dsi *DocumentSummaryIndex) {
+		dsi.summarizerOpts = opts
+	}
+}
+
+// WithDocumentSummaryIndexRetrieverMode sets the retriever mode used by
+// AsRetriever and AsQueryEngine. Defaults to embedding mode if an embedding
+// model is set, and LLM mode otherwise.
+func WithDocumentSummaryIndexRetrieverMode(mode DocumentSummaryRetrieverMode) DocumentSummaryIndexOption {
+	return func(dsi *DocumentSummaryIndex) {
+		dsi.retrieverMode = mode
+	}
+}
+
+// NewDocumentSummaryIndex creates an empty DocumentSummaryIndex.
+func NewDocumentSummaryIndex(ctx context.Context, opts ...DocumentSummaryIndexOption) (*DocumentSummaryIndex, error) {
+	indexStruct := indexstore.NewIndexStruct(indexstore.IndexStructTypeDocumentSummary)
+
+	dsi := &DocumentSummaryIndex{
+		BaseIndex: NewBaseIndex(indexStruct),
+	}
+
+	for _, opt := range opts {
+		opt(dsi)
+	}
+
+	if dsi.llm == nil {
+		return nil, fmt.Errorf("LLM must be provided for DocumentSummaryIndex")
+	}
+	if dsi.retrieverMode == "" {
+		dsi.retrieverMode = DocumentSummaryRetrieverModeLLM
+		if dsi.embedModel != nil {
+			dsi.retrieverMode = DocumentSummaryRetrieverModeEmbedding
+		}
+	}
+
+	if err := dsi.storageContext.IndexStore.AddIndexStruct(ctx, indexStruct); err != nil {
+		return nil, err
+	}
+
+	return dsi, nil
+}
+
+// NewDocumentSummaryIndexFromDocuments creates a DocumentSummaryIndex from
+// documents, summarizing each of them.
+func NewDocumentSummaryIndexFromDocuments(
+	ctx context.Context,
+	documents []schema.Document,
+	opts ...DocumentSummaryIndexOption,
+) (*DocumentSummaryIndex, error) {
+	dsi, err := NewDocumentSummaryIndex(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := dsi.InsertDocuments(ctx, documents); err != nil {
+		return nil, err
+	}
+	return dsi, nil
+}
+
+// InsertDocuments runs documents through the index's transformations and
+// summarizes them. The docstore tracks the chunks and hash of each
+// document.
+func (dsi *DocumentSummaryIndex) InsertDocuments(ctx context.Context, documents []schema.Document) error {
+	nodes, err := documentNodes(ctx, documents, dsi.transformations)
+	if err != nil {
+		return err
+	}
+	if err := dsi.InsertNodes(ctx, nodes); err != nil {
+		return err
+	}
+	for _, doc := range documents {
+		if doc.ID == "" {
+			continue
+		}
+		if err := dsi.storageContext.DocStore.SetDocumentHash(ctx, doc.ID, doc.GetHash()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InsertNodes inserts chunks, grouped by their source document, and
+// summarizes each document. Documents that are already indexed are
+// summarized again from all of their chunks.
+func (dsi *DocumentSummaryIndex) InsertNodes(ctx context.Context, nodes []schema.Node) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+	docStore := dsi.storageContext.DocStore
+
+	var order []string
+	chunks := make(map[string][]schema.Node)
+	inserted := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		inserted[node.ID] = true
+	}
+	for _, node := range nodes {
+		docID := nodeRefDocID(node)
+		if _, ok := chunks[docID]; !ok {
+			order = append(order, docID)
+			existing, err := dsi.documentChunks(ctx, docID)
+			if err != nil {
+				return err
+			}
+			for _, chunk := range existing {
+				if !inserted[chunk.ID] {
+					chunks[docID] = append(chunks[docID], chunk)
+				}
+			}
+			if chunks[docID] == nil {
+				chunks[docID] = []schema.Node{}
+			}
+		}
+		chunks[docID] = append(chunks[docID], node)
+	}
+
+	baseNodes := make([]schema.BaseNode, len(nodes))
+	for i := range nodes {
+		baseNodes[i] = &nodes[i]
+	}
+	if err := docStore.AddDocuments(ctx, baseNodes, true); err != nil {
+		return err
+	}
+
+	var all []schema.Node
+	for _, docID := range order {
+		all = append(all, chunks[docID]...)
+	}
+	summarizer := ingestion.NewDocumentSummarizer(dsi.llm, docStore, dsi.summarizerOpts...)
+	summaries, err := summarizer.Summarize(ctx, all)
+	if err != nil {
+		return err
+	}
+
+	for i, summary := range summaries {
+		docID := order[i]
+		summaryNode := schema.NewTextNode(summary.Summary)
+		if err := docStore.AddDocuments(ctx, []schema.BaseNode{summaryNode}, true); err != nil {
+			return err
+		}
+		if dsi.embedModel != nil {
+			embedding, err := dsi.embedModel.GetTextEmbedding(ctx, summary.Summary)
+			if err != nil {
+				return fmt.Errorf("failed to embed summary of document %s: %w", docID, err)
+			}
+			dsi.indexStruct.EmbeddingDict[summaryNode.ID] = embedding
+		}
+
+		if oldID, ok := dsi.indexStruct.NodesDict[docID]; ok {
+			dsi.removeSummary(ctx, oldID)
+		}
+		chunkIDs := make([]string, len(chunks[docID]))
+		for j, chunk := range chunks[docID] {
+			chunkIDs[j] = chunk.ID
+		}
+		dsi.indexStruct.NodesDict[docID] = summaryNode.ID
+		dsi.indexStruct.Table[summaryNode.ID] = chunkIDs
+	}
+
+	return dsi.storageContext.IndexStore.AddIndexStruct(ctx, dsi.indexStruct)
+}
+
+// DeleteNodes removes chunks from the index. A document's summary is
+// removed with its last chunk.
+func (dsi *DocumentSummaryIndex) DeleteNodes(ctx context.Context, nodeIDs []string) error {
+	deleteSet := make(map[string]bool, len(nodeIDs))
+	for _, id := range nodeIDs {
+		deleteSet[id] = true
+	}
+
+	for docID, summaryID := range dsi.indexStruct.NodesDict {
+		var kept []string
+		for _, id := range dsi.indexStruct.Table[summaryID] {
+			if !deleteSet[id] {
+				kept = append(kept, id)
+			}
+		}
+		if len(kept) == 0 {
+			delete(dsi.indexStruct.NodesDict, docID)
+			dsi.removeSummary(ctx, summaryID)
+			continue
+		}
+		dsi.indexStruct.Table[summaryID] = kept
+	}
+
+	for _, nodeID := range nodeIDs {
+		if err := dsi.storageContext.DocStore.DeleteDocument(ctx, nodeID, false); err != nil {
+			// Continue even if delete fails
+		}
+	}
+
+	return dsi.storageContext.IndexStore.AddIndexStruct(ctx, dsi.indexStruct)
+}
+
+// DeleteRefDoc removes a document's chunks and summary from the index and
+// the docstore.
+func (dsi *DocumentSummaryIndex) DeleteRefDoc(ctx context.Context, refDocID string) error {
+	if summaryID, ok := dsi.indexStruct.NodesDict[refDocID]; ok {
+		if err := dsi.DeleteNodes(ctx, dsi.indexStruct.Table[summaryID]); err != nil {
+			return err
+		}
+	}
+	return dsi.storageContext.DocStore.DeleteRefDoc(ctx, refDocID, false)
+}
+
+// RefreshDocuments inserts new documents and replaces the chunks and
+// summary of documents whose hash changed since they were inserted;
+// unchanged documents are skipped.
+func (dsi *DocumentSummaryIndex) RefreshDocuments(ctx context.Context, documents []schema.Document) ([]bool, error) {
+	refreshed := make([]bool, len(documents))
+
+	for i, doc := range documents {
+		existingHash, err := dsi.storageContext.DocStore.GetDocumentHash(ctx, doc.ID)
+		if err == nil && existingHash == doc.GetHash() {
+			continue
+		}
+		if err == nil && existingHash != "" {
+			if err := dsi.DeleteRefDoc(ctx, doc.ID); err != nil {
+				return refreshed, err
+			}
+		}
+		if err := dsi.InsertDocuments(ctx, []schema.Document{doc}); err != nil {
+			return refreshed, err
+		}
+		refreshed[i] = true
+	}
+
+	return refreshed, nil
+}
+
+// GetDocumentSummary returns the summary of an indexed document.
+func (dsi *DocumentSummaryIndex) GetDocumentSummary(ctx context.Context, docID string) (string, error) {
+	summaryID, ok := dsi.indexStruct.NodesDict[docID]
+	if !ok {
+		return "", fmt.Errorf("document %s not found in index", docID)
+	}
+	node, err := dsi.storageContext.DocStore.GetDocument(ctx, summaryID, true)
+	if err != nil {
+		return "", err
+	}
+	return node.GetContent(schema.MetadataModeNone), nil
+}
+
+// AsRetriever returns a retriever in the index's retriever mode. The
+// similarity top-k is the number of documents whose chunks are returned
+// and defaults to 1.
+func (dsi *DocumentSummaryIndex) AsRetriever(opts ...RetrieverOption) retriever.Retriever {
+	config := &RetrieverConfig{
+		SimilarityTopK: 1,
+		EmbedModel:     dsi.embedModel,
+	}
+
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	return NewDocumentSummaryIndexRetriever(dsi,
+		WithDocumentSummaryRetrieverMode(dsi.retrieverMode),
+		WithDocumentSummaryRetrieverTopK(config.SimilarityTopK),
+		WithDocumentSummaryRetrieverEmbedModel(config.EmbedModel),
+	)
+}
+
+// AsRetrieverWithMode returns a retriever with the specified mode. It fails
+// if the mode needs an embedding model that is not configured.
+func (dsi *DocumentSummaryIndex) AsRetrieverWithMode(mode DocumentSummaryRetrieverMode, opts ...DocumentSummaryIndexRetrieverOption) (retriever.Retriever, error) {
+	r := NewDocumentSummaryIndexRetriever(dsi, append([]DocumentSummaryIndexRetrieverOption{WithDocumentSummaryRetrieverMode(mode)}, opts...)...)
+	switch mode {
+	case DocumentSummaryRetrieverModeEmbedding:
+		if r.embedModel == nil {
+			return nil, fmt.Errorf("retriever mode %s requires an embedding model", mode)
+		}
+	case DocumentSummaryRetrieverModeLLM:
+		if r.llm == nil {
+			return nil, fmt.Errorf("retriever mode %s requires an LLM", mode)
+		}
+	default:
+		return nil, fmt.Errorf("unknown retriever mode: %s", mode)
+	}
+	return r, nil
+}
+
+// AsQueryEngine returns a query engine that answers from the chunks of the
+// selected documents.
+func (dsi *DocumentSummaryIndex) AsQueryEngine(opts ...QueryEngineOption) queryengine.QueryEngine {
+	config := &QueryEngineConfig{
+		ResponseMode: synthesizer.ResponseModeCompact,
+	}
+	config.SimilarityTopK = 1
+	config.EmbedModel = dsi.embedModel
+
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	l := config.LLM
+	if l == nil {
+		l = dsi.llm
+	}
+
+	ret := NewDocumentSummaryIndexRetriever(dsi,
+		WithDocumentSummaryRetrieverMode(dsi.retrieverMode),
+		WithDocumentSummaryRetrieverTopK(config.SimilarityTopK),
+		WithDocumentSummaryRetrieverEmbedModel(config.EmbedModel),
+		WithDocumentSummaryRetrieverLLM(l),
+	)
+
+	synth := config.Synthesizer
+	if synth == nil {
+		synth, _ = synthesizer.GetSynthesizer(config.ResponseMode, l)
+	}
+
+	return queryengine.NewRetrieverQueryEngine(ret, synth)
+}
+
+// documentChunks returns the indexed chunks of a document in order.
+func (dsi *DocumentSummaryIndex) documentChunks(ctx context.Context, docID string) ([]schema.Node, error) {
+	summaryID, ok := dsi.indexStruct.NodesDict[docID]
+	if !ok {
+		return nil, nil
+	}
+	return dsi.getNodes(ctx, dsi.indexStruct.Table[summaryID])
+}
+
+// getNodes returns the docstore nodes with the given IDs, skipping missing
+// ones.
+func (dsi *DocumentSummaryIndex) getNodes(ctx context.Context, nodeIDs []string) ([]schema.Node, error) {
+	if len(nodeIDs) == 0 {
+		return nil, nil
+	}
+	baseNodes, err := docstore.GetNodes(ctx, dsi.storageContext.DocStore, nodeIDs, false)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]schema.Node, 0, len(baseNodes))
+	for _, n := range baseNodes {
+		if node, ok := n.(*schema.Node); ok {
+			nodes = append(nodes, *node)
+		}
+	}
+	return nodes, nil
+}
+
+// removeSummary drops a summary node, its chunk list and its embedding.
+func (dsi *DocumentSummaryIndex) removeSummary(ctx context.Context, summaryID string) {
+	delete(dsi.indexStruct.Table, summaryID)
+	dsi.mu.Lock()
+	delete(dsi.indexStruct.EmbeddingDict, summaryID)
+	dsi.mu.Unlock()
+	_ = dsi.storageContext.DocStore.DeleteDocument(ctx, summaryID, false)
+}
+
+// nodeRefDocID returns the ID of a node's source document, or the node's
+// own ID if it has none.
+func nodeRefDocID(node schema.Node) string {
+	if source := node.Relationships.GetSource(); source != nil && source.NodeID != "" {
+		return source.NodeID
+	}
+	return node.ID
+}
+
+// DocumentSummaryIndexRetriever selects documents of a DocumentSummaryIndex
+// by their summaries and returns the selected documents' chunks, scored by
+// the relevance of their document.
+type DocumentSummaryIndexRetriever struct {
+	index           *DocumentSummaryIndex
+	mode            DocumentSummaryRetrieverMode
+	similarityTopK  int
+	embedModel      EmbeddingModel
+	llm             llm.LLM
+	choiceBatchSize int
+}
+
+// DocumentSummaryIndexRetrieverOption configures the retriever.
+type DocumentSummaryIndexRetrieverOption func(*DocumentSummaryIndexRetriever)
+
+// WithDocumentSummaryRetrieverMode sets the retriever mode.
+func WithDocumentSummaryRetrieverMode(mode DocumentSummaryRetrieverMode) DocumentSummaryIndexRetrieverOption {
+	return func(r *DocumentSummaryIndexRetriever) {
+		r.mode = mode
+	}
+}
+
+// WithDocumentSummaryRetrieverTopK sets the number of documents selected.
+// Defaults to 1; 0 keeps all documents the LLM selects, or all documents in
+// embedding mode.
+func WithDocumentSummaryRetrieverTopK(k int) DocumentSummaryIndexRetrieverOption {
+	return func(r *DocumentSummaryIndexRetriever) {
+		r.similarityTopK = k
+	}
+}
+
+// WithDocumentSummaryRetrieverEmbedModel sets the embedding model for
+// embedding mode.
+func WithDocumentSummaryRetrieverEmbedModel(model EmbeddingModel) DocumentSummaryIndexRetrieverOption {
+	return func(r *DocumentSummaryIndexRetriever) {
+		r.embedModel = model
+	}
+}
+
+// WithDocumentSummaryRetrieverLLM sets the LLM for LLM mode.
+func WithDocumentSummaryRetrieverLLM(l llm.LLM) DocumentSummaryIndexRetrieverOption {
+	return func(r *DocumentSummaryIndexRetriever) {
+		r.llm = l
+	}
+}
+
+// WithDocumentSummaryRetrieverChoiceBatchSize sets how many summaries are
+// shown to the LLM per call in LLM mode. Defaults to 10.
+func WithDocumentSummaryRetrieverChoiceBatchSize(size int) DocumentSummaryIndexRetrieverOption {
+	return func(r *DocumentSummaryIndexRetriever) {
+		if size > 0 {
+			r.choiceBatchSize = size
+		}
+	}
+}
+
+// NewDocumentSummaryIndexRetriever creates a retriever for dsi, using the
+// index's retriever mode, embedding model and LLM unless options override
+// them.
+func NewDocumentSummaryIndexRetriever(dsi *DocumentSummaryIndex, opts ...DocumentSummaryIndexRetrieverOption) *DocumentSummaryIndexRetriever {
+	r := &DocumentSummaryIndexRetriever{
+		index:           dsi,
+		mode:            dsi.retrieverMode,
+		similarityTopK:  1,
+		embedModel:      dsi.embedModel,
+		llm:             dsi.llm,
+		choiceBatchSize: 10,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Retrieve selects documents by their summaries and returns their chunks.
+func (r *DocumentSummaryIndexRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	docIDs := make([]string, 0, len(r.index.indexStruct.NodesDict))
+	for docID := range r.index.indexStruct.NodesDict {
+		docIDs = append(docIDs, docID)
+	}
+	if len(docIDs) == 0 {
+		return nil, nil
+	}
+	sort.Strings(docIDs)
+
+	summaryIDs := make([]string, len(docIDs))
+	for i, docID := range docIDs {
+		summaryIDs[i] = r.index.indexStruct.NodesDict[docID]
+	}
+	summaries, err := r.index.getNodes(ctx, summaryIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []schema.NodeWithScore
+	switch r.mode {
+	case DocumentSummaryRetrieverModeEmbedding:
+		selected, err = r.selectEmbedding(ctx, query, summaries)
+	case DocumentSummaryRetrieverModeLLM:
+		selected, err = r.selectLLM(ctx, query, summaries)
+	default:
+		err = fmt.Errorf("unknown retriever mode: %s", r.mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var results []schema.NodeWithScore
+	for _, summary := range selected {
+		chunks, err := r.index.getNodes(ctx, r.index.indexStruct.Table[summary.Node.ID])
+		if err != nil {
+			return nil, err
+		}
+		for _, chunk := range chunks {
+			results = append(results, schema.NodeWithScore{Node: chunk, Score: summary.Score})
+		}
+	}
+	return results, nil
+}
+
+// selectEmbedding ranks summaries by the similarity of their embeddings to
+// the query. Summaries inserted without an embedding model are embedded
+// once and cached in the index struct.
+func (r *DocumentSummaryIndexRetriever) selectEmbedding(ctx context.Context, query schema.QueryBundle, summaries []schema.Node) ([]schema.NodeWithScore, error) {
+	if r.embedModel == nil {
+		return nil, fmt.Errorf("embedding model not configured for embedding mode")
+	}
+
+	queryEmbedding, err := r.embedModel.GetQueryEmbedding(ctx, query.QueryString)
+	if err != nil {
+		return nil, err
+	}
+
+	embeddings := r.index.indexStruct.EmbeddingDict
+	results := make([]schema.NodeWithScore, 0, len(summaries))
+	embedded := false
+	for _, summary := range summaries {
+		r.index.mu.Lock()
+		embedding, ok := embeddings[summary.ID]
+		r.index.mu.Unlock()
+		if !ok {
+			embedding, err = r.embedModel.GetTextEmbedding(ctx, summary.Text)
+			if err != nil {
+				return nil, fmt.Errorf("failed to embed summary %s: %w", summary.ID, err)
+			}
+			r.index.mu.Lock()
+			embeddings[summary.ID] = embedding
+			r.index.mu.Unlock()
+			embedded = true
+		}
+		results = append(results, schema.NodeWithScore{Node: summary, Score: cosineSimilarity(queryEmbedding, embedding)})
+	}
+	if embedded {
+		r.index.mu.Lock()
+		err := r.index.storageContext.IndexStore.AddIndexStruct(ctx, r.index.indexStruct)
+		r.index.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if r.similarityTopK > 0 && r.similarityTopK < len(results) {
+		results = results[:r.similarityTopK]
+	}
+	return results, nil
+}
+
+// selectLLM shows the summaries to the LLM in batches and keeps the
+// documents it rates most relevant.
+func (r *DocumentSummaryIndexRetriever) selectLLM(ctx context.Context, query schema.QueryBundle, summaries []schema.Node) ([]schema.NodeWithScore, error) {
+	if r.llm == nil {
+		return nil, fmt.Errorf("LLM not configured for LLM mode")
+	}
+
+	topN := r.similarityTopK
+	if topN <= 0 {
+		topN = len(summaries)
+	}
+	selector := postprocessor.NewLLMRerank(
+		postprocessor.WithLLMRerankLLM(r.llm),
+		postprocessor.WithLLMRerankTopN(topN),
+		postprocessor.WithLLMRerankBatchSize(r.choiceBatchSize),
+	)
+	candidates := make([]schema.NodeWithScore, len(summaries))
+	for i, summary := range summaries {
+		candidates[i] = schema.NodeWithScore{Node: summary, Score: 1.0}
+	}
+	return selector.PostprocessNodes(ctx, candidates, &query)
+}
+
+// Ensure DocumentSummaryIndex implements Index.
+var _ Index = (*DocumentSummaryIndex)(nil)
+
+// Ensure DocumentSummaryIndexRetriever implements Retriever.
+var _ retriever.Retriever = (*DocumentSummaryIndexRetriever)(nil)
@@ -0,0 +1,55 @@
+package index
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aqua777/go-llamaindex/ingestion"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// documentNode converts a document to a node with the document's ID, so
+// that the document is its own reference document.
+func documentNode(doc schema.Document) schema.Node {
+	node := schema.NewTextNode(doc.Text)
+	node.Metadata = doc.Metadata
+	if doc.ID != "" {
+		node.ID = doc.ID
+	}
+	if doc.MimeType != "" {
+		node.MimeType = doc.MimeType
+	}
+	return *node
+}
+
+// documentNodes runs each document through the transformations and links
+// the resulting nodes to it with a source relationship, so that the nodes
+// of a document can be found, replaced and deleted by its ID. Without
+// transformations each document becomes a single node with its ID.
+func documentNodes(ctx context.Context, documents []schema.Document, transformations []ingestion.TransformComponent) ([]schema.Node, error) {
+	var result []schema.Node
+	for _, doc := range documents {
+		docNode := documentNode(doc)
+		nodes := []schema.Node{docNode}
+		for _, t := range transformations {
+			var err error
+			if nodes, err = t.Transform(ctx, nodes); err != nil {
+				return nil, fmt.Errorf("transformation %s failed on document %s: %w", t.Name(), docNode.ID, err)
+			}
+		}
+		for i := range nodes {
+			if nodes[i].Relationships == nil {
+				nodes[i].Relationships = make(schema.NodeRelationships)
+			}
+			if nodes[i].Relationships.GetSource() == nil {
+				nodes[i].Relationships.SetSource(schema.RelatedNodeInfo{
+					NodeID:   docNode.ID,
+					NodeType: schema.ObjectTypeDocument,
+					Hash:     doc.GetHash(),
+				})
+			}
+		}
+		result = append(result, nodes...)
+	}
+	return result, nil
+}
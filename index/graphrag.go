@@ -0,0 +1,382 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/graphstore"
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/rag/queryengine"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// Default prompts for GraphRAG community summaries and global queries.
+const (
+	DefaultCommunitySummaryPrompt = `You are given the entities and relationships of one community in a knowledge graph.
+Write a concise report describing what this community is about: the key entities, how they are related, and any notable facts.
+
+Entities: {entities}
+
+Relationships:
+{relationships}
+
+REPORT:`
+
+	DefaultGlobalMapPrompt = `Below is a report about one community of entities in a document collection.
+
+---------------------
+{summary}
+---------------------
+
+Using only this report, answer the question: {query_str}
+
+Respond in the following format:
+SCORE: <0-100, how helpful the report is for answering the question; 0 if it is not relevant>
+ANSWER: <the partial answer>
+`
+
+	DefaultGlobalReducePrompt = `Several analysts answered the same question from different parts of a document collection. Their answers are listed below, most helpful first.
+
+---------------------
+{partial_answers}
+---------------------
+
+Combine them into a single comprehensive answer to the question: {query_str}
+Leave out anything irrelevant and do not mention the analysts.
+`
+)
+
+// Community is a group of closely related entities in a knowledge graph,
+// together with an LLM-written summary of it.
+type Community struct {
+	// ID identifies the community within the index.
+	ID string
+	// Entities are the entity names in the community.
+	Entities []string
+	// Triplets are the relationships between entities of the community.
+	Triplets []graphstore.Triplet
+	// NodeIDs are the source chunks that mention the community's entities.
+	NodeIDs []string
+	// Summary is the community report.
+	Summary string
+}
+
+// CommunityBuildOption configures BuildCommunities.
+type CommunityBuildOption func(*communityBuildConfig)
+
+type communityBuildConfig struct {
+	detectOpts      []graphstore.CommunityOption
+	summaryTemplate *prompts.PromptTemplate
+	maxTriplets     int
+}
+
+// WithCommunityDetectionOptions passes options to community detection,
+// e.g. graphstore.WithCommunityResolution.
+func WithCommunityDetectionOptions(opts ...graphstore.CommunityOption) CommunityBuildOption {
+	return func(c *communityBuildConfig) {
+		c.detectOpts = append(c.detectOpts, opts...)
+	}
+}
+
+// WithCommunitySummaryTemplate sets the community summary prompt. It uses
+// the {entities} and {relationships} placeholders.
+func WithCommunitySummaryTemplate(tmpl *prompts.PromptTemplate) CommunityBuildOption {
+	return func(c *communityBuildConfig) {
+		c.summaryTemplate = tmpl
+	}
+}
+
+// WithCommunityMaxTriplets caps the relationships included in each summary
+// prompt. Defaults to 100.
+func WithCommunityMaxTriplets(n int) CommunityBuildOption {
+	return func(c *communityBuildConfig) {
+		if n > 0 {
+			c.maxTriplets = n
+		}
+	}
+}
+
+// BuildCommunities detects communities in the entity graph with the
+// Louvain method and summarizes each one with the index LLM. The
+// communities replace any built previously and are used by the global
+// query engine.
+func (kg *KnowledgeGraphIndex) BuildCommunities(ctx context.Context, opts ...CommunityBuildOption) ([]Community, error) {
+	if kg.llm == nil {
+		return nil, fmt.Errorf("LLM must be provided to summarize communities")
+	}
+
+	config := &communityBuildConfig{
+		summaryTemplate: prompts.NewPromptTemplate(DefaultCommunitySummaryPrompt, prompts.PromptTypeSummary),
+		maxTriplets:     100,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	triplets, err := kg.allTriplets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := graphstore.DetectCommunities(triplets, config.detectOpts...)
+	communityOf := make(map[string]int)
+	for i, entities := range groups {
+		for _, entity := range entities {
+			communityOf[entity] = i
+		}
+	}
+
+	communities := make([]Community, len(groups))
+	for i, entities := range groups {
+		communities[i] = Community{
+			ID:       fmt.Sprintf("community-%d", i),
+			Entities: entities,
+		}
+	}
+	for _, t := range triplets {
+		c := communityOf[t.Subject]
+		if communityOf[t.Object] == c {
+			communities[c].Triplets = append(communities[c].Triplets, t)
+		}
+	}
+
+	for i := range communities {
+		community := &communities[i]
+		community.NodeIDs = kg.sourceNodeIDs(community.Entities)
+
+		relationships := community.Triplets
+		if len(relationships) > config.maxTriplets {
+			relationships = relationships[:config.maxTriplets]
+		}
+		lines := make([]string, len(relationships))
+		for j, t := range relationships {
+			lines[j] = t.String()
+		}
+
+		prompt := config.summaryTemplate.Format(map[string]string{
+			"entities":      strings.Join(community.Entities, ", "),
+			"relationships": strings.Join(lines, "\n"),
+		})
+		summary, err := kg.llm.Complete(ctx, prompt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to summarize %s: %w", community.ID, err)
+		}
+		community.Summary = strings.TrimSpace(summary)
+	}
+
+	kg.communities = communities
+	return communities, nil
+}
+
+// Communities returns the communities built by BuildCommunities.
+func (kg *KnowledgeGraphIndex) Communities() []Community {
+	return kg.communities
+}
+
+// allTriplets returns every triplet in the graph store, sorted.
+func (kg *KnowledgeGraphIndex) allTriplets(ctx context.Context) ([]graphstore.Triplet, error) {
+	subjects, err := kg.graphStore.GetAllSubjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(subjects)
+
+	var triplets []graphstore.Triplet
+	for _, subj := range subjects {
+		relObjs, err := kg.graphStore.Get(ctx, subj)
+		if err != nil {
+			return nil, err
+		}
+		for _, relObj := range relObjs {
+			if len(relObj) >= 2 {
+				triplets = append(triplets, graphstore.Triplet{Subject: subj, Relation: relObj[0], Object: relObj[1]})
+			}
+		}
+	}
+	return triplets, nil
+}
+
+// sourceNodeIDs returns the IDs of the chunks that mention any of the
+// entities, in first-seen order.
+func (kg *KnowledgeGraphIndex) sourceNodeIDs(entities []string) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, entity := range entities {
+		for _, id := range kg.indexStruct.Table[entity] {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// KGGlobalQueryEngine answers corpus-level questions ("What are the main
+// themes?") from community summaries instead of chunk retrieval, following
+// GraphRAG's global search. Each community report is asked for a partial
+// answer and a helpfulness score (map), and the most helpful partial
+// answers are combined into the final response (reduce).
+type KGGlobalQueryEngine struct {
+	*queryengine.BaseQueryEngine
+	index          *KnowledgeGraphIndex
+	llm            llm.LLM
+	mapTemplate    *prompts.PromptTemplate
+	reduceTemplate *prompts.PromptTemplate
+	maxPartials    int
+}
+
+// KGGlobalQueryOption configures a KGGlobalQueryEngine.
+type KGGlobalQueryOption func(*KGGlobalQueryEngine)
+
+// WithGlobalQueryLLM sets the LLM. Defaults to the index LLM.
+func WithGlobalQueryLLM(l llm.LLM) KGGlobalQueryOption {
+	return func(e *KGGlobalQueryEngine) {
+		e.llm = l
+	}
+}
+
+// WithGlobalMapTemplate sets the map prompt. It uses the {summary} and
+// {query_str} placeholders and must ask for SCORE and ANSWER lines.
+func WithGlobalMapTemplate(tmpl *prompts.PromptTemplate) KGGlobalQueryOption {
+	return func(e *KGGlobalQueryEngine) {
+		e.mapTemplate = tmpl
+	}
+}
+
+// WithGlobalReduceTemplate sets the reduce prompt. It uses the
+// {partial_answers} and {query_str} placeholders.
+func WithGlobalReduceTemplate(tmpl *prompts.PromptTemplate) KGGlobalQueryOption {
+	return func(e *KGGlobalQueryEngine) {
+		e.reduceTemplate = tmpl
+	}
+}
+
+// WithGlobalMaxPartials caps the partial answers passed to the reduce step.
+// Defaults to 10.
+func WithGlobalMaxPartials(n int) KGGlobalQueryOption {
+	return func(e *KGGlobalQueryEngine) {
+		if n > 0 {
+			e.maxPartials = n
+		}
+	}
+}
+
+// AsGlobalQueryEngine returns a query engine that answers from community
+// summaries. BuildCommunities must be called first.
+func (kg *KnowledgeGraphIndex) AsGlobalQueryEngine(opts ...KGGlobalQueryOption) (*KGGlobalQueryEngine, error) {
+	e := &KGGlobalQueryEngine{
+		BaseQueryEngine: queryengine.NewBaseQueryEngine(),
+		index:           kg,
+		llm:             kg.llm,
+		mapTemplate:     prompts.NewPromptTemplate(DefaultGlobalMapPrompt, prompts.PromptTypeQuestionAnswer),
+		reduceTemplate:  prompts.NewPromptTemplate(DefaultGlobalReducePrompt, prompts.PromptTypeSummary),
+		maxPartials:     10,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	if e.llm == nil {
+		return nil, fmt.Errorf("LLM must be provided for global queries")
+	}
+	if len(kg.communities) == 0 {
+		return nil, fmt.Errorf("no communities built; call BuildCommunities first")
+	}
+	return e, nil
+}
+
+var (
+	globalScorePattern  = regexp.MustCompile(`(?i)SCORE:\s*(\d+)`)
+	globalAnswerPattern = regexp.MustCompile(`(?is)ANSWER:\s*(.*)`)
+)
+
+// Query implements queryengine.QueryEngine.
+func (e *KGGlobalQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	type partial struct {
+		community Community
+		score     int
+		answer    string
+	}
+
+	var partials []partial
+	for _, community := range e.index.communities {
+		prompt := e.mapTemplate.Format(map[string]string{
+			"summary":   community.Summary,
+			"query_str": query,
+		})
+		response, err := e.llm.Complete(ctx, prompt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s: %w", community.ID, err)
+		}
+
+		score, answer := parseGlobalMapResponse(response)
+		if score <= 0 || answer == "" {
+			continue
+		}
+		partials = append(partials, partial{community: community, score: score, answer: answer})
+	}
+
+	sort.SliceStable(partials, func(i, j int) bool {
+		return partials[i].score > partials[j].score
+	})
+	if len(partials) > e.maxPartials {
+		partials = partials[:e.maxPartials]
+	}
+
+	sourceNodes := make([]schema.NodeWithScore, len(partials))
+	for i, p := range partials {
+		node := schema.NewTextNode(p.community.Summary)
+		node.ID = p.community.ID
+		node.Metadata = map[string]interface{}{
+			"community_id": p.community.ID,
+			"entities":     p.community.Entities,
+		}
+		sourceNodes[i] = schema.NodeWithScore{Node: *node, Score: float64(p.score) / 100}
+	}
+
+	if len(partials) == 0 {
+		return synthesizer.NewResponse("I could not find information relevant to this question in the document collection.", sourceNodes), nil
+	}
+
+	lines := make([]string, len(partials))
+	for i, p := range partials {
+		lines[i] = fmt.Sprintf("Analyst %d (score %d):\n%s", i+1, p.score, p.answer)
+	}
+	prompt := e.reduceTemplate.Format(map[string]string{
+		"partial_answers": strings.Join(lines, "\n\n"),
+		"query_str":       query,
+	})
+	answer, err := e.llm.Complete(ctx, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to combine partial answers: %w", err)
+	}
+
+	return synthesizer.NewResponse(strings.TrimSpace(answer), sourceNodes), nil
+}
+
+// parseGlobalMapResponse extracts the score and answer from a map response.
+func parseGlobalMapResponse(response string) (int, string) {
+	score := 0
+	if m := globalScorePattern.FindStringSubmatch(response); m != nil {
+		score, _ = strconv.Atoi(m[1])
+	}
+	if score > 100 {
+		score = 100
+	}
+	answer := ""
+	if m := globalAnswerPattern.FindStringSubmatch(response); m != nil {
+		answer = strings.TrimSpace(m[1])
+	}
+	return score, answer
+}
+
+// Ensure KGGlobalQueryEngine implements QueryEngine.
+var _ queryengine.QueryEngine = (*KGGlobalQueryEngine)(nil)
@@ -2,12 +2,22 @@ package index
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/aqua777/go-llamaindex/graphstore"
+	"github.com/aqua777/go-llamaindex/ingestion"
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/nodeparser"
+	"github.com/aqua777/go-llamaindex/rag/retriever"
 	"github.com/aqua777/go-llamaindex/rag/store"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
 	"github.com/aqua777/go-llamaindex/schema"
 	"github.com/aqua777/go-llamaindex/storage"
+	"github.com/aqua777/go-llamaindex/storage/docstore"
+	"github.com/aqua777/go-llamaindex/storage/indexstore"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -163,6 +173,97 @@ func TestVectorStoreIndex(t *testing.T) {
 		qe := vsi.AsQueryEngine()
 		assert.NotNil(t, qe)
 	})
+
+	t.Run("FromDocuments", func(t *testing.T) {
+		sc := storage.NewStorageContext()
+		sc.SetVectorStore(store.NewSimpleVectorStore())
+
+		docs := []schema.Document{
+			{ID: "runbook", Text: "Restart the pod. Check the logs. Page the owner.", Metadata: map[string]interface{}{"team": "sre"}},
+			{ID: "faq", Text: "The API listens on port 8080.", Metadata: map[string]interface{}{"team": "api"}},
+		}
+		vsi, err := NewVectorStoreIndexFromDocuments(ctx, docs,
+			WithVectorIndexStorageContext(sc),
+			WithVectorIndexEmbedModel(NewMockEmbeddingModel()),
+			WithVectorIndexTransformations(ingestion.NewNodeParserTransform(nodeparser.NewSentenceNodeParserWithConfig(6, 0))),
+		)
+		require.NoError(t, err)
+		assert.Greater(t, len(vsi.IndexStruct().NodesDict), 2)
+
+		info, err := sc.DocStore.GetRefDocInfo(ctx, "runbook")
+		require.NoError(t, err)
+		require.NotNil(t, info)
+		assert.Greater(t, len(info.NodeIDs), 1)
+		hash, err := sc.DocStore.GetDocumentHash(ctx, "faq")
+		require.NoError(t, err)
+		assert.Equal(t, docs[1].GetHash(), hash)
+
+		ret := vsi.AsRetriever(WithSimilarityTopK(10), WithRetrieverFilters(schema.NewMetadataFilters(schema.NewMetadataFilter("team", "api"))))
+		results, err := ret.Retrieve(ctx, schema.QueryBundle{QueryString: "port"})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "faq", results[0].Node.Relationships.GetSource().NodeID)
+
+		// Query filters are combined with the retriever's filters.
+		ret = vsi.AsRetriever(WithSimilarityTopK(10), WithRetrieverFilters(schema.MustParseMetadataFilters(`team in ["sre", "api"]`)))
+		results, err = ret.Retrieve(ctx, schema.QueryBundle{QueryString: "logs", Filters: schema.MustParseMetadataFilters(`team != "api"`)})
+		require.NoError(t, err)
+		require.NotEmpty(t, results)
+		for _, r := range results {
+			assert.Equal(t, "sre", r.Node.Metadata["team"])
+		}
+	})
+
+	t.Run("DeleteRefDoc", func(t *testing.T) {
+		sc := storage.NewStorageContext()
+		sc.SetVectorStore(store.NewSimpleVectorStore())
+
+		vsi, err := NewVectorStoreIndexFromDocuments(ctx,
+			[]schema.Document{{ID: "a", Text: "Alpha one. Alpha two. Alpha three."}, {ID: "b", Text: "Beta."}},
+			WithVectorIndexStorageContext(sc),
+			WithVectorIndexEmbedModel(NewMockEmbeddingModel()),
+			WithVectorIndexTransformations(ingestion.NewNodeParserTransform(nodeparser.NewSentenceNodeParserWithConfig(4, 0))),
+		)
+		require.NoError(t, err)
+
+		require.NoError(t, vsi.DeleteRefDoc(ctx, "a"))
+		results, err := vsi.AsRetriever(WithSimilarityTopK(10)).Retrieve(ctx, schema.QueryBundle{QueryString: "alpha"})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "Beta.", results[0].Node.Text)
+		assert.Len(t, vsi.IndexStruct().NodesDict, 1)
+		info, err := sc.DocStore.GetRefDocInfo(ctx, "a")
+		require.NoError(t, err)
+		assert.Nil(t, info)
+	})
+
+	t.Run("RefreshDocuments", func(t *testing.T) {
+		sc := storage.NewStorageContext()
+		sc.SetVectorStore(store.NewSimpleVectorStore())
+
+		vsi, err := NewVectorStoreIndexFromDocuments(ctx,
+			[]schema.Document{{ID: "a", Text: "Version one."}, {ID: "b", Text: "Unchanged."}},
+			WithVectorIndexStorageContext(sc),
+			WithVectorIndexEmbedModel(NewMockEmbeddingModel()),
+		)
+		require.NoError(t, err)
+
+		refreshed, err := vsi.RefreshDocuments(ctx, []schema.Document{
+			{ID: "a", Text: "Version two."},
+			{ID: "b", Text: "Unchanged."},
+			{ID: "c", Text: "New document."},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []bool{true, false, true}, refreshed)
+
+		results, err := vsi.AsRetriever(WithSimilarityTopK(10)).Retrieve(ctx, schema.QueryBundle{QueryString: "version"})
+		require.NoError(t, err)
+		var texts []string
+		for _, r := range results {
+			texts = append(texts, r.Node.Text)
+		}
+		assert.ElementsMatch(t, []string{"Version two.", "Unchanged.", "New document."}, texts)
+	})
 }
 
 // TestSummaryIndex tests the SummaryIndex.
@@ -348,6 +449,29 @@ func TestKeywordTableIndex(t *testing.T) {
 		// Should find at least one result
 		assert.GreaterOrEqual(t, len(results), 1)
 	})
+
+	t.Run("analyzer synonyms", func(t *testing.T) {
+		sc := storage.NewStorageContext()
+		k8s := schema.NewTextNode("Deploying services on Kubernetes clusters")
+		k8s.ID = "k8s"
+		db := schema.NewTextNode("Tuning database indexes")
+		db.ID = "db"
+
+		analyzer := retriever.NewAnalyzer(
+			retriever.WithAnalyzerStemmer(retriever.EnglishPluralStemmer),
+			retriever.WithAnalyzerSynonyms(map[string][]string{"kubernetes": {"k8s"}}),
+		)
+		kti, err := NewKeywordTableIndex(ctx, []schema.Node{*k8s, *db},
+			WithKeywordTableStorageContext(sc),
+			WithKeywordTableAnalyzer(analyzer),
+		)
+		require.NoError(t, err)
+
+		results, err := kti.AsRetriever().Retrieve(ctx, schema.QueryBundle{QueryString: "k8s cluster"})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "k8s", results[0].Node.ID)
+	})
 }
 
 // TestSimpleKeywordExtractor tests the SimpleKeywordExtractor.
@@ -435,6 +559,234 @@ func TestSummaryIndexRetriever(t *testing.T) {
 		// Default mode returns all nodes
 		assert.Equal(t, 3, len(results))
 	})
+
+	t.Run("RetrieveEmbedding", func(t *testing.T) {
+		embedModel := NewMockEmbeddingModel()
+		embedModel.SetEmbedding("cats", []float64{1, 0})
+		embedModel.SetEmbedding("Cats purr.", []float64{0.9, 0.1})
+		embedModel.SetEmbedding("Dogs bark.", []float64{0.1, 0.9})
+		embedModel.SetEmbedding("Cats nap.", []float64{1, 0})
+
+		si, err := NewSummaryIndexFromDocuments(ctx,
+			[]schema.Document{{ID: "a", Text: "Cats purr."}, {ID: "b", Text: "Dogs bark."}, {ID: "c", Text: "Cats nap."}},
+			WithSummaryIndexStorageContext(storage.NewStorageContext()),
+			WithSummaryIndexEmbedModel(embedModel),
+		)
+		require.NoError(t, err)
+
+		ret, err := si.AsRetrieverWithMode(SummaryRetrieverModeEmbedding, WithSummaryRetrieverTopK(2))
+		require.NoError(t, err)
+		results, err := ret.Retrieve(ctx, schema.QueryBundle{QueryString: "cats"})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, "Cats nap.", results[0].Node.Text)
+		assert.Equal(t, "Cats purr.", results[1].Node.Text)
+
+		// Embeddings are cached in the docstore.
+		nodes, err := si.GetNodes(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []float64{0.1, 0.9}, nodes[1].Embedding)
+	})
+
+	t.Run("RetrieveLLM", func(t *testing.T) {
+		si, err := NewSummaryIndexFromDocuments(ctx,
+			[]schema.Document{{ID: "a", Text: "Refund policy."}, {ID: "b", Text: "Shipping times."}, {ID: "c", Text: "Returns window."}},
+			WithSummaryIndexStorageContext(storage.NewStorageContext()),
+			WithSummaryIndexRetrieverMode(SummaryRetrieverModeLLM),
+			WithSummaryIndexLLM(llm.NewMockLLM("Doc: 3, Relevance: 6\nDoc: 1, Relevance: 9")),
+		)
+		require.NoError(t, err)
+
+		results, err := si.AsRetriever().Retrieve(ctx, schema.QueryBundle{QueryString: "How do refunds work?"})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, "Refund policy.", results[0].Node.Text)
+		assert.Equal(t, 9.0, results[0].Score)
+		assert.Equal(t, "Returns window.", results[1].Node.Text)
+	})
+
+	t.Run("AsRetrieverWithMode", func(t *testing.T) {
+		si, err := NewSummaryIndex(ctx, nil, WithSummaryIndexStorageContext(storage.NewStorageContext()))
+		require.NoError(t, err)
+
+		_, err = si.AsRetrieverWithMode(SummaryRetrieverModeEmbedding)
+		assert.Error(t, err)
+		_, err = si.AsRetrieverWithMode(SummaryRetrieverModeLLM)
+		assert.Error(t, err)
+		_, err = si.AsRetrieverWithMode("unknown")
+		assert.Error(t, err)
+		_, err = si.AsRetrieverWithMode(SummaryRetrieverModeLLM, WithSummaryRetrieverLLM(llm.NewMockLLM("")))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Documents", func(t *testing.T) {
+		si, err := NewSummaryIndexFromDocuments(ctx,
+			[]schema.Document{{ID: "guide", Text: "Step one. Step two. Step three."}, {ID: "faq", Text: "Question."}},
+			WithSummaryIndexStorageContext(storage.NewStorageContext()),
+			WithSummaryIndexTransformations(ingestion.NewNodeParserTransform(nodeparser.NewSentenceNodeParserWithConfig(4, 0))),
+		)
+		require.NoError(t, err)
+		chunks := len(si.IndexStruct().Nodes)
+		assert.Greater(t, chunks, 2)
+
+		refreshed, err := si.RefreshDocuments(ctx, []schema.Document{{ID: "guide", Text: "Step one. Step two. Step three."}, {ID: "faq", Text: "Answer."}})
+		require.NoError(t, err)
+		assert.Equal(t, []bool{false, true}, refreshed)
+
+		nodes, err := si.GetNodes(ctx)
+		require.NoError(t, err)
+		assert.Len(t, nodes, chunks)
+		assert.Equal(t, "Answer.", nodes[len(nodes)-1].Text)
+
+		require.NoError(t, si.DeleteRefDoc(ctx, "guide"))
+		assert.Len(t, si.IndexStruct().Nodes, 1)
+	})
+
+	t.Run("AsQueryEngine", func(t *testing.T) {
+		si, err := NewSummaryIndexFromDocuments(ctx,
+			[]schema.Document{{ID: "a", Text: "Part one."}, {ID: "b", Text: "Part two."}},
+			WithSummaryIndexStorageContext(storage.NewStorageContext()),
+		)
+		require.NoError(t, err)
+
+		qe := si.AsQueryEngine(WithQueryEngineLLM(llm.NewMockLLM("A summary.")), WithResponseMode(synthesizer.ResponseModeCompact))
+		resp, err := qe.Query(ctx, "Summarize.")
+		require.NoError(t, err)
+		assert.Equal(t, "A summary.", resp.Response)
+		assert.Len(t, resp.SourceNodes, 2)
+	})
+}
+
+// TestDocumentSummaryIndex tests summary generation and summary-based
+// retrieval.
+func TestDocumentSummaryIndex(t *testing.T) {
+	ctx := context.Background()
+
+	summarize := func(prompt string) string {
+		switch {
+		case strings.Contains(prompt, "Refunds are issued"):
+			return "SUMMARY: Refund policy.\nKEYWORDS: refunds"
+		case strings.Contains(prompt, "Parcels ship"):
+			return "SUMMARY: Shipping times.\nKEYWORDS: shipping"
+		case strings.Contains(prompt, "Parcels now ship"):
+			return "SUMMARY: Faster shipping times.\nKEYWORDS: shipping"
+		default:
+			return "Doc: 2, Relevance: 8"
+		}
+	}
+	docs := []schema.Document{
+		{ID: "refunds", Text: "Refunds are issued within 14 days. Store credit is instant."},
+		{ID: "shipping", Text: "Parcels ship in three days. Express takes one day."},
+	}
+	newIndex := func(t *testing.T, l *scriptedLLM, opts ...DocumentSummaryIndexOption) *DocumentSummaryIndex {
+		t.Helper()
+		dsi, err := NewDocumentSummaryIndexFromDocuments(ctx, docs, append([]DocumentSummaryIndexOption{
+			WithDocumentSummaryIndexLLM(l),
+			WithDocumentSummaryIndexStorageContext(storage.NewStorageContext()),
+			WithDocumentSummaryIndexTransformations(ingestion.NewNodeParserTransform(nodeparser.NewSentenceNodeParserWithConfig(8, 0))),
+			WithDocumentSummaryIndexSummarizerOptions(ingestion.WithDocumentSummaryWorkers(1)),
+		}, opts...)...)
+		require.NoError(t, err)
+		return dsi
+	}
+
+	t.Run("RequiresLLM", func(t *testing.T) {
+		_, err := NewDocumentSummaryIndex(ctx, WithDocumentSummaryIndexStorageContext(storage.NewStorageContext()))
+		assert.Error(t, err)
+	})
+
+	t.Run("Summaries", func(t *testing.T) {
+		l := &scriptedLLM{MockLLM: llm.NewMockLLM(""), respond: summarize}
+		dsi := newIndex(t, l)
+		assert.Len(t, l.prompts, 2)
+		assert.Equal(t, indexstore.IndexStructTypeDocumentSummary, dsi.IndexStruct().Type)
+
+		summary, err := dsi.GetDocumentSummary(ctx, "refunds")
+		require.NoError(t, err)
+		assert.Equal(t, "Refund policy.", summary)
+		stored, err := docstore.GetDocumentSummary(ctx, dsi.StorageContext().DocStore, "shipping")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"shipping"}, stored.Keywords)
+		_, err = dsi.GetDocumentSummary(ctx, "missing")
+		assert.Error(t, err)
+	})
+
+	t.Run("RetrieveLLM", func(t *testing.T) {
+		l := &scriptedLLM{MockLLM: llm.NewMockLLM(""), respond: summarize}
+		dsi := newIndex(t, l)
+
+		results, err := dsi.AsRetriever().Retrieve(ctx, schema.QueryBundle{QueryString: "How fast is delivery?"})
+		require.NoError(t, err)
+		require.Greater(t, len(results), 1, "all chunks of the selected document are returned")
+		assert.Equal(t, "Parcels ship in three days.", results[0].Node.Text)
+		assert.Equal(t, "Express takes one day.", results[1].Node.Text)
+		for _, r := range results {
+			assert.Equal(t, 8.0, r.Score)
+		}
+		assert.Contains(t, l.prompts[len(l.prompts)-1], "Shipping times.")
+	})
+
+	t.Run("RetrieveEmbedding", func(t *testing.T) {
+		embedModel := NewMockEmbeddingModel()
+		embedModel.SetEmbedding("Refund policy.", []float64{1, 0})
+		embedModel.SetEmbedding("Shipping times.", []float64{0, 1})
+		embedModel.SetEmbedding("Can I get my money back?", []float64{0.9, 0.1})
+		l := &scriptedLLM{MockLLM: llm.NewMockLLM(""), respond: summarize}
+		dsi := newIndex(t, l, WithDocumentSummaryIndexEmbedModel(embedModel))
+		assert.Len(t, dsi.IndexStruct().EmbeddingDict, 2)
+
+		results, err := dsi.AsRetriever().Retrieve(ctx, schema.QueryBundle{QueryString: "Can I get my money back?"})
+		require.NoError(t, err)
+		require.NotEmpty(t, results)
+		assert.Contains(t, results[0].Node.Text, "Refunds")
+		assert.Len(t, l.prompts, 2, "embedding mode needs no LLM calls")
+
+		results, err = dsi.AsRetriever(WithSimilarityTopK(2)).Retrieve(ctx, schema.QueryBundle{QueryString: "Can I get my money back?"})
+		require.NoError(t, err)
+		assert.Equal(t, "Express takes one day.", results[len(results)-1].Node.Text)
+	})
+
+	t.Run("AsRetrieverWithMode", func(t *testing.T) {
+		dsi := newIndex(t, &scriptedLLM{MockLLM: llm.NewMockLLM(""), respond: summarize})
+
+		_, err := dsi.AsRetrieverWithMode(DocumentSummaryRetrieverModeEmbedding)
+		assert.Error(t, err)
+		_, err = dsi.AsRetrieverWithMode("unknown")
+		assert.Error(t, err)
+		_, err = dsi.AsRetrieverWithMode(DocumentSummaryRetrieverModeEmbedding, WithDocumentSummaryRetrieverEmbedModel(NewMockEmbeddingModel()))
+		assert.NoError(t, err)
+	})
+
+	t.Run("RefreshAndDelete", func(t *testing.T) {
+		l := &scriptedLLM{MockLLM: llm.NewMockLLM(""), respond: summarize}
+		dsi := newIndex(t, l)
+		oldSummaryID := dsi.IndexStruct().NodesDict["shipping"]
+
+		refreshed, err := dsi.RefreshDocuments(ctx, []schema.Document{docs[0], {ID: "shipping", Text: "Parcels now ship in one day."}})
+		require.NoError(t, err)
+		assert.Equal(t, []bool{false, true}, refreshed)
+		summary, err := dsi.GetDocumentSummary(ctx, "shipping")
+		require.NoError(t, err)
+		assert.Equal(t, "Faster shipping times.", summary)
+		assert.NotContains(t, dsi.IndexStruct().Table, oldSummaryID)
+
+		require.NoError(t, dsi.DeleteRefDoc(ctx, "refunds"))
+		assert.Len(t, dsi.IndexStruct().NodesDict, 1)
+		assert.Len(t, dsi.IndexStruct().Table, 1)
+		_, err = dsi.GetDocumentSummary(ctx, "refunds")
+		assert.Error(t, err)
+	})
+
+	t.Run("AsQueryEngine", func(t *testing.T) {
+		l := &scriptedLLM{MockLLM: llm.NewMockLLM(""), respond: summarize}
+		dsi := newIndex(t, l, WithDocumentSummaryIndexEmbedModel(NewMockEmbeddingModel()))
+
+		qe := dsi.AsQueryEngine(WithQueryEngineLLM(llm.NewMockLLM("Parcels ship in three days.")))
+		resp, err := qe.Query(ctx, "How fast is delivery?")
+		require.NoError(t, err)
+		assert.Equal(t, "Parcels ship in three days.", resp.Response)
+		require.NotEmpty(t, resp.SourceNodes)
+	})
 }
 
 // TestIndexInterface tests that all index types implement the Index interface.
@@ -927,6 +1279,14 @@ func TestTreeIndexHelpers(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("generateSummary without LLM", func(t *testing.T) {
+		text := strings.Repeat("é", 600)
+		summary, err := (&TreeIndex{}).generateSummary(context.Background(), text)
+		require.NoError(t, err)
+		assert.True(t, utf8.ValidString(summary))
+		assert.Equal(t, strings.Repeat("é", 500)+"...", summary)
+	})
 }
 
 // TestTreeIndexOptions tests TreeIndex options.
@@ -1122,6 +1482,37 @@ func TestKnowledgeGraphIndex(t *testing.T) {
 		assert.NotNil(t, qe)
 	})
 
+	t.Run("FromDocuments_with_triplet_extractor", func(t *testing.T) {
+		l := &scriptedLLM{MockLLM: llm.NewMockLLM(""), respond: func(prompt string) string {
+			if strings.Contains(prompt, "Text: Alice works at ACME.") {
+				return "(Alice, works at, ACME)"
+			}
+			return "(Bob, knows, Carol)"
+		}}
+		docs := []schema.Document{
+			{ID: "doc-1", Text: "Alice works at ACME."},
+			{ID: "doc-2", Text: "Bob knows Carol."},
+		}
+		kg, err := NewKnowledgeGraphIndexFromDocuments(ctx, docs,
+			WithKGIndexTransformations(ingestion.NewTripletExtractor(l, ingestion.WithTripletExtractWorkers(1))),
+			WithKGIndexTripletExtractFn(func(string) ([]graphstore.Triplet, error) {
+				return nil, fmt.Errorf("triplets should come from the transformation")
+			}),
+		)
+		require.NoError(t, err)
+		assert.Len(t, l.prompts, 2)
+
+		rels, err := kg.GraphStore().Get(ctx, "Alice")
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"works at", "ACME"}}, rels)
+		assert.Contains(t, kg.SearchNodeByKeyword("Carol"), "doc-2")
+
+		refreshed, err := kg.RefreshDocuments(ctx, append(docs, schema.Document{ID: "doc-3", Text: "Alice knows Bob."}))
+		require.NoError(t, err)
+		assert.Equal(t, []bool{false, false, true}, refreshed)
+		assert.Len(t, l.prompts, 3)
+	})
+
 	t.Run("DeleteNodes_returns_error", func(t *testing.T) {
 		kg, err := NewKnowledgeGraphIndex(ctx, nil)
 		require.NoError(t, err)
@@ -1225,6 +1616,96 @@ func TestKGRAGRetriever(t *testing.T) {
 		)
 		assert.NotNil(t, ret)
 	})
+	// Alice works at ACME, ACME is based in Berlin, Bob knows Carol.
+	graphDocs := []schema.Document{
+		{ID: "alice", Text: "Alice works at ACME."},
+		{ID: "acme", Text: "ACME is based in Berlin."},
+		{ID: "bob", Text: "Bob knows Carol."},
+	}
+	graphTriplets := map[string][]graphstore.Triplet{
+		"Alice works at ACME.":     {{Subject: "Alice", Relation: "works at", Object: "ACME"}},
+		"ACME is based in Berlin.": {{Subject: "ACME", Relation: "based in", Object: "Berlin"}},
+		"Bob knows Carol.":         {{Subject: "Bob", Relation: "knows", Object: "Carol"}},
+	}
+	newGraph := func(t *testing.T, opts ...KGIndexOption) *KnowledgeGraphIndex {
+		opts = append([]KGIndexOption{WithKGIndexTripletExtractFn(func(text string) ([]graphstore.Triplet, error) {
+			return graphTriplets[text], nil
+		})}, opts...)
+		kg, err := NewKnowledgeGraphIndexFromDocuments(ctx, graphDocs, opts...)
+		require.NoError(t, err)
+		return kg
+	}
+
+	t.Run("Retrieve_subgraph", func(t *testing.T) {
+		kg := newGraph(t)
+		ret := NewKGRAGRetriever(kg,
+			WithKGRAGEntityExtractFn(func(string) ([]string, error) { return []string{"alice"}, nil }),
+		)
+
+		results, err := ret.Retrieve(ctx, schema.QueryBundle{QueryString: "Where is alice's employer?"})
+		require.NoError(t, err)
+		require.NotEmpty(t, results)
+		relNode := results[len(results)-1].Node
+		assert.Contains(t, relNode.Text, "[Alice, works at, ACME]")
+		assert.Contains(t, relNode.Text, "[ACME, based in, Berlin]", "the subgraph is expanded two hops")
+		assert.NotContains(t, relNode.Text, "Carol")
+		assert.Contains(t, relNode.Metadata["kg_entities"], "Alice")
+
+		var chunkIDs []string
+		for _, r := range results[:len(results)-1] {
+			chunkIDs = append(chunkIDs, r.Node.ID)
+		}
+		assert.ElementsMatch(t, []string{"alice", "acme"}, chunkIDs)
+
+		ret = NewKGRAGRetriever(kg,
+			WithKGRAGEntityExtractFn(func(string) ([]string, error) { return []string{"alice"}, nil }),
+			WithKGRAGRetrieverOptions(WithKGRetrieverGraphDepth(1), WithKGRetrieverIncludeText(false)),
+		)
+		results, err = ret.Retrieve(ctx, schema.QueryBundle{QueryString: "alice"})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.NotContains(t, results[0].Node.Text, "Berlin")
+	})
+
+	t.Run("Retrieve_synonyms", func(t *testing.T) {
+		kg := newGraph(t)
+		ret := NewKGRAGRetriever(kg,
+			WithKGRAGEntityExtractFn(func(string) ([]string, error) { return []string{"Robert"}, nil }),
+			WithKGRAGSynonymExpandFn(func(entity string) ([]string, error) {
+				return []string{"Rob", "Bob", "Bobby"}, nil
+			}),
+			WithKGRAGMaxSynonyms(2),
+		)
+		results, err := ret.Retrieve(ctx, schema.QueryBundle{QueryString: "Who does Robert know?"})
+		require.NoError(t, err)
+		require.NotEmpty(t, results)
+		assert.Contains(t, results[len(results)-1].Node.Text, "[Bob, knows, Carol]")
+
+		ret = NewKGRAGRetriever(kg,
+			WithKGRAGEntityExtractFn(func(string) ([]string, error) { return []string{"Dave"}, nil }),
+		)
+		results, err = ret.Retrieve(ctx, schema.QueryBundle{QueryString: "Who is Dave?"})
+		require.NoError(t, err)
+		assert.Empty(t, results)
+	})
+
+	t.Run("Retrieve_LLM", func(t *testing.T) {
+		l := &scriptedLLM{MockLLM: llm.NewMockLLM(""), respond: func(prompt string) string {
+			if strings.Contains(prompt, "SYNONYMS") {
+				return "SYNONYMS: Acme Corp, ACME"
+			}
+			return "KEYWORDS: acme corporation"
+		}}
+		kg := newGraph(t, WithKGIndexLLM(l))
+		ret := NewKGRAGRetriever(kg)
+
+		results, err := ret.Retrieve(ctx, schema.QueryBundle{QueryString: "Where is the acme corporation?"})
+		require.NoError(t, err)
+		require.NotEmpty(t, results)
+		assert.Contains(t, results[len(results)-1].Node.Text, "[ACME, based in, Berlin]")
+		require.Len(t, l.prompts, 2)
+		assert.Contains(t, l.prompts[1], "KEYWORDS: acme corporation")
+	})
 }
 
 func TestKGIndexOptions(t *testing.T) {
@@ -1387,3 +1868,254 @@ func TestKGHelperFunctions(t *testing.T) {
 		assert.Equal(t, "c", result[1])
 	})
 }
+
+// topicLLM summarizes a cluster by naming the topics it mentions.
+type topicLLM struct {
+	*llm.MockLLM
+}
+
+func (m *topicLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	apple := strings.Contains(prompt, "apple")
+	bolt := strings.Contains(prompt, "bolt")
+	switch {
+	case apple && bolt:
+		return "summary of everything", nil
+	case apple:
+		return "summary of apple", nil
+	default:
+		return "summary of bolt", nil
+	}
+}
+
+func newRaptorTestIndex(t *testing.T) (*RaptorIndex, *MockEmbeddingModel) {
+	embedModel := NewMockEmbeddingModel()
+	embedModel.SetEmbedding("apple one", []float64{1, 0, 0.1})
+	embedModel.SetEmbedding("apple two", []float64{1, 0, 0.2})
+	embedModel.SetEmbedding("apple three", []float64{1, 0, 0.3})
+	embedModel.SetEmbedding("bolt one", []float64{0, 1, 0.1})
+	embedModel.SetEmbedding("bolt two", []float64{0, 1, 0.2})
+	embedModel.SetEmbedding("bolt three", []float64{0, 1, 0.3})
+	embedModel.SetEmbedding("summary of apple", []float64{1, 0.2, 0})
+	embedModel.SetEmbedding("summary of bolt", []float64{0.2, 1, 0})
+	embedModel.SetEmbedding("summary of everything", []float64{0.7, 0.7, 0})
+	embedModel.SetEmbedding("fruit", []float64{1, 0, 0})
+
+	var nodes []schema.Node
+	for _, text := range []string{"apple one", "bolt one", "apple two", "bolt two", "apple three", "bolt three"} {
+		nodes = append(nodes, *schema.NewTextNode(text))
+	}
+
+	idx, err := NewRaptorIndex(context.Background(), nodes,
+		WithRaptorEmbedModel(embedModel),
+		WithRaptorLLM(&topicLLM{MockLLM: llm.NewMockLLM("")}),
+		WithRaptorClusterSize(3),
+	)
+	require.NoError(t, err)
+	return idx, embedModel
+}
+
+// TestRaptorIndex tests RAPTOR tree construction and retrieval.
+func TestRaptorIndex(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("builds summary levels", func(t *testing.T) {
+		idx, _ := newRaptorTestIndex(t)
+
+		levels := idx.Levels()
+		require.Len(t, levels, 3)
+		assert.Len(t, levels[0], 6)
+		assert.Len(t, levels[1], 2)
+		assert.Len(t, levels[2], 1)
+
+		for _, id := range levels[1] {
+			summary, ok := idx.GetNode(id)
+			require.True(t, ok)
+			assert.Equal(t, 1, summary.Metadata[RaptorLevelMetadataKey])
+
+			children := idx.GetChildren(id)
+			require.Len(t, children, 3)
+			topic := strings.TrimPrefix(summary.Text, "summary of ")
+			for _, childID := range children {
+				child, ok := idx.GetNode(childID)
+				require.True(t, ok)
+				assert.True(t, strings.HasPrefix(child.Text, topic))
+				assert.Equal(t, id, child.Relationships.GetParent().NodeID)
+				assert.NotContains(t, child.GetContent(schema.MetadataModeLLM), RaptorLevelMetadataKey)
+			}
+		}
+
+		root, ok := idx.GetNode(levels[2][0])
+		require.True(t, ok)
+		assert.Equal(t, "summary of everything", root.Text)
+		assert.ElementsMatch(t, levels[1], idx.GetChildren(root.ID))
+	})
+
+	t.Run("collapsed retriever searches all levels", func(t *testing.T) {
+		idx, _ := newRaptorTestIndex(t)
+
+		results, err := idx.AsRetriever(WithSimilarityTopK(5)).Retrieve(ctx, schema.QueryBundle{QueryString: "fruit"})
+		require.NoError(t, err)
+		require.Len(t, results, 5)
+
+		levels := map[int]bool{}
+		for _, r := range results {
+			levels[r.Node.Metadata[RaptorLevelMetadataKey].(int)] = true
+		}
+		assert.True(t, levels[0])
+		assert.True(t, levels[1])
+	})
+
+	t.Run("collapsed retriever token budget", func(t *testing.T) {
+		idx, _ := newRaptorTestIndex(t)
+
+		ret := NewRaptorCollapsedRetriever(idx,
+			WithRaptorRetrieverTopK(5),
+			WithRaptorRetrieverTokenBudget(5, nil),
+		)
+		results, err := ret.Retrieve(ctx, schema.QueryBundle{QueryString: "fruit"})
+		require.NoError(t, err)
+		assert.Len(t, results, 2)
+	})
+
+	t.Run("tree traversal retriever", func(t *testing.T) {
+		idx, _ := newRaptorTestIndex(t)
+
+		ret, err := idx.AsRetrieverWithMode(RaptorRetrieverModeTreeTraversal, WithRaptorRetrieverTopK(1))
+		require.NoError(t, err)
+
+		results, err := ret.Retrieve(ctx, schema.QueryBundle{QueryString: "fruit"})
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		assert.Equal(t, "summary of everything", results[0].Node.Text)
+		assert.Equal(t, "summary of apple", results[1].Node.Text)
+		assert.Equal(t, "apple one", results[2].Node.Text)
+
+		_, err = idx.AsRetrieverWithMode("unknown")
+		assert.Error(t, err)
+	})
+
+	t.Run("rebuild after insert", func(t *testing.T) {
+		idx, embedModel := newRaptorTestIndex(t)
+		embedModel.SetEmbedding("apple four", []float64{1, 0, 0.4})
+
+		require.NoError(t, idx.InsertNodes(ctx, []schema.Node{*schema.NewTextNode("apple four")}))
+		assert.Len(t, idx.Levels()[0], 7)
+		assert.Len(t, idx.Levels()[1], 2)
+
+		require.NoError(t, idx.Rebuild(ctx))
+		levels := idx.Levels()
+		assert.Len(t, levels[0], 7)
+		assert.Equal(t, 7+len(levels[1])+len(levels[2]), len(idx.IndexStruct().NodesDict))
+		for _, id := range levels[0] {
+			leaf, _ := idx.GetNode(id)
+			assert.Equal(t, 0, leaf.Metadata[RaptorLevelMetadataKey])
+			assert.Len(t, leaf.ExcludedEmbedMetadataKeys, 1)
+		}
+	})
+
+	t.Run("summarization error", func(t *testing.T) {
+		nodes := []schema.Node{*schema.NewTextNode("a"), *schema.NewTextNode("b"), *schema.NewTextNode("c")}
+		_, err := NewRaptorIndex(ctx, nodes,
+			WithRaptorEmbedModel(NewMockEmbeddingModel()),
+			WithRaptorLLM(llm.NewMockLLMWithError(assert.AnError)),
+			WithRaptorClusterSize(2),
+		)
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
+
+func TestClusterEmbeddings(t *testing.T) {
+	embeddings := [][]float64{{1, 0}, {0, 1}, {0.9, 0.1}, {0.1, 0.9}}
+	clusters := clusterEmbeddings(embeddings, 2)
+	assert.Equal(t, [][]int{{0, 2}, {1, 3}}, clusters)
+
+	assert.Equal(t, [][]int{{0, 1, 2, 3}}, clusterEmbeddings(embeddings, 4))
+}
+
+// scriptedLLM answers each prompt with a function of it.
+type scriptedLLM struct {
+	*llm.MockLLM
+	respond func(prompt string) string
+	prompts []string
+}
+
+func (m *scriptedLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	m.prompts = append(m.prompts, prompt)
+	return m.respond(prompt), nil
+}
+
+// TestKGCommunities tests GraphRAG community summaries and global queries.
+func TestKGCommunities(t *testing.T) {
+	ctx := context.Background()
+
+	triplets := map[string][]graphstore.Triplet{
+		"people": {
+			{Subject: "Alice", Relation: "knows", Object: "Bob"},
+			{Subject: "Bob", Relation: "knows", Object: "Carol"},
+			{Subject: "Carol", Relation: "knows", Object: "Alice"},
+		},
+		"machines": {
+			{Subject: "Engine", Relation: "powers", Object: "Pump"},
+			{Subject: "Pump", Relation: "feeds", Object: "Boiler"},
+			{Subject: "Boiler", Relation: "heats", Object: "Engine"},
+		},
+	}
+	extractFn := func(text string) ([]graphstore.Triplet, error) {
+		return triplets[text], nil
+	}
+
+	l := &scriptedLLM{MockLLM: llm.NewMockLLM(""), respond: func(prompt string) string {
+		switch {
+		case strings.Contains(prompt, "REPORT:"):
+			if strings.Contains(prompt, "Alice") {
+				return "A social circle of Alice, Bob and Carol."
+			}
+			return "A heating system of engine, pump and boiler."
+		case strings.Contains(prompt, "SCORE:"):
+			if strings.Contains(prompt, "social circle") {
+				return "SCORE: 90\nANSWER: Friendship between three people."
+			}
+			return "SCORE: 0\nANSWER: Not relevant."
+		default:
+			return "  The corpus is about friendship.  "
+		}
+	}}
+
+	nodes := []schema.Node{*schema.NewTextNode("people"), *schema.NewTextNode("machines")}
+	kg, err := NewKnowledgeGraphIndex(ctx, nodes,
+		WithKGIndexTripletExtractFn(extractFn),
+		WithKGIndexLLM(l),
+	)
+	require.NoError(t, err)
+
+	_, err = kg.AsGlobalQueryEngine()
+	assert.Error(t, err, "communities must be built first")
+
+	communities, err := kg.BuildCommunities(ctx)
+	require.NoError(t, err)
+	require.Len(t, communities, 2)
+	assert.Equal(t, []string{"Alice", "Bob", "Carol"}, communities[0].Entities)
+	assert.Len(t, communities[0].Triplets, 3)
+	assert.Equal(t, []string{nodes[0].ID}, communities[0].NodeIDs)
+	assert.Equal(t, "A social circle of Alice, Bob and Carol.", communities[0].Summary)
+	assert.Contains(t, l.prompts[0], "(Alice, knows, Bob)")
+	assert.Equal(t, communities, kg.Communities())
+
+	engine, err := kg.AsGlobalQueryEngine()
+	require.NoError(t, err)
+
+	l.prompts = nil
+	resp, err := engine.Query(ctx, "What is the corpus about?")
+	require.NoError(t, err)
+	assert.Equal(t, "The corpus is about friendship.", resp.Response)
+	require.Len(t, resp.SourceNodes, 1)
+	assert.Equal(t, communities[0].ID, resp.SourceNodes[0].Node.ID)
+	assert.InDelta(t, 0.9, resp.SourceNodes[0].Score, 0.001)
+	require.Len(t, l.prompts, 3)
+	assert.Contains(t, l.prompts[2], "Friendship between three people.")
+	assert.NotContains(t, l.prompts[2], "Not relevant.")
+
+	score, answer := parseGlobalMapResponse("score: 250\nanswer: a\nb")
+	assert.Equal(t, 100, score)
+	assert.Equal(t, "a\nb", answer)
+}
@@ -115,6 +115,13 @@ func WithQueryEngineTopK(k int) QueryEngineOption {
 	}
 }
 
+// WithQueryEngineFilters sets metadata filters for retrieval.
+func WithQueryEngineFilters(filters *schema.MetadataFilters) QueryEngineOption {
+	return func(c *QueryEngineConfig) {
+		c.Filters = filters
+	}
+}
+
 // BaseIndex provides common functionality for all index types.
 type BaseIndex struct {
 	// indexStruct is the underlying index structure.
@@ -2,6 +2,7 @@ package index
 
 import (
 	"context"
+	"sort"
 	"strings"
 
 	"github.com/aqua777/go-llamaindex/llm"
@@ -100,6 +101,39 @@ func (e *SimpleKeywordExtractor) ExtractKeywords(ctx context.Context, text strin
 	return keywords, nil
 }
 
+// AnalyzerKeywordExtractor extracts keywords with a retriever.Analyzer, so
+// the keyword table and its queries share stopwords, stemming and synonyms.
+type AnalyzerKeywordExtractor struct {
+	// Analyzer turns text into terms.
+	Analyzer *retriever.Analyzer
+}
+
+// NewAnalyzerKeywordExtractor creates a new AnalyzerKeywordExtractor.
+func NewAnalyzerKeywordExtractor(analyzer *retriever.Analyzer) *AnalyzerKeywordExtractor {
+	return &AnalyzerKeywordExtractor{Analyzer: analyzer}
+}
+
+// ExtractKeywords returns the most frequent terms of text, ties in order of
+// first occurrence. A maxKeywords of zero or less returns every term.
+func (e *AnalyzerKeywordExtractor) ExtractKeywords(ctx context.Context, text string, maxKeywords int) ([]string, error) {
+	counts := make(map[string]int)
+	var keywords []string
+	for _, term := range e.Analyzer.Analyze(text) {
+		if counts[term] == 0 {
+			keywords = append(keywords, term)
+		}
+		counts[term]++
+	}
+
+	sort.SliceStable(keywords, func(i, j int) bool {
+		return counts[keywords[i]] > counts[keywords[j]]
+	})
+	if maxKeywords > 0 && len(keywords) > maxKeywords {
+		keywords = keywords[:maxKeywords]
+	}
+	return keywords, nil
+}
+
 // KeywordTableIndexOption configures KeywordTableIndex creation.
 type KeywordTableIndexOption func(*KeywordTableIndex)
 
@@ -110,6 +144,12 @@ func WithKeywordExtractor(extractor KeywordExtractor) KeywordTableIndexOption {
 	}
 }
 
+// WithKeywordTableAnalyzer extracts keywords from nodes and queries with
+// analyzer, e.g. to match domain synonyms such as "k8s" and "kubernetes".
+func WithKeywordTableAnalyzer(analyzer *retriever.Analyzer) KeywordTableIndexOption {
+	return WithKeywordExtractor(NewAnalyzerKeywordExtractor(analyzer))
+}
+
 // WithMaxKeywordsPerChunk sets the maximum keywords per chunk.
 func WithMaxKeywordsPerChunk(max int) KeywordTableIndexOption {
 	return func(kti *KeywordTableIndex) {
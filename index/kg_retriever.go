@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/aqua777/go-llamaindex/prompts"
 	"github.com/aqua777/go-llamaindex/schema"
 )
 
@@ -533,11 +534,26 @@ func kgSqrt(x float64) float64 {
 	return z
 }
 
-// KGRAGRetriever is a more advanced retriever that performs SubGraph RAG.
+// DefaultKGSynonymExpandPrompt asks for alternative forms of the entities
+// extracted from a query, so that they match how the entities were written
+// when the graph was built.
+const DefaultKGSynonymExpandPrompt = `Generate synonyms or possible forms of the keywords up to {max_keywords} in total, considering possible cases of capitalization, pluralization, common expressions, etc.
+Provide all synonyms of the keywords in comma-separated format: 'SYNONYMS: <synonyms>'
+Note, the result should be on one line with only one 'SYNONYMS: ' prefix
+----
+KEYWORDS: {question}
+----
+`
+
+// KGRAGRetriever performs SubGraph RAG: it extracts the entities of a
+// query, expands them with synonyms, and returns the subgraph around them
+// up to the graph depth as a knowledge sequence node, together with the
+// chunks the subgraph's entities were extracted from.
 type KGRAGRetriever struct {
 	*KGTableRetriever
 	entityExtractFn   func(string) ([]string, error)
 	synonymExpandFn   func(string) ([]string, error)
+	synonymTemplate   *prompts.PromptTemplate
 	maxEntities       int
 	maxSynonyms       int
 	withNL2GraphQuery bool
@@ -578,10 +594,30 @@ func WithKGRAGMaxSynonyms(n int) KGRAGRetrieverOption {
 	}
 }
 
+// WithKGRAGSynonymExpandTemplate sets the synonym expansion prompt. It
+// uses the {question} and {max_keywords} placeholders.
+func WithKGRAGSynonymExpandTemplate(tmpl *prompts.PromptTemplate) KGRAGRetrieverOption {
+	return func(r *KGRAGRetriever) {
+		r.synonymTemplate = tmpl
+	}
+}
+
+// WithKGRAGRetrieverOptions configures the graph traversal and chunk
+// retrieval, e.g. with WithKGRetrieverGraphDepth,
+// WithKGRetrieverMaxKnowledgeSequence or WithKGRetrieverIncludeText.
+func WithKGRAGRetrieverOptions(opts ...KGRetrieverOption) KGRAGRetrieverOption {
+	return func(r *KGRAGRetriever) {
+		for _, opt := range opts {
+			opt(r.KGTableRetriever)
+		}
+	}
+}
+
 // NewKGRAGRetriever creates a new KGRAGRetriever.
 func NewKGRAGRetriever(index *KnowledgeGraphIndex, opts ...KGRAGRetrieverOption) *KGRAGRetriever {
 	r := &KGRAGRetriever{
 		KGTableRetriever:  NewKGTableRetriever(index),
+		synonymTemplate:   prompts.NewPromptTemplate(DefaultKGSynonymExpandPrompt, prompts.PromptTypeQueryKeywordExtract),
 		maxEntities:       5,
 		maxSynonyms:       5,
 		withNL2GraphQuery: false,
@@ -594,6 +630,185 @@ func NewKGRAGRetriever(index *KnowledgeGraphIndex, opts ...KGRAGRetrieverOption)
 	return r
 }
 
+// Retrieve returns the knowledge sequence of the subgraph around the query
+// entities, preceded by the chunks that mention the most subgraph
+// entities. It returns no nodes when no entity is in the graph.
+func (r *KGRAGRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	entities, err := r.extractEntities(ctx, query.QueryString)
+	if err != nil {
+		return nil, err
+	}
+	synonyms, err := r.expandSynonyms(ctx, entities)
+	if err != nil {
+		return nil, err
+	}
+	subjects := entityVariants(append(entities, synonyms...))
+	if len(subjects) == 0 {
+		return nil, nil
+	}
+
+	relMap, err := r.index.graphStore.GetRelMap(ctx, subjects, r.graphStoreQueryDepth, r.maxKnowledgeSequence)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subgraph: %w", err)
+	}
+
+	var relTexts []string
+	seen := make(map[string]bool)
+	subgraph := make(map[string][][]string)
+	chunkCounts := make(map[string]int)
+	for _, subj := range subjects {
+		for _, rel := range relMap[subj] {
+			text := formatRelText(rel)
+			if seen[text] || len(relTexts) >= r.maxKnowledgeSequence {
+				continue
+			}
+			seen[text] = true
+			relTexts = append(relTexts, text)
+			subgraph[subj] = append(subgraph[subj], rel)
+			if r.includeText && len(rel) >= 3 {
+				for _, entity := range []string{rel[0], rel[2]} {
+					for _, nodeID := range r.index.SearchNodeByKeyword(entity) {
+						chunkCounts[nodeID]++
+					}
+				}
+			}
+		}
+	}
+	if len(relTexts) == 0 {
+		return nil, nil
+	}
+
+	var results []schema.NodeWithScore
+	for _, nodeID := range sortByCount(chunkCounts, r.numChunksPerQuery) {
+		doc, err := r.index.storageContext.DocStore.GetDocument(ctx, nodeID, false)
+		if err != nil || doc == nil {
+			continue
+		}
+		if node, ok := doc.(*schema.Node); ok {
+			results = append(results, schema.NodeWithScore{Node: *node, Score: DefaultNodeScore})
+		}
+	}
+
+	relNode := schema.NewTextNode(fmt.Sprintf(
+		"The following are knowledge sequences in max depth %d in the form of directed graph like:\n"+
+			"`subject -[predicate]-> object, <-[predicate_next_hop]- object_next_hop ...`\n%s",
+		r.graphStoreQueryDepth, strings.Join(relTexts, "\n"),
+	))
+	relNode.Metadata = map[string]interface{}{
+		"kg_rel_texts": relTexts,
+		"kg_rel_map":   subgraph,
+		"kg_entities":  subjects,
+	}
+	return append(results, schema.NodeWithScore{Node: *relNode, Score: DefaultNodeScore}), nil
+}
+
+// extractEntities returns the entities of the query, using the entity
+// extraction function, the index LLM, or simple keyword extraction.
+func (r *KGRAGRetriever) extractEntities(ctx context.Context, query string) ([]string, error) {
+	var entities []string
+	switch {
+	case r.entityExtractFn != nil:
+		var err error
+		if entities, err = r.entityExtractFn(query); err != nil {
+			return nil, fmt.Errorf("failed to extract entities: %w", err)
+		}
+	case r.index.llm != nil:
+		prompt := r.index.keywordExtractTemplate.Format(map[string]string{
+			"max_keywords": fmt.Sprintf("%d", r.maxEntities),
+			"question":     query,
+		})
+		response, err := r.index.llm.Complete(ctx, prompt)
+		if err != nil {
+			entities = simpleKeywordExtract(query, r.maxEntities)
+		} else {
+			entities = parseKGTerms(response, "KEYWORDS:")
+		}
+	default:
+		entities = simpleKeywordExtract(query, r.maxEntities)
+	}
+	if len(entities) > r.maxEntities {
+		entities = entities[:r.maxEntities]
+	}
+	return entities, nil
+}
+
+// expandSynonyms returns alternative forms of the entities, using the
+// synonym function or the index LLM. Without either it returns none; LLM
+// failures are ignored since synonyms only widen the search.
+func (r *KGRAGRetriever) expandSynonyms(ctx context.Context, entities []string) ([]string, error) {
+	if len(entities) == 0 {
+		return nil, nil
+	}
+	var synonyms []string
+	switch {
+	case r.synonymExpandFn != nil:
+		for _, entity := range entities {
+			s, err := r.synonymExpandFn(entity)
+			if err != nil {
+				return nil, fmt.Errorf("failed to expand synonyms of %q: %w", entity, err)
+			}
+			if len(s) > r.maxSynonyms {
+				s = s[:r.maxSynonyms]
+			}
+			synonyms = append(synonyms, s...)
+		}
+	case r.index.llm != nil:
+		prompt := r.synonymTemplate.Format(map[string]string{
+			"max_keywords": fmt.Sprintf("%d", r.maxSynonyms*len(entities)),
+			"question":     strings.Join(entities, ", "),
+		})
+		response, err := r.index.llm.Complete(ctx, prompt)
+		if err != nil {
+			return nil, nil
+		}
+		synonyms = parseKGTerms(response, "SYNONYMS:")
+	}
+	return synonyms, nil
+}
+
+// parseKGTerms parses the comma-separated terms after prefix, keeping their
+// case.
+func parseKGTerms(response, prefix string) []string {
+	if idx := strings.Index(strings.ToUpper(response), prefix); idx != -1 {
+		response = response[idx+len(prefix):]
+	}
+	if idx := strings.Index(response, "\n"); idx != -1 {
+		response = response[:idx]
+	}
+	var terms []string
+	for _, part := range strings.Split(response, ",") {
+		if term := strings.Trim(strings.TrimSpace(part), `"'`); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// entityVariants returns the entities with the capitalized and lower case
+// forms graph subjects are commonly stored in, without duplicates.
+func entityVariants(entities []string) []string {
+	var variants []string
+	seen := make(map[string]bool)
+	add := func(s string) {
+		if s != "" && !seen[s] {
+			seen[s] = true
+			variants = append(variants, s)
+		}
+	}
+	for _, entity := range entities {
+		entity = strings.TrimSpace(entity)
+		if entity == "" {
+			continue
+		}
+		lower := strings.ToLower(entity)
+		add(entity)
+		add(strings.ToUpper(entity[:1]) + entity[1:])
+		add(strings.ToUpper(lower[:1]) + lower[1:])
+		add(lower)
+	}
+	return variants
+}
+
 // Name returns the retriever name.
 func (r *KGRAGRetriever) Name() string {
 	return "KGRAGRetriever"
@@ -3,10 +3,9 @@ package index
 import (
 	"context"
 	"fmt"
-	"regexp"
-	"strings"
 
 	"github.com/aqua777/go-llamaindex/graphstore"
+	"github.com/aqua777/go-llamaindex/ingestion"
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/prompts"
 	"github.com/aqua777/go-llamaindex/rag/queryengine"
@@ -62,6 +61,8 @@ type KnowledgeGraphIndex struct {
 	keywordExtractTemplate    *prompts.PromptTemplate
 	maxKeywordsPerQuery       int
 	graphStoreQueryDepth      int
+	transformations           []ingestion.TransformComponent
+	communities               []Community
 }
 
 // KGIndexOption configures KnowledgeGraphIndex creation.
@@ -134,6 +135,15 @@ func WithKGIndexTripletExtractTemplate(tmpl *prompts.PromptTemplate) KGIndexOpti
 	}
 }
 
+// WithKGIndexTransformations sets the transformations run on documents
+// before triplet extraction, e.g. a node parser followed by an
+// ingestion.TripletExtractor. Without them each document is one node.
+func WithKGIndexTransformations(transformations ...ingestion.TransformComponent) KGIndexOption {
+	return func(kg *KnowledgeGraphIndex) {
+		kg.transformations = transformations
+	}
+}
+
 // WithKGIndexGraphStoreQueryDepth sets the depth for graph traversal.
 func WithKGIndexGraphStoreQueryDepth(depth int) KGIndexOption {
 	return func(kg *KnowledgeGraphIndex) {
@@ -185,23 +195,20 @@ func NewKnowledgeGraphIndexFromDocuments(
 	documents []schema.Document,
 	opts ...KGIndexOption,
 ) (*KnowledgeGraphIndex, error) {
-	var nodes []schema.Node
-	for _, doc := range documents {
-		node := schema.NewTextNode(doc.Text)
-		node.Metadata = doc.Metadata
-		if doc.ID != "" {
-			node.ID = doc.ID
-		}
-		nodes = append(nodes, *node)
+	kg, err := NewKnowledgeGraphIndex(ctx, nil, opts...)
+	if err != nil {
+		return nil, err
 	}
-
-	return NewKnowledgeGraphIndex(ctx, nodes, opts...)
+	if err := kg.InsertDocuments(ctx, documents); err != nil {
+		return nil, err
+	}
+	return kg, nil
 }
 
 // buildIndexFromNodes builds the index from nodes by extracting triplets.
 func (kg *KnowledgeGraphIndex) buildIndexFromNodes(ctx context.Context, nodes []schema.Node) error {
 	for _, node := range nodes {
-		triplets, err := kg.extractTriplets(ctx, node.GetContent(schema.MetadataModeLLM))
+		triplets, err := kg.nodeTriplets(ctx, node)
 		if err != nil {
 			return err
 		}
@@ -252,6 +259,15 @@ func (kg *KnowledgeGraphIndex) addNodeToIndex(keyword, nodeID string) {
 	kg.indexStruct.Table[keyword] = append(kg.indexStruct.Table[keyword], nodeID)
 }
 
+// nodeTriplets returns the triplets stored on the node by an
+// ingestion.TripletExtractor, or extracts them from its text.
+func (kg *KnowledgeGraphIndex) nodeTriplets(ctx context.Context, node schema.Node) ([]graphstore.Triplet, error) {
+	if triplets, ok := graphstore.TripletsFromMetadata(node.Metadata); ok {
+		return triplets, nil
+	}
+	return kg.extractTriplets(ctx, node.GetContent(schema.MetadataModeLLM))
+}
+
 // extractTriplets extracts triplets from text.
 func (kg *KnowledgeGraphIndex) extractTriplets(ctx context.Context, text string) ([]graphstore.Triplet, error) {
 	if kg.tripletExtractFn != nil {
@@ -281,55 +297,7 @@ func (kg *KnowledgeGraphIndex) llmExtractTriplets(ctx context.Context, text stri
 
 // parseTripletResponse parses the LLM response to extract triplets.
 func (kg *KnowledgeGraphIndex) parseTripletResponse(response string) ([]graphstore.Triplet, error) {
-	var triplets []graphstore.Triplet
-
-	// Match patterns like (subject, predicate, object)
-	re := regexp.MustCompile(`\(([^,]+),\s*([^,]+),\s*([^)]+)\)`)
-	matches := re.FindAllStringSubmatch(response, -1)
-
-	for _, match := range matches {
-		if len(match) != 4 {
-			continue
-		}
-
-		subj := strings.TrimSpace(match[1])
-		pred := strings.TrimSpace(match[2])
-		obj := strings.TrimSpace(match[3])
-
-		// Skip if any part is empty
-		if subj == "" || pred == "" || obj == "" {
-			continue
-		}
-
-		// Skip if any part is too long
-		if len(subj) > kg.maxObjectLength || len(pred) > kg.maxObjectLength || len(obj) > kg.maxObjectLength {
-			continue
-		}
-
-		// Clean up quotes and capitalize
-		subj = strings.Trim(subj, `"'`)
-		pred = strings.Trim(pred, `"'`)
-		obj = strings.Trim(obj, `"'`)
-
-		if len(subj) > 0 {
-			subj = strings.ToUpper(subj[:1]) + subj[1:]
-		}
-		if len(obj) > 0 {
-			obj = strings.ToUpper(obj[:1]) + obj[1:]
-		}
-
-		triplets = append(triplets, graphstore.Triplet{
-			Subject:  subj,
-			Relation: pred,
-			Object:   obj,
-		})
-
-		if len(triplets) >= kg.maxTripletsPerChunk {
-			break
-		}
-	}
-
-	return triplets, nil
+	return graphstore.ParseTriplets(response, kg.maxTripletsPerChunk, kg.maxObjectLength), nil
 }
 
 // GraphStore returns the graph store.
@@ -396,7 +364,7 @@ func (kg *KnowledgeGraphIndex) AsQueryEngine(opts ...QueryEngineOption) queryeng
 // InsertNodes inserts nodes into the index.
 func (kg *KnowledgeGraphIndex) InsertNodes(ctx context.Context, nodes []schema.Node) error {
 	for _, node := range nodes {
-		triplets, err := kg.extractTriplets(ctx, node.GetContent(schema.MetadataModeLLM))
+		triplets, err := kg.nodeTriplets(ctx, node)
 		if err != nil {
 			return err
 		}
@@ -430,6 +398,28 @@ func (kg *KnowledgeGraphIndex) InsertNodes(ctx context.Context, nodes []schema.N
 	return kg.storageContext.IndexStore.AddIndexStruct(ctx, kg.indexStruct)
 }
 
+// InsertDocuments runs documents through the index transformations and
+// inserts the resulting nodes, recording each document's hash for
+// RefreshDocuments.
+func (kg *KnowledgeGraphIndex) InsertDocuments(ctx context.Context, documents []schema.Document) error {
+	nodes, err := documentNodes(ctx, documents, kg.transformations)
+	if err != nil {
+		return err
+	}
+	if err := kg.InsertNodes(ctx, nodes); err != nil {
+		return err
+	}
+	for _, doc := range documents {
+		if doc.ID == "" {
+			continue
+		}
+		if err := kg.storageContext.DocStore.SetDocumentHash(ctx, doc.ID, doc.GetHash()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // DeleteNodes removes nodes from the index.
 // Note: Delete is not fully implemented for KG index.
 func (kg *KnowledgeGraphIndex) DeleteNodes(ctx context.Context, nodeIDs []string) error {
@@ -444,12 +434,7 @@ func (kg *KnowledgeGraphIndex) RefreshDocuments(ctx context.Context, documents [
 		existingHash, err := kg.storageContext.DocStore.GetDocumentHash(ctx, doc.ID)
 		if err != nil || existingHash == "" {
 			// Document doesn't exist, insert it
-			node := schema.NewTextNode(doc.Text)
-			node.Metadata = doc.Metadata
-			if doc.ID != "" {
-				node.ID = doc.ID
-			}
-			if err := kg.InsertNodes(ctx, []schema.Node{*node}); err != nil {
+			if err := kg.InsertDocuments(ctx, []schema.Document{doc}); err != nil {
 				return refreshed, err
 			}
 			refreshed[i] = true
@@ -0,0 +1,785 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/rag/queryengine"
+	"github.com/aqua777/go-llamaindex/rag/retriever"
+	"github.com/aqua777/go-llamaindex/rag/store"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/storage"
+	"github.com/aqua777/go-llamaindex/storage/indexstore"
+	"github.com/aqua777/go-llamaindex/textutil"
+)
+
+// RaptorLevelMetadataKey is the metadata key holding a node's level in a
+// RaptorIndex. Leaves are level 0; each summary level adds one.
+const RaptorLevelMetadataKey = "raptor_level"
+
+// RaptorRetrieverMode specifies how a RaptorIndex retrieves nodes.
+type RaptorRetrieverMode string
+
+const (
+	// RaptorRetrieverModeCollapsed searches all levels of the tree at once.
+	RaptorRetrieverModeCollapsed RaptorRetrieverMode = "collapsed"
+	// RaptorRetrieverModeTreeTraversal descends from the top level, keeping
+	// the best matching nodes at each level.
+	RaptorRetrieverModeTreeTraversal RaptorRetrieverMode = "tree_traversal"
+)
+
+// RaptorIndex implements RAPTOR (Recursive Abstractive Processing for
+// Tree-Organized Retrieval). Leaf nodes are embedded and clustered by
+// similarity, each cluster is summarized by the LLM, and the summaries are
+// embedded and clustered again until a single cluster remains or the
+// maximum depth is reached. Every level is stored in the vector store, so a
+// query can match a specific detail in a leaf or a theme that only appears
+// in a summary.
+//
+// Clustering uses k-means over cosine similarity with a deterministic
+// initialization, so the same input always builds the same tree.
+type RaptorIndex struct {
+	*VectorStoreIndex
+	llm             llm.LLM
+	summaryTemplate *prompts.PromptTemplate
+	clusterSize     int
+	maxLevels       int
+	// nodes holds every node in the tree, with embeddings, by ID.
+	nodes map[string]schema.Node
+	// levels holds node IDs per level, starting with the leaves.
+	levels [][]string
+}
+
+// RaptorIndexOption configures RaptorIndex creation.
+type RaptorIndexOption func(*RaptorIndex)
+
+// WithRaptorVectorStore sets the vector store.
+func WithRaptorVectorStore(vs store.VectorStore) RaptorIndexOption {
+	return func(ri *RaptorIndex) {
+		ri.vectorStore = vs
+	}
+}
+
+// WithRaptorStorageContext sets the storage context.
+func WithRaptorStorageContext(sc *storage.StorageContext) RaptorIndexOption {
+	return func(ri *RaptorIndex) {
+		ri.storageContext = sc
+	}
+}
+
+// WithRaptorEmbedModel sets the embedding model.
+func WithRaptorEmbedModel(model EmbeddingModel) RaptorIndexOption {
+	return func(ri *RaptorIndex) {
+		ri.embedModel = model
+	}
+}
+
+// WithRaptorLLM sets the LLM for cluster summarization.
+func WithRaptorLLM(l llm.LLM) RaptorIndexOption {
+	return func(ri *RaptorIndex) {
+		ri.llm = l
+	}
+}
+
+// WithRaptorSummaryTemplate sets the cluster summary prompt template. It
+// uses the {context_str} placeholder.
+func WithRaptorSummaryTemplate(tmpl *prompts.PromptTemplate) RaptorIndexOption {
+	return func(ri *RaptorIndex) {
+		ri.summaryTemplate = tmpl
+	}
+}
+
+// WithRaptorClusterSize sets the target number of nodes per cluster.
+func WithRaptorClusterSize(n int) RaptorIndexOption {
+	return func(ri *RaptorIndex) {
+		if n >= 2 {
+			ri.clusterSize = n
+		}
+	}
+}
+
+// WithRaptorMaxLevels sets the maximum number of summary levels.
+func WithRaptorMaxLevels(n int) RaptorIndexOption {
+	return func(ri *RaptorIndex) {
+		if n >= 1 {
+			ri.maxLevels = n
+		}
+	}
+}
+
+// NewRaptorIndex creates a new RaptorIndex and builds the tree from nodes.
+func NewRaptorIndex(ctx context.Context, nodes []schema.Node, opts ...RaptorIndexOption) (*RaptorIndex, error) {
+	indexStruct := indexstore.NewVectorStoreIndex()
+	indexStruct.NodeIDToChildrenIDs = make(map[string][]string)
+
+	ri := &RaptorIndex{
+		VectorStoreIndex: &VectorStoreIndex{
+			BaseIndex:       NewBaseIndex(indexStruct),
+			insertBatchSize: 2048,
+		},
+		summaryTemplate: prompts.NewPromptTemplate(DefaultSummaryPromptTemplate, prompts.PromptTypeSummary),
+		clusterSize:     8,
+		maxLevels:       3,
+		nodes:           make(map[string]schema.Node),
+	}
+
+	for _, opt := range opts {
+		opt(ri)
+	}
+
+	if ri.vectorStore == nil {
+		ri.vectorStore = ri.storageContext.VectorStore()
+	}
+	if ri.vectorStore == nil {
+		ri.vectorStore = store.NewSimpleVectorStore()
+	}
+
+	if len(nodes) > 0 {
+		if err := ri.buildTree(ctx, nodes); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ri.storageContext.IndexStore.AddIndexStruct(ctx, indexStruct); err != nil {
+		return nil, err
+	}
+
+	return ri, nil
+}
+
+// NewRaptorIndexFromDocuments creates a RaptorIndex from documents.
+func NewRaptorIndexFromDocuments(
+	ctx context.Context,
+	documents []schema.Document,
+	opts ...RaptorIndexOption,
+) (*RaptorIndex, error) {
+	var nodes []schema.Node
+	for _, doc := range documents {
+		node := schema.NewTextNode(doc.Text)
+		node.Metadata = doc.Metadata
+		if doc.ID != "" {
+			node.ID = doc.ID
+		}
+		nodes = append(nodes, *node)
+	}
+
+	return NewRaptorIndex(ctx, nodes, opts...)
+}
+
+// buildTree embeds the leaves, builds the summary levels and stores every
+// node in the vector store.
+func (ri *RaptorIndex) buildTree(ctx context.Context, leaves []schema.Node) error {
+	var contentNodes []schema.Node
+	for _, node := range leaves {
+		if node.GetContent(schema.MetadataModeEmbed) != "" {
+			contentNodes = append(contentNodes, node)
+		}
+	}
+	if len(contentNodes) == 0 {
+		return nil
+	}
+
+	embedded, err := ri.getNodesWithEmbeddings(ctx, contentNodes)
+	if err != nil {
+		return err
+	}
+
+	all := make([]schema.Node, 0, len(embedded))
+	current := make([]int, 0, len(embedded))
+	for _, node := range embedded {
+		current = append(current, len(all))
+		all = append(all, withRaptorLevel(node, 0))
+	}
+	ri.levels = [][]string{nodeIDs(all, current)}
+
+	for level := 1; level <= ri.maxLevels && len(current) > 1; level++ {
+		embeddings := make([][]float64, len(current))
+		for i, idx := range current {
+			embeddings[i] = all[idx].Embedding
+		}
+
+		clusters := clusterEmbeddings(embeddings, ri.clusterSize)
+		if len(clusters) >= len(current) {
+			// Clustering no longer reduces the level.
+			break
+		}
+
+		parents := make([]schema.Node, 0, len(clusters))
+		members := make([][]int, 0, len(clusters))
+		for _, cluster := range clusters {
+			children := make([]int, len(cluster))
+			texts := make([]string, len(cluster))
+			for i, c := range cluster {
+				children[i] = current[c]
+				texts[i] = all[current[c]].GetContent(schema.MetadataModeLLM)
+			}
+
+			summary, err := ri.summarize(ctx, strings.Join(texts, "\n\n"))
+			if err != nil {
+				return fmt.Errorf("failed to summarize level %d cluster: %w", level, err)
+			}
+
+			parent := withRaptorLevel(*schema.NewTextNode(summary), level)
+			parents = append(parents, parent)
+			members = append(members, children)
+		}
+
+		parents, err = ri.getNodesWithEmbeddings(ctx, parents)
+		if err != nil {
+			return err
+		}
+
+		next := make([]int, 0, len(parents))
+		for i, parent := range parents {
+			childInfos := make([]schema.RelatedNodeInfo, len(members[i]))
+			childIDs := make([]string, len(members[i]))
+			for j, idx := range members[i] {
+				all[idx].Relationships.SetParent(schema.RelatedNodeInfo{NodeID: parent.ID})
+				childInfos[j] = schema.RelatedNodeInfo{NodeID: all[idx].ID}
+				childIDs[j] = all[idx].ID
+			}
+			parent.Relationships.SetChildren(childInfos)
+			ri.indexStruct.NodeIDToChildrenIDs[parent.ID] = childIDs
+
+			next = append(next, len(all))
+			all = append(all, parent)
+		}
+		current = next
+		ri.levels = append(ri.levels, nodeIDs(all, current))
+	}
+
+	return ri.storeNodes(ctx, all)
+}
+
+// summarize generates the summary of one cluster.
+func (ri *RaptorIndex) summarize(ctx context.Context, text string) (string, error) {
+	if ri.llm == nil {
+		// Without an LLM, fall back to the start of the cluster text.
+		return textutil.Truncate(text, 500), nil
+	}
+
+	prompt := ri.summaryTemplate.Format(map[string]string{
+		"context_str": text,
+	})
+
+	response, err := ri.llm.Complete(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(response), nil
+}
+
+// storeNodes adds embedded nodes to the vector store in batches.
+func (ri *RaptorIndex) storeNodes(ctx context.Context, nodes []schema.Node) error {
+	for i := 0; i < len(nodes); i += ri.insertBatchSize {
+		end := i + ri.insertBatchSize
+		if end > len(nodes) {
+			end = len(nodes)
+		}
+		batch := nodes[i:end]
+
+		newIDs, err := ri.vectorStore.Add(ctx, batch)
+		if err != nil {
+			return err
+		}
+
+		for j, node := range batch {
+			ri.indexStruct.AddNode(node.ID, newIDs[j])
+			ri.nodes[node.ID] = node
+		}
+	}
+	return nil
+}
+
+// withRaptorLevel returns a copy of node tagged with its tree level. The
+// level is kept out of embeddings and prompts.
+func withRaptorLevel(node schema.Node, level int) schema.Node {
+	metadata := make(map[string]interface{}, len(node.Metadata)+1)
+	for k, v := range node.Metadata {
+		metadata[k] = v
+	}
+	metadata[RaptorLevelMetadataKey] = level
+	node.Metadata = metadata
+
+	relationships := make(schema.NodeRelationships, len(node.Relationships)+1)
+	for k, v := range node.Relationships {
+		relationships[k] = v
+	}
+	node.Relationships = relationships
+
+	node.ExcludedEmbedMetadataKeys = withKey(node.ExcludedEmbedMetadataKeys, RaptorLevelMetadataKey)
+	node.ExcludedLLMMetadataKeys = withKey(node.ExcludedLLMMetadataKeys, RaptorLevelMetadataKey)
+	return node
+}
+
+// withKey returns a copy of keys that contains key.
+func withKey(keys []string, key string) []string {
+	out := make([]string, 0, len(keys)+1)
+	for _, k := range keys {
+		if k != key {
+			out = append(out, k)
+		}
+	}
+	return append(out, key)
+}
+
+func nodeIDs(nodes []schema.Node, indices []int) []string {
+	ids := make([]string, len(indices))
+	for i, idx := range indices {
+		ids[i] = nodes[idx].ID
+	}
+	return ids
+}
+
+// clusterEmbeddings groups embeddings into clusters of roughly clusterSize
+// members using k-means over cosine similarity. Centroids are seeded with
+// farthest-point initialization from the first embedding, so the result is
+// deterministic. Clusters are returned as indices into embeddings, ordered
+// by their first member.
+func clusterEmbeddings(embeddings [][]float64, clusterSize int) [][]int {
+	n := len(embeddings)
+	k := (n + clusterSize - 1) / clusterSize
+	if k <= 1 {
+		all := make([]int, n)
+		for i := range all {
+			all[i] = i
+		}
+		return [][]int{all}
+	}
+
+	// Farthest-point initialization.
+	centroids := [][]float64{embeddings[0]}
+	for len(centroids) < k {
+		best, bestSim := -1, 2.0
+		for i, emb := range embeddings {
+			closest := -2.0
+			for _, c := range centroids {
+				if sim := cosineSimilarity(emb, c); sim > closest {
+					closest = sim
+				}
+			}
+			if closest < bestSim {
+				best, bestSim = i, closest
+			}
+		}
+		centroids = append(centroids, embeddings[best])
+	}
+
+	assignment := make([]int, n)
+	for i := range assignment {
+		assignment[i] = -1
+	}
+
+	for iter := 0; iter < 50; iter++ {
+		changed := false
+		for i, emb := range embeddings {
+			best, bestSim := 0, -2.0
+			for c, centroid := range centroids {
+				if sim := cosineSimilarity(emb, centroid); sim > bestSim {
+					best, bestSim = c, sim
+				}
+			}
+			if assignment[i] != best {
+				assignment[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		for c := range centroids {
+			var mean []float64
+			count := 0
+			for i, a := range assignment {
+				if a != c {
+					continue
+				}
+				if mean == nil {
+					mean = make([]float64, len(embeddings[i]))
+				}
+				for d, v := range embeddings[i] {
+					if d < len(mean) {
+						mean[d] += v
+					}
+				}
+				count++
+			}
+			if count > 0 {
+				for d := range mean {
+					mean[d] /= float64(count)
+				}
+				centroids[c] = mean
+			}
+		}
+	}
+
+	groups := make(map[int][]int)
+	for i, a := range assignment {
+		groups[a] = append(groups[a], i)
+	}
+	clusters := make([][]int, 0, len(groups))
+	for _, members := range groups {
+		clusters = append(clusters, members)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i][0] < clusters[j][0]
+	})
+	return clusters
+}
+
+// Levels returns the node IDs at each level of the tree, starting with the
+// leaves.
+func (ri *RaptorIndex) Levels() [][]string {
+	return ri.levels
+}
+
+// GetNode returns a node of the tree by ID.
+func (ri *RaptorIndex) GetNode(nodeID string) (schema.Node, bool) {
+	node, ok := ri.nodes[nodeID]
+	return node, ok
+}
+
+// GetChildren returns the IDs of the nodes summarized by a node.
+func (ri *RaptorIndex) GetChildren(nodeID string) []string {
+	return ri.indexStruct.NodeIDToChildrenIDs[nodeID]
+}
+
+// LLM returns the summarization LLM.
+func (ri *RaptorIndex) LLM() llm.LLM {
+	return ri.llm
+}
+
+// AsRetriever returns a collapsed-tree retriever for this index.
+func (ri *RaptorIndex) AsRetriever(opts ...RetrieverOption) retriever.Retriever {
+	config := &RetrieverConfig{
+		SimilarityTopK: 10,
+		EmbedModel:     ri.embedModel,
+	}
+
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	return NewRaptorCollapsedRetriever(ri,
+		WithRaptorRetrieverTopK(config.SimilarityTopK),
+		WithRaptorRetrieverEmbedModel(config.EmbedModel),
+		WithRaptorRetrieverFilters(config.Filters),
+	)
+}
+
+// AsRetrieverWithMode returns a retriever with the specified mode.
+func (ri *RaptorIndex) AsRetrieverWithMode(mode RaptorRetrieverMode, opts ...RaptorRetrieverOption) (retriever.Retriever, error) {
+	switch mode {
+	case RaptorRetrieverModeCollapsed:
+		return NewRaptorCollapsedRetriever(ri, opts...), nil
+	case RaptorRetrieverModeTreeTraversal:
+		return NewRaptorTreeTraversalRetriever(ri, opts...), nil
+	default:
+		return nil, fmt.Errorf("unknown retriever mode: %s", mode)
+	}
+}
+
+// AsQueryEngine returns a query engine over the collapsed tree.
+func (ri *RaptorIndex) AsQueryEngine(opts ...QueryEngineOption) queryengine.QueryEngine {
+	config := &QueryEngineConfig{
+		ResponseMode: synthesizer.ResponseModeCompact,
+	}
+	config.SimilarityTopK = 10
+
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	ret := ri.AsRetriever(WithSimilarityTopK(config.SimilarityTopK))
+
+	var synth synthesizer.Synthesizer
+	if config.Synthesizer != nil {
+		synth = config.Synthesizer
+	} else if config.LLM != nil {
+		synth, _ = synthesizer.GetSynthesizer(config.ResponseMode, config.LLM)
+	} else if ri.llm != nil {
+		synth, _ = synthesizer.GetSynthesizer(config.ResponseMode, ri.llm)
+	} else {
+		synth = synthesizer.NewSimpleSynthesizer(llm.NewMockLLM(""))
+	}
+
+	return queryengine.NewRetrieverQueryEngine(ret, synth)
+}
+
+// InsertNodes adds nodes as leaves. They are searchable immediately but are
+// not summarized until Rebuild is called.
+func (ri *RaptorIndex) InsertNodes(ctx context.Context, nodes []schema.Node) error {
+	embedded, err := ri.getNodesWithEmbeddings(ctx, nodes)
+	if err != nil {
+		return err
+	}
+	for i := range embedded {
+		embedded[i] = withRaptorLevel(embedded[i], 0)
+	}
+	if err := ri.storeNodes(ctx, embedded); err != nil {
+		return err
+	}
+	if len(ri.levels) == 0 {
+		ri.levels = [][]string{nil}
+	}
+	ri.levels[0] = append(ri.levels[0], nodeIDs(embedded, allIndices(len(embedded)))...)
+	return nil
+}
+
+// DeleteNodes removes nodes from the index. Summaries that cover them are
+// kept until Rebuild is called.
+func (ri *RaptorIndex) DeleteNodes(ctx context.Context, nodeIDs []string) error {
+	if err := ri.VectorStoreIndex.DeleteNodes(ctx, nodeIDs); err != nil {
+		return err
+	}
+
+	deleted := make(map[string]bool, len(nodeIDs))
+	for _, id := range nodeIDs {
+		deleted[id] = true
+		delete(ri.nodes, id)
+		delete(ri.indexStruct.NodeIDToChildrenIDs, id)
+	}
+	for level, ids := range ri.levels {
+		kept := ids[:0]
+		for _, id := range ids {
+			if !deleted[id] {
+				kept = append(kept, id)
+			}
+		}
+		ri.levels[level] = kept
+	}
+	return nil
+}
+
+// Rebuild discards all summary levels and rebuilds the tree from the
+// current leaves.
+func (ri *RaptorIndex) Rebuild(ctx context.Context) error {
+	if len(ri.levels) == 0 {
+		return nil
+	}
+
+	var summaries []string
+	for _, ids := range ri.levels[1:] {
+		summaries = append(summaries, ids...)
+	}
+
+	leaves := make([]schema.Node, 0, len(ri.levels[0]))
+	for _, id := range ri.levels[0] {
+		node := ri.nodes[id]
+		delete(node.Metadata, RaptorLevelMetadataKey)
+		if node.Relationships != nil {
+			delete(node.Relationships, schema.RelationshipParent)
+		}
+		leaves = append(leaves, node)
+	}
+
+	if err := ri.DeleteNodes(ctx, append(summaries, ri.levels[0]...)); err != nil {
+		return err
+	}
+	ri.levels = nil
+
+	if err := ri.buildTree(ctx, leaves); err != nil {
+		return err
+	}
+	return ri.storageContext.IndexStore.AddIndexStruct(ctx, ri.indexStruct)
+}
+
+func allIndices(n int) []int {
+	indices := make([]int, n)
+	for i := range indices {
+		indices[i] = i
+	}
+	return indices
+}
+
+// RaptorRetrieverOption configures RAPTOR retrievers.
+type RaptorRetrieverOption func(*raptorRetrieverConfig)
+
+type raptorRetrieverConfig struct {
+	topK        int
+	embedModel  EmbeddingModel
+	filters     *schema.MetadataFilters
+	tokenBudget int
+	tokenizer   textutil.Tokenizer
+}
+
+// WithRaptorRetrieverTopK sets the number of nodes to return. For tree
+// traversal it is the number of nodes kept at each level.
+func WithRaptorRetrieverTopK(k int) RaptorRetrieverOption {
+	return func(c *raptorRetrieverConfig) {
+		c.topK = k
+	}
+}
+
+// WithRaptorRetrieverEmbedModel sets the query embedding model.
+func WithRaptorRetrieverEmbedModel(model EmbeddingModel) RaptorRetrieverOption {
+	return func(c *raptorRetrieverConfig) {
+		c.embedModel = model
+	}
+}
+
+// WithRaptorRetrieverFilters sets metadata filters for collapsed retrieval.
+func WithRaptorRetrieverFilters(filters *schema.MetadataFilters) RaptorRetrieverOption {
+	return func(c *raptorRetrieverConfig) {
+		c.filters = filters
+	}
+}
+
+// WithRaptorRetrieverTokenBudget caps the total tokens of the nodes returned
+// by collapsed retrieval. Nodes are taken in score order until the next one
+// would exceed the budget. Tokens are counted with the tokenizer, or as
+// whitespace-separated words when none is set.
+func WithRaptorRetrieverTokenBudget(maxTokens int, tokenizer textutil.Tokenizer) RaptorRetrieverOption {
+	return func(c *raptorRetrieverConfig) {
+		c.tokenBudget = maxTokens
+		c.tokenizer = tokenizer
+	}
+}
+
+func newRaptorRetrieverConfig(index *RaptorIndex, opts []RaptorRetrieverOption) raptorRetrieverConfig {
+	config := raptorRetrieverConfig{
+		topK:       10,
+		embedModel: index.embedModel,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.embedModel == nil {
+		config.embedModel = index.embedModel
+	}
+	return config
+}
+
+// RaptorCollapsedRetriever searches every level of a RaptorIndex at once,
+// so leaves and summaries compete on similarity alone.
+type RaptorCollapsedRetriever struct {
+	index  *RaptorIndex
+	config raptorRetrieverConfig
+}
+
+// NewRaptorCollapsedRetriever creates a new RaptorCollapsedRetriever.
+func NewRaptorCollapsedRetriever(index *RaptorIndex, opts ...RaptorRetrieverOption) *RaptorCollapsedRetriever {
+	return &RaptorCollapsedRetriever{
+		index:  index,
+		config: newRaptorRetrieverConfig(index, opts),
+	}
+}
+
+// Retrieve retrieves nodes for a query.
+func (r *RaptorCollapsedRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	if r.config.embedModel == nil {
+		return nil, fmt.Errorf("embedding model not configured")
+	}
+
+	queryEmbedding, err := r.config.embedModel.GetQueryEmbedding(ctx, query.QueryString)
+	if err != nil {
+		return nil, err
+	}
+
+	vsQuery := schema.NewVectorStoreQuery(queryEmbedding, r.config.topK)
+	if r.config.filters != nil {
+		vsQuery.Filters = r.config.filters
+	}
+
+	results, err := r.index.vectorStore.Query(ctx, *vsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.config.tokenBudget <= 0 {
+		return results, nil
+	}
+
+	used := 0
+	for i, result := range results {
+		used += r.countTokens(result.Node.GetContent(schema.MetadataModeLLM))
+		if used > r.config.tokenBudget {
+			return results[:i], nil
+		}
+	}
+	return results, nil
+}
+
+func (r *RaptorCollapsedRetriever) countTokens(text string) int {
+	if r.config.tokenizer != nil {
+		return textutil.TokenLen(text, r.config.tokenizer)
+	}
+	return len(strings.Fields(text))
+}
+
+// RaptorTreeTraversalRetriever starts at the top level of a RaptorIndex,
+// keeps the topK most similar nodes, and repeats among their children down
+// to the leaves. It returns the selected nodes from every level.
+type RaptorTreeTraversalRetriever struct {
+	index  *RaptorIndex
+	config raptorRetrieverConfig
+}
+
+// NewRaptorTreeTraversalRetriever creates a new RaptorTreeTraversalRetriever.
+func NewRaptorTreeTraversalRetriever(index *RaptorIndex, opts ...RaptorRetrieverOption) *RaptorTreeTraversalRetriever {
+	config := newRaptorRetrieverConfig(index, opts)
+	if config.topK <= 0 {
+		config.topK = 1
+	}
+	return &RaptorTreeTraversalRetriever{
+		index:  index,
+		config: config,
+	}
+}
+
+// Retrieve retrieves nodes for a query.
+func (r *RaptorTreeTraversalRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	if r.config.embedModel == nil {
+		return nil, fmt.Errorf("embedding model not configured")
+	}
+	levels := r.index.levels
+	if len(levels) == 0 {
+		return []schema.NodeWithScore{}, nil
+	}
+
+	queryEmbedding, err := r.config.embedModel.GetQueryEmbedding(ctx, query.QueryString)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []schema.NodeWithScore
+	candidates := levels[len(levels)-1]
+	for len(candidates) > 0 {
+		scored := make([]schema.NodeWithScore, 0, len(candidates))
+		for _, id := range candidates {
+			node, ok := r.index.nodes[id]
+			if !ok {
+				continue
+			}
+			scored = append(scored, schema.NodeWithScore{
+				Node:  node,
+				Score: cosineSimilarity(queryEmbedding, node.Embedding),
+			})
+		}
+		sort.SliceStable(scored, func(i, j int) bool {
+			return scored[i].Score > scored[j].Score
+		})
+		if len(scored) > r.config.topK {
+			scored = scored[:r.config.topK]
+		}
+		results = append(results, scored...)
+
+		var next []string
+		for _, s := range scored {
+			next = append(next, r.index.GetChildren(s.Node.ID)...)
+		}
+		candidates = next
+	}
+
+	return results, nil
+}
+
+// Ensure RaptorIndex implements Index.
+var _ Index = (*RaptorIndex)(nil)
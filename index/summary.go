@@ -3,8 +3,11 @@ package index
 import (
 	"context"
 	"fmt"
+	"sort"
 
+	"github.com/aqua777/go-llamaindex/ingestion"
 	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/postprocessor"
 	"github.com/aqua777/go-llamaindex/rag/queryengine"
 	"github.com/aqua777/go-llamaindex/rag/retriever"
 	"github.com/aqua777/go-llamaindex/rag/synthesizer"
@@ -26,10 +29,18 @@ const (
 	SummaryRetrieverModeLLM SummaryRetrieverMode = "llm"
 )
 
-// SummaryIndex is a simple index that stores nodes in a list.
-// During query time, it iterates through all nodes to synthesize an answer.
+// SummaryIndex is a simple index that stores nodes in a list, in insertion
+// order. By default queries retrieve all nodes and synthesize an answer from
+// them with tree summarization, which suits summarizing whole documents;
+// the embedding and LLM retriever modes select the relevant nodes instead.
 type SummaryIndex struct {
 	*BaseIndex
+	// transformations turn inserted documents into nodes.
+	transformations []ingestion.TransformComponent
+	// retrieverMode is the mode of AsRetriever and AsQueryEngine.
+	retrieverMode SummaryRetrieverMode
+	// llm selects nodes in LLM mode and synthesizes answers.
+	llm llm.LLM
 }
 
 // SummaryIndexOption configures SummaryIndex creation.
@@ -49,12 +60,38 @@ func WithSummaryIndexEmbedModel(model EmbeddingModel) SummaryIndexOption {
 	}
 }
 
+// WithSummaryIndexTransformations sets the transformations that turn
+// inserted documents into nodes, e.g. an ingestion.NodeParserTransform.
+// Without transformations each document is stored as a single node.
+func WithSummaryIndexTransformations(transformations ...ingestion.TransformComponent) SummaryIndexOption {
+	return func(si *SummaryIndex) {
+		si.transformations = transformations
+	}
+}
+
+// WithSummaryIndexRetrieverMode sets the retriever mode used by AsRetriever
+// and AsQueryEngine. Defaults to SummaryRetrieverModeDefault.
+func WithSummaryIndexRetrieverMode(mode SummaryRetrieverMode) SummaryIndexOption {
+	return func(si *SummaryIndex) {
+		si.retrieverMode = mode
+	}
+}
+
+// WithSummaryIndexLLM sets the LLM used by the LLM retriever mode and by
+// query engines created without one.
+func WithSummaryIndexLLM(l llm.LLM) SummaryIndexOption {
+	return func(si *SummaryIndex) {
+		si.llm = l
+	}
+}
+
 // NewSummaryIndex creates a new SummaryIndex.
 func NewSummaryIndex(ctx context.Context, nodes []schema.Node, opts ...SummaryIndexOption) (*SummaryIndex, error) {
 	indexStruct := indexstore.NewListIndex()
 
 	si := &SummaryIndex{
-		BaseIndex: NewBaseIndex(indexStruct),
+		BaseIndex:     NewBaseIndex(indexStruct),
+		retrieverMode: SummaryRetrieverModeDefault,
 	}
 
 	for _, opt := range opts {
@@ -76,24 +113,21 @@ func NewSummaryIndex(ctx context.Context, nodes []schema.Node, opts ...SummaryIn
 	return si, nil
 }
 
-// NewSummaryIndexFromDocuments creates a SummaryIndex from documents.
+// NewSummaryIndexFromDocuments creates a SummaryIndex from documents,
+// running them through the index's transformations.
 func NewSummaryIndexFromDocuments(
 	ctx context.Context,
 	documents []schema.Document,
 	opts ...SummaryIndexOption,
 ) (*SummaryIndex, error) {
-	// Convert documents to nodes
-	var nodes []schema.Node
-	for _, doc := range documents {
-		node := schema.NewTextNode(doc.Text)
-		node.Metadata = doc.Metadata
-		if doc.ID != "" {
-			node.ID = doc.ID
-		}
-		nodes = append(nodes, *node)
+	si, err := NewSummaryIndex(ctx, nil, opts...)
+	if err != nil {
+		return nil, err
 	}
-
-	return NewSummaryIndex(ctx, nodes, opts...)
+	if err := si.InsertDocuments(ctx, documents); err != nil {
+		return nil, err
+	}
+	return si, nil
 }
 
 // buildIndexFromNodes builds the index from nodes.
@@ -110,7 +144,8 @@ func (si *SummaryIndex) buildIndexFromNodes(ctx context.Context, nodes []schema.
 	return nil
 }
 
-// AsRetriever returns a retriever for this index.
+// AsRetriever returns a retriever in the index's retriever mode. A
+// similarity top-k of 0 selects all relevant nodes.
 func (si *SummaryIndex) AsRetriever(opts ...RetrieverOption) retriever.Retriever {
 	config := &RetrieverConfig{
 		SimilarityTopK: 0, // 0 means return all nodes
@@ -121,33 +156,68 @@ func (si *SummaryIndex) AsRetriever(opts ...RetrieverOption) retriever.Retriever
 		opt(config)
 	}
 
-	return &SummaryIndexRetriever{
-		index:          si,
-		mode:           SummaryRetrieverModeDefault,
-		similarityTopK: config.SimilarityTopK,
-		embedModel:     config.EmbedModel,
+	return NewSummaryIndexRetriever(si,
+		WithSummaryRetrieverMode(si.retrieverMode),
+		WithSummaryRetrieverTopK(config.SimilarityTopK),
+		WithSummaryRetrieverEmbedModel(config.EmbedModel),
+	)
+}
+
+// AsRetrieverWithMode returns a retriever with the specified mode. It fails
+// if the mode needs an embedding model or LLM that is not configured.
+func (si *SummaryIndex) AsRetrieverWithMode(mode SummaryRetrieverMode, opts ...SummaryIndexRetrieverOption) (retriever.Retriever, error) {
+	r := NewSummaryIndexRetriever(si, append([]SummaryIndexRetrieverOption{WithSummaryRetrieverMode(mode)}, opts...)...)
+	switch mode {
+	case SummaryRetrieverModeDefault:
+	case SummaryRetrieverModeEmbedding:
+		if r.embedModel == nil {
+			return nil, fmt.Errorf("retriever mode %s requires an embedding model", mode)
+		}
+	case SummaryRetrieverModeLLM:
+		if r.llm == nil {
+			return nil, fmt.Errorf("retriever mode %s requires an LLM", mode)
+		}
+	default:
+		return nil, fmt.Errorf("unknown retriever mode: %s", mode)
 	}
+	return r, nil
 }
 
-// AsQueryEngine returns a query engine for this index.
+// AsQueryEngine returns a query engine for this index. Answers are
+// synthesized with tree summarization unless another response mode, such
+// as compact, is set.
 func (si *SummaryIndex) AsQueryEngine(opts ...QueryEngineOption) queryengine.QueryEngine {
 	config := &QueryEngineConfig{
 		ResponseMode: synthesizer.ResponseModeTreeSummarize,
 	}
+	config.EmbedModel = si.embedModel
 
 	for _, opt := range opts {
 		opt(config)
 	}
 
+	l := config.LLM
+	if l == nil {
+		l = si.llm
+	}
+
 	// Create retriever
-	ret := si.AsRetriever()
+	retrieverOpts := []SummaryIndexRetrieverOption{
+		WithSummaryRetrieverMode(si.retrieverMode),
+		WithSummaryRetrieverTopK(config.SimilarityTopK),
+		WithSummaryRetrieverEmbedModel(config.EmbedModel),
+	}
+	if l != nil {
+		retrieverOpts = append(retrieverOpts, WithSummaryRetrieverLLM(l))
+	}
+	ret := NewSummaryIndexRetriever(si, retrieverOpts...)
 
 	// Create synthesizer
 	var synth synthesizer.Synthesizer
 	if config.Synthesizer != nil {
 		synth = config.Synthesizer
-	} else if config.LLM != nil {
-		synth, _ = synthesizer.GetSynthesizer(config.ResponseMode, config.LLM)
+	} else if l != nil {
+		synth, _ = synthesizer.GetSynthesizer(config.ResponseMode, l)
 	} else {
 		// Use a default mock LLM for now
 		synth = synthesizer.NewSimpleSynthesizer(llm.NewMockLLM(""))
@@ -171,6 +241,28 @@ func (si *SummaryIndex) InsertNodes(ctx context.Context, nodes []schema.Node) er
 	return si.storageContext.IndexStore.AddIndexStruct(ctx, si.indexStruct)
 }
 
+// InsertDocuments runs documents through the index's transformations and
+// appends the resulting nodes. The docstore tracks the nodes and hash of
+// each document.
+func (si *SummaryIndex) InsertDocuments(ctx context.Context, documents []schema.Document) error {
+	nodes, err := documentNodes(ctx, documents, si.transformations)
+	if err != nil {
+		return err
+	}
+	if err := si.buildIndexFromNodes(ctx, nodes); err != nil {
+		return err
+	}
+	for _, doc := range documents {
+		if doc.ID == "" {
+			continue
+		}
+		if err := si.storageContext.DocStore.SetDocumentHash(ctx, doc.ID, doc.GetHash()); err != nil {
+			return err
+		}
+	}
+	return si.storageContext.IndexStore.AddIndexStruct(ctx, si.indexStruct)
+}
+
 // DeleteNodes removes nodes from the index.
 func (si *SummaryIndex) DeleteNodes(ctx context.Context, nodeIDs []string) error {
 	// Get current nodes
@@ -202,39 +294,47 @@ func (si *SummaryIndex) DeleteNodes(ctx context.Context, nodeIDs []string) error
 	return si.storageContext.IndexStore.AddIndexStruct(ctx, si.indexStruct)
 }
 
-// RefreshDocuments refreshes the index with updated documents.
+// DeleteRefDoc removes the nodes of a document inserted with
+// InsertDocuments from the index and the docstore.
+func (si *SummaryIndex) DeleteRefDoc(ctx context.Context, refDocID string) error {
+	info, err := si.storageContext.DocStore.GetRefDocInfo(ctx, refDocID)
+	if err != nil {
+		return err
+	}
+	nodeIDs := []string{refDocID}
+	if info != nil {
+		for _, id := range info.NodeIDs {
+			if id != refDocID {
+				nodeIDs = append(nodeIDs, id)
+			}
+		}
+	}
+	if err := si.DeleteNodes(ctx, nodeIDs); err != nil {
+		return err
+	}
+	return si.storageContext.DocStore.DeleteRefDoc(ctx, refDocID, false)
+}
+
+// RefreshDocuments inserts new documents and replaces the nodes of
+// documents whose hash changed since they were inserted; unchanged
+// documents are skipped. Replaced documents move to the end of the list.
 func (si *SummaryIndex) RefreshDocuments(ctx context.Context, documents []schema.Document) ([]bool, error) {
 	refreshed := make([]bool, len(documents))
 
 	for i, doc := range documents {
-		// Check if document exists and has changed
 		existingHash, err := si.storageContext.DocStore.GetDocumentHash(ctx, doc.ID)
-		if err != nil || existingHash == "" {
-			// Document doesn't exist, insert it
-			node := schema.NewTextNode(doc.Text)
-			node.Metadata = doc.Metadata
-			if doc.ID != "" {
-				node.ID = doc.ID
-			}
-			if err := si.InsertNodes(ctx, []schema.Node{*node}); err != nil {
-				return refreshed, err
-			}
-			refreshed[i] = true
-		} else if existingHash != doc.GetHash() {
-			// Document has changed, update it
-			if err := si.DeleteNodes(ctx, []string{doc.ID}); err != nil {
-				return refreshed, err
-			}
-			node := schema.NewTextNode(doc.Text)
-			node.Metadata = doc.Metadata
-			if doc.ID != "" {
-				node.ID = doc.ID
-			}
-			if err := si.InsertNodes(ctx, []schema.Node{*node}); err != nil {
+		if err == nil && existingHash == doc.GetHash() {
+			continue
+		}
+		if err == nil && existingHash != "" {
+			if err := si.DeleteRefDoc(ctx, doc.ID); err != nil {
 				return refreshed, err
 			}
-			refreshed[i] = true
 		}
+		if err := si.InsertDocuments(ctx, []schema.Document{doc}); err != nil {
+			return refreshed, err
+		}
+		refreshed[i] = true
 	}
 
 	return refreshed, nil
@@ -265,11 +365,12 @@ func (si *SummaryIndex) GetNodes(ctx context.Context) ([]schema.Node, error) {
 
 // SummaryIndexRetriever retrieves nodes from a SummaryIndex.
 type SummaryIndexRetriever struct {
-	index          *SummaryIndex
-	mode           SummaryRetrieverMode
-	similarityTopK int
-	embedModel     EmbeddingModel
-	llm            llm.LLM
+	index           *SummaryIndex
+	mode            SummaryRetrieverMode
+	similarityTopK  int
+	embedModel      EmbeddingModel
+	llm             llm.LLM
+	choiceBatchSize int
 }
 
 // SummaryIndexRetrieverOption configures the retriever.
@@ -289,6 +390,49 @@ func WithSummaryRetrieverLLM(l llm.LLM) SummaryIndexRetrieverOption {
 	}
 }
 
+// WithSummaryRetrieverTopK sets the number of nodes returned in embedding
+// and LLM mode. 0 returns all nodes the LLM selects, or all nodes in
+// embedding mode.
+func WithSummaryRetrieverTopK(k int) SummaryIndexRetrieverOption {
+	return func(r *SummaryIndexRetriever) {
+		r.similarityTopK = k
+	}
+}
+
+// WithSummaryRetrieverEmbedModel sets the embedding model for embedding
+// mode.
+func WithSummaryRetrieverEmbedModel(model EmbeddingModel) SummaryIndexRetrieverOption {
+	return func(r *SummaryIndexRetriever) {
+		r.embedModel = model
+	}
+}
+
+// WithSummaryRetrieverChoiceBatchSize sets how many nodes are shown to the
+// LLM per call in LLM mode. Defaults to 10.
+func WithSummaryRetrieverChoiceBatchSize(size int) SummaryIndexRetrieverOption {
+	return func(r *SummaryIndexRetriever) {
+		if size > 0 {
+			r.choiceBatchSize = size
+		}
+	}
+}
+
+// NewSummaryIndexRetriever creates a retriever for si, using the index's
+// embedding model and LLM unless options override them.
+func NewSummaryIndexRetriever(si *SummaryIndex, opts ...SummaryIndexRetrieverOption) *SummaryIndexRetriever {
+	r := &SummaryIndexRetriever{
+		index:           si,
+		mode:            SummaryRetrieverModeDefault,
+		embedModel:      si.embedModel,
+		llm:             si.llm,
+		choiceBatchSize: 10,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
 // Retrieve retrieves nodes for a query.
 func (r *SummaryIndexRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
 	nodeIDs := r.index.indexStruct.Nodes
@@ -297,10 +441,16 @@ func (r *SummaryIndexRetriever) Retrieve(ctx context.Context, query schema.Query
 	}
 
 	// Get nodes from docstore
-	nodes, err := docstore.GetNodes(ctx, r.index.storageContext.DocStore, nodeIDs, false)
+	baseNodes, err := docstore.GetNodes(ctx, r.index.storageContext.DocStore, nodeIDs, false)
 	if err != nil {
 		return nil, err
 	}
+	nodes := make([]schema.Node, 0, len(baseNodes))
+	for _, n := range baseNodes {
+		if node, ok := n.(*schema.Node); ok {
+			nodes = append(nodes, *node)
+		}
+	}
 
 	switch r.mode {
 	case SummaryRetrieverModeDefault:
@@ -314,19 +464,18 @@ func (r *SummaryIndexRetriever) Retrieve(ctx context.Context, query schema.Query
 	}
 }
 
-// retrieveDefault returns all nodes.
-func (r *SummaryIndexRetriever) retrieveDefault(nodes []schema.BaseNode) ([]schema.NodeWithScore, error) {
+// retrieveDefault returns all nodes in list order.
+func (r *SummaryIndexRetriever) retrieveDefault(nodes []schema.Node) ([]schema.NodeWithScore, error) {
 	results := make([]schema.NodeWithScore, len(nodes))
-	for i, n := range nodes {
-		if node, ok := n.(*schema.Node); ok {
-			results[i] = schema.NodeWithScore{Node: *node, Score: 1.0}
-		}
+	for i, node := range nodes {
+		results[i] = schema.NodeWithScore{Node: node, Score: 1.0}
 	}
 	return results, nil
 }
 
-// retrieveEmbedding uses embeddings to select top-k nodes.
-func (r *SummaryIndexRetriever) retrieveEmbedding(ctx context.Context, query schema.QueryBundle, nodes []schema.BaseNode) ([]schema.NodeWithScore, error) {
+// retrieveEmbedding uses embeddings to select top-k nodes. Nodes without an
+// embedding are embedded once and stored back to the docstore.
+func (r *SummaryIndexRetriever) retrieveEmbedding(ctx context.Context, query schema.QueryBundle, nodes []schema.Node) ([]schema.NodeWithScore, error) {
 	if r.embedModel == nil {
 		return nil, fmt.Errorf("embedding model not configured for embedding mode")
 	}
@@ -338,61 +487,54 @@ func (r *SummaryIndexRetriever) retrieveEmbedding(ctx context.Context, query sch
 	}
 
 	// Score each node
-	type scoredNode struct {
-		node  schema.Node
-		score float64
-	}
-	var scored []scoredNode
-
-	for _, n := range nodes {
-		node, ok := n.(*schema.Node)
-		if !ok {
-			continue
-		}
-
-		// Get node embedding
-		nodeEmbedding, err := r.embedModel.GetTextEmbedding(ctx, node.GetContent(schema.MetadataModeEmbed))
-		if err != nil {
-			continue
+	results := make([]schema.NodeWithScore, 0, len(nodes))
+	var embedded []schema.BaseNode
+	for i := range nodes {
+		node := &nodes[i]
+		if len(node.Embedding) == 0 {
+			node.Embedding, err = r.embedModel.GetTextEmbedding(ctx, node.GetContent(schema.MetadataModeEmbed))
+			if err != nil {
+				return nil, fmt.Errorf("failed to embed node %s: %w", node.ID, err)
+			}
+			embedded = append(embedded, node)
 		}
-
-		// Calculate cosine similarity
-		score := cosineSimilarity(queryEmbedding, nodeEmbedding)
-		scored = append(scored, scoredNode{node: *node, score: score})
+		results = append(results, schema.NodeWithScore{Node: *node, Score: cosineSimilarity(queryEmbedding, node.Embedding)})
 	}
-
-	// Sort by score descending
-	for i := 0; i < len(scored)-1; i++ {
-		for j := i + 1; j < len(scored); j++ {
-			if scored[j].score > scored[i].score {
-				scored[i], scored[j] = scored[j], scored[i]
-			}
+	if len(embedded) > 0 {
+		if err := r.index.storageContext.DocStore.AddDocuments(ctx, embedded, true); err != nil {
+			return nil, err
 		}
 	}
 
-	// Return top-k
-	k := r.similarityTopK
-	if k <= 0 || k > len(scored) {
-		k = len(scored)
-	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
 
-	results := make([]schema.NodeWithScore, k)
-	for i := 0; i < k; i++ {
-		results[i] = schema.NodeWithScore{Node: scored[i].node, Score: scored[i].score}
+	// Return top-k
+	if r.similarityTopK > 0 && r.similarityTopK < len(results) {
+		results = results[:r.similarityTopK]
 	}
-
 	return results, nil
 }
 
-// retrieveLLM uses an LLM to select relevant nodes.
-func (r *SummaryIndexRetriever) retrieveLLM(ctx context.Context, query schema.QueryBundle, nodes []schema.BaseNode) ([]schema.NodeWithScore, error) {
+// retrieveLLM shows the nodes to the LLM in batches and keeps those it
+// selects as relevant, scored by the relevance it gives them.
+func (r *SummaryIndexRetriever) retrieveLLM(ctx context.Context, query schema.QueryBundle, nodes []schema.Node) ([]schema.NodeWithScore, error) {
 	if r.llm == nil {
 		return nil, fmt.Errorf("LLM not configured for LLM mode")
 	}
 
-	// For simplicity, return all nodes - in production, this would use the LLM
-	// to determine which nodes are relevant
-	return r.retrieveDefault(nodes)
+	topN := r.similarityTopK
+	if topN <= 0 {
+		topN = len(nodes)
+	}
+	selector := postprocessor.NewLLMRerank(
+		postprocessor.WithLLMRerankLLM(r.llm),
+		postprocessor.WithLLMRerankTopN(topN),
+		postprocessor.WithLLMRerankBatchSize(r.choiceBatchSize),
+	)
+	candidates, _ := r.retrieveDefault(nodes)
+	return selector.PostprocessNodes(ctx, candidates, &query)
 }
 
 // cosineSimilarity calculates the cosine similarity between two vectors.
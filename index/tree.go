@@ -14,6 +14,7 @@ import (
 	"github.com/aqua777/go-llamaindex/storage"
 	"github.com/aqua777/go-llamaindex/storage/docstore"
 	"github.com/aqua777/go-llamaindex/storage/indexstore"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 // TreeRetrieverMode specifies how the tree index retrieves nodes.
@@ -272,10 +273,7 @@ func (ti *TreeIndex) buildTreeFromNodes(ctx context.Context, curNodeIDs map[int]
 func (ti *TreeIndex) generateSummary(ctx context.Context, text string) (string, error) {
 	if ti.llm == nil {
 		// Return truncated text if no LLM
-		if len(text) > 500 {
-			return text[:500] + "...", nil
-		}
-		return text, nil
+		return textutil.Truncate(text, 500), nil
 	}
 
 	prompt := ti.summaryTemplate.Format(map[string]string{
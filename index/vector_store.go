@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/ingestion"
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/rag/queryengine"
 	"github.com/aqua777/go-llamaindex/rag/retriever"
@@ -23,6 +25,8 @@ type VectorStoreIndex struct {
 	insertBatchSize int
 	// storeNodesOverride forces storing nodes in docstore even if vector store stores text.
 	storeNodesOverride bool
+	// transformations turn inserted documents into nodes.
+	transformations []ingestion.TransformComponent
 }
 
 // VectorStoreIndexOption configures VectorStoreIndex creation.
@@ -63,6 +67,16 @@ func WithVectorIndexEmbedModel(model EmbeddingModel) VectorStoreIndexOption {
 	}
 }
 
+// WithVectorIndexTransformations sets the transformations that turn
+// inserted documents into nodes, e.g. an ingestion.NodeParserTransform
+// that chunks them. Without transformations each document is indexed as a
+// single node.
+func WithVectorIndexTransformations(transformations ...ingestion.TransformComponent) VectorStoreIndexOption {
+	return func(vsi *VectorStoreIndex) {
+		vsi.transformations = transformations
+	}
+}
+
 // NewVectorStoreIndex creates a new VectorStoreIndex.
 func NewVectorStoreIndex(ctx context.Context, nodes []schema.Node, opts ...VectorStoreIndexOption) (*VectorStoreIndex, error) {
 	indexStruct := indexstore.NewVectorStoreIndex()
@@ -97,24 +111,23 @@ func NewVectorStoreIndex(ctx context.Context, nodes []schema.Node, opts ...Vecto
 	return vsi, nil
 }
 
-// NewVectorStoreIndexFromDocuments creates a VectorStoreIndex from documents.
+// NewVectorStoreIndexFromDocuments creates a VectorStoreIndex from
+// documents, running them through the index's transformations. The nodes
+// and hash of every document are tracked in the docstore, so documents can
+// later be deleted with DeleteRefDoc or updated with RefreshDocuments.
 func NewVectorStoreIndexFromDocuments(
 	ctx context.Context,
 	documents []schema.Document,
 	opts ...VectorStoreIndexOption,
 ) (*VectorStoreIndex, error) {
-	// Convert documents to nodes
-	var nodes []schema.Node
-	for _, doc := range documents {
-		node := schema.NewTextNode(doc.Text)
-		node.Metadata = doc.Metadata
-		if doc.ID != "" {
-			node.ID = doc.ID
-		}
-		nodes = append(nodes, *node)
+	vsi, err := NewVectorStoreIndex(ctx, nil, opts...)
+	if err != nil {
+		return nil, err
 	}
-
-	return NewVectorStoreIndex(ctx, nodes, opts...)
+	if err := vsi.InsertDocuments(ctx, documents); err != nil {
+		return nil, err
+	}
+	return vsi, nil
 }
 
 // NewVectorStoreIndexFromVectorStore creates a VectorStoreIndex from an existing vector store.
@@ -141,11 +154,12 @@ func (vsi *VectorStoreIndex) buildIndexFromNodes(ctx context.Context, nodes []sc
 		return nil
 	}
 
-	return vsi.addNodesToIndex(ctx, contentNodes)
+	return vsi.addNodesToIndex(ctx, contentNodes, vsi.storeNodesOverride)
 }
 
-// addNodesToIndex adds nodes to the index.
-func (vsi *VectorStoreIndex) addNodesToIndex(ctx context.Context, nodes []schema.Node) error {
+// addNodesToIndex adds nodes to the index, and to the docstore when
+// storeNodes is set.
+func (vsi *VectorStoreIndex) addNodesToIndex(ctx context.Context, nodes []schema.Node, storeNodes bool) error {
 	if vsi.vectorStore == nil {
 		return fmt.Errorf("vector store not configured")
 	}
@@ -177,7 +191,7 @@ func (vsi *VectorStoreIndex) addNodesToIndex(ctx context.Context, nodes []schema
 		}
 
 		// Store nodes in docstore if needed
-		if vsi.storeNodesOverride {
+		if storeNodes {
 			for _, node := range nodesWithEmbeddings {
 				// Clear embedding to avoid duplication
 				nodeCopy := node
@@ -198,15 +212,26 @@ func (vsi *VectorStoreIndex) getNodesWithEmbeddings(ctx context.Context, nodes [
 		return nil, fmt.Errorf("embedding model not configured")
 	}
 
+	modelName := embedding.ModelName(vsi.embedModel)
 	result := make([]schema.Node, len(nodes))
 	for i, node := range nodes {
-		embedding, err := vsi.embedModel.GetTextEmbedding(ctx, node.GetContent(schema.MetadataModeEmbed))
+		emb, err := vsi.embedModel.GetTextEmbedding(ctx, node.GetContent(schema.MetadataModeEmbed))
 		if err != nil {
 			return nil, err
 		}
 
 		nodeCopy := node
-		nodeCopy.Embedding = embedding
+		nodeCopy.Embedding = emb
+		if modelName != "" {
+			// Copy metadata and exclusions so the caller's node is not modified.
+			nodeCopy.Metadata = make(map[string]interface{}, len(node.Metadata)+1)
+			for k, v := range node.Metadata {
+				nodeCopy.Metadata[k] = v
+			}
+			nodeCopy.ExcludedEmbedMetadataKeys = append([]string{}, node.ExcludedEmbedMetadataKeys...)
+			nodeCopy.ExcludedLLMMetadataKeys = append([]string{}, node.ExcludedLLMMetadataKeys...)
+			nodeCopy.SetEmbeddingModel(modelName)
+		}
 		result[i] = nodeCopy
 	}
 
@@ -249,9 +274,14 @@ func (vsi *VectorStoreIndex) AsQueryEngine(opts ...QueryEngineOption) queryengin
 	}
 
 	// Create retriever
-	ret := vsi.AsRetriever(
+	retrieverOpts := []RetrieverOption{
 		WithSimilarityTopK(config.SimilarityTopK),
-	)
+		WithRetrieverFilters(config.Filters),
+	}
+	if config.EmbedModel != nil {
+		retrieverOpts = append(retrieverOpts, WithRetrieverEmbedModel(config.EmbedModel))
+	}
+	ret := vsi.AsRetriever(retrieverOpts...)
 
 	// Create synthesizer
 	var synth synthesizer.Synthesizer
@@ -269,7 +299,35 @@ func (vsi *VectorStoreIndex) AsQueryEngine(opts ...QueryEngineOption) queryengin
 
 // InsertNodes inserts nodes into the index.
 func (vsi *VectorStoreIndex) InsertNodes(ctx context.Context, nodes []schema.Node) error {
-	return vsi.addNodesToIndex(ctx, nodes)
+	return vsi.addNodesToIndex(ctx, nodes, vsi.storeNodesOverride)
+}
+
+// InsertDocuments runs documents through the index's transformations and
+// inserts the resulting nodes. The nodes are also stored in the docstore,
+// which tracks the nodes and hash of each document.
+func (vsi *VectorStoreIndex) InsertDocuments(ctx context.Context, documents []schema.Document) error {
+	nodes, err := documentNodes(ctx, documents, vsi.transformations)
+	if err != nil {
+		return err
+	}
+	var contentNodes []schema.Node
+	for _, node := range nodes {
+		if node.GetContent(schema.MetadataModeEmbed) != "" {
+			contentNodes = append(contentNodes, node)
+		}
+	}
+	if err := vsi.addNodesToIndex(ctx, contentNodes, true); err != nil {
+		return err
+	}
+	for _, doc := range documents {
+		if doc.ID == "" {
+			continue
+		}
+		if err := vsi.storageContext.DocStore.SetDocumentHash(ctx, doc.ID, doc.GetHash()); err != nil {
+			return err
+		}
+	}
+	return vsi.storageContext.IndexStore.AddIndexStruct(ctx, vsi.indexStruct)
 }
 
 // DeleteNodes removes nodes from the index.
@@ -290,39 +348,50 @@ func (vsi *VectorStoreIndex) DeleteNodes(ctx context.Context, nodeIDs []string)
 	return vsi.storageContext.IndexStore.AddIndexStruct(ctx, vsi.indexStruct)
 }
 
-// RefreshDocuments refreshes the index with updated documents.
+// DeleteRefDoc removes the nodes of a document inserted with
+// InsertDocuments from the vector store, the index and the docstore.
+func (vsi *VectorStoreIndex) DeleteRefDoc(ctx context.Context, refDocID string) error {
+	info, err := vsi.storageContext.DocStore.GetRefDocInfo(ctx, refDocID)
+	if err != nil {
+		return err
+	}
+	nodeIDs := []string{refDocID}
+	if info != nil {
+		for _, id := range info.NodeIDs {
+			if id != refDocID {
+				nodeIDs = append(nodeIDs, id)
+			}
+		}
+	}
+	// The document ID is deleted too, for vector stores that delete by
+	// reference document and for documents indexed as a single node.
+	if err := vsi.DeleteNodes(ctx, nodeIDs); err != nil {
+		return err
+	}
+	return vsi.storageContext.DocStore.DeleteRefDoc(ctx, refDocID, false)
+}
+
+// RefreshDocuments inserts new documents and replaces the nodes of
+// documents whose hash changed since they were inserted; unchanged
+// documents are skipped. It reports which documents were inserted or
+// replaced.
 func (vsi *VectorStoreIndex) RefreshDocuments(ctx context.Context, documents []schema.Document) ([]bool, error) {
 	refreshed := make([]bool, len(documents))
 
 	for i, doc := range documents {
-		// Check if document exists and has changed
 		existingHash, err := vsi.storageContext.DocStore.GetDocumentHash(ctx, doc.ID)
-		if err != nil || existingHash == "" {
-			// Document doesn't exist, insert it
-			node := schema.NewTextNode(doc.Text)
-			node.Metadata = doc.Metadata
-			if doc.ID != "" {
-				node.ID = doc.ID
-			}
-			if err := vsi.InsertNodes(ctx, []schema.Node{*node}); err != nil {
-				return refreshed, err
-			}
-			refreshed[i] = true
-		} else if existingHash != doc.GetHash() {
-			// Document has changed, update it
-			if err := vsi.DeleteNodes(ctx, []string{doc.ID}); err != nil {
-				return refreshed, err
-			}
-			node := schema.NewTextNode(doc.Text)
-			node.Metadata = doc.Metadata
-			if doc.ID != "" {
-				node.ID = doc.ID
-			}
-			if err := vsi.InsertNodes(ctx, []schema.Node{*node}); err != nil {
+		if err == nil && existingHash == doc.GetHash() {
+			continue
+		}
+		if err == nil && existingHash != "" {
+			if err := vsi.DeleteRefDoc(ctx, doc.ID); err != nil {
 				return refreshed, err
 			}
-			refreshed[i] = true
 		}
+		if err := vsi.InsertDocuments(ctx, []schema.Document{doc}); err != nil {
+			return refreshed, err
+		}
+		refreshed[i] = true
 	}
 
 	return refreshed, nil
@@ -348,11 +417,10 @@ func (r *VectorIndexRetriever) Retrieve(ctx context.Context, query schema.QueryB
 		return nil, err
 	}
 
-	// Build vector store query
+	// Build vector store query; filters on the query narrow the retriever's
+	// own filters
 	vsQuery := schema.NewVectorStoreQuery(queryEmbedding, r.similarityTopK)
-	if r.filters != nil {
-		vsQuery.Filters = r.filters
-	}
+	vsQuery.Filters = schema.CombineMetadataFilters(r.filters, query.Filters)
 
 	// Query vector store
 	results, err := r.index.vectorStore.Query(ctx, *vsQuery)
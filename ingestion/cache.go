@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"os"
 	"sync"
+	"sync/atomic"
 
 	"github.com/aqua777/go-llamaindex/schema"
 )
@@ -18,6 +19,27 @@ type IngestionCache struct {
 	nodesKey   string
 	cache      map[string]map[string]interface{}
 	mu         sync.RWMutex
+	hits       atomic.Int64
+	misses     atomic.Int64
+}
+
+// CacheStats reports cache usage.
+type CacheStats struct {
+	// Hits is the number of successful lookups.
+	Hits int64 `json:"hits"`
+	// Misses is the number of lookups that found nothing.
+	Misses int64 `json:"misses"`
+	// Entries is the number of cached entries across all collections.
+	Entries int `json:"entries"`
+}
+
+// HitRate returns the fraction of lookups that hit, or 0 with no lookups.
+func (s CacheStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
 }
 
 // IngestionCacheOption configures an IngestionCache.
@@ -80,6 +102,16 @@ func (c *IngestionCache) Put(key string, nodes []schema.Node, collection string)
 
 // Get retrieves nodes from the cache.
 func (c *IngestionCache) Get(key string, collection string) ([]schema.Node, bool) {
+	nodes, ok := c.get(key, collection)
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return nodes, ok
+}
+
+func (c *IngestionCache) get(key string, collection string) ([]schema.Node, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -173,6 +205,22 @@ func NewIngestionCacheFromPath(path string, collection string) (*IngestionCache,
 	return cache, nil
 }
 
+// Stats returns hit, miss and entry counts.
+func (c *IngestionCache) Stats() CacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := 0
+	for _, collection := range c.cache {
+		entries += len(collection)
+	}
+	return CacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: entries,
+	}
+}
+
 // Collection returns the current collection name.
 func (c *IngestionCache) Collection() string {
 	return c.collection
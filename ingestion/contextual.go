@@ -0,0 +1,333 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
+)
+
+// DefaultContextualChunkPrompt asks the LLM to situate a chunk within its
+// document. It uses the {document} and {chunk} placeholders.
+const DefaultContextualChunkPrompt = `<document>
+{document}
+</document>
+Here is the chunk we want to situate within the whole document:
+<chunk>
+{chunk}
+</chunk>
+Please give a short succinct context to situate this chunk within the overall document for the purposes of improving search retrieval of the chunk. Answer only with the succinct context and nothing else.`
+
+// DefaultChunkContextMetadataKey is the metadata key holding generated
+// chunk context.
+const DefaultChunkContextMetadataKey = "chunk_context"
+
+// ContextPlacement controls where generated chunk context is stored.
+type ContextPlacement string
+
+const (
+	// ContextPlacementText prepends the context to the node text.
+	ContextPlacementText ContextPlacement = "text"
+	// ContextPlacementMetadata stores the context in node metadata only.
+	ContextPlacementMetadata ContextPlacement = "metadata"
+	// ContextPlacementBoth prepends the context and stores it in metadata.
+	ContextPlacementBoth ContextPlacement = "both"
+)
+
+// DocumentTextFunc returns the full text of a source document by ID.
+type DocumentTextFunc func(ctx context.Context, docID string) (string, error)
+
+// ContextualChunkTransform generates a short context for each chunk that
+// situates it within its parent document and adds it to the chunk before
+// embedding ("contextual retrieval"). A chunk like "Revenue grew 3%" becomes
+// findable for "ACME Q2 2023 revenue" once its context names the company
+// and quarter.
+//
+// Parent documents are resolved with a DocumentTextFunc when one is set.
+// Otherwise the transform reconstructs each document from the chunks in the
+// batch that share its source, so it must run after the splitter in the
+// same pipeline.
+type ContextualChunkTransform struct {
+	llm              llm.LLM
+	promptTemplate   string
+	placement        ContextPlacement
+	metadataKey      string
+	separator        string
+	maxDocumentRunes int
+	numWorkers       int
+	documentText     DocumentTextFunc
+	excludeFromLLM   bool
+}
+
+// ContextualChunkOption configures a ContextualChunkTransform.
+type ContextualChunkOption func(*ContextualChunkTransform)
+
+// WithContextPromptTemplate sets the prompt template.
+func WithContextPromptTemplate(template string) ContextualChunkOption {
+	return func(t *ContextualChunkTransform) {
+		t.promptTemplate = template
+	}
+}
+
+// WithContextPlacement sets where the context is stored. Defaults to
+// ContextPlacementBoth.
+func WithContextPlacement(placement ContextPlacement) ContextualChunkOption {
+	return func(t *ContextualChunkTransform) {
+		t.placement = placement
+	}
+}
+
+// WithContextMetadataKey sets the metadata key for the context.
+func WithContextMetadataKey(key string) ContextualChunkOption {
+	return func(t *ContextualChunkTransform) {
+		t.metadataKey = key
+	}
+}
+
+// WithContextSeparator sets the separator between the context and the
+// chunk text. Defaults to "\n\n".
+func WithContextSeparator(separator string) ContextualChunkOption {
+	return func(t *ContextualChunkTransform) {
+		t.separator = separator
+	}
+}
+
+// WithContextMaxDocumentRunes caps the document text sent with each chunk.
+// Longer documents are truncated. Defaults to 100,000 runes.
+func WithContextMaxDocumentRunes(n int) ContextualChunkOption {
+	return func(t *ContextualChunkTransform) {
+		t.maxDocumentRunes = n
+	}
+}
+
+// WithContextNumWorkers sets the number of concurrent LLM calls.
+func WithContextNumWorkers(n int) ContextualChunkOption {
+	return func(t *ContextualChunkTransform) {
+		t.numWorkers = n
+	}
+}
+
+// WithContextDocumentText sets how parent document text is resolved.
+func WithContextDocumentText(fn DocumentTextFunc) ContextualChunkOption {
+	return func(t *ContextualChunkTransform) {
+		t.documentText = fn
+	}
+}
+
+// WithContextExcludedFromLLM hides the context metadata from the LLM at
+// query time, so it only influences embeddings and retrieval.
+func WithContextExcludedFromLLM(exclude bool) ContextualChunkOption {
+	return func(t *ContextualChunkTransform) {
+		t.excludeFromLLM = exclude
+	}
+}
+
+// NewContextualChunkTransform creates a ContextualChunkTransform.
+func NewContextualChunkTransform(l llm.LLM, opts ...ContextualChunkOption) *ContextualChunkTransform {
+	t := &ContextualChunkTransform{
+		llm:              l,
+		promptTemplate:   DefaultContextualChunkPrompt,
+		placement:        ContextPlacementBoth,
+		metadataKey:      DefaultChunkContextMetadataKey,
+		separator:        "\n\n",
+		maxDocumentRunes: 100000,
+		numWorkers:       4,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// Name implements TransformComponent.
+func (t *ContextualChunkTransform) Name() string {
+	return "ContextualChunkTransform"
+}
+
+// Transform implements TransformComponent. Nodes that already carry
+// context under the metadata key are left unchanged, so re-running the
+// transform does not prepend context twice.
+func (t *ContextualChunkTransform) Transform(ctx context.Context, nodes []schema.Node) ([]schema.Node, error) {
+	if t.llm == nil {
+		return nil, fmt.Errorf("LLM must be provided for ContextualChunkTransform")
+	}
+
+	documents, err := t.resolveDocuments(ctx, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]schema.Node, len(nodes))
+	copy(result, nodes)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	workers := t.numWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	semaphore := make(chan struct{}, workers)
+
+	for i := range result {
+		node := &result[i]
+		if _, done := node.Metadata[t.metadataKey]; done {
+			continue
+		}
+		document, ok := documents[sourceID(*node)]
+		if !ok || strings.TrimSpace(node.Text) == "" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(node *schema.Node, document string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			chunkContext, err := t.generateContext(ctx, document, node.Text)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to contextualize node %s: %w", node.ID, err)
+				}
+				mu.Unlock()
+				return
+			}
+			t.apply(node, chunkContext)
+		}(node, document)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return result, nil
+}
+
+// generateContext asks the LLM for the context of one chunk.
+func (t *ContextualChunkTransform) generateContext(ctx context.Context, document, chunk string) (string, error) {
+	prompt := strings.NewReplacer(
+		"{document}", document,
+		"{chunk}", chunk,
+	).Replace(t.promptTemplate)
+
+	response, err := t.llm.Complete(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(response), nil
+}
+
+// apply stores the context on a node according to the placement. Maps and
+// slices are copied so the caller's nodes are not modified.
+func (t *ContextualChunkTransform) apply(node *schema.Node, chunkContext string) {
+	if chunkContext == "" {
+		return
+	}
+
+	if t.placement == ContextPlacementText || t.placement == ContextPlacementBoth {
+		node.Text = chunkContext + t.separator + node.Text
+	}
+
+	metadata := make(map[string]interface{}, len(node.Metadata)+1)
+	for k, v := range node.Metadata {
+		metadata[k] = v
+	}
+	node.Metadata = metadata
+
+	if t.placement == ContextPlacementText {
+		// Record the context so re-runs are idempotent, but keep it out of
+		// embeddings and prompts since it is already in the text.
+		node.Metadata[t.metadataKey] = chunkContext
+		node.ExcludedEmbedMetadataKeys = appendKey(node.ExcludedEmbedMetadataKeys, t.metadataKey)
+		node.ExcludedLLMMetadataKeys = appendKey(node.ExcludedLLMMetadataKeys, t.metadataKey)
+		return
+	}
+
+	node.Metadata[t.metadataKey] = chunkContext
+	if t.placement == ContextPlacementBoth {
+		// The context is already in the text; avoid embedding it twice.
+		node.ExcludedEmbedMetadataKeys = appendKey(node.ExcludedEmbedMetadataKeys, t.metadataKey)
+	}
+	if t.excludeFromLLM {
+		node.ExcludedLLMMetadataKeys = appendKey(node.ExcludedLLMMetadataKeys, t.metadataKey)
+	}
+}
+
+// resolveDocuments returns the text of every source document referenced by
+// the nodes, truncated to the configured maximum.
+func (t *ContextualChunkTransform) resolveDocuments(ctx context.Context, nodes []schema.Node) (map[string]string, error) {
+	documents := make(map[string]string)
+
+	if t.documentText != nil {
+		for _, node := range nodes {
+			id := sourceID(node)
+			if id == "" {
+				continue
+			}
+			if _, ok := documents[id]; ok {
+				continue
+			}
+			text, err := t.documentText(ctx, id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load document %s: %w", id, err)
+			}
+			documents[id] = textutil.Truncate(text, t.maxDocumentRunes)
+		}
+		return documents, nil
+	}
+
+	// Reconstruct documents from their chunks in batch order.
+	parts := make(map[string][]string)
+	var order []string
+	for _, node := range nodes {
+		id := sourceID(node)
+		if id == "" {
+			continue
+		}
+		if _, ok := parts[id]; !ok {
+			order = append(order, id)
+		}
+		parts[id] = append(parts[id], node.Text)
+	}
+	for _, id := range order {
+		documents[id] = textutil.Truncate(strings.Join(parts[id], "\n"), t.maxDocumentRunes)
+	}
+	return documents, nil
+}
+
+// sourceID returns the ID of a node's source document, or "" if it has none.
+func sourceID(node schema.Node) string {
+	if node.Relationships == nil {
+		return ""
+	}
+	if source := node.Relationships.GetSource(); source != nil {
+		return source.NodeID
+	}
+	return ""
+}
+
+// appendKey returns keys with key appended, copying the slice and skipping
+// duplicates.
+func appendKey(keys []string, key string) []string {
+	for _, k := range keys {
+		if k == key {
+			return keys
+		}
+	}
+	out := make([]string, len(keys), len(keys)+1)
+	copy(out, keys)
+	return append(out, key)
+}
+
+// Ensure ContextualChunkTransform implements TransformComponent.
+var _ TransformComponent = (*ContextualChunkTransform)(nil)
@@ -0,0 +1,316 @@
+package ingestion
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/aqua777/go-llamaindex/rag/store"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/storage/docstore"
+	"github.com/google/uuid"
+)
+
+// DocumentDiff describes how a document's chunks changed on re-ingestion.
+type DocumentDiff struct {
+	// RefDocID is the document ID.
+	RefDocID string
+	// Skipped is true when the document hash was unchanged and the
+	// document was not split at all.
+	Skipped bool
+	// Unchanged are the IDs of chunks kept from the previous version,
+	// with their embeddings.
+	Unchanged []string
+	// Added are the IDs of new or changed chunks.
+	Added []string
+	// Removed are the IDs of previous chunks no longer present.
+	Removed []string
+}
+
+// DiffIngestResult summarizes a DiffIngester run.
+type DiffIngestResult struct {
+	// Nodes are the current chunks of every changed document, in order.
+	Nodes []schema.Node
+	// Documents describes each input document.
+	Documents []DocumentDiff
+	// Transformed is the number of chunks passed through the
+	// transformations, e.g. embedded.
+	Transformed int
+}
+
+// DiffIngester re-ingests updated documents at chunk level. Each document
+// is split and its chunks are compared with the chunks of the previous
+// version in the docstore. Chunks whose text is unchanged keep their node
+// ID and stored embedding; only new or changed chunks run through the
+// transformations (typically embedding) and are written to the vector
+// store, and chunks that disappeared are deleted. For large, frequently
+// edited documents this avoids re-embedding the whole document.
+//
+// Chunks are matched by text, so unchanged chunks are not rewritten in the
+// vector store even if their position metadata shifted; the docstore always
+// holds the current version.
+type DiffIngester struct {
+	splitter        TransformComponent
+	transformations []TransformComponent
+	docStore        docstore.DocStore
+	vectorStore     store.VectorStore
+}
+
+// DiffIngesterOption configures a DiffIngester.
+type DiffIngesterOption func(*DiffIngester)
+
+// WithDiffTransformations sets the transformations run on new or changed
+// chunks, e.g. an embedding transformation.
+func WithDiffTransformations(transformations ...TransformComponent) DiffIngesterOption {
+	return func(d *DiffIngester) {
+		d.transformations = transformations
+	}
+}
+
+// WithDiffVectorStore sets the vector store kept in sync with the chunks.
+func WithDiffVectorStore(vectorStore store.VectorStore) DiffIngesterOption {
+	return func(d *DiffIngester) {
+		d.vectorStore = vectorStore
+	}
+}
+
+// NewDiffIngester creates a new DiffIngester. splitter turns a document
+// node into chunks whose source relationship points at the document.
+func NewDiffIngester(splitter TransformComponent, ds docstore.DocStore, opts ...DiffIngesterOption) *DiffIngester {
+	d := &DiffIngester{
+		splitter: splitter,
+		docStore: ds,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Ingest splits and diffs each document against its previous version,
+// transforms only the new or changed chunks, and updates the docstore and
+// vector store.
+func (d *DiffIngester) Ingest(ctx context.Context, documents []schema.Document) (*DiffIngestResult, error) {
+	result := &DiffIngestResult{}
+
+	type docChunks struct {
+		hash      string
+		chunks    []schema.Node
+		changed   []int
+		removed   []string
+		resultIdx int
+	}
+	var pending []*docChunks
+	var changedChunks []schema.Node
+
+	for _, doc := range documents {
+		docHash := doc.GetHash()
+		diff := DocumentDiff{RefDocID: doc.ID}
+
+		previousHash, err := d.docStore.GetDocumentHash(ctx, doc.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hash of document %s: %w", doc.ID, err)
+		}
+		info, err := d.docStore.GetRefDocInfo(ctx, doc.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chunks of document %s: %w", doc.ID, err)
+		}
+
+		if previousHash == docHash && info != nil {
+			diff.Skipped = true
+			diff.Unchanged = append(diff.Unchanged, info.NodeIDs...)
+			result.Documents = append(result.Documents, diff)
+			continue
+		}
+
+		chunks, err := d.split(ctx, doc)
+		if err != nil {
+			return nil, err
+		}
+
+		var previous []schema.BaseNode
+		if info != nil {
+			previous, err = docstore.GetNodes(ctx, d.docStore, info.NodeIDs, false)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load chunks of document %s: %w", doc.ID, err)
+			}
+		}
+
+		dc := &docChunks{hash: docHash, chunks: chunks, resultIdx: len(result.Documents)}
+		byText := make(map[string][]schema.BaseNode)
+		for _, node := range previous {
+			key := chunkKey(node.GetContent(schema.MetadataModeNone))
+			byText[key] = append(byText[key], node)
+		}
+
+		for i := range chunks {
+			key := chunkKey(chunks[i].Text)
+			if matches := byText[key]; len(matches) > 0 {
+				prev := matches[0]
+				byText[key] = matches[1:]
+				chunks[i].ID = prev.GetID()
+				chunks[i].Embedding = prev.GetEmbedding()
+				chunks[i].Hash = ""
+				diff.Unchanged = append(diff.Unchanged, chunks[i].ID)
+				continue
+			}
+			dc.changed = append(dc.changed, i)
+		}
+
+		// Splitters that derive IDs from positions may give a changed chunk
+		// the ID of a kept one, so such chunks get fresh IDs.
+		used := make(map[string]bool, len(chunks))
+		for _, id := range diff.Unchanged {
+			used[id] = true
+		}
+		for _, i := range dc.changed {
+			if chunks[i].ID == "" || used[chunks[i].ID] {
+				chunks[i].ID = uuid.New().String()
+			}
+			used[chunks[i].ID] = true
+			changedChunks = append(changedChunks, chunks[i])
+		}
+
+		for _, node := range previous {
+			if matches := byText[chunkKey(node.GetContent(schema.MetadataModeNone))]; containsNode(matches, node.GetID()) {
+				dc.removed = append(dc.removed, node.GetID())
+			}
+		}
+		diff.Removed = dc.removed
+
+		result.Documents = append(result.Documents, diff)
+		pending = append(pending, dc)
+	}
+
+	transformed, err := RunTransformations(ctx, changedChunks, d.transformations, nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to transform changed chunks: %w", err)
+	}
+	result.Transformed = len(changedChunks)
+
+	// Transformations may drop or add chunks, so map their output back to
+	// documents by source relationship and to chunks by ID.
+	transformedByDoc := make(map[string][]schema.Node)
+	for _, node := range transformed {
+		id := refDocID(node)
+		transformedByDoc[id] = append(transformedByDoc[id], node)
+	}
+
+	for _, dc := range pending {
+		diff := &result.Documents[dc.resultIdx]
+		changed := transformedByDoc[diff.RefDocID]
+
+		byID := make(map[string]schema.Node, len(changed))
+		for _, node := range changed {
+			byID[node.ID] = node
+		}
+		isChanged := make(map[int]bool, len(dc.changed))
+		for _, i := range dc.changed {
+			isChanged[i] = true
+		}
+
+		// Keep document order; nodes added by the transformations go last.
+		current := make([]schema.Node, 0, len(dc.chunks))
+		for i, chunk := range dc.chunks {
+			if !isChanged[i] {
+				current = append(current, chunk)
+				continue
+			}
+			if node, ok := byID[chunk.ID]; ok {
+				current = append(current, node)
+				diff.Added = append(diff.Added, node.ID)
+				delete(byID, chunk.ID)
+			}
+		}
+		for _, node := range changed {
+			if _, ok := byID[node.ID]; ok {
+				current = append(current, node)
+				diff.Added = append(diff.Added, node.ID)
+			}
+		}
+
+		if err := d.apply(ctx, diff.RefDocID, dc.hash, current, changed, dc.removed); err != nil {
+			return nil, err
+		}
+		result.Nodes = append(result.Nodes, current...)
+	}
+
+	return result, nil
+}
+
+// split turns a document into chunks that point at the document.
+func (d *DiffIngester) split(ctx context.Context, doc schema.Document) ([]schema.Node, error) {
+	docNode := schema.Node{
+		ID:       doc.ID,
+		Text:     doc.Text,
+		Type:     schema.ObjectTypeDocument,
+		Metadata: doc.Metadata,
+	}
+
+	chunks, err := d.splitter.Transform(ctx, []schema.Node{docNode})
+	if err != nil {
+		return nil, fmt.Errorf("failed to split document %s: %w", doc.ID, err)
+	}
+
+	for i := range chunks {
+		if chunks[i].Relationships.GetSource() == nil {
+			if chunks[i].Relationships == nil {
+				chunks[i].Relationships = make(schema.NodeRelationships)
+			}
+			chunks[i].Relationships.SetSource(schema.RelatedNodeInfo{
+				NodeID:   doc.ID,
+				NodeType: schema.ObjectTypeDocument,
+			})
+		}
+	}
+	return chunks, nil
+}
+
+// apply writes a document's new chunk set to the stores.
+func (d *DiffIngester) apply(ctx context.Context, docID, docHash string, current, changed []schema.Node, removed []string) error {
+	for _, id := range removed {
+		if err := d.docStore.DeleteDocument(ctx, id, false); err != nil {
+			return fmt.Errorf("failed to delete chunk %s: %w", id, err)
+		}
+		if d.vectorStore != nil {
+			if err := d.vectorStore.Delete(ctx, id); err != nil {
+				return fmt.Errorf("failed to delete chunk %s from vector store: %w", id, err)
+			}
+		}
+	}
+
+	if d.vectorStore != nil {
+		if withEmbeddings := filterNodesWithEmbeddings(changed); len(withEmbeddings) > 0 {
+			if _, err := d.vectorStore.Add(ctx, withEmbeddings); err != nil {
+				return fmt.Errorf("failed to add chunks of document %s to vector store: %w", docID, err)
+			}
+		}
+	}
+
+	nodes := make([]schema.BaseNode, len(current))
+	for i := range current {
+		nodes[i] = &current[i]
+	}
+	if err := d.docStore.AddDocuments(ctx, nodes, true); err != nil {
+		return fmt.Errorf("failed to store chunks of document %s: %w", docID, err)
+	}
+	return d.docStore.SetDocumentHash(ctx, docID, docHash)
+}
+
+// chunkKey identifies a chunk by its text.
+func chunkKey(text string) string {
+	hash := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(hash[:])
+}
+
+func containsNode(nodes []schema.BaseNode, id string) bool {
+	for _, node := range nodes {
+		if node.GetID() == id {
+			return true
+		}
+	}
+	return false
+}
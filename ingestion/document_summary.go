@@ -0,0 +1,217 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/storage/docstore"
+	"github.com/aqua777/go-llamaindex/textutil"
+)
+
+// DefaultDocumentSummaryPrompt asks the LLM for a document summary and
+// keywords. It uses the {document} and {max_keywords} placeholders.
+const DefaultDocumentSummaryPrompt = `<document>
+{document}
+</document>
+Summarize the document above in 2-3 sentences, then list up to {max_keywords} keywords that characterize it. Answer in exactly this format:
+SUMMARY: <summary>
+KEYWORDS: <comma separated keywords>`
+
+// DocumentSummarizer generates a summary and keyword set per reference
+// document and persists them in the docstore's reference document metadata
+// (see docstore.GetDocumentSummary). Use it as the last pipeline
+// transformation: it groups the batch's nodes by source document, passes
+// them through unchanged, and regenerates the summary whenever a document
+// is re-ingested.
+type DocumentSummarizer struct {
+	llm            llm.LLM
+	docStore       docstore.DocStore
+	promptTemplate string
+	maxKeywords    int
+	maxRunes       int
+	workers        int
+}
+
+// DocumentSummarizerOption configures a DocumentSummarizer.
+type DocumentSummarizerOption func(*DocumentSummarizer)
+
+// WithDocumentSummaryPrompt sets the prompt template. The LLM must answer
+// with SUMMARY: and KEYWORDS: lines.
+func WithDocumentSummaryPrompt(template string) DocumentSummarizerOption {
+	return func(s *DocumentSummarizer) {
+		s.promptTemplate = template
+	}
+}
+
+// WithDocumentSummaryMaxKeywords sets the maximum number of keywords kept.
+// Defaults to 10.
+func WithDocumentSummaryMaxKeywords(n int) DocumentSummarizerOption {
+	return func(s *DocumentSummarizer) {
+		s.maxKeywords = n
+	}
+}
+
+// WithDocumentSummaryMaxRunes truncates long documents before
+// summarizing. Defaults to 12000.
+func WithDocumentSummaryMaxRunes(n int) DocumentSummarizerOption {
+	return func(s *DocumentSummarizer) {
+		s.maxRunes = n
+	}
+}
+
+// WithDocumentSummaryWorkers sets how many documents are summarized
+// concurrently. Defaults to 4.
+func WithDocumentSummaryWorkers(n int) DocumentSummarizerOption {
+	return func(s *DocumentSummarizer) {
+		s.workers = n
+	}
+}
+
+// NewDocumentSummarizer creates a new DocumentSummarizer that stores its
+// results in ds.
+func NewDocumentSummarizer(l llm.LLM, ds docstore.DocStore, opts ...DocumentSummarizerOption) *DocumentSummarizer {
+	s := &DocumentSummarizer{
+		llm:            l,
+		docStore:       ds,
+		promptTemplate: DefaultDocumentSummaryPrompt,
+		maxKeywords:    10,
+		maxRunes:       12000,
+		workers:        4,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Name returns the transformation name.
+func (s *DocumentSummarizer) Name() string {
+	return "DocumentSummarizer"
+}
+
+// Transform summarizes the documents in nodes and returns nodes unchanged.
+func (s *DocumentSummarizer) Transform(ctx context.Context, nodes []schema.Node) ([]schema.Node, error) {
+	if _, err := s.Summarize(ctx, nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// Summarize summarizes each reference document in nodes, rebuilt from its
+// chunks in order, and stores the results.
+func (s *DocumentSummarizer) Summarize(ctx context.Context, nodes []schema.Node) ([]docstore.DocumentSummary, error) {
+	texts := make(map[string][]string)
+	var order []string
+	for _, node := range nodes {
+		doc := refDocID(node)
+		if _, ok := texts[doc]; !ok {
+			order = append(order, doc)
+		}
+		texts[doc] = append(texts[doc], node.Text)
+	}
+
+	workers := s.workers
+	if workers <= 0 {
+		workers = 1
+	}
+	summaries := make([]docstore.DocumentSummary, len(order))
+	sem := make(chan struct{}, workers)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for i, doc := range order {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, doc string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			summary, err := s.summarize(ctx, doc, strings.Join(texts[doc], "\n\n"))
+			if err == nil {
+				err = docstore.SetDocumentSummary(ctx, s.docStore, summary)
+			}
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			summaries[i] = summary
+		}(i, doc)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return summaries, nil
+}
+
+// summarize asks the LLM for one document's summary and keywords.
+func (s *DocumentSummarizer) summarize(ctx context.Context, refDocID, text string) (docstore.DocumentSummary, error) {
+	if s.maxRunes > 0 {
+		text = textutil.Truncate(text, s.maxRunes)
+	}
+	prompt := strings.ReplaceAll(s.promptTemplate, "{document}", text)
+	prompt = strings.ReplaceAll(prompt, "{max_keywords}", strconv.Itoa(s.maxKeywords))
+
+	output, err := s.llm.Complete(ctx, prompt)
+	if err != nil {
+		return docstore.DocumentSummary{}, fmt.Errorf("failed to summarize document %s: %w", refDocID, err)
+	}
+
+	summary, keywords := parseDocumentSummary(output)
+	if s.maxKeywords > 0 && len(keywords) > s.maxKeywords {
+		keywords = keywords[:s.maxKeywords]
+	}
+	return docstore.DocumentSummary{RefDocID: refDocID, Summary: summary, Keywords: keywords}, nil
+}
+
+// parseDocumentSummary reads the SUMMARY: and KEYWORDS: sections. Output
+// without a SUMMARY: marker is taken as the summary.
+func parseDocumentSummary(output string) (string, []string) {
+	var summaryLines []string
+	var keywordsLine string
+	section := "summary"
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		upper := strings.ToUpper(trimmed)
+		switch {
+		case strings.HasPrefix(upper, "SUMMARY:"):
+			section = "summary"
+			summaryLines = append(summaryLines, strings.TrimSpace(trimmed[len("SUMMARY:"):]))
+		case strings.HasPrefix(upper, "KEYWORDS:"):
+			section = "keywords"
+			keywordsLine += "," + trimmed[len("KEYWORDS:"):]
+		case section == "keywords":
+			keywordsLine += "," + trimmed
+		default:
+			summaryLines = append(summaryLines, trimmed)
+		}
+	}
+
+	var keywords []string
+	seen := make(map[string]bool)
+	for _, k := range strings.Split(keywordsLine, ",") {
+		k = strings.Trim(strings.TrimSpace(k), `"'.-*`)
+		if k != "" && !seen[strings.ToLower(k)] {
+			seen[strings.ToLower(k)] = true
+			keywords = append(keywords, k)
+		}
+	}
+	return strings.Join(strings.Fields(strings.Join(summaryLines, " ")), " "), keywords
+}
+
+// Ensure DocumentSummarizer implements TransformComponent.
+var _ TransformComponent = (*DocumentSummarizer)(nil)
@@ -0,0 +1,519 @@
+package ingestion
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/rag/store"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// NodeSource streams nodes to fn one at a time, so node sets that do not fit
+// in memory can be embedded. It stops and returns fn's error if fn fails.
+type NodeSource func(ctx context.Context, fn func(schema.Node) error) error
+
+// SliceNodeSource returns a NodeSource over nodes.
+func SliceNodeSource(nodes []schema.Node) NodeSource {
+	return func(ctx context.Context, fn func(schema.Node) error) error {
+		for _, node := range nodes {
+			if err := fn(node); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// EmbedFailure records a node that could not be embedded.
+type EmbedFailure struct {
+	// NodeID is the ID of the node.
+	NodeID string `json:"id"`
+	// Error is the last embedding error.
+	Error string `json:"error"`
+}
+
+// EmbedJobProgress reports the state of a running EmbedJob.
+type EmbedJobProgress struct {
+	// Seen is the number of nodes read from the source so far.
+	Seen int
+	// Embedded is the number of nodes embedded in this run.
+	Embedded int
+	// Skipped is the number of nodes done in earlier runs.
+	Skipped int
+	// Failed is the number of nodes that failed in this run.
+	Failed int
+}
+
+// EmbedJobReport summarizes an EmbedJob run.
+type EmbedJobReport struct {
+	// Model is the embedding model name stamped on the nodes.
+	Model string `json:"model"`
+	// Resumed is true when the run continued from an existing progress
+	// file.
+	Resumed bool `json:"resumed"`
+	// Total is the number of nodes read from the source.
+	Total int `json:"total"`
+	// Embedded is the number of nodes embedded in this run.
+	Embedded int `json:"embedded"`
+	// Skipped is the number of nodes done in earlier runs.
+	Skipped int `json:"skipped"`
+	// Failed is the number of nodes that failed in this run.
+	Failed int `json:"failed"`
+	// Failures lists the failed nodes.
+	Failures []EmbedFailure `json:"failures,omitempty"`
+	// Batches is the number of batches sent.
+	Batches int `json:"batches"`
+	// Retries is the number of retried batch requests.
+	Retries int `json:"retries"`
+	// Duration is how long the run took.
+	Duration time.Duration `json:"duration"`
+}
+
+// String returns a one-line summary of the report.
+func (r *EmbedJobReport) String() string {
+	rate := 0.0
+	if r.Duration > 0 {
+		rate = float64(r.Embedded) / r.Duration.Seconds()
+	}
+	return fmt.Sprintf("embedded %d of %d nodes (%d skipped, %d failed) in %d batches, %d retries, %s (%.1f nodes/s)",
+		r.Embedded, r.Total, r.Skipped, r.Failed, r.Batches, r.Retries, r.Duration.Round(time.Millisecond), rate)
+}
+
+// EmbedJob embeds a large node set in batches and records its progress in a
+// file, so a job that crashes or is cancelled resumes where it stopped
+// instead of re-embedding everything. It is meant for indexing tens of
+// millions of chunks:
+//
+//   - nodes are streamed from a NodeSource and embedded by concurrent
+//     workers;
+//   - embedded batches are handed to the sink (typically a vector store)
+//     before they are checkpointed, so every node is written at least once;
+//   - requests go through an embedding.RateLimiter, which should be shared
+//     by all jobs calling the same provider;
+//   - failed batches are retried with backoff and then embedded node by
+//     node, so one bad input fails only itself.
+//
+// The progress file is append-only JSON lines: a header naming the model,
+// then one record per batch with the IDs done and failed. Nodes that failed
+// in an earlier run are retried unless WithEmbedJobSkipFailed is set.
+type EmbedJob struct {
+	embedModel   embedding.EmbeddingModel
+	progressPath string
+	modelName    string
+	batchSize    int
+	concurrency  int
+	maxRetries   int
+	retryDelay   time.Duration
+	limiter      *embedding.RateLimiter
+	sink         func(ctx context.Context, nodes []schema.Node) error
+	skipFailed   bool
+	progress     func(EmbedJobProgress)
+}
+
+// EmbedJobOption configures an EmbedJob.
+type EmbedJobOption func(*EmbedJob)
+
+// WithEmbedJobModelName sets the model name stamped on embedded nodes and
+// checked against the progress file. Defaults to the model's reported name.
+func WithEmbedJobModelName(name string) EmbedJobOption {
+	return func(j *EmbedJob) {
+		j.modelName = name
+	}
+}
+
+// WithEmbedJobBatchSize sets the number of nodes per embedding request.
+// Defaults to 64.
+func WithEmbedJobBatchSize(size int) EmbedJobOption {
+	return func(j *EmbedJob) {
+		j.batchSize = size
+	}
+}
+
+// WithEmbedJobConcurrency sets the number of batches embedded at once.
+// Defaults to 4.
+func WithEmbedJobConcurrency(n int) EmbedJobOption {
+	return func(j *EmbedJob) {
+		j.concurrency = n
+	}
+}
+
+// WithEmbedJobRetries sets how often a failed batch is retried and the
+// initial backoff, which doubles on each attempt. Defaults to 3 and 1s.
+func WithEmbedJobRetries(maxRetries int, delay time.Duration) EmbedJobOption {
+	return func(j *EmbedJob) {
+		j.maxRetries = maxRetries
+		j.retryDelay = delay
+	}
+}
+
+// WithEmbedJobRateLimiter sets the provider's rate limiter.
+func WithEmbedJobRateLimiter(limiter *embedding.RateLimiter) EmbedJobOption {
+	return func(j *EmbedJob) {
+		j.limiter = limiter
+	}
+}
+
+// WithEmbedJobVectorStore adds every embedded batch to vectorStore.
+func WithEmbedJobVectorStore(vectorStore store.VectorStore) EmbedJobOption {
+	return WithEmbedJobSink(func(ctx context.Context, nodes []schema.Node) error {
+		_, err := vectorStore.Add(ctx, nodes)
+		return err
+	})
+}
+
+// WithEmbedJobSink sets the function that receives every embedded batch. A
+// sink error stops the job; the batch is not checkpointed and is embedded
+// again on resume.
+func WithEmbedJobSink(sink func(ctx context.Context, nodes []schema.Node) error) EmbedJobOption {
+	return func(j *EmbedJob) {
+		j.sink = sink
+	}
+}
+
+// WithEmbedJobSkipFailed skips nodes that failed in an earlier run instead
+// of retrying them.
+func WithEmbedJobSkipFailed(skip bool) EmbedJobOption {
+	return func(j *EmbedJob) {
+		j.skipFailed = skip
+	}
+}
+
+// WithEmbedJobProgress sets a callback invoked after each checkpoint. It is
+// never called concurrently.
+func WithEmbedJobProgress(fn func(EmbedJobProgress)) EmbedJobOption {
+	return func(j *EmbedJob) {
+		j.progress = fn
+	}
+}
+
+// NewEmbedJob creates a new EmbedJob that records its progress in
+// progressPath.
+func NewEmbedJob(embedModel embedding.EmbeddingModel, progressPath string, opts ...EmbedJobOption) *EmbedJob {
+	j := &EmbedJob{
+		embedModel:   embedModel,
+		progressPath: progressPath,
+		modelName:    embedding.ModelName(embedModel),
+		batchSize:    64,
+		concurrency:  4,
+		maxRetries:   3,
+		retryDelay:   time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	if j.batchSize < 1 {
+		j.batchSize = 1
+	}
+	if j.concurrency < 1 {
+		j.concurrency = 1
+	}
+
+	return j
+}
+
+// embedJobRecord is one line of the progress file.
+type embedJobRecord struct {
+	Model   string         `json:"model,omitempty"`
+	Started *time.Time     `json:"started,omitempty"`
+	Done    []string       `json:"done,omitempty"`
+	Failed  []EmbedFailure `json:"failed,omitempty"`
+}
+
+// embedJobState is the progress loaded from the progress file.
+type embedJobState struct {
+	model  string
+	done   map[string]struct{}
+	failed map[string]string
+}
+
+// Run embeds the nodes from source that are not done yet. It returns the
+// report so far together with any error that stopped the job.
+func (j *EmbedJob) Run(ctx context.Context, source NodeSource) (*EmbedJobReport, error) {
+	start := time.Now()
+
+	state, err := j.loadProgress()
+	if err != nil {
+		return nil, err
+	}
+	resumed := state != nil
+	if state != nil && state.model != j.modelName {
+		return nil, fmt.Errorf("progress file %s was written for model %q, not %q", j.progressPath, state.model, j.modelName)
+	}
+
+	file, err := os.OpenFile(j.progressPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open progress file: %w", err)
+	}
+	defer file.Close()
+
+	if state == nil {
+		state = &embedJobState{model: j.modelName, done: map[string]struct{}{}, failed: map[string]string{}}
+		now := time.Now().UTC()
+		if err := writeEmbedJobRecord(file, embedJobRecord{Model: j.modelName, Started: &now}); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &EmbedJobReport{Model: j.modelName, Resumed: resumed}
+	var mu sync.Mutex
+	var firstErr error
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		mu.Unlock()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	batches := make(chan []schema.Node)
+	var wg sync.WaitGroup
+	for w := 0; w < j.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				embedded, failures, retries := j.embedBatch(ctx, batch)
+				if err := ctx.Err(); err != nil {
+					fail(err)
+					continue
+				}
+
+				if len(embedded) > 0 && j.sink != nil {
+					if err := j.sink(ctx, embedded); err != nil {
+						fail(fmt.Errorf("failed to write embedded batch: %w", err))
+						cancel()
+						continue
+					}
+				}
+
+				record := embedJobRecord{Failed: failures}
+				for _, node := range embedded {
+					record.Done = append(record.Done, node.ID)
+				}
+
+				mu.Lock()
+				err := writeEmbedJobRecord(file, record)
+				if err == nil {
+					report.Batches++
+					report.Retries += retries
+					report.Embedded += len(embedded)
+					report.Failed += len(failures)
+					report.Failures = append(report.Failures, failures...)
+				}
+				if err == nil && j.progress != nil {
+					j.progress(EmbedJobProgress{Seen: report.Total, Embedded: report.Embedded, Skipped: report.Skipped, Failed: report.Failed})
+				}
+				mu.Unlock()
+
+				if err != nil {
+					fail(err)
+					cancel()
+				}
+			}
+		}()
+	}
+
+	var pending []schema.Node
+	sourceErr := source(ctx, func(node schema.Node) error {
+		mu.Lock()
+		report.Total++
+		_, done := state.done[node.ID]
+		_, failedBefore := state.failed[node.ID]
+		if done || (failedBefore && j.skipFailed) {
+			report.Skipped++
+			mu.Unlock()
+			return nil
+		}
+		mu.Unlock()
+
+		pending = append(pending, node)
+		if len(pending) < j.batchSize {
+			return nil
+		}
+		batch := pending
+		pending = nil
+		select {
+		case batches <- batch:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if sourceErr == nil && len(pending) > 0 {
+		select {
+		case batches <- pending:
+		case <-ctx.Done():
+			sourceErr = ctx.Err()
+		}
+	}
+	close(batches)
+	wg.Wait()
+
+	report.Duration = time.Since(start)
+	if firstErr != nil {
+		return report, firstErr
+	}
+	if sourceErr != nil {
+		return report, fmt.Errorf("failed to read nodes: %w", sourceErr)
+	}
+	return report, nil
+}
+
+// embedBatch embeds a batch with retries. If the batch keeps failing, its
+// nodes are embedded one at a time so only the bad ones fail.
+func (j *EmbedJob) embedBatch(ctx context.Context, batch []schema.Node) ([]schema.Node, []EmbedFailure, int) {
+	retries := 0
+	delay := j.retryDelay
+	var err error
+	for attempt := 0; attempt <= j.maxRetries; attempt++ {
+		if attempt > 0 {
+			retries++
+			if !sleepContext(ctx, delay) {
+				return nil, nil, retries
+			}
+			delay *= 2
+		}
+		if err = j.embed(ctx, batch); err == nil {
+			return batch, nil, retries
+		}
+		if ctx.Err() != nil {
+			return nil, nil, retries
+		}
+	}
+
+	if len(batch) == 1 {
+		return nil, []EmbedFailure{{NodeID: batch[0].ID, Error: err.Error()}}, retries
+	}
+
+	var embedded []schema.Node
+	var failures []EmbedFailure
+	for i := range batch {
+		single := batch[i : i+1]
+		if err := j.embed(ctx, single); err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, retries
+			}
+			failures = append(failures, EmbedFailure{NodeID: batch[i].ID, Error: err.Error()})
+			continue
+		}
+		embedded = append(embedded, single[0])
+	}
+	return embedded, failures, retries
+}
+
+// embed waits for the rate limiter and embeds nodes in place.
+func (j *EmbedJob) embed(ctx context.Context, nodes []schema.Node) error {
+	if j.limiter != nil {
+		texts := make([]string, len(nodes))
+		for i := range nodes {
+			texts[i] = nodes[i].GetContent(schema.MetadataModeEmbed)
+		}
+		if err := j.limiter.Wait(ctx, embedding.EstimateTokens(texts...)); err != nil {
+			return err
+		}
+	}
+	return embedNodes(ctx, j.embedModel, j.modelName, nodes)
+}
+
+// loadProgress reads the progress file. It returns nil if the file does not
+// exist yet. A truncated last line, left by a crash, is ignored.
+func (j *EmbedJob) loadProgress() (*embedJobState, error) {
+	file, err := os.Open(j.progressPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open progress file: %w", err)
+	}
+	defer file.Close()
+
+	state := &embedJobState{done: map[string]struct{}{}, failed: map[string]string{}}
+	reader := bufio.NewReader(file)
+	first := true
+	var valid int64
+	for {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return nil, fmt.Errorf("failed to read progress file: %w", readErr)
+		}
+
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			var record embedJobRecord
+			if err := json.Unmarshal([]byte(trimmed), &record); err != nil {
+				if readErr == nil {
+					return nil, fmt.Errorf("corrupt progress file %s: %w", j.progressPath, err)
+				}
+				// Cut off the truncated last line so new records start on
+				// a line of their own.
+				if err := os.Truncate(j.progressPath, valid); err != nil {
+					return nil, fmt.Errorf("failed to repair progress file: %w", err)
+				}
+				break
+			}
+			if first {
+				state.model = record.Model
+				first = false
+			}
+			for _, id := range record.Done {
+				state.done[id] = struct{}{}
+				delete(state.failed, id)
+			}
+			for _, failure := range record.Failed {
+				state.failed[failure.NodeID] = failure.Error
+			}
+		}
+
+		valid += int64(len(line))
+
+		if readErr != nil {
+			break
+		}
+	}
+
+	if first {
+		// An empty file, e.g. created just before a crash.
+		return nil, nil
+	}
+	return state, nil
+}
+
+// writeEmbedJobRecord appends a record to the progress file and syncs it.
+func writeEmbedJobRecord(file *os.File, record embedJobRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write progress file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync progress file: %w", err)
+	}
+	return nil
+}
+
+// sleepContext sleeps for d and reports false if ctx ended first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
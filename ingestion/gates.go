@@ -0,0 +1,629 @@
+package ingestion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/aqua777/go-llamaindex/postprocessor"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// QualityViolationsMetadataKey holds the gate violations on nodes that
+// failed a gate in dry-run mode, as a []string of "gate: reason".
+const QualityViolationsMetadataKey = "quality_violations"
+
+// GateViolation is a failed quality check.
+type GateViolation struct {
+	// Gate is the name of the gate that failed.
+	Gate string `json:"gate"`
+	// RefDocID is the document the failing node belongs to.
+	RefDocID string `json:"ref_doc_id"`
+	// NodeID is the failing node, empty for document-level checks.
+	NodeID string `json:"node_id,omitempty"`
+	// Reason explains the failure.
+	Reason string `json:"reason"`
+}
+
+// QualityGate is a lightweight check run on a batch of nodes during
+// ingestion. Gates see the whole batch so they can check document-level
+// properties such as the duplicate ratio.
+type QualityGate interface {
+	// Name returns the gate name.
+	Name() string
+	// Check returns the violations found in nodes.
+	Check(ctx context.Context, nodes []schema.Node) ([]GateViolation, error)
+}
+
+// refDocID returns the document a node belongs to: its source, or itself.
+func refDocID(node schema.Node) string {
+	if source := node.Relationships.GetSource(); source != nil && source.NodeID != "" {
+		return source.NodeID
+	}
+	return node.ID
+}
+
+// EmptyChunkGate flags nodes whose trimmed text is shorter than MinChars.
+type EmptyChunkGate struct {
+	// MinChars is the minimum number of non-space characters. Defaults to 1.
+	MinChars int
+}
+
+// Name returns the gate name.
+func (g *EmptyChunkGate) Name() string { return "empty_chunk" }
+
+// Check flags empty or near-empty nodes.
+func (g *EmptyChunkGate) Check(ctx context.Context, nodes []schema.Node) ([]GateViolation, error) {
+	minChars := g.MinChars
+	if minChars <= 0 {
+		minChars = 1
+	}
+
+	var violations []GateViolation
+	for _, node := range nodes {
+		if n := len([]rune(strings.TrimSpace(node.Text))); n < minChars {
+			violations = append(violations, GateViolation{
+				Gate:     g.Name(),
+				RefDocID: refDocID(node),
+				NodeID:   node.ID,
+				Reason:   fmt.Sprintf("chunk has %d characters, minimum is %d", n, minChars),
+			})
+		}
+	}
+	return violations, nil
+}
+
+// LanguageDetector returns the ISO 639-1 code of the language of text and
+// a confidence in [0, 1], or "" when it cannot tell.
+type LanguageDetector func(text string) (string, float64)
+
+// LanguageGate flags nodes whose detected language is not one of Allowed.
+// Nodes whose language cannot be detected confidently pass.
+type LanguageGate struct {
+	// Allowed are the accepted ISO 639-1 codes, e.g. "en".
+	Allowed []string
+	// MinConfidence is the detection confidence needed to flag a node.
+	// Defaults to 0.5.
+	MinConfidence float64
+	// Detector detects the language. Defaults to DetectLanguage.
+	Detector LanguageDetector
+}
+
+// Name returns the gate name.
+func (g *LanguageGate) Name() string { return "language" }
+
+// Check flags nodes in unexpected languages.
+func (g *LanguageGate) Check(ctx context.Context, nodes []schema.Node) ([]GateViolation, error) {
+	detect := g.Detector
+	if detect == nil {
+		detect = DetectLanguage
+	}
+	minConfidence := g.MinConfidence
+	if minConfidence <= 0 {
+		minConfidence = 0.5
+	}
+	allowed := make(map[string]bool, len(g.Allowed))
+	for _, lang := range g.Allowed {
+		allowed[strings.ToLower(lang)] = true
+	}
+
+	var violations []GateViolation
+	for _, node := range nodes {
+		lang, confidence := detect(node.Text)
+		if lang == "" || confidence < minConfidence || allowed[lang] {
+			continue
+		}
+		violations = append(violations, GateViolation{
+			Gate:     g.Name(),
+			RefDocID: refDocID(node),
+			NodeID:   node.ID,
+			Reason:   fmt.Sprintf("detected language %q (confidence %.2f), expected one of %v", lang, confidence, g.Allowed),
+		})
+	}
+	return violations, nil
+}
+
+// PIIGate flags nodes containing more personally identifiable information
+// than MaxMatches allows.
+type PIIGate struct {
+	// Types limits detection to these PII types. Empty means all.
+	Types []postprocessor.PIIType
+	// MaxMatches is the number of PII matches tolerated per node.
+	MaxMatches int
+
+	once     sync.Once
+	detector *postprocessor.PIIPostprocessor
+}
+
+// Name returns the gate name.
+func (g *PIIGate) Name() string { return "pii" }
+
+// Check flags nodes with PII.
+func (g *PIIGate) Check(ctx context.Context, nodes []schema.Node) ([]GateViolation, error) {
+	g.once.Do(func() {
+		g.detector = postprocessor.NewPIIPostprocessor(postprocessor.WithPIITypes(g.Types...))
+	})
+
+	var violations []GateViolation
+	for _, node := range nodes {
+		matches := g.detector.DetectPII(node.Text)
+		if len(matches) <= g.MaxMatches {
+			continue
+		}
+		counts := make(map[postprocessor.PIIType]int)
+		for _, m := range matches {
+			counts[m.Type]++
+		}
+		types := make([]string, 0, len(counts))
+		for t, n := range counts {
+			types = append(types, fmt.Sprintf("%s x%d", t, n))
+		}
+		sort.Strings(types)
+		violations = append(violations, GateViolation{
+			Gate:     g.Name(),
+			RefDocID: refDocID(node),
+			NodeID:   node.ID,
+			Reason:   "contains PII: " + strings.Join(types, ", "),
+		})
+	}
+	return violations, nil
+}
+
+// DuplicateRatioGate flags documents in which more than MaxRatio of the
+// chunks repeat a chunk seen earlier in the batch, a sign of boilerplate
+// or a re-uploaded document.
+type DuplicateRatioGate struct {
+	// MaxRatio is the tolerated fraction of duplicate chunks per document.
+	MaxRatio float64
+}
+
+// Name returns the gate name.
+func (g *DuplicateRatioGate) Name() string { return "duplicate_ratio" }
+
+// Check flags documents with too many duplicate chunks.
+func (g *DuplicateRatioGate) Check(ctx context.Context, nodes []schema.Node) ([]GateViolation, error) {
+	seen := make(map[string]bool)
+	total := make(map[string]int)
+	dups := make(map[string]int)
+	var order []string
+
+	for _, node := range nodes {
+		text := strings.ToLower(strings.Join(strings.Fields(node.Text), " "))
+		if text == "" {
+			continue
+		}
+		doc := refDocID(node)
+		if total[doc] == 0 {
+			order = append(order, doc)
+		}
+		total[doc]++
+		if seen[text] {
+			dups[doc]++
+		}
+		seen[text] = true
+	}
+
+	var violations []GateViolation
+	for _, doc := range order {
+		ratio := float64(dups[doc]) / float64(total[doc])
+		if ratio > g.MaxRatio {
+			violations = append(violations, GateViolation{
+				Gate:     g.Name(),
+				RefDocID: doc,
+				Reason:   fmt.Sprintf("%d of %d chunks are duplicates (%.0f%%), maximum is %.0f%%", dups[doc], total[doc], ratio*100, g.MaxRatio*100),
+			})
+		}
+	}
+	return violations, nil
+}
+
+// QuarantinedDocument is a document held back from indexing by a gate.
+type QuarantinedDocument struct {
+	// RefDocID identifies the document.
+	RefDocID string `json:"ref_doc_id"`
+	// Nodes are the document's nodes in the batch, unindexed.
+	Nodes []schema.Node `json:"nodes"`
+	// Violations are the failed checks.
+	Violations []GateViolation `json:"violations"`
+	// QuarantinedAt is when the document was quarantined.
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// ReviewQueue receives quarantined documents for human review.
+type ReviewQueue interface {
+	// Enqueue adds quarantined documents.
+	Enqueue(ctx context.Context, docs []QuarantinedDocument) error
+}
+
+// InMemoryReviewQueue is a ReviewQueue held in memory. Reviewed documents
+// are released with Release and can be passed back to the pipeline.
+type InMemoryReviewQueue struct {
+	mu    sync.Mutex
+	items map[string]QuarantinedDocument
+	order []string
+}
+
+// NewInMemoryReviewQueue creates a new InMemoryReviewQueue.
+func NewInMemoryReviewQueue() *InMemoryReviewQueue {
+	return &InMemoryReviewQueue{items: make(map[string]QuarantinedDocument)}
+}
+
+// Enqueue adds quarantined documents, replacing earlier entries for the
+// same document.
+func (q *InMemoryReviewQueue) Enqueue(ctx context.Context, docs []QuarantinedDocument) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, doc := range docs {
+		if _, ok := q.items[doc.RefDocID]; !ok {
+			q.order = append(q.order, doc.RefDocID)
+		}
+		q.items[doc.RefDocID] = doc
+	}
+	return nil
+}
+
+// Items returns the queued documents in the order they were first queued.
+func (q *InMemoryReviewQueue) Items() []QuarantinedDocument {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := make([]QuarantinedDocument, 0, len(q.order))
+	for _, id := range q.order {
+		items = append(items, q.items[id])
+	}
+	return items
+}
+
+// Len returns the number of queued documents.
+func (q *InMemoryReviewQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.order)
+}
+
+// Release removes a document from the queue and returns it, e.g. to
+// re-ingest its nodes after review.
+func (q *InMemoryReviewQueue) Release(refDocID string) (QuarantinedDocument, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	doc, ok := q.items[refDocID]
+	if !ok {
+		return QuarantinedDocument{}, false
+	}
+	delete(q.items, refDocID)
+	for i, id := range q.order {
+		if id == refDocID {
+			q.order = append(q.order[:i], q.order[i+1:]...)
+			break
+		}
+	}
+	return doc, true
+}
+
+// QualityGateTransform runs quality gates as a pipeline transformation.
+// Documents with any violation are removed from the batch, with all their
+// nodes, and sent to the review queue instead of being indexed. In dry-run
+// mode nothing is removed; failing nodes are annotated under
+// QualityViolationsMetadataKey.
+//
+// Place it after the node parser so gates see the final chunks. With the
+// pipeline cache enabled, a cached batch skips the gates and is not queued
+// again.
+type QualityGateTransform struct {
+	gates  []QualityGate
+	queue  ReviewQueue
+	dryRun bool
+	now    func() time.Time
+}
+
+// QualityGateOption configures a QualityGateTransform.
+type QualityGateOption func(*QualityGateTransform)
+
+// WithReviewQueue sets the queue receiving quarantined documents. Without
+// one, failing documents are dropped.
+func WithReviewQueue(queue ReviewQueue) QualityGateOption {
+	return func(t *QualityGateTransform) {
+		t.queue = queue
+	}
+}
+
+// WithGateDryRun annotates failing nodes instead of quarantining them.
+func WithGateDryRun(dryRun bool) QualityGateOption {
+	return func(t *QualityGateTransform) {
+		t.dryRun = dryRun
+	}
+}
+
+// NewQualityGateTransform creates a new QualityGateTransform.
+func NewQualityGateTransform(gates []QualityGate, opts ...QualityGateOption) *QualityGateTransform {
+	t := &QualityGateTransform{
+		gates: gates,
+		now:   time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// Name returns the transformation name.
+func (t *QualityGateTransform) Name() string {
+	return "QualityGateTransform"
+}
+
+// Transform runs the gates and quarantines failing documents.
+func (t *QualityGateTransform) Transform(ctx context.Context, nodes []schema.Node) ([]schema.Node, error) {
+	byDoc := make(map[string][]GateViolation)
+	byNode := make(map[string][]string)
+	for _, gate := range t.gates {
+		violations, err := gate.Check(ctx, nodes)
+		if err != nil {
+			return nil, fmt.Errorf("quality gate %s failed: %w", gate.Name(), err)
+		}
+		for _, v := range violations {
+			byDoc[v.RefDocID] = append(byDoc[v.RefDocID], v)
+			if v.NodeID != "" {
+				byNode[v.NodeID] = append(byNode[v.NodeID], v.Gate+": "+v.Reason)
+			}
+		}
+	}
+	if len(byDoc) == 0 {
+		return nodes, nil
+	}
+
+	if t.dryRun {
+		result := make([]schema.Node, len(nodes))
+		for i, node := range nodes {
+			reasons := byNode[node.ID]
+			if len(reasons) == 0 {
+				for _, v := range byDoc[refDocID(node)] {
+					if v.NodeID == "" {
+						reasons = append(reasons, v.Gate+": "+v.Reason)
+					}
+				}
+			}
+			if len(reasons) > 0 {
+				metadata := make(map[string]interface{}, len(node.Metadata)+1)
+				for k, v := range node.Metadata {
+					metadata[k] = v
+				}
+				metadata[QualityViolationsMetadataKey] = reasons
+				node.Metadata = metadata
+			}
+			result[i] = node
+		}
+		return result, nil
+	}
+
+	quarantined := make(map[string]*QuarantinedDocument)
+	var order []string
+	result := make([]schema.Node, 0, len(nodes))
+	for _, node := range nodes {
+		doc := refDocID(node)
+		violations, failed := byDoc[doc]
+		if !failed {
+			result = append(result, node)
+			continue
+		}
+		q, ok := quarantined[doc]
+		if !ok {
+			q = &QuarantinedDocument{RefDocID: doc, Violations: violations, QuarantinedAt: t.now()}
+			quarantined[doc] = q
+			order = append(order, doc)
+		}
+		q.Nodes = append(q.Nodes, node)
+	}
+
+	if t.queue != nil {
+		docs := make([]QuarantinedDocument, len(order))
+		for i, doc := range order {
+			docs[i] = *quarantined[doc]
+		}
+		if err := t.queue.Enqueue(ctx, docs); err != nil {
+			return nil, fmt.Errorf("failed to enqueue quarantined documents: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// QualityGateConfig configures quality gates declaratively, e.g. from a
+// JSON file. Gates left nil are disabled.
+type QualityGateConfig struct {
+	// EmptyChunk enables EmptyChunkGate.
+	EmptyChunk *EmptyChunkGateConfig `json:"empty_chunk,omitempty"`
+	// Language enables LanguageGate.
+	Language *LanguageGateConfig `json:"language,omitempty"`
+	// PII enables PIIGate.
+	PII *PIIGateConfig `json:"pii,omitempty"`
+	// DuplicateRatio enables DuplicateRatioGate.
+	DuplicateRatio *DuplicateRatioGateConfig `json:"duplicate_ratio,omitempty"`
+	// DryRun annotates failing nodes instead of quarantining them.
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// EmptyChunkGateConfig configures EmptyChunkGate.
+type EmptyChunkGateConfig struct {
+	MinChars int `json:"min_chars,omitempty"`
+}
+
+// LanguageGateConfig configures LanguageGate.
+type LanguageGateConfig struct {
+	Allowed       []string `json:"allowed"`
+	MinConfidence float64  `json:"min_confidence,omitempty"`
+}
+
+// PIIGateConfig configures PIIGate.
+type PIIGateConfig struct {
+	Types      []string `json:"types,omitempty"`
+	MaxMatches int      `json:"max_matches,omitempty"`
+}
+
+// DuplicateRatioGateConfig configures DuplicateRatioGate.
+type DuplicateRatioGateConfig struct {
+	MaxRatio float64 `json:"max_ratio"`
+}
+
+// LoadQualityGateConfig reads a JSON QualityGateConfig from path.
+func LoadQualityGateConfig(path string) (*QualityGateConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quality gate config: %w", err)
+	}
+	var cfg QualityGateConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse quality gate config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// Gates builds the configured gates.
+func (c *QualityGateConfig) Gates() ([]QualityGate, error) {
+	var gates []QualityGate
+	if c.EmptyChunk != nil {
+		gates = append(gates, &EmptyChunkGate{MinChars: c.EmptyChunk.MinChars})
+	}
+	if c.Language != nil {
+		if len(c.Language.Allowed) == 0 {
+			return nil, fmt.Errorf("language gate needs at least one allowed language")
+		}
+		gates = append(gates, &LanguageGate{Allowed: c.Language.Allowed, MinConfidence: c.Language.MinConfidence})
+	}
+	if c.PII != nil {
+		known := make(map[postprocessor.PIIType]bool)
+		for _, p := range postprocessor.DefaultPIIPatterns() {
+			known[p.Type] = true
+		}
+		types := make([]postprocessor.PIIType, len(c.PII.Types))
+		for i, name := range c.PII.Types {
+			types[i] = postprocessor.PIIType(name)
+			if !known[types[i]] {
+				return nil, fmt.Errorf("unknown PII type %q", name)
+			}
+		}
+		gates = append(gates, &PIIGate{Types: types, MaxMatches: c.PII.MaxMatches})
+	}
+	if c.DuplicateRatio != nil {
+		if c.DuplicateRatio.MaxRatio < 0 || c.DuplicateRatio.MaxRatio > 1 {
+			return nil, fmt.Errorf("duplicate ratio must be in [0, 1], got %v", c.DuplicateRatio.MaxRatio)
+		}
+		gates = append(gates, &DuplicateRatioGate{MaxRatio: c.DuplicateRatio.MaxRatio})
+	}
+	return gates, nil
+}
+
+// NewQualityGateTransformFromConfig builds a QualityGateTransform from
+// configuration.
+func NewQualityGateTransformFromConfig(cfg *QualityGateConfig, opts ...QualityGateOption) (*QualityGateTransform, error) {
+	gates, err := cfg.Gates()
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]QualityGateOption{WithGateDryRun(cfg.DryRun)}, opts...)
+	return NewQualityGateTransform(gates, opts...), nil
+}
+
+// languageStopwords are frequent function words used to tell Latin-script
+// languages apart.
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "of", "to", "in", "that", "it", "with", "for", "this", "was", "on", "be", "not", "you", "have"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "mit", "den", "von", "sich", "auf", "für", "auch", "dem", "wir"},
+	"fr": {"le", "la", "les", "et", "est", "des", "une", "un", "du", "que", "pas", "pour", "dans", "qui", "sur", "avec", "sont", "nous"},
+	"es": {"el", "la", "los", "las", "y", "es", "que", "de", "del", "una", "por", "para", "con", "no", "se", "su", "como", "está"},
+	"it": {"il", "lo", "gli", "che", "di", "è", "e", "non", "una", "per", "con", "sono", "della", "del", "questo", "anche", "nel", "alla"},
+	"pt": {"o", "os", "as", "que", "não", "uma", "um", "para", "com", "por", "do", "da", "dos", "em", "é", "são", "mais", "como"},
+	"nl": {"de", "het", "een", "en", "is", "van", "niet", "dat", "op", "te", "zijn", "met", "voor", "ook", "maar", "wij", "er", "aan"},
+}
+
+// languageScripts map Unicode scripts to the language they mostly indicate.
+var languageScripts = []struct {
+	lang  string
+	table *unicode.RangeTable
+}{
+	{"ja", unicode.Hiragana},
+	{"ja", unicode.Katakana},
+	{"ko", unicode.Hangul},
+	{"zh", unicode.Han},
+	{"ru", unicode.Cyrillic},
+	{"ar", unicode.Arabic},
+	{"el", unicode.Greek},
+	{"he", unicode.Hebrew},
+	{"hi", unicode.Devanagari},
+	{"th", unicode.Thai},
+}
+
+// DetectLanguage is a lightweight language detector. Non-Latin scripts are
+// identified by script; Latin-script text by function-word frequency among
+// English, German, French, Spanish, Italian, Portuguese and Dutch. It
+// returns "" for text too short to judge.
+func DetectLanguage(text string) (string, float64) {
+	letters := 0
+	scriptCounts := make(map[string]int)
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, s := range languageScripts {
+			if unicode.Is(s.table, r) {
+				scriptCounts[s.lang]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return "", 0
+	}
+
+	// Japanese mixes kana with Han characters.
+	if scriptCounts["ja"] > 0 {
+		scriptCounts["ja"] += scriptCounts["zh"]
+		scriptCounts["zh"] = 0
+	}
+	bestScript, bestScriptCount := "", 0
+	for lang, n := range scriptCounts {
+		if n > bestScriptCount || (n == bestScriptCount && lang < bestScript) {
+			bestScript, bestScriptCount = lang, n
+		}
+	}
+	if float64(bestScriptCount) > float64(letters)/2 {
+		return bestScript, float64(bestScriptCount) / float64(letters)
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	counts := make(map[string]int)
+	total := 0
+	for _, w := range words {
+		for lang, stopwords := range languageStopwords {
+			for _, sw := range stopwords {
+				if w == sw {
+					counts[lang]++
+					total++
+					break
+				}
+			}
+		}
+	}
+	if total < 3 {
+		return "", 0
+	}
+
+	best, bestCount := "", 0
+	for lang, n := range counts {
+		if n > bestCount || (n == bestCount && lang < best) {
+			best, bestCount = lang, n
+		}
+	}
+	return best, float64(bestCount) / float64(total)
+}
+
+// Ensure QualityGateTransform implements TransformComponent.
+var _ TransformComponent = (*QualityGateTransform)(nil)
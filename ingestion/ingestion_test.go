@@ -2,10 +2,25 @@ package ingestion
 
 import (
 	"context"
+	"errors"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
-
+	"time"
+
+	"github.com/aqua777/go-llamaindex/callbacks"
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/graphstore"
+	"github.com/aqua777/go-llamaindex/limits"
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/nodeparser"
+	"github.com/aqua777/go-llamaindex/postprocessor"
+	"github.com/aqua777/go-llamaindex/rag/store"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/storage/docstore"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -95,6 +110,15 @@ func (m *MockVectorStore) Delete(ctx context.Context, refDocID string) error {
 	return nil
 }
 
+// refDocRecorder records RefDocsChanged notifications.
+type refDocRecorder struct {
+	changed [][]string
+}
+
+func (r *refDocRecorder) RefDocsChanged(ctx context.Context, refDocIDs []string) {
+	r.changed = append(r.changed, refDocIDs)
+}
+
 // TestIngestionCache tests the IngestionCache.
 func TestIngestionCache(t *testing.T) {
 	t.Run("NewIngestionCache", func(t *testing.T) {
@@ -387,6 +411,66 @@ func TestDocstoreStrategy(t *testing.T) {
 		require.NoError(t, err)
 		assert.Len(t, nodes, 0) // Should be deduplicated
 	})
+
+	t.Run("Upserts and delete strategy", func(t *testing.T) {
+		docstore := NewMockDocStore()
+		vectorStore := NewMockVectorStore()
+
+		pipeline := NewIngestionPipeline(
+			WithDocstore(docstore),
+			WithVectorStore(vectorStore),
+			WithDocstoreStrategy(DocstoreStrategyUpsertsAndDelete),
+			WithDisableCache(true),
+		)
+
+		_, err := pipeline.Run(ctx, []schema.Document{
+			{ID: "doc1", Text: "Hello"},
+			{ID: "doc2", Text: "World"},
+		}, nil)
+		require.NoError(t, err)
+
+		_, err = pipeline.Run(ctx, []schema.Document{{ID: "doc1", Text: "Hello"}}, nil)
+		require.NoError(t, err)
+
+		_, ok := docstore.GetDocumentHash("doc2")
+		assert.False(t, ok)
+		_, ok = docstore.GetDocumentHash("doc1")
+		assert.True(t, ok)
+	})
+
+	t.Run("Ref doc listener", func(t *testing.T) {
+		docstore := NewMockDocStore()
+		listener := &refDocRecorder{}
+
+		pipeline := NewIngestionPipeline(
+			WithDocstore(docstore),
+			WithDocstoreStrategy(DocstoreStrategyUpsertsAndDelete),
+			WithRefDocListener(listener),
+			WithDisableCache(true),
+		)
+
+		// New documents are not changes
+		_, err := pipeline.Run(ctx, []schema.Document{
+			{ID: "doc1", Text: "Hello"},
+			{ID: "doc2", Text: "World"},
+		}, nil)
+		require.NoError(t, err)
+		assert.Empty(t, listener.changed)
+
+		// Unchanged documents are not changes
+		_, err = pipeline.Run(ctx, []schema.Document{
+			{ID: "doc1", Text: "Hello"},
+			{ID: "doc2", Text: "World"},
+		}, nil)
+		require.NoError(t, err)
+		assert.Empty(t, listener.changed)
+
+		// doc1 updated, doc2 deleted
+		_, err = pipeline.Run(ctx, []schema.Document{{ID: "doc1", Text: "Hello Updated"}}, nil)
+		require.NoError(t, err)
+		require.Len(t, listener.changed, 1)
+		assert.ElementsMatch(t, []string{"doc1", "doc2"}, listener.changed[0])
+	})
 }
 
 // TestRunTransformations tests the standalone RunTransformations function.
@@ -432,6 +516,29 @@ func TestRunTransformations(t *testing.T) {
 	})
 }
 
+func TestRunTransformationsCallbacks(t *testing.T) {
+	collector := callbacks.NewEventCollectorHandler()
+	manager := callbacks.NewCallbackManager(callbacks.WithHandlers([]callbacks.CallbackHandler{collector}))
+	ctx := callbacks.ContextWithManager(context.Background(), manager)
+
+	split := &MockTransform{
+		name: "split",
+		transform: func(nodes []schema.Node) []schema.Node {
+			return append(nodes, schema.Node{ID: "2", Text: "More"})
+		},
+	}
+	pipeline := NewIngestionPipeline(WithTransformations([]TransformComponent{split}), WithDisableCache(true))
+	_, err := pipeline.Run(ctx, nil, []schema.Node{{ID: "1", Text: "Test"}})
+	require.NoError(t, err)
+
+	starts := collector.GetEventsByType(callbacks.CBEventTypeTransform)
+	require.Len(t, starts, 1)
+	assert.Equal(t, "split", starts[0].Payload[string(callbacks.EventPayloadTransform)])
+	ends := collector.EndEvents()
+	require.Len(t, ends, 1)
+	assert.Len(t, ends[0].Payload[string(callbacks.EventPayloadNodes)], 2)
+}
+
 // TestGetTransformationHash tests the hash generation.
 func TestGetTransformationHash(t *testing.T) {
 	t.Run("Same input produces same hash", func(t *testing.T) {
@@ -466,3 +573,638 @@ func TestGetTransformationHash(t *testing.T) {
 		assert.NotEqual(t, hash1, hash2)
 	})
 }
+
+func TestReembed(t *testing.T) {
+	ctx := context.Background()
+	vs := store.NewSimpleVectorStore()
+
+	var nodes []schema.Node
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		node := schema.NewTextNode("text " + id)
+		node.ID = id
+		node.Embedding = []float64{1, 0}
+		nodes = append(nodes, *node)
+	}
+	nodes[0].SetEmbeddingModel("new-model")
+	nodes[1].SetEmbeddingModel("old-model")
+	_, err := vs.Add(ctx, nodes)
+	require.NoError(t, err)
+
+	model := embedding.NewMockEmbeddingModel([]float64{0, 1})
+	model.ModelInfo = &embedding.EmbeddingInfo{ModelName: "new-model"}
+
+	var progress []ReembedProgress
+	result, err := Reembed(ctx, vs, model,
+		WithReembedBatchSize(2),
+		WithReembedProgress(func(p ReembedProgress) { progress = append(progress, p) }),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, &ReembedResult{Scanned: 5, Stale: 4, Reembedded: 4}, result)
+	assert.Equal(t, []ReembedProgress{{Done: 2, Total: 4}, {Done: 4, Total: 4}}, progress)
+
+	stored, err := vs.ListNodes(ctx)
+	require.NoError(t, err)
+	for _, node := range stored {
+		assert.Equal(t, "new-model", node.GetEmbeddingModel(), node.ID)
+		assert.NotContains(t, node.GetContent(schema.MetadataModeEmbed), "new-model")
+	}
+	assert.Equal(t, []float64{1, 0}, stored[0].Embedding)
+	assert.Equal(t, []float64{0, 1}, stored[1].Embedding)
+
+	// A second run finds nothing to do.
+	result, err = Reembed(ctx, vs, model)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Stale)
+}
+
+func TestReembedRequiresModelName(t *testing.T) {
+	_, err := Reembed(context.Background(), store.NewSimpleVectorStore(), embedding.NewMockEmbeddingModel([]float64{1}), WithReembedModelName(""))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "model name")
+}
+
+func TestIngestionCacheStats(t *testing.T) {
+	cache := NewIngestionCache()
+	cache.Put("k1", []schema.Node{{ID: "n1", Text: "a"}}, "")
+
+	_, ok := cache.Get("k1", "")
+	assert.True(t, ok)
+	_, ok = cache.Get("missing", "")
+	assert.False(t, ok)
+	_, ok = cache.Get("k1", "")
+	assert.True(t, ok)
+
+	stats := cache.Stats()
+	assert.Equal(t, int64(2), stats.Hits)
+	assert.Equal(t, int64(1), stats.Misses)
+	assert.Equal(t, 1, stats.Entries)
+	assert.InDelta(t, 2.0/3.0, stats.HitRate(), 1e-9)
+	assert.Equal(t, 0.0, CacheStats{}.HitRate())
+}
+
+func TestIngestionPipelineLastRun(t *testing.T) {
+	pipeline := NewIngestionPipeline(WithDisableCache(true))
+
+	_, ok := pipeline.LastRun()
+	assert.False(t, ok)
+
+	_, err := pipeline.Run(context.Background(), []schema.Document{{ID: "doc1", Text: "Hello"}}, nil)
+	require.NoError(t, err)
+
+	run, ok := pipeline.LastRun()
+	require.True(t, ok)
+	assert.Equal(t, 1, run.InputCount)
+	assert.Equal(t, 1, run.OutputCount)
+	assert.Empty(t, run.Error)
+	assert.False(t, run.FinishedAt.Before(run.StartedAt))
+}
+
+func TestIngestionPipelineLimits(t *testing.T) {
+	ctx := context.Background()
+	l := limits.Limits{
+		Document:         limits.Limit{MaxBytes: 100, Strategy: limits.StrategyError},
+		NodeText:         limits.Limit{MaxBytes: 8, Strategy: limits.StrategyTruncate},
+		TruncationMarker: "~",
+	}
+
+	t.Run("rejects oversized documents", func(t *testing.T) {
+		pipeline := NewIngestionPipeline(WithDisableCache(true), WithPipelineLimits(l))
+		_, err := pipeline.Run(ctx, []schema.Document{{ID: "big", Text: strings.Repeat("x", 101)}}, nil)
+		assert.ErrorIs(t, err, limits.ErrLimitExceeded)
+		assert.ErrorContains(t, err, "document big")
+	})
+
+	t.Run("truncates transformation output", func(t *testing.T) {
+		pipeline := NewIngestionPipeline(
+			WithDisableCache(true),
+			WithPipelineLimits(l),
+			WithTransformations([]TransformComponent{&MockTransform{name: "identity"}}),
+		)
+		docs := []schema.Document{{ID: "doc", Text: "a long document text"}}
+		nodes, err := pipeline.Run(ctx, docs, nil)
+		require.NoError(t, err)
+		require.Len(t, nodes, 1)
+		assert.Equal(t, "a long ~", nodes[0].Text)
+		assert.Equal(t, "a long document text", docs[0].Text, "inputs must not be modified")
+	})
+}
+
+// recordingLLM records prompts and answers with a fixed response.
+type recordingLLM struct {
+	*llm.MockLLM
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (r *recordingLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	r.mu.Lock()
+	r.prompts = append(r.prompts, prompt)
+	r.mu.Unlock()
+	return r.MockLLM.Complete(ctx, prompt)
+}
+
+func chunkOf(docID, id, text string) schema.Node {
+	node := schema.Node{ID: id, Text: text, Relationships: make(schema.NodeRelationships)}
+	node.Relationships.SetSource(schema.RelatedNodeInfo{NodeID: docID})
+	return node
+}
+
+func TestContextualChunkTransform(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("prepends context and records metadata", func(t *testing.T) {
+		l := &recordingLLM{MockLLM: llm.NewMockLLM("  From ACME's Q2 2023 report.  ")}
+		transform := NewContextualChunkTransform(l)
+
+		nodes := []schema.Node{
+			chunkOf("doc-1", "a", "ACME Q2 2023 results."),
+			chunkOf("doc-1", "b", "Revenue grew 3%."),
+			{ID: "orphan", Text: "No source."},
+		}
+		result, err := transform.Transform(ctx, nodes)
+		require.NoError(t, err)
+		require.Len(t, result, 3)
+
+		assert.Equal(t, "From ACME's Q2 2023 report.\n\nRevenue grew 3%.", result[1].Text)
+		assert.Equal(t, "From ACME's Q2 2023 report.", result[1].Metadata[DefaultChunkContextMetadataKey])
+		assert.Contains(t, result[1].ExcludedEmbedMetadataKeys, DefaultChunkContextMetadataKey)
+		assert.Equal(t, "No source.", result[2].Text, "nodes without a source are skipped")
+		assert.Equal(t, "Revenue grew 3%.", nodes[1].Text, "inputs must not be modified")
+		assert.Nil(t, nodes[1].Metadata)
+
+		require.Len(t, l.prompts, 2)
+		for _, prompt := range l.prompts {
+			assert.Contains(t, prompt, "ACME Q2 2023 results.\nRevenue grew 3%.")
+		}
+
+		// Re-running does not prepend the context twice.
+		again, err := transform.Transform(ctx, result)
+		require.NoError(t, err)
+		assert.Equal(t, result[1].Text, again[1].Text)
+		assert.Len(t, l.prompts, 2)
+	})
+
+	t.Run("metadata placement with document lookup", func(t *testing.T) {
+		l := &recordingLLM{MockLLM: llm.NewMockLLM("Section on pricing.")}
+		transform := NewContextualChunkTransform(l,
+			WithContextPlacement(ContextPlacementMetadata),
+			WithContextMetadataKey("situating_context"),
+			WithContextDocumentText(func(ctx context.Context, docID string) (string, error) {
+				return "Full text of " + docID, nil
+			}),
+		)
+
+		result, err := transform.Transform(ctx, []schema.Node{chunkOf("doc-2", "c", "It costs $5.")})
+		require.NoError(t, err)
+
+		assert.Equal(t, "It costs $5.", result[0].Text)
+		assert.Equal(t, "Section on pricing.", result[0].Metadata["situating_context"])
+		assert.Empty(t, result[0].ExcludedEmbedMetadataKeys)
+		require.Len(t, l.prompts, 1)
+		assert.Contains(t, l.prompts[0], "Full text of doc-2")
+	})
+
+	t.Run("llm errors fail the transform", func(t *testing.T) {
+		transform := NewContextualChunkTransform(llm.NewMockLLMWithError(assert.AnError))
+		_, err := transform.Transform(ctx, []schema.Node{chunkOf("doc", "d", "text")})
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		lang string
+	}{
+		{"The service is available in all regions and it is free for the first year.", "en"},
+		{"Der Dienst ist in allen Regionen verfügbar und für das erste Jahr nicht kostenpflichtig.", "de"},
+		{"Le service est disponible dans toutes les régions et il est gratuit pour la première année.", "fr"},
+		{"El servicio está disponible en todas las regiones y es gratuito para el primer año.", "es"},
+		{"Сервис доступен во всех регионах.", "ru"},
+		{"このサービスはすべての地域で利用できます。", "ja"},
+		{"Invoice 42", ""},
+	}
+	for _, tt := range tests {
+		lang, _ := DetectLanguage(tt.text)
+		assert.Equal(t, tt.lang, lang, tt.text)
+	}
+}
+
+func TestQualityGates(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("empty chunk", func(t *testing.T) {
+		gate := &EmptyChunkGate{MinChars: 5}
+		violations, err := gate.Check(ctx, []schema.Node{
+			chunkOf("doc1", "n1", "  \n "),
+			chunkOf("doc1", "n2", "long enough"),
+			chunkOf("doc2", "n3", "abc"),
+		})
+		require.NoError(t, err)
+		require.Len(t, violations, 2)
+		assert.Equal(t, "n1", violations[0].NodeID)
+		assert.Equal(t, "doc2", violations[1].RefDocID)
+	})
+
+	t.Run("language", func(t *testing.T) {
+		gate := &LanguageGate{Allowed: []string{"en"}}
+		violations, err := gate.Check(ctx, []schema.Node{
+			chunkOf("doc1", "n1", "The report is ready and it covers the second quarter."),
+			chunkOf("doc2", "n2", "Der Bericht ist fertig und er deckt das zweite Quartal ab."),
+			chunkOf("doc3", "n3", "Q2 2024"),
+		})
+		require.NoError(t, err)
+		require.Len(t, violations, 1)
+		assert.Equal(t, "n2", violations[0].NodeID)
+		assert.Contains(t, violations[0].Reason, `"de"`)
+	})
+
+	t.Run("pii", func(t *testing.T) {
+		gate := &PIIGate{}
+		violations, err := gate.Check(ctx, []schema.Node{
+			chunkOf("doc1", "n1", "Contact jane@example.com or bob@example.com"),
+			chunkOf("doc2", "n2", "No personal data here"),
+		})
+		require.NoError(t, err)
+		require.Len(t, violations, 1)
+		assert.Equal(t, "contains PII: email x2", violations[0].Reason)
+
+		tolerant := &PIIGate{MaxMatches: 2}
+		violations, err = tolerant.Check(ctx, []schema.Node{chunkOf("doc1", "n1", "jane@example.com, bob@example.com")})
+		require.NoError(t, err)
+		assert.Empty(t, violations)
+	})
+
+	t.Run("duplicate ratio", func(t *testing.T) {
+		gate := &DuplicateRatioGate{MaxRatio: 0.5}
+		violations, err := gate.Check(ctx, []schema.Node{
+			chunkOf("docA", "a1", "Confidential. Do not distribute."),
+			chunkOf("docA", "a2", "Quarterly revenue grew."),
+			chunkOf("docB", "b1", "Confidential.   do not distribute."),
+			chunkOf("docB", "b2", "Quarterly revenue grew."),
+			chunkOf("docB", "b3", "Costs fell."),
+		})
+		require.NoError(t, err)
+		require.Len(t, violations, 1)
+		assert.Equal(t, "docB", violations[0].RefDocID)
+		assert.Empty(t, violations[0].NodeID)
+	})
+
+	t.Run("pipeline quarantines documents", func(t *testing.T) {
+		queue := NewInMemoryReviewQueue()
+		gates := NewQualityGateTransform([]QualityGate{&EmptyChunkGate{}, &PIIGate{}}, WithReviewQueue(queue))
+		vectorStore := NewMockVectorStore()
+		pipeline := NewIngestionPipeline(
+			WithTransformations([]TransformComponent{gates}),
+			WithVectorStore(vectorStore),
+			WithDisableCache(true),
+		)
+
+		nodes := []schema.Node{
+			chunkOf("docA", "a1", "Pricing starts at $10."),
+			chunkOf("docB", "b1", "Call 555-123-4567 for help."),
+			chunkOf("docB", "b2", "Support hours are 9 to 5."),
+			chunkOf("docC", "c1", " "),
+		}
+		result, err := pipeline.Run(ctx, nil, nodes)
+		require.NoError(t, err)
+		require.Len(t, result, 1)
+		assert.Equal(t, "a1", result[0].ID)
+
+		items := queue.Items()
+		require.Len(t, items, 2)
+		assert.Equal(t, "docB", items[0].RefDocID)
+		assert.Len(t, items[0].Nodes, 2)
+		assert.Equal(t, "pii", items[0].Violations[0].Gate)
+		assert.Equal(t, "docC", items[1].RefDocID)
+
+		doc, ok := queue.Release("docB")
+		require.True(t, ok)
+		assert.Len(t, doc.Nodes, 2)
+		assert.Equal(t, 1, queue.Len())
+	})
+
+	t.Run("dry run annotates", func(t *testing.T) {
+		queue := NewInMemoryReviewQueue()
+		gates := NewQualityGateTransform([]QualityGate{&EmptyChunkGate{MinChars: 3}, &DuplicateRatioGate{MaxRatio: 0}},
+			WithReviewQueue(queue), WithGateDryRun(true))
+
+		result, err := gates.Transform(ctx, []schema.Node{
+			chunkOf("docA", "a1", "ok text"),
+			chunkOf("docA", "a2", "ok text"),
+			chunkOf("docB", "b1", "x"),
+		})
+		require.NoError(t, err)
+		require.Len(t, result, 3)
+		assert.Equal(t, []string{"duplicate_ratio: 1 of 2 chunks are duplicates (50%), maximum is 0%"}, result[0].Metadata[QualityViolationsMetadataKey])
+		assert.Equal(t, []string{"empty_chunk: chunk has 1 characters, minimum is 3"}, result[2].Metadata[QualityViolationsMetadataKey])
+		assert.Equal(t, 0, queue.Len())
+	})
+
+	t.Run("config", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "gates.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{
+			"empty_chunk": {"min_chars": 10},
+			"language": {"allowed": ["en"]},
+			"pii": {"types": ["email"]},
+			"duplicate_ratio": {"max_ratio": 0.3},
+			"dry_run": true
+		}`), 0o644))
+
+		cfg, err := LoadQualityGateConfig(path)
+		require.NoError(t, err)
+		transform, err := NewQualityGateTransformFromConfig(cfg)
+		require.NoError(t, err)
+		require.Len(t, transform.gates, 4)
+		assert.True(t, transform.dryRun)
+		assert.Equal(t, []postprocessor.PIIType{postprocessor.PIITypeEmail}, transform.gates[2].(*PIIGate).Types)
+
+		_, err = NewQualityGateTransformFromConfig(&QualityGateConfig{PII: &PIIGateConfig{Types: []string{"dna"}}})
+		assert.Error(t, err)
+		_, err = NewQualityGateTransformFromConfig(&QualityGateConfig{Language: &LanguageGateConfig{}})
+		assert.Error(t, err)
+		_, err = NewQualityGateTransformFromConfig(&QualityGateConfig{DuplicateRatio: &DuplicateRatioGateConfig{MaxRatio: 2}})
+		assert.Error(t, err)
+	})
+}
+
+// promptLLM answers each prompt with a function of it.
+type promptLLM struct {
+	*llm.MockLLM
+	respond func(prompt string) string
+}
+
+func (p *promptLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	return p.respond(prompt), nil
+}
+
+func TestDocumentSummarizer(t *testing.T) {
+	ctx := context.Background()
+
+	l := &promptLLM{MockLLM: llm.NewMockLLM(""), respond: func(prompt string) string {
+		if strings.Contains(prompt, "pricing") {
+			return "SUMMARY: Acme pricing.\nIt covers plans.\nKEYWORDS: pricing, plans, Pricing, discounts"
+		}
+		return "A short FAQ."
+	}}
+	ds := docstore.NewSimpleDocumentStore()
+	summarizer := NewDocumentSummarizer(l, ds, WithDocumentSummaryMaxKeywords(2))
+
+	pipeline := NewIngestionPipeline(
+		WithTransformations([]TransformComponent{summarizer}),
+		WithDisableCache(true),
+	)
+	nodes := []schema.Node{
+		chunkOf("doc-pricing", "p1", "Our pricing starts at $10."),
+		chunkOf("doc-pricing", "p2", "Annual plans get discounts."),
+		chunkOf("doc-faq", "f1", "How do I reset my password?"),
+	}
+	result, err := pipeline.Run(ctx, nil, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, nodes, result)
+
+	summary, err := docstore.GetDocumentSummary(ctx, ds, "doc-pricing")
+	require.NoError(t, err)
+	require.NotNil(t, summary)
+	assert.Equal(t, "Acme pricing. It covers plans.", summary.Summary)
+	assert.Equal(t, []string{"pricing", "plans"}, summary.Keywords)
+
+	summaries, err := docstore.ListDocumentSummaries(ctx, ds)
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+	assert.Equal(t, "doc-faq", summaries[0].RefDocID)
+	assert.Equal(t, "A short FAQ.", summaries[0].Summary)
+	assert.Empty(t, summaries[0].Keywords)
+}
+
+func TestDiffIngester(t *testing.T) {
+	ctx := context.Background()
+
+	// Paragraph splitter with positional IDs.
+	splitter := &MockTransform{name: "paragraphs", transform: func(nodes []schema.Node) []schema.Node {
+		var chunks []schema.Node
+		for _, node := range nodes {
+			for i, para := range strings.Split(node.Text, "\n\n") {
+				chunks = append(chunks, chunkOf(node.ID, node.ID+"-"+strconv.Itoa(i), para))
+			}
+		}
+		return chunks
+	}}
+	var embedded []string
+	embedder := &MockTransform{name: "embed", transform: func(nodes []schema.Node) []schema.Node {
+		for i := range nodes {
+			embedded = append(embedded, nodes[i].Text)
+			nodes[i].Embedding = []float64{float64(len(nodes[i].Text)), 1}
+		}
+		return nodes
+	}}
+
+	ds := docstore.NewSimpleDocumentStore()
+	vs := store.NewSimpleVectorStore()
+	ingester := NewDiffIngester(splitter, ds, WithDiffTransformations(embedder), WithDiffVectorStore(vs))
+
+	doc := schema.Document{ID: "doc", Text: "alpha\n\nbeta\n\ngamma"}
+	result, err := ingester.Ingest(ctx, []schema.Document{doc})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"doc-0", "doc-1", "doc-2"}, result.Documents[0].Added)
+	assert.Equal(t, 3, result.Transformed)
+
+	// Insert a paragraph and drop another: only the new one is embedded.
+	embedded = nil
+	doc.Text = "intro\n\nalpha\n\ngamma"
+	result, err = ingester.Ingest(ctx, []schema.Document{doc})
+	require.NoError(t, err)
+	diff := result.Documents[0]
+	assert.Equal(t, []string{"intro"}, embedded)
+	assert.Equal(t, []string{"doc-0", "doc-2"}, diff.Unchanged)
+	assert.Equal(t, []string{"doc-1"}, diff.Removed)
+	require.Len(t, diff.Added, 1)
+	assert.NotContains(t, []string{"doc-0", "doc-2"}, diff.Added[0])
+
+	require.Len(t, result.Nodes, 3)
+	assert.Equal(t, "intro", result.Nodes[0].Text)
+	assert.Equal(t, "doc-0", result.Nodes[1].ID)
+	assert.Equal(t, []float64{5, 1}, result.Nodes[1].Embedding)
+
+	info, err := ds.GetRefDocInfo(ctx, "doc")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{diff.Added[0], "doc-0", "doc-2"}, info.NodeIDs)
+
+	stored, err := vs.ListNodes(ctx)
+	require.NoError(t, err)
+	var texts []string
+	for _, node := range stored {
+		texts = append(texts, node.Text)
+	}
+	assert.ElementsMatch(t, []string{"intro", "alpha", "gamma"}, texts)
+
+	// An unchanged document is skipped.
+	embedded = nil
+	result, err = ingester.Ingest(ctx, []schema.Document{doc})
+	require.NoError(t, err)
+	assert.True(t, result.Documents[0].Skipped)
+	assert.Empty(t, embedded)
+	assert.Empty(t, result.Nodes)
+}
+
+// pickyEmbedding fails to embed any batch containing "bad".
+type pickyEmbedding struct {
+	*embedding.MockEmbeddingModel
+	mu       sync.Mutex
+	requests int
+}
+
+func (p *pickyEmbedding) GetTextEmbeddingsBatch(ctx context.Context, texts []string, callback embedding.ProgressCallback) ([][]float64, error) {
+	p.mu.Lock()
+	p.requests++
+	p.mu.Unlock()
+	for _, text := range texts {
+		if strings.Contains(text, "bad") {
+			return nil, errors.New("invalid input")
+		}
+	}
+	return p.MockEmbeddingModel.GetTextEmbeddingsBatch(ctx, texts, nil)
+}
+
+func TestEmbedJob(t *testing.T) {
+	ctx := context.Background()
+	var nodes []schema.Node
+	for i := 0; i < 10; i++ {
+		node := schema.NewTextNode("chunk " + strconv.Itoa(i))
+		node.ID = "n" + strconv.Itoa(i)
+		nodes = append(nodes, *node)
+	}
+	nodes[7].Text = "bad chunk"
+	progressPath := filepath.Join(t.TempDir(), "progress.jsonl")
+
+	model := &pickyEmbedding{MockEmbeddingModel: embedding.NewMockEmbeddingModel([]float64{1, 0})}
+	vs := store.NewSimpleVectorStore()
+
+	// The first run crashes when writing the third batch.
+	var written []string
+	var mu sync.Mutex
+	crashing := NewEmbedJob(model, progressPath,
+		WithEmbedJobBatchSize(3),
+		WithEmbedJobConcurrency(1),
+		WithEmbedJobRetries(1, time.Millisecond),
+		WithEmbedJobSink(func(ctx context.Context, batch []schema.Node) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if len(written) >= 6 {
+				return errors.New("disk full")
+			}
+			for _, n := range batch {
+				written = append(written, n.ID)
+			}
+			return nil
+		}),
+	)
+	report, err := crashing.Run(ctx, SliceNodeSource(nodes))
+	require.ErrorContains(t, err, "disk full")
+	assert.Equal(t, 6, report.Embedded)
+	assert.False(t, report.Resumed)
+
+	// A crash can leave half a line behind.
+	f, err := os.OpenFile(progressPath, os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"done":["n6","n`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	var progress []EmbedJobProgress
+	job := NewEmbedJob(model, progressPath,
+		WithEmbedJobBatchSize(3),
+		WithEmbedJobRetries(1, time.Millisecond),
+		WithEmbedJobVectorStore(vs),
+		WithEmbedJobProgress(func(p EmbedJobProgress) { progress = append(progress, p) }),
+	)
+	report, err = job.Run(ctx, SliceNodeSource(nodes))
+	require.NoError(t, err)
+	assert.True(t, report.Resumed)
+	assert.Equal(t, 10, report.Total)
+	assert.Equal(t, 6, report.Skipped)
+	assert.Equal(t, 3, report.Embedded)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, []EmbedFailure{{NodeID: "n7", Error: "invalid input"}}, report.Failures)
+	assert.Equal(t, 1, report.Retries)
+	assert.Contains(t, report.String(), "embedded 3 of 10 nodes (6 skipped, 1 failed)")
+	assert.NotEmpty(t, progress)
+
+	stored, err := vs.ListNodes(ctx)
+	require.NoError(t, err)
+	require.Len(t, stored, 3)
+	assert.Equal(t, "mock-embedding-model", stored[0].GetEmbeddingModel())
+
+	// Only the failed node is attempted again, unless failures are skipped.
+	model.requests = 0
+	report, err = job.Run(ctx, SliceNodeSource(nodes))
+	require.NoError(t, err)
+	assert.Equal(t, 9, report.Skipped)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, 2, model.requests)
+
+	skipping := NewEmbedJob(model, progressPath, WithEmbedJobSkipFailed(true))
+	report, err = skipping.Run(ctx, SliceNodeSource(nodes))
+	require.NoError(t, err)
+	assert.Equal(t, 10, report.Skipped)
+
+	other := NewEmbedJob(model, progressPath, WithEmbedJobModelName("other-model"))
+	_, err = other.Run(ctx, SliceNodeSource(nodes))
+	assert.ErrorContains(t, err, "was written for model")
+}
+
+func TestNodeParserTransform(t *testing.T) {
+	transform := NewNodeParserTransform(nodeparser.NewSentenceNodeParserWithConfig(4, 0))
+	assert.Equal(t, "NodeParserTransform", transform.Name())
+
+	doc := schema.NewTextNode("First sentence here. Second sentence here. Third sentence here.")
+	doc.ID = "doc-1"
+	nodes, err := transform.Transform(context.Background(), []schema.Node{*doc})
+	require.NoError(t, err)
+	require.Greater(t, len(nodes), 1)
+	for _, n := range nodes {
+		require.NotNil(t, n.Relationships.GetSource())
+		assert.Equal(t, "doc-1", n.Relationships.GetSource().NodeID)
+	}
+}
+
+func TestTripletExtractor(t *testing.T) {
+	ctx := context.Background()
+
+	l := &recordingLLM{MockLLM: llm.NewMockLLM("(alice, works at, ACME)\n(ACME, based in, Berlin)")}
+	transform := NewTripletExtractor(l, WithMaxTripletsPerChunk(5))
+	assert.Equal(t, "TripletExtractor", transform.Name())
+
+	done := schema.Node{ID: "done", Text: "Already processed.", Metadata: map[string]interface{}{
+		graphstore.TripletsMetadataKey: []graphstore.Triplet{},
+	}}
+	nodes := []schema.Node{
+		{ID: "a", Text: "Alice works at ACME in Berlin.", Metadata: map[string]interface{}{"page": 1}},
+		{ID: "empty", Text: "  "},
+		done,
+	}
+	result, err := transform.Transform(ctx, nodes)
+	require.NoError(t, err)
+	require.Len(t, result, 3)
+	assert.Len(t, l.prompts, 1)
+	assert.Contains(t, l.prompts[0], "Alice works at ACME in Berlin.")
+	assert.Contains(t, l.prompts[0], "up to 5 knowledge triplets")
+
+	triplets, ok := graphstore.TripletsFromMetadata(result[0].Metadata)
+	require.True(t, ok)
+	assert.Equal(t, []graphstore.Triplet{
+		{Subject: "Alice", Relation: "works at", Object: "ACME"},
+		{Subject: "ACME", Relation: "based in", Object: "Berlin"},
+	}, triplets)
+	assert.Equal(t, 1, result[0].Metadata["page"])
+	assert.NotContains(t, nodes[0].Metadata, graphstore.TripletsMetadataKey, "inputs must not be modified")
+	assert.NotContains(t, result[0].GetContent(schema.MetadataModeEmbed), "kg_triplets")
+	assert.NotContains(t, result[0].GetContent(schema.MetadataModeLLM), "kg_triplets")
+
+	_, ok = graphstore.TripletsFromMetadata(result[1].Metadata)
+	assert.False(t, ok, "nodes without text are skipped")
+
+	_, err = NewTripletExtractor(llm.NewMockLLMWithError(assert.AnError)).Transform(ctx, nodes[:1])
+	assert.ErrorIs(t, err, assert.AnError)
+}
@@ -0,0 +1,46 @@
+package ingestion
+
+import (
+	"context"
+
+	"github.com/aqua777/go-llamaindex/nodeparser"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// NodeParserTransform runs a node parser, such as a
+// nodeparser.SentenceNodeParser, as a pipeline transformation. Each input
+// node is split into chunks whose source relationship points at it.
+type NodeParserTransform struct {
+	parser nodeparser.NodeParser
+}
+
+// NewNodeParserTransform creates a transformation that splits nodes with
+// parser.
+func NewNodeParserTransform(parser nodeparser.NodeParser) *NodeParserTransform {
+	return &NodeParserTransform{parser: parser}
+}
+
+// Name returns the name of the transformation.
+func (t *NodeParserTransform) Name() string {
+	return "NodeParserTransform"
+}
+
+// Transform splits the nodes into chunks.
+func (t *NodeParserTransform) Transform(ctx context.Context, nodes []schema.Node) ([]schema.Node, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	inputs := make([]*schema.Node, len(nodes))
+	for i := range nodes {
+		inputs[i] = &nodes[i]
+	}
+	chunks := t.parser.ParseNodes(inputs)
+	result := make([]schema.Node, len(chunks))
+	for i, chunk := range chunks {
+		result[i] = *chunk
+	}
+	return result, nil
+}
+
+// Ensure NodeParserTransform implements TransformComponent.
+var _ TransformComponent = (*NodeParserTransform)(nil)
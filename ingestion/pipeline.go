@@ -5,7 +5,11 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/aqua777/go-llamaindex/callbacks"
+	"github.com/aqua777/go-llamaindex/limits"
 	"github.com/aqua777/go-llamaindex/schema"
 )
 
@@ -45,6 +49,13 @@ type DocStoreInterface interface {
 	DeleteRefDoc(refDocID string) error
 }
 
+// RefDocListener is notified when the pipeline replaces or deletes
+// reference documents, so that state derived from them (such as cached
+// answers) can be invalidated.
+type RefDocListener interface {
+	RefDocsChanged(ctx context.Context, refDocIDs []string)
+}
+
 // IngestionPipeline is a document processing pipeline.
 type IngestionPipeline struct {
 	name             string
@@ -54,6 +65,25 @@ type IngestionPipeline struct {
 	docstore         DocStoreInterface
 	vectorStore      VectorStoreInterface
 	docstoreStrategy DocstoreStrategy
+	limits           *limits.Limits
+	refDocListeners  []RefDocListener
+
+	mu      sync.Mutex
+	lastRun *PipelineRunStats
+}
+
+// PipelineRunStats describes a pipeline run.
+type PipelineRunStats struct {
+	// StartedAt is when the run started.
+	StartedAt time.Time `json:"started_at"`
+	// FinishedAt is when the run finished.
+	FinishedAt time.Time `json:"finished_at"`
+	// InputCount is the number of documents and nodes passed in.
+	InputCount int `json:"input_count"`
+	// OutputCount is the number of nodes produced.
+	OutputCount int `json:"output_count"`
+	// Error is the run error, if it failed.
+	Error string `json:"error,omitempty"`
 }
 
 // IngestionPipelineOption configures an IngestionPipeline.
@@ -66,7 +96,10 @@ func WithPipelineName(name string) IngestionPipelineOption {
 	}
 }
 
-// WithTransformations sets the transformations.
+// WithTransformations sets the transformations. Transformations that call
+// an LLM, such as extractors and the contextual chunker, share provider
+// limits with the rest of the application when given an LLM wrapped with
+// llm.NewLimitedLLM.
 func WithTransformations(transformations []TransformComponent) IngestionPipelineOption {
 	return func(p *IngestionPipeline) {
 		p.transformations = transformations
@@ -108,6 +141,23 @@ func WithDocstoreStrategy(strategy DocstoreStrategy) IngestionPipelineOption {
 	}
 }
 
+// WithPipelineLimits enforces payload limits: the document limit on input
+// documents, and the node text limit on input nodes and on the output of
+// every transformation.
+func WithPipelineLimits(l limits.Limits) IngestionPipelineOption {
+	return func(p *IngestionPipeline) {
+		p.limits = &l
+	}
+}
+
+// WithRefDocListener registers a listener notified with the IDs of
+// reference documents that a run updated or deleted in the docstore.
+func WithRefDocListener(listener RefDocListener) IngestionPipelineOption {
+	return func(p *IngestionPipeline) {
+		p.refDocListeners = append(p.refDocListeners, listener)
+	}
+}
+
 // NewIngestionPipeline creates a new IngestionPipeline.
 func NewIngestionPipeline(opts ...IngestionPipelineOption) *IngestionPipeline {
 	p := &IngestionPipeline{
@@ -139,17 +189,64 @@ func getTransformationHash(nodes []schema.Node, transform TransformComponent) st
 
 // Run runs the ingestion pipeline on the given documents/nodes.
 func (p *IngestionPipeline) Run(ctx context.Context, documents []schema.Document, nodes []schema.Node) ([]schema.Node, error) {
+	stats := PipelineRunStats{
+		StartedAt:  time.Now(),
+		InputCount: len(documents) + len(nodes),
+	}
+
+	result, err := p.run(ctx, documents, nodes)
+
+	stats.FinishedAt = time.Now()
+	stats.OutputCount = len(result)
+	if err != nil {
+		stats.Error = err.Error()
+	}
+	p.mu.Lock()
+	p.lastRun = &stats
+	p.mu.Unlock()
+
+	return result, err
+}
+
+// LastRun returns the stats of the most recent run, if any.
+func (p *IngestionPipeline) LastRun() (PipelineRunStats, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.lastRun == nil {
+		return PipelineRunStats{}, false
+	}
+	return *p.lastRun, true
+}
+
+func (p *IngestionPipeline) run(ctx context.Context, documents []schema.Document, nodes []schema.Node) ([]schema.Node, error) {
+	// Enforce payload limits on inputs
+	if p.limits != nil {
+		var err error
+		if documents, err = p.limitDocuments(documents); err != nil {
+			return nil, err
+		}
+		if nodes, err = p.limitNodes(nodes); err != nil {
+			return nil, err
+		}
+	}
+
 	// Prepare input nodes
 	inputNodes := p.prepareInputs(documents, nodes)
 
 	// Handle deduplication if docstore is set
 	nodesToRun := inputNodes
 	if p.docstore != nil {
+		var changed []string
 		var err error
-		nodesToRun, err = p.handleDeduplication(inputNodes)
+		nodesToRun, changed, err = p.handleDeduplication(inputNodes)
 		if err != nil {
 			return nil, err
 		}
+		if len(changed) > 0 {
+			for _, listener := range p.refDocListeners {
+				listener.RefDocsChanged(ctx, changed)
+			}
+		}
 	}
 
 	// Run transformations
@@ -198,14 +295,17 @@ func (p *IngestionPipeline) prepareInputs(documents []schema.Document, nodes []s
 }
 
 // handleDeduplication handles document deduplication based on strategy.
-func (p *IngestionPipeline) handleDeduplication(nodes []schema.Node) ([]schema.Node, error) {
+// It also returns the IDs of reference documents that were updated or
+// deleted.
+func (p *IngestionPipeline) handleDeduplication(nodes []schema.Node) ([]schema.Node, []string, error) {
 	switch p.docstoreStrategy {
 	case DocstoreStrategyUpserts, DocstoreStrategyUpsertsAndDelete:
 		return p.handleUpserts(nodes)
 	case DocstoreStrategyDuplicatesOnly:
-		return p.handleDuplicates(nodes)
+		nodesToRun, err := p.handleDuplicates(nodes)
+		return nodesToRun, nil, err
 	default:
-		return nil, fmt.Errorf("invalid docstore strategy: %s", p.docstoreStrategy)
+		return nil, nil, fmt.Errorf("invalid docstore strategy: %s", p.docstoreStrategy)
 	}
 }
 
@@ -233,10 +333,12 @@ func (p *IngestionPipeline) handleDuplicates(nodes []schema.Node) ([]schema.Node
 	return nodesToRun, nil
 }
 
-// handleUpserts handles upserts by checking hashes and IDs.
-func (p *IngestionPipeline) handleUpserts(nodes []schema.Node) ([]schema.Node, error) {
+// handleUpserts handles upserts by checking hashes and IDs. It returns the
+// nodes to run and the IDs of reference documents updated or deleted.
+func (p *IngestionPipeline) handleUpserts(nodes []schema.Node) ([]schema.Node, []string, error) {
 	docIDsFromNodes := make(map[string]bool)
 	dedupedNodesToRun := make(map[string]schema.Node)
+	var changed []string
 
 	for _, node := range nodes {
 		refDocID := node.ID
@@ -256,6 +358,9 @@ func (p *IngestionPipeline) handleUpserts(nodes []schema.Node) ([]schema.Node, e
 			if p.vectorStore != nil {
 				p.vectorStore.Delete(context.Background(), refDocID)
 			}
+			if _, seen := dedupedNodesToRun[refDocID]; !seen {
+				changed = append(changed, refDocID)
+			}
 			dedupedNodesToRun[refDocID] = node
 		}
 		// Otherwise, document exists and is unchanged, skip it
@@ -264,8 +369,9 @@ func (p *IngestionPipeline) handleUpserts(nodes []schema.Node) ([]schema.Node, e
 	// Handle delete strategy
 	if p.docstoreStrategy == DocstoreStrategyUpsertsAndDelete {
 		existingHashes := p.docstore.GetAllDocumentHashes()
-		for _, docID := range existingHashes {
+		for docID := range existingHashes {
 			if !docIDsFromNodes[docID] {
+				changed = append(changed, docID)
 				p.docstore.DeleteDocument(docID)
 				if p.vectorStore != nil {
 					p.vectorStore.Delete(context.Background(), docID)
@@ -280,7 +386,7 @@ func (p *IngestionPipeline) handleUpserts(nodes []schema.Node) ([]schema.Node, e
 		result = append(result, node)
 	}
 
-	return result, nil
+	return result, changed, nil
 }
 
 // runTransformations runs all transformations on the nodes.
@@ -297,7 +403,7 @@ func (p *IngestionPipeline) runTransformations(ctx context.Context, nodes []sche
 			}
 
 			// Run transformation
-			transformedNodes, err := transform.Transform(ctx, currentNodes)
+			transformedNodes, err := runTransform(ctx, transform, currentNodes)
 			if err != nil {
 				return nil, fmt.Errorf("transformation %s failed: %w", transform.Name(), err)
 			}
@@ -307,17 +413,52 @@ func (p *IngestionPipeline) runTransformations(ctx context.Context, nodes []sche
 			currentNodes = transformedNodes
 		} else {
 			// Run transformation without cache
-			transformedNodes, err := transform.Transform(ctx, currentNodes)
+			transformedNodes, err := runTransform(ctx, transform, currentNodes)
 			if err != nil {
 				return nil, fmt.Errorf("transformation %s failed: %w", transform.Name(), err)
 			}
 			currentNodes = transformedNodes
 		}
+
+		if p.limits != nil {
+			limited, err := p.limitNodes(currentNodes)
+			if err != nil {
+				return nil, fmt.Errorf("transformation %s output: %w", transform.Name(), err)
+			}
+			currentNodes = limited
+		}
 	}
 
 	return currentNodes, nil
 }
 
+// limitDocuments applies the document limit to copies of the documents.
+func (p *IngestionPipeline) limitDocuments(documents []schema.Document) ([]schema.Document, error) {
+	limited := make([]schema.Document, len(documents))
+	for i, doc := range documents {
+		if err := p.limits.ApplyDocument(&doc); err != nil {
+			return nil, fmt.Errorf("document %s: %w", doc.ID, err)
+		}
+		limited[i] = doc
+	}
+	return limited, nil
+}
+
+// limitNodes applies the node text limit to copies of the nodes.
+func (p *IngestionPipeline) limitNodes(nodes []schema.Node) ([]schema.Node, error) {
+	if nodes == nil {
+		return nil, nil
+	}
+	limited := make([]schema.Node, len(nodes))
+	for i, node := range nodes {
+		if err := p.limits.ApplyNode(&node); err != nil {
+			return nil, fmt.Errorf("node %s: %w", node.ID, err)
+		}
+		limited[i] = node
+	}
+	return limited, nil
+}
+
 // updateDocstore updates the document store with processed nodes.
 func (p *IngestionPipeline) updateDocstore(nodes []schema.Node) error {
 	// Set document hashes
@@ -360,6 +501,21 @@ func (p *IngestionPipeline) Cache() *IngestionCache {
 	return p.cache
 }
 
+// runTransform runs transform within a transform event on the callback
+// manager in ctx. Cached results are not reported.
+func runTransform(ctx context.Context, transform TransformComponent, nodes []schema.Node) ([]schema.Node, error) {
+	ctx, span := callbacks.StartSpan(ctx, callbacks.CBEventTypeTransform, map[string]interface{}{
+		string(callbacks.EventPayloadTransform): transform.Name(),
+		string(callbacks.EventPayloadNodes):     nodes,
+	})
+	transformed, err := transform.Transform(ctx, nodes)
+	span.End(map[string]interface{}{
+		string(callbacks.EventPayloadTransform): transform.Name(),
+		string(callbacks.EventPayloadNodes):     transformed,
+	}, err)
+	return transformed, err
+}
+
 // RunTransformations is a standalone function to run transformations.
 func RunTransformations(
 	ctx context.Context,
@@ -378,7 +534,7 @@ func RunTransformations(
 				continue
 			}
 
-			transformedNodes, err := transform.Transform(ctx, currentNodes)
+			transformedNodes, err := runTransform(ctx, transform, currentNodes)
 			if err != nil {
 				return nil, err
 			}
@@ -386,7 +542,7 @@ func RunTransformations(
 			cache.Put(hash, transformedNodes, cacheCollection)
 			currentNodes = transformedNodes
 		} else {
-			transformedNodes, err := transform.Transform(ctx, currentNodes)
+			transformedNodes, err := runTransform(ctx, transform, currentNodes)
 			if err != nil {
 				return nil, err
 			}
@@ -0,0 +1,199 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/rag/store"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// ReembedProgress reports the state of a re-embedding migration.
+type ReembedProgress struct {
+	// Done is the number of stale nodes re-embedded so far.
+	Done int
+	// Total is the number of stale nodes found.
+	Total int
+}
+
+// ReembedResult summarizes a re-embedding migration.
+type ReembedResult struct {
+	// Scanned is the number of nodes inspected.
+	Scanned int
+	// Stale is the number of nodes not embedded with the target model.
+	Stale int
+	// Reembedded is the number of nodes re-embedded and written back.
+	Reembedded int
+}
+
+// Reembedder migrates stored nodes to a new embedding model. Nodes whose
+// embedding model stamp (schema.EmbeddingModelKey) differs from the target
+// are re-embedded in batches and written back to the store.
+//
+// Each batch is written before the next one starts, and re-embedded nodes
+// carry the new stamp, so an interrupted migration resumes where it stopped
+// when run again.
+type Reembedder struct {
+	store      store.VectorStore
+	embedModel embedding.EmbeddingModel
+	modelName  string
+	batchSize  int
+	progress   func(ReembedProgress)
+}
+
+// ReembedderOption configures a Reembedder.
+type ReembedderOption func(*Reembedder)
+
+// WithReembedModelName sets the model name stamped on re-embedded nodes.
+// Defaults to the model's reported name.
+func WithReembedModelName(name string) ReembedderOption {
+	return func(r *Reembedder) {
+		r.modelName = name
+	}
+}
+
+// WithReembedBatchSize sets the number of nodes embedded and written per batch.
+func WithReembedBatchSize(size int) ReembedderOption {
+	return func(r *Reembedder) {
+		r.batchSize = size
+	}
+}
+
+// WithReembedProgress sets a callback invoked after each batch.
+func WithReembedProgress(fn func(ReembedProgress)) ReembedderOption {
+	return func(r *Reembedder) {
+		r.progress = fn
+	}
+}
+
+// NewReembedder creates a new Reembedder. The store must implement
+// store.NodeLister so stale nodes can be found.
+func NewReembedder(vectorStore store.VectorStore, embedModel embedding.EmbeddingModel, opts ...ReembedderOption) *Reembedder {
+	r := &Reembedder{
+		store:      vectorStore,
+		embedModel: embedModel,
+		modelName:  embedding.ModelName(embedModel),
+		batchSize:  32,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// FindStale returns the stored nodes not embedded with the target model.
+func (r *Reembedder) FindStale(ctx context.Context) ([]schema.Node, int, error) {
+	lister, ok := r.store.(store.NodeLister)
+	if !ok {
+		return nil, 0, fmt.Errorf("vector store %T does not support listing nodes", r.store)
+	}
+	if r.modelName == "" {
+		return nil, 0, fmt.Errorf("target embedding model name is unknown; set it with WithReembedModelName")
+	}
+
+	nodes, err := lister.ListNodes(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	var stale []schema.Node
+	for _, node := range nodes {
+		if node.GetEmbeddingModel() != r.modelName {
+			stale = append(stale, node)
+		}
+	}
+	return stale, len(nodes), nil
+}
+
+// Run re-embeds all stale nodes.
+func (r *Reembedder) Run(ctx context.Context) (*ReembedResult, error) {
+	stale, scanned, err := r.FindStale(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReembedResult{Scanned: scanned, Stale: len(stale)}
+
+	batchSize := r.batchSize
+	if batchSize <= 0 {
+		batchSize = len(stale)
+	}
+
+	for start := 0; start < len(stale); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		end := start + batchSize
+		if end > len(stale) {
+			end = len(stale)
+		}
+		batch := stale[start:end]
+
+		if err := r.embedBatch(ctx, batch); err != nil {
+			return result, fmt.Errorf("failed to re-embed batch starting at node %s: %w", batch[0].ID, err)
+		}
+		if _, err := r.store.Add(ctx, batch); err != nil {
+			return result, fmt.Errorf("failed to write re-embedded batch: %w", err)
+		}
+
+		result.Reembedded += len(batch)
+		if r.progress != nil {
+			r.progress(ReembedProgress{Done: result.Reembedded, Total: len(stale)})
+		}
+	}
+
+	return result, nil
+}
+
+// embedBatch computes new embeddings for the batch in place.
+func (r *Reembedder) embedBatch(ctx context.Context, batch []schema.Node) error {
+	return embedNodes(ctx, r.embedModel, r.modelName, batch)
+}
+
+// embedNodes embeds nodes in place, in one request when the model supports
+// batching, and stamps them with modelName.
+func embedNodes(ctx context.Context, embedModel embedding.EmbeddingModel, modelName string, batch []schema.Node) error {
+	texts := make([]string, len(batch))
+	for i := range batch {
+		batch[i].ExcludeEmbeddingModelKey()
+		texts[i] = batch[i].GetContent(schema.MetadataModeEmbed)
+	}
+
+	var embeddings [][]float64
+	if batchModel, ok := embedModel.(embedding.EmbeddingModelWithBatch); ok {
+		var err error
+		embeddings, err = batchModel.GetTextEmbeddingsBatch(ctx, texts, nil)
+		if err != nil {
+			return err
+		}
+		if len(embeddings) != len(batch) {
+			return fmt.Errorf("expected %d embeddings, got %d", len(batch), len(embeddings))
+		}
+	} else {
+		embeddings = make([][]float64, len(batch))
+		for i, text := range texts {
+			emb, err := embedModel.GetTextEmbedding(ctx, text)
+			if err != nil {
+				return err
+			}
+			embeddings[i] = emb
+		}
+	}
+
+	for i := range batch {
+		batch[i].Embedding = embeddings[i]
+		if modelName != "" {
+			batch[i].SetEmbeddingModel(modelName)
+		}
+	}
+	return nil
+}
+
+// Reembed re-embeds all nodes in the store not produced by the given model.
+func Reembed(ctx context.Context, vectorStore store.VectorStore, embedModel embedding.EmbeddingModel, opts ...ReembedderOption) (*ReembedResult, error) {
+	return NewReembedder(vectorStore, embedModel, opts...).Run(ctx)
+}
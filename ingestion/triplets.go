@@ -0,0 +1,176 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/graphstore"
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// TripletExtractor asks an LLM for the (subject, predicate, object)
+// triplets stated in each node and stores them in the node metadata under
+// graphstore.TripletsMetadataKey. A KnowledgeGraphIndex built from the
+// nodes uses the stored triplets instead of extracting them again, so
+// extraction can run once in a cached pipeline, in parallel, and be
+// inspected before the graph is built.
+//
+// The triplets are kept out of embeddings and LLM prompts.
+type TripletExtractor struct {
+	llm                 llm.LLM
+	template            *prompts.PromptTemplate
+	maxTripletsPerChunk int
+	maxObjectLength     int
+	numWorkers          int
+}
+
+// TripletExtractorOption configures a TripletExtractor.
+type TripletExtractorOption func(*TripletExtractor)
+
+// WithTripletExtractTemplate sets the extraction prompt. It uses the
+// {text} and {max_knowledge_triplets} placeholders.
+func WithTripletExtractTemplate(tmpl *prompts.PromptTemplate) TripletExtractorOption {
+	return func(t *TripletExtractor) {
+		t.template = tmpl
+	}
+}
+
+// WithMaxTripletsPerChunk sets the maximum number of triplets per node.
+// Defaults to 10.
+func WithMaxTripletsPerChunk(n int) TripletExtractorOption {
+	return func(t *TripletExtractor) {
+		if n > 0 {
+			t.maxTripletsPerChunk = n
+		}
+	}
+}
+
+// WithMaxTripletObjectLength sets the maximum length of a triplet part;
+// longer triplets are dropped. Defaults to 128.
+func WithMaxTripletObjectLength(n int) TripletExtractorOption {
+	return func(t *TripletExtractor) {
+		if n > 0 {
+			t.maxObjectLength = n
+		}
+	}
+}
+
+// WithTripletExtractWorkers sets the number of concurrent LLM calls.
+// Defaults to 4.
+func WithTripletExtractWorkers(n int) TripletExtractorOption {
+	return func(t *TripletExtractor) {
+		if n > 0 {
+			t.numWorkers = n
+		}
+	}
+}
+
+// NewTripletExtractor creates a TripletExtractor using l.
+func NewTripletExtractor(l llm.LLM, opts ...TripletExtractorOption) *TripletExtractor {
+	t := &TripletExtractor{
+		llm:                 l,
+		template:            prompts.DefaultKGTripletExtractPrompt,
+		maxTripletsPerChunk: 10,
+		maxObjectLength:     128,
+		numWorkers:          4,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// Name implements TransformComponent.
+func (t *TripletExtractor) Name() string {
+	return "TripletExtractor"
+}
+
+// Transform implements TransformComponent. Nodes that already carry
+// triplets and nodes without text are left unchanged.
+func (t *TripletExtractor) Transform(ctx context.Context, nodes []schema.Node) ([]schema.Node, error) {
+	if t.llm == nil {
+		return nil, fmt.Errorf("LLM must be provided for TripletExtractor")
+	}
+
+	result := make([]schema.Node, len(nodes))
+	copy(result, nodes)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	semaphore := make(chan struct{}, t.numWorkers)
+
+	for i := range result {
+		node := &result[i]
+		if _, done := graphstore.TripletsFromMetadata(node.Metadata); done {
+			continue
+		}
+		if strings.TrimSpace(node.Text) == "" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(node *schema.Node) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			triplets, err := t.Extract(ctx, node.GetContent(schema.MetadataModeLLM))
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to extract triplets from node %s: %w", node.ID, err)
+				}
+				mu.Unlock()
+				return
+			}
+			t.apply(node, triplets)
+		}(node)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return result, nil
+}
+
+// Extract asks the LLM for the triplets stated in text.
+func (t *TripletExtractor) Extract(ctx context.Context, text string) ([]graphstore.Triplet, error) {
+	prompt := t.template.Format(map[string]string{
+		"max_knowledge_triplets": fmt.Sprintf("%d", t.maxTripletsPerChunk),
+		"text":                   text,
+	})
+
+	response, err := t.llm.Complete(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	return graphstore.ParseTriplets(response, t.maxTripletsPerChunk, t.maxObjectLength), nil
+}
+
+// apply stores the triplets on a copy of the node metadata.
+func (t *TripletExtractor) apply(node *schema.Node, triplets []graphstore.Triplet) {
+	metadata := make(map[string]interface{}, len(node.Metadata)+1)
+	for k, v := range node.Metadata {
+		metadata[k] = v
+	}
+	if triplets == nil {
+		triplets = []graphstore.Triplet{}
+	}
+	metadata[graphstore.TripletsMetadataKey] = triplets
+	node.Metadata = metadata
+	node.ExcludedEmbedMetadataKeys = appendKey(node.ExcludedEmbedMetadataKeys, graphstore.TripletsMetadataKey)
+	node.ExcludedLLMMetadataKeys = appendKey(node.ExcludedLLMMetadataKeys, graphstore.TripletsMetadataKey)
+}
+
+// Ensure TripletExtractor implements TransformComponent.
+var _ TransformComponent = (*TripletExtractor)(nil)
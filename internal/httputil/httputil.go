@@ -0,0 +1,93 @@
+// Package httputil holds the retry and authentication helpers shared by the
+// HTTP clients of readers and API integrations.
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxRetries is the default number of retries of rate-limited or
+// failed requests.
+const DefaultMaxRetries = 3
+
+// MaxRetryDelay caps the delay before a retry, whether it comes from a
+// Retry-After header or from backoff, so that a misbehaving server cannot
+// stall a client for hours.
+const MaxRetryDelay = time.Minute
+
+// RetryAfter parses a Retry-After header value, given either in seconds or
+// as an HTTP date, into the delay from now, capped at MaxRetryDelay. It
+// reports false if the value is missing or malformed. Dates in the past
+// yield a zero delay.
+func RetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	var d time.Duration
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		if seconds >= MaxRetryDelay.Seconds() {
+			return MaxRetryDelay, true
+		}
+		d = time.Duration(seconds * float64(time.Second))
+	} else if t, err := http.ParseTime(value); err == nil {
+		d = t.Sub(now)
+	} else {
+		return 0, false
+	}
+	if d < 0 {
+		d = 0
+	}
+	if d > MaxRetryDelay {
+		d = MaxRetryDelay
+	}
+	return d, true
+}
+
+// Backoff returns the exponential backoff before retry attempt, counting
+// from 0: 1s, 2s, 4s and so on, capped at MaxRetryDelay.
+func Backoff(attempt int) time.Duration {
+	if attempt < 0 {
+		attempt = 0
+	}
+	if attempt >= 6 {
+		return MaxRetryDelay
+	}
+	return min(time.Duration(1<<attempt)*time.Second, MaxRetryDelay)
+}
+
+// RetryDelay returns the delay before retry attempt: the Retry-After
+// header's if it has a valid one, exponential backoff otherwise.
+func RetryDelay(header string, attempt int) time.Duration {
+	if d, ok := RetryAfter(header, time.Now()); ok {
+		return d
+	}
+	return Backoff(attempt)
+}
+
+// Sleep waits for d, returning early with the context's error if ctx is
+// done first.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// StaticToken returns a token source that always returns token.
+func StaticToken(token string) func(context.Context) (string, error) {
+	return func(context.Context) (string, error) {
+		return token, nil
+	}
+}
@@ -0,0 +1,62 @@
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"soon", 0, false},
+		{"-1", 0, false},
+		{"0", 0, true},
+		{"2", 2 * time.Second, true},
+		{"1.5", 1500 * time.Millisecond, true},
+		{"86400", MaxRetryDelay, true},
+		{"1e300", MaxRetryDelay, true},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second, true},
+		{now.Add(time.Hour).Format(http.TimeFormat), MaxRetryDelay, true},
+		{now.Add(-time.Hour).Format(http.TimeFormat), 0, true},
+	}
+
+	for _, tt := range tests {
+		got, ok := RetryAfter(tt.value, now)
+		assert.Equal(t, tt.ok, ok, tt.value)
+		assert.Equal(t, tt.want, got, tt.value)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, Backoff(0))
+	assert.Equal(t, 4*time.Second, Backoff(2))
+	assert.Equal(t, MaxRetryDelay, Backoff(10))
+	assert.Equal(t, MaxRetryDelay, Backoff(100))
+
+	assert.Equal(t, 2*time.Second, RetryDelay("2", 5))
+	assert.Equal(t, 2*time.Second, RetryDelay("", 1))
+}
+
+func TestSleep(t *testing.T) {
+	require.NoError(t, Sleep(context.Background(), time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, Sleep(ctx, time.Hour), context.Canceled)
+}
+
+func TestStaticToken(t *testing.T) {
+	token, err := StaticToken("abc")(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "abc", token)
+}
@@ -0,0 +1,210 @@
+// Package limits enforces hard size limits on payloads flowing through the
+// framework: documents, node text, tool outputs and responses. Each limit
+// either rejects oversized payloads with a typed error or truncates them,
+// so pathological inputs cannot explode memory or cost.
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
+)
+
+// Kind identifies the payload a limit applies to.
+type Kind string
+
+const (
+	// KindDocument is the text and raw data of an ingested document.
+	KindDocument Kind = "document"
+	// KindNodeText is the text of a node.
+	KindNodeText Kind = "node_text"
+	// KindToolOutput is the content returned by a tool.
+	KindToolOutput Kind = "tool_output"
+	// KindResponse is the text of a generated response.
+	KindResponse Kind = "response"
+)
+
+// Strategy is what happens when a payload exceeds its limit.
+type Strategy string
+
+const (
+	// StrategyError rejects the payload with a *LimitExceededError.
+	StrategyError Strategy = "error"
+	// StrategyTruncate keeps the beginning of the payload.
+	StrategyTruncate Strategy = "truncate"
+	// StrategyTruncateMiddle keeps the beginning and end of the payload,
+	// which suits logs and tool output where the tail often matters.
+	StrategyTruncateMiddle Strategy = "truncate_middle"
+)
+
+// DefaultTruncationMarker is appended or inserted where text was removed.
+const DefaultTruncationMarker = "\n...[truncated]...\n"
+
+// ErrLimitExceeded is matched by every *LimitExceededError via errors.Is.
+var ErrLimitExceeded = errors.New("payload limit exceeded")
+
+// LimitExceededError reports a payload rejected by a limit.
+type LimitExceededError struct {
+	// Kind is the payload kind.
+	Kind Kind
+	// Size is the payload size in bytes.
+	Size int
+	// Limit is the maximum allowed size in bytes.
+	Limit int
+}
+
+// Error implements error.
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s size %d bytes exceeds limit of %d bytes", e.Kind, e.Size, e.Limit)
+}
+
+// Unwrap returns ErrLimitExceeded.
+func (e *LimitExceededError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// Limit is the maximum size of one kind of payload.
+type Limit struct {
+	// MaxBytes is the maximum size in bytes. Zero means unlimited.
+	MaxBytes int
+	// Strategy is applied to oversized payloads. Defaults to StrategyError.
+	Strategy Strategy
+}
+
+// Limits groups the limits for every payload kind.
+type Limits struct {
+	Document   Limit
+	NodeText   Limit
+	ToolOutput Limit
+	Response   Limit
+	// TruncationMarker marks removed text. Defaults to DefaultTruncationMarker.
+	TruncationMarker string
+}
+
+// DefaultLimits returns conservative limits: documents over 50 MB are
+// rejected, node text is capped at 1 MB, tool output at 64 KB (keeping
+// head and tail) and responses at 256 KB.
+func DefaultLimits() Limits {
+	return Limits{
+		Document:   Limit{MaxBytes: 50 << 20, Strategy: StrategyError},
+		NodeText:   Limit{MaxBytes: 1 << 20, Strategy: StrategyTruncate},
+		ToolOutput: Limit{MaxBytes: 64 << 10, Strategy: StrategyTruncateMiddle},
+		Response:   Limit{MaxBytes: 256 << 10, Strategy: StrategyTruncate},
+	}
+}
+
+var (
+	globalMu     sync.RWMutex
+	globalLimits = DefaultLimits()
+)
+
+// Global returns the process-wide limits used when no explicit limits are
+// given.
+func Global() Limits {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return globalLimits
+}
+
+// SetGlobal sets the process-wide limits.
+func SetGlobal(l Limits) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	globalLimits = l
+}
+
+// Resolve returns *l, or Global() when l is nil.
+func Resolve(l *Limits) Limits {
+	if l == nil {
+		return Global()
+	}
+	return *l
+}
+
+// For returns the limit for a payload kind.
+func (l Limits) For(kind Kind) Limit {
+	switch kind {
+	case KindDocument:
+		return l.Document
+	case KindNodeText:
+		return l.NodeText
+	case KindToolOutput:
+		return l.ToolOutput
+	case KindResponse:
+		return l.Response
+	}
+	return Limit{}
+}
+
+// Apply enforces the limit for kind on text. It returns the text unchanged
+// when it fits, a truncated copy, or a *LimitExceededError.
+func (l Limits) Apply(kind Kind, text string) (string, error) {
+	limit := l.For(kind)
+	if limit.MaxBytes <= 0 || len(text) <= limit.MaxBytes {
+		return text, nil
+	}
+
+	marker := l.TruncationMarker
+	if marker == "" {
+		marker = DefaultTruncationMarker
+	}
+
+	switch limit.Strategy {
+	case StrategyTruncate:
+		return truncateHead(text, limit.MaxBytes, marker), nil
+	case StrategyTruncateMiddle:
+		return truncateMiddle(text, limit.MaxBytes, marker), nil
+	default:
+		return "", &LimitExceededError{Kind: kind, Size: len(text), Limit: limit.MaxBytes}
+	}
+}
+
+// ApplyDocument enforces the document limit on the document's text and raw
+// data. Raw data cannot be truncated meaningfully, so oversized data is
+// always rejected.
+func (l Limits) ApplyDocument(doc *schema.Document) error {
+	limit := l.Document
+	if limit.MaxBytes > 0 && len(doc.Data) > limit.MaxBytes {
+		return &LimitExceededError{Kind: KindDocument, Size: len(doc.Data), Limit: limit.MaxBytes}
+	}
+	text, err := l.Apply(KindDocument, doc.Text)
+	if err != nil {
+		return err
+	}
+	doc.Text = text
+	return nil
+}
+
+// ApplyNode enforces the node text limit on a node.
+func (l Limits) ApplyNode(node *schema.Node) error {
+	text, err := l.Apply(KindNodeText, node.Text)
+	if err != nil {
+		return err
+	}
+	node.Text = text
+	return nil
+}
+
+// truncateHead keeps the longest prefix that fits with the marker, cut on a
+// rune boundary.
+func truncateHead(text string, maxBytes int, marker string) string {
+	keep := maxBytes - len(marker)
+	if keep <= 0 {
+		return textutil.TruncateBytes(text, maxBytes)
+	}
+	return textutil.TruncateBytes(text, keep) + marker
+}
+
+// truncateMiddle keeps a prefix and suffix of roughly equal size around the
+// marker, cut on rune boundaries.
+func truncateMiddle(text string, maxBytes int, marker string) string {
+	keep := maxBytes - len(marker)
+	if keep <= 0 {
+		return textutil.TruncateBytes(text, maxBytes)
+	}
+	head := keep - keep/2
+	return textutil.TruncateBytes(text, head) + marker + textutil.TailBytes(text, keep/2)
+}
@@ -0,0 +1,99 @@
+package limits
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyWithinLimit(t *testing.T) {
+	l := Limits{NodeText: Limit{MaxBytes: 10}}
+	text, err := l.Apply(KindNodeText, "short")
+	require.NoError(t, err)
+	assert.Equal(t, "short", text)
+
+	// Zero means unlimited.
+	text, err = Limits{}.Apply(KindNodeText, strings.Repeat("x", 1000))
+	require.NoError(t, err)
+	assert.Len(t, text, 1000)
+}
+
+func TestApplyError(t *testing.T) {
+	l := Limits{Response: Limit{MaxBytes: 4, Strategy: StrategyError}}
+	_, err := l.Apply(KindResponse, "too long")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrLimitExceeded))
+
+	var limitErr *LimitExceededError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, KindResponse, limitErr.Kind)
+	assert.Equal(t, 8, limitErr.Size)
+	assert.Equal(t, 4, limitErr.Limit)
+	assert.Equal(t, "response size 8 bytes exceeds limit of 4 bytes", err.Error())
+}
+
+func TestApplyTruncate(t *testing.T) {
+	l := Limits{
+		NodeText:         Limit{MaxBytes: 10, Strategy: StrategyTruncate},
+		ToolOutput:       Limit{MaxBytes: 11, Strategy: StrategyTruncateMiddle},
+		TruncationMarker: "|",
+	}
+
+	text, err := l.Apply(KindNodeText, "abcdefghijklmnop")
+	require.NoError(t, err)
+	assert.Equal(t, "abcdefghi|", text)
+
+	text, err = l.Apply(KindToolOutput, "abcdefghijklmnop")
+	require.NoError(t, err)
+	assert.Equal(t, "abcde|lmnop", text)
+}
+
+func TestApplyTruncateRuneSafe(t *testing.T) {
+	l := Limits{NodeText: Limit{MaxBytes: 8, Strategy: StrategyTruncate}, TruncationMarker: "…"}
+
+	text, err := l.Apply(KindNodeText, "héllo wörld")
+	require.NoError(t, err)
+	assert.True(t, utf8.ValidString(text))
+	assert.LessOrEqual(t, len(text), 8)
+
+	middle := Limits{ToolOutput: Limit{MaxBytes: 9, Strategy: StrategyTruncateMiddle}, TruncationMarker: "|"}
+	text, err = middle.Apply(KindToolOutput, "日本語のテキスト")
+	require.NoError(t, err)
+	assert.True(t, utf8.ValidString(text))
+	assert.LessOrEqual(t, len(text), 9)
+}
+
+func TestApplyDocumentAndNode(t *testing.T) {
+	l := Limits{
+		Document: Limit{MaxBytes: 5},
+		NodeText: Limit{MaxBytes: 5, Strategy: StrategyTruncate},
+	}
+
+	doc := schema.Document{ID: "d", Data: []byte("0123456789")}
+	assert.ErrorIs(t, l.ApplyDocument(&doc), ErrLimitExceeded)
+
+	doc = schema.Document{ID: "d", Text: "ok"}
+	assert.NoError(t, l.ApplyDocument(&doc))
+
+	node := schema.Node{Text: "0123456789"}
+	require.NoError(t, l.ApplyNode(&node))
+	assert.LessOrEqual(t, len(node.Text), 5)
+}
+
+func TestGlobal(t *testing.T) {
+	original := Global()
+	defer SetGlobal(original)
+
+	custom := Limits{Response: Limit{MaxBytes: 1}}
+	SetGlobal(custom)
+	assert.Equal(t, custom, Global())
+	assert.Equal(t, custom, Resolve(nil))
+
+	explicit := DefaultLimits()
+	assert.Equal(t, explicit, Resolve(&explicit))
+}
@@ -9,6 +9,8 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+
+	"github.com/aqua777/go-llamaindex/credentials"
 )
 
 const (
@@ -29,12 +31,13 @@ const (
 
 // AnthropicLLM implements the LLM interface for Anthropic Claude models.
 type AnthropicLLM struct {
-	apiKey     string
-	baseURL    string
-	model      string
-	maxTokens  int
-	httpClient *http.Client
-	logger     *slog.Logger
+	apiKey      string
+	baseURL     string
+	model       string
+	maxTokens   int
+	httpClient  *http.Client
+	credentials credentials.Provider
+	logger      *slog.Logger
 }
 
 // AnthropicOption configures an AnthropicLLM.
@@ -75,6 +78,16 @@ func WithAnthropicHTTPClient(client *http.Client) AnthropicOption {
 	}
 }
 
+// WithAnthropicCredentials resolves the API key from a credentials provider on
+// every request, under the name "ANTHROPIC_API_KEY". It takes precedence over the
+// static API key. When the API responds 401 the key is invalidated and the
+// request retried once, so rotated keys are picked up without restarting.
+func WithAnthropicCredentials(provider credentials.Provider) AnthropicOption {
+	return func(a *AnthropicLLM) {
+		a.credentials = provider
+	}
+}
+
 // NewAnthropicLLM creates a new Anthropic LLM client.
 func NewAnthropicLLM(opts ...AnthropicOption) *AnthropicLLM {
 	a := &AnthropicLLM{
@@ -89,6 +102,9 @@ func NewAnthropicLLM(opts ...AnthropicOption) *AnthropicLLM {
 	for _, opt := range opts {
 		opt(a)
 	}
+	if a.credentials != nil {
+		a.httpClient = credentials.NewHTTPClient(a.httpClient, a.credentials, "ANTHROPIC_API_KEY", credentials.HeaderAuth("x-api-key"))
+	}
 
 	return a
 }
@@ -459,7 +475,9 @@ func (a *AnthropicLLM) doRequest(ctx context.Context, path string, body interfac
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", a.apiKey)
+	if a.credentials == nil {
+		req.Header.Set("x-api-key", a.apiKey)
+	}
 	req.Header.Set("anthropic-version", AnthropicAPIVersion)
 
 	resp, err := a.httpClient.Do(req)
@@ -502,7 +520,9 @@ func (a *AnthropicLLM) doStreamRequest(ctx context.Context, path string, body in
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", a.apiKey)
+	if a.credentials == nil {
+		req.Header.Set("x-api-key", a.apiKey)
+	}
 	req.Header.Set("anthropic-version", AnthropicAPIVersion)
 	req.Header.Set("Accept", "text/event-stream")
 
@@ -7,16 +7,18 @@ import (
 	"log/slog"
 	"os"
 
+	"github.com/aqua777/go-llamaindex/credentials"
 	openai "github.com/sashabaranov/go-openai"
 )
 
 // AzureOpenAILLM implements the LLM interface for Azure OpenAI models.
 // It uses the same underlying client as OpenAI but with Azure-specific configuration.
 type AzureOpenAILLM struct {
-	client     *openai.Client
-	model      string // This is the deployment name in Azure
-	logger     *slog.Logger
-	apiVersion string
+	client      *openai.Client
+	model       string // This is the deployment name in Azure
+	logger      *slog.Logger
+	apiVersion  string
+	credentials credentials.Provider
 }
 
 // AzureOpenAIOption configures an AzureOpenAILLM.
@@ -36,12 +38,21 @@ func WithAzureAPIVersion(version string) AzureOpenAIOption {
 	}
 }
 
+// WithAzureOpenAICredentials resolves the API key from a credentials
+// provider on every request, under the name "AZURE_OPENAI_API_KEY", instead
+// of reading it from the environment.
+func WithAzureOpenAICredentials(provider credentials.Provider) AzureOpenAIOption {
+	return func(a *AzureOpenAILLM) {
+		a.credentials = provider
+	}
+}
+
 // NewAzureOpenAILLM creates a new Azure OpenAI LLM client.
 // It requires the Azure endpoint and API key, which can be provided via
-// environment variables AZURE_OPENAI_ENDPOINT and AZURE_OPENAI_API_KEY.
+// environment variables AZURE_OPENAI_ENDPOINT and AZURE_OPENAI_API_KEY, or
+// the key via WithAzureOpenAICredentials.
 func NewAzureOpenAILLM(opts ...AzureOpenAIOption) *AzureOpenAILLM {
 	endpoint := os.Getenv("AZURE_OPENAI_ENDPOINT")
-	apiKey := os.Getenv("AZURE_OPENAI_API_KEY")
 	deployment := os.Getenv("AZURE_OPENAI_DEPLOYMENT")
 
 	a := &AzureOpenAILLM{
@@ -55,7 +66,13 @@ func NewAzureOpenAILLM(opts ...AzureOpenAIOption) *AzureOpenAILLM {
 	}
 
 	// Create Azure OpenAI config
-	config := openai.DefaultAzureConfig(apiKey, endpoint)
+	var config openai.ClientConfig
+	if a.credentials != nil {
+		config = openai.DefaultAzureConfig("", endpoint)
+		config.HTTPClient = credentials.NewHTTPClient(nil, a.credentials, "AZURE_OPENAI_API_KEY", credentials.HeaderAuth("api-key"))
+	} else {
+		config = openai.DefaultAzureConfig(os.Getenv("AZURE_OPENAI_API_KEY"), endpoint)
+	}
 	config.APIVersion = a.apiVersion
 
 	a.client = openai.NewClientWithConfig(config)
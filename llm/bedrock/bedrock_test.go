@@ -1,10 +1,14 @@
 package bedrock
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/aqua777/go-llamaindex/embedding"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestLLM tests the AWS Bedrock LLM implementation.
@@ -203,6 +207,20 @@ func TestEmbedding(t *testing.T) {
 		assert.Equal(t, "cohere.embed-v4:0", CohereEmbedV4)
 	})
 
+	t.Run("Cohere request truncates on rune boundaries", func(t *testing.T) {
+		emb := NewEmbedding(WithEmbeddingModel(CohereEmbedEnglishV3))
+		body, err := emb.buildRequestBody("cohere", strings.Repeat("é", 3000), "document")
+		require.NoError(t, err)
+
+		var request struct {
+			Texts []string `json:"texts"`
+		}
+		require.NoError(t, json.Unmarshal(body, &request))
+		require.Len(t, request.Texts, 1)
+		assert.True(t, utf8.ValidString(request.Texts[0]))
+		assert.Equal(t, 2048, utf8.RuneCountInString(request.Texts[0]))
+	})
+
 	t.Run("Embedding implements all interfaces", func(t *testing.T) {
 		var _ embedding.EmbeddingModel = (*Embedding)(nil)
 		var _ embedding.EmbeddingModelWithInfo = (*Embedding)(nil)
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/textutil"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
@@ -244,11 +245,7 @@ func (e *Embedding) getCohereBatchEmbeddings(ctx context.Context, texts []string
 	// Truncate texts to 2048 chars (Cohere limit)
 	truncatedTexts := make([]string, len(texts))
 	for i, text := range texts {
-		if len(text) > 2048 {
-			truncatedTexts[i] = text[:2048]
-		} else {
-			truncatedTexts[i] = text
-		}
+		truncatedTexts[i] = textutil.TruncateWith(text, 2048, "")
 	}
 
 	// Build Cohere batch request
@@ -321,9 +318,7 @@ func (e *Embedding) buildRequestBody(provider, text, inputType string) ([]byte,
 
 	case "cohere":
 		// Truncate to 2048 chars
-		if len(text) > 2048 {
-			text = text[:2048]
-		}
+		text = textutil.TruncateWith(text, 2048, "")
 
 		cohereInputType := "search_document"
 		if inputType == "query" {
@@ -9,6 +9,8 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+
+	"github.com/aqua777/go-llamaindex/credentials"
 )
 
 const (
@@ -33,6 +35,7 @@ type CohereLLM struct {
 	maxTokens   int
 	temperature *float32
 	httpClient  *http.Client
+	credentials credentials.Provider
 	logger      *slog.Logger
 }
 
@@ -81,6 +84,16 @@ func WithCohereHTTPClient(client *http.Client) CohereOption {
 	}
 }
 
+// WithCohereCredentials resolves the API key from a credentials provider on
+// every request, under the name "COHERE_API_KEY". It takes precedence over the
+// static API key. When the API responds 401 the key is invalidated and the
+// request retried once, so rotated keys are picked up without restarting.
+func WithCohereCredentials(provider credentials.Provider) CohereOption {
+	return func(c *CohereLLM) {
+		c.credentials = provider
+	}
+}
+
 // NewCohereLLM creates a new Cohere LLM client.
 func NewCohereLLM(opts ...CohereOption) *CohereLLM {
 	c := &CohereLLM{
@@ -95,6 +108,9 @@ func NewCohereLLM(opts ...CohereOption) *CohereLLM {
 	for _, opt := range opts {
 		opt(c)
 	}
+	if c.credentials != nil {
+		c.httpClient = credentials.NewHTTPClient(c.httpClient, c.credentials, "COHERE_API_KEY", credentials.BearerAuth)
+	}
 
 	return c
 }
@@ -489,7 +505,9 @@ func (c *CohereLLM) doGenerateRequest(ctx context.Context, body cohereGenerateRe
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	if c.credentials == nil {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -527,7 +545,9 @@ func (c *CohereLLM) doChatRequest(ctx context.Context, body cohereChatRequest) (
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	if c.credentials == nil {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -0,0 +1,507 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// tenantContextKey is the context key for the tenant of a request.
+type tenantContextKey struct{}
+
+// ContextWithTenant returns a context carrying the tenant a request is made
+// for. ConcurrencyLimiter and LimitedLLM use it to queue tenants fairly.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant set with ContextWithTenant, or an
+// empty string.
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenant
+}
+
+// LimiterStats reports the state of one model and tenant pair in a
+// ConcurrencyLimiter.
+type LimiterStats struct {
+	// Model is the model name.
+	Model string `json:"model"`
+	// Tenant is the tenant, empty for requests without one.
+	Tenant string `json:"tenant"`
+	// InFlight is the number of requests currently running.
+	InFlight int `json:"in_flight"`
+	// Queued is the number of requests waiting for a slot.
+	Queued int `json:"queued"`
+	// Acquired is the total number of requests granted a slot.
+	Acquired uint64 `json:"acquired"`
+	// Cancelled is the total number of requests whose context ended while
+	// they were queued.
+	Cancelled uint64 `json:"cancelled"`
+	// TotalWait is the total time granted requests spent queued.
+	TotalWait time.Duration `json:"total_wait"`
+	// MaxWait is the longest time a granted request spent queued.
+	MaxWait time.Duration `json:"max_wait"`
+}
+
+// ConcurrencyLimiter bounds the number of in-flight LLM requests per model
+// and, within a model, per tenant. Requests over a limit wait in a queue.
+// When a slot frees up it goes to the waiting tenants in round-robin order,
+// so one tenant's burst cannot starve the others, and requests of the same
+// tenant are served in arrival order.
+//
+// A single limiter is meant to be shared by everything that calls a
+// provider, e.g. agents, ingestion pipelines and batch evaluators. None of
+// them take a limiter directly: wrap the LLM they are given with
+// NewLimitedLLM, as documented on agent.WithAgentLLM,
+// ingestion.WithTransformations and evaluation.WithBatchWorkers.
+type ConcurrencyLimiter struct {
+	modelLimits   map[string]int
+	defaultModel  int
+	tenantLimits  map[string]int
+	defaultTenant int
+
+	mu     sync.Mutex
+	models map[string]*modelGate
+}
+
+// ConcurrencyLimiterOption configures a ConcurrencyLimiter.
+type ConcurrencyLimiterOption func(*ConcurrencyLimiter)
+
+// WithModelConcurrency sets the maximum in-flight requests for a model,
+// across all tenants.
+func WithModelConcurrency(model string, n int) ConcurrencyLimiterOption {
+	return func(l *ConcurrencyLimiter) {
+		l.modelLimits[model] = n
+	}
+}
+
+// WithDefaultModelConcurrency sets the maximum in-flight requests for models
+// without their own limit. Zero means unlimited.
+func WithDefaultModelConcurrency(n int) ConcurrencyLimiterOption {
+	return func(l *ConcurrencyLimiter) {
+		l.defaultModel = n
+	}
+}
+
+// WithTenantConcurrency sets the maximum in-flight requests of a tenant for
+// each model.
+func WithTenantConcurrency(tenant string, n int) ConcurrencyLimiterOption {
+	return func(l *ConcurrencyLimiter) {
+		l.tenantLimits[tenant] = n
+	}
+}
+
+// WithDefaultTenantConcurrency sets the maximum in-flight requests per model
+// for tenants without their own limit. Zero means unlimited.
+func WithDefaultTenantConcurrency(n int) ConcurrencyLimiterOption {
+	return func(l *ConcurrencyLimiter) {
+		l.defaultTenant = n
+	}
+}
+
+// NewConcurrencyLimiter creates a new ConcurrencyLimiter. Without options
+// it does not limit anything.
+func NewConcurrencyLimiter(opts ...ConcurrencyLimiterOption) *ConcurrencyLimiter {
+	l := &ConcurrencyLimiter{
+		modelLimits:  make(map[string]int),
+		tenantLimits: make(map[string]int),
+		models:       make(map[string]*modelGate),
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// Acquire waits for a slot for model and the tenant in ctx. The returned
+// release function must be called once the request finishes. It returns
+// the context's error if ctx ends while waiting.
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context, model string) (func(), error) {
+	tenant := TenantFromContext(ctx)
+	gate := l.gate(model)
+
+	gate.mu.Lock()
+	state := gate.tenant(tenant, l.tenantLimit(tenant))
+	if len(state.waiters) == 0 && gate.hasCapacity() && state.hasCapacity() {
+		gate.grant(state, 0)
+		gate.mu.Unlock()
+		return gate.releaseFunc(state), nil
+	}
+
+	w := &limiterWaiter{ready: make(chan struct{}), queuedAt: time.Now()}
+	state.waiters = append(state.waiters, w)
+	gate.enqueue(tenant)
+	gate.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return gate.releaseFunc(state), nil
+	case <-ctx.Done():
+		gate.mu.Lock()
+		defer gate.mu.Unlock()
+		if w.granted {
+			// Granted while giving up: hand the slot back.
+			gate.release(state)
+		} else {
+			state.remove(w)
+		}
+		state.cancelled++
+		return nil, fmt.Errorf("waiting for %s concurrency slot: %w", model, ctx.Err())
+	}
+}
+
+// Stats returns the state of every model and tenant pair seen so far,
+// sorted by model and tenant.
+func (l *ConcurrencyLimiter) Stats() []LimiterStats {
+	l.mu.Lock()
+	gates := make([]*modelGate, 0, len(l.models))
+	for _, gate := range l.models {
+		gates = append(gates, gate)
+	}
+	l.mu.Unlock()
+
+	var stats []LimiterStats
+	for _, gate := range gates {
+		gate.mu.Lock()
+		for tenant, state := range gate.tenants {
+			stats = append(stats, LimiterStats{
+				Model:     gate.model,
+				Tenant:    tenant,
+				InFlight:  state.inFlight,
+				Queued:    len(state.waiters),
+				Acquired:  state.acquired,
+				Cancelled: state.cancelled,
+				TotalWait: state.totalWait,
+				MaxWait:   state.maxWait,
+			})
+		}
+		gate.mu.Unlock()
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Model != stats[j].Model {
+			return stats[i].Model < stats[j].Model
+		}
+		return stats[i].Tenant < stats[j].Tenant
+	})
+	return stats
+}
+
+// gate returns the gate of a model, creating it on first use.
+func (l *ConcurrencyLimiter) gate(model string) *modelGate {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	gate, ok := l.models[model]
+	if !ok {
+		limit, ok := l.modelLimits[model]
+		if !ok {
+			limit = l.defaultModel
+		}
+		gate = &modelGate{model: model, limit: limit, tenants: make(map[string]*tenantState)}
+		l.models[model] = gate
+	}
+	return gate
+}
+
+// tenantLimit returns the per-model limit of a tenant.
+func (l *ConcurrencyLimiter) tenantLimit(tenant string) int {
+	if limit, ok := l.tenantLimits[tenant]; ok {
+		return limit
+	}
+	return l.defaultTenant
+}
+
+// limiterWaiter is a request waiting for a slot.
+type limiterWaiter struct {
+	ready    chan struct{}
+	queuedAt time.Time
+	granted  bool
+}
+
+// tenantState tracks one tenant's requests for a model.
+type tenantState struct {
+	limit     int
+	inFlight  int
+	waiters   []*limiterWaiter
+	acquired  uint64
+	cancelled uint64
+	totalWait time.Duration
+	maxWait   time.Duration
+}
+
+func (t *tenantState) hasCapacity() bool {
+	return t.limit <= 0 || t.inFlight < t.limit
+}
+
+func (t *tenantState) remove(w *limiterWaiter) {
+	for i, waiter := range t.waiters {
+		if waiter == w {
+			t.waiters = append(t.waiters[:i], t.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// modelGate holds the slots of one model.
+type modelGate struct {
+	model    string
+	limit    int
+	mu       sync.Mutex
+	inFlight int
+	tenants  map[string]*tenantState
+	// queue lists tenants with waiters in round-robin order.
+	queue []string
+}
+
+func (g *modelGate) hasCapacity() bool {
+	return g.limit <= 0 || g.inFlight < g.limit
+}
+
+// tenant returns a tenant's state, creating it on first use.
+func (g *modelGate) tenant(tenant string, limit int) *tenantState {
+	state, ok := g.tenants[tenant]
+	if !ok {
+		state = &tenantState{limit: limit}
+		g.tenants[tenant] = state
+	}
+	return state
+}
+
+// enqueue adds a tenant to the round-robin queue if it is not there yet.
+func (g *modelGate) enqueue(tenant string) {
+	for _, t := range g.queue {
+		if t == tenant {
+			return
+		}
+	}
+	g.queue = append(g.queue, tenant)
+}
+
+// grant takes a slot for a tenant.
+func (g *modelGate) grant(state *tenantState, wait time.Duration) {
+	g.inFlight++
+	state.inFlight++
+	state.acquired++
+	state.totalWait += wait
+	if wait > state.maxWait {
+		state.maxWait = wait
+	}
+}
+
+// releaseFunc returns a function that releases a tenant's slot once.
+func (g *modelGate) releaseFunc(state *tenantState) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			g.release(state)
+		})
+	}
+}
+
+// release frees a slot and hands free slots to waiting tenants in
+// round-robin order. The caller must hold g.mu.
+func (g *modelGate) release(state *tenantState) {
+	g.inFlight--
+	state.inFlight--
+
+	for g.hasCapacity() {
+		granted := false
+		for i := 0; i < len(g.queue); i++ {
+			tenant := g.queue[i]
+			ts := g.tenants[tenant]
+			if len(ts.waiters) == 0 {
+				g.queue = append(g.queue[:i], g.queue[i+1:]...)
+				i--
+				continue
+			}
+			if !ts.hasCapacity() {
+				continue
+			}
+
+			w := ts.waiters[0]
+			ts.waiters = ts.waiters[1:]
+			w.granted = true
+			g.grant(ts, time.Since(w.queuedAt))
+			close(w.ready)
+
+			// Move the tenant to the back of the queue.
+			g.queue = append(g.queue[:i], g.queue[i+1:]...)
+			if len(ts.waiters) > 0 {
+				g.queue = append(g.queue, tenant)
+			}
+			granted = true
+			break
+		}
+		if !granted {
+			return
+		}
+	}
+}
+
+// LimitedLLM wraps an LLM and runs every request through a
+// ConcurrencyLimiter under the model's name. Tool calling, structured
+// output and chat streaming are passed through when the wrapped LLM
+// supports them.
+type LimitedLLM struct {
+	llm     LLM
+	limiter *ConcurrencyLimiter
+	model   string
+}
+
+// LimitedLLMOption configures a LimitedLLM.
+type LimitedLLMOption func(*LimitedLLM)
+
+// WithLimitedLLMModel sets the model name used as the limiter key. Defaults
+// to the wrapped LLM's metadata model name.
+func WithLimitedLLMModel(model string) LimitedLLMOption {
+	return func(l *LimitedLLM) {
+		l.model = model
+	}
+}
+
+// NewLimitedLLM wraps l with limiter.
+func NewLimitedLLM(l LLM, limiter *ConcurrencyLimiter, opts ...LimitedLLMOption) *LimitedLLM {
+	limited := &LimitedLLM{llm: l, limiter: limiter}
+	if withMetadata, ok := l.(LLMWithMetadata); ok {
+		limited.model = withMetadata.Metadata().ModelName
+	}
+
+	for _, opt := range opts {
+		opt(limited)
+	}
+
+	return limited
+}
+
+// Unwrap returns the wrapped LLM.
+func (l *LimitedLLM) Unwrap() LLM {
+	return l.llm
+}
+
+// Complete generates a completion once a slot is free.
+func (l *LimitedLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	release, err := l.limiter.Acquire(ctx, l.model)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return l.llm.Complete(ctx, prompt)
+}
+
+// Chat generates a chat response once a slot is free.
+func (l *LimitedLLM) Chat(ctx context.Context, messages []ChatMessage) (string, error) {
+	release, err := l.limiter.Acquire(ctx, l.model)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return l.llm.Chat(ctx, messages)
+}
+
+// Stream streams a completion once a slot is free. The slot is held until
+// the stream ends.
+func (l *LimitedLLM) Stream(ctx context.Context, prompt string) (<-chan string, error) {
+	release, err := l.limiter.Acquire(ctx, l.model)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := l.llm.Stream(ctx, prompt)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return holdUntilClosed(stream, release), nil
+}
+
+// StreamChat streams a chat response once a slot is free. The slot is held
+// until the stream ends.
+func (l *LimitedLLM) StreamChat(ctx context.Context, messages []ChatMessage) (<-chan StreamToken, error) {
+	full, ok := l.llm.(FullLLM)
+	if !ok {
+		return nil, fmt.Errorf("wrapped LLM %T does not support chat streaming", l.llm)
+	}
+	release, err := l.limiter.Acquire(ctx, l.model)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := full.StreamChat(ctx, messages)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return holdUntilClosed(stream, release), nil
+}
+
+// Metadata returns the wrapped LLM's metadata.
+func (l *LimitedLLM) Metadata() LLMMetadata {
+	if withMetadata, ok := l.llm.(LLMWithMetadata); ok {
+		return withMetadata.Metadata()
+	}
+	return DefaultLLMMetadata(l.model)
+}
+
+// ChatWithTools generates a response that may include tool calls once a
+// slot is free.
+func (l *LimitedLLM) ChatWithTools(ctx context.Context, messages []ChatMessage, tools []*ToolMetadata, opts *ChatCompletionOptions) (CompletionResponse, error) {
+	withTools, ok := l.llm.(LLMWithToolCalling)
+	if !ok {
+		return CompletionResponse{}, fmt.Errorf("wrapped LLM %T does not support tool calling", l.llm)
+	}
+	release, err := l.limiter.Acquire(ctx, l.model)
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+	defer release()
+	return withTools.ChatWithTools(ctx, messages, tools, opts)
+}
+
+// SupportsToolCalling reports whether the wrapped LLM supports tool calling.
+func (l *LimitedLLM) SupportsToolCalling() bool {
+	withTools, ok := l.llm.(LLMWithToolCalling)
+	return ok && withTools.SupportsToolCalling()
+}
+
+// ChatWithFormat generates a formatted response once a slot is free.
+func (l *LimitedLLM) ChatWithFormat(ctx context.Context, messages []ChatMessage, format *ResponseFormat) (string, error) {
+	structured, ok := l.llm.(LLMWithStructuredOutput)
+	if !ok {
+		return "", fmt.Errorf("wrapped LLM %T does not support structured output", l.llm)
+	}
+	release, err := l.limiter.Acquire(ctx, l.model)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return structured.ChatWithFormat(ctx, messages, format)
+}
+
+// SupportsStructuredOutput reports whether the wrapped LLM supports
+// structured output.
+func (l *LimitedLLM) SupportsStructuredOutput() bool {
+	structured, ok := l.llm.(LLMWithStructuredOutput)
+	return ok && structured.SupportsStructuredOutput()
+}
+
+// holdUntilClosed forwards a stream and calls release once it is drained.
+func holdUntilClosed[T any](stream <-chan T, release func()) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		defer release()
+		for item := range stream {
+			out <- item
+		}
+	}()
+	return out
+}
+
+// Ensure LimitedLLM implements FullLLM.
+var _ FullLLM = (*LimitedLLM)(nil)
@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"os"
 
+	"github.com/aqua777/go-llamaindex/credentials"
 	openai "github.com/sashabaranov/go-openai"
 )
 
@@ -78,6 +79,14 @@ func WithDeepSeekBaseURL(baseURL string) DeepSeekOption {
 	}
 }
 
+// WithDeepSeekCredentials resolves the API key from a credentials provider on
+// every request, under the name "DEEPSEEK_API_KEY".
+func WithDeepSeekCredentials(provider credentials.Provider) DeepSeekOption {
+	return func(d *DeepSeekLLM) {
+		d.client = NewOpenAIClientWithCredentials(DeepSeekAPIURL, provider, "DEEPSEEK_API_KEY")
+	}
+}
+
 // WithDeepSeekClient sets a custom OpenAI client (for testing).
 func WithDeepSeekClient(client *openai.Client) DeepSeekOption {
 	return func(d *DeepSeekLLM) {
@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"os"
 
+	"github.com/aqua777/go-llamaindex/credentials"
 	openai "github.com/sashabaranov/go-openai"
 )
 
@@ -115,6 +116,14 @@ func WithGroqBaseURL(baseURL string) GroqOption {
 	}
 }
 
+// WithGroqCredentials resolves the API key from a credentials provider on
+// every request, under the name "GROQ_API_KEY".
+func WithGroqCredentials(provider credentials.Provider) GroqOption {
+	return func(g *GroqLLM) {
+		g.client = NewOpenAIClientWithCredentials(GroqAPIURL, provider, "GROQ_API_KEY")
+	}
+}
+
 // WithGroqClient sets a custom OpenAI client (for testing).
 func WithGroqClient(client *openai.Client) GroqOption {
 	return func(g *GroqLLM) {
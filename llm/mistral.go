@@ -11,6 +11,8 @@ import (
 	"net/http"
 	"os"
 	"strings"
+
+	"github.com/aqua777/go-llamaindex/credentials"
 )
 
 const (
@@ -82,6 +84,7 @@ type MistralLLM struct {
 	safeMode    bool
 	randomSeed  *int
 	httpClient  *http.Client
+	credentials credentials.Provider
 	logger      *slog.Logger
 }
 
@@ -151,6 +154,16 @@ func WithMistralHTTPClient(client *http.Client) MistralOption {
 	}
 }
 
+// WithMistralCredentials resolves the API key from a credentials provider on
+// every request, under the name "MISTRAL_API_KEY". It takes precedence over the
+// static API key. When the API responds 401 the key is invalidated and the
+// request retried once, so rotated keys are picked up without restarting.
+func WithMistralCredentials(provider credentials.Provider) MistralOption {
+	return func(m *MistralLLM) {
+		m.credentials = provider
+	}
+}
+
 // NewMistralLLM creates a new Mistral AI LLM client.
 func NewMistralLLM(opts ...MistralOption) *MistralLLM {
 	apiKey := os.Getenv("MISTRAL_API_KEY")
@@ -174,6 +187,9 @@ func NewMistralLLM(opts ...MistralOption) *MistralLLM {
 	for _, opt := range opts {
 		opt(m)
 	}
+	if m.credentials != nil {
+		m.httpClient = credentials.NewHTTPClient(m.httpClient, m.credentials, "MISTRAL_API_KEY", credentials.BearerAuth)
+	}
 
 	return m
 }
@@ -559,7 +575,9 @@ func (m *MistralLLM) doRequest(ctx context.Context, path string, body interface{
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	if m.credentials == nil {
+		req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
 	req.Header.Set("Accept", "application/json")
 
 	resp, err := m.httpClient.Do(req)
@@ -605,7 +623,9 @@ func (m *MistralLLM) doStreamRequest(ctx context.Context, path string, body inte
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	if m.credentials == nil {
+		req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
 	req.Header.Set("Accept", "text/event-stream")
 
 	resp, err := m.httpClient.Do(req)
@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"os"
 
+	"github.com/aqua777/go-llamaindex/credentials"
 	openai "github.com/sashabaranov/go-openai"
 )
 
@@ -55,6 +56,27 @@ func NewOpenAILLM(baseUrl, model, apiKey string) *OpenAILLM {
 	}
 }
 
+// NewOpenAIClientWithCredentials creates an OpenAI-compatible client that
+// resolves its API key from provider under the given name on every request,
+// refreshing it when the server rejects a rotated key.
+func NewOpenAIClientWithCredentials(baseUrl string, provider credentials.Provider, name string) *openai.Client {
+	config := openai.DefaultConfig("")
+	if baseUrl != "" {
+		config.BaseURL = baseUrl
+	}
+	config.HTTPClient = credentials.NewHTTPClient(nil, provider, name, credentials.BearerAuth)
+	return openai.NewClientWithConfig(config)
+}
+
+// NewOpenAILLMWithCredentials creates an OpenAI LLM whose API key is
+// resolved from provider under "OPENAI_API_KEY".
+func NewOpenAILLMWithCredentials(baseUrl, model string, provider credentials.Provider) *OpenAILLM {
+	if baseUrl == "" {
+		baseUrl = OpenAI_API_URL_v1
+	}
+	return NewOpenAILLMWithClient(NewOpenAIClientWithCredentials(baseUrl, provider, "OPENAI_API_KEY"), model)
+}
+
 func NewOpenAILLMWithClient(client *openai.Client, model string) *OpenAILLM {
 	// Default to gpt-3.5-turbo if not specified
 	if model == "" {
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/aqua777/go-llamaindex/credentials"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -94,6 +95,63 @@ func TestAnthropicLLM(t *testing.T) {
 		assert.Equal(t, "Hello, I'm Claude!", result)
 	})
 
+	t.Run("Complete with credentials provider", func(t *testing.T) {
+		var keys []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys = append(keys, r.Header.Get("x-api-key"))
+			json.NewEncoder(w).Encode(anthropicResponse{
+				Content: []anthropicContent{{Type: "text", Text: "ok"}},
+			})
+		}))
+		defer server.Close()
+
+		provider := credentials.NewStaticProvider(map[string]string{"ANTHROPIC_API_KEY": "key-1"})
+		llm := NewAnthropicLLM(
+			WithAnthropicAPIKey("static-key"),
+			WithAnthropicBaseURL(server.URL),
+			WithAnthropicCredentials(provider),
+		)
+
+		_, err := llm.Complete(context.Background(), "Hello")
+		require.NoError(t, err)
+		provider.Set("ANTHROPIC_API_KEY", "key-2")
+		_, err = llm.Complete(context.Background(), "Hello")
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"key-1", "key-2"}, keys)
+	})
+
+	t.Run("Complete refreshes a rejected key", func(t *testing.T) {
+		var keys []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys = append(keys, r.Header.Get("x-api-key"))
+			if r.Header.Get("x-api-key") != "new-key" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(anthropicResponse{
+				Content: []anthropicContent{{Type: "text", Text: "ok"}},
+			})
+		}))
+		defer server.Close()
+
+		backend := credentials.NewStaticProvider(map[string]string{"ANTHROPIC_API_KEY": "old-key"})
+		cached := credentials.NewCachingProvider(backend, 0)
+		_, err := cached.Get(context.Background(), "ANTHROPIC_API_KEY")
+		require.NoError(t, err)
+		backend.Set("ANTHROPIC_API_KEY", "new-key")
+
+		llm := NewAnthropicLLM(
+			WithAnthropicBaseURL(server.URL),
+			WithAnthropicCredentials(cached),
+		)
+
+		result, err := llm.Complete(context.Background(), "Hello")
+		require.NoError(t, err)
+		assert.Equal(t, "ok", result)
+		assert.Equal(t, []string{"old-key", "new-key"}, keys)
+	})
+
 	t.Run("Chat with mock server", func(t *testing.T) {
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			var req anthropicRequest
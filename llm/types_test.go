@@ -1,7 +1,9 @@
 package llm
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -250,3 +252,94 @@ func TestToolChoice(t *testing.T) {
 	fn := specific["function"].(map[string]interface{})
 	assert.Equal(t, "get_weather", fn["name"])
 }
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := NewConcurrencyLimiter(WithModelConcurrency("gpt", 1))
+	tenantA := ContextWithTenant(context.Background(), "a")
+	tenantB := ContextWithTenant(context.Background(), "b")
+	assert.Equal(t, "a", TenantFromContext(tenantA))
+
+	queued := func(tenant string) int {
+		for _, s := range limiter.Stats() {
+			if s.Tenant == tenant {
+				return s.Queued
+			}
+		}
+		return 0
+	}
+	waitQueued := func(tenant string, n int) {
+		require.Eventually(t, func() bool { return queued(tenant) == n }, time.Second, time.Millisecond)
+	}
+
+	release, err := limiter.Acquire(tenantA, "gpt")
+	require.NoError(t, err)
+
+	granted := make(chan string, 3)
+	releases := make(chan func(), 3)
+	acquire := func(ctx context.Context, name string) {
+		r, err := limiter.Acquire(ctx, "gpt")
+		if err == nil {
+			granted <- name
+			releases <- r
+		}
+	}
+	go acquire(tenantA, "a1")
+	waitQueued("a", 1)
+	go acquire(tenantA, "a2")
+	waitQueued("a", 2)
+	go acquire(tenantB, "b1")
+	waitQueued("b", 1)
+
+	// Slots alternate between tenants rather than following arrival order.
+	release()
+	release() // releasing twice is a no-op
+	assert.Equal(t, "a1", <-granted)
+	(<-releases)()
+	assert.Equal(t, "b1", <-granted)
+	(<-releases)()
+	assert.Equal(t, "a2", <-granted)
+
+	// A request whose context ends while queued gives up.
+	ctx, cancel := context.WithTimeout(tenantB, 10*time.Millisecond)
+	defer cancel()
+	_, err = limiter.Acquire(ctx, "gpt")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	(<-releases)()
+
+	stats := limiter.Stats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, LimiterStats{Model: "gpt", Tenant: "a", Acquired: 3, TotalWait: stats[0].TotalWait, MaxWait: stats[0].MaxWait}, stats[0])
+	assert.Equal(t, uint64(1), stats[1].Cancelled)
+	assert.Equal(t, 0, stats[1].InFlight)
+	assert.Equal(t, 0, stats[1].Queued)
+
+	// Other models are unlimited by default.
+	other, err := limiter.Acquire(tenantA, "claude")
+	require.NoError(t, err)
+	other()
+}
+
+func TestLimitedLLM(t *testing.T) {
+	limiter := NewConcurrencyLimiter(WithTenantConcurrency("a", 1))
+	limited := NewLimitedLLM(NewMockLLM("hello"), limiter, WithLimitedLLMModel("mock"))
+	ctx := ContextWithTenant(context.Background(), "a")
+
+	response, err := limited.Complete(ctx, "hi")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", response)
+
+	stream, err := limited.Stream(ctx, "hi")
+	require.NoError(t, err)
+	for range stream {
+	}
+
+	// The stream's slot is released once it is drained.
+	_, err = limited.Chat(ctx, []ChatMessage{NewUserMessage("hi")})
+	require.NoError(t, err)
+
+	stats := limiter.Stats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, "mock", stats[0].Model)
+	assert.Equal(t, uint64(3), stats[0].Acquired)
+	assert.Equal(t, 0, stats[0].InFlight)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/storage/chatstore"
+	"github.com/aqua777/go-llamaindex/textsplitter"
 )
 
 const (
@@ -85,6 +86,12 @@ func NewChatMemoryBufferFromDefaults(
 		}
 	}
 
+	// Count tokens with the LLM's own tokenizer when it provides one;
+	// an explicit WithTokenizer option still takes precedence.
+	if counter, ok := llmModel.(textsplitter.TokenCounter); ok && counter != nil {
+		opts = append([]ChatMemoryBufferOption{WithTokenizer(counter.CountTokens)}, opts...)
+	}
+
 	m := NewChatMemoryBuffer(append(opts, WithTokenLimit(tokenLimit))...)
 
 	// Set initial chat history
@@ -109,6 +116,10 @@ func (m *ChatMemoryBuffer) Get(ctx context.Context, input string) ([]llm.ChatMes
 }
 
 // GetWithInitialTokenCount retrieves chat history accounting for initial tokens.
+// System messages are always kept and placed first. The remaining history is
+// trimmed from the oldest end one turn at a time, so the returned window
+// starts with a user message and never splits a user message from the
+// assistant and tool messages that answered it.
 func (m *ChatMemoryBuffer) GetWithInitialTokenCount(ctx context.Context, input string, initialTokenCount int) ([]llm.ChatMessage, error) {
 	chatHistory, err := m.GetAll(ctx)
 	if err != nil {
@@ -123,34 +134,38 @@ func (m *ChatMemoryBuffer) GetWithInitialTokenCount(ctx context.Context, input s
 		return chatHistory, nil
 	}
 
-	messageCount := len(chatHistory)
-	curMessages := chatHistory[len(chatHistory)-messageCount:]
-	tokenCount := m.tokenCountForMessages(curMessages) + initialTokenCount
+	var systemMessages, conversation []llm.ChatMessage
+	for _, msg := range chatHistory {
+		if msg.Role == llm.MessageRoleSystem {
+			systemMessages = append(systemMessages, msg)
+		} else {
+			conversation = append(conversation, msg)
+		}
+	}
 
-	// Trim messages until we're under the token limit
-	for tokenCount > m.tokenLimit && messageCount > 1 {
-		messageCount--
+	fixedTokens := m.tokenCountForMessages(systemMessages) + initialTokenCount
+	if fixedTokens > m.tokenLimit {
+		return []llm.ChatMessage{}, nil
+	}
 
-		// Skip assistant and tool messages at the start
-		for messageCount > 0 && (chatHistory[len(chatHistory)-messageCount].Role == llm.MessageRoleAssistant ||
-			chatHistory[len(chatHistory)-messageCount].Role == llm.MessageRoleTool) {
-			messageCount--
-		}
+	start := 0
+	tokenCount := m.tokenCountForMessages(conversation) + fixedTokens
 
-		if messageCount <= 0 {
-			break
-		}
+	// Drop the oldest turn until we're under the token limit
+	for tokenCount > m.tokenLimit && start < len(conversation) {
+		start++
 
-		curMessages = chatHistory[len(chatHistory)-messageCount:]
-		tokenCount = m.tokenCountForMessages(curMessages) + initialTokenCount
-	}
+		// Skip the assistant and tool messages of the dropped turn
+		for start < len(conversation) && conversation[start].Role != llm.MessageRoleUser {
+			start++
+		}
 
-	// If still over limit or no messages, return empty
-	if tokenCount > m.tokenLimit || messageCount <= 0 {
-		return []llm.ChatMessage{}, nil
+		tokenCount = m.tokenCountForMessages(conversation[start:]) + fixedTokens
 	}
 
-	return chatHistory[len(chatHistory)-messageCount:], nil
+	result := make([]llm.ChatMessage, 0, len(systemMessages)+len(conversation)-start)
+	result = append(result, systemMessages...)
+	return append(result, conversation[start:]...), nil
 }
 
 // tokenCountForMessages counts tokens in a list of messages.
@@ -167,5 +182,13 @@ func (m *ChatMemoryBuffer) tokenCountForMessages(messages []llm.ChatMessage) int
 	return m.tokenizerFn(totalContent)
 }
 
+// TokenizerFromTextSplitter adapts a textsplitter.Tokenizer, such as a
+// tiktoken tokenizer for the configured model, into a TokenizerFunc.
+func TokenizerFromTextSplitter(tokenizer textsplitter.Tokenizer) TokenizerFunc {
+	return func(text string) int {
+		return len(tokenizer.Encode(text))
+	}
+}
+
 // Ensure ChatMemoryBuffer implements Memory.
 var _ Memory = (*ChatMemoryBuffer)(nil)
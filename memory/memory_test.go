@@ -2,11 +2,15 @@ package memory
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/rag/store"
 	"github.com/aqua777/go-llamaindex/storage/chatstore"
+	"github.com/aqua777/go-llamaindex/textsplitter"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -222,6 +226,55 @@ func TestChatMemoryBuffer(t *testing.T) {
 		require.NoError(t, err)
 		assert.Len(t, messages, 1)
 	})
+
+	t.Run("Trimming keeps system messages and whole turns", func(t *testing.T) {
+		mem := NewChatMemoryBuffer(
+			WithTokenLimit(9),
+			WithTokenizer(func(text string) int { return len(strings.Fields(text)) }),
+		)
+
+		msgs := []llm.ChatMessage{
+			{Role: llm.MessageRoleSystem, Content: "be brief"},
+			{Role: llm.MessageRoleUser, Content: "first question"},
+			{Role: llm.MessageRoleAssistant, Content: "first answer"},
+			{Role: llm.MessageRoleTool, Content: "tool output"},
+			{Role: llm.MessageRoleUser, Content: "second question"},
+			{Role: llm.MessageRoleAssistant, Content: "second answer"},
+		}
+		require.NoError(t, mem.PutMessages(ctx, msgs))
+
+		messages, err := mem.Get(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, messages, 3)
+		assert.Equal(t, llm.MessageRoleSystem, messages[0].Role)
+		assert.Equal(t, "second question", messages[1].Content)
+		assert.Equal(t, "second answer", messages[2].Content)
+
+		// Only the system message fits.
+		messages, err = mem.GetWithInitialTokenCount(ctx, "", 4)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, llm.MessageRoleSystem, messages[0].Role)
+
+		// Not even the system message fits.
+		messages, err = mem.GetWithInitialTokenCount(ctx, "", 8)
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("TokenizerFromTextSplitter", func(t *testing.T) {
+		count := TokenizerFromTextSplitter(textsplitter.NewSimpleTokenizer())
+		assert.Equal(t, 3, count("one two three"))
+	})
+}
+
+// countingLLM is a mock LLM that provides its own token counter.
+type countingLLM struct {
+	*MockLLM
+}
+
+func (m *countingLLM) CountTokens(text string) int {
+	return len(text)
 }
 
 // TestChatMemoryBufferFromDefaults tests NewChatMemoryBufferFromDefaults.
@@ -246,6 +299,21 @@ func TestChatMemoryBufferFromDefaults(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, 500, mem.TokenLimit())
 	})
+
+	t.Run("Uses the LLM token counter", func(t *testing.T) {
+		chatHistory := []llm.ChatMessage{
+			{Role: llm.MessageRoleUser, Content: "a much longer first message"},
+			{Role: llm.MessageRoleUser, Content: "short"},
+		}
+
+		mem, err := NewChatMemoryBufferFromDefaults(chatHistory, &countingLLM{NewMockLLM("")}, 10)
+		require.NoError(t, err)
+
+		messages, err := mem.Get(context.Background(), "")
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, "short", messages[0].Content)
+	})
 }
 
 // TestChatSummaryMemoryBuffer tests the ChatSummaryMemoryBuffer.
@@ -311,6 +379,121 @@ func TestChatSummaryMemoryBuffer(t *testing.T) {
 	})
 }
 
+// promptRecordingLLM is a mock LLM that records the chat messages it receives.
+type promptRecordingLLM struct {
+	*MockLLM
+	calls [][]llm.ChatMessage
+}
+
+func (m *promptRecordingLLM) Chat(ctx context.Context, messages []llm.ChatMessage) (string, error) {
+	m.calls = append(m.calls, messages)
+	return m.MockLLM.Chat(ctx, messages)
+}
+
+// TestSummaryMemory tests the SummaryMemory.
+func TestSummaryMemory(t *testing.T) {
+	ctx := context.Background()
+	words := func(text string) int { return len(strings.Fields(text)) }
+
+	turn := func(n int) []llm.ChatMessage {
+		return []llm.ChatMessage{
+			{Role: llm.MessageRoleUser, Content: fmt.Sprintf("question %d", n)},
+			{Role: llm.MessageRoleAssistant, Content: fmt.Sprintf("answer %d", n)},
+		}
+	}
+
+	t.Run("Within budget is unchanged", func(t *testing.T) {
+		l := &promptRecordingLLM{MockLLM: NewMockLLM("summary")}
+		mem := NewSummaryMemory(l, WithSummaryMemoryTokenLimit(100), WithSummaryMemoryTokenizer(words))
+		require.NoError(t, mem.PutMessages(ctx, append(turn(1), turn(2)...)))
+
+		messages, err := mem.Get(ctx, "")
+		require.NoError(t, err)
+		assert.Len(t, messages, 4)
+		assert.Empty(t, l.calls)
+	})
+
+	t.Run("Condenses older turns and keeps recent turns", func(t *testing.T) {
+		l := &promptRecordingLLM{MockLLM: NewMockLLM("user asked two questions")}
+		mem := NewSummaryMemory(l,
+			WithSummaryMemoryTokenLimit(10),
+			WithSummaryMemoryKeepTurns(1),
+			WithSummaryMemoryTokenizer(words),
+		)
+		require.NoError(t, mem.Put(ctx, llm.ChatMessage{Role: llm.MessageRoleSystem, Content: "be helpful"}))
+		for i := 1; i <= 3; i++ {
+			require.NoError(t, mem.PutMessages(ctx, turn(i)))
+		}
+
+		messages, err := mem.Get(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, messages, 4)
+		assert.Equal(t, "be helpful", messages[0].Content)
+		assert.Equal(t, SummaryMessageName, messages[1].Name)
+		assert.Equal(t, "user asked two questions", messages[1].Content)
+		assert.Equal(t, "question 3", messages[2].Content)
+		assert.Equal(t, "answer 3", messages[3].Content)
+
+		require.Len(t, l.calls, 1)
+		prompt := l.calls[0][1].Content
+		assert.Contains(t, prompt, "question 1")
+		assert.Contains(t, prompt, "answer 2")
+		assert.NotContains(t, prompt, "question 3")
+
+		summary, err := mem.Summary(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "user asked two questions", summary)
+	})
+
+	t.Run("Rolls the existing summary forward", func(t *testing.T) {
+		l := &promptRecordingLLM{MockLLM: NewMockLLM("first summary")}
+		mem := NewSummaryMemory(l,
+			WithSummaryMemoryTokenLimit(6),
+			WithSummaryMemoryKeepTurns(1),
+			WithSummaryMemoryTokenizer(words),
+		)
+		require.NoError(t, mem.PutMessages(ctx, append(turn(1), turn(2)...)))
+		_, err := mem.Get(ctx, "")
+		require.NoError(t, err)
+
+		l.response = "second summary"
+		require.NoError(t, mem.PutMessages(ctx, turn(3)))
+		condensed, err := mem.Condense(ctx)
+		require.NoError(t, err)
+		assert.True(t, condensed)
+
+		require.Len(t, l.calls, 2)
+		prompt := l.calls[1][1].Content
+		assert.Contains(t, prompt, "Existing summary:\nfirst summary")
+		assert.Contains(t, prompt, "question 2")
+
+		messages, err := mem.GetAll(ctx)
+		require.NoError(t, err)
+		require.Len(t, messages, 3)
+		assert.Equal(t, "second summary", messages[0].Content)
+	})
+
+	t.Run("LLM errors are returned", func(t *testing.T) {
+		mem := NewSummaryMemory(&failingChatLLM{MockLLM: NewMockLLM("")},
+			WithSummaryMemoryTokenLimit(1),
+			WithSummaryMemoryKeepTurns(1),
+		)
+		require.NoError(t, mem.PutMessages(ctx, append(turn(1), turn(2)...)))
+
+		_, err := mem.Get(ctx, "")
+		assert.Error(t, err)
+	})
+}
+
+// failingChatLLM is a mock LLM whose Chat always fails.
+type failingChatLLM struct {
+	*MockLLM
+}
+
+func (m *failingChatLLM) Chat(ctx context.Context, messages []llm.ChatMessage) (string, error) {
+	return "", errors.New("llm unavailable")
+}
+
 // TestChatSummaryMemoryBufferFromDefaults tests NewChatSummaryMemoryBufferFromDefaults.
 func TestChatSummaryMemoryBufferFromDefaults(t *testing.T) {
 	t.Run("With chat history", func(t *testing.T) {
@@ -354,11 +537,55 @@ func TestVectorMemory(t *testing.T) {
 		err = mem.Put(ctx, llm.ChatMessage{Role: llm.MessageRoleAssistant, Content: "I'm doing well!"})
 		require.NoError(t, err)
 
-		// Query for relevant messages - the Get should not error
-		// Note: The actual retrieval of messages depends on metadata handling
-		// which may vary based on vector store implementation
-		_, err = mem.Get(ctx, "Hello")
+		messages, err := mem.Get(ctx, "Hello")
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		assert.Equal(t, llm.MessageRoleUser, messages[0].Role)
+		assert.Equal(t, "I'm doing well!", messages[1].Content)
+	})
+
+	t.Run("Recalls the most relevant batches in conversation order", func(t *testing.T) {
+		embedModel := NewMockEmbeddingModel()
+		embedModel.embeddings["My dog is called Rex Good name"] = []float64{1, 0, 0}
+		embedModel.embeddings["I live in Paris Nice city"] = []float64{0, 1, 0}
+		embedModel.embeddings["I like tennis Great sport"] = []float64{0.9, 0, 0.1}
+		embedModel.embeddings["what pets and hobbies do I have?"] = []float64{1, 0, 0.05}
+
+		mem := NewVectorMemory(
+			WithVectorMemoryEmbedModel(embedModel),
+			WithRetrieverTopK(2),
+		)
+		require.NoError(t, mem.PutMessages(ctx, []llm.ChatMessage{
+			{Role: llm.MessageRoleUser, Content: "My dog is called Rex"},
+			{Role: llm.MessageRoleAssistant, Content: "Good name"},
+			{Role: llm.MessageRoleUser, Content: "I live in Paris"},
+			{Role: llm.MessageRoleAssistant, Content: "Nice city"},
+			{Role: llm.MessageRoleUser, Content: "I like tennis"},
+			{Role: llm.MessageRoleAssistant, Content: "Great sport"},
+		}))
+
+		messages, err := mem.Get(ctx, "what pets and hobbies do I have?")
+		require.NoError(t, err)
+		require.Len(t, messages, 4)
+		assert.Equal(t, "My dog is called Rex", messages[0].Content)
+		assert.Equal(t, "Good name", messages[1].Content)
+		assert.Equal(t, "I like tennis", messages[2].Content)
+		assert.Equal(t, "Great sport", messages[3].Content)
+	})
+
+	t.Run("Sessions sharing a store are isolated", func(t *testing.T) {
+		embedModel := NewMockEmbeddingModel()
+		vs := store.NewSimpleVectorStore()
+		alice := NewVectorMemoryFromDefaults(vs, embedModel, WithVectorMemorySessionID("alice"))
+		bob := NewVectorMemoryFromDefaults(vs, embedModel, WithVectorMemorySessionID("bob"))
+
+		require.NoError(t, alice.Put(ctx, llm.ChatMessage{Role: llm.MessageRoleUser, Content: "Alice's secret"}))
+		require.NoError(t, bob.Put(ctx, llm.ChatMessage{Role: llm.MessageRoleUser, Content: "Bob's secret"}))
+
+		messages, err := bob.Get(ctx, "secret")
 		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, "Bob's secret", messages[0].Content)
 	})
 
 	t.Run("Get with empty input", func(t *testing.T) {
@@ -385,6 +612,32 @@ func TestVectorMemory(t *testing.T) {
 
 		err = mem.Reset(ctx)
 		require.NoError(t, err)
+
+		messages, err := mem.Get(ctx, "Hello")
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("Reset deletes the whole session", func(t *testing.T) {
+		embedModel := NewMockEmbeddingModel()
+		vs := store.NewSimpleVectorStore()
+		earlier := NewVectorMemoryFromDefaults(vs, embedModel, WithVectorMemorySessionID("alice"))
+		bob := NewVectorMemoryFromDefaults(vs, embedModel, WithVectorMemorySessionID("bob"))
+		require.NoError(t, earlier.Put(ctx, llm.ChatMessage{Role: llm.MessageRoleUser, Content: "Alice's secret"}))
+		require.NoError(t, bob.Put(ctx, llm.ChatMessage{Role: llm.MessageRoleUser, Content: "Bob's secret"}))
+
+		// A new instance for the same session, e.g. after a restart.
+		alice := NewVectorMemoryFromDefaults(vs, embedModel, WithVectorMemorySessionID("alice"))
+		require.NoError(t, alice.Reset(ctx))
+
+		messages, err := alice.Get(ctx, "secret")
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+
+		messages, err = bob.Get(ctx, "secret")
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, "Bob's secret", messages[0].Content)
 	})
 
 	t.Run("BatchByUserMessage", func(t *testing.T) {
@@ -484,6 +737,10 @@ func TestMemoryInterface(t *testing.T) {
 		var _ Memory = NewChatSummaryMemoryBuffer()
 	})
 
+	t.Run("SummaryMemory implements Memory", func(t *testing.T) {
+		var _ Memory = NewSummaryMemory(NewMockLLM(""))
+	})
+
 	t.Run("VectorMemory implements Memory", func(t *testing.T) {
 		var _ Memory = NewVectorMemory()
 	})
@@ -0,0 +1,258 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/storage/chatstore"
+)
+
+const (
+	// DefaultSummaryMemoryKeepTurns is the default number of recent turns
+	// kept verbatim by SummaryMemory.
+	DefaultSummaryMemoryKeepTurns = 3
+	// SummaryMessageName marks the rolling summary message in the chat store.
+	SummaryMessageName = "conversation_summary"
+	// DefaultRollingSummaryPrompt is the default prompt for updating the
+	// rolling summary.
+	DefaultRollingSummaryPrompt = "Progressively summarize the conversation between the user and assistant. Extend the existing summary with the new lines of conversation, keeping facts, decisions and open questions. Return only the updated summary."
+)
+
+// SummaryMemory keeps the most recent turns of a conversation verbatim and
+// condenses older turns into a rolling summary with an LLM. When the stored
+// history exceeds the token limit, every turn but the most recent ones is
+// folded into the summary, which is stored as a system message at the
+// start of the history. A turn is a user message with the assistant and tool
+// messages that follow it. Other system messages are kept as they are.
+type SummaryMemory struct {
+	*BaseMemory
+	llm             llm.LLM
+	tokenLimit      int
+	keepTurns       int
+	tokenizerFn     TokenizerFunc
+	summarizePrompt string
+	mu              sync.Mutex
+}
+
+// SummaryMemoryOption configures a SummaryMemory.
+type SummaryMemoryOption func(*SummaryMemory)
+
+// WithSummaryMemoryTokenLimit sets the token budget that triggers
+// summarization.
+func WithSummaryMemoryTokenLimit(limit int) SummaryMemoryOption {
+	return func(m *SummaryMemory) {
+		m.tokenLimit = limit
+	}
+}
+
+// WithSummaryMemoryKeepTurns sets how many recent turns are kept verbatim.
+func WithSummaryMemoryKeepTurns(turns int) SummaryMemoryOption {
+	return func(m *SummaryMemory) {
+		m.keepTurns = turns
+	}
+}
+
+// WithSummaryMemoryTokenizer sets the tokenizer function.
+func WithSummaryMemoryTokenizer(fn TokenizerFunc) SummaryMemoryOption {
+	return func(m *SummaryMemory) {
+		m.tokenizerFn = fn
+	}
+}
+
+// WithSummaryMemoryPrompt sets the summarization prompt.
+func WithSummaryMemoryPrompt(prompt string) SummaryMemoryOption {
+	return func(m *SummaryMemory) {
+		m.summarizePrompt = prompt
+	}
+}
+
+// WithSummaryMemoryChatStore sets the chat store.
+func WithSummaryMemoryChatStore(store chatstore.ChatStore) SummaryMemoryOption {
+	return func(m *SummaryMemory) {
+		m.chatStore = store
+	}
+}
+
+// WithSummaryMemoryChatStoreKey sets the chat store key.
+func WithSummaryMemoryChatStoreKey(key string) SummaryMemoryOption {
+	return func(m *SummaryMemory) {
+		m.chatStoreKey = key
+	}
+}
+
+// NewSummaryMemory creates a new SummaryMemory that summarizes with l.
+func NewSummaryMemory(l llm.LLM, opts ...SummaryMemoryOption) *SummaryMemory {
+	m := &SummaryMemory{
+		BaseMemory:      NewBaseMemory(),
+		llm:             l,
+		tokenLimit:      DefaultSummaryTokenLimit,
+		keepTurns:       DefaultSummaryMemoryKeepTurns,
+		tokenizerFn:     DefaultTokenizer,
+		summarizePrompt: DefaultRollingSummaryPrompt,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// TokenLimit returns the token limit.
+func (m *SummaryMemory) TokenLimit() int {
+	return m.tokenLimit
+}
+
+// Get returns the chat history, condensing older turns first if the history
+// exceeds the token limit. The recent turns are returned verbatim even if
+// they alone exceed the limit.
+func (m *SummaryMemory) Get(ctx context.Context, input string) ([]llm.ChatMessage, error) {
+	if _, err := m.Condense(ctx); err != nil {
+		return nil, err
+	}
+	return m.GetAll(ctx)
+}
+
+// Summary returns the current rolling summary, or an empty string if no
+// turns have been summarized yet.
+func (m *SummaryMemory) Summary(ctx context.Context) (string, error) {
+	history, err := m.GetAll(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, msg := range history {
+		if isSummaryMessage(msg) {
+			return msg.Content, nil
+		}
+	}
+	return "", nil
+}
+
+// Condense folds all but the most recent turns into the rolling summary if
+// the history exceeds the token limit. It reports whether the history was
+// condensed.
+func (m *SummaryMemory) Condense(ctx context.Context) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	history, err := m.GetAll(ctx)
+	if err != nil {
+		return false, err
+	}
+	if m.tokenCountForMessages(history) <= m.tokenLimit {
+		return false, nil
+	}
+
+	var summary string
+	var systemMessages, conversation []llm.ChatMessage
+	for _, msg := range history {
+		switch {
+		case isSummaryMessage(msg):
+			summary = msg.Content
+		case msg.Role == llm.MessageRoleSystem:
+			systemMessages = append(systemMessages, msg)
+		default:
+			conversation = append(conversation, msg)
+		}
+	}
+
+	turns := splitTurns(conversation)
+	keep := m.keepTurns
+	if keep < 0 {
+		keep = 0
+	}
+	if len(turns) <= keep {
+		return false, nil
+	}
+
+	var older, recent []llm.ChatMessage
+	for i, turn := range turns {
+		if i < len(turns)-keep {
+			older = append(older, turn...)
+		} else {
+			recent = append(recent, turn...)
+		}
+	}
+
+	summary, err = m.summarize(ctx, summary, older)
+	if err != nil {
+		return false, err
+	}
+
+	updated := make([]llm.ChatMessage, 0, len(systemMessages)+1+len(recent))
+	updated = append(updated, systemMessages...)
+	updated = append(updated, llm.ChatMessage{
+		Role:    llm.MessageRoleSystem,
+		Name:    SummaryMessageName,
+		Content: summary,
+	})
+	updated = append(updated, recent...)
+	if err := m.Set(ctx, updated); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// summarize extends the existing summary with the given messages.
+func (m *SummaryMemory) summarize(ctx context.Context, summary string, messages []llm.ChatMessage) (string, error) {
+	var sb strings.Builder
+	if summary != "" {
+		sb.WriteString("Existing summary:\n")
+		sb.WriteString(summary)
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString("New lines of conversation:\n")
+	for _, msg := range messages {
+		sb.WriteString(string(msg.Role))
+		sb.WriteString(": ")
+		sb.WriteString(msg.Content)
+		sb.WriteString("\n\n")
+	}
+
+	response, err := m.llm.Chat(ctx, []llm.ChatMessage{
+		{Role: llm.MessageRoleSystem, Content: m.summarizePrompt},
+		{Role: llm.MessageRoleUser, Content: sb.String()},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize chat history: %w", err)
+	}
+	return strings.TrimSpace(response), nil
+}
+
+// tokenCountForMessages counts tokens in a list of messages.
+func (m *SummaryMemory) tokenCountForMessages(messages []llm.ChatMessage) int {
+	if len(messages) == 0 {
+		return 0
+	}
+
+	var totalContent string
+	for _, msg := range messages {
+		totalContent += " " + msg.Content
+	}
+
+	return m.tokenizerFn(totalContent)
+}
+
+// isSummaryMessage reports whether msg is the rolling summary message.
+func isSummaryMessage(msg llm.ChatMessage) bool {
+	return msg.Role == llm.MessageRoleSystem && msg.Name == SummaryMessageName
+}
+
+// splitTurns groups messages into turns, each starting at a user message.
+// Messages before the first user message form their own turn.
+func splitTurns(messages []llm.ChatMessage) [][]llm.ChatMessage {
+	var turns [][]llm.ChatMessage
+	for _, msg := range messages {
+		if msg.Role == llm.MessageRoleUser || len(turns) == 0 {
+			turns = append(turns, nil)
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], msg)
+	}
+	return turns
+}
+
+// Ensure SummaryMemory implements Memory.
+var _ Memory = (*SummaryMemory)(nil)
@@ -4,6 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/aqua777/go-llamaindex/embedding"
 	"github.com/aqua777/go-llamaindex/llm"
@@ -12,14 +15,31 @@ import (
 	"github.com/google/uuid"
 )
 
-// VectorMemory is memory backed by a vector store.
+// Metadata keys of the nodes stored by VectorMemory.
+const (
+	// VectorMemoryMessagesKey holds the batch's messages.
+	VectorMemoryMessagesKey = "sub_dicts"
+	// VectorMemorySessionKey holds the session ID.
+	VectorMemorySessionKey = "session_id"
+	// VectorMemoryCreatedAtKey holds the batch's creation time in Unix
+	// nanoseconds, used to return recalled messages in conversation order.
+	VectorMemoryCreatedAtKey = "created_at"
+)
+
+// VectorMemory is long-term memory backed by a vector store. Each message,
+// or each user message with the replies that follow it, is embedded and
+// stored as a node; Get recalls the batches most similar to the current
+// input and returns their messages in conversation order.
 type VectorMemory struct {
 	vectorStore        store.VectorStore
 	embedModel         embedding.EmbeddingModel
 	retrieverTopK      int
 	batchByUserMessage bool
+	sessionID          string
 	curBatchNode       *schema.Node
 	curBatchMessages   []llm.ChatMessage
+	nodeIDs            []string
+	mu                 sync.Mutex
 }
 
 // VectorMemoryOption configures a VectorMemory.
@@ -53,6 +73,15 @@ func WithBatchByUserMessage(batch bool) VectorMemoryOption {
 	}
 }
 
+// WithVectorMemorySessionID scopes the memory to a session, so several
+// conversations can share one vector store without recalling each other's
+// messages.
+func WithVectorMemorySessionID(id string) VectorMemoryOption {
+	return func(m *VectorMemory) {
+		m.sessionID = id
+	}
+}
+
 // NewVectorMemory creates a new VectorMemory.
 func NewVectorMemory(opts ...VectorMemoryOption) *VectorMemory {
 	m := &VectorMemory{
@@ -91,12 +120,20 @@ func (m *VectorMemory) newBatchNode() *schema.Node {
 	node := schema.NewTextNode("")
 	node.ID = uuid.New().String()
 	node.Metadata = map[string]interface{}{
-		"sub_dicts": []map[string]interface{}{},
+		VectorMemoryMessagesKey:  []map[string]interface{}{},
+		VectorMemoryCreatedAtKey: time.Now().UnixNano(),
+	}
+	if m.sessionID != "" {
+		node.Metadata[VectorMemorySessionKey] = m.sessionID
 	}
+	// Point the node at itself so stores that delete by reference document
+	// can remove it.
+	node.Relationships.SetSource(schema.RelatedNodeInfo{NodeID: node.ID})
 	return node
 }
 
-// Get retrieves chat history relevant to the input query.
+// Get retrieves the messages relevant to the input query, in conversation
+// order.
 func (m *VectorMemory) Get(ctx context.Context, input string) ([]llm.ChatMessage, error) {
 	if input == "" {
 		return []llm.ChatMessage{}, nil
@@ -114,25 +151,22 @@ func (m *VectorMemory) Get(ctx context.Context, input string) ([]llm.ChatMessage
 
 	// Query vector store
 	vsQuery := schema.NewVectorStoreQuery(queryEmbedding, m.retrieverTopK)
+	if m.sessionID != "" {
+		vsQuery.Filters = schema.NewMetadataFilters(schema.NewMetadataFilter(VectorMemorySessionKey, m.sessionID))
+	}
 	results, err := m.vectorStore.Query(ctx, *vsQuery)
 	if err != nil {
 		return nil, err
 	}
 
+	sort.SliceStable(results, func(i, j int) bool {
+		return createdAt(results[i].Node) < createdAt(results[j].Node)
+	})
+
 	// Extract messages from results
 	var messages []llm.ChatMessage
 	for _, result := range results {
-		subDicts, ok := result.Node.Metadata["sub_dicts"].([]interface{})
-		if !ok {
-			continue
-		}
-
-		for _, subDict := range subDicts {
-			msgMap, ok := subDict.(map[string]interface{})
-			if !ok {
-				continue
-			}
-
+		for _, msgMap := range messageMaps(result.Node.Metadata[VectorMemoryMessagesKey]) {
 			msg, err := chatMessageFromMap(msgMap)
 			if err != nil {
 				continue
@@ -144,6 +178,37 @@ func (m *VectorMemory) Get(ctx context.Context, input string) ([]llm.ChatMessage
 	return messages, nil
 }
 
+// messageMaps reads the stored messages, which are []interface{} after a
+// JSON round trip.
+func messageMaps(value interface{}) []map[string]interface{} {
+	switch v := value.(type) {
+	case []map[string]interface{}:
+		return v
+	case []interface{}:
+		maps := make([]map[string]interface{}, 0, len(v))
+		for _, item := range v {
+			if msgMap, ok := item.(map[string]interface{}); ok {
+				maps = append(maps, msgMap)
+			}
+		}
+		return maps
+	}
+	return nil
+}
+
+// createdAt returns a node's creation time, or 0 if it has none.
+func createdAt(node schema.Node) float64 {
+	switch v := node.Metadata[VectorMemoryCreatedAtKey].(type) {
+	case int64:
+		return float64(v)
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
 // GetAll is not supported for vector memory.
 func (m *VectorMemory) GetAll(ctx context.Context) ([]llm.ChatMessage, error) {
 	return nil, fmt.Errorf("vector memory does not support GetAll, can only retrieve based on input")
@@ -151,6 +216,9 @@ func (m *VectorMemory) GetAll(ctx context.Context) ([]llm.ChatMessage, error) {
 
 // Put adds a message to the memory.
 func (m *VectorMemory) Put(ctx context.Context, message llm.ChatMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	// Start new batch on user/system messages if batching is enabled
 	if !m.batchByUserMessage || message.Role == llm.MessageRoleUser || message.Role == llm.MessageRoleSystem {
 		// Commit current batch if it has content
@@ -175,9 +243,9 @@ func (m *VectorMemory) Put(ctx context.Context, message llm.ChatMessage) error {
 	}
 
 	// Update metadata
-	subDicts := m.curBatchNode.Metadata["sub_dicts"].([]map[string]interface{})
+	subDicts := m.curBatchNode.Metadata[VectorMemoryMessagesKey].([]map[string]interface{})
 	subDicts = append(subDicts, msgMap)
-	m.curBatchNode.Metadata["sub_dicts"] = subDicts
+	m.curBatchNode.Metadata[VectorMemoryMessagesKey] = subDicts
 
 	// Commit the updated node
 	return m.commitNode(ctx, true)
@@ -201,10 +269,26 @@ func (m *VectorMemory) Set(ctx context.Context, messages []llm.ChatMessage) erro
 	return m.PutMessages(ctx, messages)
 }
 
-// Reset clears all memory.
+// Reset clears all memory. With a session ID it deletes every node of the
+// session, including those stored by earlier instances of the memory;
+// otherwise it deletes the nodes this memory stored.
 func (m *VectorMemory) Reset(ctx context.Context) error {
-	// Note: This requires the vector store to support clearing
-	// For now, we just reset the current batch
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sessionID != "" {
+		filters := schema.NewMetadataFilters(schema.NewMetadataFilter(VectorMemorySessionKey, m.sessionID))
+		if _, err := m.vectorStore.DeleteByFilter(ctx, filters); err != nil {
+			return fmt.Errorf("failed to delete memory of session %s: %w", m.sessionID, err)
+		}
+	} else {
+		for _, id := range m.nodeIDs {
+			if err := m.vectorStore.Delete(ctx, id); err != nil {
+				return fmt.Errorf("failed to delete memory node %s: %w", id, err)
+			}
+		}
+	}
+	m.nodeIDs = nil
 	m.curBatchNode = m.newBatchNode()
 	m.curBatchMessages = []llm.ChatMessage{}
 	return nil
@@ -233,8 +317,13 @@ func (m *VectorMemory) commitNode(ctx context.Context, overrideLast bool) error
 	}
 
 	// Add node to vector store
-	_, err = m.vectorStore.Add(ctx, []schema.Node{*m.curBatchNode})
-	return err
+	if _, err := m.vectorStore.Add(ctx, []schema.Node{*m.curBatchNode}); err != nil {
+		return err
+	}
+	if len(m.nodeIDs) == 0 || m.nodeIDs[len(m.nodeIDs)-1] != m.curBatchNode.ID {
+		m.nodeIDs = append(m.nodeIDs, m.curBatchNode.ID)
+	}
+	return nil
 }
 
 // chatMessageToMap converts a ChatMessage to a map.
@@ -0,0 +1,289 @@
+package nodeparser
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// Metadata keys set by CSVNodeParser.
+const (
+	// CSVColumnsMetadataKey holds the column names of the file.
+	CSVColumnsMetadataKey = "csv_columns"
+	// CSVRowStartMetadataKey holds the index of the node's first row.
+	CSVRowStartMetadataKey = "csv_row_start"
+	// CSVRowEndMetadataKey holds the index of the node's last row.
+	CSVRowEndMetadataKey = "csv_row_end"
+)
+
+// CSVNodeParser parses CSV documents into row-level or row-group-level
+// nodes. The first record is the header; each row is written as
+// "column: value" lines so the node is readable on its own, and the rows of
+// a group are separated by blank lines. Row indexes count data rows from 0.
+//
+// Rows are grouped either by count with WithRowsPerNode or by the value of a
+// column with WithGroupByColumn. Documents that are not valid CSV are kept as
+// a single text node and reported with an error event.
+type CSVNodeParser struct {
+	*BaseNodeParser
+	rowsPerNode     int
+	groupByColumn   string
+	delimiter       rune
+	textColumns     []string
+	metadataColumns []string
+}
+
+// NewCSVNodeParser creates a new CSVNodeParser with one node per row.
+func NewCSVNodeParser() *CSVNodeParser {
+	return &CSVNodeParser{
+		BaseNodeParser: NewBaseNodeParser(),
+		rowsPerNode:    1,
+		delimiter:      ',',
+	}
+}
+
+// WithRowsPerNode sets how many consecutive rows go into one node.
+func (p *CSVNodeParser) WithRowsPerNode(n int) *CSVNodeParser {
+	if n > 0 {
+		p.rowsPerNode = n
+	}
+	return p
+}
+
+// WithGroupByColumn puts all rows with the same value in column into one
+// node, in order of first appearance, instead of grouping by count.
+func (p *CSVNodeParser) WithGroupByColumn(column string) *CSVNodeParser {
+	p.groupByColumn = column
+	return p
+}
+
+// WithDelimiter sets the field delimiter. Defaults to a comma.
+func (p *CSVNodeParser) WithDelimiter(delimiter rune) *CSVNodeParser {
+	p.delimiter = delimiter
+	return p
+}
+
+// WithTextColumns restricts the node text to these columns. By default all
+// columns are included.
+func (p *CSVNodeParser) WithTextColumns(columns ...string) *CSVNodeParser {
+	p.textColumns = columns
+	return p
+}
+
+// WithMetadataColumns copies these columns into node metadata. For a group of
+// rows a column is copied only if all rows share its value.
+func (p *CSVNodeParser) WithMetadataColumns(columns ...string) *CSVNodeParser {
+	p.metadataColumns = columns
+	return p
+}
+
+// WithIncludeMetadata sets whether to include parent metadata in child nodes.
+func (p *CSVNodeParser) WithIncludeMetadata(include bool) NodeParserWithOptions {
+	p.BaseNodeParser.WithIncludeMetadata(include)
+	return p
+}
+
+// WithIncludePrevNextRel sets whether to establish PREVIOUS/NEXT relationships.
+func (p *CSVNodeParser) WithIncludePrevNextRel(include bool) NodeParserWithOptions {
+	p.BaseNodeParser.WithIncludePrevNextRel(include)
+	return p
+}
+
+// GetNodesFromDocuments parses CSV documents into row nodes.
+func (p *CSVNodeParser) GetNodesFromDocuments(documents []schema.Document) []*schema.Node {
+	var allNodes []*schema.Node
+
+	for _, doc := range documents {
+		p.EmitStart(doc.ID)
+
+		nodes := p.buildCSVNodes(doc.ID, doc.Text, nil, &doc)
+		for _, node := range nodes {
+			node.Metadata["source_doc_id"] = doc.ID
+		}
+
+		allNodes = append(allNodes, nodes...)
+
+		p.EmitComplete(doc.ID, len(nodes))
+	}
+
+	return allNodes
+}
+
+// ParseNodes parses nodes holding CSV into row nodes.
+func (p *CSVNodeParser) ParseNodes(nodes []*schema.Node) []*schema.Node {
+	var allNodes []*schema.Node
+
+	for _, node := range nodes {
+		p.EmitStart(node.ID)
+
+		childNodes := p.buildCSVNodes(node.ID, node.Text, node, nil)
+		for _, childNode := range childNodes {
+			childNode.Metadata["source_node_id"] = node.ID
+		}
+
+		allNodes = append(allNodes, childNodes...)
+
+		p.EmitComplete(node.ID, len(childNodes))
+	}
+
+	return allNodes
+}
+
+// csvRowGroup is a group of rows that becomes one node.
+type csvRowGroup struct {
+	rows    [][]string
+	indexes []int
+}
+
+// buildCSVNodes creates row nodes from CSV text.
+func (p *CSVNodeParser) buildCSVNodes(id, text string, parentNode *schema.Node, parentDoc *schema.Document) []*schema.Node {
+	header, rows, err := p.readCSV(text)
+	if err != nil {
+		p.EmitError(id, fmt.Errorf("invalid CSV: %w", err))
+		return p.BuildNodesFromSplits([]string{text}, parentNode, parentDoc)
+	}
+	if header == nil {
+		return nil
+	}
+
+	columnIndex := make(map[string]int, len(header))
+	for i, name := range header {
+		if _, ok := columnIndex[name]; !ok {
+			columnIndex[name] = i
+		}
+	}
+
+	groups, err := p.groupRows(rows, columnIndex)
+	if err != nil {
+		p.EmitError(id, err)
+		return p.BuildNodesFromSplits([]string{text}, parentNode, parentDoc)
+	}
+
+	textColumns := header
+	if len(p.textColumns) > 0 {
+		textColumns = p.textColumns
+	}
+
+	nodes := make([]*schema.Node, 0, len(groups))
+	for _, group := range groups {
+		blocks := make([]string, 0, len(group.rows))
+		for _, row := range group.rows {
+			var lines []string
+			for _, column := range textColumns {
+				if i, ok := columnIndex[column]; ok && i < len(row) {
+					lines = append(lines, column+": "+row[i])
+				}
+			}
+			blocks = append(blocks, strings.Join(lines, "\n"))
+		}
+
+		node := schema.NewNode()
+		node.ID = p.GenerateID()
+		node.Text = strings.Join(blocks, "\n\n")
+		node.Type = schema.ObjectTypeText
+		node.Metadata[CSVColumnsMetadataKey] = append([]string(nil), header...)
+		node.Metadata[CSVRowStartMetadataKey] = group.indexes[0]
+		node.Metadata[CSVRowEndMetadataKey] = group.indexes[len(group.indexes)-1]
+		for _, column := range p.metadataColumns {
+			if value, ok := sharedColumnValue(group.rows, columnIndex, column); ok {
+				node.Metadata[column] = value
+			}
+		}
+		node.Hash = node.GenerateHash()
+		nodes = append(nodes, node)
+	}
+
+	return p.PostProcessNodes(nodes, parentNode, parentDoc)
+}
+
+// readCSV returns the header and data rows of text. A document without
+// records returns a nil header.
+func (p *CSVNodeParser) readCSV(text string) ([]string, [][]string, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = p.delimiter
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, rows, nil
+}
+
+// groupRows groups rows by count or by the group-by column.
+func (p *CSVNodeParser) groupRows(rows [][]string, columnIndex map[string]int) ([]*csvRowGroup, error) {
+	var groups []*csvRowGroup
+
+	if p.groupByColumn == "" {
+		for start := 0; start < len(rows); start += p.rowsPerNode {
+			end := start + p.rowsPerNode
+			if end > len(rows) {
+				end = len(rows)
+			}
+			group := &csvRowGroup{rows: rows[start:end]}
+			for i := start; i < end; i++ {
+				group.indexes = append(group.indexes, i)
+			}
+			groups = append(groups, group)
+		}
+		return groups, nil
+	}
+
+	column, ok := columnIndex[p.groupByColumn]
+	if !ok {
+		return nil, fmt.Errorf("group-by column %q not found", p.groupByColumn)
+	}
+	byValue := make(map[string]*csvRowGroup)
+	for i, row := range rows {
+		value := ""
+		if column < len(row) {
+			value = row[column]
+		}
+		group, ok := byValue[value]
+		if !ok {
+			group = &csvRowGroup{}
+			byValue[value] = group
+			groups = append(groups, group)
+		}
+		group.rows = append(group.rows, row)
+		group.indexes = append(group.indexes, i)
+	}
+	return groups, nil
+}
+
+// sharedColumnValue returns the value of column if all rows share it.
+func sharedColumnValue(rows [][]string, columnIndex map[string]int, column string) (string, bool) {
+	i, ok := columnIndex[column]
+	if !ok {
+		return "", false
+	}
+	var value string
+	for n, row := range rows {
+		if i >= len(row) {
+			return "", false
+		}
+		if n == 0 {
+			value = row[i]
+		} else if row[i] != value {
+			return "", false
+		}
+	}
+	return value, true
+}
+
+// Ensure CSVNodeParser implements NodeParserWithOptions.
+var _ NodeParserWithOptions = (*CSVNodeParser)(nil)
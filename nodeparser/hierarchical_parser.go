@@ -0,0 +1,212 @@
+package nodeparser
+
+import (
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textsplitter"
+)
+
+// DefaultHierarchicalChunkSizes are the default chunk sizes, in tokens, of
+// the levels built by HierarchicalNodeParser, from the root level down.
+var DefaultHierarchicalChunkSizes = []int{2048, 512, 128}
+
+// HierarchicalNodeParser splits documents into a hierarchy of chunks, one
+// level per chunk size. Every chunk of a level is split again with the next
+// level's size, and each child gets a PARENT relationship to the chunk it was
+// split from, which lists its children in CHILD. Chunks with the same parent
+// are linked with PREVIOUS/NEXT, and every chunk has a SOURCE relationship to
+// its document.
+//
+// This is the structure retriever.AutoMergingRetriever needs: index the leaf
+// nodes (GetLeafNodes) in the vector store and put all nodes in the docstore,
+// so retrieved leaves can be merged into their parents.
+type HierarchicalNodeParser struct {
+	*BaseNodeParser
+	splitters []*textsplitter.SentenceSplitter
+}
+
+// NewHierarchicalNodeParser creates a new HierarchicalNodeParser with the
+// given chunk sizes, largest first. Without sizes it uses
+// DefaultHierarchicalChunkSizes.
+func NewHierarchicalNodeParser(chunkSizes ...int) *HierarchicalNodeParser {
+	return NewHierarchicalNodeParserWithOverlap(0, chunkSizes...)
+}
+
+// NewHierarchicalNodeParserWithOverlap creates a new HierarchicalNodeParser
+// with the given chunk overlap on every level.
+func NewHierarchicalNodeParserWithOverlap(chunkOverlap int, chunkSizes ...int) *HierarchicalNodeParser {
+	if len(chunkSizes) == 0 {
+		chunkSizes = DefaultHierarchicalChunkSizes
+	}
+
+	splitters := make([]*textsplitter.SentenceSplitter, len(chunkSizes))
+	for i, size := range chunkSizes {
+		splitters[i] = textsplitter.NewSentenceSplitter(size, chunkOverlap, nil, nil)
+	}
+	return NewHierarchicalNodeParserWithSplitters(splitters...)
+}
+
+// NewHierarchicalNodeParserWithSplitters creates a new HierarchicalNodeParser
+// with one custom splitter per level, root level first.
+func NewHierarchicalNodeParserWithSplitters(splitters ...*textsplitter.SentenceSplitter) *HierarchicalNodeParser {
+	return &HierarchicalNodeParser{
+		BaseNodeParser: NewBaseNodeParser(),
+		splitters:      splitters,
+	}
+}
+
+// WithTokenizer sets the tokenizer used to size chunks on every level.
+func (p *HierarchicalNodeParser) WithTokenizer(tokenizer textsplitter.Tokenizer) *HierarchicalNodeParser {
+	for _, splitter := range p.splitters {
+		splitter.WithTokenizer(tokenizer)
+	}
+	return p
+}
+
+// WithIncludeMetadata sets whether to include parent metadata in child nodes.
+func (p *HierarchicalNodeParser) WithIncludeMetadata(include bool) NodeParserWithOptions {
+	p.BaseNodeParser.WithIncludeMetadata(include)
+	return p
+}
+
+// WithIncludePrevNextRel sets whether to establish PREVIOUS/NEXT relationships.
+func (p *HierarchicalNodeParser) WithIncludePrevNextRel(include bool) NodeParserWithOptions {
+	p.BaseNodeParser.WithIncludePrevNextRel(include)
+	return p
+}
+
+// Levels returns the number of levels in the hierarchy.
+func (p *HierarchicalNodeParser) Levels() int {
+	return len(p.splitters)
+}
+
+// GetNodesFromDocuments parses documents into nodes of every level, each
+// parent followed by its descendants.
+func (p *HierarchicalNodeParser) GetNodesFromDocuments(documents []schema.Document) []*schema.Node {
+	var allNodes []*schema.Node
+
+	for _, doc := range documents {
+		p.EmitStart(doc.ID)
+
+		var nodes []*schema.Node
+		for _, root := range p.buildLevel(doc.Text, 0, nil, &doc) {
+			nodes = append(nodes, p.descend(root, 1, &doc)...)
+		}
+		for _, node := range nodes {
+			node.Metadata["source_doc_id"] = doc.ID
+		}
+
+		allNodes = append(allNodes, nodes...)
+
+		p.EmitComplete(doc.ID, len(nodes))
+	}
+
+	return allNodes
+}
+
+// ParseNodes parses each node into a hierarchy below it. The given nodes
+// become the parents of the first level.
+func (p *HierarchicalNodeParser) ParseNodes(nodes []*schema.Node) []*schema.Node {
+	var allNodes []*schema.Node
+
+	for _, node := range nodes {
+		p.EmitStart(node.ID)
+
+		var childNodes []*schema.Node
+		for _, child := range p.buildLevel(node.Text, 0, node, nil) {
+			childNodes = append(childNodes, p.descend(child, 1, nil)...)
+		}
+		for _, childNode := range childNodes {
+			childNode.Metadata["source_node_id"] = node.ID
+		}
+
+		allNodes = append(allNodes, childNodes...)
+
+		p.EmitComplete(node.ID, len(childNodes))
+	}
+
+	return allNodes
+}
+
+// descend returns node followed by the nodes of the levels below it.
+func (p *HierarchicalNodeParser) descend(node *schema.Node, level int, doc *schema.Document) []*schema.Node {
+	nodes := []*schema.Node{node}
+	if level >= len(p.splitters) {
+		return nodes
+	}
+
+	for _, child := range p.buildLevel(node.Text, level, node, doc) {
+		nodes = append(nodes, p.descend(child, level+1, doc)...)
+	}
+	return nodes
+}
+
+// buildLevel splits text with the level's splitter. Children of a parent
+// node point at it with PARENT and are listed in its CHILD relationship;
+// their SOURCE stays the document when there is one.
+func (p *HierarchicalNodeParser) buildLevel(text string, level int, parent *schema.Node, doc *schema.Document) []*schema.Node {
+	splits := p.splitters[level].SplitText(text)
+
+	var nodes []*schema.Node
+	if doc != nil {
+		nodes = p.BuildNodesFromSplits(splits, nil, doc)
+		if parent != nil && p.options.IncludeMetadata {
+			for _, node := range nodes {
+				p.mergeMetadata(node, parent.Metadata)
+			}
+		}
+	} else {
+		nodes = p.BuildNodesFromSplits(splits, parent, nil)
+	}
+
+	if parent != nil {
+		parentInfo := parent.AsRelatedNodeInfo()
+		for _, node := range nodes {
+			node.Relationships.SetParent(parentInfo)
+			parent.Relationships.AddChild(node.AsRelatedNodeInfo())
+		}
+	}
+	return nodes
+}
+
+// GetLeafNodes returns the nodes without children.
+func GetLeafNodes(nodes []*schema.Node) []*schema.Node {
+	var leaves []*schema.Node
+	for _, node := range nodes {
+		if len(node.Relationships.GetChildren()) == 0 {
+			leaves = append(leaves, node)
+		}
+	}
+	return leaves
+}
+
+// GetRootNodes returns the nodes without a parent.
+func GetRootNodes(nodes []*schema.Node) []*schema.Node {
+	var roots []*schema.Node
+	for _, node := range nodes {
+		if node.Relationships.GetParent() == nil {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
+
+// GetChildNodes returns the children of the given nodes found in allNodes.
+func GetChildNodes(nodes []*schema.Node, allNodes []*schema.Node) []*schema.Node {
+	byID := make(map[string]*schema.Node, len(allNodes))
+	for _, node := range allNodes {
+		byID[node.ID] = node
+	}
+
+	var children []*schema.Node
+	for _, node := range nodes {
+		for _, child := range node.Relationships.GetChildren() {
+			if childNode, ok := byID[child.NodeID]; ok {
+				children = append(children, childNode)
+			}
+		}
+	}
+	return children
+}
+
+// Ensure HierarchicalNodeParser implements NodeParserWithOptions.
+var _ NodeParserWithOptions = (*HierarchicalNodeParser)(nil)
@@ -0,0 +1,331 @@
+package nodeparser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// JSONPathMetadataKey is the metadata key holding a JSON node's path.
+const JSONPathMetadataKey = "json_path"
+
+// JSONNodeParser parses JSON documents into one node per object. Each node
+// holds the scalar fields of one object as "path: value" lines, with paths
+// relative to the document root so the node keeps its context; nested
+// objects and arrays of objects become nodes of their own. The object's
+// path, e.g. $.employees[2], is stored under JSONPathMetadataKey.
+//
+// JSON Lines documents are parsed as an array of their values. Documents
+// that are not valid JSON are kept as a single text node and reported with
+// an error event.
+type JSONNodeParser struct {
+	*BaseNodeParser
+	metadataKeys map[string]bool
+}
+
+// NewJSONNodeParser creates a new JSONNodeParser.
+func NewJSONNodeParser() *JSONNodeParser {
+	return &JSONNodeParser{
+		BaseNodeParser: NewBaseNodeParser(),
+		metadataKeys:   make(map[string]bool),
+	}
+}
+
+// WithMetadataKeys copies the scalar fields with these keys, such as "id"
+// or "category", into the metadata of their object's node.
+func (p *JSONNodeParser) WithMetadataKeys(keys ...string) *JSONNodeParser {
+	for _, key := range keys {
+		p.metadataKeys[key] = true
+	}
+	return p
+}
+
+// WithIncludeMetadata sets whether to include parent metadata in child nodes.
+func (p *JSONNodeParser) WithIncludeMetadata(include bool) NodeParserWithOptions {
+	p.BaseNodeParser.WithIncludeMetadata(include)
+	return p
+}
+
+// WithIncludePrevNextRel sets whether to establish PREVIOUS/NEXT relationships.
+func (p *JSONNodeParser) WithIncludePrevNextRel(include bool) NodeParserWithOptions {
+	p.BaseNodeParser.WithIncludePrevNextRel(include)
+	return p
+}
+
+// GetNodesFromDocuments parses JSON documents into object nodes.
+func (p *JSONNodeParser) GetNodesFromDocuments(documents []schema.Document) []*schema.Node {
+	var allNodes []*schema.Node
+
+	for _, doc := range documents {
+		p.EmitStart(doc.ID)
+
+		nodes := p.buildJSONNodes(doc.ID, doc.Text, nil, &doc)
+		for _, node := range nodes {
+			node.Metadata["source_doc_id"] = doc.ID
+		}
+
+		allNodes = append(allNodes, nodes...)
+
+		p.EmitComplete(doc.ID, len(nodes))
+	}
+
+	return allNodes
+}
+
+// ParseNodes parses nodes holding JSON into object nodes.
+func (p *JSONNodeParser) ParseNodes(nodes []*schema.Node) []*schema.Node {
+	var allNodes []*schema.Node
+
+	for _, node := range nodes {
+		p.EmitStart(node.ID)
+
+		childNodes := p.buildJSONNodes(node.ID, node.Text, node, nil)
+		for _, childNode := range childNodes {
+			childNode.Metadata["source_node_id"] = node.ID
+		}
+
+		allNodes = append(allNodes, childNodes...)
+
+		p.EmitComplete(node.ID, len(childNodes))
+	}
+
+	return allNodes
+}
+
+// buildJSONNodes creates one node per object in text.
+func (p *JSONNodeParser) buildJSONNodes(id, text string, parentNode *schema.Node, parentDoc *schema.Document) []*schema.Node {
+	root, err := parseOrderedJSON(text)
+	if err != nil {
+		p.EmitError(id, fmt.Errorf("invalid JSON: %w", err))
+		return p.BuildNodesFromSplits([]string{text}, parentNode, parentDoc)
+	}
+
+	var nodes []*schema.Node
+	var walk func(value *jsonValue, path, key string)
+	walk = func(value *jsonValue, path, key string) {
+		var lines []string
+		metadata := make(map[string]interface{})
+		var children []func()
+
+		switch value.kind {
+		case jsonObject:
+			for _, field := range value.fields {
+				fieldPath := path + "." + field.key
+				fieldKey := joinJSONKey(key, field.key)
+				switch {
+				case field.value.isScalar():
+					lines = append(lines, fieldKey+": "+field.value.String())
+					if p.metadataKeys[field.key] {
+						metadata[field.key] = field.value.scalar
+					}
+				case field.value.isScalarArray():
+					lines = append(lines, fieldKey+": "+field.value.joinScalars())
+				default:
+					child := field.value
+					children = append(children, func() { walk(child, fieldPath, fieldKey) })
+				}
+			}
+		case jsonArray:
+			if value.isScalarArray() {
+				lines = append(lines, displayJSONKey(key)+": "+value.joinScalars())
+				break
+			}
+			for i, item := range value.items {
+				itemPath := path + "[" + strconv.Itoa(i) + "]"
+				itemKey := key + "[" + strconv.Itoa(i) + "]"
+				if item.isScalar() {
+					lines = append(lines, displayJSONKey(itemKey)+": "+item.String())
+					continue
+				}
+				child := item
+				children = append(children, func() { walk(child, itemPath, itemKey) })
+			}
+		default:
+			lines = append(lines, displayJSONKey(key)+": "+value.String())
+		}
+
+		if len(lines) > 0 {
+			node := schema.NewNode()
+			node.ID = p.GenerateID()
+			node.Text = strings.Join(lines, "\n")
+			node.Type = schema.ObjectTypeText
+			node.Metadata[JSONPathMetadataKey] = path
+			for k, v := range metadata {
+				node.Metadata[k] = v
+			}
+			node.Hash = node.GenerateHash()
+			nodes = append(nodes, node)
+		}
+		for _, child := range children {
+			child()
+		}
+	}
+	walk(root, "$", "")
+
+	return p.PostProcessNodes(nodes, parentNode, parentDoc)
+}
+
+func joinJSONKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func displayJSONKey(key string) string {
+	if key == "" {
+		return "value"
+	}
+	return key
+}
+
+// jsonKind is the kind of a JSON value.
+type jsonKind int
+
+const (
+	jsonScalar jsonKind = iota
+	jsonObject
+	jsonArray
+)
+
+// jsonValue is a JSON value that keeps the order of object keys.
+type jsonValue struct {
+	kind   jsonKind
+	scalar interface{}
+	fields []jsonField
+	items  []*jsonValue
+}
+
+// jsonField is one key of a JSON object.
+type jsonField struct {
+	key   string
+	value *jsonValue
+}
+
+func (v *jsonValue) isScalar() bool {
+	return v.kind == jsonScalar
+}
+
+func (v *jsonValue) isScalarArray() bool {
+	if v.kind != jsonArray {
+		return false
+	}
+	for _, item := range v.items {
+		if !item.isScalar() {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *jsonValue) joinScalars() string {
+	parts := make([]string, len(v.items))
+	for i, item := range v.items {
+		parts[i] = item.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// String formats a scalar value.
+func (v *jsonValue) String() string {
+	switch s := v.scalar.(type) {
+	case nil:
+		return "null"
+	case string:
+		return s
+	default:
+		return fmt.Sprint(s)
+	}
+}
+
+// parseOrderedJSON parses a JSON value, or a sequence of values as in JSON
+// Lines, which is returned as an array.
+func parseOrderedJSON(text string) (*jsonValue, error) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+
+	var values []*jsonValue
+	for {
+		value, err := decodeOrderedJSON(dec)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	switch len(values) {
+	case 0:
+		return nil, errors.New("empty document")
+	case 1:
+		return values[0], nil
+	default:
+		return &jsonValue{kind: jsonArray, items: values}, nil
+	}
+}
+
+func decodeOrderedJSON(dec *json.Decoder) (*jsonValue, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			value := &jsonValue{kind: jsonObject}
+			for dec.More() {
+				keyToken, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, _ := keyToken.(string)
+				fieldValue, err := decodeOrderedJSON(dec)
+				if err != nil {
+					return nil, noEOF(err)
+				}
+				value.fields = append(value.fields, jsonField{key: key, value: fieldValue})
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, noEOF(err)
+			}
+			return value, nil
+		case '[':
+			value := &jsonValue{kind: jsonArray}
+			for dec.More() {
+				item, err := decodeOrderedJSON(dec)
+				if err != nil {
+					return nil, noEOF(err)
+				}
+				value.items = append(value.items, item)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, noEOF(err)
+			}
+			return value, nil
+		default:
+			return nil, fmt.Errorf("unexpected %v", t)
+		}
+	default:
+		return &jsonValue{kind: jsonScalar, scalar: t}, nil
+	}
+}
+
+// noEOF turns an EOF inside a value into an unexpected EOF.
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Ensure JSONNodeParser implements NodeParserWithOptions.
+var _ NodeParserWithOptions = (*JSONNodeParser)(nil)
@@ -1,9 +1,15 @@
 package nodeparser
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/aqua777/go-llamaindex/postprocessor"
+	"github.com/aqua777/go-llamaindex/rag/retriever"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -392,3 +398,201 @@ func TestMetadataMergeDoesNotOverwrite(t *testing.T) {
 	assert.Equal(t, "doc_value", nodes[0].Metadata["doc_only"])
 	assert.Equal(t, "doc_value", nodes[0].Metadata["shared_key"])
 }
+
+func TestSentenceWindowNodeParser(t *testing.T) {
+	parser := NewSentenceWindowNodeParser(1)
+	doc := schema.Document{
+		ID:       "doc1",
+		Text:     "Go is compiled. It has goroutines. Channels connect them. The GC is concurrent.",
+		Metadata: map[string]interface{}{"author": "gopher"},
+	}
+
+	nodes := parser.GetNodesFromDocuments([]schema.Document{doc})
+	require.Len(t, nodes, 4)
+
+	second := nodes[1]
+	assert.Equal(t, "It has goroutines.", second.Text)
+	assert.Equal(t, "Go is compiled. It has goroutines. Channels connect them.", second.Metadata[parser.WindowMetadataKey()])
+	assert.Equal(t, "It has goroutines.", second.Metadata[parser.OriginalTextMetadataKey()])
+	assert.Equal(t, "gopher", second.Metadata["author"])
+	assert.Equal(t, "doc1", second.Relationships.GetSource().NodeID)
+	assert.Equal(t, nodes[0].ID, second.Relationships.GetPrevious().NodeID)
+
+	// The window is kept out of the embedded and LLM content.
+	assert.NotContains(t, second.GetContent(schema.MetadataModeEmbed), "Channels")
+	assert.NotContains(t, second.GetContent(schema.MetadataModeLLM), "Channels")
+	assert.Contains(t, second.GetContent(schema.MetadataModeEmbed), "gopher")
+
+	// The companion postprocessor swaps the sentence for its window.
+	replacer := postprocessor.NewMetadataReplacementPostprocessor(parser.WindowMetadataKey())
+	result, err := replacer.PostprocessNodes(context.Background(), []schema.NodeWithScore{{Node: *second, Score: 0.8}}, &schema.QueryBundle{QueryString: "goroutines"})
+	require.NoError(t, err)
+	assert.Equal(t, "Go is compiled. It has goroutines. Channels connect them.", result[0].Node.Text)
+	assert.Equal(t, "It has goroutines.", second.Text)
+
+	custom := NewSentenceWindowNodeParser(0).WithMetadataKeys("original_text", "context")
+	nodes = custom.GetNodesFromDocuments([]schema.Document{doc})
+	assert.Equal(t, "Go is compiled.", nodes[0].Metadata["context"])
+	assert.Equal(t, "Go is compiled.", nodes[0].Metadata["original_text"])
+}
+
+// staticRetriever returns fixed nodes.
+type staticRetriever struct {
+	nodes []schema.NodeWithScore
+}
+
+func (r *staticRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	return r.nodes, nil
+}
+
+func TestHierarchicalNodeParser(t *testing.T) {
+	var sentences []string
+	for i := 0; i < 24; i++ {
+		sentences = append(sentences, fmt.Sprintf("Sentence number %d talks about topic %d in some detail.", i, i%3))
+	}
+	doc := schema.Document{ID: "doc1", Text: strings.Join(sentences, " "), Metadata: map[string]interface{}{"title": "Topics"}}
+
+	parser := NewHierarchicalNodeParser(120, 40)
+	assert.Equal(t, 2, parser.Levels())
+	nodes := parser.GetNodesFromDocuments([]schema.Document{doc})
+
+	roots := GetRootNodes(nodes)
+	leaves := GetLeafNodes(nodes)
+	require.Greater(t, len(roots), 1)
+	require.Greater(t, len(leaves), len(roots))
+	assert.Len(t, nodes, len(roots)+len(leaves))
+
+	byID := make(map[string]*schema.Node)
+	for _, node := range nodes {
+		byID[node.ID] = node
+		assert.Equal(t, "doc1", node.Relationships.GetSource().NodeID)
+		assert.Equal(t, "Topics", node.Metadata["title"])
+	}
+	for _, leaf := range leaves {
+		parent := byID[leaf.Relationships.GetParent().NodeID]
+		require.NotNil(t, parent)
+		assert.Contains(t, parent.Text, leaf.Text)
+	}
+
+	// Siblings are linked within their parent.
+	children := GetChildNodes(roots[:1], nodes)
+	require.Greater(t, len(children), 1)
+	assert.Equal(t, children[1].ID, children[0].Relationships.GetNext().NodeID)
+	assert.Nil(t, children[0].Relationships.GetPrevious())
+
+	// Retrieving most of a parent's leaves merges them into the parent.
+	storageCtx := storage.NewStorageContext()
+	all := make([]schema.BaseNode, len(nodes))
+	for i, node := range nodes {
+		all[i] = node
+	}
+	require.NoError(t, storageCtx.DocStore.AddDocuments(context.Background(), all, true))
+
+	var retrieved []schema.NodeWithScore
+	for _, child := range children {
+		retrieved = append(retrieved, schema.NodeWithScore{Node: *child, Score: 0.5})
+	}
+	amr := retriever.NewAutoMergingRetriever(&staticRetriever{nodes: retrieved}, storageCtx)
+	merged, err := amr.Retrieve(context.Background(), schema.QueryBundle{QueryString: "topic"})
+	require.NoError(t, err)
+	require.Len(t, merged, 1)
+	assert.Equal(t, roots[0].ID, merged[0].Node.ID)
+}
+
+func TestJSONNodeParser(t *testing.T) {
+	doc := schema.Document{
+		ID: "doc1",
+		Text: `{"company": "Acme", "tags": ["b2b", "saas"],
+			"address": {"city": "Berlin", "zip": "10115"},
+			"employees": [{"id": 7, "name": "Ada", "role": "CTO"}, {"id": 8, "name": "Bob"}]}`,
+		Metadata: map[string]interface{}{"file_name": "acme.json"},
+	}
+
+	parser := NewJSONNodeParser().WithMetadataKeys("id")
+	nodes := parser.GetNodesFromDocuments([]schema.Document{doc})
+	require.Len(t, nodes, 4)
+
+	paths := make([]string, len(nodes))
+	for i, node := range nodes {
+		paths[i] = node.Metadata[JSONPathMetadataKey].(string)
+		assert.Equal(t, "acme.json", node.Metadata["file_name"])
+		assert.Equal(t, "doc1", node.Relationships.GetSource().NodeID)
+	}
+	assert.Equal(t, []string{"$", "$.address", "$.employees[0]", "$.employees[1]"}, paths)
+	assert.Equal(t, "company: Acme\ntags: b2b, saas", nodes[0].Text)
+	assert.Equal(t, "address.city: Berlin\naddress.zip: 10115", nodes[1].Text)
+	assert.Equal(t, "employees[0].id: 7\nemployees[0].name: Ada\nemployees[0].role: CTO", nodes[2].Text)
+	assert.NotNil(t, nodes[2].Metadata["id"])
+	assert.Equal(t, nodes[3].ID, nodes[2].Relationships.GetNext().NodeID)
+
+	t.Run("json lines", func(t *testing.T) {
+		nodes := NewJSONNodeParser().GetNodesFromDocuments([]schema.Document{
+			{ID: "lines", Text: "{\"q\": \"a\"}\n{\"q\": \"b\"}\n"},
+		})
+		require.Len(t, nodes, 2)
+		assert.Equal(t, "$[1]", nodes[1].Metadata[JSONPathMetadataKey])
+		assert.Equal(t, "[1].q: b", nodes[1].Text)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		var events []NodeParserEvent
+		parser := NewJSONNodeParser()
+		parser.WithCallback(func(e NodeParserEvent) { events = append(events, e) })
+
+		nodes := parser.GetNodesFromDocuments([]schema.Document{{ID: "bad", Text: `{"a": `}})
+		require.Len(t, nodes, 1)
+		assert.Equal(t, `{"a": `, nodes[0].Text)
+
+		var errs int
+		for _, e := range events {
+			if e.Type == EventTypeError {
+				errs++
+			}
+		}
+		assert.Equal(t, 1, errs)
+	})
+}
+
+func TestCSVNodeParser(t *testing.T) {
+	text := "region,product,revenue\nEU,Widget,100\nEU,Gadget,80\nUS,Widget,120\n"
+	doc := schema.Document{ID: "sales", Text: text}
+
+	t.Run("one node per row", func(t *testing.T) {
+		nodes := NewCSVNodeParser().WithMetadataColumns("region").GetNodesFromDocuments([]schema.Document{doc})
+		require.Len(t, nodes, 3)
+		assert.Equal(t, "region: EU\nproduct: Widget\nrevenue: 100", nodes[0].Text)
+		assert.Equal(t, []string{"region", "product", "revenue"}, nodes[0].Metadata[CSVColumnsMetadataKey])
+		assert.Equal(t, 2, nodes[2].Metadata[CSVRowStartMetadataKey])
+		assert.Equal(t, "US", nodes[2].Metadata["region"])
+		assert.Equal(t, "sales", nodes[2].Relationships.GetSource().NodeID)
+	})
+
+	t.Run("rows per node", func(t *testing.T) {
+		nodes := NewCSVNodeParser().WithRowsPerNode(2).WithTextColumns("product", "revenue").
+			WithMetadataColumns("region").GetNodesFromDocuments([]schema.Document{doc})
+		require.Len(t, nodes, 2)
+		assert.Equal(t, "product: Widget\nrevenue: 100\n\nproduct: Gadget\nrevenue: 80", nodes[0].Text)
+		assert.Equal(t, 0, nodes[0].Metadata[CSVRowStartMetadataKey])
+		assert.Equal(t, 1, nodes[0].Metadata[CSVRowEndMetadataKey])
+		assert.Equal(t, "EU", nodes[0].Metadata["region"])
+	})
+
+	t.Run("group by column", func(t *testing.T) {
+		nodes := NewCSVNodeParser().WithGroupByColumn("product").WithMetadataColumns("product", "region").
+			GetNodesFromDocuments([]schema.Document{doc})
+		require.Len(t, nodes, 2)
+		assert.Equal(t, "Widget", nodes[0].Metadata["product"])
+		assert.Equal(t, 0, nodes[0].Metadata[CSVRowStartMetadataKey])
+		assert.Equal(t, 2, nodes[0].Metadata[CSVRowEndMetadataKey])
+		assert.NotContains(t, nodes[0].Metadata, "region")
+	})
+
+	t.Run("delimiter and empty", func(t *testing.T) {
+		nodes := NewCSVNodeParser().WithDelimiter(';').GetNodesFromDocuments([]schema.Document{
+			{ID: "semi", Text: "a;b\n1;2\n"},
+			{ID: "empty", Text: ""},
+		})
+		require.Len(t, nodes, 1)
+		assert.Equal(t, "a: 1\nb: 2", nodes[0].Text)
+	})
+}
@@ -36,6 +36,13 @@ func NewSentenceNodeParserWithSplitter(splitter *textsplitter.SentenceSplitter)
 	}
 }
 
+// WithTokenizer sets the tokenizer used to size chunks, so chunk size and
+// overlap are measured in that model's tokens.
+func (p *SentenceNodeParser) WithTokenizer(tokenizer textsplitter.Tokenizer) *SentenceNodeParser {
+	p.splitter.WithTokenizer(tokenizer)
+	return p
+}
+
 // WithIncludeMetadata sets whether to include parent metadata in child nodes.
 func (p *SentenceNodeParser) WithIncludeMetadata(include bool) NodeParserWithOptions {
 	p.BaseNodeParser.WithIncludeMetadata(include)
@@ -0,0 +1,131 @@
+package nodeparser
+
+import (
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textsplitter"
+)
+
+// SentenceWindowNodeParser parses documents into one node per sentence and
+// stores the surrounding sentences in the node's metadata. Sentences are
+// embedded and matched on their own, which keeps retrieval precise; at query
+// time postprocessor.NewMetadataReplacementPostprocessor with the window key
+// swaps each node's text for its window so the LLM sees the wider context.
+//
+// The window and original sentence are excluded from the embedding and LLM
+// metadata so they do not dilute the sentence embedding or get repeated in
+// prompts.
+type SentenceWindowNodeParser struct {
+	*BaseNodeParser
+	splitter *textsplitter.SentenceWindowSplitter
+}
+
+// NewSentenceWindowNodeParser creates a new SentenceWindowNodeParser that
+// keeps windowSize sentences on each side of every sentence.
+func NewSentenceWindowNodeParser(windowSize int) *SentenceWindowNodeParser {
+	return NewSentenceWindowNodeParserWithSplitter(textsplitter.NewSentenceWindowSplitter(windowSize))
+}
+
+// NewSentenceWindowNodeParserWithSplitter creates a new
+// SentenceWindowNodeParser with a custom splitter.
+func NewSentenceWindowNodeParserWithSplitter(splitter *textsplitter.SentenceWindowSplitter) *SentenceWindowNodeParser {
+	return &SentenceWindowNodeParser{
+		BaseNodeParser: NewBaseNodeParser(),
+		splitter:       splitter,
+	}
+}
+
+// WithMetadataKeys sets the metadata keys for the original sentence and the
+// window.
+func (p *SentenceWindowNodeParser) WithMetadataKeys(originalKey, windowKey string) *SentenceWindowNodeParser {
+	p.splitter.WithMetadataKeys(originalKey, windowKey)
+	return p
+}
+
+// WithIncludeMetadata sets whether to include parent metadata in child nodes.
+func (p *SentenceWindowNodeParser) WithIncludeMetadata(include bool) NodeParserWithOptions {
+	p.BaseNodeParser.WithIncludeMetadata(include)
+	return p
+}
+
+// WithIncludePrevNextRel sets whether to establish PREVIOUS/NEXT relationships.
+func (p *SentenceWindowNodeParser) WithIncludePrevNextRel(include bool) NodeParserWithOptions {
+	p.BaseNodeParser.WithIncludePrevNextRel(include)
+	return p
+}
+
+// WindowMetadataKey returns the metadata key holding the window text.
+func (p *SentenceWindowNodeParser) WindowMetadataKey() string {
+	return p.splitter.WindowMetadataKey
+}
+
+// OriginalTextMetadataKey returns the metadata key holding the sentence.
+func (p *SentenceWindowNodeParser) OriginalTextMetadataKey() string {
+	return p.splitter.OriginalTextMetadataKey
+}
+
+// GetNodesFromDocuments parses documents into sentence nodes.
+func (p *SentenceWindowNodeParser) GetNodesFromDocuments(documents []schema.Document) []*schema.Node {
+	var allNodes []*schema.Node
+
+	for _, doc := range documents {
+		p.EmitStart(doc.ID)
+
+		nodes := p.buildWindowNodes(doc.Text, nil, &doc)
+		for _, node := range nodes {
+			node.Metadata["source_doc_id"] = doc.ID
+		}
+
+		allNodes = append(allNodes, nodes...)
+
+		p.EmitComplete(doc.ID, len(nodes))
+	}
+
+	return allNodes
+}
+
+// ParseNodes parses nodes into sentence nodes.
+func (p *SentenceWindowNodeParser) ParseNodes(nodes []*schema.Node) []*schema.Node {
+	var allNodes []*schema.Node
+
+	for _, node := range nodes {
+		p.EmitStart(node.ID)
+
+		childNodes := p.buildWindowNodes(node.Text, node, nil)
+		for _, childNode := range childNodes {
+			childNode.Metadata["source_node_id"] = node.ID
+		}
+
+		allNodes = append(allNodes, childNodes...)
+
+		p.EmitComplete(node.ID, len(childNodes))
+	}
+
+	return allNodes
+}
+
+// buildWindowNodes creates one node per sentence with its window metadata.
+func (p *SentenceWindowNodeParser) buildWindowNodes(text string, parentNode *schema.Node, parentDoc *schema.Document) []*schema.Node {
+	windows := p.splitter.SplitTextForNodes(text)
+	nodes := make([]*schema.Node, len(windows))
+	excluded := []string{p.splitter.WindowMetadataKey, p.splitter.OriginalTextMetadataKey}
+
+	for i, window := range windows {
+		node := schema.NewNode()
+		node.ID = p.GenerateID()
+		node.Text = window.Text
+		node.Type = schema.ObjectTypeText
+		for k, v := range window.Metadata {
+			node.Metadata[k] = v
+		}
+		node.ExcludedEmbedMetadataKeys = append(node.ExcludedEmbedMetadataKeys, excluded...)
+		node.ExcludedLLMMetadataKeys = append(node.ExcludedLLMMetadataKeys, excluded...)
+		node.Hash = node.GenerateHash()
+
+		nodes[i] = node
+	}
+
+	return p.PostProcessNodes(nodes, parentNode, parentDoc)
+}
+
+// Ensure SentenceWindowNodeParser implements NodeParserWithOptions.
+var _ NodeParserWithOptions = (*SentenceWindowNodeParser)(nil)
@@ -0,0 +1,481 @@
+package postprocessor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/credentials"
+	"github.com/aqua777/go-llamaindex/internal/httputil"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// RerankProvider identifies a hosted rerank API.
+type RerankProvider string
+
+const (
+	// RerankProviderCohere is the Cohere rerank API.
+	RerankProviderCohere RerankProvider = "cohere"
+	// RerankProviderJina is the Jina AI reranker API.
+	RerankProviderJina RerankProvider = "jina"
+	// RerankProviderVoyage is the Voyage AI rerank API.
+	RerankProviderVoyage RerankProvider = "voyage"
+)
+
+// Default rerank models.
+const (
+	CohereRerankEnglishV3      = "rerank-english-v3.0"
+	CohereRerankMultilingualV3 = "rerank-multilingual-v3.0"
+	JinaRerankerV2Multilingual = "jina-reranker-v2-base-multilingual"
+	VoyageRerank2              = "rerank-2"
+	VoyageRerank2Lite          = "rerank-2-lite"
+)
+
+// RerankScoreNormalization controls how API relevance scores are mapped
+// before they replace the node scores.
+type RerankScoreNormalization string
+
+const (
+	// RerankScoreNormalizationNone keeps the scores returned by the API.
+	RerankScoreNormalizationNone RerankScoreNormalization = "none"
+	// RerankScoreNormalizationMinMax rescales scores to [0, 1] over all
+	// reranked nodes.
+	RerankScoreNormalizationMinMax RerankScoreNormalization = "minmax"
+	// RerankScoreNormalizationSigmoid maps raw logits to (0, 1).
+	RerankScoreNormalizationSigmoid RerankScoreNormalization = "sigmoid"
+)
+
+// rerankProviderConfig describes a provider's endpoint and request format.
+type rerankProviderConfig struct {
+	name      string
+	baseURL   string
+	model     string
+	apiKeyEnv string
+	// topNField is the request field holding the number of results.
+	topNField string
+	// resultsField is the response field holding the results.
+	resultsField string
+}
+
+var rerankProviders = map[RerankProvider]rerankProviderConfig{
+	RerankProviderCohere: {
+		name:         "CohereRerank",
+		baseURL:      "https://api.cohere.ai/v1",
+		model:        CohereRerankEnglishV3,
+		apiKeyEnv:    "COHERE_API_KEY",
+		topNField:    "top_n",
+		resultsField: "results",
+	},
+	RerankProviderJina: {
+		name:         "JinaRerank",
+		baseURL:      "https://api.jina.ai/v1",
+		model:        JinaRerankerV2Multilingual,
+		apiKeyEnv:    "JINA_API_KEY",
+		topNField:    "top_n",
+		resultsField: "results",
+	},
+	RerankProviderVoyage: {
+		name:         "VoyageRerank",
+		baseURL:      "https://api.voyageai.com/v1",
+		model:        VoyageRerank2,
+		apiKeyEnv:    "VOYAGE_API_KEY",
+		topNField:    "top_k",
+		resultsField: "data",
+	},
+}
+
+// APIRerank reranks nodes with a hosted cross-encoder rerank API (Cohere,
+// Jina or Voyage). Nodes are sent in batches, each batch is retried on rate
+// limiting and server errors, and the scores of all batches are merged,
+// optionally normalized, and sorted to return the top N.
+type APIRerank struct {
+	*BaseNodePostprocessor
+	provider      RerankProvider
+	config        rerankProviderConfig
+	apiKey        string
+	baseURL       string
+	model         string
+	topN          int
+	batchSize     int
+	maxRetries    int
+	retryDelay    time.Duration
+	normalization RerankScoreNormalization
+	httpClient    *http.Client
+	credentials   credentials.Provider
+	explain       bool
+}
+
+// APIRerankOption configures an APIRerank.
+type APIRerankOption func(*APIRerank)
+
+// WithAPIRerankAPIKey sets the API key. Defaults to the provider's
+// environment variable (COHERE_API_KEY, JINA_API_KEY or VOYAGE_API_KEY).
+func WithAPIRerankAPIKey(apiKey string) APIRerankOption {
+	return func(r *APIRerank) {
+		r.apiKey = apiKey
+	}
+}
+
+// WithAPIRerankBaseURL sets the API base URL.
+func WithAPIRerankBaseURL(baseURL string) APIRerankOption {
+	return func(r *APIRerank) {
+		r.baseURL = baseURL
+	}
+}
+
+// WithAPIRerankModel sets the rerank model.
+func WithAPIRerankModel(model string) APIRerankOption {
+	return func(r *APIRerank) {
+		r.model = model
+	}
+}
+
+// WithAPIRerankTopN sets the number of top nodes to return.
+func WithAPIRerankTopN(n int) APIRerankOption {
+	return func(r *APIRerank) {
+		r.topN = n
+	}
+}
+
+// WithAPIRerankBatchSize sets the number of nodes sent per request.
+func WithAPIRerankBatchSize(size int) APIRerankOption {
+	return func(r *APIRerank) {
+		r.batchSize = size
+	}
+}
+
+// WithAPIRerankMaxRetries sets how many times a failed request is retried.
+func WithAPIRerankMaxRetries(n int) APIRerankOption {
+	return func(r *APIRerank) {
+		r.maxRetries = n
+	}
+}
+
+// WithAPIRerankRetryDelay sets the initial retry delay, doubled after each
+// attempt. A Retry-After header takes precedence.
+func WithAPIRerankRetryDelay(delay time.Duration) APIRerankOption {
+	return func(r *APIRerank) {
+		r.retryDelay = delay
+	}
+}
+
+// WithAPIRerankNormalization sets the score normalization.
+func WithAPIRerankNormalization(normalization RerankScoreNormalization) APIRerankOption {
+	return func(r *APIRerank) {
+		r.normalization = normalization
+	}
+}
+
+// WithAPIRerankHTTPClient sets a custom HTTP client.
+func WithAPIRerankHTTPClient(client *http.Client) APIRerankOption {
+	return func(r *APIRerank) {
+		r.httpClient = client
+	}
+}
+
+// WithAPIRerankCredentials resolves the API key from a credentials provider
+// on every request, under the provider's environment variable name. It takes
+// precedence over the static API key. When the API responds 401 the key is
+// invalidated and the request retried once.
+func WithAPIRerankCredentials(provider credentials.Provider) APIRerankOption {
+	return func(r *APIRerank) {
+		r.credentials = provider
+	}
+}
+
+// WithAPIRerankExplain records each result's relevance score, raw API score
+// and previous score in its score explanation (see
+// schema.ScoreExplanationMetadataKey).
+func WithAPIRerankExplain(explain bool) APIRerankOption {
+	return func(r *APIRerank) {
+		r.explain = explain
+	}
+}
+
+// NewAPIRerank creates a new APIRerank for the given provider.
+func NewAPIRerank(provider RerankProvider, opts ...APIRerankOption) (*APIRerank, error) {
+	config, ok := rerankProviders[provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown rerank provider: %s", provider)
+	}
+
+	r := &APIRerank{
+		BaseNodePostprocessor: NewBaseNodePostprocessor(WithPostprocessorName(config.name)),
+		provider:              provider,
+		config:                config,
+		apiKey:                os.Getenv(config.apiKeyEnv),
+		baseURL:               config.baseURL,
+		model:                 config.model,
+		topN:                  5,
+		batchSize:             100,
+		maxRetries:            3,
+		retryDelay:            time.Second,
+		normalization:         RerankScoreNormalizationNone,
+		httpClient:            http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.credentials != nil {
+		r.httpClient = credentials.NewHTTPClient(r.httpClient, r.credentials, config.apiKeyEnv, credentials.BearerAuth)
+	}
+
+	return r, nil
+}
+
+// CohereRerank reranks nodes with the Cohere rerank API.
+type CohereRerank = APIRerank
+
+// CohereRerankOption configures a CohereRerank.
+type CohereRerankOption = APIRerankOption
+
+// WithCohereAPIKey sets the Cohere API key.
+func WithCohereAPIKey(key string) CohereRerankOption {
+	return WithAPIRerankAPIKey(key)
+}
+
+// WithCohereModel sets the Cohere rerank model.
+func WithCohereModel(model string) CohereRerankOption {
+	return WithAPIRerankModel(model)
+}
+
+// WithCohereTopN sets the number of top nodes to return.
+func WithCohereTopN(n int) CohereRerankOption {
+	return WithAPIRerankTopN(n)
+}
+
+// NewCohereRerank creates a reranker using the Cohere rerank API.
+func NewCohereRerank(opts ...CohereRerankOption) *CohereRerank {
+	r, _ := NewAPIRerank(RerankProviderCohere, opts...)
+	return r
+}
+
+// NewJinaRerank creates a reranker using the Jina AI reranker API.
+func NewJinaRerank(opts ...APIRerankOption) *APIRerank {
+	r, _ := NewAPIRerank(RerankProviderJina, opts...)
+	return r
+}
+
+// NewVoyageRerank creates a reranker using the Voyage AI rerank API.
+func NewVoyageRerank(opts ...APIRerankOption) *APIRerank {
+	r, _ := NewAPIRerank(RerankProviderVoyage, opts...)
+	return r
+}
+
+// Provider returns the rerank provider.
+func (r *APIRerank) Provider() RerankProvider {
+	return r.provider
+}
+
+// Model returns the rerank model.
+func (r *APIRerank) Model() string {
+	return r.model
+}
+
+// PostprocessNodes reranks nodes with the rerank API.
+func (r *APIRerank) PostprocessNodes(
+	ctx context.Context,
+	nodes []schema.NodeWithScore,
+	queryBundle *schema.QueryBundle,
+) ([]schema.NodeWithScore, error) {
+	if queryBundle == nil {
+		return nil, fmt.Errorf("query bundle must be provided")
+	}
+	if len(nodes) == 0 {
+		return []schema.NodeWithScore{}, nil
+	}
+
+	batchSize := r.batchSize
+	if batchSize <= 0 {
+		batchSize = len(nodes)
+	}
+
+	reranked := make([]schema.NodeWithScore, 0, len(nodes))
+	var previous []float64
+	for start := 0; start < len(nodes); start += batchSize {
+		end := start + batchSize
+		if end > len(nodes) {
+			end = len(nodes)
+		}
+		batch := nodes[start:end]
+
+		documents := make([]string, len(batch))
+		for i, n := range batch {
+			documents[i] = n.Node.GetContent(schema.MetadataModeNone)
+		}
+
+		results, err := r.rerank(ctx, queryBundle.QueryString, documents)
+		if err != nil {
+			return nil, err
+		}
+		for _, res := range results {
+			if res.Index < 0 || res.Index >= len(batch) {
+				continue
+			}
+			reranked = append(reranked, schema.NodeWithScore{
+				Node:  batch[res.Index].Node,
+				Score: res.RelevanceScore,
+			})
+			previous = append(previous, batch[res.Index].Score)
+		}
+	}
+
+	var raw []float64
+	if r.explain {
+		raw = make([]float64, len(reranked))
+		for i, n := range reranked {
+			raw[i] = n.Score
+		}
+	}
+	normalizeRerankScores(reranked, r.normalization)
+	for i := range raw {
+		reranked[i].ExplainScore(schema.ScoreComponent{
+			Name:    schema.ScoreComponentRerank,
+			Score:   reranked[i].Score,
+			Details: map[string]float64{"relevance_score": raw[i], "previous_score": previous[i]},
+			Note:    string(r.provider) + "/" + r.model,
+		})
+	}
+
+	sort.SliceStable(reranked, func(i, j int) bool {
+		return reranked[i].Score > reranked[j].Score
+	})
+
+	if r.topN > 0 && len(reranked) > r.topN {
+		reranked = reranked[:r.topN]
+	}
+
+	return reranked, nil
+}
+
+// rerankResult is a single result of a rerank API.
+type rerankResult struct {
+	Index          int     `json:"index"`
+	RelevanceScore float64 `json:"relevance_score"`
+}
+
+// rerank scores one batch of documents, retrying transient failures.
+func (r *APIRerank) rerank(ctx context.Context, query string, documents []string) ([]rerankResult, error) {
+	body := map[string]interface{}{
+		"model":            r.model,
+		"query":            query,
+		"documents":        documents,
+		r.config.topNField: len(documents),
+	}
+	if r.provider == RerankProviderCohere {
+		body["return_documents"] = false
+	}
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	delay := r.retryDelay
+	for attempt := 0; ; attempt++ {
+		results, retryAfter, err := r.doRequest(ctx, jsonBody)
+		if err == nil {
+			return results, nil
+		}
+		if retryAfter < 0 || attempt >= r.maxRetries {
+			return nil, err
+		}
+
+		wait := delay
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		if err := httputil.Sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+		delay *= 2
+	}
+}
+
+// doRequest sends one rerank request. A negative retryAfter marks errors
+// that should not be retried; a positive one is the server's Retry-After.
+func (r *APIRerank) doRequest(ctx context.Context, jsonBody []byte) ([]rerankResult, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", r.baseURL+"/rerank", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, -1, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.credentials == nil {
+		if r.apiKey == "" {
+			return nil, -1, fmt.Errorf("%s API key must be provided", r.provider)
+		}
+		req.Header.Set("Authorization", "Bearer "+r.apiKey)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, -1, ctx.Err()
+		}
+		return nil, 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := fmt.Errorf("%s API error (status %d): %s", r.provider, resp.StatusCode, string(respBody))
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			retryAfter, _ := httputil.RetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			return nil, retryAfter, apiErr
+		}
+		return nil, -1, apiErr
+	}
+
+	var parsed map[string]json.RawMessage
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return nil, -1, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	var results []rerankResult
+	if raw, ok := parsed[r.config.resultsField]; ok {
+		if err := json.Unmarshal(raw, &results); err != nil {
+			return nil, -1, fmt.Errorf("failed to unmarshal results: %w", err)
+		}
+	}
+	return results, 0, nil
+}
+
+// normalizeRerankScores normalizes scores in place.
+func normalizeRerankScores(nodes []schema.NodeWithScore, normalization RerankScoreNormalization) {
+	switch normalization {
+	case RerankScoreNormalizationSigmoid:
+		for i := range nodes {
+			nodes[i].Score = 1 / (1 + math.Exp(-nodes[i].Score))
+		}
+	case RerankScoreNormalizationMinMax:
+		if len(nodes) == 0 {
+			return
+		}
+		minScore, maxScore := nodes[0].Score, nodes[0].Score
+		for _, n := range nodes[1:] {
+			minScore = math.Min(minScore, n.Score)
+			maxScore = math.Max(maxScore, n.Score)
+		}
+		for i := range nodes {
+			if maxScore == minScore {
+				nodes[i].Score = 1
+			} else {
+				nodes[i].Score = (nodes[i].Score - minScore) / (maxScore - minScore)
+			}
+		}
+	}
+}
+
+// Ensure APIRerank implements NodePostprocessor.
+var _ NodePostprocessor = (*APIRerank)(nil)
@@ -10,6 +10,7 @@ import (
 
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 // DefaultChoiceSelectPrompt is the default prompt for LLM-based reranking.
@@ -56,6 +57,7 @@ type LLMRerank struct {
 	choiceSelectPrompt        string
 	formatNodeBatchFn         func([]*schema.Node) string
 	parseChoiceSelectAnswerFn func(string, int) ([]ChoiceWithRelevance, error)
+	explain                   bool
 }
 
 // LLMRerankOption configures an LLMRerank.
@@ -103,6 +105,14 @@ func WithLLMRerankParseFn(fn func(string, int) ([]ChoiceWithRelevance, error)) L
 	}
 }
 
+// WithLLMRerankExplain records each result's LLM relevance and previous
+// score in its score explanation (see schema.ScoreExplanationMetadataKey).
+func WithLLMRerankExplain(explain bool) LLMRerankOption {
+	return func(r *LLMRerank) {
+		r.explain = explain
+	}
+}
+
 // NewLLMRerank creates a new LLMRerank.
 func NewLLMRerank(opts ...LLMRerankOption) *LLMRerank {
 	r := &LLMRerank{
@@ -171,10 +181,8 @@ func (r *LLMRerank) PostprocessNodes(
 		if err != nil {
 			// If parsing fails, keep original order for this batch
 			for i, n := range nodes[idx:end] {
-				initialResults = append(initialResults, schema.NodeWithScore{
-					Node:  n.Node,
-					Score: float64(len(nodes) - idx - i), // Decreasing scores
-				})
+				// Decreasing scores
+				initialResults = append(initialResults, r.result(n.Node, float64(len(nodes)-idx-i), n.Score, "unparsed answer, original order kept"))
 			}
 			continue
 		}
@@ -182,10 +190,7 @@ func (r *LLMRerank) PostprocessNodes(
 		// Add chosen nodes with relevance scores
 		for _, choice := range choices {
 			if choice.DocIndex >= 0 && choice.DocIndex < len(nodesBatch) {
-				initialResults = append(initialResults, schema.NodeWithScore{
-					Node:  *nodesBatch[choice.DocIndex],
-					Score: choice.Relevance,
-				})
+				initialResults = append(initialResults, r.result(*nodesBatch[choice.DocIndex], choice.Relevance, nodes[idx+choice.DocIndex].Score, ""))
 			}
 		}
 	}
@@ -202,6 +207,20 @@ func (r *LLMRerank) PostprocessNodes(
 	return initialResults, nil
 }
 
+// result builds a reranked node, explaining its score if enabled.
+func (r *LLMRerank) result(node schema.Node, score, previous float64, note string) schema.NodeWithScore {
+	n := schema.NodeWithScore{Node: node, Score: score}
+	if r.explain {
+		n.ExplainScore(schema.ScoreComponent{
+			Name:    schema.ScoreComponentRerank,
+			Score:   score,
+			Details: map[string]float64{"llm_relevance": score, "previous_score": previous},
+			Note:    note,
+		})
+	}
+	return n
+}
+
 // defaultFormatNodeBatch formats a batch of nodes for the LLM prompt.
 func defaultFormatNodeBatch(nodes []*schema.Node) string {
 	var builder strings.Builder
@@ -209,9 +228,7 @@ func defaultFormatNodeBatch(nodes []*schema.Node) string {
 	for i, node := range nodes {
 		content := node.GetContent(schema.MetadataModeNone)
 		// Truncate long content
-		if len(content) > 500 {
-			content = content[:500] + "..."
-		}
+		content = textutil.Truncate(content, 500)
 		builder.WriteString(fmt.Sprintf("Document %d:\n%s\n\n", i+1, content))
 	}
 
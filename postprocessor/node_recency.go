@@ -45,6 +45,9 @@ type NodeRecencyPostprocessor struct {
 	SortByDate bool
 	// Now is the reference time (defaults to time.Now()).
 	Now func() time.Time
+	// Explain records the recency weight in each node's score explanation
+	// (see schema.ScoreExplanationMetadataKey).
+	Explain bool
 }
 
 // NodeRecencyOption configures a NodeRecencyPostprocessor.
@@ -115,6 +118,13 @@ func WithRecencyNowFunc(fn func() time.Time) NodeRecencyOption {
 	}
 }
 
+// WithRecencyExplain enables score explanations.
+func WithRecencyExplain(explain bool) NodeRecencyOption {
+	return func(p *NodeRecencyPostprocessor) {
+		p.Explain = explain
+	}
+}
+
 // NewNodeRecencyPostprocessor creates a new NodeRecencyPostprocessor.
 func NewNodeRecencyPostprocessor(opts ...NodeRecencyOption) *NodeRecencyPostprocessor {
 	p := &NodeRecencyPostprocessor{
@@ -174,11 +184,28 @@ func (p *NodeRecencyPostprocessor) PostprocessNodes(
 		// Apply time-based weight adjustment
 		adjustedScore := p.adjustScore(nodeWithScore.Score, nodeTime, now)
 
+		adjusted := schema.NodeWithScore{
+			Node:  nodeWithScore.Node,
+			Score: adjustedScore,
+		}
+		if p.Explain {
+			details := map[string]float64{
+				"previous_score": nodeWithScore.Score,
+				"age_hours":      now.Sub(nodeTime).Hours(),
+			}
+			if nodeWithScore.Score != 0 {
+				details["weight"] = adjustedScore / nodeWithScore.Score
+			}
+			adjusted.ExplainScore(schema.ScoreComponent{
+				Name:    schema.ScoreComponentRecency,
+				Score:   adjustedScore,
+				Details: details,
+				Note:    "mode=" + string(p.TimeWeightMode),
+			})
+		}
+
 		nodesWithTime = append(nodesWithTime, nodeWithTime{
-			node: schema.NodeWithScore{
-				Node:  nodeWithScore.Node,
-				Score: adjustedScore,
-			},
+			node: adjusted,
 			time: nodeTime,
 		})
 	}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/aqua777/go-llamaindex/embedding"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 // SentenceOptimizerPostprocessor optimizes node content by selecting
@@ -381,7 +382,7 @@ func (p *TextCompressorPostprocessor) compressText(text string) string {
 	// Truncate if too long
 	if p.MaxLength > 0 && len(text) > p.MaxLength {
 		// Try to truncate at sentence boundary
-		truncated := text[:p.MaxLength]
+		truncated := textutil.TruncateBytes(text, p.MaxLength)
 		lastPeriod := strings.LastIndex(truncated, ".")
 		if lastPeriod > p.MaxLength/2 {
 			truncated = truncated[:lastPeriod+1]
@@ -2,7 +2,12 @@ package postprocessor
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aqua777/go-llamaindex/llm"
 	"github.com/aqua777/go-llamaindex/schema"
@@ -545,6 +550,24 @@ func TestLLMRerank(t *testing.T) {
 		require.NoError(t, err)
 		assert.Len(t, result, 2)
 	})
+
+	t.Run("Explains scores", func(t *testing.T) {
+		mockLLM := NewMockLLM("Doc: 2, Relevance: 9\nDoc: 1, Relevance: 5")
+		pp := NewLLMRerank(WithLLMRerankLLM(mockLLM), WithLLMRerankExplain(true))
+
+		nodes := []schema.NodeWithScore{
+			createTestNode("1", "First document", 0.5),
+			createTestNode("2", "Second document", 0.3),
+		}
+		result, err := pp.PostprocessNodes(ctx, nodes, &schema.QueryBundle{QueryString: "test query"})
+
+		require.NoError(t, err)
+		explanation := result[0].ScoreExplanation()
+		require.Len(t, explanation, 1)
+		assert.Equal(t, schema.ScoreComponentRerank, explanation[0].Name)
+		assert.Equal(t, 9.0, explanation[0].Details["llm_relevance"])
+		assert.Equal(t, 0.3, explanation[0].Details["previous_score"])
+	})
 }
 
 // TestRankGPTRerank tests the RankGPTRerank postprocessor.
@@ -872,3 +895,206 @@ func TestDefaultFormatNodeBatch(t *testing.T) {
 		assert.Equal(t, "", result)
 	})
 }
+
+// TestTemporalPostprocessor tests as-of filtering and down-weighting.
+func TestTemporalPostprocessor(t *testing.T) {
+	ctx := context.Background()
+	nodes := []schema.NodeWithScore{
+		createTestNodeWithMetadata("v1", "Policy v1", 0.9, map[string]interface{}{
+			schema.ValidFromKey: "2020-01-01", schema.ValidToKey: "2022-01-01",
+		}),
+		createTestNodeWithMetadata("v2", "Policy v2", 0.8, map[string]interface{}{
+			schema.ValidFromKey: "2022-01-01",
+		}),
+		createTestNodeWithMetadata("timeless", "Glossary", 0.5, map[string]interface{}{}),
+	}
+	ids := func(nodes []schema.NodeWithScore) []string {
+		out := make([]string, len(nodes))
+		for i, n := range nodes {
+			out[i] = n.Node.ID
+		}
+		return out
+	}
+
+	t.Run("no reference time", func(t *testing.T) {
+		result, err := NewTemporalPostprocessor().PostprocessNodes(ctx, nodes, &schema.QueryBundle{QueryString: "q"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"v1", "v2", "timeless"}, ids(result))
+	})
+
+	t.Run("filter by query as-of", func(t *testing.T) {
+		query := schema.NewQueryBundle("q", schema.AsOf(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)))
+		result, err := NewTemporalPostprocessor().PostprocessNodes(ctx, nodes, &query)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"v1", "timeless"}, ids(result))
+	})
+
+	t.Run("valid_to is exclusive", func(t *testing.T) {
+		p := NewTemporalPostprocessor(WithTemporalAsOf(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)))
+		result, err := p.PostprocessNodes(ctx, nodes, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"v2", "timeless"}, ids(result))
+	})
+
+	t.Run("down-weight", func(t *testing.T) {
+		p := NewTemporalPostprocessor(WithTemporalMode(TemporalModeDownWeight), WithTemporalPenalty(0.1))
+		query := schema.NewQueryBundle("q", schema.AsOf(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)))
+		result, err := p.PostprocessNodes(ctx, nodes, &query)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"v2", "timeless", "v1"}, ids(result))
+		assert.InDelta(t, 0.09, result[2].Score, 1e-9)
+	})
+}
+
+func TestAPIRerank(t *testing.T) {
+	ctx := context.Background()
+	query := &schema.QueryBundle{QueryString: "capital of france"}
+	nodes := []schema.NodeWithScore{
+		{Node: *schema.NewTextNode("Berlin is in Germany"), Score: 0.9},
+		{Node: *schema.NewTextNode("Paris is the capital of France"), Score: 0.5},
+		{Node: *schema.NewTextNode("France is in Europe"), Score: 0.4},
+	}
+	scores := map[string]float64{
+		"Berlin is in Germany":           0.1,
+		"Paris is the capital of France": 0.95,
+		"France is in Europe":            0.3,
+	}
+
+	newServer := func(resultsField string, failures int) (*httptest.Server, *[]map[string]interface{}) {
+		var requests []map[string]interface{}
+		var mu sync.Mutex
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, "/rerank", r.URL.Path)
+			assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+			if failures > 0 {
+				failures--
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			requests = append(requests, body)
+
+			var results []map[string]interface{}
+			for i, doc := range body["documents"].([]interface{}) {
+				results = append(results, map[string]interface{}{"index": i, "relevance_score": scores[doc.(string)]})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{resultsField: results})
+		}))
+		return server, &requests
+	}
+
+	t.Run("Cohere with batching", func(t *testing.T) {
+		server, requests := newServer("results", 0)
+		defer server.Close()
+
+		rerank := NewCohereRerank(
+			WithAPIRerankAPIKey("test-key"),
+			WithAPIRerankBaseURL(server.URL),
+			WithAPIRerankBatchSize(2),
+			WithAPIRerankTopN(2),
+		)
+		assert.Equal(t, "CohereRerank", rerank.Name())
+
+		result, err := rerank.PostprocessNodes(ctx, nodes, query)
+		require.NoError(t, err)
+		require.Len(t, result, 2)
+		assert.Equal(t, "Paris is the capital of France", result[0].Node.Text)
+		assert.Equal(t, 0.95, result[0].Score)
+		assert.Equal(t, "France is in Europe", result[1].Node.Text)
+
+		require.Len(t, *requests, 2)
+		assert.Equal(t, CohereRerankEnglishV3, (*requests)[0]["model"])
+		assert.Equal(t, "capital of france", (*requests)[0]["query"])
+		assert.Equal(t, float64(2), (*requests)[0]["top_n"])
+	})
+
+	t.Run("Voyage retries and normalizes", func(t *testing.T) {
+		server, requests := newServer("data", 1)
+		defer server.Close()
+
+		rerank := NewVoyageRerank(
+			WithAPIRerankAPIKey("test-key"),
+			WithAPIRerankBaseURL(server.URL),
+			WithAPIRerankRetryDelay(time.Millisecond),
+			WithAPIRerankNormalization(RerankScoreNormalizationMinMax),
+		)
+
+		result, err := rerank.PostprocessNodes(ctx, nodes, query)
+		require.NoError(t, err)
+		require.Len(t, result, 3)
+		assert.Equal(t, 1.0, result[0].Score)
+		assert.Equal(t, 0.0, result[2].Score)
+		assert.Equal(t, "Berlin is in Germany", result[2].Node.Text)
+		require.Len(t, *requests, 1)
+		assert.Equal(t, float64(3), (*requests)[0]["top_k"])
+	})
+
+	t.Run("explains scores", func(t *testing.T) {
+		server, _ := newServer("results", 0)
+		defer server.Close()
+
+		rerank := NewJinaRerank(
+			WithAPIRerankAPIKey("test-key"),
+			WithAPIRerankBaseURL(server.URL),
+			WithAPIRerankNormalization(RerankScoreNormalizationMinMax),
+			WithAPIRerankExplain(true),
+		)
+		result, err := rerank.PostprocessNodes(ctx, nodes, query)
+		require.NoError(t, err)
+		explanation := result[0].ScoreExplanation()
+		require.Len(t, explanation, 1)
+		assert.Equal(t, schema.ScoreComponentRerank, explanation[0].Name)
+		assert.Equal(t, 1.0, explanation[0].Score)
+		assert.Equal(t, 0.95, explanation[0].Details["relevance_score"])
+		assert.Equal(t, 0.5, explanation[0].Details["previous_score"])
+		assert.Equal(t, "jina/"+rerank.Model(), explanation[0].Note)
+		assert.Nil(t, nodes[1].ScoreExplanation())
+	})
+
+	t.Run("Jina gives up after retries", func(t *testing.T) {
+		server, _ := newServer("results", 10)
+		defer server.Close()
+
+		rerank := NewJinaRerank(
+			WithAPIRerankAPIKey("test-key"),
+			WithAPIRerankBaseURL(server.URL),
+			WithAPIRerankMaxRetries(1),
+			WithAPIRerankRetryDelay(time.Millisecond),
+		)
+		_, err := rerank.PostprocessNodes(ctx, nodes, query)
+		assert.ErrorContains(t, err, "status 429")
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		_, err := NewAPIRerank("acme")
+		assert.Error(t, err)
+	})
+}
+
+func TestNodeRecencyExplain(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	pp := NewNodeRecencyPostprocessor(
+		WithRecencyTimeWeightMode(TimeWeightModeStep),
+		WithRecencyStepThreshold(24*time.Hour, 1.0, 0.5),
+		WithRecencyNowFunc(func() time.Time { return now }),
+		WithRecencyExplain(true),
+	)
+	old := createTestNodeWithMetadata("old", "Old", 0.8, map[string]interface{}{"date": "2025-03-07T12:00:00Z"})
+	old.ExplainScore(schema.ScoreComponent{Name: schema.ScoreComponentVectorSimilarity, Score: 0.8})
+
+	result, err := pp.PostprocessNodes(context.Background(), []schema.NodeWithScore{old}, nil)
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	explanation := result[0].ScoreExplanation()
+	require.Len(t, explanation, 2)
+	assert.Equal(t, schema.ScoreComponentRecency, explanation[1].Name)
+	assert.Equal(t, 0.4, explanation[1].Score)
+	assert.Equal(t, 0.5, explanation[1].Details["weight"])
+	assert.Equal(t, 72.0, explanation[1].Details["age_hours"])
+	assert.Equal(t, 0.8, explanation[1].Details["previous_score"])
+	assert.Equal(t, "mode=step", explanation[1].Note)
+}
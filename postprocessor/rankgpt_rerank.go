@@ -3,7 +3,6 @@ package postprocessor
 import (
 	"context"
 	"fmt"
-	"sort"
 	"strconv"
 	"strings"
 	"unicode"
@@ -352,80 +351,3 @@ func min(a, b int) int {
 
 // Ensure SlidingWindowRankGPT implements NodePostprocessor.
 var _ NodePostprocessor = (*SlidingWindowRankGPT)(nil)
-
-// CohereRerank is a placeholder for Cohere-based reranking.
-// This would require the Cohere API client.
-type CohereRerank struct {
-	*BaseNodePostprocessor
-	apiKey string
-	model  string
-	topN   int
-}
-
-// CohereRerankOption configures a CohereRerank.
-type CohereRerankOption func(*CohereRerank)
-
-// WithCohereAPIKey sets the API key.
-func WithCohereAPIKey(key string) CohereRerankOption {
-	return func(r *CohereRerank) {
-		r.apiKey = key
-	}
-}
-
-// WithCohereModel sets the model.
-func WithCohereModel(model string) CohereRerankOption {
-	return func(r *CohereRerank) {
-		r.model = model
-	}
-}
-
-// WithCohereTopN sets the number of top nodes to return.
-func WithCohereTopN(n int) CohereRerankOption {
-	return func(r *CohereRerank) {
-		r.topN = n
-	}
-}
-
-// NewCohereRerank creates a new CohereRerank.
-func NewCohereRerank(opts ...CohereRerankOption) *CohereRerank {
-	r := &CohereRerank{
-		BaseNodePostprocessor: NewBaseNodePostprocessor(WithPostprocessorName("CohereRerank")),
-		model:                 "rerank-english-v2.0",
-		topN:                  5,
-	}
-
-	for _, opt := range opts {
-		opt(r)
-	}
-
-	return r
-}
-
-// PostprocessNodes reranks nodes using Cohere.
-// Note: This is a placeholder implementation. Full implementation would require Cohere API client.
-func (r *CohereRerank) PostprocessNodes(
-	ctx context.Context,
-	nodes []schema.NodeWithScore,
-	queryBundle *schema.QueryBundle,
-) ([]schema.NodeWithScore, error) {
-	if r.apiKey == "" {
-		return nil, fmt.Errorf("Cohere API key must be provided")
-	}
-
-	// Placeholder: In a full implementation, this would call the Cohere rerank API
-	// For now, return nodes sorted by existing score
-	result := make([]schema.NodeWithScore, len(nodes))
-	copy(result, nodes)
-
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].Score > result[j].Score
-	})
-
-	if len(result) > r.topN {
-		return result[:r.topN], nil
-	}
-	return result, nil
-}
-
-// Ensure CohereRerank implements NodePostprocessor.
-var _ NodePostprocessor = (*CohereRerank)(nil)
@@ -0,0 +1,111 @@
+package postprocessor
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// TemporalMode determines how nodes outside the requested time are handled.
+type TemporalMode string
+
+const (
+	// TemporalModeFilter drops nodes not valid at the requested time.
+	TemporalModeFilter TemporalMode = "filter"
+	// TemporalModeDownWeight multiplies the score of nodes not valid at the
+	// requested time by the penalty factor.
+	TemporalModeDownWeight TemporalMode = "down_weight"
+)
+
+// TemporalPostprocessor applies as-of semantics to retrieved nodes using the
+// valid-time metadata (schema.ValidFromKey, schema.ValidToKey). The reference
+// time is taken from the query bundle's AsOf, falling back to the configured
+// AsOf; without either, nodes are returned unchanged. Nodes without
+// valid-time metadata are always considered valid.
+type TemporalPostprocessor struct {
+	*BaseNodePostprocessor
+	// AsOf is the default reference time when the query does not set one.
+	AsOf *time.Time
+	// Mode determines whether invalid nodes are dropped or down-weighted.
+	Mode TemporalMode
+	// Penalty is the score multiplier for invalid nodes in down-weight mode.
+	Penalty float64
+}
+
+// TemporalOption configures a TemporalPostprocessor.
+type TemporalOption func(*TemporalPostprocessor)
+
+// WithTemporalAsOf sets the default reference time.
+func WithTemporalAsOf(t time.Time) TemporalOption {
+	return func(p *TemporalPostprocessor) {
+		p.AsOf = &t
+	}
+}
+
+// WithTemporalMode sets the handling mode.
+func WithTemporalMode(mode TemporalMode) TemporalOption {
+	return func(p *TemporalPostprocessor) {
+		p.Mode = mode
+	}
+}
+
+// WithTemporalPenalty sets the score multiplier used in down-weight mode.
+func WithTemporalPenalty(penalty float64) TemporalOption {
+	return func(p *TemporalPostprocessor) {
+		p.Penalty = penalty
+	}
+}
+
+// NewTemporalPostprocessor creates a new TemporalPostprocessor.
+func NewTemporalPostprocessor(opts ...TemporalOption) *TemporalPostprocessor {
+	p := &TemporalPostprocessor{
+		BaseNodePostprocessor: NewBaseNodePostprocessor(WithPostprocessorName("TemporalPostprocessor")),
+		Mode:                  TemporalModeFilter,
+		Penalty:               0.5,
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// PostprocessNodes filters or down-weights nodes not valid at the reference time.
+func (p *TemporalPostprocessor) PostprocessNodes(
+	ctx context.Context,
+	nodes []schema.NodeWithScore,
+	queryBundle *schema.QueryBundle,
+) ([]schema.NodeWithScore, error) {
+	asOf := p.AsOf
+	if queryBundle != nil && queryBundle.AsOf != nil {
+		asOf = queryBundle.AsOf
+	}
+	if asOf == nil {
+		return nodes, nil
+	}
+
+	result := make([]schema.NodeWithScore, 0, len(nodes))
+	for _, n := range nodes {
+		if schema.IsValidAt(n.Node.Metadata, *asOf) {
+			result = append(result, n)
+			continue
+		}
+		if p.Mode == TemporalModeDownWeight {
+			result = append(result, schema.NodeWithScore{Node: n.Node, Score: n.Score * p.Penalty})
+		}
+	}
+
+	if p.Mode == TemporalModeDownWeight {
+		sort.SliceStable(result, func(i, j int) bool {
+			return result[i].Score > result[j].Score
+		})
+	}
+
+	return result, nil
+}
+
+// Ensure TemporalPostprocessor implements NodePostprocessor.
+var _ NodePostprocessor = (*TemporalPostprocessor)(nil)
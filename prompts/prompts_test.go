@@ -1,9 +1,11 @@
 package prompts
 
 import (
+	"context"
 	"testing"
 
 	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/requestctx"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -242,3 +244,31 @@ func TestPromptTemplateMetadata(t *testing.T) {
 	assert.Equal(t, "1.0", pt.GetMetadata()["version"])
 	assert.Equal(t, "test", pt.GetMetadata()["author"])
 }
+
+func TestFormatContext(t *testing.T) {
+	ctx := requestctx.WithValues(context.Background(), map[string]string{
+		requestctx.LocaleKey:  "de-DE",
+		requestctx.ChannelKey: "slack",
+		"query_str":           "injected",
+	})
+
+	pt := NewPromptTemplate("[{locale}/{channel}/{user_id}] {query_str}", PromptTypeCustom)
+	assert.Equal(t, "[{locale}/{channel}/{user_id}] hi", FormatContext(ctx, pt, map[string]string{"query_str": "hi"}),
+		"templates without request vars ignore the context")
+
+	allowed := pt.WithRequestVars(requestctx.LocaleKey, requestctx.UserIDKey, "query_str")
+	assert.Empty(t, pt.RequestVars)
+	assert.Equal(t, "[de-DE/{channel}/] hi", FormatContext(ctx, allowed, map[string]string{"query_str": "hi"}),
+		"explicit vars win and missing allowed keys are cleared")
+
+	partial := allowed.PartialFormat(map[string]string{"query_str": "partial"})
+	assert.Equal(t, "[de-DE/{channel}/] partial", FormatContext(ctx, partial, nil))
+
+	chat := NewChatPromptTemplate([]llm.ChatMessage{
+		llm.NewChatMessage(llm.MessageRoleSystem, "Reply in {locale}."),
+		llm.NewChatMessage(llm.MessageRoleUser, "{query_str}"),
+	}, PromptTypeCustom).WithRequestVars(requestctx.LocaleKey)
+	messages := FormatMessagesContext(ctx, chat, map[string]string{"query_str": "hi"})
+	assert.Equal(t, "Reply in de-DE.", messages[0].Content)
+	assert.Equal(t, "hi", messages[1].Content)
+}
@@ -0,0 +1,90 @@
+package prompts
+
+import (
+	"context"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/requestctx"
+)
+
+// RequestVarsTemplate is a prompt template that can reference request
+// metadata carried by a context.
+type RequestVarsTemplate interface {
+	BasePromptTemplate
+	// GetRequestVars returns the request metadata keys the template may
+	// reference.
+	GetRequestVars() []string
+}
+
+// GetRequestVars returns the request metadata keys the template may
+// reference.
+func (pt *PromptTemplate) GetRequestVars() []string {
+	return pt.RequestVars
+}
+
+// WithRequestVars returns a copy of the template that may reference the
+// given request metadata keys, e.g. requestctx.LocaleKey as {locale}.
+func (pt *PromptTemplate) WithRequestVars(keys ...string) *PromptTemplate {
+	newPT := pt.PartialFormat(nil).(*PromptTemplate)
+	newPT.RequestVars = keys
+	return newPT
+}
+
+// GetRequestVars returns the request metadata keys the template may
+// reference.
+func (cpt *ChatPromptTemplate) GetRequestVars() []string {
+	return cpt.RequestVars
+}
+
+// WithRequestVars returns a copy of the template that may reference the
+// given request metadata keys.
+func (cpt *ChatPromptTemplate) WithRequestVars(keys ...string) *ChatPromptTemplate {
+	newCPT := cpt.PartialFormat(nil).(*ChatPromptTemplate)
+	newCPT.RequestVars = keys
+	return newCPT
+}
+
+// FormatContext formats a template like Format, also filling in the
+// request metadata of ctx for the keys the template allows. Values are
+// sanitized with requestctx.Sanitize, and explicit and partial vars take
+// precedence so that request metadata cannot replace the query or
+// retrieved context.
+func FormatContext(ctx context.Context, template BasePromptTemplate, vars map[string]string) string {
+	return template.Format(contextVars(ctx, template, vars))
+}
+
+// FormatMessagesContext formats a template like FormatMessages, also
+// filling in the allowed request metadata of ctx.
+func FormatMessagesContext(ctx context.Context, template BasePromptTemplate, vars map[string]string) []llm.ChatMessage {
+	return template.FormatMessages(contextVars(ctx, template, vars))
+}
+
+// contextVars merges the allowed request metadata of ctx under vars and
+// the template's partial vars.
+func contextVars(ctx context.Context, template BasePromptTemplate, vars map[string]string) map[string]string {
+	rt, ok := template.(RequestVarsTemplate)
+	if !ok || len(rt.GetRequestVars()) == 0 {
+		return vars
+	}
+	var partial map[string]string
+	switch t := template.(type) {
+	case *PromptTemplate:
+		partial = t.PartialVars
+	case *ChatPromptTemplate:
+		partial = t.PartialVars
+	}
+	merged := requestctx.Allowed(ctx, rt.GetRequestVars())
+	for k := range partial {
+		delete(merged, k)
+	}
+	for k, v := range vars {
+		merged[k] = v
+	}
+	return merged
+}
+
+// Ensure the templates implement RequestVarsTemplate.
+var (
+	_ RequestVarsTemplate = (*PromptTemplate)(nil)
+	_ RequestVarsTemplate = (*ChatPromptTemplate)(nil)
+)
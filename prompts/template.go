@@ -64,6 +64,9 @@ type PromptTemplate struct {
 	Metadata map[string]interface{}
 	// PartialVars are pre-filled variables.
 	PartialVars map[string]string
+	// RequestVars are the request metadata keys (see requestctx) that
+	// FormatContext may fill in, e.g. "locale".
+	RequestVars []string
 }
 
 // NewPromptTemplate creates a new PromptTemplate.
@@ -123,6 +126,7 @@ func (pt *PromptTemplate) PartialFormat(vars map[string]string) BasePromptTempla
 		PromptType:   pt.PromptType,
 		Metadata:     pt.Metadata,
 		PartialVars:  make(map[string]string),
+		RequestVars:  pt.RequestVars,
 	}
 	// Copy existing partial vars
 	for k, v := range pt.PartialVars {
@@ -157,6 +161,9 @@ type ChatPromptTemplate struct {
 	Metadata map[string]interface{}
 	// PartialVars are pre-filled variables.
 	PartialVars map[string]string
+	// RequestVars are the request metadata keys (see requestctx) that
+	// FormatContext may fill in, e.g. "locale".
+	RequestVars []string
 }
 
 // NewChatPromptTemplate creates a new ChatPromptTemplate.
@@ -249,6 +256,7 @@ func (cpt *ChatPromptTemplate) PartialFormat(vars map[string]string) BasePromptT
 		PromptType:       cpt.PromptType,
 		Metadata:         cpt.Metadata,
 		PartialVars:      make(map[string]string),
+		RequestVars:      cpt.RequestVars,
 	}
 	// Copy existing partial vars
 	for k, v := range cpt.PartialVars {
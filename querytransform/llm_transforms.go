@@ -0,0 +1,251 @@
+package querytransform
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// Default prompt templates for the LLM-based transforms.
+const (
+	DefaultHyDEPromptTmpl = `Please write a passage to answer the question.
+Try to include as many key details as possible.
+
+Question: {query_str}
+
+Passage:`
+
+	DefaultRewritePromptTmpl = `Rewrite the following query so that it retrieves the most relevant documents from a search engine.
+Make it specific and self-contained, expand abbreviations, and keep the original intent.
+Write only the rewritten query.
+
+Query: {query_str}
+Rewritten query:`
+
+	DefaultStepDecomposePromptTmpl = `The original question is as follows: {query_str}
+We have an opportunity to answer some, or all of the question from a knowledge source.
+Context information for the knowledge source is provided below, as well as previous reasoning steps.
+Given the context and previous reasoning, return a question that can be answered from the context.
+This question can be the same as the original question, or this question can represent a subcomponent of the overall question.
+It should not be irrelevant to the original question.
+If we cannot extract more information from the context, provide 'None' as the answer.
+
+Question: How many Grand Slam titles does the winner of the 2020 Australian Open have?
+Knowledge source context: Provides names of the winners of the 2020 Australian Open
+Previous reasoning: None
+New question: Who was the winner of the 2020 Australian Open?
+
+Question: {query_str}
+Knowledge source context: {context_str}
+Previous reasoning: {prev_reasoning}
+New question:`
+)
+
+// HyDETransform implements Hypothetical Document Embeddings: the LLM writes
+// a passage that answers the query, and the passage is used for retrieval
+// because it tends to embed closer to relevant documents than the question.
+type HyDETransform struct {
+	// LLM writes the hypothetical document.
+	LLM llm.LLM
+	// Prompt is the template for the hypothetical document.
+	Prompt prompts.BasePromptTemplate
+	// IncludeOriginal keeps the original query ahead of the passage.
+	IncludeOriginal bool
+}
+
+// HyDETransformOption configures a HyDETransform.
+type HyDETransformOption func(*HyDETransform)
+
+// WithHyDEPrompt sets the hypothetical document prompt.
+func WithHyDEPrompt(prompt prompts.BasePromptTemplate) HyDETransformOption {
+	return func(t *HyDETransform) {
+		t.Prompt = prompt
+	}
+}
+
+// WithHyDEIncludeOriginal sets whether the original query is kept. Defaults
+// to true.
+func WithHyDEIncludeOriginal(include bool) HyDETransformOption {
+	return func(t *HyDETransform) {
+		t.IncludeOriginal = include
+	}
+}
+
+// NewHyDETransform creates a new HyDETransform.
+func NewHyDETransform(llmModel llm.LLM, opts ...HyDETransformOption) *HyDETransform {
+	t := &HyDETransform{
+		LLM:             llmModel,
+		Prompt:          prompts.NewPromptTemplate(DefaultHyDEPromptTmpl, prompts.PromptTypeCustom),
+		IncludeOriginal: true,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Transform replaces the query string with a hypothetical document.
+func (t *HyDETransform) Transform(ctx context.Context, query schema.QueryBundle) (schema.QueryBundle, error) {
+	prompt := t.Prompt.Format(map[string]string{"query_str": query.QueryString})
+	passage, err := t.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return query, fmt.Errorf("failed to generate hypothetical document: %w", err)
+	}
+
+	passage = strings.TrimSpace(passage)
+	if t.IncludeOriginal {
+		passage = query.QueryString + "\n\n" + passage
+	}
+	query.QueryString = passage
+	return query, nil
+}
+
+// RewriteTransform asks the LLM to rewrite the query for better retrieval.
+type RewriteTransform struct {
+	// LLM rewrites the query.
+	LLM llm.LLM
+	// Prompt is the rewrite template.
+	Prompt prompts.BasePromptTemplate
+}
+
+// RewriteTransformOption configures a RewriteTransform.
+type RewriteTransformOption func(*RewriteTransform)
+
+// WithRewritePrompt sets the rewrite prompt.
+func WithRewritePrompt(prompt prompts.BasePromptTemplate) RewriteTransformOption {
+	return func(t *RewriteTransform) {
+		t.Prompt = prompt
+	}
+}
+
+// NewRewriteTransform creates a new RewriteTransform.
+func NewRewriteTransform(llmModel llm.LLM, opts ...RewriteTransformOption) *RewriteTransform {
+	t := &RewriteTransform{
+		LLM:    llmModel,
+		Prompt: prompts.NewPromptTemplate(DefaultRewritePromptTmpl, prompts.PromptTypeCustom),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Transform replaces the query string with the rewritten query. An empty
+// rewrite leaves the query unchanged.
+func (t *RewriteTransform) Transform(ctx context.Context, query schema.QueryBundle) (schema.QueryBundle, error) {
+	prompt := t.Prompt.Format(map[string]string{"query_str": query.QueryString})
+	rewritten, err := t.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return query, fmt.Errorf("failed to rewrite query: %w", err)
+	}
+
+	if rewritten = cleanQuery(rewritten); rewritten != "" {
+		query.QueryString = rewritten
+	}
+	return query, nil
+}
+
+// StepDecomposeTransform turns a complex query into the next simpler
+// question that a knowledge source can answer, given the reasoning so far.
+type StepDecomposeTransform struct {
+	// LLM writes the next question.
+	LLM llm.LLM
+	// Prompt is the decomposition template.
+	Prompt prompts.BasePromptTemplate
+	// IndexSummary describes the knowledge source.
+	IndexSummary string
+}
+
+// StepDecomposeTransformOption configures a StepDecomposeTransform.
+type StepDecomposeTransformOption func(*StepDecomposeTransform)
+
+// WithStepDecomposePrompt sets the decomposition prompt.
+func WithStepDecomposePrompt(prompt prompts.BasePromptTemplate) StepDecomposeTransformOption {
+	return func(t *StepDecomposeTransform) {
+		t.Prompt = prompt
+	}
+}
+
+// WithIndexSummary sets the description of the knowledge source.
+func WithIndexSummary(summary string) StepDecomposeTransformOption {
+	return func(t *StepDecomposeTransform) {
+		t.IndexSummary = summary
+	}
+}
+
+// NewStepDecomposeTransform creates a new StepDecomposeTransform.
+func NewStepDecomposeTransform(llmModel llm.LLM, opts ...StepDecomposeTransformOption) *StepDecomposeTransform {
+	t := &StepDecomposeTransform{
+		LLM:          llmModel,
+		Prompt:       prompts.NewPromptTemplate(DefaultStepDecomposePromptTmpl, prompts.PromptTypeCustom),
+		IndexSummary: "None",
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Transform replaces the query string with its first decomposition step.
+// When the LLM answers None, the query is left unchanged.
+func (t *StepDecomposeTransform) Transform(ctx context.Context, query schema.QueryBundle) (schema.QueryBundle, error) {
+	next, err := t.NextQuestion(ctx, query.QueryString, "")
+	if err != nil {
+		return query, err
+	}
+	if next != "" {
+		query.QueryString = next
+	}
+	return query, nil
+}
+
+// NextQuestion returns the next question to ask given the previous
+// reasoning, or an empty string if no more information can be extracted.
+func (t *StepDecomposeTransform) NextQuestion(ctx context.Context, query, prevReasoning string) (string, error) {
+	if strings.TrimSpace(prevReasoning) == "" {
+		prevReasoning = "None"
+	}
+	prompt := t.Prompt.Format(map[string]string{
+		"query_str":      query,
+		"context_str":    t.IndexSummary,
+		"prev_reasoning": prevReasoning,
+	})
+
+	response, err := t.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to decompose query: %w", err)
+	}
+
+	next := cleanQuery(response)
+	if strings.EqualFold(strings.TrimRight(next, "."), "none") {
+		return "", nil
+	}
+	return next, nil
+}
+
+// cleanQuery strips labels, quotes and trailing lines an LLM may add
+// around a single query.
+func cleanQuery(response string) string {
+	response = strings.TrimSpace(response)
+	if idx := strings.Index(response, "\n"); idx >= 0 {
+		response = strings.TrimSpace(response[:idx])
+	}
+	for _, label := range []string{"new question:", "rewritten query:", "query:"} {
+		if strings.HasPrefix(strings.ToLower(response), label) {
+			response = strings.TrimSpace(response[len(label):])
+			break
+		}
+	}
+	return strings.Trim(response, "\"'")
+}
+
+// Ensure the LLM transforms implement QueryTransform.
+var (
+	_ QueryTransform = (*HyDETransform)(nil)
+	_ QueryTransform = (*RewriteTransform)(nil)
+	_ QueryTransform = (*StepDecomposeTransform)(nil)
+)
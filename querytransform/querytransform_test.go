@@ -0,0 +1,121 @@
+package querytransform
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingLLM returns a fixed response and records prompts.
+type recordingLLM struct {
+	*llm.MockLLM
+	response string
+	err      error
+	prompts  []string
+}
+
+func (r *recordingLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	r.prompts = append(r.prompts, prompt)
+	return r.response, r.err
+}
+
+func newRecordingLLM(response string) *recordingLLM {
+	return &recordingLLM{MockLLM: llm.NewMockLLM(""), response: response}
+}
+
+func TestHyDETransform(t *testing.T) {
+	ctx := context.Background()
+	l := newRecordingLLM("  Paris is the capital of France.  ")
+
+	out, err := NewHyDETransform(l).Transform(ctx, schema.QueryBundle{QueryString: "capital of France?"})
+	require.NoError(t, err)
+	assert.Equal(t, "capital of France?\n\nParis is the capital of France.", out.QueryString)
+	assert.Contains(t, l.prompts[0], "Question: capital of France?")
+
+	out, err = NewHyDETransform(l, WithHyDEIncludeOriginal(false)).Transform(ctx, schema.QueryBundle{QueryString: "q"})
+	require.NoError(t, err)
+	assert.Equal(t, "Paris is the capital of France.", out.QueryString)
+
+	custom := prompts.NewPromptTemplate("Answer: {query_str}", prompts.PromptTypeCustom)
+	_, err = NewHyDETransform(l, WithHyDEPrompt(custom)).Transform(ctx, schema.QueryBundle{QueryString: "q"})
+	require.NoError(t, err)
+	assert.Equal(t, "Answer: q", l.prompts[2])
+}
+
+func TestRewriteTransform(t *testing.T) {
+	ctx := context.Background()
+
+	out, err := NewRewriteTransform(newRecordingLLM("Rewritten query: \"kubernetes pod eviction policy\"\nExplanation: ...")).
+		Transform(ctx, schema.QueryBundle{QueryString: "k8s evictions"})
+	require.NoError(t, err)
+	assert.Equal(t, "kubernetes pod eviction policy", out.QueryString)
+
+	out, err = NewRewriteTransform(newRecordingLLM("  ")).Transform(ctx, schema.QueryBundle{QueryString: "k8s evictions"})
+	require.NoError(t, err)
+	assert.Equal(t, "k8s evictions", out.QueryString, "empty rewrites keep the query")
+
+	failing := newRecordingLLM("")
+	failing.err = errors.New("boom")
+	_, err = NewRewriteTransform(failing).Transform(ctx, schema.QueryBundle{QueryString: "q"})
+	assert.Error(t, err)
+}
+
+func TestStepDecomposeTransform(t *testing.T) {
+	ctx := context.Background()
+	l := newRecordingLLM("Who was the winner of the 2020 Australian Open?")
+	tr := NewStepDecomposeTransform(l, WithIndexSummary("Tennis results"))
+
+	out, err := tr.Transform(ctx, schema.QueryBundle{QueryString: "How many titles does the 2020 winner have?"})
+	require.NoError(t, err)
+	assert.Equal(t, "Who was the winner of the 2020 Australian Open?", out.QueryString)
+	assert.Contains(t, l.prompts[0], "Knowledge source context: Tennis results\nPrevious reasoning: None")
+
+	l.response = "None."
+	next, err := tr.NextQuestion(ctx, "q", "Question: a\nAnswer: b")
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	assert.Contains(t, l.prompts[1], "Previous reasoning: Question: a")
+
+	out, err = tr.Transform(ctx, schema.QueryBundle{QueryString: "q"})
+	require.NoError(t, err)
+	assert.Equal(t, "q", out.QueryString)
+}
+
+func TestPipeline(t *testing.T) {
+	ctx := context.Background()
+	upper := TransformFunc(func(ctx context.Context, q schema.QueryBundle) (schema.QueryBundle, error) {
+		return schema.QueryBundle{QueryString: strings.ToUpper(q.QueryString)}, nil
+	})
+	suffix := TransformFunc(func(ctx context.Context, q schema.QueryBundle) (schema.QueryBundle, error) {
+		q.QueryString += "!"
+		return q, nil
+	})
+
+	asOf := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	filters := &schema.MetadataFilters{}
+	in := schema.NewQueryBundle("hello", schema.AsOf(asOf), schema.WithQueryFilters(filters))
+
+	out, err := NewPipeline(upper, &IdentityTransform{}, suffix).Transform(ctx, in)
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO!", out.QueryString)
+	assert.Same(t, filters, out.Filters, "filters survive transforms that drop them")
+	assert.Equal(t, asOf, *out.AsOf)
+
+	failing := TransformFunc(func(ctx context.Context, q schema.QueryBundle) (schema.QueryBundle, error) {
+		return q, errors.New("boom")
+	})
+	_, err = NewPipeline(upper, failing).Transform(ctx, in)
+	assert.ErrorContains(t, err, "query transform 1 failed")
+
+	out, err = Apply(ctx, in)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", out.QueryString)
+}
@@ -0,0 +1,74 @@
+// Package querytransform provides query transformations applied before
+// retrieval, such as HyDE, LLM query rewriting and step decomposition.
+package querytransform
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// QueryTransform transforms a query before execution.
+type QueryTransform interface {
+	// Transform transforms the query.
+	Transform(ctx context.Context, query schema.QueryBundle) (schema.QueryBundle, error)
+}
+
+// TransformFunc adapts a function to the QueryTransform interface.
+type TransformFunc func(ctx context.Context, query schema.QueryBundle) (schema.QueryBundle, error)
+
+// Transform calls f.
+func (f TransformFunc) Transform(ctx context.Context, query schema.QueryBundle) (schema.QueryBundle, error) {
+	return f(ctx, query)
+}
+
+// IdentityTransform returns the query unchanged.
+type IdentityTransform struct{}
+
+// Transform returns the query unchanged.
+func (t *IdentityTransform) Transform(ctx context.Context, query schema.QueryBundle) (schema.QueryBundle, error) {
+	return query, nil
+}
+
+// Pipeline chains transforms, feeding each one the output of the previous.
+type Pipeline struct {
+	// Transforms are applied in order.
+	Transforms []QueryTransform
+}
+
+// NewPipeline creates a Pipeline from the given transforms.
+func NewPipeline(transforms ...QueryTransform) *Pipeline {
+	return &Pipeline{Transforms: transforms}
+}
+
+// Transform applies every transform in order.
+func (p *Pipeline) Transform(ctx context.Context, query schema.QueryBundle) (schema.QueryBundle, error) {
+	return Apply(ctx, query, p.Transforms...)
+}
+
+// Apply runs the transforms over the query in order. Filters and the
+// as-of time of the original query are kept when a transform drops them.
+func Apply(ctx context.Context, query schema.QueryBundle, transforms ...QueryTransform) (schema.QueryBundle, error) {
+	for i, t := range transforms {
+		transformed, err := t.Transform(ctx, query)
+		if err != nil {
+			return query, fmt.Errorf("query transform %d failed: %w", i, err)
+		}
+		if transformed.Filters == nil {
+			transformed.Filters = query.Filters
+		}
+		if transformed.AsOf == nil {
+			transformed.AsOf = query.AsOf
+		}
+		query = transformed
+	}
+	return query, nil
+}
+
+// Ensure the transforms implement QueryTransform.
+var (
+	_ QueryTransform = TransformFunc(nil)
+	_ QueryTransform = (*IdentityTransform)(nil)
+	_ QueryTransform = (*Pipeline)(nil)
+)
@@ -0,0 +1,260 @@
+package queryengine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aqua777/go-llamaindex/evaluation"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// Metadata keys set on abstained responses.
+const (
+	// AbstainedMetadataKey is true when the engine declined to answer.
+	AbstainedMetadataKey = "abstained"
+	// AbstentionReasonsMetadataKey holds the []AbstentionReason that caused abstention.
+	AbstentionReasonsMetadataKey = "abstention_reasons"
+)
+
+// DefaultAbstentionMessage is the response text returned when abstaining.
+const DefaultAbstentionMessage = "I don't know. The available sources do not contain enough reliable information to answer this question."
+
+// AbstentionCode identifies why a query engine abstained.
+type AbstentionCode string
+
+const (
+	// AbstentionCodeLowScore means the best retrieval score was below the minimum.
+	AbstentionCodeLowScore AbstentionCode = "low_retrieval_score"
+	// AbstentionCodeInsufficientSources means too few sources were retrieved.
+	AbstentionCodeInsufficientSources AbstentionCode = "insufficient_sources"
+	// AbstentionCodeUnfaithful means the answer was not supported by the sources.
+	AbstentionCodeUnfaithful AbstentionCode = "unfaithful_answer"
+)
+
+// AbstentionReason explains a single failed abstention check.
+type AbstentionReason struct {
+	Code    AbstentionCode `json:"code"`
+	Message string         `json:"message"`
+}
+
+// AbstentionPolicy decides when a query engine should answer "I don't know"
+// instead of risking a hallucinated answer. Zero-valued checks are disabled.
+type AbstentionPolicy struct {
+	// MinRetrievalScore is the minimum score the best source node must reach.
+	MinRetrievalScore float64
+	// MinSourceCount is the minimum number of sources scoring at least
+	// MinRetrievalScore.
+	MinSourceCount int
+	// FaithfulnessEvaluator, if set, checks the generated answer against the
+	// sources; a non-passing result causes abstention.
+	FaithfulnessEvaluator evaluation.Evaluator
+	// Message is the response text used when abstaining.
+	Message string
+}
+
+// AbstentionPolicyOption configures an AbstentionPolicy.
+type AbstentionPolicyOption func(*AbstentionPolicy)
+
+// WithMinRetrievalScore sets the minimum best retrieval score.
+func WithMinRetrievalScore(score float64) AbstentionPolicyOption {
+	return func(p *AbstentionPolicy) {
+		p.MinRetrievalScore = score
+	}
+}
+
+// WithMinSourceCount sets the minimum number of qualifying sources.
+func WithMinSourceCount(count int) AbstentionPolicyOption {
+	return func(p *AbstentionPolicy) {
+		p.MinSourceCount = count
+	}
+}
+
+// WithFaithfulnessCheck sets the evaluator used to verify answers.
+func WithFaithfulnessCheck(evaluator evaluation.Evaluator) AbstentionPolicyOption {
+	return func(p *AbstentionPolicy) {
+		p.FaithfulnessEvaluator = evaluator
+	}
+}
+
+// WithAbstentionMessage sets the response text used when abstaining.
+func WithAbstentionMessage(message string) AbstentionPolicyOption {
+	return func(p *AbstentionPolicy) {
+		p.Message = message
+	}
+}
+
+// NewAbstentionPolicy creates a new AbstentionPolicy.
+func NewAbstentionPolicy(opts ...AbstentionPolicyOption) *AbstentionPolicy {
+	p := &AbstentionPolicy{
+		Message: DefaultAbstentionMessage,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// CheckRetrieval applies the retrieval-time checks and returns the reasons
+// to abstain, if any.
+func (p *AbstentionPolicy) CheckRetrieval(nodes []schema.NodeWithScore) []AbstentionReason {
+	var reasons []AbstentionReason
+
+	if p.MinRetrievalScore > 0 {
+		best := 0.0
+		for _, n := range nodes {
+			if n.Score > best {
+				best = n.Score
+			}
+		}
+		if best < p.MinRetrievalScore {
+			reasons = append(reasons, AbstentionReason{
+				Code:    AbstentionCodeLowScore,
+				Message: fmt.Sprintf("best retrieval score %.3f is below minimum %.3f", best, p.MinRetrievalScore),
+			})
+		}
+	}
+
+	if p.MinSourceCount > 0 {
+		count := 0
+		for _, n := range nodes {
+			if n.Score >= p.MinRetrievalScore {
+				count++
+			}
+		}
+		if count < p.MinSourceCount {
+			reasons = append(reasons, AbstentionReason{
+				Code:    AbstentionCodeInsufficientSources,
+				Message: fmt.Sprintf("%d qualifying sources, need at least %d", count, p.MinSourceCount),
+			})
+		}
+	}
+
+	return reasons
+}
+
+// CheckResponse applies the answer-time checks and returns the reasons to
+// abstain, if any.
+func (p *AbstentionPolicy) CheckResponse(ctx context.Context, query string, resp *synthesizer.Response) ([]AbstentionReason, error) {
+	if p.FaithfulnessEvaluator == nil || resp == nil {
+		return nil, nil
+	}
+
+	contexts := make([]string, len(resp.SourceNodes))
+	for i, n := range resp.SourceNodes {
+		contexts[i] = n.Node.GetContent(schema.MetadataModeNone)
+	}
+	if len(contexts) == 0 {
+		return []AbstentionReason{{
+			Code:    AbstentionCodeUnfaithful,
+			Message: "no sources to support the answer",
+		}}, nil
+	}
+
+	input := evaluation.NewEvaluateInput().
+		WithQuery(query).
+		WithResponse(resp.Response).
+		WithContexts(contexts)
+	result, err := p.FaithfulnessEvaluator.Evaluate(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("faithfulness check failed: %w", err)
+	}
+	if result.IsPassing() {
+		return nil, nil
+	}
+
+	message := "answer is not supported by the sources"
+	if result.Feedback != "" {
+		message += ": " + result.Feedback
+	}
+	return []AbstentionReason{{Code: AbstentionCodeUnfaithful, Message: message}}, nil
+}
+
+// Abstain builds the structured response returned instead of an answer.
+func (p *AbstentionPolicy) Abstain(nodes []schema.NodeWithScore, reasons []AbstentionReason) *synthesizer.Response {
+	message := p.Message
+	if message == "" {
+		message = DefaultAbstentionMessage
+	}
+	return synthesizer.NewResponseWithMetadata(message, nodes, map[string]interface{}{
+		AbstainedMetadataKey:         true,
+		AbstentionReasonsMetadataKey: reasons,
+	})
+}
+
+// IsAbstained reports whether a response is an abstention and returns its reasons.
+func IsAbstained(resp *synthesizer.Response) (bool, []AbstentionReason) {
+	if resp == nil || resp.Metadata == nil {
+		return false, nil
+	}
+	abstained, _ := resp.Metadata[AbstainedMetadataKey].(bool)
+	if !abstained {
+		return false, nil
+	}
+	reasons, _ := resp.Metadata[AbstentionReasonsMetadataKey].([]AbstentionReason)
+	return true, reasons
+}
+
+// AbstainingQueryEngine applies an AbstentionPolicy to another query engine.
+// If the wrapped engine exposes separate retrieval and synthesis, retrieval
+// checks run before synthesis so no LLM call is spent on a doomed answer;
+// otherwise they run on the response's source nodes.
+type AbstainingQueryEngine struct {
+	*BaseQueryEngine
+	// QueryEngine is the underlying query engine.
+	QueryEngine QueryEngine
+	// Policy decides when to abstain.
+	Policy *AbstentionPolicy
+}
+
+// NewAbstainingQueryEngine creates a new AbstainingQueryEngine.
+func NewAbstainingQueryEngine(engine QueryEngine, policy *AbstentionPolicy) *AbstainingQueryEngine {
+	if policy == nil {
+		policy = NewAbstentionPolicy()
+	}
+	return &AbstainingQueryEngine{
+		BaseQueryEngine: NewBaseQueryEngine(),
+		QueryEngine:     engine,
+		Policy:          policy,
+	}
+}
+
+// Query executes a query, abstaining if the policy is not satisfied.
+func (aqe *AbstainingQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	var resp *synthesizer.Response
+
+	if withRetrieval, ok := aqe.QueryEngine.(QueryEngineWithRetrieval); ok {
+		nodes, err := withRetrieval.Retrieve(ctx, schema.QueryBundle{QueryString: query})
+		if err != nil {
+			return nil, err
+		}
+		if reasons := aqe.Policy.CheckRetrieval(nodes); len(reasons) > 0 {
+			return aqe.Policy.Abstain(nodes, reasons), nil
+		}
+		resp, err = withRetrieval.Synthesize(ctx, query, nodes)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		resp, err = aqe.QueryEngine.Query(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		if reasons := aqe.Policy.CheckRetrieval(resp.SourceNodes); len(reasons) > 0 {
+			return aqe.Policy.Abstain(resp.SourceNodes, reasons), nil
+		}
+	}
+
+	reasons, err := aqe.Policy.CheckResponse(ctx, query, resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(reasons) > 0 {
+		return aqe.Policy.Abstain(resp.SourceNodes, reasons), nil
+	}
+	return resp, nil
+}
+
+// Ensure AbstainingQueryEngine implements QueryEngine.
+var _ QueryEngine = (*AbstainingQueryEngine)(nil)
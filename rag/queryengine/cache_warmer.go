@@ -0,0 +1,290 @@
+package queryengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/rag/retriever"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// QueryLog is a source of historical queries, e.g. a feedback or query log
+// store.
+type QueryLog interface {
+	// TopQueries returns up to n queries, most popular first.
+	TopQueries(ctx context.Context, n int) ([]string, error)
+}
+
+// QueryLogFunc adapts a function to the QueryLog interface.
+type QueryLogFunc func(ctx context.Context, n int) ([]string, error)
+
+// TopQueries calls f(ctx, n).
+func (f QueryLogFunc) TopQueries(ctx context.Context, n int) ([]string, error) {
+	return f(ctx, n)
+}
+
+// InMemoryQueryLog counts queries in memory. Queries that differ only in case
+// and whitespace are counted together under the first spelling seen.
+type InMemoryQueryLog struct {
+	mu      sync.Mutex
+	entries map[string]*queryLogEntry
+	seq     int
+}
+
+type queryLogEntry struct {
+	query string
+	count int
+	first int
+}
+
+// NewInMemoryQueryLog creates an empty InMemoryQueryLog.
+func NewInMemoryQueryLog() *InMemoryQueryLog {
+	return &InMemoryQueryLog{entries: make(map[string]*queryLogEntry)}
+}
+
+// Record counts one occurrence of query.
+func (l *InMemoryQueryLog) Record(query string) {
+	key := normalizeCacheQuery(query)
+	if key == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[key]
+	if !ok {
+		entry = &queryLogEntry{query: query, first: l.seq}
+		l.entries[key] = entry
+		l.seq++
+	}
+	entry.count++
+}
+
+// TopQueries returns up to n queries by descending count. Ties keep the
+// order in which the queries were first recorded.
+func (l *InMemoryQueryLog) TopQueries(ctx context.Context, n int) ([]string, error) {
+	l.mu.Lock()
+	entries := make([]*queryLogEntry, 0, len(l.entries))
+	for _, entry := range l.entries {
+		entries = append(entries, entry)
+	}
+	l.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].count != entries[j].count {
+			return entries[i].count > entries[j].count
+		}
+		return entries[i].first < entries[j].first
+	})
+
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	queries := make([]string, len(entries))
+	for i, entry := range entries {
+		queries[i] = entry.query
+	}
+	return queries, nil
+}
+
+// Len returns the number of distinct queries recorded.
+func (l *InMemoryQueryLog) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.entries)
+}
+
+// LoggingQueryEngine records every query in an InMemoryQueryLog before
+// passing it to the wrapped engine.
+type LoggingQueryEngine struct {
+	*BaseQueryEngine
+	// Engine answers the queries.
+	Engine QueryEngine
+	// Log records the queries.
+	Log *InMemoryQueryLog
+}
+
+// NewLoggingQueryEngine wraps engine so its queries are recorded in log.
+func NewLoggingQueryEngine(engine QueryEngine, log *InMemoryQueryLog) *LoggingQueryEngine {
+	return &LoggingQueryEngine{
+		BaseQueryEngine: NewBaseQueryEngine(),
+		Engine:          engine,
+		Log:             log,
+	}
+}
+
+// Query records query and asks the wrapped engine.
+func (e *LoggingQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	e.Log.Record(query)
+	return e.Engine.Query(ctx, query)
+}
+
+// CacheWarmResult summarizes a CacheWarmer run.
+type CacheWarmResult struct {
+	// Queries is the number of queries replayed.
+	Queries int
+	// Failed is the number of queries for which a replay failed.
+	Failed int
+	// Errors are the replay errors.
+	Errors []error
+	// Duration is how long the run took.
+	Duration time.Duration
+}
+
+// CacheWarmer replays the most popular historical queries against a query
+// engine and/or retriever, so the caches behind them are filled before the
+// first user arrives. Run it at startup or after a reindex; as a Warmer it
+// can be registered with Readiness.
+//
+// To fill a ResponseCache, pass the CachedQueryEngine wrapping it. To prime
+// retriever-level caches, such as cached query embeddings, pass the
+// retriever.
+type CacheWarmer struct {
+	log         QueryLog
+	engine      QueryEngine
+	retriever   retriever.Retriever
+	topN        int
+	concurrency int
+}
+
+// CacheWarmerOption configures a CacheWarmer.
+type CacheWarmerOption func(*CacheWarmer)
+
+// WithCacheWarmerEngine sets the query engine the queries are replayed
+// against.
+func WithCacheWarmerEngine(engine QueryEngine) CacheWarmerOption {
+	return func(w *CacheWarmer) {
+		w.engine = engine
+	}
+}
+
+// WithCacheWarmerRetriever sets the retriever the queries are replayed
+// against.
+func WithCacheWarmerRetriever(r retriever.Retriever) CacheWarmerOption {
+	return func(w *CacheWarmer) {
+		w.retriever = r
+	}
+}
+
+// WithCacheWarmerTopN sets how many of the most popular queries are
+// replayed. Defaults to 100.
+func WithCacheWarmerTopN(n int) CacheWarmerOption {
+	return func(w *CacheWarmer) {
+		w.topN = n
+	}
+}
+
+// WithCacheWarmerConcurrency sets how many queries are replayed at once.
+// Defaults to 4.
+func WithCacheWarmerConcurrency(n int) CacheWarmerOption {
+	return func(w *CacheWarmer) {
+		w.concurrency = n
+	}
+}
+
+// NewCacheWarmer creates a new CacheWarmer that takes its queries from log.
+func NewCacheWarmer(log QueryLog, opts ...CacheWarmerOption) *CacheWarmer {
+	w := &CacheWarmer{
+		log:         log,
+		topN:        100,
+		concurrency: 4,
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	if w.concurrency < 1 {
+		w.concurrency = 1
+	}
+
+	return w
+}
+
+// Run replays the top queries. Failed replays are reported in the result;
+// Run itself only fails if the queries cannot be loaded or ctx ends.
+func (w *CacheWarmer) Run(ctx context.Context) (*CacheWarmResult, error) {
+	start := time.Now()
+
+	queries, err := w.log.TopQueries(ctx, w.topN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load popular queries: %w", err)
+	}
+
+	result := &CacheWarmResult{Queries: len(queries)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, w.concurrency)
+
+	for _, query := range queries {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+
+		wg.Add(1)
+		go func(query string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if errs := w.replay(ctx, query); len(errs) > 0 {
+				mu.Lock()
+				result.Failed++
+				result.Errors = append(result.Errors, errs...)
+				mu.Unlock()
+			}
+		}(query)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// Warmup runs the warmer. It fails if the queries cannot be loaded or every
+// replay failed, since a few bad queries should not hold back readiness.
+func (w *CacheWarmer) Warmup(ctx context.Context) error {
+	result, err := w.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("cache warmup failed: %w", err)
+	}
+	if result.Queries > 0 && result.Failed == result.Queries {
+		return fmt.Errorf("cache warmup failed: %w", errors.Join(result.Errors...))
+	}
+	return nil
+}
+
+// replay runs one query against the retriever and engine.
+func (w *CacheWarmer) replay(ctx context.Context, query string) []error {
+	var errs []error
+	if w.retriever != nil {
+		if _, err := w.retriever.Retrieve(ctx, schema.QueryBundle{QueryString: query}); err != nil {
+			errs = append(errs, fmt.Errorf("retrieve %q: %w", query, err))
+		}
+	}
+	if w.engine != nil {
+		if _, err := w.engine.Query(ctx, query); err != nil {
+			errs = append(errs, fmt.Errorf("query %q: %w", query, err))
+		}
+	}
+	return errs
+}
+
+// Ensure the types implement their interfaces.
+var (
+	_ QueryLog    = (*InMemoryQueryLog)(nil)
+	_ QueryEngine = (*LoggingQueryEngine)(nil)
+	_ Warmer      = (*CacheWarmer)(nil)
+)
@@ -0,0 +1,298 @@
+package queryengine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/rag/retriever"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// Metadata keys set by ComparisonQueryEngine.
+const (
+	// ComparisonMetadataKey holds the *Comparison on the response.
+	ComparisonMetadataKey = "comparison"
+	// ComparisonFindingsMetadataKey holds the per-set findings as a
+	// map[string]string keyed by set label.
+	ComparisonFindingsMetadataKey = "comparison_findings"
+	// ComparisonSetMetadataKey holds the set label on source nodes.
+	ComparisonSetMetadataKey = "comparison_set"
+)
+
+// Default prompts for ComparisonQueryEngine.
+const (
+	DefaultComparisonFindingsPromptTmpl = `Context information from the document set "{label}" is below.
+---------------------
+{context_str}
+---------------------
+Using only this context, summarize what "{label}" says about the query. List concrete facts, figures and terms. If the context does not cover the query, say so.
+Query: {query_str}
+Findings: `
+
+	DefaultComparisonPromptTmpl = `Compare the document sets below for the query.
+{findings_str}
+
+Query: {query_str}
+
+Respond with a JSON object only, in this format:
+{"summary": "<one paragraph overview>",
+ "similarities": ["<point shared by all sets>"],
+ "differences": [{"aspect": "<what differs>", "values": {"<set label>": "<that set's position>"}}]}
+Use the exact set labels as keys in "values".`
+)
+
+// ComparisonSet is a labeled document set to compare.
+type ComparisonSet struct {
+	// Label names the set, e.g. "2023 report" or "Contract A".
+	Label string
+	// Retriever retrieves from the set.
+	Retriever retriever.Retriever
+}
+
+// ComparisonDifference is one aspect on which the sets differ.
+type ComparisonDifference struct {
+	// Aspect names what differs.
+	Aspect string `json:"aspect"`
+	// Values holds each set's position, keyed by set label.
+	Values map[string]string `json:"values"`
+}
+
+// Comparison is the structured result of a ComparisonQueryEngine query.
+type Comparison struct {
+	// Labels are the compared set labels, in order.
+	Labels []string `json:"labels"`
+	// Summary is an overview of the comparison.
+	Summary string `json:"summary"`
+	// Similarities are points shared by all sets.
+	Similarities []string `json:"similarities"`
+	// Differences are the aspects on which the sets differ.
+	Differences []ComparisonDifference `json:"differences"`
+}
+
+// Table renders the differences as a Markdown table with one column per
+// set.
+func (c *Comparison) Table() string {
+	if len(c.Differences) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("| Aspect |")
+	for _, label := range c.Labels {
+		sb.WriteString(" " + escapeTableCell(label) + " |")
+	}
+	sb.WriteString("\n|---|")
+	sb.WriteString(strings.Repeat("---|", len(c.Labels)))
+	for _, d := range c.Differences {
+		sb.WriteString("\n| " + escapeTableCell(d.Aspect) + " |")
+		for _, label := range c.Labels {
+			value := d.Values[label]
+			if value == "" {
+				value = "-"
+			}
+			sb.WriteString(" " + escapeTableCell(value) + " |")
+		}
+	}
+	return sb.String()
+}
+
+// String renders the comparison as Markdown: summary, similarities and the
+// differences table.
+func (c *Comparison) String() string {
+	var parts []string
+	if c.Summary != "" {
+		parts = append(parts, c.Summary)
+	}
+	if len(c.Similarities) > 0 {
+		lines := []string{"Similarities:"}
+		for _, s := range c.Similarities {
+			lines = append(lines, "- "+s)
+		}
+		parts = append(parts, strings.Join(lines, "\n"))
+	}
+	if table := c.Table(); table != "" {
+		parts = append(parts, "Differences:\n"+table)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// ComparisonQueryEngine compares two or more labeled document sets. For
+// each set it retrieves context and asks the LLM for that set's findings;
+// the findings are then compared into a structured Comparison of
+// similarities and differences. The response text is the rendered
+// comparison, and the Comparison itself is under ComparisonMetadataKey.
+type ComparisonQueryEngine struct {
+	*BaseQueryEngine
+	// LLM writes findings and the comparison.
+	LLM llm.LLM
+	// Sets are the document sets to compare.
+	Sets []ComparisonSet
+	// FindingsPrompt summarizes one set.
+	FindingsPrompt prompts.BasePromptTemplate
+	// ComparisonPrompt compares the findings.
+	ComparisonPrompt prompts.BasePromptTemplate
+}
+
+// ComparisonQueryEngineOption is a functional option for
+// ComparisonQueryEngine.
+type ComparisonQueryEngineOption func(*ComparisonQueryEngine)
+
+// WithComparisonFindingsPrompt sets the per-set findings prompt. It
+// receives {label}, {context_str} and {query_str}.
+func WithComparisonFindingsPrompt(prompt prompts.BasePromptTemplate) ComparisonQueryEngineOption {
+	return func(e *ComparisonQueryEngine) {
+		e.FindingsPrompt = prompt
+	}
+}
+
+// WithComparisonPrompt sets the comparison prompt. It receives
+// {findings_str} and {query_str} and must ask for the JSON format of
+// DefaultComparisonPromptTmpl.
+func WithComparisonPrompt(prompt prompts.BasePromptTemplate) ComparisonQueryEngineOption {
+	return func(e *ComparisonQueryEngine) {
+		e.ComparisonPrompt = prompt
+	}
+}
+
+// NewComparisonQueryEngine creates a new ComparisonQueryEngine.
+func NewComparisonQueryEngine(llmModel llm.LLM, sets []ComparisonSet, opts ...ComparisonQueryEngineOption) *ComparisonQueryEngine {
+	e := &ComparisonQueryEngine{
+		BaseQueryEngine:  NewBaseQueryEngine(),
+		LLM:              llmModel,
+		Sets:             sets,
+		FindingsPrompt:   prompts.NewPromptTemplate(DefaultComparisonFindingsPromptTmpl, prompts.PromptTypeQuestionAnswer),
+		ComparisonPrompt: prompts.NewPromptTemplate(DefaultComparisonPromptTmpl, prompts.PromptTypeCustom),
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	e.SetPrompt("findings_prompt", e.FindingsPrompt)
+	e.SetPrompt("comparison_prompt", e.ComparisonPrompt)
+
+	return e
+}
+
+// Query compares the sets for the query.
+func (e *ComparisonQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	if len(e.Sets) < 2 {
+		return nil, fmt.Errorf("comparison needs at least 2 document sets, got %d", len(e.Sets))
+	}
+
+	findings := make([]string, len(e.Sets))
+	sources := make([][]schema.NodeWithScore, len(e.Sets))
+	errs := make([]error, len(e.Sets))
+
+	var wg sync.WaitGroup
+	for i := range e.Sets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			findings[i], sources[i], errs[i] = e.findings(ctx, e.Sets[i], query)
+		}(i)
+	}
+	wg.Wait()
+
+	labels := make([]string, len(e.Sets))
+	var findingsStr strings.Builder
+	findingsByLabel := make(map[string]string, len(e.Sets))
+	var sourceNodes []schema.NodeWithScore
+	for i, set := range e.Sets {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("failed to analyze %q: %w", set.Label, errs[i])
+		}
+		labels[i] = set.Label
+		findingsByLabel[set.Label] = findings[i]
+		fmt.Fprintf(&findingsStr, "Findings for %q:\n%s\n\n", set.Label, findings[i])
+		sourceNodes = append(sourceNodes, sources[i]...)
+	}
+
+	prompt := e.ComparisonPrompt.Format(map[string]string{
+		"findings_str": strings.TrimSpace(findingsStr.String()),
+		"query_str":    query,
+	})
+	output, err := e.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare document sets: %w", err)
+	}
+
+	comparison, err := parseComparison(output, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := map[string]interface{}{
+		ComparisonMetadataKey:         comparison,
+		ComparisonFindingsMetadataKey: findingsByLabel,
+	}
+	return synthesizer.NewResponseWithMetadata(comparison.String(), sourceNodes, metadata), nil
+}
+
+// findings retrieves from one set and summarizes it for the query. Source
+// nodes are tagged with the set label.
+func (e *ComparisonQueryEngine) findings(ctx context.Context, set ComparisonSet, query string) (string, []schema.NodeWithScore, error) {
+	nodes, err := set.Retriever.Retrieve(ctx, schema.QueryBundle{QueryString: query})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to retrieve: %w", err)
+	}
+	if len(nodes) == 0 {
+		return "No relevant information found.", nil, nil
+	}
+
+	texts := make([]string, len(nodes))
+	tagged := make([]schema.NodeWithScore, len(nodes))
+	for i, n := range nodes {
+		texts[i] = n.Node.GetContent(schema.MetadataModeLLM)
+
+		metadata := make(map[string]interface{}, len(n.Node.Metadata)+1)
+		for k, v := range n.Node.Metadata {
+			metadata[k] = v
+		}
+		metadata[ComparisonSetMetadataKey] = set.Label
+		n.Node.Metadata = metadata
+		tagged[i] = n
+	}
+
+	prompt := e.FindingsPrompt.Format(map[string]string{
+		"label":       set.Label,
+		"context_str": strings.Join(texts, "\n\n"),
+		"query_str":   query,
+	})
+	answer, err := e.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to summarize findings: %w", err)
+	}
+	return strings.TrimSpace(answer), tagged, nil
+}
+
+// parseComparison decodes the comparison JSON from the LLM output.
+func parseComparison(output string, labels []string) (*Comparison, error) {
+	start := strings.Index(output, "{")
+	end := strings.LastIndex(output, "}")
+	if start == -1 || end < start {
+		return nil, fmt.Errorf("comparison output is not JSON: %q", output)
+	}
+
+	var comparison Comparison
+	if err := json.Unmarshal([]byte(output[start:end+1]), &comparison); err != nil {
+		return nil, fmt.Errorf("failed to parse comparison: %w", err)
+	}
+	comparison.Labels = labels
+	return &comparison, nil
+}
+
+// escapeTableCell makes text safe for a Markdown table cell.
+func escapeTableCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Ensure ComparisonQueryEngine implements QueryEngine.
+var _ QueryEngine = (*ComparisonQueryEngine)(nil)
@@ -0,0 +1,656 @@
+package queryengine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/rag/reader"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// Metadata keys set on responses produced by DataFrameQueryEngine.
+const (
+	// DataFrameQueryMetadataKey holds the evaluated *DataFrameQuery.
+	DataFrameQueryMetadataKey = "dataframe_query"
+	// DataFrameResultMetadataKey holds the result as a *reader.Table.
+	DataFrameResultMetadataKey = "dataframe_result"
+)
+
+// Default prompts for dataframe queries.
+const (
+	defaultDataFrameQueryPrompt = `You are working with a table named {table_name}. Its columns and example rows are:
+{schema}
+
+Write a query that answers the question as a JSON object with these optional fields:
+- "filters": a list of {"column": ..., "op": ..., "value": ...}; op is one of ==, !=, >, >=, <, <=, contains, in, is_null, not_null. Rows must match all filters. "in" takes a list of values.
+- "group_by": a list of columns to group rows by.
+- "aggregations": a list of {"func": ..., "column": ..., "as": ...}; func is one of count, count_distinct, sum, mean, min, max. count needs no column. "as" names the result column.
+- "select": a list of columns to return when not aggregating.
+- "sort": a list of {"column": ..., "desc": true|false}; columns may name aggregation results.
+- "limit": the maximum number of rows to return.
+
+Reply with the JSON object only.
+
+Question: {query_str}
+Query: `
+
+	defaultDataFrameResponsePrompt = `Given an input question, synthesize a response from the query results.
+Query: {query_str}
+Dataframe query: {dataframe_query}
+Result: {context_str}
+Response: `
+)
+
+// Filter operators of a DataFrameFilter.
+const (
+	FilterOpEq       = "=="
+	FilterOpNe       = "!="
+	FilterOpGt       = ">"
+	FilterOpGte      = ">="
+	FilterOpLt       = "<"
+	FilterOpLte      = "<="
+	FilterOpContains = "contains"
+	FilterOpIn       = "in"
+	FilterOpIsNull   = "is_null"
+	FilterOpNotNull  = "not_null"
+)
+
+// Aggregation functions of a DataFrameAggregation.
+const (
+	AggCount         = "count"
+	AggCountDistinct = "count_distinct"
+	AggSum           = "sum"
+	AggMean          = "mean"
+	AggMin           = "min"
+	AggMax           = "max"
+)
+
+// DataFrameFilter keeps rows whose column value satisfies the operator.
+type DataFrameFilter struct {
+	Column string      `json:"column"`
+	Op     string      `json:"op"`
+	Value  interface{} `json:"value,omitempty"`
+}
+
+// DataFrameAggregation computes a function over a column per group.
+type DataFrameAggregation struct {
+	Func   string `json:"func"`
+	Column string `json:"column,omitempty"`
+	// As names the result column. Defaults to func_column, or count.
+	As string `json:"as,omitempty"`
+}
+
+// DataFrameSort orders the result by a column.
+type DataFrameSort struct {
+	Column string `json:"column"`
+	Desc   bool   `json:"desc,omitempty"`
+}
+
+// DataFrameQuery is a restricted, pandas-style query over a table: filter
+// rows, optionally group and aggregate them, then project, sort and limit
+// the result. It is plain data, so queries written by an LLM are evaluated
+// without running any generated code.
+type DataFrameQuery struct {
+	Filters      []DataFrameFilter      `json:"filters,omitempty"`
+	GroupBy      []string               `json:"group_by,omitempty"`
+	Aggregations []DataFrameAggregation `json:"aggregations,omitempty"`
+	Select       []string               `json:"select,omitempty"`
+	Sort         []DataFrameSort        `json:"sort,omitempty"`
+	Limit        int                    `json:"limit,omitempty"`
+}
+
+// ParseDataFrameQuery parses a DataFrameQuery from LLM output, ignoring
+// code fences and text around the JSON object. Unknown fields are errors.
+func ParseDataFrameQuery(output string) (*DataFrameQuery, error) {
+	start := strings.Index(output, "{")
+	end := strings.LastIndex(output, "}")
+	if start == -1 || end < start {
+		return nil, fmt.Errorf("no JSON object found in output: %s", output)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader([]byte(output[start : end+1])))
+	dec.DisallowUnknownFields()
+	var q DataFrameQuery
+	if err := dec.Decode(&q); err != nil {
+		return nil, fmt.Errorf("invalid dataframe query: %w", err)
+	}
+	return &q, nil
+}
+
+// String returns the query as JSON.
+func (q *DataFrameQuery) String() string {
+	data, _ := json.Marshal(q)
+	return string(data)
+}
+
+// Apply evaluates the query against t and returns the result as a new
+// table. Columns are matched by name or original header, ignoring case.
+func (q *DataFrameQuery) Apply(t *reader.Table) (*reader.Table, error) {
+	// Filter.
+	rows := t.Rows
+	for _, f := range q.Filters {
+		col, err := columnIndex(t.Columns, f.Column)
+		if err != nil {
+			return nil, err
+		}
+		var kept [][]interface{}
+		for _, row := range rows {
+			ok, err := matchFilter(row[col], f)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				kept = append(kept, row)
+			}
+		}
+		rows = kept
+	}
+
+	result := &reader.Table{Name: t.Name, Source: t.Source}
+	if len(q.GroupBy) > 0 || len(q.Aggregations) > 0 {
+		var err error
+		if result.Columns, result.Rows, err = q.aggregate(t.Columns, rows); err != nil {
+			return nil, err
+		}
+		if err := q.sortRows(result.Columns, result.Rows); err != nil {
+			return nil, err
+		}
+	} else {
+		// Sort before projecting, so rows can be ordered by columns that
+		// are not selected.
+		rows = append([][]interface{}(nil), rows...)
+		if err := q.sortRows(t.Columns, rows); err != nil {
+			return nil, err
+		}
+		result.Columns = t.Columns
+		result.Rows = rows
+		if len(q.Select) > 0 {
+			indices := make([]int, len(q.Select))
+			result.Columns = nil
+			for i, name := range q.Select {
+				col, err := columnIndex(t.Columns, name)
+				if err != nil {
+					return nil, err
+				}
+				indices[i] = col
+				result.Columns = append(result.Columns, t.Columns[col])
+			}
+			result.Rows = make([][]interface{}, len(rows))
+			for r, row := range rows {
+				projected := make([]interface{}, len(indices))
+				for i, col := range indices {
+					projected[i] = row[col]
+				}
+				result.Rows[r] = projected
+			}
+		}
+	}
+
+	if q.Limit > 0 && len(result.Rows) > q.Limit {
+		result.Rows = result.Rows[:q.Limit]
+	}
+	return result, nil
+}
+
+// columnIndex finds a column by name or original header, ignoring case.
+func columnIndex(columns []reader.TableColumn, name string) (int, error) {
+	for i, c := range columns {
+		if strings.EqualFold(c.Name, name) || strings.EqualFold(c.Header, name) {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("unknown column %q", name)
+}
+
+// sortRows sorts rows in place by the sort keys, applying them from last
+// to first on a stable sort. Missing values sort last in either direction.
+func (q *DataFrameQuery) sortRows(columns []reader.TableColumn, rows [][]interface{}) error {
+	for i := len(q.Sort) - 1; i >= 0; i-- {
+		s := q.Sort[i]
+		col, err := columnIndex(columns, s.Column)
+		if err != nil {
+			return err
+		}
+		sort.SliceStable(rows, func(a, b int) bool {
+			va, vb := rows[a][col], rows[b][col]
+			if va == nil || vb == nil {
+				return va != nil && vb == nil
+			}
+			c, _ := compareValues(va, vb)
+			if s.Desc {
+				return c > 0
+			}
+			return c < 0
+		})
+	}
+	return nil
+}
+
+// aggregate groups rows and computes the aggregations of each group.
+func (q *DataFrameQuery) aggregate(columns []reader.TableColumn, rows [][]interface{}) ([]reader.TableColumn, [][]interface{}, error) {
+	var outColumns []reader.TableColumn
+	groupCols := make([]int, len(q.GroupBy))
+	for i, name := range q.GroupBy {
+		col, err := columnIndex(columns, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		groupCols[i] = col
+		outColumns = append(outColumns, columns[col])
+	}
+
+	aggCols := make([]int, len(q.Aggregations))
+	for i, agg := range q.Aggregations {
+		aggCols[i] = -1
+		colType := reader.ColumnTypeInteger
+		if agg.Column != "" {
+			col, err := columnIndex(columns, agg.Column)
+			if err != nil {
+				return nil, nil, err
+			}
+			aggCols[i] = col
+			colType = columns[col].Type
+		}
+		switch agg.Func {
+		case AggCount, AggCountDistinct:
+			colType = reader.ColumnTypeInteger
+		case AggMean:
+			colType = reader.ColumnTypeReal
+		case AggSum:
+			if colType == reader.ColumnTypeText {
+				return nil, nil, fmt.Errorf("cannot sum text column %q", agg.Column)
+			}
+		case AggMin, AggMax:
+		default:
+			return nil, nil, fmt.Errorf("unknown aggregation %q", agg.Func)
+		}
+		if aggCols[i] == -1 && agg.Func != AggCount {
+			return nil, nil, fmt.Errorf("aggregation %s needs a column", agg.Func)
+		}
+		name := agg.As
+		if name == "" {
+			name = agg.Func
+			if agg.Column != "" {
+				name += "_" + reader.SQLIdentifier(agg.Column)
+			}
+		}
+		outColumns = append(outColumns, reader.TableColumn{Name: name, Header: name, Type: colType})
+	}
+
+	// Group rows in order of first appearance.
+	var keys []string
+	groups := make(map[string][][]interface{})
+	for _, row := range rows {
+		parts := make([]string, len(groupCols))
+		for i, col := range groupCols {
+			parts[i] = fmt.Sprintf("%T:%v", row[col], row[col])
+		}
+		key := strings.Join(parts, "\x00")
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], row)
+	}
+	if len(groupCols) == 0 && len(keys) == 0 {
+		// Aggregating no rows still yields one row, e.g. a count of 0.
+		keys = []string{""}
+	}
+
+	outRows := make([][]interface{}, 0, len(keys))
+	for _, key := range keys {
+		members := groups[key]
+		out := make([]interface{}, 0, len(outColumns))
+		for _, col := range groupCols {
+			out = append(out, members[0][col])
+		}
+		for i, agg := range q.Aggregations {
+			out = append(out, aggregateValues(agg.Func, members, aggCols[i]))
+		}
+		outRows = append(outRows, out)
+	}
+	return outColumns, outRows, nil
+}
+
+// aggregateValues computes fn over column col of rows, skipping missing
+// values. Aggregates over no values are nil, except counts.
+func aggregateValues(fn string, rows [][]interface{}, col int) interface{} {
+	if fn == AggCount && col == -1 {
+		return int64(len(rows))
+	}
+
+	var values []interface{}
+	for _, row := range rows {
+		if row[col] != nil {
+			values = append(values, row[col])
+		}
+	}
+
+	switch fn {
+	case AggCount:
+		return int64(len(values))
+	case AggCountDistinct:
+		seen := make(map[string]bool)
+		for _, v := range values {
+			seen[fmt.Sprintf("%T:%v", v, v)] = true
+		}
+		return int64(len(seen))
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	switch fn {
+	case AggSum, AggMean:
+		var sum float64
+		allInts := true
+		var intSum int64
+		for _, v := range values {
+			f, _ := toFloat(v)
+			sum += f
+			if n, ok := v.(int64); ok {
+				intSum += n
+			} else {
+				allInts = false
+			}
+		}
+		if fn == AggMean {
+			return sum / float64(len(values))
+		}
+		if allInts {
+			return intSum
+		}
+		return sum
+	case AggMin, AggMax:
+		best := values[0]
+		for _, v := range values[1:] {
+			c, _ := compareValues(v, best)
+			if (fn == AggMin && c < 0) || (fn == AggMax && c > 0) {
+				best = v
+			}
+		}
+		return best
+	}
+	return nil
+}
+
+// matchFilter reports whether a cell value satisfies a filter.
+func matchFilter(value interface{}, f DataFrameFilter) (bool, error) {
+	switch f.Op {
+	case FilterOpIsNull:
+		return value == nil, nil
+	case FilterOpNotNull:
+		return value != nil, nil
+	case FilterOpIn:
+		list, ok := f.Value.([]interface{})
+		if !ok {
+			return false, fmt.Errorf("filter on %q: in needs a list of values", f.Column)
+		}
+		for _, v := range list {
+			if c, ok := compareValues(value, v); ok && c == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	case FilterOpContains:
+		if value == nil {
+			return false, nil
+		}
+		return strings.Contains(strings.ToLower(fmt.Sprintf("%v", value)), strings.ToLower(fmt.Sprintf("%v", f.Value))), nil
+	case FilterOpEq, FilterOpNe, FilterOpGt, FilterOpGte, FilterOpLt, FilterOpLte:
+	default:
+		return false, fmt.Errorf("filter on %q: unknown operator %q", f.Column, f.Op)
+	}
+
+	if value == nil || f.Value == nil {
+		return f.Op == FilterOpNe && (value != nil || f.Value != nil), nil
+	}
+	c, _ := compareValues(value, f.Value)
+	switch f.Op {
+	case FilterOpEq:
+		return c == 0, nil
+	case FilterOpNe:
+		return c != 0, nil
+	case FilterOpGt:
+		return c > 0, nil
+	case FilterOpGte:
+		return c >= 0, nil
+	case FilterOpLt:
+		return c < 0, nil
+	default:
+		return c <= 0, nil
+	}
+}
+
+// compareValues compares numbers numerically and anything else as
+// case-insensitive text. The boolean reports whether both were non-nil.
+func compareValues(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	fa, okA := toFloat(a)
+	fb, okB := toFloat(b)
+	if okA && okB {
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+	return strings.Compare(strings.ToLower(fmt.Sprintf("%v", a)), strings.ToLower(fmt.Sprintf("%v", b))), true
+}
+
+// toFloat converts numeric values, including numeric strings, to float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, !math.IsNaN(n)
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// DataFrameQueryEngine answers natural language questions about a table
+// held in memory, such as a CSV file, without a database. The LLM writes a
+// DataFrameQuery (filters, group-by, aggregations, sorting), which is
+// validated and evaluated in Go, and the answer is synthesized from the
+// result.
+type DataFrameQueryEngine struct {
+	*BaseQueryEngine
+	// Table holds the data.
+	Table *reader.Table
+	// LLM writes queries and synthesizes the answer.
+	LLM llm.LLM
+	// QueryPrompt is the template used to write the query.
+	QueryPrompt prompts.BasePromptTemplate
+	// ResponsePrompt is the template used to synthesize the answer.
+	ResponsePrompt prompts.BasePromptTemplate
+	// SynthesizeResponse controls whether results are turned into prose.
+	// When false, the formatted result is returned as the response text.
+	SynthesizeResponse bool
+	// SampleRows is the number of example rows shown in the prompt.
+	SampleRows int
+	// MaxRows caps the rows of a result.
+	MaxRows int
+}
+
+// DataFrameQueryEngineOption is a functional option.
+type DataFrameQueryEngineOption func(*DataFrameQueryEngine)
+
+// WithDataFrameQueryPrompt sets the query writing prompt.
+func WithDataFrameQueryPrompt(prompt prompts.BasePromptTemplate) DataFrameQueryEngineOption {
+	return func(e *DataFrameQueryEngine) {
+		e.QueryPrompt = prompt
+	}
+}
+
+// WithDataFrameResponsePrompt sets the response synthesis prompt.
+func WithDataFrameResponsePrompt(prompt prompts.BasePromptTemplate) DataFrameQueryEngineOption {
+	return func(e *DataFrameQueryEngine) {
+		e.ResponsePrompt = prompt
+	}
+}
+
+// WithDataFrameSynthesizeResponse sets whether results are synthesized
+// into prose.
+func WithDataFrameSynthesizeResponse(synthesize bool) DataFrameQueryEngineOption {
+	return func(e *DataFrameQueryEngine) {
+		e.SynthesizeResponse = synthesize
+	}
+}
+
+// WithDataFrameSampleRows sets the number of example rows in the prompt.
+func WithDataFrameSampleRows(n int) DataFrameQueryEngineOption {
+	return func(e *DataFrameQueryEngine) {
+		e.SampleRows = n
+	}
+}
+
+// WithDataFrameMaxRows caps the rows of a result.
+func WithDataFrameMaxRows(n int) DataFrameQueryEngineOption {
+	return func(e *DataFrameQueryEngine) {
+		e.MaxRows = n
+	}
+}
+
+// NewDataFrameQueryEngine creates a DataFrameQueryEngine over table.
+func NewDataFrameQueryEngine(table *reader.Table, llmModel llm.LLM, opts ...DataFrameQueryEngineOption) *DataFrameQueryEngine {
+	e := &DataFrameQueryEngine{
+		BaseQueryEngine:    NewBaseQueryEngine(),
+		Table:              table,
+		LLM:                llmModel,
+		QueryPrompt:        prompts.NewPromptTemplate(defaultDataFrameQueryPrompt, prompts.PromptTypeCustom),
+		ResponsePrompt:     prompts.NewPromptTemplate(defaultDataFrameResponsePrompt, prompts.PromptTypeCustom),
+		SynthesizeResponse: true,
+		SampleRows:         5,
+		MaxRows:            100,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	e.SetPrompt("dataframe_query_prompt", e.QueryPrompt)
+	e.SetPrompt("response_synthesis_prompt", e.ResponsePrompt)
+
+	return e
+}
+
+// NewDataFrameQueryEngineFromFile reads the first table of a CSV or Excel
+// file and returns an engine over it.
+func NewDataFrameQueryEngineFromFile(path string, llmModel llm.LLM, opts ...DataFrameQueryEngineOption) (*DataFrameQueryEngine, error) {
+	tables, err := reader.NewTableReader().LoadTables(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("no table found in %s", path)
+	}
+	return NewDataFrameQueryEngine(tables[0], llmModel, opts...), nil
+}
+
+// Schema describes the table columns and example rows for the prompt.
+func (e *DataFrameQueryEngine) Schema() string {
+	var b strings.Builder
+	for _, c := range e.Table.Columns {
+		fmt.Fprintf(&b, "- %s (%s)", c.Name, strings.ToLower(string(c.Type)))
+		if c.Header != c.Name {
+			fmt.Fprintf(&b, ": %q", c.Header)
+		}
+		b.WriteString("\n")
+	}
+	n := e.SampleRows
+	if n > len(e.Table.Rows) {
+		n = len(e.Table.Rows)
+	}
+	if n > 0 {
+		sample := &reader.Table{Columns: e.Table.Columns, Rows: e.Table.Rows[:n]}
+		b.WriteString(formatTable(sample))
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Query executes a natural language query against the table.
+func (e *DataFrameQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	dfQuery, err := e.GenerateQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if e.Verbose {
+		fmt.Printf("Generated dataframe query: %s\n", dfQuery)
+	}
+
+	result, err := dfQuery.Apply(e.Table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate dataframe query: %w", err)
+	}
+	if e.MaxRows > 0 && len(result.Rows) > e.MaxRows {
+		result.Rows = result.Rows[:e.MaxRows]
+	}
+
+	resultStr := formatTable(result)
+	resultNode := schema.NewTextNode(resultStr)
+	resultNode.Metadata = map[string]interface{}{DataFrameQueryMetadataKey: dfQuery.String()}
+	sourceNodes := []schema.NodeWithScore{{Node: *resultNode, Score: 1.0}}
+
+	responseText := resultStr
+	if e.SynthesizeResponse {
+		prompt := e.ResponsePrompt.Format(map[string]string{
+			"query_str":       query,
+			"dataframe_query": dfQuery.String(),
+			"context_str":     resultStr,
+		})
+		responseText, err = e.LLM.Complete(ctx, prompt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to synthesize response: %w", err)
+		}
+	}
+
+	return synthesizer.NewResponseWithMetadata(responseText, sourceNodes, map[string]interface{}{
+		DataFrameQueryMetadataKey:  dfQuery,
+		DataFrameResultMetadataKey: result,
+	}), nil
+}
+
+// GenerateQuery asks the LLM for a DataFrameQuery answering the question.
+func (e *DataFrameQueryEngine) GenerateQuery(ctx context.Context, query string) (*DataFrameQuery, error) {
+	prompt := e.QueryPrompt.Format(map[string]string{
+		"table_name": e.Table.Name,
+		"schema":     e.Schema(),
+		"query_str":  query,
+	})
+
+	response, err := e.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate dataframe query: %w", err)
+	}
+	return ParseDataFrameQuery(response)
+}
+
+// formatTable renders a table as a pipe-separated header and rows.
+func formatTable(t *reader.Table) string {
+	columns := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		columns[i] = c.Name
+	}
+	return (&SQLResult{Columns: columns, Rows: t.Rows}).String()
+}
+
+// Ensure DataFrameQueryEngine implements QueryEngine.
+var _ QueryEngine = (*DataFrameQueryEngine)(nil)
@@ -0,0 +1,357 @@
+package queryengine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textsplitter"
+)
+
+// Metadata keys set on responses from DocumentQAEngine.
+const (
+	// DocumentQAChunksMetadataKey holds the number of chunks mapped.
+	DocumentQAChunksMetadataKey = "document_qa_chunks"
+	// DocumentQARelevantMetadataKey holds the number of chunks that
+	// contributed an answer.
+	DocumentQARelevantMetadataKey = "document_qa_relevant_chunks"
+	// DocumentQARoundsMetadataKey holds the number of reduce rounds.
+	DocumentQARoundsMetadataKey = "document_qa_reduce_rounds"
+	// DocumentQAChunkIndexMetadataKey holds the chunk position on source
+	// nodes.
+	DocumentQAChunkIndexMetadataKey = "chunk_index"
+)
+
+// DocumentQANotRelevant is the reply the map prompt asks for when a chunk
+// says nothing about the query.
+const DocumentQANotRelevant = "NOT RELEVANT"
+
+// Default prompts for DocumentQAEngine.
+const (
+	DefaultDocumentQAMapPromptTmpl = `Below is one section of a longer document.
+---------------------
+{context_str}
+---------------------
+Using only this section, answer the query. Quote the relevant facts. If the section contains nothing relevant to the query, reply with exactly "` + DocumentQANotRelevant + `".
+Query: {query_str}
+Answer: `
+
+	DefaultDocumentQAReducePromptTmpl = `Below are partial answers to a query, each from a different section of the same document, in document order.
+---------------------
+{context_str}
+---------------------
+Combine them into a single complete answer. Resolve overlaps and keep every distinct fact.
+Query: {query_str}
+Answer: `
+)
+
+// DocumentQAEngine answers questions about a single long document without
+// building an index. The document is split into chunks that fit the
+// model's context; each chunk is asked the query in parallel (map), and
+// the per-chunk answers are merged (reduce). When the partial answers do
+// not fit one reduce prompt they are merged in batches, round after round,
+// until one answer remains.
+type DocumentQAEngine struct {
+	*BaseQueryEngine
+	// LLM answers chunks and merges answers.
+	LLM llm.LLM
+	// MapPrompt asks a single chunk the query.
+	MapPrompt prompts.BasePromptTemplate
+	// ReducePrompt merges partial answers.
+	ReducePrompt prompts.BasePromptTemplate
+
+	document     string
+	chunkSize    int
+	chunkOverlap int
+	reduceBudget int
+	concurrency  int
+	tokenizer    textsplitter.Tokenizer
+	chunks       []string
+}
+
+// DocumentQAEngineOption is a functional option for DocumentQAEngine.
+type DocumentQAEngineOption func(*DocumentQAEngine)
+
+// WithDocumentQAChunkSize sets the chunk size and overlap, in tokens.
+// Defaults to 2048 and 128.
+func WithDocumentQAChunkSize(chunkSize, chunkOverlap int) DocumentQAEngineOption {
+	return func(e *DocumentQAEngine) {
+		e.chunkSize = chunkSize
+		e.chunkOverlap = chunkOverlap
+	}
+}
+
+// WithDocumentQAReduceBudget sets the maximum tokens of partial answers
+// merged in one reduce call. Defaults to 3000.
+func WithDocumentQAReduceBudget(tokens int) DocumentQAEngineOption {
+	return func(e *DocumentQAEngine) {
+		e.reduceBudget = tokens
+	}
+}
+
+// WithDocumentQAConcurrency sets how many LLM calls run at once. Defaults
+// to 4.
+func WithDocumentQAConcurrency(n int) DocumentQAEngineOption {
+	return func(e *DocumentQAEngine) {
+		e.concurrency = n
+	}
+}
+
+// WithDocumentQATokenizer sets the tokenizer used for chunking and
+// budgeting, so sizes are measured in the model's tokens.
+func WithDocumentQATokenizer(tokenizer textsplitter.Tokenizer) DocumentQAEngineOption {
+	return func(e *DocumentQAEngine) {
+		e.tokenizer = tokenizer
+	}
+}
+
+// WithDocumentQAMapPrompt sets the map prompt.
+func WithDocumentQAMapPrompt(prompt prompts.BasePromptTemplate) DocumentQAEngineOption {
+	return func(e *DocumentQAEngine) {
+		e.MapPrompt = prompt
+	}
+}
+
+// WithDocumentQAReducePrompt sets the reduce prompt.
+func WithDocumentQAReducePrompt(prompt prompts.BasePromptTemplate) DocumentQAEngineOption {
+	return func(e *DocumentQAEngine) {
+		e.ReducePrompt = prompt
+	}
+}
+
+// NewDocumentQAEngine creates a DocumentQAEngine over document.
+func NewDocumentQAEngine(document string, llmModel llm.LLM, opts ...DocumentQAEngineOption) *DocumentQAEngine {
+	e := &DocumentQAEngine{
+		BaseQueryEngine: NewBaseQueryEngine(),
+		LLM:             llmModel,
+		MapPrompt:       prompts.NewPromptTemplate(DefaultDocumentQAMapPromptTmpl, prompts.PromptTypeQuestionAnswer),
+		ReducePrompt:    prompts.NewPromptTemplate(DefaultDocumentQAReducePromptTmpl, prompts.PromptTypeSummary),
+		document:        document,
+		chunkSize:       2048,
+		chunkOverlap:    128,
+		reduceBudget:    3000,
+		concurrency:     4,
+		tokenizer:       textsplitter.NewSimpleTokenizer(),
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	e.SetPrompt("map_prompt", e.MapPrompt)
+	e.SetPrompt("reduce_prompt", e.ReducePrompt)
+
+	e.chunks = textsplitter.NewTokenTextSplitterWithTokenizer(e.chunkSize, e.chunkOverlap, e.tokenizer).SplitText(document)
+
+	return e
+}
+
+// Chunks returns the document chunks the map stage runs over.
+func (e *DocumentQAEngine) Chunks() []string {
+	return e.chunks
+}
+
+// Query answers the query from the document.
+func (e *DocumentQAEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	partials, err := e.mapChunks(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var answers []string
+	var sources []schema.NodeWithScore
+	for i, p := range partials {
+		if p == "" {
+			continue
+		}
+		answers = append(answers, p)
+		node := schema.NewTextNode(e.chunks[i])
+		node.Metadata[DocumentQAChunkIndexMetadataKey] = i
+		sources = append(sources, schema.NodeWithScore{Node: *node, Score: 1.0})
+	}
+
+	metadata := map[string]interface{}{
+		DocumentQAChunksMetadataKey:   len(e.chunks),
+		DocumentQARelevantMetadataKey: len(answers),
+	}
+	if len(answers) == 0 {
+		metadata[DocumentQARoundsMetadataKey] = 0
+		return synthesizer.NewResponseWithMetadata("The document does not contain an answer to the query.", nil, metadata), nil
+	}
+
+	answer, rounds, err := e.reduce(ctx, query, answers)
+	if err != nil {
+		return nil, err
+	}
+	metadata[DocumentQARoundsMetadataKey] = rounds
+
+	return synthesizer.NewResponseWithMetadata(answer, sources, metadata), nil
+}
+
+// mapChunks asks every chunk the query. Chunks with nothing relevant yield
+// an empty string.
+func (e *DocumentQAEngine) mapChunks(ctx context.Context, query string) ([]string, error) {
+	inputs := make([]string, len(e.chunks))
+	for i, chunk := range e.chunks {
+		inputs[i] = e.MapPrompt.Format(map[string]string{
+			"context_str": chunk,
+			"query_str":   query,
+		})
+	}
+
+	results, err := e.completeAll(ctx, inputs)
+	if err != nil {
+		return nil, fmt.Errorf("map stage failed: %w", err)
+	}
+	for i, r := range results {
+		r = strings.TrimSpace(r)
+		if isNotRelevant(r) {
+			r = ""
+		}
+		results[i] = r
+	}
+	return results, nil
+}
+
+// reduce merges answers in batches that fit the reduce budget until one
+// answer remains. It returns the answer and the number of rounds.
+func (e *DocumentQAEngine) reduce(ctx context.Context, query string, answers []string) (string, int, error) {
+	rounds := 0
+	for len(answers) > 1 || rounds == 0 {
+		batches := e.batch(answers)
+		inputs := make([]string, len(batches))
+		for i, b := range batches {
+			inputs[i] = e.ReducePrompt.Format(map[string]string{
+				"context_str": strings.Join(b, "\n\n"),
+				"query_str":   query,
+			})
+		}
+
+		merged, err := e.completeAll(ctx, inputs)
+		if err != nil {
+			return "", rounds, fmt.Errorf("reduce stage failed: %w", err)
+		}
+		rounds++
+		for i := range merged {
+			merged[i] = strings.TrimSpace(merged[i])
+		}
+
+		if len(merged) >= len(answers) && len(answers) > 1 {
+			// Each answer alone fills the budget; merge pairwise so the
+			// loop still makes progress.
+			return e.reducePairs(ctx, query, merged, rounds)
+		}
+		answers = merged
+	}
+	return answers[0], rounds, nil
+}
+
+// reducePairs merges answers two at a time, ignoring the budget.
+func (e *DocumentQAEngine) reducePairs(ctx context.Context, query string, answers []string, rounds int) (string, int, error) {
+	for len(answers) > 1 {
+		var inputs []string
+		for i := 0; i < len(answers); i += 2 {
+			end := i + 2
+			if end > len(answers) {
+				end = len(answers)
+			}
+			inputs = append(inputs, e.ReducePrompt.Format(map[string]string{
+				"context_str": strings.Join(answers[i:end], "\n\n"),
+				"query_str":   query,
+			}))
+		}
+		merged, err := e.completeAll(ctx, inputs)
+		if err != nil {
+			return "", rounds, fmt.Errorf("reduce stage failed: %w", err)
+		}
+		rounds++
+		for i := range merged {
+			merged[i] = strings.TrimSpace(merged[i])
+		}
+		answers = merged
+	}
+	return answers[0], rounds, nil
+}
+
+// batch groups answers, in order, so each group stays within the reduce
+// budget. A single answer larger than the budget gets its own group.
+func (e *DocumentQAEngine) batch(answers []string) [][]string {
+	var batches [][]string
+	var current []string
+	used := 0
+	for _, a := range answers {
+		n := len(e.tokenizer.Encode(a))
+		if len(current) > 0 && e.reduceBudget > 0 && used+n > e.reduceBudget {
+			batches = append(batches, current)
+			current, used = nil, 0
+		}
+		current = append(current, a)
+		used += n
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// completeAll runs the prompts with bounded concurrency, keeping order.
+func (e *DocumentQAEngine) completeAll(ctx context.Context, inputs []string) ([]string, error) {
+	results := make([]string, len(inputs))
+	workers := e.concurrency
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	semaphore := make(chan struct{}, workers)
+
+	for i, prompt := range inputs {
+		wg.Add(1)
+		go func(i int, prompt string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			if ctx.Err() != nil {
+				return
+			}
+			out, err := e.LLM.Complete(ctx, prompt)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			results[i] = out
+		}(i, prompt)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// isNotRelevant reports whether a map answer says the chunk is irrelevant.
+func isNotRelevant(answer string) bool {
+	a := strings.ToUpper(strings.Trim(answer, " \t\n\"'."))
+	return a == "" || a == DocumentQANotRelevant || strings.HasPrefix(a, DocumentQANotRelevant)
+}
+
+// Ensure DocumentQAEngine implements QueryEngine.
+var _ QueryEngine = (*DocumentQAEngine)(nil)
@@ -0,0 +1,370 @@
+package queryengine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// Metadata keys set on responses from a FallbackEngine.
+const (
+	// FallbackPathMetadataKey holds the name of the path that answered.
+	FallbackPathMetadataKey = "fallback_path"
+	// FallbackFailuresMetadataKey holds the []PathFailure of the paths
+	// tried before it, in order.
+	FallbackFailuresMetadataKey = "fallback_failures"
+)
+
+// PrimaryPathName is the name of a FallbackEngine's primary path.
+const PrimaryPathName = "primary"
+
+// FailureKind classifies why a query path failed.
+type FailureKind string
+
+const (
+	// FailureEmptyRetrieval means the retriever found no nodes.
+	FailureEmptyRetrieval FailureKind = "empty_retrieval"
+	// FailureRetrievalError means the retriever returned an error.
+	FailureRetrievalError FailureKind = "retrieval_error"
+	// FailureLLMError means synthesis, i.e. the LLM call, returned an error.
+	FailureLLMError FailureKind = "llm_error"
+	// FailureLowConfidence means the engine abstained or its answer scored
+	// below the minimum confidence.
+	FailureLowConfidence FailureKind = "low_confidence"
+	// FailureError means an engine that does not expose its retrieval and
+	// synthesis stages returned an error.
+	FailureError FailureKind = "error"
+)
+
+// PathFailure records a failed attempt of a FallbackEngine.
+type PathFailure struct {
+	// Path is the name of the path.
+	Path string `json:"path"`
+	// Kind classifies the failure.
+	Kind FailureKind `json:"kind"`
+	// Error is the error message, if the path returned an error.
+	Error string `json:"error,omitempty"`
+}
+
+// Fallback is a secondary path of a FallbackEngine.
+type Fallback struct {
+	// Name identifies the path in metadata and stats.
+	Name string
+	// Engine answers queries routed to this path.
+	Engine QueryEngine
+	// On lists the failures routed to this path. Empty means any failure.
+	On []FailureKind
+	// Final accepts the path's answer without classifying it, as for a
+	// canned response.
+	Final bool
+}
+
+// NewFallback creates a Fallback for the given failures, or for any
+// failure if none are listed.
+func NewFallback(name string, engine QueryEngine, on ...FailureKind) Fallback {
+	return Fallback{Name: name, Engine: engine, On: on}
+}
+
+// NewCannedFallback creates a final Fallback that answers with a fixed
+// response.
+func NewCannedFallback(name, response string, on ...FailureKind) Fallback {
+	return Fallback{Name: name, Engine: NewCannedResponseEngine(response), On: on, Final: true}
+}
+
+// handles reports whether failures of the given kind are routed to f.
+func (f Fallback) handles(kind FailureKind) bool {
+	if len(f.On) == 0 {
+		return true
+	}
+	for _, k := range f.On {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// FallbackStats counts how queries were handled by a FallbackEngine.
+type FallbackStats struct {
+	// Queries is the number of queries handled.
+	Queries int
+	// Attempts counts calls per path.
+	Attempts map[string]int
+	// Answered counts accepted answers per path.
+	Answered map[string]int
+	// Failures counts failures per path and kind.
+	Failures map[string]map[FailureKind]int
+	// Latency is the total time spent per path.
+	Latency map[string]time.Duration
+	// Unanswered counts queries no path answered.
+	Unanswered int
+}
+
+// FallbackRate returns the fraction of queries not answered by the primary
+// path.
+func (s FallbackStats) FallbackRate() float64 {
+	if s.Queries == 0 {
+		return 0
+	}
+	return float64(s.Queries-s.Answered[PrimaryPathName]) / float64(s.Queries)
+}
+
+func newFallbackStats() FallbackStats {
+	return FallbackStats{
+		Attempts: make(map[string]int),
+		Answered: make(map[string]int),
+		Failures: make(map[string]map[FailureKind]int),
+		Latency:  make(map[string]time.Duration),
+	}
+}
+
+// FallbackEngine answers with a primary engine and, when it fails, routes
+// the query to a fallback chosen by the kind of failure: an empty retrieval
+// can go to a broader retriever, a low-confidence answer to web search, and
+// an LLM error to a canned response.
+//
+// Each attempt is classified. Engines implementing QueryEngineWithRetrieval
+// are run stage by stage, so an empty retrieval falls back without an LLM
+// call and synthesis errors count as LLM errors. Any answer that abstained,
+// or scored below the minimum confidence, is low confidence.
+//
+// After a failure, the first untried fallback handling its kind runs next.
+// When none is left, the last answer is returned even if it failed, so
+// callers still get e.g. an abstention message; if every path returned an
+// error, the last error is returned.
+type FallbackEngine struct {
+	*BaseQueryEngine
+	primary       QueryEngine
+	fallbacks     []Fallback
+	minConfidence float64
+	scorer        ConfidenceScorer
+
+	mu    sync.Mutex
+	stats FallbackStats
+}
+
+// NewFallbackEngine creates a FallbackEngine. Fallbacks are considered in
+// order.
+func NewFallbackEngine(primary QueryEngine, fallbacks ...Fallback) *FallbackEngine {
+	return &FallbackEngine{
+		BaseQueryEngine: NewBaseQueryEngine(),
+		primary:         primary,
+		fallbacks:       fallbacks,
+		stats:           newFallbackStats(),
+	}
+}
+
+// WithMinConfidence sets the confidence an answer needs to be accepted.
+// With the default of 0, only abstained answers are low confidence.
+func (e *FallbackEngine) WithMinConfidence(min float64) *FallbackEngine {
+	e.minConfidence = min
+	return e
+}
+
+// WithConfidenceScorer sets the scorer used to judge answers when
+// a minimum confidence is set.
+func (e *FallbackEngine) WithConfidenceScorer(scorer ConfidenceScorer) *FallbackEngine {
+	e.scorer = scorer
+	return e
+}
+
+// Query answers with the primary engine, falling back on failure.
+func (e *FallbackEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	e.record(func(s *FallbackStats) { s.Queries++ })
+
+	var failures []PathFailure
+	var lastResp *synthesizer.Response
+	var lastErr error
+
+	path := Fallback{Name: PrimaryPathName, Engine: e.primary}
+	tried := make([]bool, len(e.fallbacks))
+	for {
+		resp, kind, err := e.attempt(ctx, path, query)
+		if kind == "" {
+			e.record(func(s *FallbackStats) { s.Answered[path.Name]++ })
+			return withFallbackMetadata(resp, path.Name, failures), nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		failure := PathFailure{Path: path.Name, Kind: kind}
+		if err != nil {
+			failure.Error = err.Error()
+			lastErr = err
+		} else {
+			lastResp = resp
+		}
+		failures = append(failures, failure)
+
+		next := -1
+		for i, f := range e.fallbacks {
+			if !tried[i] && f.handles(kind) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+		tried[next] = true
+		path = e.fallbacks[next]
+	}
+
+	e.record(func(s *FallbackStats) { s.Unanswered++ })
+	if lastResp != nil {
+		return withFallbackMetadata(lastResp, failures[len(failures)-1].Path, failures), nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no query path answered")
+	}
+	return nil, fmt.Errorf("all query paths failed: %w", lastErr)
+}
+
+// attempt runs one path and classifies the outcome. An empty kind means
+// the answer is accepted.
+func (e *FallbackEngine) attempt(ctx context.Context, path Fallback, query string) (*synthesizer.Response, FailureKind, error) {
+	start := time.Now()
+	resp, kind, err := e.run(ctx, path, query)
+	elapsed := time.Since(start)
+
+	e.record(func(s *FallbackStats) {
+		s.Attempts[path.Name]++
+		s.Latency[path.Name] += elapsed
+		if kind != "" {
+			if s.Failures[path.Name] == nil {
+				s.Failures[path.Name] = make(map[FailureKind]int)
+			}
+			s.Failures[path.Name][kind]++
+		}
+	})
+	return resp, kind, err
+}
+
+func (e *FallbackEngine) run(ctx context.Context, path Fallback, query string) (*synthesizer.Response, FailureKind, error) {
+	if path.Engine == nil {
+		return nil, FailureError, fmt.Errorf("path %s has no engine", path.Name)
+	}
+
+	var resp *synthesizer.Response
+	var err error
+	if staged, ok := path.Engine.(QueryEngineWithRetrieval); ok {
+		nodes, err := staged.Retrieve(ctx, schema.QueryBundle{QueryString: query})
+		if err != nil {
+			return nil, FailureRetrievalError, err
+		}
+		if len(nodes) == 0 {
+			return nil, FailureEmptyRetrieval, nil
+		}
+		resp, err = staged.Synthesize(ctx, query, nodes)
+		if err != nil {
+			return nil, FailureLLMError, err
+		}
+	} else {
+		resp, err = path.Engine.Query(ctx, query)
+		if err != nil {
+			return nil, FailureError, err
+		}
+	}
+
+	if path.Final {
+		return resp, "", nil
+	}
+	if abstained, _ := IsAbstained(resp); abstained {
+		return resp, FailureLowConfidence, nil
+	}
+	if e.minConfidence > 0 {
+		confidence, err := responseConfidence(ctx, e.scorer, query, resp)
+		if err != nil {
+			return nil, FailureError, fmt.Errorf("confidence check failed: %w", err)
+		}
+		if confidence < e.minConfidence {
+			return resp, FailureLowConfidence, nil
+		}
+	}
+	return resp, "", nil
+}
+
+func (e *FallbackEngine) record(update func(*FallbackStats)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	update(&e.stats)
+}
+
+// Stats returns a snapshot of the per-path counters.
+func (e *FallbackEngine) Stats() FallbackStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	snapshot := newFallbackStats()
+	snapshot.Queries = e.stats.Queries
+	snapshot.Unanswered = e.stats.Unanswered
+	for k, v := range e.stats.Attempts {
+		snapshot.Attempts[k] = v
+	}
+	for k, v := range e.stats.Answered {
+		snapshot.Answered[k] = v
+	}
+	for k, v := range e.stats.Latency {
+		snapshot.Latency[k] = v
+	}
+	for path, kinds := range e.stats.Failures {
+		snapshot.Failures[path] = make(map[FailureKind]int, len(kinds))
+		for k, v := range kinds {
+			snapshot.Failures[path][k] = v
+		}
+	}
+	return snapshot
+}
+
+// ResetStats clears the per-path counters.
+func (e *FallbackEngine) ResetStats() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stats = newFallbackStats()
+}
+
+// withFallbackMetadata returns a copy of resp annotated with the path that
+// answered and the failures before it.
+func withFallbackMetadata(resp *synthesizer.Response, path string, failures []PathFailure) *synthesizer.Response {
+	metadata := make(map[string]interface{}, len(resp.Metadata)+2)
+	for k, v := range resp.Metadata {
+		metadata[k] = v
+	}
+	metadata[FallbackPathMetadataKey] = path
+	metadata[FallbackFailuresMetadataKey] = failures
+
+	out := *resp
+	out.Metadata = metadata
+	return &out
+}
+
+// CannedResponseEngine answers every query with a fixed response.
+type CannedResponseEngine struct {
+	*BaseQueryEngine
+	// Response is the response text.
+	Response string
+}
+
+// NewCannedResponseEngine creates a CannedResponseEngine.
+func NewCannedResponseEngine(response string) *CannedResponseEngine {
+	return &CannedResponseEngine{
+		BaseQueryEngine: NewBaseQueryEngine(),
+		Response:        response,
+	}
+}
+
+// Query returns the canned response.
+func (e *CannedResponseEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	return synthesizer.NewResponse(e.Response, nil), nil
+}
+
+// Ensure the engines implement QueryEngine.
+var (
+	_ QueryEngine = (*FallbackEngine)(nil)
+	_ QueryEngine = (*CannedResponseEngine)(nil)
+)
@@ -0,0 +1,358 @@
+package queryengine
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/rag/retriever"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// FLARETraceMetadataKey holds the []FLAREStep trace in Response.Metadata.
+const FLARETraceMetadataKey = "flare_trace"
+
+// Default prompt for generating the next sentence of a FLARE answer.
+const defaultFLAREPrompt = `Context information is below.
+---------------------
+{context_str}
+---------------------
+Write the answer to the question one sentence at a time, continuing the answer so far.
+If you are not sure about a fact that is missing from the context, write "[Search(query)]" in its place, where query is a search query for the missing information.
+If the answer so far fully answers the question, respond with DONE.
+
+Question: {query_str}
+Answer so far: {existing_answer}
+Next sentence:`
+
+// flareDone is the marker the LLM uses to finish the answer.
+const flareDone = "DONE"
+
+// flareSearchPattern matches the [Search(query)] markers the LLM writes for
+// spans it is unsure about.
+var flareSearchPattern = regexp.MustCompile(`\[Search\((.*?)\)\]`)
+
+// TokenLogProb is a generated token with its log probability.
+type TokenLogProb struct {
+	// Token is the token text, including any leading whitespace.
+	Token string
+	// LogProb is the natural log of the token's probability.
+	LogProb float64
+}
+
+// LogProbLLM is an LLM that can report token log probabilities. When the
+// FLAREQueryEngine's LLM implements it, low-probability tokens mark a
+// lookahead sentence as low confidence in addition to [Search(query)]
+// markers.
+type LogProbLLM interface {
+	llm.LLM
+	// CompleteWithLogProbs generates a completion and the log probability
+	// of each of its tokens. The tokens concatenate to the completion.
+	CompleteWithLogProbs(ctx context.Context, prompt string) (string, []TokenLogProb, error)
+}
+
+// FLAREStep records one generate→check→retrieve cycle.
+type FLAREStep struct {
+	// Lookahead is the tentatively generated sentence.
+	Lookahead string
+	// LowConfidence reports whether the lookahead triggered retrieval.
+	LowConfidence bool
+	// Queries are the retrieval queries issued for the low-confidence spans.
+	Queries []string
+	// NodeIDs are the IDs of nodes newly added to the context.
+	NodeIDs []string
+	// Sentence is the sentence appended to the answer.
+	Sentence string
+}
+
+// FLAREQueryEngine implements Forward-Looking Active REtrieval: the answer
+// is generated one sentence at a time, and each tentative lookahead
+// sentence is checked for low-confidence spans. Spans are flagged by the
+// LLM writing [Search(query)] markers or, for a LogProbLLM, by tokens below
+// ConfidenceThreshold. Flagged spans are retrieved for and the sentence is
+// regenerated with the new context before it is kept.
+type FLAREQueryEngine struct {
+	*BaseQueryEngine
+	// Retriever looks up context for the question and low-confidence spans.
+	Retriever retriever.Retriever
+	// LLM generates the answer.
+	LLM llm.LLM
+	// Prompt is the template used to generate each sentence.
+	Prompt prompts.BasePromptTemplate
+	// LookaheadLength caps the lookahead sentence, in words.
+	LookaheadLength int
+	// ConfidenceThreshold is the token probability below which a token is
+	// low confidence. Only used with a LogProbLLM.
+	ConfidenceThreshold float64
+	// MaxIterations bounds the number of sentences generated.
+	MaxIterations int
+}
+
+// FLAREQueryEngineOption is a functional option.
+type FLAREQueryEngineOption func(*FLAREQueryEngine)
+
+// WithFLAREPrompt sets the sentence generation prompt template.
+func WithFLAREPrompt(prompt prompts.BasePromptTemplate) FLAREQueryEngineOption {
+	return func(fqe *FLAREQueryEngine) {
+		fqe.Prompt = prompt
+	}
+}
+
+// WithFLARELookaheadLength sets the maximum lookahead length in words.
+// Defaults to 64.
+func WithFLARELookaheadLength(words int) FLAREQueryEngineOption {
+	return func(fqe *FLAREQueryEngine) {
+		fqe.LookaheadLength = words
+	}
+}
+
+// WithFLAREConfidenceThreshold sets the token probability below which a
+// lookahead triggers retrieval. Defaults to 0.4.
+func WithFLAREConfidenceThreshold(threshold float64) FLAREQueryEngineOption {
+	return func(fqe *FLAREQueryEngine) {
+		fqe.ConfidenceThreshold = threshold
+	}
+}
+
+// WithFLAREMaxIterations sets the maximum number of sentences generated.
+// Defaults to 10.
+func WithFLAREMaxIterations(maxIterations int) FLAREQueryEngineOption {
+	return func(fqe *FLAREQueryEngine) {
+		fqe.MaxIterations = maxIterations
+	}
+}
+
+// WithFLAREVerbose enables verbose logging.
+func WithFLAREVerbose(verbose bool) FLAREQueryEngineOption {
+	return func(fqe *FLAREQueryEngine) {
+		fqe.Verbose = verbose
+	}
+}
+
+// NewFLAREQueryEngine creates a new FLAREQueryEngine.
+func NewFLAREQueryEngine(ret retriever.Retriever, llmModel llm.LLM, opts ...FLAREQueryEngineOption) *FLAREQueryEngine {
+	fqe := &FLAREQueryEngine{
+		BaseQueryEngine:     NewBaseQueryEngine(),
+		Retriever:           ret,
+		LLM:                 llmModel,
+		Prompt:              prompts.NewPromptTemplate(defaultFLAREPrompt, prompts.PromptTypeCustom),
+		LookaheadLength:     64,
+		ConfidenceThreshold: 0.4,
+		MaxIterations:       10,
+	}
+
+	for _, opt := range opts {
+		opt(fqe)
+	}
+
+	fqe.SetPrompt("flare_prompt", fqe.Prompt)
+
+	return fqe
+}
+
+// flareContext accumulates the deduplicated retrieved nodes.
+type flareContext struct {
+	nodes []schema.NodeWithScore
+	seen  map[string]bool
+}
+
+// add appends unseen nodes and returns their IDs.
+func (c *flareContext) add(nodes []schema.NodeWithScore) []string {
+	var added []string
+	for _, n := range nodes {
+		if c.seen[n.Node.ID] {
+			continue
+		}
+		c.seen[n.Node.ID] = true
+		c.nodes = append(c.nodes, n)
+		added = append(added, n.Node.ID)
+	}
+	return added
+}
+
+func (c *flareContext) String() string {
+	if len(c.nodes) == 0 {
+		return "No context retrieved yet."
+	}
+	var sb strings.Builder
+	for i, n := range c.nodes {
+		sb.WriteString(fmt.Sprintf("[%d] %s\n", i+1, n.Node.GetContent(schema.MetadataModeLLM)))
+	}
+	return sb.String()
+}
+
+// Query generates an answer with lookahead retrieval.
+func (fqe *FLAREQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	fc := &flareContext{seen: make(map[string]bool)}
+	initial, err := fqe.Retriever.Retrieve(ctx, schema.QueryBundle{QueryString: query})
+	if err != nil {
+		return nil, fmt.Errorf("initial retrieval failed: %w", err)
+	}
+	fc.add(initial)
+
+	maxIterations := fqe.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = 1
+	}
+
+	var sentences []string
+	var steps []FLAREStep
+	for i := 0; i < maxIterations; i++ {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		existing := strings.Join(sentences, " ")
+		lookahead, tokens, err := fqe.generate(ctx, query, existing, fc)
+		if err != nil {
+			return nil, fmt.Errorf("generation failed at step %d: %w", i, err)
+		}
+		lookahead, done := fqe.trimLookahead(lookahead)
+		if lookahead == "" {
+			break
+		}
+
+		step := FLAREStep{Lookahead: lookahead, Sentence: lookahead}
+		step.Queries = fqe.lowConfidenceQueries(query, lookahead, tokens)
+		if len(step.Queries) > 0 {
+			step.LowConfidence = true
+			for _, q := range step.Queries {
+				nodes, err := fqe.Retriever.Retrieve(ctx, schema.QueryBundle{QueryString: q})
+				if err != nil {
+					return nil, fmt.Errorf("retrieval failed at step %d: %w", i, err)
+				}
+				step.NodeIDs = append(step.NodeIDs, fc.add(nodes)...)
+			}
+
+			regenerated, _, err := fqe.generate(ctx, query, existing, fc)
+			if err != nil {
+				return nil, fmt.Errorf("regeneration failed at step %d: %w", i, err)
+			}
+			step.Sentence, done = fqe.trimLookahead(regenerated)
+		}
+		step.Sentence = stripSearchMarkers(step.Sentence)
+
+		if fqe.Verbose {
+			fmt.Printf("FLARE step %d: %q (low confidence: %v)\n", i, step.Sentence, step.LowConfidence)
+		}
+
+		steps = append(steps, step)
+		if step.Sentence != "" {
+			sentences = append(sentences, step.Sentence)
+		}
+		if done {
+			break
+		}
+	}
+
+	return synthesizer.NewResponseWithMetadata(strings.Join(sentences, " "), fc.nodes, map[string]interface{}{
+		FLARETraceMetadataKey: steps,
+	}), nil
+}
+
+// generate produces the next tentative sentence, with token log
+// probabilities when the LLM supports them.
+func (fqe *FLAREQueryEngine) generate(ctx context.Context, query, existing string, fc *flareContext) (string, []TokenLogProb, error) {
+	prompt := fqe.Prompt.Format(map[string]string{
+		"context_str":     fc.String(),
+		"query_str":       query,
+		"existing_answer": existing,
+	})
+
+	if lp, ok := fqe.LLM.(LogProbLLM); ok {
+		return lp.CompleteWithLogProbs(ctx, prompt)
+	}
+	text, err := fqe.LLM.Complete(ctx, prompt)
+	return text, nil, err
+}
+
+// trimLookahead cuts generated text to its first sentence and the
+// lookahead length, and reports whether the LLM signalled completion.
+func (fqe *FLAREQueryEngine) trimLookahead(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	done := false
+	if idx := strings.Index(text, flareDone); idx >= 0 {
+		text = strings.TrimSpace(text[:idx])
+		done = true
+	}
+
+	text = firstSentence(text)
+
+	if fqe.LookaheadLength > 0 {
+		words := strings.Fields(text)
+		if len(words) > fqe.LookaheadLength {
+			text = strings.Join(words[:fqe.LookaheadLength], " ")
+		}
+	}
+	return text, done
+}
+
+// lowConfidenceQueries returns the retrieval queries for the low-confidence
+// spans of a lookahead sentence: the contents of [Search(query)] markers
+// and, given token log probabilities, the sentence with its low-confidence
+// tokens masked out.
+func (fqe *FLAREQueryEngine) lowConfidenceQueries(query, lookahead string, tokens []TokenLogProb) []string {
+	var queries []string
+	for _, m := range flareSearchPattern.FindAllStringSubmatch(lookahead, -1) {
+		if q := strings.TrimSpace(m[1]); q != "" {
+			queries = append(queries, q)
+		}
+	}
+
+	if len(tokens) > 0 {
+		threshold := math.Log(fqe.ConfidenceThreshold)
+		var generated, masked strings.Builder
+		lowConfidence := false
+		for _, t := range tokens {
+			// Tokens past the lookahead sentence were cut off.
+			if len(strings.TrimSpace(generated.String())) >= len(lookahead) {
+				break
+			}
+			generated.WriteString(t.Token)
+			if t.LogProb < threshold {
+				lowConfidence = true
+				continue
+			}
+			masked.WriteString(t.Token)
+		}
+		if lowConfidence {
+			q := strings.TrimSpace(stripSearchMarkers(masked.String()))
+			if q == "" {
+				q = query
+			}
+			queries = append(queries, q)
+		}
+	}
+	return queries
+}
+
+// firstSentence returns text up to and including its first sentence
+// terminator.
+func firstSentence(text string) string {
+	for i, r := range text {
+		switch r {
+		case '\n':
+			return strings.TrimSpace(text[:i])
+		case '.', '?', '!':
+			if i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\n' {
+				return strings.TrimSpace(text[:i+1])
+			}
+		}
+	}
+	return strings.TrimSpace(text)
+}
+
+// stripSearchMarkers removes [Search(query)] markers from text.
+func stripSearchMarkers(text string) string {
+	return strings.Join(strings.Fields(flareSearchPattern.ReplaceAllString(text, "")), " ")
+}
+
+// Ensure FLAREQueryEngine implements QueryEngine.
+var _ QueryEngine = (*FLAREQueryEngine)(nil)
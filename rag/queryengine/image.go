@@ -0,0 +1,163 @@
+package queryengine
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/rag/retriever"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// ImageNodesMetadataKey holds the retrieved []*schema.ImageNode on
+// responses from ImageQueryEngine.
+const ImageNodesMetadataKey = "image_nodes"
+
+// DefaultImageQAPromptTmpl asks a vision model to answer from the attached
+// images.
+const DefaultImageQAPromptTmpl = `The images below were retrieved for the query.
+Answer the query using only what is visible in the images. If the images do not answer it, say so.
+Query: {query_str}
+Answer: `
+
+// ImageQueryEngine retrieves images for a text query and, when a vision
+// LLM is set, answers the query grounded in the retrieved images. Without
+// an LLM it returns the image references as the response text.
+//
+// The retrieved images are returned as source nodes and, rebuilt as
+// ImageNodes, under ImageNodesMetadataKey.
+type ImageQueryEngine struct {
+	*BaseQueryEngine
+	// Retriever finds images, typically a retriever.ImageRetriever.
+	Retriever retriever.Retriever
+	// LLM is the vision model that answers from the images. Optional.
+	LLM llm.LLM
+	// Prompt is the text sent with the images.
+	Prompt prompts.BasePromptTemplate
+}
+
+// ImageQueryEngineOption is a functional option for ImageQueryEngine.
+type ImageQueryEngineOption func(*ImageQueryEngine)
+
+// WithImageLLM sets the vision LLM used to answer from the images.
+func WithImageLLM(visionLLM llm.LLM) ImageQueryEngineOption {
+	return func(e *ImageQueryEngine) {
+		e.LLM = visionLLM
+	}
+}
+
+// WithImageQAPrompt sets the prompt sent with the images.
+func WithImageQAPrompt(prompt prompts.BasePromptTemplate) ImageQueryEngineOption {
+	return func(e *ImageQueryEngine) {
+		e.Prompt = prompt
+	}
+}
+
+// NewImageQueryEngine creates a new ImageQueryEngine.
+func NewImageQueryEngine(r retriever.Retriever, opts ...ImageQueryEngineOption) *ImageQueryEngine {
+	e := &ImageQueryEngine{
+		BaseQueryEngine: NewBaseQueryEngine(),
+		Retriever:       r,
+		Prompt:          prompts.NewPromptTemplate(DefaultImageQAPromptTmpl, prompts.PromptTypeQuestionAnswer),
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	e.SetPrompt("image_qa_template", e.Prompt)
+
+	return e
+}
+
+// Query retrieves images for the query and answers from them.
+func (e *ImageQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	nodes, err := e.Retriever.Retrieve(ctx, schema.QueryBundle{QueryString: query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve images: %w", err)
+	}
+
+	var images []*schema.ImageNode
+	var sources []schema.NodeWithScore
+	for _, n := range nodes {
+		if img, ok := schema.ImageNodeFromNode(n.Node); ok {
+			images = append(images, img)
+			sources = append(sources, n)
+		}
+	}
+
+	metadata := map[string]interface{}{ImageNodesMetadataKey: images}
+	if len(images) == 0 {
+		return synthesizer.NewResponseWithMetadata("No relevant images found.", nil, metadata), nil
+	}
+	if e.LLM == nil {
+		return synthesizer.NewResponseWithMetadata(describeImages(images), sources, metadata), nil
+	}
+
+	blocks := []llm.ContentBlock{llm.NewTextBlock(e.Prompt.Format(map[string]string{"query_str": query}))}
+	for _, img := range images {
+		block, err := imageBlock(img)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+
+	answer, err := e.LLM.Chat(ctx, []llm.ChatMessage{llm.NewMultiModalMessage(llm.MessageRoleUser, blocks...)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to answer from images: %w", err)
+	}
+
+	return synthesizer.NewResponseWithMetadata(answer, sources, metadata), nil
+}
+
+// imageBlock turns an image node into a chat content block. Local files
+// are read and sent inline.
+func imageBlock(img *schema.ImageNode) (llm.ContentBlock, error) {
+	mimeType := img.ImageMimeType
+	switch {
+	case img.Image != "":
+		return llm.NewImageBase64Block(img.Image, mimeType), nil
+	case img.ImageURL != "":
+		return llm.NewImageURLBlock(img.ImageURL, mimeType), nil
+	}
+
+	data, err := os.ReadFile(img.ImagePath)
+	if err != nil {
+		return llm.ContentBlock{}, fmt.Errorf("failed to read image %s: %w", img.ImagePath, err)
+	}
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(filepath.Ext(img.ImagePath))
+	}
+	return llm.NewImageBase64Block(base64.StdEncoding.EncodeToString(data), mimeType), nil
+}
+
+// describeImages lists image references, one per line.
+func describeImages(images []*schema.ImageNode) string {
+	lines := make([]string, 0, len(images)+1)
+	lines = append(lines, fmt.Sprintf("Found %d relevant images:", len(images)))
+	for _, img := range images {
+		ref := img.ImageURL
+		if ref == "" {
+			ref = img.ImagePath
+		}
+		if ref == "" {
+			ref = "inline image " + img.ID
+		}
+		if text := strings.TrimSpace(img.Text); text != "" {
+			ref += " (" + text + ")"
+		}
+		lines = append(lines, "- "+ref)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Ensure ImageQueryEngine implements QueryEngine.
+var _ QueryEngine = (*ImageQueryEngine)(nil)
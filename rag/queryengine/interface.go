@@ -3,8 +3,12 @@ package queryengine
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/aqua777/go-llamaindex/callbacks"
+	"github.com/aqua777/go-llamaindex/postprocessor"
 	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/querytransform"
 	"github.com/aqua777/go-llamaindex/rag/retriever"
 	"github.com/aqua777/go-llamaindex/rag/synthesizer"
 	"github.com/aqua777/go-llamaindex/schema"
@@ -67,6 +71,14 @@ type RetrieverQueryEngine struct {
 	Retriever retriever.Retriever
 	// Synthesizer generates responses from nodes.
 	Synthesizer synthesizer.Synthesizer
+	// NodePostprocessors filter or rerank the retrieved nodes, in order,
+	// before synthesis.
+	NodePostprocessors []postprocessor.NodePostprocessor
+	// Pager holds result snapshots for QueryWithCursor.
+	Pager *retriever.ResultPager
+	// QueryTransforms rewrite the query, in order, before retrieval.
+	// Postprocessors and synthesis see the original query.
+	QueryTransforms []QueryTransform
 }
 
 // RetrieverQueryEngineOption is a functional option.
@@ -79,6 +91,30 @@ func WithRetrieverQueryEngineVerbose(verbose bool) RetrieverQueryEngineOption {
 	}
 }
 
+// WithNodePostprocessors sets the postprocessors applied to retrieved nodes,
+// such as postprocessor.LLMRerank.
+func WithNodePostprocessors(postprocessors ...postprocessor.NodePostprocessor) RetrieverQueryEngineOption {
+	return func(rqe *RetrieverQueryEngine) {
+		rqe.NodePostprocessors = postprocessors
+	}
+}
+
+// WithQueryTransforms sets the transforms applied to the query before
+// retrieval, e.g. querytransform.NewHyDETransform.
+func WithQueryTransforms(transforms ...QueryTransform) RetrieverQueryEngineOption {
+	return func(rqe *RetrieverQueryEngine) {
+		rqe.QueryTransforms = transforms
+	}
+}
+
+// WithResultPager sets the pager used by QueryWithCursor, e.g. to change its
+// TTL or share it between engines.
+func WithResultPager(pager *retriever.ResultPager) RetrieverQueryEngineOption {
+	return func(rqe *RetrieverQueryEngine) {
+		rqe.Pager = pager
+	}
+}
+
 // NewRetrieverQueryEngine creates a new RetrieverQueryEngine.
 func NewRetrieverQueryEngine(
 	ret retriever.Retriever,
@@ -89,6 +125,7 @@ func NewRetrieverQueryEngine(
 		BaseQueryEngine: NewBaseQueryEngine(),
 		Retriever:       ret,
 		Synthesizer:     synth,
+		Pager:           retriever.NewResultPager(),
 	}
 
 	for _, opt := range opts {
@@ -98,8 +135,18 @@ func NewRetrieverQueryEngine(
 	return rqe
 }
 
-// Query executes a query and returns a response.
+// Query executes a query and returns a response. The query, its retrieval
+// and synthesis are reported to the callback manager in ctx, if any.
 func (rqe *RetrieverQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	ctx, span := callbacks.StartSpan(ctx, callbacks.CBEventTypeQuery, map[string]interface{}{
+		string(callbacks.EventPayloadQueryStr): query,
+	})
+	response, err := rqe.query(ctx, query)
+	span.End(responsePayload(response), err)
+	return response, err
+}
+
+func (rqe *RetrieverQueryEngine) query(ctx context.Context, query string) (*synthesizer.Response, error) {
 	queryBundle := schema.QueryBundle{QueryString: query}
 
 	// Retrieve nodes
@@ -112,14 +159,57 @@ func (rqe *RetrieverQueryEngine) Query(ctx context.Context, query string) (*synt
 	return rqe.Synthesize(ctx, query, nodes)
 }
 
-// Retrieve retrieves nodes for a query.
+// Retrieve retrieves nodes for a query, after applying the query
+// transforms, and runs the node postprocessors.
 func (rqe *RetrieverQueryEngine) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
-	return rqe.Retriever.Retrieve(ctx, query)
+	transformed, err := querytransform.Apply(ctx, query, rqe.QueryTransforms...)
+	if err != nil {
+		return nil, err
+	}
+
+	retrieveCtx, span := callbacks.StartSpan(ctx, callbacks.CBEventTypeRetrieve, map[string]interface{}{
+		string(callbacks.EventPayloadQueryStr): transformed.QueryString,
+	})
+	nodes, err := rqe.Retriever.Retrieve(retrieveCtx, transformed)
+	span.End(map[string]interface{}{string(callbacks.EventPayloadNodes): nodes}, err)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pp := range rqe.NodePostprocessors {
+		ppCtx, span := callbacks.StartSpan(ctx, callbacks.CBEventTypeReranking, map[string]interface{}{
+			string(callbacks.EventPayloadQueryStr): query.QueryString,
+			string(callbacks.EventPayloadNodes):    nodes,
+			"postprocessor":                        pp.Name(),
+		})
+		nodes, err = pp.PostprocessNodes(ppCtx, nodes, &query)
+		span.End(map[string]interface{}{string(callbacks.EventPayloadNodes): nodes}, err)
+		if err != nil {
+			return nil, fmt.Errorf("postprocessor %s failed: %w", pp.Name(), err)
+		}
+	}
+	return nodes, nil
 }
 
 // Synthesize synthesizes a response from nodes.
 func (rqe *RetrieverQueryEngine) Synthesize(ctx context.Context, query string, nodes []schema.NodeWithScore) (*synthesizer.Response, error) {
-	return rqe.Synthesizer.Synthesize(ctx, query, nodes)
+	ctx, span := callbacks.StartSpan(ctx, callbacks.CBEventTypeSynthesize, map[string]interface{}{
+		string(callbacks.EventPayloadQueryStr): query,
+		string(callbacks.EventPayloadNodes):    nodes,
+	})
+	response, err := rqe.Synthesizer.Synthesize(ctx, query, nodes)
+	span.End(responsePayload(response), err)
+	return response, err
+}
+
+// responsePayload returns the callback event payload of a response.
+func responsePayload(response *synthesizer.Response) map[string]interface{} {
+	payload := map[string]interface{}{}
+	if response != nil {
+		payload[string(callbacks.EventPayloadResponse)] = response.Response
+		payload[string(callbacks.EventPayloadNodes)] = response.SourceNodes
+	}
+	return payload
 }
 
 // Ensure RetrieverQueryEngine implements interfaces.
@@ -0,0 +1,45 @@
+package queryengine
+
+import (
+	"context"
+
+	"github.com/aqua777/go-llamaindex/limits"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+)
+
+// LimitedQueryEngine wraps a query engine and enforces the response limit
+// on its answers.
+type LimitedQueryEngine struct {
+	*BaseQueryEngine
+	// QueryEngine is the wrapped engine.
+	QueryEngine QueryEngine
+	// Limits are the limits to enforce. Nil uses limits.Global().
+	Limits *limits.Limits
+}
+
+// NewLimitedQueryEngine creates a new LimitedQueryEngine.
+func NewLimitedQueryEngine(engine QueryEngine, l *limits.Limits) *LimitedQueryEngine {
+	return &LimitedQueryEngine{
+		BaseQueryEngine: NewBaseQueryEngine(),
+		QueryEngine:     engine,
+		Limits:          l,
+	}
+}
+
+// Query executes the query and limits the response text.
+func (e *LimitedQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	response, err := e.QueryEngine.Query(ctx, query)
+	if err != nil || response == nil {
+		return response, err
+	}
+
+	text, err := limits.Resolve(e.Limits).Apply(limits.KindResponse, response.Response)
+	if err != nil {
+		return nil, err
+	}
+	response.Response = text
+	return response, nil
+}
+
+// Ensure LimitedQueryEngine implements QueryEngine.
+var _ QueryEngine = (*LimitedQueryEngine)(nil)
@@ -0,0 +1,277 @@
+package queryengine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/rag/retriever"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// MultiHopStepsMetadataKey holds the []MultiHopStep trace in Response.Metadata.
+const MultiHopStepsMetadataKey = "multi_hop_steps"
+
+// Default prompt for deciding the next hop.
+const defaultMultiHopPrompt = `You are answering a question that may require chaining facts from several documents.
+Evidence gathered so far:
+---------------------
+{evidence_str}
+---------------------
+Queries already issued:
+{queries_str}
+
+Question: {query_str}
+
+Decide whether the evidence is sufficient to answer the question.
+If it is not, write a single new search query that retrieves the missing fact.
+Respond in exactly this format:
+Reasoning: <one or two sentences>
+Next query: <new search query, or NONE if the evidence is sufficient>`
+
+// Evidence is a retrieved node with provenance information.
+type Evidence struct {
+	// Node is the retrieved node.
+	Node schema.NodeWithScore
+	// Hop is the zero-based hop in which the node was first retrieved.
+	Hop int
+	// Query is the query that retrieved the node.
+	Query string
+}
+
+// MultiHopStep records one retrieve→reason cycle.
+type MultiHopStep struct {
+	// Hop is the zero-based hop number.
+	Hop int
+	// Query is the query issued in this hop.
+	Query string
+	// NodeIDs are the IDs of nodes newly added to the evidence set.
+	NodeIDs []string
+	// Reasoning is the LLM's explanation after this hop.
+	Reasoning string
+}
+
+// MultiHopQueryEngine answers questions that require chaining facts across
+// documents. It alternates retrieval with an LLM reasoning step that either
+// declares the evidence sufficient or proposes the next query, up to MaxHops
+// retrievals, then synthesizes an answer from the accumulated evidence.
+type MultiHopQueryEngine struct {
+	*BaseQueryEngine
+	// Retriever retrieves nodes for each hop.
+	Retriever retriever.Retriever
+	// LLM decides the next query.
+	LLM llm.LLM
+	// Synthesizer generates the final answer from the evidence.
+	Synthesizer synthesizer.Synthesizer
+	// MaxHops is the maximum number of retrievals.
+	MaxHops int
+	// Prompt is the template used for the reasoning step.
+	Prompt prompts.BasePromptTemplate
+}
+
+// MultiHopQueryEngineOption is a functional option.
+type MultiHopQueryEngineOption func(*MultiHopQueryEngine)
+
+// WithMaxHops sets the maximum number of retrieval hops.
+func WithMaxHops(maxHops int) MultiHopQueryEngineOption {
+	return func(mhe *MultiHopQueryEngine) {
+		mhe.MaxHops = maxHops
+	}
+}
+
+// WithMultiHopPrompt sets the reasoning prompt template.
+func WithMultiHopPrompt(prompt prompts.BasePromptTemplate) MultiHopQueryEngineOption {
+	return func(mhe *MultiHopQueryEngine) {
+		mhe.Prompt = prompt
+	}
+}
+
+// WithMultiHopVerbose enables verbose logging.
+func WithMultiHopVerbose(verbose bool) MultiHopQueryEngineOption {
+	return func(mhe *MultiHopQueryEngine) {
+		mhe.Verbose = verbose
+	}
+}
+
+// NewMultiHopQueryEngine creates a new MultiHopQueryEngine.
+func NewMultiHopQueryEngine(
+	ret retriever.Retriever,
+	llmModel llm.LLM,
+	synth synthesizer.Synthesizer,
+	opts ...MultiHopQueryEngineOption,
+) *MultiHopQueryEngine {
+	mhe := &MultiHopQueryEngine{
+		BaseQueryEngine: NewBaseQueryEngine(),
+		Retriever:       ret,
+		LLM:             llmModel,
+		Synthesizer:     synth,
+		MaxHops:         3,
+		Prompt:          prompts.NewPromptTemplate(defaultMultiHopPrompt, prompts.PromptTypeCustom),
+	}
+
+	for _, opt := range opts {
+		opt(mhe)
+	}
+
+	mhe.SetPrompt("multi_hop_prompt", mhe.Prompt)
+
+	return mhe
+}
+
+// Query executes a multi-hop query.
+func (mhe *MultiHopQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	evidence, steps, err := mhe.GatherEvidence(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]schema.NodeWithScore, len(evidence))
+	for i, e := range evidence {
+		nodes[i] = e.Node
+	}
+
+	response, err := mhe.Synthesizer.Synthesize(ctx, query, nodes)
+	if err != nil {
+		return nil, err
+	}
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata[MultiHopStepsMetadataKey] = steps
+
+	return response, nil
+}
+
+// GatherEvidence runs the retrieve→reason loop and returns the evidence set
+// in retrieval order along with a trace of each hop. Each evidence node is
+// annotated with "hop" and "hop_query" metadata for provenance.
+func (mhe *MultiHopQueryEngine) GatherEvidence(ctx context.Context, query string) ([]Evidence, []MultiHopStep, error) {
+	maxHops := mhe.MaxHops
+	if maxHops <= 0 {
+		maxHops = 1
+	}
+
+	var evidence []Evidence
+	var steps []MultiHopStep
+	seenNodes := make(map[string]bool)
+	seenQueries := make(map[string]bool)
+	currentQuery := query
+
+	for hop := 0; hop < maxHops; hop++ {
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		default:
+		}
+
+		seenQueries[normalizeHopQuery(currentQuery)] = true
+
+		nodes, err := mhe.Retriever.Retrieve(ctx, schema.QueryBundle{QueryString: currentQuery})
+		if err != nil {
+			return nil, nil, fmt.Errorf("retrieval failed at hop %d: %w", hop, err)
+		}
+
+		step := MultiHopStep{Hop: hop, Query: currentQuery}
+		for _, n := range nodes {
+			if seenNodes[n.Node.ID] {
+				continue
+			}
+			seenNodes[n.Node.ID] = true
+			n.Node.Metadata = withProvenance(n.Node.Metadata, hop, currentQuery)
+			n.Node.ExcludedLLMMetadataKeys = append(append([]string{}, n.Node.ExcludedLLMMetadataKeys...), "hop", "hop_query")
+			evidence = append(evidence, Evidence{Node: n, Hop: hop, Query: currentQuery})
+			step.NodeIDs = append(step.NodeIDs, n.Node.ID)
+		}
+
+		// No point reasoning about a next hop we are not allowed to take.
+		if hop == maxHops-1 {
+			steps = append(steps, step)
+			break
+		}
+
+		reasoning, nextQuery, err := mhe.nextQuery(ctx, query, evidence, steps, currentQuery)
+		if err != nil {
+			return nil, nil, err
+		}
+		step.Reasoning = reasoning
+		steps = append(steps, step)
+
+		if nextQuery == "" || seenQueries[normalizeHopQuery(nextQuery)] {
+			break
+		}
+		currentQuery = nextQuery
+	}
+
+	return evidence, steps, nil
+}
+
+// nextQuery asks the LLM whether more evidence is needed. An empty next
+// query means the evidence is sufficient.
+func (mhe *MultiHopQueryEngine) nextQuery(ctx context.Context, query string, evidence []Evidence, steps []MultiHopStep, currentQuery string) (string, string, error) {
+	var evidenceStr strings.Builder
+	for i, e := range evidence {
+		evidenceStr.WriteString(fmt.Sprintf("[%d] (hop %d) %s\n", i+1, e.Hop+1, e.Node.Node.GetContent(schema.MetadataModeLLM)))
+	}
+
+	var queriesStr strings.Builder
+	for _, s := range steps {
+		queriesStr.WriteString("- " + s.Query + "\n")
+	}
+	queriesStr.WriteString("- " + currentQuery + "\n")
+
+	prompt := mhe.Prompt.Format(map[string]string{
+		"evidence_str": evidenceStr.String(),
+		"queries_str":  queriesStr.String(),
+		"query_str":    query,
+	})
+
+	response, err := mhe.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to plan next hop: %w", err)
+	}
+
+	reasoning, next := parseMultiHopResponse(response)
+	return reasoning, next, nil
+}
+
+// parseMultiHopResponse extracts the reasoning and next query from the LLM output.
+func parseMultiHopResponse(response string) (string, string) {
+	var reasoning, next string
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		lower := strings.ToLower(line)
+		switch {
+		case strings.HasPrefix(lower, "reasoning:"):
+			reasoning = strings.TrimSpace(line[len("reasoning:"):])
+		case strings.HasPrefix(lower, "next query:"):
+			next = strings.TrimSpace(line[len("next query:"):])
+		}
+	}
+
+	next = strings.Trim(next, "\"'")
+	if strings.EqualFold(next, "none") {
+		next = ""
+	}
+	return reasoning, next
+}
+
+// withProvenance returns a copy of metadata annotated with hop information.
+func withProvenance(metadata map[string]interface{}, hop int, query string) map[string]interface{} {
+	annotated := make(map[string]interface{}, len(metadata)+2)
+	for k, v := range metadata {
+		annotated[k] = v
+	}
+	annotated["hop"] = hop
+	annotated["hop_query"] = query
+	return annotated
+}
+
+func normalizeHopQuery(query string) string {
+	return strings.ToLower(strings.TrimSpace(query))
+}
+
+// Ensure MultiHopQueryEngine implements QueryEngine.
+var _ QueryEngine = (*MultiHopQueryEngine)(nil)
@@ -0,0 +1,312 @@
+package queryengine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// MultiStepTraceMetadataKey holds the []MultiStepStep trace in Response.Metadata.
+const MultiStepTraceMetadataKey = "multi_step_trace"
+
+// Default prompt for generating the next reasoning step.
+const defaultMultiStepPrompt = `The original question is as follows: {query_str}
+We have an opportunity to answer some, or all of the question from a knowledge source.
+Context information for the knowledge source is provided below, as well as previous reasoning steps.
+Given the context and previous reasoning, return a question that can be answered from the context.
+This question can be the same as the original question, or it can represent a subcomponent of the overall question.
+It should not be irrelevant to the original question.
+If the previous reasoning already answers the original question, or we cannot extract more information from the context, answer None.
+
+Knowledge source context: {context_str}
+
+Previous reasoning:
+{prev_reasoning}
+
+Respond in exactly this format:
+Reasoning: <one or two sentences>
+Next question: <new question, or None>`
+
+// MultiStepStep records one reason→query→observe cycle.
+type MultiStepStep struct {
+	// Step is the zero-based step number.
+	Step int
+	// Reasoning is the LLM's explanation for the step.
+	Reasoning string
+	// Query is the question generated for this step.
+	Query string
+	// TransformedQuery is the question after the step transforms ran. It
+	// equals Query when no transforms are configured.
+	TransformedQuery string
+	// Answer is the underlying engine's answer.
+	Answer string
+	// SourceNodeIDs are the IDs of the nodes the answer was drawn from.
+	SourceNodeIDs []string
+}
+
+// MultiStepQueryEngine answers complex questions by iterative refinement.
+// At each step the LLM reads the previous questions and answers, reasons
+// about what is still missing and writes the next question, which is
+// answered by the underlying query engine. The loop stops when the LLM
+// answers None, a question repeats, the stop function fires or MaxSteps is
+// reached, and the final answer is synthesized from the intermediate
+// answers and their sources.
+type MultiStepQueryEngine struct {
+	*BaseQueryEngine
+	// QueryEngine answers each step's question.
+	QueryEngine QueryEngine
+	// LLM generates the reasoning steps.
+	LLM llm.LLM
+	// Synthesizer generates the final answer.
+	Synthesizer synthesizer.Synthesizer
+	// MaxSteps is the maximum number of steps.
+	MaxSteps int
+	// IndexSummary describes the knowledge source to the LLM.
+	IndexSummary string
+	// StepTransforms are applied, in order, to each step's question before
+	// it is sent to the query engine.
+	StepTransforms []QueryTransform
+	// StopFn, when set, is called after each step; returning true finalizes
+	// the answer early.
+	StopFn func(step MultiStepStep) bool
+	// Prompt is the template used for the reasoning step.
+	Prompt prompts.BasePromptTemplate
+}
+
+// MultiStepQueryEngineOption is a functional option.
+type MultiStepQueryEngineOption func(*MultiStepQueryEngine)
+
+// WithMaxSteps sets the maximum number of steps. Defaults to 3.
+func WithMaxSteps(maxSteps int) MultiStepQueryEngineOption {
+	return func(mse *MultiStepQueryEngine) {
+		mse.MaxSteps = maxSteps
+	}
+}
+
+// WithMultiStepIndexSummary sets the description of the knowledge source.
+func WithMultiStepIndexSummary(summary string) MultiStepQueryEngineOption {
+	return func(mse *MultiStepQueryEngine) {
+		mse.IndexSummary = summary
+	}
+}
+
+// WithStepTransforms sets the transforms applied to each step's question.
+func WithStepTransforms(transforms ...QueryTransform) MultiStepQueryEngineOption {
+	return func(mse *MultiStepQueryEngine) {
+		mse.StepTransforms = transforms
+	}
+}
+
+// WithMultiStepStopFn sets a function that can finalize the answer early.
+func WithMultiStepStopFn(stopFn func(step MultiStepStep) bool) MultiStepQueryEngineOption {
+	return func(mse *MultiStepQueryEngine) {
+		mse.StopFn = stopFn
+	}
+}
+
+// WithMultiStepPrompt sets the reasoning prompt template.
+func WithMultiStepPrompt(prompt prompts.BasePromptTemplate) MultiStepQueryEngineOption {
+	return func(mse *MultiStepQueryEngine) {
+		mse.Prompt = prompt
+	}
+}
+
+// WithMultiStepVerbose enables verbose logging.
+func WithMultiStepVerbose(verbose bool) MultiStepQueryEngineOption {
+	return func(mse *MultiStepQueryEngine) {
+		mse.Verbose = verbose
+	}
+}
+
+// NewMultiStepQueryEngine creates a new MultiStepQueryEngine.
+func NewMultiStepQueryEngine(
+	engine QueryEngine,
+	llmModel llm.LLM,
+	synth synthesizer.Synthesizer,
+	opts ...MultiStepQueryEngineOption,
+) *MultiStepQueryEngine {
+	mse := &MultiStepQueryEngine{
+		BaseQueryEngine: NewBaseQueryEngine(),
+		QueryEngine:     engine,
+		LLM:             llmModel,
+		Synthesizer:     synth,
+		MaxSteps:        3,
+		IndexSummary:    "None",
+		Prompt:          prompts.NewPromptTemplate(defaultMultiStepPrompt, prompts.PromptTypeCustom),
+	}
+
+	for _, opt := range opts {
+		opt(mse)
+	}
+
+	mse.SetPrompt("multi_step_prompt", mse.Prompt)
+
+	return mse
+}
+
+// Query executes a multi-step query.
+func (mse *MultiStepQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	steps, sources, err := mse.RunSteps(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// The intermediate answers are context for the final synthesis, next
+	// to the sources they were drawn from.
+	nodes := make([]schema.NodeWithScore, 0, len(steps)+len(sources))
+	for _, step := range steps {
+		node := schema.NewTextNode(fmt.Sprintf("Question: %s\nAnswer: %s", step.Query, step.Answer))
+		nodes = append(nodes, schema.NodeWithScore{Node: *node, Score: 1.0})
+	}
+	nodes = append(nodes, sources...)
+
+	response, err := mse.Synthesizer.Synthesize(ctx, query, nodes)
+	if err != nil {
+		return nil, err
+	}
+	response.SourceNodes = sources
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata[MultiStepTraceMetadataKey] = steps
+
+	return response, nil
+}
+
+// RunSteps runs the reason→query→observe loop and returns the trace of
+// each step along with the deduplicated source nodes of all answers.
+func (mse *MultiStepQueryEngine) RunSteps(ctx context.Context, query string) ([]MultiStepStep, []schema.NodeWithScore, error) {
+	maxSteps := mse.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = 1
+	}
+
+	var steps []MultiStepStep
+	var sources []schema.NodeWithScore
+	seenNodes := make(map[string]bool)
+	seenQueries := make(map[string]bool)
+
+	for i := 0; i < maxSteps; i++ {
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		default:
+		}
+
+		reasoning, next, err := mse.nextStep(ctx, query, steps)
+		if err != nil {
+			return nil, nil, err
+		}
+		if next == "" && len(steps) == 0 {
+			// Always answer something; fall back to the original question.
+			next = query
+		}
+		if next == "" || seenQueries[normalizeHopQuery(next)] {
+			break
+		}
+		seenQueries[normalizeHopQuery(next)] = true
+
+		bundle := schema.QueryBundle{QueryString: next}
+		for _, transform := range mse.StepTransforms {
+			bundle, err = transform.Transform(ctx, bundle)
+			if err != nil {
+				return nil, nil, fmt.Errorf("step %d transform failed: %w", i, err)
+			}
+		}
+
+		if mse.Verbose {
+			fmt.Printf("Step %d question: %s\n", i, bundle.QueryString)
+		}
+
+		resp, err := mse.QueryEngine.Query(ctx, bundle.QueryString)
+		if err != nil {
+			return nil, nil, fmt.Errorf("query failed at step %d: %w", i, err)
+		}
+
+		step := MultiStepStep{
+			Step:             i,
+			Reasoning:        reasoning,
+			Query:            next,
+			TransformedQuery: bundle.QueryString,
+			Answer:           resp.Response,
+		}
+		for _, n := range resp.SourceNodes {
+			step.SourceNodeIDs = append(step.SourceNodeIDs, n.Node.ID)
+			if seenNodes[n.Node.ID] {
+				continue
+			}
+			seenNodes[n.Node.ID] = true
+			sources = append(sources, n)
+		}
+		steps = append(steps, step)
+
+		if mse.StopFn != nil && mse.StopFn(step) {
+			break
+		}
+	}
+
+	return steps, sources, nil
+}
+
+// nextStep asks the LLM for the next question. An empty question means the
+// answer should be finalized.
+func (mse *MultiStepQueryEngine) nextStep(ctx context.Context, query string, steps []MultiStepStep) (string, string, error) {
+	prevReasoning := "None"
+	if len(steps) > 0 {
+		var sb strings.Builder
+		for _, s := range steps {
+			sb.WriteString(fmt.Sprintf("- Question: %s\n  Answer: %s\n", s.Query, s.Answer))
+		}
+		prevReasoning = sb.String()
+	}
+
+	prompt := mse.Prompt.Format(map[string]string{
+		"query_str":      query,
+		"context_str":    mse.IndexSummary,
+		"prev_reasoning": prevReasoning,
+	})
+
+	response, err := mse.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate step %d: %w", len(steps), err)
+	}
+
+	reasoning, next := parseMultiStepResponse(response)
+	return reasoning, next, nil
+}
+
+// parseMultiStepResponse extracts the reasoning and next question from the
+// LLM output. A response without a "Next question:" line is taken as the
+// question itself.
+func parseMultiStepResponse(response string) (string, string) {
+	var reasoning, next string
+	found := false
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		lower := strings.ToLower(line)
+		switch {
+		case strings.HasPrefix(lower, "reasoning:"):
+			reasoning = strings.TrimSpace(line[len("reasoning:"):])
+		case strings.HasPrefix(lower, "next question:"):
+			next = strings.TrimSpace(line[len("next question:"):])
+			found = true
+		}
+	}
+	if !found && reasoning == "" {
+		next = strings.TrimSpace(response)
+	}
+
+	next = strings.Trim(next, "\"'")
+	if strings.EqualFold(strings.TrimRight(next, "."), "none") {
+		next = ""
+	}
+	return reasoning, next
+}
+
+// Ensure MultiStepQueryEngine implements QueryEngine.
+var _ QueryEngine = (*MultiStepQueryEngine)(nil)
@@ -0,0 +1,62 @@
+package queryengine
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aqua777/go-llamaindex/rag/retriever"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// PagedResponse is one page of a paginated query.
+type PagedResponse struct {
+	*synthesizer.Response
+	// NextCursor fetches the next page of sources. It is empty on the last
+	// page.
+	NextCursor string
+	// Offset is the position of the first source on this page.
+	Offset int
+	// Total is the number of sources across all pages.
+	Total int
+}
+
+// HasMore reports whether there is a next page.
+func (r *PagedResponse) HasMore() bool {
+	return r.NextCursor != ""
+}
+
+// QueryWithCursor answers a query page by page. Without a cursor it
+// retrieves and postprocesses the nodes once, keeps a snapshot of them in
+// the engine's Pager and synthesizes the answer from the first pageSize
+// nodes. With the returned cursor it returns the next batch of sources from
+// the snapshot, without retrieving or synthesizing again; those pages have
+// an empty response text.
+func (rqe *RetrieverQueryEngine) QueryWithCursor(ctx context.Context, query, cursor string, pageSize int) (*PagedResponse, error) {
+	if rqe.Pager == nil {
+		return nil, errors.New("query engine has no result pager")
+	}
+
+	queryBundle := schema.QueryBundle{QueryString: query}
+	page, err := rqe.Pager.Paginate(ctx, retriever.QueryKey(queryBundle), cursor, pageSize, func(ctx context.Context) ([]schema.NodeWithScore, error) {
+		return rqe.Retrieve(ctx, queryBundle)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := synthesizer.NewResponse("", page.Nodes)
+	if cursor == "" {
+		resp, err = rqe.Synthesize(ctx, query, page.Nodes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &PagedResponse{
+		Response:   resp,
+		NextCursor: page.NextCursor,
+		Offset:     page.Offset,
+		Total:      page.Total,
+	}, nil
+}
@@ -2,13 +2,33 @@ package queryengine
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/aqua777/go-llamaindex/callbacks"
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/evaluation"
+	"github.com/aqua777/go-llamaindex/graphstore"
+	"github.com/aqua777/go-llamaindex/ingestion"
+	"github.com/aqua777/go-llamaindex/limits"
 	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/postprocessor"
+	"github.com/aqua777/go-llamaindex/querytransform"
+	"github.com/aqua777/go-llamaindex/rag/reader"
+	"github.com/aqua777/go-llamaindex/rag/retriever"
 	"github.com/aqua777/go-llamaindex/rag/synthesizer"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/storage/docstore"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -93,6 +113,87 @@ func TestRetrieverQueryEngineRetrieve(t *testing.T) {
 	assert.Len(t, retrieved, 2)
 }
 
+func TestRetrieverQueryEngineNodePostprocessors(t *testing.T) {
+	rerank := postprocessor.NewLLMRerank(
+		postprocessor.WithLLMRerankLLM(llm.NewMockLLM("Doc: 2, Relevance: 9\nDoc: 1, Relevance: 3")),
+		postprocessor.WithLLMRerankTopN(1),
+	)
+	rqe := NewRetrieverQueryEngine(
+		&MockRetriever{Nodes: createTestNodes()},
+		synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("ok")),
+		WithNodePostprocessors(rerank),
+	)
+
+	nodes, err := rqe.Retrieve(context.Background(), schema.QueryBundle{QueryString: "test"})
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "node2", nodes[0].Node.ID)
+	assert.Equal(t, 9.0, nodes[0].Score)
+
+	// Postprocessor errors are surfaced with the postprocessor name.
+	failing := postprocessor.NewLLMRerank(postprocessor.WithLLMRerankLLM(llm.NewMockLLMWithError(errors.New("rate limited"))))
+	rqe = NewRetrieverQueryEngine(&MockRetriever{Nodes: createTestNodes()}, synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("ok")), WithNodePostprocessors(failing))
+	_, err = rqe.Retrieve(context.Background(), schema.QueryBundle{QueryString: "test"})
+	assert.ErrorContains(t, err, "LLMRerank")
+}
+
+func TestRetrieverQueryEngineCallbacks(t *testing.T) {
+	debug := callbacks.NewDebugHandler(callbacks.WithPrintTraceOnEnd(false))
+	manager := callbacks.NewCallbackManager(callbacks.WithHandlers([]callbacks.CallbackHandler{debug}))
+	ctx := callbacks.ContextWithManager(context.Background(), manager)
+
+	rqe := NewRetrieverQueryEngine(
+		&MockRetriever{Nodes: createTestNodes()},
+		synthesizer.NewSimpleSynthesizer(callbacks.NewInstrumentedLLM(llm.NewMockLLM("answer"))),
+	)
+	_, err := rqe.Query(ctx, "test query")
+	require.NoError(t, err)
+
+	query := debug.EventPairs(callbacks.CBEventTypeQuery)
+	require.Len(t, query, 1)
+	assert.Equal(t, "test query", query[0].StartPayload[string(callbacks.EventPayloadQueryStr)])
+	assert.Equal(t, "answer", query[0].EndPayload[string(callbacks.EventPayloadResponse)])
+
+	retrieve := debug.EventPairs(callbacks.CBEventTypeRetrieve)
+	require.Len(t, retrieve, 1)
+	assert.Equal(t, query[0].EventID, retrieve[0].ParentID)
+	assert.Len(t, retrieve[0].EndPayload[string(callbacks.EventPayloadNodes)], 2)
+
+	synthesize := debug.EventPairs(callbacks.CBEventTypeSynthesize)
+	require.Len(t, synthesize, 1)
+	assert.Equal(t, query[0].EventID, synthesize[0].ParentID)
+
+	llmCalls := debug.LLMInputsOutputs()
+	require.Len(t, llmCalls, 1)
+	assert.Equal(t, synthesize[0].EventID, llmCalls[0].ParentID)
+}
+
+func TestRetrieverQueryEngineQueryWithCursor(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockRetriever{Nodes: createTestNodes()}
+	rqe := NewRetrieverQueryEngine(mock, synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("answer")))
+
+	first, err := rqe.QueryWithCursor(ctx, "test", "", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "answer", first.Response.Response)
+	require.Len(t, first.SourceNodes, 1)
+	assert.Equal(t, "node1", first.SourceNodes[0].Node.ID)
+	assert.Equal(t, 2, first.Total)
+	require.True(t, first.HasMore())
+
+	// Later pages come from the snapshot, without retrieving again.
+	mock.Err = errors.New("retriever should not be called")
+	second, err := rqe.QueryWithCursor(ctx, "test", first.NextCursor, 1)
+	require.NoError(t, err)
+	assert.Empty(t, second.Response.Response)
+	require.Len(t, second.SourceNodes, 1)
+	assert.Equal(t, "node2", second.SourceNodes[0].Node.ID)
+	assert.False(t, second.HasMore())
+
+	_, err = rqe.QueryWithCursor(ctx, "other", first.NextCursor, 1)
+	assert.ErrorIs(t, err, retriever.ErrInvalidCursor)
+}
+
 func TestRetrieverQueryEngineSynthesize(t *testing.T) {
 	ctx := context.Background()
 
@@ -168,6 +269,203 @@ func TestRouterQueryEngineNoEngines(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestRouterQueryEngineSelectors(t *testing.T) {
+	ctx := context.Background()
+
+	newTools := func() ([]*QueryEngineTool, []*MockQueryEngine) {
+		engines := []*MockQueryEngine{
+			{Response: &synthesizer.Response{Response: "Solar answer"}},
+			{Response: &synthesizer.Response{Response: "Wind answer"}},
+		}
+		return []*QueryEngineTool{
+			NewQueryEngineTool(engines[0], "solar", "Questions about solar panels"),
+			NewQueryEngineTool(engines[1], "wind", "Questions about wind turbines"),
+		}, engines
+	}
+
+	t.Run("LLM single selector records the engine", func(t *testing.T) {
+		tools, engines := newTools()
+		rqe := NewRouterQueryEngine(tools, WithRouterSelector(
+			NewLLMSingleSelector(llm.NewMockLLM(`[{"choice": 2, "reason": "about turbines"}]`)),
+		))
+
+		resp, err := rqe.Query(ctx, "How do turbines work?")
+		require.NoError(t, err)
+		assert.Equal(t, "Wind answer", resp.Response)
+		assert.Equal(t, []string{"wind"}, resp.Metadata[SelectedEnginesMetadataKey])
+		assert.Equal(t, []string{"about turbines"}, resp.Metadata[SelectorReasonsMetadataKey])
+		assert.Equal(t, 0, engines[0].CallCount)
+		assert.Nil(t, engines[1].Response.Metadata, "the engine's own response is not modified")
+	})
+
+	t.Run("LLM multi selector summarizes", func(t *testing.T) {
+		tools, _ := newTools()
+		summarizer := synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("Both answers combined"))
+		rqe := NewRouterQueryEngine(tools,
+			WithRouterSelector(NewLLMMultiSelector(llm.NewMockLLM(`[{"choice": 1, "reason": "a"}, {"choice": 2, "reason": "b"}]`))),
+			WithRouterSummarizer(summarizer),
+		)
+
+		resp, err := rqe.Query(ctx, "Compare solar and wind")
+		require.NoError(t, err)
+		assert.Equal(t, "Both answers combined", resp.Response)
+		assert.Equal(t, []string{"solar", "wind"}, resp.Metadata[SelectedEnginesMetadataKey])
+		assert.Equal(t, []string{"a", "b"}, resp.Metadata[SelectorReasonsMetadataKey])
+	})
+
+	t.Run("LLM selector with no valid choice", func(t *testing.T) {
+		tools, _ := newTools()
+		sel := NewLLMSingleSelector(llm.NewMockLLM(`[{"choice": 5, "reason": "?"}]`))
+		_, err := NewRouterQueryEngine(tools, WithRouterSelector(sel)).Query(ctx, "q")
+		assert.Error(t, err)
+	})
+
+	t.Run("Embedding selector", func(t *testing.T) {
+		tools, _ := newTools()
+		model := &keywordEmbedding{words: []string{"solar", "wind", "turbine"}}
+		sel := NewEmbeddingSelector(model)
+		rqe := NewRouterQueryEngine(tools, WithRouterSelector(sel))
+
+		resp, err := rqe.Query(ctx, "wind turbine noise")
+		require.NoError(t, err)
+		assert.Equal(t, "Wind answer", resp.Response)
+		assert.Equal(t, []string{"wind"}, resp.Metadata[SelectedEnginesMetadataKey])
+
+		_, err = rqe.Query(ctx, "solar")
+		require.NoError(t, err)
+		assert.Equal(t, 2, model.textCalls, "description embeddings are cached")
+
+		sel = NewEmbeddingSelector(model, WithEmbeddingSelectorTopK(2))
+		result, err := sel.Select(ctx, tools, schema.QueryBundle{QueryString: "solar and wind"})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int{0, 1}, result.Indices)
+	})
+}
+
+// sourceEvaluator passes a response when every context contains the
+// relevant phrase, and records the queries it was asked to judge.
+type sourceEvaluator struct {
+	relevant string
+	queries  []string
+}
+
+func (e *sourceEvaluator) Name() string { return "source" }
+
+func (e *sourceEvaluator) Evaluate(ctx context.Context, input *evaluation.EvaluateInput) (*evaluation.EvaluationResult, error) {
+	e.queries = append(e.queries, input.Query)
+	for _, c := range input.Contexts {
+		if !strings.Contains(c, e.relevant) {
+			return evaluation.NewEvaluationResult().WithPassing(false).WithFeedback("off-topic source: " + c), nil
+		}
+	}
+	return evaluation.NewEvaluationResult().WithPassing(true), nil
+}
+
+func TestRetrySourceQueryEngine(t *testing.T) {
+	ctx := context.Background()
+
+	relevant := schema.NewTextNode("Mars has two moons, Phobos and Deimos.")
+	offTopic := schema.NewTextNode("Jupiter has many moons.")
+	engine := &MockQueryEngine{Response: synthesizer.NewResponse("Mars has dozens of moons.", []schema.NodeWithScore{
+		{Node: *relevant, Score: 0.9},
+		{Node: *offTopic, Score: 0.8},
+	})}
+	synthLLM := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{"Mars has two moons."}}
+	evaluator := &sourceEvaluator{relevant: "Mars"}
+
+	rse := NewRetrySourceQueryEngine(engine, evaluator, synthesizer.NewSimpleSynthesizer(synthLLM))
+	resp, err := rse.Query(ctx, "How many moons does Mars have?")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Mars has two moons.", resp.Response)
+	require.Len(t, resp.SourceNodes, 1)
+	assert.Equal(t, relevant.ID, resp.SourceNodes[0].Node.ID)
+	assert.Equal(t, 1, resp.Metadata[RetryAttemptsMetadataKey])
+	result := resp.Metadata[RetryEvaluationMetadataKey].(*evaluation.EvaluationResult)
+	assert.True(t, result.IsPassing())
+	assert.NotContains(t, synthLLM.prompts[0], "Jupiter")
+
+	t.Run("gives up when no source passes", func(t *testing.T) {
+		evaluator := &sourceEvaluator{relevant: "Saturn"}
+		rse := NewRetrySourceQueryEngine(engine, evaluator, synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("unused")))
+		resp, err := rse.Query(ctx, "q")
+		require.NoError(t, err)
+		assert.Equal(t, "Mars has dozens of moons.", resp.Response)
+		assert.Equal(t, 0, resp.Metadata[RetryAttemptsMetadataKey])
+		assert.False(t, resp.Metadata[RetryEvaluationMetadataKey].(*evaluation.EvaluationResult).IsPassing())
+	})
+
+	t.Run("max retries", func(t *testing.T) {
+		rse := NewRetrySourceQueryEngine(engine, evaluator, synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("unused")),
+			WithRetrySourceMaxRetries(0))
+		resp, err := rse.Query(ctx, "q")
+		require.NoError(t, err)
+		assert.Equal(t, "Mars has dozens of moons.", resp.Response)
+	})
+}
+
+func TestRetryGuidelineQueryEngine(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("rewrites the query with an LLM", func(t *testing.T) {
+		engine := &queryRecorder{answer: "A few."}
+		judge := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+			`{"passing": false, "feedback": "Give the exact number."}`,
+			`{"passing": true, "feedback": "Good."}`,
+		}}
+		evaluator := evaluation.NewGuidelineEvaluator(evaluation.WithGuidelineLLM(judge), evaluation.WithGuidelines("Use numbers."))
+		rewriter := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{"Exactly how many moons does Mars have?"}}
+
+		rge := NewRetryGuidelineQueryEngine(engine, evaluator, WithQueryRewriteLLM(rewriter))
+		resp, err := rge.Query(ctx, "How many moons does Mars have?")
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"How many moons does Mars have?", "Exactly how many moons does Mars have?"}, engine.queries)
+		assert.Equal(t, engine.queries, resp.Metadata[RetryQueriesMetadataKey])
+		assert.Equal(t, 1, resp.Metadata[RetryAttemptsMetadataKey])
+		assert.Contains(t, rewriter.prompts[0], "Give the exact number.")
+		assert.Contains(t, rewriter.prompts[0], "Use numbers.")
+		assert.Contains(t, judge.prompts[1], "Query: How many moons does Mars have?", "responses are judged against the original query")
+	})
+
+	t.Run("appends feedback without an LLM", func(t *testing.T) {
+		engine := &queryRecorder{answer: "A few."}
+		judge := llm.NewMockLLM(`{"passing": false, "feedback": "Be specific."}`)
+		evaluator := evaluation.NewGuidelineEvaluator(evaluation.WithGuidelineLLM(judge))
+
+		rge := NewRetryGuidelineQueryEngine(engine, evaluator, WithRetryGuidelineMaxRetries(2))
+		resp, err := rge.Query(ctx, "q")
+		require.NoError(t, err)
+
+		require.Len(t, engine.queries, 3)
+		assert.Contains(t, engine.queries[1], "Here is a previous bad answer.\nA few.")
+		assert.Contains(t, engine.queries[1], "Be specific.")
+		assert.Equal(t, 2, resp.Metadata[RetryAttemptsMetadataKey])
+	})
+
+	t.Run("uses EvaluateResponse overrides", func(t *testing.T) {
+		engine := &queryRecorder{answer: "A few."}
+		evaluator := &sourceResponseEvaluator{sourceEvaluator: &sourceEvaluator{relevant: "never"}}
+
+		rge := NewRetryGuidelineQueryEngine(engine, evaluator)
+		resp, err := rge.Query(ctx, "q")
+		require.NoError(t, err)
+
+		assert.Len(t, engine.queries, 1)
+		assert.Equal(t, 0, resp.Metadata[RetryAttemptsMetadataKey])
+		assert.Empty(t, evaluator.queries, "Evaluate is bypassed")
+	})
+}
+
+// sourceResponseEvaluator passes every response through EvaluateResponse.
+type sourceResponseEvaluator struct {
+	*sourceEvaluator
+}
+
+func (e *sourceResponseEvaluator) EvaluateResponse(ctx context.Context, query, response string, sourceNodes []schema.NodeWithScore) (*evaluation.EvaluationResult, error) {
+	return evaluation.NewEvaluationResult().WithPassing(true), nil
+}
+
 func TestTransformQueryEngine(t *testing.T) {
 	ctx := context.Background()
 
@@ -182,6 +480,22 @@ func TestTransformQueryEngine(t *testing.T) {
 	assert.Equal(t, "Transformed response", resp.Response)
 }
 
+func TestRetrieverQueryEngineQueryTransforms(t *testing.T) {
+	ctx := context.Background()
+	ret := &queryMapRetriever{nodes: map[string][]schema.NodeWithScore{
+		"rewritten question": createTestNodes(),
+	}}
+	synthLLM := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{"answer"}}
+
+	rqe := NewRetrieverQueryEngine(ret, synthesizer.NewSimpleSynthesizer(synthLLM),
+		WithQueryTransforms(querytransform.NewRewriteTransform(llm.NewMockLLM("rewritten question"))))
+	resp, err := rqe.Query(ctx, "original question")
+	require.NoError(t, err)
+	assert.Equal(t, "answer", resp.Response)
+	assert.Equal(t, []string{"rewritten question"}, ret.queries)
+	assert.Contains(t, synthLLM.prompts[0], "original question", "synthesis answers the original query")
+}
+
 func TestIdentityTransform(t *testing.T) {
 	ctx := context.Background()
 	transform := &IdentityTransform{}
@@ -363,3 +677,1664 @@ func TestSelectorEmpty(t *testing.T) {
 	_, err = multi.Select(ctx, []*QueryEngineTool{}, schema.QueryBundle{})
 	assert.Error(t, err)
 }
+
+func TestAbstentionPolicyCheckRetrieval(t *testing.T) {
+	nodes := createTestNodes()
+
+	policy := NewAbstentionPolicy(WithMinRetrievalScore(0.85), WithMinSourceCount(2))
+	reasons := policy.CheckRetrieval(nodes)
+	require.Len(t, reasons, 1)
+	assert.Equal(t, AbstentionCodeInsufficientSources, reasons[0].Code)
+
+	policy = NewAbstentionPolicy(WithMinRetrievalScore(0.95))
+	reasons = policy.CheckRetrieval(nodes)
+	require.Len(t, reasons, 1)
+	assert.Equal(t, AbstentionCodeLowScore, reasons[0].Code)
+
+	policy = NewAbstentionPolicy(WithMinRetrievalScore(0.5), WithMinSourceCount(2))
+	assert.Empty(t, policy.CheckRetrieval(nodes))
+}
+
+func TestAbstainingQueryEngine(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("abstains before synthesis", func(t *testing.T) {
+		mockLLM := llm.NewMockLLM("Hallucinated answer")
+		rqe := NewRetrieverQueryEngine(&MockRetriever{Nodes: createTestNodes()}, synthesizer.NewSimpleSynthesizer(mockLLM))
+
+		engine := NewAbstainingQueryEngine(rqe, NewAbstentionPolicy(
+			WithMinRetrievalScore(0.95),
+			WithAbstentionMessage("Not sure."),
+		))
+
+		resp, err := engine.Query(ctx, "test query")
+		require.NoError(t, err)
+		assert.Equal(t, "Not sure.", resp.Response)
+		assert.Len(t, resp.SourceNodes, 2)
+
+		abstained, reasons := IsAbstained(resp)
+		assert.True(t, abstained)
+		require.Len(t, reasons, 1)
+		assert.Equal(t, AbstentionCodeLowScore, reasons[0].Code)
+	})
+
+	t.Run("faithfulness check", func(t *testing.T) {
+		mockLLM := llm.NewMockLLM("Answer")
+		rqe := NewRetrieverQueryEngine(&MockRetriever{Nodes: createTestNodes()}, synthesizer.NewSimpleSynthesizer(mockLLM))
+
+		checker := evaluation.NewFaithfulnessEvaluator(evaluation.WithFaithfulnessLLM(llm.NewMockLLM("NO")))
+		engine := NewAbstainingQueryEngine(rqe, NewAbstentionPolicy(WithFaithfulnessCheck(checker)))
+
+		resp, err := engine.Query(ctx, "test query")
+		require.NoError(t, err)
+		assert.Equal(t, DefaultAbstentionMessage, resp.Response)
+		abstained, reasons := IsAbstained(resp)
+		assert.True(t, abstained)
+		assert.Equal(t, AbstentionCodeUnfaithful, reasons[0].Code)
+
+		checker = evaluation.NewFaithfulnessEvaluator(evaluation.WithFaithfulnessLLM(llm.NewMockLLM("YES")))
+		engine.Policy.FaithfulnessEvaluator = checker
+		resp, err = engine.Query(ctx, "test query")
+		require.NoError(t, err)
+		assert.Equal(t, "Answer", resp.Response)
+		abstained, _ = IsAbstained(resp)
+		assert.False(t, abstained)
+	})
+
+	t.Run("plain query engine", func(t *testing.T) {
+		mock := &MockQueryEngine{Response: synthesizer.NewResponse("Answer", nil)}
+		engine := NewAbstainingQueryEngine(mock, NewAbstentionPolicy(WithMinSourceCount(1)))
+
+		resp, err := engine.Query(ctx, "test query")
+		require.NoError(t, err)
+		abstained, reasons := IsAbstained(resp)
+		assert.True(t, abstained)
+		assert.Equal(t, AbstentionCodeInsufficientSources, reasons[0].Code)
+	})
+}
+
+// queryMapRetriever returns nodes keyed by query string.
+type queryMapRetriever struct {
+	nodes   map[string][]schema.NodeWithScore
+	queries []string
+}
+
+func (r *queryMapRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	r.queries = append(r.queries, query.QueryString)
+	return r.nodes[query.QueryString], nil
+}
+
+// sequenceLLM returns responses in order and records prompts.
+type sequenceLLM struct {
+	*llm.MockLLM
+	responses []string
+	prompts   []string
+}
+
+func (s *sequenceLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	s.prompts = append(s.prompts, prompt)
+	if len(s.responses) == 0 {
+		return "", nil
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return resp, nil
+}
+
+func TestMultiHopQueryEngine(t *testing.T) {
+	ctx := context.Background()
+
+	founder := schema.NewTextNode("Acme Corp was founded by Jane Doe.")
+	founder.ID = "founder"
+	birthplace := schema.NewTextNode("Jane Doe was born in Lisbon.")
+	birthplace.ID = "birthplace"
+
+	ret := &queryMapRetriever{nodes: map[string][]schema.NodeWithScore{
+		"Where was the founder of Acme born?": {{Node: *founder, Score: 0.9}},
+		"Where was Jane Doe born?":            {{Node: *birthplace, Score: 0.8}, {Node: *founder, Score: 0.5}},
+	}}
+
+	planner := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+		"Reasoning: The founder is Jane Doe, birthplace unknown.\nNext query: Where was Jane Doe born?",
+		"Reasoning: Both facts found.\nNext query: NONE",
+	}}
+	synth := synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("Lisbon"))
+
+	engine := NewMultiHopQueryEngine(ret, planner, synth, WithMaxHops(4))
+	resp, err := engine.Query(ctx, "Where was the founder of Acme born?")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Lisbon", resp.Response)
+	assert.Equal(t, []string{"Where was the founder of Acme born?", "Where was Jane Doe born?"}, ret.queries)
+	require.Len(t, resp.SourceNodes, 2)
+	assert.Equal(t, "founder", resp.SourceNodes[0].Node.ID)
+	assert.Equal(t, 0, resp.SourceNodes[0].Node.Metadata["hop"])
+	assert.Equal(t, 1, resp.SourceNodes[1].Node.Metadata["hop"])
+	assert.Equal(t, "Where was Jane Doe born?", resp.SourceNodes[1].Node.Metadata["hop_query"])
+
+	steps, ok := resp.Metadata[MultiHopStepsMetadataKey].([]MultiHopStep)
+	require.True(t, ok)
+	require.Len(t, steps, 2)
+	assert.Equal(t, []string{"birthplace"}, steps[1].NodeIDs)
+	assert.Equal(t, "Both facts found.", steps[1].Reasoning)
+	assert.Contains(t, planner.prompts[1], "Jane Doe was born in Lisbon.")
+}
+
+func TestMultiHopQueryEngineBounded(t *testing.T) {
+	ctx := context.Background()
+
+	ret := &queryMapRetriever{nodes: map[string][]schema.NodeWithScore{}}
+	planner := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+		"Next query: a", "Next query: b", "Next query: c",
+	}}
+
+	engine := NewMultiHopQueryEngine(ret, planner, synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("x")), WithMaxHops(2))
+	_, steps, err := engine.GatherEvidence(ctx, "start")
+	require.NoError(t, err)
+	assert.Len(t, steps, 2)
+	assert.Equal(t, []string{"start", "a"}, ret.queries)
+	assert.Len(t, planner.prompts, 1)
+}
+
+func TestParseMultiHopResponse(t *testing.T) {
+	reasoning, next := parseMultiHopResponse("Reasoning: need more\nNext query: \"who is X\"")
+	assert.Equal(t, "need more", reasoning)
+	assert.Equal(t, "who is X", next)
+
+	_, next = parseMultiHopResponse("next query: none")
+	assert.Empty(t, next)
+}
+
+func TestMultiStepQueryEngine(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("iterates until finalized", func(t *testing.T) {
+		engine := &queryRecorder{answer: "intermediate"}
+		planner := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+			"Reasoning: Need the founder first.\nNext question: Who founded Acme?",
+			"Reasoning: Now the birthplace.\nNext question: Where was Jane Doe born?",
+			"Reasoning: Enough information.\nNext question: None",
+		}}
+		synthLLM := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{"Lisbon"}}
+
+		mse := NewMultiStepQueryEngine(engine, planner, synthesizer.NewSimpleSynthesizer(synthLLM),
+			WithMaxSteps(5), WithMultiStepIndexSummary("Company histories"))
+		resp, err := mse.Query(ctx, "Where was the founder of Acme born?")
+		require.NoError(t, err)
+
+		assert.Equal(t, "Lisbon", resp.Response)
+		assert.Equal(t, []string{"Who founded Acme?", "Where was Jane Doe born?"}, engine.queries)
+		assert.Len(t, planner.prompts, 3)
+		assert.Contains(t, planner.prompts[0], "Company histories")
+		assert.Contains(t, planner.prompts[2], "Question: Where was Jane Doe born?\n  Answer: intermediate")
+		assert.Contains(t, synthLLM.prompts[0], "Question: Who founded Acme?\nAnswer: intermediate")
+		assert.Len(t, resp.SourceNodes, 2)
+
+		steps, ok := resp.Metadata[MultiStepTraceMetadataKey].([]MultiStepStep)
+		require.True(t, ok)
+		require.Len(t, steps, 2)
+		assert.Equal(t, "Need the founder first.", steps[0].Reasoning)
+		assert.Equal(t, "intermediate", steps[1].Answer)
+		assert.Len(t, steps[1].SourceNodeIDs, 1)
+	})
+
+	t.Run("max steps and repeated questions", func(t *testing.T) {
+		engine := &queryRecorder{answer: "x"}
+		planner := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+			"Next question: a", "Next question: A", "Next question: b",
+		}}
+		mse := NewMultiStepQueryEngine(engine, planner, synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("y")))
+		steps, _, err := mse.RunSteps(ctx, "q")
+		require.NoError(t, err)
+		assert.Len(t, steps, 1)
+		assert.Equal(t, []string{"a"}, engine.queries)
+
+		planner = &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{"Next question: a", "Next question: b", "Next question: c"}}
+		engine = &queryRecorder{answer: "x"}
+		mse = NewMultiStepQueryEngine(engine, planner, synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("y")), WithMaxSteps(2))
+		steps, _, err = mse.RunSteps(ctx, "q")
+		require.NoError(t, err)
+		assert.Len(t, steps, 2)
+	})
+
+	t.Run("stop function and transforms", func(t *testing.T) {
+		engine := &queryRecorder{answer: "done"}
+		planner := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{"Next question: a", "Next question: b"}}
+		hyde := NewHyDETransform(llm.NewMockLLM("hypothetical passage"))
+		mse := NewMultiStepQueryEngine(engine, planner, synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("y")),
+			WithStepTransforms(hyde),
+			WithMultiStepStopFn(func(step MultiStepStep) bool { return step.Answer == "done" }),
+		)
+		steps, _, err := mse.RunSteps(ctx, "q")
+		require.NoError(t, err)
+		require.Len(t, steps, 1)
+		assert.Equal(t, "a", steps[0].Query)
+		assert.Equal(t, "hypothetical passage", steps[0].TransformedQuery)
+		assert.Equal(t, []string{"hypothetical passage"}, engine.queries)
+	})
+
+	t.Run("falls back to the original question", func(t *testing.T) {
+		engine := &queryRecorder{answer: "x"}
+		planner := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{"Next question: None"}}
+		mse := NewMultiStepQueryEngine(engine, planner, synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("y")))
+		steps, _, err := mse.RunSteps(ctx, "original")
+		require.NoError(t, err)
+		require.Len(t, steps, 1)
+		assert.Equal(t, []string{"original"}, engine.queries)
+	})
+}
+
+func TestParseMultiStepResponse(t *testing.T) {
+	reasoning, next := parseMultiStepResponse("Reasoning: need more\nNext question: \"who is X\"")
+	assert.Equal(t, "need more", reasoning)
+	assert.Equal(t, "who is X", next)
+
+	_, next = parseMultiStepResponse("None.")
+	assert.Empty(t, next)
+
+	_, next = parseMultiStepResponse("What is X?")
+	assert.Equal(t, "What is X?", next)
+}
+
+// logProbLLM returns scripted completions with token log probabilities.
+type logProbLLM struct {
+	*llm.MockLLM
+	responses [][]TokenLogProb
+	prompts   []string
+}
+
+func (l *logProbLLM) CompleteWithLogProbs(ctx context.Context, prompt string) (string, []TokenLogProb, error) {
+	l.prompts = append(l.prompts, prompt)
+	if len(l.responses) == 0 {
+		return "", nil, nil
+	}
+	tokens := l.responses[0]
+	l.responses = l.responses[1:]
+	var text strings.Builder
+	for _, t := range tokens {
+		text.WriteString(t.Token)
+	}
+	return text.String(), tokens, nil
+}
+
+func confidentTokens(text string) []TokenLogProb {
+	var tokens []TokenLogProb
+	for i, w := range strings.Fields(text) {
+		if i > 0 {
+			w = " " + w
+		}
+		tokens = append(tokens, TokenLogProb{Token: w, LogProb: -0.01})
+	}
+	return tokens
+}
+
+func TestFLAREQueryEngine(t *testing.T) {
+	ctx := context.Background()
+
+	founder := schema.NewTextNode("Acme Corp was founded by Jane Doe.")
+	founder.ID = "founder"
+	birthplace := schema.NewTextNode("Jane Doe was born in Lisbon.")
+	birthplace.ID = "birthplace"
+	question := "Who founded Acme and where were they born?"
+
+	t.Run("search markers trigger retrieval", func(t *testing.T) {
+		ret := &queryMapRetriever{nodes: map[string][]schema.NodeWithScore{
+			question:              {{Node: *founder, Score: 0.9}},
+			"Jane Doe birthplace": {{Node: *birthplace, Score: 0.8}},
+		}}
+		gen := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+			"Acme was founded by Jane Doe. She later moved abroad.",
+			"She was born in [Search(Jane Doe birthplace)].",
+			"She was born in Lisbon.",
+			"DONE",
+		}}
+
+		resp, err := NewFLAREQueryEngine(ret, gen).Query(ctx, question)
+		require.NoError(t, err)
+
+		assert.Equal(t, "Acme was founded by Jane Doe. She was born in Lisbon.", resp.Response)
+		assert.Equal(t, []string{question, "Jane Doe birthplace"}, ret.queries)
+		require.Len(t, resp.SourceNodes, 2)
+		assert.Contains(t, gen.prompts[2], "Jane Doe was born in Lisbon.")
+		assert.Contains(t, gen.prompts[2], "Answer so far: Acme was founded by Jane Doe.")
+
+		steps, ok := resp.Metadata[FLARETraceMetadataKey].([]FLAREStep)
+		require.True(t, ok)
+		require.Len(t, steps, 2)
+		assert.False(t, steps[0].LowConfidence)
+		assert.True(t, steps[1].LowConfidence)
+		assert.Equal(t, []string{"birthplace"}, steps[1].NodeIDs)
+		assert.Equal(t, "She was born in [Search(Jane Doe birthplace)].", steps[1].Lookahead)
+	})
+
+	t.Run("low-probability tokens trigger retrieval", func(t *testing.T) {
+		ret := &queryMapRetriever{nodes: map[string][]schema.NodeWithScore{}}
+		uncertain := confidentTokens("Jane Doe was born in Paris.")
+		uncertain[5].LogProb = math.Log(0.1)
+		gen := &logProbLLM{MockLLM: llm.NewMockLLM(""), responses: [][]TokenLogProb{
+			uncertain,
+			confidentTokens("Jane Doe was born in Lisbon."),
+			confidentTokens("DONE"),
+		}}
+
+		engine := NewFLAREQueryEngine(ret, gen, WithFLAREConfidenceThreshold(0.5))
+		resp, err := engine.Query(ctx, "Where was Jane Doe born?")
+		require.NoError(t, err)
+		assert.Equal(t, "Jane Doe was born in Lisbon.", resp.Response)
+		assert.Equal(t, []string{"Where was Jane Doe born?", "Jane Doe was born in"}, ret.queries)
+
+		gen.responses = [][]TokenLogProb{uncertain, confidentTokens("DONE")}
+		resp, err = NewFLAREQueryEngine(ret, gen, WithFLAREConfidenceThreshold(0.05)).Query(ctx, "q")
+		require.NoError(t, err)
+		assert.Equal(t, "Jane Doe was born in Paris.", resp.Response)
+	})
+
+	t.Run("lookahead length and iterations", func(t *testing.T) {
+		ret := &queryMapRetriever{nodes: map[string][]schema.NodeWithScore{}}
+		engine := NewFLAREQueryEngine(ret, llm.NewMockLLM("one two three four five."),
+			WithFLARELookaheadLength(3), WithFLAREMaxIterations(2))
+		resp, err := engine.Query(ctx, "q")
+		require.NoError(t, err)
+		assert.Equal(t, "one two three one two three", resp.Response)
+	})
+}
+
+func TestFirstSentence(t *testing.T) {
+	assert.Equal(t, "It costs 3.5 dollars.", firstSentence("It costs 3.5 dollars. Then more."))
+	assert.Equal(t, "Is it?", firstSentence("Is it? Yes"))
+	assert.Equal(t, "line one", firstSentence("line one\nline two"))
+	assert.Equal(t, "She was born in", stripSearchMarkers("She was born in [Search(birthplace)]"))
+}
+
+// cypherGraphStore records executed Cypher and returns fixed rows.
+type cypherGraphStore struct {
+	*graphstore.SimpleGraphStore
+	schema  string
+	rows    interface{}
+	queries []string
+}
+
+func (s *cypherGraphStore) GetSchema(ctx context.Context, refresh bool) (string, error) {
+	return s.schema, nil
+}
+
+func (s *cypherGraphStore) Query(ctx context.Context, query string, params map[string]interface{}) (interface{}, error) {
+	s.queries = append(s.queries, query)
+	return s.rows, nil
+}
+
+func TestTextToCypherEngine(t *testing.T) {
+	ctx := context.Background()
+	store := &cypherGraphStore{
+		SimpleGraphStore: graphstore.NewSimpleGraphStore(),
+		schema:           "(:Person {name})-[:WORKS_AT]->(:Company {name})",
+		rows:             []map[string]interface{}{{"company": "Acme"}},
+	}
+	model := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+		"```cypher\nMATCH (p:Person {name: 'Jane'})-[:WORKS_AT]->(c:Company) RETURN c.name AS company\n```",
+		"Jane works at Acme.",
+	}}
+
+	engine := NewTextToCypherEngine(store, model)
+	resp, err := engine.Query(ctx, "Where does Jane work?")
+	require.NoError(t, err)
+
+	cypher := "MATCH (p:Person {name: 'Jane'})-[:WORKS_AT]->(c:Company) RETURN c.name AS company"
+	assert.Equal(t, "Jane works at Acme.", resp.Response)
+	assert.Equal(t, []string{cypher}, store.queries)
+	assert.Equal(t, cypher, resp.Metadata[CypherQueryMetadataKey])
+	require.Len(t, resp.SourceNodes, 1)
+	assert.Equal(t, `[{"company":"Acme"}]`, resp.SourceNodes[0].Node.Text)
+	assert.Contains(t, model.prompts[0], store.schema)
+	assert.Contains(t, model.prompts[1], `[{"company":"Acme"}]`)
+}
+
+func TestTextToCypherEngineRejectsWrites(t *testing.T) {
+	store := &cypherGraphStore{SimpleGraphStore: graphstore.NewSimpleGraphStore()}
+	model := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+		"MATCH (n) DETACH DELETE n",
+	}}
+
+	engine := NewTextToCypherEngine(store, model, WithCypherSchema("(:Person)"))
+	_, err := engine.Query(context.Background(), "Remove everyone")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not read-only")
+	assert.Empty(t, store.queries)
+}
+
+// readCypherGraphStore is a cypherGraphStore that supports read-only
+// transactions.
+type readCypherGraphStore struct {
+	cypherGraphStore
+	readQueries []string
+}
+
+func (s *readCypherGraphStore) QueryRead(ctx context.Context, query string, params map[string]interface{}) (interface{}, error) {
+	s.readQueries = append(s.readQueries, query)
+	return s.rows, nil
+}
+
+func TestTextToCypherEngineReadTransaction(t *testing.T) {
+	newStore := func() *readCypherGraphStore {
+		return &readCypherGraphStore{cypherGraphStore: cypherGraphStore{SimpleGraphStore: graphstore.NewSimpleGraphStore(), rows: "1"}}
+	}
+	cypher := "MATCH (n) RETURN count(n)"
+
+	store := newStore()
+	model := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{cypher}}
+	engine := NewTextToCypherEngine(store, model, WithCypherSchema("(:Person)"), WithCypherSynthesizeResponse(false))
+	_, err := engine.Query(context.Background(), "How many nodes?")
+	require.NoError(t, err)
+	assert.Equal(t, []string{cypher}, store.readQueries)
+	assert.Empty(t, store.queries)
+
+	store = newStore()
+	model = &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{cypher}}
+	engine = NewTextToCypherEngine(store, model, WithCypherSchema("(:Person)"), WithCypherSynthesizeResponse(false), WithCypherReadOnly(false))
+	_, err = engine.Query(context.Background(), "How many nodes?")
+	require.NoError(t, err)
+	assert.Empty(t, store.readQueries)
+	assert.Equal(t, []string{cypher}, store.queries)
+}
+
+func TestTextToCypherEngineRawResults(t *testing.T) {
+	store := &cypherGraphStore{SimpleGraphStore: graphstore.NewSimpleGraphStore(), rows: "42"}
+	model := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{"Cypher: MATCH (n) RETURN count(n)"}}
+
+	engine := NewTextToCypherEngine(store, model, WithCypherSchema("(:Person)"), WithCypherSynthesizeResponse(false))
+	resp, err := engine.Query(context.Background(), "How many nodes?")
+	require.NoError(t, err)
+	assert.Equal(t, "42", resp.Response)
+	assert.Equal(t, []string{"MATCH (n) RETURN count(n)"}, store.queries)
+	assert.Len(t, model.prompts, 1)
+}
+
+func TestValidateReadOnlyCypher(t *testing.T) {
+	tests := []struct {
+		cypher  string
+		wantErr bool
+	}{
+		{"MATCH (n:Person) RETURN n.name LIMIT 10", false},
+		{"MATCH (n) WHERE n.note = 'please delete me' RETURN n", false},
+		{"MATCH (n) RETURN n // create index later", false},
+		{"MATCH (n) RETURN n;", false},
+		{"CREATE (n:Person {name: 'x'})", true},
+		{"MATCH (n) SET n.age = 3", true},
+		{"merge (n:Person {name: 'x'})", true},
+		{"MATCH (n) RETURN n; MATCH (m) DELETE m", true},
+		{"CALL apoc.create.node(['Person'], {})", true},
+		{"LOAD CSV FROM 'file:///x.csv' AS row RETURN row", true},
+		{"CALL db.labels()", false},
+		{"CALL db.labels", false},
+		{"CALL db.index.fulltext.queryNodes('names', 'jane') YIELD node RETURN node", false},
+		{"MATCH (n) CALL apoc.path.subgraphAll(n, {maxLevel: 2}) YIELD nodes RETURN nodes", false},
+		{"MATCH (n) CALL { WITH n MATCH (n)--(m) RETURN count(m) AS c } RETURN n, c", false},
+		{"MATCH (n) CALL (n) { MATCH (n)--(m) RETURN count(m) AS c } RETURN n, c", false},
+		{"CALL apoc.do.when(true, 'CREATE (n) RETURN n', '', {}) YIELD value RETURN value", true},
+		{"CALL apoc.do.case([true, 'MATCH (n) DETACH DELETE n'], '', {})", true},
+		{"CALL apoc.cypher.doIt('MATCH (n) DETACH DELETE n', {})", true},
+		{"CALL apoc . cypher . doIt('x', {})", true},
+		{"CALL apoc.`do`.when(true, 'x', '', {})", true},
+		{"CALL `apoc.do.when`(true, 'x', '', {})", true},
+		{"CALL apoc.meta.`x`()", true},
+		{"CALL dbms.security.createUser('x', 'y', false)", true},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateReadOnlyCypher(tt.cypher)
+		if tt.wantErr {
+			assert.Error(t, err, tt.cypher)
+		} else {
+			assert.NoError(t, err, tt.cypher)
+		}
+	}
+}
+
+// warmingRetriever records warm-up calls.
+type warmingRetriever struct {
+	MockRetriever
+	warmups int
+}
+
+func (w *warmingRetriever) Warmup(ctx context.Context) error {
+	w.warmups++
+	return nil
+}
+
+func TestRetrieverQueryEngineWarmup(t *testing.T) {
+	ret := &warmingRetriever{}
+	engine := NewRetrieverQueryEngine(ret, synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("ok")))
+
+	require.NoError(t, engine.Warmup(context.Background()))
+	assert.Equal(t, 1, ret.warmups)
+}
+
+func TestProviderWarmers(t *testing.T) {
+	ctx := context.Background()
+
+	assert.NoError(t, LLMWarmer(llm.NewMockLLM("pong")).Warmup(ctx))
+	assert.ErrorContains(t, LLMWarmer(llm.NewMockLLMWithError(errors.New("invalid api key"))).Warmup(ctx), "invalid api key")
+
+	assert.NoError(t, EmbeddingWarmer(embedding.NewMockEmbeddingModel([]float64{1, 0})).Warmup(ctx))
+	assert.Error(t, EmbeddingWarmer(embedding.NewMockEmbeddingModelWithError(errors.New("unauthorized"))).Warmup(ctx))
+}
+
+func TestReadiness(t *testing.T) {
+	ctx := context.Background()
+	release := make(chan struct{})
+
+	r := StartWarmup(ctx, map[string]Warmer{
+		"index": WarmupFunc(func(ctx context.Context) error {
+			<-release
+			return nil
+		}),
+		"llm": WarmupFunc(func(ctx context.Context) error { return nil }),
+	})
+
+	assert.False(t, r.Ready())
+	assert.ErrorContains(t, r.HealthCheck(ctx), "index running")
+
+	close(release)
+	require.NoError(t, r.Wait(ctx))
+	assert.True(t, r.Ready())
+
+	statuses := r.Status()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "index", statuses[0].Name)
+	assert.Equal(t, WarmupStateReady, statuses[0].State)
+
+	// A failed component is reported and can be restarted.
+	r.Start(ctx, "embeddings", WarmupFunc(func(ctx context.Context) error { return errors.New("bad credentials") }))
+	err := r.Wait(ctx)
+	assert.ErrorContains(t, err, "embeddings failed: bad credentials")
+
+	r.Start(ctx, "embeddings", WarmupFunc(func(ctx context.Context) error { return nil }))
+	assert.NoError(t, r.Wait(ctx))
+}
+
+func TestReadinessTimeout(t *testing.T) {
+	ctx := context.Background()
+	r := StartWarmup(ctx, map[string]Warmer{
+		"slow": WarmupFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+	}, WithWarmupTimeout(10*time.Millisecond))
+
+	assert.ErrorContains(t, r.Wait(ctx), "slow failed")
+}
+
+func TestLimitedQueryEngine(t *testing.T) {
+	inner := &MockQueryEngine{Response: synthesizer.NewResponse("a very long answer", nil)}
+
+	l := limits.Limits{Response: limits.Limit{MaxBytes: 6, Strategy: limits.StrategyTruncate}, TruncationMarker: "…"}
+	resp, err := NewLimitedQueryEngine(inner, &l).Query(context.Background(), "q")
+	require.NoError(t, err)
+	assert.Equal(t, "a v…", resp.Response)
+
+	strict := limits.Limits{Response: limits.Limit{MaxBytes: 6}}
+	inner.Response = synthesizer.NewResponse("another long answer", nil)
+	_, err = NewLimitedQueryEngine(inner, &strict).Query(context.Background(), "q")
+	assert.ErrorIs(t, err, limits.ErrLimitExceeded)
+}
+
+// fixedScorer returns a confidence per response text.
+type fixedScorer map[string]float64
+
+func (s fixedScorer) Estimate(ctx context.Context, query string, resp *synthesizer.Response) (float64, error) {
+	return s[resp.Response], nil
+}
+
+func TestTieredQueryEngine(t *testing.T) {
+	ctx := context.Background()
+
+	small := &MockQueryEngine{Response: synthesizer.NewResponse("small answer", nil)}
+	large := &MockQueryEngine{Response: synthesizer.NewResponse("large answer", nil)}
+	tiers := []QueryTier{
+		{Name: "small", Engine: small, MinConfidence: 0.7},
+		{Name: "large", Engine: large},
+	}
+
+	t.Run("accepts confident cheap answer", func(t *testing.T) {
+		engine := NewTieredQueryEngine(tiers, WithTierConfidenceScorer(fixedScorer{"small answer": 0.9}))
+
+		resp, err := engine.Query(ctx, "q")
+		require.NoError(t, err)
+		assert.Equal(t, "small answer", resp.Response)
+		assert.Equal(t, "small", resp.Metadata[TierMetadataKey])
+		assert.Equal(t, 0.9, resp.Metadata[TierConfidenceMetadataKey])
+		assert.Empty(t, resp.Metadata[TierEscalationsMetadataKey])
+		assert.Equal(t, 0.0, engine.EscalationRate())
+	})
+
+	t.Run("escalates low confidence", func(t *testing.T) {
+		engine := NewTieredQueryEngine(tiers, WithTierConfidenceScorer(fixedScorer{"small answer": 0.2}))
+
+		resp, err := engine.Query(ctx, "q")
+		require.NoError(t, err)
+		assert.Equal(t, "large answer", resp.Response)
+		assert.Equal(t, "large", resp.Metadata[TierMetadataKey])
+		assert.Equal(t, []string{"small"}, resp.Metadata[TierEscalationsMetadataKey])
+		assert.Empty(t, large.Response.Metadata, "the engine's response is not modified")
+
+		stats := engine.Stats()
+		assert.Equal(t, 1, stats.Queries)
+		assert.Equal(t, 1, stats.Escalated["small"])
+		assert.Equal(t, 1, stats.Answered["large"])
+		assert.Equal(t, 1.0, engine.EscalationRate())
+
+		engine.ResetStats()
+		assert.Equal(t, 0, engine.Stats().Queries)
+	})
+
+	t.Run("uses confidence metadata and abstentions", func(t *testing.T) {
+		confident := &MockQueryEngine{Response: synthesizer.NewResponseWithMetadata("meta", nil,
+			map[string]interface{}{evaluation.ConfidenceMetadataKey: 0.8})}
+		abstaining := &MockQueryEngine{Response: NewAbstentionPolicy().Abstain(nil, nil)}
+
+		engine := NewTieredQueryEngine([]QueryTier{
+			{Name: "retrieval", Engine: abstaining, MinConfidence: 0.5},
+			{Name: "small", Engine: confident, MinConfidence: 0.5},
+			{Name: "large", Engine: large},
+		})
+		resp, err := engine.Query(ctx, "q")
+		require.NoError(t, err)
+		assert.Equal(t, "meta", resp.Response)
+		assert.InDelta(t, 1.0, engine.EscalationRate(), 0.001)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		failing := &MockQueryEngine{Err: errors.New("boom")}
+		failingTiers := []QueryTier{{Name: "small", Engine: failing}, {Name: "large", Engine: large}}
+
+		_, err := NewTieredQueryEngine(failingTiers).Query(ctx, "q")
+		assert.ErrorContains(t, err, "tier small failed")
+
+		engine := NewTieredQueryEngine(failingTiers, WithTierEscalateOnError(true))
+		resp, err := engine.Query(ctx, "q")
+		require.NoError(t, err)
+		assert.Equal(t, "large answer", resp.Response)
+		assert.Equal(t, 1, engine.Stats().Errors["small"])
+
+		_, err = NewTieredQueryEngine(nil).Query(ctx, "q")
+		assert.Error(t, err)
+	})
+}
+
+func TestFallbackEngine(t *testing.T) {
+	ctx := context.Background()
+
+	answering := func(answer string) *RetrieverQueryEngine {
+		return NewRetrieverQueryEngine(&MockRetriever{Nodes: createTestNodes()}, synthesizer.NewSimpleSynthesizer(llm.NewMockLLM(answer)))
+	}
+	emptyRetrieval := NewRetrieverQueryEngine(&MockRetriever{}, synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("unused")))
+	llmDown := NewRetrieverQueryEngine(&MockRetriever{Nodes: createTestNodes()}, synthesizer.NewSimpleSynthesizer(&llm.MockLLM{Err: errors.New("rate limited")}))
+
+	t.Run("primary answers", func(t *testing.T) {
+		engine := NewFallbackEngine(answering("primary answer"), NewCannedFallback("canned", "Sorry."))
+
+		resp, err := engine.Query(ctx, "q")
+		require.NoError(t, err)
+		assert.Equal(t, "primary answer", resp.Response)
+		assert.Equal(t, PrimaryPathName, resp.Metadata[FallbackPathMetadataKey])
+		assert.Empty(t, resp.Metadata[FallbackFailuresMetadataKey])
+		assert.Equal(t, 0.0, engine.Stats().FallbackRate())
+	})
+
+	t.Run("routes by failure kind", func(t *testing.T) {
+		broader := answering("broader answer")
+		fallbacks := []Fallback{
+			NewFallback("broader", broader, FailureEmptyRetrieval),
+			NewCannedFallback("canned", "The assistant is unavailable.", FailureLLMError),
+		}
+
+		engine := NewFallbackEngine(emptyRetrieval, fallbacks...)
+		resp, err := engine.Query(ctx, "q")
+		require.NoError(t, err)
+		assert.Equal(t, "broader answer", resp.Response)
+		assert.Equal(t, "broader", resp.Metadata[FallbackPathMetadataKey])
+		assert.Equal(t, []PathFailure{{Path: PrimaryPathName, Kind: FailureEmptyRetrieval}}, resp.Metadata[FallbackFailuresMetadataKey])
+
+		engine = NewFallbackEngine(llmDown, fallbacks...)
+		resp, err = engine.Query(ctx, "q")
+		require.NoError(t, err)
+		assert.Equal(t, "The assistant is unavailable.", resp.Response)
+		failures := resp.Metadata[FallbackFailuresMetadataKey].([]PathFailure)
+		require.Len(t, failures, 1)
+		assert.Equal(t, FailureLLMError, failures[0].Kind)
+		assert.Contains(t, failures[0].Error, "rate limited")
+
+		stats := engine.Stats()
+		assert.Equal(t, 1, stats.Queries)
+		assert.Equal(t, 1, stats.Failures[PrimaryPathName][FailureLLMError])
+		assert.Equal(t, 1, stats.Answered["canned"])
+		assert.Equal(t, 0, stats.Attempts["broader"])
+		assert.Equal(t, 1.0, stats.FallbackRate())
+
+		engine.ResetStats()
+		assert.Equal(t, 0, engine.Stats().Queries)
+	})
+
+	t.Run("low confidence", func(t *testing.T) {
+		abstaining := &MockQueryEngine{Response: NewAbstentionPolicy().Abstain(nil, nil)}
+		web := &MockQueryEngine{Response: synthesizer.NewResponse("web answer", nil)}
+
+		engine := NewFallbackEngine(abstaining, NewFallback("web", web, FailureLowConfidence))
+		resp, err := engine.Query(ctx, "q")
+		require.NoError(t, err)
+		assert.Equal(t, "web answer", resp.Response)
+		assert.Empty(t, web.Response.Metadata, "the engine's response is not modified")
+
+		primary := &MockQueryEngine{Response: synthesizer.NewResponse("unsure", nil)}
+		engine = NewFallbackEngine(primary, NewFallback("web", web, FailureLowConfidence)).
+			WithMinConfidence(0.5).
+			WithConfidenceScorer(fixedScorer{"unsure": 0.3, "web answer": 0.9})
+		resp, err = engine.Query(ctx, "q")
+		require.NoError(t, err)
+		assert.Equal(t, "web", resp.Metadata[FallbackPathMetadataKey])
+		assert.Equal(t, 1, engine.Stats().Failures[PrimaryPathName][FailureLowConfidence])
+	})
+
+	t.Run("no path answers", func(t *testing.T) {
+		abstaining := &MockQueryEngine{Response: NewAbstentionPolicy().Abstain(nil, nil)}
+
+		// Unhandled failures return the last answer, even an abstention.
+		engine := NewFallbackEngine(abstaining, NewCannedFallback("canned", "Sorry.", FailureLLMError))
+		resp, err := engine.Query(ctx, "q")
+		require.NoError(t, err)
+		assert.Equal(t, PrimaryPathName, resp.Metadata[FallbackPathMetadataKey])
+		assert.Equal(t, 1, engine.Stats().Unanswered)
+
+		failing := &MockQueryEngine{Err: errors.New("boom")}
+		engine = NewFallbackEngine(failing, NewFallback("also failing", &MockQueryEngine{Err: errors.New("bang")}))
+		_, err = engine.Query(ctx, "q")
+		assert.ErrorContains(t, err, "all query paths failed: bang")
+		assert.Equal(t, 1, engine.Stats().Failures[PrimaryPathName][FailureError])
+	})
+}
+
+// fakeSQL is a minimal database/sql driver that records statements and
+// answers every query with a fixed result.
+type fakeSQL struct {
+	execs   []string
+	args    [][]driver.Value
+	queries []string
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (f *fakeSQL) Connect(ctx context.Context) (driver.Conn, error) { return &fakeSQLConn{db: f}, nil }
+func (f *fakeSQL) Driver() driver.Driver                            { return nil }
+
+type fakeSQLConn struct{ db *fakeSQL }
+
+func (c *fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeSQLStmt{db: c.db, query: query}, nil
+}
+func (c *fakeSQLConn) Close() error              { return nil }
+func (c *fakeSQLConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *fakeSQLConn) Commit() error             { return nil }
+func (c *fakeSQLConn) Rollback() error           { return nil }
+
+type fakeSQLStmt struct {
+	db    *fakeSQL
+	query string
+}
+
+func (s *fakeSQLStmt) Close() error  { return nil }
+func (s *fakeSQLStmt) NumInput() int { return -1 }
+func (s *fakeSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.execs = append(s.db.execs, s.query)
+	s.db.args = append(s.db.args, args)
+	return driver.RowsAffected(1), nil
+}
+func (s *fakeSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.queries = append(s.db.queries, s.query)
+	return &fakeSQLRows{columns: s.db.columns, rows: s.db.rows}, nil
+}
+
+type fakeSQLRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeSQLRows) Columns() []string { return r.columns }
+func (r *fakeSQLRows) Close() error      { return nil }
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestTableQueryEngine(t *testing.T) {
+	ctx := context.Background()
+	csvPath := filepath.Join(t.TempDir(), "sales.csv")
+	require.NoError(t, os.WriteFile(csvPath, []byte("Region,Units\nNorth,10\nSouth,4\n"), 0o644))
+
+	fake := &fakeSQL{
+		columns: []string{"region", "total"},
+		rows:    [][]driver.Value{{"North", int64(10)}},
+	}
+	db := sql.OpenDB(fake)
+	defer db.Close()
+
+	query := `SELECT "region", SUM("units") AS total FROM "sales" GROUP BY "region" ORDER BY total DESC LIMIT 1`
+	model := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+		"```sql\n" + query + "\n```",
+		"North sold the most units (10).",
+	}}
+
+	engine, err := NewTableQueryEngineFromFiles(ctx, db, model, []string{csvPath})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`CREATE TABLE "sales" ("region" TEXT, "units" INTEGER)`,
+		`INSERT INTO "sales" ("region", "units") VALUES (?, ?)`,
+		`INSERT INTO "sales" ("region", "units") VALUES (?, ?)`,
+	}, fake.execs)
+	assert.Equal(t, []driver.Value{"North", int64(10)}, fake.args[1])
+
+	resp, err := engine.Query(ctx, "Which region sold the most?")
+	require.NoError(t, err)
+	assert.Equal(t, "North sold the most units (10).", resp.Response)
+	assert.Equal(t, query, resp.Metadata[SQLQueryMetadataKey])
+	assert.Equal(t, []string{query}, fake.queries)
+
+	result, ok := resp.Metadata[SQLResultMetadataKey].(*SQLResult)
+	require.True(t, ok)
+	assert.Equal(t, "region | total\nNorth | 10", result.String())
+	require.Len(t, resp.SourceNodes, 1)
+	assert.Equal(t, result.String(), resp.SourceNodes[0].Node.Text)
+
+	assert.Contains(t, model.prompts[0], `CREATE TABLE "sales"`)
+	assert.Contains(t, model.prompts[0], "-- North | 10")
+	assert.Contains(t, model.prompts[0], "SQLite")
+	assert.Contains(t, model.prompts[1], "North | 10")
+}
+
+func TestTableQueryEngineRejectsWrites(t *testing.T) {
+	fake := &fakeSQL{}
+	db := sql.OpenDB(fake)
+	defer db.Close()
+
+	table := reader.InferTable("sales", []string{"region"}, [][]string{{"North"}})
+	model := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{`DROP TABLE "sales"`}}
+
+	engine := NewTableQueryEngine(db, model, []*reader.Table{table})
+	_, err := engine.Query(context.Background(), "Delete everything")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "refusing to execute")
+	assert.Empty(t, fake.queries)
+}
+
+func TestTableQueryEngineRawResults(t *testing.T) {
+	fake := &fakeSQL{columns: []string{"n"}, rows: [][]driver.Value{{int64(2)}, {int64(3)}}}
+	db := sql.OpenDB(fake)
+	defer db.Close()
+
+	model := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{"SQLQuery: SELECT 1 AS n\nSQLResult: 1"}}
+	engine := NewTableQueryEngine(db, model, nil, WithSQLSynthesizeResponse(false), WithSQLMaxRows(1))
+
+	resp, err := engine.Query(context.Background(), "How many?")
+	require.NoError(t, err)
+	assert.Equal(t, "n\n2", resp.Response)
+	assert.Equal(t, []string{"SELECT 1 AS n"}, fake.queries)
+	assert.Len(t, model.prompts, 1)
+}
+
+func TestValidateReadOnlySQL(t *testing.T) {
+	tests := []struct {
+		sql     string
+		wantErr bool
+	}{
+		{`SELECT * FROM "sales" LIMIT 10`, false},
+		{`select region from sales where note = 'delete me';`, false},
+		{`WITH t AS (SELECT 1) SELECT * FROM t`, false},
+		{`SELECT "update" FROM sales -- drop later`, false},
+		{`DELETE FROM sales`, true},
+		{`SELECT 1; DROP TABLE sales`, true},
+		{`WITH t AS (DELETE FROM sales RETURNING *) SELECT * FROM t`, true},
+		{`PRAGMA table_info(sales)`, true},
+		{`ATTACH DATABASE 'x.db' AS x`, true},
+		{``, true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateReadOnlySQL(tt.sql)
+		if tt.wantErr {
+			assert.Error(t, err, tt.sql)
+		} else {
+			assert.NoError(t, err, tt.sql)
+		}
+	}
+}
+
+// queryRecorder answers every query with a fixed response and records
+// the queries.
+type queryRecorder struct {
+	answer  string
+	queries []string
+}
+
+func (q *queryRecorder) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	q.queries = append(q.queries, query)
+	node := schema.NewTextNode(q.answer)
+	return synthesizer.NewResponse(q.answer, []schema.NodeWithScore{{Node: *node, Score: 0.8}}), nil
+}
+
+func TestSQLAutoVectorQueryEngine(t *testing.T) {
+	ctx := context.Background()
+
+	newEngine := func(choices, followUp string) (*SQLAutoVectorQueryEngine, *MockQueryEngine, *queryRecorder, *[]string) {
+		sqlEngine := &MockQueryEngine{Response: synthesizer.NewResponseWithMetadata(
+			"Tokyo has the largest population.", nil,
+			map[string]interface{}{SQLQueryMetadataKey: `SELECT city FROM cities ORDER BY population DESC LIMIT 1`},
+		)}
+		vectorEngine := &queryRecorder{answer: "Tokyo has a vibrant arts scene."}
+		var prompts []string
+		l := &funcLLM{MockLLM: llm.NewMockLLM(""), respond: func(prompt string) (string, error) {
+			prompts = append(prompts, prompt)
+			switch {
+			case strings.Contains(prompt, "Some choices are given below"):
+				return choices, nil
+			case strings.Contains(prompt, "New question: "):
+				return followUp, nil
+			default:
+				return "Tokyo, the most populous city, has a vibrant arts scene.", nil
+			}
+		}}
+		engine := NewSQLAutoVectorQueryEngine(
+			NewQueryEngineTool(sqlEngine, "city_stats", "Population and country of each city"),
+			NewQueryEngineTool(vectorEngine, "city_articles", "Articles about the culture and history of cities"),
+			l,
+		)
+		return engine, sqlEngine, vectorEngine, &prompts
+	}
+
+	t.Run("SQLThenVector", func(t *testing.T) {
+		engine, _, vectorEngine, prompts := newEngine(`[{"choice": 1, "reason": "population is in the table"}]`, "What is the arts scene in Tokyo like?")
+
+		resp, err := engine.Query(ctx, "Tell me about the arts scene of the most populous city.")
+		require.NoError(t, err)
+		assert.Equal(t, "Tokyo, the most populous city, has a vibrant arts scene.", resp.Response)
+		assert.Equal(t, []string{"What is the arts scene in Tokyo like?"}, vectorEngine.queries)
+		assert.Equal(t, []string{"city_stats", "city_articles"}, resp.Metadata[SelectedEnginesMetadataKey])
+		assert.Equal(t, "What is the arts scene in Tokyo like?", resp.Metadata[VectorQueryMetadataKey])
+		assert.Contains(t, resp.Metadata[SQLQueryMetadataKey], "ORDER BY population")
+		assert.Len(t, resp.SourceNodes, 1)
+
+		synthesis := (*prompts)[len(*prompts)-1]
+		assert.Contains(t, synthesis, "SQL response: Tokyo has the largest population.")
+		assert.Contains(t, synthesis, "Query engine response: Tokyo has a vibrant arts scene.")
+	})
+
+	t.Run("SQLOnly", func(t *testing.T) {
+		engine, sqlEngine, vectorEngine, _ := newEngine(`[{"choice": 1, "reason": "numbers"}]`, "None")
+
+		resp, err := engine.Query(ctx, "Which city has the largest population?")
+		require.NoError(t, err)
+		assert.Equal(t, "Tokyo has the largest population.", resp.Response)
+		assert.Equal(t, []string{"city_stats"}, resp.Metadata[SelectedEnginesMetadataKey])
+		assert.Equal(t, 1, sqlEngine.CallCount)
+		assert.Empty(t, vectorEngine.queries)
+	})
+
+	t.Run("VectorOnly", func(t *testing.T) {
+		engine, sqlEngine, _, prompts := newEngine(`[{"choice": 2, "reason": "culture"}]`, "unused")
+
+		resp, err := engine.Query(ctx, "What is Tokyo's arts scene like?")
+		require.NoError(t, err)
+		assert.Equal(t, "Tokyo has a vibrant arts scene.", resp.Response)
+		assert.Equal(t, []string{"city_articles"}, resp.Metadata[SelectedEnginesMetadataKey])
+		assert.Equal(t, 0, sqlEngine.CallCount)
+		assert.Len(t, *prompts, 1, "only the selector is asked")
+	})
+
+	t.Run("Both", func(t *testing.T) {
+		engine, _, vectorEngine, _ := newEngine(`[{"choice": 1, "reason": "a"}, {"choice": 2, "reason": "b"}]`, "None")
+
+		resp, err := engine.Query(ctx, "Compare the population and arts scene of Tokyo.")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Compare the population and arts scene of Tokyo."}, vectorEngine.queries,
+			"the original question is used when no follow-up is needed")
+		assert.Equal(t, []string{"city_stats", "city_articles"}, resp.Metadata[SelectedEnginesMetadataKey])
+	})
+
+	t.Run("NoAugment", func(t *testing.T) {
+		engine, _, vectorEngine, _ := newEngine(`[{"choice": 1, "reason": "numbers"}]`, "What about Tokyo?")
+		engine.AugmentVectorQuery = false
+
+		_, err := engine.Query(ctx, "Which city has the largest population?")
+		require.NoError(t, err)
+		assert.Empty(t, vectorEngine.queries)
+	})
+
+	t.Run("InvalidSelection", func(t *testing.T) {
+		engine, _, _, _ := newEngine(`[{"choice": 5, "reason": "?"}]`, "None")
+		_, err := engine.Query(ctx, "Anything?")
+		assert.Error(t, err)
+	})
+}
+
+func TestDataFrameQuery(t *testing.T) {
+	table := reader.InferTable("sales", []string{"Region", "Product", "Units", "Price"}, [][]string{
+		{"North", "Widget", "10", "2.5"},
+		{"South", "Widget", "4", "2.5"},
+		{"North", "Gadget", "7", "10"},
+		{"East", "Gadget", "", "12"},
+		{"South", "Gizmo", "1", "3"},
+	})
+
+	apply := func(t *testing.T, q string) string {
+		t.Helper()
+		query, err := ParseDataFrameQuery(q)
+		require.NoError(t, err)
+		result, err := query.Apply(table)
+		require.NoError(t, err)
+		return formatTable(result)
+	}
+
+	t.Run("FilterSelectSort", func(t *testing.T) {
+		got := apply(t, `{"filters": [{"column": "units", "op": ">=", "value": 4}], "select": ["Region", "units"], "sort": [{"column": "units", "desc": true}]}`)
+		assert.Equal(t, "region | units\nNorth | 10\nNorth | 7\nSouth | 4", got)
+	})
+
+	t.Run("GroupByAggregate", func(t *testing.T) {
+		got := apply(t, "```json\n"+`{"group_by": ["region"], "aggregations": [{"func": "sum", "column": "units", "as": "total"}, {"func": "count"}, {"func": "mean", "column": "price"}], "sort": [{"column": "total", "desc": true}, {"column": "region"}]}`+"\n```")
+		assert.Equal(t, "region | total | count | mean_price\nNorth | 17 | 2 | 6.25\nSouth | 5 | 2 | 2.75\nEast | NULL | 1 | 12", got)
+	})
+
+	t.Run("AggregateWithoutGroups", func(t *testing.T) {
+		got := apply(t, `{"filters": [{"column": "product", "op": "in", "value": ["gadget", "Gizmo"]}, {"column": "units", "op": "not_null"}], "aggregations": [{"func": "max", "column": "units"}, {"func": "count_distinct", "column": "region"}]}`)
+		assert.Equal(t, "max_units | count_distinct_region\n7 | 2", got)
+
+		got = apply(t, `{"filters": [{"column": "region", "op": "contains", "value": "west"}], "aggregations": [{"func": "count"}]}`)
+		assert.Equal(t, "count\n0", got)
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		got := apply(t, `{"select": ["product"], "sort": [{"column": "price", "desc": true}], "limit": 2}`)
+		assert.Equal(t, "product\nGadget\nGadget", got)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, q := range []string{
+			`{"select": ["missing"]}`,
+			`{"filters": [{"column": "units", "op": "~", "value": 1}]}`,
+			`{"aggregations": [{"func": "median", "column": "units"}]}`,
+			`{"aggregations": [{"func": "sum", "column": "region"}]}`,
+			`{"aggregations": [{"func": "sum"}]}`,
+		} {
+			query, err := ParseDataFrameQuery(q)
+			require.NoError(t, err, q)
+			_, err = query.Apply(table)
+			assert.Error(t, err, q)
+		}
+
+		_, err := ParseDataFrameQuery(`{"code": "import os"}`)
+		assert.Error(t, err, "unknown fields are rejected")
+		_, err = ParseDataFrameQuery("I cannot answer that.")
+		assert.Error(t, err)
+	})
+}
+
+func TestDataFrameQueryEngine(t *testing.T) {
+	ctx := context.Background()
+	csvPath := filepath.Join(t.TempDir(), "sales.csv")
+	require.NoError(t, os.WriteFile(csvPath, []byte("Region,Units\nNorth,10\nSouth,4\nNorth,3\n"), 0o644))
+
+	model := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+		`{"group_by": ["region"], "aggregations": [{"func": "sum", "column": "units", "as": "total"}], "sort": [{"column": "total", "desc": true}], "limit": 1}`,
+		"North sold the most units (13).",
+	}}
+	engine, err := NewDataFrameQueryEngineFromFile(csvPath, model)
+	require.NoError(t, err)
+
+	resp, err := engine.Query(ctx, "Which region sold the most?")
+	require.NoError(t, err)
+	assert.Equal(t, "North sold the most units (13).", resp.Response)
+	query, ok := resp.Metadata[DataFrameQueryMetadataKey].(*DataFrameQuery)
+	require.True(t, ok)
+	assert.Equal(t, []string{"region"}, query.GroupBy)
+	result, ok := resp.Metadata[DataFrameResultMetadataKey].(*reader.Table)
+	require.True(t, ok)
+	assert.Equal(t, [][]interface{}{{"North", int64(13)}}, result.Rows)
+	require.Len(t, resp.SourceNodes, 1)
+	assert.Equal(t, "region | total\nNorth | 13", resp.SourceNodes[0].Node.Text)
+
+	assert.Contains(t, model.prompts[0], "- units (integer)")
+	assert.Contains(t, model.prompts[0], "North | 10")
+	assert.Contains(t, model.prompts[0], "Question: Which region sold the most?")
+	assert.Contains(t, model.prompts[1], "Result: region | total\nNorth | 13")
+
+	raw := NewDataFrameQueryEngine(engine.Table, &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+		`{"select": ["units"]}`,
+	}}, WithDataFrameSynthesizeResponse(false), WithDataFrameMaxRows(2))
+	resp, err = raw.Query(ctx, "List the units.")
+	require.NoError(t, err)
+	assert.Equal(t, "units\n10\n4", resp.Response)
+
+	bad := NewDataFrameQueryEngine(engine.Table, &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+		`{"filters": [{"column": "price", "op": ">", "value": 1}]}`,
+	}})
+	_, err = bad.Query(ctx, "Expensive items?")
+	assert.ErrorContains(t, err, "unknown column")
+}
+
+// chatRecorder records chat messages and returns a fixed answer.
+type chatRecorder struct {
+	*llm.MockLLM
+	messages []llm.ChatMessage
+}
+
+func (c *chatRecorder) Chat(ctx context.Context, messages []llm.ChatMessage) (string, error) {
+	c.messages = append(c.messages, messages...)
+	return "The cat is orange.", nil
+}
+
+func TestImageQueryEngine(t *testing.T) {
+	ctx := context.Background()
+
+	pngPath := filepath.Join(t.TempDir(), "chart.png")
+	require.NoError(t, os.WriteFile(pngPath, []byte("png-bytes"), 0o644))
+
+	cat := schema.NewImageNodeFromURL("https://img/cat.jpg", "image/jpeg")
+	cat.Text = "a cat"
+	chart := schema.NewImageNodeFromPath(pngPath, "")
+	text := schema.NewTextNode("not an image")
+	r := &MockRetriever{Nodes: []schema.NodeWithScore{
+		{Node: cat.AsNode(), Score: 0.9},
+		{Node: chart.AsNode(), Score: 0.5},
+		{Node: *text, Score: 0.4},
+	}}
+
+	t.Run("Retrieval only", func(t *testing.T) {
+		resp, err := NewImageQueryEngine(r).Query(ctx, "cat pictures")
+		require.NoError(t, err)
+		assert.Contains(t, resp.Response, "Found 2 relevant images")
+		assert.Contains(t, resp.Response, "https://img/cat.jpg (a cat)")
+		assert.Contains(t, resp.Response, pngPath)
+		assert.Len(t, resp.SourceNodes, 2)
+
+		images, ok := resp.Metadata[ImageNodesMetadataKey].([]*schema.ImageNode)
+		require.True(t, ok)
+		require.Len(t, images, 2)
+		assert.Equal(t, "https://img/cat.jpg", images[0].ImageURL)
+	})
+
+	t.Run("Vision answer", func(t *testing.T) {
+		vision := &chatRecorder{MockLLM: llm.NewMockLLM("")}
+		resp, err := NewImageQueryEngine(r, WithImageLLM(vision)).Query(ctx, "What colour is the cat?")
+		require.NoError(t, err)
+		assert.Equal(t, "The cat is orange.", resp.Response)
+
+		require.Len(t, vision.messages, 1)
+		blocks := vision.messages[0].Blocks
+		require.Len(t, blocks, 3)
+		assert.Equal(t, llm.ContentBlockTypeText, blocks[0].Type)
+		assert.Contains(t, blocks[0].Text, "What colour is the cat?")
+		assert.Equal(t, "https://img/cat.jpg", blocks[1].ImageURL)
+		assert.Equal(t, "cG5nLWJ5dGVz", blocks[2].ImageBase64)
+		assert.Equal(t, "image/png", blocks[2].ImageMimeType)
+	})
+
+	t.Run("No images", func(t *testing.T) {
+		vision := &chatRecorder{MockLLM: llm.NewMockLLM("")}
+		engine := NewImageQueryEngine(&MockRetriever{}, WithImageLLM(vision))
+		resp, err := engine.Query(ctx, "anything")
+		require.NoError(t, err)
+		assert.Equal(t, "No relevant images found.", resp.Response)
+		assert.Empty(t, vision.messages)
+	})
+}
+
+// keywordLLM answers map prompts that mention a keyword and merges reduce
+// prompts. It is safe for concurrent use.
+type keywordLLM struct {
+	*llm.MockLLM
+	keyword string
+	mu      sync.Mutex
+	maps    int
+	reduces int
+}
+
+func (k *keywordLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if strings.HasPrefix(prompt, "Below are partial answers") {
+		k.reduces++
+		return fmt.Sprintf("merged-%d", k.reduces), nil
+	}
+	k.maps++
+	if strings.Contains(prompt, k.keyword) {
+		return "found " + k.keyword, nil
+	}
+	return DocumentQANotRelevant, nil
+}
+
+func TestDocumentQAEngine(t *testing.T) {
+	filler := strings.Repeat("lorem ipsum dolor sit amet ", 20)
+	document := filler + "the secret code is zebra " + filler + filler + "zebra appears again " + filler
+
+	t.Run("map and reduce", func(t *testing.T) {
+		model := &keywordLLM{MockLLM: llm.NewMockLLM(""), keyword: "zebra"}
+		engine := NewDocumentQAEngine(document, model,
+			WithDocumentQAChunkSize(50, 0),
+			WithDocumentQAConcurrency(3),
+		)
+		require.Greater(t, len(engine.Chunks()), 4)
+
+		resp, err := engine.Query(context.Background(), "what is the code?")
+		require.NoError(t, err)
+		assert.Equal(t, "merged-1", resp.Response)
+		assert.Equal(t, len(engine.Chunks()), resp.Metadata[DocumentQAChunksMetadataKey])
+		assert.Equal(t, 2, resp.Metadata[DocumentQARelevantMetadataKey])
+		assert.Equal(t, 1, resp.Metadata[DocumentQARoundsMetadataKey])
+		require.Len(t, resp.SourceNodes, 2)
+		assert.Contains(t, resp.SourceNodes[0].Node.Text, "zebra")
+		assert.Less(t, resp.SourceNodes[0].Node.Metadata[DocumentQAChunkIndexMetadataKey].(int),
+			resp.SourceNodes[1].Node.Metadata[DocumentQAChunkIndexMetadataKey].(int))
+		assert.Equal(t, len(engine.Chunks()), model.maps)
+	})
+
+	t.Run("budget forces reduce rounds", func(t *testing.T) {
+		model := &keywordLLM{MockLLM: llm.NewMockLLM(""), keyword: "lorem"}
+		engine := NewDocumentQAEngine(document, model,
+			WithDocumentQAChunkSize(50, 0),
+			WithDocumentQAReduceBudget(4),
+		)
+		resp, err := engine.Query(context.Background(), "what is repeated?")
+		require.NoError(t, err)
+		assert.Greater(t, resp.Metadata[DocumentQARoundsMetadataKey].(int), 1)
+		assert.True(t, strings.HasPrefix(resp.Response, "merged-"))
+	})
+
+	t.Run("nothing relevant", func(t *testing.T) {
+		model := &keywordLLM{MockLLM: llm.NewMockLLM(""), keyword: "giraffe"}
+		engine := NewDocumentQAEngine(document, model, WithDocumentQAChunkSize(50, 0))
+		resp, err := engine.Query(context.Background(), "which animal?")
+		require.NoError(t, err)
+		assert.Empty(t, resp.SourceNodes)
+		assert.Equal(t, 0, model.reduces)
+		assert.Contains(t, resp.Response, "does not contain")
+	})
+
+	t.Run("llm error", func(t *testing.T) {
+		engine := NewDocumentQAEngine(document, &failingCompleteLLM{MockLLM: llm.NewMockLLM("")}, WithDocumentQAChunkSize(50, 0))
+		_, err := engine.Query(context.Background(), "q")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "map stage failed")
+	})
+}
+
+type failingCompleteLLM struct {
+	*llm.MockLLM
+}
+
+func (f *failingCompleteLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	return "", errors.New("model unavailable")
+}
+
+// funcLLM answers Complete with a function of the prompt. It is safe for
+// concurrent use when the function is.
+type funcLLM struct {
+	*llm.MockLLM
+	respond func(prompt string) (string, error)
+}
+
+func (f *funcLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	return f.respond(prompt)
+}
+
+func TestComparisonQueryEngine(t *testing.T) {
+	nodeWithScore := func(text string) schema.NodeWithScore {
+		return schema.NodeWithScore{Node: *schema.NewTextNode(text), Score: 1}
+	}
+	sets := []ComparisonSet{
+		{Label: "2023", Retriever: &MockRetriever{Nodes: []schema.NodeWithScore{nodeWithScore("Revenue was $10M. Headcount 50.")}}},
+		{Label: "2024", Retriever: &MockRetriever{Nodes: []schema.NodeWithScore{nodeWithScore("Revenue was $14M. Headcount 50.")}}},
+	}
+
+	var mu sync.Mutex
+	var comparePrompt string
+	model := &funcLLM{MockLLM: llm.NewMockLLM(""), respond: func(prompt string) (string, error) {
+		switch {
+		case strings.HasPrefix(prompt, "Compare the document sets"):
+			mu.Lock()
+			comparePrompt = prompt
+			mu.Unlock()
+			return "```json\n" + `{"summary": "Revenue grew.", "similarities": ["Headcount is 50"],
+				"differences": [{"aspect": "Revenue", "values": {"2023": "$10M", "2024": "$14M"}}]}` + "\n```", nil
+		case strings.Contains(prompt, `"2023"`):
+			return "2023 revenue $10M", nil
+		default:
+			return "2024 revenue $14M", nil
+		}
+	}}
+
+	engine := NewComparisonQueryEngine(model, sets)
+	resp, err := engine.Query(context.Background(), "How did revenue change?")
+	require.NoError(t, err)
+
+	comparison := resp.Metadata[ComparisonMetadataKey].(*Comparison)
+	assert.Equal(t, []string{"2023", "2024"}, comparison.Labels)
+	assert.Equal(t, []string{"Headcount is 50"}, comparison.Similarities)
+	require.Len(t, comparison.Differences, 1)
+	assert.Equal(t, "| Aspect | 2023 | 2024 |\n|---|---|---|\n| Revenue | $10M | $14M |", comparison.Table())
+	assert.Contains(t, resp.Response, "Revenue grew.")
+	assert.Contains(t, resp.Response, "| Revenue | $10M | $14M |")
+
+	assert.Contains(t, comparePrompt, "2023 revenue $10M")
+	assert.Contains(t, comparePrompt, "2024 revenue $14M")
+	assert.Equal(t, map[string]string{"2023": "2023 revenue $10M", "2024": "2024 revenue $14M"},
+		resp.Metadata[ComparisonFindingsMetadataKey])
+
+	require.Len(t, resp.SourceNodes, 2)
+	assert.Equal(t, "2023", resp.SourceNodes[0].Node.Metadata[ComparisonSetMetadataKey])
+	assert.Equal(t, "2024", resp.SourceNodes[1].Node.Metadata[ComparisonSetMetadataKey])
+	assert.Nil(t, sets[0].Retriever.(*MockRetriever).Nodes[0].Node.Metadata[ComparisonSetMetadataKey])
+
+	t.Run("needs two sets", func(t *testing.T) {
+		_, err := NewComparisonQueryEngine(model, sets[:1]).Query(context.Background(), "q")
+		assert.Error(t, err)
+	})
+
+	t.Run("retriever error", func(t *testing.T) {
+		broken := []ComparisonSet{sets[0], {Label: "bad", Retriever: &MockRetriever{Err: errors.New("down")}}}
+		_, err := NewComparisonQueryEngine(model, broken).Query(context.Background(), "q")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `"bad"`)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		plain := &funcLLM{MockLLM: llm.NewMockLLM(""), respond: func(string) (string, error) { return "no json here", nil }}
+		_, err := NewComparisonQueryEngine(plain, sets).Query(context.Background(), "q")
+		assert.Error(t, err)
+	})
+}
+
+// mapEmbedding embeds queries from a fixed table.
+type mapEmbedding struct {
+	*embedding.MockEmbeddingModel
+	vectors map[string][]float64
+}
+
+func (m *mapEmbedding) GetQueryEmbedding(ctx context.Context, query string) ([]float64, error) {
+	if v, ok := m.vectors[query]; ok {
+		return v, nil
+	}
+	return []float64{0, 0, 1}, nil
+}
+
+// keywordEmbedding embeds text as counts of a fixed vocabulary.
+type keywordEmbedding struct {
+	words     []string
+	textCalls int
+}
+
+func (m *keywordEmbedding) embed(text string) []float64 {
+	text = strings.ToLower(text)
+	v := make([]float64, len(m.words)+1)
+	v[len(m.words)] = 0.1
+	for i, w := range m.words {
+		v[i] = float64(strings.Count(text, w))
+	}
+	return v
+}
+
+func (m *keywordEmbedding) GetTextEmbedding(ctx context.Context, text string) ([]float64, error) {
+	m.textCalls++
+	return m.embed(text), nil
+}
+
+func (m *keywordEmbedding) GetQueryEmbedding(ctx context.Context, query string) ([]float64, error) {
+	return m.embed(query), nil
+}
+
+// hashDocStore is a minimal ingestion.DocStoreInterface.
+type hashDocStore struct {
+	hashes map[string]string
+}
+
+func (s *hashDocStore) GetDocumentHash(docID string) (string, bool) {
+	h, ok := s.hashes[docID]
+	return h, ok
+}
+func (s *hashDocStore) SetDocumentHash(docID, hash string)      { s.hashes[docID] = hash }
+func (s *hashDocStore) GetAllDocumentHashes() map[string]string { return s.hashes }
+func (s *hashDocStore) AddDocuments(nodes []schema.Node) error {
+	for _, n := range nodes {
+		s.hashes[n.ID] = n.GetHash()
+	}
+	return nil
+}
+func (s *hashDocStore) DeleteDocument(docID string) error { delete(s.hashes, docID); return nil }
+func (s *hashDocStore) DeleteRefDoc(refDocID string) error {
+	delete(s.hashes, refDocID)
+	return nil
+}
+
+func citingResponse(text string, refDocIDs ...string) *synthesizer.Response {
+	var sources []schema.NodeWithScore
+	for i, id := range refDocIDs {
+		node := schema.NewTextNode(text)
+		node.ID = fmt.Sprintf("%s-chunk-%d", id, i)
+		node.Relationships.SetSource(schema.RelatedNodeInfo{NodeID: id})
+		sources = append(sources, schema.NodeWithScore{Node: *node, Score: 1})
+	}
+	return synthesizer.NewResponse(text, sources)
+}
+
+func TestResponseCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("exact hit", func(t *testing.T) {
+		engine := &MockQueryEngine{Response: citingResponse("42", "doc1")}
+		cached := NewCachedQueryEngine(engine, NewResponseCache())
+
+		resp, err := cached.Query(ctx, "What is the answer?")
+		require.NoError(t, err)
+		assert.Nil(t, resp.Metadata[CacheHitMetadataKey])
+
+		resp, err = cached.Query(ctx, "  what is the   ANSWER? ")
+		require.NoError(t, err)
+		assert.Equal(t, "42", resp.Response)
+		assert.Equal(t, true, resp.Metadata[CacheHitMetadataKey])
+		assert.Equal(t, "What is the answer?", resp.Metadata[CacheQueryMetadataKey])
+		assert.Len(t, resp.SourceNodes, 1)
+		assert.Equal(t, 1, engine.CallCount)
+	})
+
+	t.Run("semantic hit", func(t *testing.T) {
+		embed := &mapEmbedding{
+			MockEmbeddingModel: embedding.NewMockEmbeddingModel(nil),
+			vectors: map[string][]float64{
+				"How much does Acme cost?": {1, 0, 0},
+				"What is Acme's price?":    {0.98, 0.2, 0},
+				"Who founded Acme?":        {0, 1, 0},
+			},
+		}
+		cache := NewResponseCache(WithResponseCacheEmbedding(embed, 0.95))
+		engine := &MockQueryEngine{Response: citingResponse("$10", "pricing")}
+		cached := NewCachedQueryEngine(engine, cache)
+
+		_, err := cached.Query(ctx, "How much does Acme cost?")
+		require.NoError(t, err)
+
+		resp, err := cached.Query(ctx, "What is Acme's price?")
+		require.NoError(t, err)
+		assert.Equal(t, true, resp.Metadata[CacheHitMetadataKey])
+		assert.Greater(t, resp.Metadata[CacheSimilarityMetadataKey], 0.95)
+		assert.Equal(t, 1, engine.CallCount)
+
+		_, err = cached.Query(ctx, "Who founded Acme?")
+		require.NoError(t, err)
+		assert.Equal(t, 2, engine.CallCount)
+	})
+
+	t.Run("invalidate ref docs", func(t *testing.T) {
+		cache := NewResponseCache()
+		require.NoError(t, cache.Put(ctx, "q1", citingResponse("a1", "doc1")))
+		require.NoError(t, cache.Put(ctx, "q2", citingResponse("a2", "doc1", "doc2")))
+		require.NoError(t, cache.Put(ctx, "q3", citingResponse("a3", "doc3")))
+
+		assert.Equal(t, 2, cache.InvalidateRefDocs("doc1"))
+		assert.Equal(t, 1, cache.Len())
+		_, ok, err := cache.Get(ctx, "q2")
+		require.NoError(t, err)
+		assert.False(t, ok)
+		_, ok, _ = cache.Get(ctx, "q3")
+		assert.True(t, ok)
+
+		assert.Equal(t, 0, cache.InvalidateRefDocs("doc2"))
+	})
+
+	t.Run("ref docs from docstore", func(t *testing.T) {
+		ds := docstore.NewSimpleDocumentStore()
+		chunk := schema.NewTextNode("chunk")
+		chunk.ID = "chunk1"
+		chunk.Relationships.SetSource(schema.RelatedNodeInfo{NodeID: "doc1"})
+		require.NoError(t, ds.AddDocuments(ctx, []schema.BaseNode{chunk}, true))
+
+		// Retrieved nodes often come back from a vector store without
+		// relationships.
+		bare := schema.NewTextNode("chunk")
+		bare.ID = "chunk1"
+		cache := NewResponseCache(WithResponseCacheDocStore(ds))
+		require.NoError(t, cache.Put(ctx, "q", synthesizer.NewResponse("a", []schema.NodeWithScore{{Node: *bare}})))
+
+		assert.Equal(t, 1, cache.InvalidateRefDocs("doc1"))
+	})
+
+	t.Run("eviction and ttl", func(t *testing.T) {
+		cache := NewResponseCache(WithResponseCacheMaxEntries(2), WithResponseCacheTTL(time.Minute))
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+
+		require.NoError(t, cache.Put(ctx, "q1", citingResponse("a1", "doc1")))
+		require.NoError(t, cache.Put(ctx, "q2", citingResponse("a2", "doc2")))
+		require.NoError(t, cache.Put(ctx, "q3", citingResponse("a3", "doc3")))
+		assert.Equal(t, 2, cache.Len())
+		_, ok, _ := cache.Get(ctx, "q1")
+		assert.False(t, ok)
+
+		now = now.Add(2 * time.Minute)
+		_, ok, _ = cache.Get(ctx, "q3")
+		assert.False(t, ok)
+
+		cache.Clear()
+		assert.Equal(t, 0, cache.Len())
+	})
+
+	t.Run("ingestion invalidates", func(t *testing.T) {
+		cache := NewResponseCache()
+		pipeline := ingestion.NewIngestionPipeline(
+			ingestion.WithDocstore(&hashDocStore{hashes: map[string]string{}}),
+			ingestion.WithDocstoreStrategy(ingestion.DocstoreStrategyUpsertsAndDelete),
+			ingestion.WithRefDocListener(cache),
+			ingestion.WithDisableCache(true),
+		)
+		docs := []schema.Document{
+			{ID: "pricing", Text: "Acme costs $10."},
+			{ID: "history", Text: "Acme was founded in 1999."},
+			{ID: "team", Text: "Acme has 20 staff."},
+		}
+		_, err := pipeline.Run(ctx, docs, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, cache.Put(ctx, "price?", citingResponse("$10", "pricing")))
+		require.NoError(t, cache.Put(ctx, "founded?", citingResponse("1999", "history")))
+		require.NoError(t, cache.Put(ctx, "staff?", citingResponse("20", "team")))
+
+		// Re-ingesting unchanged documents keeps the cache.
+		_, err = pipeline.Run(ctx, docs, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 3, cache.Len())
+
+		// Updating pricing and dropping team invalidates their answers.
+		_, err = pipeline.Run(ctx, []schema.Document{
+			{ID: "pricing", Text: "Acme costs $12."},
+			{ID: "history", Text: "Acme was founded in 1999."},
+		}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 1, cache.Len())
+		_, ok, _ := cache.Get(ctx, "founded?")
+		assert.True(t, ok)
+	})
+}
+
+func TestCacheWarmer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("query log", func(t *testing.T) {
+		log := NewInMemoryQueryLog()
+		for _, q := range []string{"Pricing?", "Who founded Acme?", "pricing? ", "Staff?", "PRICING?", "Staff?"} {
+			log.Record(q)
+		}
+		log.Record("   ")
+
+		assert.Equal(t, 3, log.Len())
+		top, err := log.TopQueries(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Pricing?", "Staff?"}, top)
+	})
+
+	t.Run("fills response cache", func(t *testing.T) {
+		log := NewInMemoryQueryLog()
+		engine := &MockQueryEngine{Response: citingResponse("42", "doc1")}
+		logged := NewLoggingQueryEngine(engine, log)
+		for _, q := range []string{"q1", "q2", "q1", "q3", "q3", "q3"} {
+			_, err := logged.Query(ctx, q)
+			require.NoError(t, err)
+		}
+
+		// After a deploy the cache starts out empty.
+		cache := NewResponseCache()
+		engine.CallCount = 0
+		warmer := NewCacheWarmer(log,
+			WithCacheWarmerEngine(NewCachedQueryEngine(engine, cache)),
+			WithCacheWarmerTopN(2),
+			WithCacheWarmerConcurrency(1),
+		)
+		result, err := warmer.Run(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Queries)
+		assert.Equal(t, 0, result.Failed)
+		assert.Equal(t, 2, engine.CallCount)
+		assert.Equal(t, 2, cache.Len())
+
+		_, ok, err := cache.Get(ctx, "q3")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		_, ok, _ = cache.Get(ctx, "q2")
+		assert.False(t, ok)
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		queries := QueryLogFunc(func(ctx context.Context, n int) ([]string, error) {
+			return []string{"a", "b"}, nil
+		})
+		failing := NewCacheWarmer(queries, WithCacheWarmerRetriever(&MockRetriever{Err: errors.New("index not loaded")}))
+
+		result, err := failing.Run(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Failed)
+		assert.Len(t, result.Errors, 2)
+
+		err = failing.Warmup(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "index not loaded")
+
+		ok := NewCacheWarmer(queries, WithCacheWarmerRetriever(&MockRetriever{Nodes: createTestNodes()}))
+		assert.NoError(t, ok.Warmup(ctx))
+
+		broken := NewCacheWarmer(QueryLogFunc(func(ctx context.Context, n int) ([]string, error) {
+			return nil, errors.New("log unavailable")
+		}))
+		assert.ErrorContains(t, broken.Warmup(ctx), "log unavailable")
+	})
+}
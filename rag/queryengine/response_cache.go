@@ -0,0 +1,363 @@
+package queryengine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/storage/docstore"
+)
+
+// Metadata keys set on responses served by CachedQueryEngine.
+const (
+	// CacheHitMetadataKey is true when the response came from the cache.
+	CacheHitMetadataKey = "cache_hit"
+	// CacheQueryMetadataKey holds the cached query a hit matched.
+	CacheQueryMetadataKey = "cache_query"
+	// CacheSimilarityMetadataKey holds the similarity of a semantic hit.
+	CacheSimilarityMetadataKey = "cache_similarity"
+)
+
+// ResponseCache caches answers by query. With an embedding model it also
+// serves semantically similar queries; without one it matches normalized
+// query text only.
+//
+// Each entry remembers the reference documents its answer cited. When a
+// document changes, InvalidateRefDocs drops every answer that cited it.
+// Pass the cache to ingestion.WithRefDocListener so that documents updated
+// or deleted by the ingestion pipeline invalidate answers automatically.
+type ResponseCache struct {
+	embedModel embedding.EmbeddingModel
+	threshold  float64
+	maxEntries int
+	ttl        time.Duration
+	docStore   docstore.DocStore
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*responseCacheEntry
+	byDoc   map[string]map[string]bool
+	order   []string
+}
+
+type responseCacheEntry struct {
+	key       string
+	query     string
+	embedding []float64
+	response  *synthesizer.Response
+	refDocs   []string
+	createdAt time.Time
+}
+
+// ResponseCacheOption is a functional option for ResponseCache.
+type ResponseCacheOption func(*ResponseCache)
+
+// WithResponseCacheEmbedding enables semantic lookup: a query hits an entry
+// whose query embedding has cosine similarity of at least threshold.
+func WithResponseCacheEmbedding(model embedding.EmbeddingModel, threshold float64) ResponseCacheOption {
+	return func(c *ResponseCache) {
+		c.embedModel = model
+		c.threshold = threshold
+	}
+}
+
+// WithResponseCacheMaxEntries bounds the cache; the oldest entries are
+// evicted first. Defaults to 1000. Zero means unbounded.
+func WithResponseCacheMaxEntries(n int) ResponseCacheOption {
+	return func(c *ResponseCache) {
+		c.maxEntries = n
+	}
+}
+
+// WithResponseCacheTTL expires entries after ttl. Zero means no expiry.
+func WithResponseCacheTTL(ttl time.Duration) ResponseCacheOption {
+	return func(c *ResponseCache) {
+		c.ttl = ttl
+	}
+}
+
+// WithResponseCacheDocStore resolves cited nodes to their reference
+// documents through the docstore's ref-doc tracking, for nodes that carry
+// no source relationship.
+func WithResponseCacheDocStore(ds docstore.DocStore) ResponseCacheOption {
+	return func(c *ResponseCache) {
+		c.docStore = ds
+	}
+}
+
+// NewResponseCache creates a new ResponseCache.
+func NewResponseCache(opts ...ResponseCacheOption) *ResponseCache {
+	c := &ResponseCache{
+		maxEntries: 1000,
+		now:        time.Now,
+		entries:    make(map[string]*responseCacheEntry),
+		byDoc:      make(map[string]map[string]bool),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Get returns the cached response for query, if any. The returned response
+// is a copy whose metadata marks it as a cache hit.
+func (c *ResponseCache) Get(ctx context.Context, query string) (*synthesizer.Response, bool, error) {
+	key := normalizeCacheQuery(query)
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && !c.expired(e) {
+		c.mu.Unlock()
+		return cachedResponse(e, 1), true, nil
+	}
+	semantic := c.embedModel != nil && len(c.entries) > 0
+	c.mu.Unlock()
+
+	if !semantic {
+		return nil, false, nil
+	}
+
+	queryEmbedding, err := c.embedModel.GetQueryEmbedding(ctx, query)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to embed query for cache lookup: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var best *responseCacheEntry
+	bestScore := c.threshold
+	for _, e := range c.entries {
+		if e.embedding == nil || c.expired(e) {
+			continue
+		}
+		score, err := embedding.CosineSimilarity(queryEmbedding, e.embedding)
+		if err != nil {
+			continue
+		}
+		if score >= bestScore {
+			best, bestScore = e, score
+		}
+	}
+	if best == nil {
+		return nil, false, nil
+	}
+	return cachedResponse(best, bestScore), true, nil
+}
+
+// Put caches response for query, recording the reference documents of its
+// source nodes.
+func (c *ResponseCache) Put(ctx context.Context, query string, response *synthesizer.Response) error {
+	var queryEmbedding []float64
+	if c.embedModel != nil {
+		var err error
+		queryEmbedding, err = c.embedModel.GetQueryEmbedding(ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to embed query for cache: %w", err)
+		}
+	}
+
+	refDocs, err := c.refDocIDs(ctx, response.SourceNodes)
+	if err != nil {
+		return err
+	}
+
+	e := &responseCacheEntry{
+		key:       normalizeCacheQuery(query),
+		query:     query,
+		embedding: queryEmbedding,
+		response:  response,
+		refDocs:   refDocs,
+		createdAt: c.now(),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removeLocked(e.key)
+	c.entries[e.key] = e
+	c.order = append(c.order, e.key)
+	for _, id := range refDocs {
+		if c.byDoc[id] == nil {
+			c.byDoc[id] = make(map[string]bool)
+		}
+		c.byDoc[id][e.key] = true
+	}
+
+	for c.maxEntries > 0 && len(c.entries) > c.maxEntries && len(c.order) > 0 {
+		c.removeLocked(c.order[0])
+	}
+	return nil
+}
+
+// InvalidateRefDocs drops every cached answer that cited one of the
+// reference documents and returns the number dropped.
+func (c *ResponseCache) InvalidateRefDocs(refDocIDs ...string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for _, id := range refDocIDs {
+		for key := range c.byDoc[id] {
+			if c.removeLocked(key) {
+				removed++
+			}
+		}
+	}
+	return removed
+}
+
+// RefDocsChanged invalidates answers citing the changed documents. It lets
+// the cache act as an ingestion.RefDocListener.
+func (c *ResponseCache) RefDocsChanged(ctx context.Context, refDocIDs []string) {
+	c.InvalidateRefDocs(refDocIDs...)
+}
+
+// Len returns the number of cached answers.
+func (c *ResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Clear drops all cached answers.
+func (c *ResponseCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*responseCacheEntry)
+	c.byDoc = make(map[string]map[string]bool)
+	c.order = nil
+}
+
+// refDocIDs returns the reference documents of the nodes: the source
+// relationship when set, else the docstore's ref-doc tracking, else the
+// node ID itself.
+func (c *ResponseCache) refDocIDs(ctx context.Context, nodes []schema.NodeWithScore) ([]string, error) {
+	var nodeToRef map[string]string
+	seen := make(map[string]bool)
+	var ids []string
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for _, n := range nodes {
+		if source := n.Node.Relationships.GetSource(); source != nil && source.NodeID != "" {
+			add(source.NodeID)
+			continue
+		}
+		if c.docStore != nil {
+			if nodeToRef == nil {
+				infos, err := c.docStore.GetAllRefDocInfo(ctx)
+				if err != nil {
+					return nil, fmt.Errorf("failed to load ref doc info: %w", err)
+				}
+				nodeToRef = make(map[string]string)
+				for refID, info := range infos {
+					for _, nodeID := range info.NodeIDs {
+						nodeToRef[nodeID] = refID
+					}
+				}
+			}
+			if refID, ok := nodeToRef[n.Node.ID]; ok {
+				add(refID)
+				continue
+			}
+		}
+		add(n.Node.ID)
+	}
+	return ids, nil
+}
+
+// removeLocked drops an entry. The caller holds the lock.
+func (c *ResponseCache) removeLocked(key string) bool {
+	e, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	delete(c.entries, key)
+	for _, id := range e.refDocs {
+		delete(c.byDoc[id], key)
+		if len(c.byDoc[id]) == 0 {
+			delete(c.byDoc, id)
+		}
+	}
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+func (c *ResponseCache) expired(e *responseCacheEntry) bool {
+	return c.ttl > 0 && c.now().Sub(e.createdAt) > c.ttl
+}
+
+// cachedResponse copies an entry's response and marks it as a cache hit.
+func cachedResponse(e *responseCacheEntry, similarity float64) *synthesizer.Response {
+	metadata := make(map[string]interface{}, len(e.response.Metadata)+3)
+	for k, v := range e.response.Metadata {
+		metadata[k] = v
+	}
+	metadata[CacheHitMetadataKey] = true
+	metadata[CacheQueryMetadataKey] = e.query
+	metadata[CacheSimilarityMetadataKey] = similarity
+
+	sources := make([]schema.NodeWithScore, len(e.response.SourceNodes))
+	copy(sources, e.response.SourceNodes)
+	return synthesizer.NewResponseWithMetadata(e.response.Response, sources, metadata)
+}
+
+func normalizeCacheQuery(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+// CachedQueryEngine answers from a ResponseCache when it can and caches the
+// answers of the wrapped engine otherwise.
+type CachedQueryEngine struct {
+	*BaseQueryEngine
+	// Engine answers cache misses.
+	Engine QueryEngine
+	// Cache stores the answers.
+	Cache *ResponseCache
+}
+
+// NewCachedQueryEngine wraps engine with cache.
+func NewCachedQueryEngine(engine QueryEngine, cache *ResponseCache) *CachedQueryEngine {
+	return &CachedQueryEngine{
+		BaseQueryEngine: NewBaseQueryEngine(),
+		Engine:          engine,
+		Cache:           cache,
+	}
+}
+
+// Query returns the cached answer for query or asks the wrapped engine.
+func (e *CachedQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	if resp, ok, err := e.Cache.Get(ctx, query); err != nil {
+		return nil, err
+	} else if ok {
+		return resp, nil
+	}
+
+	resp, err := e.Engine.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.Cache.Put(ctx, query, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Ensure CachedQueryEngine implements QueryEngine.
+var _ QueryEngine = (*CachedQueryEngine)(nil)
@@ -2,11 +2,42 @@ package queryengine
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/aqua777/go-llamaindex/evaluation"
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
 	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
 )
 
+// Metadata keys set on responses produced by the evaluator-guided retry
+// engines.
+const (
+	// RetryAttemptsMetadataKey holds the number of retries made, as an int.
+	RetryAttemptsMetadataKey = "retry_attempts"
+	// RetryEvaluationMetadataKey holds the *evaluation.EvaluationResult of
+	// the returned response.
+	RetryEvaluationMetadataKey = "retry_evaluation"
+	// RetryQueriesMetadataKey holds every query tried by
+	// RetryGuidelineQueryEngine, in order, as a []string.
+	RetryQueriesMetadataKey = "retry_queries"
+)
+
+// Default prompt for rewriting a query from guideline feedback.
+const defaultQueryRewritePrompt = `Your task is to rewrite a query so that the answer to it follows the given guidelines.
+A previous answer to the query did not follow the guidelines; feedback on it is given below.
+
+Query: {query_str}
+Previous answer: {response_str}
+Feedback: {feedback}
+Guidelines: {guidelines}
+
+Write only the rewritten query.
+Rewritten query:`
+
 // RetryQueryEngine retries queries on failure.
 type RetryQueryEngine struct {
 	*BaseQueryEngine
@@ -84,5 +115,244 @@ func (rqe *RetryQueryEngine) Query(ctx context.Context, query string) (*synthesi
 	return nil, lastErr
 }
 
-// Ensure RetryQueryEngine implements QueryEngine.
-var _ QueryEngine = (*RetryQueryEngine)(nil)
+// RetrySourceQueryEngine retries queries whose response fails evaluation by
+// dropping unhelpful source nodes. Each source node is evaluated on its own
+// against the response; the nodes that fail are removed and the answer is
+// synthesized again from the remaining ones, up to MaxRetries times.
+type RetrySourceQueryEngine struct {
+	*BaseQueryEngine
+	// QueryEngine produces the initial response.
+	QueryEngine QueryEngine
+	// Evaluator judges responses and individual source nodes.
+	Evaluator evaluation.Evaluator
+	// Synthesizer answers again from the filtered source nodes.
+	Synthesizer synthesizer.Synthesizer
+	// MaxRetries is the maximum number of retries.
+	MaxRetries int
+}
+
+// RetrySourceQueryEngineOption is a functional option.
+type RetrySourceQueryEngineOption func(*RetrySourceQueryEngine)
+
+// WithRetrySourceMaxRetries sets the maximum number of retries.
+func WithRetrySourceMaxRetries(maxRetries int) RetrySourceQueryEngineOption {
+	return func(rse *RetrySourceQueryEngine) {
+		rse.MaxRetries = maxRetries
+	}
+}
+
+// NewRetrySourceQueryEngine creates a new RetrySourceQueryEngine.
+func NewRetrySourceQueryEngine(
+	engine QueryEngine,
+	evaluator evaluation.Evaluator,
+	synth synthesizer.Synthesizer,
+	opts ...RetrySourceQueryEngineOption,
+) *RetrySourceQueryEngine {
+	rse := &RetrySourceQueryEngine{
+		BaseQueryEngine: NewBaseQueryEngine(),
+		QueryEngine:     engine,
+		Evaluator:       evaluator,
+		Synthesizer:     synth,
+		MaxRetries:      3,
+	}
+
+	for _, opt := range opts {
+		opt(rse)
+	}
+
+	return rse
+}
+
+// Query executes a query, filtering sources and answering again while the
+// response fails evaluation.
+func (rse *RetrySourceQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	response, err := rse.QueryEngine.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := 0
+	for {
+		result, err := evaluation.EvaluateResponse(ctx, rse.Evaluator, query, response.Response, response.SourceNodes)
+		if err != nil {
+			return nil, fmt.Errorf("evaluator %s failed: %w", rse.Evaluator.Name(), err)
+		}
+		if result.IsPassing() || attempts >= rse.MaxRetries {
+			return rse.annotate(response, attempts, result), nil
+		}
+
+		kept, err := rse.filterSources(ctx, query, response)
+		if err != nil {
+			return nil, err
+		}
+		// Nothing to answer from, or nothing removed: another attempt would
+		// give the same response.
+		if len(kept) == 0 || len(kept) == len(response.SourceNodes) {
+			return rse.annotate(response, attempts, result), nil
+		}
+
+		attempts++
+		if rse.Verbose {
+			fmt.Printf("Retry %d with %d of %d sources\n", attempts, len(kept), len(response.SourceNodes))
+		}
+
+		response, err = rse.Synthesizer.Synthesize(ctx, query, kept)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// filterSources returns the source nodes that pass evaluation on their own.
+func (rse *RetrySourceQueryEngine) filterSources(ctx context.Context, query string, response *synthesizer.Response) ([]schema.NodeWithScore, error) {
+	var kept []schema.NodeWithScore
+	for _, n := range response.SourceNodes {
+		result, err := evaluation.EvaluateResponse(ctx, rse.Evaluator, query, response.Response, []schema.NodeWithScore{n})
+		if err != nil {
+			return nil, fmt.Errorf("evaluator %s failed: %w", rse.Evaluator.Name(), err)
+		}
+		if result.IsPassing() {
+			kept = append(kept, n)
+		}
+	}
+	return kept, nil
+}
+
+func (rse *RetrySourceQueryEngine) annotate(response *synthesizer.Response, attempts int, result *evaluation.EvaluationResult) *synthesizer.Response {
+	return withMetadata(response, map[string]interface{}{
+		RetryAttemptsMetadataKey:   attempts,
+		RetryEvaluationMetadataKey: result,
+	})
+}
+
+// RetryGuidelineQueryEngine retries queries whose response fails evaluation
+// by rewriting the query with the evaluator's feedback. With an LLM the
+// query is rewritten to steer the answer towards the guidelines; without
+// one the previous answer and feedback are appended to the query.
+type RetryGuidelineQueryEngine struct {
+	*BaseQueryEngine
+	// QueryEngine answers the query.
+	QueryEngine QueryEngine
+	// Evaluator judges responses, typically an evaluation.GuidelineEvaluator.
+	Evaluator evaluation.Evaluator
+	// LLM rewrites the query. When nil, feedback is appended instead.
+	LLM llm.LLM
+	// RewritePrompt is the template used to rewrite the query.
+	RewritePrompt prompts.BasePromptTemplate
+	// MaxRetries is the maximum number of retries.
+	MaxRetries int
+}
+
+// RetryGuidelineQueryEngineOption is a functional option.
+type RetryGuidelineQueryEngineOption func(*RetryGuidelineQueryEngine)
+
+// WithRetryGuidelineMaxRetries sets the maximum number of retries.
+func WithRetryGuidelineMaxRetries(maxRetries int) RetryGuidelineQueryEngineOption {
+	return func(rge *RetryGuidelineQueryEngine) {
+		rge.MaxRetries = maxRetries
+	}
+}
+
+// WithQueryRewriteLLM sets the LLM that rewrites the query from feedback.
+func WithQueryRewriteLLM(llmModel llm.LLM) RetryGuidelineQueryEngineOption {
+	return func(rge *RetryGuidelineQueryEngine) {
+		rge.LLM = llmModel
+	}
+}
+
+// WithQueryRewritePrompt sets the query rewrite prompt template.
+func WithQueryRewritePrompt(prompt prompts.BasePromptTemplate) RetryGuidelineQueryEngineOption {
+	return func(rge *RetryGuidelineQueryEngine) {
+		rge.RewritePrompt = prompt
+	}
+}
+
+// NewRetryGuidelineQueryEngine creates a new RetryGuidelineQueryEngine.
+func NewRetryGuidelineQueryEngine(engine QueryEngine, evaluator evaluation.Evaluator, opts ...RetryGuidelineQueryEngineOption) *RetryGuidelineQueryEngine {
+	rge := &RetryGuidelineQueryEngine{
+		BaseQueryEngine: NewBaseQueryEngine(),
+		QueryEngine:     engine,
+		Evaluator:       evaluator,
+		RewritePrompt:   prompts.NewPromptTemplate(defaultQueryRewritePrompt, prompts.PromptTypeCustom),
+		MaxRetries:      3,
+	}
+
+	for _, opt := range opts {
+		opt(rge)
+	}
+
+	rge.SetPrompt("query_rewrite_prompt", rge.RewritePrompt)
+
+	return rge
+}
+
+// Query executes a query, rewriting it while the response fails evaluation.
+// The response is evaluated against the original query.
+func (rge *RetryGuidelineQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	current := query
+	queries := []string{query}
+
+	for attempts := 0; ; attempts++ {
+		response, err := rge.QueryEngine.Query(ctx, current)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := evaluation.EvaluateResponse(ctx, rge.Evaluator, query, response.Response, response.SourceNodes)
+		if err != nil {
+			return nil, fmt.Errorf("evaluator %s failed: %w", rge.Evaluator.Name(), err)
+		}
+		if result.IsPassing() || attempts >= rge.MaxRetries {
+			return withMetadata(response, map[string]interface{}{
+				RetryAttemptsMetadataKey:   attempts,
+				RetryEvaluationMetadataKey: result,
+				RetryQueriesMetadataKey:    queries,
+			}), nil
+		}
+
+		current, err = rge.rewriteQuery(ctx, query, response.Response, result.Feedback)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, current)
+		if rge.Verbose {
+			fmt.Printf("Retry %d with query: %s\n", attempts+1, current)
+		}
+	}
+}
+
+// rewriteQuery builds the next query from the evaluation feedback.
+func (rge *RetryGuidelineQueryEngine) rewriteQuery(ctx context.Context, query, response, feedback string) (string, error) {
+	if rge.LLM == nil {
+		return fmt.Sprintf("%s\nHere is a previous bad answer.\n%s\nHere is some feedback on the previous answer.\n%s\nNow answer the question.",
+			query, response, feedback), nil
+	}
+
+	guidelines := ""
+	if g, ok := rge.Evaluator.(interface{ Guidelines() string }); ok {
+		guidelines = g.Guidelines()
+	}
+	prompt := rge.RewritePrompt.Format(map[string]string{
+		"query_str":    query,
+		"response_str": response,
+		"feedback":     feedback,
+		"guidelines":   guidelines,
+	})
+
+	rewritten, err := rge.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to rewrite query: %w", err)
+	}
+	rewritten = strings.TrimSpace(rewritten)
+	if rewritten == "" {
+		return query, nil
+	}
+	return rewritten, nil
+}
+
+// Ensure the retry engines implement QueryEngine.
+var (
+	_ QueryEngine = (*RetryQueryEngine)(nil)
+	_ QueryEngine = (*RetrySourceQueryEngine)(nil)
+	_ QueryEngine = (*RetryGuidelineQueryEngine)(nil)
+)
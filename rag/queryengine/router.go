@@ -3,12 +3,23 @@ package queryengine
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/aqua777/go-llamaindex/rag/synthesizer"
 	"github.com/aqua777/go-llamaindex/schema"
 )
 
+// Metadata keys set on responses produced by routing query engines.
+const (
+	// SelectedEnginesMetadataKey holds the names of the query engines that
+	// handled the query, as a []string.
+	SelectedEnginesMetadataKey = "selected_engines"
+	// SelectorReasonsMetadataKey holds the selector's reason for each
+	// selected engine, as a []string aligned with SelectedEnginesMetadataKey.
+	SelectorReasonsMetadataKey = "selector_reasons"
+)
+
 // QueryEngineSelector selects which query engine(s) to use.
 type QueryEngineSelector interface {
 	// Select chooses query engines based on their metadata and the query.
@@ -61,7 +72,8 @@ type RouterQueryEngine struct {
 	Selector QueryEngineSelector
 	// Tools are the available query engine tools.
 	Tools []*QueryEngineTool
-	// Summarizer combines responses from multiple engines.
+	// Summarizer combines responses from multiple engines. When nil, the
+	// responses are concatenated.
 	Summarizer synthesizer.Synthesizer
 }
 
@@ -119,8 +131,9 @@ func (rqe *RouterQueryEngine) Query(ctx context.Context, query string) (*synthes
 	// Query selected engines
 	var responses []*synthesizer.Response
 	var allSourceNodes []schema.NodeWithScore
+	var selected, reasons []string
 
-	for _, idx := range result.Indices {
+	for i, idx := range result.Indices {
 		if idx < 0 || idx >= len(rqe.Tools) {
 			continue
 		}
@@ -128,20 +141,39 @@ func (rqe *RouterQueryEngine) Query(ctx context.Context, query string) (*synthes
 		tool := rqe.Tools[idx]
 		resp, err := tool.QueryEngine.Query(ctx, query)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("query engine %s failed: %w", tool.Name, err)
 		}
 
 		responses = append(responses, resp)
 		allSourceNodes = append(allSourceNodes, resp.SourceNodes...)
+		selected = append(selected, tool.Name)
+		reason := ""
+		if i < len(result.Reasons) {
+			reason = result.Reasons[i]
+		}
+		reasons = append(reasons, reason)
+	}
+
+	if len(responses) == 0 {
+		return nil, errors.New("selector chose no valid query engine")
+	}
+
+	routing := map[string]interface{}{
+		SelectedEnginesMetadataKey: selected,
+		SelectorReasonsMetadataKey: reasons,
 	}
 
 	// Combine responses
 	if len(responses) == 1 {
-		return responses[0], nil
+		return withMetadata(responses[0], routing), nil
 	}
 
 	// Multiple responses - combine them
-	return rqe.combineResponses(ctx, query, responses, allSourceNodes)
+	combined, err := rqe.combineResponses(ctx, query, responses, allSourceNodes)
+	if err != nil {
+		return nil, err
+	}
+	return withMetadata(combined, routing), nil
 }
 
 // combineResponses combines multiple responses into one.
@@ -0,0 +1,300 @@
+package queryengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/selector"
+)
+
+// VectorQueryMetadataKey holds the question SQLAutoVectorQueryEngine sent to
+// the vector engine.
+const VectorQueryMetadataKey = "vector_query"
+
+// Default prompts for combining SQL and vector results.
+const (
+	defaultSQLAugmentPrompt = `The original question is given below.
+This question has been translated into a SQL query. Both the SQL query and the response are given below.
+The SQL response either answers the question, or should provide additional context that can be used to make the question more specific.
+Your job is to come up with a more specific question that needs to be answered to fully answer the original question, or 'None' if the original question has already been fully answered from the SQL response. Do not create a new question that is irrelevant to the original question; in that case return None instead.
+
+Examples:
+
+Original question: Please give more details about the demographics of the city with the highest population.
+SQL query: SELECT city, population FROM cities ORDER BY population DESC LIMIT 1
+SQL response: The city with the highest population is New York City.
+New question: Can you tell me more about the demographics of New York City?
+
+Original question: Please compare the sports environment of cities in North America.
+SQL query: SELECT city_name FROM cities WHERE country = 'United States' LIMIT 3
+SQL response: The cities in North America are New York, San Francisco, and Toronto.
+New question: What sports teams are based in New York, San Francisco, and Toronto?
+
+Original question: What is the city with the highest population?
+SQL query: SELECT city, population FROM cities ORDER BY population DESC LIMIT 1
+SQL response: The city with the highest population is New York City.
+New question: None
+
+Original question: {query_str}
+SQL query: {sql_query_str}
+SQL response: {sql_response_str}
+New question: `
+
+	defaultSQLJoinSynthesisPrompt = `The original question is given below.
+This question has been translated into a SQL query. Both the SQL query and the response are given below.
+Given the SQL response, the question has also been transformed into a more detailed query, and executed against another query engine.
+The transformed query and query engine response are also given below.
+Given SQL query, SQL response, transformed query, and query engine response, please synthesize a response to the original question.
+
+Original question: {query_str}
+SQL query: {sql_query_str}
+SQL response: {sql_response_str}
+Transformed query: {query_engine_query_str}
+Query engine response: {query_engine_response_str}
+Response: `
+)
+
+// SQLAutoVectorQueryEngine answers questions over structured and
+// unstructured data. A selector decides per query whether the SQL engine,
+// the vector engine, or both are needed. When SQL is used, the LLM turns
+// the SQL result into a more specific follow-up question for the vector
+// engine (or decides none is needed), and the final answer is synthesized
+// from both results. For example, "Tell me about the arts scene of the
+// most populous city" first finds the city with SQL and then asks the
+// vector index about that city.
+type SQLAutoVectorQueryEngine struct {
+	*BaseQueryEngine
+	// SQLTool wraps the text-to-SQL engine, e.g. a TableQueryEngine.
+	SQLTool *QueryEngineTool
+	// VectorTool wraps the engine over unstructured documents.
+	VectorTool *QueryEngineTool
+	// Selector chooses between the two tools.
+	Selector selector.Selector
+	// LLM writes follow-up questions and synthesizes the final answer.
+	LLM llm.LLM
+	// AugmentPrompt turns the SQL result into a follow-up question.
+	AugmentPrompt prompts.BasePromptTemplate
+	// SynthesisPrompt combines the SQL and vector results.
+	SynthesisPrompt prompts.BasePromptTemplate
+	// AugmentVectorQuery controls whether questions routed only to SQL are
+	// followed up in the vector engine.
+	AugmentVectorQuery bool
+}
+
+// SQLAutoVectorQueryEngineOption is a functional option.
+type SQLAutoVectorQueryEngineOption func(*SQLAutoVectorQueryEngine)
+
+// WithSQLAutoVectorSelector sets the selector. Defaults to an
+// LLMMultiSelector that may pick both tools.
+func WithSQLAutoVectorSelector(s selector.Selector) SQLAutoVectorQueryEngineOption {
+	return func(e *SQLAutoVectorQueryEngine) {
+		e.Selector = s
+	}
+}
+
+// WithSQLAugmentPrompt sets the follow-up question prompt.
+func WithSQLAugmentPrompt(prompt prompts.BasePromptTemplate) SQLAutoVectorQueryEngineOption {
+	return func(e *SQLAutoVectorQueryEngine) {
+		e.AugmentPrompt = prompt
+	}
+}
+
+// WithSQLJoinSynthesisPrompt sets the prompt combining both results.
+func WithSQLJoinSynthesisPrompt(prompt prompts.BasePromptTemplate) SQLAutoVectorQueryEngineOption {
+	return func(e *SQLAutoVectorQueryEngine) {
+		e.SynthesisPrompt = prompt
+	}
+}
+
+// WithSQLAugmentVectorQuery sets whether SQL-only questions are followed
+// up in the vector engine. Defaults to true.
+func WithSQLAugmentVectorQuery(augment bool) SQLAutoVectorQueryEngineOption {
+	return func(e *SQLAutoVectorQueryEngine) {
+		e.AugmentVectorQuery = augment
+	}
+}
+
+// WithSQLAutoVectorVerbose enables verbose logging.
+func WithSQLAutoVectorVerbose(verbose bool) SQLAutoVectorQueryEngineOption {
+	return func(e *SQLAutoVectorQueryEngine) {
+		e.Verbose = verbose
+	}
+}
+
+// NewSQLAutoVectorQueryEngine creates an engine routing between sqlTool
+// and vectorTool. The tool descriptions guide the selector, so they should
+// name the tables and the topics of the documents.
+func NewSQLAutoVectorQueryEngine(sqlTool, vectorTool *QueryEngineTool, llmModel llm.LLM, opts ...SQLAutoVectorQueryEngineOption) *SQLAutoVectorQueryEngine {
+	e := &SQLAutoVectorQueryEngine{
+		BaseQueryEngine:    NewBaseQueryEngine(),
+		SQLTool:            sqlTool,
+		VectorTool:         vectorTool,
+		LLM:                llmModel,
+		AugmentPrompt:      prompts.NewPromptTemplate(defaultSQLAugmentPrompt, prompts.PromptTypeCustom),
+		SynthesisPrompt:    prompts.NewPromptTemplate(defaultSQLJoinSynthesisPrompt, prompts.PromptTypeCustom),
+		AugmentVectorQuery: true,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	if e.Selector == nil {
+		e.Selector = selector.NewLLMMultiSelector(llmModel, selector.WithMaxOutputs(2))
+	}
+	e.SetPrompt("sql_augment_query_transform_prompt", e.AugmentPrompt)
+	e.SetPrompt("sql_join_synthesis_prompt", e.SynthesisPrompt)
+
+	return e
+}
+
+// Query routes the query, follows SQL results up in the vector engine when
+// needed and combines the answers.
+func (e *SQLAutoVectorQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	if e.SQLTool == nil || e.VectorTool == nil {
+		return nil, errors.New("both a SQL and a vector query engine are required")
+	}
+
+	useSQL, useVector, err := e.route(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if !useSQL {
+		resp, err := e.VectorTool.QueryEngine.Query(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		return withMetadata(resp, map[string]interface{}{
+			SelectedEnginesMetadataKey: []string{e.VectorTool.Name},
+			VectorQueryMetadataKey:     query,
+		}), nil
+	}
+
+	sqlResp, err := e.SQLTool.QueryEngine.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("SQL engine failed: %w", err)
+	}
+	sqlQuery, _ := sqlResp.Metadata[SQLQueryMetadataKey].(string)
+
+	vectorQuery := ""
+	if useVector || e.AugmentVectorQuery {
+		vectorQuery, err = e.followUpQuestion(ctx, query, sqlQuery, sqlResp.Response)
+		if err != nil {
+			return nil, err
+		}
+		if vectorQuery == "" && useVector {
+			vectorQuery = query
+		}
+	}
+	if e.Verbose {
+		fmt.Printf("SQL query: %s\nVector query: %s\n", sqlQuery, vectorQuery)
+	}
+	if vectorQuery == "" {
+		return withMetadata(sqlResp, map[string]interface{}{
+			SelectedEnginesMetadataKey: []string{e.SQLTool.Name},
+		}), nil
+	}
+
+	vectorResp, err := e.VectorTool.QueryEngine.Query(ctx, vectorQuery)
+	if err != nil {
+		return nil, fmt.Errorf("vector engine failed: %w", err)
+	}
+
+	prompt := e.SynthesisPrompt.Format(map[string]string{
+		"query_str":                 query,
+		"sql_query_str":             sqlQuery,
+		"sql_response_str":          sqlResp.Response,
+		"query_engine_query_str":    vectorQuery,
+		"query_engine_response_str": vectorResp.Response,
+	})
+	answer, err := e.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to synthesize response: %w", err)
+	}
+
+	sourceNodes := make([]schema.NodeWithScore, 0, len(sqlResp.SourceNodes)+len(vectorResp.SourceNodes))
+	sourceNodes = append(sourceNodes, sqlResp.SourceNodes...)
+	sourceNodes = append(sourceNodes, vectorResp.SourceNodes...)
+	metadata := map[string]interface{}{
+		SelectedEnginesMetadataKey: []string{e.SQLTool.Name, e.VectorTool.Name},
+		VectorQueryMetadataKey:     vectorQuery,
+	}
+	for _, key := range []string{SQLQueryMetadataKey, SQLResultMetadataKey} {
+		if v, ok := sqlResp.Metadata[key]; ok {
+			metadata[key] = v
+		}
+	}
+	return synthesizer.NewResponseWithMetadata(strings.TrimSpace(answer), sourceNodes, metadata), nil
+}
+
+// route asks the selector which tools the query needs.
+func (e *SQLAutoVectorQueryEngine) route(ctx context.Context, query string) (useSQL, useVector bool, err error) {
+	choices := []selector.ToolMetadata{
+		{Name: e.SQLTool.Name, Description: e.SQLTool.Description},
+		{Name: e.VectorTool.Name, Description: e.VectorTool.Description},
+	}
+	result, err := e.Selector.Select(ctx, choices, query)
+	if err != nil {
+		return false, false, fmt.Errorf("selector %s failed: %w", e.Selector.Name(), err)
+	}
+	for _, sel := range result.Selections {
+		switch sel.Index {
+		case 0:
+			useSQL = true
+		case 1:
+			useVector = true
+		}
+	}
+	if !useSQL && !useVector {
+		return false, false, errors.New("selector chose no valid query engine")
+	}
+	return useSQL, useVector, nil
+}
+
+// followUpQuestion asks the LLM for a vector question building on the SQL
+// result. It returns "" if the SQL result already answers the query.
+func (e *SQLAutoVectorQueryEngine) followUpQuestion(ctx context.Context, query, sqlQuery, sqlResponse string) (string, error) {
+	prompt := e.AugmentPrompt.Format(map[string]string{
+		"query_str":        query,
+		"sql_query_str":    sqlQuery,
+		"sql_response_str": sqlResponse,
+	})
+	response, err := e.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate follow-up question: %w", err)
+	}
+
+	question := strings.TrimSpace(response)
+	if i := strings.Index(question, "\n"); i != -1 {
+		question = strings.TrimSpace(question[:i])
+	}
+	if len(question) >= 13 && strings.EqualFold(question[:13], "new question:") {
+		question = strings.TrimSpace(question[13:])
+	}
+	if strings.EqualFold(strings.Trim(question, `"'.`), "none") {
+		return "", nil
+	}
+	return question, nil
+}
+
+// withMetadata returns a copy of resp with the keys added to its metadata.
+func withMetadata(resp *synthesizer.Response, extra map[string]interface{}) *synthesizer.Response {
+	metadata := make(map[string]interface{}, len(resp.Metadata)+len(extra))
+	for k, v := range resp.Metadata {
+		metadata[k] = v
+	}
+	for k, v := range extra {
+		metadata[k] = v
+	}
+	return synthesizer.NewResponseWithMetadata(resp.Response, resp.SourceNodes, metadata)
+}
+
+// Ensure SQLAutoVectorQueryEngine implements QueryEngine.
+var _ QueryEngine = (*SQLAutoVectorQueryEngine)(nil)
@@ -0,0 +1,340 @@
+package queryengine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/graphstore"
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// Metadata keys set on responses produced by TextToCypherEngine.
+const (
+	// CypherQueryMetadataKey holds the executed Cypher statement.
+	CypherQueryMetadataKey = "cypher_query"
+	// CypherResultMetadataKey holds the raw result returned by the graph store.
+	CypherResultMetadataKey = "cypher_result"
+)
+
+// Default prompts for text-to-Cypher.
+const (
+	defaultTextToCypherPrompt = `Task: Generate a Cypher statement to query a graph database.
+Instructions:
+Use only the node labels, relationship types and properties provided in the schema.
+Do not use any other relationship types or properties that are not provided.
+Generate a read-only query; never create, update or delete data.
+
+Schema:
+{schema}
+
+Do not include any explanations or apologies in your response.
+Do not respond to any questions that might ask anything other than constructing a Cypher statement.
+Do not include any text except the generated Cypher statement.
+
+The question is:
+{query_str}`
+
+	defaultCypherResponsePrompt = `Given an input question, synthesize a response from the query results.
+Query: {query_str}
+Cypher query: {cypher_query}
+Cypher response: {context_str}
+Response: `
+)
+
+// cypherWriteClause matches Cypher clauses that modify data or the
+// database.
+var cypherWriteClause = regexp.MustCompile(`(?i)\b(CREATE|MERGE|DELETE|DETACH|SET|REMOVE|DROP|FOREACH|LOAD\s+CSV|IN\s+TRANSACTIONS|ALTER|GRANT|DENY|REVOKE)\b`)
+
+// cypherCall matches a CALL clause and the procedure name that follows it,
+// if any. Subqueries (CALL { ... } and CALL (x) { ... }) have no name.
+var cypherCall = regexp.MustCompile(`(?i)\bCALL\b\s*([A-Za-z_]\w*(?:\s*\.\s*[A-Za-z_]\w*)*)?\s*(\S?)`)
+
+// readProcedures are the procedures generated Cypher may CALL. Names
+// ending in "." allow every procedure in the namespace. Any other
+// procedure is rejected, since procedures such as apoc.do.when or
+// apoc.cypher.doIt can run arbitrary writes.
+var readProcedures = []string{
+	"db.labels",
+	"db.relationshipTypes",
+	"db.propertyKeys",
+	"db.indexes",
+	"db.constraints",
+	"db.info",
+	"db.ping",
+	"db.schema.visualization",
+	"db.schema.nodeTypeProperties",
+	"db.schema.relTypeProperties",
+	"db.index.fulltext.queryNodes",
+	"db.index.fulltext.queryRelationships",
+	"db.index.vector.queryNodes",
+	"db.index.vector.queryRelationships",
+	"apoc.meta.",
+	"apoc.path.",
+}
+
+// isReadProcedure reports whether name is in readProcedures.
+func isReadProcedure(name string) bool {
+	for _, allowed := range readProcedures {
+		if strings.HasSuffix(allowed, ".") {
+			if strings.HasPrefix(strings.ToLower(name), strings.ToLower(allowed)) && len(name) > len(allowed) {
+				return true
+			}
+		} else if strings.EqualFold(name, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// cypherLiteral matches string literals, quoted identifiers and comments,
+// which are blanked out before the read-only check.
+var cypherLiteral = regexp.MustCompile("'(?:[^'\\\\]|\\\\.)*'|\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`|//[^\n]*|/\\*(?s:.*?)\\*/")
+
+// ValidateReadOnlyCypher returns an error if the statement could modify the
+// graph, or if it contains more than one statement. Only the procedures in
+// a fixed allowlist of read procedures may be called.
+func ValidateReadOnlyCypher(cypher string) error {
+	stripped := cypherLiteral.ReplaceAllString(cypher, "''")
+
+	trimmed := strings.TrimSpace(stripped)
+	trimmed = strings.TrimSuffix(trimmed, ";")
+	if strings.Contains(trimmed, ";") {
+		return fmt.Errorf("multiple Cypher statements are not allowed")
+	}
+	if trimmed == "" {
+		return fmt.Errorf("empty Cypher statement")
+	}
+
+	if match := cypherWriteClause.FindString(stripped); match != "" {
+		return fmt.Errorf("Cypher statement is not read-only: contains %q", strings.ToUpper(match))
+	}
+	for _, m := range cypherCall.FindAllStringSubmatch(stripped, -1) {
+		name, next := strings.Join(strings.Fields(m[1]), ""), m[2]
+		if name == "" {
+			if next == "{" || next == "(" {
+				continue
+			}
+			return fmt.Errorf("Cypher statement is not read-only: CALL of an unknown procedure")
+		}
+		// A quoted name part, e.g. apoc.`do`.when, is blanked to ''.
+		if next == "'" || next == "." || !isReadProcedure(name) {
+			return fmt.Errorf("Cypher statement is not read-only: calls procedure %q", name)
+		}
+	}
+	return nil
+}
+
+// TextToCypherEngine translates natural language questions into Cypher,
+// executes them against a property graph (e.g. Neo4j or Memgraph), and
+// synthesizes an answer from the results.
+type TextToCypherEngine struct {
+	*BaseQueryEngine
+	// GraphStore executes the generated Cypher.
+	GraphStore graphstore.GraphStore
+	// LLM generates Cypher and synthesizes the answer.
+	LLM llm.LLM
+	// Schema describes the graph. If empty, it is fetched from GraphStore.
+	Schema string
+	// CypherPrompt is the template used to generate Cypher.
+	CypherPrompt prompts.BasePromptTemplate
+	// ResponsePrompt is the template used to synthesize the answer.
+	ResponsePrompt prompts.BasePromptTemplate
+	// SynthesizeResponse controls whether results are turned into prose.
+	// When false, the raw results are returned as the response text.
+	SynthesizeResponse bool
+	// ReadOnly rejects Cypher that could modify the graph, and runs the
+	// rest in a read-only transaction if GraphStore is a
+	// graphstore.ReadQuerier.
+	ReadOnly bool
+}
+
+// TextToCypherEngineOption is a functional option.
+type TextToCypherEngineOption func(*TextToCypherEngine)
+
+// WithCypherSchema sets the graph schema description, overriding the store's.
+func WithCypherSchema(schema string) TextToCypherEngineOption {
+	return func(e *TextToCypherEngine) {
+		e.Schema = schema
+	}
+}
+
+// WithCypherPrompt sets the Cypher generation prompt.
+func WithCypherPrompt(prompt prompts.BasePromptTemplate) TextToCypherEngineOption {
+	return func(e *TextToCypherEngine) {
+		e.CypherPrompt = prompt
+	}
+}
+
+// WithCypherResponsePrompt sets the response synthesis prompt.
+func WithCypherResponsePrompt(prompt prompts.BasePromptTemplate) TextToCypherEngineOption {
+	return func(e *TextToCypherEngine) {
+		e.ResponsePrompt = prompt
+	}
+}
+
+// WithCypherSynthesizeResponse sets whether results are synthesized into prose.
+func WithCypherSynthesizeResponse(synthesize bool) TextToCypherEngineOption {
+	return func(e *TextToCypherEngine) {
+		e.SynthesizeResponse = synthesize
+	}
+}
+
+// WithCypherReadOnly sets whether write statements are rejected.
+func WithCypherReadOnly(readOnly bool) TextToCypherEngineOption {
+	return func(e *TextToCypherEngine) {
+		e.ReadOnly = readOnly
+	}
+}
+
+// WithCypherVerbose enables verbose logging.
+func WithCypherVerbose(verbose bool) TextToCypherEngineOption {
+	return func(e *TextToCypherEngine) {
+		e.Verbose = verbose
+	}
+}
+
+// NewTextToCypherEngine creates a new TextToCypherEngine. Generated Cypher
+// is validated as read-only unless WithCypherReadOnly(false) is given.
+func NewTextToCypherEngine(store graphstore.GraphStore, llmModel llm.LLM, opts ...TextToCypherEngineOption) *TextToCypherEngine {
+	e := &TextToCypherEngine{
+		BaseQueryEngine:    NewBaseQueryEngine(),
+		GraphStore:         store,
+		LLM:                llmModel,
+		CypherPrompt:       prompts.NewPromptTemplate(defaultTextToCypherPrompt, prompts.PromptTypeTextToGraphQuery),
+		ResponsePrompt:     prompts.NewPromptTemplate(defaultCypherResponsePrompt, prompts.PromptTypeCustom),
+		SynthesizeResponse: true,
+		ReadOnly:           true,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	e.SetPrompt("text_to_cypher_prompt", e.CypherPrompt)
+	e.SetPrompt("response_synthesis_prompt", e.ResponsePrompt)
+
+	return e
+}
+
+// Query executes a natural language query against the graph.
+func (e *TextToCypherEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	cypher, err := e.GenerateCypher(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if e.Verbose {
+		fmt.Printf("Generated Cypher: %s\n", cypher)
+	}
+
+	if e.ReadOnly {
+		if err := ValidateReadOnlyCypher(cypher); err != nil {
+			return nil, fmt.Errorf("refusing to execute generated Cypher: %w", err)
+		}
+	}
+
+	var result interface{}
+	if reader, ok := e.GraphStore.(graphstore.ReadQuerier); ok && e.ReadOnly {
+		result, err = reader.QueryRead(ctx, cypher, nil)
+	} else {
+		result, err = e.GraphStore.Query(ctx, cypher, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute Cypher: %w", err)
+	}
+
+	resultStr := formatCypherResult(result)
+	resultNode := schema.NewTextNode(resultStr)
+	resultNode.Metadata = map[string]interface{}{CypherQueryMetadataKey: cypher}
+	sourceNodes := []schema.NodeWithScore{{Node: *resultNode, Score: 1.0}}
+
+	responseText := resultStr
+	if e.SynthesizeResponse {
+		prompt := e.ResponsePrompt.Format(map[string]string{
+			"query_str":    query,
+			"cypher_query": cypher,
+			"context_str":  resultStr,
+		})
+		responseText, err = e.LLM.Complete(ctx, prompt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to synthesize response: %w", err)
+		}
+	}
+
+	return synthesizer.NewResponseWithMetadata(responseText, sourceNodes, map[string]interface{}{
+		CypherQueryMetadataKey:  cypher,
+		CypherResultMetadataKey: result,
+	}), nil
+}
+
+// GenerateCypher asks the LLM for a Cypher statement answering the query.
+func (e *TextToCypherEngine) GenerateCypher(ctx context.Context, query string) (string, error) {
+	graphSchema := e.Schema
+	if graphSchema == "" {
+		var err error
+		graphSchema, err = e.GraphStore.GetSchema(ctx, false)
+		if err != nil {
+			return "", fmt.Errorf("failed to get graph schema: %w", err)
+		}
+	}
+
+	prompt := e.CypherPrompt.Format(map[string]string{
+		"schema":    graphSchema,
+		"query_str": query,
+	})
+
+	response, err := e.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate Cypher: %w", err)
+	}
+
+	cypher := parseCypher(response)
+	if cypher == "" {
+		return "", fmt.Errorf("LLM returned no Cypher statement")
+	}
+	return cypher, nil
+}
+
+// parseCypher extracts the Cypher statement from LLM output, removing code
+// fences and a leading "Cypher:" label.
+func parseCypher(response string) string {
+	text := strings.TrimSpace(response)
+	if start := strings.Index(text, "```"); start != -1 {
+		body := text[start+3:]
+		if nl := strings.Index(body, "\n"); nl != -1 && !strings.ContainsAny(body[:nl], " ()") {
+			// Drop the language tag, e.g. ```cypher.
+			body = body[nl+1:]
+		}
+		if end := strings.Index(body, "```"); end != -1 {
+			body = body[:end]
+		}
+		text = strings.TrimSpace(body)
+	}
+	if len(text) >= 7 && strings.EqualFold(text[:7], "cypher:") {
+		text = strings.TrimSpace(text[7:])
+	}
+	return text
+}
+
+// formatCypherResult renders a graph store result for prompts.
+func formatCypherResult(result interface{}) string {
+	switch r := result.(type) {
+	case nil:
+		return "[]"
+	case string:
+		return r
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Sprintf("%v", result)
+	}
+	return string(data)
+}
+
+// Ensure TextToCypherEngine implements QueryEngine.
+var _ QueryEngine = (*TextToCypherEngine)(nil)
@@ -0,0 +1,390 @@
+package queryengine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/rag/reader"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// Metadata keys set on responses produced by TableQueryEngine.
+const (
+	// SQLQueryMetadataKey holds the executed SQL statement.
+	SQLQueryMetadataKey = "sql_query"
+	// SQLResultMetadataKey holds the *SQLResult returned by the database.
+	SQLResultMetadataKey = "sql_result"
+)
+
+// Default prompts for text-to-SQL.
+const (
+	defaultTextToSQLPrompt = `Given an input question, create a syntactically correct {dialect} query to run.
+Only use the tables and columns listed in the schema below. Quote column and table names with double quotes.
+Generate a single read-only SELECT statement. Unless the question asks for a specific number of rows, limit the result to {max_rows} rows.
+
+Schema:
+{schema}
+
+Do not include any explanations. Reply with the SQL query only.
+
+Question: {query_str}
+SQLQuery: `
+
+	defaultSQLResponsePrompt = `Given an input question, synthesize a response from the query results.
+Query: {query_str}
+SQL: {sql_query}
+SQL Response: {context_str}
+Response: `
+)
+
+// SQLResult is the result of a SQL query.
+type SQLResult struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// String renders the result as a pipe-separated table.
+func (r *SQLResult) String() string {
+	var b strings.Builder
+	b.WriteString(strings.Join(r.Columns, " | "))
+	for _, row := range r.Rows {
+		values := make([]string, len(row))
+		for i, v := range row {
+			switch t := v.(type) {
+			case nil:
+				values[i] = "NULL"
+			case []byte:
+				values[i] = string(t)
+			default:
+				values[i] = fmt.Sprintf("%v", t)
+			}
+		}
+		b.WriteString("\n")
+		b.WriteString(strings.Join(values, " | "))
+	}
+	return b.String()
+}
+
+// sqlWriteKeyword matches statements that modify data, the schema or the
+// connection.
+var sqlWriteKeyword = regexp.MustCompile(`(?i)\b(INSERT|UPDATE|DELETE|REPLACE|UPSERT|MERGE|CREATE|DROP|ALTER|TRUNCATE|ATTACH|DETACH|PRAGMA|VACUUM|REINDEX|GRANT|REVOKE|COPY)\b`)
+
+// sqlLiteral matches string literals, quoted identifiers and comments,
+// which are blanked out before the read-only check.
+var sqlLiteral = regexp.MustCompile("'(?:[^']|'')*'|\"(?:[^\"]|\"\")*\"|`[^`]*`|\\[[^\\]]*\\]|--[^\n]*|/\\*(?s:.*?)\\*/")
+
+// ValidateReadOnlySQL returns an error unless the statement is a single
+// SELECT (or WITH ... SELECT) query.
+func ValidateReadOnlySQL(query string) error {
+	stripped := sqlLiteral.ReplaceAllString(query, "''")
+
+	trimmed := strings.TrimSpace(stripped)
+	trimmed = strings.TrimSpace(strings.TrimSuffix(trimmed, ";"))
+	if strings.Contains(trimmed, ";") {
+		return fmt.Errorf("multiple SQL statements are not allowed")
+	}
+	if trimmed == "" {
+		return fmt.Errorf("empty SQL statement")
+	}
+
+	first := strings.ToUpper(strings.Fields(trimmed)[0])
+	if first != "SELECT" && first != "WITH" {
+		return fmt.Errorf("SQL statement is not a query: starts with %q", first)
+	}
+	if match := sqlWriteKeyword.FindString(trimmed); match != "" {
+		return fmt.Errorf("SQL statement is not read-only: contains %q", strings.ToUpper(match))
+	}
+	return nil
+}
+
+// TableQueryEngine answers natural language questions over CSV and Excel
+// data. Tables with inferred column types are loaded into an in-process
+// SQL database, the LLM translates each question into SQL, and the answer
+// is synthesized from the query result. Both the answer and the executed
+// SQL are returned.
+//
+// The engine works with any database/sql driver; open an in-memory SQLite
+// database (e.g. "file::memory:" with modernc.org/sqlite or
+// mattn/go-sqlite3) and pass it in.
+type TableQueryEngine struct {
+	*BaseQueryEngine
+	// DB holds the loaded tables.
+	DB *sql.DB
+	// LLM generates SQL and synthesizes the answer.
+	LLM llm.LLM
+	// Tables are the tables available to queries.
+	Tables []*reader.Table
+	// Dialect names the SQL dialect in the prompt.
+	Dialect string
+	// SQLPrompt is the template used to generate SQL.
+	SQLPrompt prompts.BasePromptTemplate
+	// ResponsePrompt is the template used to synthesize the answer.
+	ResponsePrompt prompts.BasePromptTemplate
+	// SynthesizeResponse controls whether results are turned into prose.
+	// When false, the formatted result is returned as the response text.
+	SynthesizeResponse bool
+	// SampleRows is the number of example rows shown per table.
+	SampleRows int
+	// MaxRows caps the rows read from a result.
+	MaxRows int
+}
+
+// TableQueryEngineOption is a functional option.
+type TableQueryEngineOption func(*TableQueryEngine)
+
+// WithSQLDialect sets the SQL dialect named in the prompt. Defaults to
+// "SQLite".
+func WithSQLDialect(dialect string) TableQueryEngineOption {
+	return func(e *TableQueryEngine) {
+		e.Dialect = dialect
+	}
+}
+
+// WithSQLPrompt sets the SQL generation prompt.
+func WithSQLPrompt(prompt prompts.BasePromptTemplate) TableQueryEngineOption {
+	return func(e *TableQueryEngine) {
+		e.SQLPrompt = prompt
+	}
+}
+
+// WithSQLResponsePrompt sets the response synthesis prompt.
+func WithSQLResponsePrompt(prompt prompts.BasePromptTemplate) TableQueryEngineOption {
+	return func(e *TableQueryEngine) {
+		e.ResponsePrompt = prompt
+	}
+}
+
+// WithSQLSynthesizeResponse sets whether results are synthesized into prose.
+func WithSQLSynthesizeResponse(synthesize bool) TableQueryEngineOption {
+	return func(e *TableQueryEngine) {
+		e.SynthesizeResponse = synthesize
+	}
+}
+
+// WithSQLSampleRows sets the number of example rows shown per table.
+func WithSQLSampleRows(n int) TableQueryEngineOption {
+	return func(e *TableQueryEngine) {
+		e.SampleRows = n
+	}
+}
+
+// WithSQLMaxRows caps the rows read from a result.
+func WithSQLMaxRows(n int) TableQueryEngineOption {
+	return func(e *TableQueryEngine) {
+		e.MaxRows = n
+	}
+}
+
+// NewTableQueryEngine creates a TableQueryEngine over tables that are
+// already loaded into db. Use LoadTables or NewTableQueryEngineFromFiles to
+// load them.
+func NewTableQueryEngine(db *sql.DB, llmModel llm.LLM, tables []*reader.Table, opts ...TableQueryEngineOption) *TableQueryEngine {
+	e := &TableQueryEngine{
+		BaseQueryEngine:    NewBaseQueryEngine(),
+		DB:                 db,
+		LLM:                llmModel,
+		Tables:             tables,
+		Dialect:            "SQLite",
+		SQLPrompt:          prompts.NewPromptTemplate(defaultTextToSQLPrompt, prompts.PromptTypeTextToSQL),
+		ResponsePrompt:     prompts.NewPromptTemplate(defaultSQLResponsePrompt, prompts.PromptTypeSQLResponseSynthesis),
+		SynthesizeResponse: true,
+		SampleRows:         3,
+		MaxRows:            100,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	e.SetPrompt("text_to_sql_prompt", e.SQLPrompt)
+	e.SetPrompt("response_synthesis_prompt", e.ResponsePrompt)
+
+	return e
+}
+
+// NewTableQueryEngineFromFiles reads CSV and Excel files, loads them into
+// db and returns an engine over them.
+func NewTableQueryEngineFromFiles(ctx context.Context, db *sql.DB, llmModel llm.LLM, files []string, opts ...TableQueryEngineOption) (*TableQueryEngine, error) {
+	tableReader := reader.NewTableReader()
+	var tables []*reader.Table
+	for _, file := range files {
+		loaded, err := tableReader.LoadTables(file)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, loaded...)
+	}
+
+	if err := LoadTables(ctx, db, tables); err != nil {
+		return nil, err
+	}
+	return NewTableQueryEngine(db, llmModel, tables, opts...), nil
+}
+
+// LoadTables creates the tables in db and inserts their rows in a single
+// transaction.
+func LoadTables(ctx context.Context, db *sql.DB, tables []*reader.Table) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, t := range tables {
+		if _, err := tx.ExecContext(ctx, t.CreateTableSQL()); err != nil {
+			return fmt.Errorf("failed to create table %s: %w", t.Name, err)
+		}
+		stmt, err := tx.PrepareContext(ctx, t.InsertSQL())
+		if err != nil {
+			return fmt.Errorf("failed to prepare insert for %s: %w", t.Name, err)
+		}
+		for _, row := range t.Rows {
+			if _, err := stmt.ExecContext(ctx, row...); err != nil {
+				stmt.Close()
+				return fmt.Errorf("failed to insert into %s: %w", t.Name, err)
+			}
+		}
+		stmt.Close()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tables: %w", err)
+	}
+	return nil
+}
+
+// Schema describes the tables for the SQL prompt.
+func (e *TableQueryEngine) Schema() string {
+	descriptions := make([]string, len(e.Tables))
+	for i, t := range e.Tables {
+		descriptions[i] = t.Describe(e.SampleRows)
+	}
+	return strings.Join(descriptions, "\n\n")
+}
+
+// Query executes a natural language query against the tables.
+func (e *TableQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	sqlQuery, err := e.GenerateSQL(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if e.Verbose {
+		fmt.Printf("Generated SQL: %s\n", sqlQuery)
+	}
+
+	if err := ValidateReadOnlySQL(sqlQuery); err != nil {
+		return nil, fmt.Errorf("refusing to execute generated SQL: %w", err)
+	}
+
+	result, err := e.execute(ctx, sqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute SQL: %w", err)
+	}
+
+	resultStr := result.String()
+	resultNode := schema.NewTextNode(resultStr)
+	resultNode.Metadata = map[string]interface{}{SQLQueryMetadataKey: sqlQuery}
+	sourceNodes := []schema.NodeWithScore{{Node: *resultNode, Score: 1.0}}
+
+	responseText := resultStr
+	if e.SynthesizeResponse {
+		prompt := e.ResponsePrompt.Format(map[string]string{
+			"query_str":   query,
+			"sql_query":   sqlQuery,
+			"context_str": resultStr,
+		})
+		responseText, err = e.LLM.Complete(ctx, prompt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to synthesize response: %w", err)
+		}
+	}
+
+	return synthesizer.NewResponseWithMetadata(responseText, sourceNodes, map[string]interface{}{
+		SQLQueryMetadataKey:  sqlQuery,
+		SQLResultMetadataKey: result,
+	}), nil
+}
+
+// GenerateSQL asks the LLM for a SQL statement answering the query.
+func (e *TableQueryEngine) GenerateSQL(ctx context.Context, query string) (string, error) {
+	prompt := e.SQLPrompt.Format(map[string]string{
+		"dialect":   e.Dialect,
+		"schema":    e.Schema(),
+		"max_rows":  fmt.Sprintf("%d", e.MaxRows),
+		"query_str": query,
+	})
+
+	response, err := e.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate SQL: %w", err)
+	}
+
+	sqlQuery := parseSQL(response)
+	if sqlQuery == "" {
+		return "", fmt.Errorf("LLM returned no SQL statement")
+	}
+	return sqlQuery, nil
+}
+
+// execute runs a query and reads up to MaxRows rows.
+func (e *TableQueryEngine) execute(ctx context.Context, sqlQuery string) (*SQLResult, error) {
+	rows, err := e.DB.QueryContext(ctx, sqlQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := &SQLResult{Columns: columns}
+	for rows.Next() {
+		if e.MaxRows > 0 && len(result.Rows) >= e.MaxRows {
+			break
+		}
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result, rows.Err()
+}
+
+// parseSQL extracts the SQL statement from LLM output, removing code
+// fences, a leading "SQLQuery:" label and anything after "SQLResult:".
+func parseSQL(response string) string {
+	text := strings.TrimSpace(response)
+	if start := strings.Index(text, "```"); start != -1 {
+		body := text[start+3:]
+		if nl := strings.Index(body, "\n"); nl != -1 && !strings.ContainsAny(body[:nl], " ()") {
+			// Drop the language tag, e.g. ```sql.
+			body = body[nl+1:]
+		}
+		if end := strings.Index(body, "```"); end != -1 {
+			body = body[:end]
+		}
+		text = strings.TrimSpace(body)
+	}
+	if len(text) >= 9 && strings.EqualFold(text[:9], "sqlquery:") {
+		text = strings.TrimSpace(text[9:])
+	}
+	if i := strings.Index(text, "SQLResult:"); i != -1 {
+		text = strings.TrimSpace(text[:i])
+	}
+	return text
+}
+
+// Ensure TableQueryEngine implements QueryEngine.
+var _ QueryEngine = (*TableQueryEngine)(nil)
@@ -0,0 +1,245 @@
+package queryengine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/evaluation"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+)
+
+// Metadata keys set on responses from a TieredQueryEngine.
+const (
+	// TierMetadataKey holds the name of the tier that produced the answer.
+	TierMetadataKey = "tier"
+	// TierConfidenceMetadataKey holds the confidence of the accepted answer.
+	TierConfidenceMetadataKey = "tier_confidence"
+	// TierEscalationsMetadataKey holds the names of the tiers that were
+	// tried and escalated from, in order.
+	TierEscalationsMetadataKey = "tier_escalations"
+)
+
+// ConfidenceScorer estimates how likely a response is to be correct, in
+// [0, 1]. evaluation.ConfidenceEstimator implements it.
+type ConfidenceScorer interface {
+	Estimate(ctx context.Context, query string, resp *synthesizer.Response) (float64, error)
+}
+
+// QueryTier is one step of a TieredQueryEngine, e.g. a retrieval-only path,
+// a small model or a full pipeline with a large model.
+type QueryTier struct {
+	// Name identifies the tier in metadata and stats.
+	Name string
+	// Engine answers queries for this tier.
+	Engine QueryEngine
+	// MinConfidence is the confidence an answer needs to be accepted
+	// without escalating. It is ignored for the last tier.
+	MinConfidence float64
+}
+
+// TierStats counts how queries were handled by a TieredQueryEngine.
+type TierStats struct {
+	// Queries is the number of queries answered.
+	Queries int
+	// Answered counts accepted answers per tier.
+	Answered map[string]int
+	// Escalated counts escalations away from each tier.
+	Escalated map[string]int
+	// Errors counts failed calls per tier.
+	Errors map[string]int
+}
+
+// EscalationRate returns the fraction of queries not answered by the first
+// tier.
+func (s TierStats) EscalationRate(firstTier string) float64 {
+	if s.Queries == 0 {
+		return 0
+	}
+	return float64(s.Queries-s.Answered[firstTier]) / float64(s.Queries)
+}
+
+// TieredQueryEngine sends each query to the cheapest tier first and
+// escalates to the next, more expensive tier only when the answer's
+// confidence is below the tier's threshold. Routine queries are answered
+// by a small model or retrieval alone; hard ones still reach the large
+// model.
+//
+// Confidence comes from the response metadata when the engine already set
+// evaluation.ConfidenceMetadataKey, otherwise from the ConfidenceScorer.
+// Without a scorer, abstained responses count as confidence 0 and all
+// others as 1.
+type TieredQueryEngine struct {
+	*BaseQueryEngine
+	tiers           []QueryTier
+	scorer          ConfidenceScorer
+	escalateOnError bool
+
+	mu    sync.Mutex
+	stats TierStats
+}
+
+// TieredQueryEngineOption configures a TieredQueryEngine.
+type TieredQueryEngineOption func(*TieredQueryEngine)
+
+// WithTierConfidenceScorer sets the scorer used to judge answers.
+func WithTierConfidenceScorer(scorer ConfidenceScorer) TieredQueryEngineOption {
+	return func(e *TieredQueryEngine) {
+		e.scorer = scorer
+	}
+}
+
+// WithTierEscalateOnError escalates to the next tier when a tier fails,
+// instead of returning the error.
+func WithTierEscalateOnError(escalate bool) TieredQueryEngineOption {
+	return func(e *TieredQueryEngine) {
+		e.escalateOnError = escalate
+	}
+}
+
+// NewTieredQueryEngine creates a TieredQueryEngine. Tiers are tried in
+// order, so list the cheapest first.
+func NewTieredQueryEngine(tiers []QueryTier, opts ...TieredQueryEngineOption) *TieredQueryEngine {
+	e := &TieredQueryEngine{
+		BaseQueryEngine: NewBaseQueryEngine(),
+		tiers:           tiers,
+		stats:           newTierStats(),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+func newTierStats() TierStats {
+	return TierStats{
+		Answered:  make(map[string]int),
+		Escalated: make(map[string]int),
+		Errors:    make(map[string]int),
+	}
+}
+
+// Query answers with the first tier whose answer is confident enough.
+func (e *TieredQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	if len(e.tiers) == 0 {
+		return nil, fmt.Errorf("no query tiers configured")
+	}
+
+	var escalations []string
+	for i, tier := range e.tiers {
+		last := i == len(e.tiers)-1
+
+		resp, err := tier.Engine.Query(ctx, query)
+		if err != nil {
+			e.record(func(s *TierStats) { s.Errors[tier.Name]++ })
+			if last || !e.escalateOnError {
+				return nil, fmt.Errorf("tier %s failed: %w", tier.Name, err)
+			}
+			escalations = append(escalations, tier.Name)
+			e.record(func(s *TierStats) { s.Escalated[tier.Name]++ })
+			continue
+		}
+
+		confidence, err := e.confidence(ctx, query, resp)
+		if err != nil {
+			return nil, fmt.Errorf("tier %s confidence check failed: %w", tier.Name, err)
+		}
+
+		if last || confidence >= tier.MinConfidence {
+			e.record(func(s *TierStats) {
+				s.Queries++
+				s.Answered[tier.Name]++
+			})
+			return withTierMetadata(resp, tier.Name, confidence, escalations), nil
+		}
+
+		escalations = append(escalations, tier.Name)
+		e.record(func(s *TierStats) { s.Escalated[tier.Name]++ })
+	}
+
+	// Unreachable: the last tier always returns.
+	return nil, fmt.Errorf("no tier produced an answer")
+}
+
+// confidence returns the confidence of a tier's response.
+func (e *TieredQueryEngine) confidence(ctx context.Context, query string, resp *synthesizer.Response) (float64, error) {
+	return responseConfidence(ctx, e.scorer, query, resp)
+}
+
+// responseConfidence returns the confidence recorded on resp, 0 if it
+// abstained, or else the scorer's estimate. Without a scorer, answers that
+// did not abstain have confidence 1.
+func responseConfidence(ctx context.Context, scorer ConfidenceScorer, query string, resp *synthesizer.Response) (float64, error) {
+	if resp.Metadata != nil {
+		if c, ok := resp.Metadata[evaluation.ConfidenceMetadataKey].(float64); ok {
+			return c, nil
+		}
+	}
+	if abstained, _ := IsAbstained(resp); abstained {
+		return 0, nil
+	}
+	if scorer == nil {
+		return 1, nil
+	}
+	return scorer.Estimate(ctx, query, resp)
+}
+
+func (e *TieredQueryEngine) record(update func(*TierStats)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	update(&e.stats)
+}
+
+// Stats returns a snapshot of the routing counters.
+func (e *TieredQueryEngine) Stats() TierStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	snapshot := newTierStats()
+	snapshot.Queries = e.stats.Queries
+	for k, v := range e.stats.Answered {
+		snapshot.Answered[k] = v
+	}
+	for k, v := range e.stats.Escalated {
+		snapshot.Escalated[k] = v
+	}
+	for k, v := range e.stats.Errors {
+		snapshot.Errors[k] = v
+	}
+	return snapshot
+}
+
+// EscalationRate returns the fraction of queries not answered by the first
+// tier.
+func (e *TieredQueryEngine) EscalationRate() float64 {
+	if len(e.tiers) == 0 {
+		return 0
+	}
+	return e.Stats().EscalationRate(e.tiers[0].Name)
+}
+
+// ResetStats clears the routing counters.
+func (e *TieredQueryEngine) ResetStats() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stats = newTierStats()
+}
+
+// withTierMetadata returns a copy of resp annotated with the routing
+// decision.
+func withTierMetadata(resp *synthesizer.Response, tier string, confidence float64, escalations []string) *synthesizer.Response {
+	metadata := make(map[string]interface{}, len(resp.Metadata)+3)
+	for k, v := range resp.Metadata {
+		metadata[k] = v
+	}
+	metadata[TierMetadataKey] = tier
+	metadata[TierConfidenceMetadataKey] = confidence
+	metadata[TierEscalationsMetadataKey] = escalations
+
+	out := *resp
+	out.Metadata = metadata
+	return &out
+}
+
+// Ensure TieredQueryEngine implements QueryEngine.
+var _ QueryEngine = (*TieredQueryEngine)(nil)
@@ -3,95 +3,35 @@ package queryengine
 import (
 	"context"
 
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/querytransform"
 	"github.com/aqua777/go-llamaindex/rag/synthesizer"
 	"github.com/aqua777/go-llamaindex/schema"
 )
 
-// QueryTransform transforms a query before execution.
-type QueryTransform interface {
-	// Transform transforms the query.
-	Transform(ctx context.Context, query schema.QueryBundle) (schema.QueryBundle, error)
-}
+// QueryTransform transforms a query before execution. It is the same
+// interface as querytransform.QueryTransform, so the transforms of that
+// package can be used wherever a QueryTransform is expected.
+type QueryTransform = querytransform.QueryTransform
 
 // IdentityTransform returns the query unchanged.
-type IdentityTransform struct{}
-
-// Transform returns the query unchanged.
-func (t *IdentityTransform) Transform(ctx context.Context, query schema.QueryBundle) (schema.QueryBundle, error) {
-	return query, nil
-}
+//
+// Deprecated: use querytransform.IdentityTransform, which this aliases.
+type IdentityTransform = querytransform.IdentityTransform
 
 // HyDETransform generates a hypothetical document to improve retrieval.
-// HyDE = Hypothetical Document Embeddings
-type HyDETransform struct {
-	// LLM generates the hypothetical document.
-	LLM interface {
-		Complete(ctx context.Context, prompt string) (string, error)
-	}
-	// Prompt is the template for generating hypothetical documents.
-	Prompt string
-}
-
-// Default HyDE prompt.
-const defaultHyDEPrompt = `Please write a passage to answer the question.
-Try to include as many key details as possible.
-
-Question: {query_str}
-
-Passage:`
-
-// NewHyDETransform creates a new HyDETransform.
-func NewHyDETransform(llm interface {
-	Complete(ctx context.Context, prompt string) (string, error)
-}) *HyDETransform {
-	return &HyDETransform{
-		LLM:    llm,
-		Prompt: defaultHyDEPrompt,
-	}
-}
-
-// Transform generates a hypothetical document and uses it as the query.
-func (t *HyDETransform) Transform(ctx context.Context, query schema.QueryBundle) (schema.QueryBundle, error) {
-	// Format prompt
-	prompt := query.QueryString
-	if t.Prompt != "" {
-		prompt = replaceQueryStr(t.Prompt, query.QueryString)
-	}
-
-	// Generate hypothetical document
-	hydeDoc, err := t.LLM.Complete(ctx, prompt)
-	if err != nil {
-		return query, err
-	}
-
-	// Return new query with hypothetical document as the query string
-	// The hypothetical document improves embedding-based retrieval
-	return schema.QueryBundle{
-		QueryString: hydeDoc,
-		Filters:     query.Filters,
-	}, nil
-}
-
-// replaceQueryStr replaces {query_str} in the template.
-func replaceQueryStr(template, query string) string {
-	result := template
-	for {
-		idx := indexOf(result, "{query_str}")
-		if idx == -1 {
-			break
-		}
-		result = result[:idx] + query + result[idx+11:]
-	}
-	return result
-}
-
-func indexOf(s, substr string) int {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return i
-		}
-	}
-	return -1
+//
+// Deprecated: use querytransform.HyDETransform, which this aliases.
+type HyDETransform = querytransform.HyDETransform
+
+// NewHyDETransform creates a HyDETransform whose query string is replaced by
+// the hypothetical document alone, without the original query.
+//
+// Deprecated: use querytransform.NewHyDETransform, which keeps the original
+// query by default.
+func NewHyDETransform(llmModel llm.LLM, opts ...querytransform.HyDETransformOption) *HyDETransform {
+	opts = append([]querytransform.HyDETransformOption{querytransform.WithHyDEIncludeOriginal(false)}, opts...)
+	return querytransform.NewHyDETransform(llmModel, opts...)
 }
 
 // TransformQueryEngine applies a transform before querying.
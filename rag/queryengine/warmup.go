@@ -0,0 +1,234 @@
+package queryengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/llm"
+)
+
+// Warmer is implemented by components that can prepare themselves before
+// serving traffic: loading indexes into memory, priming caches or verifying
+// provider credentials.
+type Warmer interface {
+	// Warmup prepares the component. It should be safe to call more than once.
+	Warmup(ctx context.Context) error
+}
+
+// WarmupFunc adapts a function to the Warmer interface.
+type WarmupFunc func(ctx context.Context) error
+
+// Warmup calls f(ctx).
+func (f WarmupFunc) Warmup(ctx context.Context) error {
+	return f(ctx)
+}
+
+// WarmupComponents warms every argument that implements Warmer and ignores
+// the others. All components are warmed even if one fails; the errors are
+// joined.
+func WarmupComponents(ctx context.Context, components ...interface{}) error {
+	var errs []error
+	for _, c := range components {
+		if w, ok := c.(Warmer); ok {
+			if err := w.Warmup(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LLMWarmer verifies LLM credentials and connectivity with a minimal
+// completion request. Note that this consumes a few tokens.
+func LLMWarmer(model llm.LLM) Warmer {
+	return WarmupFunc(func(ctx context.Context) error {
+		if _, err := model.Complete(ctx, "ping"); err != nil {
+			return fmt.Errorf("LLM warmup failed: %w", err)
+		}
+		return nil
+	})
+}
+
+// EmbeddingWarmer verifies embedding model credentials and connectivity by
+// embedding a short probe query.
+func EmbeddingWarmer(model embedding.EmbeddingModel) Warmer {
+	return WarmupFunc(func(ctx context.Context) error {
+		if _, err := model.GetQueryEmbedding(ctx, "ping"); err != nil {
+			return fmt.Errorf("embedding warmup failed: %w", err)
+		}
+		return nil
+	})
+}
+
+// Warmup warms the retriever and synthesizer if they implement Warmer.
+func (rqe *RetrieverQueryEngine) Warmup(ctx context.Context) error {
+	return WarmupComponents(ctx, rqe.Retriever, rqe.Synthesizer)
+}
+
+// WarmupState is the state of a component's warm-up.
+type WarmupState string
+
+const (
+	// WarmupStatePending means warm-up has not started.
+	WarmupStatePending WarmupState = "pending"
+	// WarmupStateRunning means warm-up is in progress.
+	WarmupStateRunning WarmupState = "running"
+	// WarmupStateReady means warm-up succeeded.
+	WarmupStateReady WarmupState = "ready"
+	// WarmupStateFailed means warm-up returned an error.
+	WarmupStateFailed WarmupState = "failed"
+)
+
+// WarmupStatus reports the warm-up of one component.
+type WarmupStatus struct {
+	// Name identifies the component.
+	Name string `json:"name"`
+	// State is the current state.
+	State WarmupState `json:"state"`
+	// Error is the warm-up error, if it failed.
+	Error string `json:"error,omitempty"`
+	// Duration is how long warm-up took, once finished.
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// Readiness warms components asynchronously at startup and reports whether
+// they are ready to serve, e.g. from a readiness probe.
+type Readiness struct {
+	mu       sync.Mutex
+	statuses map[string]*WarmupStatus
+	wg       sync.WaitGroup
+	timeout  time.Duration
+}
+
+// ReadinessOption configures a Readiness.
+type ReadinessOption func(*Readiness)
+
+// WithWarmupTimeout bounds each component's warm-up. Zero means no timeout.
+func WithWarmupTimeout(timeout time.Duration) ReadinessOption {
+	return func(r *Readiness) {
+		r.timeout = timeout
+	}
+}
+
+// NewReadiness creates an empty Readiness. It reports ready until a
+// component is started.
+func NewReadiness(opts ...ReadinessOption) *Readiness {
+	r := &Readiness{
+		statuses: make(map[string]*WarmupStatus),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// StartWarmup warms all components concurrently and returns immediately.
+func StartWarmup(ctx context.Context, warmers map[string]Warmer, opts ...ReadinessOption) *Readiness {
+	r := NewReadiness(opts...)
+	for name, w := range warmers {
+		r.Start(ctx, name, w)
+	}
+	return r
+}
+
+// Start warms a component in the background. Starting a name again
+// re-runs its warm-up, e.g. to retry after a failure.
+func (r *Readiness) Start(ctx context.Context, name string, w Warmer) {
+	status := &WarmupStatus{Name: name, State: WarmupStateRunning}
+	r.mu.Lock()
+	r.statuses[name] = status
+	r.mu.Unlock()
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		warmCtx := ctx
+		if r.timeout > 0 {
+			var cancel context.CancelFunc
+			warmCtx, cancel = context.WithTimeout(ctx, r.timeout)
+			defer cancel()
+		}
+
+		start := time.Now()
+		err := w.Warmup(warmCtx)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		status.Duration = time.Since(start)
+		if err != nil {
+			status.State = WarmupStateFailed
+			status.Error = err.Error()
+			return
+		}
+		status.State = WarmupStateReady
+	}()
+}
+
+// Wait blocks until all started warm-ups finish or ctx is done, then
+// returns the readiness error.
+func (r *Readiness) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return r.HealthCheck(ctx)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Ready reports whether every started component warmed up successfully.
+func (r *Readiness) Ready() bool {
+	return r.HealthCheck(context.Background()) == nil
+}
+
+// HealthCheck returns nil when all components are ready, or an error naming
+// those still running or failed. Its signature matches health-check hooks
+// such as store.HealthChecker.
+func (r *Readiness) HealthCheck(ctx context.Context) error {
+	var notReady []string
+	for _, status := range r.Status() {
+		switch status.State {
+		case WarmupStateReady:
+		case WarmupStateFailed:
+			notReady = append(notReady, fmt.Sprintf("%s failed: %s", status.Name, status.Error))
+		default:
+			notReady = append(notReady, fmt.Sprintf("%s %s", status.Name, status.State))
+		}
+	}
+	if len(notReady) > 0 {
+		return fmt.Errorf("not ready: %s", strings.Join(notReady, "; "))
+	}
+	return nil
+}
+
+// Status returns the warm-up status of each component, ordered by name.
+func (r *Readiness) Status() []WarmupStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses := make([]WarmupStatus, 0, len(r.statuses))
+	for _, status := range r.statuses {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// Ensure RetrieverQueryEngine implements Warmer.
+var _ Warmer = (*RetrieverQueryEngine)(nil)
@@ -0,0 +1,292 @@
+package atlassian
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/ingestion"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func confluencePageJSON(id, title, when string, version int, body string) map[string]interface{} {
+	return map[string]interface{}{
+		"id": id, "type": "page", "title": title,
+		"space":     map[string]string{"key": "ENG", "name": "Engineering"},
+		"version":   map[string]interface{}{"number": version, "when": when, "by": map[string]string{"displayName": "Ann"}},
+		"ancestors": []map[string]string{{"title": "Home"}, {"title": "Runbooks"}},
+		"body":      map[string]interface{}{"view": map[string]string{"value": body}},
+		"metadata":  map[string]interface{}{"labels": map[string]interface{}{"results": []map[string]string{{"name": "ops"}}}},
+		"_links":    map[string]string{"webui": "/spaces/ENG/pages/" + id},
+	}
+}
+
+// fakeConfluence serves two pages of search results and a page by ID. The
+// first request is rate limited.
+func fakeConfluence(t *testing.T, cqls *[]string) *httptest.Server {
+	t.Helper()
+	pages := []interface{}{
+		confluencePageJSON("101", "Deploys", "2025-03-01T10:00:00.000Z", 4,
+			`<h2>Steps</h2><ol><li>Run <code>make deploy</code></li></ol><p>See <a href="/wiki/spaces/ENG/pages/102">rollback</a>.</p>`),
+		confluencePageJSON("102", "Rollback", "2025-03-05T08:30:00.000Z", 2, `<p>Revert the <strong>release</strong>.</p>`),
+	}
+	var limited int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wiki/rest/api/content/search", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.CompareAndSwapInt32(&limited, 0, 1) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if r.URL.Query().Get("cursor") == "" {
+			*cqls = append(*cqls, r.URL.Query().Get("cql"))
+			assert.Equal(t, confluenceExpand, r.URL.Query().Get("expand"))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"results": pages[:1],
+				"_links":  map[string]string{"next": "/rest/api/content/search?cursor=abc&cql=x"},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": pages[1:], "_links": map[string]string{}})
+	})
+	mux.HandleFunc("/wiki/rest/api/content/102", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(pages[1])
+	})
+	mux.HandleFunc("/wiki/rest/api/content/404", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"statusCode":404,"message":"No content found with id: 404"}`))
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "me@example.com" || pass != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestConfluenceReader(t *testing.T) {
+	var cqls []string
+	srv := fakeConfluence(t, &cqls)
+	client := NewClient(srv.URL+"/wiki", WithBasicAuth("me@example.com", "token"))
+
+	r := NewConfluenceReader(client, WithSpaceKeys("ENG", "OPS"), WithCQL(`label = "runbook"`),
+		WithConfluenceMetadata(map[string]interface{}{"team": "sre"}))
+	docs, checkpoint, err := r.LoadUpdated(context.Background(), time.Time{})
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	assert.Equal(t, []string{`type = page AND space IN ("ENG", "OPS") AND (label = "runbook") ORDER BY lastmodified ASC`}, cqls)
+	assert.Equal(t, time.Date(2025, 3, 5, 8, 30, 0, 0, time.UTC), checkpoint)
+
+	doc := docs[0]
+	assert.Equal(t, "101", doc.ID)
+	assert.Equal(t, "text/markdown", doc.MimeType)
+	assert.Contains(t, doc.Text, "# Deploys\n\n## Steps")
+	assert.Contains(t, doc.Text, "`make deploy`")
+	assert.Contains(t, doc.Text, "[rollback]("+srv.URL+"/wiki/spaces/ENG/pages/102)")
+	assert.Equal(t, srv.URL+"/wiki/spaces/ENG/pages/101", doc.Metadata[URLMetadataKey])
+	assert.Equal(t, "ENG", doc.Metadata[SpaceKeyMetadataKey])
+	assert.Equal(t, 4, doc.Metadata[VersionMetadataKey])
+	assert.Equal(t, "Ann", doc.Metadata[UpdatedByMetadataKey])
+	assert.Equal(t, "2025-03-01T10:00:00Z", doc.Metadata[UpdatedMetadataKey])
+	assert.Equal(t, []string{"Home", "Runbooks"}, doc.Metadata[AncestorsMetadataKey])
+	assert.Equal(t, []string{"ops"}, doc.Metadata[LabelsMetadataKey])
+	assert.Equal(t, "sre", doc.Metadata["team"])
+
+	// Incremental loads widen the query and filter by exact update time.
+	docs, next, err := r.LoadUpdated(context.Background(), checkpoint.Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	assert.Equal(t, "102", docs[0].ID)
+	assert.Equal(t, checkpoint, next)
+	assert.Contains(t, cqls[1], `lastmodified >= "2025/03/04 07:30"`)
+
+	docs, next, err = r.LoadUpdated(context.Background(), checkpoint)
+	require.NoError(t, err)
+	assert.Empty(t, docs)
+	assert.Equal(t, checkpoint, next, "the checkpoint is kept when nothing changed")
+
+	docs, err = NewConfluenceReader(client, WithConfluencePageIDs("102")).LoadData()
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	assert.Equal(t, "# Rollback\n\nRevert the **release**.", docs[0].Text)
+
+	_, err = NewConfluenceReader(client, WithConfluencePageIDs("404")).LoadData()
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.Status)
+	assert.Equal(t, []string{"No content found with id: 404"}, apiErr.Messages)
+
+	_, err = NewConfluenceReader(NewClient(srv.URL+"/wiki", WithBasicAuth("me@example.com", "wrong"))).LoadData()
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.Status)
+}
+
+func jiraIssueJSON(key, summary, updated string) map[string]interface{} {
+	return map[string]interface{}{
+		"id": "1" + key[len(key)-1:], "key": key,
+		"fields": map[string]interface{}{
+			"summary":           summary,
+			"description":       "h2. Raw wiki markup",
+			"status":            map[string]string{"name": "In Progress"},
+			"issuetype":         map[string]string{"name": "Bug"},
+			"priority":          map[string]string{"name": "High"},
+			"assignee":          map[string]string{"displayName": "Bo", "accountId": "x"},
+			"reporter":          nil,
+			"labels":            []string{"login", "mobile"},
+			"project":           map[string]string{"key": "APP", "name": "App"},
+			"created":           "2025-02-01T09:00:00.000+0000",
+			"updated":           updated,
+			"customfield_10016": 5,
+			"customfield_10020": []map[string]string{{"value": "Team A"}},
+			"comment": map[string]interface{}{"comments": []map[string]interface{}{
+				{"author": map[string]string{"displayName": "Cy"}, "body": "raw comment", "created": "2025-02-02T10:15:00.000+0100"},
+			}},
+		},
+		"renderedFields": map[string]interface{}{
+			"description": "<p>Login fails on <em>Android</em>.</p>",
+			"comment":     map[string]interface{}{"comments": []map[string]string{{"body": "<p>Reproduced.</p>"}}},
+		},
+	}
+}
+
+func fakeJira(t *testing.T, jqls *[]string, issues *[]interface{}) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer pat" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{"errorMessages": []string{"Unauthorized"}})
+			return
+		}
+		require.Equal(t, "/rest/api/2/search", r.URL.Path)
+		q := r.URL.Query()
+		assert.Equal(t, "renderedFields", q.Get("expand"))
+		start := 0
+		if q.Get("startAt") != "0" {
+			start = 1
+		} else {
+			*jqls = append(*jqls, q.Get("jql"))
+		}
+		page := []interface{}{}
+		if start < len(*issues) {
+			page = (*issues)[start : start+1]
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"startAt": start, "total": len(*issues), "issues": page})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestJiraReader(t *testing.T) {
+	var jqls []string
+	issues := []interface{}{
+		jiraIssueJSON("APP-1", "Login fails", "2025-03-01T12:00:00.000+0000"),
+		jiraIssueJSON("APP-2", "Crash on start", "2025-03-02T12:00:00.000+0200"),
+	}
+	srv := fakeJira(t, &jqls, &issues)
+	client := NewClient(srv.URL, WithBearerToken("pat"))
+
+	r := NewJiraReader(client, WithProjects("APP"), WithJQL("status != Done"), WithJiraFields("customfield_10016", "customfield_10020"))
+	docs, checkpoint, err := r.LoadUpdated(context.Background(), time.Time{})
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	assert.Equal(t, []string{`project IN ("APP") AND (status != Done) ORDER BY updated ASC`}, jqls)
+	assert.Equal(t, time.Date(2025, 3, 2, 10, 0, 0, 0, time.UTC), checkpoint.UTC())
+
+	doc := docs[0]
+	assert.Equal(t, "APP-1", doc.ID)
+	assert.Equal(t, "# APP-1: Login fails\n\nLogin fails on *Android*.\n\n## Comments\n\n**Cy** (2025-02-02 09:15):\n\nReproduced.", doc.Text)
+	assert.Equal(t, srv.URL+"/browse/APP-1", doc.Metadata[URLMetadataKey])
+	assert.Equal(t, "In Progress", doc.Metadata[StatusMetadataKey])
+	assert.Equal(t, "Bug", doc.Metadata[IssueTypeMetadataKey])
+	assert.Equal(t, "Bo", doc.Metadata[AssigneeMetadataKey])
+	assert.Equal(t, "App", doc.Metadata[ProjectMetadataKey])
+	assert.NotContains(t, doc.Metadata, ReporterMetadataKey)
+	assert.Equal(t, []string{"login", "mobile"}, doc.Metadata[LabelsMetadataKey])
+	assert.Equal(t, "2025-02-01T09:00:00Z", doc.Metadata[CreatedMetadataKey])
+	assert.Equal(t, 5.0, doc.Metadata["customfield_10016"])
+	assert.Equal(t, []string{"Team A"}, doc.Metadata["customfield_10020"])
+
+	// Without rendered fields or comments, the raw text is used.
+	issue := issues[0].(map[string]interface{})
+	delete(issue, "renderedFields")
+	docs, err = NewJiraReader(client, WithIncludeComments(false)).LoadData()
+	require.NoError(t, err)
+	assert.Equal(t, "# APP-1: Login fails\n\nh2. Raw wiki markup", docs[0].Text)
+	assert.Equal(t, "ORDER BY updated ASC", jqls[1])
+
+	_, _, err = r.LoadUpdated(context.Background(), checkpoint)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(jqls[2], `AND updated >= "2025/03/01 10:00" ORDER BY updated ASC`), jqls[2])
+
+	_, err = NewJiraReader(NewClient(srv.URL, WithBearerToken("bad"))).LoadData()
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, []string{"Unauthorized"}, apiErr.Messages)
+}
+
+// hashDocStore is a minimal ingestion.DocStoreInterface.
+type hashDocStore struct {
+	hashes map[string]string
+}
+
+func (s *hashDocStore) GetDocumentHash(docID string) (string, bool) {
+	h, ok := s.hashes[docID]
+	return h, ok
+}
+func (s *hashDocStore) SetDocumentHash(docID, hash string)      { s.hashes[docID] = hash }
+func (s *hashDocStore) GetAllDocumentHashes() map[string]string { return s.hashes }
+func (s *hashDocStore) AddDocuments(nodes []schema.Node) error {
+	for _, n := range nodes {
+		s.hashes[n.ID] = n.GetHash()
+	}
+	return nil
+}
+func (s *hashDocStore) DeleteDocument(docID string) error { delete(s.hashes, docID); return nil }
+func (s *hashDocStore) DeleteRefDoc(refDocID string) error {
+	delete(s.hashes, refDocID)
+	return nil
+}
+
+func TestSync(t *testing.T) {
+	var jqls []string
+	issues := []interface{}{
+		jiraIssueJSON("APP-1", "Login fails", "2025-03-01T12:00:00.000+0000"),
+		jiraIssueJSON("APP-2", "Crash on start", "2025-03-02T12:00:00.000+0000"),
+	}
+	srv := fakeJira(t, &jqls, &issues)
+	r := NewJiraReader(NewClient(srv.URL, WithBearerToken("pat")))
+	pipeline := ingestion.NewIngestionPipeline(
+		ingestion.WithDocstore(&hashDocStore{hashes: map[string]string{}}),
+		ingestion.WithDisableCache(true),
+	)
+
+	result, err := Sync(context.Background(), r, pipeline, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, result.Documents, 2)
+	assert.Len(t, result.Nodes, 2)
+
+	// APP-2 is edited: the overlap returns APP-1 too, but only the changed
+	// issue is re-ingested.
+	issues[1] = jiraIssueJSON("APP-2", "Crash on start (Android)", "2025-03-03T12:00:00.000+0000")
+	result, err = Sync(context.Background(), r, pipeline, time.Date(2025, 3, 1, 13, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, result.Documents, 1)
+	require.Len(t, result.Nodes, 1)
+	assert.Equal(t, "APP-2", result.Nodes[0].ID)
+	assert.Equal(t, time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC), result.Checkpoint.UTC())
+
+	// Re-running from the same checkpoint finds nothing new.
+	result, err = Sync(context.Background(), r, pipeline, result.Checkpoint)
+	require.NoError(t, err)
+	assert.Empty(t, result.Documents)
+}
@@ -0,0 +1,190 @@
+// Package atlassian provides readers that load Confluence pages and Jira
+// issues through the Atlassian REST APIs, with incremental sync by
+// last-updated time.
+package atlassian
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/internal/httputil"
+)
+
+// DefaultMaxRetries is the default number of retries of rate-limited
+// requests.
+const DefaultMaxRetries = httputil.DefaultMaxRetries
+
+// APIError is an error response of an Atlassian API.
+type APIError struct {
+	// Status is the HTTP status code.
+	Status int
+	// Messages are the error messages returned by the API.
+	Messages []string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("atlassian API error %d: %s", e.Status, strings.Join(e.Messages, "; "))
+}
+
+// Client sends authenticated requests to one Confluence or Jira site.
+// Atlassian Cloud uses basic authentication with an account email and API
+// token; Server and Data Center use a personal access token.
+type Client struct {
+	baseURL    string
+	email      string
+	token      string
+	bearer     bool
+	client     *http.Client
+	maxRetries int
+}
+
+// ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// WithBasicAuth authenticates with an account email and API token
+// (Atlassian Cloud). Defaults to the ATLASSIAN_EMAIL and
+// ATLASSIAN_API_TOKEN environment variables.
+func WithBasicAuth(email, apiToken string) ClientOption {
+	return func(c *Client) {
+		c.email, c.token, c.bearer = email, apiToken, false
+	}
+}
+
+// WithBearerToken authenticates with a personal access token (Server and
+// Data Center).
+func WithBearerToken(token string) ClientOption {
+	return func(c *Client) {
+		c.email, c.token, c.bearer = "", token, true
+	}
+}
+
+// WithHTTPClient sets the HTTP client.
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *Client) {
+		c.client = client
+	}
+}
+
+// WithMaxRetries sets the number of retries of rate-limited requests.
+func WithMaxRetries(n int) ClientOption {
+	return func(c *Client) {
+		c.maxRetries = n
+	}
+}
+
+// NewClient creates a client for a site. baseURL is the product root, e.g.
+// "https://acme.atlassian.net/wiki" for Confluence Cloud or
+// "https://acme.atlassian.net" for Jira Cloud.
+func NewClient(baseURL string, opts ...ClientOption) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		email:      os.Getenv("ATLASSIAN_EMAIL"),
+		token:      os.Getenv("ATLASSIAN_API_TOKEN"),
+		client:     http.DefaultClient,
+		maxRetries: DefaultMaxRetries,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// BaseURL returns the site's product root.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// get sends a GET request for a path below the base URL and decodes the
+// response into out, retrying rate-limited requests after the delay the
+// API asks for.
+func (c *Client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	if c.token == "" {
+		return fmt.Errorf("atlassian API token not set")
+	}
+	target := c.baseURL + path
+	if len(query) > 0 {
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		target += sep + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		if c.bearer {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		} else {
+			req.SetBasicAuth(c.email, c.token)
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+		if retryable && attempt < c.maxRetries {
+			if err := httputil.Sleep(ctx, httputil.RetryDelay(resp.Header.Get("Retry-After"), attempt)); err != nil {
+				return err
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return newAPIError(resp.StatusCode, data)
+		}
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		return nil
+	}
+}
+
+// newAPIError reads the error messages of Jira ("errorMessages" and
+// "errors") and Confluence ("message") error responses.
+func newAPIError(status int, data []byte) *APIError {
+	apiErr := &APIError{Status: status}
+	var body struct {
+		Message       string            `json:"message"`
+		ErrorMessages []string          `json:"errorMessages"`
+		Errors        map[string]string `json:"errors"`
+	}
+	if json.Unmarshal(data, &body) == nil {
+		apiErr.Messages = append(apiErr.Messages, body.ErrorMessages...)
+		fields := make([]string, 0, len(body.Errors))
+		for field := range body.Errors {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			apiErr.Messages = append(apiErr.Messages, field+": "+body.Errors[field])
+		}
+		if body.Message != "" {
+			apiErr.Messages = append(apiErr.Messages, body.Message)
+		}
+	}
+	if len(apiErr.Messages) == 0 {
+		if text := strings.TrimSpace(string(data)); text != "" {
+			apiErr.Messages = []string{text}
+		} else {
+			apiErr.Messages = []string{http.StatusText(status)}
+		}
+	}
+	return apiErr
+}
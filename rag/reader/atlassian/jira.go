@@ -0,0 +1,397 @@
+package atlassian
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/rag/reader"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// Metadata keys set on Jira documents.
+const (
+	// IssueKeyMetadataKey holds the issue key, e.g. "PROJ-12".
+	IssueKeyMetadataKey = "issue_key"
+	// IssueIDMetadataKey holds the numeric issue ID.
+	IssueIDMetadataKey = "issue_id"
+	// ProjectMetadataKey holds the project key.
+	ProjectMetadataKey = "project"
+	// IssueTypeMetadataKey holds the issue type name.
+	IssueTypeMetadataKey = "issue_type"
+	// StatusMetadataKey holds the status name.
+	StatusMetadataKey = "status"
+	// PriorityMetadataKey holds the priority name.
+	PriorityMetadataKey = "priority"
+	// AssigneeMetadataKey holds the assignee's display name.
+	AssigneeMetadataKey = "assignee"
+	// ReporterMetadataKey holds the reporter's display name.
+	ReporterMetadataKey = "reporter"
+	// CreatedMetadataKey holds the creation time (RFC3339).
+	CreatedMetadataKey = "created"
+)
+
+const jiraPageSize = 50
+
+// jiraFields are the fields every issue is loaded with.
+var jiraFields = []string{
+	"summary", "description", "status", "issuetype", "priority", "assignee",
+	"reporter", "labels", "project", "created", "updated", "comment",
+}
+
+// jiraIssue is a Jira issue with its raw and rendered fields.
+type jiraIssue struct {
+	ID             string                     `json:"id"`
+	Key            string                     `json:"key"`
+	Fields         map[string]json.RawMessage `json:"fields"`
+	RenderedFields struct {
+		Description string `json:"description"`
+		Comment     struct {
+			Comments []struct {
+				Body string `json:"body"`
+			} `json:"comments"`
+		} `json:"comment"`
+	} `json:"renderedFields"`
+}
+
+// jiraComment is a comment in an issue's comment field.
+type jiraComment struct {
+	Author struct {
+		DisplayName string `json:"displayName"`
+	} `json:"author"`
+	Body    string `json:"body"`
+	Created string `json:"created"`
+}
+
+// JiraReader loads Jira issues as Markdown documents, with the description
+// and comments as text and the issue fields as metadata. Issues are
+// selected by project or by a JQL filter; document IDs are issue keys.
+type JiraReader struct {
+	client          *Client
+	projects        []string
+	jql             string
+	fields          []string
+	includeComments bool
+	updatedSince    time.Time
+	overlap         time.Duration
+	extra           map[string]interface{}
+}
+
+// JiraOption configures a JiraReader.
+type JiraOption func(*JiraReader)
+
+// WithProjects loads the issues of the given projects.
+func WithProjects(keys ...string) JiraOption {
+	return func(r *JiraReader) {
+		r.projects = keys
+	}
+}
+
+// WithJQL adds a JQL filter clause, e.g. `status != Done`, combined with the
+// project selection. It must not contain an ORDER BY clause.
+func WithJQL(jql string) JiraOption {
+	return func(r *JiraReader) {
+		r.jql = jql
+	}
+}
+
+// WithJiraFields adds fields, such as custom fields, to the metadata under
+// their field IDs. Objects are reduced to their name or value.
+func WithJiraFields(fieldIDs ...string) JiraOption {
+	return func(r *JiraReader) {
+		r.fields = fieldIDs
+	}
+}
+
+// WithIncludeComments enables or disables adding comments to the text.
+// Enabled by default.
+func WithIncludeComments(include bool) JiraOption {
+	return func(r *JiraReader) {
+		r.includeComments = include
+	}
+}
+
+// WithJiraUpdatedSince makes LoadData return only issues updated after t.
+func WithJiraUpdatedSince(t time.Time) JiraOption {
+	return func(r *JiraReader) {
+		r.updatedSince = t
+	}
+}
+
+// WithJiraSyncOverlap sets how far incremental queries reach back before
+// the requested time. Defaults to DefaultSyncOverlap.
+func WithJiraSyncOverlap(d time.Duration) JiraOption {
+	return func(r *JiraReader) {
+		r.overlap = d
+	}
+}
+
+// WithJiraMetadata sets metadata added to all documents.
+func WithJiraMetadata(metadata map[string]interface{}) JiraOption {
+	return func(r *JiraReader) {
+		r.extra = metadata
+	}
+}
+
+// NewJiraReader creates a Jira reader using client.
+func NewJiraReader(client *Client, opts ...JiraOption) *JiraReader {
+	r := &JiraReader{
+		client:          client,
+		includeComments: true,
+		overlap:         DefaultSyncOverlap,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// LoadData loads the configured issues.
+func (r *JiraReader) LoadData() ([]schema.Node, error) {
+	return r.LoadDataWithContext(context.Background())
+}
+
+// LoadDataWithContext loads the configured issues with context support.
+func (r *JiraReader) LoadDataWithContext(ctx context.Context) ([]schema.Node, error) {
+	docs, _, err := r.LoadUpdated(ctx, r.updatedSince)
+	return docs, err
+}
+
+// LoadUpdated loads the issues updated after since, ordered by update
+// time, and returns the latest update time among them.
+func (r *JiraReader) LoadUpdated(ctx context.Context, since time.Time) ([]schema.Node, time.Time, error) {
+	issues, err := r.search(ctx, since)
+	if err != nil {
+		return nil, since, reader.NewReaderError(r.client.BaseURL(), "failed to search issues", err)
+	}
+
+	checkpoint := since
+	var docs []schema.Node
+	for i := range issues {
+		issue := &issues[i]
+		var updatedRaw string
+		_ = json.Unmarshal(issue.Fields["updated"], &updatedRaw)
+		updated := parseTime(updatedRaw)
+		if !since.IsZero() && !updated.After(since) {
+			continue
+		}
+		doc, err := r.document(issue, updated)
+		if err != nil {
+			return nil, since, reader.NewReaderError(issue.Key, "failed to convert issue", err)
+		}
+		docs = append(docs, doc)
+		if updated.After(checkpoint) {
+			checkpoint = updated
+		}
+	}
+	return docs, checkpoint, nil
+}
+
+// search runs a paginated JQL search for the configured issues.
+func (r *JiraReader) search(ctx context.Context, since time.Time) ([]jiraIssue, error) {
+	var clauses []string
+	if len(r.projects) > 0 {
+		clauses = append(clauses, "project IN ("+quoteList(r.projects)+")")
+	}
+	if r.jql != "" {
+		clauses = append(clauses, "("+r.jql+")")
+	}
+	if !since.IsZero() {
+		clauses = append(clauses, `updated >= "`+queryTime(since, r.overlap)+`"`)
+	}
+	jql := strings.TrimSpace(strings.Join(clauses, " AND ") + " ORDER BY updated ASC")
+
+	var issues []jiraIssue
+	for {
+		query := url.Values{
+			"jql":        {jql},
+			"fields":     {strings.Join(append(append([]string{}, jiraFields...), r.fields...), ",")},
+			"expand":     {"renderedFields"},
+			"startAt":    {strconv.Itoa(len(issues))},
+			"maxResults": {strconv.Itoa(jiraPageSize)},
+		}
+		var resp struct {
+			Total  int         `json:"total"`
+			Issues []jiraIssue `json:"issues"`
+		}
+		if err := r.client.get(ctx, "/rest/api/2/search", query, &resp); err != nil {
+			return nil, err
+		}
+		issues = append(issues, resp.Issues...)
+		if len(resp.Issues) == 0 || len(issues) >= resp.Total {
+			return issues, nil
+		}
+	}
+}
+
+// document converts an issue to a Markdown document.
+func (r *JiraReader) document(issue *jiraIssue, updated time.Time) (schema.Node, error) {
+	var summary string
+	_ = json.Unmarshal(issue.Fields["summary"], &summary)
+	issueURL := r.client.BaseURL() + "/browse/" + issue.Key
+
+	var sb strings.Builder
+	sb.WriteString("# " + issue.Key + ": " + summary)
+	var rawDescription string
+	_ = json.Unmarshal(issue.Fields["description"], &rawDescription)
+	description, err := r.richText(issue.RenderedFields.Description, rawDescription)
+	if err != nil {
+		return schema.Node{}, err
+	}
+	if description != "" {
+		sb.WriteString("\n\n" + description)
+	}
+
+	if r.includeComments {
+		var comments struct {
+			Comments []jiraComment `json:"comments"`
+		}
+		_ = json.Unmarshal(issue.Fields["comment"], &comments)
+		rendered := issue.RenderedFields.Comment.Comments
+		for i, c := range comments.Comments {
+			if i == 0 {
+				sb.WriteString("\n\n## Comments")
+			}
+			html := ""
+			if i < len(rendered) {
+				html = rendered[i].Body
+			}
+			body, err := r.richText(html, c.Body)
+			if err != nil {
+				return schema.Node{}, err
+			}
+			header := "**" + c.Author.DisplayName + "**"
+			if created := parseTime(c.Created); !created.IsZero() {
+				header += " (" + created.UTC().Format("2006-01-02 15:04") + ")"
+			}
+			sb.WriteString("\n\n" + header + ":\n\n" + body)
+		}
+	}
+
+	metadata := map[string]interface{}{
+		IssueKeyMetadataKey: issue.Key,
+		IssueIDMetadataKey:  issue.ID,
+		TitleMetadataKey:    summary,
+		URLMetadataKey:      issueURL,
+		"source":            issueURL,
+	}
+	simpleFields := map[string]string{
+		"project":   ProjectMetadataKey,
+		"issuetype": IssueTypeMetadataKey,
+		"status":    StatusMetadataKey,
+		"priority":  PriorityMetadataKey,
+		"assignee":  AssigneeMetadataKey,
+		"reporter":  ReporterMetadataKey,
+		"labels":    LabelsMetadataKey,
+	}
+	for field, key := range simpleFields {
+		if v := fieldValue(issue.Fields[field]); v != nil {
+			metadata[key] = v
+		}
+	}
+	var createdRaw string
+	_ = json.Unmarshal(issue.Fields["created"], &createdRaw)
+	if created := parseTime(createdRaw); !created.IsZero() {
+		metadata[CreatedMetadataKey] = created.UTC().Format(time.RFC3339)
+	}
+	if !updated.IsZero() {
+		metadata[UpdatedMetadataKey] = updated.UTC().Format(time.RFC3339)
+	}
+	for _, field := range r.fields {
+		if v := fieldValue(issue.Fields[field]); v != nil {
+			metadata[field] = v
+		}
+	}
+	for k, v := range r.extra {
+		metadata[k] = v
+	}
+
+	return schema.Node{
+		ID:       issue.Key,
+		Text:     sb.String(),
+		Type:     schema.ObjectTypeDocument,
+		Metadata: metadata,
+		MimeType: "text/markdown",
+	}, nil
+}
+
+// richText converts rendered HTML to Markdown, falling back to the raw
+// wiki markup when the field was not rendered.
+func (r *JiraReader) richText(html, raw string) (string, error) {
+	if strings.TrimSpace(html) != "" {
+		return reader.HTMLToMarkdown(html, r.client.BaseURL()+"/")
+	}
+	return strings.TrimSpace(raw), nil
+}
+
+// fieldValue reduces a field value to a string, number, boolean or list.
+// Objects such as users, statuses and options are reduced to their display
+// name, name, value or key.
+func fieldValue(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil
+	}
+	return simpleValue(v)
+}
+
+func simpleValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return v
+	case float64, bool:
+		return v
+	case map[string]interface{}:
+		for _, key := range []string{"displayName", "name", "value", "key"} {
+			if s, ok := v[key].(string); ok && s != "" {
+				return s
+			}
+		}
+	case []interface{}:
+		var strs []string
+		var values []interface{}
+		allStrings := true
+		for _, item := range v {
+			sv := simpleValue(item)
+			if sv == nil {
+				continue
+			}
+			s, ok := sv.(string)
+			allStrings = allStrings && ok
+			strs = append(strs, s)
+			values = append(values, sv)
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		if allStrings {
+			return strs
+		}
+		return values
+	}
+	return nil
+}
+
+// Metadata returns reader metadata.
+func (r *JiraReader) Metadata() reader.ReaderMetadata {
+	return reader.ReaderMetadata{
+		Name:        "JiraReader",
+		Description: "Loads Jira issues as Markdown documents with their fields as metadata",
+	}
+}
+
+// Ensure JiraReader implements the reader interfaces.
+var (
+	_ reader.ReaderWithContext  = (*JiraReader)(nil)
+	_ reader.ReaderWithMetadata = (*JiraReader)(nil)
+	_ IncrementalReader         = (*JiraReader)(nil)
+)
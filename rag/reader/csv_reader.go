@@ -117,6 +117,12 @@ func (r *CSVReader) LoadFromFile(filePath string) ([]schema.Node, error) {
 	return r.loadFile(filePath)
 }
 
+// LoadFromReader loads CSV content from src. name is used like a file
+// path, for document IDs and to detect TSV by extension.
+func (r *CSVReader) LoadFromReader(src io.Reader, name string) ([]schema.Node, error) {
+	return r.loadReader(src, name)
+}
+
 // Metadata returns reader metadata.
 func (r *CSVReader) Metadata() ReaderMetadata {
 	return ReaderMetadata{
@@ -167,13 +173,17 @@ func (r *CSVReader) loadFile(filePath string) ([]schema.Node, error) {
 	}
 	defer file.Close()
 
+	return r.loadReader(file, filePath)
+}
+
+func (r *CSVReader) loadReader(src io.Reader, filePath string) ([]schema.Node, error) {
 	// Detect delimiter from extension if not explicitly set
 	delimiter := r.Delimiter
 	if delimiter == ',' && strings.ToLower(filepath.Ext(filePath)) == ".tsv" {
 		delimiter = '\t'
 	}
 
-	reader := csv.NewReader(file)
+	reader := csv.NewReader(src)
 	reader.Comma = delimiter
 	reader.LazyQuotes = true
 	reader.TrimLeadingSpace = true
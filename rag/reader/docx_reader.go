@@ -101,6 +101,41 @@ func (r *DocxReader) LoadFromFile(filePath string) ([]schema.Node, error) {
 	return r.loadFile(filePath)
 }
 
+// LoadDocument loads a DOCX file as one Document holding its text and the
+// original DOCX bytes. Images are not extracted.
+func (r *DocxReader) LoadDocument(filePath string) (*schema.Document, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DOCX file: %w", err)
+	}
+
+	textOnly := *r
+	textOnly.ExtractImages = false
+	nodes, err := textOnly.LoadFromBytes(content, filePath)
+	if err != nil {
+		return nil, err
+	}
+	nodes[0].Metadata["filename"] = filepath.Base(filePath)
+
+	return &schema.Document{
+		ID:       filePath,
+		Text:     nodes[0].Text,
+		Metadata: nodes[0].Metadata,
+		Data:     content,
+		MimeType: schema.MimeTypeDocx,
+	}, nil
+}
+
+// LoadFromReader loads a DOCX from src. name is used as the document
+// source.
+func (r *DocxReader) LoadFromReader(src io.Reader, name string) ([]schema.Node, error) {
+	content, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content: %w", err)
+	}
+	return r.LoadFromBytes(content, name)
+}
+
 // Metadata returns reader metadata.
 func (r *DocxReader) Metadata() ReaderMetadata {
 	return ReaderMetadata{
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/aqua777/go-llamaindex/schema"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -359,6 +360,33 @@ func TestDocxReader_LoadFromBytes(t *testing.T) {
 	assert.Equal(t, "memory://test.docx", docs[0].Metadata["source"])
 }
 
+func TestDocxReader_LoadDocument(t *testing.T) {
+	tmpDir := t.TempDir()
+	docxPath := filepath.Join(tmpDir, "report.docx")
+	createTestDocxFile(t, docxPath, []string{"Quarterly results"})
+
+	content, err := os.ReadFile(docxPath)
+	require.NoError(t, err)
+
+	doc, err := NewDocxReader().LoadDocument(docxPath)
+	require.NoError(t, err)
+	assert.Contains(t, doc.Text, "Quarterly results")
+	assert.Equal(t, content, doc.Data)
+	assert.Equal(t, schema.MimeTypeDocx, doc.MimeType)
+	assert.Equal(t, "report.docx", doc.Metadata["filename"])
+
+	docs, err := NewSimpleDirectoryReader(tmpDir, ".docx").
+		WithFileReader(".docx", NewDocxReader()).
+		LoadDocuments()
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	assert.Contains(t, docs[0].Text, "Quarterly results")
+	assert.Equal(t, content, docs[0].Data)
+	assert.Equal(t, docxPath, docs[0].Metadata["path"])
+
+	var _ DocumentFileReader = (*DocxReader)(nil)
+}
+
 func TestDocxReader_EmptyDocument(t *testing.T) {
 	tmpDir := t.TempDir()
 	docxPath := filepath.Join(tmpDir, "empty.docx")
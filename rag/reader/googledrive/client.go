@@ -0,0 +1,206 @@
+// Package googledrive provides a reader that loads Google Drive files
+// through the Drive v3 REST API, exporting Google Docs, Sheets and Slides
+// to text, with incremental sync through Drive change tokens.
+package googledrive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/internal/httputil"
+)
+
+// DefaultEndpoint is the Google APIs endpoint.
+const DefaultEndpoint = "https://www.googleapis.com"
+
+// DefaultMaxRetries is the default number of retries of rate-limited
+// requests and server errors.
+const DefaultMaxRetries = httputil.DefaultMaxRetries
+
+// APIError is an error response of the Drive API.
+type APIError struct {
+	// Status is the HTTP status code.
+	Status int
+	// Reason is the reason of the first error, e.g. "notFound" or
+	// "exportSizeLimitExceeded".
+	Reason string
+	// Message is the error message.
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("drive API error %d (%s): %s", e.Status, e.Reason, e.Message)
+	}
+	return fmt.Sprintf("drive API error %d: %s", e.Status, e.Message)
+}
+
+// Client sends authenticated requests to the Drive API. It authenticates
+// with an OAuth 2.0 access token for a user or service account with a
+// Drive scope such as drive.readonly.
+type Client struct {
+	endpoint    string
+	tokenSource func(ctx context.Context) (string, error)
+	client      *http.Client
+	maxRetries  int
+}
+
+// ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// WithToken authenticates with a static access token. Defaults to the
+// GOOGLE_OAUTH_ACCESS_TOKEN environment variable.
+func WithToken(token string) ClientOption {
+	return func(c *Client) {
+		c.tokenSource = httputil.StaticToken(token)
+	}
+}
+
+// WithTokenSource authenticates with a token fetched before every request,
+// so that expiring tokens can be refreshed.
+func WithTokenSource(source func(ctx context.Context) (string, error)) ClientOption {
+	return func(c *Client) {
+		c.tokenSource = source
+	}
+}
+
+// WithEndpoint sets the API endpoint, e.g. for a test server.
+func WithEndpoint(endpoint string) ClientOption {
+	return func(c *Client) {
+		c.endpoint = strings.TrimRight(endpoint, "/")
+	}
+}
+
+// WithHTTPClient sets the HTTP client.
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *Client) {
+		c.client = client
+	}
+}
+
+// WithMaxRetries sets the number of retries of rate-limited requests and
+// server errors.
+func WithMaxRetries(n int) ClientOption {
+	return func(c *Client) {
+		c.maxRetries = n
+	}
+}
+
+// NewClient creates a Drive API client.
+func NewClient(opts ...ClientOption) *Client {
+	c := &Client{
+		endpoint:    DefaultEndpoint,
+		tokenSource: httputil.StaticToken(os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")),
+		client:      http.DefaultClient,
+		maxRetries:  DefaultMaxRetries,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// getJSON sends a GET request for a path below /drive/v3 and decodes the
+// response into out.
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, out interface{}) error {
+	body, err := c.get(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// get sends a GET request for a path below /drive/v3 and returns the
+// response body, retrying rate-limited requests and server errors with
+// exponential backoff.
+func (c *Client) get(ctx context.Context, path string, query url.Values) (io.ReadCloser, error) {
+	token, err := c.tokenSource(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+	if token == "" {
+		return nil, fmt.Errorf("google drive access token not set")
+	}
+	target := c.endpoint + "/drive/v3" + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp.Body, nil
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		apiErr := newAPIError(resp.StatusCode, data)
+		if apiErr.retryable() && attempt < c.maxRetries {
+			if err := httputil.Sleep(ctx, httputil.RetryDelay(resp.Header.Get("Retry-After"), attempt)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return nil, apiErr
+	}
+}
+
+// newAPIError reads a Google API error response.
+func newAPIError(status int, data []byte) *APIError {
+	apiErr := &APIError{Status: status}
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+			Errors  []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil {
+		apiErr.Message = body.Error.Message
+		if len(body.Error.Errors) > 0 {
+			apiErr.Reason = body.Error.Errors[0].Reason
+		}
+	}
+	if apiErr.Message == "" {
+		if text := strings.TrimSpace(string(data)); text != "" {
+			apiErr.Message = text
+		} else {
+			apiErr.Message = http.StatusText(status)
+		}
+	}
+	return apiErr
+}
+
+// retryable reports whether a request may succeed when retried. Drive
+// reports exceeded quotas as 403 with a rate limit reason.
+func (e *APIError) retryable() bool {
+	switch {
+	case e.Status == http.StatusTooManyRequests || e.Status >= 500:
+		return true
+	case e.Status == http.StatusForbidden:
+		return e.Reason == "rateLimitExceeded" || e.Reason == "userRateLimitExceeded"
+	}
+	return false
+}
@@ -0,0 +1,490 @@
+package googledrive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/rag/reader"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// Google Workspace MIME types.
+const (
+	// FolderMimeType is the MIME type of folders.
+	FolderMimeType = "application/vnd.google-apps.folder"
+	// DocumentMimeType is the MIME type of Google Docs.
+	DocumentMimeType = "application/vnd.google-apps.document"
+	// SpreadsheetMimeType is the MIME type of Google Sheets.
+	SpreadsheetMimeType = "application/vnd.google-apps.spreadsheet"
+	// PresentationMimeType is the MIME type of Google Slides.
+	PresentationMimeType = "application/vnd.google-apps.presentation"
+)
+
+// workspacePrefix is the MIME type prefix of Google Workspace files, which
+// have no content of their own and can only be exported.
+const workspacePrefix = "application/vnd.google-apps."
+
+// Metadata keys set on Drive documents, in addition to
+// reader.FileNameMetadataKey, reader.LastModifiedMetadataKey,
+// reader.URLMetadataKey and, for uploaded files, reader.FileSizeMetadataKey.
+const (
+	// FileIDMetadataKey holds the Drive file ID.
+	FileIDMetadataKey = "file_id"
+	// MimeTypeMetadataKey holds the file's Drive MIME type.
+	MimeTypeMetadataKey = "mime_type"
+	// FolderPathMetadataKey holds the path of the file's folder relative to
+	// the loaded folder, e.g. "Runbooks/Databases". It is not set for files
+	// directly in a loaded folder.
+	FolderPathMetadataKey = "folder_path"
+	// DriveIDMetadataKey holds the ID of the shared drive the file is in.
+	DriveIDMetadataKey = "drive_id"
+)
+
+// DefaultMaxFileSize is the default size limit of downloaded files.
+const DefaultMaxFileSize = 50 << 20
+
+// fileFields are the file fields requested from the API.
+const fileFields = "id,name,mimeType,modifiedTime,size,webViewLink,parents,driveId,trashed"
+
+const listPageSize = 1000
+
+// DefaultExportFormats returns the formats Google Workspace files are
+// exported to: Docs and Slides as plain text and Sheets as CSV. Drive
+// exports only the first sheet of a spreadsheet as CSV.
+func DefaultExportFormats() map[string]string {
+	return map[string]string{
+		DocumentMimeType:     "text/plain",
+		SpreadsheetMimeType:  "text/csv",
+		PresentationMimeType: "text/plain",
+	}
+}
+
+// driveFile is a Drive file resource.
+type driveFile struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	MimeType     string   `json:"mimeType"`
+	ModifiedTime string   `json:"modifiedTime"`
+	Size         string   `json:"size"`
+	WebViewLink  string   `json:"webViewLink"`
+	Parents      []string `json:"parents"`
+	DriveID      string   `json:"driveId"`
+	Trashed      bool     `json:"trashed"`
+}
+
+// folder is a folder in the reader's scope.
+type folder struct {
+	// path is the folder's path relative to the loaded folder it is in.
+	path string
+	// parent is the ID of the parent folder, empty for loaded folders.
+	parent string
+}
+
+// Reader loads Google Drive files as documents whose IDs are Drive file
+// IDs, so that a docstore with the upserts strategy replaces changed files.
+// Google Docs, Sheets and Slides are exported to text; other files are
+// downloaded and dispatched by extension to file readers, like
+// reader.SimpleDirectoryReader does for local files. Shortcuts, forms and
+// files without a reader or a text MIME type are skipped.
+//
+// By default the reader loads My Drive, or the whole shared drive when
+// WithSharedDrive is set, recursing into folders.
+type Reader struct {
+	client        *Client
+	folderIDs     []string
+	fileIDs       []string
+	driveID       string
+	recursive     bool
+	exportFormats map[string]string
+	fileReaders   map[string]reader.FileReader
+	maxFileSize   int64
+	extra         map[string]interface{}
+
+	mu sync.Mutex
+	// folders are the folders in scope as of the last load, by ID.
+	folders map[string]folder
+}
+
+// Option configures a Reader.
+type Option func(*Reader)
+
+// WithFolderIDs loads the files in the given folders.
+func WithFolderIDs(ids ...string) Option {
+	return func(r *Reader) {
+		r.folderIDs = ids
+	}
+}
+
+// WithFileIDs loads the given files, in addition to the files of the
+// folders set with WithFolderIDs.
+func WithFileIDs(ids ...string) Option {
+	return func(r *Reader) {
+		r.fileIDs = ids
+	}
+}
+
+// WithSharedDrive reads the shared drive with the given ID. Without folder
+// or file IDs, the whole drive is loaded.
+func WithSharedDrive(driveID string) Option {
+	return func(r *Reader) {
+		r.driveID = driveID
+	}
+}
+
+// WithRecursive sets whether the files of subfolders are loaded. Defaults
+// to true.
+func WithRecursive(recursive bool) Option {
+	return func(r *Reader) {
+		r.recursive = recursive
+	}
+}
+
+// WithExportFormat sets the MIME type Google Workspace files of type
+// mimeType are exported to, e.g. "text/markdown" for DocumentMimeType. An
+// empty export type skips those files. The defaults are
+// DefaultExportFormats.
+func WithExportFormat(mimeType, exportMimeType string) Option {
+	return func(r *Reader) {
+		if exportMimeType == "" {
+			delete(r.exportFormats, mimeType)
+		} else {
+			r.exportFormats[mimeType] = exportMimeType
+		}
+	}
+}
+
+// WithFileReader registers a reader for uploaded files with extension ext.
+// A nil reader removes the registration. The defaults are
+// reader.DefaultFileReaders.
+func WithFileReader(ext string, fileReader reader.FileReader) Option {
+	return func(r *Reader) {
+		ext = strings.ToLower(ext)
+		if fileReader == nil {
+			delete(r.fileReaders, ext)
+		} else {
+			r.fileReaders[ext] = fileReader
+		}
+	}
+}
+
+// WithMaxFileSize skips uploaded files larger than size bytes. Defaults to
+// DefaultMaxFileSize; zero disables the limit.
+func WithMaxFileSize(size int64) Option {
+	return func(r *Reader) {
+		r.maxFileSize = size
+	}
+}
+
+// WithMetadata sets metadata added to all documents.
+func WithMetadata(metadata map[string]interface{}) Option {
+	return func(r *Reader) {
+		r.extra = metadata
+	}
+}
+
+// NewReader creates a Google Drive reader using client.
+func NewReader(client *Client, opts ...Option) *Reader {
+	r := &Reader{
+		client:        client,
+		recursive:     true,
+		exportFormats: DefaultExportFormats(),
+		fileReaders:   reader.DefaultFileReaders(),
+		maxFileSize:   DefaultMaxFileSize,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// LoadData loads the configured files.
+func (r *Reader) LoadData() ([]schema.Node, error) {
+	return r.LoadDataWithContext(context.Background())
+}
+
+// LoadDataWithContext loads the configured files with context support.
+func (r *Reader) LoadDataWithContext(ctx context.Context) ([]schema.Node, error) {
+	folders := make(map[string]folder)
+	seen := make(map[string]bool)
+	var docs []schema.Node
+	load := func(f driveFile, folderPath string) error {
+		if seen[f.ID] {
+			return nil
+		}
+		seen[f.ID] = true
+		doc, ok, err := r.document(ctx, f, folderPath)
+		if err != nil || !ok {
+			return err
+		}
+		docs = append(docs, doc)
+		return nil
+	}
+
+	roots, err := r.rootIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range roots {
+		if err := r.walk(ctx, id, folder{}, folders, load); err != nil {
+			return nil, reader.NewReaderError(id, "failed to list folder", err)
+		}
+	}
+	for _, id := range r.fileIDs {
+		var f driveFile
+		if err := r.client.getJSON(ctx, "/files/"+url.PathEscape(id), r.fileQuery(), &f); err != nil {
+			return nil, reader.NewReaderError(id, "failed to get file", err)
+		}
+		if err := load(f, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	r.folders = folders
+	r.mu.Unlock()
+	return docs, nil
+}
+
+// rootIDs returns the IDs of the folders to load. The "root" alias of My
+// Drive is resolved, as changes refer to parents by their actual ID.
+func (r *Reader) rootIDs(ctx context.Context) ([]string, error) {
+	switch {
+	case len(r.folderIDs) > 0:
+		return r.folderIDs, nil
+	case len(r.fileIDs) > 0:
+		return nil, nil
+	case r.driveID != "":
+		// The ID of a shared drive is also the ID of its root folder.
+		return []string{r.driveID}, nil
+	}
+	var root driveFile
+	if err := r.client.getJSON(ctx, "/files/root", url.Values{"fields": {"id"}}, &root); err != nil {
+		return nil, reader.NewReaderError("root", "failed to get My Drive root folder", err)
+	}
+	return []string{root.ID}, nil
+}
+
+// walk adds a folder and, when recursive, its subfolders to folders and
+// calls fn for the files in them. fn may be nil to only collect folders.
+func (r *Reader) walk(ctx context.Context, id string, root folder, folders map[string]folder, fn func(driveFile, string) error) error {
+	folders[id] = root
+	queue := []string{id}
+	for len(queue) > 0 {
+		parentID := queue[0]
+		queue = queue[1:]
+		parent := folders[parentID]
+
+		q := fmt.Sprintf("'%s' in parents and trashed = false", strings.ReplaceAll(parentID, "'", `\'`))
+		if fn == nil {
+			q += " and mimeType = '" + FolderMimeType + "'"
+		}
+		err := r.listFiles(ctx, q, func(f driveFile) error {
+			if f.MimeType == FolderMimeType {
+				if r.recursive {
+					folders[f.ID] = folder{path: path.Join(parent.path, f.Name), parent: parentID}
+					queue = append(queue, f.ID)
+				}
+				return nil
+			}
+			if fn == nil {
+				return nil
+			}
+			return fn(f, parent.path)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listFiles runs a paginated files.list query.
+func (r *Reader) listFiles(ctx context.Context, q string, fn func(driveFile) error) error {
+	query := r.fileQuery()
+	query.Set("q", q)
+	query.Set("fields", "nextPageToken,files("+fileFields+")")
+	query.Set("pageSize", strconv.Itoa(listPageSize))
+	query.Set("includeItemsFromAllDrives", "true")
+	if r.driveID != "" {
+		query.Set("corpora", "drive")
+		query.Set("driveId", r.driveID)
+	}
+	for {
+		var page struct {
+			NextPageToken string      `json:"nextPageToken"`
+			Files         []driveFile `json:"files"`
+		}
+		if err := r.client.getJSON(ctx, "/files", query, &page); err != nil {
+			return err
+		}
+		for _, f := range page.Files {
+			if err := fn(f); err != nil {
+				return err
+			}
+		}
+		if page.NextPageToken == "" {
+			return nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// fileQuery returns the query parameters of single-file requests.
+func (r *Reader) fileQuery() url.Values {
+	return url.Values{
+		"fields":            {fileFields},
+		"supportsAllDrives": {"true"},
+	}
+}
+
+// document converts a file to a document. It reports false for files that
+// are skipped.
+func (r *Reader) document(ctx context.Context, f driveFile, folderPath string) (schema.Node, bool, error) {
+	text, mimeType, ok, err := r.content(ctx, f)
+	if err != nil {
+		return schema.Node{}, false, reader.NewReaderError(f.ID, "failed to load file "+strconv.Quote(f.Name), err)
+	}
+	if !ok {
+		return schema.Node{}, false, nil
+	}
+
+	metadata := map[string]interface{}{
+		FileIDMetadataKey:          f.ID,
+		MimeTypeMetadataKey:        f.MimeType,
+		reader.FileNameMetadataKey: f.Name,
+	}
+	if t, err := time.Parse(time.RFC3339Nano, f.ModifiedTime); err == nil {
+		metadata[reader.LastModifiedMetadataKey] = t.UTC().Format(time.RFC3339)
+	}
+	if f.WebViewLink != "" {
+		metadata[reader.URLMetadataKey] = f.WebViewLink
+	}
+	if size, err := strconv.ParseInt(f.Size, 10, 64); err == nil {
+		metadata[reader.FileSizeMetadataKey] = size
+	}
+	if folderPath != "" {
+		metadata[FolderPathMetadataKey] = folderPath
+	}
+	if f.DriveID != "" {
+		metadata[DriveIDMetadataKey] = f.DriveID
+	}
+	for k, v := range r.extra {
+		metadata[k] = v
+	}
+
+	return schema.Node{
+		ID:       f.ID,
+		Text:     text,
+		Type:     schema.ObjectTypeDocument,
+		Metadata: metadata,
+		MimeType: mimeType,
+	}, true, nil
+}
+
+// content exports or downloads a file and returns its text and MIME type.
+func (r *Reader) content(ctx context.Context, f driveFile) (string, string, bool, error) {
+	if strings.HasPrefix(f.MimeType, workspacePrefix) {
+		exportType, ok := r.exportFormats[f.MimeType]
+		if !ok {
+			return "", "", false, nil
+		}
+		body, err := r.client.get(ctx, "/files/"+url.PathEscape(f.ID)+"/export", url.Values{"mimeType": {exportType}})
+		if err != nil {
+			return "", "", false, err
+		}
+		defer body.Close()
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return "", "", false, fmt.Errorf("failed to read export: %w", err)
+		}
+		return strings.TrimPrefix(string(data), "\ufeff"), exportType, true, nil
+	}
+
+	if size, err := strconv.ParseInt(f.Size, 10, 64); err == nil && r.maxFileSize > 0 && size > r.maxFileSize {
+		return "", "", false, nil
+	}
+	ext := strings.ToLower(path.Ext(f.Name))
+	fileReader := r.fileReaders[ext]
+	if fileReader == nil && !isText(f.MimeType, ext) {
+		return "", "", false, nil
+	}
+
+	body, err := r.client.get(ctx, "/files/"+url.PathEscape(f.ID), url.Values{"alt": {"media"}, "supportsAllDrives": {"true"}})
+	if err != nil {
+		return "", "", false, err
+	}
+	defer body.Close()
+
+	var nodes []schema.Node
+	switch fr := fileReader.(type) {
+	case nil:
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return "", "", false, fmt.Errorf("failed to download file: %w", err)
+		}
+		return string(data), f.MimeType, true, nil
+	case reader.StreamFileReader:
+		nodes, err = fr.LoadFromReader(body, f.Name)
+	default:
+		nodes, err = loadViaTempFile(fr, body, ext)
+	}
+	if err != nil {
+		return "", "", false, err
+	}
+	// Readers may split a file, e.g. a PDF by page; the document keeps the
+	// whole file under its file ID.
+	texts := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		if strings.TrimSpace(n.Text) != "" {
+			texts = append(texts, n.Text)
+		}
+	}
+	return strings.Join(texts, "\n\n"), f.MimeType, true, nil
+}
+
+// isText reports whether an uploaded file without a reader can be loaded
+// as text.
+func isText(mimeType, ext string) bool {
+	return strings.HasPrefix(mimeType, "text/") || ext == ".txt" || ext == ".md"
+}
+
+// loadViaTempFile copies a download to a temporary file for a reader that
+// only reads local files.
+func loadViaTempFile(fileReader reader.FileReader, body io.Reader, ext string) ([]schema.Node, error) {
+	f, err := os.CreateTemp("", "googledrive-*"+ext)
+	if err != nil {
+		return nil, err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	_, err = io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	return fileReader.LoadFromFile(tmp)
+}
+
+// Metadata returns reader metadata.
+func (r *Reader) Metadata() reader.ReaderMetadata {
+	return reader.ReaderMetadata{
+		Name:        "GoogleDriveReader",
+		Description: "Loads Google Drive files, exporting Docs, Sheets and Slides to text",
+	}
+}
+
+// Ensure Reader implements the reader interfaces.
+var (
+	_ reader.ReaderWithContext  = (*Reader)(nil)
+	_ reader.ReaderWithMetadata = (*Reader)(nil)
+)
@@ -0,0 +1,362 @@
+package googledrive
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aqua777/go-llamaindex/ingestion"
+	"github.com/aqua777/go-llamaindex/rag/reader"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDrive serves a Drive from an in-memory file tree. Listings are paged
+// two files at a time and the first request is rate limited.
+type fakeDrive struct {
+	mu       sync.Mutex
+	files    map[string]driveFile
+	content  map[string]string
+	changes  []change
+	exports  []string
+	queries  []queryLog
+	requests int
+}
+
+// queryLog records the path and shared drive parameters of a request.
+type queryLog map[string]string
+
+var parentQuery = regexp.MustCompile(`'([^']+)' in parents`)
+
+func newFakeDrive() *fakeDrive {
+	d := &fakeDrive{files: map[string]driveFile{}, content: map[string]string{}}
+	d.add(driveFile{ID: "my-root", Name: "My Drive", MimeType: FolderMimeType}, "")
+	d.add(driveFile{ID: "docs", Name: "Docs", MimeType: FolderMimeType, Parents: []string{"my-root"}}, "")
+	d.add(driveFile{ID: "doc1", Name: "Onboarding", MimeType: DocumentMimeType, Parents: []string{"docs"}}, "\ufeffWelcome aboard.")
+	d.add(driveFile{ID: "sheet1", Name: "Budget", MimeType: SpreadsheetMimeType, Parents: []string{"docs"}}, "item,cost\nlaptop,1200\n")
+	d.add(driveFile{ID: "notes", Name: "notes.txt", MimeType: "text/plain", Size: "11", Parents: []string{"docs"}}, "plain notes")
+	d.add(driveFile{ID: "logo", Name: "logo.png", MimeType: "image/png", Size: "4", Parents: []string{"docs"}}, "\x89PNG")
+	d.add(driveFile{ID: "form1", Name: "Survey", MimeType: "application/vnd.google-apps.form", Parents: []string{"docs"}}, "")
+	d.add(driveFile{ID: "sub", Name: "Decks", MimeType: FolderMimeType, Parents: []string{"docs"}}, "")
+	d.add(driveFile{ID: "slides1", Name: "Roadmap", MimeType: PresentationMimeType, Parents: []string{"sub"}}, "Q1: ship it")
+	d.add(driveFile{ID: "data", Name: "data.csv", MimeType: "text/csv", Size: "20", Parents: []string{"sub"}}, "name,role\nann,lead\n")
+	return d
+}
+
+func (d *fakeDrive) add(f driveFile, content string) {
+	f.ModifiedTime = "2025-03-01T10:00:00.000Z"
+	f.WebViewLink = "https://drive.google.com/file/d/" + f.ID
+	d.files[f.ID] = f
+	d.content[f.ID] = content
+}
+
+func (d *fakeDrive) serve(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.requests++
+		if d.requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"code":403,"message":"Rate limit","errors":[{"reason":"userRateLimitExceeded"}]}}`))
+			return
+		}
+		if req.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		query := req.URL.Query()
+		d.queries = append(d.queries, queryLog{"path": req.URL.Path, "corpora": query.Get("corpora"), "driveId": query.Get("driveId")})
+		p := strings.TrimPrefix(req.URL.Path, "/drive/v3")
+		switch {
+		case p == "/files/root":
+			json.NewEncoder(w).Encode(map[string]string{"id": "my-root"})
+		case p == "/files":
+			d.list(w, query)
+		case strings.HasSuffix(p, "/export"):
+			id := strings.TrimSuffix(strings.TrimPrefix(p, "/files/"), "/export")
+			d.exports = append(d.exports, id+":"+query.Get("mimeType"))
+			w.Write([]byte(d.content[id]))
+		case strings.HasPrefix(p, "/files/"):
+			id := strings.TrimPrefix(p, "/files/")
+			f, ok := d.files[id]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error":{"code":404,"message":"File not found","errors":[{"reason":"notFound"}]}}`))
+				return
+			}
+			if query.Get("alt") == "media" {
+				w.Write([]byte(d.content[id]))
+				return
+			}
+			json.NewEncoder(w).Encode(f)
+		case p == "/changes/startPageToken":
+			json.NewEncoder(w).Encode(map[string]string{"startPageToken": "t1"})
+		case p == "/changes":
+			// The changes are split over two pages.
+			half := len(d.changes) / 2
+			switch query.Get("pageToken") {
+			case "t1":
+				json.NewEncoder(w).Encode(map[string]interface{}{"changes": d.changes[:half], "nextPageToken": "t1b"})
+			case "t1b":
+				json.NewEncoder(w).Encode(map[string]interface{}{"changes": d.changes[half:], "newStartPageToken": "t2"})
+			default:
+				json.NewEncoder(w).Encode(map[string]interface{}{"changes": []change{}, "newStartPageToken": "t2"})
+			}
+		default:
+			t.Errorf("unexpected request %s", req.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// list serves files.list for "'<id>' in parents" queries.
+func (d *fakeDrive) list(w http.ResponseWriter, query map[string][]string) {
+	q := query["q"][0]
+	parent := parentQuery.FindStringSubmatch(q)[1]
+	var files []driveFile
+	for _, f := range d.files {
+		if len(f.Parents) == 0 || f.Parents[0] != parent || f.Trashed {
+			continue
+		}
+		if strings.Contains(q, "mimeType = ") && f.MimeType != FolderMimeType {
+			continue
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	offset := 0
+	if tokens := query["pageToken"]; len(tokens) > 0 {
+		offset, _ = strconv.Atoi(tokens[0])
+	}
+	page := map[string]interface{}{}
+	end := offset + 2
+	if end < len(files) {
+		page["nextPageToken"] = strconv.Itoa(end)
+	} else {
+		end = len(files)
+	}
+	page["files"] = files[offset:end]
+	json.NewEncoder(w).Encode(page)
+}
+
+func nodeIDs(nodes []schema.Node) []string {
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.ID
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestReaderLoadData(t *testing.T) {
+	d := newFakeDrive()
+	srv := d.serve(t)
+	client := NewClient(WithEndpoint(srv.URL), WithToken("tok"))
+
+	t.Run("loads My Drive recursively", func(t *testing.T) {
+		r := NewReader(client, WithMetadata(map[string]interface{}{"source": "drive"}))
+		docs, err := r.LoadData()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"data", "doc1", "notes", "sheet1", "slides1"}, nodeIDs(docs))
+
+		byID := map[string]schema.Node{}
+		for _, doc := range docs {
+			byID[doc.ID] = doc
+		}
+		doc := byID["doc1"]
+		assert.Equal(t, "Welcome aboard.", doc.Text)
+		assert.Equal(t, "text/plain", doc.MimeType)
+		assert.Equal(t, schema.ObjectTypeDocument, doc.Type)
+		assert.Equal(t, "doc1", doc.Metadata[FileIDMetadataKey])
+		assert.Equal(t, DocumentMimeType, doc.Metadata[MimeTypeMetadataKey])
+		assert.Equal(t, "Onboarding", doc.Metadata[reader.FileNameMetadataKey])
+		assert.Equal(t, "2025-03-01T10:00:00Z", doc.Metadata[reader.LastModifiedMetadataKey])
+		assert.Equal(t, "https://drive.google.com/file/d/doc1", doc.Metadata[reader.URLMetadataKey])
+		assert.Equal(t, "Docs", doc.Metadata[FolderPathMetadataKey])
+		assert.Equal(t, "drive", doc.Metadata["source"])
+
+		assert.Equal(t, "item,cost\nlaptop,1200\n", byID["sheet1"].Text)
+		assert.Equal(t, "text/csv", byID["sheet1"].MimeType)
+		assert.Equal(t, "plain notes", byID["notes"].Text)
+		assert.Equal(t, int64(11), byID["notes"].Metadata[reader.FileSizeMetadataKey])
+		assert.Equal(t, "Docs/Decks", byID["slides1"].Metadata[FolderPathMetadataKey])
+		assert.Contains(t, byID["data"].Text, "ann")
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		assert.ElementsMatch(t, []string{"doc1:text/plain", "sheet1:text/csv", "slides1:text/plain"}, d.exports)
+	})
+
+	t.Run("loads folders and files", func(t *testing.T) {
+		r := NewReader(client, WithFolderIDs("docs"), WithRecursive(false), WithFileIDs("data"),
+			WithExportFormat(SpreadsheetMimeType, ""), WithExportFormat(DocumentMimeType, "text/markdown"))
+		docs, err := r.LoadDataWithContext(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"data", "doc1", "notes"}, nodeIDs(docs))
+		for _, doc := range docs {
+			assert.NotContains(t, doc.Metadata, FolderPathMetadataKey)
+			if doc.ID == "doc1" {
+				assert.Equal(t, "text/markdown", doc.MimeType)
+			}
+		}
+	})
+
+	t.Run("skips large files", func(t *testing.T) {
+		r := NewReader(client, WithFolderIDs("sub"), WithMaxFileSize(10))
+		docs, err := r.LoadData()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"slides1"}, nodeIDs(docs))
+	})
+
+	t.Run("reads shared drives", func(t *testing.T) {
+		d.mu.Lock()
+		d.queries = nil
+		d.mu.Unlock()
+		r := NewReader(client, WithSharedDrive("docs"), WithRecursive(false))
+		docs, err := r.LoadData()
+		require.NoError(t, err)
+		assert.Len(t, docs, 3)
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		require.NotEmpty(t, d.queries)
+		assert.Equal(t, queryLog{"path": "/drive/v3/files", "corpora": "drive", "driveId": "docs"}, d.queries[0])
+	})
+
+	t.Run("reports API errors", func(t *testing.T) {
+		r := NewReader(client, WithFileIDs("missing"))
+		_, err := r.LoadData()
+		require.Error(t, err)
+		var apiErr *APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusNotFound, apiErr.Status)
+		assert.Equal(t, "notFound", apiErr.Reason)
+	})
+}
+
+func TestReaderLoadChanges(t *testing.T) {
+	d := newFakeDrive()
+	srv := d.serve(t)
+	r := NewReader(NewClient(WithEndpoint(srv.URL), WithToken("tok")), WithFolderIDs("docs"))
+
+	token, err := r.StartPageToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "t1", token)
+	_, err = r.LoadData()
+	require.NoError(t, err)
+
+	edited := d.files["doc1"]
+	d.content["doc1"] = "Welcome aboard, again."
+	trashed := d.files["notes"]
+	trashed.Trashed = true
+	// A folder from elsewhere is moved into scope with its file, and the
+	// decks folder is moved out.
+	d.add(driveFile{ID: "archive", Name: "Archive", MimeType: FolderMimeType, Parents: []string{"docs"}}, "")
+	d.add(driveFile{ID: "old", Name: "old.md", MimeType: "text/markdown", Parents: []string{"archive"}}, "# Old")
+	movedOut := d.files["sub"]
+	movedOut.Parents = []string{"my-root"}
+	d.files["sub"] = movedOut
+	outside := driveFile{ID: "elsewhere", Name: "x.txt", MimeType: "text/plain", Parents: []string{"my-root"}}
+	d.changes = []change{
+		{ChangeType: "file", FileID: "doc1", File: &edited},
+		{ChangeType: "file", FileID: "notes", File: &trashed},
+		{ChangeType: "file", FileID: "sub", File: &movedOut},
+		{ChangeType: "file", FileID: "elsewhere", File: &outside},
+		{ChangeType: "drive"},
+		{ChangeType: "file", FileID: "gone", Removed: true},
+		{ChangeType: "file", FileID: "archive", File: ptr(d.files["archive"])},
+	}
+
+	set, err := r.LoadChanges(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, "t2", set.Token)
+	assert.Equal(t, []string{"doc1", "old"}, nodeIDs(set.Documents))
+	for _, doc := range set.Documents {
+		if doc.ID == "old" {
+			assert.Equal(t, "Archive", doc.Metadata[FolderPathMetadataKey])
+		} else {
+			assert.Equal(t, "Welcome aboard, again.", doc.Text)
+		}
+	}
+	assert.ElementsMatch(t, []string{"notes", "slides1", "data", "elsewhere", "gone"}, set.Removed)
+
+	// Files in the folder moved out are no longer in scope.
+	d.changes = []change{{ChangeType: "file", FileID: "slides1", File: ptr(d.files["slides1"])}}
+	set, err = r.LoadChanges(context.Background(), "t1")
+	require.NoError(t, err)
+	assert.Empty(t, set.Documents)
+	assert.Equal(t, []string{"slides1"}, set.Removed)
+}
+
+func ptr(f driveFile) *driveFile {
+	return &f
+}
+
+// hashDocStore is a minimal docstore for the upserts strategy.
+type hashDocStore struct {
+	hashes map[string]string
+}
+
+func (s *hashDocStore) GetDocumentHash(docID string) (string, bool) {
+	h, ok := s.hashes[docID]
+	return h, ok
+}
+
+func (s *hashDocStore) SetDocumentHash(docID, hash string) { s.hashes[docID] = hash }
+
+func (s *hashDocStore) GetAllDocumentHashes() map[string]string { return s.hashes }
+
+func (s *hashDocStore) AddDocuments(nodes []schema.Node) error { return nil }
+
+func (s *hashDocStore) DeleteDocument(docID string) error {
+	delete(s.hashes, docID)
+	return nil
+}
+
+func (s *hashDocStore) DeleteRefDoc(refDocID string) error {
+	delete(s.hashes, refDocID)
+	return nil
+}
+
+func TestSync(t *testing.T) {
+	d := newFakeDrive()
+	srv := d.serve(t)
+	r := NewReader(NewClient(WithEndpoint(srv.URL), WithToken("tok")), WithFolderIDs("docs"))
+	pipeline := ingestion.NewIngestionPipeline(
+		ingestion.WithDocstore(&hashDocStore{hashes: map[string]string{}}),
+		ingestion.WithDisableCache(true),
+	)
+
+	result, err := Sync(context.Background(), r, pipeline, "")
+	require.NoError(t, err)
+	assert.Equal(t, "t1", result.Token)
+	assert.Len(t, result.Documents, 5)
+	assert.Len(t, result.Nodes, 5)
+
+	// Only the edited file is re-ingested; a change to an unchanged file is
+	// skipped by the docstore.
+	d.content["sheet1"] = "item,cost\nlaptop,1300\n"
+	d.changes = []change{
+		{ChangeType: "file", FileID: "sheet1", File: ptr(d.files["sheet1"])},
+		{ChangeType: "file", FileID: "notes", File: ptr(d.files["notes"])},
+	}
+	result, err = Sync(context.Background(), r, pipeline, result.Token)
+	require.NoError(t, err)
+	assert.Equal(t, "t2", result.Token)
+	assert.Len(t, result.Documents, 2)
+	require.Len(t, result.Nodes, 1)
+	assert.Equal(t, "sheet1", result.Nodes[0].ID)
+	assert.Empty(t, result.Removed)
+}
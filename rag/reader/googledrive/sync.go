@@ -0,0 +1,330 @@
+package googledrive
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+
+	"github.com/aqua777/go-llamaindex/ingestion"
+	"github.com/aqua777/go-llamaindex/rag/reader"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// changeFields are the change fields requested from the API.
+const changeFields = "nextPageToken,newStartPageToken,changes(changeType,fileId,removed,file(" + fileFields + "))"
+
+// change is a Drive change resource.
+type change struct {
+	ChangeType string     `json:"changeType"`
+	FileID     string     `json:"fileId"`
+	Removed    bool       `json:"removed"`
+	File       *driveFile `json:"file"`
+}
+
+// ChangeSet is the result of loading the changes after a change token.
+type ChangeSet struct {
+	// Documents are the documents of files added or modified in scope,
+	// including the files of folders moved into scope.
+	Documents []schema.Node
+	// Removed are the IDs of files deleted, trashed, moved out of scope or
+	// no longer accessible. It may include files that were never loaded,
+	// as Drive reports changes to files outside the loaded folders.
+	Removed []string
+	// Token is the change token to pass to the next call.
+	Token string
+}
+
+// StartPageToken returns the change token of the current state of the
+// drive. Get it before a full load, so that changes made during the load
+// are not missed.
+func (r *Reader) StartPageToken(ctx context.Context) (string, error) {
+	query := url.Values{"supportsAllDrives": {"true"}}
+	if r.driveID != "" {
+		query.Set("driveId", r.driveID)
+	}
+	var resp struct {
+		StartPageToken string `json:"startPageToken"`
+	}
+	if err := r.client.getJSON(ctx, "/changes/startPageToken", query, &resp); err != nil {
+		return "", reader.NewReaderError("changes", "failed to get start page token", err)
+	}
+	return resp.StartPageToken, nil
+}
+
+// LoadChanges loads the files changed after token, as returned by
+// StartPageToken or a previous call. Folders moved into scope are loaded
+// whole and the files of folders moved out of scope are reported removed;
+// renaming or moving a folder within scope reloads its files, whose folder
+// path changed.
+func (r *Reader) LoadChanges(ctx context.Context, token string) (*ChangeSet, error) {
+	changes, next, err := r.listChanges(ctx, token)
+	if err != nil {
+		return nil, reader.NewReaderError("changes", "failed to list changes", err)
+	}
+	folders, err := r.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &ChangeSet{Token: next}
+	loaded := make(map[string]bool)
+	removed := make(map[string]bool)
+	load := func(f driveFile, folderPath string) error {
+		if loaded[f.ID] {
+			return nil
+		}
+		loaded[f.ID] = true
+		doc, ok, err := r.document(ctx, f, folderPath)
+		if err != nil {
+			return err
+		}
+		if ok {
+			set.Documents = append(set.Documents, doc)
+		} else if !removed[f.ID] {
+			// The file may have been loaded before, e.g. under the size
+			// limit.
+			removed[f.ID] = true
+			set.Removed = append(set.Removed, f.ID)
+		}
+		return nil
+	}
+	remove := func(f driveFile, _ string) error {
+		if !loaded[f.ID] && !removed[f.ID] {
+			removed[f.ID] = true
+			set.Removed = append(set.Removed, f.ID)
+		}
+		return nil
+	}
+
+	// Folders are handled first, so that the files changed in a folder
+	// moved into scope are matched against it.
+	var files []change
+	for _, c := range changes {
+		if c.FileID == "" {
+			// Changes of shared drives themselves.
+			continue
+		}
+		_, known := folders[c.FileID]
+		isFolder := c.File != nil && c.File.MimeType == FolderMimeType
+		if !isFolder && !(c.File == nil && known) {
+			files = append(files, c)
+			continue
+		}
+		if err := r.applyFolderChange(ctx, c, folders, load, remove); err != nil {
+			return nil, reader.NewReaderError(c.FileID, "failed to apply folder change", err)
+		}
+	}
+
+	fileIDs := make(map[string]bool, len(r.fileIDs))
+	for _, id := range r.fileIDs {
+		fileIDs[id] = true
+	}
+	for _, c := range files {
+		f := c.File
+		if c.Removed || f == nil || f.Trashed {
+			remove(driveFile{ID: c.FileID}, "")
+			continue
+		}
+		parent, inScope := parentIn(f, folders)
+		switch {
+		case inScope:
+			err = load(*f, folders[parent].path)
+		case fileIDs[f.ID]:
+			err = load(*f, "")
+		default:
+			err = remove(*f, "")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	r.folders = folders
+	r.mu.Unlock()
+	return set, nil
+}
+
+// applyFolderChange updates the folders in scope for a changed folder,
+// loading or removing the files of folders that enter or leave the scope.
+func (r *Reader) applyFolderChange(ctx context.Context, c change, folders map[string]folder, load, remove func(driveFile, string) error) error {
+	current, known := folders[c.FileID]
+	isRoot := known && current.parent == ""
+	gone := c.Removed || c.File == nil || c.File.Trashed
+
+	var parent string
+	inScope := false
+	if !gone {
+		if isRoot {
+			inScope = true
+		} else if r.recursive {
+			parent, inScope = parentIn(c.File, folders)
+		}
+	}
+
+	switch {
+	case !known && inScope:
+		return r.walk(ctx, c.FileID, folder{path: path.Join(folders[parent].path, c.File.Name), parent: parent}, folders, load)
+	case known && !inScope:
+		if !gone {
+			// The folder was moved out of scope; its files still exist and
+			// are listed to report them removed.
+			if err := r.walk(ctx, c.FileID, folder{}, make(map[string]folder), remove); err != nil {
+				return err
+			}
+		}
+		// Files of deleted or trashed folders are reported by their own
+		// changes.
+		for _, id := range descendants(folders, c.FileID) {
+			delete(folders, id)
+		}
+	case known && !isRoot:
+		moved := folder{path: path.Join(folders[parent].path, c.File.Name), parent: parent}
+		if moved != current {
+			for _, id := range descendants(folders, c.FileID) {
+				delete(folders, id)
+			}
+			return r.walk(ctx, c.FileID, moved, folders, load)
+		}
+	}
+	return nil
+}
+
+// scope returns a copy of the folders in scope, listing them when no load
+// has been done yet.
+func (r *Reader) scope(ctx context.Context) (map[string]folder, error) {
+	r.mu.Lock()
+	current := r.folders
+	r.mu.Unlock()
+
+	folders := make(map[string]folder, len(current))
+	if current != nil {
+		for id, f := range current {
+			folders[id] = f
+		}
+		return folders, nil
+	}
+	roots, err := r.rootIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range roots {
+		if err := r.walk(ctx, id, folder{}, folders, nil); err != nil {
+			return nil, reader.NewReaderError(id, "failed to list folders", err)
+		}
+	}
+	return folders, nil
+}
+
+// listChanges returns the changes after token and the token of the next
+// call.
+func (r *Reader) listChanges(ctx context.Context, token string) ([]change, string, error) {
+	query := url.Values{
+		"pageToken":                 {token},
+		"fields":                    {changeFields},
+		"pageSize":                  {strconv.Itoa(listPageSize)},
+		"includeRemoved":            {"true"},
+		"supportsAllDrives":         {"true"},
+		"includeItemsFromAllDrives": {"true"},
+	}
+	if r.driveID != "" {
+		query.Set("driveId", r.driveID)
+	}
+	var changes []change
+	for {
+		var page struct {
+			NextPageToken     string   `json:"nextPageToken"`
+			NewStartPageToken string   `json:"newStartPageToken"`
+			Changes           []change `json:"changes"`
+		}
+		if err := r.client.getJSON(ctx, "/changes", query, &page); err != nil {
+			return nil, "", err
+		}
+		changes = append(changes, page.Changes...)
+		if page.NewStartPageToken != "" {
+			return changes, page.NewStartPageToken, nil
+		}
+		if page.NextPageToken == "" {
+			return nil, "", fmt.Errorf("change list ended without a new start page token")
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// parentIn returns the first parent of f in folders.
+func parentIn(f *driveFile, folders map[string]folder) (string, bool) {
+	for _, p := range f.Parents {
+		if _, ok := folders[p]; ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// descendants returns a folder and the folders below it.
+func descendants(folders map[string]folder, id string) []string {
+	var ids []string
+	for candidate := range folders {
+		for p := candidate; p != ""; p = folders[p].parent {
+			if p == id {
+				ids = append(ids, candidate)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// SyncResult reports an incremental sync.
+type SyncResult struct {
+	// Documents are the documents loaded from Drive.
+	Documents []schema.Node
+	// Nodes are the nodes the pipeline produced for new or changed
+	// documents.
+	Nodes []schema.Node
+	// Removed are the IDs of removed files. The pipeline does not delete
+	// them; remove them from the docstore and vector store with
+	// DeleteRefDoc and Delete.
+	Removed []string
+	// Token is the change token to pass to the next sync.
+	Token string
+}
+
+// Sync loads the files changed after token, or all files when token is
+// empty, and runs them through an ingestion pipeline. Document IDs are
+// Drive file IDs, so a pipeline with a docstore and the upserts strategy
+// replaces changed files and skips unchanged ones. The upserts-and-delete
+// strategy must not be used, as an incremental load does not contain the
+// unchanged files.
+func Sync(ctx context.Context, r *Reader, pipeline *ingestion.IngestionPipeline, token string) (*SyncResult, error) {
+	var result *SyncResult
+	if token == "" {
+		next, err := r.StartPageToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		docs, err := r.LoadDataWithContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result = &SyncResult{Documents: docs, Token: next}
+	} else {
+		set, err := r.LoadChanges(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		result = &SyncResult{Documents: set.Documents, Removed: set.Removed, Token: set.Token}
+	}
+	if len(result.Documents) == 0 {
+		return result, nil
+	}
+	nodes, err := pipeline.Run(ctx, nil, result.Documents)
+	if err != nil {
+		// The token is not advanced, so the next sync retries.
+		return nil, fmt.Errorf("failed to ingest changed documents: %w", err)
+	}
+	result.Nodes = nodes
+	return result, nil
+}
@@ -2,6 +2,7 @@ package reader
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -87,6 +88,16 @@ func (r *HTMLReader) LoadFromFile(filePath string) ([]schema.Node, error) {
 	return r.loadFile(filePath)
 }
 
+// LoadFromReader loads HTML content from src. name is used like a file
+// path, for the document ID and source metadata.
+func (r *HTMLReader) LoadFromReader(src io.Reader, name string) ([]schema.Node, error) {
+	content, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content: %w", err)
+	}
+	return r.parse(content, name)
+}
+
 // Metadata returns reader metadata.
 func (r *HTMLReader) Metadata() ReaderMetadata {
 	return ReaderMetadata{
@@ -141,7 +152,10 @@ func (r *HTMLReader) loadFile(filePath string) ([]schema.Node, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
+	return r.parse(content, filePath)
+}
 
+func (r *HTMLReader) parse(content []byte, filePath string) ([]schema.Node, error) {
 	html := string(content)
 
 	// Extract metadata from HTML
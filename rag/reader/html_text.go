@@ -0,0 +1,521 @@
+package reader
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// alwaysRemovedAtoms are elements that never hold readable content.
+var alwaysRemovedAtoms = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Iframe: true,
+	atom.Svg: true, atom.Template: true, atom.Canvas: true, atom.Object: true,
+	atom.Embed: true, atom.Head: true,
+}
+
+// boilerplateAtoms are page chrome removed by boilerplate removal.
+var boilerplateAtoms = map[atom.Atom]bool{
+	atom.Nav: true, atom.Footer: true, atom.Header: true, atom.Aside: true,
+	atom.Form: true, atom.Button: true, atom.Dialog: true, atom.Menu: true,
+}
+
+// boilerplateRoles are ARIA landmark roles of page chrome.
+var boilerplateRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true,
+	"complementary": true, "search": true, "menu": true, "menubar": true,
+	"dialog": true, "alertdialog": true,
+}
+
+// boilerplateTokens are class and id tokens of page chrome, such as
+// "site-nav" or "cookie_banner".
+var boilerplateTokens = map[string]bool{
+	"nav": true, "navbar": true, "navigation": true, "menu": true,
+	"footer": true, "header": true, "sidebar": true, "breadcrumb": true,
+	"breadcrumbs": true, "cookie": true, "cookies": true, "consent": true,
+	"banner": true, "ad": true, "ads": true, "advert": true,
+	"advertisement": true, "promo": true, "social": true, "share": true,
+	"sharing": true, "subscribe": true, "newsletter": true, "popup": true,
+	"modal": true, "related": true, "comments": true, "skip": true,
+}
+
+var (
+	htmlSpaceRun   = regexp.MustCompile(`[ \t\r\n\f]+`)
+	htmlTokenSplit = regexp.MustCompile(`[^a-z0-9]+`)
+	htmlBlankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlPage is the readable content of a parsed HTML page.
+type htmlPage struct {
+	Title       string
+	Description string
+	Language    string
+	Text        string
+	// Canonical is the absolute <link rel="canonical"> URL, if any.
+	Canonical string
+	// Links are the absolute http(s) targets of the page's links, without
+	// fragments, in document order. Links in page chrome are included.
+	Links []string
+}
+
+// htmlConverter renders an HTML tree as clean text or Markdown.
+type htmlConverter struct {
+	// markdown renders Markdown instead of plain text.
+	markdown bool
+	// removeBoilerplate drops navigation, headers, footers and similar
+	// page chrome, and keeps only <main> or <article> when present.
+	removeBoilerplate bool
+	// base resolves relative links in Markdown output.
+	base *url.URL
+}
+
+// convert parses an HTML document and extracts its metadata and content.
+func (c *htmlConverter) convert(src string) (*htmlPage, error) {
+	doc, err := html.Parse(strings.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	page := &htmlPage{}
+	c.readHead(doc, page)
+	c.readLinks(doc, page)
+
+	c.prune(doc, false)
+	root := findElement(doc, atom.Body)
+	if root == nil {
+		root = doc
+	}
+	if c.removeBoilerplate {
+		if main := findElement(root, atom.Main); main != nil {
+			root = main
+		} else if article := findElement(root, atom.Article); article != nil {
+			root = article
+		}
+	}
+
+	text := strings.Join(c.blocks(root), "\n\n")
+	page.Text = strings.TrimSpace(htmlBlankLines.ReplaceAllString(text, "\n\n"))
+	return page, nil
+}
+
+// HTMLToMarkdown converts an HTML fragment or document to Markdown, keeping
+// all content. Relative links are resolved against baseURL when it is set.
+// It is used by readers of APIs that return rendered HTML.
+func HTMLToMarkdown(src, baseURL string) (string, error) {
+	converter := &htmlConverter{markdown: true}
+	if baseURL != "" {
+		base, err := url.Parse(baseURL)
+		if err != nil {
+			return "", fmt.Errorf("invalid base URL: %w", err)
+		}
+		converter.base = base
+	}
+	page, err := converter.convert(src)
+	if err != nil {
+		return "", err
+	}
+	return page.Text, nil
+}
+
+// readHead extracts the title, description and language of a document.
+func (c *htmlConverter) readHead(doc *html.Node, page *htmlPage) {
+	var ogTitle, ogDescription string
+	walkElements(doc, func(n *html.Node) {
+		switch n.DataAtom {
+		case atom.Html:
+			page.Language = attr(n, "lang")
+		case atom.Title:
+			if page.Title == "" {
+				page.Title = collapseSpace(textContent(n))
+			}
+		case atom.Meta:
+			content := strings.TrimSpace(attr(n, "content"))
+			switch strings.ToLower(attr(n, "name") + attr(n, "property")) {
+			case "description":
+				page.Description = content
+			case "og:title":
+				ogTitle = content
+			case "og:description":
+				ogDescription = content
+			}
+		}
+	})
+	if page.Title == "" {
+		page.Title = ogTitle
+	}
+	if page.Description == "" {
+		page.Description = ogDescription
+	}
+}
+
+// readLinks collects the canonical URL and link targets of a document.
+func (c *htmlConverter) readLinks(doc *html.Node, page *htmlPage) {
+	seen := make(map[string]bool)
+	walkElements(doc, func(n *html.Node) {
+		switch n.DataAtom {
+		case atom.Link:
+			if page.Canonical == "" && strings.EqualFold(strings.TrimSpace(attr(n, "rel")), "canonical") {
+				page.Canonical = c.resolveLink(attr(n, "href"))
+			}
+		case atom.A:
+			if link := c.resolveLink(attr(n, "href")); link != "" && !seen[link] {
+				seen[link] = true
+				page.Links = append(page.Links, link)
+			}
+		}
+	})
+}
+
+// resolveLink returns the absolute http(s) target of href without its
+// fragment, or "" for other schemes.
+func (c *htmlConverter) resolveLink(href string) string {
+	target := c.resolve(href)
+	if target == "" {
+		return ""
+	}
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
+
+// prune removes non-content elements, and page chrome when boilerplate
+// removal is enabled. inContent is set under <main> and <article>.
+func (c *htmlConverter) prune(n *html.Node, inContent bool) {
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		switch {
+		case child.Type == html.CommentNode:
+			n.RemoveChild(child)
+		case child.Type == html.ElementNode && (alwaysRemovedAtoms[child.DataAtom] || hasAttr(child, "hidden") || attr(child, "aria-hidden") == "true"):
+			n.RemoveChild(child)
+		case child.Type == html.ElementNode && c.removeBoilerplate && isBoilerplate(child, inContent):
+			n.RemoveChild(child)
+		default:
+			c.prune(child, inContent || child.DataAtom == atom.Main || child.DataAtom == atom.Article)
+		}
+		child = next
+	}
+}
+
+// isBoilerplate reports whether an element is page chrome. <main> and
+// <article> are never boilerplate, whatever their classes, and headers
+// within them are kept since they usually hold the title.
+func isBoilerplate(n *html.Node, inContent bool) bool {
+	switch n.DataAtom {
+	case atom.Main, atom.Article, atom.Body:
+		return false
+	case atom.Header:
+		if inContent {
+			return false
+		}
+	}
+	if boilerplateAtoms[n.DataAtom] || boilerplateRoles[strings.ToLower(attr(n, "role"))] {
+		return true
+	}
+	for _, token := range htmlTokenSplit.Split(strings.ToLower(attr(n, "class")+" "+attr(n, "id")), -1) {
+		if boilerplateTokens[token] && !(inContent && token == "header") {
+			return true
+		}
+	}
+	return false
+}
+
+// blocks renders the children of n as blocks, grouping runs of inline
+// content into paragraphs.
+func (c *htmlConverter) blocks(n *html.Node) []string {
+	var out []string
+	var inline strings.Builder
+	flush := func() {
+		if text := trimLines(inline.String()); text != "" {
+			out = append(out, text)
+		}
+		inline.Reset()
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && isBlockElement(child.DataAtom) {
+			flush()
+			out = append(out, c.block(child)...)
+			continue
+		}
+		inline.WriteString(c.inline(child))
+	}
+	flush()
+	return out
+}
+
+// block renders a block element.
+func (c *htmlConverter) block(n *html.Node) []string {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := collapseSpace(c.inlineChildren(n))
+		if text == "" {
+			return nil
+		}
+		if c.markdown {
+			level := int(n.Data[1] - '0')
+			text = strings.Repeat("#", level) + " " + text
+		}
+		return []string{text}
+	case atom.Ul, atom.Ol:
+		if list := c.list(n); list != "" {
+			return []string{list}
+		}
+		return nil
+	case atom.Pre:
+		text := strings.Trim(textContent(n), "\n")
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		if c.markdown {
+			text = "```\n" + text + "\n```"
+		}
+		return []string{text}
+	case atom.Blockquote:
+		inner := strings.Join(c.blocks(n), "\n\n")
+		if inner == "" {
+			return nil
+		}
+		if c.markdown {
+			inner = prefixLines(inner, "> ", "> ")
+		}
+		return []string{inner}
+	case atom.Table:
+		if table := c.table(n); table != "" {
+			return []string{table}
+		}
+		return nil
+	case atom.Hr:
+		if c.markdown {
+			return []string{"---"}
+		}
+		return nil
+	default:
+		return c.blocks(n)
+	}
+}
+
+// list renders a list, indenting nested lists under their item.
+func (c *htmlConverter) list(n *html.Node) string {
+	var items []string
+	number := 1
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.DataAtom != atom.Li {
+			continue
+		}
+		content := strings.Join(c.blocks(li), "\n")
+		if content == "" {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		items = append(items, prefixLines(content, marker, strings.Repeat(" ", len(marker))))
+	}
+	return strings.Join(items, "\n")
+}
+
+// table renders a table as Markdown rows, or as " | "-separated cells in
+// plain text.
+func (c *htmlConverter) table(n *html.Node) string {
+	var rows [][]string
+	walkElements(n, func(tr *html.Node) {
+		if tr.DataAtom != atom.Tr {
+			return
+		}
+		var cells []string
+		for cell := tr.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+				text := collapseSpace(strings.ReplaceAll(c.inlineChildren(cell), "|", "\\|"))
+				cells = append(cells, text)
+			}
+		}
+		if len(cells) > 0 {
+			rows = append(rows, cells)
+		}
+	})
+	if len(rows) == 0 {
+		return ""
+	}
+
+	lines := make([]string, 0, len(rows)+1)
+	for i, cells := range rows {
+		if !c.markdown {
+			lines = append(lines, strings.Join(cells, " | "))
+			continue
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", len(cells)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// inline renders inline content.
+func (c *htmlConverter) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return htmlSpaceRun.ReplaceAllString(n.Data, " ")
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return "\n"
+	case atom.Img:
+		return ""
+	}
+
+	inner := c.inlineChildren(n)
+	if !c.markdown || strings.TrimSpace(inner) == "" {
+		return inner
+	}
+
+	switch n.DataAtom {
+	case atom.A:
+		if href := c.resolve(attr(n, "href")); href != "" {
+			return "[" + strings.TrimSpace(inner) + "](" + href + ")"
+		}
+	case atom.Strong, atom.B:
+		return "**" + strings.TrimSpace(inner) + "**"
+	case atom.Em, atom.I:
+		return "*" + strings.TrimSpace(inner) + "*"
+	case atom.Code:
+		return "`" + strings.TrimSpace(inner) + "`"
+	}
+	return inner
+}
+
+func (c *htmlConverter) inlineChildren(n *html.Node) string {
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(c.inline(child))
+	}
+	return sb.String()
+}
+
+// resolve returns an absolute link target, or "" for fragments and
+// scripts.
+func (c *htmlConverter) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return ""
+	}
+	if c.base == nil {
+		return href
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	return c.base.ResolveReference(ref).String()
+}
+
+// isBlockElement reports whether an element starts a new block.
+func isBlockElement(a atom.Atom) bool {
+	switch a {
+	case atom.Address, atom.Article, atom.Aside, atom.Blockquote, atom.Dd,
+		atom.Details, atom.Div, atom.Dl, atom.Dt, atom.Fieldset,
+		atom.Figcaption, atom.Figure, atom.Footer, atom.H1, atom.H2, atom.H3,
+		atom.H4, atom.H5, atom.H6, atom.Header, atom.Hr, atom.Li, atom.Main,
+		atom.Nav, atom.Ol, atom.P, atom.Pre, atom.Section, atom.Summary,
+		atom.Table, atom.Ul:
+		return true
+	}
+	return false
+}
+
+// findElement returns the first element of the given kind under n.
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := findElement(child, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// walkElements calls fn for every element under n, in document order.
+func walkElements(n *html.Node, fn func(*html.Node)) {
+	if n.Type == html.ElementNode {
+		fn(n)
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		walkElements(child, fn)
+	}
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(textContent(child))
+	}
+	return sb.String()
+}
+
+func collapseSpace(s string) string {
+	return strings.TrimSpace(htmlSpaceRun.ReplaceAllString(s, " "))
+}
+
+// trimLines trims each line and drops blank ones.
+func trimLines(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// prefixLines prefixes the first line of s with first and the others with
+// rest.
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if i == 0 {
+			lines[i] = first + line
+		} else if line != "" {
+			lines[i] = rest + line
+		} else {
+			lines[i] = strings.TrimRight(rest, " ")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -3,6 +3,7 @@ package reader
 
 import (
 	"context"
+	"io"
 
 	"github.com/aqua777/go-llamaindex/schema"
 )
@@ -36,6 +37,25 @@ type FileReader interface {
 	LoadFromFile(filePath string) ([]schema.Node, error)
 }
 
+// StreamFileReader is a FileReader that can also load content that is not
+// in a local file, e.g. an object streamed from cloud storage.
+type StreamFileReader interface {
+	FileReader
+	// LoadFromReader loads documents from src. name stands in for the file
+	// path: it is used for document IDs, source metadata and format
+	// detection by extension.
+	LoadFromReader(src io.Reader, name string) ([]schema.Node, error)
+}
+
+// DocumentFileReader is a FileReader of a binary format that can also load
+// a file as one binary-safe schema.Document: the extracted text in Text and
+// the original file content in Data.
+type DocumentFileReader interface {
+	FileReader
+	// LoadDocument loads the file at filePath as a single Document.
+	LoadDocument(filePath string) (*schema.Document, error)
+}
+
 // ReaderMetadata contains metadata about a reader.
 type ReaderMetadata struct {
 	// Name is the reader name (e.g., "JSONReader", "PDFReader")
@@ -3,6 +3,7 @@ package reader
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -86,6 +87,16 @@ func (r *JSONReader) LoadFromFile(filePath string) ([]schema.Node, error) {
 	return r.loadFile(filePath)
 }
 
+// LoadFromReader loads JSON content from src. name is used like a file
+// path, for document IDs and to detect JSON Lines by extension.
+func (r *JSONReader) LoadFromReader(src io.Reader, name string) ([]schema.Node, error) {
+	content, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content: %w", err)
+	}
+	return r.parse(content, name)
+}
+
 // Metadata returns reader metadata.
 func (r *JSONReader) Metadata() ReaderMetadata {
 	return ReaderMetadata{
@@ -134,7 +145,10 @@ func (r *JSONReader) loadFile(filePath string) ([]schema.Node, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
+	return r.parse(content, filePath)
+}
 
+func (r *JSONReader) parse(content []byte, filePath string) ([]schema.Node, error) {
 	ext := strings.ToLower(filepath.Ext(filePath))
 	isJSONL := r.IsJSONL || ext == ".jsonl"
 
@@ -3,6 +3,7 @@ package reader
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -92,6 +93,16 @@ func (r *MarkdownReader) LoadFromFile(filePath string) ([]schema.Node, error) {
 	return r.loadFile(filePath)
 }
 
+// LoadFromReader loads Markdown content from src. name is used like a file
+// path, for document IDs and source metadata.
+func (r *MarkdownReader) LoadFromReader(src io.Reader, name string) ([]schema.Node, error) {
+	content, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content: %w", err)
+	}
+	return r.parse(content, name)
+}
+
 // Metadata returns reader metadata.
 func (r *MarkdownReader) Metadata() ReaderMetadata {
 	return ReaderMetadata{
@@ -147,7 +158,10 @@ func (r *MarkdownReader) loadFile(filePath string) ([]schema.Node, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
+	return r.parse(content, filePath)
+}
 
+func (r *MarkdownReader) parse(content []byte, filePath string) ([]schema.Node, error) {
 	text := string(content)
 
 	// Apply preprocessing
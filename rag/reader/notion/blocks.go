@@ -0,0 +1,402 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// richText is a Notion rich text span.
+type richText struct {
+	PlainText   string `json:"plain_text"`
+	Href        string `json:"href"`
+	Annotations struct {
+		Bold          bool `json:"bold"`
+		Italic        bool `json:"italic"`
+		Strikethrough bool `json:"strikethrough"`
+		Code          bool `json:"code"`
+	} `json:"annotations"`
+}
+
+// page is a Notion page object.
+type page struct {
+	ID             string `json:"id"`
+	URL            string `json:"url"`
+	CreatedTime    string `json:"created_time"`
+	LastEditedTime string `json:"last_edited_time"`
+	Parent         struct {
+		Type       string `json:"type"`
+		DatabaseID string `json:"database_id"`
+		PageID     string `json:"page_id"`
+	} `json:"parent"`
+	Properties map[string]property `json:"properties"`
+}
+
+type named struct {
+	Name string `json:"name"`
+}
+
+// property is a page property value.
+type property struct {
+	Type        string     `json:"type"`
+	Title       []richText `json:"title"`
+	RichText    []richText `json:"rich_text"`
+	Number      *float64   `json:"number"`
+	Select      *named     `json:"select"`
+	Status      *named     `json:"status"`
+	MultiSelect []named    `json:"multi_select"`
+	People      []named    `json:"people"`
+	Checkbox    bool       `json:"checkbox"`
+	URL         *string    `json:"url"`
+	Email       *string    `json:"email"`
+	PhoneNumber *string    `json:"phone_number"`
+	Date        *struct {
+		Start string `json:"start"`
+		End   string `json:"end"`
+	} `json:"date"`
+}
+
+// title returns the page title.
+func (p *page) title() string {
+	for _, prop := range p.Properties {
+		if prop.Type == "title" {
+			return plainText(prop.Title)
+		}
+	}
+	return ""
+}
+
+// simpleProperties returns the non-empty properties other than the title
+// as strings, numbers, booleans or string lists.
+func (p *page) simpleProperties() map[string]interface{} {
+	props := make(map[string]interface{})
+	for name, prop := range p.Properties {
+		var value interface{}
+		switch prop.Type {
+		case "rich_text":
+			if text := plainText(prop.RichText); text != "" {
+				value = text
+			}
+		case "number":
+			if prop.Number != nil {
+				value = *prop.Number
+			}
+		case "select":
+			if prop.Select != nil {
+				value = prop.Select.Name
+			}
+		case "status":
+			if prop.Status != nil {
+				value = prop.Status.Name
+			}
+		case "multi_select":
+			if len(prop.MultiSelect) > 0 {
+				value = names(prop.MultiSelect)
+			}
+		case "people":
+			if len(prop.People) > 0 {
+				value = names(prop.People)
+			}
+		case "checkbox":
+			value = prop.Checkbox
+		case "url":
+			value = stringValue(prop.URL)
+		case "email":
+			value = stringValue(prop.Email)
+		case "phone_number":
+			value = stringValue(prop.PhoneNumber)
+		case "date":
+			if prop.Date != nil {
+				value = prop.Date.Start
+				if prop.Date.End != "" {
+					value = prop.Date.Start + "/" + prop.Date.End
+				}
+			}
+		}
+		if value != nil && value != "" {
+			props[name] = value
+		}
+	}
+	return props
+}
+
+// block is a Notion block with its type-specific payload.
+type block struct {
+	ID          string
+	Type        string
+	HasChildren bool
+	Data        blockData
+	Children    []*block
+}
+
+// blockData holds the fields of the block types the reader renders.
+type blockData struct {
+	RichText   []richText   `json:"rich_text"`
+	Caption    []richText   `json:"caption"`
+	Checked    bool         `json:"checked"`
+	Language   string       `json:"language"`
+	Title      string       `json:"title"`
+	URL        string       `json:"url"`
+	Expression string       `json:"expression"`
+	Cells      [][]richText `json:"cells"`
+	External   struct {
+		URL string `json:"url"`
+	} `json:"external"`
+	File struct {
+		URL string `json:"url"`
+	} `json:"file"`
+	Icon struct {
+		Emoji string `json:"emoji"`
+	} `json:"icon"`
+}
+
+// UnmarshalJSON decodes a block and the payload stored under its type.
+func (b *block) UnmarshalJSON(data []byte) error {
+	var head struct {
+		ID          string `json:"id"`
+		Type        string `json:"type"`
+		HasChildren bool   `json:"has_children"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return err
+	}
+	b.ID, b.Type, b.HasChildren = head.ID, head.Type, head.HasChildren
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if payload, ok := fields[head.Type]; ok {
+		if err := json.Unmarshal(payload, &b.Data); err != nil {
+			return fmt.Errorf("block %s: %w", head.ID, err)
+		}
+	}
+	return nil
+}
+
+// renderBlocks renders blocks as Markdown. Consecutive list items of the
+// same kind are kept together and other blocks are separated by blank
+// lines.
+func renderBlocks(blocks []*block) string {
+	var sb strings.Builder
+	number := 0
+	prevType := ""
+	for _, b := range blocks {
+		if b.Type == "numbered_list_item" {
+			number++
+		} else {
+			number = 0
+		}
+		text := renderBlock(b, number)
+		if text == "" {
+			continue
+		}
+		if sb.Len() > 0 {
+			if isListItem(b.Type) && b.Type == prevType {
+				sb.WriteString("\n")
+			} else {
+				sb.WriteString("\n\n")
+			}
+		}
+		sb.WriteString(text)
+		prevType = b.Type
+	}
+	return sb.String()
+}
+
+// renderBlock renders one block and its children. number is the position
+// of a numbered list item.
+func renderBlock(b *block, number int) string {
+	d := b.Data
+	text := markdownText(d.RichText)
+	children := renderBlocks(b.Children)
+
+	switch b.Type {
+	case "heading_1", "heading_2", "heading_3":
+		level, _ := strconv.Atoi(strings.TrimPrefix(b.Type, "heading_"))
+		return joinBlocks(strings.Repeat("#", level)+" "+text, children)
+	case "bulleted_list_item":
+		return listItem("- ", text, children)
+	case "numbered_list_item":
+		return listItem(strconv.Itoa(number)+". ", text, children)
+	case "to_do":
+		box := "- [ ] "
+		if d.Checked {
+			box = "- [x] "
+		}
+		return listItem(box, text, children)
+	case "quote":
+		return prefixLines(joinBlocks(text, children), "> ")
+	case "callout":
+		if d.Icon.Emoji != "" {
+			text = d.Icon.Emoji + " " + text
+		}
+		return prefixLines(joinBlocks(text, children), "> ")
+	case "code":
+		return "```" + d.Language + "\n" + plainText(d.RichText) + "\n```"
+	case "equation":
+		return "$$\n" + d.Expression + "\n$$"
+	case "divider":
+		return "---"
+	case "image":
+		return "![" + plainText(d.Caption) + "](" + d.fileURL() + ")"
+	case "file", "pdf", "video", "audio":
+		return link(plainText(d.Caption), d.fileURL(), b.Type)
+	case "bookmark", "embed", "link_preview":
+		return link(plainText(d.Caption), d.URL, d.URL)
+	case "table":
+		return renderTable(b)
+	case "child_page", "child_database":
+		return link(d.Title, "https://www.notion.so/"+normalizeID(b.ID), "Untitled")
+	default:
+		// Paragraphs, toggles, columns, synced blocks and unknown types
+		// render their text followed by their children.
+		return joinBlocks(text, children)
+	}
+}
+
+// renderTable renders a table block whose children are its rows. The first
+// row is the header, as Markdown tables require one.
+func renderTable(b *block) string {
+	var lines []string
+	for _, row := range b.Children {
+		if row.Type != "table_row" {
+			continue
+		}
+		cells := make([]string, len(row.Data.Cells))
+		for j, cell := range row.Data.Cells {
+			cells[j] = strings.ReplaceAll(markdownText(cell), "|", "\\|")
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+		if len(lines) == 1 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", len(cells)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// childEntries returns the child page and database blocks in a block tree.
+func childEntries(blocks []*block) []*block {
+	var out []*block
+	for _, b := range blocks {
+		if b.Type == "child_page" || b.Type == "child_database" {
+			out = append(out, b)
+			continue
+		}
+		out = append(out, childEntries(b.Children)...)
+	}
+	return out
+}
+
+func (d blockData) fileURL() string {
+	if d.External.URL != "" {
+		return d.External.URL
+	}
+	return d.File.URL
+}
+
+func isListItem(blockType string) bool {
+	return blockType == "bulleted_list_item" || blockType == "numbered_list_item" || blockType == "to_do"
+}
+
+// listItem renders a list item with its children indented below it.
+func listItem(marker, text, children string) string {
+	item := marker + text
+	if children != "" {
+		item += "\n" + indentLines(children, strings.Repeat(" ", len(marker)))
+	}
+	return item
+}
+
+func joinBlocks(text, children string) string {
+	switch {
+	case text == "":
+		return children
+	case children == "":
+		return text
+	default:
+		return text + "\n\n" + children
+	}
+}
+
+func link(label, target, fallback string) string {
+	if target == "" {
+		return label
+	}
+	if label == "" {
+		label = fallback
+	}
+	return "[" + label + "](" + target + ")"
+}
+
+// markdownText renders rich text with its annotations and links.
+func markdownText(spans []richText) string {
+	var sb strings.Builder
+	for _, span := range spans {
+		text := span.PlainText
+		if strings.TrimSpace(text) == "" {
+			sb.WriteString(text)
+			continue
+		}
+		a := span.Annotations
+		if a.Code {
+			text = "`" + text + "`"
+		}
+		if a.Bold {
+			text = "**" + text + "**"
+		}
+		if a.Italic {
+			text = "*" + text + "*"
+		}
+		if a.Strikethrough {
+			text = "~~" + text + "~~"
+		}
+		if span.Href != "" {
+			text = "[" + text + "](" + span.Href + ")"
+		}
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
+
+func plainText(spans []richText) string {
+	var sb strings.Builder
+	for _, span := range spans {
+		sb.WriteString(span.PlainText)
+	}
+	return sb.String()
+}
+
+func prefixLines(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(prefix+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func indentLines(s, indent string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func names(values []named) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = v.Name
+	}
+	return out
+}
+
+func stringValue(s *string) interface{} {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
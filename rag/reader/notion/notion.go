@@ -0,0 +1,465 @@
+// Package notion provides a reader that loads pages and databases from a
+// Notion workspace through the Notion API, converting page content to
+// Markdown documents.
+package notion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/internal/httputil"
+	"github.com/aqua777/go-llamaindex/rag/reader"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+const (
+	// DefaultBaseURL is the Notion API endpoint.
+	DefaultBaseURL = "https://api.notion.com/v1"
+	// DefaultAPIVersion is the Notion-Version header sent with requests.
+	DefaultAPIVersion = "2022-06-28"
+	// DefaultMaxDepth is the default nesting of child pages and databases
+	// loaded below the requested pages.
+	DefaultMaxDepth = 3
+	// DefaultMaxRetries is the default number of retries of rate-limited
+	// requests.
+	DefaultMaxRetries = httputil.DefaultMaxRetries
+
+	pageSize = 100
+)
+
+// Metadata keys set on Notion documents.
+const (
+	// PageIDMetadataKey holds the page ID.
+	PageIDMetadataKey = "page_id"
+	// TitleMetadataKey holds the page title.
+	TitleMetadataKey = "title"
+	// URLMetadataKey holds the page URL.
+	URLMetadataKey = "url"
+	// LastEditedMetadataKey holds the last edit time (RFC3339).
+	LastEditedMetadataKey = "last_edited_time"
+	// CreatedMetadataKey holds the creation time (RFC3339).
+	CreatedMetadataKey = "created_time"
+	// DatabaseIDMetadataKey holds the ID of the database a page belongs to.
+	DatabaseIDMetadataKey = "database_id"
+	// ParentPageIDMetadataKey holds the ID of the parent page of a child
+	// page.
+	ParentPageIDMetadataKey = "parent_page_id"
+	// PropertiesMetadataKey holds the database properties of a page as
+	// simple values.
+	PropertiesMetadataKey = "properties"
+)
+
+// APIError is an error response of the Notion API.
+type APIError struct {
+	// Status is the HTTP status code.
+	Status int `json:"status"`
+	// Code is the Notion error code, e.g. "object_not_found".
+	Code string `json:"code"`
+	// Message describes the error.
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("notion API error %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// Reader loads Notion pages as Markdown documents. Pages can be listed
+// directly or through the databases they belong to; without either, every
+// page shared with the integration is loaded. Child pages and databases
+// are loaded as separate documents, up to MaxDepth levels below.
+type Reader struct {
+	token       string
+	baseURL     string
+	apiVersion  string
+	client      *http.Client
+	pageIDs     []string
+	databaseIDs []string
+	recursive   bool
+	maxDepth    int
+	maxRetries  int
+	extra       map[string]interface{}
+}
+
+// Option configures a Reader.
+type Option func(*Reader)
+
+// WithToken sets the integration token. Defaults to the NOTION_TOKEN
+// environment variable.
+func WithToken(token string) Option {
+	return func(r *Reader) {
+		r.token = token
+	}
+}
+
+// WithPageIDs sets the pages to load.
+func WithPageIDs(ids ...string) Option {
+	return func(r *Reader) {
+		r.pageIDs = ids
+	}
+}
+
+// WithDatabaseIDs sets the databases whose pages are loaded.
+func WithDatabaseIDs(ids ...string) Option {
+	return func(r *Reader) {
+		r.databaseIDs = ids
+	}
+}
+
+// WithRecursive enables or disables loading child pages and databases.
+func WithRecursive(recursive bool) Option {
+	return func(r *Reader) {
+		r.recursive = recursive
+	}
+}
+
+// WithMaxDepth sets how many levels of child pages are loaded.
+func WithMaxDepth(depth int) Option {
+	return func(r *Reader) {
+		r.maxDepth = depth
+	}
+}
+
+// WithMaxRetries sets the number of retries of rate-limited requests.
+func WithMaxRetries(n int) Option {
+	return func(r *Reader) {
+		r.maxRetries = n
+	}
+}
+
+// WithHTTPClient sets the HTTP client.
+func WithHTTPClient(client *http.Client) Option {
+	return func(r *Reader) {
+		r.client = client
+	}
+}
+
+// WithBaseURL sets the API endpoint (for testing).
+func WithBaseURL(baseURL string) Option {
+	return func(r *Reader) {
+		r.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithExtraMetadata sets metadata added to all documents.
+func WithExtraMetadata(metadata map[string]interface{}) Option {
+	return func(r *Reader) {
+		r.extra = metadata
+	}
+}
+
+// New creates a Notion reader.
+func New(opts ...Option) *Reader {
+	r := &Reader{
+		token:      os.Getenv("NOTION_TOKEN"),
+		baseURL:    DefaultBaseURL,
+		apiVersion: DefaultAPIVersion,
+		client:     http.DefaultClient,
+		recursive:  true,
+		maxDepth:   DefaultMaxDepth,
+		maxRetries: DefaultMaxRetries,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// LoadData loads the configured pages and databases.
+func (r *Reader) LoadData() ([]schema.Node, error) {
+	return r.LoadDataWithContext(context.Background())
+}
+
+// LoadDataWithContext loads the configured pages and databases with context
+// support. Each page yields one document, even if reached several times.
+func (r *Reader) LoadDataWithContext(ctx context.Context) ([]schema.Node, error) {
+	if r.token == "" {
+		return nil, fmt.Errorf("notion token not set")
+	}
+
+	l := &loader{Reader: r, visited: make(map[string]bool)}
+	switch {
+	case len(r.pageIDs) == 0 && len(r.databaseIDs) == 0:
+		pages, err := r.searchPages(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for i := range pages {
+			if err := l.loadPage(ctx, &pages[i], 0, nil); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		for _, id := range r.pageIDs {
+			page, err := r.getPage(ctx, id)
+			if err != nil {
+				return nil, reader.NewReaderError(id, "failed to get page", err)
+			}
+			if err := l.loadPage(ctx, page, 0, nil); err != nil {
+				return nil, err
+			}
+		}
+		for _, id := range r.databaseIDs {
+			if err := l.loadDatabase(ctx, id, 0); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return l.docs, nil
+}
+
+// Metadata returns reader metadata.
+func (r *Reader) Metadata() reader.ReaderMetadata {
+	return reader.ReaderMetadata{
+		Name:        "NotionReader",
+		Description: "Loads Notion pages and databases as Markdown documents",
+	}
+}
+
+// loader holds the state of one LoadData call.
+type loader struct {
+	*Reader
+	visited map[string]bool
+	docs    []schema.Node
+}
+
+// loadPage converts a page to a document and loads its child pages and
+// databases. extra holds metadata of how the page was reached.
+func (l *loader) loadPage(ctx context.Context, p *page, depth int, extra map[string]interface{}) error {
+	id := normalizeID(p.ID)
+	if l.visited[id] {
+		return nil
+	}
+	l.visited[id] = true
+
+	blocks, err := l.blockTree(ctx, p.ID)
+	if err != nil {
+		return reader.NewReaderError(p.ID, "failed to read page content", err)
+	}
+
+	title := p.title()
+	var sb strings.Builder
+	if title != "" {
+		sb.WriteString("# " + title + "\n\n")
+	}
+	sb.WriteString(renderBlocks(blocks))
+
+	metadata := map[string]interface{}{
+		PageIDMetadataKey:     p.ID,
+		TitleMetadataKey:      title,
+		URLMetadataKey:        p.URL,
+		"source":              p.URL,
+		CreatedMetadataKey:    p.CreatedTime,
+		LastEditedMetadataKey: p.LastEditedTime,
+	}
+	if p.Parent.Type == "database_id" {
+		metadata[DatabaseIDMetadataKey] = p.Parent.DatabaseID
+		if props := p.simpleProperties(); len(props) > 0 {
+			metadata[PropertiesMetadataKey] = props
+		}
+	}
+	for k, v := range extra {
+		metadata[k] = v
+	}
+	for k, v := range l.extra {
+		metadata[k] = v
+	}
+
+	l.docs = append(l.docs, schema.Node{
+		ID:       p.ID,
+		Text:     strings.TrimSpace(sb.String()),
+		Type:     schema.ObjectTypeDocument,
+		Metadata: metadata,
+		MimeType: "text/markdown",
+	})
+
+	if !l.recursive || depth >= l.maxDepth {
+		return nil
+	}
+	for _, child := range childEntries(blocks) {
+		switch child.Type {
+		case "child_page":
+			childPage, err := l.getPage(ctx, child.ID)
+			if err != nil {
+				return reader.NewReaderError(child.ID, "failed to get child page", err)
+			}
+			if err := l.loadPage(ctx, childPage, depth+1, map[string]interface{}{ParentPageIDMetadataKey: p.ID}); err != nil {
+				return err
+			}
+		case "child_database":
+			if err := l.loadDatabase(ctx, child.ID, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadDatabase loads every page of a database.
+func (l *loader) loadDatabase(ctx context.Context, databaseID string, depth int) error {
+	pages, err := l.queryDatabase(ctx, databaseID)
+	if err != nil {
+		return reader.NewReaderError(databaseID, "failed to query database", err)
+	}
+	for i := range pages {
+		if err := l.loadPage(ctx, &pages[i], depth, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockTree returns the blocks of a page or block, with the children of
+// nested blocks filled in. Child pages and databases are not descended
+// into; they become documents of their own.
+func (r *Reader) blockTree(ctx context.Context, blockID string) ([]*block, error) {
+	var blocks []*block
+	cursor := ""
+	for {
+		query := url.Values{"page_size": {strconv.Itoa(pageSize)}}
+		if cursor != "" {
+			query.Set("start_cursor", cursor)
+		}
+		var resp struct {
+			Results    []*block `json:"results"`
+			HasMore    bool     `json:"has_more"`
+			NextCursor string   `json:"next_cursor"`
+		}
+		if err := r.do(ctx, http.MethodGet, "/blocks/"+blockID+"/children?"+query.Encode(), nil, &resp); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, resp.Results...)
+		if !resp.HasMore || resp.NextCursor == "" {
+			break
+		}
+		cursor = resp.NextCursor
+	}
+
+	for _, b := range blocks {
+		if !b.HasChildren || b.Type == "child_page" || b.Type == "child_database" {
+			continue
+		}
+		children, err := r.blockTree(ctx, b.ID)
+		if err != nil {
+			return nil, err
+		}
+		b.Children = children
+	}
+	return blocks, nil
+}
+
+func (r *Reader) getPage(ctx context.Context, pageID string) (*page, error) {
+	var p page
+	if err := r.do(ctx, http.MethodGet, "/pages/"+pageID, nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// queryDatabase returns all pages of a database.
+func (r *Reader) queryDatabase(ctx context.Context, databaseID string) ([]page, error) {
+	return r.paginatePages(ctx, "/databases/"+databaseID+"/query", map[string]interface{}{})
+}
+
+// searchPages returns all pages shared with the integration.
+func (r *Reader) searchPages(ctx context.Context) ([]page, error) {
+	pages, err := r.paginatePages(ctx, "/search", map[string]interface{}{
+		"filter": map[string]string{"property": "object", "value": "page"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search pages: %w", err)
+	}
+	return pages, nil
+}
+
+// paginatePages POSTs a paginated page query.
+func (r *Reader) paginatePages(ctx context.Context, path string, body map[string]interface{}) ([]page, error) {
+	var pages []page
+	for {
+		body["page_size"] = pageSize
+		var resp struct {
+			Results    []page `json:"results"`
+			HasMore    bool   `json:"has_more"`
+			NextCursor string `json:"next_cursor"`
+		}
+		if err := r.do(ctx, http.MethodPost, path, body, &resp); err != nil {
+			return nil, err
+		}
+		pages = append(pages, resp.Results...)
+		if !resp.HasMore || resp.NextCursor == "" {
+			return pages, nil
+		}
+		body["start_cursor"] = resp.NextCursor
+	}
+}
+
+// do sends an API request and decodes the response into out, retrying
+// rate-limited requests after the delay the API asks for.
+func (r *Reader) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, r.baseURL+path, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+r.token)
+		req.Header.Set("Notion-Version", r.apiVersion)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < r.maxRetries {
+			if err := httputil.Sleep(ctx, httputil.RetryDelay(resp.Header.Get("Retry-After"), attempt)); err != nil {
+				return err
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			apiErr := &APIError{Status: resp.StatusCode}
+			if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+				apiErr.Message = strings.TrimSpace(string(data))
+			}
+			apiErr.Status = resp.StatusCode
+			return apiErr
+		}
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		return nil
+	}
+}
+
+// normalizeID strips the dashes Notion IDs may be written with.
+func normalizeID(id string) string {
+	return strings.ReplaceAll(id, "-", "")
+}
+
+// Ensure Reader implements the reader interfaces.
+var (
+	_ reader.ReaderWithContext  = (*Reader)(nil)
+	_ reader.ReaderWithMetadata = (*Reader)(nil)
+)
@@ -0,0 +1,246 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func text(s string) map[string]interface{} {
+	return map[string]interface{}{"plain_text": s, "annotations": map[string]bool{}}
+}
+
+func styled(s string, annotation string) map[string]interface{} {
+	return map[string]interface{}{"plain_text": s, "annotations": map[string]bool{annotation: true}}
+}
+
+func blk(id, typ string, payload map[string]interface{}, hasChildren bool) map[string]interface{} {
+	return map[string]interface{}{"object": "block", "id": id, "type": typ, typ: payload, "has_children": hasChildren}
+}
+
+func rich(spans ...map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"rich_text": spans}
+}
+
+func pageObject(id, title string, parent map[string]interface{}, props map[string]interface{}) map[string]interface{} {
+	if props == nil {
+		props = map[string]interface{}{}
+	}
+	props["Name"] = map[string]interface{}{"type": "title", "title": []interface{}{text(title)}}
+	return map[string]interface{}{
+		"object": "page", "id": id, "url": "https://www.notion.so/" + id,
+		"created_time": "2025-01-01T00:00:00.000Z", "last_edited_time": "2025-02-01T00:00:00.000Z",
+		"parent": parent, "properties": props,
+	}
+}
+
+// fakeNotion serves a workspace with a root page, a child page, and a
+// database of two pages. Block children of the root page are paginated.
+func fakeNotion(t *testing.T) (*httptest.Server, *int32) {
+	t.Helper()
+
+	workspace := map[string]interface{}{"type": "workspace", "workspace": true}
+	pages := map[string]interface{}{
+		"root":  pageObject("root", "Handbook", workspace, nil),
+		"child": pageObject("child", "Onboarding", map[string]interface{}{"type": "page_id", "page_id": "root"}, nil),
+	}
+	dbParent := map[string]interface{}{"type": "database_id", "database_id": "db1"}
+	dbPages := []interface{}{
+		pageObject("task-1", "Write docs", dbParent, map[string]interface{}{
+			"Status":   map[string]interface{}{"type": "status", "status": map[string]string{"name": "Done"}},
+			"Estimate": map[string]interface{}{"type": "number", "number": 3},
+			"Tags":     map[string]interface{}{"type": "multi_select", "multi_select": []map[string]string{{"name": "docs"}, {"name": "q1"}}},
+			"Notes":    map[string]interface{}{"type": "rich_text", "rich_text": []interface{}{}},
+		}),
+		pageObject("task-2", "Review", dbParent, nil),
+	}
+	children := map[string][][]interface{}{
+		"root": {
+			{
+				blk("h", "heading_1", rich(text("Welcome")), false),
+				blk("p", "paragraph", rich(text("Read the "), styled("guide", "bold"), text(" first.")), false),
+				blk("b1", "bulleted_list_item", rich(text("Laptop")), true),
+			},
+			{
+				blk("n1", "numbered_list_item", rich(text("Sign in")), false),
+				blk("n2", "numbered_list_item", rich(text("Say hi")), false),
+				blk("todo", "to_do", map[string]interface{}{"rich_text": []interface{}{text("Set up VPN")}, "checked": true}, false),
+				blk("code", "code", map[string]interface{}{"rich_text": []interface{}{text("make setup")}, "language": "bash"}, false),
+				blk("tbl", "table", map[string]interface{}{"has_column_header": true}, true),
+				blk("child", "child_page", map[string]interface{}{"title": "Onboarding"}, true),
+				blk("db1", "child_database", map[string]interface{}{"title": "Tasks"}, false),
+			},
+		},
+		"b1": {{blk("b1a", "bulleted_list_item", rich(styled("charger", "code")), false)}},
+		"tbl": {{
+			blk("r1", "table_row", map[string]interface{}{"cells": [][]interface{}{{text("Tool")}, {text("Owner")}}}, false),
+			blk("r2", "table_row", map[string]interface{}{"cells": [][]interface{}{{text("VPN")}, {text("IT")}}}, false),
+		}},
+		"child":  {{blk("cq", "quote", rich(text("Ask questions")), false)}},
+		"task-1": {{blk("t1p", "paragraph", rich(text("Draft the API docs")), false)}},
+		"task-2": {{}},
+	}
+
+	var rateLimited int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/pages/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/v1/pages/")
+		p, ok := pages[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"object": "error", "status": 404, "code": "object_not_found", "message": "Could not find page"})
+			return
+		}
+		json.NewEncoder(w).Encode(p)
+	})
+	mux.HandleFunc("/v1/blocks/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/blocks/"), "/children")
+		// The first request is rate limited.
+		if atomic.CompareAndSwapInt32(&rateLimited, 0, 1) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		pagesOf := children[id]
+		i := 0
+		if cursor := r.URL.Query().Get("start_cursor"); cursor != "" {
+			i = 1
+		}
+		resp := map[string]interface{}{"results": []interface{}{}, "has_more": false}
+		if i < len(pagesOf) {
+			resp["results"] = pagesOf[i]
+			if i+1 < len(pagesOf) {
+				resp["has_more"], resp["next_cursor"] = true, "next"
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("/v1/databases/db1/query", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		data, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(data, &body))
+		if body["start_cursor"] == nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{"results": dbPages[:1], "has_more": true, "next_cursor": "c2"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": dbPages[1:], "has_more": false})
+	})
+	mux.HandleFunc("/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": []interface{}{pages["child"]}, "has_more": false})
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("Notion-Version") != DefaultAPIVersion {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &rateLimited
+}
+
+func TestReaderPages(t *testing.T) {
+	srv, _ := fakeNotion(t)
+
+	r := New(WithToken("secret"), WithBaseURL(srv.URL+"/v1"), WithPageIDs("root"),
+		WithExtraMetadata(map[string]interface{}{"team": "people"}))
+	docs, err := r.LoadData()
+	require.NoError(t, err)
+
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	assert.Equal(t, []string{"root", "child", "task-1", "task-2"}, ids)
+
+	root := docs[0]
+	assert.Equal(t, "text/markdown", root.MimeType)
+	assert.Equal(t, "Handbook", root.Metadata[TitleMetadataKey])
+	assert.Equal(t, "https://www.notion.so/root", root.Metadata[URLMetadataKey])
+	assert.Equal(t, "2025-02-01T00:00:00.000Z", root.Metadata[LastEditedMetadataKey])
+	assert.Equal(t, "people", root.Metadata["team"])
+	assert.Equal(t, strings.Join([]string{
+		"# Handbook",
+		"",
+		"# Welcome",
+		"",
+		"Read the **guide** first.",
+		"",
+		"- Laptop",
+		"  - `charger`",
+		"",
+		"1. Sign in",
+		"2. Say hi",
+		"",
+		"- [x] Set up VPN",
+		"",
+		"```bash\nmake setup\n```",
+		"",
+		"| Tool | Owner |\n| --- | --- |\n| VPN | IT |",
+		"",
+		"[Onboarding](https://www.notion.so/child)",
+		"",
+		"[Tasks](https://www.notion.so/db1)",
+	}, "\n"), root.Text)
+
+	child := docs[1]
+	assert.Equal(t, "# Onboarding\n\n> Ask questions", child.Text)
+	assert.Equal(t, "root", child.Metadata[ParentPageIDMetadataKey])
+
+	task := docs[2]
+	assert.Equal(t, "# Write docs\n\nDraft the API docs", task.Text)
+	assert.Equal(t, "db1", task.Metadata[DatabaseIDMetadataKey])
+	assert.Equal(t, map[string]interface{}{"Status": "Done", "Estimate": 3.0, "Tags": []string{"docs", "q1"}}, task.Metadata[PropertiesMetadataKey])
+
+	// Without recursion, only the requested page is loaded.
+	docs, err = New(WithToken("secret"), WithBaseURL(srv.URL+"/v1"), WithPageIDs("root"), WithRecursive(false)).LoadData()
+	require.NoError(t, err)
+	assert.Len(t, docs, 1)
+}
+
+func TestReaderDatabasesAndSearch(t *testing.T) {
+	srv, _ := fakeNotion(t)
+
+	docs, err := New(WithToken("secret"), WithBaseURL(srv.URL+"/v1"), WithDatabaseIDs("db1")).LoadDataWithContext(context.Background())
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	assert.Equal(t, "Review", docs[1].Metadata[TitleMetadataKey])
+
+	// Without IDs, pages shared with the integration are loaded.
+	docs, err = New(WithToken("secret"), WithBaseURL(srv.URL+"/v1")).LoadData()
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	assert.Equal(t, "child", docs[0].ID)
+}
+
+func TestReaderErrors(t *testing.T) {
+	srv, _ := fakeNotion(t)
+
+	_, err := New(WithToken(""), WithBaseURL(srv.URL+"/v1")).LoadData()
+	assert.ErrorContains(t, err, "token")
+
+	_, err = New(WithToken("secret"), WithBaseURL(srv.URL+"/v1"), WithPageIDs("missing")).LoadData()
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 404, apiErr.Status)
+	assert.Equal(t, "object_not_found", apiErr.Code)
+
+	_, err = New(WithToken("wrong"), WithBaseURL(srv.URL+"/v1"), WithPageIDs("root")).LoadData()
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.Status)
+
+	// Rate limiting is retried only up to the limit.
+	srv, _ = fakeNotion(t)
+	_, err = New(WithToken("secret"), WithBaseURL(srv.URL+"/v1"), WithPageIDs("root"), WithMaxRetries(0)).LoadData()
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.Status)
+}
@@ -0,0 +1,179 @@
+package objectstore
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// azureAPIVersion is the Blob service version requested. It is required
+// for bearer token authentication.
+const azureAPIVersion = "2021-08-06"
+
+// AzureBlobStore reads an Azure Blob Storage container through the Blob
+// service REST API. It authenticates with a shared access signature or a
+// Microsoft Entra ID bearer token; without either, only containers with
+// public access can be read.
+type AzureBlobStore struct {
+	account   string
+	container string
+	endpoint  string
+	sasToken  string
+	bearer    func(ctx context.Context) (string, error)
+	client    *http.Client
+}
+
+// AzureOption configures an AzureBlobStore.
+type AzureOption func(*AzureBlobStore)
+
+// WithAzureSASToken authenticates with a shared access signature, with or
+// without its leading "?". Defaults to the AZURE_STORAGE_SAS_TOKEN
+// environment variable.
+func WithAzureSASToken(token string) AzureOption {
+	return func(s *AzureBlobStore) {
+		s.sasToken = strings.TrimPrefix(token, "?")
+	}
+}
+
+// WithAzureTokenSource authenticates with a Microsoft Entra ID access
+// token for https://storage.azure.com, fetched before every request.
+func WithAzureTokenSource(source func(ctx context.Context) (string, error)) AzureOption {
+	return func(s *AzureBlobStore) {
+		s.bearer = source
+	}
+}
+
+// WithAzureEndpoint sets the Blob service endpoint, e.g. for Azurite or a
+// sovereign cloud. Defaults to https://<account>.blob.core.windows.net.
+func WithAzureEndpoint(endpoint string) AzureOption {
+	return func(s *AzureBlobStore) {
+		s.endpoint = strings.TrimRight(endpoint, "/")
+	}
+}
+
+// WithAzureHTTPClient sets the HTTP client.
+func WithAzureHTTPClient(client *http.Client) AzureOption {
+	return func(s *AzureBlobStore) {
+		s.client = client
+	}
+}
+
+// NewAzureBlobStore creates a store for a container of a storage account.
+func NewAzureBlobStore(account, container string, opts ...AzureOption) *AzureBlobStore {
+	s := &AzureBlobStore{
+		account:   account,
+		container: container,
+		endpoint:  "https://" + account + ".blob.core.windows.net",
+		sasToken:  strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?"),
+		client:    http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// URI returns "azblob://account/container".
+func (s *AzureBlobStore) URI() string {
+	return "azblob://" + s.account + "/" + s.container
+}
+
+// Bucket returns the container name.
+func (s *AzureBlobStore) Bucket() string {
+	return s.container
+}
+
+// List lists the blobs under prefix.
+func (s *AzureBlobStore) List(ctx context.Context, prefix string, fn func(Object) error) error {
+	query := url.Values{"restype": {"container"}, "comp": {"list"}}
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	for {
+		var page struct {
+			Blobs []struct {
+				Name       string `xml:"Name"`
+				Properties struct {
+					LastModified  string `xml:"Last-Modified"`
+					ETag          string `xml:"Etag"`
+					ContentLength int64  `xml:"Content-Length"`
+					ContentType   string `xml:"Content-Type"`
+				} `xml:"Properties"`
+			} `xml:"Blobs>Blob"`
+			NextMarker string `xml:"NextMarker"`
+		}
+		resp, err := s.get(ctx, "/"+url.PathEscape(s.container), query)
+		if err != nil {
+			return err
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to decode blob list: %w", err)
+		}
+
+		for _, blob := range page.Blobs {
+			modified, _ := time.Parse(time.RFC1123, blob.Properties.LastModified)
+			obj := Object{
+				Key:          blob.Name,
+				Size:         blob.Properties.ContentLength,
+				LastModified: modified.UTC(),
+				ETag:         blob.Properties.ETag,
+				ContentType:  blob.Properties.ContentType,
+			}
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
+		if page.NextMarker == "" {
+			return nil
+		}
+		query.Set("marker", page.NextMarker)
+	}
+}
+
+// Open streams a blob's content.
+func (s *AzureBlobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.get(ctx, "/"+url.PathEscape(s.container)+"/"+escapePath(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// get sends an authenticated GET request.
+func (s *AzureBlobStore) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	rawQuery := query.Encode()
+	if s.sasToken != "" {
+		if rawQuery != "" {
+			rawQuery += "&"
+		}
+		rawQuery += s.sasToken
+	}
+	target := s.endpoint + path
+	if rawQuery != "" {
+		target += "?" + rawQuery
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	if s.bearer != nil {
+		token, err := s.bearer(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get access token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return do(s.client, req, s.URI())
+}
+
+// Ensure AzureBlobStore implements Store.
+var _ Store = (*AzureBlobStore)(nil)
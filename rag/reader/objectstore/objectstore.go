@@ -0,0 +1,98 @@
+// Package objectstore provides a reader that lists and streams objects
+// from Amazon S3, Google Cloud Storage and Azure Blob Storage and hands
+// them to the file-type readers of the reader package, so cloud-hosted
+// corpora can be ingested without copying them to disk first.
+package objectstore
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Object describes a stored object.
+type Object struct {
+	// Key is the object name within its bucket or container.
+	Key string
+	// Size is the object size in bytes.
+	Size int64
+	// LastModified is the last modification time.
+	LastModified time.Time
+	// ETag identifies the object version.
+	ETag string
+	// ContentType is the object's content type, if the listing reports it.
+	ContentType string
+}
+
+// Store lists and opens the objects of one bucket or container.
+type Store interface {
+	// URI returns the store's URI, e.g. "s3://bucket", prefixed to object
+	// keys in document IDs.
+	URI() string
+	// Bucket returns the bucket or container name.
+	Bucket() string
+	// List calls fn for each object whose key starts with prefix, in key
+	// order, following pagination. List stops at the first error from fn.
+	List(ctx context.Context, prefix string, fn func(Object) error) error
+	// Open streams the content of an object. The caller closes it.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// StatusError is an error response of an object store API.
+type StatusError struct {
+	// Store is the URI of the store.
+	Store string
+	// Status is the HTTP status code.
+	Status int
+	// Code is the service error code, e.g. "NoSuchBucket", if returned.
+	Code string
+	// Message is the service error message.
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("%s: status %d", e.Store, e.Status)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// do sends a request and returns the response if its status is 200. Other
+// responses are closed and returned as a StatusError, reading the code
+// and message from XML (S3, Azure) or JSON (GCS) error bodies.
+func do(client *http.Client, req *http.Request, store string) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	statusErr := &StatusError{Store: store, Status: resp.StatusCode}
+	var xmlBody struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(data, &xmlBody) == nil && (xmlBody.Code != "" || xmlBody.Message != "") {
+		statusErr.Code, statusErr.Message = xmlBody.Code, strings.TrimSpace(xmlBody.Message)
+	} else if msg := jsonErrorMessage(data); msg != "" {
+		statusErr.Message = msg
+	} else {
+		statusErr.Message = strings.TrimSpace(string(data))
+	}
+	if statusErr.Message == "" {
+		statusErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return nil, statusErr
+}
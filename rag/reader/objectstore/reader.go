@@ -0,0 +1,387 @@
+package objectstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/rag/reader"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// Metadata keys set on documents loaded from an object store, in addition
+// to reader.FilePathMetadataKey (the object URI), reader.FileNameMetadataKey,
+// reader.FileSizeMetadataKey and reader.LastModifiedMetadataKey.
+const (
+	// BucketMetadataKey holds the bucket or container name.
+	BucketMetadataKey = "bucket"
+	// ObjectKeyMetadataKey holds the object key.
+	ObjectKeyMetadataKey = "object_key"
+	// ETagMetadataKey holds the object's ETag.
+	ETagMetadataKey = "etag"
+)
+
+// DefaultMaxObjectSize is the default size limit of loaded objects.
+const DefaultMaxObjectSize = 100 << 20
+
+// Reader loads the objects of a Store as documents. Objects are dispatched
+// by extension to registered file readers, like
+// reader.SimpleDirectoryReader does for local files. Readers implementing
+// reader.StreamFileReader parse the object as it is downloaded; other
+// readers, such as the PDF reader, get a temporary copy on disk. Objects
+// without a reader are loaded as a single document with their content.
+type Reader struct {
+	store         Store
+	prefixes      []string
+	extensions    []string
+	include       []string
+	exclude       []string
+	fileReaders   map[string]reader.FileReader
+	numWorkers    int
+	maxObjectSize int64
+	modifiedSince time.Time
+	extra         map[string]interface{}
+}
+
+// Option configures a Reader.
+type Option func(*Reader)
+
+// WithPrefixes only loads objects whose keys start with one of the
+// prefixes, e.g. "docs/". Defaults to the whole bucket.
+func WithPrefixes(prefixes ...string) Option {
+	return func(r *Reader) {
+		r.prefixes = prefixes
+	}
+}
+
+// WithExtensions sets the extensions of the objects to load, e.g. ".pdf".
+// Defaults to the extensions of the registered file readers plus ".txt"
+// and ".md".
+func WithExtensions(extensions ...string) Option {
+	return func(r *Reader) {
+		r.extensions = extensions
+	}
+}
+
+// WithInclude only loads objects whose key or name matches one of the glob
+// patterns; "**/" matches any number of directories.
+func WithInclude(patterns ...string) Option {
+	return func(r *Reader) {
+		r.include = append(r.include, patterns...)
+	}
+}
+
+// WithExclude skips objects whose key or name matches one of the glob
+// patterns.
+func WithExclude(patterns ...string) Option {
+	return func(r *Reader) {
+		r.exclude = append(r.exclude, patterns...)
+	}
+}
+
+// WithFileReader registers a reader for objects with extension ext. A nil
+// reader loads the objects with their raw content. The defaults are
+// reader.DefaultFileReaders.
+func WithFileReader(ext string, fileReader reader.FileReader) Option {
+	return func(r *Reader) {
+		ext = strings.ToLower(ext)
+		if fileReader == nil {
+			delete(r.fileReaders, ext)
+		} else {
+			r.fileReaders[ext] = fileReader
+		}
+	}
+}
+
+// WithNumWorkers sets how many objects are downloaded concurrently.
+// Documents are returned in key order regardless. Defaults to 4.
+func WithNumWorkers(n int) Option {
+	return func(r *Reader) {
+		if n > 0 {
+			r.numWorkers = n
+		}
+	}
+}
+
+// WithMaxObjectSize skips objects larger than size bytes. Defaults to
+// DefaultMaxObjectSize; zero disables the limit.
+func WithMaxObjectSize(size int64) Option {
+	return func(r *Reader) {
+		r.maxObjectSize = size
+	}
+}
+
+// WithModifiedSince only loads objects modified after t.
+func WithModifiedSince(t time.Time) Option {
+	return func(r *Reader) {
+		r.modifiedSince = t
+	}
+}
+
+// WithExtraMetadata adds metadata to every loaded document.
+func WithExtraMetadata(metadata map[string]interface{}) Option {
+	return func(r *Reader) {
+		r.extra = metadata
+	}
+}
+
+// NewReader creates a reader for the objects of store.
+func NewReader(store Store, opts ...Option) *Reader {
+	r := &Reader{
+		store:         store,
+		fileReaders:   reader.DefaultFileReaders(),
+		numWorkers:    4,
+		maxObjectSize: DefaultMaxObjectSize,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// LoadData loads the selected objects.
+func (r *Reader) LoadData() ([]schema.Node, error) {
+	return r.LoadDataWithContext(context.Background())
+}
+
+// LoadDataWithContext loads the selected objects with context support.
+func (r *Reader) LoadDataWithContext(ctx context.Context) ([]schema.Node, error) {
+	objects, err := r.ListObjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([][]schema.Node, len(objects))
+	indexes := make(chan int)
+	var once sync.Once
+	var firstErr error
+
+	var wg sync.WaitGroup
+	for w := 0; w < r.numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				nodes, err := r.loadObject(ctx, objects[i])
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				results[i] = nodes
+			}
+		}()
+	}
+send:
+	for i := range objects {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var docs []schema.Node
+	for _, nodes := range results {
+		docs = append(docs, nodes...)
+	}
+	return docs, nil
+}
+
+// ListObjects returns the objects that would be loaded, in key order.
+func (r *Reader) ListObjects(ctx context.Context) ([]Object, error) {
+	prefixes := r.prefixes
+	if len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+	extensions := r.extensions
+	if len(extensions) == 0 {
+		extensions = []string{".txt", ".md"}
+		for ext := range r.fileReaders {
+			extensions = append(extensions, ext)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var objects []Object
+	for _, prefix := range prefixes {
+		err := r.store.List(ctx, prefix, func(obj Object) error {
+			if seen[obj.Key] || !r.selected(obj, extensions) {
+				return nil
+			}
+			seen[obj.Key] = true
+			objects = append(objects, obj)
+			return nil
+		})
+		if err != nil {
+			return nil, reader.NewReaderError(r.store.URI()+"/"+prefix, "failed to list objects", err)
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// selected reports whether an object passes the filters.
+func (r *Reader) selected(obj Object, extensions []string) bool {
+	ext := strings.ToLower(path.Ext(obj.Key))
+	match := false
+	for _, e := range extensions {
+		if ext == strings.ToLower(e) {
+			match = true
+			break
+		}
+	}
+	if !match {
+		return false
+	}
+	if r.maxObjectSize > 0 && obj.Size > r.maxObjectSize {
+		return false
+	}
+	if !r.modifiedSince.IsZero() && !obj.LastModified.After(r.modifiedSince) {
+		return false
+	}
+	name := path.Base(obj.Key)
+	if len(r.include) > 0 && !matchAny(r.include, obj.Key, name) {
+		return false
+	}
+	return !matchAny(r.exclude, obj.Key, name)
+}
+
+// loadObject downloads an object and converts it to documents.
+func (r *Reader) loadObject(ctx context.Context, obj Object) ([]schema.Node, error) {
+	uri := r.store.URI() + "/" + obj.Key
+	body, err := r.store.Open(ctx, obj.Key)
+	if err != nil {
+		return nil, reader.NewReaderError(uri, "failed to open object", err)
+	}
+	defer body.Close()
+	if r.maxObjectSize > 0 {
+		body = struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(body, r.maxObjectSize), body}
+	}
+
+	var nodes []schema.Node
+	switch fileReader := r.fileReaders[strings.ToLower(path.Ext(obj.Key))].(type) {
+	case nil:
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, reader.NewReaderError(uri, "failed to read object", err)
+		}
+		doc := schema.NewDocumentFromData(uri, data, obj.ContentType, map[string]interface{}{"filename": path.Base(obj.Key)})
+		nodes = []schema.Node{{
+			ID:       uri,
+			Text:     doc.Text,
+			Type:     schema.ObjectTypeDocument,
+			Metadata: map[string]interface{}{},
+			MimeType: doc.MimeType,
+		}}
+	case reader.StreamFileReader:
+		nodes, err = fileReader.LoadFromReader(body, uri)
+	default:
+		nodes, err = loadViaTempFile(fileReader, body, obj.Key, uri)
+	}
+	if err != nil {
+		return nil, reader.NewReaderError(uri, "failed to load object", err)
+	}
+
+	metadata := map[string]interface{}{
+		reader.FilePathMetadataKey:     uri,
+		reader.FileNameMetadataKey:     path.Base(obj.Key),
+		reader.FileSizeMetadataKey:     obj.Size,
+		reader.LastModifiedMetadataKey: obj.LastModified.UTC().Format(time.RFC3339),
+		BucketMetadataKey:              r.store.Bucket(),
+		ObjectKeyMetadataKey:           obj.Key,
+	}
+	if obj.ETag != "" {
+		metadata[ETagMetadataKey] = obj.ETag
+	}
+	for i := range nodes {
+		if nodes[i].Metadata == nil {
+			nodes[i].Metadata = make(map[string]interface{})
+		}
+		for k, v := range metadata {
+			nodes[i].Metadata[k] = v
+		}
+		for k, v := range r.extra {
+			nodes[i].Metadata[k] = v
+		}
+	}
+	return nodes, nil
+}
+
+// loadViaTempFile copies an object to a temporary file for a reader that
+// only reads local files, and replaces the temporary path with the object
+// URI in the resulting IDs and metadata.
+func loadViaTempFile(fileReader reader.FileReader, body io.Reader, key, uri string) ([]schema.Node, error) {
+	f, err := os.CreateTemp("", "objectstore-*"+path.Ext(key))
+	if err != nil {
+		return nil, err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	_, err = io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy object: %w", err)
+	}
+
+	nodes, err := fileReader.LoadFromFile(tmp)
+	if err != nil {
+		return nil, err
+	}
+	for i := range nodes {
+		nodes[i].ID = strings.ReplaceAll(nodes[i].ID, tmp, uri)
+		for k, v := range nodes[i].Metadata {
+			if s, ok := v.(string); ok && strings.Contains(s, tmp) {
+				nodes[i].Metadata[k] = strings.ReplaceAll(s, tmp, uri)
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// matchAny reports whether the key or name matches one of the patterns.
+func matchAny(patterns []string, key, name string) bool {
+	for _, pattern := range patterns {
+		if reader.MatchGlob(pattern, key) || reader.MatchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Metadata returns reader metadata.
+func (r *Reader) Metadata() reader.ReaderMetadata {
+	return reader.ReaderMetadata{
+		Name:        "ObjectStoreReader",
+		Description: "Loads objects from S3, GCS or Azure Blob Storage through the file-type readers",
+	}
+}
+
+// Ensure Reader implements the reader interfaces.
+var (
+	_ reader.ReaderWithContext  = (*Reader)(nil)
+	_ reader.ReaderWithMetadata = (*Reader)(nil)
+)
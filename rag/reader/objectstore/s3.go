@@ -0,0 +1,308 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Store reads and writes an Amazon S3 bucket, or a bucket of an
+// S3-compatible service such as MinIO or Cloudflare R2, through the S3 REST
+// API. Requests are signed with Signature Version 4; without credentials,
+// only public buckets can be read.
+type S3Store struct {
+	bucket       string
+	region       string
+	endpoint     string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+	now          func() time.Time
+}
+
+// S3Option configures an S3Store.
+type S3Option func(*S3Store)
+
+// WithS3Region sets the bucket's region. Defaults to the AWS_REGION or
+// AWS_DEFAULT_REGION environment variable, or us-east-1.
+func WithS3Region(region string) S3Option {
+	return func(s *S3Store) {
+		s.region = region
+	}
+}
+
+// WithS3Credentials sets the access key, secret key and optional session
+// token. Defaults to the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN environment variables.
+func WithS3Credentials(accessKey, secretKey, sessionToken string) S3Option {
+	return func(s *S3Store) {
+		s.accessKey, s.secretKey, s.sessionToken = accessKey, secretKey, sessionToken
+	}
+}
+
+// WithS3Endpoint sets the endpoint of an S3-compatible service, e.g.
+// "http://localhost:9000". Buckets are then addressed path-style.
+func WithS3Endpoint(endpoint string) S3Option {
+	return func(s *S3Store) {
+		s.endpoint = strings.TrimRight(endpoint, "/")
+	}
+}
+
+// WithS3HTTPClient sets the HTTP client.
+func WithS3HTTPClient(client *http.Client) S3Option {
+	return func(s *S3Store) {
+		s.client = client
+	}
+}
+
+// NewS3Store creates a store for an S3 bucket.
+func NewS3Store(bucket string, opts ...S3Option) *S3Store {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	s := &S3Store{
+		bucket:       bucket,
+		region:       region,
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		client:       http.DefaultClient,
+		now:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// URI returns "s3://bucket".
+func (s *S3Store) URI() string {
+	return "s3://" + s.bucket
+}
+
+// Bucket returns the bucket name.
+func (s *S3Store) Bucket() string {
+	return s.bucket
+}
+
+// List lists the objects under prefix with ListObjectsV2.
+func (s *S3Store) List(ctx context.Context, prefix string, fn func(Object) error) error {
+	query := url.Values{"list-type": {"2"}}
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	for {
+		var page struct {
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+			Contents              []struct {
+				Key          string    `xml:"Key"`
+				LastModified time.Time `xml:"LastModified"`
+				ETag         string    `xml:"ETag"`
+				Size         int64     `xml:"Size"`
+			} `xml:"Contents"`
+		}
+		resp, err := s.get(ctx, "", query)
+		if err != nil {
+			return err
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to decode object list: %w", err)
+		}
+
+		for _, c := range page.Contents {
+			if strings.HasSuffix(c.Key, "/") {
+				continue
+			}
+			obj := Object{Key: c.Key, Size: c.Size, LastModified: c.LastModified.UTC(), ETag: strings.Trim(c.ETag, `"`)}
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		query.Set("continuation-token", page.NextContinuationToken)
+	}
+}
+
+// Open streams an object's content with GetObject.
+func (s *S3Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.get(ctx, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// PutObject uploads data as an object with PutObject. Together with
+// callbacks.ArtifactSink, it stores artifacts in S3.
+func (s *S3Store) PutObject(ctx context.Context, key string, data []byte, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.url(key, nil), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if s.accessKey != "" {
+		sum := sha256.Sum256(data)
+		s.sign(req, hex.EncodeToString(sum[:]), s.now())
+	}
+	resp, err := do(s.client, req, s.URI())
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// get sends a signed GET request for an object key, or for the bucket
+// when key is empty.
+func (s *S3Store) get(ctx context.Context, key string, query url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url(key, query), nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.accessKey != "" {
+		s.sign(req, emptyPayloadHash, s.now())
+	}
+	return do(s.client, req, s.URI())
+}
+
+// url returns the URL of an object key, or of the bucket when key is
+// empty.
+func (s *S3Store) url(key string, query url.Values) string {
+	target := "https://" + s.bucket + ".s3." + s.region + ".amazonaws.com/" + escapePath(key)
+	if s.endpoint != "" {
+		target = s.endpoint + "/" + url.PathEscape(s.bucket) + "/" + escapePath(key)
+	}
+	if len(query) > 0 {
+		target += "?" + canonicalQuery(query)
+	}
+	return target
+}
+
+// sign adds Signature Version 4 headers to a request whose body has the
+// given SHA-256 hash.
+func (s *S3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("x-amz-security-token", s.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	for _, part := range []string{s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQuery encodes query parameters sorted by name, as required by
+// Signature Version 4.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(name)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapePath encodes each segment of an object key.
+func escapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// uriEncode percent-encodes everything but RFC 3986 unreserved characters.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Ensure S3Store implements Store.
+var _ Store = (*S3Store)(nil)
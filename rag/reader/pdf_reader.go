@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/aqua777/go-llamaindex/schema"
@@ -22,6 +23,8 @@ type PDFReader struct {
 	Recursive bool
 	// SplitByPage creates separate nodes for each page
 	SplitByPage bool
+	// MergeHyphenation joins words hyphenated across line breaks
+	MergeHyphenation bool
 	// ExtraMetadata is additional metadata to add to all documents
 	ExtraMetadata map[string]interface{}
 	// PasswordFunc is a function that returns the password for a PDF file
@@ -60,6 +63,13 @@ func WithPDFSplitByPage(split bool) PDFReaderOption {
 	}
 }
 
+// WithPDFMergeHyphenation enables merging of hyphenated line breaks.
+func WithPDFMergeHyphenation(merge bool) PDFReaderOption {
+	return func(r *PDFReader) {
+		r.MergeHyphenation = merge
+	}
+}
+
 // WithPDFExtraMetadata sets extra metadata.
 func WithPDFExtraMetadata(metadata map[string]interface{}) PDFReaderOption {
 	return func(r *PDFReader) {
@@ -107,6 +117,12 @@ func (r *PDFReader) WithSplitByPage(split bool) *PDFReader {
 	return r
 }
 
+// WithMergeHyphenation enables merging of hyphenated line breaks (fluent API).
+func (r *PDFReader) WithMergeHyphenation(merge bool) *PDFReader {
+	r.MergeHyphenation = merge
+	return r
+}
+
 // WithExtraMetadata sets extra metadata (fluent API).
 func (r *PDFReader) WithExtraMetadata(metadata map[string]interface{}) *PDFReader {
 	r.ExtraMetadata = metadata
@@ -161,6 +177,30 @@ func (r *PDFReader) LoadFromFile(filePath string) ([]schema.Node, error) {
 	return r.loadFile(filePath)
 }
 
+// LoadDocument loads a PDF file as one Document holding the text of all
+// pages and the original PDF bytes, whether or not SplitByPage is set.
+func (r *PDFReader) LoadDocument(filePath string) (*schema.Document, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+
+	whole := *r
+	whole.SplitByPage = false
+	nodes, err := whole.loadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema.Document{
+		ID:       filePath,
+		Text:     nodes[0].Text,
+		Metadata: nodes[0].Metadata,
+		Data:     content,
+		MimeType: schema.MimeTypePDF,
+	}, nil
+}
+
 // Metadata returns reader metadata.
 func (r *PDFReader) Metadata() ReaderMetadata {
 	return ReaderMetadata{
@@ -261,7 +301,7 @@ func (r *PDFReader) loadByPage(pdfReader *pdf.Reader, numPages int, filePath str
 			continue
 		}
 
-		text, err := page.GetPlainText(nil)
+		text, err := r.pageText(page)
 		if err != nil {
 			// Try to continue with other pages
 			continue
@@ -302,7 +342,7 @@ func (r *PDFReader) loadEntireDocument(pdfReader *pdf.Reader, numPages int, file
 			continue
 		}
 
-		text, err := page.GetPlainText(nil)
+		text, err := r.pageText(page)
 		if err != nil {
 			// Try to continue with other pages
 			continue
@@ -328,6 +368,30 @@ func (r *PDFReader) loadEntireDocument(pdfReader *pdf.Reader, numPages int, file
 	return []schema.Node{*node}, nil
 }
 
+// pageText extracts the plain text of a page.
+func (r *PDFReader) pageText(page pdf.Page) (string, error) {
+	text, err := page.GetPlainText(nil)
+	if err != nil {
+		return "", err
+	}
+	if r.MergeHyphenation {
+		text = MergeHyphenatedLines(text)
+	}
+	return text, nil
+}
+
+// hyphenatedBreak matches a word split by a hyphen at the end of a line,
+// where the next line continues in lowercase.
+var hyphenatedBreak = regexp.MustCompile(`(\pL)-[ \t]*\r?\n[ \t]*(\p{Ll})`)
+
+// MergeHyphenatedLines joins words that were hyphenated across line breaks,
+// e.g. "retri-\neval" becomes "retrieval". Hyphens followed by an uppercase
+// letter, a digit or a blank line are kept, since they are more likely to be
+// part of a compound or a list.
+func MergeHyphenatedLines(text string) string {
+	return hyphenatedBreak.ReplaceAllString(text, "$1$2")
+}
+
 // LazyLoadData returns a channel that yields documents one at a time.
 func (r *PDFReader) LazyLoadData() (<-chan schema.Node, <-chan error) {
 	nodeChan := make(chan schema.Node)
@@ -439,6 +503,7 @@ func GetPDFMetadata(filePath string) (map[string]string, error) {
 // Ensure PDFReader implements the interfaces.
 var _ Reader = (*PDFReader)(nil)
 var _ FileReader = (*PDFReader)(nil)
+var _ DocumentFileReader = (*PDFReader)(nil)
 var _ ReaderWithMetadata = (*PDFReader)(nil)
 var _ ReaderWithContext = (*PDFReader)(nil)
 var _ LazyReader = (*PDFReader)(nil)
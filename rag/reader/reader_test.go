@@ -1,8 +1,12 @@
 package reader
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -351,6 +355,199 @@ func TestSimpleDirectoryReader(t *testing.T) {
 			t.Errorf("expected 2 docs, got %d", len(docs))
 		}
 	})
+
+	t.Run("binary files keep raw bytes", func(t *testing.T) {
+		pngHeader := []byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A, 0x00, 0x00, 0x00, 0x0D}
+		if err := os.WriteFile(filepath.Join(tmpDir, "image.png"), pngHeader, 0644); err != nil {
+			t.Fatalf("failed to write image.png: %v", err)
+		}
+
+		reader := NewSimpleDirectoryReader(tmpDir, ".png", ".md")
+		docs, err := reader.LoadDocuments()
+		if err != nil {
+			t.Fatalf("LoadDocuments() error = %v", err)
+		}
+		if len(docs) != 2 {
+			t.Fatalf("expected 2 docs, got %d", len(docs))
+		}
+
+		for _, doc := range docs {
+			switch doc.Metadata["filename"] {
+			case "image.png":
+				if doc.MimeType != "image/png" {
+					t.Errorf("expected image/png, got '%s'", doc.MimeType)
+				}
+				if doc.Text != "" {
+					t.Errorf("expected empty text for binary file, got '%s'", doc.Text)
+				}
+				if string(doc.Data) != string(pngHeader) {
+					t.Errorf("expected raw bytes to be preserved")
+				}
+			case "doc3.md":
+				if doc.MimeType != "text/markdown" {
+					t.Errorf("expected text/markdown, got '%s'", doc.MimeType)
+				}
+				if doc.Text != "# Markdown\n\nContent" {
+					t.Errorf("unexpected text '%s'", doc.Text)
+				}
+				if string(doc.Data) != doc.Text {
+					t.Errorf("expected text files to keep their raw bytes")
+				}
+			}
+		}
+
+		nodes, err := reader.LoadData()
+		if err != nil {
+			t.Fatalf("LoadData() error = %v", err)
+		}
+		for _, node := range nodes {
+			if node.MimeType == "" {
+				t.Errorf("expected MimeType to be set on node %s", node.ID)
+			}
+		}
+	})
+
+	t.Run("text files with non-text extensions or encodings", func(t *testing.T) {
+		textFiles := map[string]struct {
+			content []byte
+			text    string
+		}{
+			"run.sh":      {[]byte("#!/bin/sh\necho hi\n"), "#!/bin/sh\necho hi\n"},
+			"config.toml": {[]byte("[server]\nport = 8080\n"), "[server]\nport = 8080\n"},
+			"menu.txt":    {[]byte("caf\xe9 cr\xe8me"), "café crème"},
+		}
+		dir := t.TempDir()
+		for name, f := range textFiles {
+			if err := os.WriteFile(filepath.Join(dir, name), f.content, 0644); err != nil {
+				t.Fatalf("failed to write %s: %v", name, err)
+			}
+		}
+
+		docs, err := NewSimpleDirectoryReader(dir, ".sh", ".toml", ".txt").LoadDocuments()
+		if err != nil {
+			t.Fatalf("LoadDocuments() error = %v", err)
+		}
+		if len(docs) != len(textFiles) {
+			t.Fatalf("expected %d docs, got %d", len(textFiles), len(docs))
+		}
+		for _, doc := range docs {
+			name, _ := doc.Metadata["filename"].(string)
+			if doc.Text != textFiles[name].text {
+				t.Errorf("%s: expected text %q, got %q", name, textFiles[name].text, doc.Text)
+			}
+			if doc.IsBinary() {
+				t.Errorf("%s: expected a text MIME type, got '%s'", name, doc.MimeType)
+			}
+		}
+	})
+}
+
+func TestSimpleDirectoryReaderFileReaders(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"notes.txt":          "plain notes",
+		"data/sales.csv":     "region,revenue\nEU,100\nUS,120\n",
+		"data/item.json":     `{"text": "json item"}`,
+		"web/page.html":      "<html><body><p>Hello page</p><script>x()</script></body></html>",
+		"drafts/draft.txt":   "draft",
+		"deep/a/b/skip.txt":  "skip me",
+		".hidden/secret.txt": "secret",
+	}
+	for name, content := range files {
+		full := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	t.Run("dispatch by extension", func(t *testing.T) {
+		reader := NewSimpleDirectoryReader(tmpDir, ".txt", ".csv", ".json", ".html").
+			WithExclude("drafts", "**/b/*.txt").
+			WithExtraMetadata(map[string]interface{}{"source": "test"}).
+			WithNumWorkers(4)
+
+		docs, err := reader.LoadData()
+		if err != nil {
+			t.Fatalf("LoadData() error = %v", err)
+		}
+
+		// notes.txt, 2 CSV rows, the JSON item and the HTML page.
+		if len(docs) != 5 {
+			t.Fatalf("expected 5 docs, got %d", len(docs))
+		}
+
+		var texts []string
+		for _, doc := range docs {
+			texts = append(texts, doc.Text)
+			if doc.Metadata["source"] != "test" {
+				t.Errorf("expected extra metadata on %s", doc.ID)
+			}
+			if doc.Metadata[FilePathMetadataKey] == nil || doc.Metadata[LastModifiedMetadataKey] == nil {
+				t.Errorf("expected file metadata on %s, got %v", doc.ID, doc.Metadata)
+			}
+		}
+		joined := strings.Join(texts, "\n")
+		for _, want := range []string{"plain notes", "EU", "json item", "Hello page"} {
+			if !strings.Contains(joined, want) {
+				t.Errorf("expected %q in loaded texts", want)
+			}
+		}
+		for _, unwanted := range []string{"x()", "draft", "skip me", "secret"} {
+			if strings.Contains(joined, unwanted) {
+				t.Errorf("unexpected %q in loaded texts", unwanted)
+			}
+		}
+	})
+
+	t.Run("include and recursion", func(t *testing.T) {
+		files, err := NewSimpleDirectoryReader(tmpDir, ".txt").WithInclude("**/*.txt").WithRecursive(false).ListFiles()
+		if err != nil {
+			t.Fatalf("ListFiles() error = %v", err)
+		}
+		if len(files) != 1 || filepath.Base(files[0]) != "notes.txt" {
+			t.Errorf("expected only notes.txt, got %v", files)
+		}
+
+		files, err = NewSimpleDirectoryReader(tmpDir, ".txt").WithInclude("deep/**").WithIncludeHidden(true).ListFiles()
+		if err != nil {
+			t.Fatalf("ListFiles() error = %v", err)
+		}
+		if len(files) != 1 || filepath.Base(files[0]) != "skip.txt" {
+			t.Errorf("expected only skip.txt, got %v", files)
+		}
+	})
+
+	t.Run("custom reader and metadata func", func(t *testing.T) {
+		reader := NewSimpleDirectoryReader(tmpDir, ".txt").
+			WithExclude("deep", "drafts").
+			WithFileReader(".txt", NewMarkdownReader()).
+			WithMetadataFunc(func(path string) map[string]interface{} {
+				return map[string]interface{}{"dir": filepath.Base(filepath.Dir(path))}
+			})
+
+		docs, err := reader.LoadData()
+		if err != nil {
+			t.Fatalf("LoadData() error = %v", err)
+		}
+		if len(docs) != 1 {
+			t.Fatalf("expected 1 doc, got %d", len(docs))
+		}
+		if docs[0].Metadata["dir"] != filepath.Base(tmpDir) {
+			t.Errorf("expected dir metadata, got %v", docs[0].Metadata["dir"])
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := NewSimpleDirectoryReader(tmpDir).LoadDataWithContext(ctx); err == nil {
+			t.Error("expected error for cancelled context")
+		}
+	})
 }
 
 func containsString(s, substr string) bool {
@@ -366,6 +563,31 @@ func containsStringHelper(s, substr string) bool {
 	return false
 }
 
+func TestStreamFileReaders(t *testing.T) {
+	streams := map[string]struct {
+		reader  StreamFileReader
+		content string
+		want    string
+	}{
+		"s3://b/rows.tsv":    {NewCSVReader(), "name\trole\nAnn\tops\n", "Ann"},
+		"s3://b/items.jsonl": {NewJSONReader(), "{\"text\": \"first\"}\n{\"text\": \"second\"}\n", "second"},
+		"s3://b/page.html":   {NewHTMLReader(), "<html><body><p>Hello page</p></body></html>", "Hello page"},
+		"s3://b/notes.md":    {NewMarkdownReader(), "---\ntitle: Notes\n---\n# Notes\n", "# Notes"},
+	}
+	for name, tc := range streams {
+		docs, err := tc.reader.LoadFromReader(strings.NewReader(tc.content), name)
+		if err != nil {
+			t.Fatalf("%s: LoadFromReader() error = %v", name, err)
+		}
+		if len(docs) == 0 || !strings.Contains(docs[len(docs)-1].Text, tc.want) {
+			t.Errorf("%s: expected text containing %q, got %+v", name, tc.want, docs)
+		}
+		if !strings.HasPrefix(docs[0].ID, name) && docs[0].Metadata["source"] != name {
+			t.Errorf("%s: expected the name as ID or source, got %q / %v", name, docs[0].ID, docs[0].Metadata["source"])
+		}
+	}
+}
+
 func TestPDFReader(t *testing.T) {
 	t.Run("constructor with files", func(t *testing.T) {
 		reader := NewPDFReader("test1.pdf", "test2.pdf")
@@ -647,3 +869,122 @@ func TestPDFReaderLazyLoad(t *testing.T) {
 		}
 	})
 }
+
+// writeTestPDF writes a minimal PDF with one page per entry in pages. Each
+// line of a page is drawn on its own text line.
+func writeTestPDF(t *testing.T, path string, pages [][]string) {
+	t.Helper()
+
+	var objects []string
+	kids := make([]string, len(pages))
+	for i, lines := range pages {
+		var content strings.Builder
+		content.WriteString("BT /F1 12 Tf 72 720 Td 14 TL\n")
+		for _, line := range lines {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", line)
+		}
+		content.WriteString("ET")
+
+		pageObj := 4 + 2*i
+		kids[i] = fmt.Sprintf("%d 0 R", pageObj)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pageObj+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+	objects = append([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}, objects...)
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write PDF: %v", err)
+	}
+}
+
+func TestPDFReaderPages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guide.pdf")
+	writeTestPDF(t, path, [][]string{
+		{"Dense retri-", "eval finds passages."},
+		{},
+		{"Sparse search uses BM25."},
+	})
+
+	docs, err := NewPDFReader(path).WithSplitByPage(true).LoadData()
+	if err != nil {
+		t.Fatalf("LoadData() error: %v", err)
+	}
+	// The blank second page is skipped.
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 documents, got %d", len(docs))
+	}
+	if docs[0].Metadata["page_number"] != 1 || docs[1].Metadata["page_number"] != 3 {
+		t.Errorf("unexpected page numbers: %v, %v", docs[0].Metadata["page_number"], docs[1].Metadata["page_number"])
+	}
+	if docs[0].Metadata["file_name"] != "guide.pdf" || docs[0].Metadata["total_pages"] != 3 {
+		t.Errorf("unexpected file metadata: %v", docs[0].Metadata)
+	}
+	if !strings.Contains(docs[1].Text, "BM25") {
+		t.Errorf("expected second document to hold page 3, got %q", docs[1].Text)
+	}
+
+	merged, err := NewPDFReader(path).WithSplitByPage(true).WithMergeHyphenation(true).LoadData()
+	if err != nil {
+		t.Fatalf("LoadData() error: %v", err)
+	}
+	if !strings.Contains(merged[0].Text, "retrieval") {
+		t.Errorf("expected hyphenation to be merged, got %q", merged[0].Text)
+	}
+
+	whole, err := NewPDFReader(path).LoadData()
+	if err != nil {
+		t.Fatalf("LoadData() error: %v", err)
+	}
+	if len(whole) != 1 || !strings.Contains(whole[0].Text, "BM25") {
+		t.Errorf("expected a single document with all pages, got %d", len(whole))
+	}
+
+	// SimpleDirectoryReader splits PDFs by page by default.
+	dirDocs, err := NewSimpleDirectoryReader(filepath.Dir(path), ".pdf").LoadData()
+	if err != nil {
+		t.Fatalf("LoadData() error: %v", err)
+	}
+	if len(dirDocs) != 2 || dirDocs[1].Metadata["page_number"] != 3 {
+		t.Errorf("expected one document per page, got %d", len(dirDocs))
+	}
+}
+
+func TestMergeHyphenatedLines(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"retri-\neval", "retrieval"},
+		{"retri- \r\n  eval works", "retrieval works"},
+		{"Jean-\nPaul", "Jean-\nPaul"},
+		{"items:\n- one\n- two", "items:\n- one\n- two"},
+		{"pages 10-\n12", "pages 10-\n12"},
+		{"well-known", "well-known"},
+	}
+
+	for _, tt := range tests {
+		if got := MergeHyphenatedLines(tt.input); got != tt.expected {
+			t.Errorf("MergeHyphenatedLines(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
@@ -1,19 +1,47 @@
 package reader
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aqua777/go-llamaindex/schema"
 )
 
+// Metadata keys set by SimpleDirectoryReader on every loaded document.
+const (
+	// FilePathMetadataKey holds the file path.
+	FilePathMetadataKey = "file_path"
+	// FileNameMetadataKey holds the file name.
+	FileNameMetadataKey = "file_name"
+	// FileSizeMetadataKey holds the file size in bytes.
+	FileSizeMetadataKey = "file_size"
+	// LastModifiedMetadataKey holds the file's modification time in RFC 3339.
+	LastModifiedMetadataKey = "last_modified"
+)
+
 // SimpleDirectoryReader reads files from a directory.
+//
+// Files are dispatched by extension to registered FileReaders, e.g. CSV rows
+// to CSVReader; files without a reader are loaded as a single document with
+// their raw content. NewSimpleDirectoryReader registers DefaultFileReaders.
 type SimpleDirectoryReader struct {
-	inputDir   string
-	extensions []string // e.g. ".txt", ".md"
+	inputDir      string
+	extensions    []string // e.g. ".txt", ".md"
+	recursive     bool
+	include       []string
+	exclude       []string
+	includeHidden bool
+	numWorkers    int
+	fileReaders   map[string]FileReader
+	extraMetadata map[string]interface{}
+	metadataFunc  func(path string) map[string]interface{}
 }
 
 // NewSimpleDirectoryReader creates a new SimpleDirectoryReader.
@@ -23,28 +51,206 @@ func NewSimpleDirectoryReader(inputDir string, extensions ...string) *SimpleDire
 		extensions = []string{".txt", ".md"}
 	}
 	return &SimpleDirectoryReader{
-		inputDir:   inputDir,
-		extensions: extensions,
+		inputDir:    inputDir,
+		extensions:  extensions,
+		recursive:   true,
+		numWorkers:  1,
+		fileReaders: DefaultFileReaders(),
+	}
+}
+
+// DefaultFileReaders returns the file readers registered by
+// NewSimpleDirectoryReader: CSV, JSON, JSON Lines, HTML, and PDF split into
+// one document per page. Text and Markdown files are loaded as they are;
+// register a MarkdownReader for ".md" to clean them up or split them by
+// headers.
+func DefaultFileReaders() map[string]FileReader {
+	jsonReader := NewJSONReader()
+	htmlReader := NewHTMLReader()
+	return map[string]FileReader{
+		".csv":   NewCSVReader(),
+		".json":  jsonReader,
+		".jsonl": jsonReader,
+		".html":  htmlReader,
+		".htm":   htmlReader,
+		".pdf":   NewPDFReader().WithSplitByPage(true),
+	}
+}
+
+// WithFileReader registers reader for files with extension ext, e.g. ".pdf".
+// A nil reader loads the files with their raw content.
+func (r *SimpleDirectoryReader) WithFileReader(ext string, reader FileReader) *SimpleDirectoryReader {
+	ext = strings.ToLower(ext)
+	if reader == nil {
+		delete(r.fileReaders, ext)
+	} else {
+		r.fileReaders[ext] = reader
+	}
+	return r
+}
+
+// WithRecursive sets whether subdirectories are read. Defaults to true.
+func (r *SimpleDirectoryReader) WithRecursive(recursive bool) *SimpleDirectoryReader {
+	r.recursive = recursive
+	return r
+}
+
+// WithInclude only reads files matching one of the glob patterns. Patterns
+// are matched against the path relative to the input directory, with
+// forward slashes, and against the file name; "**/" matches any number of
+// directories.
+func (r *SimpleDirectoryReader) WithInclude(patterns ...string) *SimpleDirectoryReader {
+	r.include = append(r.include, patterns...)
+	return r
+}
+
+// WithExclude skips files and directories matching one of the glob
+// patterns, matched like WithInclude.
+func (r *SimpleDirectoryReader) WithExclude(patterns ...string) *SimpleDirectoryReader {
+	r.exclude = append(r.exclude, patterns...)
+	return r
+}
+
+// WithIncludeHidden sets whether files and directories starting with a dot
+// are read. Defaults to false.
+func (r *SimpleDirectoryReader) WithIncludeHidden(include bool) *SimpleDirectoryReader {
+	r.includeHidden = include
+	return r
+}
+
+// WithNumWorkers sets how many files are loaded concurrently. Documents are
+// returned in directory order regardless.
+func (r *SimpleDirectoryReader) WithNumWorkers(n int) *SimpleDirectoryReader {
+	if n > 0 {
+		r.numWorkers = n
 	}
+	return r
+}
+
+// WithExtraMetadata adds metadata to every loaded document.
+func (r *SimpleDirectoryReader) WithExtraMetadata(metadata map[string]interface{}) *SimpleDirectoryReader {
+	r.extraMetadata = metadata
+	return r
+}
+
+// WithMetadataFunc sets a function returning metadata for each file, e.g.
+// a category derived from its directory.
+func (r *SimpleDirectoryReader) WithMetadataFunc(fn func(path string) map[string]interface{}) *SimpleDirectoryReader {
+	r.metadataFunc = fn
+	return r
 }
 
 // LoadData reads files and returns a slice of Documents (Nodes with type Document).
+// Files with a registered FileReader return the reader's documents. Other
+// binary files are returned with an empty Text and their sniffed MIME type;
+// use LoadDocuments to access the original bytes.
 func (r *SimpleDirectoryReader) LoadData() ([]schema.Node, error) {
-	var docs []schema.Node
+	return r.LoadDataWithContext(context.Background())
+}
+
+// LoadDataWithContext is LoadData with cancellation.
+func (r *SimpleDirectoryReader) LoadDataWithContext(ctx context.Context) ([]schema.Node, error) {
+	files, err := r.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := loadConcurrently(ctx, files, r.numWorkers, func(file string) ([]schema.Node, error) {
+		reader, ok := r.fileReaders[strings.ToLower(filepath.Ext(file))]
+		if !ok {
+			doc, err := r.loadDocument(file)
+			if err != nil {
+				return nil, err
+			}
+			return []schema.Node{{
+				ID:       doc.ID,
+				Text:     doc.Text,
+				Type:     schema.ObjectTypeDocument,
+				Metadata: doc.Metadata,
+				MimeType: doc.MimeType,
+			}}, nil
+		}
 
-	err := filepath.WalkDir(r.inputDir, func(path string, d fs.DirEntry, err error) error {
+		nodes, err := reader.LoadFromFile(file)
+		if err != nil {
+			return nil, NewReaderError(file, "failed to load file", err)
+		}
+		stats, err := fileStatMetadata(file)
+		if err != nil {
+			return nil, err
+		}
+		custom := r.customMetadata(file)
+		for i := range nodes {
+			if nodes[i].Metadata == nil {
+				nodes[i].Metadata = make(map[string]interface{})
+			}
+			// Keep what the reader set, but let the caller's metadata win.
+			for k, v := range stats {
+				if _, exists := nodes[i].Metadata[k]; !exists {
+					nodes[i].Metadata[k] = v
+				}
+			}
+			for k, v := range custom {
+				nodes[i].Metadata[k] = v
+			}
+		}
+		return nodes, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]schema.Node, 0, len(results))
+	for _, nodes := range results {
+		docs = append(docs, nodes...)
+	}
+	return docs, nil
+}
+
+// LoadDocuments reads files and returns binary-safe Documents.
+// Each document keeps the raw file content in Data and a MIME type sniffed
+// from the content and file extension. Text is populated for textual files,
+// and for binary files whose registered reader is a DocumentFileReader, such
+// as PDFReader. Other registered FileReaders are not used.
+func (r *SimpleDirectoryReader) LoadDocuments() ([]schema.Document, error) {
+	files, err := r.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	return loadConcurrently(context.Background(), files, r.numWorkers, r.loadDocument)
+}
+
+// ListFiles returns the files that would be loaded, in directory order.
+func (r *SimpleDirectoryReader) ListFiles() ([]string, error) {
+	var files []string
+
+	err := filepath.WalkDir(r.inputDir, func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
+		rel, _ := filepath.Rel(r.inputDir, p)
+		rel = filepath.ToSlash(rel)
+
 		if d.IsDir() {
+			if p == r.inputDir {
+				return nil
+			}
+			if !r.recursive || (!r.includeHidden && strings.HasPrefix(d.Name(), ".")) || matchAnyGlob(r.exclude, rel, d.Name()) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
-		ext := strings.ToLower(filepath.Ext(path))
+		if !r.includeHidden && strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		ext := strings.ToLower(filepath.Ext(p))
 		match := false
 		for _, e := range r.extensions {
-			if ext == e {
+			if ext == strings.ToLower(e) {
 				match = true
 				break
 			}
@@ -53,23 +259,14 @@ func (r *SimpleDirectoryReader) LoadData() ([]schema.Node, error) {
 		if !match {
 			return nil
 		}
-
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("failed to read file %s: %w", path, err)
+		if len(r.include) > 0 && !matchAnyGlob(r.include, rel, d.Name()) {
+			return nil
 		}
-
-		doc := schema.Node{
-			ID:   path, // Use file path as ID for now
-			Text: string(content),
-			Type: schema.ObjectTypeDocument,
-			Metadata: map[string]interface{}{
-				"filename": d.Name(),
-				"path":     path,
-				"ext":      ext,
-			},
+		if matchAnyGlob(r.exclude, rel, d.Name()) {
+			return nil
 		}
-		docs = append(docs, doc)
+
+		files = append(files, p)
 		return nil
 	})
 
@@ -77,5 +274,175 @@ func (r *SimpleDirectoryReader) LoadData() ([]schema.Node, error) {
 		return nil, fmt.Errorf("failed to walk directory %s: %w", r.inputDir, err)
 	}
 
-	return docs, nil
+	return files, nil
+}
+
+// loadDocument reads a file into a binary-safe Document. Files whose
+// registered reader is a DocumentFileReader are loaded by it, so that they
+// carry the extracted text as well as their bytes.
+func (r *SimpleDirectoryReader) loadDocument(file string) (schema.Document, error) {
+	ext := strings.ToLower(filepath.Ext(file))
+	metadata, err := fileStatMetadata(file)
+	if err != nil {
+		return schema.Document{}, err
+	}
+	for k, v := range r.customMetadata(file) {
+		metadata[k] = v
+	}
+	metadata["filename"] = filepath.Base(file)
+	metadata["path"] = file
+	metadata["ext"] = ext
+
+	if reader, ok := r.fileReaders[ext].(DocumentFileReader); ok {
+		doc, err := reader.LoadDocument(file)
+		if err != nil {
+			return schema.Document{}, NewReaderError(file, "failed to load file", err)
+		}
+		if doc.Metadata == nil {
+			doc.Metadata = make(map[string]interface{})
+		}
+		for k, v := range metadata {
+			doc.Metadata[k] = v
+		}
+		return *doc, nil
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return schema.Document{}, fmt.Errorf("failed to read file %s: %w", file, err)
+	}
+	return *schema.NewDocumentFromData(file, content, "", metadata), nil
+}
+
+// fileStatMetadata returns the path, name, size and modification time of
+// a file as metadata.
+func fileStatMetadata(file string) (map[string]interface{}, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file %s: %w", file, err)
+	}
+
+	return map[string]interface{}{
+		FilePathMetadataKey:     file,
+		FileNameMetadataKey:     info.Name(),
+		FileSizeMetadataKey:     info.Size(),
+		LastModifiedMetadataKey: info.ModTime().UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// customMetadata returns the extra metadata and the metadata function's
+// result for a file.
+func (r *SimpleDirectoryReader) customMetadata(file string) map[string]interface{} {
+	metadata := make(map[string]interface{}, len(r.extraMetadata))
+	for k, v := range r.extraMetadata {
+		metadata[k] = v
+	}
+	if r.metadataFunc != nil {
+		for k, v := range r.metadataFunc(file) {
+			metadata[k] = v
+		}
+	}
+	return metadata
 }
+
+// loadConcurrently calls load for each file with up to numWorkers workers
+// and returns the results in file order. The first error stops loading.
+func loadConcurrently[T any](ctx context.Context, files []string, numWorkers int, load func(file string) (T, error)) ([]T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]T, len(files))
+	indexes := make(chan int)
+	var once sync.Once
+	var firstErr error
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				result, err := load(files[i])
+				if err != nil {
+					fail(err)
+					continue
+				}
+				results[i] = result
+			}
+		}()
+	}
+
+send:
+	for i := range files {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// matchAnyGlob reports whether the relative path or name matches one of the
+// patterns.
+func matchAnyGlob(patterns []string, rel, name string) bool {
+	for _, pattern := range patterns {
+		pattern = filepath.ToSlash(pattern)
+		if MatchGlob(pattern, rel) || MatchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchGlob matches a slash-separated path against a glob pattern in which
+// "**" matches any number of path segments, as used by WithInclude.
+func MatchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "**") {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}
+
+	patternParts := strings.Split(pattern, "/")
+	nameParts := strings.Split(name, "/")
+	var match func(pi, ni int) bool
+	match = func(pi, ni int) bool {
+		if pi == len(patternParts) {
+			return ni == len(nameParts)
+		}
+		if patternParts[pi] == "**" {
+			for k := ni; k <= len(nameParts); k++ {
+				if match(pi+1, k) {
+					return true
+				}
+			}
+			return false
+		}
+		if ni == len(nameParts) {
+			return false
+		}
+		if ok, _ := path.Match(patternParts[pi], nameParts[ni]); !ok {
+			return false
+		}
+		return match(pi+1, ni+1)
+	}
+	return match(0, 0)
+}
+
+// Ensure SimpleDirectoryReader implements ReaderWithContext.
+var _ ReaderWithContext = (*SimpleDirectoryReader)(nil)
@@ -0,0 +1,306 @@
+package reader
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ColumnType is the SQL type inferred for a table column.
+type ColumnType string
+
+const (
+	// ColumnTypeInteger holds whole numbers.
+	ColumnTypeInteger ColumnType = "INTEGER"
+	// ColumnTypeReal holds decimal numbers.
+	ColumnTypeReal ColumnType = "REAL"
+	// ColumnTypeText holds anything else.
+	ColumnTypeText ColumnType = "TEXT"
+)
+
+// TableColumn is a column of a Table.
+type TableColumn struct {
+	// Name is the SQL identifier for the column.
+	Name string
+	// Header is the original header text.
+	Header string
+	// Type is the inferred column type.
+	Type ColumnType
+}
+
+// Table is tabular data with inferred column types, ready to be loaded
+// into a SQL database.
+type Table struct {
+	// Name is the SQL identifier for the table.
+	Name string
+	// Source is the file the table was read from.
+	Source string
+	// Columns describes each column.
+	Columns []TableColumn
+	// Rows holds the values, converted to int64, float64 or string. Empty
+	// cells are nil.
+	Rows [][]interface{}
+}
+
+// InferTable builds a Table from string records. A column is INTEGER if
+// every non-empty value parses as an integer, REAL if every non-empty value
+// parses as a number, and TEXT otherwise.
+func InferTable(name string, headers []string, records [][]string) *Table {
+	t := &Table{Name: SQLIdentifier(name)}
+
+	seen := make(map[string]int)
+	for i, header := range headers {
+		if strings.TrimSpace(header) == "" {
+			header = fmt.Sprintf("column_%d", i+1)
+		}
+		colName := SQLIdentifier(header)
+		seen[colName]++
+		if n := seen[colName]; n > 1 {
+			colName = fmt.Sprintf("%s_%d", colName, n)
+		}
+		t.Columns = append(t.Columns, TableColumn{
+			Name:   colName,
+			Header: header,
+			Type:   inferColumnType(records, i),
+		})
+	}
+
+	for _, record := range records {
+		row := make([]interface{}, len(t.Columns))
+		for i, col := range t.Columns {
+			if i < len(record) {
+				row[i] = convertCell(record[i], col.Type)
+			}
+		}
+		t.Rows = append(t.Rows, row)
+	}
+
+	return t
+}
+
+func inferColumnType(records [][]string, col int) ColumnType {
+	colType := ColumnTypeInteger
+	nonEmpty := 0
+	for _, record := range records {
+		if col >= len(record) {
+			continue
+		}
+		value := strings.TrimSpace(record[col])
+		if value == "" {
+			continue
+		}
+		nonEmpty++
+		if colType == ColumnTypeInteger {
+			if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+				continue
+			}
+			colType = ColumnTypeReal
+		}
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return ColumnTypeText
+		}
+	}
+	if nonEmpty == 0 {
+		return ColumnTypeText
+	}
+	return colType
+}
+
+func convertCell(value string, colType ColumnType) interface{} {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return nil
+	}
+	switch colType {
+	case ColumnTypeInteger:
+		n, _ := strconv.ParseInt(trimmed, 10, 64)
+		return n
+	case ColumnTypeReal:
+		f, _ := strconv.ParseFloat(trimmed, 64)
+		return f
+	default:
+		return value
+	}
+}
+
+// SQLIdentifier turns arbitrary text into a lower-case SQL identifier made
+// of letters, digits and underscores.
+func SQLIdentifier(s string) string {
+	var b strings.Builder
+	lastUnderscore := false
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			lastUnderscore = false
+		} else if !lastUnderscore {
+			b.WriteRune('_')
+			lastUnderscore = true
+		}
+	}
+	id := strings.Trim(b.String(), "_")
+	if id == "" {
+		return "t"
+	}
+	if unicode.IsDigit(rune(id[0])) {
+		id = "t_" + id
+	}
+	return id
+}
+
+// CreateTableSQL returns the CREATE TABLE statement for the table.
+func (t *Table) CreateTableSQL() string {
+	cols := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		cols[i] = fmt.Sprintf("%q %s", c.Name, c.Type)
+	}
+	return fmt.Sprintf("CREATE TABLE %q (%s)", t.Name, strings.Join(cols, ", "))
+}
+
+// InsertSQL returns a parameterized INSERT statement for one row.
+func (t *Table) InsertSQL() string {
+	cols := make([]string, len(t.Columns))
+	params := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		cols[i] = fmt.Sprintf("%q", c.Name)
+		params[i] = "?"
+	}
+	return fmt.Sprintf("INSERT INTO %q (%s) VALUES (%s)", t.Name, strings.Join(cols, ", "), strings.Join(params, ", "))
+}
+
+// Describe returns the table schema with up to sampleRows example rows, for
+// use in prompts.
+func (t *Table) Describe(sampleRows int) string {
+	var b strings.Builder
+	b.WriteString(t.CreateTableSQL())
+	for _, c := range t.Columns {
+		if c.Header != c.Name {
+			fmt.Fprintf(&b, "\n-- %s: %q", c.Name, c.Header)
+		}
+	}
+	if sampleRows > len(t.Rows) {
+		sampleRows = len(t.Rows)
+	}
+	if sampleRows > 0 {
+		fmt.Fprintf(&b, "\n-- %d example rows:", sampleRows)
+		for _, row := range t.Rows[:sampleRows] {
+			values := make([]string, len(row))
+			for i, v := range row {
+				if v == nil {
+					values[i] = "NULL"
+				} else {
+					values[i] = fmt.Sprintf("%v", v)
+				}
+			}
+			fmt.Fprintf(&b, "\n-- %s", strings.Join(values, " | "))
+		}
+	}
+	return b.String()
+}
+
+// TableReader reads CSV and Excel files into Tables for SQL querying.
+type TableReader struct {
+	// Delimiter is the CSV field delimiter (default: comma, tab for .tsv).
+	Delimiter rune
+	// SheetNames limits which Excel sheets are read. Empty reads all.
+	SheetNames []string
+}
+
+// NewTableReader creates a new TableReader.
+func NewTableReader() *TableReader {
+	return &TableReader{}
+}
+
+// WithDelimiter sets the CSV field delimiter.
+func (r *TableReader) WithDelimiter(delimiter rune) *TableReader {
+	r.Delimiter = delimiter
+	return r
+}
+
+// WithSheets limits which Excel sheets are read.
+func (r *TableReader) WithSheets(sheets ...string) *TableReader {
+	r.SheetNames = sheets
+	return r
+}
+
+// LoadTables reads a .csv, .tsv, .xlsx or .xlsm file. CSV files produce
+// one table named after the file; Excel files produce one table per sheet,
+// named "<file>_<sheet>". The first row is used as the header.
+func (r *TableReader) LoadTables(filePath string) ([]*Table, error) {
+	base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".csv", ".tsv":
+		records, err := r.readCSV(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, nil
+		}
+		t := InferTable(base, records[0], records[1:])
+		t.Source = filePath
+		return []*Table{t}, nil
+	case ".xlsx", ".xlsm":
+		return r.readExcel(filePath, base)
+	default:
+		return nil, fmt.Errorf("unsupported table file: %s", filePath)
+	}
+}
+
+func (r *TableReader) readCSV(filePath string) ([][]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	switch {
+	case r.Delimiter != 0:
+		reader.Comma = r.Delimiter
+	case strings.EqualFold(filepath.Ext(filePath), ".tsv"):
+		reader.Comma = '\t'
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+	return records, nil
+}
+
+func (r *TableReader) readExcel(filePath, base string) ([]*Table, error) {
+	f, err := excelize.OpenFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Excel file: %w", err)
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(r.SheetNames) > 0 {
+		sheets = (&ExcelReader{}).filterSheets(sheets, r.SheetNames)
+	}
+
+	var tables []*Table
+	for _, sheet := range sheets {
+		rows, err := f.GetRows(sheet)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sheet %s: %w", sheet, err)
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		t := InferTable(base+"_"+sheet, rows[0], rows[1:])
+		t.Source = filePath
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
@@ -0,0 +1,81 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferTable(t *testing.T) {
+	table := InferTable("Sales Q1", []string{"Region", "Units", "Revenue ($)", "units", ""}, [][]string{
+		{"North", "10", "1200.50", "1", "x"},
+		{"South", "", "900", "2"},
+		{"East", "7", "", "3", "y"},
+	})
+
+	assert.Equal(t, "sales_q1", table.Name)
+	require.Len(t, table.Columns, 5)
+	assert.Equal(t, TableColumn{Name: "region", Header: "Region", Type: ColumnTypeText}, table.Columns[0])
+	assert.Equal(t, ColumnTypeInteger, table.Columns[1].Type)
+	assert.Equal(t, "revenue", table.Columns[2].Name)
+	assert.Equal(t, ColumnTypeReal, table.Columns[2].Type)
+	assert.Equal(t, "units_2", table.Columns[3].Name)
+	assert.Equal(t, "column_5", table.Columns[4].Name)
+
+	assert.Equal(t, []interface{}{"North", int64(10), 1200.5, int64(1), "x"}, table.Rows[0])
+	assert.Equal(t, []interface{}{"South", nil, 900.0, int64(2), nil}, table.Rows[1])
+
+	assert.Equal(t,
+		`CREATE TABLE "sales_q1" ("region" TEXT, "units" INTEGER, "revenue" REAL, "units_2" INTEGER, "column_5" TEXT)`,
+		table.CreateTableSQL())
+	assert.Equal(t,
+		`INSERT INTO "sales_q1" ("region", "units", "revenue", "units_2", "column_5") VALUES (?, ?, ?, ?, ?)`,
+		table.InsertSQL())
+
+	desc := table.Describe(1)
+	assert.Contains(t, desc, `-- revenue: "Revenue ($)"`)
+	assert.Contains(t, desc, "-- North | 10 | 1200.5 | 1 | x")
+	assert.NotContains(t, desc, "South")
+}
+
+func TestSQLIdentifier(t *testing.T) {
+	assert.Equal(t, "order_date", SQLIdentifier(" Order Date "))
+	assert.Equal(t, "t_2024_sales", SQLIdentifier("2024-Sales"))
+	assert.Equal(t, "t", SQLIdentifier("$$$"))
+}
+
+func TestTableReader_LoadTables(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	csvPath := filepath.Join(tmpDir, "products.csv")
+	require.NoError(t, os.WriteFile(csvPath, []byte("name,price\nWidget,2.5\nGadget,10\n"), 0o644))
+
+	tables, err := NewTableReader().LoadTables(csvPath)
+	require.NoError(t, err)
+	require.Len(t, tables, 1)
+	assert.Equal(t, "products", tables[0].Name)
+	assert.Equal(t, csvPath, tables[0].Source)
+	assert.Equal(t, ColumnTypeReal, tables[0].Columns[1].Type)
+	assert.Len(t, tables[0].Rows, 2)
+
+	xlsxPath := filepath.Join(tmpDir, "staff.xlsx")
+	createTestExcelFile(t, xlsxPath, map[string][][]string{
+		"People": {
+			{"name", "age"},
+			{"Alice", "30"},
+		},
+	})
+
+	tables, err = NewTableReader().LoadTables(xlsxPath)
+	require.NoError(t, err)
+	require.Len(t, tables, 1)
+	assert.Equal(t, "staff_people", tables[0].Name)
+	assert.Equal(t, ColumnTypeInteger, tables[0].Columns[1].Type)
+	assert.Equal(t, []interface{}{"Alice", int64(30)}, tables[0].Rows[0])
+
+	_, err = NewTableReader().LoadTables(filepath.Join(tmpDir, "notes.txt"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,467 @@
+package reader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// CrawlDepthMetadataKey holds the number of links followed from a start
+// page or sitemap entry to reach a crawled page.
+const CrawlDepthMetadataKey = "crawl_depth"
+
+const (
+	// maxSitemapSize bounds sitemap downloads; the sitemap protocol allows
+	// up to 50MB uncompressed.
+	maxSitemapSize = 50 << 20
+	// maxSitemapNesting bounds how deep sitemap indexes are followed.
+	maxSitemapNesting = 3
+)
+
+// WebCrawler ingests a whole site. Pages listed in sitemaps and the start
+// pages are fetched first, then links are followed breadth-first up to
+// MaxDepth. Pages are fetched and converted by a WebReader, whose
+// robots.txt, boilerplate, format and concurrency settings apply.
+//
+// URLs are deduplicated before fetching, after redirects, and by the
+// canonical URL pages declare, so each page yields one document.
+type WebCrawler struct {
+	// Reader fetches and converts pages.
+	Reader *WebReader
+	// StartURLs are the pages the crawl starts from.
+	StartURLs []string
+	// SitemapURLs are sitemap.xml files, or sitemap indexes, listing pages
+	// to crawl. Gzipped sitemaps are supported.
+	SitemapURLs []string
+	// MaxDepth is the number of links followed from start pages and
+	// sitemap entries. Zero fetches only those pages.
+	MaxDepth int
+	// MaxPages is the maximum number of documents. Zero means no limit.
+	MaxPages int
+	// SameHost follows only links to the hosts of the start pages and
+	// sitemaps.
+	SameHost bool
+	// Include, if set, keeps only discovered URLs matching one of the
+	// patterns. Start URLs are always fetched.
+	Include []*regexp.Regexp
+	// Exclude drops discovered URLs matching any of the patterns.
+	Exclude []*regexp.Regexp
+	// Delay is the minimum time between requests to the same host.
+	Delay time.Duration
+	// OnError is called, possibly concurrently, for pages and sitemaps
+	// that fail to load. A nil return skips them and a non-nil one stops
+	// the crawl. Without OnError, failures are skipped.
+	OnError func(pageURL string, err error) error
+}
+
+// NewWebCrawler creates a WebCrawler that starts from the given pages. It
+// respects robots.txt, follows links two levels deep within the start
+// hosts, and stops after 100 pages.
+func NewWebCrawler(startURLs ...string) *WebCrawler {
+	return &WebCrawler{
+		Reader:    NewWebReader().WithRespectRobotsTxt(true),
+		StartURLs: startURLs,
+		MaxDepth:  2,
+		MaxPages:  100,
+		SameHost:  true,
+		Delay:     500 * time.Millisecond,
+	}
+}
+
+// WithReader sets the reader used to fetch pages.
+func (c *WebCrawler) WithReader(reader *WebReader) *WebCrawler {
+	c.Reader = reader
+	return c
+}
+
+// WithSitemaps sets the sitemaps to crawl.
+func (c *WebCrawler) WithSitemaps(urls ...string) *WebCrawler {
+	c.SitemapURLs = urls
+	return c
+}
+
+// WithMaxDepth sets the maximum link depth.
+func (c *WebCrawler) WithMaxDepth(depth int) *WebCrawler {
+	c.MaxDepth = depth
+	return c
+}
+
+// WithMaxPages sets the maximum number of documents.
+func (c *WebCrawler) WithMaxPages(n int) *WebCrawler {
+	c.MaxPages = n
+	return c
+}
+
+// WithSameHost restricts link following to the start hosts.
+func (c *WebCrawler) WithSameHost(sameHost bool) *WebCrawler {
+	c.SameHost = sameHost
+	return c
+}
+
+// WithInclude sets the patterns discovered URLs must match.
+func (c *WebCrawler) WithInclude(patterns ...*regexp.Regexp) *WebCrawler {
+	c.Include = patterns
+	return c
+}
+
+// WithExclude sets the patterns of URLs to skip.
+func (c *WebCrawler) WithExclude(patterns ...*regexp.Regexp) *WebCrawler {
+	c.Exclude = patterns
+	return c
+}
+
+// WithDelay sets the politeness delay between requests to a host.
+func (c *WebCrawler) WithDelay(delay time.Duration) *WebCrawler {
+	c.Delay = delay
+	return c
+}
+
+// WithOnError sets the failure handler.
+func (c *WebCrawler) WithOnError(onError func(pageURL string, err error) error) *WebCrawler {
+	c.OnError = onError
+	return c
+}
+
+// LoadData crawls the site and returns one document per page.
+func (c *WebCrawler) LoadData() ([]schema.Node, error) {
+	return c.LoadDataWithContext(context.Background())
+}
+
+// crawledPage is the outcome of fetching one page.
+type crawledPage struct {
+	doc   *schema.Node
+	links []string
+}
+
+// LoadDataWithContext crawls the site with context support. Documents are
+// returned in crawl order.
+func (c *WebCrawler) LoadDataWithContext(ctx context.Context) ([]schema.Node, error) {
+	if len(c.StartURLs) == 0 && len(c.SitemapURLs) == 0 {
+		return nil, fmt.Errorf("no start URLs or sitemaps specified")
+	}
+	reader := c.Reader
+	if reader == nil {
+		reader = NewWebReader()
+	}
+	workers := reader.MaxConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	hosts := make(map[string]bool)
+	seen := make(map[string]bool)
+	var frontier []string
+	enqueue := func(rawURL string, explicit bool) {
+		u := normalizeCrawlURL(rawURL)
+		if u == "" || seen[u] || (!explicit && !c.accepts(u, hosts)) {
+			return
+		}
+		seen[u] = true
+		frontier = append(frontier, u)
+	}
+
+	for _, u := range append(append([]string(nil), c.StartURLs...), c.SitemapURLs...) {
+		if host := crawlHost(u); host != "" {
+			hosts[host] = true
+		}
+	}
+	for _, start := range c.StartURLs {
+		enqueue(start, true)
+	}
+	for _, sitemap := range c.SitemapURLs {
+		pages, err := c.readSitemap(ctx, reader, sitemap, 0)
+		if err != nil {
+			if err := c.fail(ctx, sitemap, err); err != nil {
+				return nil, err
+			}
+		}
+		for _, page := range pages {
+			enqueue(page, false)
+		}
+	}
+
+	throttle := &hostThrottle{delay: c.Delay, next: make(map[string]time.Time)}
+	load := func(pageURL string) (crawledPage, error) {
+		if err := throttle.wait(ctx, pageURL); err != nil {
+			return crawledPage{}, err
+		}
+		doc, links, err := reader.loadPage(ctx, pageURL)
+		if errors.Is(err, ErrDisallowedByRobots) {
+			return crawledPage{}, nil
+		}
+		if err != nil {
+			return crawledPage{}, c.fail(ctx, pageURL, err)
+		}
+		return crawledPage{doc: doc, links: links}, nil
+	}
+
+	docIDs := make(map[string]bool)
+	var docs []schema.Node
+crawl:
+	for depth := 0; len(frontier) > 0; depth++ {
+		level := frontier
+		frontier = nil
+		for len(level) > 0 {
+			batch := level
+			if c.MaxPages > 0 {
+				remaining := c.MaxPages - len(docs)
+				if remaining <= 0 {
+					break crawl
+				}
+				if len(batch) > remaining {
+					batch = batch[:remaining]
+				}
+			}
+			level = level[len(batch):]
+
+			pages, err := loadConcurrently(ctx, batch, workers, load)
+			if err != nil {
+				return nil, err
+			}
+			for _, page := range pages {
+				if page.doc == nil {
+					continue
+				}
+				// Redirect targets and canonical URLs are not fetched again.
+				if final, ok := page.doc.Metadata[URLMetadataKey].(string); ok {
+					seen[normalizeCrawlURL(final)] = true
+				}
+				id := normalizeCrawlURL(page.doc.ID)
+				if id == "" {
+					id = page.doc.ID
+				}
+				if docIDs[id] {
+					continue
+				}
+				docIDs[id] = true
+				seen[id] = true
+
+				page.doc.Metadata[CrawlDepthMetadataKey] = depth
+				docs = append(docs, *page.doc)
+				if depth < c.MaxDepth {
+					for _, link := range page.links {
+						enqueue(link, false)
+					}
+				}
+			}
+		}
+	}
+	return docs, nil
+}
+
+// accepts reports whether a discovered URL should be crawled.
+func (c *WebCrawler) accepts(pageURL string, hosts map[string]bool) bool {
+	if c.SameHost && !hosts[crawlHost(pageURL)] {
+		return false
+	}
+	if len(c.Include) > 0 {
+		included := false
+		for _, re := range c.Include {
+			if re.MatchString(pageURL) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	for _, re := range c.Exclude {
+		if re.MatchString(pageURL) {
+			return false
+		}
+	}
+	return true
+}
+
+// fail passes a failure to OnError. Cancellation always stops the crawl.
+func (c *WebCrawler) fail(ctx context.Context, pageURL string, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if c.OnError == nil {
+		return nil
+	}
+	return c.OnError(pageURL, err)
+}
+
+// sitemapFile is a sitemap or a sitemap index.
+type sitemapFile struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// readSitemap returns the page URLs of a sitemap, following sitemap
+// indexes.
+func (c *WebCrawler) readSitemap(ctx context.Context, reader *WebReader, sitemapURL string, nesting int) ([]string, error) {
+	data, err := fetchSitemap(ctx, reader, sitemapURL)
+	if err != nil {
+		return nil, NewReaderError(sitemapURL, "failed to fetch sitemap", err)
+	}
+
+	var sitemap sitemapFile
+	if err := xml.Unmarshal(data, &sitemap); err != nil {
+		return nil, NewReaderError(sitemapURL, "failed to parse sitemap", err)
+	}
+
+	var pages []string
+	for _, u := range sitemap.URLs {
+		if loc := strings.TrimSpace(u.Loc); loc != "" {
+			pages = append(pages, loc)
+		}
+	}
+	if nesting >= maxSitemapNesting {
+		return pages, nil
+	}
+	for _, s := range sitemap.Sitemaps {
+		loc := strings.TrimSpace(s.Loc)
+		if loc == "" {
+			continue
+		}
+		nested, err := c.readSitemap(ctx, reader, loc, nesting+1)
+		if err != nil {
+			if err := c.fail(ctx, loc, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		pages = append(pages, nested...)
+	}
+	return pages, nil
+}
+
+// fetchSitemap downloads a sitemap, decompressing gzipped files.
+func fetchSitemap(ctx context.Context, reader *WebReader, sitemapURL string) ([]byte, error) {
+	if reader.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, reader.Timeout)
+		defer cancel()
+	}
+
+	resp, err := reader.get(ctx, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSitemapSize))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return io.ReadAll(io.LimitReader(gz, maxSitemapSize))
+	}
+	return data, nil
+}
+
+// normalizeCrawlURL returns the form of a URL used for deduplication:
+// lowercase scheme and host, no default port or fragment, and "/" for an
+// empty path. It returns "" for URLs that cannot be crawled.
+func normalizeCrawlURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return ""
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
+
+// crawlHost returns the normalized host of a URL.
+func crawlHost(rawURL string) string {
+	u, err := url.Parse(normalizeCrawlURL(rawURL))
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// hostThrottle spaces requests to each host by a delay.
+type hostThrottle struct {
+	delay time.Duration
+	mu    sync.Mutex
+	next  map[string]time.Time
+}
+
+// wait blocks until a request to the URL's host may be sent.
+func (t *hostThrottle) wait(ctx context.Context, rawURL string) error {
+	if t.delay <= 0 {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	at := t.next[u.Host]
+	if at.Before(now) {
+		at = now
+	}
+	t.next[u.Host] = at.Add(t.delay)
+	t.mu.Unlock()
+
+	wait := time.Until(at)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Metadata returns reader metadata.
+func (c *WebCrawler) Metadata() ReaderMetadata {
+	return ReaderMetadata{
+		Name:        "WebCrawler",
+		Description: "Crawls a website from sitemaps or by following links, with URL filters, politeness delays and deduplication",
+	}
+}
+
+// Ensure WebCrawler implements the interfaces.
+var (
+	_ ReaderWithContext  = (*WebCrawler)(nil)
+	_ ReaderWithMetadata = (*WebCrawler)(nil)
+)
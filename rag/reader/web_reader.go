@@ -0,0 +1,497 @@
+package reader
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// Metadata keys set by WebReader.
+const (
+	// URLMetadataKey holds the fetched URL, after redirects.
+	URLMetadataKey = "url"
+	// TitleMetadataKey holds the page title.
+	TitleMetadataKey = "title"
+	// FetchedAtMetadataKey holds the fetch time (RFC3339).
+	FetchedAtMetadataKey = "fetched_at"
+	// CanonicalURLMetadataKey holds the canonical URL declared by the page.
+	CanonicalURLMetadataKey = "canonical_url"
+)
+
+// ErrDisallowedByRobots is returned by LoadURL for URLs that robots.txt
+// disallows. LoadData skips such URLs.
+var ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+
+// WebOutputFormat is the text format produced by WebReader.
+type WebOutputFormat string
+
+const (
+	// WebOutputText produces plain text.
+	WebOutputText WebOutputFormat = "text"
+	// WebOutputMarkdown produces Markdown, keeping headings, lists, links,
+	// emphasis, code and tables.
+	WebOutputMarkdown WebOutputFormat = "markdown"
+)
+
+// DefaultWebUserAgent is the User-Agent sent by WebReader, also used to
+// select robots.txt rules.
+const DefaultWebUserAgent = "go-llamaindex"
+
+// WebReader fetches web pages and converts them to documents. Navigation,
+// headers, footers and similar boilerplate are stripped, and each document
+// records its URL, title and fetch time for citation.
+type WebReader struct {
+	// URLs are the pages to fetch.
+	URLs []string
+	// Client is the HTTP client. Defaults to http.DefaultClient.
+	Client *http.Client
+	// Timeout bounds each request, including reading the body.
+	Timeout time.Duration
+	// MaxConcurrency is the maximum number of pages fetched at once.
+	MaxConcurrency int
+	// MaxBodySize is the maximum page size in bytes; larger pages fail.
+	MaxBodySize int64
+	// UserAgent is sent with every request.
+	UserAgent string
+	// RespectRobotsTxt skips pages disallowed by the site's robots.txt.
+	RespectRobotsTxt bool
+	// RemoveBoilerplate strips page chrome and keeps <main> or <article>
+	// when present.
+	RemoveBoilerplate bool
+	// OutputFormat is the document text format.
+	OutputFormat WebOutputFormat
+	// ExtraMetadata is additional metadata to add to all documents.
+	ExtraMetadata map[string]interface{}
+
+	robotsMu sync.Mutex
+	robots   map[string]*robotsRules
+}
+
+// NewWebReader creates a new WebReader for the given URLs.
+func NewWebReader(urls ...string) *WebReader {
+	return &WebReader{
+		URLs:              urls,
+		Timeout:           30 * time.Second,
+		MaxConcurrency:    4,
+		MaxBodySize:       10 << 20,
+		UserAgent:         DefaultWebUserAgent,
+		RemoveBoilerplate: true,
+		OutputFormat:      WebOutputText,
+	}
+}
+
+// WithClient sets the HTTP client.
+func (r *WebReader) WithClient(client *http.Client) *WebReader {
+	r.Client = client
+	return r
+}
+
+// WithTimeout sets the per-request timeout.
+func (r *WebReader) WithTimeout(timeout time.Duration) *WebReader {
+	r.Timeout = timeout
+	return r
+}
+
+// WithMaxConcurrency sets the maximum number of concurrent fetches.
+func (r *WebReader) WithMaxConcurrency(n int) *WebReader {
+	r.MaxConcurrency = n
+	return r
+}
+
+// WithMaxBodySize sets the maximum page size in bytes.
+func (r *WebReader) WithMaxBodySize(n int64) *WebReader {
+	r.MaxBodySize = n
+	return r
+}
+
+// WithUserAgent sets the User-Agent.
+func (r *WebReader) WithUserAgent(userAgent string) *WebReader {
+	r.UserAgent = userAgent
+	return r
+}
+
+// WithRespectRobotsTxt enables robots.txt checks.
+func (r *WebReader) WithRespectRobotsTxt(respect bool) *WebReader {
+	r.RespectRobotsTxt = respect
+	return r
+}
+
+// WithRemoveBoilerplate enables or disables boilerplate removal.
+func (r *WebReader) WithRemoveBoilerplate(remove bool) *WebReader {
+	r.RemoveBoilerplate = remove
+	return r
+}
+
+// WithOutputFormat sets the document text format.
+func (r *WebReader) WithOutputFormat(format WebOutputFormat) *WebReader {
+	r.OutputFormat = format
+	return r
+}
+
+// WithExtraMetadata sets extra metadata.
+func (r *WebReader) WithExtraMetadata(metadata map[string]interface{}) *WebReader {
+	r.ExtraMetadata = metadata
+	return r
+}
+
+// LoadData fetches all URLs and returns one document per page.
+func (r *WebReader) LoadData() ([]schema.Node, error) {
+	return r.LoadDataWithContext(context.Background())
+}
+
+// LoadDataWithContext fetches all URLs with context support. Documents are
+// returned in URL order; pages disallowed by robots.txt are skipped, and
+// the first other failure stops loading.
+func (r *WebReader) LoadDataWithContext(ctx context.Context) ([]schema.Node, error) {
+	if len(r.URLs) == 0 {
+		return nil, fmt.Errorf("no URLs specified")
+	}
+
+	workers := r.MaxConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	results, err := loadConcurrently(ctx, r.URLs, workers, func(pageURL string) (*schema.Node, error) {
+		doc, err := r.LoadURL(ctx, pageURL)
+		if errors.Is(err, ErrDisallowedByRobots) {
+			return nil, nil
+		}
+		return doc, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]schema.Node, 0, len(results))
+	for _, doc := range results {
+		if doc != nil {
+			docs = append(docs, *doc)
+		}
+	}
+	return docs, nil
+}
+
+// LoadURL fetches a single page. The document ID is the page's canonical
+// URL when it declares one, and the URL after redirects otherwise.
+func (r *WebReader) LoadURL(ctx context.Context, pageURL string) (*schema.Node, error) {
+	doc, _, err := r.loadPage(ctx, pageURL)
+	return doc, err
+}
+
+// loadPage fetches a single page and also returns the targets of its links.
+func (r *WebReader) loadPage(ctx context.Context, pageURL string) (*schema.Node, []string, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, nil, NewReaderError(pageURL, "invalid URL", err)
+	}
+
+	if r.RespectRobotsTxt {
+		rules, err := r.robotsRules(ctx, u)
+		if err != nil {
+			return nil, nil, NewReaderError(pageURL, "failed to read robots.txt", err)
+		}
+		if !rules.allowed(u.RequestURI()) {
+			return nil, nil, NewReaderError(pageURL, "skipped", ErrDisallowedByRobots)
+		}
+	}
+
+	body, finalURL, contentType, err := r.fetch(ctx, u)
+	if err != nil {
+		return nil, nil, NewReaderError(pageURL, "failed to fetch page", err)
+	}
+	fetchedAt := time.Now().UTC()
+
+	metadata := make(map[string]interface{})
+	text := body
+	mimeType := "text/plain"
+	id := finalURL.String()
+	var links []string
+
+	if isHTMLContentType(contentType) {
+		converter := &htmlConverter{
+			markdown:          r.OutputFormat == WebOutputMarkdown,
+			removeBoilerplate: r.RemoveBoilerplate,
+			base:              finalURL,
+		}
+		page, err := converter.convert(body)
+		if err != nil {
+			return nil, nil, NewReaderError(pageURL, "failed to parse page", err)
+		}
+		text = page.Text
+		if r.OutputFormat == WebOutputMarkdown {
+			mimeType = "text/markdown"
+		}
+		if page.Title != "" {
+			metadata[TitleMetadataKey] = page.Title
+		}
+		if page.Description != "" {
+			metadata["description"] = page.Description
+		}
+		if page.Language != "" {
+			metadata["language"] = page.Language
+		}
+		if page.Canonical != "" {
+			id = page.Canonical
+			metadata[CanonicalURLMetadataKey] = page.Canonical
+		}
+		links = page.Links
+	}
+
+	metadata[URLMetadataKey] = finalURL.String()
+	metadata["source"] = finalURL.String()
+	metadata["content_type"] = contentType
+	metadata[FetchedAtMetadataKey] = fetchedAt.Format(time.RFC3339)
+	for k, v := range r.ExtraMetadata {
+		metadata[k] = v
+	}
+
+	return &schema.Node{
+		ID:       id,
+		Text:     text,
+		Type:     schema.ObjectTypeDocument,
+		Metadata: metadata,
+		MimeType: mimeType,
+	}, links, nil
+}
+
+// Metadata returns reader metadata.
+func (r *WebReader) Metadata() ReaderMetadata {
+	return ReaderMetadata{
+		Name:        "WebReader",
+		Description: "Fetches web pages and extracts their main content as text or Markdown",
+	}
+}
+
+// fetch GETs a page and returns its body, final URL and content type.
+func (r *WebReader) fetch(ctx context.Context, u *url.URL) (string, *url.URL, string, error) {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	resp, err := r.get(ctx, u.String())
+	if err != nil {
+		return "", nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if !isHTMLContentType(contentType) && !strings.HasPrefix(contentType, "text/") {
+		return "", nil, "", fmt.Errorf("unsupported content type %q", contentType)
+	}
+
+	var reader io.Reader = resp.Body
+	if r.MaxBodySize > 0 {
+		reader = io.LimitReader(resp.Body, r.MaxBodySize+1)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("failed to read body: %w", err)
+	}
+	if r.MaxBodySize > 0 && int64(len(body)) > r.MaxBodySize {
+		return "", nil, "", fmt.Errorf("page exceeds %d bytes", r.MaxBodySize)
+	}
+
+	return string(body), resp.Request.URL, contentType, nil
+}
+
+func (r *WebReader) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.UserAgent != "" {
+		req.Header.Set("User-Agent", r.UserAgent)
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.9,*/*;q=0.1")
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// robotsRules returns the robots.txt rules of a URL's host, fetching them
+// once per host. A missing robots.txt allows everything.
+func (r *WebReader) robotsRules(ctx context.Context, u *url.URL) (*robotsRules, error) {
+	key := u.Scheme + "://" + u.Host
+
+	r.robotsMu.Lock()
+	defer r.robotsMu.Unlock()
+
+	if rules, ok := r.robots[key]; ok {
+		return rules, nil
+	}
+
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	resp, err := r.get(ctx, key+"/robots.txt")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var rules *robotsRules
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		rules = parseRobotsTxt(io.LimitReader(resp.Body, 512<<10), r.UserAgent)
+	case resp.StatusCode >= 500:
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	default:
+		rules = &robotsRules{}
+	}
+
+	if r.robots == nil {
+		r.robots = make(map[string]*robotsRules)
+	}
+	r.robots[key] = rules
+	return rules, nil
+}
+
+// robotsRules are the Allow and Disallow rules of one robots.txt group.
+type robotsRules struct {
+	rules []robotsRule
+}
+
+type robotsRule struct {
+	allow bool
+	path  string
+}
+
+// allowed reports whether a path may be fetched. The longest matching rule
+// wins, and Allow wins ties.
+func (rr *robotsRules) allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+
+	allowed, best := true, -1
+	for _, rule := range rr.rules {
+		if !robotsPathMatch(rule.path, path) {
+			continue
+		}
+		if len(rule.path) > best || (len(rule.path) == best && rule.allow) {
+			allowed, best = rule.allow, len(rule.path)
+		}
+	}
+	return allowed
+}
+
+// parseRobotsTxt reads the rules of the group matching userAgent, falling
+// back to the "*" group.
+func parseRobotsTxt(body io.Reader, userAgent string) *robotsRules {
+	token := strings.ToLower(userAgent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+
+	var specific, wildcard *robotsRules
+	var current []*robotsRules
+	inRules := false
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// A user-agent line after rules starts a new group.
+			if inRules {
+				current, inRules = nil, false
+			}
+			agent := strings.ToLower(value)
+			switch {
+			case agent == "*":
+				if wildcard == nil {
+					wildcard = &robotsRules{}
+				}
+				current = append(current, wildcard)
+			case token != "" && strings.Contains(token, agent):
+				if specific == nil {
+					specific = &robotsRules{}
+				}
+				current = append(current, specific)
+			}
+		case "allow", "disallow":
+			inRules = true
+			// An empty Disallow allows everything.
+			if value == "" {
+				continue
+			}
+			for _, group := range current {
+				group.rules = append(group.rules, robotsRule{allow: key == "allow", path: value})
+			}
+		}
+	}
+
+	if specific != nil {
+		return specific
+	}
+	if wildcard != nil {
+		return wildcard
+	}
+	return &robotsRules{}
+}
+
+// robotsPathMatch matches a robots.txt path pattern, which may use "*" for
+// any characters and a trailing "$" to anchor the end.
+func robotsPathMatch(pattern, path string) bool {
+	if !strings.ContainsAny(pattern, "*$") {
+		return strings.HasPrefix(path, pattern)
+	}
+
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	re, err := regexp.Compile(expr)
+	return err == nil && re.MatchString(path)
+}
+
+func isHTMLContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.Contains(contentType, "html")
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// Ensure WebReader implements the interfaces.
+var (
+	_ ReaderWithContext  = (*WebReader)(nil)
+	_ ReaderWithMetadata = (*WebReader)(nil)
+)
@@ -0,0 +1,411 @@
+package reader
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testArticlePage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <title>Vector Search Guide</title>
+  <meta name="description" content="How vector search works.">
+  <style>body { color: red; }</style>
+</head>
+<body>
+  <header class="site-header"><a href="/">Home</a> <a href="/blog">Blog</a></header>
+  <nav><ul><li><a href="/docs">Docs</a></li></ul></nav>
+  <div class="cookie-banner">We use cookies.</div>
+  <main>
+    <article>
+      <header><h1>Vector   Search</h1></header>
+      <p>Embeddings map <strong>text</strong> to vectors. See <a href="/docs/embeddings">the docs</a>.</p>
+      <h2>Steps</h2>
+      <ol><li>Embed the query</li><li>Find <em>nearest</em> neighbours
+        <ul><li>cosine similarity</li></ul></li></ol>
+      <pre>k := 5
+top := index.Query(q, k)</pre>
+      <table><tr><th>Store</th><th>Type</th></tr><tr><td>Chroma</td><td>local</td></tr></table>
+      <script>track();</script>
+    </article>
+    <aside>Related posts</aside>
+  </main>
+  <footer>Copyright 2025</footer>
+</body>
+</html>`
+
+func newTestWebServer(t *testing.T) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var robotsHits int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&robotsHits, 1)
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private\nAllow: /private/public\n\nUser-agent: otherbot\nDisallow: /\n")
+	})
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, testArticlePage)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/article", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/private/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<p>private page</p>")
+	})
+	mux.HandleFunc("/notes.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "plain notes")
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte{0x89, 'P', 'N', 'G'})
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, &robotsHits
+}
+
+func TestWebReader_LoadData(t *testing.T) {
+	srv, _ := newTestWebServer(t)
+
+	reader := NewWebReader(srv.URL+"/moved", srv.URL+"/notes.txt").
+		WithExtraMetadata(map[string]interface{}{"crawl": "test"})
+	docs, err := reader.LoadData()
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+
+	doc := docs[0]
+	assert.Equal(t, srv.URL+"/article", doc.ID)
+	assert.Equal(t, srv.URL+"/article", doc.Metadata[URLMetadataKey])
+	assert.Equal(t, "Vector Search Guide", doc.Metadata[TitleMetadataKey])
+	assert.Equal(t, "How vector search works.", doc.Metadata["description"])
+	assert.Equal(t, "en", doc.Metadata["language"])
+	assert.Equal(t, "test", doc.Metadata["crawl"])
+	_, err = time.Parse(time.RFC3339, doc.Metadata[FetchedAtMetadataKey].(string))
+	assert.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(doc.Text, "Vector Search\n\nEmbeddings map text to vectors. See the docs."), doc.Text)
+	assert.Contains(t, doc.Text, "1. Embed the query\n2. Find nearest neighbours\n   - cosine similarity")
+	assert.Contains(t, doc.Text, "k := 5\ntop := index.Query(q, k)")
+	assert.Contains(t, doc.Text, "Store | Type\nChroma | local")
+	for _, boilerplate := range []string{"Home", "Docs", "cookies", "Related posts", "Copyright", "track()", "color: red"} {
+		assert.NotContains(t, doc.Text, boilerplate)
+	}
+
+	assert.Equal(t, "plain notes", docs[1].Text)
+	assert.Equal(t, "text/plain", docs[1].MimeType)
+}
+
+func TestWebReader_Markdown(t *testing.T) {
+	srv, _ := newTestWebServer(t)
+
+	docs, err := NewWebReader(srv.URL + "/article").WithOutputFormat(WebOutputMarkdown).LoadData()
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+
+	text := docs[0].Text
+	assert.Equal(t, "text/markdown", docs[0].MimeType)
+	assert.True(t, strings.HasPrefix(text, "# Vector Search\n\n"), text)
+	assert.Contains(t, text, "Embeddings map **text** to vectors. See [the docs]("+srv.URL+"/docs/embeddings).")
+	assert.Contains(t, text, "## Steps")
+	assert.Contains(t, text, "2. Find *nearest* neighbours\n   - cosine similarity")
+	assert.Contains(t, text, "```\nk := 5\ntop := index.Query(q, k)\n```")
+	assert.Contains(t, text, "| Store | Type |\n| --- | --- |\n| Chroma | local |")
+}
+
+func TestWebReader_KeepBoilerplate(t *testing.T) {
+	srv, _ := newTestWebServer(t)
+
+	docs, err := NewWebReader(srv.URL + "/article").WithRemoveBoilerplate(false).LoadData()
+	require.NoError(t, err)
+	assert.Contains(t, docs[0].Text, "Copyright 2025")
+	assert.Contains(t, docs[0].Text, "Related posts")
+	assert.NotContains(t, docs[0].Text, "track()")
+}
+
+func TestWebReader_RobotsTxt(t *testing.T) {
+	srv, robotsHits := newTestWebServer(t)
+
+	urls := []string{srv.URL + "/article", srv.URL + "/private/page", srv.URL + "/private/public/page"}
+	docs, err := NewWebReader(urls...).WithRespectRobotsTxt(true).LoadData()
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	assert.Equal(t, srv.URL+"/article", docs[0].ID)
+	assert.Equal(t, srv.URL+"/private/public/page", docs[1].ID)
+	assert.Equal(t, int32(1), atomic.LoadInt32(robotsHits), "robots.txt is fetched once per host")
+
+	_, err = NewWebReader().WithRespectRobotsTxt(true).LoadURL(context.Background(), srv.URL+"/private/page")
+	assert.ErrorIs(t, err, ErrDisallowedByRobots)
+
+	// The group of the reader's own user agent takes precedence.
+	docs, err = NewWebReader(srv.URL + "/article").WithRespectRobotsTxt(true).WithUserAgent("OtherBot/1.0").LoadData()
+	require.NoError(t, err)
+	assert.Empty(t, docs)
+
+	// Without the option, robots.txt is ignored.
+	docs, err = NewWebReader(srv.URL + "/private/page").LoadData()
+	require.NoError(t, err)
+	assert.Len(t, docs, 1)
+}
+
+func TestWebReader_Errors(t *testing.T) {
+	srv, _ := newTestWebServer(t)
+
+	_, err := NewWebReader().LoadData()
+	assert.Error(t, err)
+
+	_, err = NewWebReader("ftp://example.com/file").LoadData()
+	assert.ErrorContains(t, err, "invalid URL")
+
+	_, err = NewWebReader(srv.URL + "/missing").LoadData()
+	assert.ErrorContains(t, err, "404")
+
+	_, err = NewWebReader(srv.URL + "/image.png").LoadData()
+	assert.ErrorContains(t, err, "unsupported content type")
+
+	_, err = NewWebReader(srv.URL + "/article").WithMaxBodySize(100).LoadData()
+	assert.ErrorContains(t, err, "exceeds 100 bytes")
+
+	_, err = NewWebReader(srv.URL + "/slow").WithTimeout(50 * time.Millisecond).LoadData()
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error: %v", err)
+	var readerErr *ReaderError
+	assert.ErrorAs(t, err, &readerErr)
+	assert.Equal(t, srv.URL+"/slow", readerErr.Source)
+}
+
+func TestWebReader_Concurrency(t *testing.T) {
+	var inFlight, maxInFlight int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<p>page %s</p>", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	var urls []string
+	for i := 0; i < 8; i++ {
+		urls = append(urls, fmt.Sprintf("%s/%d", srv.URL, i))
+	}
+
+	docs, err := NewWebReader(urls...).WithMaxConcurrency(2).LoadData()
+	require.NoError(t, err)
+	require.Len(t, docs, 8)
+	for i, doc := range docs {
+		assert.Equal(t, fmt.Sprintf("page /%d", i), doc.Text)
+	}
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(2))
+}
+
+func TestRobotsPathMatch(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/private", "/private/page", true},
+		{"/private", "/public", false},
+		{"/*.pdf$", "/docs/file.pdf", true},
+		{"/*.pdf$", "/docs/file.pdf?x=1", false},
+		{"/search*q=", "/search?q=go", true},
+		{"/fish$", "/fish", true},
+		{"/fish$", "/fishing", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, robotsPathMatch(tt.pattern, tt.path), "%s vs %s", tt.pattern, tt.path)
+	}
+}
+
+func newTestSite(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	page := func(title, body string) string {
+		return "<html><head><title>" + title + "</title></head><body><main>" + body + "</main></body></html>"
+	}
+	pages := map[string]string{
+		"/":             page("Home", `<p>Welcome</p><a href="/docs">Docs</a> <a href="/blog#latest">Blog</a> <a href="https://elsewhere.example/">Out</a> <a href="mailto:hi@example.com">Mail</a>`),
+		"/docs":         page("Docs", `<p>Docs index</p><a href="/docs/install">Install</a> <a href="/docs/install?ref=nav">Install again</a> <a href="/">Home</a>`),
+		"/docs/install": page("Install", `<p>Run go get</p>`),
+		"/blog":         page("Blog", `<p>Posts</p><a href="/blog/post-1">Post 1</a>`),
+		"/blog/post-1":  page("Post 1", `<p>First post</p><a href="/blog/post-2">Post 2</a>`),
+		"/blog/post-2":  page("Post 2", `<p>Second post</p>`),
+		"/private/page": page("Private", `<p>Secret</p>`),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+	})
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>http://`+r.Host+`/sitemap-docs.xml.gz</loc></sitemap>
+  <sitemap><loc>http://`+r.Host+`/sitemap-missing.xml</loc></sitemap>
+</sitemapindex>`)
+	})
+	mux.HandleFunc("/sitemap-docs.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		gz := gzip.NewWriter(w)
+		fmt.Fprint(gz, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>http://`+r.Host+`/docs/install</loc></url>
+  <url><loc>http://`+r.Host+`/private/page</loc></url>
+  <url><loc>http://`+r.Host+`/blog/post-2</loc></url>
+</urlset>`)
+		gz.Close()
+	})
+	// The query-string variant declares the clean page as canonical.
+	mux.HandleFunc("/docs/install", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, strings.Replace(pages["/docs/install"], "<head>", `<head><link rel="canonical" href="/docs/install">`, 1))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, body)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func crawledIDs(docs []schema.Node) []string {
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	return ids
+}
+
+func TestWebCrawler_Links(t *testing.T) {
+	srv := newTestSite(t)
+
+	docs, err := NewWebCrawler(srv.URL + "/").WithDelay(0).LoadData()
+	require.NoError(t, err)
+
+	// Breadth-first to depth 2, within the start host, with the install
+	// page deduplicated by its canonical URL.
+	assert.Equal(t, []string{
+		srv.URL + "/",
+		srv.URL + "/docs", srv.URL + "/blog",
+		srv.URL + "/docs/install", srv.URL + "/blog/post-1",
+	}, crawledIDs(docs))
+	assert.Equal(t, 0, docs[0].Metadata[CrawlDepthMetadataKey])
+	assert.Equal(t, 2, docs[4].Metadata[CrawlDepthMetadataKey])
+	assert.Equal(t, "Post 1", docs[4].Metadata[TitleMetadataKey])
+
+	docs, err = NewWebCrawler(srv.URL + "/").WithDelay(0).WithMaxDepth(0).LoadData()
+	require.NoError(t, err)
+	assert.Len(t, docs, 1)
+
+	docs, err = NewWebCrawler(srv.URL + "/").WithDelay(0).WithMaxDepth(5).WithMaxPages(3).LoadData()
+	require.NoError(t, err)
+	assert.Len(t, docs, 3)
+}
+
+func TestWebCrawler_Filters(t *testing.T) {
+	srv := newTestSite(t)
+
+	docs, err := NewWebCrawler(srv.URL + "/").
+		WithDelay(0).
+		WithMaxDepth(5).
+		WithExclude(regexp.MustCompile(`/docs`)).
+		WithInclude(regexp.MustCompile(`/blog`)).
+		LoadData()
+	require.NoError(t, err)
+	assert.Equal(t, []string{srv.URL + "/", srv.URL + "/blog", srv.URL + "/blog/post-1", srv.URL + "/blog/post-2"}, crawledIDs(docs))
+}
+
+func TestWebCrawler_Sitemap(t *testing.T) {
+	srv := newTestSite(t)
+
+	var failed []string
+	var mu sync.Mutex
+	crawler := NewWebCrawler().
+		WithSitemaps(srv.URL + "/sitemap.xml").
+		WithMaxDepth(0).
+		WithDelay(0).
+		WithOnError(func(pageURL string, err error) error {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, pageURL)
+			return nil
+		})
+	docs, err := crawler.LoadData()
+	require.NoError(t, err)
+
+	// robots.txt disallows /private; the missing sitemap is reported.
+	assert.Equal(t, []string{srv.URL + "/docs/install", srv.URL + "/blog/post-2"}, crawledIDs(docs))
+	assert.Equal(t, []string{srv.URL + "/sitemap-missing.xml"}, failed)
+
+	_, err = crawler.WithOnError(func(pageURL string, err error) error { return err }).LoadData()
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestWebCrawler_Politeness(t *testing.T) {
+	var mu sync.Mutex
+	var times []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<p>page</p><a href="/a">a</a><a href="/b">b</a>`)
+	}))
+	defer srv.Close()
+
+	docs, err := NewWebCrawler(srv.URL).WithDelay(30 * time.Millisecond).WithMaxDepth(1).LoadData()
+	require.NoError(t, err)
+	require.Len(t, docs, 3)
+	require.Len(t, times, 3)
+	for i := 1; i < len(times); i++ {
+		assert.GreaterOrEqual(t, times[i].Sub(times[i-1]), 25*time.Millisecond)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewWebCrawler(srv.URL).LoadDataWithContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestNormalizeCrawlURL(t *testing.T) {
+	assert.Equal(t, "https://example.com/", normalizeCrawlURL("HTTPS://Example.COM:443#top"))
+	assert.Equal(t, "http://example.com:8080/a?b=1", normalizeCrawlURL("http://example.com:8080/a?b=1#frag"))
+	assert.Equal(t, "", normalizeCrawlURL("mailto:hi@example.com"))
+}
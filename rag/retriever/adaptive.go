@@ -0,0 +1,280 @@
+package retriever
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// RetrievalParams are the retrieval settings chosen for one query.
+type RetrievalParams struct {
+	// TopK is the number of results to retrieve.
+	TopK int
+	// SimilarityCutoff drops results scoring below it. Zero keeps all
+	// results.
+	SimilarityCutoff float64
+	// Complexity is the estimated complexity of the query the settings were
+	// chosen for, from 0 for a simple lookup to 1 for a broad analytical
+	// question.
+	Complexity float64
+}
+
+// TopKPolicy chooses the top-k and similarity cutoff of each query.
+type TopKPolicy interface {
+	// Params returns the retrieval settings for query.
+	Params(ctx context.Context, query schema.QueryBundle) (RetrievalParams, error)
+}
+
+// ComplexityEstimator estimates how broad a query is, from 0 for a simple
+// lookup ("what port does the API use") to 1 for a broad analytical
+// question ("compare the incident trends of the last three releases").
+type ComplexityEstimator interface {
+	EstimateComplexity(ctx context.Context, query string) (float64, error)
+}
+
+// defaultAnalyticalCues are words that mark questions asking for a broad
+// answer rather than a single fact.
+var defaultAnalyticalCues = []string{
+	"analyze", "analyse", "analysis", "across", "compare", "comparison", "contrast",
+	"difference", "differences", "evolution", "evolve", "explain", "impact",
+	"implications", "overview", "pros", "cons", "relationship", "summarize",
+	"summarise", "summary", "tradeoffs", "trends", "trend", "versus", "vs", "why",
+}
+
+// HeuristicComplexityEstimator estimates complexity without a model, from
+// the query's length, the entropy of its terms (long queries repeating a
+// few terms stay simple) and words that mark analytical questions, such as
+// "compare" or "why".
+type HeuristicComplexityEstimator struct {
+	longQueryTerms int
+	cues           map[string]bool
+}
+
+// HeuristicOption configures a HeuristicComplexityEstimator.
+type HeuristicOption func(*HeuristicComplexityEstimator)
+
+// WithLongQueryTerms sets the number of terms at which a query counts as
+// fully complex by length. Defaults to 20.
+func WithLongQueryTerms(n int) HeuristicOption {
+	return func(e *HeuristicComplexityEstimator) {
+		if n > 1 {
+			e.longQueryTerms = n
+		}
+	}
+}
+
+// WithAnalyticalCues replaces the words that mark analytical questions.
+func WithAnalyticalCues(words ...string) HeuristicOption {
+	return func(e *HeuristicComplexityEstimator) {
+		e.cues = make(map[string]bool, len(words))
+		for _, w := range words {
+			e.cues[strings.ToLower(w)] = true
+		}
+	}
+}
+
+// NewHeuristicComplexityEstimator creates a heuristic estimator.
+func NewHeuristicComplexityEstimator(opts ...HeuristicOption) *HeuristicComplexityEstimator {
+	e := &HeuristicComplexityEstimator{longQueryTerms: 20}
+	WithAnalyticalCues(defaultAnalyticalCues...)(e)
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// EstimateComplexity weighs length 0.4, term entropy 0.3 and analytical
+// cues 0.3.
+func (e *HeuristicComplexityEstimator) EstimateComplexity(ctx context.Context, query string) (float64, error) {
+	terms := UnicodeTokenize(query)
+	if len(terms) == 0 {
+		return 0, nil
+	}
+	length := math.Min(1, float64(len(terms))/float64(e.longQueryTerms))
+
+	counts := make(map[string]int, len(terms))
+	cue := 0.0
+	for _, t := range terms {
+		counts[t]++
+		if e.cues[t] {
+			cue = 1
+		}
+	}
+	entropy := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(len(terms))
+		entropy -= p * math.Log2(p)
+	}
+	entropy = math.Min(1, entropy/math.Log2(float64(e.longQueryTerms)))
+
+	return clamp01(0.4*length + 0.3*entropy + 0.3*cue), nil
+}
+
+// DefaultComplexityPrompt asks an LLM to rate a query's complexity.
+const DefaultComplexityPrompt = `Rate how much information is needed to answer the question below, on a scale from 1 to 5:
+1 = a single fact that one passage answers (e.g. a name, number or definition)
+3 = a few related facts from several passages
+5 = a broad analysis, comparison or summary that draws on many passages
+
+Question: {query_str}
+
+Answer with the number only.
+Rating: `
+
+// LLMComplexityEstimator asks an LLM to rate query complexity from 1 to 5.
+// When the call fails or the answer has no rating, it falls back to a
+// heuristic estimate, so retrieval still adapts.
+type LLMComplexityEstimator struct {
+	llm      llm.LLM
+	prompt   *prompts.PromptTemplate
+	fallback ComplexityEstimator
+}
+
+// LLMComplexityOption configures an LLMComplexityEstimator.
+type LLMComplexityOption func(*LLMComplexityEstimator)
+
+// WithComplexityPrompt sets the prompt, which must contain {query_str} and
+// ask for a rating from 1 to 5.
+func WithComplexityPrompt(prompt string) LLMComplexityOption {
+	return func(e *LLMComplexityEstimator) {
+		e.prompt = prompts.NewPromptTemplate(prompt, prompts.PromptTypeCustom)
+	}
+}
+
+// WithComplexityFallback sets the estimator used when the LLM fails. A nil
+// fallback makes failures errors. Defaults to a
+// HeuristicComplexityEstimator.
+func WithComplexityFallback(fallback ComplexityEstimator) LLMComplexityOption {
+	return func(e *LLMComplexityEstimator) {
+		e.fallback = fallback
+	}
+}
+
+// NewLLMComplexityEstimator creates an estimator using l.
+func NewLLMComplexityEstimator(l llm.LLM, opts ...LLMComplexityOption) *LLMComplexityEstimator {
+	e := &LLMComplexityEstimator{
+		llm:      l,
+		prompt:   prompts.NewPromptTemplate(DefaultComplexityPrompt, prompts.PromptTypeCustom),
+		fallback: NewHeuristicComplexityEstimator(),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// EstimateComplexity maps the LLM's rating of 1 to 5 to 0 to 1.
+func (e *LLMComplexityEstimator) EstimateComplexity(ctx context.Context, query string) (float64, error) {
+	answer, err := e.llm.Complete(ctx, e.prompt.Format(map[string]string{"query_str": query}))
+	if err == nil {
+		rating, ok := parseRating(answer)
+		if ok {
+			return (rating - 1) / 4, nil
+		}
+		err = fmt.Errorf("no complexity rating in LLM answer %q", answer)
+	}
+	if e.fallback == nil {
+		return 0, err
+	}
+	return e.fallback.EstimateComplexity(ctx, query)
+}
+
+// parseRating returns the first number from 1 to 5 in an answer.
+func parseRating(answer string) (float64, bool) {
+	fields := strings.FieldsFunc(answer, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+	for _, f := range fields {
+		v, err := strconv.ParseFloat(strings.Trim(f, "."), 64)
+		if err == nil && v >= 1 && v <= 5 {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// AdaptiveTopK is a TopKPolicy that scales top-k and the similarity cutoff
+// with the estimated complexity of the query: simple lookups retrieve few
+// chunks with a strict cutoff, broad questions many chunks with a loose
+// one. Both are interpolated linearly between configurable bounds.
+type AdaptiveTopK struct {
+	estimator    ComplexityEstimator
+	minTopK      int
+	maxTopK      int
+	simpleCutoff float64
+	broadCutoff  float64
+}
+
+// AdaptiveTopKOption configures an AdaptiveTopK.
+type AdaptiveTopKOption func(*AdaptiveTopK)
+
+// WithComplexityEstimator sets the complexity estimator. Defaults to a
+// HeuristicComplexityEstimator.
+func WithComplexityEstimator(estimator ComplexityEstimator) AdaptiveTopKOption {
+	return func(p *AdaptiveTopK) {
+		p.estimator = estimator
+	}
+}
+
+// WithTopKBounds sets the top-k of the simplest and the broadest queries.
+// Defaults to 2 and 20.
+func WithTopKBounds(minTopK, maxTopK int) AdaptiveTopKOption {
+	return func(p *AdaptiveTopK) {
+		if minTopK > 0 && maxTopK >= minTopK {
+			p.minTopK, p.maxTopK = minTopK, maxTopK
+		}
+	}
+}
+
+// WithCutoffBounds sets the similarity cutoff of the simplest and the
+// broadest queries, e.g. 0.8 and 0.5. Defaults to no cutoff.
+func WithCutoffBounds(simpleCutoff, broadCutoff float64) AdaptiveTopKOption {
+	return func(p *AdaptiveTopK) {
+		p.simpleCutoff, p.broadCutoff = simpleCutoff, broadCutoff
+	}
+}
+
+// NewAdaptiveTopK creates an adaptive top-k policy.
+func NewAdaptiveTopK(opts ...AdaptiveTopKOption) *AdaptiveTopK {
+	p := &AdaptiveTopK{
+		estimator: NewHeuristicComplexityEstimator(),
+		minTopK:   2,
+		maxTopK:   20,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Params estimates the query's complexity and interpolates the bounds.
+func (p *AdaptiveTopK) Params(ctx context.Context, query schema.QueryBundle) (RetrievalParams, error) {
+	c, err := p.estimator.EstimateComplexity(ctx, query.QueryString)
+	if err != nil {
+		return RetrievalParams{}, fmt.Errorf("failed to estimate query complexity: %w", err)
+	}
+	c = clamp01(c)
+	return RetrievalParams{
+		TopK:             p.minTopK + int(math.Round(c*float64(p.maxTopK-p.minTopK))),
+		SimilarityCutoff: p.simpleCutoff + c*(p.broadCutoff-p.simpleCutoff),
+		Complexity:       c,
+	}, nil
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// Ensure the estimators and the policy implement their interfaces.
+var (
+	_ ComplexityEstimator = (*HeuristicComplexityEstimator)(nil)
+	_ ComplexityEstimator = (*LLMComplexityEstimator)(nil)
+	_ TopKPolicy          = (*AdaptiveTopK)(nil)
+)
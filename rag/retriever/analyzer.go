@@ -0,0 +1,299 @@
+package retriever
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/aqua777/go-llamaindex/embedding"
+)
+
+// Analyzer turns text into the terms used by lexical retrievers:
+// tokenization, stopword removal, stemming and synonym expansion. The same
+// analyzer must be used for indexing and querying.
+//
+// Synonyms are expanded symmetrically. Each synonym group has a canonical
+// term, and wherever a member of the group occurs, in a document or a
+// query, the canonical term is emitted next to it. So with the group
+// "kubernetes": {"k8s", "kube"}, a query for "k8s" matches documents that
+// only say "kubernetes", and the other way round, without falling back to
+// embeddings.
+type Analyzer struct {
+	tokenizer func(string) []string
+	stopwords map[string]bool
+	stemmer   func(string) string
+	// synonyms maps the first term of each synonym phrase to the phrases
+	// starting with it, longest first.
+	synonyms map[string][]synonymPhrase
+}
+
+// synonymPhrase is an analyzed synonym and the canonical terms it expands to.
+type synonymPhrase struct {
+	terms     []string
+	canonical []string
+}
+
+// AnalyzerOption configures an Analyzer.
+type AnalyzerOption func(*Analyzer)
+
+// WithAnalyzerTokenizer sets the function that splits text into lowercase
+// tokens. Defaults to UnicodeTokenize.
+func WithAnalyzerTokenizer(tokenizer func(string) []string) AnalyzerOption {
+	return func(a *Analyzer) {
+		a.tokenizer = tokenizer
+	}
+}
+
+// WithAnalyzerStopwords sets the stopwords to drop. Pass no words to keep
+// every token. Defaults to the English stopwords.
+func WithAnalyzerStopwords(stopwords ...string) AnalyzerOption {
+	return func(a *Analyzer) {
+		a.stopwords = make(map[string]bool, len(stopwords))
+		for _, w := range stopwords {
+			a.stopwords[strings.ToLower(w)] = true
+		}
+	}
+}
+
+// WithAnalyzerLanguages sets the stopwords to those of the given languages,
+// see StopwordsForLanguage. Unknown languages are ignored.
+func WithAnalyzerLanguages(languages ...string) AnalyzerOption {
+	return func(a *Analyzer) {
+		var stopwords []string
+		for _, lang := range languages {
+			stopwords = append(stopwords, StopwordsForLanguage(lang)...)
+		}
+		WithAnalyzerStopwords(stopwords...)(a)
+	}
+}
+
+// WithAnalyzerStemmer sets a function applied to every token after
+// stopword removal, e.g. EnglishPluralStemmer or a Porter stemmer.
+func WithAnalyzerStemmer(stemmer func(string) string) AnalyzerOption {
+	return func(a *Analyzer) {
+		a.stemmer = stemmer
+	}
+}
+
+// WithAnalyzerSynonyms adds synonym groups. Each key is the canonical term
+// of its group and the values are its synonyms, e.g.
+// {"kubernetes": {"k8s", "kube"}}. Keys and synonyms may be phrases, such as
+// {"ml": {"machine learning"}}. Options are applied in order, so set the
+// tokenizer, stopwords and stemmer before the synonyms.
+func WithAnalyzerSynonyms(synonyms map[string][]string) AnalyzerOption {
+	return func(a *Analyzer) {
+		canonicals := make([]string, 0, len(synonyms))
+		for canonical := range synonyms {
+			canonicals = append(canonicals, canonical)
+		}
+		sort.Strings(canonicals)
+
+		for _, canonical := range canonicals {
+			canonicalTerms := a.baseTerms(canonical)
+			if len(canonicalTerms) == 0 {
+				continue
+			}
+			for _, synonym := range append([]string{canonical}, synonyms[canonical]...) {
+				a.addSynonym(a.baseTerms(synonym), canonicalTerms)
+			}
+		}
+	}
+}
+
+// NewAnalyzer creates a new Analyzer. Without options it tokenizes with
+// UnicodeTokenize and drops English stopwords.
+func NewAnalyzer(opts ...AnalyzerOption) *Analyzer {
+	a := &Analyzer{
+		tokenizer: UnicodeTokenize,
+		synonyms:  make(map[string][]synonymPhrase),
+	}
+	WithAnalyzerStopwords(embedding.DefaultBM25Stopwords()...)(a)
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// Analyze returns the terms of text, with the canonical terms of any
+// synonyms it contains.
+func (a *Analyzer) Analyze(text string) []string {
+	terms := a.baseTerms(text)
+	if len(a.synonyms) == 0 {
+		return terms
+	}
+
+	out := make([]string, 0, len(terms))
+	for i := 0; i < len(terms); {
+		phrase, ok := a.matchSynonym(terms[i:])
+		if !ok {
+			out = append(out, terms[i])
+			i++
+			continue
+		}
+		out = append(out, phrase.terms...)
+		if !equalTerms(phrase.terms, phrase.canonical) {
+			out = append(out, phrase.canonical...)
+		}
+		i += len(phrase.terms)
+	}
+	return out
+}
+
+// baseTerms tokenizes text and applies stopwords and stemming.
+func (a *Analyzer) baseTerms(text string) []string {
+	raw := a.tokenizer(text)
+	terms := make([]string, 0, len(raw))
+	for _, t := range raw {
+		if t == "" || a.stopwords[t] {
+			continue
+		}
+		if a.stemmer != nil {
+			t = a.stemmer(t)
+		}
+		terms = append(terms, t)
+	}
+	return terms
+}
+
+func (a *Analyzer) addSynonym(terms, canonical []string) {
+	if len(terms) == 0 {
+		return
+	}
+	phrases := a.synonyms[terms[0]]
+	for _, p := range phrases {
+		if equalTerms(p.terms, terms) {
+			return
+		}
+	}
+	phrases = append(phrases, synonymPhrase{terms: terms, canonical: canonical})
+	sort.SliceStable(phrases, func(i, j int) bool {
+		return len(phrases[i].terms) > len(phrases[j].terms)
+	})
+	a.synonyms[terms[0]] = phrases
+}
+
+// matchSynonym returns the longest synonym phrase at the start of terms.
+func (a *Analyzer) matchSynonym(terms []string) (synonymPhrase, bool) {
+	for _, phrase := range a.synonyms[terms[0]] {
+		if len(phrase.terms) <= len(terms) && equalTerms(phrase.terms, terms[:len(phrase.terms)]) {
+			return phrase, true
+		}
+	}
+	return synonymPhrase{}, false
+}
+
+func equalTerms(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// UnicodeTokenize lowercases text and splits it into runs of letters and
+// digits, so accented and non-Latin words stay intact.
+func UnicodeTokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// EnglishPluralStemmer is a light stemmer that conflates English plurals
+// with their singular ("policies" -> "policy", "nodes" -> "node"). It is
+// less aggressive than a Porter stemmer, so it rarely merges unrelated
+// words.
+func EnglishPluralStemmer(term string) string {
+	switch {
+	case len(term) > 4 && strings.HasSuffix(term, "ies") && !strings.HasSuffix(term, "eies") && !strings.HasSuffix(term, "aies"):
+		return term[:len(term)-3] + "y"
+	case len(term) > 3 && strings.HasSuffix(term, "es") && !strings.HasSuffix(term, "aes") && !strings.HasSuffix(term, "ees") && !strings.HasSuffix(term, "oes"):
+		return term[:len(term)-1]
+	case len(term) > 3 && strings.HasSuffix(term, "s") && !strings.HasSuffix(term, "us") && !strings.HasSuffix(term, "ss"):
+		return term[:len(term)-1]
+	}
+	return term
+}
+
+// StopwordsForLanguage returns the stopwords of a language, by ISO 639-1
+// code or English name: en, de, fr, es, it, pt and nl. It returns nil for
+// other languages.
+func StopwordsForLanguage(language string) []string {
+	switch strings.ToLower(language) {
+	case "en", "english":
+		return embedding.DefaultBM25Stopwords()
+	case "de", "german":
+		return append([]string(nil), germanStopwords...)
+	case "fr", "french":
+		return append([]string(nil), frenchStopwords...)
+	case "es", "spanish":
+		return append([]string(nil), spanishStopwords...)
+	case "it", "italian":
+		return append([]string(nil), italianStopwords...)
+	case "pt", "portuguese":
+		return append([]string(nil), portugueseStopwords...)
+	case "nl", "dutch":
+		return append([]string(nil), dutchStopwords...)
+	}
+	return nil
+}
+
+var germanStopwords = []string{
+	"der", "die", "das", "den", "dem", "des", "ein", "eine", "einer",
+	"eines", "einem", "einen", "und", "oder", "aber", "in", "im", "an",
+	"am", "auf", "aus", "bei", "mit", "nach", "von", "vom", "zu", "zum",
+	"zur", "für", "über", "unter", "ist", "sind", "war", "waren", "sein",
+	"hat", "haben", "wird", "werden", "ich", "du", "er", "sie", "es",
+	"wir", "ihr", "nicht", "auch", "als", "wie", "wo", "was", "wer",
+	"dass", "so", "noch", "nur",
+}
+
+var frenchStopwords = []string{
+	"le", "la", "les", "l", "un", "une", "des", "du", "de", "d", "et",
+	"ou", "mais", "en", "dans", "sur", "sous", "par", "pour", "avec",
+	"sans", "au", "aux", "ce", "cet", "cette", "ces", "est", "sont",
+	"été", "être", "a", "ont", "avoir", "je", "tu", "il", "elle", "nous",
+	"vous", "ils", "elles", "ne", "pas", "plus", "que", "qui", "quoi",
+	"où", "comment", "se", "s", "qu", "c",
+}
+
+var spanishStopwords = []string{
+	"el", "la", "los", "las", "un", "una", "unos", "unas", "y", "o",
+	"pero", "en", "de", "del", "al", "a", "por", "para", "con", "sin",
+	"sobre", "es", "son", "era", "fue", "ser", "ha", "han", "haber",
+	"yo", "tú", "él", "ella", "nosotros", "vosotros", "ellos", "ellas",
+	"no", "que", "qué", "quién", "cómo", "dónde", "se", "lo", "le",
+	"su", "sus", "este", "esta", "estos", "estas", "como", "más",
+}
+
+var italianStopwords = []string{
+	"il", "lo", "la", "i", "gli", "le", "l", "un", "uno", "una", "e",
+	"o", "ma", "in", "di", "del", "della", "dei", "delle", "da", "a",
+	"al", "alla", "per", "con", "su", "è", "sono", "era", "essere",
+	"ha", "hanno", "avere", "io", "tu", "lui", "lei", "noi", "voi",
+	"loro", "non", "che", "chi", "come", "dove", "si", "questo",
+	"questa", "più",
+}
+
+var portugueseStopwords = []string{
+	"o", "a", "os", "as", "um", "uma", "uns", "umas", "e", "ou", "mas",
+	"em", "no", "na", "nos", "nas", "de", "do", "da", "dos", "das",
+	"por", "para", "com", "sem", "sobre", "é", "são", "era", "foi",
+	"ser", "tem", "têm", "ter", "eu", "tu", "ele", "ela", "nós", "vós",
+	"eles", "elas", "não", "que", "quem", "como", "onde", "se", "seu",
+	"sua", "este", "esta", "mais",
+}
+
+var dutchStopwords = []string{
+	"de", "het", "een", "en", "of", "maar", "in", "op", "aan", "bij",
+	"met", "van", "voor", "naar", "uit", "over", "onder", "is", "zijn",
+	"was", "waren", "heeft", "hebben", "wordt", "worden", "ik", "jij",
+	"je", "hij", "zij", "ze", "wij", "we", "jullie", "niet", "ook",
+	"als", "hoe", "waar", "wat", "wie", "dat", "die", "dit", "deze",
+	"er", "nog", "zo",
+}
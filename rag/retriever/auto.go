@@ -0,0 +1,268 @@
+package retriever
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/rag/store"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// DefaultAutoRetrieverPrompt is the default template for inferring a query
+// spec. It uses the variables {info}, {max_top_k} and {query}.
+const DefaultAutoRetrieverPrompt = `Your goal is to structure the user's query to match the request schema provided below.
+
+The data source is described as follows, with the metadata fields that can be filtered on:
+{info}
+
+Respond with a JSON object of the form:
+{"query": "<text to search for>", "filters": [{"key": "<metadata field>", "operator": "<operator>", "value": <value>}], "condition": "and" or "or", "top_k": <number of results or null>}
+
+Rules:
+- "query" should only contain text expected to match the contents of documents. Conditions captured by filters should not be repeated in the query.
+- Only use metadata fields listed above, with values of the listed type.
+- Operators are ==, !=, >, >=, <, <=, in, nin, contains and text_match. "in" and "nin" take a list value.
+- Use an empty list of filters if no filters apply.
+- Set "top_k" only if the user asks for a specific number of results, at most {max_top_k}.
+
+User query: {query}
+Structured request:`
+
+// AutoRetrieverSpec is the query spec inferred from a natural-language
+// query by VectorIndexAutoRetriever.
+type AutoRetrieverSpec struct {
+	// Query is the text to embed, with filter conditions removed.
+	Query string
+	// Filters are the inferred metadata filters, nil if none apply.
+	Filters *schema.MetadataFilters
+	// TopK is the inferred number of results, zero if not specified.
+	TopK int
+}
+
+// VectorIndexAutoRetriever uses the LLM to turn a natural-language query
+// into a query spec before retrieving: the semantic part of the query,
+// metadata filters on the fields described by a VectorStoreInfo, and an
+// optional top-k. For example, "2024 compliance docs" becomes the query
+// "compliance docs" filtered on year == 2024.
+type VectorIndexAutoRetriever struct {
+	*BaseRetriever
+	// VectorStore is the vector store to query.
+	VectorStore store.VectorStore
+	// EmbeddingModel is the model used to embed queries.
+	EmbeddingModel embedding.EmbeddingModel
+	// LLM infers the query spec.
+	LLM llm.LLM
+	// Info describes the store contents and filterable metadata.
+	Info schema.VectorStoreInfo
+	// TopK is the number of results when the LLM does not set one.
+	TopK int
+	// MaxTopK caps the top-k inferred by the LLM.
+	MaxTopK int
+	// ExtraFilters are always applied, in addition to the inferred ones.
+	ExtraFilters *schema.MetadataFilters
+	// Prompt is the template used to infer the query spec.
+	Prompt prompts.BasePromptTemplate
+}
+
+// AutoRetrieverOption is a functional option for VectorIndexAutoRetriever.
+type AutoRetrieverOption func(*VectorIndexAutoRetriever)
+
+// WithAutoRetrieverTopK sets the number of results when the LLM does not
+// set one. Defaults to 10.
+func WithAutoRetrieverTopK(topK int) AutoRetrieverOption {
+	return func(ar *VectorIndexAutoRetriever) {
+		ar.TopK = topK
+	}
+}
+
+// WithAutoRetrieverMaxTopK caps the top-k inferred by the LLM. Defaults
+// to 20.
+func WithAutoRetrieverMaxTopK(maxTopK int) AutoRetrieverOption {
+	return func(ar *VectorIndexAutoRetriever) {
+		ar.MaxTopK = maxTopK
+	}
+}
+
+// WithAutoRetrieverExtraFilters sets filters applied to every query in
+// addition to the inferred ones.
+func WithAutoRetrieverExtraFilters(filters *schema.MetadataFilters) AutoRetrieverOption {
+	return func(ar *VectorIndexAutoRetriever) {
+		ar.ExtraFilters = filters
+	}
+}
+
+// WithAutoRetrieverPrompt sets the query spec prompt template.
+func WithAutoRetrieverPrompt(prompt prompts.BasePromptTemplate) AutoRetrieverOption {
+	return func(ar *VectorIndexAutoRetriever) {
+		ar.Prompt = prompt
+	}
+}
+
+// WithAutoRetrieverVerbose enables verbose logging.
+func WithAutoRetrieverVerbose(verbose bool) AutoRetrieverOption {
+	return func(ar *VectorIndexAutoRetriever) {
+		ar.Verbose = verbose
+	}
+}
+
+// NewVectorIndexAutoRetriever creates a new VectorIndexAutoRetriever.
+func NewVectorIndexAutoRetriever(
+	vectorStore store.VectorStore,
+	embeddingModel embedding.EmbeddingModel,
+	llmModel llm.LLM,
+	info schema.VectorStoreInfo,
+	opts ...AutoRetrieverOption,
+) *VectorIndexAutoRetriever {
+	ar := &VectorIndexAutoRetriever{
+		BaseRetriever:  NewBaseRetriever(),
+		VectorStore:    vectorStore,
+		EmbeddingModel: embeddingModel,
+		LLM:            llmModel,
+		Info:           info,
+		TopK:           10,
+		MaxTopK:        20,
+		Prompt:         prompts.NewPromptTemplate(DefaultAutoRetrieverPrompt, prompts.PromptTypeCustom),
+	}
+
+	for _, opt := range opts {
+		opt(ar)
+	}
+
+	ar.SetPrompt("auto_retriever_prompt", ar.Prompt)
+
+	return ar
+}
+
+// GenerateSpec asks the LLM for the query spec of query. Filters on fields
+// not described in Info, or with unknown operators, are dropped.
+func (ar *VectorIndexAutoRetriever) GenerateSpec(ctx context.Context, query string) (*AutoRetrieverSpec, error) {
+	info, err := json.MarshalIndent(ar.Info, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode vector store info: %w", err)
+	}
+
+	prompt := ar.Prompt.Format(map[string]string{
+		"info":      string(info),
+		"max_top_k": fmt.Sprintf("%d", ar.MaxTopK),
+		"query":     query,
+	})
+	response, err := ar.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate query spec: %w", err)
+	}
+
+	return ar.parseSpec(query, response)
+}
+
+// parseSpec parses and validates the LLM's JSON query spec.
+func (ar *VectorIndexAutoRetriever) parseSpec(query, response string) (*AutoRetrieverSpec, error) {
+	start := strings.Index(response, "{")
+	end := strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON object in query spec response: %q", response)
+	}
+
+	var raw struct {
+		Query   string                  `json:"query"`
+		Filters []schema.MetadataFilter `json:"filters"`
+		// Condition combines the filters, "and" unless "or" is given.
+		Condition string `json:"condition"`
+		TopK      *int   `json:"top_k"`
+	}
+	if err := json.Unmarshal([]byte(response[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse query spec: %w", err)
+	}
+
+	spec := &AutoRetrieverSpec{Query: strings.TrimSpace(raw.Query)}
+	if spec.Query == "" {
+		spec.Query = query
+	}
+	if raw.TopK != nil && *raw.TopK > 0 {
+		spec.TopK = *raw.TopK
+		if ar.MaxTopK > 0 && spec.TopK > ar.MaxTopK {
+			spec.TopK = ar.MaxTopK
+		}
+	}
+
+	fields := make(map[string]bool, len(ar.Info.MetadataInfo))
+	for _, mi := range ar.Info.MetadataInfo {
+		fields[mi.Name] = true
+	}
+	var filters []schema.MetadataFilter
+	for _, f := range raw.Filters {
+		if !fields[f.Key] {
+			continue
+		}
+		op, ok := filterOperatorAliases[strings.ToLower(string(f.Operator))]
+		if !ok {
+			continue
+		}
+		f.Operator = op
+		filters = append(filters, f)
+	}
+	if len(filters) > 0 {
+		condition := schema.FilterConditionAnd
+		if strings.EqualFold(raw.Condition, string(schema.FilterConditionOr)) {
+			condition = schema.FilterConditionOr
+		}
+		spec.Filters = schema.NewMetadataFiltersWithCondition(condition, filters...)
+	}
+
+	return spec, nil
+}
+
+// filterOperatorAliases maps the operator spellings accepted in query specs
+// to filter operators.
+var filterOperatorAliases = map[string]schema.FilterOperator{
+	"": schema.FilterOperatorEq, "==": schema.FilterOperatorEq, "=": schema.FilterOperatorEq, "eq": schema.FilterOperatorEq,
+	"!=": schema.FilterOperatorNe, "ne": schema.FilterOperatorNe,
+	">": schema.FilterOperatorGt, "gt": schema.FilterOperatorGt,
+	">=": schema.FilterOperatorGte, "gte": schema.FilterOperatorGte,
+	"<": schema.FilterOperatorLt, "lt": schema.FilterOperatorLt,
+	"<=": schema.FilterOperatorLte, "lte": schema.FilterOperatorLte,
+	"in": schema.FilterOperatorIn, "nin": schema.FilterOperatorNin,
+	"contains":   schema.FilterOperatorContains,
+	"text_match": schema.FilterOperatorTextMatch,
+}
+
+// Retrieve infers the query spec and retrieves with it. The spec's filters
+// are combined with ExtraFilters and the query's own filters.
+func (ar *VectorIndexAutoRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	spec, err := ar.GenerateSpec(ctx, query.QueryString)
+	if err != nil {
+		return nil, err
+	}
+	if ar.Verbose {
+		fmt.Printf("Using query str: %s\nUsing filters: %s\nUsing top_k: %d\n", spec.Query, spec.Filters, spec.TopK)
+	}
+
+	topK := ar.TopK
+	if spec.TopK > 0 {
+		topK = spec.TopK
+	}
+
+	queryEmbedding, err := ar.EmbeddingModel.GetQueryEmbedding(ctx, spec.Query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get query embedding: %w", err)
+	}
+
+	nodes, err := ar.VectorStore.Query(ctx, schema.VectorStoreQuery{
+		Embedding: queryEmbedding,
+		TopK:      topK,
+		Filters:   schema.CombineMetadataFilters(spec.Filters, ar.ExtraFilters, query.Filters),
+		Mode:      schema.QueryModeDefault,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vector store: %w", err)
+	}
+
+	return ar.HandleRecursiveRetrieval(ctx, query, nodes)
+}
+
+// Ensure VectorIndexAutoRetriever implements Retriever.
+var _ Retriever = (*VectorIndexAutoRetriever)(nil)
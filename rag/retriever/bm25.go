@@ -0,0 +1,483 @@
+package retriever
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/storage/docstore"
+)
+
+// DefaultBM25StatsID is the docstore ID under which BM25Retriever persists
+// its term statistics.
+const DefaultBM25StatsID = "__bm25_stats__"
+
+// bm25StatsVersion is the version of the persisted statistics format.
+const bm25StatsVersion = 1
+
+// BM25Retriever ranks nodes lexically with Okapi BM25. It keeps an
+// in-memory inverted index that can be updated incrementally with AddNodes
+// and DeleteNodes, and can persist its nodes and term statistics to a
+// docstore so a restarted process does not have to re-tokenize the corpus.
+type BM25Retriever struct {
+	*BaseRetriever
+	// TopK is the number of results to return.
+	TopK int
+	// K1 controls term frequency saturation.
+	K1 float64
+	// B controls document length normalization.
+	B float64
+
+	tokenizer func(string) []string
+	stemmer   func(string) string
+	stopwords map[string]bool
+	analyzer  *Analyzer
+	docStore  docstore.DocStore
+	statsID   string
+	explain   bool
+	policy    TopKPolicy
+
+	mu       sync.RWMutex
+	nodes    map[string]schema.Node
+	docTerms map[string]map[string]int
+	docLens  map[string]int
+	postings map[string]map[string]int
+	totalLen int
+	deleted  map[string]bool
+}
+
+// BM25RetrieverOption is a functional option for BM25Retriever.
+type BM25RetrieverOption func(*BM25Retriever)
+
+// WithBM25TopK sets the number of results to return. Defaults to 10.
+func WithBM25TopK(topK int) BM25RetrieverOption {
+	return func(r *BM25Retriever) {
+		r.TopK = topK
+	}
+}
+
+// WithBM25Params sets the k1 and b parameters. Defaults to 1.5 and 0.75.
+func WithBM25Params(k1, b float64) BM25RetrieverOption {
+	return func(r *BM25Retriever) {
+		r.K1 = k1
+		r.B = b
+	}
+}
+
+// WithBM25Tokenizer sets the function that splits text into terms.
+// Defaults to embedding.BM25Tokenize.
+func WithBM25Tokenizer(tokenizer func(string) []string) BM25RetrieverOption {
+	return func(r *BM25Retriever) {
+		r.tokenizer = tokenizer
+	}
+}
+
+// WithBM25Stemmer sets a function applied to every term after stopword
+// removal, e.g. a Porter stemmer.
+func WithBM25Stemmer(stemmer func(string) string) BM25RetrieverOption {
+	return func(r *BM25Retriever) {
+		r.stemmer = stemmer
+	}
+}
+
+// WithBM25Stopwords sets the stopwords to drop. Pass no words to keep every
+// term. Defaults to embedding.DefaultBM25Stopwords.
+func WithBM25Stopwords(stopwords ...string) BM25RetrieverOption {
+	return func(r *BM25Retriever) {
+		r.stopwords = make(map[string]bool, len(stopwords))
+		for _, w := range stopwords {
+			r.stopwords[strings.ToLower(w)] = true
+		}
+	}
+}
+
+// WithBM25Analyzer sets an Analyzer for per-language stopwords, stemming and
+// synonym expansion. It replaces the tokenizer, stemmer and stopword options.
+func WithBM25Analyzer(analyzer *Analyzer) BM25RetrieverOption {
+	return func(r *BM25Retriever) {
+		r.analyzer = analyzer
+	}
+}
+
+// WithBM25DocStore sets the docstore that Persist writes to.
+func WithBM25DocStore(ds docstore.DocStore) BM25RetrieverOption {
+	return func(r *BM25Retriever) {
+		r.docStore = ds
+	}
+}
+
+// WithBM25StatsID sets the docstore ID of the persisted statistics, so
+// several BM25 retrievers can share one docstore.
+func WithBM25StatsID(id string) BM25RetrieverOption {
+	return func(r *BM25Retriever) {
+		r.statsID = id
+	}
+}
+
+// WithBM25Explain records each result's per-term score contributions in
+// its score explanation (see schema.ScoreExplanationMetadataKey).
+func WithBM25Explain(explain bool) BM25RetrieverOption {
+	return func(r *BM25Retriever) {
+		r.explain = explain
+	}
+}
+
+// WithBM25TopKPolicy chooses the top-k of each query with policy, e.g. an
+// AdaptiveTopK, instead of the fixed TopK. The policy's similarity cutoff
+// is ignored, as BM25 scores are not normalized.
+func WithBM25TopKPolicy(policy TopKPolicy) BM25RetrieverOption {
+	return func(r *BM25Retriever) {
+		r.policy = policy
+	}
+}
+
+// NewBM25Retriever creates a BM25Retriever over nodes.
+func NewBM25Retriever(nodes []schema.Node, opts ...BM25RetrieverOption) *BM25Retriever {
+	r := &BM25Retriever{
+		BaseRetriever: NewBaseRetriever(),
+		TopK:          10,
+		K1:            1.5,
+		B:             0.75,
+		tokenizer:     embedding.BM25Tokenize,
+		statsID:       DefaultBM25StatsID,
+		nodes:         make(map[string]schema.Node),
+		docTerms:      make(map[string]map[string]int),
+		docLens:       make(map[string]int),
+		postings:      make(map[string]map[string]int),
+		deleted:       make(map[string]bool),
+	}
+	WithBM25Stopwords(embedding.DefaultBM25Stopwords()...)(r)
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	r.AddNodes(nodes)
+	return r
+}
+
+// NewBM25RetrieverFromDocStore restores a BM25Retriever from a docstore.
+// When persisted statistics are present the nodes they reference are
+// loaded without re-tokenizing; otherwise every node in the docstore is
+// indexed. The docstore is also used by Persist.
+func NewBM25RetrieverFromDocStore(ctx context.Context, ds docstore.DocStore, opts ...BM25RetrieverOption) (*BM25Retriever, error) {
+	r := NewBM25Retriever(nil, append([]BM25RetrieverOption{WithBM25DocStore(ds)}, opts...)...)
+
+	statsDoc, err := ds.GetDocument(ctx, r.statsID, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load BM25 statistics: %w", err)
+	}
+	if statsDoc == nil {
+		docs, err := ds.Docs(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load documents: %w", err)
+		}
+		ids := make([]string, 0, len(docs))
+		for id := range docs {
+			if id != r.statsID {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		nodes := make([]schema.Node, 0, len(ids))
+		for _, id := range ids {
+			if node, ok := toNode(docs[id]); ok {
+				nodes = append(nodes, node)
+			}
+		}
+		r.AddNodes(nodes)
+		return r, nil
+	}
+
+	var stats bm25Stats
+	if err := json.Unmarshal([]byte(statsDoc.GetContent(schema.MetadataModeNone)), &stats); err != nil {
+		return nil, fmt.Errorf("failed to decode BM25 statistics: %w", err)
+	}
+	if stats.Version != bm25StatsVersion {
+		return nil, fmt.Errorf("unsupported BM25 statistics version %d", stats.Version)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, doc := range stats.Docs {
+		stored, err := ds.GetDocument(ctx, id, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load node %s: %w", id, err)
+		}
+		node, ok := toNode(stored)
+		if !ok {
+			// The node was removed from the docstore after the statistics
+			// were written.
+			continue
+		}
+		r.nodes[id] = node
+		r.indexTerms(id, doc.Terms, doc.Length)
+	}
+	return r, nil
+}
+
+// AddNodes indexes nodes. A node whose ID is already indexed is replaced.
+func (r *BM25Retriever) AddNodes(nodes []schema.Node) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, node := range nodes {
+		r.removeLocked(node.ID)
+		delete(r.deleted, node.ID)
+
+		terms := r.Tokenize(node.GetContent(schema.MetadataModeEmbed))
+		tf := make(map[string]int)
+		for _, t := range terms {
+			tf[t]++
+		}
+		r.nodes[node.ID] = node
+		r.indexTerms(node.ID, tf, len(terms))
+	}
+}
+
+// DeleteNodes removes nodes from the index. Unknown IDs are ignored.
+func (r *BM25Retriever) DeleteNodes(ids ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		if _, ok := r.nodes[id]; ok {
+			r.removeLocked(id)
+			r.deleted[id] = true
+		}
+	}
+}
+
+// Len returns the number of indexed nodes.
+func (r *BM25Retriever) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.nodes)
+}
+
+// DocFreq returns the number of indexed nodes that contain term. The term
+// is normalized like query text.
+func (r *BM25Retriever) DocFreq(term string) int {
+	terms := r.Tokenize(term)
+	if len(terms) == 0 {
+		return 0
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.postings[terms[0]])
+}
+
+// Tokenize splits text into index terms: tokenization, stopword removal and
+// stemming, or the configured Analyzer.
+func (r *BM25Retriever) Tokenize(text string) []string {
+	if r.analyzer != nil {
+		return r.analyzer.Analyze(text)
+	}
+	raw := r.tokenizer(text)
+	terms := make([]string, 0, len(raw))
+	for _, t := range raw {
+		if t == "" || r.stopwords[t] {
+			continue
+		}
+		if r.stemmer != nil {
+			t = r.stemmer(t)
+		}
+		terms = append(terms, t)
+	}
+	return terms
+}
+
+// Retrieve returns the TopK nodes by BM25 score. Nodes that share no term
+// with the query are not returned.
+func (r *BM25Retriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	topK := r.TopK
+	if r.policy != nil {
+		params, err := r.policy.Params(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		topK = params.TopK
+	}
+	queryTerms := r.Tokenize(query.QueryString)
+
+	r.mu.RLock()
+	n := float64(len(r.nodes))
+	avgLen := 0.0
+	if n > 0 {
+		avgLen = float64(r.totalLen) / n
+	}
+
+	scores := make(map[string]float64)
+	var contributions map[string]map[string]float64
+	if r.explain {
+		contributions = make(map[string]map[string]float64)
+	}
+	seen := make(map[string]bool)
+	for _, term := range queryTerms {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		posting := r.postings[term]
+		if len(posting) == 0 {
+			continue
+		}
+		df := float64(len(posting))
+		idf := math.Log((n-df+0.5)/(df+0.5) + 1)
+		for id, freq := range posting {
+			tf := float64(freq)
+			norm := 1 - r.B
+			if avgLen > 0 {
+				norm += r.B * float64(r.docLens[id]) / avgLen
+			}
+			contribution := idf * tf * (r.K1 + 1) / (tf + r.K1*norm)
+			scores[id] += contribution
+			if contributions != nil {
+				if contributions[id] == nil {
+					contributions[id] = make(map[string]float64)
+				}
+				contributions[id][term] = contribution
+			}
+		}
+	}
+
+	results := make([]schema.NodeWithScore, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, schema.NodeWithScore{Node: r.nodes[id], Score: score})
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Node.ID < results[j].Node.ID
+	})
+	if topK > 0 && len(results) > topK {
+		results = results[:topK]
+	}
+	for i := range results {
+		if terms := contributions[results[i].Node.ID]; terms != nil {
+			results[i].ExplainScore(schema.ScoreComponent{
+				Name:    schema.ScoreComponentBM25,
+				Score:   results[i].Score,
+				Details: terms,
+			})
+		}
+	}
+
+	return r.HandleRecursiveRetrieval(ctx, query, results)
+}
+
+// Persist writes the indexed nodes and their term statistics to the
+// docstore, and removes nodes deleted since the last Persist.
+func (r *BM25Retriever) Persist(ctx context.Context) error {
+	if r.docStore == nil {
+		return errors.New("no docstore configured")
+	}
+
+	r.mu.RLock()
+	stats := bm25Stats{Version: bm25StatsVersion, Docs: make(map[string]bm25DocStats, len(r.nodes))}
+	docs := make([]schema.BaseNode, 0, len(r.nodes)+1)
+	for id, node := range r.nodes {
+		node := node
+		docs = append(docs, &node)
+		stats.Docs[id] = bm25DocStats{Length: r.docLens[id], Terms: r.docTerms[id]}
+	}
+	deleted := make([]string, 0, len(r.deleted))
+	for id := range r.deleted {
+		deleted = append(deleted, id)
+	}
+	data, err := json.Marshal(stats)
+	r.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode BM25 statistics: %w", err)
+	}
+
+	statsNode := schema.NewTextNode(string(data))
+	statsNode.ID = r.statsID
+	docs = append(docs, statsNode)
+
+	if err := r.docStore.AddDocuments(ctx, docs, true); err != nil {
+		return fmt.Errorf("failed to persist BM25 index: %w", err)
+	}
+	for _, id := range deleted {
+		if err := r.docStore.DeleteDocument(ctx, id, false); err != nil {
+			return fmt.Errorf("failed to delete node %s: %w", id, err)
+		}
+	}
+
+	r.mu.Lock()
+	for _, id := range deleted {
+		delete(r.deleted, id)
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// indexTerms adds a node's term frequencies to the index. The caller holds
+// the write lock.
+func (r *BM25Retriever) indexTerms(id string, tf map[string]int, length int) {
+	r.docTerms[id] = tf
+	r.docLens[id] = length
+	r.totalLen += length
+	for term, freq := range tf {
+		posting, ok := r.postings[term]
+		if !ok {
+			posting = make(map[string]int)
+			r.postings[term] = posting
+		}
+		posting[id] = freq
+	}
+}
+
+// removeLocked drops a node from the index. The caller holds the write
+// lock.
+func (r *BM25Retriever) removeLocked(id string) {
+	tf, ok := r.docTerms[id]
+	if !ok {
+		return
+	}
+	for term := range tf {
+		delete(r.postings[term], id)
+		if len(r.postings[term]) == 0 {
+			delete(r.postings, term)
+		}
+	}
+	r.totalLen -= r.docLens[id]
+	delete(r.docTerms, id)
+	delete(r.docLens, id)
+	delete(r.nodes, id)
+}
+
+// bm25Stats is the persisted form of the index.
+type bm25Stats struct {
+	Version int                     `json:"version"`
+	Docs    map[string]bm25DocStats `json:"docs"`
+}
+
+// bm25DocStats holds one node's length and term frequencies.
+type bm25DocStats struct {
+	Length int            `json:"length"`
+	Terms  map[string]int `json:"terms"`
+}
+
+// toNode converts a docstore entry to a schema.Node.
+func toNode(doc schema.BaseNode) (schema.Node, bool) {
+	n, ok := doc.(*schema.Node)
+	if !ok || n == nil {
+		return schema.Node{}, false
+	}
+	return *n, true
+}
+
+// Ensure BM25Retriever implements Retriever.
+var _ Retriever = (*BM25Retriever)(nil)
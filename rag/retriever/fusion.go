@@ -2,6 +2,7 @@ package retriever
 
 import (
 	"context"
+	"fmt"
 	"sort"
 
 	"github.com/aqua777/go-llamaindex/schema"
@@ -32,6 +33,12 @@ type FusionRetriever struct {
 	Mode FusionMode
 	// SimilarityTopK is the number of results to return.
 	SimilarityTopK int
+	// RetrieverNames name the retrievers in timeout reports. Defaults to
+	// "retriever_<index>".
+	RetrieverNames []string
+	// Timeouts sets per-retriever deadlines. When nil, retrievers run one
+	// after another without deadlines.
+	Timeouts *ChildTimeouts
 }
 
 // FusionRetrieverOption is a functional option for FusionRetriever.
@@ -67,6 +74,22 @@ func WithRetrieverWeights(weights []float64) FusionRetrieverOption {
 	}
 }
 
+// WithRetrieverNames names the retrievers, in order, for timeout reports
+// and per-source timeouts.
+func WithRetrieverNames(names ...string) FusionRetrieverOption {
+	return func(fr *FusionRetriever) {
+		fr.RetrieverNames = names
+	}
+}
+
+// WithFusionTimeouts runs the retrievers concurrently with per-retriever
+// deadlines, fusing the results that arrive in time.
+func WithFusionTimeouts(timeouts ChildTimeouts) FusionRetrieverOption {
+	return func(fr *FusionRetriever) {
+		fr.Timeouts = &timeouts
+	}
+}
+
 // NewFusionRetriever creates a new FusionRetriever.
 func NewFusionRetriever(retrievers []Retriever, opts ...FusionRetrieverOption) *FusionRetriever {
 	// Default equal weights
@@ -90,41 +113,55 @@ func NewFusionRetriever(retrievers []Retriever, opts ...FusionRetrieverOption) *
 	return fr
 }
 
-// Retrieve retrieves nodes from all retrievers and fuses the results.
+// Retrieve retrieves nodes from all retrievers and fuses the results. With
+// timeouts, retrievers that miss their deadline are left out and listed
+// under MissingSourcesMetadataKey in the results' metadata.
 func (fr *FusionRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
-	// Collect results from all retrievers
-	// Key: (query_str, retriever_index), Value: results
-	results := make(map[int][]schema.NodeWithScore)
-
+	children := make([]namedRetriever, len(fr.Retrievers))
 	for i, retriever := range fr.Retrievers {
-		nodes, err := retriever.Retrieve(ctx, query)
-		if err != nil {
-			return nil, err
+		name := fmt.Sprintf("retriever_%d", i)
+		if i < len(fr.RetrieverNames) && fr.RetrieverNames[i] != "" {
+			name = fr.RetrieverNames[i]
 		}
-		results[i] = nodes
+		children[i] = namedRetriever{name: name, retriever: retriever}
+	}
+	gathered, missing, err := gatherResults(ctx, "fusion", children, query, fr.Timeouts)
+	if err != nil {
+		return nil, err
 	}
 
-	// Apply fusion strategy
-	var fusedNodes []schema.NodeWithScore
-	switch fr.Mode {
-	case FusionModeReciprocalRank:
-		fusedNodes = fr.reciprocalRankFusion(results)
-	case FusionModeRelativeScore:
-		fusedNodes = fr.relativeScoreFusion(results, false)
-	case FusionModeDistBasedScore:
-		fusedNodes = fr.relativeScoreFusion(results, true)
-	case FusionModeSimple:
-		fusedNodes = fr.simpleFusion(results)
-	default:
-		fusedNodes = fr.simpleFusion(results)
+	// Collect results from the retrievers that answered, keyed by
+	// retriever index so that weights still apply.
+	results := make(map[int][]schema.NodeWithScore)
+	for i, res := range gathered {
+		if !res.timedOut {
+			results[i] = res.nodes
+		}
 	}
 
+	fusedNodes := fr.fuse(results)
+
 	// Limit to top K
 	if len(fusedNodes) > fr.SimilarityTopK {
 		fusedNodes = fusedNodes[:fr.SimilarityTopK]
 	}
 
-	return fusedNodes, nil
+	return annotateMissing(fusedNodes, missing), nil
+}
+
+// fuse applies the fusion strategy to result lists keyed by their index in
+// RetrieverWeights.
+func (fr *FusionRetriever) fuse(results map[int][]schema.NodeWithScore) []schema.NodeWithScore {
+	switch fr.Mode {
+	case FusionModeReciprocalRank:
+		return fr.reciprocalRankFusion(results)
+	case FusionModeRelativeScore:
+		return fr.relativeScoreFusion(results, false)
+	case FusionModeDistBasedScore:
+		return fr.relativeScoreFusion(results, true)
+	default:
+		return fr.simpleFusion(results)
+	}
 }
 
 // reciprocalRankFusion applies Reciprocal Rank Fusion.
@@ -0,0 +1,278 @@
+package retriever
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// FusionModeWeightedScore sums the raw scores weighted per retriever,
+// without normalization. Use it when both retrievers already score on a
+// comparable scale.
+const FusionModeWeightedScore FusionMode = "weighted_score"
+
+// Metadata keys HybridRetriever sets on fused nodes.
+const (
+	// HybridDenseScoreMetadataKey holds the node's dense retriever score.
+	HybridDenseScoreMetadataKey = "hybrid_dense_score"
+	// HybridSparseScoreMetadataKey holds the node's sparse retriever score.
+	HybridSparseScoreMetadataKey = "hybrid_sparse_score"
+)
+
+// HybridRetriever combines a dense (vector) retriever with a sparse
+// (keyword or BM25) retriever. Both run concurrently and their results are
+// fused by node ID with one of:
+//
+//   - FusionModeReciprocalRank: weighted Reciprocal Rank Fusion (default)
+//   - FusionModeRelativeScore: min-max normalized scores, weighted
+//   - FusionModeDistBasedScore: scores normalized by mean ± 3 std dev, weighted
+//   - FusionModeWeightedScore: raw scores, weighted
+//   - FusionModeSimple: the higher of the two scores
+//
+// Alpha weights the dense side and 1-Alpha the sparse side. Each fused node
+// carries the original scores under HybridDenseScoreMetadataKey and
+// HybridSparseScoreMetadataKey.
+type HybridRetriever struct {
+	*BaseRetriever
+	// Dense is the vector retriever.
+	Dense Retriever
+	// Sparse is the keyword retriever, typically a BM25Retriever.
+	Sparse Retriever
+	// Mode is the fusion strategy.
+	Mode FusionMode
+	// Alpha is the weight of the dense retriever, in [0, 1].
+	Alpha float64
+	// RRFK is the rank constant for Reciprocal Rank Fusion.
+	RRFK float64
+	// TopK is the number of fused results to return.
+	TopK int
+}
+
+// HybridRetrieverOption is a functional option for HybridRetriever.
+type HybridRetrieverOption func(*HybridRetriever)
+
+// WithHybridMode sets the fusion strategy.
+func WithHybridMode(mode FusionMode) HybridRetrieverOption {
+	return func(r *HybridRetriever) {
+		r.Mode = mode
+	}
+}
+
+// WithHybridAlpha sets the dense weight; the sparse weight is 1-alpha.
+// Defaults to 0.5.
+func WithHybridAlpha(alpha float64) HybridRetrieverOption {
+	return func(r *HybridRetriever) {
+		r.Alpha = math.Max(0, math.Min(1, alpha))
+	}
+}
+
+// WithHybridRRFK sets the Reciprocal Rank Fusion rank constant. Defaults
+// to 60.
+func WithHybridRRFK(k float64) HybridRetrieverOption {
+	return func(r *HybridRetriever) {
+		r.RRFK = k
+	}
+}
+
+// WithHybridTopK sets the number of fused results. Defaults to 10.
+func WithHybridTopK(topK int) HybridRetrieverOption {
+	return func(r *HybridRetriever) {
+		r.TopK = topK
+	}
+}
+
+// NewHybridRetriever creates a new HybridRetriever.
+func NewHybridRetriever(dense, sparse Retriever, opts ...HybridRetrieverOption) *HybridRetriever {
+	r := &HybridRetriever{
+		BaseRetriever: NewBaseRetriever(),
+		Dense:         dense,
+		Sparse:        sparse,
+		Mode:          FusionModeReciprocalRank,
+		Alpha:         0.5,
+		RRFK:          60,
+		TopK:          10,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Retrieve runs both retrievers and fuses their results.
+func (r *HybridRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	var (
+		wg                  sync.WaitGroup
+		dense, sparse       []schema.NodeWithScore
+		denseErr, sparseErr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		dense, denseErr = r.Dense.Retrieve(ctx, query)
+	}()
+	go func() {
+		defer wg.Done()
+		sparse, sparseErr = r.Sparse.Retrieve(ctx, query)
+	}()
+	wg.Wait()
+
+	if denseErr != nil {
+		return nil, fmt.Errorf("dense retrieval failed: %w", denseErr)
+	}
+	if sparseErr != nil {
+		return nil, fmt.Errorf("sparse retrieval failed: %w", sparseErr)
+	}
+
+	fused := r.fuse(dense, sparse)
+	if r.TopK > 0 && len(fused) > r.TopK {
+		fused = fused[:r.TopK]
+	}
+
+	return r.HandleRecursiveRetrieval(ctx, query, fused)
+}
+
+// hybridEntry accumulates one node's scores during fusion.
+type hybridEntry struct {
+	node   schema.NodeWithScore
+	dense  *float64
+	sparse *float64
+	score  float64
+}
+
+// fuse merges the two result lists according to Mode.
+func (r *HybridRetriever) fuse(dense, sparse []schema.NodeWithScore) []schema.NodeWithScore {
+	entries := make(map[string]*hybridEntry)
+	var order []string
+	add := func(nodes []schema.NodeWithScore, isDense bool, weight float64, scoreOf func(int, schema.NodeWithScore) float64) {
+		for i, n := range nodes {
+			key := hybridKey(n.Node)
+			e, ok := entries[key]
+			if !ok {
+				e = &hybridEntry{node: n}
+				entries[key] = e
+				order = append(order, key)
+			}
+			raw := n.Score
+			if isDense {
+				e.dense = &raw
+			} else {
+				e.sparse = &raw
+			}
+
+			s := scoreOf(i, n)
+			if r.Mode == FusionModeSimple {
+				if s > e.score || !ok {
+					e.score = s
+				}
+				continue
+			}
+			e.score += weight * s
+		}
+	}
+
+	denseWeight, sparseWeight := r.Alpha, 1-r.Alpha
+	switch r.Mode {
+	case FusionModeRelativeScore, FusionModeDistBasedScore:
+		distBased := r.Mode == FusionModeDistBasedScore
+		add(dense, true, denseWeight, normalizer(dense, distBased))
+		add(sparse, false, sparseWeight, normalizer(sparse, distBased))
+	case FusionModeWeightedScore, FusionModeSimple:
+		raw := func(_ int, n schema.NodeWithScore) float64 { return n.Score }
+		add(dense, true, denseWeight, raw)
+		add(sparse, false, sparseWeight, raw)
+	default:
+		rrf := func(rank int, _ schema.NodeWithScore) float64 { return 1 / (r.RRFK + float64(rank+1)) }
+		add(sortedByScore(dense), true, denseWeight, rrf)
+		add(sortedByScore(sparse), false, sparseWeight, rrf)
+	}
+
+	fused := make([]schema.NodeWithScore, 0, len(order))
+	for _, key := range order {
+		e := entries[key]
+		n := e.node
+		metadata := make(map[string]interface{}, len(n.Node.Metadata)+2)
+		for k, v := range n.Node.Metadata {
+			metadata[k] = v
+		}
+		if e.dense != nil {
+			metadata[HybridDenseScoreMetadataKey] = *e.dense
+		}
+		if e.sparse != nil {
+			metadata[HybridSparseScoreMetadataKey] = *e.sparse
+		}
+		n.Node.Metadata = metadata
+		n.Score = e.score
+		fused = append(fused, n)
+	}
+
+	sort.SliceStable(fused, func(i, j int) bool {
+		return fused[i].Score > fused[j].Score
+	})
+	return fused
+}
+
+// normalizer returns a function that maps scores into [0, 1] using the
+// min and max of nodes, or mean ± 3 standard deviations when distBased.
+func normalizer(nodes []schema.NodeWithScore, distBased bool) func(int, schema.NodeWithScore) float64 {
+	if len(nodes) == 0 {
+		return func(int, schema.NodeWithScore) float64 { return 0 }
+	}
+
+	lo, hi := nodes[0].Score, nodes[0].Score
+	if distBased {
+		mean := 0.0
+		for _, n := range nodes {
+			mean += n.Score
+		}
+		mean /= float64(len(nodes))
+		variance := 0.0
+		for _, n := range nodes {
+			variance += (n.Score - mean) * (n.Score - mean)
+		}
+		std := math.Sqrt(variance / float64(len(nodes)))
+		lo, hi = mean-3*std, mean+3*std
+	} else {
+		for _, n := range nodes {
+			lo = math.Min(lo, n.Score)
+			hi = math.Max(hi, n.Score)
+		}
+	}
+
+	return func(_ int, n schema.NodeWithScore) float64 {
+		if hi == lo {
+			if hi > 0 {
+				return 1
+			}
+			return 0
+		}
+		return math.Max(0, math.Min(1, (n.Score-lo)/(hi-lo)))
+	}
+}
+
+// sortedByScore returns a copy of nodes sorted by descending score.
+func sortedByScore(nodes []schema.NodeWithScore) []schema.NodeWithScore {
+	sorted := make([]schema.NodeWithScore, len(nodes))
+	copy(sorted, nodes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Score > sorted[j].Score
+	})
+	return sorted
+}
+
+// hybridKey identifies a node across retrievers: its ID, or its content
+// hash when it has none.
+func hybridKey(node schema.Node) string {
+	if node.ID != "" {
+		return node.ID
+	}
+	return node.GenerateHash()
+}
+
+// Ensure HybridRetriever implements Retriever.
+var _ Retriever = (*HybridRetriever)(nil)
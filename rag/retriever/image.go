@@ -0,0 +1,113 @@
+package retriever
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/rag/store"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// ImageRetriever finds images for a text query. Images and queries are
+// embedded into the same space by a multi-modal model (e.g. CLIP), so a
+// text query embedding can be matched against image embeddings.
+//
+// Images are stored as plain nodes with the image reference in metadata;
+// use schema.ImageNodeFromNode on the results to get the ImageNode back.
+type ImageRetriever struct {
+	*BaseRetriever
+	// VectorStore holds the image embeddings.
+	VectorStore store.VectorStore
+	// EmbeddingModel embeds images and text queries.
+	EmbeddingModel embedding.MultiModalEmbeddingModel
+	// TopK is the number of images to return.
+	TopK int
+}
+
+// ImageRetrieverOption is a functional option for ImageRetriever.
+type ImageRetrieverOption func(*ImageRetriever)
+
+// WithImageTopK sets the number of images to return.
+func WithImageTopK(topK int) ImageRetrieverOption {
+	return func(r *ImageRetriever) {
+		r.TopK = topK
+	}
+}
+
+// NewImageRetriever creates a new ImageRetriever.
+func NewImageRetriever(
+	vectorStore store.VectorStore,
+	embeddingModel embedding.MultiModalEmbeddingModel,
+	opts ...ImageRetrieverOption,
+) *ImageRetriever {
+	r := &ImageRetriever{
+		BaseRetriever:  NewBaseRetriever(),
+		VectorStore:    vectorStore,
+		EmbeddingModel: embeddingModel,
+		TopK:           4,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// AddImages embeds the images and adds them to the vector store.
+func (r *ImageRetriever) AddImages(ctx context.Context, images []*schema.ImageNode) ([]string, error) {
+	if !r.EmbeddingModel.SupportsMultiModal() {
+		return nil, fmt.Errorf("embedding model does not support images")
+	}
+
+	nodes := make([]schema.Node, 0, len(images))
+	for _, img := range images {
+		if !img.HasImage() {
+			return nil, fmt.Errorf("image node %s has no image", img.ID)
+		}
+		emb, err := r.EmbeddingModel.GetImageEmbedding(ctx, embedding.ImageType{
+			URL:      img.ImageURL,
+			Base64:   img.Image,
+			Path:     img.ImagePath,
+			MimeType: img.ImageMimeType,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed image %s: %w", img.ID, err)
+		}
+		node := img.AsNode()
+		node.Embedding = emb
+		nodes = append(nodes, node)
+	}
+
+	return r.VectorStore.Add(ctx, nodes)
+}
+
+// Retrieve returns the images most similar to the query text. Non-image
+// nodes in the store are skipped.
+func (r *ImageRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	queryEmbedding, err := r.EmbeddingModel.GetQueryEmbedding(ctx, query.QueryString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get query embedding: %w", err)
+	}
+
+	nodes, err := r.VectorStore.Query(ctx, schema.VectorStoreQuery{
+		Embedding: queryEmbedding,
+		TopK:      r.TopK,
+		Filters:   query.Filters,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vector store: %w", err)
+	}
+
+	images := make([]schema.NodeWithScore, 0, len(nodes))
+	for _, n := range nodes {
+		if _, ok := schema.ImageNodeFromNode(n.Node); ok {
+			images = append(images, n)
+		}
+	}
+	return images, nil
+}
+
+// Ensure ImageRetriever implements Retriever.
+var _ Retriever = (*ImageRetriever)(nil)
@@ -0,0 +1,92 @@
+package retriever
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/rag/store"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// LateInteractionRetriever retrieves nodes with ColBERT-style late
+// interaction. Queries and documents are embedded token by token, and each
+// document is scored by MaxSim: every query token is matched against its
+// most similar document token and the matches are summed. This keeps
+// fine-grained term matching that a single pooled vector loses.
+type LateInteractionRetriever struct {
+	*BaseRetriever
+	// Store holds the per-token document embeddings.
+	Store store.MultiVectorStore
+	// EmbeddingModel produces per-token embeddings.
+	EmbeddingModel embedding.MultiVectorEmbeddingModel
+	// TopK is the number of results to return.
+	TopK int
+}
+
+// LateInteractionRetrieverOption is a functional option for
+// LateInteractionRetriever.
+type LateInteractionRetrieverOption func(*LateInteractionRetriever)
+
+// WithLateInteractionTopK sets the number of results to return.
+func WithLateInteractionTopK(topK int) LateInteractionRetrieverOption {
+	return func(r *LateInteractionRetriever) {
+		r.TopK = topK
+	}
+}
+
+// NewLateInteractionRetriever creates a new LateInteractionRetriever.
+func NewLateInteractionRetriever(
+	multiVectorStore store.MultiVectorStore,
+	embeddingModel embedding.MultiVectorEmbeddingModel,
+	opts ...LateInteractionRetrieverOption,
+) *LateInteractionRetriever {
+	r := &LateInteractionRetriever{
+		BaseRetriever:  NewBaseRetriever(),
+		Store:          multiVectorStore,
+		EmbeddingModel: embeddingModel,
+		TopK:           10,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// AddNodes embeds the nodes token by token and adds them to the store.
+func (r *LateInteractionRetriever) AddNodes(ctx context.Context, nodes []schema.Node) ([]string, error) {
+	vectors := make([][][]float64, len(nodes))
+	for i, node := range nodes {
+		v, err := r.EmbeddingModel.GetTextMultiVector(ctx, node.GetContent(schema.MetadataModeEmbed))
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed node %s: %w", node.ID, err)
+		}
+		vectors[i] = v
+	}
+
+	return r.Store.AddMultiVector(ctx, nodes, vectors)
+}
+
+// Retrieve retrieves nodes by MaxSim score.
+func (r *LateInteractionRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	queryVectors, err := r.EmbeddingModel.GetQueryMultiVector(ctx, query.QueryString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get query embeddings: %w", err)
+	}
+
+	nodes, err := r.Store.QueryMultiVector(ctx, store.MultiVectorQuery{
+		Embeddings: queryVectors,
+		TopK:       r.TopK,
+		Filters:    query.Filters,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query multi-vector store: %w", err)
+	}
+
+	return r.HandleRecursiveRetrieval(ctx, query, nodes)
+}
+
+// Ensure LateInteractionRetriever implements Retriever.
+var _ Retriever = (*LateInteractionRetriever)(nil)
@@ -0,0 +1,305 @@
+package retriever
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidCursor is returned for a cursor that cannot be decoded or
+	// belongs to a different query.
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrCursorExpired is returned for a cursor whose results are no longer
+	// held, e.g. after the pager's TTL. Start again without a cursor.
+	ErrCursorExpired = errors.New("cursor expired")
+)
+
+// Page is one page of retrieval results.
+type Page struct {
+	// Nodes are the results on this page.
+	Nodes []schema.NodeWithScore
+	// NextCursor fetches the next page. It is empty on the last page.
+	NextCursor string
+	// Offset is the position of the first node in the full result list.
+	Offset int
+	// Total is the number of results across all pages.
+	Total int
+}
+
+// HasMore reports whether there is a next page.
+func (p *Page) HasMore() bool {
+	return p.NextCursor != ""
+}
+
+// CursorRetriever is implemented by retrievers that return results page by
+// page.
+type CursorRetriever interface {
+	// RetrieveWithCursor returns the page starting at cursor, or the first
+	// page for an empty cursor.
+	RetrieveWithCursor(ctx context.Context, query schema.QueryBundle, cursor string, pageSize int) (*Page, error)
+}
+
+// ResultPager holds result lists between page requests. The first request
+// for a query computes the results and takes a snapshot of them; later pages
+// are served from the snapshot, so they are neither recomputed nor shifted
+// by index updates, and no node appears on two pages.
+type ResultPager struct {
+	ttl         time.Duration
+	maxSessions int
+	now         func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*pagerSession
+	order    []string
+}
+
+// pagerSession is the snapshot of one query's results.
+type pagerSession struct {
+	key        string
+	nodes      []schema.NodeWithScore
+	lastAccess time.Time
+}
+
+// cursorToken is the decoded form of a cursor.
+type cursorToken struct {
+	Session string `json:"s"`
+	Offset  int    `json:"o"`
+}
+
+// ResultPagerOption configures a ResultPager.
+type ResultPagerOption func(*ResultPager)
+
+// WithPagerTTL sets how long an unused snapshot is kept. Defaults to 10
+// minutes.
+func WithPagerTTL(ttl time.Duration) ResultPagerOption {
+	return func(p *ResultPager) {
+		p.ttl = ttl
+	}
+}
+
+// WithPagerMaxSessions sets how many snapshots are kept; the oldest are
+// dropped first. Defaults to 1000.
+func WithPagerMaxSessions(n int) ResultPagerOption {
+	return func(p *ResultPager) {
+		p.maxSessions = n
+	}
+}
+
+// NewResultPager creates a new ResultPager.
+func NewResultPager(opts ...ResultPagerOption) *ResultPager {
+	p := &ResultPager{
+		ttl:         10 * time.Minute,
+		maxSessions: 1000,
+		now:         time.Now,
+		sessions:    make(map[string]*pagerSession),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// Paginate returns a page of the results for key, which identifies the
+// query. Without a cursor it calls fetch and snapshots the results in their
+// order, dropping repeated node IDs; with a cursor it serves the page from
+// the snapshot. A page size of zero or less returns all remaining results.
+func (p *ResultPager) Paginate(ctx context.Context, key, cursor string, pageSize int, fetch func(ctx context.Context) ([]schema.NodeWithScore, error)) (*Page, error) {
+	if cursor == "" {
+		nodes, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		id := p.store(key, dedupeNodes(nodes))
+		return p.page(id, 0, pageSize)
+	}
+
+	token, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	session, ok := p.sessions[token.Session]
+	if ok && p.expired(session) {
+		p.dropLocked(token.Session)
+		ok = false
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		return nil, ErrCursorExpired
+	}
+	if session.key != key {
+		return nil, fmt.Errorf("%w: cursor belongs to a different query", ErrInvalidCursor)
+	}
+	return p.page(token.Session, token.Offset, pageSize)
+}
+
+// Len returns the number of snapshots held.
+func (p *ResultPager) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.sessions)
+}
+
+// store snapshots nodes and returns the session ID.
+func (p *ResultPager) store(key string, nodes []schema.NodeWithScore) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Drop expired snapshots, then the oldest ones over the limit.
+	for _, id := range append([]string(nil), p.order...) {
+		if p.expired(p.sessions[id]) {
+			p.dropLocked(id)
+		}
+	}
+	for p.maxSessions > 0 && len(p.order) >= p.maxSessions {
+		p.dropLocked(p.order[0])
+	}
+
+	id := uuid.New().String()
+	p.sessions[id] = &pagerSession{key: key, nodes: nodes, lastAccess: p.now()}
+	p.order = append(p.order, id)
+	return id
+}
+
+// page cuts a page out of a snapshot.
+func (p *ResultPager) page(id string, offset, pageSize int) (*Page, error) {
+	p.mu.Lock()
+	session, ok := p.sessions[id]
+	if ok {
+		session.lastAccess = p.now()
+	}
+	p.mu.Unlock()
+	if !ok {
+		return nil, ErrCursorExpired
+	}
+
+	total := len(session.nodes)
+	if offset < 0 || offset > total {
+		return nil, fmt.Errorf("%w: offset %d out of range", ErrInvalidCursor, offset)
+	}
+
+	end := total
+	if pageSize > 0 && offset+pageSize < total {
+		end = offset + pageSize
+	}
+
+	page := &Page{
+		Nodes:  append([]schema.NodeWithScore(nil), session.nodes[offset:end]...),
+		Offset: offset,
+		Total:  total,
+	}
+	if end < total {
+		page.NextCursor = encodeCursor(cursorToken{Session: id, Offset: end})
+	}
+	return page, nil
+}
+
+func (p *ResultPager) expired(session *pagerSession) bool {
+	return p.ttl > 0 && p.now().Sub(session.lastAccess) > p.ttl
+}
+
+func (p *ResultPager) dropLocked(id string) {
+	delete(p.sessions, id)
+	for i, other := range p.order {
+		if other == id {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func encodeCursor(token cursorToken) string {
+	data, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (cursorToken, error) {
+	var token cursorToken
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || json.Unmarshal(data, &token) != nil || token.Session == "" {
+		return token, ErrInvalidCursor
+	}
+	return token, nil
+}
+
+// dedupeNodes drops repeated node IDs, keeping the first occurrence.
+func dedupeNodes(nodes []schema.NodeWithScore) []schema.NodeWithScore {
+	seen := make(map[string]bool, len(nodes))
+	out := make([]schema.NodeWithScore, 0, len(nodes))
+	for _, n := range nodes {
+		if n.Node.ID != "" {
+			if seen[n.Node.ID] {
+				continue
+			}
+			seen[n.Node.ID] = true
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// QueryKey identifies a query bundle for pagination: its query string,
+// filters and as-of time.
+func QueryKey(query schema.QueryBundle) string {
+	var b strings.Builder
+	b.WriteString(query.QueryString)
+	if query.Filters != nil {
+		filters, _ := json.Marshal(query.Filters)
+		b.WriteString("\x00")
+		b.Write(filters)
+	}
+	if query.AsOf != nil {
+		b.WriteString("\x00")
+		b.WriteString(strconv.FormatInt(query.AsOf.UnixNano(), 10))
+	}
+	return b.String()
+}
+
+// PaginatedRetriever adds cursor pagination to a retriever. Configure the
+// wrapped retriever to return the full result list, e.g. with a large top
+// k; pages are cut from a snapshot of it.
+type PaginatedRetriever struct {
+	// Retriever computes the results.
+	Retriever Retriever
+	// Pager holds the snapshots.
+	Pager *ResultPager
+}
+
+// NewPaginatedRetriever wraps r with a new ResultPager.
+func NewPaginatedRetriever(r Retriever, opts ...ResultPagerOption) *PaginatedRetriever {
+	return &PaginatedRetriever{
+		Retriever: r,
+		Pager:     NewResultPager(opts...),
+	}
+}
+
+// Retrieve returns all results of the wrapped retriever.
+func (r *PaginatedRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	return r.Retriever.Retrieve(ctx, query)
+}
+
+// RetrieveWithCursor returns the page starting at cursor, or retrieves and
+// returns the first page for an empty cursor.
+func (r *PaginatedRetriever) RetrieveWithCursor(ctx context.Context, query schema.QueryBundle, cursor string, pageSize int) (*Page, error) {
+	return r.Pager.Paginate(ctx, QueryKey(query), cursor, pageSize, func(ctx context.Context) ([]schema.NodeWithScore, error) {
+		return r.Retriever.Retrieve(ctx, query)
+	})
+}
+
+// Ensure PaginatedRetriever implements Retriever and CursorRetriever.
+var _ Retriever = (*PaginatedRetriever)(nil)
+var _ CursorRetriever = (*PaginatedRetriever)(nil)
@@ -0,0 +1,223 @@
+package retriever
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// DefaultQueryGenPrompt is the default template for generating the extra
+// search queries of a QueryFusionRetriever.
+const DefaultQueryGenPrompt = `You are a helpful assistant that generates multiple search queries based on a single input query. Generate {num_queries} search queries, one on each line, related to the following input query:
+Query: {query}
+Queries:
+`
+
+// listMarkerPattern matches the numbering or bullet an LLM puts in front of
+// each generated query.
+var listMarkerPattern = regexp.MustCompile(`^\s*(?:\d+[.)]|[-*•])?\s*`)
+
+// QueryFusionRetriever boosts recall by asking the LLM for paraphrases of
+// the query, retrieving for the original and every paraphrase from each
+// retriever concurrently, and fusing all result lists into one ranking.
+type QueryFusionRetriever struct {
+	*BaseRetriever
+	// Retrievers are queried with every generated query.
+	Retrievers []Retriever
+	// LLM generates the paraphrased queries.
+	LLM llm.LLM
+	// NumQueries is the total number of queries, including the original.
+	// With 1, no queries are generated.
+	NumQueries int
+	// Mode is the fusion strategy. Defaults to reciprocal rank fusion.
+	Mode FusionMode
+	// RetrieverWeights weight each retriever in score-based fusion modes.
+	RetrieverWeights []float64
+	// SimilarityTopK is the number of results to return.
+	SimilarityTopK int
+	// MaxConcurrency bounds the concurrent retrievals. Zero means no limit.
+	MaxConcurrency int
+	// Prompt is the template used to generate queries.
+	Prompt prompts.BasePromptTemplate
+}
+
+// QueryFusionRetrieverOption is a functional option for QueryFusionRetriever.
+type QueryFusionRetrieverOption func(*QueryFusionRetriever)
+
+// WithNumQueries sets the total number of queries, including the original.
+// Defaults to 4.
+func WithNumQueries(n int) QueryFusionRetrieverOption {
+	return func(qfr *QueryFusionRetriever) {
+		qfr.NumQueries = n
+	}
+}
+
+// WithQueryFusionMode sets the fusion mode.
+func WithQueryFusionMode(mode FusionMode) QueryFusionRetrieverOption {
+	return func(qfr *QueryFusionRetriever) {
+		qfr.Mode = mode
+	}
+}
+
+// WithQueryFusionWeights sets the weight of each retriever for the
+// score-based fusion modes. Weights are normalized to sum to 1.
+func WithQueryFusionWeights(weights []float64) QueryFusionRetrieverOption {
+	return func(qfr *QueryFusionRetriever) {
+		total := 0.0
+		for _, w := range weights {
+			total += w
+		}
+		normalized := make([]float64, len(weights))
+		for i, w := range weights {
+			normalized[i] = w / total
+		}
+		qfr.RetrieverWeights = normalized
+	}
+}
+
+// WithQueryFusionTopK sets the number of results to return.
+func WithQueryFusionTopK(topK int) QueryFusionRetrieverOption {
+	return func(qfr *QueryFusionRetriever) {
+		qfr.SimilarityTopK = topK
+	}
+}
+
+// WithQueryFusionMaxConcurrency bounds the number of concurrent retrievals.
+func WithQueryFusionMaxConcurrency(n int) QueryFusionRetrieverOption {
+	return func(qfr *QueryFusionRetriever) {
+		qfr.MaxConcurrency = n
+	}
+}
+
+// WithQueryGenPrompt sets the query generation prompt template.
+func WithQueryGenPrompt(prompt prompts.BasePromptTemplate) QueryFusionRetrieverOption {
+	return func(qfr *QueryFusionRetriever) {
+		qfr.Prompt = prompt
+	}
+}
+
+// NewQueryFusionRetriever creates a new QueryFusionRetriever.
+func NewQueryFusionRetriever(retrievers []Retriever, llmModel llm.LLM, opts ...QueryFusionRetrieverOption) *QueryFusionRetriever {
+	weights := make([]float64, len(retrievers))
+	for i := range weights {
+		weights[i] = 1.0 / float64(len(retrievers))
+	}
+
+	qfr := &QueryFusionRetriever{
+		BaseRetriever:    NewBaseRetriever(),
+		Retrievers:       retrievers,
+		LLM:              llmModel,
+		NumQueries:       4,
+		Mode:             FusionModeReciprocalRank,
+		RetrieverWeights: weights,
+		SimilarityTopK:   10,
+		Prompt:           prompts.NewPromptTemplate(DefaultQueryGenPrompt, prompts.PromptTypeCustom),
+	}
+
+	for _, opt := range opts {
+		opt(qfr)
+	}
+
+	qfr.SetPrompt("query_gen_prompt", qfr.Prompt)
+
+	return qfr
+}
+
+// GenerateQueries returns the original query followed by up to
+// NumQueries-1 LLM-generated paraphrases.
+func (qfr *QueryFusionRetriever) GenerateQueries(ctx context.Context, query string) ([]string, error) {
+	queries := []string{query}
+	if qfr.NumQueries <= 1 {
+		return queries, nil
+	}
+
+	prompt := qfr.Prompt.Format(map[string]string{
+		"num_queries": fmt.Sprintf("%d", qfr.NumQueries-1),
+		"query":       query,
+	})
+	response, err := qfr.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate queries: %w", err)
+	}
+
+	seen := map[string]bool{strings.ToLower(query): true}
+	for _, line := range strings.Split(response, "\n") {
+		if len(queries) >= qfr.NumQueries {
+			break
+		}
+		q := strings.Trim(listMarkerPattern.ReplaceAllString(line, ""), "\" ")
+		if q == "" || seen[strings.ToLower(q)] {
+			continue
+		}
+		seen[strings.ToLower(q)] = true
+		queries = append(queries, q)
+	}
+
+	if qfr.Verbose {
+		fmt.Printf("Generated queries:\n%s\n", strings.Join(queries[1:], "\n"))
+	}
+	return queries, nil
+}
+
+// Retrieve generates the queries, retrieves for every query and retriever
+// pair concurrently, and fuses the results.
+func (qfr *QueryFusionRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	queries, err := qfr.GenerateQueries(ctx, query.QueryString)
+	if err != nil {
+		return nil, err
+	}
+
+	numRetrievers := len(qfr.Retrievers)
+	lists := make([][]schema.NodeWithScore, len(queries)*numRetrievers)
+	errs := make([]error, len(lists))
+
+	var sem chan struct{}
+	if qfr.MaxConcurrency > 0 {
+		sem = make(chan struct{}, qfr.MaxConcurrency)
+	}
+
+	var wg sync.WaitGroup
+	for qi, q := range queries {
+		bundle := query
+		bundle.QueryString = q
+		for ri, ret := range qfr.Retrievers {
+			wg.Add(1)
+			go func(idx int, ret Retriever, bundle schema.QueryBundle) {
+				defer wg.Done()
+				if sem != nil {
+					sem <- struct{}{}
+					defer func() { <-sem }()
+				}
+				lists[idx], errs[idx] = ret.Retrieve(ctx, bundle)
+			}(qi*numRetrievers+ri, ret, bundle)
+		}
+	}
+	wg.Wait()
+
+	// Each result list gets its retriever's weight, shared across queries.
+	results := make(map[int][]schema.NodeWithScore, len(lists))
+	weights := make([]float64, len(lists))
+	for idx, nodes := range lists {
+		if errs[idx] != nil {
+			return nil, fmt.Errorf("retrieval for query %q failed: %w", queries[idx/numRetrievers], errs[idx])
+		}
+		results[idx] = nodes
+		weights[idx] = qfr.RetrieverWeights[idx%numRetrievers] / float64(len(queries))
+	}
+
+	fuser := &FusionRetriever{Mode: qfr.Mode, RetrieverWeights: weights}
+	fused := fuser.fuse(results)
+	if qfr.SimilarityTopK > 0 && len(fused) > qfr.SimilarityTopK {
+		fused = fused[:qfr.SimilarityTopK]
+	}
+	return fused, nil
+}
+
+// Ensure QueryFusionRetriever implements Retriever.
+var _ Retriever = (*QueryFusionRetriever)(nil)
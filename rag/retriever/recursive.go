@@ -0,0 +1,194 @@
+package retriever
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// QueryEngineIDMetadataKey is the metadata key recording which query engine
+// produced a response node in recursive retrieval.
+const QueryEngineIDMetadataKey = "query_engine_id"
+
+// QueryEngine is the query engine behaviour RecursiveRetriever needs to
+// follow a reference into a query engine. It is satisfied by the engines in
+// the queryengine package.
+type QueryEngine interface {
+	// Query executes a query and returns a response.
+	Query(ctx context.Context, query string) (*synthesizer.Response, error)
+}
+
+// RecursiveRetriever retrieves from a root retriever and follows index
+// references in the results. A retrieved node that references another ID,
+// either as an IndexNode stored with AsNode or by its own ID being
+// registered, is replaced by the results of the referenced retriever, the
+// response of the referenced query engine, or the referenced node. This
+// enables small-to-big retrieval and composing document agents under a
+// summary index.
+type RecursiveRetriever struct {
+	*BaseRetriever
+	// RootID is the ID of the retriever queried first.
+	RootID string
+	// Retrievers maps IDs to retrievers.
+	Retrievers map[string]Retriever
+	// QueryEngines maps IDs to query engines.
+	QueryEngines map[string]QueryEngine
+	// Nodes maps IDs to nodes, typically the larger parents of small chunks.
+	Nodes map[string]schema.Node
+	// MaxDepth bounds how many references are followed in a chain.
+	MaxDepth int
+}
+
+// RecursiveRetrieverOption is a functional option for RecursiveRetriever.
+type RecursiveRetrieverOption func(*RecursiveRetriever)
+
+// WithQueryEngines sets the query engines that references can point to.
+func WithQueryEngines(engines map[string]QueryEngine) RecursiveRetrieverOption {
+	return func(rr *RecursiveRetriever) {
+		rr.QueryEngines = engines
+	}
+}
+
+// WithReferencedNodes sets the nodes that references can point to.
+func WithReferencedNodes(nodes map[string]schema.Node) RecursiveRetrieverOption {
+	return func(rr *RecursiveRetriever) {
+		rr.Nodes = nodes
+	}
+}
+
+// WithMaxDepth sets how many references are followed in a chain. Defaults
+// to 5.
+func WithMaxDepth(depth int) RecursiveRetrieverOption {
+	return func(rr *RecursiveRetriever) {
+		rr.MaxDepth = depth
+	}
+}
+
+// WithRecursiveVerbose enables verbose logging.
+func WithRecursiveVerbose(verbose bool) RecursiveRetrieverOption {
+	return func(rr *RecursiveRetriever) {
+		rr.Verbose = verbose
+	}
+}
+
+// NewRecursiveRetriever creates a new RecursiveRetriever starting at the
+// retriever registered under rootID.
+func NewRecursiveRetriever(rootID string, retrievers map[string]Retriever, opts ...RecursiveRetrieverOption) *RecursiveRetriever {
+	rr := &RecursiveRetriever{
+		BaseRetriever: NewBaseRetriever(),
+		RootID:        rootID,
+		Retrievers:    retrievers,
+		QueryEngines:  make(map[string]QueryEngine),
+		Nodes:         make(map[string]schema.Node),
+		MaxDepth:      5,
+	}
+
+	for _, opt := range opts {
+		opt(rr)
+	}
+
+	return rr
+}
+
+// Retrieve retrieves from the root retriever and resolves references.
+func (rr *RecursiveRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	if _, ok := rr.Retrievers[rr.RootID]; !ok {
+		return nil, fmt.Errorf("root retriever %q not found", rr.RootID)
+	}
+
+	nodes, err := rr.retrieveFrom(ctx, query, rr.RootID, 1.0, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	// The same node can be reached through several references.
+	seen := make(map[string]bool, len(nodes))
+	var results []schema.NodeWithScore
+	for _, n := range nodes {
+		if seen[n.Node.ID] {
+			continue
+		}
+		seen[n.Node.ID] = true
+		results = append(results, n)
+	}
+	return results, nil
+}
+
+// retrieveFrom resolves the object registered under id. path holds the IDs
+// being resolved in the current chain, to stop reference cycles.
+func (rr *RecursiveRetriever) retrieveFrom(ctx context.Context, query schema.QueryBundle, id string, score float64, path map[string]bool) ([]schema.NodeWithScore, error) {
+	if path[id] {
+		return nil, fmt.Errorf("reference cycle at %q", id)
+	}
+	if len(path) > rr.MaxDepth {
+		return nil, fmt.Errorf("max recursion depth %d exceeded at %q", rr.MaxDepth, id)
+	}
+	path[id] = true
+	defer delete(path, id)
+
+	if rr.Verbose {
+		fmt.Printf("Retrieving from %s with query: %s\n", id, query.QueryString)
+	}
+
+	if ret, ok := rr.Retrievers[id]; ok {
+		nodes, err := ret.Retrieve(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("retriever %s failed: %w", id, err)
+		}
+
+		var results []schema.NodeWithScore
+		for _, n := range nodes {
+			ref, ok := rr.reference(n.Node)
+			if !ok {
+				results = append(results, n)
+				continue
+			}
+			nested, err := rr.retrieveFrom(ctx, query, ref, n.Score, path)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, nested...)
+		}
+		return results, nil
+	}
+
+	if qe, ok := rr.QueryEngines[id]; ok {
+		response, err := qe.Query(ctx, query.QueryString)
+		if err != nil {
+			return nil, fmt.Errorf("query engine %s failed: %w", id, err)
+		}
+		node := schema.NewTextNode(response.Response)
+		node.Metadata[QueryEngineIDMetadataKey] = id
+		return []schema.NodeWithScore{{Node: *node, Score: score}}, nil
+	}
+
+	if node, ok := rr.Nodes[id]; ok {
+		if ref, ok := rr.reference(node); ok && ref != id {
+			return rr.retrieveFrom(ctx, query, ref, score, path)
+		}
+		return []schema.NodeWithScore{{Node: node, Score: score}}, nil
+	}
+
+	return nil, fmt.Errorf("no retriever, query engine or node registered for %q", id)
+}
+
+// reference returns the ID a node points to. A node built with
+// IndexNode.AsNode points to its index ID; any other node points to its own
+// ID if that ID is registered with a retriever or query engine.
+func (rr *RecursiveRetriever) reference(node schema.Node) (string, bool) {
+	if idx, ok := schema.IndexNodeFromNode(node); ok {
+		return idx.IndexID, true
+	}
+	if _, ok := rr.Retrievers[node.ID]; ok {
+		return node.ID, true
+	}
+	if _, ok := rr.QueryEngines[node.ID]; ok {
+		return node.ID, true
+	}
+	return "", false
+}
+
+// Ensure RecursiveRetriever implements Retriever.
+var _ Retriever = (*RecursiveRetriever)(nil)
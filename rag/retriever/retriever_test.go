@@ -2,9 +2,19 @@ package retriever
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/querytransform"
+	"github.com/aqua777/go-llamaindex/rag/store"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/storage/docstore"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -145,6 +155,89 @@ func TestFusionRetrieverWithWeights(t *testing.T) {
 	assert.Len(t, results, 2)
 }
 
+// byQueryRetriever returns fixed results per query string and is safe for
+// concurrent use.
+type byQueryRetriever struct {
+	mu      sync.Mutex
+	results map[string][]schema.NodeWithScore
+	queries []string
+}
+
+func (r *byQueryRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries = append(r.queries, query.QueryString)
+	if query.QueryString == "fail" {
+		return nil, errors.New("backend down")
+	}
+	return r.results[query.QueryString], nil
+}
+
+func TestQueryFusionRetriever(t *testing.T) {
+	ctx := context.Background()
+	generated := "1. solar panel price\n2) \"photovoltaic module cost\"\n- solar panel price\n3. 2024 panel prices"
+
+	newRetrievers := func() (*byQueryRetriever, *byQueryRetriever) {
+		vector := &byQueryRetriever{results: map[string][]schema.NodeWithScore{
+			"how much do solar panels cost": {createTestNode("a", "A", 0.9), createTestNode("b", "B", 0.8)},
+			"solar panel price":             {createTestNode("b", "B", 0.9)},
+			"photovoltaic module cost":      {createTestNode("c", "C", 0.7), createTestNode("b", "B", 0.6)},
+		}}
+		keyword := &byQueryRetriever{results: map[string][]schema.NodeWithScore{
+			"solar panel price": {createTestNode("b", "B", 3.0), createTestNode("d", "D", 1.0)},
+		}}
+		return vector, keyword
+	}
+
+	t.Run("generates queries and fuses with RRF", func(t *testing.T) {
+		vector, keyword := newRetrievers()
+		r := NewQueryFusionRetriever([]Retriever{vector, keyword}, llm.NewMockLLM(generated), WithNumQueries(3))
+
+		queries, err := r.GenerateQueries(ctx, "how much do solar panels cost")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"how much do solar panels cost", "solar panel price", "photovoltaic module cost"}, queries)
+
+		results, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "how much do solar panels cost"})
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+		assert.Equal(t, "B", results[0].Node.Text, "the node found by most queries ranks first")
+		assert.ElementsMatch(t, queries, vector.queries)
+		assert.ElementsMatch(t, queries, keyword.queries)
+	})
+
+	t.Run("weighted relative score fusion", func(t *testing.T) {
+		vector, keyword := newRetrievers()
+		r := NewQueryFusionRetriever([]Retriever{vector, keyword}, llm.NewMockLLM(generated),
+			WithNumQueries(2),
+			WithQueryFusionMode(FusionModeRelativeScore),
+			WithQueryFusionWeights([]float64{1, 3}),
+			WithQueryFusionTopK(2),
+			WithQueryFusionMaxConcurrency(1),
+		)
+		results, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "how much do solar panels cost"})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, "B", results[0].Node.Text)
+		assert.InDelta(t, 0.125+0.375, results[0].Score, 1e-9, "retriever weights are shared across queries")
+	})
+
+	t.Run("single query skips generation", func(t *testing.T) {
+		vector, _ := newRetrievers()
+		r := NewQueryFusionRetriever([]Retriever{vector}, nil, WithNumQueries(1))
+		results, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "how much do solar panels cost"})
+		require.NoError(t, err)
+		assert.Len(t, results, 2)
+		assert.Equal(t, []string{"how much do solar panels cost"}, vector.queries)
+	})
+
+	t.Run("retrieval errors", func(t *testing.T) {
+		vector, _ := newRetrievers()
+		r := NewQueryFusionRetriever([]Retriever{vector}, llm.NewMockLLM("fail"), WithNumQueries(2))
+		_, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "q"})
+		assert.ErrorContains(t, err, "backend down")
+	})
+}
+
 func TestRouterRetriever(t *testing.T) {
 	ctx := context.Background()
 
@@ -204,6 +297,86 @@ func TestRouterRetrieverWithSingleSelector(t *testing.T) {
 	assert.Equal(t, "node1", results[0].Node.ID)
 }
 
+// blockingRetriever waits for release, ignoring its context unless
+// honorCtx is set.
+type blockingRetriever struct {
+	release  chan struct{}
+	honorCtx bool
+	nodes    []schema.NodeWithScore
+}
+
+func (b *blockingRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	if b.honorCtx {
+		select {
+		case <-b.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	} else {
+		<-b.release
+	}
+	return b.nodes, nil
+}
+
+func TestEnsembleTimeouts(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	fast := &MockRetriever{Nodes: []schema.NodeWithScore{createTestNode("f1", "fast", 0.9)}}
+	stuck := &blockingRetriever{release: release, nodes: []schema.NodeWithScore{createTestNode("s1", "stuck", 1)}}
+	polite := &blockingRetriever{release: release, honorCtx: true}
+	query := schema.QueryBundle{QueryString: "q"}
+
+	var mu sync.Mutex
+	var events []TimeoutEvent
+	timeouts := ChildTimeouts{
+		Default:   20 * time.Millisecond,
+		PerSource: map[string]time.Duration{"fast": time.Second},
+		OnTimeout: func(ctx context.Context, event TimeoutEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event)
+		},
+	}
+
+	fr := NewFusionRetriever([]Retriever{fast, stuck, polite},
+		WithRetrieverNames("fast", "stuck"), WithFusionTimeouts(timeouts))
+	start := time.Now()
+	nodes, err := fr.Retrieve(context.Background(), query)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "slow retrievers do not block the ensemble")
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "f1", nodes[0].Node.ID)
+	assert.Equal(t, []string{"stuck", "retriever_2"}, nodes[0].Node.Metadata[MissingSourcesMetadataKey])
+	assert.Nil(t, fast.Nodes[0].Node.Metadata[MissingSourcesMetadataKey], "child results are not modified")
+
+	mu.Lock()
+	require.Len(t, events, 2)
+	assert.Equal(t, TimeoutEvent{Retriever: "fusion", Source: "stuck", Timeout: 20 * time.Millisecond}, events[0])
+	events = nil
+	mu.Unlock()
+
+	rr := NewRouterRetriever([]*RetrieverTool{
+		NewRetrieverTool(fast, "fast", "fast backend"),
+		NewRetrieverTool(stuck, "slow", "slow backend"),
+	}, WithRouterTimeouts(timeouts))
+	nodes, err = rr.Retrieve(context.Background(), query)
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, []string{"slow"}, nodes[0].Node.Metadata[MissingSourcesMetadataKey])
+	mu.Lock()
+	assert.Equal(t, []TimeoutEvent{{Retriever: "router", Source: "slow", Timeout: 20 * time.Millisecond}}, events)
+	mu.Unlock()
+
+	// When nothing arrives in time the ensemble fails.
+	_, err = NewFusionRetriever([]Retriever{stuck, polite}, WithFusionTimeouts(ChildTimeouts{Default: 10 * time.Millisecond})).Retrieve(context.Background(), query)
+	assert.ErrorIs(t, err, ErrAllSourcesTimedOut)
+
+	// Errors other than timeouts still fail the ensemble.
+	broken := &MockRetriever{Err: errors.New("backend down")}
+	_, err = NewFusionRetriever([]Retriever{fast, broken}, WithFusionTimeouts(timeouts)).Retrieve(context.Background(), query)
+	assert.ErrorContains(t, err, "retriever_1: backend down")
+}
+
 func TestRetrieverTool(t *testing.T) {
 	mock := &MockRetriever{}
 	tool := NewRetrieverTool(mock, "test", "Test retriever")
@@ -310,3 +483,1228 @@ func TestSingleSelectorEmpty(t *testing.T) {
 	_, err := selector.Select(ctx, []*RetrieverTool{}, schema.QueryBundle{})
 	assert.Error(t, err)
 }
+
+func TestShardedRetrieverMergesByScore(t *testing.T) {
+	shardA := &MockRetriever{Nodes: []schema.NodeWithScore{
+		createTestNode("a1", "alpha one", 0.9),
+		createTestNode("a2", "alpha two", 0.4),
+	}}
+	shardB := &MockRetriever{Nodes: []schema.NodeWithScore{
+		createTestNode("b1", "beta one", 0.7),
+		createTestNode("a1", "alpha one", 0.5),
+	}}
+
+	sr := NewShardedRetriever([]Retriever{shardA, shardB}, WithShardedTopK(2))
+	nodes, err := sr.Retrieve(context.Background(), schema.QueryBundle{QueryString: "q"})
+	require.NoError(t, err)
+
+	require.Len(t, nodes, 2)
+	assert.Equal(t, "a1", nodes[0].Node.ID)
+	assert.Equal(t, 0.9, nodes[0].Score)
+	assert.Equal(t, "b1", nodes[1].Node.ID)
+}
+
+func TestShardedRetrieverPartialFailure(t *testing.T) {
+	healthy := &MockRetriever{Nodes: []schema.NodeWithScore{createTestNode("a1", "alpha", 0.9)}}
+	broken := &MockRetriever{Err: errors.New("shard down")}
+	query := schema.QueryBundle{QueryString: "q"}
+
+	_, err := NewShardedRetriever([]Retriever{healthy, broken}).Retrieve(context.Background(), query)
+	assert.ErrorContains(t, err, "shard 1")
+
+	nodes, err := NewShardedRetriever([]Retriever{healthy, broken}, WithAllowPartialShards(true)).Retrieve(context.Background(), query)
+	require.NoError(t, err)
+	assert.Len(t, nodes, 1)
+
+	_, err = NewShardedRetriever([]Retriever{broken, broken}, WithAllowPartialShards(true)).Retrieve(context.Background(), query)
+	assert.ErrorContains(t, err, "all 2 shards failed")
+}
+
+func TestShardedVectorStore(t *testing.T) {
+	ctx := context.Background()
+	shards := []store.VectorStore{store.NewSimpleVectorStore(), store.NewSimpleVectorStore()}
+
+	router := store.NewMetadataShardRouter("tenant")
+	router.Shards["acme"] = 0
+	router.Shards["globex"] = 1
+
+	sharded, err := store.NewShardedVectorStore(shards, store.WithShardRouter(router))
+	require.NoError(t, err)
+
+	newNode := func(id, tenant string, embedding []float64) schema.Node {
+		node := schema.NewTextNode(id)
+		node.ID = id
+		node.Metadata = map[string]interface{}{"tenant": tenant}
+		node.Embedding = embedding
+		return *node
+	}
+	ids, err := sharded.Add(ctx, []schema.Node{
+		newNode("n1", "acme", []float64{1, 0}),
+		newNode("n2", "globex", []float64{0.9, 0.1}),
+		newNode("n3", "acme", []float64{0, 1}),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"n1", "n2", "n3"}, ids)
+
+	shard0, err := shards[0].(store.NodeLister).ListNodes(ctx)
+	require.NoError(t, err)
+	assert.Len(t, shard0, 2)
+
+	results, err := sharded.Query(ctx, schema.VectorStoreQuery{Embedding: []float64{1, 0}, TopK: 2})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "n1", results[0].Node.ID)
+	assert.Equal(t, "n2", results[1].Node.ID)
+
+	// An equality filter on the routing key only searches that shard.
+	filtered, err := sharded.Query(ctx, schema.VectorStoreQuery{
+		Embedding: []float64{1, 0},
+		TopK:      5,
+		Filters:   schema.NewMetadataFilters(schema.MetadataFilter{Key: "tenant", Value: "globex", Operator: schema.FilterOperatorEq}),
+	})
+	require.NoError(t, err)
+	require.Len(t, filtered, 1)
+	assert.Equal(t, "n2", filtered[0].Node.ID)
+
+	require.NoError(t, sharded.Delete(ctx, "n2"))
+	all, err := sharded.ListNodes(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	_, err = store.NewShardedVectorStore(nil)
+	assert.Error(t, err)
+}
+
+func TestVectorStoreDeleteByFilter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+
+	newNode := func(id, kind string, ttl time.Duration) schema.Node {
+		node := schema.NewTextNode(id)
+		node.ID = id
+		node.Metadata = map[string]interface{}{"kind": kind, "tags": []string{kind, "all"}}
+		node.Embedding = []float64{1, 0}
+		if ttl != 0 {
+			store.SetTTL(node, ttl, now)
+		}
+		return *node
+	}
+	nodes := []schema.Node{
+		newNode("offer-old", "offer", -time.Hour),
+		newNode("offer-new", "offer", time.Hour),
+		newNode("ticket", "ticket", -time.Minute),
+		newNode("manual", "doc", 0),
+	}
+
+	t.Run("simple", func(t *testing.T) {
+		vs := store.NewSimpleVectorStore()
+		_, err := vs.Add(ctx, nodes)
+		require.NoError(t, err)
+
+		_, err = vs.DeleteByFilter(ctx, nil)
+		assert.ErrorIs(t, err, store.ErrEmptyDeleteFilter)
+		_, err = vs.DeleteByFilter(ctx, &schema.MetadataFilters{Nested: []*schema.MetadataFilters{{}}})
+		assert.ErrorIs(t, err, store.ErrEmptyDeleteFilter)
+
+		n, err := store.DeleteExpired(ctx, vs, now)
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		n, err = vs.DeleteByFilter(ctx, schema.NewMetadataFiltersWithCondition(schema.FilterConditionOr,
+			schema.NewMetadataFilterWithOp("tags", "doc", schema.FilterOperatorContains),
+			schema.NewMetadataFilterWithOp("kind", []string{"ticket"}, schema.FilterOperatorIn),
+		))
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		remaining, err := vs.ListNodes(ctx)
+		require.NoError(t, err)
+		require.Len(t, remaining, 1)
+		assert.Equal(t, "offer-new", remaining[0].ID)
+	})
+
+	t.Run("sharded", func(t *testing.T) {
+		shards := []store.VectorStore{store.NewSimpleVectorStore(), store.NewSimpleVectorStore()}
+		sharded, err := store.NewShardedVectorStore(shards)
+		require.NoError(t, err)
+		_, err = sharded.Add(ctx, nodes)
+		require.NoError(t, err)
+
+		n, err := sharded.DeleteByFilter(ctx, schema.NewMetadataFilters(schema.NewMetadataFilter("kind", "offer")))
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		all, err := sharded.ListNodes(ctx)
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+	})
+
+	t.Run("replicated", func(t *testing.T) {
+		primary, replica := store.NewSimpleVectorStore(), store.NewSimpleVectorStore()
+		replicated := store.NewReplicatedVectorStore(primary, []store.VectorStore{replica}, store.WithReplicateWrites(true))
+		_, err := replicated.Add(ctx, nodes)
+		require.NoError(t, err)
+
+		swept := make(chan int, 1)
+		sweeper := store.NewExpirySweeper(replicated,
+			store.WithExpiryClock(func() time.Time { return now }),
+			store.WithExpirySweepHandler(func(n int, err error) {
+				if err == nil {
+					select {
+					case swept <- n:
+					default:
+					}
+				}
+			}),
+		)
+		sweepCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sweeper.Start(sweepCtx, time.Millisecond)
+		assert.Equal(t, 2, <-swept)
+		cancel()
+
+		for _, vs := range []*store.SimpleVectorStore{primary, replica} {
+			remaining, err := vs.ListNodes(ctx)
+			require.NoError(t, err)
+			assert.Len(t, remaining, 2)
+		}
+	})
+}
+
+func TestHashShardRouterIsStable(t *testing.T) {
+	router := store.HashShardRouter{}
+	node := schema.Node{ID: "node-123"}
+	first := router.Route(node, 4)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, first, router.Route(node, 4))
+	}
+	assert.GreaterOrEqual(t, first, 0)
+	assert.Less(t, first, 4)
+}
+
+// flakyVectorStore wraps a store and fails queries while Down is set.
+type flakyVectorStore struct {
+	*store.SimpleVectorStore
+	Down    bool
+	Queries int
+}
+
+func (f *flakyVectorStore) Query(ctx context.Context, query schema.VectorStoreQuery) ([]schema.NodeWithScore, error) {
+	f.Queries++
+	if f.Down {
+		return nil, errors.New("connection refused")
+	}
+	return f.SimpleVectorStore.Query(ctx, query)
+}
+
+func (f *flakyVectorStore) HealthCheck(ctx context.Context) error {
+	if f.Down {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestReplicatedVectorStoreFailover(t *testing.T) {
+	ctx := context.Background()
+	primary := &flakyVectorStore{SimpleVectorStore: store.NewSimpleVectorStore()}
+	replicaA := &flakyVectorStore{SimpleVectorStore: store.NewSimpleVectorStore()}
+	replicaB := &flakyVectorStore{SimpleVectorStore: store.NewSimpleVectorStore()}
+
+	rs := store.NewReplicatedVectorStore(primary, []store.VectorStore{replicaA, replicaB}, store.WithReplicateWrites(true))
+
+	node := schema.NewTextNode("hello")
+	node.ID = "n1"
+	node.Embedding = []float64{1, 0}
+	_, err := rs.Add(ctx, []schema.Node{*node})
+	require.NoError(t, err)
+
+	query := schema.VectorStoreQuery{Embedding: []float64{1, 0}, TopK: 1}
+
+	// Reads are balanced across replicas.
+	for i := 0; i < 4; i++ {
+		results, err := rs.Query(ctx, query)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+	}
+	assert.Equal(t, 2, replicaA.Queries)
+	assert.Equal(t, 2, replicaB.Queries)
+	assert.Equal(t, 0, primary.Queries)
+
+	// A failing replica is skipped after its first failure.
+	replicaA.Down = true
+	for i := 0; i < 4; i++ {
+		_, err := rs.Query(ctx, query)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, replicaA.Queries)
+	assert.Equal(t, 0, primary.Queries)
+
+	status := rs.Status()
+	require.Len(t, status, 3)
+	assert.False(t, status[1].Healthy)
+	assert.Equal(t, "connection refused", status[1].LastError)
+
+	// With both replicas down the primary serves reads.
+	replicaB.Down = true
+	_, err = rs.Query(ctx, query)
+	require.NoError(t, err)
+	assert.Equal(t, 1, primary.Queries)
+
+	// Health checks restore recovered replicas.
+	replicaA.Down = false
+	replicaB.Down = false
+	for _, s := range rs.CheckHealth(ctx) {
+		assert.True(t, s.Healthy, s.Name)
+	}
+	require.NoError(t, rs.HealthCheck(ctx))
+}
+
+func TestReplicatedVectorStoreAllDown(t *testing.T) {
+	primary := &flakyVectorStore{SimpleVectorStore: store.NewSimpleVectorStore(), Down: true}
+	replica := &flakyVectorStore{SimpleVectorStore: store.NewSimpleVectorStore(), Down: true}
+	rs := store.NewReplicatedVectorStore(primary, []store.VectorStore{replica})
+
+	_, err := rs.Query(context.Background(), schema.VectorStoreQuery{Embedding: []float64{1}, TopK: 1})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "replica-0")
+	assert.Contains(t, err.Error(), "primary")
+	assert.Error(t, rs.HealthCheck(context.Background()))
+}
+
+// cancelingVectorStore cancels the caller's context while a query is in
+// flight.
+type cancelingVectorStore struct {
+	*flakyVectorStore
+	cancel context.CancelFunc
+}
+
+func (c *cancelingVectorStore) Query(ctx context.Context, query schema.VectorStoreQuery) ([]schema.NodeWithScore, error) {
+	c.Queries++
+	c.cancel()
+	return nil, ctx.Err()
+}
+
+func TestReplicatedVectorStoreCallerCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	primary := &flakyVectorStore{SimpleVectorStore: store.NewSimpleVectorStore()}
+	replica := &cancelingVectorStore{flakyVectorStore: &flakyVectorStore{SimpleVectorStore: store.NewSimpleVectorStore()}, cancel: cancel}
+	rs := store.NewReplicatedVectorStore(primary, []store.VectorStore{replica})
+
+	_, err := rs.Query(ctx, schema.VectorStoreQuery{Embedding: []float64{1}, TopK: 1})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, replica.Queries)
+	assert.Equal(t, 0, primary.Queries)
+
+	// The replica is not blamed for the caller's cancellation.
+	for _, s := range rs.Status() {
+		assert.True(t, s.Healthy, s.Name)
+		assert.Zero(t, s.ConsecutiveFailures, s.Name)
+	}
+}
+
+func TestVectorRetrieverWarmup(t *testing.T) {
+	ctx := context.Background()
+	vs := &flakyVectorStore{SimpleVectorStore: store.NewSimpleVectorStore()}
+
+	vr := NewVectorRetriever(vs, embedding.NewMockEmbeddingModel([]float64{1, 0}))
+	require.NoError(t, vr.Warmup(ctx))
+	assert.Equal(t, 1, vs.Queries)
+
+	vs.Down = true
+	assert.ErrorContains(t, vr.Warmup(ctx), "failed to query vector store")
+
+	failing := NewVectorRetriever(vs, embedding.NewMockEmbeddingModelWithError(errors.New("unauthorized")))
+	assert.ErrorContains(t, failing.Warmup(ctx), "unauthorized")
+}
+
+func TestVectorRetrieverExplain(t *testing.T) {
+	ctx := context.Background()
+	vs := store.NewSimpleVectorStore()
+	a := createTestNode("a", "first", 0).Node
+	a.Embedding = []float64{1, 0}
+	b := createTestNode("b", "second", 0).Node
+	b.Embedding = []float64{0.6, 0.8}
+	_, err := vs.Add(ctx, []schema.Node{a, b})
+	require.NoError(t, err)
+
+	vr := NewVectorRetriever(vs, embedding.NewMockEmbeddingModel([]float64{1, 0}), WithVectorExplain(true))
+	results, err := vr.Retrieve(ctx, schema.QueryBundle{QueryString: "first"})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	explanation := results[1].ScoreExplanation()
+	require.Len(t, explanation, 1)
+	assert.Equal(t, schema.ScoreComponentVectorSimilarity, explanation[0].Name)
+	assert.Equal(t, results[1].Score, explanation[0].Details["similarity"])
+	assert.Equal(t, 2.0, explanation[0].Details["rank"])
+	assert.Equal(t, "mode=default", explanation[0].Note)
+}
+
+// fixedComplexity is a ComplexityEstimator returning a set complexity.
+type fixedComplexity float64
+
+func (c fixedComplexity) EstimateComplexity(ctx context.Context, query string) (float64, error) {
+	return float64(c), nil
+}
+
+func TestAdaptiveTopK(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("heuristic estimator", func(t *testing.T) {
+		e := NewHeuristicComplexityEstimator()
+		simple, err := e.EstimateComplexity(ctx, "what port does the api use")
+		require.NoError(t, err)
+		broad, err := e.EstimateComplexity(ctx, "compare the incident trends across the last three releases and explain why latency regressed in each of them")
+		require.NoError(t, err)
+		repeated, err := e.EstimateComplexity(ctx, "port port port port port port")
+		require.NoError(t, err)
+		empty, err := e.EstimateComplexity(ctx, "")
+		require.NoError(t, err)
+
+		assert.Less(t, simple, 0.4)
+		assert.Greater(t, broad, 0.7)
+		assert.Less(t, repeated, simple)
+		assert.Equal(t, 0.0, empty)
+	})
+
+	t.Run("LLM estimator", func(t *testing.T) {
+		c, err := NewLLMComplexityEstimator(llm.NewMockLLM("4")).EstimateComplexity(ctx, "q")
+		require.NoError(t, err)
+		assert.Equal(t, 0.75, c)
+
+		c, err = NewLLMComplexityEstimator(llm.NewMockLLM("Rating: 2/5")).EstimateComplexity(ctx, "q")
+		require.NoError(t, err)
+		assert.Equal(t, 0.25, c)
+
+		c, err = NewLLMComplexityEstimator(llm.NewMockLLM("unsure"), WithComplexityFallback(fixedComplexity(0.3))).EstimateComplexity(ctx, "q")
+		require.NoError(t, err)
+		assert.Equal(t, 0.3, c)
+
+		_, err = NewLLMComplexityEstimator(llm.NewMockLLM("unsure"), WithComplexityFallback(nil)).EstimateComplexity(ctx, "q")
+		assert.Error(t, err)
+	})
+
+	t.Run("interpolates bounds", func(t *testing.T) {
+		for _, tc := range []struct {
+			complexity float64
+			topK       int
+			cutoff     float64
+		}{
+			{0, 2, 0.8},
+			{0.5, 11, 0.65},
+			{1, 20, 0.5},
+			{1.5, 20, 0.5},
+		} {
+			p := NewAdaptiveTopK(WithComplexityEstimator(fixedComplexity(tc.complexity)), WithCutoffBounds(0.8, 0.5))
+			params, err := p.Params(ctx, schema.QueryBundle{QueryString: "q"})
+			require.NoError(t, err)
+			assert.Equal(t, tc.topK, params.TopK)
+			assert.InDelta(t, tc.cutoff, params.SimilarityCutoff, 1e-9)
+		}
+	})
+
+	t.Run("vector retriever", func(t *testing.T) {
+		vs := store.NewSimpleVectorStore()
+		var nodes []schema.Node
+		for i, emb := range [][]float64{{1, 0}, {0.6, 0.8}, {0, 1}} {
+			id := string(rune('a' + i))
+			n := createTestNode(id, "text "+id, 0).Node
+			n.Embedding = emb
+			nodes = append(nodes, n)
+		}
+		_, err := vs.Add(ctx, nodes)
+		require.NoError(t, err)
+		model := embedding.NewMockEmbeddingModel([]float64{1, 0})
+
+		simple := NewAdaptiveTopK(WithComplexityEstimator(fixedComplexity(0)), WithTopKBounds(1, 3))
+		results, err := NewVectorRetriever(vs, model, WithTopKPolicy(simple)).Retrieve(ctx, schema.QueryBundle{QueryString: "q"})
+		require.NoError(t, err)
+		assert.Len(t, results, 1)
+
+		broad := NewAdaptiveTopK(WithComplexityEstimator(fixedComplexity(1)), WithTopKBounds(1, 3))
+		results, err = NewVectorRetriever(vs, model, WithTopKPolicy(broad)).Retrieve(ctx, schema.QueryBundle{QueryString: "q"})
+		require.NoError(t, err)
+		assert.Len(t, results, 3)
+
+		strict := NewAdaptiveTopK(WithComplexityEstimator(fixedComplexity(0)), WithTopKBounds(3, 3), WithCutoffBounds(0.9, 0))
+		results, err = NewVectorRetriever(vs, model, WithTopKPolicy(strict)).Retrieve(ctx, schema.QueryBundle{QueryString: "q"})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "a", results[0].Node.ID)
+	})
+
+	t.Run("BM25 retriever", func(t *testing.T) {
+		r := NewBM25Retriever([]schema.Node{
+			createTestNode("a", "pod restart loop", 0).Node,
+			createTestNode("b", "pod scheduling", 0).Node,
+			createTestNode("c", "pod logs", 0).Node,
+		}, WithBM25TopKPolicy(NewAdaptiveTopK(WithComplexityEstimator(fixedComplexity(0)), WithTopKBounds(2, 10))))
+		results, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "pod"})
+		require.NoError(t, err)
+		assert.Len(t, results, 2)
+	})
+}
+
+// wordVectorModel embeds each known word as a one-hot vector, giving one
+// vector per token.
+type wordVectorModel struct {
+	vocab []string
+}
+
+func (m *wordVectorModel) GetTextMultiVector(ctx context.Context, text string) ([][]float64, error) {
+	var vectors [][]float64
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		v := make([]float64, len(m.vocab))
+		for i, w := range m.vocab {
+			if w == word {
+				v[i] = 1
+			}
+		}
+		vectors = append(vectors, v)
+	}
+	return vectors, nil
+}
+
+func (m *wordVectorModel) GetQueryMultiVector(ctx context.Context, query string) ([][]float64, error) {
+	return m.GetTextMultiVector(ctx, query)
+}
+
+func TestLateInteractionRetriever(t *testing.T) {
+	ctx := context.Background()
+	model := &wordVectorModel{vocab: []string{"solar", "panel", "wind", "turbine", "energy"}}
+	vs := store.NewSimpleVectorStore()
+	r := NewLateInteractionRetriever(vs, model, WithLateInteractionTopK(2))
+
+	nodes := []schema.Node{
+		createTestNode("solar", "solar panel energy", 0).Node,
+		createTestNode("wind", "wind turbine energy", 0).Node,
+		createTestNode("mixed", "solar wind", 0).Node,
+	}
+	nodes[1].Metadata = map[string]interface{}{"kind": "wind"}
+
+	ids, err := r.AddNodes(ctx, nodes)
+	require.NoError(t, err)
+	assert.Len(t, ids, 3)
+
+	results, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "solar panel"})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "solar", results[0].Node.ID)
+	assert.InDelta(t, 2.0, results[0].Score, 0.0001)
+	assert.Equal(t, "mixed", results[1].Node.ID)
+	assert.InDelta(t, 1.0, results[1].Score, 0.0001)
+
+	// Filters apply to late-interaction queries.
+	results, err = r.Retrieve(ctx, schema.QueryBundle{
+		QueryString: "solar panel",
+		Filters:     schema.NewMetadataFilters(schema.NewMetadataFilter("kind", "wind")),
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "wind", results[0].Node.ID)
+
+	// Deleting a node removes its token embeddings too.
+	require.NoError(t, vs.Delete(ctx, "solar"))
+	results, err = r.Retrieve(ctx, schema.QueryBundle{QueryString: "solar panel"})
+	require.NoError(t, err)
+	assert.Equal(t, "mixed", results[0].Node.ID)
+
+	_, err = vs.AddMultiVector(ctx, nodes, nil)
+	assert.Error(t, err)
+}
+
+// pooledWordModel sums wordVectorModel token vectors into one embedding and
+// counts text embedding calls.
+type pooledWordModel struct {
+	*wordVectorModel
+	textCalls int
+}
+
+func (m *pooledWordModel) GetTextEmbedding(ctx context.Context, text string) ([]float64, error) {
+	m.textCalls++
+	return m.pool(ctx, text)
+}
+
+func (m *pooledWordModel) GetQueryEmbedding(ctx context.Context, query string) ([]float64, error) {
+	return m.pool(ctx, query)
+}
+
+func (m *pooledWordModel) pool(ctx context.Context, text string) ([]float64, error) {
+	vectors, _ := m.GetTextMultiVector(ctx, text)
+	sum := make([]float64, len(m.vocab))
+	for _, v := range vectors {
+		for i := range v {
+			sum[i] += v[i]
+		}
+	}
+	return sum, nil
+}
+
+func TestRouterSelectors(t *testing.T) {
+	ctx := context.Background()
+	tools := []*RetrieverTool{
+		NewRetrieverTool(&MockRetriever{Nodes: []schema.NodeWithScore{createTestNode("s", "solar", 1)}}, "solar", "solar panel energy"),
+		NewRetrieverTool(&MockRetriever{Nodes: []schema.NodeWithScore{createTestNode("w", "wind", 1)}}, "wind", "wind turbine energy"),
+		NewRetrieverTool(&MockRetriever{Nodes: []schema.NodeWithScore{createTestNode("o", "other", 1)}}, "other", "energy storage"),
+	}
+
+	t.Run("LLM single selector", func(t *testing.T) {
+		sel := NewLLMSingleSelector(llm.NewMockLLM(`[{"choice": 2, "reason": "about wind"}]`))
+		rr := NewRouterRetriever(tools, WithSelector(sel))
+
+		results, err := rr.Retrieve(ctx, schema.QueryBundle{QueryString: "how do turbines work?"})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "w", results[0].Node.ID)
+
+		result, err := sel.Select(ctx, tools, schema.QueryBundle{QueryString: "q"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"about wind"}, result.Reasons)
+	})
+
+	t.Run("LLM multi selector drops invalid choices", func(t *testing.T) {
+		sel := NewLLMMultiSelector(llm.NewMockLLM(`[{"choice": 1, "reason": "a"}, {"choice": 9, "reason": "b"}, {"choice": 2, "reason": "c"}, {"choice": 1, "reason": "d"}]`))
+
+		result, err := sel.Select(ctx, tools, schema.QueryBundle{QueryString: "renewables"})
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, 1}, result.Indices)
+		assert.Equal(t, []string{"a", "c"}, result.Reasons)
+	})
+
+	t.Run("LLM selector with no valid choice", func(t *testing.T) {
+		sel := NewLLMSingleSelector(llm.NewMockLLM(`[{"choice": 7, "reason": "?"}]`))
+		_, err := sel.Select(ctx, tools, schema.QueryBundle{QueryString: "q"})
+		assert.Error(t, err)
+
+		_, err = sel.Select(ctx, nil, schema.QueryBundle{QueryString: "q"})
+		assert.Error(t, err)
+	})
+
+	t.Run("Embedding selector", func(t *testing.T) {
+		model := &pooledWordModel{wordVectorModel: &wordVectorModel{vocab: []string{"solar", "panel", "wind", "turbine", "energy"}}}
+		sel := NewEmbeddingSelector(model)
+
+		result, err := sel.Select(ctx, tools, schema.QueryBundle{QueryString: "wind turbine"})
+		require.NoError(t, err)
+		assert.Equal(t, []int{1}, result.Indices)
+		assert.Contains(t, result.Reasons[0], "similarity")
+
+		_, err = sel.Select(ctx, tools, schema.QueryBundle{QueryString: "solar"})
+		require.NoError(t, err)
+		assert.Equal(t, 3, model.textCalls, "description embeddings are cached")
+
+		sel = NewEmbeddingSelector(model, WithEmbeddingSelectorTopK(3), WithEmbeddingSelectorThreshold(0.5))
+		result, err = sel.Select(ctx, tools, schema.QueryBundle{QueryString: "solar energy"})
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, 2}, result.Indices)
+	})
+}
+
+// imageTextModel embeds images by URL and text by the word it contains, in
+// a shared space.
+type imageTextModel struct {
+	images map[string][]float64
+	words  map[string][]float64
+}
+
+func (m *imageTextModel) GetTextEmbedding(ctx context.Context, text string) ([]float64, error) {
+	for word, v := range m.words {
+		if strings.Contains(text, word) {
+			return v, nil
+		}
+	}
+	return []float64{1, 1, 1}, nil
+}
+
+func (m *imageTextModel) GetQueryEmbedding(ctx context.Context, query string) ([]float64, error) {
+	return m.GetTextEmbedding(ctx, query)
+}
+
+func (m *imageTextModel) GetImageEmbedding(ctx context.Context, image embedding.ImageType) ([]float64, error) {
+	v, ok := m.images[image.URL]
+	if !ok {
+		return nil, errors.New("unknown image")
+	}
+	return v, nil
+}
+
+func (m *imageTextModel) SupportsMultiModal() bool { return true }
+
+func TestImageRetriever(t *testing.T) {
+	ctx := context.Background()
+	model := &imageTextModel{
+		images: map[string][]float64{
+			"https://img/cat.jpg": {1, 0, 0},
+			"https://img/dog.jpg": {0, 1, 0},
+			"https://img/car.jpg": {0, 0, 1},
+		},
+		words: map[string][]float64{
+			"cat": {0.9, 0.1, 0},
+			"dog": {0.1, 0.9, 0},
+		},
+	}
+	vs := store.NewSimpleVectorStore()
+	r := NewImageRetriever(vs, model, WithImageTopK(1))
+
+	var images []*schema.ImageNode
+	for _, name := range []string{"cat", "dog", "car"} {
+		images = append(images, schema.NewImageNodeFromURL("https://img/"+name+".jpg", "image/jpeg"))
+	}
+	ids, err := r.AddImages(ctx, images)
+	require.NoError(t, err)
+	assert.Len(t, ids, 3)
+
+	// A text node in the same store is never returned.
+	text := schema.NewTextNode("a cat")
+	text.Embedding = []float64{1, 0, 0}
+	_, err = vs.Add(ctx, []schema.Node{*text})
+	require.NoError(t, err)
+
+	results, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "photo of a dog"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	img, ok := schema.ImageNodeFromNode(results[0].Node)
+	require.True(t, ok)
+	assert.Equal(t, "https://img/dog.jpg", img.ImageURL)
+
+	r.TopK = 2
+	results, err = r.Retrieve(ctx, schema.QueryBundle{QueryString: "cat"})
+	require.NoError(t, err)
+	require.Len(t, results, 1, "the matching text node is filtered out")
+	assert.Equal(t, images[0].ID, results[0].Node.ID)
+
+	_, err = r.AddImages(ctx, []*schema.ImageNode{schema.NewImageNode()})
+	assert.Error(t, err)
+}
+
+func TestBM25Retriever(t *testing.T) {
+	ctx := context.Background()
+	newNode := func(id, text string) schema.Node {
+		n := schema.NewTextNode(text)
+		n.ID = id
+		return *n
+	}
+	nodes := []schema.Node{
+		newNode("ml", "Machine learning algorithms learn patterns from data."),
+		newNode("db", "Database systems use indexing for query optimization."),
+		newNode("nlp", "Natural language processing is machine understanding of language."),
+	}
+	ids := func(results []schema.NodeWithScore) []string {
+		out := make([]string, len(results))
+		for i, r := range results {
+			out[i] = r.Node.ID
+		}
+		return out
+	}
+
+	t.Run("ranks by term statistics", func(t *testing.T) {
+		r := NewBM25Retriever(nodes, WithBM25TopK(2))
+		results, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "machine learning"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ml", "nlp"}, ids(results))
+		assert.Greater(t, results[0].Score, results[1].Score)
+
+		results, err = r.Retrieve(ctx, schema.QueryBundle{QueryString: "the weather"})
+		require.NoError(t, err)
+		assert.Empty(t, results)
+	})
+
+	t.Run("tokenization options", func(t *testing.T) {
+		stem := func(s string) string { return strings.TrimSuffix(s, "s") }
+		r := NewBM25Retriever(nodes, WithBM25Stemmer(stem), WithBM25Stopwords())
+		assert.Equal(t, 1, r.DocFreq("algorithm"))
+		assert.Equal(t, 1, r.DocFreq("is"))
+		assert.Equal(t, 0, NewBM25Retriever(nodes).DocFreq("is"))
+
+		results, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "system"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"db"}, ids(results))
+	})
+
+	t.Run("incremental updates", func(t *testing.T) {
+		r := NewBM25Retriever(nodes)
+		r.AddNodes([]schema.Node{newNode("db", "Vector databases store embeddings.")})
+		assert.Equal(t, 3, r.Len())
+		assert.Equal(t, 0, r.DocFreq("optimization"))
+
+		r.DeleteNodes("ml", "missing")
+		assert.Equal(t, 2, r.Len())
+		results, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "machine learning"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"nlp"}, ids(results))
+	})
+
+	t.Run("persists to docstore", func(t *testing.T) {
+		ds := docstore.NewSimpleDocumentStore()
+		r := NewBM25Retriever(nodes, WithBM25DocStore(ds))
+		require.NoError(t, r.Persist(ctx))
+		r.DeleteNodes("db")
+		require.NoError(t, r.Persist(ctx))
+
+		exists, err := ds.DocumentExists(ctx, "db")
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		restored, err := NewBM25RetrieverFromDocStore(ctx, ds)
+		require.NoError(t, err)
+		assert.Equal(t, 2, restored.Len())
+		want, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "machine language"})
+		require.NoError(t, err)
+		got, err := restored.Retrieve(ctx, schema.QueryBundle{QueryString: "machine language"})
+		require.NoError(t, err)
+		assert.Equal(t, ids(want), ids(got))
+		assert.InDelta(t, want[0].Score, got[0].Score, 1e-9)
+	})
+
+	t.Run("indexes docstore without statistics", func(t *testing.T) {
+		ds := docstore.NewSimpleDocumentStore()
+		docs := make([]schema.BaseNode, len(nodes))
+		for i := range nodes {
+			docs[i] = &nodes[i]
+		}
+		require.NoError(t, ds.AddDocuments(ctx, docs, true))
+
+		r, err := NewBM25RetrieverFromDocStore(ctx, ds)
+		require.NoError(t, err)
+		assert.Equal(t, 3, r.Len())
+	})
+
+	t.Run("persist requires docstore", func(t *testing.T) {
+		assert.Error(t, NewBM25Retriever(nodes).Persist(ctx))
+	})
+
+	t.Run("explains scores", func(t *testing.T) {
+		r := NewBM25Retriever(nodes, WithBM25Explain(true))
+		results, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "machine learning"})
+		require.NoError(t, err)
+		require.NotEmpty(t, results)
+
+		explanation := results[0].ScoreExplanation()
+		require.Len(t, explanation, 1)
+		assert.Equal(t, schema.ScoreComponentBM25, explanation[0].Name)
+		assert.Len(t, explanation[0].Details, 2)
+		assert.InDelta(t, results[0].Score, explanation[0].Details["machine"]+explanation[0].Details["learning"], 1e-9)
+		assert.NotContains(t, nodes[0].Metadata, schema.ScoreExplanationMetadataKey)
+
+		results, err = NewBM25Retriever(nodes).Retrieve(ctx, schema.QueryBundle{QueryString: "machine learning"})
+		require.NoError(t, err)
+		assert.Nil(t, results[0].ScoreExplanation())
+	})
+}
+
+func TestHybridRetriever(t *testing.T) {
+	ctx := context.Background()
+	scored := func(id string, score float64) schema.NodeWithScore {
+		n := schema.NewTextNode("text " + id)
+		n.ID = id
+		return schema.NodeWithScore{Node: *n, Score: score}
+	}
+	dense := &MockRetriever{Nodes: []schema.NodeWithScore{scored("a", 0.9), scored("b", 0.8), scored("c", 0.1)}}
+	sparse := &MockRetriever{Nodes: []schema.NodeWithScore{scored("c", 12), scored("b", 6)}}
+	ids := func(results []schema.NodeWithScore) []string {
+		out := make([]string, len(results))
+		for i, r := range results {
+			out[i] = r.Node.ID
+		}
+		return out
+	}
+
+	t.Run("reciprocal rank fusion", func(t *testing.T) {
+		results, err := NewHybridRetriever(dense, sparse).Retrieve(ctx, schema.QueryBundle{QueryString: "q"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"c", "b", "a"}, ids(results))
+		assert.InDelta(t, 0.5/63+0.5/61, results[0].Score, 1e-12)
+		assert.InDelta(t, 0.5/62+0.5/62, results[1].Score, 1e-12)
+		assert.Equal(t, 0.1, results[0].Node.Metadata[HybridDenseScoreMetadataKey])
+		assert.Equal(t, 12.0, results[0].Node.Metadata[HybridSparseScoreMetadataKey])
+		assert.Nil(t, results[2].Node.Metadata[HybridSparseScoreMetadataKey])
+		assert.Nil(t, dense.Nodes[1].Node.Metadata[HybridDenseScoreMetadataKey])
+	})
+
+	t.Run("alpha favors dense", func(t *testing.T) {
+		r := NewHybridRetriever(dense, sparse, WithHybridAlpha(1), WithHybridTopK(2))
+		results, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "q"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, ids(results))
+	})
+
+	t.Run("relative score", func(t *testing.T) {
+		r := NewHybridRetriever(dense, sparse, WithHybridMode(FusionModeRelativeScore))
+		results, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "q"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "c", "b"}, ids(results))
+		assert.InDelta(t, 0.5, results[1].Score, 1e-9)
+		assert.InDelta(t, 0.5*0.875, results[2].Score, 1e-9)
+	})
+
+	t.Run("weighted raw score", func(t *testing.T) {
+		r := NewHybridRetriever(dense, sparse, WithHybridMode(FusionModeWeightedScore), WithHybridAlpha(0.75))
+		results, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "q"})
+		require.NoError(t, err)
+		assert.Equal(t, "c", results[0].Node.ID)
+		assert.InDelta(t, 0.75*0.1+0.25*12, results[0].Score, 1e-9)
+	})
+
+	t.Run("simple keeps max", func(t *testing.T) {
+		r := NewHybridRetriever(dense, sparse, WithHybridMode(FusionModeSimple))
+		results, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "q"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"c", "b", "a"}, ids(results))
+		assert.Equal(t, 12.0, results[0].Score)
+	})
+
+	t.Run("retriever error", func(t *testing.T) {
+		_, err := NewHybridRetriever(dense, &MockRetriever{Err: errors.New("down")}).Retrieve(ctx, schema.QueryBundle{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "sparse")
+	})
+}
+
+func TestAnalyzer(t *testing.T) {
+	t.Run("languages and stemming", func(t *testing.T) {
+		a := NewAnalyzer(WithAnalyzerLanguages("de"), WithAnalyzerStemmer(EnglishPluralStemmer))
+		assert.Equal(t, []string{"größe", "datei"}, a.Analyze("Die Größe der Datei"))
+
+		en := NewAnalyzer(WithAnalyzerStemmer(EnglishPluralStemmer))
+		assert.Equal(t, []string{"policy", "node", "glass", "status"}, en.Analyze("The policies, nodes, glass and status"))
+
+		assert.Nil(t, StopwordsForLanguage("xx"))
+		assert.Contains(t, StopwordsForLanguage("French"), "les")
+	})
+
+	t.Run("synonyms", func(t *testing.T) {
+		a := NewAnalyzer(WithAnalyzerSynonyms(map[string][]string{
+			"kubernetes": {"k8s", "kube"},
+			"ml":         {"machine learning"},
+		}))
+		assert.Equal(t, []string{"k8s", "kubernetes", "pods"}, a.Analyze("K8s pods"))
+		assert.Equal(t, []string{"kubernetes", "pods"}, a.Analyze("Kubernetes pods"))
+		assert.Equal(t, []string{"machine", "learning", "ml", "models"}, a.Analyze("machine learning models"))
+		assert.Equal(t, []string{"machine", "parts"}, a.Analyze("machine parts"))
+	})
+
+	t.Run("bm25", func(t *testing.T) {
+		ctx := context.Background()
+		newNode := func(id, text string) schema.Node {
+			n := schema.NewTextNode(text)
+			n.ID = id
+			return *n
+		}
+		nodes := []schema.Node{
+			newNode("k8s", "Scaling deployments on Kubernetes."),
+			newNode("vm", "Scaling virtual machines."),
+		}
+		analyzer := NewAnalyzer(WithAnalyzerSynonyms(map[string][]string{"kubernetes": {"k8s"}}))
+
+		plain := NewBM25Retriever(nodes)
+		results, err := plain.Retrieve(ctx, schema.QueryBundle{QueryString: "k8s"})
+		require.NoError(t, err)
+		assert.Empty(t, results)
+
+		r := NewBM25Retriever(nodes, WithBM25Analyzer(analyzer))
+		results, err = r.Retrieve(ctx, schema.QueryBundle{QueryString: "k8s"})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "k8s", results[0].Node.ID)
+		assert.Equal(t, 1, r.DocFreq("kubernetes"))
+	})
+}
+
+// countingRetriever counts the calls to a wrapped retriever.
+type countingRetriever struct {
+	Retriever
+	calls int
+}
+
+func (c *countingRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	c.calls++
+	return c.Retriever.Retrieve(ctx, query)
+}
+
+// queryRecordingRetriever records the queries it receives.
+type queryRecordingRetriever struct {
+	MockRetriever
+	queries []string
+}
+
+func (r *queryRecordingRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	r.queries = append(r.queries, query.QueryString)
+	return r.MockRetriever.Retrieve(ctx, query)
+}
+
+func TestTransformRetriever(t *testing.T) {
+	ctx := context.Background()
+	inner := &queryRecordingRetriever{MockRetriever: MockRetriever{Nodes: []schema.NodeWithScore{createTestNode("a", "A", 0.9)}}}
+	hyde := querytransform.NewHyDETransform(llm.NewMockLLM("a passage"), querytransform.WithHyDEIncludeOriginal(false))
+	exclaim := querytransform.TransformFunc(func(ctx context.Context, q schema.QueryBundle) (schema.QueryBundle, error) {
+		q.QueryString += "!"
+		return q, nil
+	})
+
+	r := NewTransformRetriever(inner, WithQueryTransforms(hyde), WithQueryTransforms(exclaim))
+	results, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "question"})
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, []string{"a passage!"}, inner.queries)
+
+	inner.queries = nil
+	_, err = NewTransformRetriever(inner).Retrieve(ctx, schema.QueryBundle{QueryString: "question"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"question"}, inner.queries)
+}
+
+func TestPaginatedRetriever(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockRetriever{Nodes: []schema.NodeWithScore{
+		createTestNode("a", "A", 0.9),
+		createTestNode("b", "B", 0.8),
+		createTestNode("a", "A again", 0.7),
+		createTestNode("c", "C", 0.6),
+		createTestNode("d", "D", 0.5),
+		createTestNode("e", "E", 0.4),
+	}}
+	counting := &countingRetriever{Retriever: mock}
+	r := NewPaginatedRetriever(counting)
+	query := schema.QueryBundle{QueryString: "letters"}
+	ids := func(page *Page) []string {
+		var out []string
+		for _, n := range page.Nodes {
+			out = append(out, n.Node.ID)
+		}
+		return out
+	}
+
+	first, err := r.RetrieveWithCursor(ctx, query, "", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, ids(first))
+	assert.Equal(t, 5, first.Total)
+	require.True(t, first.HasMore())
+
+	// The index changing between pages does not shift them.
+	mock.Nodes = mock.Nodes[:1]
+
+	second, err := r.RetrieveWithCursor(ctx, query, first.NextCursor, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "d"}, ids(second))
+	assert.Equal(t, 2, second.Offset)
+
+	last, err := r.RetrieveWithCursor(ctx, query, second.NextCursor, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e"}, ids(last))
+	assert.False(t, last.HasMore())
+	assert.Equal(t, 1, counting.calls)
+
+	// Cursors can be replayed.
+	again, err := r.RetrieveWithCursor(ctx, query, first.NextCursor, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "d", "e"}, ids(again))
+
+	_, err = r.RetrieveWithCursor(ctx, schema.QueryBundle{QueryString: "other"}, first.NextCursor, 2)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, err = r.RetrieveWithCursor(ctx, query, "not-a-cursor", 2)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	t.Run("expiry", func(t *testing.T) {
+		pager := NewResultPager(WithPagerTTL(time.Minute), WithPagerMaxSessions(2))
+		now := time.Now()
+		pager.now = func() time.Time { return now }
+		r := &PaginatedRetriever{
+			Retriever: &MockRetriever{Nodes: []schema.NodeWithScore{createTestNode("x", "X", 1), createTestNode("y", "Y", 1)}},
+			Pager:     pager,
+		}
+
+		page, err := r.RetrieveWithCursor(ctx, query, "", 1)
+		require.NoError(t, err)
+
+		now = now.Add(2 * time.Minute)
+		_, err = r.RetrieveWithCursor(ctx, query, page.NextCursor, 1)
+		assert.ErrorIs(t, err, ErrCursorExpired)
+
+		for i := 0; i < 3; i++ {
+			_, err := r.RetrieveWithCursor(ctx, query, "", 1)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, pager.Len())
+	})
+}
+
+// queryEngineFunc adapts a function to the QueryEngine interface.
+type queryEngineFunc func(ctx context.Context, query string) (*synthesizer.Response, error)
+
+func (f queryEngineFunc) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	return f(ctx, query)
+}
+
+func TestRecursiveRetriever(t *testing.T) {
+	ctx := context.Background()
+	query := schema.QueryBundle{QueryString: "revenue in 2023"}
+
+	indexNode := func(id, text, ref string, score float64) schema.NodeWithScore {
+		idx := schema.NewIndexNode(ref)
+		idx.ID = id
+		idx.Text = text
+		return schema.NodeWithScore{Node: idx.AsNode(), Score: score}
+	}
+
+	t.Run("follows references to retrievers and nodes", func(t *testing.T) {
+		parent := schema.NewTextNode("full parent section about revenue")
+		parent.ID = "parent-1"
+
+		root := &MockRetriever{Nodes: []schema.NodeWithScore{
+			indexNode("summary-table", "summary of the revenue table", "table", 0.9),
+			indexNode("chunk-1", "small chunk", "parent-1", 0.8),
+			createTestNode("plain", "plain node", 0.7),
+		}}
+		table := &MockRetriever{Nodes: []schema.NodeWithScore{
+			createTestNode("row-1", "2023 revenue: 10M", 0.95),
+		}}
+
+		rr := NewRecursiveRetriever("root", map[string]Retriever{"root": root, "table": table},
+			WithReferencedNodes(map[string]schema.Node{"parent-1": *parent}))
+
+		results, err := rr.Retrieve(ctx, query)
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		assert.Equal(t, "row-1", results[0].Node.ID)
+		assert.Equal(t, 0.95, results[0].Score)
+		assert.Equal(t, "parent-1", results[1].Node.ID)
+		assert.Equal(t, 0.8, results[1].Score, "referenced nodes take the score of the referencing node")
+		assert.Equal(t, "plain", results[2].Node.ID)
+	})
+
+	t.Run("follows references to query engines", func(t *testing.T) {
+		root := &MockRetriever{Nodes: []schema.NodeWithScore{
+			indexNode("doc-summary", "summary of the annual report", "report-agent", 0.6),
+		}}
+		var asked []string
+		agent := queryEngineFunc(func(ctx context.Context, q string) (*synthesizer.Response, error) {
+			asked = append(asked, q)
+			return synthesizer.NewResponse("Revenue was 10M.", nil), nil
+		})
+
+		rr := NewRecursiveRetriever("root", map[string]Retriever{"root": root},
+			WithQueryEngines(map[string]QueryEngine{"report-agent": agent}))
+
+		results, err := rr.Retrieve(ctx, query)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "Revenue was 10M.", results[0].Node.Text)
+		assert.Equal(t, "report-agent", results[0].Node.Metadata[QueryEngineIDMetadataKey])
+		assert.Equal(t, 0.6, results[0].Score)
+		assert.Equal(t, []string{"revenue in 2023"}, asked)
+	})
+
+	t.Run("deduplicates nodes reached twice", func(t *testing.T) {
+		root := &MockRetriever{Nodes: []schema.NodeWithScore{
+			indexNode("a", "a", "shared", 0.9),
+			indexNode("b", "b", "shared", 0.8),
+		}}
+		shared := &MockRetriever{Nodes: []schema.NodeWithScore{createTestNode("leaf", "leaf", 0.5)}}
+
+		rr := NewRecursiveRetriever("root", map[string]Retriever{"root": root, "shared": shared})
+		results, err := rr.Retrieve(ctx, query)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "leaf", results[0].Node.ID)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := NewRecursiveRetriever("missing", map[string]Retriever{}).Retrieve(ctx, query)
+		assert.Error(t, err)
+
+		dangling := &MockRetriever{Nodes: []schema.NodeWithScore{indexNode("x", "x", "nowhere", 1)}}
+		_, err = NewRecursiveRetriever("root", map[string]Retriever{"root": dangling}).Retrieve(ctx, query)
+		assert.ErrorContains(t, err, "nowhere")
+
+		loop := &MockRetriever{Nodes: []schema.NodeWithScore{indexNode("self", "self", "root", 1)}}
+		_, err = NewRecursiveRetriever("root", map[string]Retriever{"root": loop}).Retrieve(ctx, query)
+		assert.ErrorContains(t, err, "cycle")
+
+		failing := &MockRetriever{Err: errors.New("boom")}
+		_, err = NewRecursiveRetriever("root", map[string]Retriever{"root": failing}).Retrieve(ctx, query)
+		assert.ErrorContains(t, err, "boom")
+	})
+}
+
+func TestVectorIndexAutoRetriever(t *testing.T) {
+	ctx := context.Background()
+	vs := store.NewSimpleVectorStore()
+	var nodes []schema.Node
+	for _, d := range []struct {
+		id, category string
+		year         int
+	}{
+		{"policy-2024", "compliance", 2024},
+		{"policy-2023", "compliance", 2023},
+		{"launch-2024", "marketing", 2024},
+		{"audit-2024", "compliance", 2024},
+	} {
+		n := createTestNode(d.id, d.id, 0).Node
+		n.Metadata = map[string]interface{}{"category": d.category, "year": d.year}
+		n.Embedding = []float64{1, 0}
+		nodes = append(nodes, n)
+	}
+	_, err := vs.Add(ctx, nodes)
+	require.NoError(t, err)
+
+	info := schema.VectorStoreInfo{
+		ContentInfo: "Company documents",
+		MetadataInfo: []schema.MetadataInfo{
+			{Name: "category", Type: "string", Description: "Document category"},
+			{Name: "year", Type: "int", Description: "Year of publication"},
+		},
+	}
+	embed := embedding.NewMockEmbeddingModel([]float64{1, 0})
+	ids := func(results []schema.NodeWithScore) []string {
+		var out []string
+		for _, r := range results {
+			out = append(out, r.Node.ID)
+		}
+		return out
+	}
+
+	t.Run("infers filters", func(t *testing.T) {
+		spec := "```json\n" + `{"query": "docs", "filters": [{"key": "year", "operator": "==", "value": 2024}, {"key": "category", "operator": "eq", "value": "compliance"}], "top_k": null}` + "\n```"
+		r := NewVectorIndexAutoRetriever(vs, embed, llm.NewMockLLM(spec), info)
+
+		results, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "2024 compliance docs"})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"policy-2024", "audit-2024"}, ids(results))
+	})
+
+	t.Run("spec", func(t *testing.T) {
+		spec := `{"query": "", "filters": [{"key": "year", "operator": ">", "value": 2023}, {"key": "author", "operator": "==", "value": "x"}, {"key": "category", "operator": "between", "value": 1}], "condition": "or", "top_k": 50}`
+		r := NewVectorIndexAutoRetriever(vs, embed, llm.NewMockLLM(spec), info, WithAutoRetrieverMaxTopK(3))
+
+		s, err := r.GenerateSpec(ctx, "recent docs")
+		require.NoError(t, err)
+		assert.Equal(t, "recent docs", s.Query, "an empty query falls back to the original")
+		assert.Equal(t, 3, s.TopK, "top-k is capped")
+		require.NotNil(t, s.Filters)
+		assert.Equal(t, schema.FilterConditionOr, s.Filters.Condition)
+		assert.Equal(t, []schema.MetadataFilter{{Key: "year", Operator: schema.FilterOperatorGt, Value: float64(2023)}}, s.Filters.Filters,
+			"unknown fields and operators are dropped")
+
+		results, err := r.Retrieve(ctx, schema.QueryBundle{QueryString: "recent docs"})
+		require.NoError(t, err)
+		assert.Len(t, results, 3)
+	})
+
+	t.Run("extra and query filters", func(t *testing.T) {
+		spec := `{"query": "docs", "filters": [{"key": "year", "operator": "==", "value": 2024}]}`
+		r := NewVectorIndexAutoRetriever(vs, embed, llm.NewMockLLM(spec), info,
+			WithAutoRetrieverExtraFilters(schema.MustParseMetadataFilters(`category == "compliance"`)))
+
+		results, err := r.Retrieve(ctx, schema.QueryBundle{
+			QueryString: "2024 docs",
+			Filters:     schema.MustParseMetadataFilters(`category != "marketing"`),
+		})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"policy-2024", "audit-2024"}, ids(results))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := NewVectorIndexAutoRetriever(vs, embed, llm.NewMockLLM("no idea"), info).Retrieve(ctx, schema.QueryBundle{QueryString: "q"})
+		assert.Error(t, err)
+		_, err = NewVectorIndexAutoRetriever(vs, embed, llm.NewMockLLMWithError(errors.New("down")), info).Retrieve(ctx, schema.QueryBundle{QueryString: "q"})
+		assert.ErrorContains(t, err, "down")
+	})
+}
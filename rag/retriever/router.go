@@ -75,6 +75,9 @@ type RouterRetriever struct {
 	Selector Selector
 	// Tools are the available retriever tools.
 	Tools []*RetrieverTool
+	// Timeouts sets per-tool deadlines, keyed by tool name. When nil,
+	// selected tools run one after another without deadlines.
+	Timeouts *ChildTimeouts
 }
 
 // RouterRetrieverOption is a functional option for RouterRetriever.
@@ -87,6 +90,14 @@ func WithSelector(selector Selector) RouterRetrieverOption {
 	}
 }
 
+// WithRouterTimeouts runs the selected tools concurrently with per-tool
+// deadlines, returning the results that arrive in time.
+func WithRouterTimeouts(timeouts ChildTimeouts) RouterRetrieverOption {
+	return func(rr *RouterRetriever) {
+		rr.Timeouts = &timeouts
+	}
+}
+
 // NewRouterRetriever creates a new RouterRetriever.
 func NewRouterRetriever(tools []*RetrieverTool, opts ...RouterRetrieverOption) *RouterRetriever {
 	rr := &RouterRetriever{
@@ -103,6 +114,8 @@ func NewRouterRetriever(tools []*RetrieverTool, opts ...RouterRetrieverOption) *
 }
 
 // Retrieve routes the query to selected retrievers and combines results.
+// With timeouts, tools that miss their deadline are left out and listed
+// under MissingSourcesMetadataKey in the results' metadata.
 func (rr *RouterRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
 	if len(rr.Tools) == 0 {
 		return nil, errors.New("no retrievers configured")
@@ -119,21 +132,23 @@ func (rr *RouterRetriever) Retrieve(ctx context.Context, query schema.QueryBundl
 	}
 
 	// Retrieve from selected retrievers
-	allResults := make(map[string]schema.NodeWithScore)
-
+	var children []namedRetriever
 	for _, idx := range result.Indices {
 		if idx < 0 || idx >= len(rr.Tools) {
 			continue
 		}
-
 		tool := rr.Tools[idx]
-		nodes, err := tool.Retriever.Retrieve(ctx, query)
-		if err != nil {
-			return nil, err
-		}
+		children = append(children, namedRetriever{name: tool.Name, retriever: tool.Retriever})
+	}
+	gathered, missing, err := gatherResults(ctx, "router", children, query, rr.Timeouts)
+	if err != nil {
+		return nil, err
+	}
 
-		// Deduplicate by node ID
-		for _, node := range nodes {
+	// Deduplicate by node ID
+	allResults := make(map[string]schema.NodeWithScore)
+	for _, res := range gathered {
+		for _, node := range res.nodes {
 			allResults[node.Node.ID] = node
 		}
 	}
@@ -144,7 +159,7 @@ func (rr *RouterRetriever) Retrieve(ctx context.Context, query schema.QueryBundl
 		nodes = append(nodes, node)
 	}
 
-	return nodes, nil
+	return annotateMissing(nodes, missing), nil
 }
 
 // Ensure RouterRetriever implements Retriever.
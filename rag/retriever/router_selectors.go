@@ -0,0 +1,111 @@
+package retriever
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aqua777/go-llamaindex/embedding"
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/selector"
+)
+
+// LLMSelector adapts a selector.Selector, such as selector.LLMSingleSelector
+// or selector.LLMMultiSelector, to route between retriever tools. The
+// tool names and descriptions are offered as choices; selections outside
+// the tool range are dropped.
+type LLMSelector struct {
+	// Selector makes the choice.
+	Selector selector.Selector
+}
+
+// NewLLMSelector creates an LLMSelector from any selector.Selector.
+func NewLLMSelector(s selector.Selector) *LLMSelector {
+	return &LLMSelector{Selector: s}
+}
+
+// NewLLMSingleSelector creates an LLMSelector that asks the LLM for the
+// single best retriever.
+func NewLLMSingleSelector(llmModel llm.LLM, opts ...selector.LLMSingleSelectorOption) *LLMSelector {
+	return NewLLMSelector(selector.NewLLMSingleSelector(llmModel, opts...))
+}
+
+// NewLLMMultiSelector creates an LLMSelector that lets the LLM pick several
+// retrievers.
+func NewLLMMultiSelector(llmModel llm.LLM, opts ...selector.LLMMultiSelectorOption) *LLMSelector {
+	return NewLLMSelector(selector.NewLLMMultiSelector(llmModel, opts...))
+}
+
+// Select asks the wrapped selector to choose among the tools.
+func (s *LLMSelector) Select(ctx context.Context, tools []*RetrieverTool, query schema.QueryBundle) (*SelectorResult, error) {
+	return selectTools(ctx, s.Selector, tools, query)
+}
+
+// EmbeddingSelector adapts selector.EmbeddingSelector to route between
+// retriever tools by the similarity between the query and each tool's
+// description.
+type EmbeddingSelector struct {
+	*selector.EmbeddingSelector
+}
+
+// EmbeddingSelectorOption is a functional option for EmbeddingSelector.
+type EmbeddingSelectorOption = selector.EmbeddingSelectorOption
+
+// WithEmbeddingSelectorTopK sets the maximum number of retrievers
+// selected. Defaults to 1.
+func WithEmbeddingSelectorTopK(topK int) EmbeddingSelectorOption {
+	return selector.WithEmbeddingSelectorTopK(topK)
+}
+
+// WithEmbeddingSelectorThreshold sets the minimum similarity for selection.
+func WithEmbeddingSelectorThreshold(threshold float64) EmbeddingSelectorOption {
+	return selector.WithEmbeddingSelectorThreshold(threshold)
+}
+
+// NewEmbeddingSelector creates a new EmbeddingSelector.
+func NewEmbeddingSelector(embeddingModel embedding.EmbeddingModel, opts ...EmbeddingSelectorOption) *EmbeddingSelector {
+	return &EmbeddingSelector{EmbeddingSelector: selector.NewEmbeddingSelector(embeddingModel, opts...)}
+}
+
+// Select picks the tools whose descriptions are most similar to the query.
+func (s *EmbeddingSelector) Select(ctx context.Context, tools []*RetrieverTool, query schema.QueryBundle) (*SelectorResult, error) {
+	return selectTools(ctx, s.EmbeddingSelector, tools, query)
+}
+
+// selectTools offers the tools' names and descriptions to sel and keeps
+// its valid selections.
+func selectTools(ctx context.Context, sel selector.Selector, tools []*RetrieverTool, query schema.QueryBundle) (*SelectorResult, error) {
+	if len(tools) == 0 {
+		return nil, errors.New("no retrievers available")
+	}
+
+	choices := make([]selector.ToolMetadata, len(tools))
+	for i, tool := range tools {
+		choices[i] = selector.ToolMetadata{
+			Name:        tool.Name,
+			Description: tool.Description,
+		}
+	}
+
+	result, err := sel.Select(ctx, choices, query.QueryString)
+	if err != nil {
+		return nil, fmt.Errorf("selector %s failed: %w", sel.Name(), err)
+	}
+
+	out := &SelectorResult{}
+	for _, s := range result.Valid(len(tools)) {
+		out.Indices = append(out.Indices, s.Index)
+		out.Reasons = append(out.Reasons, s.Reason)
+	}
+	if len(out.Indices) == 0 {
+		return nil, errors.New("selector chose no valid retriever")
+	}
+	return out, nil
+}
+
+// Ensure the selectors implement Selector.
+var (
+	_ Selector = (*LLMSelector)(nil)
+	_ Selector = (*EmbeddingSelector)(nil)
+)
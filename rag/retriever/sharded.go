@@ -0,0 +1,100 @@
+package retriever
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/rag/store"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// ShardedRetriever queries several retrievers, one per shard, in parallel
+// and merges their results by score. Unlike FusionRetriever it assumes the
+// shards hold disjoint partitions of one corpus with comparable scores, so
+// scores are kept as-is rather than re-weighted.
+type ShardedRetriever struct {
+	*BaseRetriever
+	// Shards are the per-shard retrievers.
+	Shards []Retriever
+	// SimilarityTopK is the number of merged results to return.
+	// Zero returns all results.
+	SimilarityTopK int
+	// AllowPartial returns results from the healthy shards when some fail,
+	// instead of failing the whole query.
+	AllowPartial bool
+}
+
+// ShardedRetrieverOption is a functional option for ShardedRetriever.
+type ShardedRetrieverOption func(*ShardedRetriever)
+
+// WithShardedTopK sets the number of merged results to return.
+func WithShardedTopK(topK int) ShardedRetrieverOption {
+	return func(sr *ShardedRetriever) {
+		sr.SimilarityTopK = topK
+	}
+}
+
+// WithAllowPartialShards tolerates failing shards as long as one succeeds.
+func WithAllowPartialShards(allow bool) ShardedRetrieverOption {
+	return func(sr *ShardedRetriever) {
+		sr.AllowPartial = allow
+	}
+}
+
+// NewShardedRetriever creates a new ShardedRetriever.
+func NewShardedRetriever(shards []Retriever, opts ...ShardedRetrieverOption) *ShardedRetriever {
+	sr := &ShardedRetriever{
+		BaseRetriever:  NewBaseRetriever(),
+		Shards:         shards,
+		SimilarityTopK: 10,
+	}
+
+	for _, opt := range opts {
+		opt(sr)
+	}
+
+	return sr
+}
+
+// Retrieve fans the query out to all shards and merges the results.
+func (sr *ShardedRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	if len(sr.Shards) == 0 {
+		return nil, fmt.Errorf("no shards configured")
+	}
+
+	results := make([][]schema.NodeWithScore, len(sr.Shards))
+	errs := make([]error, len(sr.Shards))
+
+	var wg sync.WaitGroup
+	for i, shard := range sr.Shards {
+		wg.Add(1)
+		go func(i int, shard Retriever) {
+			defer wg.Done()
+			results[i], errs[i] = shard.Retrieve(ctx, query)
+		}(i, shard)
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if !sr.AllowPartial {
+			return nil, fmt.Errorf("shard %d: %w", i, err)
+		}
+		failed++
+		if sr.Verbose {
+			fmt.Printf("Shard %d failed: %v\n", i, err)
+		}
+	}
+	if failed == len(sr.Shards) {
+		return nil, fmt.Errorf("all %d shards failed: %w", failed, errs[0])
+	}
+
+	return store.MergeShardResults(results, sr.SimilarityTopK), nil
+}
+
+// Ensure ShardedRetriever implements Retriever.
+var _ Retriever = (*ShardedRetriever)(nil)
@@ -0,0 +1,161 @@
+package retriever
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// MissingSourcesMetadataKey is set on the results of an ensemble retriever
+// to the names of the child retrievers that timed out, when any did.
+const MissingSourcesMetadataKey = "missing_sources"
+
+// ErrAllSourcesTimedOut is returned when every child retriever of an
+// ensemble timed out.
+var ErrAllSourcesTimedOut = errors.New("all retrieval sources timed out")
+
+// TimeoutEvent describes a child retriever that missed its deadline.
+type TimeoutEvent struct {
+	// Retriever is the kind of ensemble, e.g. "fusion" or "router".
+	Retriever string
+	// Source is the name of the child retriever.
+	Source string
+	// Timeout is the deadline the child missed.
+	Timeout time.Duration
+}
+
+// ChildTimeouts configures per-child deadlines of ensemble retrievers such
+// as FusionRetriever and RouterRetriever. Children run concurrently, and
+// the ensemble returns whatever arrived before the deadlines instead of
+// waiting for a slow backend.
+type ChildTimeouts struct {
+	// Default applies to children without their own timeout. Zero waits
+	// until the request context is done.
+	Default time.Duration
+	// PerSource overrides the timeout of children by name.
+	PerSource map[string]time.Duration
+	// OnTimeout is called for every child that times out, e.g. to
+	// increment a metric. It must be safe for concurrent use.
+	OnTimeout func(ctx context.Context, event TimeoutEvent)
+}
+
+// timeoutFor returns the timeout of a child, or zero for none.
+func (t *ChildTimeouts) timeoutFor(source string) time.Duration {
+	if d, ok := t.PerSource[source]; ok {
+		return d
+	}
+	return t.Default
+}
+
+// namedRetriever is a child retriever of an ensemble.
+type namedRetriever struct {
+	name      string
+	retriever Retriever
+}
+
+// childResult is the outcome of one child retrieval.
+type childResult struct {
+	nodes    []schema.NodeWithScore
+	err      error
+	timedOut bool
+}
+
+// gatherResults runs the children and collects their results in order.
+// Without timeouts, children run one after another and the first error is
+// returned. With timeouts, they run concurrently; children that miss their
+// deadline are reported as missing and their late results discarded.
+func gatherResults(ctx context.Context, kind string, children []namedRetriever, query schema.QueryBundle, timeouts *ChildTimeouts) ([]childResult, []string, error) {
+	results := make([]childResult, len(children))
+	if timeouts == nil {
+		for i, child := range children {
+			nodes, err := child.retriever.Retrieve(ctx, query)
+			if err != nil {
+				return nil, nil, err
+			}
+			results[i].nodes = nodes
+		}
+		return results, nil, nil
+	}
+
+	var wg sync.WaitGroup
+	for i, child := range children {
+		wg.Add(1)
+		go func(i int, child namedRetriever) {
+			defer wg.Done()
+			results[i] = retrieveWithTimeout(ctx, child, query, timeouts.timeoutFor(child.name))
+		}(i, child)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	var missing []string
+	for i, res := range results {
+		if res.timedOut {
+			missing = append(missing, children[i].name)
+			if timeouts.OnTimeout != nil {
+				timeouts.OnTimeout(ctx, TimeoutEvent{Retriever: kind, Source: children[i].name, Timeout: timeouts.timeoutFor(children[i].name)})
+			}
+			continue
+		}
+		if res.err != nil {
+			return nil, nil, fmt.Errorf("retriever %s: %w", children[i].name, res.err)
+		}
+	}
+	if len(children) > 0 && len(missing) == len(children) {
+		return nil, missing, ErrAllSourcesTimedOut
+	}
+	return results, missing, nil
+}
+
+// retrieveWithTimeout runs one child, giving up once its deadline passes
+// even if the child ignores its context.
+func retrieveWithTimeout(ctx context.Context, child namedRetriever, query schema.QueryBundle, timeout time.Duration) childResult {
+	if timeout <= 0 {
+		nodes, err := child.retriever.Retrieve(ctx, query)
+		return childResult{nodes: nodes, err: err}
+	}
+
+	childCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	done := make(chan childResult, 1)
+	go func() {
+		nodes, err := child.retriever.Retrieve(childCtx, query)
+		done <- childResult{nodes: nodes, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil && ctx.Err() == nil && errors.Is(childCtx.Err(), context.DeadlineExceeded) {
+			return childResult{timedOut: true}
+		}
+		return res
+	case <-childCtx.Done():
+		if ctx.Err() != nil {
+			return childResult{err: ctx.Err()}
+		}
+		return childResult{timedOut: true}
+	}
+}
+
+// annotateMissing records the missing sources on each result, copying the
+// metadata so that nodes shared with the children are not modified.
+func annotateMissing(nodes []schema.NodeWithScore, missing []string) []schema.NodeWithScore {
+	if len(missing) == 0 {
+		return nodes
+	}
+	for i := range nodes {
+		metadata := make(map[string]interface{}, len(nodes[i].Node.Metadata)+1)
+		for k, v := range nodes[i].Node.Metadata {
+			metadata[k] = v
+		}
+		metadata[MissingSourcesMetadataKey] = append([]string(nil), missing...)
+		nodes[i].Node.Metadata = metadata
+	}
+	return nodes
+}
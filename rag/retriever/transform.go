@@ -0,0 +1,54 @@
+package retriever
+
+import (
+	"context"
+
+	"github.com/aqua777/go-llamaindex/querytransform"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// TransformRetriever applies query transforms, such as HyDE or LLM query
+// rewriting, before delegating to another retriever.
+type TransformRetriever struct {
+	*BaseRetriever
+	// Retriever retrieves with the transformed query.
+	Retriever Retriever
+	// Transforms are applied to the query in order.
+	Transforms []querytransform.QueryTransform
+}
+
+// TransformRetrieverOption is a functional option for TransformRetriever.
+type TransformRetrieverOption func(*TransformRetriever)
+
+// WithQueryTransforms appends query transforms, applied in order.
+func WithQueryTransforms(transforms ...querytransform.QueryTransform) TransformRetrieverOption {
+	return func(tr *TransformRetriever) {
+		tr.Transforms = append(tr.Transforms, transforms...)
+	}
+}
+
+// NewTransformRetriever creates a TransformRetriever around ret.
+func NewTransformRetriever(ret Retriever, opts ...TransformRetrieverOption) *TransformRetriever {
+	tr := &TransformRetriever{
+		BaseRetriever: NewBaseRetriever(),
+		Retriever:     ret,
+	}
+
+	for _, opt := range opts {
+		opt(tr)
+	}
+
+	return tr
+}
+
+// Retrieve transforms the query and retrieves with the result.
+func (tr *TransformRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	transformed, err := querytransform.Apply(ctx, query, tr.Transforms...)
+	if err != nil {
+		return nil, err
+	}
+	return tr.Retriever.Retrieve(ctx, transformed)
+}
+
+// Ensure TransformRetriever implements Retriever.
+var _ Retriever = (*TransformRetriever)(nil)
@@ -20,6 +20,9 @@ type VectorRetriever struct {
 	TopK int
 	// Mode is the query mode for the vector store.
 	Mode schema.VectorStoreQueryMode
+
+	explain bool
+	policy  TopKPolicy
 }
 
 // VectorRetrieverOption is a functional option for VectorRetriever.
@@ -39,6 +42,22 @@ func WithQueryMode(mode schema.VectorStoreQueryMode) VectorRetrieverOption {
 	}
 }
 
+// WithVectorExplain records each result's similarity and rank in its score
+// explanation (see schema.ScoreExplanationMetadataKey).
+func WithVectorExplain(explain bool) VectorRetrieverOption {
+	return func(vr *VectorRetriever) {
+		vr.explain = explain
+	}
+}
+
+// WithTopKPolicy chooses the top-k and similarity cutoff of each query with
+// policy, e.g. an AdaptiveTopK, instead of the fixed TopK.
+func WithTopKPolicy(policy TopKPolicy) VectorRetrieverOption {
+	return func(vr *VectorRetriever) {
+		vr.policy = policy
+	}
+}
+
 // NewVectorRetriever creates a new VectorRetriever.
 func NewVectorRetriever(
 	vectorStore store.VectorStore,
@@ -62,6 +81,15 @@ func NewVectorRetriever(
 
 // Retrieve retrieves nodes from the vector store.
 func (vr *VectorRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	topK, cutoff := vr.TopK, 0.0
+	if vr.policy != nil {
+		params, err := vr.policy.Params(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		topK, cutoff = params.TopK, params.SimilarityCutoff
+	}
+
 	// Get query embedding
 	queryEmbedding, err := vr.EmbeddingModel.GetQueryEmbedding(ctx, query.QueryString)
 	if err != nil {
@@ -71,7 +99,7 @@ func (vr *VectorRetriever) Retrieve(ctx context.Context, query schema.QueryBundl
 	// Build vector store query
 	storeQuery := schema.VectorStoreQuery{
 		Embedding: queryEmbedding,
-		TopK:      vr.TopK,
+		TopK:      topK,
 		Filters:   query.Filters,
 		Mode:      vr.Mode,
 	}
@@ -81,10 +109,43 @@ func (vr *VectorRetriever) Retrieve(ctx context.Context, query schema.QueryBundl
 	if err != nil {
 		return nil, fmt.Errorf("failed to query vector store: %w", err)
 	}
+	if cutoff > 0 {
+		kept := nodes[:0]
+		for _, n := range nodes {
+			if n.Score >= cutoff {
+				kept = append(kept, n)
+			}
+		}
+		nodes = kept
+	}
+	if vr.explain {
+		for i := range nodes {
+			nodes[i].ExplainScore(schema.ScoreComponent{
+				Name:    schema.ScoreComponentVectorSimilarity,
+				Score:   nodes[i].Score,
+				Details: map[string]float64{"similarity": nodes[i].Score, "rank": float64(i + 1)},
+				Note:    "mode=" + string(vr.Mode),
+			})
+		}
+	}
 
 	// Handle recursive retrieval if needed
 	return vr.HandleRecursiveRetrieval(ctx, query, nodes)
 }
 
+// Warmup verifies the embedding model and primes the vector store by running
+// a probe query, so the first real query does not pay connection or
+// index-loading costs.
+func (vr *VectorRetriever) Warmup(ctx context.Context) error {
+	queryEmbedding, err := vr.EmbeddingModel.GetQueryEmbedding(ctx, "warmup")
+	if err != nil {
+		return fmt.Errorf("failed to get query embedding: %w", err)
+	}
+	if _, err := vr.VectorStore.Query(ctx, schema.VectorStoreQuery{Embedding: queryEmbedding, TopK: 1, Mode: vr.Mode}); err != nil {
+		return fmt.Errorf("failed to query vector store: %w", err)
+	}
+	return nil
+}
+
 // Ensure VectorRetriever implements Retriever.
 var _ Retriever = (*VectorRetriever)(nil)
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"runtime"
+	"strconv"
 
+	"github.com/aqua777/go-llamaindex/rag/store"
 	"github.com/aqua777/go-llamaindex/schema"
 	"github.com/philippgille/chromem-go"
 )
@@ -99,33 +101,25 @@ func (s *ChromemStore) Query(ctx context.Context, query schema.VectorStoreQuery)
 		queryEmbedding32[i] = float32(v)
 	}
 
-	var where map[string]string
-	var whereDocument map[string]string
-
-	// Map MetadataFilters to chromem's where/whereDocument if possible
-	// Chromem currently supports exact match filtering via map[string]string for metadata
-	// and contains match for document content.
-	if query.Filters != nil {
-		for _, f := range query.Filters.Filters {
-			if f.Operator == schema.FilterOperatorEq {
-				// Only EQ is directly supported by simple map[string]string where clause
-				// Also assume value is string-able
-				if where == nil {
-					where = make(map[string]string)
-				}
-				where[f.Key] = fmt.Sprintf("%v", f.Value)
-			}
-			// For now, we only support EQ. Other operators would require post-filtering.
+	// chromem filters natively only by exact metadata values, so top-level
+	// equality filters combined with AND are pushed down and the rest are
+	// applied to the results.
+	where, residual := splitFilters(query.Filters)
+	nResults := query.TopK
+	if residual != nil {
+		nResults = s.collection.Count()
+		if nResults == 0 {
+			return nil, nil
 		}
 	}
 
-	res, err := s.collection.QueryEmbedding(ctx, queryEmbedding32, query.TopK, where, whereDocument)
+	res, err := s.collection.QueryEmbedding(ctx, queryEmbedding32, nResults, where, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query chromem collection: %w", err)
 	}
 
-	nodes := make([]schema.NodeWithScore, len(res))
-	for i, doc := range res {
+	nodes := make([]schema.NodeWithScore, 0, len(res))
+	for _, doc := range res {
 		// Reconstruct Node
 		// Convert metadata back
 		meta := make(map[string]interface{})
@@ -139,16 +133,30 @@ func (s *ChromemStore) Query(ctx context.Context, query schema.VectorStoreQuery)
 			meta[k] = v // Keep as string for now
 		}
 
-		nodes[i] = schema.NodeWithScore{
-			Node: schema.Node{
-				ID:       doc.ID,
-				Text:     doc.Content,
-				Type:     nodeType,
-				Metadata: meta,
-			},
+		node := schema.Node{
+			ID:       doc.ID,
+			Text:     doc.Content,
+			Type:     nodeType,
+			Metadata: meta,
+		}
+		if residual != nil {
+			match, err := store.MatchesFilters(schema.Node{Metadata: parseMetadata(meta)}, residual)
+			if err != nil {
+				return nil, err
+			}
+			if !match {
+				continue
+			}
+		}
+
+		nodes = append(nodes, schema.NodeWithScore{
+			Node: node,
 			// chromem returns Cosine Similarity (0-1 for normalized vectors?)
 			// The `Similarity` field in result.
 			Score: float64(doc.Similarity),
+		})
+		if residual != nil && len(nodes) == query.TopK {
+			break
 		}
 	}
 
@@ -159,3 +167,74 @@ func (s *ChromemStore) Query(ctx context.Context, query schema.VectorStoreQuery)
 func (s *ChromemStore) Delete(ctx context.Context, refDocID string) error {
 	return s.collection.Delete(ctx, nil, nil, refDocID)
 }
+
+// DeleteByFilter removes the documents matching filters and returns how
+// many were removed. chromem only filters by exact metadata values, so
+// only equality filters combined with AND are supported.
+func (s *ChromemStore) DeleteByFilter(ctx context.Context, filters *schema.MetadataFilters) (int, error) {
+	if filters == nil || len(filters.Filters) == 0 {
+		return 0, store.ErrEmptyDeleteFilter
+	}
+	if len(filters.Nested) > 0 || (filters.Condition != "" && filters.Condition != schema.FilterConditionAnd) {
+		return 0, fmt.Errorf("chromem: only AND filters without nesting are supported")
+	}
+	where := make(map[string]string, len(filters.Filters))
+	for _, f := range filters.Filters {
+		if f.Operator != schema.FilterOperatorEq && f.Operator != "" {
+			return 0, fmt.Errorf("chromem: unsupported filter operator %q", f.Operator)
+		}
+		where[f.Key] = fmt.Sprintf("%v", f.Value)
+	}
+	before := s.collection.Count()
+	if err := s.collection.Delete(ctx, where, nil); err != nil {
+		return 0, fmt.Errorf("failed to delete from chromem collection: %w", err)
+	}
+	return before - s.collection.Count(), nil
+}
+
+// splitFilters splits filters into the equality filters chromem applies
+// natively and the remaining filters, which are nil if there are none.
+func splitFilters(filters *schema.MetadataFilters) (map[string]string, *schema.MetadataFilters) {
+	if filters == nil {
+		return nil, nil
+	}
+	if filters.Condition != "" && filters.Condition != schema.FilterConditionAnd {
+		return nil, filters
+	}
+
+	var where map[string]string
+	residual := &schema.MetadataFilters{Condition: schema.FilterConditionAnd, Nested: filters.Nested}
+	for _, f := range filters.Filters {
+		_, seen := where[f.Key]
+		if (f.Operator != schema.FilterOperatorEq && f.Operator != "") || seen {
+			residual.Filters = append(residual.Filters, f)
+			continue
+		}
+		if where == nil {
+			where = make(map[string]string)
+		}
+		where[f.Key] = fmt.Sprintf("%v", f.Value)
+	}
+	if len(residual.Filters) == 0 && len(residual.Nested) == 0 {
+		return where, nil
+	}
+	return where, residual
+}
+
+// parseMetadata restores numbers from chromem's string metadata so that
+// range filters compare numerically.
+func parseMetadata(meta map[string]interface{}) map[string]interface{} {
+	parsed := make(map[string]interface{}, len(meta))
+	for k, v := range meta {
+		parsed[k] = v
+		if s, ok := v.(string); ok {
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				parsed[k] = f
+			}
+		}
+	}
+	return parsed
+}
+
+// Ensure ChromemStore implements VectorStore.
+var _ store.VectorStore = (*ChromemStore)(nil)
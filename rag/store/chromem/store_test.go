@@ -110,3 +110,61 @@ func TestChromemStore_InMemory(t *testing.T) {
 	assert.Len(t, res, 1)
 	assert.Equal(t, "A", res[0].Node.ID)
 }
+
+func TestChromemStore_DeleteByFilter(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewChromemStore("", "delete-test")
+	require.NoError(t, err)
+
+	_, err = s.Add(ctx, []schema.Node{
+		{ID: "1", Text: "Old offer.", Metadata: map[string]interface{}{"kind": "offer"}, Embedding: []float64{1, 0}},
+		{ID: "2", Text: "Another offer.", Metadata: map[string]interface{}{"kind": "offer"}, Embedding: []float64{0.9, 0.1}},
+		{ID: "3", Text: "Manual.", Metadata: map[string]interface{}{"kind": "doc"}, Embedding: []float64{0, 1}},
+	})
+	require.NoError(t, err)
+
+	n, err := s.DeleteByFilter(ctx, schema.NewMetadataFilters(schema.NewMetadataFilter("kind", "offer")))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 1, s.collection.Count())
+
+	_, err = s.DeleteByFilter(ctx, schema.NewMetadataFilters(schema.NewMetadataFilterWithOp("expires_at", 1, schema.FilterOperatorLte)))
+	assert.Error(t, err)
+	_, err = s.DeleteByFilter(ctx, nil)
+	assert.Error(t, err)
+}
+
+func TestChromemStore_QueryFilters(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewChromemStore("", "filter-test")
+	require.NoError(t, err)
+
+	_, err = s.Add(ctx, []schema.Node{
+		{ID: "1", Text: "Dune", Metadata: map[string]interface{}{"genre": "sci-fi", "year": 1965}, Embedding: []float64{1, 0}},
+		{ID: "2", Text: "Neuromancer", Metadata: map[string]interface{}{"genre": "sci-fi", "year": 1984}, Embedding: []float64{0.9, 0.1}},
+		{ID: "3", Text: "Earthsea", Metadata: map[string]interface{}{"genre": "fantasy", "year": 1968}, Embedding: []float64{0.8, 0.2}},
+		{ID: "4", Text: "Manual", Metadata: map[string]interface{}{"genre": "reference", "year": 2001}, Embedding: []float64{0, 1}},
+	})
+	require.NoError(t, err)
+
+	query := func(expr string, topK int) []string {
+		res, err := s.Query(ctx, schema.VectorStoreQuery{
+			Embedding: []float64{1, 0},
+			TopK:      topK,
+			Filters:   schema.MustParseMetadataFilters(expr),
+		})
+		require.NoError(t, err, expr)
+		ids := make([]string, len(res))
+		for i, r := range res {
+			ids[i] = r.Node.ID
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"1"}, query(`genre == "sci-fi"`, 1))
+	assert.Equal(t, []string{"2"}, query(`genre == "sci-fi" AND year > 1970`, 3), "equality pushed down, range post-filtered")
+	assert.Equal(t, []string{"2", "3"}, query(`year >= 1966 AND year < 2000`, 3))
+	assert.Equal(t, []string{"1", "3"}, query(`genre == "fantasy" OR year < 1966`, 3))
+	assert.Equal(t, []string{"1", "2"}, query(`genre in ["sci-fi", "reference"]`, 2), "post-filtered results are cut to top-k")
+	assert.Equal(t, []string{"3", "4"}, query(`NOT genre == "sci-fi"`, 3))
+}
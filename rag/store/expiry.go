@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// ExpiresAtMetadataKey is the metadata key holding a node's expiry time as
+// Unix seconds. Nodes without it never expire.
+const ExpiresAtMetadataKey = "expires_at"
+
+// SetExpiry makes node expire at t.
+func SetExpiry(node *schema.Node, t time.Time) {
+	if node.Metadata == nil {
+		node.Metadata = make(map[string]interface{})
+	}
+	node.Metadata[ExpiresAtMetadataKey] = t.Unix()
+}
+
+// SetTTL makes node expire ttl after now.
+func SetTTL(node *schema.Node, ttl time.Duration, now time.Time) {
+	SetExpiry(node, now.Add(ttl))
+}
+
+// ExpiredFilter matches the nodes that expired by now.
+func ExpiredFilter(now time.Time) *schema.MetadataFilters {
+	return schema.NewMetadataFilters(
+		schema.NewMetadataFilterWithOp(ExpiresAtMetadataKey, now.Unix(), schema.FilterOperatorLte),
+	)
+}
+
+// DeleteExpired removes the nodes of vs that expired by now and returns
+// how many were removed. The store must support the <= operator in
+// DeleteByFilter.
+func DeleteExpired(ctx context.Context, vs VectorStore, now time.Time) (int, error) {
+	return vs.DeleteByFilter(ctx, ExpiredFilter(now))
+}
+
+// ExpirySweeper periodically removes expired nodes from a vector store.
+type ExpirySweeper struct {
+	store   VectorStore
+	now     func() time.Time
+	onSweep func(int, error)
+}
+
+// ExpirySweeperOption configures an ExpirySweeper.
+type ExpirySweeperOption func(*ExpirySweeper)
+
+// WithExpiryClock sets the function used to read the current time.
+func WithExpiryClock(now func() time.Time) ExpirySweeperOption {
+	return func(s *ExpirySweeper) {
+		s.now = now
+	}
+}
+
+// WithExpirySweepHandler sets a function called with the result of every
+// background sweep.
+func WithExpirySweepHandler(fn func(removed int, err error)) ExpirySweeperOption {
+	return func(s *ExpirySweeper) {
+		s.onSweep = fn
+	}
+}
+
+// NewExpirySweeper creates a sweeper for vs.
+func NewExpirySweeper(vs VectorStore, opts ...ExpirySweeperOption) *ExpirySweeper {
+	s := &ExpirySweeper{
+		store: vs,
+		now:   time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Sweep removes the nodes expired by now once.
+func (s *ExpirySweeper) Sweep(ctx context.Context) (int, error) {
+	return DeleteExpired(ctx, s.store, s.now())
+}
+
+// Start runs Sweep every interval until ctx is cancelled.
+func (s *ExpirySweeper) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n, err := s.Sweep(ctx)
+				if s.onSweep != nil {
+					s.onSweep(n, err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// MatchesFilters reports whether node satisfies the filters, including
+// nested groups. Missing keys only match is_empty. Numbers compare
+// numerically whatever their Go type, so values that went through JSON
+// persistence still match; other values compare by their string form.
+// Stores without native support for an operator can use it to filter
+// results in memory.
+func MatchesFilters(node schema.Node, filters *schema.MetadataFilters) (bool, error) {
+	if filters == nil {
+		return true, nil
+	}
+	var results []bool
+	for _, f := range filters.Filters {
+		ok, err := matchesFilter(node.Metadata, f)
+		if err != nil {
+			return false, err
+		}
+		results = append(results, ok)
+	}
+	for _, nested := range filters.Nested {
+		if nested == nil {
+			continue
+		}
+		ok, err := MatchesFilters(node, nested)
+		if err != nil {
+			return false, err
+		}
+		results = append(results, ok)
+	}
+	if len(results) == 0 {
+		return true, nil
+	}
+
+	switch filters.Condition {
+	case schema.FilterConditionOr:
+		for _, ok := range results {
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	case schema.FilterConditionAnd, schema.FilterConditionNot, "":
+		all := true
+		for _, ok := range results {
+			all = all && ok
+		}
+		if filters.Condition == schema.FilterConditionNot {
+			return !all, nil
+		}
+		return all, nil
+	default:
+		return false, fmt.Errorf("unsupported filter condition %q", filters.Condition)
+	}
+}
+
+// matchesFilter evaluates a single filter against node metadata.
+func matchesFilter(metadata map[string]interface{}, f schema.MetadataFilter) (bool, error) {
+	value, ok := metadata[f.Key]
+	if f.Operator == schema.FilterOperatorIsEmpty {
+		return !ok || isEmptyValue(value), nil
+	}
+	if !ok {
+		return false, nil
+	}
+
+	switch f.Operator {
+	case schema.FilterOperatorEq, "":
+		return valuesEqual(value, f.Value), nil
+	case schema.FilterOperatorNe:
+		return !valuesEqual(value, f.Value), nil
+	case schema.FilterOperatorGt, schema.FilterOperatorGte, schema.FilterOperatorLt, schema.FilterOperatorLte:
+		c, ok := compareValues(value, f.Value)
+		if !ok {
+			return false, nil
+		}
+		switch f.Operator {
+		case schema.FilterOperatorGt:
+			return c > 0, nil
+		case schema.FilterOperatorGte:
+			return c >= 0, nil
+		case schema.FilterOperatorLt:
+			return c < 0, nil
+		default:
+			return c <= 0, nil
+		}
+	case schema.FilterOperatorIn, schema.FilterOperatorNin:
+		values, ok := listValues(f.Value)
+		if !ok {
+			return false, fmt.Errorf("filter %q with operator %q needs a list value, got %T", f.Key, f.Operator, f.Value)
+		}
+		found := containsValue(values, value)
+		return found == (f.Operator == schema.FilterOperatorIn), nil
+	case schema.FilterOperatorAny, schema.FilterOperatorAll:
+		values, ok := listValues(f.Value)
+		if !ok {
+			return false, fmt.Errorf("filter %q with operator %q needs a list value, got %T", f.Key, f.Operator, f.Value)
+		}
+		have, ok := listValues(value)
+		if !ok {
+			return false, nil
+		}
+		for _, v := range values {
+			found := containsValue(have, v)
+			if found && f.Operator == schema.FilterOperatorAny {
+				return true, nil
+			}
+			if !found && f.Operator == schema.FilterOperatorAll {
+				return false, nil
+			}
+		}
+		return f.Operator == schema.FilterOperatorAll, nil
+	case schema.FilterOperatorContains:
+		have, ok := listValues(value)
+		return ok && containsValue(have, f.Value), nil
+	case schema.FilterOperatorTextMatch:
+		return strings.Contains(fmt.Sprintf("%v", value), fmt.Sprintf("%v", f.Value)), nil
+	case schema.FilterOperatorTextMatchInsensitive:
+		return strings.Contains(strings.ToLower(fmt.Sprintf("%v", value)), strings.ToLower(fmt.Sprintf("%v", f.Value))), nil
+	default:
+		return false, fmt.Errorf("unsupported filter operator %q", f.Operator)
+	}
+}
+
+// valuesEqual compares numbers numerically and other values by their
+// string form.
+func valuesEqual(a, b interface{}) bool {
+	if c, ok := compareNumbers(a, b); ok {
+		return c == 0
+	}
+	return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+}
+
+// compareValues orders two numbers or two strings.
+func compareValues(a, b interface{}) (int, bool) {
+	if c, ok := compareNumbers(a, b); ok {
+		return c, true
+	}
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if !aok || !bok {
+		return 0, false
+	}
+	return strings.Compare(as, bs), true
+}
+
+func compareNumbers(a, b interface{}) (int, bool) {
+	x, ok := toFloat(a)
+	if !ok {
+		return 0, false
+	}
+	y, ok := toFloat(b)
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// listValues returns the elements of a slice or array value.
+func listValues(v interface{}) ([]interface{}, bool) {
+	if values, ok := v.([]interface{}); ok {
+		return values, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values, true
+}
+
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, candidate := range values {
+		if valuesEqual(candidate, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmptyValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	if s, ok := v.(string); ok {
+		return s == ""
+	}
+	if values, ok := listValues(v); ok {
+		return len(values) == 0
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"errors"
 
 	"github.com/aqua777/go-llamaindex/schema"
 )
@@ -14,4 +15,66 @@ type VectorStore interface {
 	Query(ctx context.Context, query schema.VectorStoreQuery) ([]schema.NodeWithScore, error)
 	// Delete removes a node from the store by ID.
 	Delete(ctx context.Context, refDocID string) error
+	// DeleteByFilter removes all nodes matching the metadata filters and
+	// returns how many were removed, or -1 if the backend cannot tell.
+	// Empty filters are rejected with ErrEmptyDeleteFilter rather than
+	// deleting everything.
+	DeleteByFilter(ctx context.Context, filters *schema.MetadataFilters) (int, error)
+}
+
+// ErrEmptyDeleteFilter is returned by DeleteByFilter when no filter is
+// given.
+var ErrEmptyDeleteFilter = errors.New("delete by filter requires at least one filter")
+
+// checkDeleteFilters rejects filters that would match every node.
+func checkDeleteFilters(filters *schema.MetadataFilters) error {
+	if !hasFilters(filters) {
+		return ErrEmptyDeleteFilter
+	}
+	return nil
+}
+
+func hasFilters(filters *schema.MetadataFilters) bool {
+	if filters == nil {
+		return false
+	}
+	if len(filters.Filters) > 0 {
+		return true
+	}
+	for _, nested := range filters.Nested {
+		if hasFilters(nested) {
+			return true
+		}
+	}
+	return false
+}
+
+// NodeLister is implemented by vector stores that can enumerate their nodes,
+// e.g. for migrations and consistency checks.
+type NodeLister interface {
+	// ListNodes returns all nodes in the store, including embeddings.
+	ListNodes(ctx context.Context) ([]schema.Node, error)
+}
+
+// MultiVectorQuery is a late-interaction query with one embedding per query
+// token.
+type MultiVectorQuery struct {
+	// Embeddings are the per-token query embeddings.
+	Embeddings [][]float64
+	// TopK is the number of results to return.
+	TopK int
+	// Filters are metadata filters to apply.
+	Filters *schema.MetadataFilters
+}
+
+// MultiVectorStore is implemented by stores that keep several embeddings per
+// node and score queries with MaxSim late interaction, as in ColBERT.
+type MultiVectorStore interface {
+	// AddMultiVector adds nodes with their per-token embeddings. vectors[i]
+	// belongs to nodes[i].
+	AddMultiVector(ctx context.Context, nodes []schema.Node, vectors [][][]float64) ([]string, error)
+	// QueryMultiVector returns the top-k nodes by MaxSim score.
+	QueryMultiVector(ctx context.Context, query MultiVectorQuery) ([]schema.NodeWithScore, error)
+	// Delete removes a node and its embeddings from the store by ID.
+	Delete(ctx context.Context, refDocID string) error
 }
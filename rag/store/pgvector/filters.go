@@ -0,0 +1,220 @@
+package pgvector
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// whereBuilder translates metadata filters into SQL over the JSONB metadata
+// column. Keys and values are always bound as parameters.
+//
+// Comparisons use JSONB ordering, so numbers compare numerically, strings
+// lexically and values of different JSON types never match equality.
+type whereBuilder struct {
+	values []interface{}
+}
+
+// arg binds a value and returns its placeholder.
+func (w *whereBuilder) arg(v interface{}) string {
+	w.values = append(w.values, v)
+	return fmt.Sprintf("$%d", len(w.values))
+}
+
+// args binds values and returns their comma-separated placeholders.
+func (w *whereBuilder) args(values []interface{}) string {
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		placeholders[i] = w.arg(v)
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+// jsonArg binds v encoded as JSONB.
+func (w *whereBuilder) jsonArg(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("pgvector: failed to encode filter value %v: %w", v, err)
+	}
+	return w.arg(string(data)) + "::jsonb", nil
+}
+
+// filters translates a filter group, including nested groups.
+func (w *whereBuilder) filters(mf *schema.MetadataFilters) (string, error) {
+	var parts []string
+	for _, f := range mf.Filters {
+		cond, err := w.filter(f)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, cond)
+	}
+	for _, nested := range mf.Nested {
+		if nested == nil {
+			continue
+		}
+		cond, err := w.filters(nested)
+		if err != nil {
+			return "", err
+		}
+		if cond != "" {
+			parts = append(parts, cond)
+		}
+	}
+	if len(parts) == 0 {
+		return "", nil
+	}
+
+	switch mf.Condition {
+	case schema.FilterConditionOr:
+		return "(" + strings.Join(parts, " OR ") + ")", nil
+	case schema.FilterConditionNot:
+		return "NOT (" + strings.Join(parts, " AND ") + ")", nil
+	case schema.FilterConditionAnd, "":
+		return "(" + strings.Join(parts, " AND ") + ")", nil
+	default:
+		return "", fmt.Errorf("pgvector: unsupported filter condition %q", mf.Condition)
+	}
+}
+
+// filter translates a single filter.
+func (w *whereBuilder) filter(f schema.MetadataFilter) (string, error) {
+	// The key is bound on first use: Postgres rejects parameters that are
+	// never referenced.
+	var keyArg string
+	key := func() string {
+		if keyArg == "" {
+			keyArg = w.arg(f.Key) + "::text"
+		}
+		return keyArg
+	}
+	field := func() string { return "metadata->" + key() }
+
+	switch f.Operator {
+	case schema.FilterOperatorEq, "":
+		return w.compare(field(), "=", f.Value)
+	case schema.FilterOperatorNe:
+		return w.compare(field(), "<>", f.Value)
+	case schema.FilterOperatorGt:
+		return w.compare(field(), ">", f.Value)
+	case schema.FilterOperatorGte:
+		return w.compare(field(), ">=", f.Value)
+	case schema.FilterOperatorLt:
+		return w.compare(field(), "<", f.Value)
+	case schema.FilterOperatorLte:
+		return w.compare(field(), "<=", f.Value)
+
+	case schema.FilterOperatorIn, schema.FilterOperatorNin:
+		values, err := filterValues(f)
+		if err != nil {
+			return "", err
+		}
+		if len(values) == 0 {
+			if f.Operator == schema.FilterOperatorIn {
+				return "FALSE", nil
+			}
+			return "TRUE", nil
+		}
+		placeholders := make([]string, len(values))
+		for i, v := range values {
+			if placeholders[i], err = w.jsonArg(v); err != nil {
+				return "", err
+			}
+		}
+		op := "IN"
+		if f.Operator == schema.FilterOperatorNin {
+			op = "NOT IN"
+		}
+		return fmt.Sprintf("%s %s (%s)", field(), op, strings.Join(placeholders, ", ")), nil
+
+	case schema.FilterOperatorAny:
+		values, err := filterValues(f)
+		if err != nil {
+			return "", err
+		}
+		if len(values) == 0 {
+			return "FALSE", nil
+		}
+		conds := make([]string, len(values))
+		for i, v := range values {
+			arg, err := w.jsonArg([]interface{}{v})
+			if err != nil {
+				return "", err
+			}
+			conds[i] = fmt.Sprintf("%s @> %s", field(), arg)
+		}
+		return "(" + strings.Join(conds, " OR ") + ")", nil
+
+	case schema.FilterOperatorAll:
+		values, err := filterValues(f)
+		if err != nil {
+			return "", err
+		}
+		arg, err := w.jsonArg(values)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s @> %s", field(), arg), nil
+
+	case schema.FilterOperatorContains:
+		arg, err := w.jsonArg([]interface{}{f.Value})
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(jsonb_typeof(%s) = 'array' AND %s @> %s)", field(), field(), arg), nil
+
+	case schema.FilterOperatorTextMatch, schema.FilterOperatorTextMatchInsensitive:
+		op := "LIKE"
+		if f.Operator == schema.FilterOperatorTextMatchInsensitive {
+			op = "ILIKE"
+		}
+		pattern := "%" + escapeLike(fmt.Sprintf("%v", f.Value)) + "%"
+		return fmt.Sprintf("metadata->>%s %s %s", key(), op, w.arg(pattern)), nil
+
+	case schema.FilterOperatorIsEmpty:
+		return fmt.Sprintf("(%s IS NULL OR %s IN ('null'::jsonb, '\"\"'::jsonb, '[]'::jsonb))", field(), field()), nil
+
+	default:
+		return "", fmt.Errorf("pgvector: unsupported filter operator %q", f.Operator)
+	}
+}
+
+// compare binds a JSONB comparison.
+func (w *whereBuilder) compare(field, op string, value interface{}) (string, error) {
+	arg, err := w.jsonArg(value)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s %s", field, op, arg), nil
+}
+
+// filterValues returns the list value of an in/nin/any/all filter.
+func filterValues(f schema.MetadataFilter) ([]interface{}, error) {
+	switch v := f.Value.(type) {
+	case []interface{}:
+		return v, nil
+	case []string:
+		return stringsToArgs(v), nil
+	case []int:
+		out := make([]interface{}, len(v))
+		for i, x := range v {
+			out[i] = x
+		}
+		return out, nil
+	case []float64:
+		out := make([]interface{}, len(v))
+		for i, x := range v {
+			out[i] = x
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("pgvector: filter %q with operator %q needs a list value, got %T", f.Key, f.Operator, f.Value)
+	}
+}
+
+// escapeLike escapes LIKE wildcards.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,531 @@
+// Package pgvector provides a vector store backed by PostgreSQL with the
+// pgvector extension.
+//
+// The store works with any database/sql Postgres driver, such as
+// github.com/jackc/pgx/v5/stdlib or github.com/lib/pq:
+//
+//	db, _ := sql.Open("pgx", "postgres://localhost/rag")
+//	vs, _ := pgvector.NewPGVectorStore(db, 1536,
+//		pgvector.WithTableName("docs"),
+//		pgvector.WithIndex(pgvector.IndexConfig{Type: pgvector.IndexHNSW}),
+//	)
+//	if err := vs.CreateTable(ctx); err != nil { ... }
+//	if err := vs.CreateIndex(ctx); err != nil { ... }
+//
+//	sc, _ := storage.NewStorageContextFromOptions(ctx, storage.StorageContextOptions{VectorStore: vs})
+package pgvector
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/rag/store"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// DefaultTableName is the table used when none is configured.
+const DefaultTableName = "llamaindex_vectors"
+
+// DistanceMetric is the similarity measure used for queries and indexes.
+type DistanceMetric string
+
+const (
+	// DistanceCosine ranks by cosine similarity. Scores are 1 - distance.
+	DistanceCosine DistanceMetric = "cosine"
+	// DistanceInnerProduct ranks by inner product. Scores are the inner
+	// product.
+	DistanceInnerProduct DistanceMetric = "inner_product"
+	// DistanceL2 ranks by Euclidean distance. Scores are 1 / (1 + distance).
+	DistanceL2 DistanceMetric = "l2"
+)
+
+// operator returns the pgvector distance operator.
+func (m DistanceMetric) operator() string {
+	switch m {
+	case DistanceInnerProduct:
+		return "<#>"
+	case DistanceL2:
+		return "<->"
+	default:
+		return "<=>"
+	}
+}
+
+// opsClass returns the pgvector index operator class.
+func (m DistanceMetric) opsClass() string {
+	switch m {
+	case DistanceInnerProduct:
+		return "vector_ip_ops"
+	case DistanceL2:
+		return "vector_l2_ops"
+	default:
+		return "vector_cosine_ops"
+	}
+}
+
+// score converts a pgvector distance into a similarity, higher is better.
+func (m DistanceMetric) score(distance float64) float64 {
+	switch m {
+	case DistanceInnerProduct:
+		// <#> returns the negative inner product.
+		return -distance
+	case DistanceL2:
+		return 1 / (1 + distance)
+	default:
+		return 1 - distance
+	}
+}
+
+// IndexType is an approximate nearest neighbor index type.
+type IndexType string
+
+const (
+	// IndexNone keeps exact (sequential scan) search.
+	IndexNone IndexType = ""
+	// IndexHNSW builds a graph index: better recall/speed trade-off, slower
+	// builds, no training data needed.
+	IndexHNSW IndexType = "hnsw"
+	// IndexIVFFlat builds an inverted-list index: fast builds, but should be
+	// created after the table holds representative data.
+	IndexIVFFlat IndexType = "ivfflat"
+)
+
+// IndexConfig configures the vector index.
+type IndexConfig struct {
+	// Type is the index type.
+	Type IndexType
+	// M is the HNSW max connections per layer. Defaults to 16.
+	M int
+	// EfConstruction is the HNSW candidate list size at build time.
+	// Defaults to 64.
+	EfConstruction int
+	// EfSearch is the HNSW candidate list size at query time. Zero keeps
+	// the server setting.
+	EfSearch int
+	// Lists is the number of IVFFlat lists. Defaults to 100.
+	Lists int
+	// Probes is the number of IVFFlat lists searched at query time. Zero
+	// keeps the server setting.
+	Probes int
+}
+
+// PGVectorStore is a vector store backed by a Postgres table with a
+// pgvector column. Nodes are upserted by ID, deleted by reference document,
+// and queried with metadata filters translated to JSONB conditions.
+type PGVectorStore struct {
+	db         *sql.DB
+	table      string
+	dimensions int
+	distance   DistanceMetric
+	index      IndexConfig
+}
+
+// PGVectorStoreOption is a functional option for PGVectorStore.
+type PGVectorStoreOption func(*PGVectorStore)
+
+// WithTableName sets the table, optionally schema-qualified
+// ("rag.vectors"). Defaults to DefaultTableName.
+func WithTableName(table string) PGVectorStoreOption {
+	return func(s *PGVectorStore) {
+		s.table = table
+	}
+}
+
+// WithDistance sets the distance metric. Defaults to DistanceCosine.
+func WithDistance(metric DistanceMetric) PGVectorStoreOption {
+	return func(s *PGVectorStore) {
+		s.distance = metric
+	}
+}
+
+// WithIndex sets the vector index created by CreateIndex and its query-time
+// parameters.
+func WithIndex(config IndexConfig) PGVectorStoreOption {
+	return func(s *PGVectorStore) {
+		s.index = config
+	}
+}
+
+// tableNamePattern allows plain or schema-qualified identifiers.
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// NewPGVectorStore creates a new PGVectorStore for embeddings of the given
+// dimensions. It does not touch the database; call CreateTable and
+// CreateIndex to set up the schema.
+func NewPGVectorStore(db *sql.DB, dimensions int, opts ...PGVectorStoreOption) (*PGVectorStore, error) {
+	s := &PGVectorStore{
+		db:         db,
+		table:      DefaultTableName,
+		dimensions: dimensions,
+		distance:   DistanceCosine,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if db == nil {
+		return nil, fmt.Errorf("pgvector: db is nil")
+	}
+	if dimensions <= 0 {
+		return nil, fmt.Errorf("pgvector: dimensions must be positive, got %d", dimensions)
+	}
+	if !tableNamePattern.MatchString(s.table) {
+		return nil, fmt.Errorf("pgvector: invalid table name %q", s.table)
+	}
+	switch s.distance {
+	case DistanceCosine, DistanceInnerProduct, DistanceL2:
+	default:
+		return nil, fmt.Errorf("pgvector: unsupported distance metric %q", s.distance)
+	}
+	switch s.index.Type {
+	case IndexNone, IndexHNSW, IndexIVFFlat:
+	default:
+		return nil, fmt.Errorf("pgvector: unsupported index type %q", s.index.Type)
+	}
+
+	return s, nil
+}
+
+// TableName returns the table name.
+func (s *PGVectorStore) TableName() string {
+	return s.table
+}
+
+// CreateTable creates the vector extension, the table and its ref_doc_id
+// index if they do not exist.
+func (s *PGVectorStore) CreateTable(ctx context.Context) error {
+	statements := []string{
+		"CREATE EXTENSION IF NOT EXISTS vector",
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id TEXT PRIMARY KEY,
+	ref_doc_id TEXT,
+	text TEXT NOT NULL DEFAULT '',
+	node_type TEXT NOT NULL DEFAULT '',
+	metadata JSONB NOT NULL DEFAULT '{}',
+	embedding vector(%d) NOT NULL
+)`, s.table, s.dimensions),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (ref_doc_id)", s.indexName("ref_doc_id"), s.table),
+	}
+	for _, stmt := range statements {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("pgvector: failed to create table: %w", err)
+		}
+	}
+	return nil
+}
+
+// CreateIndex creates the configured vector index if it does not exist. It
+// is a no-op for IndexNone.
+func (s *PGVectorStore) CreateIndex(ctx context.Context) error {
+	var stmt string
+	switch s.index.Type {
+	case IndexHNSW:
+		m, ef := s.index.M, s.index.EfConstruction
+		if m <= 0 {
+			m = 16
+		}
+		if ef <= 0 {
+			ef = 64
+		}
+		stmt = fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING hnsw (embedding %s) WITH (m = %d, ef_construction = %d)",
+			s.indexName("embedding_hnsw"), s.table, s.distance.opsClass(), m, ef)
+	case IndexIVFFlat:
+		lists := s.index.Lists
+		if lists <= 0 {
+			lists = 100
+		}
+		stmt = fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING ivfflat (embedding %s) WITH (lists = %d)",
+			s.indexName("embedding_ivfflat"), s.table, s.distance.opsClass(), lists)
+	default:
+		return nil
+	}
+
+	if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("pgvector: failed to create index: %w", err)
+	}
+	return nil
+}
+
+// indexName derives an index name from the table name.
+func (s *PGVectorStore) indexName(suffix string) string {
+	name := s.table
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name + "_" + suffix + "_idx"
+}
+
+// Add upserts nodes by ID in one transaction.
+func (s *PGVectorStore) Add(ctx context.Context, nodes []schema.Node) ([]string, error) {
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("pgvector: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt := fmt.Sprintf(`INSERT INTO %s (id, ref_doc_id, text, node_type, metadata, embedding)
+VALUES ($1, $2, $3, $4, $5::jsonb, $6::vector)
+ON CONFLICT (id) DO UPDATE SET ref_doc_id = EXCLUDED.ref_doc_id, text = EXCLUDED.text,
+	node_type = EXCLUDED.node_type, metadata = EXCLUDED.metadata, embedding = EXCLUDED.embedding`, s.table)
+
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		if len(node.Embedding) == 0 {
+			return nil, fmt.Errorf("pgvector: node %s has no embedding", node.ID)
+		}
+		if len(node.Embedding) != s.dimensions {
+			return nil, fmt.Errorf("pgvector: node %s has %d dimensions, want %d", node.ID, len(node.Embedding), s.dimensions)
+		}
+
+		metadata := node.Metadata
+		if metadata == nil {
+			metadata = map[string]interface{}{}
+		}
+		metadataJSON, err := json.Marshal(metadata)
+		if err != nil {
+			return nil, fmt.Errorf("pgvector: failed to encode metadata of node %s: %w", node.ID, err)
+		}
+
+		var refDocID sql.NullString
+		if source := node.Relationships.GetSource(); source != nil && source.NodeID != "" {
+			refDocID = sql.NullString{String: source.NodeID, Valid: true}
+		}
+
+		if _, err := tx.ExecContext(ctx, stmt, node.ID, refDocID, node.Text, string(node.Type),
+			string(metadataJSON), vectorLiteral(node.Embedding)); err != nil {
+			return nil, fmt.Errorf("pgvector: failed to add node %s: %w", node.ID, err)
+		}
+		ids[i] = node.ID
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("pgvector: failed to commit: %w", err)
+	}
+	return ids, nil
+}
+
+// Delete removes all nodes of a reference document, and the node with that
+// ID if there is one.
+func (s *PGVectorStore) Delete(ctx context.Context, refDocID string) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE ref_doc_id = $1 OR id = $1", s.table)
+	if _, err := s.db.ExecContext(ctx, stmt, refDocID); err != nil {
+		return fmt.Errorf("pgvector: failed to delete %s: %w", refDocID, err)
+	}
+	return nil
+}
+
+// DeleteByFilter removes the nodes whose metadata matches filters and
+// returns how many were removed.
+func (s *PGVectorStore) DeleteByFilter(ctx context.Context, filters *schema.MetadataFilters) (int, error) {
+	if filters == nil {
+		return 0, store.ErrEmptyDeleteFilter
+	}
+	w := &whereBuilder{}
+	cond, err := w.filters(filters)
+	if err != nil {
+		return 0, err
+	}
+	if cond == "" {
+		return 0, store.ErrEmptyDeleteFilter
+	}
+	res, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s", s.table, cond), w.values...)
+	if err != nil {
+		return 0, fmt.Errorf("pgvector: failed to delete by filter: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return -1, nil
+	}
+	return int(n), nil
+}
+
+// Query returns the top-k nodes nearest to the query embedding that match
+// the filters, DocIDs (reference documents) and NodeIDs of the query.
+func (s *PGVectorStore) Query(ctx context.Context, query schema.VectorStoreQuery) ([]schema.NodeWithScore, error) {
+	embedding := query.GetEmbedding()
+	if len(embedding) != s.dimensions {
+		return nil, fmt.Errorf("pgvector: query has %d dimensions, want %d", len(embedding), s.dimensions)
+	}
+
+	w := &whereBuilder{}
+	vectorArg := w.arg(vectorLiteral(embedding))
+	var conditions []string
+	if query.Filters != nil {
+		cond, err := w.filters(query.Filters)
+		if err != nil {
+			return nil, err
+		}
+		if cond != "" {
+			conditions = append(conditions, cond)
+		}
+	}
+	if len(query.DocIDs) > 0 {
+		conditions = append(conditions, "ref_doc_id IN ("+w.args(stringsToArgs(query.DocIDs))+")")
+	}
+	if len(query.NodeIDs) > 0 {
+		conditions = append(conditions, "id IN ("+w.args(stringsToArgs(query.NodeIDs))+")")
+	}
+
+	sqlQuery := fmt.Sprintf("SELECT id, ref_doc_id, text, node_type, metadata, embedding %s %s::vector AS distance FROM %s",
+		s.distance.operator(), vectorArg, s.table)
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	sqlQuery += fmt.Sprintf(" ORDER BY distance LIMIT %d", query.GetTopK())
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("pgvector: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if s.index.Type == IndexHNSW && s.index.EfSearch > 0 {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL hnsw.ef_search = %d", s.index.EfSearch)); err != nil {
+			return nil, fmt.Errorf("pgvector: failed to set ef_search: %w", err)
+		}
+	}
+	if s.index.Type == IndexIVFFlat && s.index.Probes > 0 {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL ivfflat.probes = %d", s.index.Probes)); err != nil {
+			return nil, fmt.Errorf("pgvector: failed to set probes: %w", err)
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, sqlQuery, w.values...)
+	if err != nil {
+		return nil, fmt.Errorf("pgvector: query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var results []schema.NodeWithScore
+	for rows.Next() {
+		var (
+			id, text, nodeType string
+			refDocID           sql.NullString
+			metadataJSON       []byte
+			distance           float64
+		)
+		if err := rows.Scan(&id, &refDocID, &text, &nodeType, &metadataJSON, &distance); err != nil {
+			return nil, fmt.Errorf("pgvector: failed to scan row: %w", err)
+		}
+		node, err := rowToNode(id, refDocID, text, nodeType, metadataJSON)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, schema.NodeWithScore{Node: *node, Score: s.distance.score(distance)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("pgvector: query failed: %w", err)
+	}
+	return results, nil
+}
+
+// ListNodes returns all nodes in the table, including embeddings.
+func (s *PGVectorStore) ListNodes(ctx context.Context) ([]schema.Node, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT id, ref_doc_id, text, node_type, metadata, embedding::text FROM %s ORDER BY id", s.table))
+	if err != nil {
+		return nil, fmt.Errorf("pgvector: failed to list nodes: %w", err)
+	}
+	defer rows.Close()
+
+	var nodes []schema.Node
+	for rows.Next() {
+		var (
+			id, text, nodeType, embedding string
+			refDocID                      sql.NullString
+			metadataJSON                  []byte
+		)
+		if err := rows.Scan(&id, &refDocID, &text, &nodeType, &metadataJSON, &embedding); err != nil {
+			return nil, fmt.Errorf("pgvector: failed to scan row: %w", err)
+		}
+		node, err := rowToNode(id, refDocID, text, nodeType, metadataJSON)
+		if err != nil {
+			return nil, err
+		}
+		if node.Embedding, err = parseVector(embedding); err != nil {
+			return nil, fmt.Errorf("pgvector: node %s: %w", id, err)
+		}
+		nodes = append(nodes, *node)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("pgvector: failed to list nodes: %w", err)
+	}
+	return nodes, nil
+}
+
+// rowToNode rebuilds a node from its columns.
+func rowToNode(id string, refDocID sql.NullString, text, nodeType string, metadataJSON []byte) (*schema.Node, error) {
+	node := schema.NewTextNode(text)
+	node.ID = id
+	if nodeType != "" {
+		node.Type = schema.NodeType(nodeType)
+	}
+	if len(metadataJSON) > 0 {
+		if err := json.Unmarshal(metadataJSON, &node.Metadata); err != nil {
+			return nil, fmt.Errorf("pgvector: failed to decode metadata of node %s: %w", id, err)
+		}
+	}
+	if refDocID.Valid && refDocID.String != "" {
+		node.Relationships.SetSource(schema.RelatedNodeInfo{NodeID: refDocID.String})
+	}
+	return node, nil
+}
+
+// vectorLiteral formats an embedding as a pgvector literal, e.g. "[1,2.5]".
+func vectorLiteral(v []float64) string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i, x := range v {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatFloat(x, 'g', -1, 32))
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+// parseVector parses a pgvector literal.
+func parseVector(s string) ([]float64, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("invalid vector %q", s)
+	}
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	v := make([]float64, len(parts))
+	for i, p := range parts {
+		x, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vector %q: %w", s, err)
+		}
+		v[i] = x
+	}
+	return v, nil
+}
+
+func stringsToArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+// Ensure PGVectorStore implements the store interfaces.
+var (
+	_ store.VectorStore = (*PGVectorStore)(nil)
+	_ store.NodeLister  = (*PGVectorStore)(nil)
+)
@@ -0,0 +1,368 @@
+package pgvector
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+
+	"github.com/aqua777/go-llamaindex/rag/store"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDB is a minimal database/sql driver that records statements and
+// answers every query with fixed rows.
+type fakeDB struct {
+	execs     []string
+	execArgs  [][]driver.Value
+	queries   []string
+	queryArgs [][]driver.Value
+	commits   int
+	columns   []string
+	rows      [][]driver.Value
+}
+
+func (f *fakeDB) Connect(ctx context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                            { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *fakeConn) Commit() error             { c.db.commits++; return nil }
+func (c *fakeConn) Rollback() error           { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.execs = append(s.db.execs, s.query)
+	s.db.execArgs = append(s.db.execArgs, args)
+	return driver.RowsAffected(1), nil
+}
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.queries = append(s.db.queries, s.query)
+	s.db.queryArgs = append(s.db.queryArgs, args)
+	return &fakeRows{columns: s.db.columns, rows: s.db.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func newTestStore(t *testing.T, opts ...PGVectorStoreOption) (*PGVectorStore, *fakeDB) {
+	t.Helper()
+	fake := &fakeDB{}
+	db := sql.OpenDB(fake)
+	t.Cleanup(func() { db.Close() })
+	s, err := NewPGVectorStore(db, 3, opts...)
+	require.NoError(t, err)
+	return s, fake
+}
+
+func TestNewPGVectorStore(t *testing.T) {
+	db := sql.OpenDB(&fakeDB{})
+	defer db.Close()
+
+	s, err := NewPGVectorStore(db, 3)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTableName, s.TableName())
+
+	_, err = NewPGVectorStore(db, 3, WithTableName("rag.docs"))
+	assert.NoError(t, err)
+
+	_, err = NewPGVectorStore(db, 3, WithTableName("docs; DROP TABLE x"))
+	assert.Error(t, err)
+	_, err = NewPGVectorStore(db, 0)
+	assert.Error(t, err)
+	_, err = NewPGVectorStore(nil, 3)
+	assert.Error(t, err)
+	_, err = NewPGVectorStore(db, 3, WithDistance("manhattan"))
+	assert.Error(t, err)
+	_, err = NewPGVectorStore(db, 3, WithIndex(IndexConfig{Type: "diskann"}))
+	assert.Error(t, err)
+}
+
+func TestCreateTableAndIndex(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("table", func(t *testing.T) {
+		s, fake := newTestStore(t, WithTableName("rag.docs"))
+		require.NoError(t, s.CreateTable(ctx))
+		require.Len(t, fake.execs, 3)
+		assert.Equal(t, "CREATE EXTENSION IF NOT EXISTS vector", fake.execs[0])
+		assert.Contains(t, fake.execs[1], "CREATE TABLE IF NOT EXISTS rag.docs")
+		assert.Contains(t, fake.execs[1], "embedding vector(3) NOT NULL")
+		assert.Contains(t, fake.execs[2], "docs_ref_doc_id_idx ON rag.docs (ref_doc_id)")
+	})
+
+	t.Run("hnsw", func(t *testing.T) {
+		s, fake := newTestStore(t, WithIndex(IndexConfig{Type: IndexHNSW}), WithDistance(DistanceInnerProduct))
+		require.NoError(t, s.CreateIndex(ctx))
+		require.Len(t, fake.execs, 1)
+		assert.Contains(t, fake.execs[0], "USING hnsw (embedding vector_ip_ops) WITH (m = 16, ef_construction = 64)")
+	})
+
+	t.Run("ivfflat", func(t *testing.T) {
+		s, fake := newTestStore(t, WithIndex(IndexConfig{Type: IndexIVFFlat, Lists: 50}), WithDistance(DistanceL2))
+		require.NoError(t, s.CreateIndex(ctx))
+		require.Len(t, fake.execs, 1)
+		assert.Contains(t, fake.execs[0], "USING ivfflat (embedding vector_l2_ops) WITH (lists = 50)")
+	})
+
+	t.Run("none", func(t *testing.T) {
+		s, fake := newTestStore(t)
+		require.NoError(t, s.CreateIndex(ctx))
+		assert.Empty(t, fake.execs)
+	})
+}
+
+func TestAddAndDelete(t *testing.T) {
+	ctx := context.Background()
+	s, fake := newTestStore(t)
+
+	node := schema.NewTextNode("hello")
+	node.ID = "n1"
+	node.Metadata["lang"] = "en"
+	node.Embedding = []float64{0.1, 0.2, 0.3}
+	node.Relationships.SetSource(schema.RelatedNodeInfo{NodeID: "doc1"})
+
+	ids, err := s.Add(ctx, []schema.Node{*node})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"n1"}, ids)
+	require.Len(t, fake.execs, 1)
+	assert.Contains(t, fake.execs[0], "ON CONFLICT (id) DO UPDATE")
+	assert.Equal(t, []driver.Value{"n1", "doc1", "hello", "TEXT", `{"lang":"en"}`, "[0.1,0.2,0.3]"}, fake.execArgs[0])
+	assert.Equal(t, 1, fake.commits)
+
+	bad := *node
+	bad.Embedding = []float64{1}
+	_, err = s.Add(ctx, []schema.Node{bad})
+	assert.Error(t, err)
+	bad.Embedding = nil
+	_, err = s.Add(ctx, []schema.Node{bad})
+	assert.Error(t, err)
+
+	require.NoError(t, s.Delete(ctx, "doc1"))
+	last := len(fake.execs) - 1
+	assert.Contains(t, fake.execs[last], "WHERE ref_doc_id = $1 OR id = $1")
+	assert.Equal(t, []driver.Value{"doc1"}, fake.execArgs[last])
+}
+
+func TestDeleteByFilter(t *testing.T) {
+	ctx := context.Background()
+	s, fake := newTestStore(t)
+
+	n, err := s.DeleteByFilter(ctx, schema.NewMetadataFilters(
+		schema.NewMetadataFilterWithOp("expires_at", 1700000000, schema.FilterOperatorLte),
+	))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	require.Len(t, fake.execs, 1)
+	assert.Equal(t, "DELETE FROM llamaindex_vectors WHERE (metadata->$1::text <= $2::jsonb)", fake.execs[0])
+	assert.Equal(t, []driver.Value{"expires_at", "1700000000"}, fake.execArgs[0])
+
+	_, err = s.DeleteByFilter(ctx, nil)
+	assert.ErrorIs(t, err, store.ErrEmptyDeleteFilter)
+	_, err = s.DeleteByFilter(ctx, schema.NewMetadataFilters())
+	assert.ErrorIs(t, err, store.ErrEmptyDeleteFilter)
+	assert.Len(t, fake.execs, 1)
+}
+
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+	columns := []string{"id", "ref_doc_id", "text", "node_type", "metadata", "distance"}
+
+	t.Run("cosine", func(t *testing.T) {
+		s, fake := newTestStore(t)
+		fake.columns = columns
+		fake.rows = [][]driver.Value{
+			{"n1", "doc1", "hello", "TEXT", []byte(`{"lang":"en"}`), 0.1},
+			{"n2", nil, "bye", "TEXT", []byte(`{}`), 0.4},
+		}
+
+		results, err := s.Query(ctx, schema.VectorStoreQuery{
+			Embedding: []float64{1, 0, 0},
+			TopK:      2,
+			Filters:   schema.NewMetadataFilters(schema.NewMetadataFilter("lang", "en")),
+			DocIDs:    []string{"doc1", "doc2"},
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, "n1", results[0].Node.ID)
+		assert.InDelta(t, 0.9, results[0].Score, 1e-9)
+		assert.Equal(t, "en", results[0].Node.Metadata["lang"])
+		assert.Equal(t, "doc1", results[0].Node.Relationships.GetSource().NodeID)
+		assert.Nil(t, results[1].Node.Relationships.GetSource())
+		assert.InDelta(t, 0.6, results[1].Score, 1e-9)
+
+		require.Len(t, fake.queries, 1)
+		assert.Equal(t, "SELECT id, ref_doc_id, text, node_type, metadata, embedding <=> $1::vector AS distance FROM llamaindex_vectors"+
+			" WHERE (metadata->$2::text = $3::jsonb) AND ref_doc_id IN ($4, $5) ORDER BY distance LIMIT 2", fake.queries[0])
+		assert.Equal(t, []driver.Value{"[1,0,0]", "lang", `"en"`, "doc1", "doc2"}, fake.queryArgs[0])
+	})
+
+	t.Run("scores", func(t *testing.T) {
+		for metric, want := range map[DistanceMetric]float64{
+			DistanceCosine:       0.75,
+			DistanceInnerProduct: -0.25,
+			DistanceL2:           0.8,
+		} {
+			s, fake := newTestStore(t, WithDistance(metric))
+			fake.columns = columns
+			fake.rows = [][]driver.Value{{"n1", nil, "x", "TEXT", []byte(`{}`), 0.25}}
+			results, err := s.Query(ctx, schema.VectorStoreQuery{Embedding: []float64{1, 0, 0}})
+			require.NoError(t, err)
+			assert.InDelta(t, want, results[0].Score, 1e-9, metric)
+			assert.Contains(t, fake.queries[0], "embedding "+metric.operator()+" $1::vector")
+		}
+	})
+
+	t.Run("query-time index parameters", func(t *testing.T) {
+		s, fake := newTestStore(t, WithIndex(IndexConfig{Type: IndexHNSW, EfSearch: 100}))
+		fake.columns = columns
+		_, err := s.Query(ctx, schema.VectorStoreQuery{Embedding: []float64{1, 0, 0}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"SET LOCAL hnsw.ef_search = 100"}, fake.execs)
+	})
+
+	t.Run("dimension mismatch", func(t *testing.T) {
+		s, _ := newTestStore(t)
+		_, err := s.Query(ctx, schema.VectorStoreQuery{Embedding: []float64{1}})
+		assert.Error(t, err)
+	})
+}
+
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters *schema.MetadataFilters
+		sql     string
+		args    []interface{}
+	}{
+		{
+			name:    "gt",
+			filters: schema.NewMetadataFilters(schema.NewMetadataFilterWithOp("year", 2020, schema.FilterOperatorGt)),
+			sql:     "(metadata->$1::text > $2::jsonb)",
+			args:    []interface{}{"year", "2020"},
+		},
+		{
+			name: "or",
+			filters: schema.NewMetadataFiltersWithCondition(schema.FilterConditionOr,
+				schema.NewMetadataFilter("lang", "en"),
+				schema.NewMetadataFilterWithOp("lang", "de", schema.FilterOperatorNe)),
+			sql:  "(metadata->$1::text = $2::jsonb OR metadata->$3::text <> $4::jsonb)",
+			args: []interface{}{"lang", `"en"`, "lang", `"de"`},
+		},
+		{
+			name:    "in",
+			filters: schema.NewMetadataFilters(schema.NewMetadataFilterWithOp("tag", []string{"a", "b"}, schema.FilterOperatorIn)),
+			sql:     "(metadata->$3::text IN ($1::jsonb, $2::jsonb))",
+			args:    []interface{}{`"a"`, `"b"`, "tag"},
+		},
+		{
+			name:    "empty nin",
+			filters: schema.NewMetadataFilters(schema.NewMetadataFilterWithOp("tag", []string{}, schema.FilterOperatorNin)),
+			sql:     "(TRUE)",
+		},
+		{
+			name:    "any",
+			filters: schema.NewMetadataFilters(schema.NewMetadataFilterWithOp("tags", []string{"x", "y"}, schema.FilterOperatorAny)),
+			sql:     "((metadata->$2::text @> $1::jsonb OR metadata->$2::text @> $3::jsonb))",
+			args:    []interface{}{`["x"]`, "tags", `["y"]`},
+		},
+		{
+			name:    "all",
+			filters: schema.NewMetadataFilters(schema.NewMetadataFilterWithOp("tags", []string{"x", "y"}, schema.FilterOperatorAll)),
+			sql:     "(metadata->$2::text @> $1::jsonb)",
+			args:    []interface{}{`["x","y"]`, "tags"},
+		},
+		{
+			name:    "contains",
+			filters: schema.NewMetadataFilters(schema.NewMetadataFilterWithOp("tags", "x", schema.FilterOperatorContains)),
+			sql:     "((jsonb_typeof(metadata->$2::text) = 'array' AND metadata->$2::text @> $1::jsonb))",
+			args:    []interface{}{`["x"]`, "tags"},
+		},
+		{
+			name:    "text match",
+			filters: schema.NewMetadataFilters(schema.NewMetadataFilterWithOp("title", "50%_off", schema.FilterOperatorTextMatchInsensitive)),
+			sql:     "(metadata->>$1::text ILIKE $2)",
+			args:    []interface{}{"title", `%50\%\_off%`},
+		},
+		{
+			name:    "is empty",
+			filters: schema.NewMetadataFilters(schema.NewMetadataFilterWithOp("notes", nil, schema.FilterOperatorIsEmpty)),
+			sql:     `((metadata->$1::text IS NULL OR metadata->$1::text IN ('null'::jsonb, '""'::jsonb, '[]'::jsonb)))`,
+			args:    []interface{}{"notes"},
+		},
+		{
+			name: "nested not",
+			filters: schema.NewMetadataFilters(schema.NewMetadataFilter("lang", "en")).
+				AddNested(schema.NewMetadataFiltersWithCondition(schema.FilterConditionNot, schema.NewMetadataFilter("draft", true))),
+			sql:  "(metadata->$1::text = $2::jsonb AND NOT (metadata->$3::text = $4::jsonb))",
+			args: []interface{}{"lang", `"en"`, "draft", "true"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &whereBuilder{}
+			sql, err := w.filters(tt.filters)
+			require.NoError(t, err)
+			assert.Equal(t, tt.sql, sql)
+			assert.Equal(t, tt.args, w.values)
+		})
+	}
+
+	_, err := (&whereBuilder{}).filters(schema.NewMetadataFilters(schema.NewMetadataFilterWithOp("tag", "a", schema.FilterOperatorIn)))
+	assert.Error(t, err)
+	_, err = (&whereBuilder{}).filters(schema.NewMetadataFilters(schema.NewMetadataFilterWithOp("tag", "a", "regex")))
+	assert.Error(t, err)
+}
+
+func TestListNodes(t *testing.T) {
+	s, fake := newTestStore(t)
+	fake.columns = []string{"id", "ref_doc_id", "text", "node_type", "metadata", "embedding"}
+	fake.rows = [][]driver.Value{{"n1", "doc1", "hello", "TEXT", []byte(`{"n":1}`), "[0.5,1,-2]"}}
+
+	nodes, err := s.ListNodes(context.Background())
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, []float64{0.5, 1, -2}, nodes[0].Embedding)
+	assert.Equal(t, float64(1), nodes[0].Metadata["n"])
+}
+
+func TestStorageContext(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+
+	sc, err := storage.NewStorageContextFromOptions(ctx, storage.StorageContextOptions{VectorStore: s})
+	require.NoError(t, err)
+	assert.Same(t, s, sc.VectorStore())
+
+	// Persisting the context leaves the database-backed store alone.
+	require.NoError(t, sc.Persist(ctx, t.TempDir()))
+}
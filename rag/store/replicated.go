@@ -0,0 +1,350 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// HealthChecker is optionally implemented by vector stores that can report
+// whether their backend is reachable.
+type HealthChecker interface {
+	// HealthCheck returns an error if the store cannot serve requests.
+	HealthCheck(ctx context.Context) error
+}
+
+// EndpointRole identifies the role of an endpoint in a ReplicatedVectorStore.
+type EndpointRole string
+
+const (
+	// EndpointRolePrimary receives writes and serves reads as a fallback.
+	EndpointRolePrimary EndpointRole = "primary"
+	// EndpointRoleReplica serves reads.
+	EndpointRoleReplica EndpointRole = "replica"
+)
+
+// EndpointStatus reports the health of one endpoint.
+type EndpointStatus struct {
+	// Name identifies the endpoint.
+	Name string `json:"name"`
+	// Role is the endpoint's role.
+	Role EndpointRole `json:"role"`
+	// Healthy is false while the endpoint is cooling down after a failure.
+	Healthy bool `json:"healthy"`
+	// ConsecutiveFailures counts failures since the last success.
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// LastError is the most recent error, if any.
+	LastError string `json:"last_error,omitempty"`
+	// LastChecked is when the endpoint was last used or health checked.
+	LastChecked time.Time `json:"last_checked,omitempty"`
+}
+
+// endpoint tracks the state of one backend store.
+type endpoint struct {
+	name  string
+	role  EndpointRole
+	store VectorStore
+
+	mu            sync.Mutex
+	failures      int
+	lastErr       error
+	lastChecked   time.Time
+	unhealthyTill time.Time
+}
+
+func (e *endpoint) healthy(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !now.Before(e.unhealthyTill)
+}
+
+func (e *endpoint) record(err error, now time.Time, cooldown time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastChecked = now
+	if err == nil {
+		e.failures = 0
+		e.lastErr = nil
+		e.unhealthyTill = time.Time{}
+		return
+	}
+	e.failures++
+	e.lastErr = err
+	e.unhealthyTill = now.Add(cooldown)
+}
+
+func (e *endpoint) status(now time.Time) EndpointStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	status := EndpointStatus{
+		Name:                e.name,
+		Role:                e.role,
+		Healthy:             !now.Before(e.unhealthyTill),
+		ConsecutiveFailures: e.failures,
+		LastChecked:         e.lastChecked,
+	}
+	if e.lastErr != nil {
+		status.LastError = e.lastErr.Error()
+	}
+	return status
+}
+
+// ReplicatedVectorStore sends writes to a primary store and balances queries
+// across read replicas. A replica that fails a query is marked unhealthy for
+// a cooldown period and the query is retried on the next endpoint; when no
+// replica can answer, the primary serves the read.
+type ReplicatedVectorStore struct {
+	primary  *endpoint
+	replicas []*endpoint
+
+	next              atomic.Uint64
+	maxAttempts       int
+	cooldown          time.Duration
+	replicateWrites   bool
+	fallbackToPrimary bool
+}
+
+// ReplicatedVectorStoreOption configures a ReplicatedVectorStore.
+type ReplicatedVectorStoreOption func(*ReplicatedVectorStore)
+
+// WithReplicaMaxAttempts sets how many endpoints a query tries before
+// failing, including the primary fallback. Defaults to trying every endpoint.
+func WithReplicaMaxAttempts(attempts int) ReplicatedVectorStoreOption {
+	return func(s *ReplicatedVectorStore) {
+		s.maxAttempts = attempts
+	}
+}
+
+// WithReplicaCooldown sets how long a failed endpoint is skipped.
+// Defaults to 30 seconds.
+func WithReplicaCooldown(cooldown time.Duration) ReplicatedVectorStoreOption {
+	return func(s *ReplicatedVectorStore) {
+		s.cooldown = cooldown
+	}
+}
+
+// WithReplicateWrites also applies writes to every replica. Use this for
+// backends without native replication, e.g. in-memory stores.
+func WithReplicateWrites(replicate bool) ReplicatedVectorStoreOption {
+	return func(s *ReplicatedVectorStore) {
+		s.replicateWrites = replicate
+	}
+}
+
+// WithPrimaryReadFallback sets whether the primary serves reads when no
+// replica can. Defaults to true.
+func WithPrimaryReadFallback(fallback bool) ReplicatedVectorStoreOption {
+	return func(s *ReplicatedVectorStore) {
+		s.fallbackToPrimary = fallback
+	}
+}
+
+// NewReplicatedVectorStore creates a ReplicatedVectorStore. With no replicas,
+// all reads go to the primary.
+func NewReplicatedVectorStore(primary VectorStore, replicas []VectorStore, opts ...ReplicatedVectorStoreOption) *ReplicatedVectorStore {
+	s := &ReplicatedVectorStore{
+		primary:           &endpoint{name: "primary", role: EndpointRolePrimary, store: primary},
+		cooldown:          30 * time.Second,
+		fallbackToPrimary: true,
+	}
+	for i, replica := range replicas {
+		s.replicas = append(s.replicas, &endpoint{
+			name:  fmt.Sprintf("replica-%d", i),
+			role:  EndpointRoleReplica,
+			store: replica,
+		})
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Add writes nodes to the primary, and to the replicas if write replication
+// is enabled.
+func (s *ReplicatedVectorStore) Add(ctx context.Context, nodes []schema.Node) ([]string, error) {
+	ids, err := s.primary.store.Add(ctx, nodes)
+	if err != nil {
+		return nil, err
+	}
+	if s.replicateWrites {
+		for _, replica := range s.replicas {
+			if _, err := replica.store.Add(ctx, nodes); err != nil {
+				return ids, fmt.Errorf("failed to replicate write to %s: %w", replica.name, err)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// Delete removes a node from the primary, and from the replicas if write
+// replication is enabled.
+func (s *ReplicatedVectorStore) Delete(ctx context.Context, refDocID string) error {
+	if err := s.primary.store.Delete(ctx, refDocID); err != nil {
+		return err
+	}
+	if s.replicateWrites {
+		for _, replica := range s.replicas {
+			if err := replica.store.Delete(ctx, refDocID); err != nil {
+				return fmt.Errorf("failed to replicate delete to %s: %w", replica.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteByFilter removes the matching nodes from the primary, and from the
+// replicas if write replication is enabled. It returns the primary's count.
+func (s *ReplicatedVectorStore) DeleteByFilter(ctx context.Context, filters *schema.MetadataFilters) (int, error) {
+	n, err := s.primary.store.DeleteByFilter(ctx, filters)
+	if err != nil {
+		return 0, err
+	}
+	if s.replicateWrites {
+		for _, replica := range s.replicas {
+			if _, err := replica.store.DeleteByFilter(ctx, filters); err != nil {
+				return n, fmt.Errorf("failed to replicate delete to %s: %w", replica.name, err)
+			}
+		}
+	}
+	return n, nil
+}
+
+// Query runs the query on a healthy replica, failing over to other
+// replicas and then the primary on error.
+func (s *ReplicatedVectorStore) Query(ctx context.Context, query schema.VectorStoreQuery) ([]schema.NodeWithScore, error) {
+	candidates := s.readOrder()
+
+	maxAttempts := s.maxAttempts
+	if maxAttempts <= 0 || maxAttempts > len(candidates) {
+		maxAttempts = len(candidates)
+	}
+
+	var errs []error
+	for _, ep := range candidates[:maxAttempts] {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		results, err := ep.store.Query(ctx, query)
+		if isCallerError(ctx, err) {
+			return nil, err
+		}
+		ep.record(err, time.Now(), s.cooldown)
+		if err == nil {
+			return results, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", ep.name, err))
+	}
+
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no healthy endpoint available")
+	}
+	return nil, fmt.Errorf("query failed on all endpoints: %w", errors.Join(errs...))
+}
+
+// isCallerError reports whether err is due to the caller cancelling ctx or
+// its deadline passing, rather than a failure of the endpoint.
+func isCallerError(ctx context.Context, err error) bool {
+	return err != nil && (ctx.Err() != nil || errors.Is(err, context.Canceled))
+}
+
+// readOrder returns the endpoints to try for a read: healthy replicas in
+// round-robin order, then the primary, then unhealthy replicas as a last
+// resort so a fully degraded cluster is still probed.
+func (s *ReplicatedVectorStore) readOrder() []*endpoint {
+	now := time.Now()
+	var healthy, unhealthy []*endpoint
+
+	if n := len(s.replicas); n > 0 {
+		start := int(s.next.Add(1)-1) % n
+		for i := 0; i < n; i++ {
+			ep := s.replicas[(start+i)%n]
+			if ep.healthy(now) {
+				healthy = append(healthy, ep)
+			} else {
+				unhealthy = append(unhealthy, ep)
+			}
+		}
+	}
+
+	order := healthy
+	if s.fallbackToPrimary || len(s.replicas) == 0 {
+		order = append(order, s.primary)
+	}
+	return append(order, unhealthy...)
+}
+
+// CheckHealth probes every endpoint implementing HealthChecker and updates
+// its status. Endpoints without health checks keep their current status.
+func (s *ReplicatedVectorStore) CheckHealth(ctx context.Context) []EndpointStatus {
+	var wg sync.WaitGroup
+	for _, ep := range s.endpoints() {
+		checker, ok := ep.store.(HealthChecker)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(ep *endpoint, checker HealthChecker) {
+			defer wg.Done()
+			if err := checker.HealthCheck(ctx); !isCallerError(ctx, err) {
+				ep.record(err, time.Now(), s.cooldown)
+			}
+		}(ep, checker)
+	}
+	wg.Wait()
+	return s.Status()
+}
+
+// StartHealthChecks runs CheckHealth every interval until ctx is cancelled.
+func (s *ReplicatedVectorStore) StartHealthChecks(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.CheckHealth(ctx)
+			}
+		}
+	}()
+}
+
+// HealthCheck returns an error unless at least one endpoint can serve reads.
+func (s *ReplicatedVectorStore) HealthCheck(ctx context.Context) error {
+	for _, status := range s.CheckHealth(ctx) {
+		if status.Healthy && (status.Role == EndpointRoleReplica || s.fallbackToPrimary || len(s.replicas) == 0) {
+			return nil
+		}
+	}
+	return fmt.Errorf("no healthy endpoint available")
+}
+
+// Status returns the current status of the primary and all replicas.
+func (s *ReplicatedVectorStore) Status() []EndpointStatus {
+	now := time.Now()
+	endpoints := s.endpoints()
+	statuses := make([]EndpointStatus, len(endpoints))
+	for i, ep := range endpoints {
+		statuses[i] = ep.status(now)
+	}
+	return statuses
+}
+
+func (s *ReplicatedVectorStore) endpoints() []*endpoint {
+	return append([]*endpoint{s.primary}, s.replicas...)
+}
+
+// Ensure ReplicatedVectorStore implements VectorStore and HealthChecker.
+var (
+	_ VectorStore   = (*ReplicatedVectorStore)(nil)
+	_ HealthChecker = (*ReplicatedVectorStore)(nil)
+)
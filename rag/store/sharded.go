@@ -0,0 +1,320 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// ShardRouter decides which shard a node is written to.
+type ShardRouter interface {
+	// Route returns the index of the shard, in [0, numShards), for the node.
+	Route(node schema.Node, numShards int) int
+}
+
+// ShardSelector is optionally implemented by a ShardRouter that can narrow
+// the shards a query must visit, e.g. when the query filters on the routing
+// key. Returning nil means all shards.
+type ShardSelector interface {
+	ShardsForQuery(query schema.VectorStoreQuery, numShards int) []int
+}
+
+// HashShardRouter spreads nodes evenly across shards by hashing the node ID.
+type HashShardRouter struct{}
+
+// Route implements ShardRouter.
+func (HashShardRouter) Route(node schema.Node, numShards int) int {
+	return hashShard(node.ID, numShards)
+}
+
+// MetadataShardRouter routes nodes by the value of a metadata key, e.g. a
+// tenant or language. Values listed in Shards go to the given shard; other
+// values are hashed so that equal values always share a shard. Nodes
+// without the key fall back to hashing the node ID.
+type MetadataShardRouter struct {
+	// Key is the metadata key to route on.
+	Key string
+	// Shards pins specific metadata values to shard indexes.
+	Shards map[string]int
+}
+
+// NewMetadataShardRouter creates a router on the given metadata key.
+func NewMetadataShardRouter(key string) *MetadataShardRouter {
+	return &MetadataShardRouter{
+		Key:    key,
+		Shards: make(map[string]int),
+	}
+}
+
+// Route implements ShardRouter.
+func (r *MetadataShardRouter) Route(node schema.Node, numShards int) int {
+	value, ok := node.Metadata[r.Key]
+	if !ok {
+		return hashShard(node.ID, numShards)
+	}
+	return r.shardForValue(fmt.Sprintf("%v", value), numShards)
+}
+
+// ShardsForQuery implements ShardSelector. When the query has an equality
+// filter on the routing key combined with AND, only that value's shard is
+// searched.
+func (r *MetadataShardRouter) ShardsForQuery(query schema.VectorStoreQuery, numShards int) []int {
+	if query.Filters == nil {
+		return nil
+	}
+	if c := query.Filters.Condition; c != "" && c != schema.FilterConditionAnd {
+		return nil
+	}
+	for _, filter := range query.Filters.Filters {
+		if filter.Key == r.Key && filter.Operator == schema.FilterOperatorEq {
+			return []int{r.shardForValue(fmt.Sprintf("%v", filter.Value), numShards)}
+		}
+	}
+	return nil
+}
+
+func (r *MetadataShardRouter) shardForValue(value string, numShards int) int {
+	if shard, ok := r.Shards[value]; ok && shard >= 0 && shard < numShards {
+		return shard
+	}
+	return hashShard(value, numShards)
+}
+
+func hashShard(key string, numShards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(numShards))
+}
+
+// ShardedVectorStore partitions nodes across several backend vector stores.
+// Writes are routed by a ShardRouter; queries fan out to the shards in
+// parallel and the results are merged by score.
+type ShardedVectorStore struct {
+	shards []VectorStore
+	router ShardRouter
+}
+
+// ShardedVectorStoreOption configures a ShardedVectorStore.
+type ShardedVectorStoreOption func(*ShardedVectorStore)
+
+// WithShardRouter sets the routing strategy. Defaults to HashShardRouter.
+func WithShardRouter(router ShardRouter) ShardedVectorStoreOption {
+	return func(s *ShardedVectorStore) {
+		s.router = router
+	}
+}
+
+// NewShardedVectorStore creates a ShardedVectorStore over the given shards.
+func NewShardedVectorStore(shards []VectorStore, opts ...ShardedVectorStoreOption) (*ShardedVectorStore, error) {
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("at least one shard is required")
+	}
+
+	s := &ShardedVectorStore{
+		shards: shards,
+		router: HashShardRouter{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+// Shards returns the backend stores.
+func (s *ShardedVectorStore) Shards() []VectorStore {
+	return s.shards
+}
+
+// ShardFor returns the index of the shard the node is routed to.
+func (s *ShardedVectorStore) ShardFor(node schema.Node) int {
+	return s.router.Route(node, len(s.shards))
+}
+
+// Add routes each node to its shard and writes the shards in parallel.
+// The returned IDs are in input order.
+func (s *ShardedVectorStore) Add(ctx context.Context, nodes []schema.Node) ([]string, error) {
+	batches := make(map[int][]schema.Node)
+	for _, node := range nodes {
+		if node.ID == "" {
+			return nil, fmt.Errorf("node ID cannot be empty")
+		}
+		shard := s.ShardFor(node)
+		batches[shard] = append(batches[shard], node)
+	}
+
+	shardIDs := make([]int, 0, len(batches))
+	for shard := range batches {
+		shardIDs = append(shardIDs, shard)
+	}
+
+	err := s.forEachShard(ctx, shardIDs, func(ctx context.Context, shard int) error {
+		_, err := s.shards[shard].Add(ctx, batches[shard])
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+	return ids, nil
+}
+
+// Query searches the relevant shards in parallel and returns the overall
+// top-k results.
+func (s *ShardedVectorStore) Query(ctx context.Context, query schema.VectorStoreQuery) ([]schema.NodeWithScore, error) {
+	shardIDs := s.allShards()
+	if selector, ok := s.router.(ShardSelector); ok {
+		if selected := selector.ShardsForQuery(query, len(s.shards)); selected != nil {
+			shardIDs = selected
+		}
+	}
+
+	results := make([][]schema.NodeWithScore, len(s.shards))
+	err := s.forEachShard(ctx, shardIDs, func(ctx context.Context, shard int) error {
+		nodes, err := s.shards[shard].Query(ctx, query)
+		results[shard] = nodes
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	topK := query.TopK
+	if topK == 0 {
+		topK = query.SimilarityTopK
+	}
+	return MergeShardResults(results, topK), nil
+}
+
+// Delete removes the node from every shard, since the shard that holds it
+// cannot be derived from the ID alone for every router.
+func (s *ShardedVectorStore) Delete(ctx context.Context, refDocID string) error {
+	return s.forEachShard(ctx, s.allShards(), func(ctx context.Context, shard int) error {
+		return s.shards[shard].Delete(ctx, refDocID)
+	})
+}
+
+// DeleteByFilter removes the matching nodes from every shard and returns
+// the total removed, or -1 if a shard cannot tell.
+func (s *ShardedVectorStore) DeleteByFilter(ctx context.Context, filters *schema.MetadataFilters) (int, error) {
+	if err := checkDeleteFilters(filters); err != nil {
+		return 0, err
+	}
+	counts := make([]int, len(s.shards))
+	err := s.forEachShard(ctx, s.allShards(), func(ctx context.Context, shard int) error {
+		n, err := s.shards[shard].DeleteByFilter(ctx, filters)
+		counts[shard] = n
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, n := range counts {
+		if n < 0 {
+			return -1, nil
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// ListNodes returns the nodes of all shards ordered by ID. Every shard must
+// implement NodeLister.
+func (s *ShardedVectorStore) ListNodes(ctx context.Context) ([]schema.Node, error) {
+	var all []schema.Node
+	for i, shard := range s.shards {
+		lister, ok := shard.(NodeLister)
+		if !ok {
+			return nil, fmt.Errorf("shard %d (%T) does not support listing nodes", i, shard)
+		}
+		nodes, err := lister.ListNodes(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("shard %d: %w", i, err)
+		}
+		all = append(all, nodes...)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].ID < all[j].ID
+	})
+	return all, nil
+}
+
+func (s *ShardedVectorStore) allShards() []int {
+	ids := make([]int, len(s.shards))
+	for i := range ids {
+		ids[i] = i
+	}
+	return ids
+}
+
+// forEachShard runs fn for each shard concurrently and returns the first
+// error. Remaining calls are cancelled once one fails.
+func (s *ShardedVectorStore) forEachShard(ctx context.Context, shardIDs []int, fn func(context.Context, int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for _, shard := range shardIDs {
+		wg.Add(1)
+		go func(shard int) {
+			defer wg.Done()
+			if err := fn(ctx, shard); err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("shard %d: %w", shard, err)
+					cancel()
+				})
+			}
+		}(shard)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// MergeShardResults merges per-shard results into a single list sorted by
+// descending score, keeping the best score for nodes returned by several
+// shards and truncating to topK when it is positive.
+func MergeShardResults(results [][]schema.NodeWithScore, topK int) []schema.NodeWithScore {
+	best := make(map[string]int)
+	var merged []schema.NodeWithScore
+	for _, nodes := range results {
+		for _, n := range nodes {
+			if i, ok := best[n.Node.ID]; ok {
+				if n.Score > merged[i].Score {
+					merged[i] = n
+				}
+				continue
+			}
+			best[n.Node.ID] = len(merged)
+			merged = append(merged, n)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Score > merged[j].Score
+	})
+
+	if topK > 0 && len(merged) > topK {
+		merged = merged[:topK]
+	}
+	return merged
+}
+
+// Ensure ShardedVectorStore implements VectorStore and NodeLister.
+var (
+	_ VectorStore = (*ShardedVectorStore)(nil)
+	_ NodeLister  = (*ShardedVectorStore)(nil)
+)
@@ -5,21 +5,26 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"sync"
 
+	"github.com/aqua777/go-llamaindex/embedding"
 	"github.com/aqua777/go-llamaindex/schema"
 )
 
-// SimpleVectorStore is a simple in-memory vector store.
+// SimpleVectorStore is a simple in-memory vector store. Besides single
+// embeddings it stores per-token embeddings for late-interaction queries.
 type SimpleVectorStore struct {
-	mu    sync.RWMutex
-	nodes map[string]schema.Node
+	mu           sync.RWMutex
+	nodes        map[string]schema.Node
+	multiVectors map[string][][]float64
 }
 
 // NewSimpleVectorStore creates a new SimpleVectorStore.
 func NewSimpleVectorStore() *SimpleVectorStore {
 	return &SimpleVectorStore{
-		nodes: make(map[string]schema.Node),
+		nodes:        make(map[string]schema.Node),
+		multiVectors: make(map[string][][]float64),
 	}
 }
 
@@ -50,21 +55,12 @@ func (s *SimpleVectorStore) Query(ctx context.Context, query schema.VectorStoreQ
 	var scores []scoreResult
 
 	for id, node := range s.nodes {
-		// Apply filters if present
-		if query.Filters != nil {
-			match := true
-			for _, filter := range query.Filters.Filters {
-				if filter.Operator == schema.FilterOperatorEq {
-					if val, ok := node.Metadata[filter.Key]; !ok || fmt.Sprintf("%v", val) != fmt.Sprintf("%v", filter.Value) {
-						match = false
-						break
-					}
-				}
-				// Add more operators as needed
-			}
-			if !match {
-				continue
-			}
+		match, err := MatchesFilters(node, query.Filters)
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
 		}
 
 		if len(node.Embedding) == 0 {
@@ -113,9 +109,119 @@ func (s *SimpleVectorStore) Delete(ctx context.Context, refDocID string) error {
 	defer s.mu.Unlock()
 
 	delete(s.nodes, refDocID)
+	delete(s.multiVectors, refDocID)
 	return nil
 }
 
+// DeleteByFilter removes the nodes matching filters and returns how many
+// were removed.
+func (s *SimpleVectorStore) DeleteByFilter(ctx context.Context, filters *schema.MetadataFilters) (int, error) {
+	if err := checkDeleteFilters(filters); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for id, node := range s.nodes {
+		match, err := MatchesFilters(node, filters)
+		if err != nil {
+			return 0, err
+		}
+		if match {
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range ids {
+		delete(s.nodes, id)
+		delete(s.multiVectors, id)
+	}
+	return len(ids), nil
+}
+
+// AddMultiVector adds nodes with their per-token embeddings.
+func (s *SimpleVectorStore) AddMultiVector(ctx context.Context, nodes []schema.Node, vectors [][][]float64) ([]string, error) {
+	if len(nodes) != len(vectors) {
+		return nil, fmt.Errorf("got %d nodes but %d multi-vectors", len(nodes), len(vectors))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for i, node := range nodes {
+		if node.ID == "" {
+			return nil, errors.New("node ID cannot be empty")
+		}
+		s.nodes[node.ID] = node
+		s.multiVectors[node.ID] = vectors[i]
+		ids = append(ids, node.ID)
+	}
+	return ids, nil
+}
+
+// QueryMultiVector returns the top-k nodes by MaxSim score. Nodes stored
+// without per-token embeddings are skipped.
+func (s *SimpleVectorStore) QueryMultiVector(ctx context.Context, query MultiVectorQuery) ([]schema.NodeWithScore, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []schema.NodeWithScore
+	for id, vectors := range s.multiVectors {
+		node, ok := s.nodes[id]
+		if !ok {
+			continue
+		}
+		match, err := MatchesFilters(node, query.Filters)
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+		results = append(results, schema.NodeWithScore{
+			Node:  node,
+			Score: embedding.MaxSim(query.Embeddings, vectors),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Node.ID < results[j].Node.ID
+	})
+
+	if query.TopK > 0 && len(results) > query.TopK {
+		results = results[:query.TopK]
+	}
+	return results, nil
+}
+
+// ListNodes returns all nodes in the store ordered by ID.
+func (s *SimpleVectorStore) ListNodes(ctx context.Context) ([]schema.Node, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nodes := make([]schema.Node, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+	return nodes, nil
+}
+
+// Ensure SimpleVectorStore implements VectorStore, NodeLister and
+// MultiVectorStore.
+var (
+	_ VectorStore      = (*SimpleVectorStore)(nil)
+	_ NodeLister       = (*SimpleVectorStore)(nil)
+	_ MultiVectorStore = (*SimpleVectorStore)(nil)
+)
+
 func cosineSimilarity(a, b []float64) (float64, error) {
 	if len(a) != len(b) {
 		return 0, errors.New("vector lengths do not match")
@@ -59,7 +59,7 @@ func (as *AccumulateSynthesizer) Synthesize(ctx context.Context, query string, n
 		return NewResponse("Empty Response", nil), nil
 	}
 
-	textChunks := GetTextChunksFromNodes(nodes, schema.MetadataModeLLM)
+	textChunks := as.GetTextChunks(nodes)
 	responseStr, err := as.GetResponse(ctx, query, textChunks)
 	if err != nil {
 		return nil, err
@@ -77,7 +77,7 @@ func (as *AccumulateSynthesizer) GetResponse(ctx context.Context, query string,
 	responses := make([]string, 0, len(textChunks))
 
 	for _, chunk := range textChunks {
-		prompt := as.TextQATemplate.Format(map[string]string{
+		prompt := prompts.FormatContext(ctx, as.TextQATemplate, map[string]string{
 			"query_str":   query,
 			"context_str": chunk,
 		})
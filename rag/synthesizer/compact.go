@@ -56,7 +56,7 @@ func (cs *CompactAndRefineSynthesizer) Synthesize(ctx context.Context, query str
 		return NewResponse("Empty Response", nil), nil
 	}
 
-	textChunks := GetTextChunksFromNodes(nodes, schema.MetadataModeLLM)
+	textChunks := cs.GetTextChunks(nodes)
 	responseStr, err := cs.GetResponse(ctx, query, textChunks)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,189 @@
+package synthesizer
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// Metadata keys set by DraftVerifySynthesizer.
+const (
+	// DraftVerifyOutcomeMetadataKey holds the DraftVerifyOutcome.
+	DraftVerifyOutcomeMetadataKey = "draft_verify_outcome"
+	// DraftMetadataKey holds the small model's draft answer.
+	DraftMetadataKey = "draft"
+)
+
+// DraftVerifyOutcome records how a draft-then-verify answer was produced.
+type DraftVerifyOutcome string
+
+const (
+	// DraftVerifyApproved means the verifier accepted the draft unchanged.
+	DraftVerifyApproved DraftVerifyOutcome = "approved"
+	// DraftVerifyEdited means the verifier replaced the draft.
+	DraftVerifyEdited DraftVerifyOutcome = "edited"
+	// DraftVerifyDisabled means drafting was off and the verifier answered
+	// directly.
+	DraftVerifyDisabled DraftVerifyOutcome = "disabled"
+)
+
+// DraftApprovedToken is the reply the verify prompt asks for when the draft
+// needs no changes.
+const DraftApprovedToken = "APPROVED"
+
+// DefaultDraftVerifyPromptTmpl asks the verifier to approve or correct a
+// draft answer against the sources.
+const DefaultDraftVerifyPromptTmpl = `Context information is below.
+---------------------
+{context_str}
+---------------------
+A draft answer to the query was written from this context.
+Query: {query_str}
+Draft answer: {draft_str}
+
+Check the draft against the context. If it is correct, complete and fully supported by the context, reply with exactly "` + DraftApprovedToken + `".
+Otherwise reply with the corrected answer only.
+Answer: `
+
+// DefaultDraftVerifyPrompt is the default verify prompt.
+var DefaultDraftVerifyPrompt = prompts.NewPromptTemplate(DefaultDraftVerifyPromptTmpl, prompts.PromptTypeRefine)
+
+// DraftVerifySynthesizer drafts an answer with a small, cheap model and has
+// the large model verify it against the sources. The large model only
+// writes a short approval for routine queries, so most output tokens come
+// from the small model; when the draft is wrong the large model's
+// correction is returned instead.
+//
+// Drafting can be switched off with WithDraftEnabled(false), in which case
+// the large model answers directly with the QA prompt.
+type DraftVerifySynthesizer struct {
+	*BaseSynthesizer
+	// DraftLLM writes the draft answer.
+	DraftLLM llm.LLM
+	// DraftTemplate is the QA prompt used for drafting, and for direct
+	// answers when drafting is disabled.
+	DraftTemplate prompts.BasePromptTemplate
+	// VerifyTemplate is the prompt used by the verifier.
+	VerifyTemplate prompts.BasePromptTemplate
+	// Enabled turns drafting on.
+	Enabled bool
+}
+
+// DraftVerifySynthesizerOption is a functional option for
+// DraftVerifySynthesizer.
+type DraftVerifySynthesizerOption func(*DraftVerifySynthesizer)
+
+// WithDraftTemplate sets the drafting QA prompt.
+func WithDraftTemplate(template prompts.BasePromptTemplate) DraftVerifySynthesizerOption {
+	return func(s *DraftVerifySynthesizer) {
+		s.DraftTemplate = template
+	}
+}
+
+// WithVerifyTemplate sets the verify prompt. It uses the {context_str},
+// {query_str} and {draft_str} placeholders.
+func WithVerifyTemplate(template prompts.BasePromptTemplate) DraftVerifySynthesizerOption {
+	return func(s *DraftVerifySynthesizer) {
+		s.VerifyTemplate = template
+	}
+}
+
+// WithDraftEnabled turns drafting on or off. Defaults to on.
+func WithDraftEnabled(enabled bool) DraftVerifySynthesizerOption {
+	return func(s *DraftVerifySynthesizer) {
+		s.Enabled = enabled
+	}
+}
+
+// NewDraftVerifySynthesizer creates a new DraftVerifySynthesizer. draftLLM
+// writes drafts; verifyLLM checks them.
+func NewDraftVerifySynthesizer(draftLLM, verifyLLM llm.LLM, opts ...DraftVerifySynthesizerOption) *DraftVerifySynthesizer {
+	s := &DraftVerifySynthesizer{
+		BaseSynthesizer: NewBaseSynthesizer(verifyLLM),
+		DraftLLM:        draftLLM,
+		DraftTemplate:   prompts.DefaultTextQAPrompt,
+		VerifyTemplate:  DefaultDraftVerifyPrompt,
+		Enabled:         true,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.SetPrompt("text_qa_template", s.DraftTemplate)
+	s.SetPrompt("verify_template", s.VerifyTemplate)
+
+	return s
+}
+
+// Synthesize generates a response from the query and source nodes.
+func (s *DraftVerifySynthesizer) Synthesize(ctx context.Context, query string, nodes []schema.NodeWithScore) (*Response, error) {
+	if len(nodes) == 0 {
+		return NewResponse("Empty Response", nil), nil
+	}
+
+	textChunks := s.GetTextChunks(nodes)
+	answer, draft, outcome, err := s.draftAndVerify(ctx, query, textChunks)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := s.PrepareResponseOutput(answer, nodes)
+	resp.Metadata[DraftVerifyOutcomeMetadataKey] = outcome
+	if outcome != DraftVerifyDisabled {
+		resp.Metadata[DraftMetadataKey] = draft
+	}
+	return resp, nil
+}
+
+// GetResponse generates a response from query and text chunks.
+func (s *DraftVerifySynthesizer) GetResponse(ctx context.Context, query string, textChunks []string) (string, error) {
+	answer, _, _, err := s.draftAndVerify(ctx, query, textChunks)
+	return answer, err
+}
+
+// draftAndVerify returns the final answer, the draft and the outcome.
+func (s *DraftVerifySynthesizer) draftAndVerify(ctx context.Context, query string, textChunks []string) (string, string, DraftVerifyOutcome, error) {
+	contextStr := strings.Join(textChunks, "\n\n")
+	qaPrompt := prompts.FormatContext(ctx, s.DraftTemplate, map[string]string{
+		"query_str":   query,
+		"context_str": contextStr,
+	})
+
+	if !s.Enabled || s.DraftLLM == nil {
+		answer, err := s.LLM.Complete(ctx, qaPrompt)
+		return answer, "", DraftVerifyDisabled, err
+	}
+
+	draft, err := s.DraftLLM.Complete(ctx, qaPrompt)
+	if err != nil {
+		return "", "", "", err
+	}
+	draft = strings.TrimSpace(draft)
+
+	verdict, err := s.LLM.Complete(ctx, prompts.FormatContext(ctx, s.VerifyTemplate, map[string]string{
+		"query_str":   query,
+		"context_str": contextStr,
+		"draft_str":   draft,
+	}))
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if isDraftApproved(verdict) {
+		return draft, draft, DraftVerifyApproved, nil
+	}
+	return strings.TrimSpace(verdict), draft, DraftVerifyEdited, nil
+}
+
+// isDraftApproved reports whether a verifier reply approves the draft.
+func isDraftApproved(verdict string) bool {
+	v := strings.Trim(strings.TrimSpace(verdict), `"'.`)
+	return strings.EqualFold(v, DraftApprovedToken)
+}
+
+// Ensure DraftVerifySynthesizer implements Synthesizer.
+var _ Synthesizer = (*DraftVerifySynthesizer)(nil)
@@ -26,6 +26,12 @@ type BaseSynthesizer struct {
 	Streaming bool
 	// Verbose enables verbose logging.
 	Verbose bool
+	// DeduplicateOverlap merges overlapping regions of adjacent chunks
+	// before they are placed in the prompt.
+	DeduplicateOverlap bool
+	// MinOverlapChars is the minimum overlap detected by suffix matching
+	// when chunks carry no relationship metadata.
+	MinOverlapChars int
 	// PromptMixin for prompt management.
 	*prompts.BasePromptMixin
 }
@@ -57,6 +63,16 @@ func WithSynthesizerVerbose(verbose bool) BaseSynthesizerOption {
 	}
 }
 
+// WithOverlapDeduplication merges overlapping text of adjacent retrieved chunks.
+// minOverlap is the minimum suffix/prefix match used when chunks carry no
+// relationship metadata; zero uses DefaultMinOverlapChars.
+func WithOverlapDeduplication(minOverlap int) BaseSynthesizerOption {
+	return func(bs *BaseSynthesizer) {
+		bs.DeduplicateOverlap = true
+		bs.MinOverlapChars = minOverlap
+	}
+}
+
 // NewBaseSynthesizerWithOptions creates a new BaseSynthesizer with options.
 func NewBaseSynthesizerWithOptions(llmModel llm.LLM, opts ...BaseSynthesizerOption) *BaseSynthesizer {
 	bs := NewBaseSynthesizer(llmModel)
@@ -85,6 +101,15 @@ func (bs *BaseSynthesizer) PrepareResponseOutput(responseStr string, sourceNodes
 	}
 }
 
+// GetTextChunks extracts LLM-mode text chunks from nodes, deduplicating
+// overlapping regions if DeduplicateOverlap is enabled.
+func (bs *BaseSynthesizer) GetTextChunks(nodes []schema.NodeWithScore) []string {
+	if bs.DeduplicateOverlap {
+		return GetDedupedTextChunksFromNodes(nodes, schema.MetadataModeLLM, bs.MinOverlapChars)
+	}
+	return GetTextChunksFromNodes(nodes, schema.MetadataModeLLM)
+}
+
 // GetTextChunksFromNodes extracts text content from nodes.
 func GetTextChunksFromNodes(nodes []schema.NodeWithScore, mode schema.MetadataMode) []string {
 	chunks := make([]string, len(nodes))
@@ -0,0 +1,178 @@
+package synthesizer
+
+import (
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// DefaultMinOverlapChars is the minimum suffix/prefix match length used to
+// detect overlap between chunks that carry no relationship metadata.
+const DefaultMinOverlapChars = 20
+
+// GetDedupedTextChunksFromNodes extracts text content from nodes, merging
+// adjacent chunks whose overlapping region would otherwise be sent to the
+// LLM twice.
+//
+// Two chunks are considered adjacent when one links to the other through a
+// NEXT/PREVIOUS relationship, or when they come from the same source and
+// their character ranges overlap. Chunks without such metadata fall back to
+// suffix matching: the end of one chunk must equal the start of the other
+// for at least minOverlap characters. The overlap of adjacent chunks is
+// taken from their character ranges when both have them, and otherwise
+// must also be at least minOverlap characters; adjacent chunks that don't
+// overlap are joined with a space. Merged chunks keep the position of their
+// earliest member in the input order.
+func GetDedupedTextChunksFromNodes(nodes []schema.NodeWithScore, mode schema.MetadataMode, minOverlap int) []string {
+	if len(nodes) < 2 {
+		return GetTextChunksFromNodes(nodes, mode)
+	}
+	if minOverlap <= 0 {
+		minOverlap = DefaultMinOverlapChars
+	}
+
+	// next[i] is the index of the chunk that continues chunk i.
+	next := make([]int, len(nodes))
+	hasPrev := make([]bool, len(nodes))
+	prev := make([]int, len(nodes))
+	overlapLen := make([]int, len(nodes))
+	for i := range next {
+		next[i] = -1
+	}
+
+	for i := range nodes {
+		for j := range nodes {
+			if i == j || next[i] != -1 || hasPrev[j] {
+				continue
+			}
+			overlap, ok := chunkOverlap(&nodes[i].Node, &nodes[j].Node, minOverlap)
+			if !ok || createsCycle(next, i, j) {
+				continue
+			}
+			next[i] = j
+			hasPrev[j] = true
+			prev[j] = i
+			overlapLen[j] = overlap
+		}
+	}
+
+	chunks := make([]string, 0, len(nodes))
+	for i := range nodes {
+		if hasPrev[i] {
+			continue
+		}
+		var sb strings.Builder
+		sb.WriteString(nodes[i].Node.GetContent(mode))
+		for j := next[i]; j != -1; j = next[j] {
+			if overlapLen[j] == 0 {
+				sb.WriteString(chunkSeparator(nodes[prev[j]].Node.Text, nodes[j].Node.Text))
+			}
+			sb.WriteString(nodes[j].Node.Text[overlapLen[j]:])
+		}
+		chunks = append(chunks, sb.String())
+	}
+
+	return chunks
+}
+
+// chunkOverlap reports whether b directly continues a and, if so, how many
+// leading bytes of b duplicate the end of a.
+func chunkOverlap(a, b *schema.Node, minOverlap int) (int, bool) {
+	if linked(a, b) || sameSourceRange(a, b) {
+		if overlap, ok := rangeOverlap(a, b); ok {
+			return overlap, true
+		}
+		return suffixPrefixOverlap(a.Text, b.Text, minOverlap), true
+	}
+	if hasAdjacencyInfo(a) && hasAdjacencyInfo(b) {
+		// Both chunks have positional metadata that says they are not
+		// neighbours; don't second-guess it with text matching.
+		return 0, false
+	}
+	overlap := suffixPrefixOverlap(a.Text, b.Text, minOverlap)
+	return overlap, overlap > 0
+}
+
+// rangeOverlap returns the overlap of a and b given by their character
+// ranges. It reports false if either node lacks a range, or if the ranges
+// overlap but the texts don't agree with them.
+func rangeOverlap(a, b *schema.Node) (int, bool) {
+	if a.EndCharIdx == nil || b.StartCharIdx == nil || a.StartCharIdx == nil || b.EndCharIdx == nil {
+		return 0, false
+	}
+	overlap := *a.EndCharIdx - *b.StartCharIdx
+	if overlap <= 0 {
+		return 0, false
+	}
+	if overlap > len(a.Text) || overlap > len(b.Text) || !strings.HasSuffix(a.Text, b.Text[:overlap]) {
+		return 0, false
+	}
+	return overlap, true
+}
+
+// chunkSeparator returns the separator between adjacent chunks a and b that
+// don't overlap: nothing if either already has whitespace at the boundary,
+// otherwise a space.
+func chunkSeparator(a, b string) string {
+	if a == "" || b == "" || strings.ContainsAny(a[len(a)-1:], " \t\n") || strings.ContainsAny(b[:1], " \t\n") {
+		return ""
+	}
+	return " "
+}
+
+// linked reports whether a and b are connected through NEXT/PREVIOUS relationships.
+func linked(a, b *schema.Node) bool {
+	if n := a.GetRelationships().GetNext(); n != nil && n.NodeID == b.ID {
+		return true
+	}
+	if p := b.GetRelationships().GetPrevious(); p != nil && p.NodeID == a.ID {
+		return true
+	}
+	return false
+}
+
+// sameSourceRange reports whether a and b come from the same source document
+// and b starts inside or right at the end of a.
+func sameSourceRange(a, b *schema.Node) bool {
+	if a.StartCharIdx == nil || a.EndCharIdx == nil || b.StartCharIdx == nil {
+		return false
+	}
+	srcA := a.GetRelationships().GetSource()
+	srcB := b.GetRelationships().GetSource()
+	if srcA == nil || srcB == nil || srcA.NodeID != srcB.NodeID {
+		return false
+	}
+	return *b.StartCharIdx > *a.StartCharIdx && *b.StartCharIdx <= *a.EndCharIdx
+}
+
+// hasAdjacencyInfo reports whether the node carries positional metadata.
+func hasAdjacencyInfo(n *schema.Node) bool {
+	rels := n.GetRelationships()
+	return rels.GetNext() != nil || rels.GetPrevious() != nil ||
+		(rels.GetSource() != nil && n.StartCharIdx != nil)
+}
+
+// suffixPrefixOverlap returns the length of the longest suffix of a that is
+// also a prefix of b, or 0 if it is shorter than minLen.
+func suffixPrefixOverlap(a, b string, minLen int) int {
+	maxLen := len(a)
+	if len(b) < maxLen {
+		maxLen = len(b)
+	}
+	for l := maxLen; l >= minLen && l > 0; l-- {
+		if strings.HasSuffix(a, b[:l]) {
+			return l
+		}
+	}
+	return 0
+}
+
+// createsCycle reports whether linking from -> to would close a loop.
+func createsCycle(next []int, from, to int) bool {
+	for j := to; j != -1; j = next[j] {
+		if j == from {
+			return true
+		}
+	}
+	return false
+}
@@ -59,7 +59,7 @@ func (rs *RefineSynthesizer) Synthesize(ctx context.Context, query string, nodes
 		return NewResponse("Empty Response", nil), nil
 	}
 
-	textChunks := GetTextChunksFromNodes(nodes, schema.MetadataModeLLM)
+	textChunks := rs.GetTextChunks(nodes)
 	responseStr, err := rs.GetResponse(ctx, query, textChunks)
 	if err != nil {
 		return nil, err
@@ -102,7 +102,7 @@ func (rs *RefineSynthesizer) GetResponse(ctx context.Context, query string, text
 
 // giveResponseSingle generates initial response from a single chunk.
 func (rs *RefineSynthesizer) giveResponseSingle(ctx context.Context, query, textChunk string) (string, error) {
-	prompt := rs.TextQATemplate.Format(map[string]string{
+	prompt := prompts.FormatContext(ctx, rs.TextQATemplate, map[string]string{
 		"query_str":   query,
 		"context_str": textChunk,
 	})
@@ -112,7 +112,7 @@ func (rs *RefineSynthesizer) giveResponseSingle(ctx context.Context, query, text
 
 // refineResponseSingle refines an existing response with new context.
 func (rs *RefineSynthesizer) refineResponseSingle(ctx context.Context, existingAnswer, query, textChunk string) (string, error) {
-	prompt := rs.RefineTemplate.Format(map[string]string{
+	prompt := prompts.FormatContext(ctx, rs.RefineTemplate, map[string]string{
 		"query_str":       query,
 		"existing_answer": existingAnswer,
 		"context_msg":     textChunk,
@@ -4,6 +4,7 @@ import (
 	"strings"
 
 	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 // Response represents a standard response with metadata.
@@ -47,9 +48,7 @@ func (r *Response) GetFormattedSources(length int) string {
 	var texts []string
 	for _, sourceNode := range r.SourceNodes {
 		content := sourceNode.Node.GetContent(schema.MetadataModeLLM)
-		if len(content) > length {
-			content = content[:length] + "..."
-		}
+		content = textutil.Truncate(content, length)
 		docID := sourceNode.Node.ID
 		if docID == "" {
 			docID = "None"
@@ -112,9 +111,7 @@ func (sr *StreamingResponse) GetFormattedSources(length int) string {
 	var texts []string
 	for _, sourceNode := range sr.SourceNodes {
 		content := sourceNode.Node.GetContent(schema.MetadataModeLLM)
-		if len(content) > length {
-			content = content[:length] + "..."
-		}
+		content = textutil.Truncate(content, length)
 		nodeID := sourceNode.Node.ID
 		if nodeID == "" {
 			nodeID = "None"
@@ -49,7 +49,7 @@ func (ss *SimpleSynthesizer) Synthesize(ctx context.Context, query string, nodes
 		return NewResponse("Empty Response", nil), nil
 	}
 
-	textChunks := GetTextChunksFromNodes(nodes, schema.MetadataModeLLM)
+	textChunks := ss.GetTextChunks(nodes)
 	responseStr, err := ss.GetResponse(ctx, query, textChunks)
 	if err != nil {
 		return nil, err
@@ -64,7 +64,7 @@ func (ss *SimpleSynthesizer) GetResponse(ctx context.Context, query string, text
 	contextStr := strings.Join(textChunks, "\n\n")
 
 	// Format prompt
-	prompt := ss.TextQATemplate.Format(map[string]string{
+	prompt := prompts.FormatContext(ctx, ss.TextQATemplate, map[string]string{
 		"query_str":   query,
 		"context_str": contextStr,
 	})
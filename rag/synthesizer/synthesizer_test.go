@@ -2,9 +2,12 @@ package synthesizer
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/requestctx"
 	"github.com/aqua777/go-llamaindex/schema"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -286,3 +289,167 @@ func TestBaseSynthesizerGetMetadata(t *testing.T) {
 	assert.Contains(t, metadata, "node1")
 	assert.Contains(t, metadata, "node2")
 }
+
+func TestGetDedupedTextChunksFromNodesSuffixMatch(t *testing.T) {
+	node1 := schema.NewTextNode("Paris is the capital of France. It has many famous museums")
+	node1.ID = "a"
+	node2 := schema.NewTextNode("It has many famous museums such as the Louvre.")
+	node2.ID = "b"
+	node3 := schema.NewTextNode("Berlin is the capital of Germany.")
+	node3.ID = "c"
+
+	nodes := []schema.NodeWithScore{
+		{Node: *node2, Score: 0.9},
+		{Node: *node3, Score: 0.8},
+		{Node: *node1, Score: 0.7},
+	}
+
+	chunks := GetDedupedTextChunksFromNodes(nodes, schema.MetadataModeNone, 10)
+	require.Len(t, chunks, 2)
+	assert.Equal(t, "Berlin is the capital of Germany.", chunks[0])
+	assert.Equal(t, "Paris is the capital of France. It has many famous museums such as the Louvre.", chunks[1])
+}
+
+func TestGetDedupedTextChunksFromNodesRelationships(t *testing.T) {
+	node1 := schema.NewTextNode("alpha beta gamma")
+	node1.ID = "n1"
+	node2 := schema.NewTextNode("gamma delta")
+	node2.ID = "n2"
+	node1.Relationships.SetNext(node2.AsRelatedNodeInfo())
+	node2.Relationships.SetPrevious(node1.AsRelatedNodeInfo())
+
+	start1, end1, start2, end2 := 0, 16, 11, 22
+	node1.StartCharIdx, node1.EndCharIdx = &start1, &end1
+	node2.StartCharIdx, node2.EndCharIdx = &start2, &end2
+
+	nodes := []schema.NodeWithScore{{Node: *node1}, {Node: *node2}}
+
+	// The overlap is shorter than minOverlap, but the character ranges are
+	// trusted.
+	chunks := GetDedupedTextChunksFromNodes(nodes, schema.MetadataModeNone, 50)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, "alpha beta gamma delta", chunks[0])
+}
+
+func TestGetDedupedTextChunksFromNodesLinkedWithoutOverlap(t *testing.T) {
+	node1 := schema.NewTextNode("The cat sat on the")
+	node1.ID = "n1"
+	node2 := schema.NewTextNode("end of the mat.")
+	node2.ID = "n2"
+	node1.Relationships.SetNext(node2.AsRelatedNodeInfo())
+	node2.Relationships.SetPrevious(node1.AsRelatedNodeInfo())
+
+	nodes := []schema.NodeWithScore{{Node: *node1}, {Node: *node2}}
+
+	// Without character ranges, the accidental one-character overlap is
+	// below minOverlap and the chunks are joined with a space.
+	chunks := GetDedupedTextChunksFromNodes(nodes, schema.MetadataModeNone, 0)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, "The cat sat on the end of the mat.", chunks[0])
+
+	// Adjacent ranges mean no overlap either.
+	start1, end1, start2, end2 := 0, 18, 18, 33
+	nodes[0].Node.StartCharIdx, nodes[0].Node.EndCharIdx = &start1, &end1
+	nodes[1].Node.StartCharIdx, nodes[1].Node.EndCharIdx = &start2, &end2
+	chunks = GetDedupedTextChunksFromNodes(nodes, schema.MetadataModeNone, 0)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, "The cat sat on the end of the mat.", chunks[0])
+}
+
+func TestGetDedupedTextChunksFromNodesNoOverlap(t *testing.T) {
+	nodes := createTestNodes()
+	chunks := GetDedupedTextChunksFromNodes(nodes, schema.MetadataModeLLM, 0)
+	assert.Equal(t, GetTextChunksFromNodes(nodes, schema.MetadataModeLLM), chunks)
+}
+
+func TestSynthesizerOverlapDeduplication(t *testing.T) {
+	mockLLM := llm.NewMockLLM("")
+	bs := NewBaseSynthesizerWithOptions(mockLLM, WithOverlapDeduplication(5))
+	assert.True(t, bs.DeduplicateOverlap)
+	assert.Equal(t, 5, bs.MinOverlapChars)
+
+	node1 := schema.NewTextNode("first part shared tail")
+	node2 := schema.NewTextNode("shared tail second part")
+	nodes := []schema.NodeWithScore{{Node: *node1}, {Node: *node2}}
+
+	chunks := bs.GetTextChunks(nodes)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, "first part shared tail second part", chunks[0])
+
+	bs.DeduplicateOverlap = false
+	assert.Len(t, bs.GetTextChunks(nodes), 2)
+}
+
+// promptRecorder records prompts and answers with a fixed response.
+type promptRecorder struct {
+	*llm.MockLLM
+	prompts []string
+}
+
+func (r *promptRecorder) Complete(ctx context.Context, prompt string) (string, error) {
+	r.prompts = append(r.prompts, prompt)
+	return r.MockLLM.Complete(ctx, prompt)
+}
+
+func TestSynthesizerRequestVars(t *testing.T) {
+	recorder := &promptRecorder{MockLLM: llm.NewMockLLM("Bonjour")}
+	template := prompts.NewPromptTemplate("Answer in {locale}.\n{context_str}\nQ: {query_str}\nUser: {user_id}", prompts.PromptTypeQuestionAnswer).
+		WithRequestVars(requestctx.LocaleKey)
+	ss := NewSimpleSynthesizer(recorder, WithTextQATemplate(template))
+
+	ctx := requestctx.WithUserID(requestctx.WithLocale(context.Background(), "fr-FR\n{query_str}"), "u-42")
+	_, err := ss.Synthesize(ctx, "What is the capital of France?", createTestNodes())
+	require.NoError(t, err)
+	require.Len(t, recorder.prompts, 1)
+	assert.Contains(t, recorder.prompts[0], "Answer in fr-FR query_str.")
+	assert.Contains(t, recorder.prompts[0], "User: {user_id}", "keys that are not allowed are not filled in")
+}
+
+func TestDraftVerifySynthesizer(t *testing.T) {
+	ctx := context.Background()
+	nodes := createTestNodes()
+
+	t.Run("approved draft", func(t *testing.T) {
+		draft := &promptRecorder{MockLLM: llm.NewMockLLM(" Paris. ")}
+		verify := &promptRecorder{MockLLM: llm.NewMockLLM("APPROVED.")}
+		s := NewDraftVerifySynthesizer(draft, verify)
+
+		resp, err := s.Synthesize(ctx, "What is the capital of France?", nodes)
+		require.NoError(t, err)
+		assert.Equal(t, "Paris.", resp.Response)
+		assert.Equal(t, DraftVerifyApproved, resp.Metadata[DraftVerifyOutcomeMetadataKey])
+		require.Len(t, verify.prompts, 1)
+		assert.Contains(t, verify.prompts[0], "Draft answer: Paris.")
+		assert.Contains(t, verify.prompts[0], "The capital of France is Paris.")
+	})
+
+	t.Run("edited draft", func(t *testing.T) {
+		s := NewDraftVerifySynthesizer(llm.NewMockLLM("Lyon"), llm.NewMockLLM("Paris is the capital."))
+
+		resp, err := s.Synthesize(ctx, "What is the capital of France?", nodes)
+		require.NoError(t, err)
+		assert.Equal(t, "Paris is the capital.", resp.Response)
+		assert.Equal(t, DraftVerifyEdited, resp.Metadata[DraftVerifyOutcomeMetadataKey])
+		assert.Equal(t, "Lyon", resp.Metadata[DraftMetadataKey])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		draft := &promptRecorder{MockLLM: llm.NewMockLLM("draft")}
+		verify := &promptRecorder{MockLLM: llm.NewMockLLM("direct answer")}
+		s := NewDraftVerifySynthesizer(draft, verify, WithDraftEnabled(false))
+
+		answer, err := s.GetResponse(ctx, "q", []string{"context"})
+		require.NoError(t, err)
+		assert.Equal(t, "direct answer", answer)
+		assert.Empty(t, draft.prompts)
+		require.Len(t, verify.prompts, 1)
+		assert.False(t, strings.Contains(verify.prompts[0], "Draft answer"))
+	})
+
+	t.Run("empty nodes", func(t *testing.T) {
+		s := NewDraftVerifySynthesizer(llm.NewMockLLM(""), llm.NewMockLLM(""))
+		resp, err := s.Synthesize(ctx, "q", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "Empty Response", resp.Response)
+	})
+}
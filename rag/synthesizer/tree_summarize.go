@@ -59,7 +59,7 @@ func (ts *TreeSummarizeSynthesizer) Synthesize(ctx context.Context, query string
 		return NewResponse("Empty Response", nil), nil
 	}
 
-	textChunks := GetTextChunksFromNodes(nodes, schema.MetadataModeLLM)
+	textChunks := ts.GetTextChunks(nodes)
 	responseStr, err := ts.GetResponse(ctx, query, textChunks)
 	if err != nil {
 		return nil, err
@@ -102,7 +102,7 @@ func (ts *TreeSummarizeSynthesizer) GetResponse(ctx context.Context, query strin
 
 // summarizeChunk summarizes a single chunk.
 func (ts *TreeSummarizeSynthesizer) summarizeChunk(ctx context.Context, query, chunk string) (string, error) {
-	prompt := ts.SummaryTemplate.Format(map[string]string{
+	prompt := prompts.FormatContext(ctx, ts.SummaryTemplate, map[string]string{
 		"query_str":   query,
 		"context_str": chunk,
 	})
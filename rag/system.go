@@ -153,28 +153,16 @@ func (s *RAGSystem) IngestDirectory(ctx context.Context, inputDir string) error
 	// Actually NewSimpleDirectoryReader takes (dir string, ext ...string)
 	docReader := reader.NewSimpleDirectoryReader(inputDir, s.Config.FileExtensions...)
 
-	// The SimpleDirectoryReader currently returns []schema.Node (which act as documents).
-	// We need to convert them to []schema.Document.
-	nodes, err := docReader.LoadData()
+	docs, err := docReader.LoadDocuments()
 	if err != nil {
 		return fmt.Errorf("failed to load data: %w", err)
 	}
 
-	if len(nodes) == 0 {
+	if len(docs) == 0 {
 		log.Println("No documents found in", inputDir)
 		return nil
 	}
 
-	// Convert nodes to docs
-	var docs []schema.Document
-	for _, node := range nodes {
-		docs = append(docs, schema.Document{
-			ID:       node.ID,
-			Text:     node.Text,
-			Metadata: node.Metadata,
-		})
-	}
-
 	return s.ingestDocuments(ctx, docs)
 }
 
@@ -225,6 +213,10 @@ func (s *RAGSystem) ingestDocuments(ctx context.Context, docs []schema.Document)
 	// 2. Split and Embed
 	var allNodes []schema.Node
 	for _, doc := range docs {
+		// Binary documents without extracted text have nothing to embed.
+		if doc.Text == "" {
+			continue
+		}
 		chunks := s.Splitter.SplitText(doc.Text)
 		for i, chunk := range chunks {
 			// Create node
@@ -249,6 +241,9 @@ func (s *RAGSystem) ingestDocuments(ctx context.Context, docs []schema.Document)
 				return fmt.Errorf("failed to get embedding for chunk %d of doc %s: %w", i, doc.ID, err)
 			}
 			node.Embedding = emb
+			if modelName := embedding.ModelName(s.EmbedModel); modelName != "" {
+				node.SetEmbeddingModel(modelName)
+			}
 			allNodes = append(allNodes, node)
 		}
 	}
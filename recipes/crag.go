@@ -0,0 +1,391 @@
+// Package recipes provides prebuilt, configurable RAG workflows that combine
+// the lower-level building blocks (retrievers, synthesizers, workflows) into
+// well-known patterns. Each recipe is usable directly as a query engine and
+// doubles as a reference for structuring workflows.
+package recipes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/rag/queryengine"
+	"github.com/aqua777/go-llamaindex/rag/retriever"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/workflow"
+)
+
+// Event types used by the corrective RAG workflow.
+const (
+	CRAGRetrieveEventType   workflow.EventType = "crag.retrieve"
+	CRAGGradeEventType      workflow.EventType = "crag.grade"
+	CRAGWebSearchEventType  workflow.EventType = "crag.web_search"
+	CRAGSynthesizeEventType workflow.EventType = "crag.synthesize"
+)
+
+// CRAGRetrieveData carries the retrieved nodes to the grading step.
+type CRAGRetrieveData struct {
+	Query string
+	Nodes []schema.NodeWithScore
+}
+
+// CRAGGradeData carries graded nodes to the correction step.
+type CRAGGradeData struct {
+	Query  string
+	Grades []DocumentGrade
+}
+
+// CRAGWebSearchData requests a web search fallback.
+type CRAGWebSearchData struct {
+	Query         string
+	RelevantNodes []schema.NodeWithScore
+}
+
+// CRAGSynthesizeData carries the refined knowledge to the synthesis step.
+type CRAGSynthesizeData struct {
+	Query     string
+	Nodes     []schema.NodeWithScore
+	UsedWeb   bool
+	WebQuery  string
+	Relevance float64
+}
+
+// Event factories for the corrective RAG workflow.
+var (
+	CRAGRetrieveEvent   = workflow.NewEventFactory[CRAGRetrieveData](CRAGRetrieveEventType)
+	CRAGGradeEvent      = workflow.NewEventFactory[CRAGGradeData](CRAGGradeEventType)
+	CRAGWebSearchEvent  = workflow.NewEventFactory[CRAGWebSearchData](CRAGWebSearchEventType)
+	CRAGSynthesizeEvent = workflow.NewEventFactory[CRAGSynthesizeData](CRAGSynthesizeEventType)
+)
+
+// Metadata keys set on responses produced by CorrectiveRAG.
+const (
+	// CRAGUsedWebSearchKey is true when web search results were used.
+	CRAGUsedWebSearchKey = "crag_used_web_search"
+	// CRAGRelevanceKey is the fraction of retrieved documents graded relevant.
+	CRAGRelevanceKey = "crag_relevance"
+	// CRAGWebQueryKey is the rewritten query sent to web search.
+	CRAGWebQueryKey = "crag_web_query"
+)
+
+// WebSearcher searches an external source (typically the web) for nodes.
+type WebSearcher interface {
+	// Search returns up to topK results for the query.
+	Search(ctx context.Context, query string, topK int) ([]schema.NodeWithScore, error)
+}
+
+// DocumentGrade is the relevance judgement for a single retrieved node.
+type DocumentGrade struct {
+	Node     schema.NodeWithScore
+	Relevant bool
+}
+
+// RelevanceGrader judges whether a retrieved node is relevant to a query.
+type RelevanceGrader interface {
+	Grade(ctx context.Context, query string, node schema.NodeWithScore) (bool, error)
+}
+
+// Default prompts for the corrective RAG workflow.
+const (
+	defaultCRAGGradePrompt = `You are a grader assessing the relevance of a retrieved document to a user question.
+If the document contains keywords or meaning related to the question, grade it as relevant.
+
+Document:
+{context_str}
+
+Question: {query_str}
+
+Answer with a single word, YES if the document is relevant or NO if it is not.`
+
+	defaultCRAGRewritePrompt = `Rewrite the following question as a concise web search query.
+Return only the query.
+
+Question: {query_str}
+Search query:`
+)
+
+// LLMRelevanceGrader grades relevance with a YES/NO LLM prompt.
+type LLMRelevanceGrader struct {
+	LLM    llm.LLM
+	Prompt string
+}
+
+// NewLLMRelevanceGrader creates a new LLMRelevanceGrader.
+func NewLLMRelevanceGrader(llmModel llm.LLM) *LLMRelevanceGrader {
+	return &LLMRelevanceGrader{
+		LLM:    llmModel,
+		Prompt: defaultCRAGGradePrompt,
+	}
+}
+
+// Grade returns true if the LLM judges the node relevant.
+func (g *LLMRelevanceGrader) Grade(ctx context.Context, query string, node schema.NodeWithScore) (bool, error) {
+	prompt := strings.ReplaceAll(g.Prompt, "{context_str}", node.Node.GetContent(schema.MetadataModeLLM))
+	prompt = strings.ReplaceAll(prompt, "{query_str}", query)
+
+	response, err := g.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return false, err
+	}
+	return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(response)), "YES"), nil
+}
+
+// CorrectiveRAG implements Corrective RAG (CRAG): retrieved documents are
+// graded for relevance, irrelevant ones are discarded, and when too few
+// remain the query is rewritten and answered with web search results.
+//
+// The pipeline runs as a workflow with the steps
+// start → retrieve → grade → (web search) → synthesize → stop,
+// which can be obtained with Workflow for customization or streaming.
+type CorrectiveRAG struct {
+	// Retriever retrieves candidate nodes from the local index.
+	Retriever retriever.Retriever
+	// Grader judges the relevance of each candidate.
+	Grader RelevanceGrader
+	// WebSearcher is the fallback source; nil disables the fallback.
+	WebSearcher WebSearcher
+	// Synthesizer generates the final answer.
+	Synthesizer synthesizer.Synthesizer
+	// LLM rewrites the query for web search; nil uses the original query.
+	LLM llm.LLM
+	// RelevanceThreshold is the minimum fraction of relevant documents
+	// below which web search is used.
+	RelevanceThreshold float64
+	// WebSearchTopK is the number of web results to request.
+	WebSearchTopK int
+	// RewritePrompt is the template used to rewrite queries for web search.
+	RewritePrompt string
+	// WorkflowOptions are passed to the underlying workflow.
+	WorkflowOptions []workflow.WorkflowOption
+}
+
+// CorrectiveRAGOption configures a CorrectiveRAG.
+type CorrectiveRAGOption func(*CorrectiveRAG)
+
+// WithCRAGGrader sets the relevance grader.
+func WithCRAGGrader(grader RelevanceGrader) CorrectiveRAGOption {
+	return func(c *CorrectiveRAG) {
+		c.Grader = grader
+	}
+}
+
+// WithCRAGWebSearcher sets the web search fallback.
+func WithCRAGWebSearcher(searcher WebSearcher) CorrectiveRAGOption {
+	return func(c *CorrectiveRAG) {
+		c.WebSearcher = searcher
+	}
+}
+
+// WithCRAGRelevanceThreshold sets the fraction of relevant documents required
+// to skip web search.
+func WithCRAGRelevanceThreshold(threshold float64) CorrectiveRAGOption {
+	return func(c *CorrectiveRAG) {
+		c.RelevanceThreshold = threshold
+	}
+}
+
+// WithCRAGWebSearchTopK sets the number of web results to request.
+func WithCRAGWebSearchTopK(topK int) CorrectiveRAGOption {
+	return func(c *CorrectiveRAG) {
+		c.WebSearchTopK = topK
+	}
+}
+
+// WithCRAGRewritePrompt sets the query rewrite prompt.
+func WithCRAGRewritePrompt(prompt string) CorrectiveRAGOption {
+	return func(c *CorrectiveRAG) {
+		c.RewritePrompt = prompt
+	}
+}
+
+// WithCRAGWorkflowOptions sets options for the underlying workflow.
+func WithCRAGWorkflowOptions(opts ...workflow.WorkflowOption) CorrectiveRAGOption {
+	return func(c *CorrectiveRAG) {
+		c.WorkflowOptions = opts
+	}
+}
+
+// NewCorrectiveRAG creates a new CorrectiveRAG. The LLM is used for grading
+// and query rewriting unless a grader is supplied with WithCRAGGrader.
+func NewCorrectiveRAG(
+	ret retriever.Retriever,
+	synth synthesizer.Synthesizer,
+	llmModel llm.LLM,
+	opts ...CorrectiveRAGOption,
+) *CorrectiveRAG {
+	c := &CorrectiveRAG{
+		Retriever:          ret,
+		Synthesizer:        synth,
+		LLM:                llmModel,
+		RelevanceThreshold: 0.5,
+		WebSearchTopK:      3,
+		RewritePrompt:      defaultCRAGRewritePrompt,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.Grader == nil && llmModel != nil {
+		c.Grader = NewLLMRelevanceGrader(llmModel)
+	}
+
+	return c
+}
+
+// Workflow builds the corrective RAG workflow. The start event input must be
+// the query string; the stop event result is a *synthesizer.Response.
+func (c *CorrectiveRAG) Workflow() *workflow.Workflow {
+	opts := append([]workflow.WorkflowOption{workflow.WithWorkflowName("corrective_rag")}, c.WorkflowOptions...)
+	w := workflow.NewWorkflow(opts...)
+
+	workflow.HandleTyped(w, workflow.StartEvent, c.retrieveStep, workflow.BuildStepConfig(workflow.WithStepName("retrieve")))
+	workflow.HandleTyped(w, CRAGRetrieveEvent, c.gradeStep, workflow.BuildStepConfig(workflow.WithStepName("grade")))
+	workflow.HandleTyped(w, CRAGGradeEvent, c.correctStep, workflow.BuildStepConfig(workflow.WithStepName("correct")))
+	workflow.HandleTyped(w, CRAGWebSearchEvent, c.webSearchStep, workflow.BuildStepConfig(workflow.WithStepName("web_search")))
+	workflow.HandleTyped(w, CRAGSynthesizeEvent, c.synthesizeStep, workflow.BuildStepConfig(workflow.WithStepName("synthesize")))
+
+	return w
+}
+
+// Query runs the corrective RAG workflow for a query.
+func (c *CorrectiveRAG) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	result, err := c.Workflow().Run(ctx, workflow.NewStartEvent(query))
+	if err != nil {
+		return nil, err
+	}
+	return responseFromResult(result)
+}
+
+func (c *CorrectiveRAG) retrieveStep(ctx *workflow.Context, data workflow.StartEventData) ([]workflow.Event, error) {
+	query, ok := data.Input.(string)
+	if !ok {
+		return nil, fmt.Errorf("corrective rag: start input must be a query string, got %T", data.Input)
+	}
+
+	nodes, err := c.Retriever.Retrieve(ctx.Context(), schema.QueryBundle{QueryString: query})
+	if err != nil {
+		return nil, fmt.Errorf("corrective rag: retrieval failed: %w", err)
+	}
+	return []workflow.Event{CRAGRetrieveEvent.With(CRAGRetrieveData{Query: query, Nodes: nodes})}, nil
+}
+
+func (c *CorrectiveRAG) gradeStep(ctx *workflow.Context, data CRAGRetrieveData) ([]workflow.Event, error) {
+	grades := make([]DocumentGrade, len(data.Nodes))
+	for i, node := range data.Nodes {
+		relevant := true
+		if c.Grader != nil {
+			var err error
+			relevant, err = c.Grader.Grade(ctx.Context(), data.Query, node)
+			if err != nil {
+				return nil, fmt.Errorf("corrective rag: grading failed: %w", err)
+			}
+		}
+		grades[i] = DocumentGrade{Node: node, Relevant: relevant}
+	}
+	return []workflow.Event{CRAGGradeEvent.With(CRAGGradeData{Query: data.Query, Grades: grades})}, nil
+}
+
+func (c *CorrectiveRAG) correctStep(ctx *workflow.Context, data CRAGGradeData) ([]workflow.Event, error) {
+	var relevant []schema.NodeWithScore
+	for _, g := range data.Grades {
+		if g.Relevant {
+			relevant = append(relevant, g.Node)
+		}
+	}
+
+	relevance := 0.0
+	if len(data.Grades) > 0 {
+		relevance = float64(len(relevant)) / float64(len(data.Grades))
+	}
+	ctx.Set(CRAGRelevanceKey, relevance)
+
+	if relevance < c.RelevanceThreshold && c.WebSearcher != nil {
+		return []workflow.Event{CRAGWebSearchEvent.With(CRAGWebSearchData{
+			Query:         data.Query,
+			RelevantNodes: relevant,
+		})}, nil
+	}
+
+	return []workflow.Event{CRAGSynthesizeEvent.With(CRAGSynthesizeData{
+		Query:     data.Query,
+		Nodes:     relevant,
+		Relevance: relevance,
+	})}, nil
+}
+
+func (c *CorrectiveRAG) webSearchStep(ctx *workflow.Context, data CRAGWebSearchData) ([]workflow.Event, error) {
+	webQuery, err := c.rewriteQuery(ctx.Context(), data.Query)
+	if err != nil {
+		return nil, fmt.Errorf("corrective rag: query rewrite failed: %w", err)
+	}
+
+	results, err := c.WebSearcher.Search(ctx.Context(), webQuery, c.WebSearchTopK)
+	if err != nil {
+		return nil, fmt.Errorf("corrective rag: web search failed: %w", err)
+	}
+
+	relevance, _ := ctx.Get(CRAGRelevanceKey)
+	rel, _ := relevance.(float64)
+	return []workflow.Event{CRAGSynthesizeEvent.With(CRAGSynthesizeData{
+		Query:     data.Query,
+		Nodes:     append(data.RelevantNodes, results...),
+		UsedWeb:   true,
+		WebQuery:  webQuery,
+		Relevance: rel,
+	})}, nil
+}
+
+func (c *CorrectiveRAG) synthesizeStep(ctx *workflow.Context, data CRAGSynthesizeData) ([]workflow.Event, error) {
+	response, err := c.Synthesizer.Synthesize(ctx.Context(), data.Query, data.Nodes)
+	if err != nil {
+		return nil, fmt.Errorf("corrective rag: synthesis failed: %w", err)
+	}
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata[CRAGUsedWebSearchKey] = data.UsedWeb
+	response.Metadata[CRAGRelevanceKey] = data.Relevance
+	if data.UsedWeb {
+		response.Metadata[CRAGWebQueryKey] = data.WebQuery
+	}
+	return []workflow.Event{workflow.NewStopEvent(response)}, nil
+}
+
+// rewriteQuery turns the question into a web search query.
+func (c *CorrectiveRAG) rewriteQuery(ctx context.Context, query string) (string, error) {
+	if c.LLM == nil || c.RewritePrompt == "" {
+		return query, nil
+	}
+	prompt := strings.ReplaceAll(c.RewritePrompt, "{query_str}", query)
+	rewritten, err := c.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	rewritten = strings.Trim(strings.TrimSpace(rewritten), "\"")
+	if rewritten == "" {
+		return query, nil
+	}
+	return rewritten, nil
+}
+
+// responseFromResult extracts the response from a recipe workflow result.
+func responseFromResult(result *workflow.WorkflowResult) (*synthesizer.Response, error) {
+	if result == nil || result.FinalEvent == nil {
+		return nil, fmt.Errorf("workflow finished without a result")
+	}
+	data, ok := workflow.StopEvent.Extract(result.FinalEvent)
+	if !ok {
+		return nil, fmt.Errorf("workflow finished with unexpected event %s", result.FinalEvent.Type())
+	}
+	response, ok := data.Result.(*synthesizer.Response)
+	if !ok {
+		return nil, fmt.Errorf("workflow result is %T, expected *synthesizer.Response", data.Result)
+	}
+	return response, nil
+}
+
+// Ensure CorrectiveRAG implements QueryEngine.
+var _ queryengine.QueryEngine = (*CorrectiveRAG)(nil)
@@ -0,0 +1,417 @@
+package recipes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/program"
+	"github.com/aqua777/go-llamaindex/prompts"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/tools"
+	"github.com/aqua777/go-llamaindex/workflow"
+)
+
+// Event types used by the document processing workflow.
+const (
+	DocumentClassifiedEventType workflow.EventType = "docflow.classified"
+	DocumentExtractedEventType  workflow.EventType = "docflow.extracted"
+	DocumentActionEventType     workflow.EventType = "docflow.action"
+	DocumentRejectedEventType   workflow.EventType = "docflow.rejected"
+)
+
+// DocumentStatus is the outcome of processing a document.
+type DocumentStatus string
+
+const (
+	// DocumentStatusProcessed means the document passed validation and its
+	// action (if any) ran successfully.
+	DocumentStatusProcessed DocumentStatus = "processed"
+	// DocumentStatusRejected means the document failed validation.
+	DocumentStatusRejected DocumentStatus = "rejected"
+	// DocumentStatusUnclassified means no registered document type matched.
+	DocumentStatusUnclassified DocumentStatus = "unclassified"
+)
+
+// DocumentClassifiedData carries a classified document to extraction.
+type DocumentClassifiedData struct {
+	Document schema.Document
+	DocType  string
+}
+
+// DocumentExtractedData carries extracted fields to validation.
+type DocumentExtractedData struct {
+	Document schema.Document
+	DocType  string
+	Fields   map[string]interface{}
+}
+
+// DocumentActionData is emitted when a valid document is handed to its action.
+type DocumentActionData struct {
+	Document schema.Document
+	DocType  string
+	Fields   map[string]interface{}
+	Action   string
+}
+
+// DocumentRejectedData is emitted when a document fails validation.
+type DocumentRejectedData struct {
+	Document   schema.Document
+	DocType    string
+	Fields     map[string]interface{}
+	Violations []string
+}
+
+// Event factories for the document processing workflow. Consumers of
+// RunStream can observe action and rejection events as they are emitted.
+var (
+	DocumentClassifiedEvent = workflow.NewEventFactory[DocumentClassifiedData](DocumentClassifiedEventType)
+	DocumentExtractedEvent  = workflow.NewEventFactory[DocumentExtractedData](DocumentExtractedEventType)
+	DocumentActionEvent     = workflow.NewEventFactory[DocumentActionData](DocumentActionEventType)
+	DocumentRejectedEvent   = workflow.NewEventFactory[DocumentRejectedData](DocumentRejectedEventType)
+)
+
+// ValidationRule is a business rule applied to extracted fields.
+type ValidationRule struct {
+	// Name identifies the rule in violation messages.
+	Name string
+	// Validate returns an error describing the violation, or nil.
+	Validate func(fields map[string]interface{}) error
+}
+
+// RequiredFields returns a rule that fails when any of the fields is missing or empty.
+func RequiredFields(names ...string) ValidationRule {
+	return ValidationRule{
+		Name: "required_fields",
+		Validate: func(fields map[string]interface{}) error {
+			var missing []string
+			for _, name := range names {
+				v, ok := fields[name]
+				if !ok || v == nil || v == "" {
+					missing = append(missing, name)
+				}
+			}
+			if len(missing) > 0 {
+				return fmt.Errorf("missing fields: %s", strings.Join(missing, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+// DocumentType describes a kind of document the workflow can handle.
+type DocumentType struct {
+	// Name is the label the classifier assigns.
+	Name string
+	// Description helps the classifier recognize the document type.
+	Description string
+	// Fields lists the fields to extract when Extractor is nil.
+	Fields []string
+	// Extractor extracts structured fields; it is called with "document"
+	// and "doc_type" arguments. Nil uses an LLMProgram built from Fields.
+	Extractor program.Program
+	// Rules are the business rules the extracted fields must satisfy.
+	Rules []ValidationRule
+	// Action is called with the extracted fields of valid documents.
+	Action tools.Tool
+}
+
+// DocumentResult is the outcome of processing a document.
+type DocumentResult struct {
+	DocumentID   string
+	DocType      string
+	Status       DocumentStatus
+	Fields       map[string]interface{}
+	Violations   []string
+	ActionOutput *tools.ToolOutput
+}
+
+// Default prompts for the document processing workflow.
+const (
+	defaultDocumentClassifyPrompt = `Classify the document into exactly one of the following types:
+{types_str}
+
+Document:
+---------------------
+{document}
+---------------------
+
+Answer with only the type name, or "unknown" if none apply.`
+
+	defaultDocumentExtractPrompt = `Extract the following fields from the {doc_type} document below: {fields_str}.
+Use null for fields that are not present.
+
+Document:
+---------------------
+{document}
+---------------------`
+)
+
+// DocumentWorkflow is an agentic document processing pipeline: incoming
+// documents are classified, structured fields are extracted with a program,
+// validated against business rules, and valid documents are handed to a
+// tool as a typed action event.
+//
+// The pipeline runs as a workflow with the steps
+// start → classify → extract → validate → act | reject → stop.
+type DocumentWorkflow struct {
+	// LLM classifies documents and backs the default extractors.
+	LLM llm.LLM
+	// Types are the registered document types.
+	Types []DocumentType
+	// ClassifyPrompt is the template used for classification.
+	ClassifyPrompt string
+	// ExtractPrompt is the template used by default extractors.
+	ExtractPrompt string
+	// WorkflowOptions are passed to the underlying workflow.
+	WorkflowOptions []workflow.WorkflowOption
+}
+
+// DocumentWorkflowOption configures a DocumentWorkflow.
+type DocumentWorkflowOption func(*DocumentWorkflow)
+
+// WithDocumentType registers a document type.
+func WithDocumentType(docType DocumentType) DocumentWorkflowOption {
+	return func(d *DocumentWorkflow) {
+		d.Types = append(d.Types, docType)
+	}
+}
+
+// WithDocumentClassifyPrompt sets the classification prompt.
+func WithDocumentClassifyPrompt(prompt string) DocumentWorkflowOption {
+	return func(d *DocumentWorkflow) {
+		d.ClassifyPrompt = prompt
+	}
+}
+
+// WithDocumentExtractPrompt sets the prompt used by default extractors.
+func WithDocumentExtractPrompt(prompt string) DocumentWorkflowOption {
+	return func(d *DocumentWorkflow) {
+		d.ExtractPrompt = prompt
+	}
+}
+
+// WithDocumentWorkflowOptions sets options for the underlying workflow.
+func WithDocumentWorkflowOptions(opts ...workflow.WorkflowOption) DocumentWorkflowOption {
+	return func(d *DocumentWorkflow) {
+		d.WorkflowOptions = opts
+	}
+}
+
+// NewDocumentWorkflow creates a new DocumentWorkflow.
+func NewDocumentWorkflow(llmModel llm.LLM, opts ...DocumentWorkflowOption) *DocumentWorkflow {
+	d := &DocumentWorkflow{
+		LLM:            llmModel,
+		ClassifyPrompt: defaultDocumentClassifyPrompt,
+		ExtractPrompt:  defaultDocumentExtractPrompt,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Workflow builds the document processing workflow. The start event input
+// must be a schema.Document; the stop event result is a *DocumentResult.
+func (d *DocumentWorkflow) Workflow() *workflow.Workflow {
+	opts := append([]workflow.WorkflowOption{workflow.WithWorkflowName("document_workflow")}, d.WorkflowOptions...)
+	w := workflow.NewWorkflow(opts...)
+
+	workflow.HandleTyped(w, workflow.StartEvent, d.classifyStep, workflow.BuildStepConfig(workflow.WithStepName("classify")))
+	workflow.HandleTyped(w, DocumentClassifiedEvent, d.extractStep, workflow.BuildStepConfig(workflow.WithStepName("extract")))
+	workflow.HandleTyped(w, DocumentExtractedEvent, d.validateStep, workflow.BuildStepConfig(workflow.WithStepName("validate")))
+	workflow.HandleTyped(w, DocumentActionEvent, d.actStep, workflow.BuildStepConfig(workflow.WithStepName("act")))
+	workflow.HandleTyped(w, DocumentRejectedEvent, d.rejectStep, workflow.BuildStepConfig(workflow.WithStepName("reject")))
+
+	return w
+}
+
+// Process runs the workflow for a document.
+func (d *DocumentWorkflow) Process(ctx context.Context, doc schema.Document) (*DocumentResult, error) {
+	result, err := d.Workflow().Run(ctx, workflow.NewStartEvent(doc))
+	if err != nil {
+		return nil, err
+	}
+	if result == nil || result.FinalEvent == nil {
+		return nil, fmt.Errorf("workflow finished without a result")
+	}
+	data, ok := workflow.StopEvent.Extract(result.FinalEvent)
+	if !ok {
+		return nil, fmt.Errorf("workflow finished with unexpected event %s", result.FinalEvent.Type())
+	}
+	docResult, ok := data.Result.(*DocumentResult)
+	if !ok {
+		return nil, fmt.Errorf("workflow result is %T, expected *DocumentResult", data.Result)
+	}
+	return docResult, nil
+}
+
+func (d *DocumentWorkflow) classifyStep(ctx *workflow.Context, data workflow.StartEventData) ([]workflow.Event, error) {
+	doc, ok := data.Input.(schema.Document)
+	if !ok {
+		return nil, fmt.Errorf("document workflow: start input must be a schema.Document, got %T", data.Input)
+	}
+
+	docType, err := d.Classify(ctx.Context(), doc)
+	if err != nil {
+		return nil, fmt.Errorf("document workflow: classification failed: %w", err)
+	}
+	if docType == nil {
+		return []workflow.Event{workflow.NewStopEvent(&DocumentResult{
+			DocumentID: doc.ID,
+			Status:     DocumentStatusUnclassified,
+		})}, nil
+	}
+	return []workflow.Event{DocumentClassifiedEvent.With(DocumentClassifiedData{Document: doc, DocType: docType.Name})}, nil
+}
+
+func (d *DocumentWorkflow) extractStep(ctx *workflow.Context, data DocumentClassifiedData) ([]workflow.Event, error) {
+	docType := d.documentType(data.DocType)
+
+	extractor := docType.Extractor
+	if extractor == nil {
+		extractor = d.defaultExtractor(docType)
+	}
+
+	output, err := extractor.Call(ctx.Context(), map[string]interface{}{
+		"document": data.Document.Text,
+		"doc_type": docType.Name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("document workflow: extraction failed: %w", err)
+	}
+
+	var fields map[string]interface{}
+	if err := output.GetParsedAs(&fields); err != nil {
+		return nil, fmt.Errorf("document workflow: extraction returned no fields: %w", err)
+	}
+
+	return []workflow.Event{DocumentExtractedEvent.With(DocumentExtractedData{
+		Document: data.Document,
+		DocType:  data.DocType,
+		Fields:   fields,
+	})}, nil
+}
+
+func (d *DocumentWorkflow) validateStep(ctx *workflow.Context, data DocumentExtractedData) ([]workflow.Event, error) {
+	docType := d.documentType(data.DocType)
+
+	var violations []string
+	for _, rule := range docType.Rules {
+		if err := rule.Validate(data.Fields); err != nil {
+			violations = append(violations, fmt.Sprintf("%s: %v", rule.Name, err))
+		}
+	}
+
+	if len(violations) > 0 {
+		return []workflow.Event{DocumentRejectedEvent.With(DocumentRejectedData{
+			Document:   data.Document,
+			DocType:    data.DocType,
+			Fields:     data.Fields,
+			Violations: violations,
+		})}, nil
+	}
+
+	action := ""
+	if docType.Action != nil {
+		action = docType.Action.Metadata().GetName()
+	}
+	return []workflow.Event{DocumentActionEvent.With(DocumentActionData{
+		Document: data.Document,
+		DocType:  data.DocType,
+		Fields:   data.Fields,
+		Action:   action,
+	})}, nil
+}
+
+func (d *DocumentWorkflow) actStep(ctx *workflow.Context, data DocumentActionData) ([]workflow.Event, error) {
+	result := &DocumentResult{
+		DocumentID: data.Document.ID,
+		DocType:    data.DocType,
+		Status:     DocumentStatusProcessed,
+		Fields:     data.Fields,
+	}
+
+	if docType := d.documentType(data.DocType); docType.Action != nil {
+		output, err := docType.Action.Call(ctx.Context(), data.Fields)
+		if err != nil {
+			return nil, fmt.Errorf("document workflow: action %s failed: %w", data.Action, err)
+		}
+		result.ActionOutput = output
+	}
+
+	return []workflow.Event{workflow.NewStopEvent(result)}, nil
+}
+
+func (d *DocumentWorkflow) rejectStep(ctx *workflow.Context, data DocumentRejectedData) ([]workflow.Event, error) {
+	return []workflow.Event{workflow.NewStopEvent(&DocumentResult{
+		DocumentID: data.Document.ID,
+		DocType:    data.DocType,
+		Status:     DocumentStatusRejected,
+		Fields:     data.Fields,
+		Violations: data.Violations,
+	})}, nil
+}
+
+// Classify returns the registered type of the document, or nil if none
+// matches. With a single registered type no LLM call is made.
+func (d *DocumentWorkflow) Classify(ctx context.Context, doc schema.Document) (*DocumentType, error) {
+	if len(d.Types) == 0 {
+		return nil, nil
+	}
+	if len(d.Types) == 1 {
+		return &d.Types[0], nil
+	}
+
+	var typesStr strings.Builder
+	for _, t := range d.Types {
+		typesStr.WriteString(fmt.Sprintf("- %s: %s\n", t.Name, t.Description))
+	}
+	prompt := strings.NewReplacer(
+		"{types_str}", typesStr.String(),
+		"{document}", doc.Text,
+	).Replace(d.ClassifyPrompt)
+
+	response, err := d.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	label := strings.ToLower(strings.Trim(strings.TrimSpace(response), "\"'."))
+	for i := range d.Types {
+		if strings.ToLower(d.Types[i].Name) == label {
+			return &d.Types[i], nil
+		}
+	}
+	// Tolerate verbose answers that mention exactly one type.
+	var match *DocumentType
+	for i := range d.Types {
+		if strings.Contains(label, strings.ToLower(d.Types[i].Name)) {
+			if match != nil {
+				return nil, nil
+			}
+			match = &d.Types[i]
+		}
+	}
+	return match, nil
+}
+
+// documentType returns the registered type with the given name.
+func (d *DocumentWorkflow) documentType(name string) *DocumentType {
+	for i := range d.Types {
+		if d.Types[i].Name == name {
+			return &d.Types[i]
+		}
+	}
+	return &DocumentType{Name: name}
+}
+
+// defaultExtractor builds an LLMProgram that extracts the type's fields as JSON.
+func (d *DocumentWorkflow) defaultExtractor(docType *DocumentType) program.Program {
+	tmpl := strings.ReplaceAll(d.ExtractPrompt, "{fields_str}", strings.Join(docType.Fields, ", "))
+	return program.NewLLMProgram(d.LLM).
+		WithPrompt(prompts.NewPromptTemplate(tmpl, prompts.PromptTypeCustom))
+}
@@ -0,0 +1,430 @@
+package recipes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/tools"
+	"github.com/aqua777/go-llamaindex/workflow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticRetriever returns the same nodes for every query.
+type staticRetriever struct {
+	nodes []schema.NodeWithScore
+	err   error
+}
+
+func (r *staticRetriever) Retrieve(ctx context.Context, query schema.QueryBundle) ([]schema.NodeWithScore, error) {
+	return r.nodes, r.err
+}
+
+// keywordGrader marks nodes relevant when they contain the keyword.
+type keywordGrader struct {
+	keyword string
+}
+
+func (g *keywordGrader) Grade(ctx context.Context, query string, node schema.NodeWithScore) (bool, error) {
+	return strings.Contains(node.Node.Text, g.keyword), nil
+}
+
+// fakeWebSearcher records queries and returns fixed results.
+type fakeWebSearcher struct {
+	results []schema.NodeWithScore
+	queries []string
+	topK    int
+}
+
+func (s *fakeWebSearcher) Search(ctx context.Context, query string, topK int) ([]schema.NodeWithScore, error) {
+	s.queries = append(s.queries, query)
+	s.topK = topK
+	return s.results, nil
+}
+
+// recordingLLM returns a fixed response and records prompts.
+type recordingLLM struct {
+	*llm.MockLLM
+	response string
+	prompts  []string
+}
+
+func (l *recordingLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	l.prompts = append(l.prompts, prompt)
+	return l.response, nil
+}
+
+func textNode(id, text string, score float64) schema.NodeWithScore {
+	n := schema.NewTextNode(text)
+	n.ID = id
+	return schema.NodeWithScore{Node: *n, Score: score}
+}
+
+func nodeIDs(nodes []schema.NodeWithScore) []string {
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.Node.ID
+	}
+	return ids
+}
+
+func TestCorrectiveRAGRelevantDocuments(t *testing.T) {
+	ret := &staticRetriever{nodes: []schema.NodeWithScore{
+		textNode("a", "Go was designed at Google.", 0.9),
+		textNode("b", "Go has goroutines.", 0.8),
+	}}
+	web := &fakeWebSearcher{}
+	synth := synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("Go is a language."))
+
+	crag := NewCorrectiveRAG(ret, synth, nil,
+		WithCRAGGrader(&keywordGrader{keyword: "Go"}),
+		WithCRAGWebSearcher(web),
+	)
+
+	resp, err := crag.Query(context.Background(), "What is Go?")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Go is a language.", resp.Response)
+	assert.Equal(t, []string{"a", "b"}, nodeIDs(resp.SourceNodes))
+	assert.Empty(t, web.queries)
+	assert.Equal(t, false, resp.Metadata[CRAGUsedWebSearchKey])
+	assert.Equal(t, 1.0, resp.Metadata[CRAGRelevanceKey])
+}
+
+func TestCorrectiveRAGWebFallback(t *testing.T) {
+	ret := &staticRetriever{nodes: []schema.NodeWithScore{
+		textNode("a", "Rust has a borrow checker.", 0.7),
+		textNode("b", "Python is dynamic.", 0.6),
+		textNode("c", "Go has goroutines.", 0.5),
+	}}
+	web := &fakeWebSearcher{results: []schema.NodeWithScore{
+		textNode("web1", "Go 1.22 added range over integers.", 1.0),
+	}}
+	rewriter := &recordingLLM{MockLLM: llm.NewMockLLM(""), response: "\"go 1.22 release notes\""}
+	synth := synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("Range over integers."))
+
+	crag := NewCorrectiveRAG(ret, synth, rewriter,
+		WithCRAGGrader(&keywordGrader{keyword: "Go"}),
+		WithCRAGWebSearcher(web),
+		WithCRAGWebSearchTopK(5),
+	)
+
+	resp, err := crag.Query(context.Background(), "What did Go 1.22 add?")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"go 1.22 release notes"}, web.queries)
+	assert.Equal(t, 5, web.topK)
+	assert.Equal(t, []string{"c", "web1"}, nodeIDs(resp.SourceNodes))
+	assert.Equal(t, true, resp.Metadata[CRAGUsedWebSearchKey])
+	assert.Equal(t, "go 1.22 release notes", resp.Metadata[CRAGWebQueryKey])
+	assert.InDelta(t, 1.0/3.0, resp.Metadata[CRAGRelevanceKey], 1e-9)
+	require.Len(t, rewriter.prompts, 1)
+	assert.Contains(t, rewriter.prompts[0], "What did Go 1.22 add?")
+}
+
+func TestCorrectiveRAGWithoutWebSearcher(t *testing.T) {
+	ret := &staticRetriever{nodes: []schema.NodeWithScore{
+		textNode("a", "Unrelated.", 0.4),
+	}}
+	synth := synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("Empty Response"))
+
+	crag := NewCorrectiveRAG(ret, synth, nil, WithCRAGGrader(&keywordGrader{keyword: "Go"}))
+
+	resp, err := crag.Query(context.Background(), "What is Go?")
+	require.NoError(t, err)
+	assert.Empty(t, resp.SourceNodes)
+	assert.Equal(t, false, resp.Metadata[CRAGUsedWebSearchKey])
+}
+
+func TestCorrectiveRAGRetrievalError(t *testing.T) {
+	ret := &staticRetriever{err: errors.New("index unavailable")}
+	synth := synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("x"))
+
+	crag := NewCorrectiveRAG(ret, synth, nil)
+
+	_, err := crag.Query(context.Background(), "What is Go?")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "index unavailable")
+}
+
+func TestCorrectiveRAGWorkflowRejectsNonStringInput(t *testing.T) {
+	crag := NewCorrectiveRAG(&staticRetriever{}, synthesizer.NewSimpleSynthesizer(llm.NewMockLLM("x")), nil)
+
+	_, err := crag.Workflow().Run(context.Background(), workflow.NewStartEvent(42))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "query string")
+}
+
+func TestLLMRelevanceGrader(t *testing.T) {
+	ctx := context.Background()
+	node := textNode("a", "Go has goroutines.", 0.9)
+
+	yes := &recordingLLM{MockLLM: llm.NewMockLLM(""), response: " yes, it is relevant"}
+	relevant, err := NewLLMRelevanceGrader(yes).Grade(ctx, "What is Go?", node)
+	require.NoError(t, err)
+	assert.True(t, relevant)
+	assert.Contains(t, yes.prompts[0], "Go has goroutines.")
+	assert.Contains(t, yes.prompts[0], "What is Go?")
+
+	relevant, err = NewLLMRelevanceGrader(llm.NewMockLLM("NO")).Grade(ctx, "What is Go?", node)
+	require.NoError(t, err)
+	assert.False(t, relevant)
+}
+
+func TestNewCorrectiveRAGDefaults(t *testing.T) {
+	model := llm.NewMockLLM("YES")
+	crag := NewCorrectiveRAG(&staticRetriever{}, synthesizer.NewSimpleSynthesizer(model), model)
+
+	assert.Equal(t, 0.5, crag.RelevanceThreshold)
+	assert.Equal(t, 3, crag.WebSearchTopK)
+	assert.IsType(t, &LLMRelevanceGrader{}, crag.Grader)
+}
+
+// sequenceLLM returns responses in order and records prompts.
+type sequenceLLM struct {
+	*llm.MockLLM
+	responses []string
+	prompts   []string
+}
+
+func (s *sequenceLLM) Complete(ctx context.Context, prompt string) (string, error) {
+	s.prompts = append(s.prompts, prompt)
+	if len(s.responses) == 0 {
+		return "", nil
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return resp, nil
+}
+
+// fixedQueryEngine returns a fixed response.
+type fixedQueryEngine struct {
+	response *synthesizer.Response
+}
+
+func (e *fixedQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	return e.response, nil
+}
+
+func TestSelfRAGAcceptsFirstDraft(t *testing.T) {
+	engine := &fixedQueryEngine{response: synthesizer.NewResponse("Go was designed at Google.", []schema.NodeWithScore{
+		textNode("a", "Go was designed at Google.", 0.9),
+	})}
+	critic := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+		`{"score": 0.95, "acceptable": true, "issues": []}`,
+	}}
+
+	selfRAG := NewSelfRAGQueryEngine(engine, critic)
+	resp, err := selfRAG.Query(context.Background(), "Who designed Go?")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Go was designed at Google.", resp.Response)
+	require.Len(t, critic.prompts, 1)
+	trace, ok := resp.Metadata[SelfRAGIterationsKey].([]ReflectionIteration)
+	require.True(t, ok)
+	require.Len(t, trace, 1)
+	assert.Equal(t, 0.95, trace[0].Critique.Score)
+}
+
+func TestSelfRAGRetrievesAndRevises(t *testing.T) {
+	engine := &fixedQueryEngine{response: synthesizer.NewResponse("Go was designed at Google.", []schema.NodeWithScore{
+		textNode("a", "Go was designed at Google.", 0.9),
+	})}
+	ret := &staticRetriever{nodes: []schema.NodeWithScore{
+		textNode("a", "Go was designed at Google.", 0.9),
+		textNode("b", "Go was designed by Griesemer, Pike and Thompson.", 0.8),
+	}}
+	model := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+		"```json\n{\"score\": 0.3, \"acceptable\": false, \"issues\": [\"Does not name the designers\"], \"needs_retrieval\": true, \"retrieval_query\": \"Go designers\"}\n```",
+		"Go was designed by Griesemer, Pike and Thompson at Google.",
+		`{"score": 9, "acceptable": false}`,
+	}}
+
+	selfRAG := NewSelfRAGQueryEngine(engine, model, WithSelfRAGRetriever(ret))
+	resp, err := selfRAG.Query(context.Background(), "Who designed Go?")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Go was designed by Griesemer, Pike and Thompson at Google.", resp.Response)
+	assert.Equal(t, []string{"a", "b"}, nodeIDs(resp.SourceNodes))
+	require.Len(t, model.prompts, 3)
+	assert.Contains(t, model.prompts[1], "Does not name the designers")
+	assert.Contains(t, model.prompts[1], "Griesemer")
+
+	trace := resp.Metadata[SelfRAGIterationsKey].([]ReflectionIteration)
+	require.Len(t, trace, 2)
+	assert.Equal(t, []string{"b"}, trace[0].RetrievedNodeIDs)
+	assert.Equal(t, 1, trace[1].Iteration)
+	assert.InDelta(t, 0.9, trace[1].Critique.Score, 1e-9)
+}
+
+func TestSelfRAGMaxIterations(t *testing.T) {
+	engine := &fixedQueryEngine{response: synthesizer.NewResponse("draft", nil)}
+	model := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+		`{"score": 0.1, "issues": ["wrong"]}`,
+		"revision 1",
+		`{"score": 0.2, "issues": ["still wrong"]}`,
+		"revision 2",
+		`{"score": 0.3}`,
+	}}
+
+	selfRAG := NewSelfRAGQueryEngine(engine, model, WithSelfRAGMaxIterations(2))
+	resp, err := selfRAG.Query(context.Background(), "q")
+	require.NoError(t, err)
+
+	assert.Equal(t, "revision 1", resp.Response)
+	assert.Len(t, model.prompts, 3)
+	assert.Len(t, resp.Metadata[SelfRAGIterationsKey], 2)
+}
+
+func TestSelfRAGUnparsedCritique(t *testing.T) {
+	engine := &fixedQueryEngine{response: synthesizer.NewResponse("draft", nil)}
+	model := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{"looks fine to me"}}
+
+	selfRAG := NewSelfRAGQueryEngine(engine, model)
+	critique, err := selfRAG.Critique(context.Background(), "q", engine.response)
+	require.NoError(t, err)
+	assert.Equal(t, Critique{Unparsed: true}, critique)
+	assert.False(t, selfRAG.accepts(critique))
+
+	model.responses = []string{"looks fine to me"}
+	resp, err := selfRAG.Query(context.Background(), "q")
+	require.NoError(t, err)
+	assert.Equal(t, "draft", resp.Response)
+	assert.Len(t, model.prompts, 2, "no revision after an unparsed critique")
+	trace := resp.Metadata[SelfRAGIterationsKey].([]ReflectionIteration)
+	require.Len(t, trace, 1)
+	assert.True(t, trace[0].Critique.Unparsed)
+}
+
+func TestParseCritique(t *testing.T) {
+	critique, err := ParseCritique(`Here is my review: {"score": 0.4, "issues": ["vague"], "needs_retrieval": true}`)
+	require.NoError(t, err)
+	assert.Equal(t, 0.4, critique.Score)
+	assert.Equal(t, []string{"vague"}, critique.Issues)
+	assert.True(t, critique.NeedsRetrieval)
+
+	_, err = ParseCritique("looks fine to me")
+	assert.Error(t, err)
+}
+
+// recordingTool records the input it was called with.
+type recordingTool struct {
+	*tools.BaseTool
+	input interface{}
+}
+
+func (t *recordingTool) Call(ctx context.Context, input interface{}) (*tools.ToolOutput, error) {
+	t.input = input
+	return tools.NewToolOutput(t.Metadata().GetName(), "filed"), nil
+}
+
+func newInvoiceWorkflow(model llm.LLM, action tools.Tool) *DocumentWorkflow {
+	return NewDocumentWorkflow(model,
+		WithDocumentType(DocumentType{
+			Name:        "invoice",
+			Description: "A bill requesting payment",
+			Fields:      []string{"vendor", "amount"},
+			Rules: []ValidationRule{
+				RequiredFields("vendor", "amount"),
+				{Name: "amount_limit", Validate: func(fields map[string]interface{}) error {
+					if amount, _ := fields["amount"].(float64); amount > 1000 {
+						return fmt.Errorf("amount %.2f exceeds approval limit", amount)
+					}
+					return nil
+				}},
+			},
+			Action: action,
+		}),
+		WithDocumentType(DocumentType{
+			Name:        "resume",
+			Description: "A job application",
+			Fields:      []string{"name"},
+		}),
+	)
+}
+
+func TestDocumentWorkflowProcessesValidDocument(t *testing.T) {
+	model := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+		"Invoice",
+		`{"vendor": "Acme", "amount": 250}`,
+	}}
+	action := &recordingTool{BaseTool: tools.NewBaseTool(tools.NewToolMetadata("file_invoice", "Files an invoice"))}
+	wf := newInvoiceWorkflow(model, action)
+
+	doc := schema.Document{ID: "doc-1", Text: "Invoice from Acme. Total due: $250."}
+	result, err := wf.Process(context.Background(), doc)
+	require.NoError(t, err)
+
+	assert.Equal(t, DocumentStatusProcessed, result.Status)
+	assert.Equal(t, "invoice", result.DocType)
+	assert.Equal(t, "Acme", result.Fields["vendor"])
+	require.NotNil(t, result.ActionOutput)
+	assert.Equal(t, "filed", result.ActionOutput.Content)
+	assert.Equal(t, result.Fields, action.input)
+	require.Len(t, model.prompts, 2)
+	assert.Contains(t, model.prompts[1], "vendor, amount")
+	assert.Contains(t, model.prompts[1], "Total due: $250.")
+}
+
+func TestDocumentWorkflowRejectsInvalidDocument(t *testing.T) {
+	model := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+		"invoice",
+		`{"vendor": "", "amount": 5000}`,
+	}}
+	action := &recordingTool{BaseTool: tools.NewBaseTool(tools.NewToolMetadata("file_invoice", "Files an invoice"))}
+	wf := newInvoiceWorkflow(model, action)
+
+	result, err := wf.Process(context.Background(), schema.Document{ID: "doc-2", Text: "Invoice total $5000"})
+	require.NoError(t, err)
+
+	assert.Equal(t, DocumentStatusRejected, result.Status)
+	require.Len(t, result.Violations, 2)
+	assert.Contains(t, result.Violations[0], "vendor")
+	assert.Contains(t, result.Violations[1], "approval limit")
+	assert.Nil(t, action.input)
+}
+
+func TestDocumentWorkflowUnclassified(t *testing.T) {
+	model := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{"unknown"}}
+	wf := newInvoiceWorkflow(model, nil)
+
+	result, err := wf.Process(context.Background(), schema.Document{ID: "doc-3", Text: "A poem."})
+	require.NoError(t, err)
+	assert.Equal(t, DocumentStatusUnclassified, result.Status)
+	assert.Len(t, model.prompts, 1)
+}
+
+func TestDocumentWorkflowEmitsActionEvent(t *testing.T) {
+	model := &sequenceLLM{MockLLM: llm.NewMockLLM(""), responses: []string{
+		"resume",
+		`{"name": "Jane Doe"}`,
+	}}
+	wf := newInvoiceWorkflow(model, nil)
+
+	events, err := wf.Workflow().RunStream(context.Background(), workflow.NewStartEvent(schema.Document{ID: "doc-4", Text: "Jane Doe, engineer"})).ToArray()
+	require.NoError(t, err)
+
+	var actions []DocumentActionData
+	for _, e := range events {
+		if data, ok := DocumentActionEvent.Extract(e); ok {
+			actions = append(actions, data)
+		}
+	}
+	require.Len(t, actions, 1)
+	assert.Equal(t, "resume", actions[0].DocType)
+	assert.Equal(t, "Jane Doe", actions[0].Fields["name"])
+}
+
+func TestRequiredFields(t *testing.T) {
+	rule := RequiredFields("a", "b")
+	assert.NoError(t, rule.Validate(map[string]interface{}{"a": 1, "b": "x"}))
+	err := rule.Validate(map[string]interface{}{"a": nil})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "a, b")
+}
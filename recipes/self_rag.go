@@ -0,0 +1,420 @@
+package recipes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/rag/queryengine"
+	"github.com/aqua777/go-llamaindex/rag/retriever"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/workflow"
+)
+
+// Event types used by the Self-RAG workflow.
+const (
+	SelfRAGCritiqueEventType workflow.EventType = "self_rag.critique"
+	SelfRAGRetrieveEventType workflow.EventType = "self_rag.retrieve"
+	SelfRAGReviseEventType   workflow.EventType = "self_rag.revise"
+)
+
+// SelfRAGIterationsKey holds the []ReflectionIteration trace in Response.Metadata.
+const SelfRAGIterationsKey = "self_rag_iterations"
+
+// Critique is the structured judgement of a draft answer.
+type Critique struct {
+	// Score rates the answer from 0 (useless) to 1 (fully correct and complete).
+	Score float64 `json:"score"`
+	// Acceptable is true when the answer needs no further revision.
+	Acceptable bool `json:"acceptable"`
+	// Issues lists concrete problems to fix.
+	Issues []string `json:"issues"`
+	// NeedsRetrieval is true when the answer lacks supporting information.
+	NeedsRetrieval bool `json:"needs_retrieval"`
+	// RetrievalQuery is the query used to retrieve the missing information.
+	RetrievalQuery string `json:"retrieval_query"`
+	// Unparsed is true when the LLM output could not be parsed as a
+	// critique. Such critiques have a zero score and no issues.
+	Unparsed bool `json:"unparsed,omitempty"`
+}
+
+// ReflectionIteration records one critique of the generate→critique→revise loop.
+type ReflectionIteration struct {
+	// Iteration is the zero-based iteration number.
+	Iteration int
+	// Answer is the draft answer that was critiqued.
+	Answer string
+	// Critique is the judgement of the draft.
+	Critique Critique
+	// RetrievedNodeIDs are the IDs of nodes retrieved after the critique.
+	RetrievedNodeIDs []string
+}
+
+// SelfRAGCritiqueData carries a draft answer to the critique step.
+type SelfRAGCritiqueData struct {
+	Query     string
+	Iteration int
+	Response  *synthesizer.Response
+	Trace     []ReflectionIteration
+}
+
+// SelfRAGReviseData carries a critiqued answer to the retrieve and revise steps.
+type SelfRAGReviseData struct {
+	Query     string
+	Iteration int
+	Response  *synthesizer.Response
+	Critique  Critique
+	Trace     []ReflectionIteration
+}
+
+// Event factories for the Self-RAG workflow.
+var (
+	SelfRAGCritiqueEvent = workflow.NewEventFactory[SelfRAGCritiqueData](SelfRAGCritiqueEventType)
+	SelfRAGRetrieveEvent = workflow.NewEventFactory[SelfRAGReviseData](SelfRAGRetrieveEventType)
+	SelfRAGReviseEvent   = workflow.NewEventFactory[SelfRAGReviseData](SelfRAGReviseEventType)
+)
+
+// Default prompts for the Self-RAG workflow.
+const (
+	defaultSelfRAGCritiquePrompt = `You are reviewing an answer to a question for correctness, completeness and support by the sources.
+
+Question: {query_str}
+
+Sources:
+---------------------
+{context_str}
+---------------------
+
+Answer: {answer_str}
+
+Respond with a JSON object with the following fields:
+- "score": a number from 0 to 1 rating the answer
+- "acceptable": true if the answer needs no revision
+- "issues": a list of concrete problems with the answer
+- "needs_retrieval": true if the sources lack information needed to answer
+- "retrieval_query": a search query for the missing information, or ""`
+
+	defaultSelfRAGRevisePrompt = `Revise the answer to the question using the sources and fixing the listed issues.
+
+Question: {query_str}
+
+Sources:
+---------------------
+{context_str}
+---------------------
+
+Previous answer: {answer_str}
+
+Issues:
+{issues_str}
+
+Revised answer:`
+)
+
+// SelfRAGQueryEngine wraps a query engine with a Self-RAG reflection loop:
+// the wrapped engine generates a draft, an LLM critiques it against a
+// structured schema, and unacceptable drafts are revised, optionally after
+// retrieving more context, until the critique passes or MaxIterations
+// critiques have been made.
+type SelfRAGQueryEngine struct {
+	// QueryEngine generates the initial draft.
+	QueryEngine queryengine.QueryEngine
+	// LLM critiques and revises drafts.
+	LLM llm.LLM
+	// Retriever fetches extra context when a critique asks for it; nil
+	// disables additional retrieval.
+	Retriever retriever.Retriever
+	// MaxIterations is the maximum number of critiques.
+	MaxIterations int
+	// ScoreThreshold is the critique score at which a draft is accepted.
+	ScoreThreshold float64
+	// CritiquePrompt is the template used to critique drafts.
+	CritiquePrompt string
+	// RevisePrompt is the template used to revise drafts.
+	RevisePrompt string
+	// WorkflowOptions are passed to the underlying workflow.
+	WorkflowOptions []workflow.WorkflowOption
+}
+
+// SelfRAGOption configures a SelfRAGQueryEngine.
+type SelfRAGOption func(*SelfRAGQueryEngine)
+
+// WithSelfRAGRetriever sets the retriever used for critique-driven retrieval.
+func WithSelfRAGRetriever(ret retriever.Retriever) SelfRAGOption {
+	return func(s *SelfRAGQueryEngine) {
+		s.Retriever = ret
+	}
+}
+
+// WithSelfRAGMaxIterations sets the maximum number of critiques.
+func WithSelfRAGMaxIterations(maxIterations int) SelfRAGOption {
+	return func(s *SelfRAGQueryEngine) {
+		s.MaxIterations = maxIterations
+	}
+}
+
+// WithSelfRAGScoreThreshold sets the score at which a draft is accepted.
+func WithSelfRAGScoreThreshold(threshold float64) SelfRAGOption {
+	return func(s *SelfRAGQueryEngine) {
+		s.ScoreThreshold = threshold
+	}
+}
+
+// WithSelfRAGCritiquePrompt sets the critique prompt.
+func WithSelfRAGCritiquePrompt(prompt string) SelfRAGOption {
+	return func(s *SelfRAGQueryEngine) {
+		s.CritiquePrompt = prompt
+	}
+}
+
+// WithSelfRAGRevisePrompt sets the revision prompt.
+func WithSelfRAGRevisePrompt(prompt string) SelfRAGOption {
+	return func(s *SelfRAGQueryEngine) {
+		s.RevisePrompt = prompt
+	}
+}
+
+// WithSelfRAGWorkflowOptions sets options for the underlying workflow.
+func WithSelfRAGWorkflowOptions(opts ...workflow.WorkflowOption) SelfRAGOption {
+	return func(s *SelfRAGQueryEngine) {
+		s.WorkflowOptions = opts
+	}
+}
+
+// NewSelfRAGQueryEngine creates a new SelfRAGQueryEngine.
+func NewSelfRAGQueryEngine(engine queryengine.QueryEngine, llmModel llm.LLM, opts ...SelfRAGOption) *SelfRAGQueryEngine {
+	s := &SelfRAGQueryEngine{
+		QueryEngine:    engine,
+		LLM:            llmModel,
+		MaxIterations:  3,
+		ScoreThreshold: 0.8,
+		CritiquePrompt: defaultSelfRAGCritiquePrompt,
+		RevisePrompt:   defaultSelfRAGRevisePrompt,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Workflow builds the Self-RAG workflow. The start event input must be the
+// query string; the stop event result is a *synthesizer.Response.
+func (s *SelfRAGQueryEngine) Workflow() *workflow.Workflow {
+	opts := append([]workflow.WorkflowOption{workflow.WithWorkflowName("self_rag")}, s.WorkflowOptions...)
+	w := workflow.NewWorkflow(opts...)
+
+	workflow.HandleTyped(w, workflow.StartEvent, s.generateStep, workflow.BuildStepConfig(workflow.WithStepName("generate")))
+	workflow.HandleTyped(w, SelfRAGCritiqueEvent, s.critiqueStep, workflow.BuildStepConfig(workflow.WithStepName("critique")))
+	workflow.HandleTyped(w, SelfRAGRetrieveEvent, s.retrieveStep, workflow.BuildStepConfig(workflow.WithStepName("retrieve")))
+	workflow.HandleTyped(w, SelfRAGReviseEvent, s.reviseStep, workflow.BuildStepConfig(workflow.WithStepName("revise")))
+
+	return w
+}
+
+// Query runs the Self-RAG workflow for a query.
+func (s *SelfRAGQueryEngine) Query(ctx context.Context, query string) (*synthesizer.Response, error) {
+	result, err := s.Workflow().Run(ctx, workflow.NewStartEvent(query))
+	if err != nil {
+		return nil, err
+	}
+	return responseFromResult(result)
+}
+
+func (s *SelfRAGQueryEngine) generateStep(ctx *workflow.Context, data workflow.StartEventData) ([]workflow.Event, error) {
+	query, ok := data.Input.(string)
+	if !ok {
+		return nil, fmt.Errorf("self rag: start input must be a query string, got %T", data.Input)
+	}
+
+	response, err := s.QueryEngine.Query(ctx.Context(), query)
+	if err != nil {
+		return nil, fmt.Errorf("self rag: generation failed: %w", err)
+	}
+	return []workflow.Event{SelfRAGCritiqueEvent.With(SelfRAGCritiqueData{Query: query, Response: response})}, nil
+}
+
+func (s *SelfRAGQueryEngine) critiqueStep(ctx *workflow.Context, data SelfRAGCritiqueData) ([]workflow.Event, error) {
+	critique, err := s.Critique(ctx.Context(), data.Query, data.Response)
+	if err != nil {
+		return nil, fmt.Errorf("self rag: critique failed: %w", err)
+	}
+
+	trace := append(data.Trace, ReflectionIteration{
+		Iteration: data.Iteration,
+		Answer:    data.Response.Response,
+		Critique:  critique,
+	})
+
+	maxIterations := s.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = 1
+	}
+	// Without a parsed critique there are no issues to revise against, so
+	// stop with the current draft rather than revising blindly.
+	if critique.Unparsed || s.accepts(critique) || data.Iteration+1 >= maxIterations {
+		return []workflow.Event{workflow.NewStopEvent(withTrace(data.Response, trace))}, nil
+	}
+
+	next := SelfRAGReviseData{
+		Query:     data.Query,
+		Iteration: data.Iteration,
+		Response:  data.Response,
+		Critique:  critique,
+		Trace:     trace,
+	}
+	if critique.NeedsRetrieval && s.Retriever != nil {
+		return []workflow.Event{SelfRAGRetrieveEvent.With(next)}, nil
+	}
+	return []workflow.Event{SelfRAGReviseEvent.With(next)}, nil
+}
+
+func (s *SelfRAGQueryEngine) retrieveStep(ctx *workflow.Context, data SelfRAGReviseData) ([]workflow.Event, error) {
+	retrievalQuery := data.Critique.RetrievalQuery
+	if retrievalQuery == "" {
+		retrievalQuery = data.Query
+	}
+
+	nodes, err := s.Retriever.Retrieve(ctx.Context(), schema.QueryBundle{QueryString: retrievalQuery})
+	if err != nil {
+		return nil, fmt.Errorf("self rag: retrieval failed: %w", err)
+	}
+
+	seen := make(map[string]bool, len(data.Response.SourceNodes))
+	for _, n := range data.Response.SourceNodes {
+		seen[n.Node.ID] = true
+	}
+
+	sources := append([]schema.NodeWithScore{}, data.Response.SourceNodes...)
+	last := &data.Trace[len(data.Trace)-1]
+	for _, n := range nodes {
+		if seen[n.Node.ID] {
+			continue
+		}
+		seen[n.Node.ID] = true
+		sources = append(sources, n)
+		last.RetrievedNodeIDs = append(last.RetrievedNodeIDs, n.Node.ID)
+	}
+
+	data.Response = synthesizer.NewResponseWithMetadata(data.Response.Response, sources, data.Response.Metadata)
+	return []workflow.Event{SelfRAGReviseEvent.With(data)}, nil
+}
+
+func (s *SelfRAGQueryEngine) reviseStep(ctx *workflow.Context, data SelfRAGReviseData) ([]workflow.Event, error) {
+	issues := data.Critique.Issues
+	if len(issues) == 0 {
+		issues = []string{"The answer was judged insufficient; make it more accurate and complete."}
+	}
+
+	prompt := strings.NewReplacer(
+		"{query_str}", data.Query,
+		"{context_str}", joinSources(data.Response.SourceNodes),
+		"{answer_str}", data.Response.Response,
+		"{issues_str}", "- "+strings.Join(issues, "\n- "),
+	).Replace(s.RevisePrompt)
+
+	revised, err := s.LLM.Complete(ctx.Context(), prompt)
+	if err != nil {
+		return nil, fmt.Errorf("self rag: revision failed: %w", err)
+	}
+
+	response := synthesizer.NewResponseWithMetadata(strings.TrimSpace(revised), data.Response.SourceNodes, data.Response.Metadata)
+	return []workflow.Event{SelfRAGCritiqueEvent.With(SelfRAGCritiqueData{
+		Query:     data.Query,
+		Iteration: data.Iteration + 1,
+		Response:  response,
+		Trace:     data.Trace,
+	})}, nil
+}
+
+// Critique asks the LLM for a structured critique of a draft answer. An
+// unparseable critique is returned with a zero score and Unparsed set,
+// leaving it to the caller whether to stop or revise anyway.
+func (s *SelfRAGQueryEngine) Critique(ctx context.Context, query string, response *synthesizer.Response) (Critique, error) {
+	prompt := strings.NewReplacer(
+		"{query_str}", query,
+		"{context_str}", joinSources(response.SourceNodes),
+		"{answer_str}", response.Response,
+	).Replace(s.CritiquePrompt)
+
+	output, err := s.LLM.Complete(ctx, prompt)
+	if err != nil {
+		return Critique{}, err
+	}
+
+	critique, err := ParseCritique(output)
+	if err != nil {
+		return Critique{Unparsed: true}, nil
+	}
+	return critique, nil
+}
+
+// accepts reports whether a critique ends the loop.
+func (s *SelfRAGQueryEngine) accepts(critique Critique) bool {
+	return critique.Acceptable || critique.Score >= s.ScoreThreshold
+}
+
+// ParseCritique parses a JSON critique from LLM output.
+func ParseCritique(output string) (Critique, error) {
+	jsonStr := extractJSON(output)
+	if jsonStr == "" {
+		return Critique{}, fmt.Errorf("no JSON found in critique: %q", output)
+	}
+
+	var critique Critique
+	if err := json.Unmarshal([]byte(jsonStr), &critique); err != nil {
+		return Critique{}, fmt.Errorf("failed to parse critique: %w", err)
+	}
+	if critique.Score > 1 && critique.Score <= 10 {
+		// Tolerate 1-10 ratings.
+		critique.Score /= 10
+	}
+	return critique, nil
+}
+
+// withTrace returns a copy of response with the reflection trace attached.
+func withTrace(response *synthesizer.Response, trace []ReflectionIteration) *synthesizer.Response {
+	metadata := make(map[string]interface{}, len(response.Metadata)+1)
+	for k, v := range response.Metadata {
+		metadata[k] = v
+	}
+	metadata[SelfRAGIterationsKey] = trace
+	return synthesizer.NewResponseWithMetadata(response.Response, response.SourceNodes, metadata)
+}
+
+// joinSources formats source nodes for inclusion in a prompt.
+func joinSources(nodes []schema.NodeWithScore) string {
+	if len(nodes) == 0 {
+		return "(no sources)"
+	}
+	parts := make([]string, len(nodes))
+	for i, n := range nodes {
+		parts[i] = fmt.Sprintf("[%d] %s", i+1, n.Node.GetContent(schema.MetadataModeLLM))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// extractJSON extracts a JSON object from text, tolerating code fences.
+func extractJSON(text string) string {
+	if start := strings.Index(text, "```"); start != -1 {
+		body := text[start+3:]
+		body = strings.TrimPrefix(body, "json")
+		if end := strings.Index(body, "```"); end != -1 {
+			text = body[:end]
+		}
+	}
+
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start == -1 || end <= start {
+		return ""
+	}
+	return text[start : end+1]
+}
+
+// Ensure SelfRAGQueryEngine implements QueryEngine.
+var _ queryengine.QueryEngine = (*SelfRAGQueryEngine)(nil)
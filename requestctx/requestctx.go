@@ -0,0 +1,138 @@
+// Package requestctx attaches caller metadata such as the user ID, locale
+// and channel to a context, so that prompt templates and guardrails deep in
+// a pipeline can use it without extra parameters.
+//
+// Values are only exposed to prompts for keys a template explicitly allows
+// (see prompts.PromptTemplate.RequestVars), and are sanitized first so that
+// caller-controlled metadata cannot inject template placeholders or
+// break out of the line it is placed on.
+package requestctx
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+// Well-known metadata keys.
+const (
+	// UserIDKey is the ID of the end user a request is made for.
+	UserIDKey = "user_id"
+	// LocaleKey is the user's locale, e.g. "fr-CA".
+	LocaleKey = "locale"
+	// ChannelKey is the channel the request came through, e.g. "slack".
+	ChannelKey = "channel"
+)
+
+// MaxValueLength is the length in runes beyond which Sanitize truncates
+// values.
+const MaxValueLength = 256
+
+// contextKey is the context key of the request metadata.
+type contextKey struct{}
+
+// WithValue returns a context carrying key set to value, in addition to
+// the metadata already in ctx. Keys should be identifiers ([A-Za-z0-9_])
+// so that templates can reference them as {key}.
+func WithValue(ctx context.Context, key, value string) context.Context {
+	return WithValues(ctx, map[string]string{key: value})
+}
+
+// WithValues returns a context carrying the given metadata in addition to
+// the metadata already in ctx. Later values replace earlier ones.
+func WithValues(ctx context.Context, values map[string]string) context.Context {
+	current, _ := ctx.Value(contextKey{}).(map[string]string)
+	merged := make(map[string]string, len(current)+len(values))
+	for k, v := range current {
+		merged[k] = v
+	}
+	for k, v := range values {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, contextKey{}, merged)
+}
+
+// WithUserID returns a context carrying the user ID.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return WithValue(ctx, UserIDKey, userID)
+}
+
+// WithLocale returns a context carrying the locale.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return WithValue(ctx, LocaleKey, locale)
+}
+
+// WithChannel returns a context carrying the channel.
+func WithChannel(ctx context.Context, channel string) context.Context {
+	return WithValue(ctx, ChannelKey, channel)
+}
+
+// Value returns the metadata value of key and whether it is set.
+func Value(ctx context.Context, key string) (string, bool) {
+	values, _ := ctx.Value(contextKey{}).(map[string]string)
+	v, ok := values[key]
+	return v, ok
+}
+
+// UserID returns the user ID, or an empty string.
+func UserID(ctx context.Context) string {
+	v, _ := Value(ctx, UserIDKey)
+	return v
+}
+
+// Locale returns the locale, or an empty string.
+func Locale(ctx context.Context) string {
+	v, _ := Value(ctx, LocaleKey)
+	return v
+}
+
+// Channel returns the channel, or an empty string.
+func Channel(ctx context.Context) string {
+	v, _ := Value(ctx, ChannelKey)
+	return v
+}
+
+// Values returns a copy of all metadata in ctx.
+func Values(ctx context.Context) map[string]string {
+	values, _ := ctx.Value(contextKey{}).(map[string]string)
+	out := make(map[string]string, len(values))
+	for k, v := range values {
+		out[k] = v
+	}
+	return out
+}
+
+// Allowed returns the sanitized values of the allowed keys, for use as
+// template variables or by guardrails. Allowed keys missing from ctx map to
+// an empty string, so their placeholders do not leak into prompts.
+func Allowed(ctx context.Context, allow []string) map[string]string {
+	values, _ := ctx.Value(contextKey{}).(map[string]string)
+	out := make(map[string]string, len(allow))
+	for _, key := range allow {
+		out[key] = Sanitize(values[key])
+	}
+	return out
+}
+
+// Sanitize makes a caller-supplied value safe to place in a prompt: braces
+// are removed so it cannot form template placeholders, line breaks and
+// other control characters become spaces, and it is truncated to
+// MaxValueLength runes.
+func Sanitize(value string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range value {
+		if n == MaxValueLength {
+			break
+		}
+		switch {
+		case r == '{' || r == '}':
+			continue
+		case unicode.IsControl(r):
+			r = ' '
+		}
+		b.WriteRune(r)
+		n++
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
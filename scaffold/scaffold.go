@@ -0,0 +1,290 @@
+// Package scaffold generates starter projects for agents, workflows and RAG
+// pipelines built with go-llamaindex.
+//
+// A generated project is a small, working Go program: a config file, the
+// app itself, an HTTP server exposing it, and a test that runs the app and
+// the server against a mock LLM, so it passes before any API key is set.
+package scaffold
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"go/format"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Template is a kind of project.
+type Template string
+
+const (
+	// TemplateAgent is a ReAct agent with a function tool.
+	TemplateAgent Template = "agent"
+	// TemplateWorkflow is an event-driven workflow that drafts and refines
+	// an answer in two steps.
+	TemplateWorkflow Template = "workflow"
+	// TemplateRAG is a RAG pipeline that indexes a data directory and
+	// answers questions over it.
+	TemplateRAG Template = "rag"
+)
+
+// Templates returns the available templates.
+func Templates() []Template {
+	return []Template{TemplateAgent, TemplateWorkflow, TemplateRAG}
+}
+
+// Provider defaults. The mock provider needs no credentials.
+var providerDefaults = map[string]struct{ model, embedModel string }{
+	"openai": {model: "gpt-4o-mini", embedModel: "text-embedding-3-small"},
+	"ollama": {model: "llama3.1", embedModel: "nomic-embed-text"},
+	"mock":   {model: "mock", embedModel: "mock"},
+}
+
+// ErrFileExists is returned when a file to generate already exists and
+// overwriting was not requested.
+var ErrFileExists = errors.New("file already exists")
+
+//go:embed templates
+var templateFS embed.FS
+
+// projectName matches names usable as directory and Go module names.
+var projectName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]*$`)
+
+// Project configures a generated project.
+type Project struct {
+	// Name is the project name, used for the directory and binary.
+	Name string
+	// Template is the kind of project.
+	Template Template
+	// Module is the Go module path. Defaults to Name.
+	Module string
+	// Provider is the LLM provider written to the config: "openai",
+	// "ollama" or "mock". Defaults to "openai".
+	Provider string
+	// Model is the LLM model. Defaults to the provider's default.
+	Model string
+	// EmbedModel is the embedding model of RAG projects. Defaults to the
+	// provider's default.
+	EmbedModel string
+	// LlamaIndexPath, if set, adds a replace directive pointing
+	// go-llamaindex at a local checkout.
+	LlamaIndexPath string
+}
+
+// Option configures a Project.
+type Option func(*Project)
+
+// WithModule sets the Go module path.
+func WithModule(module string) Option {
+	return func(p *Project) {
+		p.Module = module
+	}
+}
+
+// WithProvider sets the LLM provider.
+func WithProvider(provider string) Option {
+	return func(p *Project) {
+		p.Provider = provider
+	}
+}
+
+// WithModel sets the LLM model.
+func WithModel(model string) Option {
+	return func(p *Project) {
+		p.Model = model
+	}
+}
+
+// WithEmbedModel sets the embedding model of RAG projects.
+func WithEmbedModel(model string) Option {
+	return func(p *Project) {
+		p.EmbedModel = model
+	}
+}
+
+// WithLlamaIndexPath points go-llamaindex at a local checkout.
+func WithLlamaIndexPath(dir string) Option {
+	return func(p *Project) {
+		p.LlamaIndexPath = dir
+	}
+}
+
+// NewProject creates a Project and fills in defaults.
+func NewProject(kind Template, name string, opts ...Option) (*Project, error) {
+	p := &Project{
+		Name:     name,
+		Template: kind,
+		Provider: "openai",
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if !projectName.MatchString(p.Name) {
+		return nil, fmt.Errorf("invalid project name %q: use letters, digits, '-', '_' and '.'", p.Name)
+	}
+	if !isTemplate(p.Template) {
+		return nil, fmt.Errorf("unknown template %q: want one of %s", p.Template, templateList())
+	}
+	defaults, ok := providerDefaults[p.Provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q: want openai, ollama or mock", p.Provider)
+	}
+	if p.Module == "" {
+		p.Module = p.Name
+	}
+	if p.Model == "" {
+		p.Model = defaults.model
+	}
+	if p.EmbedModel == "" {
+		p.EmbedModel = defaults.embedModel
+	}
+
+	return p, nil
+}
+
+// Render returns the project's files keyed by slash-separated path. Go files
+// are gofmt-ed.
+func (p *Project) Render() (map[string][]byte, error) {
+	files := make(map[string][]byte)
+
+	for _, dir := range []string{"common", string(p.Template)} {
+		root := path.Join("templates", dir)
+		err := fs.WalkDir(templateFS, root, func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+
+			content, err := p.renderFile(name)
+			if err != nil {
+				return err
+			}
+			if content == nil {
+				return nil
+			}
+
+			files[outputName(strings.TrimPrefix(name, root+"/"))] = content
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// Generate writes the project into dir, which is created if needed. It
+// refuses to overwrite existing files unless force is set, and returns the
+// written paths relative to dir.
+func (p *Project) Generate(dir string, force bool) ([]string, error) {
+	files, err := p.Render()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if !force {
+		for _, name := range names {
+			target := filepath.Join(dir, filepath.FromSlash(name))
+			if _, err := os.Stat(target); err == nil {
+				return nil, fmt.Errorf("%w: %s", ErrFileExists, target)
+			}
+		}
+	}
+
+	for _, name := range names {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory for %s: %w", target, err)
+		}
+		if err := os.WriteFile(target, files[name], 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", target, err)
+		}
+	}
+
+	return names, nil
+}
+
+// Generate creates a project of the given kind in dir/name.
+func Generate(kind Template, name, dir string, force bool, opts ...Option) ([]string, error) {
+	p, err := NewProject(kind, name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return p.Generate(filepath.Join(dir, name), force)
+}
+
+// renderFile executes one template. Templates rendering to blank output are
+// skipped, which lets a common file opt out for some project kinds.
+func (p *Project) renderFile(name string) ([]byte, error) {
+	raw, err := templateFS.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(path.Base(name)).Parse(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, p); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	if len(bytes.TrimSpace(buf.Bytes())) == 0 {
+		return nil, nil
+	}
+
+	content := buf.Bytes()
+	if strings.HasSuffix(outputName(name), ".go") {
+		content, err = format.Source(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to format %s: %w", name, err)
+		}
+	}
+	return content, nil
+}
+
+// outputName maps a template path to the generated path: the .tmpl suffix
+// is dropped and a leading "dot_" becomes ".", since the embedded tree
+// avoids hidden files.
+func outputName(name string) string {
+	name = strings.TrimSuffix(name, ".tmpl")
+	dir, base := path.Split(name)
+	if strings.HasPrefix(base, "dot_") {
+		base = "." + strings.TrimPrefix(base, "dot_")
+	}
+	return dir + base
+}
+
+func isTemplate(t Template) bool {
+	for _, known := range Templates() {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+func templateList() string {
+	names := make([]string, 0, len(Templates()))
+	for _, t := range Templates() {
+		names = append(names, string(t))
+	}
+	return strings.Join(names, ", ")
+}
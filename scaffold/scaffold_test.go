@@ -0,0 +1,91 @@
+package scaffold
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProject(t *testing.T) {
+	p, err := NewProject(TemplateRAG, "docs-bot", WithProvider("ollama"))
+	require.NoError(t, err)
+	assert.Equal(t, "docs-bot", p.Module)
+	assert.Equal(t, "llama3.1", p.Model)
+	assert.Equal(t, "nomic-embed-text", p.EmbedModel)
+
+	_, err = NewProject("chatbot", "bot")
+	assert.ErrorContains(t, err, "unknown template")
+
+	_, err = NewProject(TemplateAgent, "my bot")
+	assert.ErrorContains(t, err, "invalid project name")
+
+	_, err = NewProject(TemplateAgent, "bot", WithProvider("acme"))
+	assert.ErrorContains(t, err, "unknown provider")
+}
+
+func TestRender(t *testing.T) {
+	common := []string{".env.example", ".gitignore", "README.md", "app.go", "app_test.go", "config.go", "config.json", "go.mod", "main.go", "server.go"}
+
+	for _, kind := range Templates() {
+		t.Run(string(kind), func(t *testing.T) {
+			p, err := NewProject(kind, "demo", WithModule("example.com/demo"))
+			require.NoError(t, err)
+
+			files, err := p.Render()
+			require.NoError(t, err)
+
+			for _, name := range common {
+				assert.Contains(t, files, name)
+			}
+			if kind == TemplateRAG {
+				assert.Contains(t, files, "data/example.md")
+			}
+
+			// Go files parse, and the test runs against a mock LLM.
+			for name, content := range files {
+				if strings.HasSuffix(name, ".go") {
+					_, err := parser.ParseFile(token.NewFileSet(), name, content, parser.AllErrors)
+					assert.NoError(t, err, name)
+				}
+			}
+			assert.Contains(t, string(files["app_test.go"]), "llm.NewMockLLM")
+			assert.Contains(t, string(files["server.go"]), `"/run"`)
+			assert.Contains(t, string(files["server.go"]), `"/stream"`)
+			assert.Contains(t, string(files["app.go"]), "func (a *App) Stream(")
+
+			var cfg map[string]interface{}
+			require.NoError(t, json.Unmarshal(files["config.json"], &cfg))
+			assert.Equal(t, "openai", cfg["provider"])
+			assert.Equal(t, kind == TemplateRAG, cfg["data_dir"] != nil)
+
+			assert.True(t, strings.HasPrefix(string(files["go.mod"]), "module example.com/demo\n"))
+			assert.NotContains(t, string(files["go.mod"]), "replace")
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+
+	written, err := Generate(TemplateWorkflow, "flow", dir, false, WithLlamaIndexPath("../go-llamaindex"))
+	require.NoError(t, err)
+	assert.Contains(t, written, "app.go")
+
+	goMod, err := os.ReadFile(filepath.Join(dir, "flow", "go.mod"))
+	require.NoError(t, err)
+	assert.Contains(t, string(goMod), "replace github.com/aqua777/go-llamaindex => ../go-llamaindex")
+
+	// Existing files are kept unless forced.
+	_, err = Generate(TemplateWorkflow, "flow", dir, false)
+	assert.ErrorIs(t, err, ErrFileExists)
+
+	_, err = Generate(TemplateWorkflow, "flow", dir, true)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,39 @@
+package schema
+
+// EmbeddingModelKey is the metadata key recording which embedding model (and
+// version) produced a node's embedding. It is excluded from embed and LLM
+// content so that stamping a node does not change what gets embedded.
+const EmbeddingModelKey = "embedding_model"
+
+// SetEmbeddingModel records the embedding model that produced the node's embedding.
+func (n *Node) SetEmbeddingModel(model string) {
+	if n.Metadata == nil {
+		n.Metadata = make(map[string]interface{})
+	}
+	n.Metadata[EmbeddingModelKey] = model
+	n.ExcludeEmbeddingModelKey()
+}
+
+// GetEmbeddingModel returns the recorded embedding model, or "" if unknown.
+func (n *Node) GetEmbeddingModel() string {
+	model, _ := n.Metadata[EmbeddingModelKey].(string)
+	return model
+}
+
+// ExcludeEmbeddingModelKey makes sure the embedding model stamp is excluded
+// from embed and LLM content. Vector stores that only persist metadata lose
+// the exclusion lists, so callers re-embedding stored nodes should call this
+// first.
+func (n *Node) ExcludeEmbeddingModelKey() {
+	n.ExcludedEmbedMetadataKeys = appendIfMissing(n.ExcludedEmbedMetadataKeys, EmbeddingModelKey)
+	n.ExcludedLLMMetadataKeys = appendIfMissing(n.ExcludedLLMMetadataKeys, EmbeddingModelKey)
+}
+
+func appendIfMissing(keys []string, key string) []string {
+	for _, k := range keys {
+		if k == key {
+			return keys
+		}
+	}
+	return append(keys, key)
+}
@@ -0,0 +1,457 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// filterOperatorAliases maps the word forms accepted by
+// ParseMetadataFilters to filter operators.
+var filterOperatorAliases = map[string]FilterOperator{
+	"==": FilterOperatorEq, "=": FilterOperatorEq, "eq": FilterOperatorEq,
+	"!=": FilterOperatorNe, "ne": FilterOperatorNe,
+	">": FilterOperatorGt, "gt": FilterOperatorGt,
+	">=": FilterOperatorGte, "gte": FilterOperatorGte,
+	"<": FilterOperatorLt, "lt": FilterOperatorLt,
+	"<=": FilterOperatorLte, "lte": FilterOperatorLte,
+	"in": FilterOperatorIn, "nin": FilterOperatorNin,
+	"any": FilterOperatorAny, "all": FilterOperatorAll,
+	"contains":               FilterOperatorContains,
+	"text_match":             FilterOperatorTextMatch,
+	"text_match_insensitive": FilterOperatorTextMatchInsensitive,
+}
+
+// ParseMetadataFilters parses a filter expression such as
+//
+//	year >= 2020 AND (genre in ["sci-fi", "fantasy"] OR author == 'Le Guin')
+//
+// Comparisons have the form `key operator value`, where the operator is a
+// symbol (==, !=, >, >=, <, <=) or a word (eq, ne, gt, gte, lt, lte, in,
+// nin, not in, any, all, contains, text_match, text_match_insensitive), or
+// the form `key is empty`. Values are quoted strings, numbers, true, false
+// or lists in brackets. Comparisons combine with AND, OR, NOT and
+// parentheses; AND binds tighter than OR. Keywords are case-insensitive.
+func ParseMetadataFilters(expr string) (*MetadataFilters, error) {
+	tokens, err := tokenizeFilterExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty filter expression")
+	}
+
+	p := &filterParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.peek().text, p.peek().pos)
+	}
+	if node.filter != nil {
+		return NewMetadataFilters(*node.filter), nil
+	}
+	return node.group, nil
+}
+
+// MustParseMetadataFilters is like ParseMetadataFilters but panics if the
+// expression cannot be parsed. It simplifies initialization of filters
+// from constant expressions.
+func MustParseMetadataFilters(expr string) *MetadataFilters {
+	filters, err := ParseMetadataFilters(expr)
+	if err != nil {
+		panic(fmt.Sprintf("schema: ParseMetadataFilters(%q): %v", expr, err))
+	}
+	return filters
+}
+
+// String renders the filters in the syntax accepted by
+// ParseMetadataFilters.
+func (mf *MetadataFilters) String() string {
+	if mf == nil {
+		return ""
+	}
+	var parts []string
+	for _, f := range mf.Filters {
+		parts = append(parts, f.String())
+	}
+	for _, nested := range mf.Nested {
+		if s := nested.String(); s != "" {
+			parts = append(parts, "("+s+")")
+		}
+	}
+
+	switch mf.Condition {
+	case FilterConditionOr:
+		return strings.Join(parts, " OR ")
+	case FilterConditionNot:
+		if len(parts) == 0 {
+			return ""
+		}
+		return "NOT (" + strings.Join(parts, " AND ") + ")"
+	default:
+		return strings.Join(parts, " AND ")
+	}
+}
+
+// String renders the filter in the syntax accepted by
+// ParseMetadataFilters.
+func (f MetadataFilter) String() string {
+	key := f.Key
+	if !isFilterIdent(key) {
+		key = strconv.Quote(key)
+	}
+	switch f.Operator {
+	case FilterOperatorIsEmpty:
+		return key + " is empty"
+	case "":
+		return fmt.Sprintf("%s == %s", key, formatFilterValue(f.Value))
+	default:
+		return fmt.Sprintf("%s %s %s", key, f.Operator, formatFilterValue(f.Value))
+	}
+}
+
+// formatFilterValue renders a literal value.
+func formatFilterValue(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return strconv.Quote(value)
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = formatFilterValue(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case []string:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = strconv.Quote(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+// filterToken is a lexical token of a filter expression.
+type filterToken struct {
+	kind filterTokenKind
+	text string
+	pos  int
+}
+
+type filterTokenKind int
+
+const (
+	filterTokenWord filterTokenKind = iota
+	filterTokenString
+	filterTokenNumber
+	filterTokenSymbol
+)
+
+// tokenizeFilterExpr splits a filter expression into tokens.
+func tokenizeFilterExpr(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				sb.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			i++
+			tokens = append(tokens, filterToken{kind: filterTokenString, text: sb.String(), pos: start})
+		case strings.ContainsRune("()[],", r):
+			tokens = append(tokens, filterToken{kind: filterTokenSymbol, text: string(r), pos: i})
+			i++
+		case strings.ContainsRune("=!<>", r):
+			start := i
+			i++
+			if i < len(runes) && runes[i] == '=' {
+				i++
+			}
+			op := string(runes[start:i])
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected '!' at offset %d", start)
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenSymbol, text: op, pos: start})
+		case r == '-' || r == '+' || unicode.IsDigit(r):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || strings.ContainsRune(".eE+-", runes[i])) {
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenNumber, text: string(runes[start:i]), pos: start})
+		case isFilterIdentRune(r):
+			start := i
+			for i < len(runes) && isFilterIdentRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenWord, text: string(runes[start:i]), pos: start})
+		default:
+			return nil, fmt.Errorf("unexpected %q at offset %d", r, i)
+		}
+	}
+	return tokens, nil
+}
+
+func isFilterIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-'
+}
+
+// isFilterIdent reports whether key can be written without quotes.
+func isFilterIdent(key string) bool {
+	if key == "" || unicode.IsDigit(rune(key[0])) || key[0] == '-' {
+		return false
+	}
+	for _, r := range key {
+		if !isFilterIdentRune(r) {
+			return false
+		}
+	}
+	switch strings.ToLower(key) {
+	case "and", "or", "not", "is", "empty", "true", "false":
+		return false
+	}
+	return true
+}
+
+// filterNode is a parsed expression: either a single filter or a group.
+type filterNode struct {
+	filter *MetadataFilter
+	group  *MetadataFilters
+}
+
+// filterParser is a recursive descent parser over filter tokens.
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *filterParser) peek() filterToken {
+	if p.done() {
+		return filterToken{pos: -1}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() (filterToken, error) {
+	if p.done() {
+		return filterToken{}, fmt.Errorf("unexpected end of filter expression")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	return tok, nil
+}
+
+// acceptWord consumes the next token if it is the given keyword.
+func (p *filterParser) acceptWord(word string) bool {
+	tok := p.peek()
+	if !p.done() && tok.kind == filterTokenWord && strings.EqualFold(tok.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// acceptSymbol consumes the next token if it is the given symbol.
+func (p *filterParser) acceptSymbol(symbol string) bool {
+	tok := p.peek()
+	if !p.done() && tok.kind == filterTokenSymbol && tok.text == symbol {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	return p.parseChain("or", FilterConditionOr, p.parseAnd)
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	return p.parseChain("and", FilterConditionAnd, p.parseUnary)
+}
+
+// parseChain parses operands separated by keyword into a group with the
+// given condition.
+func (p *filterParser) parseChain(keyword string, condition FilterCondition, operand func() (filterNode, error)) (filterNode, error) {
+	first, err := operand()
+	if err != nil {
+		return filterNode{}, err
+	}
+	if !p.acceptWord(keyword) {
+		return first, nil
+	}
+
+	group := &MetadataFilters{Condition: condition}
+	addFilterNode(group, first)
+	for {
+		n, err := operand()
+		if err != nil {
+			return filterNode{}, err
+		}
+		addFilterNode(group, n)
+		if !p.acceptWord(keyword) {
+			return filterNode{group: group}, nil
+		}
+	}
+}
+
+// addFilterNode adds n to group, flattening groups with the same condition.
+func addFilterNode(group *MetadataFilters, n filterNode) {
+	switch {
+	case n.filter != nil:
+		group.Filters = append(group.Filters, *n.filter)
+	case n.group.Condition == group.Condition:
+		group.Filters = append(group.Filters, n.group.Filters...)
+		group.Nested = append(group.Nested, n.group.Nested...)
+	default:
+		group.Nested = append(group.Nested, n.group)
+	}
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.acceptWord("not") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return filterNode{}, err
+		}
+		group := &MetadataFilters{Condition: FilterConditionNot}
+		if operand.filter != nil {
+			group.Filters = []MetadataFilter{*operand.filter}
+		} else {
+			group.Nested = []*MetadataFilters{operand.group}
+		}
+		return filterNode{group: group}, nil
+	}
+
+	if p.acceptSymbol("(") {
+		n, err := p.parseOr()
+		if err != nil {
+			return filterNode{}, err
+		}
+		if !p.acceptSymbol(")") {
+			return filterNode{}, p.expected("')'")
+		}
+		return n, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterNode, error) {
+	keyTok, err := p.next()
+	if err != nil {
+		return filterNode{}, err
+	}
+	if keyTok.kind != filterTokenWord && keyTok.kind != filterTokenString {
+		return filterNode{}, fmt.Errorf("expected metadata key at offset %d, got %q", keyTok.pos, keyTok.text)
+	}
+	filter := MetadataFilter{Key: keyTok.text}
+
+	if p.acceptWord("is") {
+		if !p.acceptWord("empty") {
+			return filterNode{}, p.expected("'empty'")
+		}
+		filter.Operator = FilterOperatorIsEmpty
+		return filterNode{filter: &filter}, nil
+	}
+
+	opTok, err := p.next()
+	if err != nil {
+		return filterNode{}, err
+	}
+	if strings.EqualFold(opTok.text, "not") && opTok.kind == filterTokenWord {
+		if !p.acceptWord("in") {
+			return filterNode{}, p.expected("'in'")
+		}
+		filter.Operator = FilterOperatorNin
+	} else {
+		op, ok := filterOperatorAliases[strings.ToLower(opTok.text)]
+		if !ok || opTok.kind == filterTokenString || opTok.kind == filterTokenNumber {
+			return filterNode{}, fmt.Errorf("unknown filter operator %q at offset %d", opTok.text, opTok.pos)
+		}
+		filter.Operator = op
+	}
+
+	if filter.Value, err = p.parseValue(); err != nil {
+		return filterNode{}, err
+	}
+	return filterNode{filter: &filter}, nil
+}
+
+func (p *filterParser) parseValue() (interface{}, error) {
+	if p.acceptSymbol("[") {
+		values := []interface{}{}
+		if p.acceptSymbol("]") {
+			return values, nil
+		}
+		for {
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			if p.acceptSymbol("]") {
+				return values, nil
+			}
+			if !p.acceptSymbol(",") {
+				return nil, p.expected("',' or ']'")
+			}
+		}
+	}
+
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch tok.kind {
+	case filterTokenString:
+		return tok.text, nil
+	case filterTokenNumber:
+		if i, err := strconv.Atoi(tok.text); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", tok.text, tok.pos)
+		}
+		return f, nil
+	case filterTokenWord:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return nil, fmt.Errorf("expected value at offset %d, got %q", tok.pos, tok.text)
+}
+
+// expected returns an error describing what was expected at the current
+// position.
+func (p *filterParser) expected(what string) error {
+	if p.done() {
+		return fmt.Errorf("expected %s at end of filter expression", what)
+	}
+	tok := p.peek()
+	return fmt.Errorf("expected %s at offset %d, got %q", what, tok.pos, tok.text)
+}
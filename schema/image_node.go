@@ -138,3 +138,72 @@ func (n *ImageNode) GetImageSource() string {
 	}
 	return n.ImageURL
 }
+
+// Metadata keys that hold the image reference when an ImageNode is stored
+// as a plain Node, e.g. in a vector store.
+const (
+	ImageDataMetadataKey     = "image"
+	ImagePathMetadataKey     = "image_path"
+	ImageURLMetadataKey      = "image_url"
+	ImageMimeTypeMetadataKey = "image_mimetype"
+)
+
+var imageMetadataKeys = []string{
+	ImageDataMetadataKey,
+	ImagePathMetadataKey,
+	ImageURLMetadataKey,
+	ImageMimeTypeMetadataKey,
+}
+
+// AsNode returns a copy of the node as a plain Node, with the image
+// reference kept in metadata. The image keys are excluded from embedding
+// and LLM content.
+func (n *ImageNode) AsNode() Node {
+	node := n.Node
+	node.Type = ObjectTypeImage
+	node.Metadata = make(map[string]interface{}, len(n.Metadata)+4)
+	for k, v := range n.Metadata {
+		node.Metadata[k] = v
+	}
+	for key, value := range map[string]string{
+		ImageDataMetadataKey:     n.Image,
+		ImagePathMetadataKey:     n.ImagePath,
+		ImageURLMetadataKey:      n.ImageURL,
+		ImageMimeTypeMetadataKey: n.ImageMimeType,
+	} {
+		if value != "" {
+			node.Metadata[key] = value
+		}
+	}
+	node.ExcludedEmbedMetadataKeys = appendMissing(n.ExcludedEmbedMetadataKeys, imageMetadataKeys)
+	node.ExcludedLLMMetadataKeys = appendMissing(n.ExcludedLLMMetadataKeys, imageMetadataKeys)
+	return node
+}
+
+// ImageNodeFromNode rebuilds an ImageNode from a Node produced by AsNode.
+// It reports false if the node carries no image reference.
+func ImageNodeFromNode(node Node) (*ImageNode, bool) {
+	img := &ImageNode{Node: node}
+	img.Image, _ = node.Metadata[ImageDataMetadataKey].(string)
+	img.ImagePath, _ = node.Metadata[ImagePathMetadataKey].(string)
+	img.ImageURL, _ = node.Metadata[ImageURLMetadataKey].(string)
+	img.ImageMimeType, _ = node.Metadata[ImageMimeTypeMetadataKey].(string)
+	return img, img.HasImage()
+}
+
+func appendMissing(keys, extra []string) []string {
+	out := append([]string(nil), keys...)
+	for _, e := range extra {
+		found := false
+		for _, k := range out {
+			if k == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, e)
+		}
+	}
+	return out
+}
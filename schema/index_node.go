@@ -82,3 +82,33 @@ func (n *IndexNode) SetObject(obj interface{}) {
 func (n *IndexNode) GetObject() interface{} {
 	return n.Obj
 }
+
+// IndexIDMetadataKey is the metadata key holding the referenced index ID
+// when an IndexNode is stored as a plain Node.
+const IndexIDMetadataKey = "index_id"
+
+// AsNode returns a copy of the node as a plain Node, with the index ID kept
+// in metadata so the reference survives storage. The key is excluded from
+// embedding and LLM content.
+func (n *IndexNode) AsNode() Node {
+	node := n.Node
+	node.Type = ObjectTypeIndex
+	node.Metadata = make(map[string]interface{}, len(n.Metadata)+1)
+	for k, v := range n.Metadata {
+		node.Metadata[k] = v
+	}
+	node.Metadata[IndexIDMetadataKey] = n.IndexID
+	node.ExcludedEmbedMetadataKeys = appendMissing(n.ExcludedEmbedMetadataKeys, []string{IndexIDMetadataKey})
+	node.ExcludedLLMMetadataKeys = appendMissing(n.ExcludedLLMMetadataKeys, []string{IndexIDMetadataKey})
+	return node
+}
+
+// IndexNodeFromNode rebuilds an IndexNode from a Node produced by AsNode.
+// It reports false if the node carries no index reference.
+func IndexNodeFromNode(node Node) (*IndexNode, bool) {
+	indexID, _ := node.Metadata[IndexIDMetadataKey].(string)
+	if node.Type != ObjectTypeIndex || indexID == "" {
+		return nil, false
+	}
+	return &IndexNode{Node: node, IndexID: indexID}, true
+}
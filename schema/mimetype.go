@@ -0,0 +1,144 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/binary"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Common MIME types used across readers and transforms.
+const (
+	MimeTypeOctetStream = "application/octet-stream"
+	MimeTypeTextPlain   = "text/plain"
+	MimeTypeMarkdown    = "text/markdown"
+	MimeTypeHTML        = "text/html"
+	MimeTypeJSON        = "application/json"
+	MimeTypeCSV         = "text/csv"
+	MimeTypePDF         = "application/pdf"
+	MimeTypeDocx        = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	MimeTypeXlsx        = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// extensionMimeTypes maps file extensions to MIME types that are not reliably
+// present in the system MIME tables.
+var extensionMimeTypes = map[string]string{
+	".txt":      MimeTypeTextPlain,
+	".md":       MimeTypeMarkdown,
+	".markdown": MimeTypeMarkdown,
+	".html":     MimeTypeHTML,
+	".htm":      MimeTypeHTML,
+	".json":     MimeTypeJSON,
+	".csv":      MimeTypeCSV,
+	".pdf":      MimeTypePDF,
+	".docx":     MimeTypeDocx,
+	".xlsx":     MimeTypeXlsx,
+	".png":      "image/png",
+	".jpg":      "image/jpeg",
+	".jpeg":     "image/jpeg",
+	".gif":      "image/gif",
+	".webp":     "image/webp",
+	".mp3":      "audio/mpeg",
+	".wav":      "audio/wav",
+	".mp4":      "video/mp4",
+}
+
+// DetectMimeType determines the MIME type of content from its bytes and,
+// when the content alone is ambiguous, from the filename extension.
+// Parameters such as charset are stripped from the result.
+func DetectMimeType(filename string, data []byte) string {
+	sniffed := MimeTypeOctetStream
+	if len(data) > 0 {
+		sniffed = baseMimeType(http.DetectContentType(data))
+	}
+
+	// Content sniffing cannot distinguish between text formats or
+	// between zip-based office formats, so prefer the extension then. Text
+	// stays text: an extension that maps to a non-text type, such as .sh
+	// to application/x-shellscript, does not override sniffed text.
+	switch sniffed {
+	case MimeTypeOctetStream, "application/zip":
+		if byExt := mimeTypeFromExtension(filename); byExt != "" {
+			return byExt
+		}
+	case MimeTypeTextPlain:
+		if byExt := mimeTypeFromExtension(filename); IsTextMimeType(byExt) {
+			return byExt
+		}
+	}
+	return sniffed
+}
+
+// IsTextMimeType reports whether the MIME type describes textual content.
+func IsTextMimeType(mimeType string) bool {
+	mimeType = baseMimeType(mimeType)
+	if strings.HasPrefix(mimeType, "text/") {
+		return true
+	}
+	if strings.HasSuffix(mimeType, "+json") || strings.HasSuffix(mimeType, "+xml") {
+		return true
+	}
+	switch mimeType {
+	case MimeTypeJSON, "application/xml", "application/javascript", "application/x-yaml",
+		"application/yaml", "application/toml", "application/x-sh", "application/x-shellscript",
+		"application/sql", "application/x-httpd-php":
+		return true
+	}
+	return false
+}
+
+// mimeTypeFromExtension looks up a MIME type by filename extension.
+func mimeTypeFromExtension(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		return ""
+	}
+	if mt, ok := extensionMimeTypes[ext]; ok {
+		return mt
+	}
+	return baseMimeType(mime.TypeByExtension(ext))
+}
+
+// baseMimeType strips parameters (e.g. "; charset=utf-8") from a MIME type.
+func baseMimeType(mimeType string) string {
+	if idx := strings.Index(mimeType, ";"); idx >= 0 {
+		mimeType = mimeType[:idx]
+	}
+	return strings.TrimSpace(strings.ToLower(mimeType))
+}
+
+// decodeText decodes textual content. UTF-8 (with or without a byte order
+// mark) and UTF-16 with a byte order mark are decoded as such; anything
+// else is decoded as ISO-8859-1 (Latin-1), which maps every byte to a rune.
+func decodeText(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		data = data[3:]
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeUTF16(data[2:], binary.BigEndian)
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeUTF16(data[2:], binary.LittleEndian)
+	}
+	if utf8.Valid(data) {
+		return string(data)
+	}
+
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// decodeUTF16 decodes UTF-16 data with the given byte order.
+func decodeUTF16(data []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
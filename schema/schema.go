@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -323,20 +324,69 @@ func (n *Node) GetText() string {
 }
 
 // Document represents a document.
+// Besides extracted text, a document may carry the original binary content
+// and its MIME type so downstream transforms (OCR, image embedding, audio
+// transcription) can work from the source bytes.
 type Document struct {
 	ID       string                 `json:"id"`
 	Text     string                 `json:"text"`
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	// Data is the original raw content of the document, if retained.
+	Data []byte `json:"data,omitempty"`
+	// MimeType is the MIME type of the original content.
+	MimeType string `json:"mimetype,omitempty"`
+}
+
+// NewDocumentFromData creates a Document from raw content.
+// If mimeType is empty it is sniffed from the data and the filename in the
+// document's "filename" metadata (if any). The content is kept in Data, and
+// textual content is also decoded into Text (see decodeText).
+func NewDocumentFromData(id string, data []byte, mimeType string, metadata map[string]interface{}) *Document {
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+	if mimeType == "" {
+		filename, _ := metadata["filename"].(string)
+		mimeType = DetectMimeType(filename, data)
+	}
+	doc := &Document{
+		ID:       id,
+		Metadata: metadata,
+		Data:     data,
+		MimeType: baseMimeType(mimeType),
+	}
+	if IsTextMimeType(doc.MimeType) {
+		doc.Text = decodeText(data)
+	}
+	return doc
 }
 
-// GetHash returns a hash of the document content.
+// GetHash returns a hash of the document content. Data is only hashed for
+// documents without Text, so text documents hash the same whether or not
+// they carry their raw content.
 func (d *Document) GetHash() string {
 	h := sha256.New()
 	h.Write([]byte(d.ID))
 	h.Write([]byte(d.Text))
+	if d.Text == "" && len(d.Data) > 0 {
+		h.Write(d.Data)
+	}
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// HasData returns true if the document carries raw content.
+func (d *Document) HasData() bool {
+	return len(d.Data) > 0
+}
+
+// IsBinary returns true if the document's content is not textual.
+func (d *Document) IsBinary() bool {
+	if d.MimeType == "" {
+		return false
+	}
+	return !IsTextMimeType(d.MimeType)
+}
+
 // NodeWithScore represents a node with a similarity score.
 type NodeWithScore struct {
 	Node  Node    `json:"node"`
@@ -476,11 +526,34 @@ func (mf *MetadataFilters) AddNested(nested *MetadataFilters) *MetadataFilters {
 	return mf
 }
 
+// CombineMetadataFilters returns the AND of the given filters, skipping
+// nil ones. It returns nil if all are nil, and the filter itself if only
+// one is set.
+func CombineMetadataFilters(filters ...*MetadataFilters) *MetadataFilters {
+	var set []*MetadataFilters
+	for _, f := range filters {
+		if f != nil {
+			set = append(set, f)
+		}
+	}
+	switch len(set) {
+	case 0:
+		return nil
+	case 1:
+		return set[0]
+	default:
+		return &MetadataFilters{Condition: FilterConditionAnd, Nested: set}
+	}
+}
+
 // QueryBundle encapsulates the query string and potential metadata.
 // In the future, this can support image queries or multiple modal queries.
 type QueryBundle struct {
 	QueryString string           `json:"query_string"`
 	Filters     *MetadataFilters `json:"filters,omitempty"`
+	// AsOf, if set, asks for nodes valid at this time (see ValidFromKey
+	// and ValidToKey). It is applied by temporal postprocessors.
+	AsOf *time.Time `json:"as_of,omitempty"`
 	// Image string or []byte could be added here
 }
 
@@ -590,3 +663,17 @@ type VectorStoreQueryResult struct {
 	Similarities []float64  `json:"similarities,omitempty"`
 	IDs          []string   `json:"ids,omitempty"`
 }
+
+// MetadataInfo describes a metadata field that queries can filter on.
+type MetadataInfo struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// VectorStoreInfo describes the contents and filterable metadata of a
+// vector store, e.g. for an LLM that infers filters from a query.
+type VectorStoreInfo struct {
+	ContentInfo  string         `json:"content_info"`
+	MetadataInfo []MetadataInfo `json:"metadata_info"`
+}
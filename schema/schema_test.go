@@ -3,6 +3,7 @@ package schema
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -154,6 +155,31 @@ func TestImageNodeFromBase64(t *testing.T) {
 	assert.Equal(t, "base64data", node.GetImageSource())
 }
 
+func TestImageNodeAsNode(t *testing.T) {
+	img := NewImageNodeFromURL("https://example.com/cat.jpg", "image/jpeg")
+	img.Text = "a cat"
+	img.Metadata["album"] = "pets"
+
+	node := img.AsNode()
+	assert.Equal(t, img.ID, node.ID)
+	assert.Equal(t, ObjectTypeImage, node.Type)
+	assert.Equal(t, "https://example.com/cat.jpg", node.Metadata[ImageURLMetadataKey])
+	assert.Equal(t, "image/jpeg", node.Metadata[ImageMimeTypeMetadataKey])
+	assert.NotContains(t, node.Metadata, ImagePathMetadataKey)
+	assert.NotContains(t, img.Metadata, ImageURLMetadataKey, "original metadata is not modified")
+	assert.NotContains(t, node.GetContent(MetadataModeLLM), "example.com")
+	assert.Contains(t, node.GetContent(MetadataModeLLM), "album: pets")
+
+	back, ok := ImageNodeFromNode(node)
+	require.True(t, ok)
+	assert.Equal(t, "https://example.com/cat.jpg", back.ImageURL)
+	assert.Equal(t, "image/jpeg", back.ImageMimeType)
+	assert.Equal(t, "a cat", back.Text)
+
+	_, ok = ImageNodeFromNode(*NewTextNode("text"))
+	assert.False(t, ok)
+}
+
 func TestIndexNode(t *testing.T) {
 	node := NewIndexNode("index-123")
 	assert.Equal(t, ObjectTypeIndex, node.GetType())
@@ -172,6 +198,28 @@ func TestIndexNodeFromTextNode(t *testing.T) {
 	assert.Equal(t, ObjectTypeIndex, indexNode.GetType())
 }
 
+func TestIndexNodeAsNode(t *testing.T) {
+	idx := NewIndexNode("table-1")
+	idx.Text = "Summary of the revenue table"
+	idx.Metadata["source"] = "report"
+
+	node := idx.AsNode()
+	assert.Equal(t, idx.ID, node.ID)
+	assert.Equal(t, ObjectTypeIndex, node.Type)
+	assert.Equal(t, "table-1", node.Metadata[IndexIDMetadataKey])
+	assert.NotContains(t, idx.Metadata, IndexIDMetadataKey, "original metadata is not modified")
+	assert.NotContains(t, node.GetContent(MetadataModeEmbed), "table-1")
+	assert.Contains(t, node.GetContent(MetadataModeLLM), "source: report")
+
+	back, ok := IndexNodeFromNode(node)
+	require.True(t, ok)
+	assert.Equal(t, "table-1", back.IndexID)
+	assert.Equal(t, idx.Text, back.Text)
+
+	_, ok = IndexNodeFromNode(*NewTextNode("text"))
+	assert.False(t, ok)
+}
+
 func TestMediaResource(t *testing.T) {
 	// Test from text
 	mr := NewMediaResourceFromText("Hello")
@@ -948,6 +996,103 @@ func TestMetadataFiltersNested(t *testing.T) {
 	assert.Equal(t, 1, len(mainFilters.Nested))
 }
 
+func TestCombineMetadataFilters(t *testing.T) {
+	a := NewMetadataFilters(NewMetadataFilter("category", "tech"))
+	b := NewMetadataFiltersWithCondition(FilterConditionOr, NewMetadataFilter("year", 2024))
+
+	assert.Nil(t, CombineMetadataFilters(nil, nil))
+	assert.Same(t, a, CombineMetadataFilters(nil, a))
+
+	combined := CombineMetadataFilters(a, nil, b)
+	assert.Equal(t, FilterConditionAnd, combined.Condition)
+	assert.Equal(t, []*MetadataFilters{a, b}, combined.Nested)
+}
+
+func TestParseMetadataFilters(t *testing.T) {
+	t.Run("single comparison", func(t *testing.T) {
+		filters, err := ParseMetadataFilters(`category == "tech"`)
+		require.NoError(t, err)
+		assert.Equal(t, NewMetadataFilters(NewMetadataFilter("category", "tech")), filters)
+	})
+
+	t.Run("operators", func(t *testing.T) {
+		cases := map[string]MetadataFilter{
+			`year >= 2020`:                    {Key: "year", Operator: FilterOperatorGte, Value: 2020},
+			`price lt 9.5`:                    {Key: "price", Operator: FilterOperatorLt, Value: 9.5},
+			`status ne 'draft'`:               {Key: "status", Operator: FilterOperatorNe, Value: "draft"},
+			`genre in ["a", "b"]`:             {Key: "genre", Operator: FilterOperatorIn, Value: []interface{}{"a", "b"}},
+			`genre NOT IN ["a"]`:              {Key: "genre", Operator: FilterOperatorNin, Value: []interface{}{"a"}},
+			`tags contains "go"`:              {Key: "tags", Operator: FilterOperatorContains, Value: "go"},
+			`title text_match "llama"`:        {Key: "title", Operator: FilterOperatorTextMatch, Value: "llama"},
+			`summary is empty`:                {Key: "summary", Operator: FilterOperatorIsEmpty},
+			`published = true`:                {Key: "published", Operator: FilterOperatorEq, Value: true},
+			`"file name" == "a b.txt"`:        {Key: "file name", Operator: FilterOperatorEq, Value: "a b.txt"},
+			`doc.section_id eq -3`:            {Key: "doc.section_id", Operator: FilterOperatorEq, Value: -3},
+			`quote == "say \"hi\""`:           {Key: "quote", Operator: FilterOperatorEq, Value: `say "hi"`},
+			`tags any ["x", 1, false]`:        {Key: "tags", Operator: FilterOperatorAny, Value: []interface{}{"x", 1, false}},
+			`name text_match_insensitive "A"`: {Key: "name", Operator: FilterOperatorTextMatchInsensitive, Value: "A"},
+		}
+		for expr, want := range cases {
+			filters, err := ParseMetadataFilters(expr)
+			require.NoError(t, err, expr)
+			require.Len(t, filters.Filters, 1, expr)
+			assert.Equal(t, want, filters.Filters[0], expr)
+		}
+	})
+
+	t.Run("nesting and precedence", func(t *testing.T) {
+		filters, err := ParseMetadataFilters(`year >= 2020 and (genre == "sci-fi" or author == "Le Guin") and not draft == true`)
+		require.NoError(t, err)
+
+		assert.Equal(t, FilterConditionAnd, filters.Condition)
+		assert.Equal(t, []MetadataFilter{{Key: "year", Operator: FilterOperatorGte, Value: 2020}}, filters.Filters)
+		require.Len(t, filters.Nested, 2)
+		assert.Equal(t, FilterConditionOr, filters.Nested[0].Condition)
+		assert.Len(t, filters.Nested[0].Filters, 2)
+		assert.Equal(t, FilterConditionNot, filters.Nested[1].Condition)
+
+		filters, err = ParseMetadataFilters(`a == 1 OR b == 2 AND c == 3`)
+		require.NoError(t, err)
+		assert.Equal(t, FilterConditionOr, filters.Condition)
+		assert.Len(t, filters.Filters, 1)
+		require.Len(t, filters.Nested, 1)
+		assert.Equal(t, FilterConditionAnd, filters.Nested[0].Condition)
+	})
+
+	t.Run("round trips through String", func(t *testing.T) {
+		for _, expr := range []string{
+			`year >= 2020 AND (genre in ["sci-fi", "fantasy"] OR author == "Le Guin")`,
+			`NOT (status == "draft" AND (summary is empty))`,
+			`"and" == 1 OR score < 0.5`,
+		} {
+			filters, err := ParseMetadataFilters(expr)
+			require.NoError(t, err, expr)
+			again, err := ParseMetadataFilters(filters.String())
+			require.NoError(t, err, filters.String())
+			assert.Equal(t, filters, again, expr)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, expr := range []string{
+			``,
+			`year >=`,
+			`year 2020`,
+			`year between 1`,
+			`(a == 1`,
+			`a == 1 b == 2`,
+			`a == "unterminated`,
+			`a in [1, 2`,
+			`a is full`,
+			`a ! 1`,
+		} {
+			_, err := ParseMetadataFilters(expr)
+			assert.Error(t, err, expr)
+		}
+		assert.Panics(t, func() { MustParseMetadataFilters(`a ==`) })
+	})
+}
+
 // Tests for VectorStoreQuery
 func TestNewVectorStoreQuery(t *testing.T) {
 	embedding := []float64{0.1, 0.2, 0.3}
@@ -1074,3 +1219,148 @@ func TestMetadataFiltersJSONRoundTrip(t *testing.T) {
 	assert.Equal(t, filters.Condition, restored.Condition)
 	assert.Equal(t, len(filters.Nested), len(restored.Nested))
 }
+
+func TestDetectMimeType(t *testing.T) {
+	pngData := []byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A, 0x00, 0x00, 0x00, 0x0D}
+
+	assert.Equal(t, "image/png", DetectMimeType("photo.bin", pngData))
+	assert.Equal(t, MimeTypePDF, DetectMimeType("", []byte("%PDF-1.7\n")))
+	assert.Equal(t, MimeTypeMarkdown, DetectMimeType("README.md", []byte("# Title")))
+	assert.Equal(t, MimeTypeTextPlain, DetectMimeType("", []byte("plain text")))
+	assert.Equal(t, MimeTypeDocx, DetectMimeType("report.docx", []byte("PK\x03\x04rest")))
+	assert.Equal(t, MimeTypeOctetStream, DetectMimeType("", nil))
+
+	// Sniffed text is not overridden by extensions that map to non-text
+	// types.
+	assert.True(t, IsTextMimeType(DetectMimeType("run.sh", []byte("#!/bin/sh\necho hi\n"))))
+	assert.True(t, IsTextMimeType(DetectMimeType("config.toml", []byte("[server]\nport = 8080\n"))))
+	assert.Equal(t, MimeTypeTextPlain, DetectMimeType("notes.txt", []byte("caf\xe9")))
+}
+
+func TestIsTextMimeType(t *testing.T) {
+	assert.True(t, IsTextMimeType("text/plain; charset=utf-8"))
+	assert.True(t, IsTextMimeType(MimeTypeJSON))
+	assert.False(t, IsTextMimeType("image/png"))
+	assert.False(t, IsTextMimeType(MimeTypePDF))
+}
+
+func TestNewDocumentFromData(t *testing.T) {
+	t.Run("text content", func(t *testing.T) {
+		doc := NewDocumentFromData("doc1", []byte("hello"), "", map[string]interface{}{"filename": "a.md"})
+		assert.Equal(t, MimeTypeMarkdown, doc.MimeType)
+		assert.Equal(t, "hello", doc.Text)
+		assert.Equal(t, []byte("hello"), doc.Data)
+		assert.False(t, doc.IsBinary())
+	})
+
+	t.Run("non-UTF-8 text", func(t *testing.T) {
+		doc := NewDocumentFromData("latin1", []byte("caf\xe9 cr\xe8me"), "", map[string]interface{}{"filename": "menu.txt"})
+		assert.Equal(t, "café crème", doc.Text)
+
+		utf16 := []byte{0xFF, 0xFE, 'h', 0, 'i', 0}
+		doc = NewDocumentFromData("utf16", utf16, "", nil)
+		assert.Equal(t, "hi", doc.Text)
+
+		doc = NewDocumentFromData("bom", []byte("\xEF\xBB\xBFhi"), "", nil)
+		assert.Equal(t, "hi", doc.Text)
+	})
+
+	t.Run("binary content", func(t *testing.T) {
+		data := []byte("%PDF-1.4\x00\x01\x02")
+		doc := NewDocumentFromData("doc2", data, "", nil)
+		assert.Equal(t, MimeTypePDF, doc.MimeType)
+		assert.Empty(t, doc.Text)
+		assert.Equal(t, data, doc.Data)
+		assert.True(t, doc.IsBinary())
+		assert.NotNil(t, doc.Metadata)
+	})
+
+	t.Run("hash includes data", func(t *testing.T) {
+		a := NewDocumentFromData("doc", []byte{0x01}, "application/octet-stream", nil)
+		b := NewDocumentFromData("doc", []byte{0x02}, "application/octet-stream", nil)
+		assert.NotEqual(t, a.GetHash(), b.GetHash())
+
+		// Text documents hash as before, whether or not they carry data.
+		plain := &Document{ID: "doc", Text: "text"}
+		assert.Equal(t, plain.GetHash(), (&Document{ID: "doc", Text: "text", Data: []byte("text")}).GetHash())
+		assert.Equal(t, plain.GetHash(), NewDocumentFromData("doc", []byte("text"), "", nil).GetHash())
+	})
+
+	t.Run("json round trip", func(t *testing.T) {
+		doc := NewDocumentFromData("doc3", []byte{0xff, 0x00}, "image/jpeg", nil)
+		data, err := json.Marshal(doc)
+		require.NoError(t, err)
+
+		var decoded Document
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, doc.Data, decoded.Data)
+		assert.Equal(t, "image/jpeg", decoded.MimeType)
+	})
+}
+
+func TestValidTime(t *testing.T) {
+	from := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	metadata := map[string]interface{}{}
+	SetValidTime(metadata, from, to)
+	assert.Equal(t, "2021-01-01T00:00:00Z", metadata[ValidFromKey])
+
+	gotFrom, hasFrom, gotTo, hasTo := GetValidTime(metadata)
+	assert.True(t, hasFrom)
+	assert.True(t, hasTo)
+	assert.True(t, from.Equal(gotFrom))
+	assert.True(t, to.Equal(gotTo))
+
+	assert.False(t, IsValidAt(metadata, from.Add(-time.Second)))
+	assert.True(t, IsValidAt(metadata, from))
+	assert.False(t, IsValidAt(metadata, to))
+
+	openEnded := map[string]interface{}{ValidFromKey: int64(from.Unix())}
+	assert.True(t, IsValidAt(openEnded, to.AddDate(10, 0, 0)))
+	assert.True(t, IsValidAt(map[string]interface{}{}, from))
+	assert.True(t, IsValidAt(map[string]interface{}{ValidFromKey: "not a date"}, from))
+}
+
+func TestNewQueryBundle(t *testing.T) {
+	asOf := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := NewQueryBundle("policy", AsOf(asOf))
+	assert.Equal(t, "policy", q.QueryString)
+	require.NotNil(t, q.AsOf)
+	assert.True(t, asOf.Equal(*q.AsOf))
+	assert.Nil(t, q.Filters)
+}
+
+func TestScoreExplanation(t *testing.T) {
+	node := NewTextNode("Restart the pod.")
+	node.Metadata["team"] = "sre"
+	shared := node.Metadata
+
+	n := NodeWithScore{Node: *node, Score: 1.2}
+	assert.Nil(t, n.ScoreExplanation())
+	n.ExplainScore(ScoreComponent{Name: ScoreComponentBM25, Score: 1.2, Details: map[string]float64{"pod": 0.8, "restart": 0.4}})
+	n.Score = 0.6
+	n.ExplainScore(ScoreComponent{Name: ScoreComponentRecency, Score: 0.6, Details: map[string]float64{"weight": 0.5}, Note: "mode=step"})
+
+	assert.NotContains(t, shared, ScoreExplanationMetadataKey, "the original metadata is not modified")
+	assert.Empty(t, node.ExcludedLLMMetadataKeys)
+	assert.NotContains(t, n.Node.GetContent(MetadataModeLLM), "bm25")
+	assert.NotContains(t, n.Node.GetContent(MetadataModeEmbed), "bm25")
+	assert.Contains(t, n.Node.GetContent(MetadataModeLLM), "team: sre")
+
+	explanation := n.ScoreExplanation()
+	require.Len(t, explanation, 2)
+	assert.Equal(t, ScoreComponentBM25, explanation[0].Name)
+	assert.Equal(t, 0.5, explanation[1].Details["weight"])
+	assert.Equal(t, "bm25=1.200 (pod=0.800, restart=0.400) -> recency=0.600 (weight=0.500, mode=step)", FormatScoreExplanation(n))
+
+	// Explanations survive a JSON round trip.
+	data, err := json.Marshal(n)
+	require.NoError(t, err)
+	var decoded NodeWithScore
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, explanation, decoded.ScoreExplanation())
+	decoded.ExplainScore(ScoreComponent{Name: ScoreComponentRerank, Score: 0.9})
+	assert.Len(t, decoded.ScoreExplanation(), 3)
+	assert.Equal(t, []string{ScoreExplanationMetadataKey}, decoded.Node.ExcludedLLMMetadataKeys)
+}
@@ -0,0 +1,111 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ScoreExplanationMetadataKey is the node metadata key under which
+// retrievers and postprocessors record how a node's score was computed.
+// The key is excluded from LLM and embedding metadata.
+const ScoreExplanationMetadataKey = "score_explanation"
+
+// Score component names used by the built-in retrievers and postprocessors.
+const (
+	// ScoreComponentBM25 is a BM25 score with per-term contributions.
+	ScoreComponentBM25 = "bm25"
+	// ScoreComponentVectorSimilarity is a vector store similarity.
+	ScoreComponentVectorSimilarity = "vector_similarity"
+	// ScoreComponentRecency is a recency adjustment.
+	ScoreComponentRecency = "recency"
+	// ScoreComponentRerank is a reranker score.
+	ScoreComponentRerank = "rerank"
+)
+
+// ScoreComponent is one stage of a node's scoring, in the order the stages
+// ran.
+type ScoreComponent struct {
+	// Name identifies the stage, e.g. ScoreComponentBM25.
+	Name string `json:"name"`
+	// Score is the node's score after the stage.
+	Score float64 `json:"score"`
+	// Details holds stage-specific values, such as per-term contributions
+	// or the weight applied to the previous score.
+	Details map[string]float64 `json:"details,omitempty"`
+	// Note describes the stage in words, e.g. the model or mode used.
+	Note string `json:"note,omitempty"`
+}
+
+// ExplainScore appends a component to the node's score explanation. The
+// metadata map and excluded key lists are copied first, so nodes shared
+// with an index or other results are not modified.
+func (n *NodeWithScore) ExplainScore(component ScoreComponent) {
+	explanation := append(n.ScoreExplanation(), component)
+
+	metadata := make(map[string]interface{}, len(n.Node.Metadata)+1)
+	for k, v := range n.Node.Metadata {
+		metadata[k] = v
+	}
+	metadata[ScoreExplanationMetadataKey] = explanation
+	n.Node.Metadata = metadata
+	n.Node.ExcludedLLMMetadataKeys = appendMissing(n.Node.ExcludedLLMMetadataKeys, []string{ScoreExplanationMetadataKey})
+	n.Node.ExcludedEmbedMetadataKeys = appendMissing(n.Node.ExcludedEmbedMetadataKeys, []string{ScoreExplanationMetadataKey})
+}
+
+// ScoreExplanation returns the node's score explanation, or nil if none
+// was recorded. Explanations that went through JSON are decoded.
+func (n NodeWithScore) ScoreExplanation() []ScoreComponent {
+	switch v := n.Node.Metadata[ScoreExplanationMetadataKey].(type) {
+	case nil:
+		return nil
+	case []ScoreComponent:
+		return append([]ScoreComponent(nil), v...)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		var components []ScoreComponent
+		if json.Unmarshal(data, &components) != nil {
+			return nil
+		}
+		return components
+	}
+}
+
+// FormatScoreExplanation renders a node's score explanation on one line,
+// e.g. "bm25=1.204 (pod=0.811, restart=0.393) -> recency=0.963 (weight=0.8)".
+// Details are ordered by decreasing value.
+func FormatScoreExplanation(n NodeWithScore) string {
+	components := n.ScoreExplanation()
+	parts := make([]string, len(components))
+	for i, c := range components {
+		part := fmt.Sprintf("%s=%.3f", c.Name, c.Score)
+		var extras []string
+		if len(c.Details) > 0 {
+			keys := make([]string, 0, len(c.Details))
+			for k := range c.Details {
+				keys = append(keys, k)
+			}
+			sort.Slice(keys, func(a, b int) bool {
+				if c.Details[keys[a]] != c.Details[keys[b]] {
+					return c.Details[keys[a]] > c.Details[keys[b]]
+				}
+				return keys[a] < keys[b]
+			})
+			for _, k := range keys {
+				extras = append(extras, fmt.Sprintf("%s=%.3f", k, c.Details[k]))
+			}
+		}
+		if c.Note != "" {
+			extras = append(extras, c.Note)
+		}
+		if len(extras) > 0 {
+			part += " (" + strings.Join(extras, ", ") + ")"
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, " -> ")
+}
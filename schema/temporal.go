@@ -0,0 +1,110 @@
+package schema
+
+import (
+	"time"
+)
+
+// Metadata keys describing the period during which a document or node is valid.
+const (
+	// ValidFromKey is the metadata key for the start of the validity period.
+	ValidFromKey = "valid_from"
+	// ValidToKey is the metadata key for the end of the validity period
+	// (exclusive). A missing value means the node is still valid.
+	ValidToKey = "valid_to"
+)
+
+// validTimeFormats are the layouts accepted for string valid-time values.
+var validTimeFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// SetValidTime records a validity period in metadata as RFC 3339 strings.
+// A zero from or to leaves that bound open.
+func SetValidTime(metadata map[string]interface{}, from, to time.Time) {
+	if metadata == nil {
+		return
+	}
+	if !from.IsZero() {
+		metadata[ValidFromKey] = from.UTC().Format(time.RFC3339)
+	}
+	if !to.IsZero() {
+		metadata[ValidToKey] = to.UTC().Format(time.RFC3339)
+	}
+}
+
+// GetValidTime returns the validity period recorded in metadata. Values may
+// be time.Time, RFC 3339 or ISO date strings, or Unix seconds. The returned
+// bools report whether each bound is set.
+func GetValidTime(metadata map[string]interface{}) (from time.Time, hasFrom bool, to time.Time, hasTo bool) {
+	from, hasFrom = parseValidTime(metadata[ValidFromKey])
+	to, hasTo = parseValidTime(metadata[ValidToKey])
+	return from, hasFrom, to, hasTo
+}
+
+// IsValidAt reports whether metadata describes something valid at t.
+// Metadata without valid-time information is considered always valid.
+func IsValidAt(metadata map[string]interface{}, t time.Time) bool {
+	from, hasFrom, to, hasTo := GetValidTime(metadata)
+	if hasFrom && t.Before(from) {
+		return false
+	}
+	if hasTo && !t.Before(to) {
+		return false
+	}
+	return true
+}
+
+// parseValidTime converts a metadata value to a time.
+func parseValidTime(v interface{}) (time.Time, bool) {
+	switch val := v.(type) {
+	case time.Time:
+		return val, !val.IsZero()
+	case *time.Time:
+		if val == nil {
+			return time.Time{}, false
+		}
+		return *val, !val.IsZero()
+	case string:
+		for _, layout := range validTimeFormats {
+			if t, err := time.Parse(layout, val); err == nil {
+				return t, true
+			}
+		}
+	case int64:
+		return time.Unix(val, 0), true
+	case int:
+		return time.Unix(int64(val), 0), true
+	case float64:
+		return time.Unix(int64(val), 0), true
+	}
+	return time.Time{}, false
+}
+
+// QueryBundleOption configures a QueryBundle.
+type QueryBundleOption func(*QueryBundle)
+
+// AsOf restricts a query to nodes valid at the given time.
+func AsOf(t time.Time) QueryBundleOption {
+	return func(q *QueryBundle) {
+		q.AsOf = &t
+	}
+}
+
+// WithQueryFilters sets metadata filters on a query.
+func WithQueryFilters(filters *MetadataFilters) QueryBundleOption {
+	return func(q *QueryBundle) {
+		q.Filters = filters
+	}
+}
+
+// NewQueryBundle creates a QueryBundle for the query string.
+func NewQueryBundle(query string, opts ...QueryBundleOption) QueryBundle {
+	q := QueryBundle{QueryString: query}
+	for _, opt := range opts {
+		opt(&q)
+	}
+	return q
+}
@@ -0,0 +1,140 @@
+package selector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/aqua777/go-llamaindex/embedding"
+)
+
+// EmbeddingSelector selects by the cosine similarity between the query and
+// each choice's description. It needs no LLM call, so it is a cheap default
+// when descriptions are distinctive. Description embeddings are cached.
+type EmbeddingSelector struct {
+	*BaseSelector
+	// EmbeddingModel embeds queries and descriptions.
+	EmbeddingModel embedding.EmbeddingModel
+	// TopK is the maximum number of choices selected.
+	TopK int
+	// Threshold is the minimum similarity for a choice to be selected. The
+	// best match is always selected, even below the threshold.
+	Threshold float64
+
+	mu    sync.Mutex
+	cache map[string][]float64
+}
+
+// EmbeddingSelectorOption configures an EmbeddingSelector.
+type EmbeddingSelectorOption func(*EmbeddingSelector)
+
+// WithEmbeddingSelectorTopK sets the maximum number of choices selected.
+// Defaults to 1.
+func WithEmbeddingSelectorTopK(topK int) EmbeddingSelectorOption {
+	return func(s *EmbeddingSelector) {
+		s.TopK = topK
+	}
+}
+
+// WithEmbeddingSelectorThreshold sets the minimum similarity for selection.
+func WithEmbeddingSelectorThreshold(threshold float64) EmbeddingSelectorOption {
+	return func(s *EmbeddingSelector) {
+		s.Threshold = threshold
+	}
+}
+
+// NewEmbeddingSelector creates a new EmbeddingSelector.
+func NewEmbeddingSelector(embeddingModel embedding.EmbeddingModel, opts ...EmbeddingSelectorOption) *EmbeddingSelector {
+	s := &EmbeddingSelector{
+		BaseSelector:   NewBaseSelector(WithSelectorName("EmbeddingSelector")),
+		EmbeddingModel: embeddingModel,
+		TopK:           1,
+		cache:          make(map[string][]float64),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Select picks the choices whose descriptions are most similar to the
+// query, most similar first.
+func (s *EmbeddingSelector) Select(ctx context.Context, choices []ToolMetadata, query string) (*SelectorResult, error) {
+	if len(choices) == 0 {
+		return &SelectorResult{Selections: []SingleSelection{}}, nil
+	}
+
+	queryEmbedding, err := s.EmbeddingModel.GetQueryEmbedding(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get query embedding: %w", err)
+	}
+
+	type scored struct {
+		index int
+		score float64
+	}
+	scores := make([]scored, len(choices))
+	for i, choice := range choices {
+		descEmbedding, err := s.descriptionEmbedding(ctx, choice)
+		if err != nil {
+			return nil, err
+		}
+		sim, err := embedding.CosineSimilarity(queryEmbedding, descEmbedding)
+		if err != nil {
+			return nil, fmt.Errorf("failed to score choice %s: %w", choice.Name, err)
+		}
+		scores[i] = scored{index: i, score: sim}
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].score > scores[j].score
+	})
+
+	topK := s.TopK
+	if topK <= 0 {
+		topK = 1
+	}
+
+	result := &SelectorResult{Selections: []SingleSelection{}}
+	for i, sc := range scores {
+		if i >= topK || (i > 0 && sc.score < s.Threshold) {
+			break
+		}
+		result.Selections = append(result.Selections, SingleSelection{
+			Index:  sc.index,
+			Reason: fmt.Sprintf("description similarity %.3f", sc.score),
+		})
+	}
+	return result, nil
+}
+
+// descriptionEmbedding returns the cached embedding of the choice's
+// description, or of its name if it has none.
+func (s *EmbeddingSelector) descriptionEmbedding(ctx context.Context, choice ToolMetadata) ([]float64, error) {
+	text := choice.Description
+	if text == "" {
+		text = choice.Name
+	}
+
+	s.mu.Lock()
+	cached, ok := s.cache[text]
+	s.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	emb, err := s.EmbeddingModel.GetTextEmbedding(ctx, text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed description of %s: %w", choice.Name, err)
+	}
+
+	s.mu.Lock()
+	s.cache[text] = emb
+	s.mu.Unlock()
+	return emb, nil
+}
+
+// Ensure EmbeddingSelector implements Selector.
+var _ Selector = (*EmbeddingSelector)(nil)
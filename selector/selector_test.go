@@ -2,6 +2,7 @@ package selector
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/aqua777/go-llamaindex/llm"
@@ -100,6 +101,19 @@ func TestSelectorResult(t *testing.T) {
 		assert.Equal(t, []int{0, 2}, inds)
 	})
 
+	t.Run("Valid drops out of range and repeated indices", func(t *testing.T) {
+		result := &SelectorResult{
+			Selections: []SingleSelection{
+				{Index: 1, Reason: "a"},
+				{Index: 5, Reason: "b"},
+				{Index: -1, Reason: "c"},
+				{Index: 0, Reason: "d"},
+				{Index: 1, Reason: "e"},
+			},
+		}
+		assert.Equal(t, []SingleSelection{{Index: 1, Reason: "a"}, {Index: 0, Reason: "d"}}, result.Valid(3))
+	})
+
 	t.Run("Reasons returns all reasons", func(t *testing.T) {
 		result := &SelectorResult{
 			Selections: []SingleSelection{
@@ -264,9 +278,73 @@ func TestLLMMultiSelector(t *testing.T) {
 	})
 }
 
+// keywordEmbedding embeds text as counts of keywords, and counts the
+// description embeddings it computes.
+type keywordEmbedding struct {
+	words     []string
+	textCalls int
+}
+
+func (m *keywordEmbedding) embed(text string) []float64 {
+	v := make([]float64, len(m.words))
+	for i, w := range m.words {
+		v[i] = float64(strings.Count(strings.ToLower(text), w))
+	}
+	return v
+}
+
+func (m *keywordEmbedding) GetTextEmbedding(ctx context.Context, text string) ([]float64, error) {
+	m.textCalls++
+	return m.embed(text), nil
+}
+
+func (m *keywordEmbedding) GetQueryEmbedding(ctx context.Context, query string) ([]float64, error) {
+	return m.embed(query), nil
+}
+
+// TestEmbeddingSelector tests the EmbeddingSelector.
+func TestEmbeddingSelector(t *testing.T) {
+	ctx := context.Background()
+	choices := []ToolMetadata{
+		{Name: "solar", Description: "solar panel energy"},
+		{Name: "wind", Description: "wind turbine energy"},
+		{Name: "storage", Description: "energy storage"},
+	}
+	model := &keywordEmbedding{words: []string{"solar", "panel", "wind", "turbine", "energy"}}
+
+	t.Run("selects the most similar choice", func(t *testing.T) {
+		s := NewEmbeddingSelector(model)
+		assert.Equal(t, "EmbeddingSelector", s.Name())
+
+		result, err := s.Select(ctx, choices, "wind turbine")
+		require.NoError(t, err)
+		assert.Equal(t, []int{1}, result.Inds())
+		assert.Contains(t, result.Reasons()[0], "similarity")
+
+		calls := model.textCalls
+		_, err = s.Select(ctx, choices, "solar")
+		require.NoError(t, err)
+		assert.Equal(t, calls, model.textCalls, "description embeddings are cached")
+	})
+
+	t.Run("top k above the threshold", func(t *testing.T) {
+		s := NewEmbeddingSelector(model, WithEmbeddingSelectorTopK(3), WithEmbeddingSelectorThreshold(0.5))
+		result, err := s.Select(ctx, choices, "solar energy")
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, 2}, result.Inds())
+	})
+
+	t.Run("no choices", func(t *testing.T) {
+		result, err := NewEmbeddingSelector(model).Select(ctx, nil, "q")
+		require.NoError(t, err)
+		assert.Empty(t, result.Selections)
+	})
+}
+
 // TestInterfaceCompliance tests that all selectors implement Selector.
 func TestInterfaceCompliance(t *testing.T) {
 	var _ Selector = (*BaseSelector)(nil)
 	var _ Selector = (*LLMSingleSelector)(nil)
 	var _ Selector = (*LLMMultiSelector)(nil)
+	var _ Selector = (*EmbeddingSelector)(nil)
 }
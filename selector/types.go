@@ -57,6 +57,22 @@ func (r *SelectorResult) Reasons() []string {
 	return reasons
 }
 
+// Valid returns the selections whose index is within n choices, dropping
+// repeated indices. Selectors such as LLMSingleSelector return whatever
+// the LLM answered, which may be out of range.
+func (r *SelectorResult) Valid(n int) []SingleSelection {
+	var valid []SingleSelection
+	seen := make(map[int]bool)
+	for _, sel := range r.Selections {
+		if sel.Index < 0 || sel.Index >= n || seen[sel.Index] {
+			continue
+		}
+		seen[sel.Index] = true
+		valid = append(valid, sel)
+	}
+	return valid
+}
+
 // Selector is the interface for query routing selectors.
 type Selector interface {
 	// Select chooses from the given choices based on the query.
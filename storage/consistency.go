@@ -0,0 +1,382 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aqua777/go-llamaindex/rag/store"
+	"github.com/aqua777/go-llamaindex/storage/indexstore"
+)
+
+// ConsistencyIssueType identifies a kind of inconsistency between stores.
+type ConsistencyIssueType string
+
+const (
+	// IssueOrphanVector is a vector store entry referenced by neither an
+	// index struct nor the document store.
+	IssueOrphanVector ConsistencyIssueType = "orphan_vector"
+	// IssueMissingVector is a node referenced by a vector store index whose
+	// vector is missing from the vector store.
+	IssueMissingVector ConsistencyIssueType = "missing_vector"
+	// IssueDanglingIndexRef is a node referenced by a non-vector index
+	// struct that is missing from the document store.
+	IssueDanglingIndexRef ConsistencyIssueType = "dangling_index_ref"
+	// IssueDanglingRefDoc is a node listed in ref doc info that is missing
+	// from the document store.
+	IssueDanglingRefDoc ConsistencyIssueType = "dangling_ref_doc"
+	// IssueHashMismatch is a stored document whose recorded hash does not
+	// match its content.
+	IssueHashMismatch ConsistencyIssueType = "hash_mismatch"
+)
+
+// ConsistencyIssue describes a single inconsistency.
+type ConsistencyIssue struct {
+	// Type is the kind of issue.
+	Type ConsistencyIssueType `json:"type"`
+	// NodeID is the affected node or document.
+	NodeID string `json:"node_id"`
+	// IndexID is the affected index struct, if any.
+	IndexID string `json:"index_id,omitempty"`
+	// RefDocID is the affected reference document, if any.
+	RefDocID string `json:"ref_doc_id,omitempty"`
+	// Detail is a human-readable description.
+	Detail string `json:"detail"`
+}
+
+// ConsistencyReport is the result of a consistency check.
+type ConsistencyReport struct {
+	// Issues lists all inconsistencies found, grouped by type.
+	Issues []ConsistencyIssue `json:"issues"`
+	// VectorsChecked is false when the vector store could not be enumerated,
+	// in which case orphan and missing vector checks were skipped.
+	VectorsChecked bool `json:"vectors_checked"`
+}
+
+// HasIssues reports whether any inconsistency was found.
+func (r *ConsistencyReport) HasIssues() bool {
+	return len(r.Issues) > 0
+}
+
+// Count returns the number of issues of the given type.
+func (r *ConsistencyReport) Count(issueType ConsistencyIssueType) int {
+	count := 0
+	for _, issue := range r.Issues {
+		if issue.Type == issueType {
+			count++
+		}
+	}
+	return count
+}
+
+// RepairResult summarizes a repair run.
+type RepairResult struct {
+	// Repaired lists the issues that were fixed.
+	Repaired []ConsistencyIssue `json:"repaired"`
+	// Skipped lists the issues that cannot be fixed automatically.
+	Skipped []ConsistencyIssue `json:"skipped"`
+}
+
+// ConsistencyChecker cross-checks the document store, index store and a
+// vector store of a StorageContext, e.g. after a crashed ingestion run.
+type ConsistencyChecker struct {
+	storageContext *StorageContext
+	namespace      string
+}
+
+// ConsistencyCheckerOption configures a ConsistencyChecker.
+type ConsistencyCheckerOption func(*ConsistencyChecker)
+
+// WithCheckVectorStoreNamespace sets the vector store namespace to check.
+func WithCheckVectorStoreNamespace(namespace string) ConsistencyCheckerOption {
+	return func(c *ConsistencyChecker) {
+		c.namespace = namespace
+	}
+}
+
+// NewConsistencyChecker creates a new ConsistencyChecker.
+func NewConsistencyChecker(sc *StorageContext, opts ...ConsistencyCheckerOption) *ConsistencyChecker {
+	c := &ConsistencyChecker{
+		storageContext: sc,
+		namespace:      DefaultVectorStore,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Check inspects the stores and reports inconsistencies. Orphan and missing
+// vector checks require the vector store to implement store.NodeLister.
+func (c *ConsistencyChecker) Check(ctx context.Context) (*ConsistencyReport, error) {
+	sc := c.storageContext
+	report := &ConsistencyReport{}
+
+	docs, err := sc.DocStore.Docs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	indexStructs, err := sc.IndexStore.IndexStructs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list index structs: %w", err)
+	}
+
+	// Hash mismatches.
+	for _, id := range sortedKeys(docs) {
+		recorded, err := sc.DocStore.GetDocumentHash(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hash for %s: %w", id, err)
+		}
+		if current := docs[id].GenerateHash(); recorded != "" && recorded != current {
+			report.Issues = append(report.Issues, ConsistencyIssue{
+				Type:   IssueHashMismatch,
+				NodeID: id,
+				Detail: fmt.Sprintf("recorded hash %s does not match content hash %s", shortHash(recorded), shortHash(current)),
+			})
+		}
+	}
+
+	// Ref doc info pointing at missing nodes.
+	refDocs, err := sc.DocStore.GetAllRefDocInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ref doc info: %w", err)
+	}
+	for _, refDocID := range sortedKeys(refDocs) {
+		for _, nodeID := range refDocs[refDocID].NodeIDs {
+			if _, ok := docs[nodeID]; !ok {
+				report.Issues = append(report.Issues, ConsistencyIssue{
+					Type:     IssueDanglingRefDoc,
+					NodeID:   nodeID,
+					RefDocID: refDocID,
+					Detail:   fmt.Sprintf("ref doc %s lists node %s, which is not in the docstore", refDocID, nodeID),
+				})
+			}
+		}
+	}
+
+	// Non-vector index structs pointing at missing nodes.
+	vectorRefs := make(map[string]string) // vector store ID -> index ID
+	for _, is := range indexStructs {
+		if is.Type == indexstore.IndexStructTypeVectorStore {
+			for textID := range is.NodesDict {
+				vectorRefs[textID] = is.IndexID
+			}
+			continue
+		}
+		for _, nodeID := range indexNodeIDs(is) {
+			if _, ok := docs[nodeID]; !ok {
+				report.Issues = append(report.Issues, ConsistencyIssue{
+					Type:    IssueDanglingIndexRef,
+					NodeID:  nodeID,
+					IndexID: is.IndexID,
+					Detail:  fmt.Sprintf("index %s references node %s, which is not in the docstore", is.IndexID, nodeID),
+				})
+			}
+		}
+	}
+
+	// Vector store checks.
+	lister, ok := sc.VectorStores[c.namespace].(store.NodeLister)
+	if !ok {
+		return report, nil
+	}
+	report.VectorsChecked = true
+
+	vectors, err := lister.ListNodes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vectors: %w", err)
+	}
+	inVectorStore := make(map[string]bool, len(vectors))
+	for _, v := range vectors {
+		inVectorStore[v.ID] = true
+		_, referenced := vectorRefs[v.ID]
+		_, inDocstore := docs[v.ID]
+		if !referenced && !inDocstore {
+			report.Issues = append(report.Issues, ConsistencyIssue{
+				Type:   IssueOrphanVector,
+				NodeID: v.ID,
+				Detail: fmt.Sprintf("vector %s is not referenced by any index or document", v.ID),
+			})
+		}
+	}
+	for _, textID := range sortedKeys(vectorRefs) {
+		if !inVectorStore[textID] {
+			report.Issues = append(report.Issues, ConsistencyIssue{
+				Type:    IssueMissingVector,
+				NodeID:  textID,
+				IndexID: vectorRefs[textID],
+				Detail:  fmt.Sprintf("index %s references vector %s, which is not in the vector store", vectorRefs[textID], textID),
+			})
+		}
+	}
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		return report.Issues[i].Type < report.Issues[j].Type
+	})
+	return report, nil
+}
+
+// Repair fixes the issues in a report where it is safe to do so:
+//   - orphan vectors are deleted from the vector store;
+//   - missing vectors and dangling index references are removed from their
+//     index structs (the affected documents must be re-ingested);
+//   - hash mismatches are resolved by recording the current content hash.
+//
+// Dangling ref doc entries are skipped because the docstore offers no way
+// to edit ref doc info short of deleting the whole reference document.
+func (c *ConsistencyChecker) Repair(ctx context.Context, report *ConsistencyReport) (*RepairResult, error) {
+	sc := c.storageContext
+	result := &RepairResult{}
+	touched := make(map[string]*indexstore.IndexStruct)
+
+	getIndex := func(id string) (*indexstore.IndexStruct, error) {
+		if is, ok := touched[id]; ok {
+			return is, nil
+		}
+		is, err := sc.IndexStore.GetIndexStruct(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		touched[id] = is
+		return is, nil
+	}
+
+	for _, issue := range report.Issues {
+		switch issue.Type {
+		case IssueOrphanVector:
+			vs, ok := sc.VectorStores[c.namespace]
+			if !ok {
+				result.Skipped = append(result.Skipped, issue)
+				continue
+			}
+			if err := vs.Delete(ctx, issue.NodeID); err != nil {
+				return result, fmt.Errorf("failed to delete vector %s: %w", issue.NodeID, err)
+			}
+
+		case IssueMissingVector, IssueDanglingIndexRef:
+			is, err := getIndex(issue.IndexID)
+			if err != nil {
+				return result, fmt.Errorf("failed to load index %s: %w", issue.IndexID, err)
+			}
+			removeIndexNode(is, issue.NodeID)
+
+		case IssueHashMismatch:
+			doc, err := sc.DocStore.GetDocument(ctx, issue.NodeID, true)
+			if err != nil {
+				return result, fmt.Errorf("failed to load document %s: %w", issue.NodeID, err)
+			}
+			if err := sc.DocStore.SetDocumentHash(ctx, issue.NodeID, doc.GenerateHash()); err != nil {
+				return result, fmt.Errorf("failed to update hash for %s: %w", issue.NodeID, err)
+			}
+
+		default:
+			result.Skipped = append(result.Skipped, issue)
+			continue
+		}
+		result.Repaired = append(result.Repaired, issue)
+	}
+
+	for _, id := range sortedKeys(touched) {
+		if err := sc.IndexStore.AddIndexStruct(ctx, touched[id]); err != nil {
+			return result, fmt.Errorf("failed to save index %s: %w", id, err)
+		}
+	}
+
+	return result, nil
+}
+
+// indexNodeIDs returns the node IDs referenced by a non-vector index struct.
+func indexNodeIDs(is *indexstore.IndexStruct) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for _, id := range is.Nodes {
+		add(id)
+	}
+	for _, keyword := range sortedKeys(is.Table) {
+		for _, id := range is.Table[keyword] {
+			add(id)
+		}
+	}
+	for _, id := range sortedIntKeys(is.AllNodes) {
+		add(is.AllNodes[id])
+	}
+	return ids
+}
+
+// removeIndexNode removes every reference to nodeID from an index struct.
+func removeIndexNode(is *indexstore.IndexStruct, nodeID string) {
+	delete(is.NodesDict, nodeID)
+	for textID, id := range is.NodesDict {
+		if id == nodeID {
+			delete(is.NodesDict, textID)
+		}
+	}
+
+	is.Nodes = removeString(is.Nodes, nodeID)
+	for keyword, ids := range is.Table {
+		if ids = removeString(ids, nodeID); len(ids) == 0 {
+			delete(is.Table, keyword)
+		} else {
+			is.Table[keyword] = ids
+		}
+	}
+	for idx, id := range is.AllNodes {
+		if id == nodeID {
+			delete(is.AllNodes, idx)
+		}
+	}
+	for idx, id := range is.RootNodes {
+		if id == nodeID {
+			delete(is.RootNodes, idx)
+		}
+	}
+	delete(is.NodeIDToChildrenIDs, nodeID)
+	for parent, children := range is.NodeIDToChildrenIDs {
+		is.NodeIDToChildrenIDs[parent] = removeString(children, nodeID)
+	}
+}
+
+func removeString(values []string, target string) []string {
+	out := values[:0]
+	for _, v := range values {
+		if v != target {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedIntKeys(m map[int]string) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
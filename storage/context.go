@@ -187,7 +187,8 @@ func (sc *StorageContext) Persist(ctx context.Context, persistDir string) error
 	}
 
 	// Note: Vector stores need to be persisted separately as they may have different backends
-	// For SimpleVectorStore, users can call Persist directly on the store
+	// For SimpleVectorStore, users can call Persist directly on the store;
+	// database-backed stores such as pgvector.PGVectorStore persist on write
 
 	return nil
 }
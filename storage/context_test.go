@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/aqua777/go-llamaindex/rag/store"
 	"github.com/aqua777/go-llamaindex/schema"
 	"github.com/aqua777/go-llamaindex/storage/indexstore"
 	"github.com/stretchr/testify/assert"
@@ -188,3 +189,98 @@ func TestStorageContextDefaultPersistDir(t *testing.T) {
 	_, err = os.Stat(DefaultPersistDir)
 	assert.NoError(t, err, "Default persist directory should be created")
 }
+
+func newInconsistentStorageContext(t *testing.T) *StorageContext {
+	t.Helper()
+	ctx := context.Background()
+
+	sc := NewStorageContext()
+	vs := store.NewSimpleVectorStore()
+	sc.SetVectorStore(vs)
+
+	docA := schema.NewTextNode("alpha")
+	docA.ID = "a"
+	docB := schema.NewTextNode("beta")
+	docB.ID = "b"
+	require.NoError(t, sc.DocStore.AddDocuments(ctx, []schema.BaseNode{docA, docB}, true))
+	require.NoError(t, sc.DocStore.SetDocumentHash(ctx, "b", "stale-hash"))
+
+	orphan := schema.NewTextNode("orphan")
+	orphan.ID = "orphan"
+	_, err := vs.Add(ctx, []schema.Node{*docA, *orphan})
+	require.NoError(t, err)
+
+	vectorIndex := indexstore.NewVectorStoreIndex()
+	vectorIndex.IndexID = "vector"
+	vectorIndex.AddNode("a", "")
+	vectorIndex.AddNode("lost", "")
+	require.NoError(t, sc.IndexStore.AddIndexStruct(ctx, vectorIndex))
+
+	listIndex := indexstore.NewListIndex()
+	listIndex.IndexID = "list"
+	listIndex.AddToList("b")
+	listIndex.AddToList("gone")
+	require.NoError(t, sc.IndexStore.AddIndexStruct(ctx, listIndex))
+
+	return sc
+}
+
+func TestConsistencyCheckerCheck(t *testing.T) {
+	ctx := context.Background()
+	sc := newInconsistentStorageContext(t)
+
+	report, err := NewConsistencyChecker(sc).Check(ctx)
+	require.NoError(t, err)
+
+	assert.True(t, report.VectorsChecked)
+	assert.True(t, report.HasIssues())
+	assert.Equal(t, 1, report.Count(IssueOrphanVector))
+	assert.Equal(t, 1, report.Count(IssueMissingVector))
+	assert.Equal(t, 1, report.Count(IssueDanglingIndexRef))
+	assert.Equal(t, 1, report.Count(IssueHashMismatch))
+
+	byType := make(map[ConsistencyIssueType]ConsistencyIssue)
+	for _, issue := range report.Issues {
+		byType[issue.Type] = issue
+	}
+	assert.Equal(t, "orphan", byType[IssueOrphanVector].NodeID)
+	assert.Equal(t, "lost", byType[IssueMissingVector].NodeID)
+	assert.Equal(t, "vector", byType[IssueMissingVector].IndexID)
+	assert.Equal(t, "gone", byType[IssueDanglingIndexRef].NodeID)
+	assert.Equal(t, "list", byType[IssueDanglingIndexRef].IndexID)
+	assert.Equal(t, "b", byType[IssueHashMismatch].NodeID)
+}
+
+func TestConsistencyCheckerRepair(t *testing.T) {
+	ctx := context.Background()
+	sc := newInconsistentStorageContext(t)
+	checker := NewConsistencyChecker(sc)
+
+	report, err := checker.Check(ctx)
+	require.NoError(t, err)
+
+	result, err := checker.Repair(ctx, report)
+	require.NoError(t, err)
+	assert.Len(t, result.Repaired, 4)
+	assert.Empty(t, result.Skipped)
+
+	after, err := checker.Check(ctx)
+	require.NoError(t, err)
+	assert.False(t, after.HasIssues(), "issues remain: %+v", after.Issues)
+
+	listIndex, err := sc.IndexStore.GetIndexStruct(ctx, "list")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, listIndex.Nodes)
+}
+
+func TestConsistencyCheckerWithoutVectorListing(t *testing.T) {
+	ctx := context.Background()
+	sc := newInconsistentStorageContext(t)
+
+	report, err := NewConsistencyChecker(sc, WithCheckVectorStoreNamespace("missing")).Check(ctx)
+	require.NoError(t, err)
+
+	assert.False(t, report.VectorsChecked)
+	assert.Equal(t, 0, report.Count(IssueOrphanVector))
+	assert.Equal(t, 1, report.Count(IssueDanglingIndexRef))
+}
@@ -370,3 +370,91 @@ func TestGetNodes(t *testing.T) {
 		t.Errorf("Expected 1 node, got %d", len(nodes))
 	}
 }
+
+func TestDocumentSummaries(t *testing.T) {
+	ctx := context.Background()
+	store := NewSimpleDocumentStore()
+
+	chunk := createTestNode("chunk1", "Acme pricing starts at $10.")
+	chunk.Relationships.SetSource(schema.RelatedNodeInfo{NodeID: "pricing"})
+	if err := store.AddDocuments(ctx, []schema.BaseNode{chunk}, true); err != nil {
+		t.Fatalf("AddDocuments failed: %v", err)
+	}
+
+	if err := SetDocumentSummary(ctx, store, DocumentSummary{
+		RefDocID: "pricing",
+		Summary:  "Acme's price list.",
+		Keywords: []string{"pricing", "Acme"},
+	}); err != nil {
+		t.Fatalf("SetDocumentSummary failed: %v", err)
+	}
+
+	// Adding more chunks keeps the summary.
+	chunk2 := createTestNode("chunk2", "Discounts apply to annual plans.")
+	chunk2.Relationships.SetSource(schema.RelatedNodeInfo{NodeID: "pricing"})
+	if err := store.AddDocuments(ctx, []schema.BaseNode{chunk2}, true); err != nil {
+		t.Fatalf("AddDocuments failed: %v", err)
+	}
+
+	summary, err := GetDocumentSummary(ctx, store, "pricing")
+	if err != nil {
+		t.Fatalf("GetDocumentSummary failed: %v", err)
+	}
+	if summary == nil || summary.Summary != "Acme's price list." || len(summary.Keywords) != 2 || summary.Keywords[1] != "Acme" {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	info, _ := store.GetRefDocInfo(ctx, "pricing")
+	if len(info.NodeIDs) != 2 {
+		t.Errorf("Expected 2 node IDs, got %v", info.NodeIDs)
+	}
+
+	if s, _ := GetDocumentSummary(ctx, store, "missing"); s != nil {
+		t.Errorf("Expected no summary, got %+v", s)
+	}
+
+	// Summaries survive persistence.
+	path := filepath.Join(t.TempDir(), "docstore.json")
+	if err := store.Persist(ctx, path); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+	loaded, err := FromPersistPath(ctx, path)
+	if err != nil {
+		t.Fatalf("FromPersistPath failed: %v", err)
+	}
+	summaries, err := ListDocumentSummaries(ctx, loaded)
+	if err != nil {
+		t.Fatalf("ListDocumentSummaries failed: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].Keywords[0] != "pricing" {
+		t.Errorf("unexpected summaries: %+v", summaries)
+	}
+
+	// Previews group results by document.
+	other := createTestNode("chunk3", "Unrelated.")
+	other.Relationships.SetSource(schema.RelatedNodeInfo{NodeID: "faq"})
+	previews, err := PreviewResults(ctx, store, []schema.NodeWithScore{
+		{Node: *other, Score: 0.5},
+		{Node: *chunk, Score: 0.9},
+		{Node: *chunk2, Score: 0.4},
+	})
+	if err != nil {
+		t.Fatalf("PreviewResults failed: %v", err)
+	}
+	if len(previews) != 2 {
+		t.Fatalf("Expected 2 previews, got %d", len(previews))
+	}
+	if previews[0].RefDocID != "pricing" || previews[0].Score != 0.9 || len(previews[0].Nodes) != 2 || previews[0].Summary != "Acme's price list." {
+		t.Errorf("unexpected first preview: %+v", previews[0])
+	}
+	if previews[1].RefDocID != "faq" || previews[1].Summary != "" {
+		t.Errorf("unexpected second preview: %+v", previews[1])
+	}
+
+	// Deleting the document drops its summary.
+	if err := store.DeleteRefDoc(ctx, "pricing", true); err != nil {
+		t.Fatalf("DeleteRefDoc failed: %v", err)
+	}
+	if s, _ := GetDocumentSummary(ctx, store, "pricing"); s != nil {
+		t.Errorf("Expected summary to be deleted, got %+v", s)
+	}
+}
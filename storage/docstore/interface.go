@@ -93,6 +93,10 @@ type DocStore interface {
 	// GetAllRefDocInfo returns all reference document info.
 	GetAllRefDocInfo(ctx context.Context) (map[string]*RefDocInfo, error)
 
+	// SetRefDocMetadata merges metadata into a reference document's info,
+	// creating the info if needed.
+	SetRefDocMetadata(ctx context.Context, refDocID string, metadata map[string]interface{}) error
+
 	// DeleteRefDoc deletes a reference document and all its associated nodes.
 	// If raiseError is true, returns an error if the ref doc is not found.
 	DeleteRefDoc(ctx context.Context, refDocID string, raiseError bool) error
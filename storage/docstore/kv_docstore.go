@@ -355,6 +355,25 @@ func (s *KVDocumentStore) GetAllRefDocInfo(ctx context.Context) (map[string]*Ref
 	return result, nil
 }
 
+// SetRefDocMetadata merges metadata into a reference document's info,
+// creating the info if needed.
+func (s *KVDocumentStore) SetRefDocMetadata(ctx context.Context, refDocID string, metadata map[string]interface{}) error {
+	refDocInfo, err := s.GetRefDocInfo(ctx, refDocID)
+	if err != nil {
+		return err
+	}
+	if refDocInfo == nil {
+		refDocInfo = NewRefDocInfo()
+	}
+	if refDocInfo.Metadata == nil {
+		refDocInfo.Metadata = make(map[string]interface{})
+	}
+	for k, v := range metadata {
+		refDocInfo.Metadata[k] = v
+	}
+	return s.kvstore.Put(ctx, refDocID, mapToStoredValue(refDocInfo.ToMap()), s.refDocCollection)
+}
+
 // DeleteRefDoc deletes a reference document and all its associated nodes.
 func (s *KVDocumentStore) DeleteRefDoc(ctx context.Context, refDocID string, raiseError bool) error {
 	refDocInfo, err := s.GetRefDocInfo(ctx, refDocID)
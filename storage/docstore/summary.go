@@ -0,0 +1,145 @@
+package docstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aqua777/go-llamaindex/schema"
+)
+
+// Reference document metadata keys holding document-level summaries.
+const (
+	// DocumentSummaryKey holds the document summary.
+	DocumentSummaryKey = "document_summary"
+	// DocumentKeywordsKey holds the document keywords.
+	DocumentKeywordsKey = "document_keywords"
+)
+
+// DocumentSummary is the summary and keywords of a reference document.
+type DocumentSummary struct {
+	// RefDocID identifies the document.
+	RefDocID string `json:"ref_doc_id"`
+	// Summary is a short description of the whole document.
+	Summary string `json:"summary"`
+	// Keywords are the document's key terms.
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+// SetDocumentSummary stores a document summary in the reference document's
+// metadata.
+func SetDocumentSummary(ctx context.Context, store DocStore, summary DocumentSummary) error {
+	keywords := summary.Keywords
+	if keywords == nil {
+		keywords = []string{}
+	}
+	if err := store.SetRefDocMetadata(ctx, summary.RefDocID, map[string]interface{}{
+		DocumentSummaryKey:  summary.Summary,
+		DocumentKeywordsKey: keywords,
+	}); err != nil {
+		return fmt.Errorf("failed to store summary of %s: %w", summary.RefDocID, err)
+	}
+	return nil
+}
+
+// GetDocumentSummary returns the stored summary of a reference document,
+// or nil if it has none.
+func GetDocumentSummary(ctx context.Context, store DocStore, refDocID string) (*DocumentSummary, error) {
+	info, err := store.GetRefDocInfo(ctx, refDocID)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, nil
+	}
+	return summaryFromRefDocInfo(refDocID, info), nil
+}
+
+// ListDocumentSummaries returns all stored document summaries, ordered by
+// reference document ID.
+func ListDocumentSummaries(ctx context.Context, store DocStore) ([]DocumentSummary, error) {
+	infos, err := store.GetAllRefDocInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []DocumentSummary
+	for refDocID, info := range infos {
+		if s := summaryFromRefDocInfo(refDocID, info); s != nil {
+			summaries = append(summaries, *s)
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].RefDocID < summaries[j].RefDocID
+	})
+	return summaries, nil
+}
+
+// summaryFromRefDocInfo reads the summary keys, or returns nil if the info
+// has no summary.
+func summaryFromRefDocInfo(refDocID string, info *RefDocInfo) *DocumentSummary {
+	text, ok := info.Metadata[DocumentSummaryKey].(string)
+	if !ok {
+		return nil
+	}
+	summary := &DocumentSummary{RefDocID: refDocID, Summary: text}
+	switch keywords := info.Metadata[DocumentKeywordsKey].(type) {
+	case []string:
+		summary.Keywords = append(summary.Keywords, keywords...)
+	case []interface{}:
+		for _, k := range keywords {
+			if s, ok := k.(string); ok {
+				summary.Keywords = append(summary.Keywords, s)
+			}
+		}
+	}
+	return summary
+}
+
+// DocumentPreview groups retrieved nodes by reference document for display,
+// with the document's stored summary and keywords.
+type DocumentPreview struct {
+	DocumentSummary
+	// Score is the best score among the document's nodes.
+	Score float64 `json:"score"`
+	// Nodes are the retrieved nodes of the document, in result order.
+	Nodes []schema.NodeWithScore `json:"nodes"`
+}
+
+// PreviewResults groups retrieval results into per-document previews,
+// ordered by best score. Documents without a stored summary get an empty
+// one.
+func PreviewResults(ctx context.Context, store DocStore, results []schema.NodeWithScore) ([]DocumentPreview, error) {
+	index := make(map[string]int)
+	var previews []DocumentPreview
+	for _, result := range results {
+		refDocID := result.Node.ID
+		if source := result.Node.Relationships.GetSource(); source != nil && source.NodeID != "" {
+			refDocID = source.NodeID
+		}
+
+		i, ok := index[refDocID]
+		if !ok {
+			summary, err := GetDocumentSummary(ctx, store, refDocID)
+			if err != nil {
+				return nil, err
+			}
+			if summary == nil {
+				summary = &DocumentSummary{RefDocID: refDocID}
+			}
+			i = len(previews)
+			index[refDocID] = i
+			previews = append(previews, DocumentPreview{DocumentSummary: *summary, Score: result.Score})
+		}
+		p := &previews[i]
+		p.Nodes = append(p.Nodes, result)
+		if result.Score > p.Score {
+			p.Score = result.Score
+		}
+	}
+
+	sort.SliceStable(previews, func(i, j int) bool {
+		return previews[i].Score > previews[j].Score
+	})
+	return previews, nil
+}
@@ -29,6 +29,8 @@ const (
 	IndexStructTypeKG IndexStructType = "kg"
 	// IndexStructTypeLPG represents a labeled property graph index.
 	IndexStructTypeLPG IndexStructType = "simple_lpg"
+	// IndexStructTypeDocumentSummary represents a document summary index.
+	IndexStructTypeDocumentSummary IndexStructType = "document_summary"
 )
 
 // IndexStruct represents a base index structure.
@@ -43,7 +45,7 @@ type IndexStruct struct {
 	// For IndexList
 	Nodes []string `json:"nodes,omitempty"`
 
-	// For KeywordTable and KG
+	// For KeywordTable, KG and DocumentSummary
 	Table map[string][]string `json:"table,omitempty"`
 
 	// For IndexGraph (tree index)
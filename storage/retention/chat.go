@@ -0,0 +1,283 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/storage/chatstore"
+	"github.com/aqua777/go-llamaindex/storage/kvstore"
+)
+
+// DefaultChatCollection is the key-value collection holding chat session
+// retention records.
+const DefaultChatCollection = "chat_retention"
+
+// ChatStore wraps a chat store to track when each session was last updated
+// and whether it is soft-deleted or under legal hold. Soft-deleted sessions
+// read as empty and are omitted from GetKeys; writing to one restores it.
+//
+// The records are kept in a key-value store, which can be persisted next to
+// the chat store.
+type ChatStore struct {
+	store      chatstore.ChatStore
+	ledger     kvstore.KVStore
+	collection string
+	now        func() time.Time
+	mu         sync.Mutex
+}
+
+// ChatStoreOption configures a ChatStore.
+type ChatStoreOption func(*ChatStore)
+
+// WithChatCollection sets the key-value collection holding the records.
+func WithChatCollection(collection string) ChatStoreOption {
+	return func(s *ChatStore) {
+		s.collection = collection
+	}
+}
+
+// WithChatClock sets the function used to timestamp writes.
+func WithChatClock(now func() time.Time) ChatStoreOption {
+	return func(s *ChatStore) {
+		s.now = now
+	}
+}
+
+// NewChatStore wraps store, keeping retention records in ledger. A nil
+// ledger uses an in-memory store.
+func NewChatStore(store chatstore.ChatStore, ledger kvstore.KVStore, opts ...ChatStoreOption) *ChatStore {
+	if ledger == nil {
+		ledger = kvstore.NewSimpleKVStore()
+	}
+	s := &ChatStore{
+		store:      store,
+		ledger:     ledger,
+		collection: DefaultChatCollection,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Name returns "chat".
+func (s *ChatStore) Name() string {
+	return "chat"
+}
+
+// SetMessages replaces the messages of a session.
+func (s *ChatStore) SetMessages(ctx context.Context, key string, messages []llm.ChatMessage) error {
+	if err := s.store.SetMessages(ctx, key, messages); err != nil {
+		return err
+	}
+	return s.touch(ctx, key)
+}
+
+// GetMessages returns the messages of a session, or none if it is
+// soft-deleted.
+func (s *ChatStore) GetMessages(ctx context.Context, key string) ([]llm.ChatMessage, error) {
+	deleted, err := s.deleted(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if deleted {
+		return []llm.ChatMessage{}, nil
+	}
+	return s.store.GetMessages(ctx, key)
+}
+
+// AddMessage adds a message to a session.
+func (s *ChatStore) AddMessage(ctx context.Context, key string, message llm.ChatMessage, idx int) error {
+	deleted, err := s.deleted(ctx, key)
+	if err != nil {
+		return err
+	}
+	if deleted {
+		// The hidden history must not reappear when the session restarts.
+		if _, err := s.store.DeleteMessages(ctx, key); err != nil {
+			return err
+		}
+	}
+	if err := s.store.AddMessage(ctx, key, message, idx); err != nil {
+		return err
+	}
+	return s.touch(ctx, key)
+}
+
+// DeleteMessages deletes all messages of a session. This is a hard delete
+// and is refused while the session is under legal hold.
+func (s *ChatStore) DeleteMessages(ctx context.Context, key string) ([]llm.ChatMessage, error) {
+	if _, err := s.checkHold(ctx, key); err != nil {
+		return nil, err
+	}
+	messages, err := s.store.DeleteMessages(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.ledger.Delete(ctx, key, s.collection); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// DeleteMessage deletes one message of a session. Soft-deleted sessions
+// have no messages to delete.
+func (s *ChatStore) DeleteMessage(ctx context.Context, key string, idx int) (*llm.ChatMessage, error) {
+	deleted, err := s.checkHold(ctx, key)
+	if err != nil || deleted {
+		return nil, err
+	}
+	msg, err := s.store.DeleteMessage(ctx, key, idx)
+	if err != nil || msg == nil {
+		return msg, err
+	}
+	return msg, s.touch(ctx, key)
+}
+
+// DeleteLastMessage deletes the last message of a session.
+func (s *ChatStore) DeleteLastMessage(ctx context.Context, key string) (*llm.ChatMessage, error) {
+	deleted, err := s.checkHold(ctx, key)
+	if err != nil || deleted {
+		return nil, err
+	}
+	msg, err := s.store.DeleteLastMessage(ctx, key)
+	if err != nil || msg == nil {
+		return msg, err
+	}
+	return msg, s.touch(ctx, key)
+}
+
+// GetKeys returns the keys of sessions that are not soft-deleted.
+func (s *ChatStore) GetKeys(ctx context.Context) ([]string, error) {
+	keys, err := s.store.GetKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(keys))
+	for _, key := range keys {
+		deleted, err := s.deleted(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if !deleted {
+			out = append(out, key)
+		}
+	}
+	return out, nil
+}
+
+// Records returns the retention records of all sessions. Sessions written
+// before the store was wrapped are recorded as updated now.
+func (s *ChatStore) Records(ctx context.Context) ([]Record, error) {
+	keys, err := s.store.GetKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, 0, len(keys))
+	for _, key := range keys {
+		record, err := s.record(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			if err := s.touch(ctx, key); err != nil {
+				return nil, err
+			}
+			record = &Record{Key: key, UpdatedAt: s.now()}
+		}
+		records = append(records, *record)
+	}
+	return records, nil
+}
+
+// SoftDelete hides a session.
+func (s *ChatStore) SoftDelete(ctx context.Context, key string, at time.Time) error {
+	return s.update(ctx, key, func(r *Record) { r.DeletedAt = at })
+}
+
+// Restore makes a soft-deleted session visible again.
+func (s *ChatStore) Restore(ctx context.Context, key string) error {
+	return s.update(ctx, key, func(r *Record) { r.DeletedAt = time.Time{} })
+}
+
+// Purge permanently deletes a session and its record.
+func (s *ChatStore) Purge(ctx context.Context, key string) error {
+	_, err := s.DeleteMessages(ctx, key)
+	return err
+}
+
+// SetLegalHold places or releases a legal hold on a session.
+func (s *ChatStore) SetLegalHold(ctx context.Context, key string, hold bool) error {
+	return s.update(ctx, key, func(r *Record) { r.LegalHold = hold })
+}
+
+// touch records a write, restoring the session if it was soft-deleted.
+func (s *ChatStore) touch(ctx context.Context, key string) error {
+	return s.update(ctx, key, func(r *Record) {
+		r.UpdatedAt = s.now()
+		r.DeletedAt = time.Time{}
+	})
+}
+
+func (s *ChatStore) update(ctx context.Context, key string, fn func(*Record)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, err := s.record(ctx, key)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		record = &Record{Key: key, UpdatedAt: s.now()}
+	}
+	fn(record)
+	return s.ledger.Put(ctx, key, kvstore.StoredValue{
+		"updated_at": formatTime(record.UpdatedAt),
+		"deleted_at": formatTime(record.DeletedAt),
+		"legal_hold": record.LegalHold,
+	}, s.collection)
+}
+
+// record returns the stored record of a session, or nil if it has none.
+func (s *ChatStore) record(ctx context.Context, key string) (*Record, error) {
+	val, err := s.ledger.Get(ctx, key, s.collection)
+	if err != nil || val == nil {
+		return nil, err
+	}
+	hold, _ := val["legal_hold"].(bool)
+	return &Record{
+		Key:       key,
+		UpdatedAt: parseTime(val["updated_at"]),
+		DeletedAt: parseTime(val["deleted_at"]),
+		LegalHold: hold,
+	}, nil
+}
+
+func (s *ChatStore) deleted(ctx context.Context, key string) (bool, error) {
+	record, err := s.record(ctx, key)
+	if err != nil || record == nil {
+		return false, err
+	}
+	return record.Deleted(), nil
+}
+
+// checkHold returns an error if a session is under legal hold, and
+// otherwise whether it is soft-deleted.
+func (s *ChatStore) checkHold(ctx context.Context, key string) (bool, error) {
+	record, err := s.record(ctx, key)
+	if err != nil || record == nil {
+		return false, err
+	}
+	if record.LegalHold {
+		return false, fmt.Errorf("chat session %s is under legal hold", key)
+	}
+	return record.Deleted(), nil
+}
+
+var (
+	_ chatstore.ChatStore = (*ChatStore)(nil)
+	_ Target              = (*ChatStore)(nil)
+)
@@ -0,0 +1,180 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/storage/docstore"
+)
+
+// Reference document metadata keys holding retention state.
+const (
+	// UpdatedAtKey holds when the document was last ingested.
+	UpdatedAtKey = "retention_updated_at"
+	// DeletedAtKey holds when the document was soft-deleted.
+	DeletedAtKey = "retention_deleted_at"
+	// LegalHoldKey is true while the document is under legal hold.
+	LegalHoldKey = "legal_hold"
+)
+
+// DocStore applies retention to the reference documents of a docstore.
+// Retention state is kept in each reference document's metadata.
+//
+// Documents have no write timestamp until one is recorded: register the
+// DocStore as an ingestion RefDocListener to stamp replaced documents, or
+// call Touch after inserting. Documents without a timestamp are stamped
+// when a sweep first sees them.
+//
+// Soft-deleted documents stay in the docstore and any vector store until
+// purged; use SoftDeleted to exclude them from retrieval.
+type DocStore struct {
+	store      docstore.DocStore
+	purgeHooks []func(ctx context.Context, refDocID string) error
+	now        func() time.Time
+}
+
+// DocStoreOption configures a DocStore.
+type DocStoreOption func(*DocStore)
+
+// WithPurgeHook adds a function called with each purged reference document
+// ID before it is removed from the docstore, such as a vector store's
+// Delete method.
+func WithPurgeHook(fn func(ctx context.Context, refDocID string) error) DocStoreOption {
+	return func(s *DocStore) {
+		s.purgeHooks = append(s.purgeHooks, fn)
+	}
+}
+
+// WithDocClock sets the function used to timestamp documents.
+func WithDocClock(now func() time.Time) DocStoreOption {
+	return func(s *DocStore) {
+		s.now = now
+	}
+}
+
+// NewDocStore creates a retention target for store.
+func NewDocStore(store docstore.DocStore, opts ...DocStoreOption) *DocStore {
+	s := &DocStore{
+		store: store,
+		now:   time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Name returns "docstore".
+func (s *DocStore) Name() string {
+	return "docstore"
+}
+
+// Touch records that reference documents were written, restoring them if
+// they were soft-deleted.
+func (s *DocStore) Touch(ctx context.Context, refDocIDs ...string) error {
+	now := formatTime(s.now())
+	for _, id := range refDocIDs {
+		if err := s.store.SetRefDocMetadata(ctx, id, map[string]interface{}{
+			UpdatedAtKey: now,
+			DeletedAtKey: "",
+		}); err != nil {
+			return fmt.Errorf("failed to update retention state of %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// RefDocsChanged touches the reference documents an ingestion pipeline
+// replaced. Documents the pipeline deleted are skipped.
+func (s *DocStore) RefDocsChanged(ctx context.Context, refDocIDs []string) {
+	for _, id := range refDocIDs {
+		info, err := s.store.GetRefDocInfo(ctx, id)
+		if err != nil || info == nil {
+			continue
+		}
+		_ = s.Touch(ctx, id)
+	}
+}
+
+// Records returns the retention records of all reference documents.
+func (s *DocStore) Records(ctx context.Context) ([]Record, error) {
+	infos, err := s.store.GetAllRefDocInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, 0, len(infos))
+	for id, info := range infos {
+		record := recordFromRefDocInfo(id, info)
+		if record.UpdatedAt.IsZero() {
+			if err := s.Touch(ctx, id); err != nil {
+				return nil, err
+			}
+			record.UpdatedAt = s.now()
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// SoftDeleted returns the IDs of soft-deleted reference documents, sorted.
+func (s *DocStore) SoftDeleted(ctx context.Context) ([]string, error) {
+	infos, err := s.store.GetAllRefDocInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for id, info := range infos {
+		if recordFromRefDocInfo(id, info).Deleted() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// SoftDelete marks a reference document as deleted.
+func (s *DocStore) SoftDelete(ctx context.Context, refDocID string, at time.Time) error {
+	return s.store.SetRefDocMetadata(ctx, refDocID, map[string]interface{}{DeletedAtKey: formatTime(at)})
+}
+
+// Restore clears a reference document's soft delete.
+func (s *DocStore) Restore(ctx context.Context, refDocID string) error {
+	return s.store.SetRefDocMetadata(ctx, refDocID, map[string]interface{}{DeletedAtKey: ""})
+}
+
+// Purge runs the purge hooks and deletes a reference document and its
+// nodes. Documents under legal hold are refused.
+func (s *DocStore) Purge(ctx context.Context, refDocID string) error {
+	info, err := s.store.GetRefDocInfo(ctx, refDocID)
+	if err != nil {
+		return err
+	}
+	if info != nil && recordFromRefDocInfo(refDocID, info).LegalHold {
+		return fmt.Errorf("document %s is under legal hold", refDocID)
+	}
+	for _, hook := range s.purgeHooks {
+		if err := hook(ctx, refDocID); err != nil {
+			return fmt.Errorf("failed to purge %s: %w", refDocID, err)
+		}
+	}
+	return s.store.DeleteRefDoc(ctx, refDocID, false)
+}
+
+// SetLegalHold places or releases a legal hold on a reference document.
+func (s *DocStore) SetLegalHold(ctx context.Context, refDocID string, hold bool) error {
+	return s.store.SetRefDocMetadata(ctx, refDocID, map[string]interface{}{LegalHoldKey: hold})
+}
+
+func recordFromRefDocInfo(refDocID string, info *docstore.RefDocInfo) Record {
+	hold, _ := info.Metadata[LegalHoldKey].(bool)
+	return Record{
+		Key:       refDocID,
+		UpdatedAt: parseTime(info.Metadata[UpdatedAtKey]),
+		DeletedAt: parseTime(info.Metadata[DeletedAtKey]),
+		LegalHold: hold,
+	}
+}
+
+var _ Target = (*DocStore)(nil)
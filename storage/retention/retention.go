@@ -0,0 +1,243 @@
+// Package retention enforces data-retention policies on stored chats and
+// documents.
+//
+// A Sweeper applies a Policy to one or more Targets in two stages. The
+// soft-delete stage marks items that are older than the policy's maximum age
+// or beyond its item limit; soft-deleted items are hidden but can still be
+// restored. The purge stage permanently removes items that have been
+// soft-deleted for longer than the policy's grace period. Items under legal
+// hold are never soft-deleted or purged.
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Policy configures how long items are retained.
+type Policy struct {
+	// MaxAge soft-deletes items not updated within this duration. Zero
+	// disables age-based deletion.
+	MaxAge time.Duration
+	// MaxItems soft-deletes the least recently updated items beyond this
+	// count, such as the number of chat sessions kept. Items under legal
+	// hold count towards the limit but are kept. Zero disables the limit.
+	MaxItems int
+	// PurgeAfter is how long soft-deleted items are kept before they are
+	// purged. Zero purges items in the same sweep that soft-deletes them.
+	PurgeAfter time.Duration
+	// DisablePurge keeps soft-deleted items until they are restored or
+	// purged explicitly.
+	DisablePurge bool
+}
+
+// Record describes the retention state of one stored item.
+type Record struct {
+	// Key identifies the item within its target.
+	Key string `json:"key"`
+	// UpdatedAt is when the item was last written.
+	UpdatedAt time.Time `json:"updated_at"`
+	// DeletedAt is when the item was soft-deleted, or zero if it is live.
+	DeletedAt time.Time `json:"deleted_at,omitempty"`
+	// LegalHold exempts the item from deletion.
+	LegalHold bool `json:"legal_hold,omitempty"`
+}
+
+// Deleted reports whether the item is soft-deleted.
+func (r Record) Deleted() bool {
+	return !r.DeletedAt.IsZero()
+}
+
+// Target is a store whose items are subject to a retention policy.
+type Target interface {
+	// Name identifies the target in sweep results.
+	Name() string
+	// Records returns the retention state of all items, including
+	// soft-deleted ones.
+	Records(ctx context.Context) ([]Record, error)
+	// SoftDelete hides an item, recording when it was deleted.
+	SoftDelete(ctx context.Context, key string, at time.Time) error
+	// Restore undoes a soft delete.
+	Restore(ctx context.Context, key string) error
+	// Purge permanently removes an item.
+	Purge(ctx context.Context, key string) error
+	// SetLegalHold places or releases a legal hold on an item.
+	SetLegalHold(ctx context.Context, key string, hold bool) error
+}
+
+// SweepResult reports the items a sweep changed in one target.
+type SweepResult struct {
+	// Target is the target's name.
+	Target string `json:"target"`
+	// SoftDeleted lists the keys soft-deleted by the sweep.
+	SoftDeleted []string `json:"soft_deleted,omitempty"`
+	// Purged lists the keys purged by the sweep.
+	Purged []string `json:"purged,omitempty"`
+	// Held lists the keys the policy would have deleted but that are under
+	// legal hold.
+	Held []string `json:"held,omitempty"`
+	// Error is the first error encountered in the target, if any.
+	Error error `json:"-"`
+}
+
+// Sweeper applies a retention policy to its targets.
+type Sweeper struct {
+	policy  Policy
+	targets []Target
+	now     func() time.Time
+	onSweep func([]SweepResult)
+}
+
+// SweeperOption configures a Sweeper.
+type SweeperOption func(*Sweeper)
+
+// WithClock sets the function used to read the current time.
+func WithClock(now func() time.Time) SweeperOption {
+	return func(s *Sweeper) {
+		s.now = now
+	}
+}
+
+// WithSweepHandler sets a function called with the results of every
+// background sweep, for audit logging.
+func WithSweepHandler(fn func([]SweepResult)) SweeperOption {
+	return func(s *Sweeper) {
+		s.onSweep = fn
+	}
+}
+
+// NewSweeper creates a sweeper applying policy to targets.
+func NewSweeper(policy Policy, targets []Target, opts ...SweeperOption) *Sweeper {
+	s := &Sweeper{
+		policy:  policy,
+		targets: targets,
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Policy returns the sweeper's policy.
+func (s *Sweeper) Policy() Policy {
+	return s.policy
+}
+
+// Sweep runs the soft-delete and purge stages once on every target. A
+// failing target does not stop the others; their errors are joined.
+func (s *Sweeper) Sweep(ctx context.Context) ([]SweepResult, error) {
+	now := s.now()
+	results := make([]SweepResult, 0, len(s.targets))
+	var errs []error
+	for _, target := range s.targets {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		result := s.sweepTarget(ctx, target, now)
+		if result.Error != nil {
+			errs = append(errs, fmt.Errorf("retention sweep of %s failed: %w", target.Name(), result.Error))
+		}
+		results = append(results, result)
+	}
+	return results, errors.Join(errs...)
+}
+
+// Start runs Sweep every interval until ctx is cancelled.
+func (s *Sweeper) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				results, _ := s.Sweep(ctx)
+				if s.onSweep != nil {
+					s.onSweep(results)
+				}
+			}
+		}
+	}()
+}
+
+func (s *Sweeper) sweepTarget(ctx context.Context, target Target, now time.Time) SweepResult {
+	result := SweepResult{Target: target.Name()}
+	records, err := target.Records(ctx)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+
+	// Most recently updated first, so that the item limit keeps the newest.
+	sort.SliceStable(records, func(i, j int) bool {
+		if !records[i].UpdatedAt.Equal(records[j].UpdatedAt) {
+			return records[i].UpdatedAt.After(records[j].UpdatedAt)
+		}
+		return records[i].Key < records[j].Key
+	})
+
+	// Soft-delete stage.
+	live := 0
+	for i := range records {
+		r := &records[i]
+		if r.Deleted() {
+			continue
+		}
+		live++
+		expired := s.policy.MaxAge > 0 && now.Sub(r.UpdatedAt) > s.policy.MaxAge
+		overLimit := s.policy.MaxItems > 0 && live > s.policy.MaxItems
+		if !expired && !overLimit {
+			continue
+		}
+		if r.LegalHold {
+			result.Held = append(result.Held, r.Key)
+			continue
+		}
+		if err := target.SoftDelete(ctx, r.Key, now); err != nil {
+			result.Error = err
+			return result
+		}
+		r.DeletedAt = now
+		result.SoftDeleted = append(result.SoftDeleted, r.Key)
+	}
+
+	// Purge stage.
+	if s.policy.DisablePurge {
+		return result
+	}
+	for _, r := range records {
+		if !r.Deleted() || now.Sub(r.DeletedAt) < s.policy.PurgeAfter {
+			continue
+		}
+		if r.LegalHold {
+			result.Held = append(result.Held, r.Key)
+			continue
+		}
+		if err := target.Purge(ctx, r.Key); err != nil {
+			result.Error = err
+			return result
+		}
+		result.Purged = append(result.Purged, r.Key)
+	}
+	return result
+}
+
+// formatTime and parseTime store timestamps as RFC 3339 strings so that
+// they survive JSON persistence.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(v interface{}) time.Time {
+	s, _ := v.(string)
+	t, _ := time.Parse(time.RFC3339Nano, s)
+	return t
+}
@@ -0,0 +1,248 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/storage/chatstore"
+	"github.com/aqua777/go-llamaindex/storage/docstore"
+	"github.com/aqua777/go-llamaindex/storage/kvstore"
+)
+
+// clock is a settable test clock.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+func newClock() *clock                   { return &clock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)} }
+func sorted(keys []string) []string      { sort.Strings(keys); return keys }
+func assertKeys(t *testing.T, got, want []string) {
+	t.Helper()
+	if len(got) == 0 && len(want) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(sorted(got), sorted(want)) {
+		t.Errorf("got keys %v, want %v", got, want)
+	}
+}
+
+func TestChatStoreRetention(t *testing.T) {
+	ctx := context.Background()
+	c := newClock()
+	store := NewChatStore(chatstore.NewSimpleChatStore(), kvstore.NewSimpleKVStore(), WithChatClock(c.now))
+
+	for _, key := range []string{"old", "held", "recent"} {
+		if err := store.AddMessage(ctx, key, llm.NewUserMessage("hi "+key), chatstore.IndexNotSpecified); err != nil {
+			t.Fatalf("AddMessage failed: %v", err)
+		}
+		if key == "held" {
+			c.advance(time.Hour)
+		}
+	}
+	if err := store.SetLegalHold(ctx, "held", true); err != nil {
+		t.Fatalf("SetLegalHold failed: %v", err)
+	}
+	c.advance(40 * 24 * time.Hour)
+	if err := store.AddMessage(ctx, "recent", llm.NewAssistantMessage("hello"), chatstore.IndexNotSpecified); err != nil {
+		t.Fatalf("AddMessage failed: %v", err)
+	}
+
+	sweeper := NewSweeper(Policy{MaxAge: 30 * 24 * time.Hour, PurgeAfter: 7 * 24 * time.Hour}, []Target{store}, WithClock(c.now))
+	results, err := sweeper.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	assertKeys(t, results[0].SoftDeleted, []string{"old"})
+	assertKeys(t, results[0].Held, []string{"held"})
+	assertKeys(t, results[0].Purged, nil)
+
+	// Soft-deleted sessions are hidden but not yet removed.
+	keys, _ := store.GetKeys(ctx)
+	assertKeys(t, keys, []string{"held", "recent"})
+	msgs, _ := store.GetMessages(ctx, "old")
+	if len(msgs) != 0 {
+		t.Errorf("soft-deleted session returned %d messages", len(msgs))
+	}
+	if _, err := store.DeleteMessages(ctx, "held"); err == nil {
+		t.Error("expected legal hold to block deletion")
+	}
+
+	// Restored sessions are visible again until the next sweep.
+	if err := store.Restore(ctx, "old"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	msgs, _ = store.GetMessages(ctx, "old")
+	if len(msgs) != 1 {
+		t.Errorf("restored session returned %d messages, want 1", len(msgs))
+	}
+
+	if _, err := sweeper.Sweep(ctx); err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	c.advance(8 * 24 * time.Hour)
+	results, err = sweeper.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	assertKeys(t, results[0].Purged, []string{"old"})
+
+	inner, _ := store.store.GetKeys(ctx)
+	assertKeys(t, inner, []string{"held", "recent"})
+}
+
+func TestChatStoreMaxSessions(t *testing.T) {
+	ctx := context.Background()
+	c := newClock()
+	store := NewChatStore(chatstore.NewSimpleChatStore(), nil, WithChatClock(c.now))
+	for _, key := range []string{"a", "b", "c", "d"} {
+		if err := store.SetMessages(ctx, key, []llm.ChatMessage{llm.NewUserMessage(key)}); err != nil {
+			t.Fatalf("SetMessages failed: %v", err)
+		}
+		c.advance(time.Minute)
+	}
+
+	sweeper := NewSweeper(Policy{MaxItems: 2, DisablePurge: true}, []Target{store}, WithClock(c.now))
+	results, err := sweeper.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	assertKeys(t, results[0].SoftDeleted, []string{"a", "b"})
+	assertKeys(t, results[0].Purged, nil)
+
+	// Writing to a soft-deleted session starts it afresh.
+	if err := store.AddMessage(ctx, "a", llm.NewUserMessage("again"), chatstore.IndexNotSpecified); err != nil {
+		t.Fatalf("AddMessage failed: %v", err)
+	}
+	msgs, _ := store.GetMessages(ctx, "a")
+	if len(msgs) != 1 || msgs[0].Content != "again" {
+		t.Errorf("got messages %v, want only the new one", msgs)
+	}
+}
+
+func TestDocStoreRetention(t *testing.T) {
+	ctx := context.Background()
+	c := newClock()
+	docs := docstore.NewSimpleDocumentStore()
+	for _, id := range []string{"doc-1", "doc-2"} {
+		node := schema.NewTextNode("chunk of " + id)
+		node.ID = id + "-0"
+		node.GetRelationships().SetSource(schema.RelatedNodeInfo{NodeID: id})
+		if err := docs.AddDocuments(ctx, []schema.BaseNode{node}, true); err != nil {
+			t.Fatalf("AddDocuments failed: %v", err)
+		}
+	}
+
+	var purged []string
+	target := NewDocStore(docs, WithDocClock(c.now), WithPurgeHook(func(ctx context.Context, refDocID string) error {
+		purged = append(purged, refDocID)
+		return nil
+	}))
+	sweeper := NewSweeper(Policy{MaxAge: 24 * time.Hour, PurgeAfter: time.Hour}, []Target{target}, WithClock(c.now))
+
+	// Unstamped documents are stamped by the first sweep.
+	results, err := sweeper.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	assertKeys(t, results[0].SoftDeleted, nil)
+
+	c.advance(2 * 24 * time.Hour)
+	target.RefDocsChanged(ctx, []string{"doc-2", "deleted-doc"})
+	if info, _ := docs.GetRefDocInfo(ctx, "deleted-doc"); info != nil {
+		t.Error("RefDocsChanged created info for a deleted document")
+	}
+	if _, err := sweeper.Sweep(ctx); err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	deleted, _ := target.SoftDeleted(ctx)
+	assertKeys(t, deleted, []string{"doc-1"})
+	if ok, _ := docs.DocumentExists(ctx, "doc-1-0"); !ok {
+		t.Error("soft-deleted document nodes were removed")
+	}
+
+	c.advance(2 * time.Hour)
+	results, err = sweeper.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	assertKeys(t, results[0].Purged, []string{"doc-1"})
+	assertKeys(t, purged, []string{"doc-1"})
+	if ok, _ := docs.DocumentExists(ctx, "doc-1-0"); ok {
+		t.Error("purged document nodes remain")
+	}
+	if _, err := target.Records(ctx); err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+
+	// Legal holds survive expiry and block purges.
+	if err := target.SetLegalHold(ctx, "doc-2", true); err != nil {
+		t.Fatalf("SetLegalHold failed: %v", err)
+	}
+	c.advance(30 * 24 * time.Hour)
+	results, _ = sweeper.Sweep(ctx)
+	assertKeys(t, results[0].Held, []string{"doc-2"})
+	if err := target.Purge(ctx, "doc-2"); err == nil {
+		t.Error("expected legal hold to block purge")
+	}
+}
+
+type failingTarget struct{ *DocStore }
+
+func (failingTarget) Name() string { return "failing" }
+func (failingTarget) Records(context.Context) ([]Record, error) {
+	return nil, errors.New("backend unavailable")
+}
+
+func TestSweepContinuesAfterTargetError(t *testing.T) {
+	ctx := context.Background()
+	c := newClock()
+	chats := NewChatStore(chatstore.NewSimpleChatStore(), nil, WithChatClock(c.now))
+	if err := chats.SetMessages(ctx, "s", []llm.ChatMessage{llm.NewUserMessage("hi")}); err != nil {
+		t.Fatalf("SetMessages failed: %v", err)
+	}
+	c.advance(2 * time.Hour)
+
+	sweeper := NewSweeper(Policy{MaxAge: time.Hour}, []Target{failingTarget{}, chats}, WithClock(c.now))
+	results, err := sweeper.Sweep(ctx)
+	if err == nil {
+		t.Fatal("expected an error from the failing target")
+	}
+	if len(results) != 2 || results[0].Error == nil {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	assertKeys(t, results[1].Purged, []string{"s"})
+}
+
+func TestSweeperStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := newClock()
+	chats := NewChatStore(chatstore.NewSimpleChatStore(), nil, WithChatClock(c.now))
+	if err := chats.SetMessages(ctx, "s", []llm.ChatMessage{llm.NewUserMessage("hi")}); err != nil {
+		t.Fatalf("SetMessages failed: %v", err)
+	}
+
+	swept := make(chan []SweepResult, 1)
+	sweeper := NewSweeper(Policy{MaxItems: 1}, []Target{chats}, WithSweepHandler(func(results []SweepResult) {
+		select {
+		case swept <- results:
+		default:
+		}
+	}))
+	sweeper.Start(ctx, 10*time.Millisecond)
+
+	select {
+	case results := <-swept:
+		if len(results) != 1 || results[0].Target != "chat" {
+			t.Errorf("unexpected results %+v", results)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("sweeper did not run")
+	}
+}
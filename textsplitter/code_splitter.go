@@ -0,0 +1,326 @@
+package textsplitter
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultCodeMaxChars is the default maximum chunk size of CodeSplitter, in
+// characters.
+const DefaultCodeMaxChars = 1500
+
+// CodeLanguage is a programming language understood by CodeSplitter.
+type CodeLanguage string
+
+const (
+	// CodeLanguageGo is Go.
+	CodeLanguageGo CodeLanguage = "go"
+	// CodeLanguagePython is Python.
+	CodeLanguagePython CodeLanguage = "python"
+	// CodeLanguageJavaScript is JavaScript, also used for TypeScript.
+	CodeLanguageJavaScript CodeLanguage = "javascript"
+	// CodeLanguageJava is Java.
+	CodeLanguageJava CodeLanguage = "java"
+)
+
+// codeExtensions maps file extensions to languages.
+var codeExtensions = map[string]CodeLanguage{
+	".go":   CodeLanguageGo,
+	".py":   CodeLanguagePython,
+	".pyi":  CodeLanguagePython,
+	".js":   CodeLanguageJavaScript,
+	".jsx":  CodeLanguageJavaScript,
+	".mjs":  CodeLanguageJavaScript,
+	".cjs":  CodeLanguageJavaScript,
+	".ts":   CodeLanguageJavaScript,
+	".tsx":  CodeLanguageJavaScript,
+	".java": CodeLanguageJava,
+}
+
+// DetectCodeLanguage returns the language of a file from its extension.
+func DetectCodeLanguage(filename string) (CodeLanguage, bool) {
+	lang, ok := codeExtensions[strings.ToLower(filepath.Ext(filename))]
+	return lang, ok
+}
+
+// codeSyntax describes the declarations of a language line by line.
+type codeSyntax struct {
+	// declaration matches the first line of a function, class, method or
+	// other top-level declaration, without indentation.
+	declaration []*regexp.Regexp
+	// attached matches lines that belong to the declaration below them,
+	// such as doc comments, decorators and annotations.
+	attached *regexp.Regexp
+}
+
+var codeSyntaxes = map[CodeLanguage]codeSyntax{
+	CodeLanguageGo: {
+		declaration: []*regexp.Regexp{
+			regexp.MustCompile(`^(func|type|var|const|import)\b`),
+		},
+		attached: regexp.MustCompile(`^(//|/\*|\*)`),
+	},
+	CodeLanguagePython: {
+		declaration: []*regexp.Regexp{
+			regexp.MustCompile(`^(async\s+def|def|class)\s`),
+		},
+		attached: regexp.MustCompile(`^(#|@)`),
+	},
+	CodeLanguageJavaScript: {
+		declaration: []*regexp.Regexp{
+			regexp.MustCompile(`^(export\s+)?(default\s+)?(abstract\s+)?(async\s+)?(function\b|class\b|interface\b|enum\b|type\s+\w+\s*=)`),
+			regexp.MustCompile(`^(export\s+)?(const|let|var)\s+[\w$]+(\s*:[^=]+)?\s*=\s*(async\s+)?(function\b|\([^)]*\)\s*(:[^=]+)?=>|[\w$]+\s*=>)`),
+			regexp.MustCompile(`^(public\s+|private\s+|protected\s+)?(static\s+)?(async\s+)?(get\s+|set\s+)?\*?[\w$]+\s*\([^;]*\)\s*(:[^{]+)?\{\s*$`),
+		},
+		attached: regexp.MustCompile(`^(//|/\*|\*|@)`),
+	},
+	CodeLanguageJava: {
+		declaration: []*regexp.Regexp{
+			regexp.MustCompile(`^((public|private|protected|static|final|abstract|sealed|non-sealed|strictfp)\s+)*(class|interface|enum|record|@interface)\s`),
+			regexp.MustCompile(`^((public|private|protected|static|final|abstract|synchronized|native|default)\s+)*(<[^>]+>\s+)?[\w<>\[\],.?]+(\s*<[^>]*>)?\s+\w+\s*\([^;]*$`),
+		},
+		attached: regexp.MustCompile(`^(//|/\*|\*|@)`),
+	},
+}
+
+// codeControlKeywords start statements that look like declarations to the
+// method patterns.
+var codeControlKeywords = regexp.MustCompile(`^(if|else|for|while|do|switch|case|catch|try|return|throw|new|yield|await|super|this)\b`)
+
+// CodeSplitter splits source code into chunks at function, class and method
+// boundaries, so a chunk never cuts a function in half unless the function
+// alone is larger than MaxChars.
+//
+// Declarations are found line by line with per-language patterns, in the
+// spirit of tree-sitter's syntax nodes but without a parser dependency: the
+// code is split at the shallowest declarations, doc comments and decorators
+// stay with the declaration below them, and small neighbouring declarations
+// are merged up to MaxChars. An oversized declaration, e.g. a large class,
+// is split again at its nested declarations; code without declarations
+// falls back to line and finally character splits.
+type CodeSplitter struct {
+	// Language is the language of the code.
+	Language CodeLanguage
+	// MaxChars is the maximum size of a chunk in characters.
+	MaxChars int
+}
+
+// NewCodeSplitter creates a new CodeSplitter. A maxChars of zero or less
+// uses DefaultCodeMaxChars.
+func NewCodeSplitter(language CodeLanguage, maxChars int) *CodeSplitter {
+	if maxChars <= 0 {
+		maxChars = DefaultCodeMaxChars
+	}
+	return &CodeSplitter{
+		Language: language,
+		MaxChars: maxChars,
+	}
+}
+
+// NewCodeSplitterForFile creates a new CodeSplitter for the language of
+// filename. For unknown extensions the splitter only uses the line and
+// character fallback.
+func NewCodeSplitterForFile(filename string, maxChars int) *CodeSplitter {
+	lang, _ := DetectCodeLanguage(filename)
+	return NewCodeSplitter(lang, maxChars)
+}
+
+// SplitText splits code into chunks of at most MaxChars characters.
+func (s *CodeSplitter) SplitText(text string) []string {
+	if strings.TrimSpace(text) == "" {
+		return []string{}
+	}
+
+	lines := strings.SplitAfter(text, "\n")
+	pieces := s.splitRange(lines, 0, len(lines))
+
+	var chunks []string
+	var current strings.Builder
+	currentLen := 0
+	flush := func() {
+		if chunk := trimBlankLines(current.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+		currentLen = 0
+	}
+
+	for _, piece := range pieces {
+		pieceLen := utf8.RuneCountInString(piece)
+		if currentLen > 0 && currentLen+pieceLen > s.MaxChars {
+			flush()
+		}
+		current.WriteString(piece)
+		currentLen += pieceLen
+	}
+	flush()
+
+	return chunks
+}
+
+// splitRange splits lines[lo:hi] into contiguous pieces that each fit
+// MaxChars.
+func (s *CodeSplitter) splitRange(lines []string, lo, hi int) []string {
+	text := strings.Join(lines[lo:hi], "")
+	if utf8.RuneCountInString(text) <= s.MaxChars {
+		return []string{text}
+	}
+
+	cuts := s.boundaries(lines, lo, hi, lo)
+	if len(cuts) == 0 {
+		// The range is a single declaration: split at its nested ones.
+		if decl := s.firstDeclaration(lines, lo, hi); decl >= 0 {
+			cuts = s.boundaries(lines, decl+1, hi, lo)
+		}
+	}
+	if len(cuts) == 0 {
+		return s.splitLines(lines[lo:hi])
+	}
+
+	var pieces []string
+	start := lo
+	for _, cut := range append(cuts, hi) {
+		pieces = append(pieces, s.splitRange(lines, start, cut)...)
+		start = cut
+	}
+	return pieces
+}
+
+// boundaries returns the lines in (lo, hi) where the shallowest
+// declarations found in [from, hi) start, moved up to their attached
+// comments and decorators.
+func (s *CodeSplitter) boundaries(lines []string, from, hi, lo int) []int {
+	syntax, ok := codeSyntaxes[s.Language]
+	if !ok {
+		return nil
+	}
+
+	var decls []int
+	minIndent := -1
+	for i := from; i < hi; i++ {
+		indent, trimmed := splitIndent(lines[i])
+		if !syntax.isDeclaration(trimmed) {
+			continue
+		}
+		switch {
+		case minIndent < 0 || indent < minIndent:
+			minIndent = indent
+			decls = []int{i}
+		case indent == minIndent:
+			decls = append(decls, i)
+		}
+	}
+
+	var cuts []int
+	for _, decl := range decls {
+		start := decl
+		for start > from {
+			indent, trimmed := splitIndent(lines[start-1])
+			if indent != minIndent || trimmed == "" || !syntax.attached.MatchString(trimmed) {
+				break
+			}
+			start--
+		}
+		if start > lo && (len(cuts) == 0 || start > cuts[len(cuts)-1]) {
+			cuts = append(cuts, start)
+		}
+	}
+	return cuts
+}
+
+// firstDeclaration returns the first declaration line in [lo, hi), or -1.
+func (s *CodeSplitter) firstDeclaration(lines []string, lo, hi int) int {
+	syntax, ok := codeSyntaxes[s.Language]
+	if !ok {
+		return -1
+	}
+	for i := lo; i < hi; i++ {
+		if _, trimmed := splitIndent(lines[i]); syntax.isDeclaration(trimmed) {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitLines greedily packs lines into pieces of at most MaxChars, cutting
+// lines that are longer on their own.
+func (s *CodeSplitter) splitLines(lines []string) []string {
+	var pieces []string
+	var current strings.Builder
+	currentLen := 0
+
+	for _, line := range lines {
+		lineLen := utf8.RuneCountInString(line)
+		if currentLen > 0 && currentLen+lineLen > s.MaxChars {
+			pieces = append(pieces, current.String())
+			current.Reset()
+			currentLen = 0
+		}
+		if lineLen > s.MaxChars {
+			runes := []rune(line)
+			for len(runes) > s.MaxChars {
+				pieces = append(pieces, string(runes[:s.MaxChars]))
+				runes = runes[s.MaxChars:]
+			}
+			line = string(runes)
+			lineLen = len(runes)
+		}
+		current.WriteString(line)
+		currentLen += lineLen
+	}
+	if currentLen > 0 {
+		pieces = append(pieces, current.String())
+	}
+	return pieces
+}
+
+func (c codeSyntax) isDeclaration(line string) bool {
+	if line == "" || codeControlKeywords.MatchString(line) {
+		return false
+	}
+	for _, re := range c.declaration {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitIndent returns the indentation width of a line, counting a tab as
+// four spaces, and the line without indentation and trailing whitespace.
+func splitIndent(line string) (int, string) {
+	indent := 0
+	for i, r := range line {
+		switch r {
+		case ' ':
+			indent++
+		case '\t':
+			indent += 4
+		default:
+			return indent, strings.TrimRightFunc(line[i:], isSpace)
+		}
+	}
+	return indent, ""
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+// trimBlankLines removes leading and trailing blank lines but keeps the
+// indentation of the first line.
+func trimBlankLines(text string) string {
+	for {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 || strings.TrimSpace(text[:i]) != "" {
+			break
+		}
+		text = text[i+1:]
+	}
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+	return strings.TrimRightFunc(text, isSpace)
+}
@@ -230,10 +230,13 @@ func (s *MarkdownSplitter) splitByHeaders(text string, headerRegex *regexp.Regex
 }
 
 // mergeSections merges sections into chunks respecting token limits.
+// Chunks are measured on their joined text, and consecutive chunks share
+// trailing sections up to ChunkOverlap tokens.
 func (s *MarkdownSplitter) mergeSections(sections []markdownSection) []string {
 	var chunks []string
-	var currentChunk strings.Builder
-	currentTokens := 0
+	var current []string
+	// fresh is true once current holds more than carried-over overlap.
+	fresh := false
 
 	for _, section := range sections {
 		sectionTokens := s.tokenLength(section.content)
@@ -241,11 +244,10 @@ func (s *MarkdownSplitter) mergeSections(sections []markdownSection) []string {
 		// If section alone exceeds chunk size, split it further
 		if sectionTokens > s.ChunkSize {
 			// Flush current chunk
-			if currentChunk.Len() > 0 {
-				chunks = append(chunks, currentChunk.String())
-				currentChunk.Reset()
-				currentTokens = 0
+			if fresh {
+				chunks = append(chunks, strings.Join(current, ""))
 			}
+			current, fresh = nil, false
 			// Split large section by paragraphs or lines
 			subChunks := s.splitLargeSection(section.content)
 			chunks = append(chunks, subChunks...)
@@ -253,35 +255,56 @@ func (s *MarkdownSplitter) mergeSections(sections []markdownSection) []string {
 		}
 
 		// Check if adding this section exceeds limit
-		if currentTokens+sectionTokens > s.ChunkSize && currentChunk.Len() > 0 {
-			chunks = append(chunks, currentChunk.String())
-			currentChunk.Reset()
-			currentTokens = 0
+		if len(current) > 0 && s.tokenLength(strings.Join(current, "")+section.content) > s.ChunkSize {
+			if fresh {
+				chunks = append(chunks, strings.Join(current, ""))
+				current = s.overlapSections(current)
+			}
+			for len(current) > 0 && s.tokenLength(strings.Join(current, "")+section.content) > s.ChunkSize {
+				current = current[1:]
+			}
 		}
 
-		currentChunk.WriteString(section.content)
-		currentTokens += sectionTokens
+		current = append(current, section.content)
+		fresh = true
 	}
 
 	// Add remaining
-	if currentChunk.Len() > 0 {
-		chunks = append(chunks, currentChunk.String())
+	if fresh {
+		chunks = append(chunks, strings.Join(current, ""))
 	}
 
 	return chunks
 }
 
-// splitLargeSection splits a large section by paragraphs or lines.
+// overlapSections returns the trailing sections of a chunk whose joined
+// text fits within ChunkOverlap tokens.
+func (s *MarkdownSplitter) overlapSections(sections []string) []string {
+	var overlap []string
+	for i := len(sections) - 1; i >= 0; i-- {
+		candidate := append([]string{sections[i]}, overlap...)
+		if s.tokenLength(strings.Join(candidate, "")) > s.ChunkOverlap {
+			break
+		}
+		overlap = candidate
+	}
+	return overlap
+}
+
+// splitLargeSection splits a large section by paragraphs, then lines, and
+// finally by token windows for a single oversized line.
 func (s *MarkdownSplitter) splitLargeSection(text string) []string {
 	// Try splitting by paragraphs first
-	paragraphs := strings.Split(text, "\n\n")
-	if len(paragraphs) > 1 {
-		return s.mergeSections(s.toSections(paragraphs))
+	if paragraphs := s.toSections(SplitTextKeepSeparator(text, "\n\n")); len(paragraphs) > 1 {
+		return s.mergeSections(paragraphs)
 	}
 
 	// Fall back to splitting by lines
-	lines := strings.Split(text, "\n")
-	return s.mergeSections(s.toSections(lines))
+	if lines := s.toSections(SplitTextKeepSeparator(text, "\n")); len(lines) > 1 {
+		return s.mergeSections(lines)
+	}
+
+	return splitByTokenWindows(text, s.Tokenizer, s.ChunkSize, s.ChunkOverlap)
 }
 
 // toSections converts strings to sections.
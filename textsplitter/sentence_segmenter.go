@@ -0,0 +1,313 @@
+package textsplitter
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// commonAbbreviations lists abbreviations that end with a period but rarely
+// end a sentence. They are always active; language hints add more.
+var commonAbbreviations = []string{
+	"mr", "mrs", "ms", "dr", "prof", "sr", "jr", "st", "mt", "vs", "etc",
+	"e.g", "i.e", "cf", "al", "approx", "fig", "figs", "eq", "eqs", "no",
+	"nos", "vol", "vols", "pp", "p", "ch", "sec", "ref", "refs", "ed", "eds",
+	"inc", "ltd", "co", "corp", "dept", "est", "min", "max", "ph.d", "b.sc",
+	"m.sc", "a.m", "p.m", "jan", "feb", "mar", "apr", "jun", "jul", "aug",
+	"sep", "sept", "oct", "nov", "dec", "gen", "gov", "rev", "lt", "col",
+}
+
+// languageAbbreviations lists additional abbreviations per ISO 639-1 code.
+var languageAbbreviations = map[string][]string{
+	"de": {
+		"bzw", "ca", "d.h", "evtl", "ggf", "inkl", "nr", "s", "str", "u.a",
+		"usw", "vgl", "z.b", "bspw", "abs", "abb", "hr", "fr", "geb", "jh",
+	},
+	"fr": {
+		"m", "mm", "mme", "mmes", "mlle", "p.ex", "env", "av", "bd", "chap",
+		"éd", "réf", "cf",
+	},
+	"es": {
+		"sr", "sra", "srta", "ud", "uds", "p.ej", "pág", "págs", "núm", "av",
+		"admón", "aprox", "cía", "dra",
+	},
+	"it": {
+		"sig", "sigg", "sig.ra", "dott", "ing", "avv", "pag", "ecc", "es",
+	},
+	"nl": {
+		"dhr", "mevr", "bijv", "blz", "d.w.z", "o.a", "m.b.t", "enz",
+	},
+	"pt": {
+		"sr", "sra", "srta", "dra", "pág", "núm", "av", "p.ex", "ex",
+	},
+}
+
+// UnicodeSentenceStrategy splits text into sentences following the main
+// rules of Unicode sentence segmentation (UAX #29): sentences end at
+// terminal punctuation from any script, trailing quotes and brackets stay
+// with their sentence, and a period followed by a lowercase word does not
+// end a sentence. On top of that it recognises abbreviations, initials,
+// decimals and list markers, so "Dr. Smith paid $3.50 on Jan. 5." stays one
+// sentence. CJK and other full-width terminals end a sentence without
+// trailing whitespace.
+//
+// Every input byte is kept: each sentence includes its trailing whitespace,
+// so the sentences concatenate back to the original text.
+type UnicodeSentenceStrategy struct {
+	language      string
+	abbreviations map[string]bool
+}
+
+// UnicodeSentenceOption configures a UnicodeSentenceStrategy.
+type UnicodeSentenceOption func(*UnicodeSentenceStrategy)
+
+// WithSentenceLanguage hints the document language as an ISO 639-1 code or
+// BCP 47 tag, e.g. "de" or "fr-CA". It enables that language's
+// abbreviations in addition to the common ones.
+func WithSentenceLanguage(language string) UnicodeSentenceOption {
+	return func(s *UnicodeSentenceStrategy) {
+		s.language = language
+	}
+}
+
+// WithAbbreviations adds domain-specific abbreviations, written without
+// the final period, e.g. "approx" or "u.s.a".
+func WithAbbreviations(abbreviations ...string) UnicodeSentenceOption {
+	return func(s *UnicodeSentenceStrategy) {
+		for _, abbr := range abbreviations {
+			s.abbreviations[strings.ToLower(strings.TrimSuffix(abbr, "."))] = true
+		}
+	}
+}
+
+// NewUnicodeSentenceStrategy creates a UnicodeSentenceStrategy.
+func NewUnicodeSentenceStrategy(opts ...UnicodeSentenceOption) *UnicodeSentenceStrategy {
+	s := &UnicodeSentenceStrategy{
+		abbreviations: make(map[string]bool),
+	}
+	for _, abbr := range commonAbbreviations {
+		s.abbreviations[abbr] = true
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	lang := strings.ToLower(s.language)
+	if i := strings.IndexAny(lang, "-_"); i != -1 {
+		lang = lang[:i]
+	}
+	for _, abbr := range languageAbbreviations[lang] {
+		s.abbreviations[abbr] = true
+	}
+
+	return s
+}
+
+// Language returns the language hint, if any.
+func (s *UnicodeSentenceStrategy) Language() string {
+	return s.language
+}
+
+// Split implements SentenceSplitterStrategy.
+func (s *UnicodeSentenceStrategy) Split(text string) []string {
+	var sentences []string
+	start := 0
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+
+		if r == '\n' && isBlankLineAt(text, i+size) {
+			end := skipSpaces(text, i)
+			if strings.TrimSpace(text[start:i]) != "" {
+				sentences = append(sentences, text[start:end])
+				start = end
+			}
+			i = end
+			continue
+		}
+
+		if !isSentenceTerminal(r) {
+			i += size
+			continue
+		}
+
+		// Absorb runs like "?!", "..." and closing quotes or brackets.
+		end := i + size
+		for end < len(text) {
+			next, n := utf8.DecodeRuneInString(text[end:])
+			if !isSentenceTerminal(next) && !isSentenceCloser(next) {
+				break
+			}
+			end += n
+		}
+
+		if s.isBoundary(text, start, i, end) {
+			end = skipSpaces(text, end)
+			sentences = append(sentences, text[start:end])
+			start = end
+		}
+		i = end
+	}
+
+	if start < len(text) {
+		sentences = append(sentences, text[start:])
+	}
+	return sentences
+}
+
+// isBoundary reports whether the terminal run text[term:end] ends the
+// sentence that began at start.
+func (s *UnicodeSentenceStrategy) isBoundary(text string, start, term, end int) bool {
+	if end >= len(text) {
+		return true
+	}
+
+	run := text[term:end]
+	first, _ := utf8.DecodeRuneInString(run)
+	if first != '.' && first != '!' && first != '?' {
+		// Full-width and non-Latin terminals need no following space.
+		return true
+	}
+
+	next, _ := utf8.DecodeRuneInString(text[end:])
+	if !unicode.IsSpace(next) {
+		// "3.14", "example.com", "?!x".
+		return false
+	}
+	if strings.ContainsAny(run, "!?") {
+		return true
+	}
+
+	// A period followed by a lowercase word continues the sentence.
+	after := skipSpaces(text, end)
+	if after < len(text) {
+		nextWord, _ := utf8.DecodeRuneInString(text[after:])
+		if unicode.IsLower(nextWord) {
+			return false
+		}
+	}
+
+	if strings.HasPrefix(run, "..") {
+		// Ellipsis followed by a capitalised word.
+		return true
+	}
+
+	wordStart := strings.LastIndexFunc(text[start:term], unicode.IsSpace) + 1 + start
+	word := strings.TrimLeftFunc(text[wordStart:term], isSentenceOpener)
+	lower := strings.ToLower(word)
+
+	switch {
+	case word == "":
+		return true
+	case s.abbreviations[lower]:
+		return false
+	case isInitialism(word):
+		// "J." and "U.S."
+		return false
+	case wordStart == start && isDigits(word):
+		// Numbered list marker such as "1. ".
+		return false
+	}
+	return true
+}
+
+// isSentenceTerminal reports whether r can end a sentence.
+func isSentenceTerminal(r rune) bool {
+	switch r {
+	case '.', '!', '?',
+		'。', '！', '？', '｡', '．', // CJK
+		'‼', '⁇', '⁈', '⁉', // double punctuation
+		'؟', '۔', // Arabic, Urdu
+		'।', '॥', // Devanagari
+		'։',      // Armenian
+		'።',      // Ethiopic
+		'჻',      // Georgian
+		'။',      // Myanmar
+		'។',      // Khmer
+		'\u037e': // Greek question mark
+		return true
+	}
+	return false
+}
+
+// isSentenceCloser reports whether r is closing punctuation that stays with
+// the preceding sentence.
+func isSentenceCloser(r rune) bool {
+	switch r {
+	case '"', '\'', ')', ']', '}', '»', '›':
+		return true
+	}
+	return unicode.In(r, unicode.Pe, unicode.Pf)
+}
+
+// isSentenceOpener reports whether r is opening punctuation before a word.
+func isSentenceOpener(r rune) bool {
+	switch r {
+	case '"', '\'', '(', '[', '{', '«', '‹':
+		return true
+	}
+	return unicode.In(r, unicode.Ps, unicode.Pi)
+}
+
+// isInitialism reports whether word is a single uppercase letter or a run
+// of single letters separated by periods, such as "U.S".
+func isInitialism(word string) bool {
+	for i, part := range strings.Split(word, ".") {
+		r, n := utf8.DecodeRuneInString(part)
+		if n != len(part) || !unicode.IsLetter(r) {
+			return false
+		}
+		if i == 0 && !unicode.IsUpper(r) && !strings.Contains(word, ".") {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// isBlankLineAt reports whether text[i:] starts with optional horizontal
+// whitespace followed by a newline.
+func isBlankLineAt(text string, i int) bool {
+	for i < len(text) {
+		switch text[i] {
+		case ' ', '\t', '\r':
+			i++
+		case '\n':
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// skipSpaces returns the offset of the first non-space rune at or after i.
+func skipSpaces(text string, i int) int {
+	for i < len(text) {
+		r, n := utf8.DecodeRuneInString(text[i:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		i += n
+	}
+	return i
+}
+
+// WithLanguage switches the splitter to a UnicodeSentenceStrategy for the
+// given language, e.g. "en", "de" or "ja".
+func (s *SentenceSplitter) WithLanguage(language string) *SentenceSplitter {
+	s.SplitterStrategy = NewUnicodeSentenceStrategy(WithSentenceLanguage(language))
+	return s
+}
+
+// Ensure UnicodeSentenceStrategy implements SentenceSplitterStrategy.
+var _ SentenceSplitterStrategy = (*UnicodeSentenceStrategy)(nil)
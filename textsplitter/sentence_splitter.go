@@ -40,6 +40,13 @@ type SentenceSplitter struct {
 	onChunkingEnd   func(chunks []string)
 }
 
+// WithTokenizer sets the tokenizer used to size chunks, so ChunkSize and
+// ChunkOverlap are measured in that model's tokens.
+func (s *SentenceSplitter) WithTokenizer(tokenizer Tokenizer) *SentenceSplitter {
+	s.Tokenizer = tokenizer
+	return s
+}
+
 // WithOnChunkingStart sets the callback for when chunking starts.
 func (s *SentenceSplitter) WithOnChunkingStart(fn func(text []string)) *SentenceSplitter {
 	s.onChunkingStart = fn
@@ -233,71 +240,63 @@ func (s *SentenceSplitter) merge(splits []textSplit, chunkSize int) []string {
 		len  int
 	}
 	var curChunk []bufItem
-	var lastChunk []bufItem
 	curChunkLen := 0
 	newChunk := true
 
-	closeChunk := func() {
+	joinItems := func(items []bufItem) string {
 		var sb strings.Builder
-		for _, item := range curChunk {
+		for _, item := range items {
 			sb.WriteString(item.text)
 		}
-		chunks = append(chunks, sb.String())
+		return sb.String()
+	}
+
+	closeChunk := func() {
+		chunks = append(chunks, joinItems(curChunk))
 
-		lastChunk = curChunk
+		lastChunk := curChunk
 		curChunk = nil // reset
 		curChunkLen = 0
 		newChunk = true
 
-		// Add overlap from lastChunk
-		if len(lastChunk) > 0 {
-			lastIndex := len(lastChunk) - 1
-			for lastIndex >= 0 {
-				item := lastChunk[lastIndex]
-				if curChunkLen+item.len <= s.ChunkOverlap {
-					curChunkLen += item.len
-					// Prepend to curChunk
-					curChunk = append([]bufItem{item}, curChunk...)
-					lastIndex--
-				} else {
-					break
-				}
+		// Add overlap from lastChunk. The overlap is measured on the joined
+		// text, so ChunkOverlap is an exact token budget even for tokenizers
+		// that are not additive across split boundaries.
+		for i := len(lastChunk) - 1; i >= 0; i-- {
+			candidate := append([]bufItem{lastChunk[i]}, curChunk...)
+			if s.getTokenSize(joinItems(candidate)) > s.ChunkOverlap {
+				break
 			}
+			curChunk = candidate
+			curChunkLen += lastChunk[i].len
 		}
 	}
 
 	splitIdx := 0
 	for splitIdx < len(splits) {
 		curSplit := splits[splitIdx]
-		// if curSplit.tokenSize > chunkSize {
-		// 	// Should not happen if recursion worked, but safety check
-		// 	// In Python it raises ValueError.
-		// 	// We'll just force it in or panic.
-		// 	// For now, panic to be noticeable during dev.
-		// 	panic(fmt.Sprintf("Single token exceeded chunk size: %d > %d", curSplit.tokenSize, chunkSize))
-		// }
 
 		if curChunkLen+curSplit.tokenSize > chunkSize && !newChunk {
 			closeChunk()
-		} else {
-			// Add split if it fits or if it is a sentence or if it's a new chunk (must add at least one)
-			if curSplit.isSentence || curChunkLen+curSplit.tokenSize <= chunkSize || newChunk {
-				curChunkLen += curSplit.tokenSize
-				curChunk = append(curChunk, bufItem{text: curSplit.text, len: curSplit.tokenSize})
-				splitIdx++
-				newChunk = false
-			} else {
-				closeChunk()
-			}
+			continue
+		}
+
+		// Drop overlap that would push the first split of a chunk past the
+		// chunk size.
+		for newChunk && len(curChunk) > 0 && curChunkLen+curSplit.tokenSize > chunkSize {
+			curChunkLen -= curChunk[0].len
+			curChunk = curChunk[1:]
 		}
+
+		// Add the split; a new chunk always takes at least one split.
+		curChunkLen += curSplit.tokenSize
+		curChunk = append(curChunk, bufItem{text: curSplit.text, len: curSplit.tokenSize})
+		splitIdx++
+		newChunk = false
 	}
 
 	if !newChunk {
-		var sb strings.Builder
-		for _, item := range curChunk {
-			sb.WriteString(item.text)
-		}
-		chunks = append(chunks, sb.String())
+		chunks = append(chunks, joinItems(curChunk))
 	}
 
 	return chunks
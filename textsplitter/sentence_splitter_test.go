@@ -1,6 +1,7 @@
 package textsplitter
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/suite"
@@ -137,3 +138,92 @@ func (s *SentenceSplitterTestSuite) TestNeurosnapSplitterStrategy_Error() {
 	chunks := strategy.Split(text)
 	s.NotEmpty(chunks)
 }
+
+func (s *SentenceSplitterTestSuite) TestUnicodeSentenceStrategy() {
+	tests := []struct {
+		name     string
+		language string
+		input    string
+		want     []string
+	}{
+		{
+			name:  "abbreviations and decimals",
+			input: "Dr. Smith paid $3.50 on Jan. 5. He left.",
+			want:  []string{"Dr. Smith paid $3.50 on Jan. 5. ", "He left."},
+		},
+		{
+			name:  "initials and acronyms",
+			input: "J. R. R. Tolkien moved to the U.S. in 1925. Really?! Yes.",
+			want:  []string{"J. R. R. Tolkien moved to the U.S. in 1925. ", "Really?! ", "Yes."},
+		},
+		{
+			name:  "lowercase continuation",
+			input: "Use a tool, e.g. a hammer. See the docs... then rest.",
+			want:  []string{"Use a tool, e.g. a hammer. ", "See the docs... then rest."},
+		},
+		{
+			name:  "closing quotes stay with sentence",
+			input: `He said "Stop." Then he left.`,
+			want:  []string{`He said "Stop." `, "Then he left."},
+		},
+		{
+			name:  "numbered list",
+			input: "1. Install Go. 2. Run tests.",
+			want:  []string{"1. Install Go. ", "2. Run tests."},
+		},
+		{
+			name:  "urls and versions",
+			input: "Visit example.com for v1.2.3 notes. Done.",
+			want:  []string{"Visit example.com for v1.2.3 notes. ", "Done."},
+		},
+		{
+			name:  "cjk",
+			input: "今日は晴れです。明日は雨でしょう！本当？",
+			want:  []string{"今日は晴れです。", "明日は雨でしょう！", "本当？"},
+		},
+		{
+			name:  "cjk closing bracket",
+			input: "彼は「行く。」と言った。",
+			want:  []string{"彼は「行く。」", "と言った。"},
+		},
+		{
+			name:  "hindi",
+			input: "यह एक वाक्य है। यह दूसरा है।",
+			want:  []string{"यह एक वाक्य है। ", "यह दूसरा है।"},
+		},
+		{
+			name:     "german abbreviations",
+			language: "de",
+			input:    "Wir brauchen z.B. Äpfel bzw. Birnen. Danke.",
+			want:     []string{"Wir brauchen z.B. Äpfel bzw. Birnen. ", "Danke."},
+		},
+		{
+			name:  "blank line ends sentence",
+			input: "Heading\n\nBody text. More.",
+			want:  []string{"Heading\n\n", "Body text. ", "More."},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			strategy := NewUnicodeSentenceStrategy(WithSentenceLanguage(tt.language))
+			got := strategy.Split(tt.input)
+			s.Equal(tt.want, got)
+			s.Equal(tt.input, strings.Join(got, ""))
+		})
+	}
+}
+
+func (s *SentenceSplitterTestSuite) TestUnicodeSentenceStrategy_LanguageHint() {
+	text := "Voir le chap. Deux. Fin."
+	s.Len(NewUnicodeSentenceStrategy().Split(text), 3)
+	s.Len(NewUnicodeSentenceStrategy(WithSentenceLanguage("fr-CA")).Split(text), 2)
+	s.Len(NewUnicodeSentenceStrategy(WithAbbreviations("chap.")).Split(text), 2)
+}
+
+func (s *SentenceSplitterTestSuite) TestSplitText_WithLanguage() {
+	splitter := NewSentenceSplitter(6, 0, nil, nil).WithLanguage("en")
+	chunks := splitter.SplitText("Mr. Smith went to Washington. He bought a 5.5 in. display.")
+
+	s.Equal([]string{"Mr. Smith went to Washington.", "He bought a 5.5 in. display."}, chunks)
+}
@@ -3,6 +3,7 @@ package textsplitter
 import (
 	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -92,6 +93,29 @@ func TestTokenTextSplitter_Overlap(t *testing.T) {
 	require.Greater(t, len(chunks), 1)
 }
 
+// runeTokenizer treats every rune as a token.
+type runeTokenizer struct{}
+
+func (runeTokenizer) Encode(text string) []string {
+	return strings.Split(text, "")
+}
+
+func TestTokenTextSplitter_OversizedMultiByte(t *testing.T) {
+	splitter := NewTokenTextSplitterWithTokenizer(10, 3, runeTokenizer{})
+
+	text := strings.Repeat("a日b本c語", 8)
+	chunks := splitter.SplitText(text)
+
+	require.Greater(t, len(chunks), 1)
+	for _, chunk := range chunks {
+		assert.True(t, utf8.ValidString(chunk), "chunk %q splits a rune", chunk)
+	}
+}
+
+func TestSplitByChar_KeepsGraphemes(t *testing.T) {
+	assert.Equal(t, []string{"e\u0301", "🇯🇵", "a"}, SplitByChar()("e\u0301🇯🇵a"))
+}
+
 // ============================================================================
 // MarkdownSplitter Tests
 // ============================================================================
@@ -356,6 +380,145 @@ func TestSentenceWindowSplitter_WithCustomSplitter(t *testing.T) {
 	require.NotEmpty(t, windows)
 }
 
+// ============================================================================
+// CodeSplitter Tests
+// ============================================================================
+
+const goSource = `package shapes
+
+import "math"
+
+// Circle is a circle.
+type Circle struct {
+	Radius float64
+}
+
+// Area returns the area of the circle.
+func (c Circle) Area() float64 {
+	return math.Pi * c.Radius * c.Radius
+}
+
+// Perimeter returns the perimeter of the circle.
+func (c Circle) Perimeter() float64 {
+	return 2 * math.Pi * c.Radius
+}
+`
+
+func TestCodeSplitter_Go(t *testing.T) {
+	splitter := NewCodeSplitterForFile("shapes.go", 130)
+	chunks := splitter.SplitText(goSource)
+
+	require.Len(t, chunks, 3)
+	assert.True(t, strings.HasPrefix(chunks[0], "package shapes"))
+	assert.Contains(t, chunks[0], "type Circle struct")
+	assert.True(t, strings.HasPrefix(chunks[1], "// Area returns"))
+	assert.True(t, strings.HasSuffix(chunks[1], "}"))
+	assert.True(t, strings.HasPrefix(chunks[2], "// Perimeter returns"))
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, utf8.RuneCountInString(chunk), 130)
+	}
+
+	// Everything fits in one chunk.
+	assert.Len(t, NewCodeSplitter(CodeLanguageGo, 0).SplitText(goSource), 1)
+}
+
+func TestCodeSplitter_PythonClassMethods(t *testing.T) {
+	source := `import os
+
+
+class Store:
+    """A key-value store."""
+
+    def __init__(self, path):
+        self.path = path
+        self.data = {}
+
+    @property
+    def size(self):
+        return len(self.data)
+
+    def load(self):
+        with open(self.path) as f:
+            self.data = dict(l.split("=", 1) for l in f)
+
+
+def main():
+    Store(os.getcwd()).load()
+`
+	chunks := NewCodeSplitter(CodeLanguagePython, 135).SplitText(source)
+
+	var starts []string
+	for _, chunk := range chunks {
+		starts = append(starts, strings.SplitN(strings.TrimSpace(chunk), "\n", 2)[0])
+		assert.LessOrEqual(t, utf8.RuneCountInString(chunk), 135)
+	}
+	assert.Equal(t, []string{
+		"import os",
+		"@property",
+		"def load(self):",
+		"def main():",
+	}, starts)
+	assert.Contains(t, chunks[0], "def __init__(self, path):")
+}
+
+func TestCodeSplitter_JavaScriptAndJava(t *testing.T) {
+	js := `const express = require("express");
+
+export async function getUser(id) {
+  const res = await fetch("/users/" + id);
+  return res.json();
+}
+
+export const listUsers = async () => {
+  const res = await fetch("/users");
+  return res.json();
+};
+`
+	chunks := NewCodeSplitterForFile("api.ts", 110).SplitText(js)
+	require.Len(t, chunks, 3)
+	assert.True(t, strings.HasPrefix(chunks[1], "export async function getUser"))
+	assert.True(t, strings.HasPrefix(chunks[2], "export const listUsers"))
+
+	java := `package demo;
+
+public class Greeter {
+    private final String name;
+
+    public Greeter(String name) {
+        this.name = name;
+    }
+
+    @Override
+    public String toString() {
+        if (name == null) {
+            return "anonymous";
+        }
+        return "Greeter(" + name + ")";
+    }
+}
+`
+	chunks = NewCodeSplitterForFile("Greeter.java", 170).SplitText(java)
+	require.Len(t, chunks, 2)
+	assert.Contains(t, chunks[0], "public Greeter(String name)")
+	assert.True(t, strings.HasPrefix(chunks[1], "    @Override"))
+	assert.True(t, strings.HasSuffix(chunks[1], "}\n}"))
+}
+
+func TestCodeSplitter_Fallback(t *testing.T) {
+	lang, ok := DetectCodeLanguage("notes.txt")
+	assert.False(t, ok)
+	assert.Empty(t, lang)
+
+	text := strings.Repeat("line of text\n", 10) + strings.Repeat("x", 50)
+	chunks := NewCodeSplitterForFile("notes.txt", 40).SplitText(text)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, utf8.RuneCountInString(chunk), 40)
+	}
+	assert.Equal(t, strings.ReplaceAll(text, "\n", ""), strings.ReplaceAll(strings.Join(chunks, ""), "\n", ""))
+
+	assert.Empty(t, NewCodeSplitter(CodeLanguageGo, 10).SplitText("  \n"))
+}
+
 // ============================================================================
 // Interface Compliance Tests
 // ============================================================================
@@ -366,6 +529,7 @@ func TestTextSplitterInterface(t *testing.T) {
 	var _ TextSplitter = &MarkdownSplitter{}
 	var _ TextSplitter = &SentenceWindowSplitter{}
 	var _ TextSplitter = &SentenceSplitter{}
+	var _ TextSplitter = &CodeSplitter{}
 }
 
 // ============================================================================
@@ -419,3 +583,58 @@ func TestSplitters_Unicode(t *testing.T) {
 	require.NotEmpty(t, tokenChunks)
 	require.NotEmpty(t, mdChunks)
 }
+
+// ============================================================================
+// Token-Based Sizing Tests
+// ============================================================================
+
+func TestSplitters_TokenSizing(t *testing.T) {
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 20)
+	tokenizer := runeTokenizer{}
+	chunkSize := 60
+
+	splitters := map[string]TextSplitter{
+		"sentence": NewSentenceSplitter(chunkSize, 20, nil, nil).WithTokenizer(tokenizer),
+		"token":    NewTokenTextSplitter(chunkSize, 20).WithTokenizer(tokenizer),
+		"markdown": NewMarkdownSplitter(chunkSize, 20).WithTokenizer(tokenizer),
+	}
+
+	for name, splitter := range splitters {
+		t.Run(name, func(t *testing.T) {
+			chunks := splitter.SplitText(text)
+			require.Greater(t, len(chunks), 1)
+			for _, chunk := range chunks {
+				assert.LessOrEqual(t, len(tokenizer.Encode(chunk)), chunkSize, "chunk %q", chunk)
+			}
+		})
+	}
+}
+
+func TestTokenTextSplitter_OverlapWithinBudget(t *testing.T) {
+	splitter := NewTokenTextSplitter(5, 3).WithTokenizer(runeTokenizer{})
+
+	chunks := splitter.SplitText("aa bb cc dd ee")
+
+	// "aa bb" is 5 tokens; the overlap "bb" is 2 tokens, since adding the
+	// separator would exceed the 3-token budget.
+	assert.Equal(t, []string{"aa bb", "bb cc", "cc dd", "dd ee"}, chunks)
+}
+
+func TestMarkdownSplitter_Overlap(t *testing.T) {
+	text := "# One\nalpha beta\n# Two\ngamma delta\n# Three\nepsilon zeta\n"
+	splitter := NewMarkdownSplitter(8, 4)
+
+	chunks := splitter.SplitText(text)
+
+	require.Len(t, chunks, 2)
+	assert.Equal(t, "# One\nalpha beta\n# Two\ngamma delta", chunks[0])
+	assert.Equal(t, "# Two\ngamma delta\n# Three\nepsilon zeta", chunks[1])
+}
+
+func TestMarkdownSplitter_LongLine(t *testing.T) {
+	splitter := NewMarkdownSplitter(5, 0)
+
+	chunks := splitter.SplitText(strings.Repeat("word ", 12))
+
+	assert.Equal(t, []string{"word word word word word", "word word word word word", "word word"}, chunks)
+}
@@ -0,0 +1,57 @@
+package textsplitter
+
+import (
+	"strings"
+
+	"github.com/aqua777/go-llamaindex/textutil"
+)
+
+// tokenPrefix returns the longest prefix of text, cut on a rune boundary,
+// that encodes to at most maxTokens tokens. The examined window grows
+// exponentially so long texts are not re-tokenized in full for every chunk.
+func tokenPrefix(text string, tokenizer Tokenizer, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	window := maxTokens * 4
+	if window < 64 {
+		window = 64
+	}
+	for window < len(text) {
+		candidate := textutil.TruncateBytes(text, window)
+		if len(tokenizer.Encode(candidate)) > maxTokens {
+			return textutil.TruncateTokens(candidate, tokenizer, maxTokens)
+		}
+		window *= 2
+	}
+	return textutil.TruncateTokens(text, tokenizer, maxTokens)
+}
+
+// splitByTokenWindows splits text into consecutive windows of at most
+// chunkSize tokens, each starting chunkOverlap tokens before the end of the
+// previous one. It is the last-resort split for text without usable
+// separators, and cuts on rune boundaries.
+func splitByTokenWindows(text string, tokenizer Tokenizer, chunkSize, chunkOverlap int) []string {
+	var chunks []string
+	for text != "" {
+		chunk := tokenPrefix(text, tokenizer, chunkSize)
+		if chunk == "" {
+			// A single rune encodes to more than chunkSize tokens; emit it
+			// anyway so the split always makes progress.
+			chunk = textutil.TruncateWith(text, 1, "")
+		}
+		if trimmed := strings.TrimSpace(chunk); trimmed != "" {
+			chunks = append(chunks, trimmed)
+		}
+		if len(chunk) == len(text) {
+			break
+		}
+
+		step := len(tokenPrefix(chunk, tokenizer, chunkSize-chunkOverlap))
+		if step == 0 {
+			step = len(chunk)
+		}
+		text = text[step:]
+	}
+	return chunks
+}
@@ -53,6 +53,13 @@ func NewTokenTextSplitterWithTokenizer(chunkSize, chunkOverlap int, tokenizer To
 	}
 }
 
+// WithTokenizer sets the tokenizer used to size chunks, so ChunkSize and
+// ChunkOverlap are measured in that model's tokens.
+func (s *TokenTextSplitter) WithTokenizer(tokenizer Tokenizer) *TokenTextSplitter {
+	s.Tokenizer = tokenizer
+	return s
+}
+
 // WithSeparator sets a custom separator.
 func (s *TokenTextSplitter) WithSeparator(sep string) *TokenTextSplitter {
 	s.Separator = sep
@@ -163,8 +170,12 @@ func (s *TokenTextSplitter) mergeSplits(splits []string) []string {
 			if len(currentChunk) > 0 {
 				chunks = append(chunks, s.joinChunk(currentChunk, separator))
 
-				// Handle overlap
-				currentChunk, currentTokens = s.getOverlapChunk(currentChunk, separator)
+				// Handle overlap, dropping leading parts that would push the
+				// new chunk past the chunk size.
+				currentChunk, _ = s.getOverlapChunk(currentChunk, separator)
+				for len(currentChunk) > 0 && s.tokenLength(s.joinChunk(currentChunk, separator)+separator+split) > s.ChunkSize {
+					currentChunk = currentChunk[1:]
+				}
 			}
 		}
 
@@ -182,40 +193,7 @@ func (s *TokenTextSplitter) mergeSplits(splits []string) []string {
 
 // splitOversized splits a single oversized piece into smaller chunks.
 func (s *TokenTextSplitter) splitOversized(text string) []string {
-	// Split by characters as last resort
-	tokens := s.Tokenizer.Encode(text)
-	var chunks []string
-
-	for i := 0; i < len(tokens); i += s.ChunkSize - s.ChunkOverlap {
-		end := i + s.ChunkSize
-		if end > len(tokens) {
-			end = len(tokens)
-		}
-
-		// We need to reconstruct text from tokens
-		// Since our tokenizer returns string representations, we approximate
-		// by splitting the original text proportionally
-		startRatio := float64(i) / float64(len(tokens))
-		endRatio := float64(end) / float64(len(tokens))
-
-		startChar := int(startRatio * float64(len(text)))
-		endChar := int(endRatio * float64(len(text)))
-
-		if endChar > len(text) {
-			endChar = len(text)
-		}
-
-		chunk := text[startChar:endChar]
-		if chunk != "" {
-			chunks = append(chunks, strings.TrimSpace(chunk))
-		}
-
-		if end >= len(tokens) {
-			break
-		}
-	}
-
-	return chunks
+	return splitByTokenWindows(text, s.Tokenizer, s.ChunkSize, s.ChunkOverlap)
 }
 
 // getOverlapChunk returns the overlap portion of the current chunk.
@@ -228,19 +206,17 @@ func (s *TokenTextSplitter) getOverlapChunk(chunk []string, separator string) ([
 	var overlapChunk []string
 	overlapTokens := 0
 
+	// Measure the joined text rather than summing parts, since tokenizers
+	// are not additive across separators.
 	for i := len(chunk) - 1; i >= 0; i-- {
-		part := chunk[i]
-		partTokens := s.tokenLength(part)
-
-		if overlapTokens+partTokens > s.ChunkOverlap {
+		candidate := append([]string{chunk[i]}, overlapChunk...)
+		candidateTokens := s.tokenLength(s.joinChunk(candidate, separator))
+		if candidateTokens > s.ChunkOverlap {
 			break
 		}
 
-		overlapChunk = append([]string{part}, overlapChunk...)
-		overlapTokens += partTokens
-		if len(overlapChunk) > 1 {
-			overlapTokens += s.tokenLength(separator)
-		}
+		overlapChunk = candidate
+		overlapTokens = candidateTokens
 	}
 
 	return overlapChunk, overlapTokens
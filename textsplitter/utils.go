@@ -3,6 +3,8 @@ package textsplitter
 import (
 	"regexp"
 	"strings"
+
+	"github.com/aqua777/go-llamaindex/textutil"
 )
 
 // SplitTextKeepSeparator splits text with separator and keeps the separator at the start of each split (except the first).
@@ -44,10 +46,9 @@ func SplitByRegex(regexStr string) func(string) []string {
 	}
 }
 
-// SplitByChar returns a function that splits text into characters.
+// SplitByChar returns a function that splits text into user-perceived
+// characters, keeping combining marks and emoji sequences intact.
 func SplitByChar() func(string) []string {
-	return func(text string) []string {
-		return strings.Split(text, "")
-	}
+	return textutil.Graphemes
 }
 
@@ -0,0 +1,176 @@
+// Package textutil provides Unicode-safe helpers for measuring and
+// truncating text. Slicing a string by byte offset, as in s[:n], can split a
+// multi-byte rune and produce invalid UTF-8; the helpers here always cut on
+// rune or grapheme boundaries.
+package textutil
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Ellipsis is the suffix appended by Truncate.
+const Ellipsis = "..."
+
+// Truncate shortens s to at most maxRunes runes, appending Ellipsis when
+// text was removed. It is the rune-safe replacement for the common
+// s[:maxLen] + "..." idiom.
+func Truncate(s string, maxRunes int) string {
+	return TruncateWith(s, maxRunes, Ellipsis)
+}
+
+// TruncateWith shortens s to at most maxRunes runes, appending suffix when
+// text was removed. The suffix is not counted against maxRunes.
+func TruncateWith(s string, maxRunes int, suffix string) string {
+	if maxRunes < 0 {
+		maxRunes = 0
+	}
+	if len(s) <= maxRunes {
+		return s
+	}
+	count := 0
+	for i := range s {
+		if count == maxRunes {
+			return s[:i] + suffix
+		}
+		count++
+	}
+	return s
+}
+
+// TruncateBytes returns the longest prefix of s that is at most maxBytes
+// long without splitting a rune.
+func TruncateBytes(s string, maxBytes int) string {
+	return s[:FloorRuneBoundary(s, maxBytes)]
+}
+
+// TailBytes returns the longest suffix of s that is at most maxBytes long
+// without splitting a rune.
+func TailBytes(s string, maxBytes int) string {
+	if maxBytes < 0 {
+		maxBytes = 0
+	}
+	if maxBytes >= len(s) {
+		return s
+	}
+	return s[CeilRuneBoundary(s, len(s)-maxBytes):]
+}
+
+// FloorRuneBoundary returns the largest rune boundary in s that is <= i.
+// Offsets outside s are clamped.
+func FloorRuneBoundary(s string, i int) int {
+	if i <= 0 {
+		return 0
+	}
+	if i >= len(s) {
+		return len(s)
+	}
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return i
+}
+
+// CeilRuneBoundary returns the smallest rune boundary in s that is >= i.
+// Offsets outside s are clamped.
+func CeilRuneBoundary(s string, i int) int {
+	if i <= 0 {
+		return 0
+	}
+	for i < len(s) && !utf8.RuneStart(s[i]) {
+		i++
+	}
+	if i > len(s) {
+		return len(s)
+	}
+	return i
+}
+
+// RuneLen returns the number of runes in s.
+func RuneLen(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// Graphemes splits s into user-perceived characters. Combining marks,
+// variation selectors, emoji modifiers and zero-width-joiner sequences stay
+// attached to their base character, regional-indicator pairs (flags) form
+// one character, and "\r\n" is kept together. This covers the cases that
+// matter for display and splitting; it is not a full UAX #29
+// implementation.
+func Graphemes(s string) []string {
+	var out []string
+	for len(s) > 0 {
+		n := nextGrapheme(s)
+		out = append(out, s[:n])
+		s = s[n:]
+	}
+	return out
+}
+
+// GraphemeLen returns the number of user-perceived characters in s, as
+// split by Graphemes.
+func GraphemeLen(s string) int {
+	count := 0
+	for len(s) > 0 {
+		s = s[nextGrapheme(s):]
+		count++
+	}
+	return count
+}
+
+// TruncateGraphemes shortens s to at most maxGraphemes user-perceived
+// characters, appending suffix when text was removed.
+func TruncateGraphemes(s string, maxGraphemes int, suffix string) string {
+	offset := 0
+	for count := 0; offset < len(s); count++ {
+		if count == maxGraphemes {
+			return s[:offset] + suffix
+		}
+		offset += nextGrapheme(s[offset:])
+	}
+	return s
+}
+
+// nextGrapheme returns the byte length of the first grapheme in s.
+func nextGrapheme(s string) int {
+	r, n := utf8.DecodeRuneInString(s)
+	if r == '\r' && len(s) > n && s[n] == '\n' {
+		return n + 1
+	}
+	if r == '\r' || r == '\n' {
+		return n
+	}
+
+	regional := isRegionalIndicator(r)
+	joined := false
+	for n < len(s) {
+		next, size := utf8.DecodeRuneInString(s[n:])
+		switch {
+		case joined:
+			joined = false
+		case regional && isRegionalIndicator(next):
+			regional = false
+		case isExtender(next):
+		case next == zeroWidthJoiner:
+			joined = true
+		default:
+			return n
+		}
+		n += size
+	}
+	return n
+}
+
+const zeroWidthJoiner = '\u200d'
+
+// isExtender reports whether r attaches to the preceding character.
+func isExtender(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		(r >= 0xFE00 && r <= 0xFE0F) || // variation selectors
+		(r >= 0x1F3FB && r <= 0x1F3FF) || // emoji skin tone modifiers
+		(r >= 0xE0020 && r <= 0xE007F) // emoji tag sequences
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
@@ -0,0 +1,77 @@
+package textutil
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "hello", Truncate("hello", 5))
+	assert.Equal(t, "hel...", Truncate("hello", 3))
+	assert.Equal(t, "日本...", Truncate("日本語テキスト", 2))
+	assert.Equal(t, "...", Truncate("abc", 0))
+	assert.Equal(t, "ab~", TruncateWith("abc", 2, "~"))
+}
+
+func TestTruncateBytes(t *testing.T) {
+	s := "añb" // ñ is two bytes
+	assert.Equal(t, "a", TruncateBytes(s, 2))
+	assert.Equal(t, "añ", TruncateBytes(s, 3))
+	assert.Equal(t, s, TruncateBytes(s, 10))
+	assert.Equal(t, "", TruncateBytes(s, -1))
+
+	assert.Equal(t, "b", TailBytes(s, 2))
+	assert.Equal(t, "ñb", TailBytes(s, 3))
+	assert.Equal(t, s, TailBytes(s, 10))
+
+	for i := 0; i <= len("日本語"); i++ {
+		assert.True(t, utf8.ValidString(TruncateBytes("日本語", i)))
+		assert.True(t, utf8.ValidString(TailBytes("日本語", i)))
+	}
+}
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"ascii", "abc", []string{"a", "b", "c"}},
+		{"combining mark", "e\u0301x", []string{"e\u0301", "x"}},
+		{"flag", "🇯🇵🇫🇷", []string{"🇯🇵", "🇫🇷"}},
+		{"skin tone", "👍🏽!", []string{"👍🏽", "!"}},
+		{"zwj sequence", "👩\u200d💻a", []string{"👩\u200d💻", "a"}},
+		{"crlf", "a\r\nb", []string{"a", "\r\n", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Graphemes(tt.input))
+			assert.Equal(t, len(tt.want), GraphemeLen(tt.input))
+		})
+	}
+
+	assert.Equal(t, "e\u0301…", TruncateGraphemes("e\u0301e\u0301", 1, "…"))
+	assert.Equal(t, "ab", TruncateGraphemes("ab", 2, "…"))
+}
+
+type wordTokenizer struct{}
+
+func (wordTokenizer) Encode(text string) []string {
+	return strings.Fields(text)
+}
+
+func TestTokens(t *testing.T) {
+	s := "one two three four"
+	tok := wordTokenizer{}
+
+	assert.Equal(t, 4, TokenLen(s, tok))
+	assert.Equal(t, "one two ", TruncateTokens(s, tok, 2))
+	assert.Equal(t, s, TruncateTokens(s, tok, 10))
+	assert.Equal(t, "", TruncateTokens(s, tok, 0))
+	assert.Equal(t, "three ", SliceTokens(s, tok, 2, 3))
+	assert.Equal(t, "", SliceTokens(s, tok, 3, 2))
+}
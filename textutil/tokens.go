@@ -0,0 +1,66 @@
+package textutil
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// Tokenizer counts tokens. It matches textsplitter.Tokenizer, so any
+// tokenizer from that package can be used.
+type Tokenizer interface {
+	Encode(text string) []string
+}
+
+// TokenLen returns the number of tokens in s.
+func TokenLen(s string, tokenizer Tokenizer) int {
+	return len(tokenizer.Encode(s))
+}
+
+// TruncateTokens returns the longest prefix of s, cut on a rune boundary,
+// that encodes to at most maxTokens tokens.
+func TruncateTokens(s string, tokenizer Tokenizer, maxTokens int) string {
+	return s[:tokenOffset(s, tokenizer, maxTokens)]
+}
+
+// SliceTokens returns the text spanning tokens [start, end) of s. Tokenizers
+// only report token counts, so the offsets are found by searching for the
+// longest prefixes that encode to start and end tokens; the result is
+// always cut on rune boundaries.
+func SliceTokens(s string, tokenizer Tokenizer, start, end int) string {
+	if end <= start {
+		return ""
+	}
+	from := tokenOffset(s, tokenizer, start)
+	to := tokenOffset(s, tokenizer, end)
+	if to < from {
+		return ""
+	}
+	return s[from:to]
+}
+
+// tokenOffset returns the byte offset of the longest prefix of s that
+// encodes to at most n tokens.
+func tokenOffset(s string, tokenizer Tokenizer, n int) int {
+	if n <= 0 {
+		return 0
+	}
+	if len(tokenizer.Encode(s)) <= n {
+		return len(s)
+	}
+
+	// Binary search over rune boundaries; token counts grow monotonically
+	// with prefix length for practical tokenizers.
+	boundaries := make([]int, 0, utf8.RuneCountInString(s)+1)
+	for i := range s {
+		boundaries = append(boundaries, i)
+	}
+	boundaries = append(boundaries, len(s))
+
+	i := sort.Search(len(boundaries), func(i int) bool {
+		return len(tokenizer.Encode(s[:boundaries[i]])) > n
+	})
+	if i == 0 {
+		return 0
+	}
+	return boundaries[i-1]
+}
@@ -0,0 +1,523 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// DefaultCodeInterpreterToolName is the default name for the code interpreter tool.
+	DefaultCodeInterpreterToolName = "code_interpreter"
+	// DefaultCodeInterpreterToolDescription is the default description for the code interpreter tool.
+	DefaultCodeInterpreterToolDescription = `Runs a small arithmetic program and returns the value of every variable. One statement per line, either "name = expression" or a bare expression. Supports + - * / % ^, parentheses, the constants pi and e, and the functions abs, sqrt, round, floor, ceil, min, max, pow, log, log10, exp, sum and avg.`
+	// DefaultCodeInterpreterMaxStatements is the default statement limit per program.
+	DefaultCodeInterpreterMaxStatements = 100
+	// ProgramResultVariable is the variable that holds the value of a trailing
+	// bare expression.
+	ProgramResultVariable = "result"
+)
+
+// ProgramResult is the outcome of running a program.
+type ProgramResult struct {
+	// Variables holds the value of every assigned variable.
+	Variables map[string]float64
+	// Order lists the variables in the order they were first assigned.
+	Order []string
+}
+
+// Value returns the value of a variable.
+func (r *ProgramResult) Value(name string) (float64, bool) {
+	v, ok := r.Variables[name]
+	return v, ok
+}
+
+// String lists the variables as "name = value" lines.
+func (r *ProgramResult) String() string {
+	lines := make([]string, len(r.Order))
+	for i, name := range r.Order {
+		lines[i] = name + " = " + FormatNumber(r.Variables[name])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FormatNumber formats a program value without floating point noise.
+func FormatNumber(v float64) string {
+	if math.Abs(v) >= 1e15 || (v != 0 && math.Abs(v) < 1e-9) {
+		return strconv.FormatFloat(v, 'g', 12, 64)
+	}
+	return strconv.FormatFloat(math.Round(v*1e9)/1e9, 'f', -1, 64)
+}
+
+// CodeInterpreterTool runs programs in a restricted arithmetic expression
+// language. Programs cannot loop, call out or touch the host, which makes
+// the tool safe to expose to LLM-written code.
+type CodeInterpreterTool struct {
+	*BaseTool
+	maxStatements int
+}
+
+// CodeInterpreterToolOption configures a CodeInterpreterTool.
+type CodeInterpreterToolOption func(*CodeInterpreterTool)
+
+// WithCodeInterpreterToolName sets the tool name.
+func WithCodeInterpreterToolName(name string) CodeInterpreterToolOption {
+	return func(t *CodeInterpreterTool) {
+		t.metadata.Name = name
+	}
+}
+
+// WithCodeInterpreterToolDescription sets the tool description.
+func WithCodeInterpreterToolDescription(description string) CodeInterpreterToolOption {
+	return func(t *CodeInterpreterTool) {
+		t.metadata.Description = description
+	}
+}
+
+// WithCodeInterpreterMaxStatements sets the maximum number of statements
+// per program.
+func WithCodeInterpreterMaxStatements(n int) CodeInterpreterToolOption {
+	return func(t *CodeInterpreterTool) {
+		t.maxStatements = n
+	}
+}
+
+// NewCodeInterpreterTool creates a new CodeInterpreterTool.
+func NewCodeInterpreterTool(opts ...CodeInterpreterToolOption) *CodeInterpreterTool {
+	t := &CodeInterpreterTool{
+		BaseTool: NewBaseTool(&ToolMetadata{
+			Name:        DefaultCodeInterpreterToolName,
+			Description: DefaultCodeInterpreterToolDescription,
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"code": map[string]interface{}{
+						"type":        "string",
+						"description": "The program to run",
+					},
+				},
+				"required": []string{"code"},
+			},
+		}),
+		maxStatements: DefaultCodeInterpreterMaxStatements,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// Call runs the program given as a string or in the "code" (or "input")
+// field of a map.
+func (t *CodeInterpreterTool) Call(ctx context.Context, input interface{}) (*ToolOutput, error) {
+	var code string
+	var rawInput map[string]interface{}
+	switch v := input.(type) {
+	case string:
+		code = v
+		rawInput = map[string]interface{}{"code": v}
+	case map[string]interface{}:
+		rawInput = v
+		if c, ok := v["code"].(string); ok {
+			code = c
+		} else if c, ok := v["input"].(string); ok {
+			code = c
+		}
+	default:
+		err := fmt.Errorf("unsupported input type: %T", input)
+		return NewErrorToolOutput(t.metadata.Name, err), err
+	}
+
+	result, err := t.Execute(code)
+	if err != nil {
+		return NewErrorToolOutput(t.metadata.Name, err), err
+	}
+	return NewToolOutputWithInput(t.metadata.Name, result.String(), rawInput, result), nil
+}
+
+// Execute runs a program. A trailing bare expression is stored in the
+// ProgramResultVariable variable.
+func (t *CodeInterpreterTool) Execute(code string) (*ProgramResult, error) {
+	result := &ProgramResult{Variables: make(map[string]float64)}
+
+	statements := 0
+	for lineNo, line := range strings.Split(code, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		for _, stmt := range strings.Split(line, ";") {
+			stmt = strings.TrimSpace(stmt)
+			if stmt == "" {
+				continue
+			}
+			statements++
+			if t.maxStatements > 0 && statements > t.maxStatements {
+				return nil, fmt.Errorf("program exceeds %d statements", t.maxStatements)
+			}
+
+			name, expr := ProgramResultVariable, stmt
+			if i := strings.Index(stmt, "="); i > 0 && isIdentifier(strings.TrimSpace(stmt[:i])) {
+				name, expr = strings.TrimSpace(stmt[:i]), stmt[i+1:]
+			}
+			if _, ok := programConstants[name]; ok {
+				return nil, fmt.Errorf("line %d: cannot assign to constant %s", lineNo+1, name)
+			}
+
+			value, err := evalExpression(expr, result.Variables)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo+1, err)
+			}
+			if _, ok := result.Variables[name]; !ok {
+				result.Order = append(result.Order, name)
+			}
+			result.Variables[name] = value
+		}
+	}
+
+	if statements == 0 {
+		return nil, fmt.Errorf("empty program")
+	}
+	return result, nil
+}
+
+// programConstants are the predefined, read-only names.
+var programConstants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+// programFunctions are the callable functions with their arity; -1 means
+// one or more arguments.
+var programFunctions = map[string]struct {
+	arity []int
+	fn    func(args []float64) (float64, error)
+}{
+	"abs":   {[]int{1}, func(a []float64) (float64, error) { return math.Abs(a[0]), nil }},
+	"floor": {[]int{1}, func(a []float64) (float64, error) { return math.Floor(a[0]), nil }},
+	"ceil":  {[]int{1}, func(a []float64) (float64, error) { return math.Ceil(a[0]), nil }},
+	"exp":   {[]int{1}, func(a []float64) (float64, error) { return math.Exp(a[0]), nil }},
+	"pow":   {[]int{2}, func(a []float64) (float64, error) { return math.Pow(a[0], a[1]), nil }},
+	"sqrt": {[]int{1}, func(a []float64) (float64, error) {
+		if a[0] < 0 {
+			return 0, fmt.Errorf("sqrt of negative number")
+		}
+		return math.Sqrt(a[0]), nil
+	}},
+	"round": {[]int{1, 2}, func(a []float64) (float64, error) {
+		if len(a) == 1 {
+			return math.Round(a[0]), nil
+		}
+		scale := math.Pow(10, math.Round(a[1]))
+		return math.Round(a[0]*scale) / scale, nil
+	}},
+	"log": {[]int{1, 2}, func(a []float64) (float64, error) {
+		if a[0] <= 0 {
+			return 0, fmt.Errorf("log of non-positive number")
+		}
+		if len(a) == 1 {
+			return math.Log(a[0]), nil
+		}
+		if a[1] <= 0 || a[1] == 1 {
+			return 0, fmt.Errorf("invalid log base")
+		}
+		return math.Log(a[0]) / math.Log(a[1]), nil
+	}},
+	"log10": {[]int{1}, func(a []float64) (float64, error) {
+		if a[0] <= 0 {
+			return 0, fmt.Errorf("log10 of non-positive number")
+		}
+		return math.Log10(a[0]), nil
+	}},
+	"min": {[]int{-1}, func(a []float64) (float64, error) {
+		v := a[0]
+		for _, x := range a[1:] {
+			v = math.Min(v, x)
+		}
+		return v, nil
+	}},
+	"max": {[]int{-1}, func(a []float64) (float64, error) {
+		v := a[0]
+		for _, x := range a[1:] {
+			v = math.Max(v, x)
+		}
+		return v, nil
+	}},
+	"sum": {[]int{-1}, func(a []float64) (float64, error) {
+		var total float64
+		for _, v := range a {
+			total += v
+		}
+		return total, nil
+	}},
+	"avg": {[]int{-1}, func(a []float64) (float64, error) {
+		var total float64
+		for _, v := range a {
+			total += v
+		}
+		return total / float64(len(a)), nil
+	}},
+}
+
+// exprParser is a recursive descent parser that evaluates as it parses.
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/" | "%") unary }
+//	unary   = ("+" | "-") unary | power
+//	power   = primary [ ("^" | "**") unary ]
+//	primary = number | name | name "(" expr { "," expr } ")" | "(" expr ")"
+type exprParser struct {
+	input string
+	pos   int
+	vars  map[string]float64
+}
+
+// evalExpression evaluates a single expression against vars.
+func evalExpression(input string, vars map[string]float64) (float64, error) {
+	p := &exprParser{input: input, vars: vars}
+	v, err := p.expr()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q", p.input[p.pos:])
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("result is not a finite number")
+	}
+	return v, nil
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// accept consumes tok if it is next.
+func (p *exprParser) accept(tok string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.input[p.pos:], tok) {
+		p.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expr() (float64, error) {
+	v, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch {
+		case p.accept("+"):
+			r, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			v += r
+		case p.accept("-"):
+			r, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			v -= r
+		default:
+			return v, nil
+		}
+	}
+}
+
+func (p *exprParser) term() (float64, error) {
+	v, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		var op byte
+		switch {
+		case p.accept("*"):
+			op = '*'
+		case p.accept("/"):
+			op = '/'
+		case p.accept("%"):
+			op = '%'
+		default:
+			return v, nil
+		}
+		r, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case '*':
+			v *= r
+		case '/':
+			if r == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			v /= r
+		case '%':
+			if r == 0 {
+				return 0, fmt.Errorf("modulo by zero")
+			}
+			v = math.Mod(v, r)
+		}
+	}
+}
+
+func (p *exprParser) unary() (float64, error) {
+	if p.accept("-") {
+		v, err := p.unary()
+		return -v, err
+	}
+	if p.accept("+") {
+		return p.unary()
+	}
+	return p.power()
+}
+
+func (p *exprParser) power() (float64, error) {
+	v, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+	if p.accept("**") || p.accept("^") {
+		exp, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+		return math.Pow(v, exp), nil
+	}
+	return v, nil
+}
+
+func (p *exprParser) primary() (float64, error) {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return 0, fmt.Errorf("unexpected end of expression")
+	}
+
+	if p.accept("(") {
+		v, err := p.expr()
+		if err != nil {
+			return 0, err
+		}
+		if !p.accept(")") {
+			return 0, fmt.Errorf("missing closing parenthesis")
+		}
+		return v, nil
+	}
+
+	c := p.input[p.pos]
+	if c >= '0' && c <= '9' || c == '.' {
+		return p.number()
+	}
+	if isIdentStart(rune(c)) {
+		start := p.pos
+		for p.pos < len(p.input) && isIdentPart(rune(p.input[p.pos])) {
+			p.pos++
+		}
+		name := p.input[start:p.pos]
+		if p.accept("(") {
+			return p.call(name)
+		}
+		if v, ok := p.vars[name]; ok {
+			return v, nil
+		}
+		if v, ok := programConstants[name]; ok {
+			return v, nil
+		}
+		return 0, fmt.Errorf("undefined variable %s", name)
+	}
+	return 0, fmt.Errorf("unexpected %q", string(c))
+}
+
+func (p *exprParser) number() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if c >= '0' && c <= '9' || c == '.' || c == '_' {
+			p.pos++
+			continue
+		}
+		if (c == 'e' || c == 'E') && p.pos+1 < len(p.input) {
+			next := p.input[p.pos+1]
+			if next >= '0' && next <= '9' || next == '-' || next == '+' {
+				p.pos += 2
+				continue
+			}
+		}
+		break
+	}
+	text := strings.ReplaceAll(p.input[start:p.pos], "_", "")
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", text)
+	}
+	return v, nil
+}
+
+func (p *exprParser) call(name string) (float64, error) {
+	fn, ok := programFunctions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown function %s", name)
+	}
+
+	var args []float64
+	if !p.accept(")") {
+		for {
+			v, err := p.expr()
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, v)
+			if p.accept(")") {
+				break
+			}
+			if !p.accept(",") {
+				return 0, fmt.Errorf("expected , or ) in call to %s", name)
+			}
+		}
+	}
+
+	valid := false
+	for _, n := range fn.arity {
+		if n == len(args) || n == -1 && len(args) > 0 {
+			valid = true
+		}
+	}
+	if !valid {
+		return 0, fmt.Errorf("wrong number of arguments to %s", name)
+	}
+	return fn.fn(args)
+}
+
+func isIdentifier(s string) bool {
+	if s == "" || !isIdentStart(rune(s[0])) {
+		return false
+	}
+	for _, r := range s {
+		if !isIdentPart(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+func isIdentPart(r rune) bool {
+	return isIdentStart(r) || r >= '0' && r <= '9'
+}
+
+// Ensure CodeInterpreterTool implements Tool.
+var _ Tool = (*CodeInterpreterTool)(nil)
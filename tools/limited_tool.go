@@ -0,0 +1,45 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/aqua777/go-llamaindex/limits"
+)
+
+// LimitedTool wraps a tool and enforces the tool output limit on its
+// content, so a single verbose tool cannot flood the LLM context.
+type LimitedTool struct {
+	tool   Tool
+	limits *limits.Limits
+}
+
+// NewLimitedTool wraps tool. A nil l uses limits.Global() at call time.
+func NewLimitedTool(tool Tool, l *limits.Limits) *LimitedTool {
+	return &LimitedTool{
+		tool:   tool,
+		limits: l,
+	}
+}
+
+// Metadata returns the wrapped tool's metadata.
+func (t *LimitedTool) Metadata() *ToolMetadata {
+	return t.tool.Metadata()
+}
+
+// Call calls the wrapped tool and limits its output.
+func (t *LimitedTool) Call(ctx context.Context, input interface{}) (*ToolOutput, error) {
+	output, err := t.tool.Call(ctx, input)
+	if err != nil || output == nil {
+		return output, err
+	}
+
+	content, err := limits.Resolve(t.limits).Apply(limits.KindToolOutput, output.Content)
+	if err != nil {
+		return NewErrorToolOutput(t.tool.Metadata().Name, err), err
+	}
+	output.Content = content
+	return output, nil
+}
+
+// Ensure LimitedTool implements Tool.
+var _ Tool = (*LimitedTool)(nil)
@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/aqua777/go-llamaindex/limits"
 	"github.com/aqua777/go-llamaindex/rag/synthesizer"
 	"github.com/aqua777/go-llamaindex/schema"
 	"github.com/stretchr/testify/assert"
@@ -451,3 +452,120 @@ func TestToolInterface(t *testing.T) {
 		var _ Tool = spec.ToTool()
 	})
 }
+
+func TestLimitedTool(t *testing.T) {
+	spec := &ToolSpec{
+		Name:        "dump",
+		Description: "Returns a lot of text",
+		Handler: func(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+			return "0123456789abcdefghij", nil
+		},
+	}
+
+	t.Run("truncates output", func(t *testing.T) {
+		l := limits.Limits{
+			ToolOutput:       limits.Limit{MaxBytes: 9, Strategy: limits.StrategyTruncateMiddle},
+			TruncationMarker: "~",
+		}
+		tool := NewLimitedTool(spec.ToTool(), &l)
+		assert.Equal(t, "dump", tool.Metadata().Name)
+
+		output, err := tool.Call(context.Background(), map[string]interface{}{})
+		require.NoError(t, err)
+		assert.Equal(t, "0123~ghij", output.Content)
+	})
+
+	t.Run("rejects output", func(t *testing.T) {
+		l := limits.Limits{ToolOutput: limits.Limit{MaxBytes: 5, Strategy: limits.StrategyError}}
+		output, err := NewLimitedTool(spec.ToTool(), &l).Call(context.Background(), map[string]interface{}{})
+		assert.ErrorIs(t, err, limits.ErrLimitExceeded)
+		assert.True(t, output.IsError)
+	})
+}
+
+// TestCodeInterpreterTool tests the CodeInterpreterTool.
+func TestCodeInterpreterTool(t *testing.T) {
+	interpreter := NewCodeInterpreterTool()
+
+	t.Run("Metadata", func(t *testing.T) {
+		meta := interpreter.Metadata()
+		assert.Equal(t, DefaultCodeInterpreterToolName, meta.Name)
+		props := meta.Parameters["properties"].(map[string]interface{})
+		assert.Contains(t, props, "code")
+	})
+
+	t.Run("Execute", func(t *testing.T) {
+		result, err := interpreter.Execute(`
+# order total
+price = 12.5
+quantity = 8; discount = 0.1
+subtotal = price * quantity
+answer = round(subtotal * (1 - discount), 2)
+2 ^ 3 ^ 2
+`)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"price", "quantity", "discount", "subtotal", "answer", ProgramResultVariable}, result.Order)
+		answer, _ := result.Value("answer")
+		assert.Equal(t, 90.0, answer)
+		value, _ := result.Value(ProgramResultVariable)
+		assert.Equal(t, 512.0, value)
+	})
+
+	t.Run("Operators and functions", func(t *testing.T) {
+		cases := map[string]float64{
+			"-2 ** 2":                  -4,
+			"(1 + 2) * 3 - 4 / 2":      7,
+			"10 % 4":                   2,
+			"1_000 * 1.5e2":            150000,
+			"max(3, 9, 4) + min(2, 1)": 10,
+			"sum(1, 2, 3) / avg(2, 4)": 2,
+			"sqrt(16) + abs(-1) + floor(2.7) + ceil(2.1)": 10,
+			"log(8, 2) + log10(100) + pow(2, 4)":          21,
+			"round(pi, 2)":                                3.14,
+		}
+		for expr, want := range cases {
+			result, err := interpreter.Execute(expr)
+			require.NoError(t, err, expr)
+			got, _ := result.Value(ProgramResultVariable)
+			assert.InDelta(t, want, got, 1e-9, expr)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, code := range []string{
+			"",
+			"x = 1 / 0",
+			"x = y + 1",
+			"x = system(1)",
+			"x = sqrt(1, 2)",
+			"pi = 3",
+			"x = (1 + 2",
+			"x = 1 +",
+			"import os",
+		} {
+			_, err := interpreter.Execute(code)
+			assert.Error(t, err, code)
+		}
+
+		limited := NewCodeInterpreterTool(WithCodeInterpreterMaxStatements(2))
+		_, err := limited.Execute("a = 1\nb = 2\nc = 3")
+		assert.Error(t, err)
+	})
+
+	t.Run("Call", func(t *testing.T) {
+		output, err := interpreter.Call(context.Background(), map[string]interface{}{"code": "a = 0.1 + 0.2\nb = a * 3"})
+		require.NoError(t, err)
+		assert.Equal(t, "a = 0.3\nb = 0.9", output.Content)
+		assert.IsType(t, &ProgramResult{}, output.RawOutput)
+
+		output, err = interpreter.Call(context.Background(), "x = oops")
+		assert.Error(t, err)
+		assert.True(t, output.IsError)
+	})
+
+	t.Run("FormatNumber", func(t *testing.T) {
+		assert.Equal(t, "42", FormatNumber(42))
+		assert.Equal(t, "0.3", FormatNumber(0.1+0.2))
+		assert.Equal(t, "1e+20", FormatNumber(1e20))
+	})
+}
@@ -0,0 +1,307 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/textutil"
+)
+
+// DefaultMaxToolOutput is the default maximum length, in characters, of
+// rendered tool outputs.
+const DefaultMaxToolOutput = 4000
+
+// citationMarker matches citation markers such as "[2]".
+var citationMarker = regexp.MustCompile(`\[(\d+)\]`)
+
+type renderConfig struct {
+	systemMessages bool
+	expandTools    bool
+	maxToolOutput  int
+}
+
+// RenderOption configures rendering.
+type RenderOption func(*renderConfig)
+
+// WithSystemMessages includes system messages, which are omitted by
+// default.
+func WithSystemMessages(include bool) RenderOption {
+	return func(c *renderConfig) {
+		c.systemMessages = include
+	}
+}
+
+// WithExpandedTools renders tool sections expanded instead of collapsed.
+func WithExpandedTools(expand bool) RenderOption {
+	return func(c *renderConfig) {
+		c.expandTools = expand
+	}
+}
+
+// WithMaxToolOutput sets the maximum length of rendered tool outputs.
+// Zero or less disables truncation.
+func WithMaxToolOutput(n int) RenderOption {
+	return func(c *renderConfig) {
+		c.maxToolOutput = n
+	}
+}
+
+func newRenderConfig(opts []RenderOption) *renderConfig {
+	c := &renderConfig{maxToolOutput: DefaultMaxToolOutput}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *renderConfig) includes(e Entry) bool {
+	return e.Role != llm.MessageRoleSystem || c.systemMessages
+}
+
+// Markdown renders the transcript as Markdown. Tool calls are wrapped in
+// <details> sections, which GitHub and most Markdown viewers collapse, and
+// citation markers link to their source URLs.
+func (t *Transcript) Markdown(opts ...RenderOption) string {
+	cfg := newRenderConfig(opts)
+	var sb strings.Builder
+
+	title := t.Title
+	if title == "" {
+		title = "Conversation transcript"
+	}
+	fmt.Fprintf(&sb, "# %s\n\n", title)
+	if !t.CreatedAt.IsZero() {
+		fmt.Fprintf(&sb, "- **Exported:** %s\n", t.CreatedAt.Format("2006-01-02 15:04 MST"))
+	}
+	for _, field := range t.Metadata {
+		fmt.Fprintf(&sb, "- **%s:** %s\n", field.Key, field.Value)
+	}
+	sb.WriteString("\n---\n")
+
+	for _, e := range t.Entries {
+		if !cfg.includes(e) {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n### %s\n\n", roleLabel(e))
+		if text := strings.TrimSpace(e.Text); text != "" {
+			sb.WriteString(linkCitations(text, e.Citations, func(n int, c Citation) string {
+				if !isWebURL(c.URL) {
+					return ""
+				}
+				return fmt.Sprintf("[[%d]](%s)", n, c.URL)
+			}))
+			sb.WriteString("\n")
+		}
+
+		for _, call := range e.ToolCalls {
+			open := ""
+			if cfg.expandTools {
+				open = " open"
+			}
+			fmt.Fprintf(&sb, "\n<details%s>\n<summary>%s</summary>\n\n", open, html.EscapeString(toolLabel(call)))
+			if call.Arguments != "" {
+				fmt.Fprintf(&sb, "**Arguments**\n\n%s\n\n", codeBlock(prettyJSON(call.Arguments), "json"))
+			}
+			fmt.Fprintf(&sb, "**Output**\n\n%s\n\n</details>\n", codeBlock(truncate(call.Output, cfg.maxToolOutput), ""))
+		}
+
+		if len(e.Citations) > 0 {
+			sb.WriteString("\n**Sources**\n\n")
+			for i, c := range e.Citations {
+				label := c.Title
+				if isWebURL(c.URL) {
+					label = fmt.Sprintf("[%s](%s)", c.Title, c.URL)
+				}
+				fmt.Fprintf(&sb, "%d. %s", i+1, label)
+				if c.Snippet != "" {
+					fmt.Fprintf(&sb, " — %s", c.Snippet)
+				}
+				sb.WriteString("\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// HTML renders the transcript as a standalone HTML page. Tool calls are
+// collapsible <details> sections and citation markers link to the source
+// list of their message.
+func (t *Transcript) HTML(opts ...RenderOption) string {
+	cfg := newRenderConfig(opts)
+	var sb strings.Builder
+
+	title := t.Title
+	if title == "" {
+		title = "Conversation transcript"
+	}
+	sb.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&sb, "<title>%s</title>\n<style>%s</style>\n</head>\n<body>\n", html.EscapeString(title), transcriptCSS)
+	fmt.Fprintf(&sb, "<h1>%s</h1>\n", html.EscapeString(title))
+	if !t.CreatedAt.IsZero() || len(t.Metadata) > 0 {
+		sb.WriteString("<dl class=\"meta\">\n")
+		if !t.CreatedAt.IsZero() {
+			fmt.Fprintf(&sb, "<dt>Exported</dt><dd><time datetime=\"%s\">%s</time></dd>\n",
+				t.CreatedAt.Format("2006-01-02T15:04:05Z07:00"), t.CreatedAt.Format("2006-01-02 15:04 MST"))
+		}
+		for _, field := range t.Metadata {
+			fmt.Fprintf(&sb, "<dt>%s</dt><dd>%s</dd>\n", html.EscapeString(field.Key), html.EscapeString(field.Value))
+		}
+		sb.WriteString("</dl>\n")
+	}
+
+	for i, e := range t.Entries {
+		if !cfg.includes(e) {
+			continue
+		}
+		fmt.Fprintf(&sb, "<section class=\"entry %s\" id=\"m%d\">\n", html.EscapeString(string(e.Role)), i+1)
+		fmt.Fprintf(&sb, "<h2>%s</h2>\n", html.EscapeString(roleLabel(e)))
+		if text := strings.TrimSpace(e.Text); text != "" {
+			linked := linkCitations(html.EscapeString(text), e.Citations, func(n int, c Citation) string {
+				return fmt.Sprintf("<a class=\"cite\" href=\"#m%d-s%d\">[%d]</a>", i+1, n, n)
+			})
+			fmt.Fprintf(&sb, "<div class=\"text\">%s</div>\n", linked)
+		}
+
+		for _, call := range e.ToolCalls {
+			class := "tool"
+			if call.IsError {
+				class += " error"
+			}
+			open := ""
+			if cfg.expandTools {
+				open = " open"
+			}
+			fmt.Fprintf(&sb, "<details class=\"%s\"%s>\n<summary>%s</summary>\n", class, open, html.EscapeString(toolLabel(call)))
+			if call.Arguments != "" {
+				fmt.Fprintf(&sb, "<h3>Arguments</h3>\n<pre><code>%s</code></pre>\n", html.EscapeString(prettyJSON(call.Arguments)))
+			}
+			fmt.Fprintf(&sb, "<h3>Output</h3>\n<pre><code>%s</code></pre>\n</details>\n", html.EscapeString(truncate(call.Output, cfg.maxToolOutput)))
+		}
+
+		if len(e.Citations) > 0 {
+			sb.WriteString("<h3>Sources</h3>\n<ol class=\"sources\">\n")
+			for j, c := range e.Citations {
+				label := html.EscapeString(c.Title)
+				if isWebURL(c.URL) {
+					label = fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(c.URL), label)
+				}
+				fmt.Fprintf(&sb, "<li id=\"m%d-s%d\">%s", i+1, j+1, label)
+				if c.Snippet != "" {
+					fmt.Fprintf(&sb, " <span class=\"snippet\">%s</span>", html.EscapeString(c.Snippet))
+				}
+				sb.WriteString("</li>\n")
+			}
+			sb.WriteString("</ol>\n")
+		}
+		sb.WriteString("</section>\n")
+	}
+
+	sb.WriteString("</body>\n</html>\n")
+	return sb.String()
+}
+
+const transcriptCSS = `body{font-family:system-ui,sans-serif;max-width:50rem;margin:2rem auto;padding:0 1rem;line-height:1.5;color:#1f2328}` +
+	`.meta{display:grid;grid-template-columns:max-content auto;gap:.25rem 1rem;color:#59636e}.meta dd{margin:0}` +
+	`.entry{border-top:1px solid #d1d9e0;padding:.5rem 0}.entry h2{font-size:1rem;margin:.5rem 0}` +
+	`.user h2{color:#0969da}.assistant h2{color:#1a7f37}.system h2,.tool h2{color:#59636e}` +
+	`.text{white-space:pre-wrap}h3{font-size:.875rem;margin:.75rem 0 .25rem}` +
+	`details.tool{background:#f6f8fa;border-radius:6px;padding:.5rem .75rem;margin:.5rem 0}details.error summary{color:#d1242f}` +
+	`summary{cursor:pointer;font-family:ui-monospace,monospace}pre{white-space:pre-wrap;overflow-wrap:anywhere;margin:0}` +
+	`.sources{padding-left:1.5rem}.snippet{color:#59636e}a.cite{text-decoration:none}`
+
+// linkCitations replaces "[n]" markers of known citations with link,
+// leaving markers that are already Markdown links or have no link.
+func linkCitations(text string, citations []Citation, link func(n int, c Citation) string) string {
+	if len(citations) == 0 {
+		return text
+	}
+	var sb strings.Builder
+	last := 0
+	for _, m := range citationMarker.FindAllStringSubmatchIndex(text, -1) {
+		if m[1] < len(text) && (text[m[1]] == '(' || text[m[1]] == ':') {
+			continue
+		}
+		n, err := strconv.Atoi(text[m[2]:m[3]])
+		if err != nil || n < 1 || n > len(citations) {
+			continue
+		}
+		replacement := link(n, citations[n-1])
+		if replacement == "" {
+			continue
+		}
+		sb.WriteString(text[last:m[0]])
+		sb.WriteString(replacement)
+		last = m[1]
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+func roleLabel(e Entry) string {
+	var label string
+	switch e.Role {
+	case llm.MessageRoleUser:
+		label = "User"
+	case llm.MessageRoleAssistant:
+		label = "Assistant"
+	case llm.MessageRoleSystem:
+		label = "System"
+	case llm.MessageRoleTool:
+		label = "Tool"
+	default:
+		label = string(e.Role)
+	}
+	if e.Name != "" {
+		label += " (" + e.Name + ")"
+	}
+	return label
+}
+
+func toolLabel(call ToolCall) string {
+	label := "Tool call: " + call.Name
+	if call.IsError {
+		label += " (failed)"
+	}
+	return label
+}
+
+// codeBlock fences text, using a fence longer than any backtick run in it.
+func codeBlock(text, lang string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + lang + "\n" + strings.TrimRight(text, "\n") + "\n" + fence
+}
+
+func prettyJSON(s string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(s), "", "  "); err != nil {
+		return s
+	}
+	return buf.String()
+}
+
+// truncate shortens text to limit characters, noting how much was cut.
+func truncate(text string, limit int) string {
+	n := utf8.RuneCountInString(text)
+	if limit <= 0 || n <= limit {
+		return text
+	}
+	return fmt.Sprintf("%s (%d more characters)", textutil.TruncateWith(text, limit, "\n…"), n-limit)
+}
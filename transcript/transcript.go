@@ -0,0 +1,333 @@
+// Package transcript exports conversations as shareable Markdown or HTML
+// documents for audits and support handoffs. A Transcript combines chat
+// memory with agent tool traces and the sources answers were based on;
+// tool calls render as collapsible sections and citation markers such as
+// "[1]" link to their sources.
+package transcript
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/agent"
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/memory"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/textutil"
+	"github.com/aqua777/go-llamaindex/tools"
+)
+
+// Metadata keys read from source nodes to build citations, as set by
+// readers such as WebReader.
+const (
+	// URLMetadataKey holds the source URL.
+	URLMetadataKey = "url"
+	// TitleMetadataKey holds the source title.
+	TitleMetadataKey = "title"
+)
+
+// ToolCall is a tool call made while producing an entry.
+type ToolCall struct {
+	// ID identifies the call.
+	ID string `json:"id,omitempty"`
+	// Name is the tool name.
+	Name string `json:"name"`
+	// Arguments are the JSON-encoded call arguments.
+	Arguments string `json:"arguments,omitempty"`
+	// Output is the tool output.
+	Output string `json:"output,omitempty"`
+	// IsError indicates the tool failed.
+	IsError bool `json:"is_error,omitempty"`
+}
+
+// Citation is a source of an entry. Citations are numbered from 1 in the
+// order they were added, matching "[n]" markers in the entry text.
+type Citation struct {
+	// ID identifies the source, e.g. a node ID.
+	ID string `json:"id"`
+	// Title is the display name of the source.
+	Title string `json:"title"`
+	// URL links to the source, if known.
+	URL string `json:"url,omitempty"`
+	// Snippet is an excerpt of the source text.
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// Entry is one message of a transcript.
+type Entry struct {
+	// Role is the role of the sender.
+	Role llm.MessageRole `json:"role"`
+	// Name is an optional sender name.
+	Name string `json:"name,omitempty"`
+	// Text is the message text.
+	Text string `json:"text"`
+	// ToolCalls are the tool calls made for this message.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// Citations are the sources of this message.
+	Citations []Citation `json:"citations,omitempty"`
+}
+
+// AddToolCall adds a tool call, merging it into an existing call with the
+// same ID.
+func (e *Entry) AddToolCall(call ToolCall) {
+	if call.ID != "" {
+		for i := range e.ToolCalls {
+			existing := &e.ToolCalls[i]
+			if existing.ID != call.ID {
+				continue
+			}
+			if call.Arguments != "" {
+				existing.Arguments = call.Arguments
+			}
+			if call.Output != "" {
+				existing.Output = call.Output
+			}
+			existing.IsError = existing.IsError || call.IsError
+			return
+		}
+	}
+	e.ToolCalls = append(e.ToolCalls, call)
+}
+
+// AddCitations adds citations, skipping sources already cited.
+func (e *Entry) AddCitations(citations ...Citation) {
+	for _, c := range citations {
+		duplicate := false
+		for _, existing := range e.Citations {
+			if existing.ID == c.ID || (c.URL != "" && existing.URL == c.URL) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			e.Citations = append(e.Citations, c)
+		}
+	}
+}
+
+// Transcript is an exportable conversation.
+type Transcript struct {
+	// Title is the document title.
+	Title string `json:"title"`
+	// CreatedAt is the export time shown in the header.
+	CreatedAt time.Time `json:"created_at"`
+	// Metadata is shown in the header, e.g. a ticket or session ID, in the
+	// order keys were added.
+	Metadata []MetadataField `json:"metadata,omitempty"`
+	// Entries are the messages, in order.
+	Entries []Entry `json:"entries"`
+}
+
+// MetadataField is a header field of a transcript.
+type MetadataField struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// New creates an empty transcript.
+func New(title string) *Transcript {
+	return &Transcript{
+		Title:     title,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// FromMemory creates a transcript from the full history of a memory.
+func FromMemory(ctx context.Context, mem memory.Memory, title string) (*Transcript, error) {
+	messages, err := mem.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read memory: %w", err)
+	}
+	return New(title).AddMessages(messages...), nil
+}
+
+// WithMetadata adds a header field.
+func (t *Transcript) WithMetadata(key, value string) *Transcript {
+	t.Metadata = append(t.Metadata, MetadataField{Key: key, Value: value})
+	return t
+}
+
+// AddMessages appends chat messages. Tool calls of assistant messages are
+// attached to them, and tool results are matched to their calls; results
+// without a matching call become tool entries.
+func (t *Transcript) AddMessages(messages ...llm.ChatMessage) *Transcript {
+	for _, msg := range messages {
+		if msg.Role == llm.MessageRoleTool && msg.ToolCallID != "" {
+			if t.attachToolResult(llm.ToolResult{ToolCallID: msg.ToolCallID, ToolName: msg.Name, Content: msg.GetTextContent()}) {
+				continue
+			}
+		}
+
+		entry := Entry{Role: msg.Role, Name: msg.Name, Text: msg.GetTextContent()}
+		for _, call := range msg.GetToolCalls() {
+			entry.AddToolCall(ToolCall{ID: call.ID, Name: call.Name, Arguments: call.Arguments})
+		}
+		var results []llm.ToolResult
+		for _, block := range msg.Blocks {
+			if block.Type == llm.ContentBlockTypeToolResult && block.ToolResult != nil {
+				results = append(results, *block.ToolResult)
+			}
+		}
+		if entry.Text != "" || len(entry.ToolCalls) > 0 {
+			t.Entries = append(t.Entries, entry)
+		}
+		for _, result := range results {
+			if !t.attachToolResult(result) {
+				t.Entries = append(t.Entries, Entry{Role: llm.MessageRoleTool, Name: result.ToolName, Text: result.Content})
+			}
+		}
+	}
+	return t
+}
+
+// AddAgentResponse records the tool calls and sources of an agent answer.
+// They are attached to the last entry when it is that answer, e.g. after
+// loading the agent's memory, and to a new assistant entry otherwise.
+func (t *Transcript) AddAgentResponse(resp *agent.AgentChatResponse) *Transcript {
+	if resp == nil {
+		return t
+	}
+	entry := t.assistantEntry(resp.Response)
+	for _, call := range resp.ToolCalls {
+		if call == nil {
+			continue
+		}
+		tc := ToolCall{ID: call.ToolID, Name: call.ToolName, Arguments: jsonString(call.ToolKwargs)}
+		if call.ToolOutput != nil {
+			tc.Output = call.ToolOutput.Content
+			tc.IsError = call.ToolOutput.IsError
+			entry.AddCitations(ToolOutputCitations(call.ToolOutput)...)
+		}
+		entry.AddToolCall(tc)
+	}
+	for _, output := range resp.Sources {
+		entry.AddCitations(ToolOutputCitations(output)...)
+	}
+	return t
+}
+
+// AddQueryResponse records a query engine answer and its source nodes,
+// attached like AddAgentResponse.
+func (t *Transcript) AddQueryResponse(resp *synthesizer.Response) *Transcript {
+	if resp == nil {
+		return t
+	}
+	t.assistantEntry(resp.Response).AddCitations(NodeCitations(resp.SourceNodes)...)
+	return t
+}
+
+// assistantEntry returns the last entry if it is the given assistant
+// answer, and appends a new one otherwise.
+func (t *Transcript) assistantEntry(text string) *Entry {
+	if n := len(t.Entries); n > 0 {
+		last := &t.Entries[n-1]
+		if last.Role == llm.MessageRoleAssistant && (text == "" || strings.TrimSpace(last.Text) == strings.TrimSpace(text)) {
+			return last
+		}
+	}
+	t.Entries = append(t.Entries, Entry{Role: llm.MessageRoleAssistant, Text: text})
+	return &t.Entries[len(t.Entries)-1]
+}
+
+// attachToolResult sets the output of the most recent call with the
+// result's ID, reporting whether one was found.
+func (t *Transcript) attachToolResult(result llm.ToolResult) bool {
+	for i := len(t.Entries) - 1; i >= 0; i-- {
+		for j := range t.Entries[i].ToolCalls {
+			call := &t.Entries[i].ToolCalls[j]
+			if call.ID == result.ToolCallID {
+				call.Output = result.Content
+				call.IsError = result.IsError
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WriteFile renders the transcript to a file, as HTML for ".html" and
+// ".htm" paths and as Markdown otherwise.
+func (t *Transcript) WriteFile(path string, opts ...RenderOption) error {
+	var content string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		content = t.HTML(opts...)
+	default:
+		content = t.Markdown(opts...)
+	}
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
+// NodeCitations returns citations for source nodes.
+func NodeCitations(nodes []schema.NodeWithScore) []Citation {
+	citations := make([]Citation, 0, len(nodes))
+	for _, n := range nodes {
+		citations = append(citations, nodeCitation(n.Node))
+	}
+	return citations
+}
+
+// ToolOutputCitations returns citations for the source nodes behind a tool
+// output, as returned by query engine and retriever tools.
+func ToolOutputCitations(output *tools.ToolOutput) []Citation {
+	if output == nil || output.IsError {
+		return nil
+	}
+	switch raw := output.RawOutput.(type) {
+	case *synthesizer.Response:
+		if raw != nil {
+			return NodeCitations(raw.SourceNodes)
+		}
+	case []schema.NodeWithScore:
+		return NodeCitations(raw)
+	}
+	return nil
+}
+
+func nodeCitation(node schema.Node) Citation {
+	c := Citation{ID: node.ID, Snippet: snippet(node.Text, 200)}
+	if url, ok := node.Metadata[URLMetadataKey].(string); ok {
+		c.URL = url
+	} else if source, ok := node.Metadata["source"].(string); ok && isWebURL(source) {
+		c.URL = source
+	}
+	for _, key := range []string{TitleMetadataKey, "file_name", "file_path"} {
+		if title, ok := node.Metadata[key].(string); ok && title != "" {
+			c.Title = title
+			break
+		}
+	}
+	if c.Title == "" {
+		c.Title = c.URL
+	}
+	if c.Title == "" {
+		c.Title = node.ID
+	}
+	return c
+}
+
+func isWebURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// snippet collapses whitespace and truncates text to limit runes.
+func snippet(text string, limit int) string {
+	return textutil.TruncateWith(strings.Join(strings.Fields(text), " "), limit, "…")
+}
+
+func jsonString(v map[string]interface{}) string {
+	if len(v) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
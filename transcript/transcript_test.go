@@ -0,0 +1,153 @@
+package transcript
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aqua777/go-llamaindex/agent"
+	"github.com/aqua777/go-llamaindex/llm"
+	"github.com/aqua777/go-llamaindex/memory"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sourceNode(id, title, url, text string) schema.NodeWithScore {
+	node := schema.NewTextNode(text)
+	node.ID = id
+	node.Metadata = map[string]interface{}{TitleMetadataKey: title, URLMetadataKey: url}
+	return schema.NodeWithScore{Node: *node, Score: 0.9}
+}
+
+// supportTranscript builds a transcript from agent memory plus the tool
+// trace of the agent's last answer.
+func supportTranscript(t *testing.T) *Transcript {
+	t.Helper()
+	ctx := context.Background()
+
+	mem := memory.NewChatMemoryBuffer()
+	require.NoError(t, mem.PutMessages(ctx, []llm.ChatMessage{
+		llm.NewSystemMessage("You are a support agent."),
+		llm.NewUserMessage("How do I reset my password?"),
+		llm.NewAssistantMessage("Open Settings > Security and choose Reset [1]. Links expire after 24 hours [2]."),
+	}))
+
+	tr, err := FromMemory(ctx, mem, "Ticket 4521")
+	require.NoError(t, err)
+	tr.CreatedAt = time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	tr.WithMetadata("Customer", "acme")
+
+	kb := synthesizer.NewResponse("Reset from Settings.", []schema.NodeWithScore{
+		sourceNode("kb-1", "Resetting passwords", "https://help.example.com/reset", "Open   Settings, then Security."),
+		sourceNode("kb-2", "Link expiry", "https://help.example.com/expiry", "Reset links expire after 24 hours."),
+	})
+	output := tools.NewToolOutputWithInput("kb_search", "Reset from Settings.", map[string]interface{}{"input": "reset password"}, kb)
+	resp := agent.NewAgentChatResponse("Open Settings > Security and choose Reset [1]. Links expire after 24 hours [2].")
+	resp.ToolCalls = []*agent.ToolCallResult{
+		agent.NewToolCallResult("kb_search", "call-1", map[string]interface{}{"input": "reset password"}, output, false),
+	}
+	resp.Sources = []*tools.ToolOutput{output}
+	return tr.AddAgentResponse(resp)
+}
+
+func TestAddMessages(t *testing.T) {
+	tr := New("t").AddMessages(
+		llm.NewUserMessage("What's the weather?"),
+		llm.ChatMessage{Role: llm.MessageRoleAssistant, Blocks: []llm.ContentBlock{
+			llm.NewToolCallBlock(llm.NewToolCall("call-1", "weather", `{"city":"Oslo"}`)),
+		}},
+		llm.NewToolMessage("call-1", "4°C, rain"),
+		llm.NewToolMessage("call-9", "orphan result"),
+		llm.NewAssistantMessage("It is 4°C and raining."),
+	)
+
+	require.Len(t, tr.Entries, 4)
+	assert.Equal(t, llm.MessageRoleAssistant, tr.Entries[1].Role)
+	assert.Equal(t, []ToolCall{{ID: "call-1", Name: "weather", Arguments: `{"city":"Oslo"}`, Output: "4°C, rain"}}, tr.Entries[1].ToolCalls)
+	assert.Equal(t, llm.MessageRoleTool, tr.Entries[2].Role)
+	assert.Equal(t, "orphan result", tr.Entries[2].Text)
+}
+
+func TestAddAgentResponse(t *testing.T) {
+	tr := supportTranscript(t)
+
+	// The trace is attached to the answer loaded from memory.
+	require.Len(t, tr.Entries, 3)
+	answer := tr.Entries[2]
+	require.Len(t, answer.ToolCalls, 1)
+	assert.Equal(t, "kb_search", answer.ToolCalls[0].Name)
+	assert.JSONEq(t, `{"input": "reset password"}`, answer.ToolCalls[0].Arguments)
+	require.Len(t, answer.Citations, 2, "sources are cited once")
+	assert.Equal(t, Citation{ID: "kb-1", Title: "Resetting passwords", URL: "https://help.example.com/reset", Snippet: "Open Settings, then Security."}, answer.Citations[0])
+
+	// A different answer gets its own entry.
+	tr.AddQueryResponse(synthesizer.NewResponse("Anything else?", nil))
+	assert.Len(t, tr.Entries, 4)
+}
+
+func TestMarkdown(t *testing.T) {
+	md := supportTranscript(t).Markdown()
+
+	assert.True(t, strings.HasPrefix(md, "# Ticket 4521\n\n- **Exported:** 2025-03-01 09:30 UTC\n- **Customer:** acme\n"), md)
+	assert.NotContains(t, md, "support agent", "system messages are omitted by default")
+	assert.Contains(t, md, "### User\n\nHow do I reset my password?")
+	assert.Contains(t, md, "choose Reset [[1]](https://help.example.com/reset). Links expire after 24 hours [[2]](https://help.example.com/expiry).")
+	assert.Contains(t, md, "<details>\n<summary>Tool call: kb_search</summary>\n\n**Arguments**\n\n```json\n{\n  \"input\": \"reset password\"\n}\n```")
+	assert.Contains(t, md, "**Output**\n\n```\nReset from Settings.\n```\n\n</details>")
+	assert.Contains(t, md, "1. [Resetting passwords](https://help.example.com/reset) — Open Settings, then Security.")
+
+	md = supportTranscript(t).Markdown(WithSystemMessages(true), WithExpandedTools(true))
+	assert.Contains(t, md, "### System\n\nYou are a support agent.")
+	assert.Contains(t, md, "<details open>")
+}
+
+func TestHTML(t *testing.T) {
+	tr := supportTranscript(t)
+	tr.AddMessages(llm.NewUserMessage("<script>alert(1)</script> thanks [7]"))
+	page := tr.HTML()
+
+	assert.True(t, strings.HasPrefix(page, "<!DOCTYPE html>"))
+	assert.Contains(t, page, "<title>Ticket 4521</title>")
+	assert.Contains(t, page, "<dt>Customer</dt><dd>acme</dd>")
+	assert.Contains(t, page, `choose Reset <a class="cite" href="#m3-s1">[1]</a>.`)
+	assert.Contains(t, page, `<li id="m3-s1"><a href="https://help.example.com/reset">Resetting passwords</a>`)
+	assert.Contains(t, page, "<details class=\"tool\">\n<summary>Tool call: kb_search</summary>")
+	assert.Contains(t, page, "&lt;script&gt;alert(1)&lt;/script&gt; thanks [7]")
+	assert.NotContains(t, page, "<script>")
+}
+
+func TestToolOutputRendering(t *testing.T) {
+	tr := New("t")
+	tr.Entries = []Entry{{Role: llm.MessageRoleAssistant, ToolCalls: []ToolCall{
+		{Name: "shell", Output: "```\n" + strings.Repeat("x", 30), IsError: true},
+	}}}
+
+	md := tr.Markdown(WithMaxToolOutput(10))
+	assert.Contains(t, md, "<summary>Tool call: shell (failed)</summary>")
+	assert.Contains(t, md, "````\n```\nxxxxxx\n… (24 more characters)\n````", "fences are longer than backtick runs in the output")
+	assert.Contains(t, tr.HTML(), `<details class="tool error">`)
+
+	assert.Equal(t, "héé\n… (2 more characters)", truncate("héééé", 3))
+	assert.Equal(t, "a b é…", snippet("a\n\tb  ééé", 5))
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	tr := supportTranscript(t)
+
+	require.NoError(t, tr.WriteFile(filepath.Join(dir, "ticket.html")))
+	data, err := os.ReadFile(filepath.Join(dir, "ticket.html"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "<!DOCTYPE html>"))
+
+	require.NoError(t, tr.WriteFile(filepath.Join(dir, "ticket.md")))
+	data, err = os.ReadFile(filepath.Join(dir, "ticket.md"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "# Ticket 4521"))
+}
@@ -0,0 +1,298 @@
+package uievents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aqua777/go-llamaindex/agent"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/tools"
+	"github.com/aqua777/go-llamaindex/workflow"
+	"github.com/google/uuid"
+)
+
+// RoleAssistant is the role of messages produced by agents and engines.
+const RoleAssistant = "assistant"
+
+// UIEvent wraps protocol events sent from workflow steps. FromWorkflowStream
+// passes them through unchanged, so a step can stream message deltas or
+// state patches:
+//
+//	ctx.SendEvent(uievents.UIEvent.With(uievents.TextMessageContent(id, token)))
+var UIEvent = workflow.CustomEventFactory[Event]("ui")
+
+// FromAgentStream converts a streaming agent response into a run: the
+// streamed tokens become one assistant message, followed by the response's
+// tool calls and sources. An empty runID is replaced with a new one.
+func FromAgentStream(ctx context.Context, runID string, resp *agent.StreamingAgentChatResponse) <-chan Event {
+	runID = ensureRunID(runID)
+	out := make(chan Event, 16)
+
+	go func() {
+		defer close(out)
+
+		messageID := uuid.New().String()
+		if !send(ctx, out, RunStarted(runID), TextMessageStart(messageID, RoleAssistant)) {
+			return
+		}
+
+		if !sendTokens(ctx, out, messageID, resp.ResponseChan) {
+			return
+		}
+
+		events := []Event{TextMessageEnd(messageID)}
+		events = append(events, toolCallEvents(resp.ToolCalls)...)
+		if sources := toolSources(resp.Sources); len(sources) > 0 {
+			events = append(events, Sources(messageID, sources))
+		}
+		send(ctx, out, append(events, RunFinished(runID, nil))...)
+	}()
+
+	return out
+}
+
+// FromAgentResponse converts a complete agent response into the events of
+// a run: its tool calls, the assistant message and its sources.
+func FromAgentResponse(runID string, resp *agent.AgentChatResponse) []Event {
+	runID = ensureRunID(runID)
+	messageID := uuid.New().String()
+
+	events := []Event{RunStarted(runID)}
+	events = append(events, toolCallEvents(resp.ToolCalls)...)
+	events = append(events, TextMessage(messageID, RoleAssistant, resp.Response)...)
+	if sources := toolSources(resp.Sources); len(sources) > 0 {
+		events = append(events, Sources(messageID, sources))
+	}
+	return append(events, RunFinished(runID, nil))
+}
+
+// FromQueryResponse converts a complete query engine response into the
+// events of a run: the source nodes, then the answer as one assistant
+// message.
+func FromQueryResponse(runID string, resp *synthesizer.Response) []Event {
+	runID = ensureRunID(runID)
+	messageID := uuid.New().String()
+
+	events := []Event{RunStarted(runID)}
+	if len(resp.SourceNodes) > 0 {
+		events = append(events, Sources(messageID, NodeSources(resp.SourceNodes)))
+	}
+	events = append(events, TextMessage(messageID, RoleAssistant, resp.Response)...)
+	return append(events, RunFinished(runID, nil))
+}
+
+// FromResponse returns the events of a run that answered with text, or
+// failed with err when it is non-nil.
+func FromResponse(runID, response string, err error) []Event {
+	runID = ensureRunID(runID)
+	if err != nil {
+		return []Event{RunStarted(runID), RunError(runID, err)}
+	}
+
+	events := []Event{RunStarted(runID)}
+	events = append(events, TextMessage(uuid.New().String(), RoleAssistant, response)...)
+	return append(events, RunFinished(runID, nil))
+}
+
+// FromQueryStream converts a streaming query engine response into a run:
+// the source nodes, then the streamed answer as one assistant message.
+func FromQueryStream(ctx context.Context, runID string, resp schema.StreamingEngineResponse) <-chan Event {
+	runID = ensureRunID(runID)
+	out := make(chan Event, 16)
+
+	go func() {
+		defer close(out)
+
+		messageID := uuid.New().String()
+		events := []Event{RunStarted(runID)}
+		if len(resp.SourceNodes) > 0 {
+			events = append(events, Sources(messageID, NodeSources(resp.SourceNodes)))
+		}
+		if !send(ctx, out, append(events, TextMessageStart(messageID, RoleAssistant))...) {
+			return
+		}
+
+		if !sendTokens(ctx, out, messageID, resp.ResponseStream) {
+			return
+		}
+
+		send(ctx, out, TextMessageEnd(messageID), RunFinished(runID, nil))
+	}()
+
+	return out
+}
+
+// FromWorkflowStream converts a workflow event stream into a run. Start,
+// stop, error and cancel events map to run events, UIEvent payloads pass
+// through, and any other workflow event becomes a CUSTOM event named after
+// its type. The run always ends with RUN_FINISHED or RUN_ERROR.
+func FromWorkflowStream(ctx context.Context, runID string, stream *workflow.WorkflowStream) <-chan Event {
+	runID = ensureRunID(runID)
+	out := make(chan Event, 16)
+
+	go func() {
+		defer close(out)
+
+		started, finished := false, false
+		for event := range stream.Events() {
+			e := workflowEvent(runID, event)
+			if !started && e.Type != TypeRunStarted {
+				if !send(ctx, out, RunStarted(runID)) {
+					return
+				}
+			}
+			started = true
+			if !send(ctx, out, e) {
+				return
+			}
+			if e.IsTerminal() {
+				finished = true
+				break
+			}
+		}
+		if finished {
+			return
+		}
+
+		var events []Event
+		if !started {
+			events = append(events, RunStarted(runID))
+		}
+		if err := stream.Err(); err != nil {
+			events = append(events, RunError(runID, err))
+		} else {
+			events = append(events, RunFinished(runID, nil))
+		}
+		send(ctx, out, events...)
+	}()
+
+	return out
+}
+
+// NodeSources converts retrieved nodes into sources.
+func NodeSources(nodes []schema.NodeWithScore) []Source {
+	sources := make([]Source, len(nodes))
+	for i, n := range nodes {
+		sources[i] = Source{
+			ID:       n.Node.ID,
+			Text:     n.Node.Text,
+			Score:    n.Score,
+			Metadata: n.Node.Metadata,
+		}
+	}
+	return sources
+}
+
+// workflowEvent maps a workflow event to a protocol event.
+func workflowEvent(runID string, event workflow.Event) Event {
+	switch {
+	case UIEvent.Include(event):
+		e, _ := UIEvent.Extract(event)
+		return e
+	case workflow.StartEvent.Include(event):
+		return RunStarted(runID)
+	case workflow.StopEvent.Include(event):
+		data, _ := workflow.StopEvent.Extract(event)
+		return RunFinished(runID, jsonValue(data.Result))
+	case workflow.ErrorEvent.Include(event):
+		data, _ := workflow.ErrorEvent.Extract(event)
+		err := data.Error
+		if err == nil {
+			err = fmt.Errorf("step %s failed", data.Step)
+		}
+		return RunError(runID, err)
+	case workflow.CancelEvent.Include(event):
+		data, _ := workflow.CancelEvent.Extract(event)
+		return RunError(runID, fmt.Errorf("%w: %s", workflow.ErrWorkflowCancelled, data.Reason))
+	default:
+		return Custom(string(event.Type()), jsonValue(event.Data()))
+	}
+}
+
+// toolCallEvents returns the start, arguments, end and result events of
+// each tool call.
+func toolCallEvents(calls []*agent.ToolCallResult) []Event {
+	var events []Event
+	for _, call := range calls {
+		id := call.ToolID
+		if id == "" {
+			id = uuid.New().String()
+		}
+		events = append(events, ToolCallStart(id, call.ToolName))
+		if args, err := json.Marshal(call.ToolKwargs); err == nil && call.ToolKwargs != nil {
+			events = append(events, ToolCallArgs(id, string(args)))
+		}
+		events = append(events, ToolCallEnd(id))
+		if call.ToolOutput != nil {
+			events = append(events, ToolCallResult(id, call.ToolOutput.Content, call.ToolOutput.IsError))
+		}
+	}
+	return events
+}
+
+// toolSources converts tool outputs into sources.
+func toolSources(outputs []*tools.ToolOutput) []Source {
+	var sources []Source
+	for i, output := range outputs {
+		if output == nil || output.IsError {
+			continue
+		}
+		sources = append(sources, Source{
+			ID:       fmt.Sprintf("%s-%d", output.ToolName, i),
+			Text:     output.Content,
+			Metadata: map[string]interface{}{"tool_name": output.ToolName},
+		})
+	}
+	return sources
+}
+
+// jsonValue returns v if it encodes to JSON, and its string form otherwise,
+// so that arbitrary workflow payloads never break the stream.
+func jsonValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return v
+}
+
+func ensureRunID(runID string) string {
+	if runID == "" {
+		return uuid.New().String()
+	}
+	return runID
+}
+
+// sendTokens sends each non-empty token as a message delta until the
+// token channel is closed, giving up when ctx is done.
+func sendTokens(ctx context.Context, out chan<- Event, messageID string, tokens <-chan string) bool {
+	for {
+		select {
+		case token, ok := <-tokens:
+			if !ok {
+				return true
+			}
+			if token != "" && !send(ctx, out, TextMessageContent(messageID, token)) {
+				return false
+			}
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// send delivers events in order, giving up when ctx is done.
+func send(ctx context.Context, out chan<- Event, events ...Event) bool {
+	for _, e := range events {
+		select {
+		case out <- e:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package uievents
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Writer writes events as server-sent events: each event is an "event:"
+// line with its type and a "data:" line with its JSON.
+type Writer struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+// NewWriter creates a Writer. If w is an http.Flusher, every event is
+// flushed as soon as it is written.
+func NewWriter(w io.Writer) *Writer {
+	flusher, _ := w.(http.Flusher)
+	return &Writer{w: w, flusher: flusher}
+}
+
+// Write writes one event.
+func (w *Writer) Write(e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", e.Type, err)
+	}
+	if _, err := fmt.Fprintf(w.w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+		return err
+	}
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+	return nil
+}
+
+// Serve streams events to an HTTP response until the channel is closed or
+// the client goes away. It sets the server-sent event headers, so nothing
+// may have been written to w before.
+func Serve(w http.ResponseWriter, r *http.Request, events <-chan Event) error {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	sse := NewWriter(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return r.Context().Err()
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := sse.Write(e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// StreamFunc starts a run for a request and returns its events. The
+// request's context is cancelled when the client goes away.
+type StreamFunc func(r *http.Request) (<-chan Event, error)
+
+// Handler returns an http.Handler serving the events of fn. An error from
+// fn is reported as a RUN_ERROR event.
+func Handler(fn StreamFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		events, err := fn(r)
+		if err != nil {
+			events = Channel(RunError("", err))
+		}
+		_ = Serve(w, r, events)
+	})
+}
+
+// Channel returns a closed channel holding events, for serving events
+// that are known up front.
+func Channel(events ...Event) <-chan Event {
+	ch := make(chan Event, len(events))
+	for _, e := range events {
+		ch <- e
+	}
+	close(ch)
+	return ch
+}
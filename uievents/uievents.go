@@ -0,0 +1,319 @@
+// Package uievents defines a versioned JSON event protocol for streaming
+// go-llamaindex runs to web front-ends, in the style of AG-UI.
+//
+// A run is a sequence of events: RUN_STARTED, then any number of message,
+// tool call, source, state and step events, then RUN_FINISHED or RUN_ERROR.
+// Message text and tool call arguments arrive as deltas between a START and
+// an END event sharing an ID, so a client can render them as they stream.
+//
+// Events are plain JSON objects with a "version" and a "type"; the other
+// fields depend on the type and are omitted when empty. Adapters convert
+// agent, query engine and workflow streams into events, and Serve writes
+// them to an HTTP response as server-sent events.
+package uievents
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Version is the protocol version stamped on every event. The major version
+// changes only when existing fields change meaning; new event types and
+// fields may be added within a major version.
+const Version = "1.0"
+
+// ErrUnsupportedVersion is returned by Decode for events of another major
+// version.
+var ErrUnsupportedVersion = errors.New("unsupported event version")
+
+// Type is the type of an event.
+type Type string
+
+const (
+	// TypeRunStarted starts a run.
+	TypeRunStarted Type = "RUN_STARTED"
+	// TypeRunFinished ends a run successfully, with an optional result.
+	TypeRunFinished Type = "RUN_FINISHED"
+	// TypeRunError ends a run with an error.
+	TypeRunError Type = "RUN_ERROR"
+
+	// TypeStepStarted starts a named step of a run.
+	TypeStepStarted Type = "STEP_STARTED"
+	// TypeStepFinished ends a named step of a run.
+	TypeStepFinished Type = "STEP_FINISHED"
+
+	// TypeTextMessageStart starts a message.
+	TypeTextMessageStart Type = "TEXT_MESSAGE_START"
+	// TypeTextMessageContent appends a delta to a message.
+	TypeTextMessageContent Type = "TEXT_MESSAGE_CONTENT"
+	// TypeTextMessageEnd ends a message.
+	TypeTextMessageEnd Type = "TEXT_MESSAGE_END"
+
+	// TypeToolCallStart starts a tool call.
+	TypeToolCallStart Type = "TOOL_CALL_START"
+	// TypeToolCallArgs appends a delta to the JSON arguments of a tool call.
+	TypeToolCallArgs Type = "TOOL_CALL_ARGS"
+	// TypeToolCallEnd ends a tool call's arguments.
+	TypeToolCallEnd Type = "TOOL_CALL_END"
+	// TypeToolCallResult carries the output of a tool call.
+	TypeToolCallResult Type = "TOOL_CALL_RESULT"
+
+	// TypeSources carries the sources an answer is based on.
+	TypeSources Type = "SOURCES"
+
+	// TypeStateSnapshot replaces the client's copy of the run state.
+	TypeStateSnapshot Type = "STATE_SNAPSHOT"
+	// TypeStateDelta patches the client's copy of the run state with JSON
+	// Patch (RFC 6902) operations.
+	TypeStateDelta Type = "STATE_DELTA"
+
+	// TypeCustom carries an application-defined event.
+	TypeCustom Type = "CUSTOM"
+)
+
+// Event is one protocol event.
+type Event struct {
+	// Version is the protocol version.
+	Version string `json:"version"`
+	// Type is the event type.
+	Type Type `json:"type"`
+	// Timestamp is the creation time in Unix milliseconds.
+	Timestamp int64 `json:"timestamp"`
+
+	// RunID identifies the run of run events.
+	RunID string `json:"run_id,omitempty"`
+	// Result is the result of RUN_FINISHED.
+	Result interface{} `json:"result,omitempty"`
+	// Error is the message of RUN_ERROR.
+	Error string `json:"error,omitempty"`
+
+	// StepName names the step of step events.
+	StepName string `json:"step_name,omitempty"`
+
+	// MessageID identifies the message of message and source events.
+	MessageID string `json:"message_id,omitempty"`
+	// Role is the author of a message, e.g. "assistant".
+	Role string `json:"role,omitempty"`
+	// Delta is the text appended by TEXT_MESSAGE_CONTENT and TOOL_CALL_ARGS.
+	Delta string `json:"delta,omitempty"`
+
+	// ToolCallID identifies the tool call of tool call events.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	// ToolName is the tool called by TOOL_CALL_START.
+	ToolName string `json:"tool_name,omitempty"`
+	// Content is the output of TOOL_CALL_RESULT.
+	Content string `json:"content,omitempty"`
+	// IsError reports whether a TOOL_CALL_RESULT is an error.
+	IsError bool `json:"is_error,omitempty"`
+
+	// Sources are the sources of SOURCES.
+	Sources []Source `json:"sources,omitempty"`
+
+	// Snapshot is the state of STATE_SNAPSHOT.
+	Snapshot interface{} `json:"snapshot,omitempty"`
+	// Patch is the JSON Patch of STATE_DELTA.
+	Patch []PatchOperation `json:"patch,omitempty"`
+
+	// Name names the event of CUSTOM.
+	Name string `json:"name,omitempty"`
+	// Value is the payload of CUSTOM.
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Source is a piece of content an answer is based on, such as a retrieved
+// node or a tool output.
+type Source struct {
+	// ID identifies the source, e.g. a node ID.
+	ID string `json:"id"`
+	// Text is the source content.
+	Text string `json:"text"`
+	// Score is the retrieval score, if any.
+	Score float64 `json:"score,omitempty"`
+	// Metadata is the source metadata.
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// PatchOperation is one JSON Patch (RFC 6902) operation.
+type PatchOperation struct {
+	// Op is "add", "remove", "replace", "move", "copy" or "test".
+	Op string `json:"op"`
+	// Path is a JSON Pointer to the target location.
+	Path string `json:"path"`
+	// Value is the value of add, replace and test operations.
+	Value interface{} `json:"value,omitempty"`
+	// From is the source location of move and copy operations.
+	From string `json:"from,omitempty"`
+}
+
+// New creates an event of the given type, stamped with the protocol version
+// and the current time.
+func New(eventType Type) Event {
+	return Event{
+		Version:   Version,
+		Type:      eventType,
+		Timestamp: time.Now().UnixMilli(),
+	}
+}
+
+// RunStarted creates a RUN_STARTED event.
+func RunStarted(runID string) Event {
+	e := New(TypeRunStarted)
+	e.RunID = runID
+	return e
+}
+
+// RunFinished creates a RUN_FINISHED event. result may be nil.
+func RunFinished(runID string, result interface{}) Event {
+	e := New(TypeRunFinished)
+	e.RunID = runID
+	e.Result = result
+	return e
+}
+
+// RunError creates a RUN_ERROR event.
+func RunError(runID string, err error) Event {
+	e := New(TypeRunError)
+	e.RunID = runID
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+// StepStarted creates a STEP_STARTED event.
+func StepStarted(name string) Event {
+	e := New(TypeStepStarted)
+	e.StepName = name
+	return e
+}
+
+// StepFinished creates a STEP_FINISHED event.
+func StepFinished(name string) Event {
+	e := New(TypeStepFinished)
+	e.StepName = name
+	return e
+}
+
+// TextMessageStart creates a TEXT_MESSAGE_START event.
+func TextMessageStart(messageID, role string) Event {
+	e := New(TypeTextMessageStart)
+	e.MessageID = messageID
+	e.Role = role
+	return e
+}
+
+// TextMessageContent creates a TEXT_MESSAGE_CONTENT event.
+func TextMessageContent(messageID, delta string) Event {
+	e := New(TypeTextMessageContent)
+	e.MessageID = messageID
+	e.Delta = delta
+	return e
+}
+
+// TextMessageEnd creates a TEXT_MESSAGE_END event.
+func TextMessageEnd(messageID string) Event {
+	e := New(TypeTextMessageEnd)
+	e.MessageID = messageID
+	return e
+}
+
+// TextMessage returns the events of a complete message: start, a single
+// content delta unless text is empty, and end.
+func TextMessage(messageID, role, text string) []Event {
+	events := []Event{TextMessageStart(messageID, role)}
+	if text != "" {
+		events = append(events, TextMessageContent(messageID, text))
+	}
+	return append(events, TextMessageEnd(messageID))
+}
+
+// ToolCallStart creates a TOOL_CALL_START event.
+func ToolCallStart(toolCallID, toolName string) Event {
+	e := New(TypeToolCallStart)
+	e.ToolCallID = toolCallID
+	e.ToolName = toolName
+	return e
+}
+
+// ToolCallArgs creates a TOOL_CALL_ARGS event.
+func ToolCallArgs(toolCallID, delta string) Event {
+	e := New(TypeToolCallArgs)
+	e.ToolCallID = toolCallID
+	e.Delta = delta
+	return e
+}
+
+// ToolCallEnd creates a TOOL_CALL_END event.
+func ToolCallEnd(toolCallID string) Event {
+	e := New(TypeToolCallEnd)
+	e.ToolCallID = toolCallID
+	return e
+}
+
+// ToolCallResult creates a TOOL_CALL_RESULT event.
+func ToolCallResult(toolCallID, content string, isError bool) Event {
+	e := New(TypeToolCallResult)
+	e.ToolCallID = toolCallID
+	e.Content = content
+	e.IsError = isError
+	return e
+}
+
+// Sources creates a SOURCES event. messageID may be empty when the sources
+// belong to the whole run.
+func Sources(messageID string, sources []Source) Event {
+	e := New(TypeSources)
+	e.MessageID = messageID
+	e.Sources = sources
+	return e
+}
+
+// StateSnapshot creates a STATE_SNAPSHOT event.
+func StateSnapshot(state interface{}) Event {
+	e := New(TypeStateSnapshot)
+	e.Snapshot = state
+	return e
+}
+
+// StateDelta creates a STATE_DELTA event.
+func StateDelta(ops ...PatchOperation) Event {
+	e := New(TypeStateDelta)
+	e.Patch = ops
+	return e
+}
+
+// Custom creates a CUSTOM event.
+func Custom(name string, value interface{}) Event {
+	e := New(TypeCustom)
+	e.Name = name
+	e.Value = value
+	return e
+}
+
+// IsTerminal reports whether the event ends a run.
+func (e Event) IsTerminal() bool {
+	return e.Type == TypeRunFinished || e.Type == TypeRunError
+}
+
+// Decode parses an event and checks that its major version is supported.
+func Decode(data []byte) (Event, error) {
+	var e Event
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Event{}, fmt.Errorf("failed to decode event: %w", err)
+	}
+	if majorVersion(e.Version) != majorVersion(Version) {
+		return Event{}, fmt.Errorf("%w: %q", ErrUnsupportedVersion, e.Version)
+	}
+	if e.Type == "" {
+		return Event{}, fmt.Errorf("failed to decode event: missing type")
+	}
+	return e, nil
+}
+
+func majorVersion(version string) string {
+	major, _, _ := strings.Cut(version, ".")
+	return major
+}
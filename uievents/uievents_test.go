@@ -0,0 +1,238 @@
+package uievents
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aqua777/go-llamaindex/agent"
+	"github.com/aqua777/go-llamaindex/rag/synthesizer"
+	"github.com/aqua777/go-llamaindex/schema"
+	"github.com/aqua777/go-llamaindex/tools"
+	"github.com/aqua777/go-llamaindex/workflow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collect(ch <-chan Event) []Event {
+	var events []Event
+	for e := range ch {
+		events = append(events, e)
+	}
+	return events
+}
+
+func types(events []Event) []Type {
+	out := make([]Type, len(events))
+	for i, e := range events {
+		out[i] = e.Type
+	}
+	return out
+}
+
+func tokens(values ...string) <-chan string {
+	ch := make(chan string, len(values))
+	for _, v := range values {
+		ch <- v
+	}
+	close(ch)
+	return ch
+}
+
+func TestEventJSON(t *testing.T) {
+	e := TextMessageContent("m1", "Hel")
+	data, err := json.Marshal(e)
+	require.NoError(t, err)
+
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &raw))
+	assert.Equal(t, Version, raw["version"])
+	assert.Equal(t, "TEXT_MESSAGE_CONTENT", raw["type"])
+	assert.Equal(t, "m1", raw["message_id"])
+	assert.Equal(t, "Hel", raw["delta"])
+	assert.NotZero(t, raw["timestamp"])
+	// Fields of other event types are omitted.
+	assert.NotContains(t, raw, "tool_call_id")
+	assert.NotContains(t, raw, "sources")
+
+	decoded, err := Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, e, decoded)
+
+	_, err = Decode([]byte(`{"version": "2.0", "type": "RUN_STARTED"}`))
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+
+	// Minor versions are compatible.
+	_, err = Decode([]byte(`{"version": "1.3", "type": "SOMETHING_NEW"}`))
+	assert.NoError(t, err)
+
+	patch := StateDelta(PatchOperation{Op: "replace", Path: "/step", Value: "refine"})
+	data, err = json.Marshal(patch)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"patch":[{"op":"replace","path":"/step","value":"refine"}]`)
+}
+
+func TestFromAgentStream(t *testing.T) {
+	resp := agent.NewStreamingAgentChatResponse(tokens("It is ", "", "4."))
+	resp.ToolCalls = []*agent.ToolCallResult{
+		agent.NewToolCallResult("add", "call-1", map[string]interface{}{"a": 2, "b": 2}, tools.NewToolOutput("add", "4"), false),
+	}
+	resp.Sources = []*tools.ToolOutput{tools.NewToolOutput("add", "4")}
+
+	events := collect(FromAgentStream(context.Background(), "run-1", resp))
+	assert.Equal(t, []Type{
+		TypeRunStarted,
+		TypeTextMessageStart, TypeTextMessageContent, TypeTextMessageContent, TypeTextMessageEnd,
+		TypeToolCallStart, TypeToolCallArgs, TypeToolCallEnd, TypeToolCallResult,
+		TypeSources,
+		TypeRunFinished,
+	}, types(events))
+
+	assert.Equal(t, "run-1", events[0].RunID)
+	assert.Equal(t, RoleAssistant, events[1].Role)
+	assert.Equal(t, "It is ", events[2].Delta)
+	assert.Equal(t, events[1].MessageID, events[4].MessageID)
+	assert.Equal(t, "add", events[5].ToolName)
+	assert.JSONEq(t, `{"a": 2, "b": 2}`, events[6].Delta)
+	assert.Equal(t, "4", events[8].Content)
+	assert.Equal(t, "4", events[9].Sources[0].Text)
+}
+
+func TestFromAgentResponse(t *testing.T) {
+	resp := agent.NewAgentChatResponse("Done.")
+	events := FromAgentResponse("", resp)
+
+	assert.Equal(t, []Type{TypeRunStarted, TypeTextMessageStart, TypeTextMessageContent, TypeTextMessageEnd, TypeRunFinished}, types(events))
+	assert.NotEmpty(t, events[0].RunID)
+	assert.Equal(t, events[0].RunID, events[4].RunID)
+}
+
+func TestFromQueryStream(t *testing.T) {
+	node := schema.NewTextNode("Go was released in 2009.")
+	resp := schema.StreamingEngineResponse{
+		ResponseStream: tokens("In ", "2009."),
+		SourceNodes:    []schema.NodeWithScore{{Node: *node, Score: 0.9}},
+	}
+
+	events := collect(FromQueryStream(context.Background(), "run-1", resp))
+	assert.Equal(t, []Type{
+		TypeRunStarted, TypeSources,
+		TypeTextMessageStart, TypeTextMessageContent, TypeTextMessageContent, TypeTextMessageEnd,
+		TypeRunFinished,
+	}, types(events))
+	assert.Equal(t, node.ID, events[1].Sources[0].ID)
+	assert.Equal(t, 0.9, events[1].Sources[0].Score)
+	assert.Equal(t, events[1].MessageID, events[2].MessageID)
+}
+
+func TestFromResponse(t *testing.T) {
+	node := schema.NewTextNode("Go was released in 2009.")
+	events := FromQueryResponse("run-1", synthesizer.NewResponse("In 2009.", []schema.NodeWithScore{{Node: *node}}))
+	assert.Equal(t, []Type{TypeRunStarted, TypeSources, TypeTextMessageStart, TypeTextMessageContent, TypeTextMessageEnd, TypeRunFinished}, types(events))
+
+	events = FromResponse("run-1", "Hi", nil)
+	assert.Equal(t, []Type{TypeRunStarted, TypeTextMessageStart, TypeTextMessageContent, TypeTextMessageEnd, TypeRunFinished}, types(events))
+
+	events = FromResponse("run-1", "", errors.New("llm down"))
+	require.Equal(t, []Type{TypeRunStarted, TypeRunError}, types(events))
+	assert.Equal(t, "llm down", events[1].Error)
+}
+
+func TestFromQueryStreamCancelled(t *testing.T) {
+	stream := make(chan string)
+	defer close(stream)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := FromQueryStream(ctx, "run-1", schema.StreamingEngineResponse{ResponseStream: stream})
+
+	assert.Equal(t, TypeRunStarted, (<-events).Type)
+	cancel()
+	// The adapter stops without waiting for the rest of the stream.
+	for range events {
+	}
+}
+
+func TestFromWorkflowStream(t *testing.T) {
+	progress := workflow.CustomEventFactory[string]("progress")
+
+	t.Run("finished", func(t *testing.T) {
+		wf := workflow.NewWorkflow()
+		workflow.HandleTyped(wf, workflow.StartEvent, func(ctx *workflow.Context, data workflow.StartEventData) ([]workflow.Event, error) {
+			ctx.SendEvent(UIEvent.With(StateDelta(PatchOperation{Op: "add", Path: "/status", Value: "working"})))
+			return []workflow.Event{progress.With("half way")}, nil
+		})
+		workflow.HandleTyped(wf, progress, func(ctx *workflow.Context, data string) ([]workflow.Event, error) {
+			return []workflow.Event{workflow.NewStopEvent("done")}, nil
+		})
+
+		stream := wf.RunStream(context.Background(), workflow.NewStartEvent("go"))
+		events := collect(FromWorkflowStream(context.Background(), "run-1", stream))
+
+		assert.Equal(t, []Type{TypeRunStarted, TypeStateDelta, TypeCustom, TypeRunFinished}, types(events))
+		assert.Equal(t, "/status", events[1].Patch[0].Path)
+		assert.Equal(t, "custom.progress", events[2].Name)
+		assert.Equal(t, "half way", events[2].Value)
+		assert.Equal(t, "done", events[3].Result)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		wf := workflow.NewWorkflow()
+		workflow.HandleTyped(wf, workflow.StartEvent, func(ctx *workflow.Context, data workflow.StartEventData) ([]workflow.Event, error) {
+			return nil, errors.New("boom")
+		})
+
+		stream := wf.RunStream(context.Background(), workflow.NewStartEvent("go"))
+		events := collect(FromWorkflowStream(context.Background(), "run-1", stream))
+
+		require.Equal(t, []Type{TypeRunStarted, TypeRunError}, types(events))
+		assert.Contains(t, events[1].Error, "boom")
+	})
+}
+
+func TestServe(t *testing.T) {
+	handler := Handler(func(r *http.Request) (<-chan Event, error) {
+		if r.URL.Query().Get("fail") != "" {
+			return nil, errors.New("bad input")
+		}
+		return Channel(append([]Event{RunStarted("run-1")}, TextMessage("m1", RoleAssistant, "Hi")...)...), nil
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	read := func(url string) []Event {
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		var events []Event
+		var eventType string
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				eventType = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				e, err := Decode([]byte(strings.TrimPrefix(line, "data: ")))
+				require.NoError(t, err)
+				assert.Equal(t, eventType, string(e.Type))
+				events = append(events, e)
+			}
+		}
+		return events
+	}
+
+	events := read(srv.URL)
+	assert.Equal(t, []Type{TypeRunStarted, TypeTextMessageStart, TypeTextMessageContent, TypeTextMessageEnd}, types(events))
+	assert.Equal(t, "Hi", events[2].Delta)
+
+	events = read(srv.URL + "?fail=1")
+	require.Equal(t, []Type{TypeRunError}, types(events))
+	assert.Equal(t, "bad input", events[0].Error)
+}
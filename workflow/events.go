@@ -12,6 +12,8 @@ const (
 	InputRequiredEventType EventType = "workflow.input_required"
 	// HumanResponseEventType is the event type for human response events.
 	HumanResponseEventType EventType = "workflow.human_response"
+	// CancelEventType is the event type for cancellation events.
+	CancelEventType EventType = "workflow.cancel"
 )
 
 // StartEventData contains data for a start event.
@@ -54,6 +56,12 @@ type HumanResponseEventData struct {
 	Response string
 }
 
+// CancelEventData contains data for a cancellation event.
+type CancelEventData struct {
+	// Reason is an optional reason for cancelling.
+	Reason string
+}
+
 // Pre-defined event factories for common events.
 var (
 	// StartEvent is the factory for start events.
@@ -66,6 +74,8 @@ var (
 	InputRequiredEvent = NewEventFactoryWithLabel[InputRequiredEventData](InputRequiredEventType, "input_required")
 	// HumanResponseEvent is the factory for human response events.
 	HumanResponseEvent = NewEventFactoryWithLabel[HumanResponseEventData](HumanResponseEventType, "human_response")
+	// CancelEvent is the factory for cancellation events.
+	CancelEvent = NewEventFactoryWithLabel[CancelEventData](CancelEventType, "cancel")
 )
 
 // NewStartEvent creates a new start event with the given input.
@@ -103,6 +113,13 @@ func NewHumanResponseEvent(response string) *TypedEvent[HumanResponseEventData]
 	return HumanResponseEvent.With(HumanResponseEventData{Response: response})
 }
 
+// NewCancelEvent creates a new cancellation event. Sending it cancels the
+// context observed by in-flight steps immediately; it is dispatched ahead of
+// all other pending events.
+func NewCancelEvent(reason string) *TypedEvent[CancelEventData] {
+	return CancelEvent.WithPriority(CancelEventData{Reason: reason}, PriorityCritical)
+}
+
 // CustomEventFactory creates a custom event factory with a unique type.
 func CustomEventFactory[T any](name string) *EventFactory[T] {
 	return NewEventFactory[T](EventType("custom." + name))
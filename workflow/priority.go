@@ -0,0 +1,135 @@
+package workflow
+
+import "sync"
+
+// Common event priorities. Higher priorities are dispatched first; events of
+// equal priority are dispatched in the order they were sent.
+const (
+	// PriorityLow is for background events that may wait behind others.
+	PriorityLow = -10
+	// PriorityNormal is the default priority.
+	PriorityNormal = 0
+	// PriorityHigh is for interactive events such as human responses.
+	PriorityHigh = 10
+	// PriorityCritical is for events that must preempt all other work,
+	// such as cancellation.
+	PriorityCritical = 100
+)
+
+// DefaultEventAging is the default number of dispatches after which a
+// waiting event gains one priority level.
+const DefaultEventAging = 10
+
+// PrioritizedEvent is implemented by events that carry a dispatch priority.
+// BaseEvent and TypedEvent implement it.
+type PrioritizedEvent interface {
+	Event
+	// Priority returns the event's dispatch priority.
+	Priority() int
+}
+
+// eventPriority returns the priority of an event. Events without an explicit
+// priority fall back to the priority registered for their type.
+func eventPriority(event Event, typePriorities map[EventType]int) int {
+	if p, ok := event.(PrioritizedEvent); ok && p.Priority() != PriorityNormal {
+		return p.Priority()
+	}
+	return typePriorities[event.Type()]
+}
+
+// queuedEvent is an event waiting in an eventQueue.
+type queuedEvent struct {
+	event    Event
+	priority int
+	seq      uint64
+	// enqueuedAt is the queue's dispatch count when the event was sent.
+	enqueuedAt uint64
+}
+
+// eventQueue is the workflow's pending event queue. It dispatches the event
+// with the highest effective priority first. To prevent starvation, a waiting
+// event gains one priority level for every aging dispatches made while it
+// waits, so a steady stream of high-priority events cannot hold back
+// lower-priority ones indefinitely.
+type eventQueue struct {
+	mu         sync.Mutex
+	items      []*queuedEvent
+	seq        uint64
+	dispatched uint64
+	aging      int
+	ready      chan struct{}
+}
+
+// newEventQueue creates an empty queue. aging <= 0 disables starvation
+// protection.
+func newEventQueue(aging int) *eventQueue {
+	return &eventQueue{
+		aging: aging,
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// push adds an event to the queue with the given priority.
+func (q *eventQueue) push(event Event, priority int) {
+	q.mu.Lock()
+	q.items = append(q.items, &queuedEvent{
+		event:      event,
+		priority:   priority,
+		seq:        q.seq,
+		enqueuedAt: q.dispatched,
+	})
+	q.seq++
+	q.mu.Unlock()
+	q.signal()
+}
+
+// pop removes and returns the event with the highest effective priority.
+func (q *eventQueue) pop() (Event, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
+		return nil, false
+	}
+
+	best := 0
+	bestPriority := q.effectivePriority(q.items[0])
+	for i := 1; i < len(q.items); i++ {
+		// Items are kept in send order, so a strictly greater priority is
+		// needed to overtake an earlier event.
+		if p := q.effectivePriority(q.items[i]); p > bestPriority {
+			best, bestPriority = i, p
+		}
+	}
+
+	item := q.items[best]
+	q.items = append(q.items[:best], q.items[best+1:]...)
+	q.dispatched++
+	if len(q.items) > 0 {
+		q.signal()
+	}
+	return item.event, true
+}
+
+// effectivePriority returns an item's priority including aging.
+func (q *eventQueue) effectivePriority(item *queuedEvent) int {
+	if q.aging <= 0 {
+		return item.priority
+	}
+	return item.priority + int((q.dispatched-item.enqueuedAt)/uint64(q.aging))
+}
+
+// len returns the number of pending events.
+func (q *eventQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// signal wakes up a waiting dispatcher without blocking.
+func (q *eventQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
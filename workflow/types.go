@@ -5,10 +5,17 @@ package workflow
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 )
 
+// ErrWorkflowCancelled is returned when a workflow run is cancelled by a
+// CancelEvent.
+var ErrWorkflowCancelled = errors.New("workflow cancelled")
+
 // EventType is a unique identifier for an event type.
 type EventType string
 
@@ -24,6 +31,7 @@ type Event interface {
 type BaseEvent struct {
 	eventType EventType
 	data      interface{}
+	priority  int
 }
 
 // Type returns the event type.
@@ -36,6 +44,11 @@ func (e *BaseEvent) Data() interface{} {
 	return e.data
 }
 
+// Priority returns the event's dispatch priority.
+func (e *BaseEvent) Priority() int {
+	return e.priority
+}
+
 // NewEvent creates a new event with the given type and data.
 func NewEvent(eventType EventType, data interface{}) *BaseEvent {
 	return &BaseEvent{
@@ -44,6 +57,16 @@ func NewEvent(eventType EventType, data interface{}) *BaseEvent {
 	}
 }
 
+// NewEventWithPriority creates a new event with the given type, data and
+// dispatch priority.
+func NewEventWithPriority(eventType EventType, data interface{}, priority int) *BaseEvent {
+	return &BaseEvent{
+		eventType: eventType,
+		data:      data,
+		priority:  priority,
+	}
+}
+
 // EventFactory creates typed events.
 type EventFactory[T any] struct {
 	eventType  EventType
@@ -82,6 +105,13 @@ func (f *EventFactory[T]) With(data T) *TypedEvent[T] {
 	}
 }
 
+// WithPriority creates a new event with the given data and dispatch priority.
+func (f *EventFactory[T]) WithPriority(data T, priority int) *TypedEvent[T] {
+	event := f.With(data)
+	event.priority = priority
+	return event
+}
+
 // Include checks if an event matches this factory's type.
 func (f *EventFactory[T]) Include(event Event) bool {
 	if event == nil {
@@ -123,25 +153,38 @@ type Context struct {
 	cancel     context.CancelFunc
 	workflow   *Workflow
 	state      *StateStore
-	eventQueue chan Event
+	queue      *eventQueue
+	priorities map[EventType]int
 	mu         sync.RWMutex
 	done       bool
 	timeout    time.Duration
 	startTime  time.Time
+
+	cancelled      bool
+	cancelReason   string
+	cleanups       []func(reason string)
+	cleanedUp      bool
+	completedSteps []string
+	abortedSteps   []string
 }
 
 // NewContext creates a new workflow context.
 func NewContext(ctx context.Context, workflow *Workflow, timeout time.Duration) *Context {
 	ctx, cancel := context.WithCancel(ctx)
-	return &Context{
-		ctx:        ctx,
-		cancel:     cancel,
-		workflow:   workflow,
-		state:      NewStateStore(),
-		eventQueue: make(chan Event, 1000),
-		timeout:    timeout,
-		startTime:  time.Now(),
+	c := &Context{
+		ctx:       ctx,
+		cancel:    cancel,
+		workflow:  workflow,
+		state:     NewStateStore(),
+		queue:     newEventQueue(DefaultEventAging),
+		timeout:   timeout,
+		startTime: time.Now(),
+	}
+	if workflow != nil {
+		c.queue = newEventQueue(workflow.eventAging)
+		c.priorities = workflow.eventPriorities()
 	}
+	return c
 }
 
 // Context returns the underlying context.Context.
@@ -149,22 +192,30 @@ func (c *Context) Context() context.Context {
 	return c.ctx
 }
 
-// SendEvent sends an event to the workflow for processing.
+// SendEvent sends an event to the workflow for processing. Pending events
+// are dispatched by priority (see PrioritizedEvent and
+// WithEventTypePriority), then in the order they were sent.
 func (c *Context) SendEvent(event Event) {
 	c.mu.RLock()
 	done := c.done
 	c.mu.RUnlock()
 
-	if done {
+	if done || c.ctx.Err() != nil || event == nil {
 		return
 	}
 
-	select {
-	case c.eventQueue <- event:
-	case <-c.ctx.Done():
+	c.queue.push(event, eventPriority(event, c.priorities))
+
+	if data, ok := CancelEvent.Extract(event); ok {
+		c.requestCancel(data.Reason)
 	}
 }
 
+// PendingEvents returns the number of events waiting to be dispatched.
+func (c *Context) PendingEvents() int {
+	return c.queue.len()
+}
+
 // State returns the state store for this context.
 func (c *Context) State() *StateStore {
 	return c.state
@@ -208,6 +259,102 @@ func (c *Context) Cancel() {
 	c.cancel()
 }
 
+// OnCancel registers a cleanup function that runs when the workflow is
+// cancelled by a CancelEvent. Cleanup functions run once, after the
+// in-flight step has returned, in reverse registration order.
+func (c *Context) OnCancel(cleanup func(reason string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cleanups = append(c.cleanups, cleanup)
+}
+
+// IsCancelled returns true if a CancelEvent has been sent.
+func (c *Context) IsCancelled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cancelled
+}
+
+// CancelReason returns the reason of the CancelEvent, if any.
+func (c *Context) CancelReason() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cancelReason
+}
+
+// CompletedSteps returns the names of the steps that ran to completion, in
+// completion order.
+func (c *Context) CompletedSteps() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]string{}, c.completedSteps...)
+}
+
+// AbortedSteps returns the names of the steps that were running when the
+// workflow was cancelled.
+func (c *Context) AbortedSteps() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]string{}, c.abortedSteps...)
+}
+
+// requestCancel records a cancellation and cancels the context observed by
+// in-flight steps.
+func (c *Context) requestCancel(reason string) {
+	c.mu.Lock()
+	if !c.cancelled {
+		c.cancelled = true
+		c.cancelReason = reason
+	}
+	c.mu.Unlock()
+	c.cancel()
+}
+
+// abort finishes a cancellation: it runs the cleanup functions and returns
+// the run error.
+func (c *Context) abort(reason string) error {
+	c.requestCancel(reason)
+
+	c.mu.Lock()
+	cleanups := c.cleanups
+	if c.cleanedUp {
+		cleanups = nil
+	}
+	c.cleanedUp = true
+	reason = c.cancelReason
+	c.mu.Unlock()
+
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i](reason)
+	}
+
+	if reason == "" {
+		return ErrWorkflowCancelled
+	}
+	return fmt.Errorf("%w: %s", ErrWorkflowCancelled, reason)
+}
+
+// recordStep records a finished step execution.
+func (c *Context) recordStep(step *Step, completed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if completed {
+		c.completedSteps = appendUnique(c.completedSteps, step.name())
+	} else {
+		c.abortedSteps = appendUnique(c.abortedSteps, step.name())
+	}
+}
+
+// appendUnique appends s to list unless it is already present.
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
 // IsDone returns true if the workflow has completed.
 func (c *Context) IsDone() bool {
 	c.mu.RLock()
@@ -395,6 +542,18 @@ type Step struct {
 	Config StepConfig
 }
 
+// name returns the step name, falling back to the accepted event types.
+func (s *Step) name() string {
+	if s.Config.Name != "" {
+		return s.Config.Name
+	}
+	types := make([]string, len(s.AcceptedEvents))
+	for i, t := range s.AcceptedEvents {
+		types[i] = string(t)
+	}
+	return strings.Join(types, ",")
+}
+
 // WorkflowResult represents the result of a workflow execution.
 type WorkflowResult struct {
 	// FinalEvent is the event that caused the workflow to stop.
@@ -405,6 +564,13 @@ type WorkflowResult struct {
 	Error error
 	// Duration is how long the workflow took to execute.
 	Duration time.Duration
+	// Cancelled is true if the workflow was stopped by a CancelEvent.
+	Cancelled bool
+	// CompletedSteps lists the steps that ran to completion.
+	CompletedSteps []string
+	// AbortedSteps lists the steps that were running when the workflow was
+	// cancelled.
+	AbortedSteps []string
 }
 
 // WorkflowStream provides streaming access to workflow events.
@@ -412,6 +578,7 @@ type WorkflowStream struct {
 	events  chan Event
 	done    chan struct{}
 	err     error
+	closed  bool
 	mu      sync.RWMutex
 	stopOn  []EventType
 	context *Context
@@ -489,11 +656,15 @@ func (s *WorkflowStream) emit(event Event) {
 	}
 }
 
-// close closes the stream.
+// close closes the stream. Only the first call takes effect.
 func (s *WorkflowStream) close(err error) {
 	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
 	s.err = err
-	s.mu.Unlock()
 	close(s.events)
 	close(s.done)
 }
@@ -18,6 +18,9 @@ type Workflow struct {
 	timeout  time.Duration
 	logger   *slog.Logger
 	mu       sync.RWMutex
+
+	typePriorities map[EventType]int
+	eventAging     int
 }
 
 // WorkflowOption configures a Workflow.
@@ -44,6 +47,25 @@ func WithWorkflowLogger(logger *slog.Logger) WorkflowOption {
 	}
 }
 
+// WithEventTypePriority sets the dispatch priority of events of the given
+// type that do not carry their own priority. Human response events default
+// to PriorityHigh.
+func WithEventTypePriority(eventType EventType, priority int) WorkflowOption {
+	return func(w *Workflow) {
+		w.typePriorities[eventType] = priority
+	}
+}
+
+// WithEventAging sets after how many dispatches a waiting event gains one
+// priority level, so low-priority events are not starved by a stream of
+// higher-priority ones. Zero or less disables aging. Defaults to
+// DefaultEventAging.
+func WithEventAging(dispatches int) WorkflowOption {
+	return func(w *Workflow) {
+		w.eventAging = dispatches
+	}
+}
+
 // NewWorkflow creates a new workflow.
 func NewWorkflow(opts ...WorkflowOption) *Workflow {
 	w := &Workflow{
@@ -52,6 +74,11 @@ func NewWorkflow(opts ...WorkflowOption) *Workflow {
 		handlers: make(map[EventType][]*Step),
 		timeout:  60 * time.Second,
 		logger:   slog.New(slog.NewJSONHandler(os.Stdout, nil)),
+		typePriorities: map[EventType]int{
+			HumanResponseEventType: PriorityHigh,
+			CancelEventType:        PriorityCritical,
+		},
+		eventAging: DefaultEventAging,
 	}
 
 	for _, opt := range opts {
@@ -91,6 +118,17 @@ func HandleTyped[T any](w *Workflow, factory *EventFactory[T], handler TypedHand
 	return w.Handle([]EventType{factory.Type()}, wrappedHandler, config...)
 }
 
+// eventPriorities returns a copy of the per-type event priorities.
+func (w *Workflow) eventPriorities() map[EventType]int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	priorities := make(map[EventType]int, len(w.typePriorities))
+	for t, p := range w.typePriorities {
+		priorities[t] = p
+	}
+	return priorities
+}
+
 // CreateContext creates a new execution context for this workflow.
 func (w *Workflow) CreateContext(ctx context.Context) *Context {
 	return NewContext(ctx, w, w.timeout)
@@ -110,8 +148,18 @@ func (w *Workflow) Run(ctx context.Context, startEvent Event) (*WorkflowResult,
 
 	for !wfCtx.IsDone() {
 		select {
-		case event := <-wfCtx.eventQueue:
-			if event == nil {
+		case <-wfCtx.queue.ready:
+			event, ok := wfCtx.queue.pop()
+			if !ok {
+				continue
+			}
+
+			// Check if this is a cancellation event
+			if CancelEvent.Include(event) {
+				data, _ := CancelEvent.Extract(event)
+				finalEvent = event
+				finalErr = wfCtx.abort(data.Reason)
+				wfCtx.markDone()
 				continue
 			}
 
@@ -156,13 +204,11 @@ func (w *Workflow) Run(ctx context.Context, startEvent Event) (*WorkflowResult,
 				wfCtx.markDone()
 			}
 			// Check if queue is empty and no more events expected
-			if len(wfCtx.eventQueue) == 0 {
+			if wfCtx.queue.len() == 0 {
 				// Give a small grace period for any pending events
 				select {
-				case event := <-wfCtx.eventQueue:
-					if event != nil {
-						wfCtx.SendEvent(event)
-					}
+				case <-wfCtx.queue.ready:
+					wfCtx.queue.signal()
 				case <-time.After(50 * time.Millisecond):
 					// No more events, we're done
 					wfCtx.markDone()
@@ -172,10 +218,13 @@ func (w *Workflow) Run(ctx context.Context, startEvent Event) (*WorkflowResult,
 	}
 
 	return &WorkflowResult{
-		FinalEvent: finalEvent,
-		State:      wfCtx.State(),
-		Error:      finalErr,
-		Duration:   time.Since(startTime),
+		FinalEvent:     finalEvent,
+		State:          wfCtx.State(),
+		Error:          finalErr,
+		Duration:       time.Since(startTime),
+		Cancelled:      wfCtx.IsCancelled(),
+		CompletedSteps: wfCtx.CompletedSteps(),
+		AbortedSteps:   wfCtx.AbortedSteps(),
 	}, finalErr
 }
 
@@ -192,8 +241,9 @@ func (w *Workflow) RunStream(ctx context.Context, startEvent Event) *WorkflowStr
 
 		for !wfCtx.IsDone() {
 			select {
-			case event := <-wfCtx.eventQueue:
-				if event == nil {
+			case <-wfCtx.queue.ready:
+				event, ok := wfCtx.queue.pop()
+				if !ok {
 					continue
 				}
 
@@ -206,6 +256,14 @@ func (w *Workflow) RunStream(ctx context.Context, startEvent Event) *WorkflowStr
 					continue
 				}
 
+				// Check if this is a cancellation event
+				if CancelEvent.Include(event) {
+					data, _ := CancelEvent.Extract(event)
+					stream.close(wfCtx.abort(data.Reason))
+					wfCtx.markDone()
+					return
+				}
+
 				// Check if this is a stop event
 				if StopEvent.Include(event) {
 					wfCtx.markDone()
@@ -247,12 +305,10 @@ func (w *Workflow) RunStream(ctx context.Context, startEvent Event) *WorkflowStr
 					return
 				}
 				// Check if queue is empty
-				if len(wfCtx.eventQueue) == 0 {
+				if wfCtx.queue.len() == 0 {
 					select {
-					case event := <-wfCtx.eventQueue:
-						if event != nil {
-							wfCtx.SendEvent(event)
-						}
+					case <-wfCtx.queue.ready:
+						wfCtx.queue.signal()
 					case <-time.After(50 * time.Millisecond):
 						wfCtx.markDone()
 					}
@@ -282,14 +338,24 @@ func (w *Workflow) processEvent(ctx *Context, event Event) ([]Event, error) {
 
 	var allEvents []Event
 	for _, step := range steps {
+		// Skip remaining work once cancellation was requested; the pending
+		// CancelEvent finishes the run.
+		if ctx.IsCancelled() {
+			return nil, nil
+		}
 		// Check timeout before each step
 		if ctx.IsTimedOut() {
 			return nil, fmt.Errorf("workflow timed out after %v", w.timeout)
 		}
 		events, err := w.executeStep(ctx, step, event)
+		if ctx.IsCancelled() {
+			ctx.recordStep(step, false)
+			return nil, nil
+		}
 		if err != nil {
 			return nil, err
 		}
+		ctx.recordStep(step, true)
 		allEvents = append(allEvents, events...)
 	}
 
@@ -313,7 +379,7 @@ func (w *Workflow) executeStep(ctx *Context, step *Step, event Event) ([]Event,
 		}
 
 		lastErr = err
-		if !policy.RetryOn(err) {
+		if !policy.RetryOn(err) || ctx.IsCancelled() {
 			return nil, err
 		}
 
@@ -324,7 +390,11 @@ func (w *Workflow) executeStep(ctx *Context, step *Step, event Event) ([]Event,
 				"max_retries", policy.MaxRetries,
 				"error", err,
 			)
-			time.Sleep(delay)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Context().Done():
+				return nil, err
+			}
 			delay = time.Duration(float64(delay) * policy.Multiplier)
 			if delay > policy.MaxDelay {
 				delay = policy.MaxDelay
@@ -750,3 +750,177 @@ func TestContext(t *testing.T) {
 		ctx.SendEvent(NewEvent("test", nil))
 	})
 }
+
+func TestEventPriority(t *testing.T) {
+	t.Run("Higher priority dispatched first", func(t *testing.T) {
+		q := newEventQueue(0)
+		q.push(NewEvent("a", nil), PriorityNormal)
+		q.push(NewEvent("b", nil), PriorityLow)
+		q.push(NewEvent("c", nil), PriorityHigh)
+		q.push(NewEvent("d", nil), PriorityHigh)
+
+		var order []EventType
+		for {
+			event, ok := q.pop()
+			if !ok {
+				break
+			}
+			order = append(order, event.Type())
+		}
+		assert.Equal(t, []EventType{"c", "d", "a", "b"}, order)
+	})
+
+	t.Run("Aging prevents starvation", func(t *testing.T) {
+		q := newEventQueue(2)
+		q.push(NewEvent("low", nil), PriorityNormal)
+
+		var order []EventType
+		for i := 0; i < 4; i++ {
+			q.push(NewEvent("high", nil), 1)
+			event, _ := q.pop()
+			order = append(order, event.Type())
+		}
+		assert.Contains(t, order, EventType("low"))
+
+		q = newEventQueue(0)
+		q.push(NewEvent("low", nil), PriorityNormal)
+		order = nil
+		for i := 0; i < 4; i++ {
+			q.push(NewEvent("high", nil), 1)
+			event, _ := q.pop()
+			order = append(order, event.Type())
+		}
+		assert.NotContains(t, order, EventType("low"))
+	})
+
+	t.Run("Event and type priorities", func(t *testing.T) {
+		assert.Equal(t, PriorityHigh, NewEventWithPriority("x", nil, PriorityHigh).Priority())
+		assert.Equal(t, PriorityCritical, ProcessEvent.WithPriority(ProcessData{Value: 1}, PriorityCritical).Priority())
+
+		priorities := map[EventType]int{HumanResponseEventType: PriorityHigh}
+		assert.Equal(t, PriorityHigh, eventPriority(NewHumanResponseEvent("yes"), priorities))
+		assert.Equal(t, PriorityLow, eventPriority(HumanResponseEvent.WithPriority(HumanResponseEventData{}, PriorityLow), priorities))
+		assert.Equal(t, PriorityNormal, eventPriority(NewEvent("other", nil), priorities))
+	})
+
+	t.Run("Workflow dispatches by priority", func(t *testing.T) {
+		var order []int
+		w := NewWorkflow(
+			WithEventTypePriority(CompleteEventType, PriorityLow),
+			WithEventAging(0),
+		)
+		w.Handle([]EventType{StartEventType}, func(ctx *Context, event Event) ([]Event, error) {
+			return []Event{
+				CompleteEvent.With(CompleteData{Result: 1}),
+				ProcessEvent.With(ProcessData{Value: 2}),
+				ProcessEvent.WithPriority(ProcessData{Value: 3}, PriorityHigh),
+			}, nil
+		})
+		w.Handle([]EventType{ProcessEventType}, func(ctx *Context, event Event) ([]Event, error) {
+			data, _ := ProcessEvent.Extract(event)
+			order = append(order, data.Value)
+			return nil, nil
+		})
+		w.Handle([]EventType{CompleteEventType}, func(ctx *Context, event Event) ([]Event, error) {
+			data, _ := CompleteEvent.Extract(event)
+			order = append(order, data.Result)
+			return []Event{NewStopEvent(order)}, nil
+		})
+
+		result, err := w.Run(context.Background(), NewStartEvent(nil))
+		require.NoError(t, err)
+		assert.Equal(t, []int{3, 2, 1}, order)
+		assert.NotNil(t, result.FinalEvent)
+	})
+
+	t.Run("Pending events", func(t *testing.T) {
+		ctx := NewWorkflow().CreateContext(context.Background())
+		ctx.SendEvent(NewEvent("a", nil))
+		ctx.SendEvent(nil)
+		assert.Equal(t, 1, ctx.PendingEvents())
+	})
+}
+
+func TestCancellation(t *testing.T) {
+	t.Run("In-flight step observes cancellation", func(t *testing.T) {
+		var cleanups []string
+		w := NewWorkflow()
+		w.Handle([]EventType{StartEventType}, func(ctx *Context, event Event) ([]Event, error) {
+			return []Event{ProcessEvent.With(ProcessData{Value: 1})}, nil
+		}, StepConfig{Name: "prepare"})
+		w.Handle([]EventType{ProcessEventType}, func(ctx *Context, event Event) ([]Event, error) {
+			ctx.OnCancel(func(reason string) { cleanups = append(cleanups, "first:"+reason) })
+			ctx.OnCancel(func(reason string) { cleanups = append(cleanups, "second:"+reason) })
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				ctx.SendEvent(NewCancelEvent("user abort"))
+			}()
+			<-ctx.Context().Done()
+			return nil, ctx.Context().Err()
+		}, StepConfig{Name: "slow"})
+
+		result, err := w.Run(context.Background(), NewStartEvent(nil))
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrWorkflowCancelled)
+		assert.Contains(t, err.Error(), "user abort")
+		assert.True(t, result.Cancelled)
+		assert.True(t, CancelEvent.Include(result.FinalEvent))
+		assert.Equal(t, []string{"prepare"}, result.CompletedSteps)
+		assert.Equal(t, []string{"slow"}, result.AbortedSteps)
+		assert.Equal(t, []string{"second:user abort", "first:user abort"}, cleanups)
+	})
+
+	t.Run("Cancel event preempts pending events", func(t *testing.T) {
+		var processed atomic.Bool
+		w := NewWorkflow()
+		w.Handle([]EventType{StartEventType}, func(ctx *Context, event Event) ([]Event, error) {
+			return []Event{
+				ProcessEvent.With(ProcessData{Value: 1}),
+				NewCancelEvent(""),
+			}, nil
+		})
+		w.Handle([]EventType{ProcessEventType}, func(ctx *Context, event Event) ([]Event, error) {
+			processed.Store(true)
+			return nil, nil
+		})
+
+		result, err := w.Run(context.Background(), NewStartEvent(nil))
+		assert.Equal(t, ErrWorkflowCancelled, err)
+		assert.True(t, result.Cancelled)
+		assert.Empty(t, result.AbortedSteps)
+		assert.False(t, processed.Load())
+	})
+
+	t.Run("Retries stop on cancellation", func(t *testing.T) {
+		var attempts atomic.Int32
+		w := NewWorkflow()
+		w.Handle([]EventType{StartEventType}, func(ctx *Context, event Event) ([]Event, error) {
+			if attempts.Add(1) == 1 {
+				ctx.SendEvent(NewCancelEvent("stop"))
+			}
+			return nil, errors.New("transient")
+		}, StepConfig{Name: "flaky", RetryPolicy: DefaultRetryPolicy()})
+
+		result, err := w.Run(context.Background(), NewStartEvent(nil))
+		assert.ErrorIs(t, err, ErrWorkflowCancelled)
+		assert.Equal(t, int32(1), attempts.Load())
+		assert.Equal(t, []string{"flaky"}, result.AbortedSteps)
+	})
+
+	t.Run("Stream closes with cancellation error", func(t *testing.T) {
+		w := NewWorkflow()
+		w.Handle([]EventType{StartEventType}, func(ctx *Context, event Event) ([]Event, error) {
+			return []Event{NewCancelEvent("")}, nil
+		})
+
+		events, err := w.RunStream(context.Background(), NewStartEvent(nil)).ToArray()
+		assert.ErrorIs(t, err, ErrWorkflowCancelled)
+		require.NotEmpty(t, events)
+		assert.True(t, CancelEvent.Include(events[len(events)-1]))
+	})
+
+	t.Run("Step names default to event types", func(t *testing.T) {
+		step := &Step{AcceptedEvents: []EventType{ProcessEventType, CompleteEventType}}
+		assert.Equal(t, "test.process,test.complete", step.name())
+	})
+}